@@ -4,18 +4,41 @@ import (
 	"context"
 	"flag"
 	"fmt"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"path/filepath"
+	"strconv"
 	"syscall"
 	"time"
 
 	"synthezia/internal/api"
 	"synthezia/internal/auth"
 	"synthezia/internal/config"
+	"synthezia/internal/convert"
 	"synthezia/internal/database"
+	"synthezia/internal/devices"
+	"synthezia/internal/dropzone"
+	"synthezia/internal/errreport"
+	"synthezia/internal/faults"
+	"synthezia/internal/ffmpegbin"
+	"synthezia/internal/jobcrypt"
+	"synthezia/internal/jobparams"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/processing"
+	"synthezia/internal/proxyaudio"
 	"synthezia/internal/queue"
+	"synthezia/internal/remoteworker"
+	"synthezia/internal/retention"
+	"synthezia/internal/scheduler"
+	"synthezia/internal/search"
+	"synthezia/internal/sourceaudio"
+	"synthezia/internal/storage"
+	"synthezia/internal/telemetry"
 	"synthezia/internal/transcription"
+	"synthezia/internal/usage"
+	"synthezia/pkg/fsys"
 	"synthezia/pkg/logger"
 
 	_ "synthezia/api-docs"                        // Import generated Swagger docs
@@ -56,6 +79,10 @@ var (
 func main() {
 	// Handle version flag
 	var showVersion = flag.Bool("version", false, "Show version information")
+	flag.Usage = func() {
+		fmt.Fprintf(flag.CommandLine.Output(), "Usage: %s [flags]\n       %s migrate [status|up|down [steps]|to <version>]\n\nFlags:\n", os.Args[0], os.Args[0])
+		flag.PrintDefaults()
+	}
 	flag.Parse()
 
 	if *showVersion {
@@ -69,9 +96,100 @@ func main() {
 	logger.Init(os.Getenv("LOG_LEVEL"))
 	logger.Info("Starting SynthezIA", "version", version)
 
+	// Fault injection is for integration testing only and is off unless FAULT_INJECTION is set
+	if err := faults.LoadFromEnv(); err != nil {
+		logger.Error("Invalid fault injection configuration", "error", err)
+		os.Exit(1)
+	}
+
 	// Load configuration
 	logger.Startup("config", "Loading configuration")
 	cfg := config.Load()
+	// The config file may set the log levels, which Init reads from the
+	// environment
+	logger.Init(os.Getenv("LOG_LEVEL"))
+
+	// "synthezia migrate ..." manages the schema and exits
+	if flag.Arg(0) == "migrate" {
+		os.Exit(runMigrate(cfg, flag.Args()[1:]))
+	}
+
+	// Send logs to syslog or journald where logs are collected centrally
+	if err := logger.ConfigureSinks(logger.SinkConfig{
+		Outputs:        logger.ParseOutputs(cfg.LogOutput),
+		SyslogAddress:  cfg.SyslogAddress,
+		SyslogFacility: cfg.SyslogFacility,
+		JournaldSocket: cfg.JournaldSocket,
+	}); err != nil {
+		logger.Error("Invalid log output configuration", "error", err)
+		os.Exit(1)
+	}
+	logger.SetBufferSize(cfg.LogBufferSize)
+	samplingRules, err := logger.ParseSampleRules(cfg.LogHTTPSampling)
+	if err != nil {
+		logger.Error("Invalid HTTP log sampling configuration", "error", err)
+		os.Exit(1)
+	}
+	logger.SetHTTPSampling(samplingRules)
+	logger.SetSlowThresholds(logger.SlowThresholds{
+		Request: time.Duration(cfg.SlowRequestMs) * time.Millisecond,
+		Query:   time.Duration(cfg.SlowQueryMs) * time.Millisecond,
+		FFmpeg:  time.Duration(cfg.SlowFFmpegMs) * time.Millisecond,
+	})
+
+	// Tracing is off unless an OTLP endpoint is configured
+	if err := telemetry.Init(telemetry.Config{
+		Endpoint:    cfg.OTLPEndpoint,
+		ServiceName: cfg.TraceServiceName,
+		Headers:     telemetry.ParseHeaders(cfg.OTLPHeaders),
+	}); err != nil {
+		logger.Error("Invalid tracing configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// Error reporting is off unless a Sentry-compatible DSN is configured
+	if err := errreport.Init(errreport.Config{
+		DSN:         cfg.SentryDSN,
+		Environment: cfg.SentryEnvironment,
+		Release:     "synthezia@" + version,
+	}); err != nil {
+		logger.Error("Invalid error reporting configuration", "error", err)
+		os.Exit(1)
+	}
+
+	// A job left with the defaults must be allowed by the limits
+	if err := jobparams.FromConfig(cfg).Check(); err != nil {
+		logger.Error("Invalid transcription defaults", "error", err)
+		os.Exit(1)
+	}
+
+	// Intermediate files are renamed into the upload directory, which is only
+	// atomic when both live on the same filesystem
+	logger.Startup("storage", "Preparing upload and temp directories")
+	if err := prepareTempDir(cfg); err != nil {
+		logger.Error("Failed to prepare temp directory", "error", err)
+		os.Exit(1)
+	}
+
+	// Every audio step runs ffmpeg, so find it before anything needs it
+	logger.Startup("ffmpeg", "Checking ffmpeg")
+	checksums, err := ffmpegbin.ParseChecksums(cfg.FFmpegDownloadSHA256)
+	if err != nil {
+		logger.Error("Invalid ffmpeg checksum configuration", "error", err)
+		os.Exit(1)
+	}
+	ffmpegStatus := ffmpegbin.Startup(context.Background(), ffmpegbin.Options{
+		Dir:         cfg.FFmpegDir,
+		AutoInstall: cfg.FFmpegAutoInstall,
+		InstallDir:  cfg.FFmpegInstallDir,
+		DownloadURL: cfg.FFmpegDownloadURL,
+		SHA256:      checksums,
+	})
+	if ffmpegStatus.Healthy {
+		logger.Info("ffmpeg ready", "version", ffmpegStatus.Version, "path", ffmpegStatus.FFmpegPath, "installed", ffmpegStatus.Installed)
+	} else {
+		logger.Warn("ffmpeg is not usable; audio processing will fail", "error", ffmpegStatus.Error)
+	}
 
 	// Initialize database
 	logger.Startup("database", "Connecting to database")
@@ -81,6 +199,19 @@ func main() {
 	}
 	defer database.Close()
 
+	// Job lifecycle events go to their own stream, and optionally the database
+	if cfg.JobEventsLog != "" {
+		closeJobEvents, err := openJobEventLog(cfg.JobEventsLog)
+		if err != nil {
+			logger.Error("Failed to open job event log", "path", cfg.JobEventsLog, "error", err)
+			os.Exit(1)
+		}
+		defer closeJobEvents()
+	}
+	if cfg.JobEventsDB {
+		defer jobstate.RecordEvents()()
+	}
+
 	// Initialize authentication service
 	logger.Startup("auth", "Setting up authentication")
 	authService := auth.NewAuthService(cfg.JWTSecret)
@@ -88,6 +219,12 @@ func main() {
 	// Initialize unified transcription processor
 	logger.Startup("transcription", "Initializing transcription service")
 	unifiedProcessor := transcription.NewUnifiedJobProcessor()
+	if err := unifiedProcessor.SetBackend(cfg.TranscriptionBackend); err != nil {
+		logger.Error("Invalid transcription backend", "error", err)
+		os.Exit(1)
+	}
+	unifiedProcessor.SetTempDirectory(cfg.TempDir)
+	unifiedProcessor.SetChunking(time.Duration(cfg.TranscriptionChunkMinutes)*time.Minute, cfg.TranscriptionChunkWorkers)
 
 	// Bootstrap embedded Python environment (for all adapters)
 	logger.Startup("python", "Preparing Python environment")
@@ -112,14 +249,283 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Initialize task queue
-	logger.Startup("queue", "Starting background processing")
-	taskQueue := queue.NewTaskQueue(2, unifiedProcessor) // 2 workers
+	// Remote workers run the models when the backend is remote, so the
+	// queue is not tied to this host's devices
+	var remoteWorkers *remoteworker.Dispatcher
+	var taskQueue *queue.TaskQueue
+	if cfg.TranscriptionBackend == transcription.BackendRemote {
+		if cfg.WorkerToken == "" {
+			logger.Error("WORKER_TOKEN is required by the remote transcription backend")
+			os.Exit(1)
+		}
+		remoteWorkers = remoteworker.NewDispatcher(time.Duration(cfg.WorkerHeartbeatSeconds) * time.Second)
+		unifiedProcessor.SetExecutor(remoteWorkers)
+		stopRemoteWorkers := make(chan struct{})
+		defer close(stopRemoteWorkers)
+		go remoteWorkers.Run(stopRemoteWorkers, 5*time.Second)
+
+		// Initialize task queue, sized by QUEUE_WORKERS
+		logger.Startup("queue", "Starting background processing")
+		taskQueue = queue.NewTaskQueue(0, unifiedProcessor)
+	} else {
+		// Find the GPUs jobs are spread across
+		logger.Startup("devices", "Discovering devices")
+		deviceManager := devices.NewManager(devices.Options{
+			NvidiaSMI: cfg.NvidiaSMIPath,
+			GPUSlots:  cfg.GPUJobsPerDevice,
+			CPUSlots:  cfg.CPUJobs,
+		})
+		if err := deviceManager.Discover(context.Background()); err != nil {
+			logger.Warn("Failed to discover GPUs, running jobs on the CPU", "error", err)
+		}
+
+		// Initialize task queue, with a worker for every GPU slot
+		logger.Startup("queue", "Starting background processing")
+		taskQueue = queue.NewTaskQueue(max(2, deviceManager.GPUSlots()), unifiedProcessor)
+		taskQueue.SetDevices(deviceManager)
+	}
+	// Share the workers between users instead of running jobs in order
+	userWeights, err := queue.ParseUserWeights(cfg.QueueUserWeights)
+	if err != nil {
+		logger.Error("Invalid QUEUE_USER_WEIGHTS", "error", err)
+		os.Exit(1)
+	}
+	taskQueue.SetFairShare(cfg.QueueFairShare, userWeights)
+	taskQueue.SetTaskConcurrency(cfg.MaxConcurrentConversions)
+
+	// Requeue or fail the jobs the last shutdown or crash left processing,
+	// before the workers take any
+	recoveryPolicy, err := queue.ParseRecoveryPolicy(cfg.QueueRecoveryPolicy)
+	if err != nil {
+		logger.Error("Invalid QUEUE_RECOVERY_POLICY", "error", err)
+		os.Exit(1)
+	}
+	if recovered, err := taskQueue.RecoverJobs(recoveryPolicy, cfg.QueueRecoveryMaxAttempts); err != nil {
+		logger.Warn("Failed to recover interrupted jobs", "error", err)
+	} else if recovered.Requeued > 0 || recovered.Failed > 0 {
+		logger.Info("Recovered jobs interrupted by the last shutdown", "requeued", recovered.Requeued, "failed", recovered.Failed)
+	}
 	taskQueue.Start()
+	taskQueue.RegisterMetrics()
 	defer taskQueue.Stop()
 
+	// Ingest audio dropped into the configured watch roots
+	dropzoneService := dropzone.NewService(cfg, taskQueue)
+	multiTrackProcessor := processing.NewMultiTrackProcessor()
+	multiTrackProcessor.SetTempDir(cfg.TempDir)
+	multiTrackProcessor.SetMaxConcurrentMerges(cfg.MaxConcurrentMerges)
+	dropzoneService.SetMultiTrackProcessor(multiTrackProcessor)
+	if resumed, err := multiTrackProcessor.ResumeMerges(); err != nil {
+		logger.Warn("Failed to resume interrupted merges", "error", err)
+	} else if resumed > 0 {
+		logger.Info("Resuming interrupted multi-track merges", "count", resumed)
+	}
+	if cfg.DropzonePaths != "" {
+		logger.Startup("dropzone", "Watching dropzone roots")
+		if err := dropzoneService.Start(); err != nil {
+			logger.Error("Failed to start dropzone", "error", err)
+			os.Exit(1)
+		}
+		defer dropzoneService.Stop()
+		if cfg.DropzoneRetention == dropzone.RetentionArchive && cfg.DropzoneArchiveDays > 0 {
+			stopProcessedCleanup := make(chan struct{})
+			defer close(stopProcessedCleanup)
+			go dropzoneService.RunProcessedCleanup(stopProcessedCleanup, time.Hour)
+		}
+		if cfg.DropzoneSweepSchedule != "" {
+			if err := scheduler.Default.Add("dropzone_sweep", cfg.DropzoneSweepSchedule, dropzoneService.Sweep); err != nil {
+				logger.Error("Invalid DROPZONE_SWEEP_SCHEDULE", "error", err)
+				os.Exit(1)
+			}
+		}
+	}
+
+	// Release jobs scheduled to run later and run recurring tasks
+	scheduler.Default.SetEnqueue(taskQueue.EnqueueJob)
+	stopScheduler := make(chan struct{})
+	defer close(stopScheduler)
+	go scheduler.Default.Run(stopScheduler, 30*time.Second)
+
+	// Ingest audio uploaded to an S3-compatible bucket
+	if cfg.DropzoneS3Bucket != "" {
+		logger.Startup("dropzone", "Watching bucket "+cfg.DropzoneS3Bucket)
+		bucketWatcher, err := dropzone.NewObjectStorageWatcher(dropzoneService, cfg)
+		if err != nil {
+			logger.Error("Failed to start bucket watcher", "error", err)
+			os.Exit(1)
+		}
+		stopBucketWatcher := make(chan struct{})
+		defer close(stopBucketWatcher)
+		go bucketWatcher.Run(stopBucketWatcher, time.Duration(cfg.DropzoneS3PollSeconds)*time.Second)
+	}
+
+	// Accept recordings pushed over SFTP
+	if cfg.DropzoneSFTPAddr != "" {
+		logger.Startup("dropzone", "Starting SFTP server")
+		hostKey, err := dropzone.LoadHostKey(cfg.DropzoneSFTPHostKey)
+		if err != nil {
+			logger.Error("Failed to load SFTP host key", "error", err)
+			os.Exit(1)
+		}
+		sftpServer, err := dropzone.NewSFTPServer(dropzoneService, hostKey, cfg.DropzoneSFTPOptions)
+		if err != nil {
+			logger.Error("Failed to start SFTP server", "error", err)
+			os.Exit(1)
+		}
+		listener, err := net.Listen("tcp", cfg.DropzoneSFTPAddr)
+		if err != nil {
+			logger.Error("Failed to listen for SFTP", "address", cfg.DropzoneSFTPAddr, "error", err)
+			os.Exit(1)
+		}
+		go func() {
+			if err := sftpServer.Serve(listener); err != nil {
+				logger.Error("SFTP server stopped", "error", err)
+			}
+		}()
+		defer sftpServer.Close()
+	}
+
+	// Ingest audio mailed to an IMAP mailbox
+	if cfg.DropzoneIMAPAddr != "" {
+		logger.Startup("dropzone", "Watching mailbox "+cfg.DropzoneIMAPMailbox)
+		mailboxWatcher, err := dropzone.NewMailboxWatcher(dropzoneService, cfg)
+		if err != nil {
+			logger.Error("Failed to start mailbox watcher", "error", err)
+			os.Exit(1)
+		}
+		stopMailReplies := mailboxWatcher.Track()
+		defer stopMailReplies()
+		stopMailboxWatcher := make(chan struct{})
+		defer close(stopMailboxWatcher)
+		go mailboxWatcher.Run(stopMailboxWatcher, time.Duration(cfg.DropzoneIMAPPollSeconds)*time.Second)
+	}
+
+	// Sources other than dropzone folders stage ingests too; finish or undo
+	// those a crash interrupted (Start does this for the dropzone)
+	if cfg.DropzonePaths == "" && (cfg.DropzoneS3Bucket != "" || cfg.DropzoneSFTPAddr != "" || cfg.DropzoneIMAPAddr != "") {
+		if err := dropzoneService.RecoverStaged(); err != nil {
+			logger.Warn("Failed to recover staged ingests", "error", err)
+		}
+	}
+
 	// Initialize API handlers
 	handler := api.NewHandler(cfg, authService, taskQueue, unifiedProcessor, liveTranscriptionService, quickTranscriptionService)
+	handler.SetMultiTrackProcessor(multiTrackProcessor)
+	handler.SetDropzone(dropzoneService)
+	handler.SetRemoteWorkers(remoteWorkers)
+
+	// Attribute transcribed audio to API keys and check key usage for anomalies
+	stopUsageTracking := usage.Default.TrackJobs()
+	defer stopUsageTracking()
+	stopUsageChecks := make(chan struct{})
+	defer close(stopUsageChecks)
+	go usage.Default.Run(stopUsageChecks, 5*time.Minute)
+
+	// Index transcripts for full-text search as jobs complete, catching up
+	// on those finished while the index was missing them
+	stopSearchIndexing := search.Default.TrackJobs()
+	defer stopSearchIndexing()
+	go func() {
+		indexed, err := search.Default.Backfill()
+		if err != nil {
+			logger.Warn("Failed to backfill the transcript search index", "error", err)
+		} else if indexed > 0 {
+			logger.Info("Indexed transcripts for search", "jobs", indexed)
+		}
+	}()
+
+	// Delete or proxy source audio once transcripts are final
+	if err := sourceaudio.Default.SetDefaultAction(cfg.SourceAudioAction); err != nil {
+		logger.Error("Invalid SOURCE_AUDIO_ACTION", "error", err)
+		os.Exit(1)
+	}
+	sourceaudio.Default.SetTempDir(cfg.TempDir)
+	stopSourceAudio := sourceaudio.Default.Track()
+	defer stopSourceAudio()
+
+	// Seal jobs submitted with a client key once they complete
+	jobcrypt.Default.SetTempDir(cfg.TempDir)
+	jobcrypt.Default.OnSealed(func(jobID string) {
+		if err := sourceaudio.Default.Apply(context.Background(), jobID); err != nil {
+			logger.Warn("Failed to apply source audio action", "job_id", jobID, "error", err)
+		}
+	})
+	stopJobEncryption := jobcrypt.Default.Track()
+	defer stopJobEncryption()
+
+	// Encode small playback copies of new and existing recordings
+	if cfg.PlaybackProxyEnabled {
+		proxyaudio.Default.SetTempDir(cfg.TempDir)
+		proxyaudio.Default.SetBitrate(cfg.PlaybackProxyBitrate)
+		stopPlaybackProxies := make(chan struct{})
+		defer close(stopPlaybackProxies)
+		go proxyaudio.Default.Run(stopPlaybackProxies, 30*time.Second)
+	}
+
+	// Move the media of completed jobs to the storage backend
+	storageBackend, err := storage.NewBackend(cfg)
+	if err != nil {
+		logger.Error("Invalid STORAGE_BACKEND", "error", err)
+		os.Exit(1)
+	}
+	if storageBackend != nil {
+		storage.Default.SetBackend(storageBackend)
+		storage.Default.SetKeepLocal(cfg.StorageKeepLocal)
+		storage.Default.SetURLExpiry(time.Duration(cfg.StorageURLExpiryMinutes) * time.Minute)
+		stopStorage := make(chan struct{})
+		defer close(stopStorage)
+		go storage.Default.Run(stopStorage, 30*time.Second)
+		logger.Info("Storing job media", "backend", storageBackend.Name())
+	}
+
+	// Delete or archive the audio of old jobs as the retention policies say
+	retentionPolicies, err := retention.ParsePolicies(cfg.RetentionPolicies)
+	if err != nil {
+		logger.Error("Invalid RETENTION_POLICIES", "error", err)
+		os.Exit(1)
+	}
+	if len(retentionPolicies) > 0 {
+		retention.Default.SetPolicies(retentionPolicies)
+		retention.Default.SetArchiveDir(cfg.RetentionArchiveDir)
+		retention.Default.SetDryRun(cfg.RetentionDryRun)
+		stopRetention := make(chan struct{})
+		defer close(stopRetention)
+		go retention.Default.Run(stopRetention, time.Duration(max(1, cfg.RetentionIntervalMinutes))*time.Minute)
+	}
+
+	// Pick up summary regenerations interrupted by the last shutdown
+	handler.ResumeSummaryRegenerations()
+
+	// Translate finished transcripts into the languages their jobs ask for,
+	// picking up translations interrupted by the last shutdown
+	stopTranslations := handler.TrackTranslations()
+	defer stopTranslations()
+	handler.ResumeTranslations()
+
+	// Draw summaries, action items and chapters from finished transcripts
+	// when a post-processing endpoint is configured
+	stopInsights := handler.TrackInsights()
+	defer stopInsights()
+	handler.ResumeInsights()
+
+	// Tell registered webhooks when jobs complete or fail, retrying
+	// deliveries interrupted by the last shutdown
+	stopWebhooks := handler.TrackWebhooks()
+	defer stopWebhooks()
+	handler.ResumeWebhooks()
+
+	// Restart downloads of jobs submitted by URL interrupted by the last shutdown
+	handler.ResumeRemoteDownloads()
+
+	// Requeue audio conversions interrupted by the last shutdown
+	convert.Default.SetOutputDir(filepath.Join(cfg.UploadDir, "conversions"))
+	convert.Default.SetTempDir(cfg.TempDir)
+	handler.ResumeAudioConversions()
+
+	// Download and load the default Whisper model while the server starts
+	if cfg.ModelWarmup {
+		handler.WarmUpDefaultModel()
+	}
 
 	// Set up router
 	router := api.SetupRoutes(handler, authService)
@@ -144,6 +550,7 @@ func main() {
 	logger.Info("SynthezIA is ready",
 		"url", fmt.Sprintf("http://%s:%s", cfg.Host, cfg.Port))
 	logger.Debug("API documentation available at /swagger/index.html")
+	handler.LogCapabilities(context.Background())
 
 	// Wait for interrupt signal to gracefully shutdown the server
 	quit := make(chan os.Signal, 1)
@@ -162,5 +569,138 @@ func main() {
 		os.Exit(1)
 	}
 
+	// Interrupt running merges, keeping their finished track renders for the next start
+	multiTrackProcessor.Stop()
+
+	// Flush buffered spans before exiting
+	if err := telemetry.Shutdown(ctx); err != nil {
+		logger.Warn("Failed to flush traces", "error", err)
+	}
+
+	// Send queued error reports before exiting
+	if err := errreport.Shutdown(ctx); err != nil {
+		logger.Warn("Failed to send error reports", "error", err)
+	}
+
 	logger.Info("Server stopped")
 }
+
+// prepareTempDir creates the upload and temp directories and points TMPDIR at
+// the temp directory, so multipart uploads spilled to disk land there too
+func prepareTempDir(cfg *config.Config) error {
+	for _, dir := range []string{cfg.UploadDir, cfg.TempDir} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return err
+		}
+	}
+	absTempDir, err := filepath.Abs(cfg.TempDir)
+	if err != nil {
+		return err
+	}
+	if err := os.Setenv("TMPDIR", absTempDir); err != nil {
+		return err
+	}
+
+	same, err := fsys.SameDevice(cfg.TempDir, cfg.UploadDir)
+	if err != nil {
+		return err
+	}
+	if !same {
+		return fmt.Errorf("TEMP_DIR %s must be on the same filesystem as UPLOAD_DIR %s", cfg.TempDir, cfg.UploadDir)
+	}
+	return nil
+}
+
+// openJobEventLog sends job lifecycle events to path, or to standard output
+// for "stdout", and returns a function that stops and closes the stream
+func openJobEventLog(path string) (func(), error) {
+	if path == "stdout" {
+		logger.SetJobEventOutput(os.Stdout)
+		return func() { logger.SetJobEventOutput(nil) }, nil
+	}
+	if dir := filepath.Dir(path); dir != "." {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, err
+		}
+	}
+	file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return nil, err
+	}
+	logger.SetJobEventOutput(file)
+	return func() {
+		logger.SetJobEventOutput(nil)
+		file.Close()
+	}, nil
+}
+
+// runMigrate runs the migrate subcommand: status lists migrations, up
+// applies pending ones, down reverts the newest (one by default) and to
+// moves to a version, 0 reverting everything. It returns the exit code.
+func runMigrate(cfg *config.Config, args []string) int {
+	command := "status"
+	if len(args) > 0 {
+		command = args[0]
+	}
+	number := func(fallback int) (int, bool) {
+		if len(args) < 2 {
+			return fallback, fallback >= 0
+		}
+		n, err := strconv.Atoi(args[1])
+		return n, err == nil && n >= 0 && len(args) == 2
+	}
+
+	if err := database.Open(cfg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer database.Close()
+
+	var count int
+	var err error
+	switch command {
+	case "status":
+		statuses, err := database.Status(database.DB)
+		if err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		for _, status := range statuses {
+			applied := "pending"
+			if status.AppliedAt != nil {
+				applied = "applied " + status.AppliedAt.Format(time.RFC3339)
+			}
+			fmt.Printf("%4d  %-40s %s\n", status.Version, status.Name, applied)
+		}
+		return 0
+	case "up":
+		count, err = database.Migrate(database.DB)
+	case "down":
+		steps, ok := number(1)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "usage: migrate down [steps]")
+			return 2
+		}
+		count, err = database.Rollback(database.DB, steps)
+	case "to":
+		version, ok := number(-1)
+		if !ok {
+			fmt.Fprintln(os.Stderr, "usage: migrate to <version>")
+			return 2
+		}
+		count, err = database.MigrateTo(database.DB, version)
+	default:
+		fmt.Fprintf(os.Stderr, "unknown migrate command %q, expected status, up, down or to\n", command)
+		return 2
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		if count > 0 {
+			fmt.Fprintf(os.Stderr, "%d migrations ran before the failure\n", count)
+		}
+		return 1
+	}
+	version, _ := database.CurrentVersion(database.DB)
+	fmt.Printf("Ran %d migrations; schema is at version %d of %d\n", count, version, database.LatestVersion())
+	return 0
+}
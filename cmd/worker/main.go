@@ -0,0 +1,102 @@
+// Command worker runs transcriptions for a SynthezIA server using the remote
+// backend. It registers with WORKER_SERVER_URL using WORKER_TOKEN, takes
+// tasks and runs the models on this machine's GPUs.
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"synthezia/internal/config"
+	"synthezia/internal/devices"
+	"synthezia/internal/remoteworker"
+	"synthezia/internal/transcription"
+	"synthezia/pkg/logger"
+
+	_ "synthezia/internal/transcription/adapters" // Import adapters for auto-registration
+)
+
+// Version information (set by GoReleaser)
+var (
+	version = "dev"
+	commit  = "none"
+	date    = "unknown"
+)
+
+func main() {
+	var showVersion = flag.Bool("version", false, "Show version information")
+	flag.Parse()
+
+	if *showVersion {
+		fmt.Printf("SynthezIA worker %s\n", version)
+		fmt.Printf("Commit: %s\n", commit)
+		fmt.Printf("Built: %s\n", date)
+		os.Exit(0)
+	}
+
+	logger.Init(os.Getenv("LOG_LEVEL"))
+	logger.Info("Starting SynthezIA worker", "version", version)
+
+	cfg := config.Load()
+	// The config file may set the log levels, which Init reads from the
+	// environment
+	logger.Init(os.Getenv("LOG_LEVEL"))
+	if cfg.WorkerServerURL == "" || cfg.WorkerToken == "" {
+		logger.Error("WORKER_SERVER_URL and WORKER_TOKEN are required")
+		os.Exit(1)
+	}
+
+	// The worker runs the models itself, whatever backend its server uses
+	service := transcription.NewUnifiedTranscriptionService()
+	backend := cfg.TranscriptionBackend
+	if backend == transcription.BackendRemote {
+		backend = transcription.BackendModels
+	}
+	if err := service.SetBackend(backend); err != nil {
+		logger.Error("Invalid transcription backend", "error", err)
+		os.Exit(1)
+	}
+	service.SetTempDirectory(cfg.TempDir)
+	service.SetChunking(time.Duration(cfg.TranscriptionChunkMinutes)*time.Minute, cfg.TranscriptionChunkWorkers)
+
+	logger.Startup("python", "Preparing Python environment")
+	if err := service.Initialize(context.Background()); err != nil {
+		logger.Error("Failed to prepare Python environment", "error", err)
+		os.Exit(1)
+	}
+
+	logger.Startup("devices", "Discovering devices")
+	deviceManager := devices.NewManager(devices.Options{
+		NvidiaSMI: cfg.NvidiaSMIPath,
+		GPUSlots:  cfg.GPUJobsPerDevice,
+		CPUSlots:  cfg.CPUJobs,
+	})
+	if err := deviceManager.Discover(context.Background()); err != nil {
+		logger.Warn("Failed to discover GPUs, running tasks on the CPU", "error", err)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	agent := remoteworker.NewAgent(remoteworker.AgentOptions{
+		ServerURL:  cfg.WorkerServerURL,
+		Token:      cfg.WorkerToken,
+		Name:       cfg.WorkerName,
+		Version:    version,
+		Slots:      cfg.WorkerJobs,
+		TempDir:    cfg.TempDir,
+		Devices:    deviceManager,
+		Transcribe: service.TranscribeTask,
+	})
+	if err := agent.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+		logger.Error("Worker stopped", "error", err)
+		os.Exit(1)
+	}
+	logger.Info("Worker stopped")
+}
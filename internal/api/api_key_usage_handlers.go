@@ -0,0 +1,168 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/usage"
+)
+
+// APIKeyUsageResponse holds a key's usage over a period
+type APIKeyUsageResponse struct {
+	APIKeyID uint                 `json:"api_key_id"`
+	Since    time.Time            `json:"since"`
+	Totals   usage.Totals         `json:"totals"`
+	Hourly   []models.APIKeyUsage `json:"hourly"`
+}
+
+// apiKeyIDFromContext returns the ID of the API key that authenticated the
+// request, or nil for JWT sessions
+func apiKeyIDFromContext(c *gin.Context) *uint {
+	if value, ok := c.Get("api_key_id"); ok {
+		if id, ok := value.(uint); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// GetAPIKeyUsage returns request, error and audio totals for a key
+// @Summary Get API key usage
+// @Description Get request counts, error rate and minutes of audio submitted with an API key, per hour
+// @Tags api-keys
+// @Produce json
+// @Param id path int true "API Key ID"
+// @Param hours query int false "How many hours back to report (max 720)" default(24)
+// @Success 200 {object} APIKeyUsageResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/api-keys/{id}/usage [get]
+func (h *Handler) GetAPIKeyUsage(c *gin.Context) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid API key ID"})
+		return
+	}
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if err != nil || hours < 1 || hours > 720 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "hours must be between 1 and 720"})
+		return
+	}
+
+	var apiKey models.APIKey
+	if err := database.DB.First(&apiKey, uint(id)).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+		return
+	}
+
+	since := time.Now().UTC().Truncate(time.Hour).Add(-time.Duration(hours-1) * time.Hour)
+	buckets, err := h.usageTracker.Hourly(apiKey.ID, since)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch usage"})
+		return
+	}
+	totals, _ := h.usageTracker.Usage(apiKey.ID, since)
+
+	c.JSON(http.StatusOK, APIKeyUsageResponse{
+		APIKeyID: apiKey.ID,
+		Since:    since,
+		Totals:   totals,
+		Hourly:   buckets,
+	})
+}
+
+// ListAPIKeyAlerts returns usage anomaly alerts
+// @Summary List API key alerts
+// @Description List alerts raised when an API key's usage changed sharply, newest first
+// @Tags api-keys
+// @Produce json
+// @Param status query string false "open or all" default(open)
+// @Success 200 {array} models.APIKeyAlert
+// @Failure 400 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/api-keys/alerts [get]
+func (h *Handler) ListAPIKeyAlerts(c *gin.Context) {
+	query := database.DB.Order("created_at DESC")
+	switch c.DefaultQuery("status", "open") {
+	case "open":
+		query = query.Where("resolved_at IS NULL")
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "status must be open or all"})
+		return
+	}
+
+	var alerts []models.APIKeyAlert
+	if err := query.Find(&alerts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alerts"})
+		return
+	}
+	c.JSON(http.StatusOK, alerts)
+}
+
+// RevokeAPIKeyFromAlert deactivates the key an alert was raised for
+// @Summary Revoke API key from alert
+// @Description Deactivate the API key behind an alert and resolve all its open alerts
+// @Tags api-keys
+// @Produce json
+// @Param alert_id path int true "Alert ID"
+// @Success 200 {object} models.APIKeyAlert
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/api-keys/alerts/{alert_id}/revoke [post]
+func (h *Handler) RevokeAPIKeyFromAlert(c *gin.Context) {
+	h.resolveAPIKeyAlert(c, usage.ResolutionRevoked)
+}
+
+// DismissAPIKeyAlert marks an alert as expected usage
+// @Summary Dismiss API key alert
+// @Description Resolve an alert without revoking the key
+// @Tags api-keys
+// @Produce json
+// @Param alert_id path int true "Alert ID"
+// @Success 200 {object} models.APIKeyAlert
+// @Failure 404 {object} map[string]string
+// @Security BearerAuth
+// @Router /api/v1/api-keys/alerts/{alert_id}/dismiss [post]
+func (h *Handler) DismissAPIKeyAlert(c *gin.Context) {
+	h.resolveAPIKeyAlert(c, usage.ResolutionDismissed)
+}
+
+func (h *Handler) resolveAPIKeyAlert(c *gin.Context, resolution string) {
+	var alert models.APIKeyAlert
+	if err := database.DB.First(&alert, "id = ?", c.Param("alert_id")).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Alert not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch alert"})
+		return
+	}
+
+	now := time.Now()
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.APIKeyAlert{}).Where("id = ?", alert.ID)
+		if resolution == usage.ResolutionRevoked {
+			if err := tx.Model(&models.APIKey{}).Where("id = ?", alert.APIKeyID).Update("is_active", false).Error; err != nil {
+				return err
+			}
+			// A revoked key needs no further attention
+			query = tx.Model(&models.APIKeyAlert{}).Where("api_key_id = ? AND resolved_at IS NULL", alert.APIKeyID)
+		}
+		return query.Updates(map[string]interface{}{"resolution": resolution, "resolved_at": now}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to resolve alert"})
+		return
+	}
+
+	alert.Resolution, alert.ResolvedAt = &resolution, &now
+	c.JSON(http.StatusOK, alert)
+}
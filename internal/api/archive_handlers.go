@@ -0,0 +1,223 @@
+package api
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/jobversion"
+	"synthezia/internal/models"
+)
+
+// RecordedAtUpdateRequest sets or clears the recording time of a job
+type RecordedAtUpdateRequest struct {
+	// RFC 3339 timestamp or YYYY-MM-DD; null clears the value
+	RecordedAt *string `json:"recorded_at"`
+}
+
+// CalendarBucket is the number of recordings in one period
+type CalendarBucket struct {
+	Period string    `json:"period"`
+	Start  time.Time `json:"start"`
+	Count  int       `json:"count"`
+}
+
+// CalendarResponse aggregates jobs by recording time
+type CalendarResponse struct {
+	Granularity string           `json:"granularity"`
+	Timezone    string           `json:"timezone"`
+	Buckets     []CalendarBucket `json:"buckets"`
+	Undated     int64            `json:"undated"`
+}
+
+// parseArchiveTime accepts RFC 3339 timestamps or plain dates in loc
+func parseArchiveTime(value string, loc *time.Location) (time.Time, bool) {
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, true
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, loc); err == nil {
+		return t, true
+	}
+	return time.Time{}, false
+}
+
+// applyRecordedRange filters a job query by recorded_from/recorded_to; a
+// plain date as the upper bound includes that whole day
+func applyRecordedRange(c *gin.Context, query *gorm.DB, loc *time.Location) (*gorm.DB, bool) {
+	if from := c.Query("recorded_from"); from != "" {
+		t, ok := parseArchiveTime(from, loc)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recorded_from, use RFC 3339 or YYYY-MM-DD"})
+			return nil, false
+		}
+		query = query.Where("recorded_at >= ?", t)
+	}
+	if to := c.Query("recorded_to"); to != "" {
+		t, ok := parseArchiveTime(to, loc)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recorded_to, use RFC 3339 or YYYY-MM-DD"})
+			return nil, false
+		}
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		query = query.Where("recorded_at < ?", t)
+	}
+	return query, true
+}
+
+// UpdateRecordedAt sets the recording time of a job manually
+// @Summary Update recording time
+// @Description Set or clear when the audio was recorded, overriding metadata and filename detection
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body RecordedAtUpdateRequest true "Recording time"
+// @Param If-Match header string false "Job version the edit is based on"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/v1/transcription/{id}/recorded-at [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) UpdateRecordedAt(c *gin.Context) {
+	jobID := c.Param("id")
+
+	var body RecordedAtUpdateRequest
+	if err := c.ShouldBindJSON(&body); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", jobID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+
+	updates := map[string]interface{}{"recorded_at": nil, "recorded_at_source": nil}
+	job.RecordedAt, job.RecordedAtSource = nil, nil
+	if body.RecordedAt != nil && strings.TrimSpace(*body.RecordedAt) != "" {
+		recordedAt, ok := parseArchiveTime(strings.TrimSpace(*body.RecordedAt), time.UTC)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid recorded_at, use RFC 3339 or YYYY-MM-DD"})
+			return
+		}
+		source := audio.RecordedAtSourceManual
+		job.RecordedAt, job.RecordedAtSource = &recordedAt, &source
+		updates["recorded_at"], updates["recorded_at_source"] = recordedAt, source
+	}
+
+	version, err := jobversion.Update(database.DB, job.ID, expected, updates)
+	if err != nil {
+		if !writeVersionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update recording time"})
+		}
+		return
+	}
+
+	setJobVersion(c, version)
+	c.JSON(http.StatusOK, gin.H{
+		"id":                 job.ID,
+		"recorded_at":        job.RecordedAt,
+		"recorded_at_source": job.RecordedAtSource,
+		"version":            version,
+	})
+}
+
+// GetRecordingCalendar counts jobs per day, month or year of recording
+// @Summary Recording calendar
+// @Description Aggregate transcriptions by when they were recorded, for calendar and timeline views
+// @Tags transcription
+// @Produce json
+// @Param granularity query string false "day, month or year" default(day)
+// @Param tz query string false "IANA time zone for bucket boundaries" default(UTC)
+// @Param recorded_from query string false "Only recordings at or after this time"
+// @Param recorded_to query string false "Only recordings before this time (dates include the whole day)"
+// @Success 200 {object} CalendarResponse
+// @Failure 400 {object} map[string]string
+// @Router /api/v1/transcription/calendar [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetRecordingCalendar(c *gin.Context) {
+	granularity := c.DefaultQuery("granularity", "day")
+	var layout string
+	switch granularity {
+	case "day":
+		layout = "2006-01-02"
+	case "month":
+		layout = "2006-01"
+	case "year":
+		layout = "2006"
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "granularity must be day, month or year"})
+		return
+	}
+
+	loc, err := time.LoadLocation(c.DefaultQuery("tz", "UTC"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown time zone"})
+		return
+	}
+
+	query := database.DB.Model(&models.TranscriptionJob{}).Where("id NOT LIKE 'track_%'")
+	query, ok := applyRecordedRange(c, query, loc)
+	if !ok {
+		return
+	}
+
+	var recordedAts []time.Time
+	if err := query.Where("recorded_at IS NOT NULL").Pluck("recorded_at", &recordedAts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load recordings"})
+		return
+	}
+
+	var undated int64
+	database.DB.Model(&models.TranscriptionJob{}).
+		Where("id NOT LIKE 'track_%' AND recorded_at IS NULL").
+		Count(&undated)
+
+	buckets := map[string]*CalendarBucket{}
+	for _, recordedAt := range recordedAts {
+		local := recordedAt.In(loc)
+		period := local.Format(layout)
+		bucket, exists := buckets[period]
+		if !exists {
+			start, _ := time.ParseInLocation(layout, period, loc)
+			bucket = &CalendarBucket{Period: period, Start: start}
+			buckets[period] = bucket
+		}
+		bucket.Count++
+	}
+
+	response := CalendarResponse{
+		Granularity: granularity,
+		Timezone:    loc.String(),
+		Buckets:     make([]CalendarBucket, 0, len(buckets)),
+		Undated:     undated,
+	}
+	for _, bucket := range buckets {
+		response.Buckets = append(response.Buckets, *bucket)
+	}
+	sort.Slice(response.Buckets, func(i, j int) bool {
+		return response.Buckets[i].Period < response.Buckets[j].Period
+	})
+
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,155 @@
+package api
+
+import (
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobcrypt"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+)
+
+// DownloadOriginalAudio streams a job's uploaded audio
+// @Summary Download original audio
+// @Description Stream the audio the job was created from, with HTTP Range support so players can seek without downloading the whole file. download=true saves it as an attachment. Encrypted jobs need their key in the X-Job-Key header.
+// @Tags transcription
+// @Produce audio/mpeg
+// @Param id path string true "Job ID"
+// @Param download query bool false "Send as an attachment"
+// @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 416 {object} map[string]string
+// @Router /api/v1/transcription/{id}/audio/original [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) DownloadOriginalAudio(c *gin.Context) {
+	h.downloadAudio(c, false)
+}
+
+// DownloadMergedAudio streams the mixdown of a multi-track job
+// @Summary Download merged audio
+// @Description Stream the mixdown of a multi-track job's tracks, with HTTP Range support so players can seek without downloading the whole file. download=true saves it as an attachment. Encrypted jobs need their key in the X-Job-Key header.
+// @Tags transcription
+// @Produce audio/mpeg
+// @Param id path string true "Job ID"
+// @Param download query bool false "Send as an attachment"
+// @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 416 {object} map[string]string
+// @Router /api/v1/transcription/{id}/audio/merged [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) DownloadMergedAudio(c *gin.Context) {
+	h.downloadAudio(c, true)
+}
+
+// downloadAudio serves a job's original or merged audio from disk, or from
+// the storage backend once it has been moved there
+func (h *Handler) downloadAudio(c *gin.Context, merged bool) {
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+
+	if merged && (!job.IsMultiTrack || job.MergedAudioPath == nil || *job.MergedAudioPath == "") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The job has no merged audio"})
+		return
+	}
+
+	// Unlocking may seal the job, which renames its files
+	key, ok := h.unlockJob(c, &job)
+	if !ok {
+		return
+	}
+	path := job.AudioPath
+	if merged {
+		path = *job.MergedAudioPath
+	}
+
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Methods", "GET")
+	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-API-Key, Range, "+jobcrypt.KeyHeader)
+	c.Header("Access-Control-Expose-Headers", "Content-Range, Accept-Ranges, Content-Length, Content-Disposition")
+
+	name := filepath.Base(jobcrypt.PlainName(path))
+	attachment := ""
+	if c.Query("download") == "true" {
+		attachment = name
+		c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	}
+
+	if path != "" && fsys.Exists(h.fs, path) {
+		h.serveAudioFile(c, path, name, key)
+		return
+	}
+	if job.OffloadedAt != nil && h.storage.Enabled() {
+		object, err := h.storage.Find(job.ID, path)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up stored audio"})
+			return
+		}
+		if object != nil {
+			h.serveStoredAudio(c, object, attachment)
+			return
+		}
+	}
+	if job.SourceAudioRemovedAt != nil {
+		c.JSON(http.StatusGone, gin.H{"error": "The source audio was deleted after transcription"})
+		return
+	}
+	c.JSON(http.StatusNotFound, gin.H{"error": "Audio file not found on disk"})
+}
+
+// serveAudioFile sends an audio file, decrypting sealed files on the way;
+// Range requests get partial content
+func (h *Handler) serveAudioFile(c *gin.Context, path, name string, key []byte) {
+	info, err := h.fs.Stat(path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audio file"})
+		return
+	}
+
+	var content io.ReadSeeker
+	if jobcrypt.IsSealedPath(path) {
+		reader, closer, err := jobcrypt.OpenFile(h.fs, path, key)
+		if err != nil {
+			if errors.Is(err, jobcrypt.ErrWrongKey) {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Wrong job key"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt audio file"})
+			return
+		}
+		defer closer.Close()
+		content = reader
+	} else {
+		f, err := h.fs.Open(path)
+		if err != nil {
+			logger.Warn("Failed to open audio file", "path", path, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audio file"})
+			return
+		}
+		defer f.Close()
+		content = f
+	}
+
+	c.Header("Content-Type", audioContentType(name))
+	http.ServeContent(c.Writer, c.Request, name, info.ModTime(), content)
+}
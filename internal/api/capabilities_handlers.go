@@ -0,0 +1,184 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	"synthezia/internal/ffmpegbin"
+	"synthezia/internal/jobparams"
+	"synthezia/internal/transcription"
+	"synthezia/pkg/logger"
+)
+
+// Capability reports whether an optional subsystem is turned on and, if so,
+// whether it is ready to use
+type Capability struct {
+	Enabled bool   `json:"enabled"`
+	Healthy bool   `json:"healthy"`
+	Detail  string `json:"detail,omitempty"`
+}
+
+// CapabilitiesResponse describes what this server can do
+type CapabilitiesResponse struct {
+	Version  string                `json:"version"`
+	Features map[string]Capability `json:"features"`
+	// Formats GET /api/v1/transcription/{id}/transcript can return
+	ExportFormats []string `json:"export_formats"`
+	// Largest authenticated upload in bytes; 0 means no limit
+	MaxUploadBytes int64 `json:"max_upload_bytes"`
+	// Largest upload a browser upload token may allow in bytes; 0 means no limit
+	MaxUploadTokenBytes int64 `json:"max_upload_token_bytes"`
+	// Defaults and limits of the WhisperX options a job may set
+	TranscriptionOptions jobparams.Options `json:"transcription_options"`
+}
+
+// GetCapabilities reports which optional subsystems are enabled and healthy
+// @Summary Get server capabilities
+// @Description Report which optional subsystems (diarization, GPU, live transcription, S3, LLM summarization, ...) are enabled and healthy, the transcript export formats, upload limits and the defaults and limits of per-job transcription options, so clients can hide features that would fail
+// @Tags health
+// @Produce json
+// @Success 200 {object} CapabilitiesResponse
+// @Router /api/v1/capabilities [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetCapabilities(c *gin.Context) {
+	c.JSON(http.StatusOK, h.Capabilities(c.Request.Context()))
+}
+
+// Capabilities checks each optional subsystem. It only looks at local state,
+// so it is cheap enough to call on every request.
+func (h *Handler) Capabilities(ctx context.Context) CapabilitiesResponse {
+	features := map[string]Capability{
+		"diarization":        h.diarizationCapability(ctx),
+		"live_transcription": h.liveTranscriptionCapability(ctx),
+		"llm_summarization":  h.llmCapability(),
+		"llm_insights":       {Enabled: h.postprocessor.Enabled(), Healthy: h.postprocessor.Enabled()},
+		"dropzone":           {Enabled: h.config.DropzonePaths != "", Healthy: h.config.DropzonePaths != ""},
+		"s3_ingest":          {Enabled: h.config.DropzoneS3Bucket != "", Healthy: h.config.DropzoneS3Bucket != ""},
+		"sftp_ingest":        {Enabled: h.config.DropzoneSFTPAddr != "", Healthy: h.config.DropzoneSFTPAddr != ""},
+		"imap_ingest":        h.imapCapability(),
+		"s3_storage":         {Detail: "uploads are stored on the local filesystem"},
+		"playback_proxy":     {Enabled: h.config.PlaybackProxyEnabled, Healthy: h.config.PlaybackProxyEnabled},
+	}
+	features["ffmpeg"] = ffmpegCapability()
+	gpu := transcription.GPUAvailable()
+	features["gpu"] = Capability{Enabled: gpu, Healthy: gpu}
+
+	return CapabilitiesResponse{
+		Version:              "1.0.0",
+		Features:             features,
+		ExportFormats:        []string{"json"},
+		MaxUploadTokenBytes:  int64(h.config.UploadTokenMaxMB) << 20,
+		TranscriptionOptions: h.jobParams,
+	}
+}
+
+// diarizationCapability is enabled when a model can tell speakers apart and
+// healthy when one of them is ready
+func (h *Handler) diarizationCapability(ctx context.Context) Capability {
+	if h.unifiedProcessor == nil {
+		return Capability{}
+	}
+	service := h.unifiedProcessor.GetUnifiedService()
+	status := service.GetModelStatus(ctx)
+	var capability Capability
+	var ready []string
+	for modelID, model := range service.GetSupportedModels() {
+		if !model.Features["diarization"] && !model.Features["speaker_detection"] {
+			continue
+		}
+		capability.Enabled = true
+		if status[modelID] {
+			ready = append(ready, modelID)
+		}
+	}
+	sort.Strings(ready)
+	capability.Healthy = len(ready) > 0
+	if capability.Healthy {
+		capability.Detail = strings.Join(ready, ", ")
+	}
+	return capability
+}
+
+// liveTranscriptionCapability is healthy when the service is running and a
+// transcription model is ready
+func (h *Handler) liveTranscriptionCapability(ctx context.Context) Capability {
+	if h.liveTranscription == nil {
+		return Capability{}
+	}
+	capability := Capability{Enabled: true}
+	if h.unifiedProcessor != nil {
+		for _, ready := range h.unifiedProcessor.GetModelStatus(ctx) {
+			capability.Healthy = capability.Healthy || ready
+		}
+	}
+	if !capability.Healthy {
+		capability.Detail = "no transcription model is ready"
+	}
+	return capability
+}
+
+// llmCapability is enabled when an LLM is configured and healthy when its
+// configuration is complete; the provider itself is not contacted
+func (h *Handler) llmCapability() Capability {
+	_, provider, err := h.getLLMService()
+	if provider == "" {
+		return Capability{}
+	}
+	if err != nil {
+		return Capability{Enabled: true, Detail: err.Error()}
+	}
+	return Capability{Enabled: true, Healthy: true, Detail: provider}
+}
+
+// ffmpegCapability is healthy when the ffmpeg found at startup has the
+// version and filters audio processing needs
+func ffmpegCapability() Capability {
+	status, ok := ffmpegbin.Current()
+	if !ok {
+		return Capability{Enabled: true, Detail: "ffmpeg has not been checked"}
+	}
+	if !status.Healthy {
+		return Capability{Enabled: true, Detail: status.Error}
+	}
+	return Capability{Enabled: true, Healthy: true, Detail: status.Version}
+}
+
+// imapCapability is healthy when replies to mailed-in recordings can be sent
+func (h *Handler) imapCapability() Capability {
+	if h.config.DropzoneIMAPAddr == "" {
+		return Capability{}
+	}
+	if h.config.SMTPAddr == "" {
+		return Capability{Enabled: true, Detail: "SMTP_ADDR is not set, so senders get no reply"}
+	}
+	return Capability{Enabled: true, Healthy: true}
+}
+
+// LogCapabilities logs which optional subsystems are available, warning about
+// those that are enabled but not ready
+func (h *Handler) LogCapabilities(ctx context.Context) {
+	capabilities := h.Capabilities(ctx)
+	names := make([]string, 0, len(capabilities.Features))
+	for name := range capabilities.Features {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var enabled []string
+	for _, name := range names {
+		feature := capabilities.Features[name]
+		if !feature.Enabled {
+			continue
+		}
+		enabled = append(enabled, name)
+		if !feature.Healthy {
+			logger.Warn("Feature enabled but not ready", "feature", name, "detail", feature.Detail)
+		}
+	}
+	logger.Info("Capabilities", "enabled", strings.Join(enabled, ", "), "export_formats", strings.Join(capabilities.ExportFormats, ", "))
+}
@@ -0,0 +1,157 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/middleware"
+)
+
+// CaptureRuleRequest enables debug capture for a route prefix and/or API key
+type CaptureRuleRequest struct {
+	RoutePrefix string `json:"route_prefix"`
+	APIKeyID    *uint  `json:"api_key_id"`
+	// SampleRate is the fraction of matching requests to record; 0 records all
+	SampleRate float64 `json:"sample_rate" binding:"gte=0,lte=1"`
+	// TTLMinutes limits how long the rule stays active; 0 means 60 minutes
+	TTLMinutes int `json:"ttl_minutes" binding:"gte=0"`
+}
+
+// ListCaptureRules returns the active debug capture rules
+// @Summary List debug capture rules
+// @Description List active rules that enable sanitized request/response capture
+// @Tags admin
+// @Produce json
+// @Success 200 {array} middleware.CaptureRule
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/debug/capture-rules [get]
+func (h *Handler) ListCaptureRules(c *gin.Context) {
+	c.JSON(http.StatusOK, h.captureStore.Rules())
+}
+
+// CreateCaptureRule enables debug capture for a route prefix or API key
+// @Summary Create debug capture rule
+// @Description Enable sampled capture of sanitized request/response bodies for a route prefix and/or API key
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body CaptureRuleRequest true "Capture rule"
+// @Success 201 {object} middleware.CaptureRule
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/debug/capture-rules [post]
+func (h *Handler) CreateCaptureRule(c *gin.Context) {
+	var req CaptureRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if req.RoutePrefix == "" && req.APIKeyID == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "route_prefix or api_key_id is required"})
+		return
+	}
+
+	apiKeyValue := ""
+	if req.APIKeyID != nil {
+		var apiKey models.APIKey
+		if err := database.DB.First(&apiKey, *req.APIKeyID).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				c.JSON(http.StatusNotFound, gin.H{"error": "API key not found"})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch API key"})
+			return
+		}
+		apiKeyValue = apiKey.Key
+	}
+
+	ttl := time.Duration(req.TTLMinutes) * time.Minute
+	if ttl == 0 {
+		ttl = time.Hour
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	rule := h.captureStore.AddRule(middleware.CaptureRule{
+		RoutePrefix: req.RoutePrefix,
+		APIKeyID:    req.APIKeyID,
+		SampleRate:  req.SampleRate,
+		ExpiresAt:   &expiresAt,
+	}, apiKeyValue)
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// DeleteCaptureRule disables a debug capture rule
+// @Summary Delete debug capture rule
+// @Description Stop capturing requests for the given rule
+// @Tags admin
+// @Produce json
+// @Param id path string true "Rule ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/debug/capture-rules/{id} [delete]
+func (h *Handler) DeleteCaptureRule(c *gin.Context) {
+	if !h.captureStore.RemoveRule(c.Param("id")) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Capture rule not found"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Capture rule deleted"})
+}
+
+// ListCaptures returns captured exchanges, newest first
+// @Summary List captured requests
+// @Description List sanitized request/response pairs recorded by debug capture rules, newest first
+// @Tags admin
+// @Produce json
+// @Success 200 {array} middleware.CapturedExchange
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/debug/captures [get]
+func (h *Handler) ListCaptures(c *gin.Context) {
+	c.JSON(http.StatusOK, h.captureStore.Entries())
+}
+
+// GetCapture returns a single captured exchange
+// @Summary Get captured request
+// @Description Get a single sanitized request/response pair
+// @Tags admin
+// @Produce json
+// @Param id path string true "Capture ID"
+// @Success 200 {object} middleware.CapturedExchange
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/debug/captures/{id} [get]
+func (h *Handler) GetCapture(c *gin.Context) {
+	entry, ok := h.captureStore.Entry(c.Param("id"))
+	if !ok {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Capture not found"})
+		return
+	}
+	c.JSON(http.StatusOK, entry)
+}
+
+// ClearCaptures drops all captured exchanges
+// @Summary Clear captured requests
+// @Description Delete all recorded request/response pairs
+// @Tags admin
+// @Produce json
+// @Success 200 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/debug/captures [delete]
+func (h *Handler) ClearCaptures(c *gin.Context) {
+	h.captureStore.Clear()
+	c.JSON(http.StatusOK, gin.H{"message": "Captures cleared"})
+}
@@ -184,6 +184,10 @@ func (h *Handler) CreateChatSession(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Transcription must be completed to create a chat session"})
 		return
 	}
+	if transcription.Encrypted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encrypted transcriptions cannot be used for chat"})
+		return
+	}
 
 	// Verify LLM service is available
 	_, _, err := h.getLLMService()
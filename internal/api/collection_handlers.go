@@ -0,0 +1,599 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobversion"
+	"synthezia/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// Limits on the tags of one job
+const (
+	maxTagsPerJob = 50
+	maxTagLength  = 64
+)
+
+// JobTagsRequest lists tags to set, add or remove
+type JobTagsRequest struct {
+	Tags []string `json:"tags"`
+}
+
+// JobTagsResponse is the tags of a job after a change
+type JobTagsResponse struct {
+	ID      string   `json:"id"`
+	Tags    []string `json:"tags"`
+	Version int      `json:"version"`
+}
+
+// TagCount is a tag and how many jobs carry it
+type TagCount struct {
+	Tag   string `json:"tag"`
+	Count int64  `json:"count"`
+}
+
+// CollectionRequest creates a collection or changes one; on update, fields
+// left out are kept
+type CollectionRequest struct {
+	Name        *string `json:"name"`
+	Description *string `json:"description"`
+}
+
+// CollectionResponse is a collection with the number of jobs filed in it
+type CollectionResponse struct {
+	models.Collection
+	JobCount int64 `json:"job_count"`
+}
+
+// JobCollectionRequest files a job in a collection; null takes it out
+type JobCollectionRequest struct {
+	CollectionID *uint `json:"collection_id"`
+}
+
+// normalizeTags trims tags, collapses inner whitespace and drops repeats,
+// comparing case-insensitively and keeping the first spelling
+func normalizeTags(tags []string) ([]string, error) {
+	normalized := make([]string, 0, len(tags))
+	seen := make(map[string]bool)
+	for _, tag := range tags {
+		tag = strings.Join(strings.Fields(tag), " ")
+		if tag == "" {
+			return nil, errors.New("tags cannot be empty")
+		}
+		if len([]rune(tag)) > maxTagLength {
+			return nil, fmt.Errorf("tags cannot be longer than %d characters", maxTagLength)
+		}
+		key := strings.ToLower(tag)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		normalized = append(normalized, tag)
+	}
+	return normalized, nil
+}
+
+// jobTags decodes the tags of a job; unreadable tags count as none
+func jobTags(job *models.TranscriptionJob) []string {
+	tags := []string{}
+	if job.Tags != nil && *job.Tags != "" {
+		if err := json.Unmarshal([]byte(*job.Tags), &tags); err != nil || tags == nil {
+			return []string{}
+		}
+	}
+	return tags
+}
+
+// findTaggableJob loads a job for a tag or collection change, responding when it cannot
+func findTaggableJob(c *gin.Context) (*models.TranscriptionJob, bool) {
+	jobID := c.Param("id")
+	var job models.TranscriptionJob
+	if strings.HasPrefix(jobID, "track_") {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return nil, false
+	}
+	if err := database.DB.Select("id", "tags", "collection_id", "version").Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return nil, false
+	}
+	return &job, true
+}
+
+// saveJobTags stores the tags of a job and responds with them. The tags are
+// worked out from the job as it was read, so without If-Match the edit is
+// still refused when the job changed since.
+func saveJobTags(c *gin.Context, job *models.TranscriptionJob, tags []string) {
+	if len(tags) > maxTagsPerJob {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("a job can have at most %d tags", maxTagsPerJob)})
+		return
+	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+	if expected == nil {
+		expected = &job.Version
+	}
+	var value interface{}
+	if len(tags) > 0 {
+		data, _ := json.Marshal(tags)
+		value = string(data)
+	}
+	version, err := jobversion.Update(database.DB, job.ID, expected, map[string]interface{}{"tags": value})
+	if err != nil {
+		if !writeVersionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update tags"})
+		}
+		return
+	}
+	setJobVersion(c, version)
+	c.JSON(http.StatusOK, JobTagsResponse{ID: job.ID, Tags: tags, Version: version})
+}
+
+// bindJobTags reads and normalizes the tags of a request, responding when it cannot
+func bindJobTags(c *gin.Context) ([]string, bool) {
+	var req JobTagsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	tags, err := normalizeTags(req.Tags)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return tags, true
+}
+
+// SetJobTags replaces the tags of a job
+// @Summary Set job tags
+// @Description Replace the tags of a job. Tags are trimmed and repeats, compared case-insensitively, are dropped; an empty list removes every tag.
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body JobTagsRequest true "Tags"
+// @Param If-Match header string false "Job version the edit is based on"
+// @Success 200 {object} JobTagsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/v1/transcription/{id}/tags [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) SetJobTags(c *gin.Context) {
+	job, ok := findTaggableJob(c)
+	if !ok {
+		return
+	}
+	tags, ok := bindJobTags(c)
+	if !ok {
+		return
+	}
+	saveJobTags(c, job, tags)
+}
+
+// AddJobTags adds tags to a job, keeping those it has
+// @Summary Add job tags
+// @Description Add tags to a job; tags it already has are left as they are
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body JobTagsRequest true "Tags"
+// @Param If-Match header string false "Job version the edit is based on"
+// @Success 200 {object} JobTagsResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/v1/transcription/{id}/tags [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) AddJobTags(c *gin.Context) {
+	job, ok := findTaggableJob(c)
+	if !ok {
+		return
+	}
+	added, ok := bindJobTags(c)
+	if !ok {
+		return
+	}
+	// Tags set from file metadata are kept as they were read
+	tags := jobTags(job)
+	have := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		have[strings.ToLower(tag)] = true
+	}
+	for _, tag := range added {
+		if !have[strings.ToLower(tag)] {
+			tags = append(tags, tag)
+		}
+	}
+	saveJobTags(c, job, tags)
+}
+
+// RemoveJobTag removes one tag from a job
+// @Summary Remove a job tag
+// @Description Remove a tag from a job, compared case-insensitively; removing a tag the job does not have is not an error
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param tag path string true "Tag"
+// @Param If-Match header string false "Job version the edit is based on"
+// @Success 200 {object} JobTagsResponse
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/v1/transcription/{id}/tags/{tag} [delete]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) RemoveJobTag(c *gin.Context) {
+	job, ok := findTaggableJob(c)
+	if !ok {
+		return
+	}
+	removed := strings.Join(strings.Fields(c.Param("tag")), " ")
+	tags := []string{}
+	for _, tag := range jobTags(job) {
+		if !strings.EqualFold(tag, removed) {
+			tags = append(tags, tag)
+		}
+	}
+	saveJobTags(c, job, tags)
+}
+
+// ListTags lists the tags in use with how many jobs carry each
+// @Summary List tags
+// @Description List every tag in use, most used first, with the number of jobs carrying it
+// @Tags transcription
+// @Produce json
+// @Success 200 {array} TagCount
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/tags [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) ListTags(c *gin.Context) {
+	counts := []TagCount{}
+	err := database.DB.Raw(`SELECT json_each.value AS tag, COUNT(DISTINCT transcription_jobs.id) AS count
+		FROM transcription_jobs, json_each(transcription_jobs.tags)
+		WHERE transcription_jobs.tags IS NOT NULL AND json_valid(transcription_jobs.tags)
+			AND transcription_jobs.id NOT LIKE 'track_%'
+		GROUP BY json_each.value COLLATE NOCASE
+		ORDER BY count DESC, tag COLLATE NOCASE ASC`).Scan(&counts).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list tags"})
+		return
+	}
+	c.JSON(http.StatusOK, counts)
+}
+
+// applyJobOrganization filters a job query by tag and collection: every tag
+// given must be on the job, and collection is an ID or "none" for jobs in no
+// collection
+func applyJobOrganization(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	for _, tag := range c.QueryArray("tag") {
+		tag = strings.Join(strings.Fields(tag), " ")
+		if tag == "" {
+			continue
+		}
+		query = query.Where(`transcription_jobs.tags IS NOT NULL AND json_valid(transcription_jobs.tags)
+			AND EXISTS (SELECT 1 FROM json_each(transcription_jobs.tags) WHERE json_each.value = ? COLLATE NOCASE)`, tag)
+	}
+	if collection := c.Query("collection"); collection != "" {
+		if collection == "none" {
+			return query.Where("collection_id IS NULL"), true
+		}
+		id, err := strconv.ParseUint(collection, 10, 32)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "collection must be a collection ID or none"})
+			return nil, false
+		}
+		query = query.Where("collection_id = ?", uint(id))
+	}
+	return query, true
+}
+
+// findCollection loads a collection, responding when it cannot
+func findCollection(c *gin.Context) (*models.Collection, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid collection ID"})
+		return nil, false
+	}
+	var collection models.Collection
+	if err := database.DB.Where("id = ?", uint(id)).First(&collection).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get collection"})
+		return nil, false
+	}
+	return &collection, true
+}
+
+// collectionResponse counts the jobs in a collection
+func collectionResponse(collection models.Collection) (CollectionResponse, error) {
+	response := CollectionResponse{Collection: collection}
+	err := database.DB.Model(&models.TranscriptionJob{}).Where("collection_id = ?", collection.ID).Count(&response.JobCount).Error
+	return response, err
+}
+
+// collectionName checks a collection name is usable and not taken by another collection
+func collectionName(name string, except uint) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", errors.New("name is required")
+	}
+	if len([]rune(name)) > 255 {
+		return "", errors.New("name cannot be longer than 255 characters")
+	}
+	var taken int64
+	if err := database.DB.Model(&models.Collection{}).Where("name = ? COLLATE NOCASE AND id <> ?", name, except).Count(&taken).Error; err != nil {
+		return "", err
+	}
+	if taken > 0 {
+		return "", errors.New("a collection with this name already exists")
+	}
+	return name, nil
+}
+
+// ListCollections lists the collections with how many jobs each holds
+// @Summary List collections
+// @Description List the collections jobs are filed in, by name, with the number of jobs in each
+// @Tags collections
+// @Produce json
+// @Success 200 {array} CollectionResponse
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/collections [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) ListCollections(c *gin.Context) {
+	var collections []models.Collection
+	if err := database.DB.Order("name COLLATE NOCASE ASC").Find(&collections).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list collections"})
+		return
+	}
+	var counts []struct {
+		CollectionID uint
+		Count        int64
+	}
+	if err := database.DB.Model(&models.TranscriptionJob{}).Select("collection_id, COUNT(*) AS count").
+		Where("collection_id IS NOT NULL").Group("collection_id").Scan(&counts).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list collections"})
+		return
+	}
+	byID := make(map[uint]int64, len(counts))
+	for _, count := range counts {
+		byID[count.CollectionID] = count.Count
+	}
+
+	response := make([]CollectionResponse, 0, len(collections))
+	for _, collection := range collections {
+		response = append(response, CollectionResponse{Collection: collection, JobCount: byID[collection.ID]})
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateCollection creates a collection
+// @Summary Create a collection
+// @Description Create a collection to file jobs in, such as one per client, show or case. Names are unique, ignoring case.
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param request body CollectionRequest true "Collection"
+// @Success 201 {object} CollectionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/collections [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) CreateCollection(c *gin.Context) {
+	var req CollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	name, err := collectionName(*req.Name, 0)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "already exists") {
+			status = http.StatusConflict
+		}
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	collection := models.Collection{Name: name, Description: req.Description}
+	if err := database.DB.Create(&collection).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create collection"})
+		return
+	}
+	c.JSON(http.StatusCreated, CollectionResponse{Collection: collection})
+}
+
+// GetCollection returns a collection
+// @Summary Get a collection
+// @Description Get a collection with the number of jobs in it; list them with the collection filter of the job list
+// @Tags collections
+// @Produce json
+// @Param id path int true "Collection ID"
+// @Success 200 {object} CollectionResponse
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/collections/{id} [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetCollection(c *gin.Context) {
+	collection, ok := findCollection(c)
+	if !ok {
+		return
+	}
+	response, err := collectionResponse(*collection)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get collection"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// UpdateCollection renames a collection or changes its description
+// @Summary Update a collection
+// @Description Rename a collection or change its description
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param id path int true "Collection ID"
+// @Param request body CollectionRequest true "Changes"
+// @Success 200 {object} CollectionResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/collections/{id} [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) UpdateCollection(c *gin.Context) {
+	collection, ok := findCollection(c)
+	if !ok {
+		return
+	}
+	var req CollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Name != nil {
+		name, err := collectionName(*req.Name, collection.ID)
+		if err != nil {
+			status := http.StatusBadRequest
+			if strings.Contains(err.Error(), "already exists") {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		updates["name"] = name
+	}
+	if req.Description != nil {
+		updates["description"] = *req.Description
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(collection).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+			return
+		}
+	}
+
+	var updated models.Collection
+	if err := database.DB.Where("id = ?", collection.ID).First(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get collection"})
+		return
+	}
+	response, err := collectionResponse(updated)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get collection"})
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// DeleteCollection removes a collection; its jobs are kept, in no collection
+// @Summary Delete a collection
+// @Description Remove a collection. The jobs filed in it are not deleted; they are left in no collection.
+// @Tags collections
+// @Param id path int true "Collection ID"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/collections/{id} [delete]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) DeleteCollection(c *gin.Context) {
+	collection, ok := findCollection(c)
+	if !ok {
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TranscriptionJob{}).Where("collection_id = ?", collection.ID).Update("collection_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(collection).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete collection"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Collection deleted successfully"})
+}
+
+// SetJobCollection files a job in a collection or takes it out
+// @Summary Set job collection
+// @Description File a job in a collection, moving it out of any other; a null collection_id takes it out
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body JobCollectionRequest true "Collection"
+// @Param If-Match header string false "Job version the edit is based on"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Router /api/v1/transcription/{id}/collection [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) SetJobCollection(c *gin.Context) {
+	job, ok := findTaggableJob(c)
+	if !ok {
+		return
+	}
+	var req JobCollectionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+	if req.CollectionID != nil {
+		var count int64
+		if err := database.DB.Model(&models.Collection{}).Where("id = ?", *req.CollectionID).Count(&count).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get collection"})
+			return
+		}
+		if count == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Collection not found"})
+			return
+		}
+	}
+
+	var value interface{}
+	if req.CollectionID != nil {
+		value = *req.CollectionID
+	}
+	version, err := jobversion.Update(database.DB, job.ID, expected, map[string]interface{}{"collection_id": value})
+	if err != nil {
+		if !writeVersionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update collection"})
+		}
+		return
+	}
+	setJobVersion(c, version)
+	c.JSON(http.StatusOK, gin.H{"id": job.ID, "collection_id": req.CollectionID, "version": version})
+}
@@ -0,0 +1,216 @@
+package api
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"synthezia/internal/convert"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+)
+
+// ConvertAudio queues the conversion of a job's audio or an uploaded file
+// @Summary Convert audio to another format
+// @Description Re-encode a job's audio (job_id) or an uploaded file (audio) to mp3, aac, opus, ogg, wav or flac on the task queue, e.g. to get a browser-playable copy of an exotic codec. Poll the conversion and download the artifact once it is completed.
+// @Tags audio
+// @Accept multipart/form-data
+// @Produce json
+// @Param format formData string true "Output format: mp3, aac, opus, ogg, wav or flac"
+// @Param bitrate formData string false "Bitrate of lossy formats, e.g. 128k; defaults per format"
+// @Param job_id formData string false "Job whose audio to convert"
+// @Param audio formData file false "Audio file to convert when no job is given"
+// @Success 202 {object} models.AudioConversion
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/audio/convert [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) ConvertAudio(c *gin.Context) {
+	format := c.PostForm("format")
+	bitrate, err := convert.Validate(format, c.PostForm("bitrate"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	conversion := models.AudioConversion{
+		ID:      uuid.New().String(),
+		Format:  format,
+		Bitrate: bitrate,
+		Status:  models.ConversionPending,
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(uint); ok {
+			conversion.UserID = &id
+		}
+	}
+
+	if jobID := c.PostForm("job_id"); jobID != "" {
+		if !h.conversionJobSource(c, jobID, &conversion) {
+			return
+		}
+	} else {
+		file, header, err := c.Request.FormFile("audio")
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Give a job_id or an audio file to convert"})
+			return
+		}
+		defer file.Close()
+		if err := os.MkdirAll(h.converter.OutputDir(), 0755); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create conversion directory"})
+			return
+		}
+		// Kept with the artifacts, so a conversion queued before a restart can still run
+		conversion.SourcePath = filepath.Join(h.converter.OutputDir(), conversion.ID+".source"+filepath.Ext(header.Filename))
+		conversion.SourceName = filepath.Base(header.Filename)
+		conversion.UploadedSource = true
+		if _, err := fsys.WriteFileAtomic(h.fs, h.config.TempDir, conversion.SourcePath, file); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save uploaded file"})
+			return
+		}
+	}
+
+	if err := database.DB.Create(&conversion).Error; err != nil {
+		if conversion.UploadedSource {
+			h.fs.Remove(conversion.SourcePath)
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create conversion"})
+		return
+	}
+	if err := h.taskQueue.EnqueueTask(h.converter.Task(conversion.ID)); err != nil {
+		logger.Warn("Failed to queue conversion", "conversion_id", conversion.ID, "error", err)
+		msg := "Failed to queue conversion: " + err.Error()
+		database.DB.Model(&conversion).Updates(map[string]interface{}{"status": models.ConversionFailed, "error": &msg})
+		if conversion.UploadedSource {
+			h.fs.Remove(conversion.SourcePath)
+		}
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": msg})
+		return
+	}
+	c.JSON(http.StatusAccepted, conversion)
+}
+
+// conversionJobSource points a conversion at a job's audio, preferring the
+// merged audio of multi-track jobs. It writes the error response itself and
+// reports whether the request may go on.
+func (h *Handler) conversionJobSource(c *gin.Context, jobID string, conversion *models.AudioConversion) bool {
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", jobID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return false
+	}
+	if job.Encrypted {
+		c.JSON(http.StatusConflict, gin.H{"error": "The audio of encrypted jobs cannot be converted"})
+		return false
+	}
+
+	sourcePath := job.AudioPath
+	if job.IsMultiTrack && job.MergedAudioPath != nil && fsys.Exists(h.fs, *job.MergedAudioPath) {
+		sourcePath = *job.MergedAudioPath
+	}
+	if sourcePath == "" || !fsys.Exists(h.fs, sourcePath) {
+		if job.SourceAudioRemovedAt != nil {
+			c.JSON(http.StatusGone, gin.H{"error": "The source audio was deleted after transcription"})
+			return false
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audio file not found on disk"})
+		return false
+	}
+
+	conversion.JobID = &job.ID
+	conversion.SourcePath = sourcePath
+	conversion.SourceName = filepath.Base(sourcePath)
+	if job.Title != nil && *job.Title != "" {
+		conversion.SourceName = *job.Title + filepath.Ext(sourcePath)
+	}
+	return true
+}
+
+// GetAudioConversion returns the status of a conversion
+// @Summary Get an audio conversion
+// @Description Get the status of an audio conversion; once completed its artifact can be downloaded
+// @Tags audio
+// @Produce json
+// @Param id path string true "Conversion ID"
+// @Success 200 {object} models.AudioConversion
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/audio/convert/{id} [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetAudioConversion(c *gin.Context) {
+	conversion, ok := h.findConversion(c)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, conversion)
+}
+
+// DownloadAudioConversion serves the artifact of a completed conversion
+// @Summary Download a converted audio file
+// @Description Download the artifact of a completed audio conversion
+// @Tags audio
+// @Produce octet-stream
+// @Param id path string true "Conversion ID"
+// @Success 200 {file} binary
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/audio/convert/{id}/download [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) DownloadAudioConversion(c *gin.Context) {
+	conversion, ok := h.findConversion(c)
+	if !ok {
+		return
+	}
+	if conversion.Status != models.ConversionCompleted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Conversion is " + conversion.Status})
+		return
+	}
+	if !fsys.Exists(h.fs, conversion.OutputPath) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Converted file not found on disk"})
+		return
+	}
+	c.Header("Content-Type", convert.Formats[conversion.Format].ContentType)
+	c.FileAttachment(conversion.OutputPath, convert.DownloadName(conversion))
+}
+
+// findConversion loads the conversion named in the path, writing the error
+// response itself when there is none
+func (h *Handler) findConversion(c *gin.Context) (*models.AudioConversion, bool) {
+	var conversion models.AudioConversion
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&conversion).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Conversion not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get conversion"})
+		return nil, false
+	}
+	return &conversion, true
+}
+
+// ResumeAudioConversions queues conversions interrupted by a restart
+func (h *Handler) ResumeAudioConversions() {
+	resumed, err := h.converter.Resume(h.taskQueue.EnqueueTask)
+	if err != nil {
+		logger.Warn("Failed to resume audio conversions", "resumed", resumed, "error", err)
+		return
+	}
+	if resumed > 0 {
+		logger.Info("Resumed audio conversions", "count", resumed)
+	}
+}
@@ -0,0 +1,42 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"synthezia/internal/dropzone"
+)
+
+// DropzoneStatusResponse lists the files the dropzone detected recently
+type DropzoneStatusResponse struct {
+	Enabled bool `json:"enabled"`
+	// Ingest is held back while the transcription queue is full
+	Paused bool `json:"paused"`
+	// Most recently updated first
+	Files []dropzone.FileStatus `json:"files"`
+}
+
+// SetDropzone gives the handler the dropzone service whose status it reports
+func (h *Handler) SetDropzone(service *dropzone.Service) {
+	h.dropzone = service
+}
+
+// GetDropzoneStatus reports what happened to recently detected dropzone files
+// @Summary Get dropzone ingest status
+// @Description List the files recently detected in the dropzone folders with their ingest state (waiting, uploading, job-created, failed), the job created for them and any error, so operators can see why a file has not been picked up
+// @Tags dropzone
+// @Produce json
+// @Success 200 {object} DropzoneStatusResponse
+// @Router /api/v1/dropzone/status [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetDropzoneStatus(c *gin.Context) {
+	response := DropzoneStatusResponse{Files: []dropzone.FileStatus{}}
+	if h.dropzone != nil && h.config.DropzonePaths != "" {
+		response.Enabled = true
+		response.Paused = h.dropzone.Paused()
+		response.Files = h.dropzone.Status()
+	}
+	c.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os/exec"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+)
+
+// What happens to an upload with nearly the same audio as an existing job
+const (
+	DuplicateAudioOff   = "off"
+	DuplicateAudioWarn  = "warn"  // Create the job, recording the match in near_duplicate_of
+	DuplicateAudioBlock = "block" // Reject the upload
+)
+
+// fingerprintDurationSlack is how much longer or shorter than an upload, as
+// a share of its duration, a job may be and still be compared with it
+const fingerprintDurationSlack = 0.1
+
+// nearDuplicate is an existing job whose audio matches an upload's
+type nearDuplicate struct {
+	JobID      string
+	Similarity float64
+}
+
+// checkNearDuplicate fingerprints an upload and looks for an existing job
+// with nearly the same audio, e.g. a re-encoded copy. With the block mode it
+// writes the error response and removes the file itself, and reports whether
+// the upload may go on. Nothing is checked when fpcalc is not installed.
+func (h *Handler) checkNearDuplicate(c *gin.Context, job *models.TranscriptionJob) bool {
+	mode := h.config.DuplicateAudioCheck
+	if mode == "" || mode == DuplicateAudioOff || h.fs != fsys.OS || job.Encrypted {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(logger.WithJobID(c.Request.Context(), job.ID), probeTimeout)
+	defer cancel()
+	fingerprint, err := audio.ComputeFingerprint(ctx, h.config.FpcalcPath, job.AudioPath)
+	if err != nil {
+		if !errors.Is(err, exec.ErrNotFound) {
+			logger.Warn("Failed to fingerprint upload", "job_id", job.ID, "error", err)
+		}
+		return true
+	}
+	encoded := fingerprint.Encode()
+	job.AudioFingerprint = &encoded
+
+	match, err := findNearDuplicate(fingerprint, job, float64(h.config.DuplicateAudioSimilarity)/100)
+	if err != nil {
+		logger.Warn("Failed to look for near-duplicate uploads", "job_id", job.ID, "error", err)
+		return true
+	}
+	if match == nil {
+		return true
+	}
+	similarity := math.Round(match.Similarity*1000) / 1000
+	if mode == DuplicateAudioBlock {
+		h.fs.Remove(job.AudioPath)
+		c.JSON(http.StatusConflict, gin.H{
+			"error":        fmt.Sprintf("This audio is nearly identical to job %s", match.JobID),
+			"duplicate_of": match.JobID,
+			"similarity":   similarity,
+		})
+		return false
+	}
+	logger.Warn("Upload nearly identical to an existing job", "job_id", job.ID, "duplicate_of", match.JobID, "similarity", similarity)
+	job.NearDuplicateOf = &match.JobID
+	return true
+}
+
+// findNearDuplicate returns the existing job whose fingerprint is most like
+// fingerprint, if any reaches threshold. Failed jobs and jobs of clearly
+// different length are not compared.
+func findNearDuplicate(fingerprint *audio.Fingerprint, job *models.TranscriptionJob, threshold float64) (*nearDuplicate, error) {
+	query := database.DB.Model(&models.TranscriptionJob{}).Select("id", "audio_fingerprint").
+		Where("audio_fingerprint IS NOT NULL AND status <> ? AND id <> ?", models.StatusFailed, job.ID)
+	duration := fingerprint.Duration
+	if job.AudioDuration != nil {
+		duration = *job.AudioDuration
+	}
+	if duration > 0 {
+		slack := math.Max(duration*fingerprintDurationSlack, 2)
+		query = query.Where("audio_duration IS NULL OR audio_duration BETWEEN ? AND ?", duration-slack, duration+slack)
+	}
+
+	var best *nearDuplicate
+	var candidates []models.TranscriptionJob
+	err := query.FindInBatches(&candidates, 200, func(tx *gorm.DB, batch int) error {
+		for _, candidate := range candidates {
+			values, err := audio.DecodeFingerprint(*candidate.AudioFingerprint)
+			if err != nil {
+				continue
+			}
+			similarity := audio.FingerprintSimilarity(fingerprint.Values, values)
+			if similarity >= threshold && (best == nil || similarity > best.Similarity) {
+				best = &nearDuplicate{JobID: candidate.ID, Similarity: similarity}
+			}
+		}
+		return nil
+	}).Error
+	return best, err
+}
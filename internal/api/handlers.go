@@ -1,15 +1,16 @@
 package api
 
 import (
-	"bytes"
 	"context"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/mail"
 	"os"
 	"os/exec"
 	"path/filepath"
@@ -17,20 +18,49 @@ import (
 	"strings"
 	"time"
 
+	"synthezia/internal/audio"
 	"synthezia/internal/auth"
 	"synthezia/internal/config"
+	"synthezia/internal/convert"
 	"synthezia/internal/database"
+	"synthezia/internal/dropzone"
+	"synthezia/internal/faults"
+	"synthezia/internal/fetch"
+	"synthezia/internal/ffmpegbin"
+	"synthezia/internal/jobcrypt"
+	"synthezia/internal/jobparams"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/jobversion"
+	"synthezia/internal/llm"
 	"synthezia/internal/models"
+	"synthezia/internal/postprocess"
 	"synthezia/internal/processing"
 	"synthezia/internal/queue"
+	"synthezia/internal/quota"
+	"synthezia/internal/regenerate"
+	"synthezia/internal/remoteworker"
+	"synthezia/internal/retention"
+	"synthezia/internal/scheduler"
+	"synthezia/internal/sourceaudio"
+	"synthezia/internal/storage"
 	"synthezia/internal/transcription"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/internal/translation"
+	"synthezia/internal/usage"
+	"synthezia/internal/webhook"
+	"synthezia/internal/whispermodels"
+	"synthezia/pkg/fsys"
 	"synthezia/pkg/logger"
+	"synthezia/pkg/middleware"
 
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"gorm.io/gorm"
 )
 
+// probeTimeout bounds how long ffprobe may take to read an upload
+const probeTimeout = 30 * time.Second
+
 // Handler contains all the API handlers
 type Handler struct {
 	config              *config.Config
@@ -40,11 +70,31 @@ type Handler struct {
 	liveTranscription   *transcription.LiveTranscriptionService
 	quickTranscription  *transcription.QuickTranscriptionService
 	multiTrackProcessor *processing.MultiTrackProcessor
+	captureStore        *middleware.CaptureStore
+	regenerator         *regenerate.Runner
+	translator          *translation.Service
+	postprocessor       *postprocess.Service
+	webhooks            *webhook.Service
+	downloads           *fetch.Service
+	scheduler           *scheduler.Scheduler
+	jobParams           jobparams.Options
+	whisperModels       *whispermodels.Manager
+	remoteWorkers       *remoteworker.Dispatcher
+	usageTracker        *usage.Tracker
+	quotas              *quota.Service
+	retention           *retention.Service
+	storage             *storage.Service
+	fs                  fsys.FS
+	dropzone            *dropzone.Service
+	waveforms           *audio.WaveformGenerator
+	converter           *convert.Service
+	ffprobePath         string
+	ffmpegPath          string
 }
 
 // NewHandler creates a new handler
 func NewHandler(cfg *config.Config, authService *auth.AuthService, taskQueue *queue.TaskQueue, unifiedProcessor *transcription.UnifiedJobProcessor, liveTranscription *transcription.LiveTranscriptionService, quickTranscription *transcription.QuickTranscriptionService) *Handler {
-	return &Handler{
+	h := &Handler{
 		config:              cfg,
 		authService:         authService,
 		taskQueue:           taskQueue,
@@ -52,7 +102,109 @@ func NewHandler(cfg *config.Config, authService *auth.AuthService, taskQueue *qu
 		liveTranscription:   liveTranscription,
 		quickTranscription:  quickTranscription,
 		multiTrackProcessor: processing.NewMultiTrackProcessor(),
-	}
+		captureStore:        middleware.NewCaptureStore(cfg.DebugCaptureMaxEntries, cfg.DebugCaptureMaxBodyBytes),
+		usageTracker:        usage.Default,
+		quotas:              quota.NewService(nil),
+		retention:           retention.Default,
+		storage:             storage.Default,
+		fs:                  fsys.OS,
+		waveforms:           audio.NewWaveformGenerator(),
+		converter:           convert.Default,
+		scheduler:           scheduler.Default,
+		jobParams:           jobparams.FromConfig(cfg),
+		webhooks:            webhook.NewService(nil),
+		ffprobePath:         "ffprobe",
+		ffmpegPath:          "ffmpeg",
+	}
+	h.quotas.SetLimit(int64(cfg.StorageQuotaMB) << 20)
+	h.regenerator = regenerate.NewRunner(nil, func() (llm.Service, error) {
+		svc, _, err := h.getLLMService()
+		return svc, err
+	})
+	h.translator = translation.NewService(nil, func() (llm.Service, error) {
+		svc, _, err := h.getLLMService()
+		return svc, err
+	}, func(ctx context.Context, audioPath string, params models.WhisperXParams) (*interfaces.TranscriptResult, error) {
+		if h.unifiedProcessor == nil {
+			return nil, fmt.Errorf("transcription is not available")
+		}
+		// Audio moved to the storage backend is fetched for the run
+		fetched, err := h.storage.Fetch(ctx, audioPath)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch audio from storage: %w", err)
+		}
+		if fetched {
+			defer h.fs.Remove(audioPath)
+		}
+		return h.unifiedProcessor.GetUnifiedService().TranscribeFile(ctx, audioPath, params)
+	})
+	var insightsLLM llm.Service
+	if cfg.PostProcessURL != "" {
+		insightsLLM = llm.NewOpenAICompatibleService(cfg.PostProcessURL, cfg.PostProcessAPIKey)
+	}
+	h.postprocessor = postprocess.NewService(nil, insightsLLM, cfg.PostProcessModel)
+	h.downloads = fetch.NewService(nil, fetch.Options{
+		UploadDir:    cfg.UploadDir,
+		TempDir:      cfg.TempDir,
+		MaxBytes:     int64(cfg.RemoteURLMaxMB) * 1024 * 1024,
+		YouTube:      cfg.RemoteURLYoutube,
+		YtDlp:        []string{cfg.UVPath, "run", "--native-tls", "--project", cfg.WhisperXEnv, "python", "-m", "yt_dlp"},
+		CookiesPath:  cfg.YoutubeCookiesPath,
+		FFprobePath:  "ffprobe",
+		Concurrency:  cfg.MaxConcurrentDownloads,
+		AllowedHosts: strings.Split(cfg.RemoteURLAllowedHosts, ","),
+		Proxy:        cfg.RemoteURLProxy,
+	})
+	h.whisperModels = whispermodels.NewManager(nil, whispermodels.Options{
+		Python: []string{cfg.UVPath, "run", "--native-tls", "--project", cfg.WhisperXEnv, "python"},
+		Jobs:   h.jobParams,
+	})
+	h.downloads.SetEnqueue(func(jobID string) error {
+		if h.taskQueue == nil {
+			return fmt.Errorf("no task queue")
+		}
+		return h.taskQueue.EnqueueJob(jobID)
+	})
+	h.multiTrackProcessor.SetTempDir(cfg.TempDir)
+	h.multiTrackProcessor.SetMaxConcurrentMerges(cfg.MaxConcurrentMerges)
+	return h
+}
+
+// SetFS overrides the filesystem used for uploads, mainly for tests
+func (h *Handler) SetFS(fs fsys.FS) {
+	h.fs = fs
+	h.quotas.SetFS(fs)
+}
+
+// SetFFprobePath overrides the ffprobe binary used to probe uploads, mainly for tests
+func (h *Handler) SetFFprobePath(path string) {
+	h.ffprobePath = path
+}
+
+// SetFFmpegPath overrides the ffmpeg binary used to test-decode uploads, mainly for tests
+func (h *Handler) SetFFmpegPath(path string) {
+	h.ffmpegPath = path
+}
+
+// SetMultiTrackProcessor sets the processor merging multi-track uploads, so
+// they share one with the other sources of multi-track jobs
+func (h *Handler) SetMultiTrackProcessor(processor *processing.MultiTrackProcessor) {
+	h.multiTrackProcessor = processor
+}
+
+// SetConverter overrides the service running audio conversions, mainly for tests
+func (h *Handler) SetConverter(converter *convert.Service) {
+	h.converter = converter
+}
+
+// SetRetention overrides the service applying retention policies, mainly for tests
+func (h *Handler) SetRetention(service *retention.Service) {
+	h.retention = service
+}
+
+// SetStorage overrides the service storing job media, mainly for tests
+func (h *Handler) SetStorage(service *storage.Service) {
+	h.storage = service
 }
 
 // SubmitJobRequest represents the submit job request
@@ -161,6 +313,9 @@ type APIKeyListResponse struct {
 	CreatedAt   string `json:"created_at"`
 	UpdatedAt   string `json:"updated_at"`
 	LastUsed    string `json:"last_used,omitempty"`
+	// Usage over the last 24 hours
+	Usage      *usage.Totals `json:"usage,omitempty"`
+	OpenAlerts int64         `json:"open_alerts"`
 }
 
 // APIKeysWrapper wraps the API keys list response
@@ -200,7 +355,7 @@ func transformAPIKeyForList(apiKey models.APIKey) APIKeyListResponse {
 }
 
 // @Summary Upload audio file
-// @Description Upload an audio file without starting transcription
+// @Description Upload an audio file without starting transcription. Audio nearly identical to an existing job's, e.g. a re-encoded copy, is flagged in near_duplicate_of or, when the server blocks duplicates, refused with 409.
 // @Tags transcription
 // @Accept multipart/form-data
 // @Produce json
@@ -208,24 +363,157 @@ func transformAPIKeyForList(apiKey models.APIKey) APIKeyListResponse {
 // @Param title formData string false "Job title"
 // @Success 200 {object} models.TranscriptionJob
 // @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]string
 // @Router /api/v1/transcription/upload [post]
 // @Security ApiKeyAuth
 // @Security BearerAuth
 func (h *Handler) UploadAudio(c *gin.Context) {
+	if job, ok := h.saveAudioUpload(c); ok {
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// sourceAudioAction picks what happens to an upload's audio once it is
+// transcribed: the source_audio_action form field, else the signed-in user's
+// setting. nil leaves it to the server default.
+func (h *Handler) sourceAudioAction(c *gin.Context) (*string, error) {
+	if action := c.PostForm("source_audio_action"); action != "" {
+		if !sourceaudio.ValidAction(action) {
+			return nil, errors.New("source_audio_action must be keep, delete or proxy")
+		}
+		return &action, nil
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		var user models.User
+		if err := database.DB.Select("id", "source_audio_action").First(&user, userID).Error; err == nil {
+			return user.SourceAudioAction, nil
+		}
+	}
+	return nil, nil
+}
+
+// jobEncryptionKey reads the optional client-held key for a new job from the
+// X-Job-Key header; nil means the job is stored unencrypted
+func jobEncryptionKey(c *gin.Context) ([]byte, error) {
+	header := c.GetHeader(jobcrypt.KeyHeader)
+	if header == "" {
+		return nil, nil
+	}
+	return jobcrypt.ParseKey(header)
+}
+
+// encryptJobWith marks a new job as encrypted with key, storing only the
+// key's fingerprint
+func encryptJobWith(job *models.TranscriptionJob, key []byte) {
+	if key == nil {
+		return
+	}
+	fingerprint := jobcrypt.Fingerprint(key)
+	job.Encrypted = true
+	job.EncryptionKeyHash = &fingerprint
+}
+
+// unlockJob checks the X-Job-Key header of a request for an encrypted job and
+// returns the key, or nil for jobs that are not encrypted. It writes the error
+// response itself and reports whether the request may go on. A job that
+// finished while its key was not held, e.g. across a restart, is sealed now.
+func (h *Handler) unlockJob(c *gin.Context, job *models.TranscriptionJob) ([]byte, bool) {
+	if !job.Encrypted {
+		return nil, true
+	}
+	header := c.GetHeader(jobcrypt.KeyHeader)
+	if header == "" {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "This job is encrypted; send its key in the X-Job-Key header"})
+		return nil, false
+	}
+	key, err := jobcrypt.ParseKey(header)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !jobcrypt.Verify(job, key) {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Wrong job key"})
+		return nil, false
+	}
+	if job.EncryptedAt == nil && job.Status == models.StatusCompleted {
+		if err := jobcrypt.Default.Seal(c.Request.Context(), job.ID, key); err != nil {
+			logger.Warn("Failed to encrypt job", "job_id", job.ID, "error", err)
+		} else if err := database.DB.Preload("MultiTrackFiles").Where("id = ?", job.ID).First(job).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+			return nil, false
+		}
+	}
+	return key, true
+}
+
+// openSealedText decrypts a job field sealed with key; plain values are
+// returned as they are
+func openSealedText(value *string, key []byte) (*string, error) {
+	if value == nil || !jobcrypt.IsSealedText(*value) {
+		return value, nil
+	}
+	plain, err := jobcrypt.OpenText(*value, key)
+	if err != nil {
+		return nil, err
+	}
+	return &plain, nil
+}
+
+// openSealedJob decrypts a job's sealed text fields in place
+func openSealedJob(job *models.TranscriptionJob, key []byte) error {
+	for _, field := range []**string{&job.Transcript, &job.Summary, &job.IndividualTranscripts} {
+		plain, err := openSealedText(*field, key)
+		if err != nil {
+			return err
+		}
+		*field = plain
+	}
+	return nil
+}
+
+// hideSealedJob clears an encrypted job's text fields for responses made
+// without its key. They are cleared whether sealed yet or not: a job is only
+// sealed some time after it completes, or once its key is sent again.
+func hideSealedJob(job *models.TranscriptionJob) {
+	if !job.Encrypted {
+		return
+	}
+	job.Transcript = nil
+	job.Summary = nil
+	job.IndividualTranscripts = nil
+}
+
+// saveAudioUpload stores the "audio" form file and creates its job, queueing
+// it right away for users with auto-transcription on. It writes the error
+// response itself and reports whether the upload succeeded.
+func (h *Handler) saveAudioUpload(c *gin.Context) (*models.TranscriptionJob, bool) {
 	// Parse multipart form
 	file, header, err := c.Request.FormFile("audio")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Audio file is required"})
-		return
+		return nil, false
 	}
 	defer file.Close()
+	sourceAudioAction, err := h.sourceAudioAction(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	jobKey, err := jobEncryptionKey(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	if !h.checkQuota(c, header.Size) {
+		return nil, false
+	}
 
 	// Create upload directory
 	uploadDir := h.config.UploadDir
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+	if err := h.fs.MkdirAll(uploadDir, 0755); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
-		return
+		return nil, false
 	}
 
 	// Generate unique filename
@@ -234,35 +522,50 @@ func (h *Handler) UploadAudio(c *gin.Context) {
 	filename := fmt.Sprintf("%s%s", jobID, ext)
 	filePath := filepath.Join(uploadDir, filename)
 
-	// Save file
-	dst, err := os.Create(filePath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-		return
-	}
-	defer dst.Close()
-
-	if _, err = io.Copy(dst, file); err != nil {
+	// Save file, moving it into place only once it is complete
+	faults.Delay()
+	if _, err := fsys.WriteFileAtomic(h.fs, h.config.TempDir, filePath, file); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
-		return
+		return nil, false
 	}
 
 	// Create job record with "uploaded" status (not queued for transcription)
 	job := models.TranscriptionJob{
-		ID:        jobID,
-		AudioPath: filePath,
-		Status:    models.StatusUploaded, // New status for uploaded but not transcribed
+		ID:                jobID,
+		AudioPath:         filePath,
+		Status:            models.StatusUploaded, // New status for uploaded but not transcribed
+		SourceAudioAction: sourceAudioAction,
 	}
 
 	if title := c.PostForm("title"); title != "" {
 		job.Title = &title
 	}
+	if !h.probeUpload(c, &job, header.Filename) {
+		return nil, false
+	}
+
+	// Prefill title, tags and recording date from embedded metadata
+	h.ingestAudioMetadata(c.Request.Context(), &job, header.Filename, job.Title == nil)
 
 	// Save to database
+	job.APIKeyID = apiKeyIDFromContext(c)
+	job.UserID = userIDFromContext(c)
+	encryptJobWith(&job, jobKey)
+	if !h.checkNearDuplicate(c, &job) {
+		return nil, false
+	}
 	if err := database.DB.Create(&job).Error; err != nil {
-		os.Remove(filePath) // Clean up file
+		h.fs.Remove(filePath) // Clean up file
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
-		return
+		return nil, false
+	}
+	eventArgs := []any{"source", "upload"}
+	if job.NearDuplicateOf != nil {
+		eventArgs = append(eventArgs, "near_duplicate_of", *job.NearDuplicateOf)
+	}
+	jobstate.Created(c.Request.Context(), job.ID, job.Status, eventArgs...)
+	if jobKey != nil {
+		jobcrypt.Hold(job.ID, jobKey)
 	}
 
 	// Check for auto-transcription if user is authenticated via JWT
@@ -294,22 +597,25 @@ func (h *Handler) UploadAudio(c *gin.Context) {
 			if profileFound {
 				job.Parameters = profile.Parameters
 				job.Diarization = profile.Parameters.Diarize
-				job.Status = models.StatusPending
 
-				// Update the job in database
-				if err := database.DB.Save(&job).Error; err == nil {
+				// Save the parameters and move the job to pending together
+				if _, err := jobstate.Transition(jobID, models.StatusPending, jobstate.WithTx(func(tx *gorm.DB) error {
+					return tx.Omit("status").Save(&job).Error
+				})); err == nil {
+					job.Status = models.StatusPending
 					// Enqueue the job for transcription
 					if err := h.taskQueue.EnqueueJob(jobID); err != nil {
 						// If enqueueing fails, revert status but don't fail the upload
-						job.Status = models.StatusUploaded
-						database.DB.Save(&job)
+						if _, err := jobstate.Transition(jobID, models.StatusUploaded); err == nil {
+							job.Status = models.StatusUploaded
+						}
 					}
 				}
 			}
 		}
 	}
 
-	c.JSON(http.StatusOK, job)
+	return &job, true
 }
 
 // @Summary Upload video file for transcription
@@ -333,6 +639,19 @@ func (h *Handler) UploadVideo(c *gin.Context) {
 		return
 	}
 	defer file.Close()
+	sourceAudioAction, err := h.sourceAudioAction(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	jobKey, err := jobEncryptionKey(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.checkQuota(c, header.Size) {
+		return
+	}
 
 	// Create upload directory
 	uploadDir := h.config.UploadDir
@@ -344,8 +663,7 @@ func (h *Handler) UploadVideo(c *gin.Context) {
 	// Generate unique job ID and temporary video filename
 	jobID := uuid.New().String()
 	ext := filepath.Ext(header.Filename)
-	tempVideoFilename := fmt.Sprintf("%s_temp%s", jobID, ext)
-	tempVideoPath := filepath.Join(uploadDir, tempVideoFilename)
+	tempVideoPath := fsys.TempPath(h.config.TempDir, filepath.Join(uploadDir, jobID+ext))
 
 	// Save temporary video file
 	dst, err := os.Create(tempVideoPath)
@@ -365,9 +683,11 @@ func (h *Handler) UploadVideo(c *gin.Context) {
 	}
 	dst.Close() // Close before ffmpeg processing
 
-	// Generate audio filename
+	// Generate audio filename; ffmpeg writes into the temp directory and the
+	// finished file is renamed into the upload directory
 	audioFilename := fmt.Sprintf("%s.mp3", jobID)
 	audioPath := filepath.Join(uploadDir, audioFilename)
+	tempAudioPath := fsys.TempPath(h.config.TempDir, audioPath) + ".mp3"
 
 	// Extract audio using ffmpeg
 	cmd := exec.Command("ffmpeg",
@@ -376,35 +696,64 @@ func (h *Handler) UploadVideo(c *gin.Context) {
 		"-acodec", "mp3", // audio codec
 		"-ab", "192k", // audio bitrate
 		"-y", // overwrite output
-		audioPath)
+		tempAudioPath)
 
 	// Execute ffmpeg command
-	if output, err := cmd.CombinedOutput(); err != nil {
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(logger.WithJobID(c.Request.Context(), jobID), "extract_audio", start, "input", tempVideoPath)
+	if err != nil {
 		// Clean up audio file if created
-		os.Remove(audioPath)
+		os.Remove(tempAudioPath)
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"error": fmt.Sprintf("Failed to extract audio from video: %v - %s", err, string(output)),
 		})
 		return
 	}
+	if err := os.Rename(tempAudioPath, audioPath); err != nil {
+		os.Remove(tempAudioPath)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save extracted audio"})
+		return
+	}
 
 	// Create job record with "uploaded" status (not queued for transcription)
 	job := models.TranscriptionJob{
-		ID:        jobID,
-		AudioPath: audioPath,
-		Status:    models.StatusUploaded, // Same status as audio uploads
+		ID:                jobID,
+		AudioPath:         audioPath,
+		Status:            models.StatusUploaded, // Same status as audio uploads
+		SourceAudioAction: sourceAudioAction,
 	}
 
 	if title := c.PostForm("title"); title != "" {
 		job.Title = &title
 	}
+	if !h.probeUpload(c, &job, audioFilename) {
+		return
+	}
+
+	// Prefill title, tags and recording date from the metadata ffmpeg carried over
+	h.ingestAudioMetadata(c.Request.Context(), &job, header.Filename, job.Title == nil)
 
 	// Save to database
+	job.APIKeyID = apiKeyIDFromContext(c)
+	job.UserID = userIDFromContext(c)
+	encryptJobWith(&job, jobKey)
+	if !h.checkNearDuplicate(c, &job) {
+		return
+	}
 	if err := database.DB.Create(&job).Error; err != nil {
 		os.Remove(audioPath) // Clean up audio file
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
 		return
 	}
+	eventArgs := []any{"source", "video"}
+	if job.NearDuplicateOf != nil {
+		eventArgs = append(eventArgs, "near_duplicate_of", *job.NearDuplicateOf)
+	}
+	jobstate.Created(c.Request.Context(), job.ID, job.Status, eventArgs...)
+	if jobKey != nil {
+		jobcrypt.Hold(job.ID, jobKey)
+	}
 
 	// Check for auto-transcription if user is authenticated via JWT (same logic as audio upload)
 	if userID, exists := c.Get("user_id"); exists {
@@ -435,15 +784,18 @@ func (h *Handler) UploadVideo(c *gin.Context) {
 			if profileFound {
 				job.Parameters = profile.Parameters
 				job.Diarization = profile.Parameters.Diarize
-				job.Status = models.StatusPending
 
-				// Update the job in database
-				if err := database.DB.Save(&job).Error; err == nil {
+				// Save the parameters and move the job to pending together
+				if _, err := jobstate.Transition(jobID, models.StatusPending, jobstate.WithTx(func(tx *gorm.DB) error {
+					return tx.Omit("status").Save(&job).Error
+				})); err == nil {
+					job.Status = models.StatusPending
 					// Enqueue the job for transcription
 					if err := h.taskQueue.EnqueueJob(jobID); err != nil {
 						// If enqueueing fails, revert status but don't fail the upload
-						job.Status = models.StatusUploaded
-						database.DB.Save(&job)
+						if _, err := jobstate.Transition(jobID, models.StatusUploaded); err == nil {
+							job.Status = models.StatusUploaded
+						}
 					}
 				}
 			}
@@ -454,13 +806,16 @@ func (h *Handler) UploadVideo(c *gin.Context) {
 }
 
 // @Summary Upload multi-track audio files
-// @Description Upload multiple audio files with an .aup file for multi-track transcription
+// @Description Upload multiple audio files with their Audacity (.aup), Reaper (.rpp), Ardour (.ardour) or AAF (.aaf) project file for multi-track transcription. Audacity 3 projects (.aup3) hold their own audio, which is extracted from them; no tracks are needed.
 // @Tags transcription
 // @Accept multipart/form-data
 // @Produce json
 // @Param title formData string true "Job title (required)"
-// @Param aup formData file true ".aup Audacity project file"
-// @Param tracks formData file true "Audio track files" multiple
+// @Param project formData file false "Audacity (.aup, .aup3), Reaper (.rpp), Ardour (.ardour) or AAF (.aaf) project file"
+// @Param aup formData file false "Project file, the field's former name"
+// @Param tracks formData file false "Audio track files, required unless the project embeds its audio" multiple
+// @Param speakers formData string false "JSON object of speaker names by track file name; tracks without one take their name from the project"
+// @Param merge_all_tracks formData bool false "Mix every unmuted track, even when the project solos some (default false: like the editor, only soloed tracks are heard)"
 // @Success 200 {object} models.TranscriptionJob
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -475,17 +830,20 @@ func (h *Handler) UploadMultiTrack(c *gin.Context) {
 		return
 	}
 
-	// Parse multipart form for .aup file
-	aupFile, aupHeader, err := c.Request.FormFile("aup")
+	// Parse multipart form for the project file
+	aupFile, aupHeader, err := c.Request.FormFile("project")
+	if err != nil {
+		aupFile, aupHeader, err = c.Request.FormFile("aup")
+	}
 	if err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": ".aup Audacity project file is required"})
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project file (" + strings.Join(audio.ProjectExtensions(), ", ") + ") is required"})
 		return
 	}
 	defer aupFile.Close()
 
-	// Validate .aup file extension
-	if !strings.HasSuffix(strings.ToLower(aupHeader.Filename), ".aup") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Project file must have .aup extension"})
+	// Validate project file extension
+	if !audio.IsProjectFile(aupHeader.Filename) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Project file must have one of these extensions: " + strings.Join(audio.ProjectExtensions(), ", ")})
 		return
 	}
 
@@ -497,10 +855,36 @@ func (h *Handler) UploadMultiTrack(c *gin.Context) {
 	}
 
 	tracks := form.File["tracks"]
-	if len(tracks) == 0 {
+	embedded := audio.EmbedsAudio(aupHeader.Filename)
+	if embedded {
+		// The tracks come out of the project
+		tracks = nil
+	} else if len(tracks) == 0 {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "At least one audio track is required"})
 		return
 	}
+	sourceAudioAction, err := h.sourceAudioAction(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	jobKey, err := jobEncryptionKey(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	speakers, err := parseTrackSpeakers(c.PostForm("speakers"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	incoming := aupHeader.Size
+	for _, track := range tracks {
+		incoming += track.Size
+	}
+	if !h.checkQuota(c, incoming) {
+		return
+	}
 
 	// Generate unique job ID
 	jobID := uuid.New().String()
@@ -510,37 +894,38 @@ func (h *Handler) UploadMultiTrack(c *gin.Context) {
 	multiTrackFolder := filepath.Join(uploadDir, jobID)
 	tracksFolder := filepath.Join(multiTrackFolder, "tracks")
 
-	if err := os.MkdirAll(tracksFolder, 0755); err != nil {
+	if err := h.fs.MkdirAll(tracksFolder, 0755); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
 		return
 	}
 
-	// Save .aup file
-	aupFilePath := filepath.Join(multiTrackFolder, "project.aup")
-	aupDst, err := os.Create(aupFilePath)
-	if err != nil {
-		os.RemoveAll(multiTrackFolder) // Clean up on error
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save .aup file"})
-		return
-	}
-	defer aupDst.Close()
-
-	if _, err = io.Copy(aupDst, aupFile); err != nil {
-		os.RemoveAll(multiTrackFolder) // Clean up on error
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save .aup file"})
+	// Save project file, keeping its extension to pick its parser
+	aupFilePath := filepath.Join(multiTrackFolder, "project"+strings.ToLower(filepath.Ext(aupHeader.Filename)))
+	if _, err := fsys.WriteFileAtomic(h.fs, h.config.TempDir, aupFilePath, aupFile); err != nil {
+		h.fs.RemoveAll(multiTrackFolder) // Clean up on error
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save project file"})
 		return
 	}
-	aupDst.Close()
 
 	// Process and save track files
 	var multiTrackFiles []models.MultiTrackFile
 	var firstTrackPath string
 
+	if embedded {
+		multiTrackFiles, err = h.extractProjectTracks(aupFilePath, tracksFolder, jobID)
+		if err != nil {
+			h.fs.RemoveAll(multiTrackFolder) // Clean up on error
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Failed to extract the project's audio: %v", err)})
+			return
+		}
+		firstTrackPath = multiTrackFiles[0].FilePath
+	}
+
 	for i, trackFileHeader := range tracks {
 		// Open track file
 		trackFile, err := trackFileHeader.Open()
 		if err != nil {
-			os.RemoveAll(multiTrackFolder) // Clean up on error
+			h.fs.RemoveAll(multiTrackFolder) // Clean up on error
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to open track file: %s", trackFileHeader.Filename)})
 			return
 		}
@@ -549,30 +934,19 @@ func (h *Handler) UploadMultiTrack(c *gin.Context) {
 		// Validate it's an audio file (basic check)
 		if !strings.HasPrefix(trackFileHeader.Header.Get("Content-Type"), "audio/") {
 			trackFile.Close()
-			os.RemoveAll(multiTrackFolder) // Clean up on error
+			h.fs.RemoveAll(multiTrackFolder) // Clean up on error
 			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("File %s is not an audio file", trackFileHeader.Filename)})
 			return
 		}
 
 		// Save track file with original filename
 		trackPath := filepath.Join(tracksFolder, trackFileHeader.Filename)
-		trackDst, err := os.Create(trackPath)
-		if err != nil {
+		if _, err := fsys.WriteFileAtomic(h.fs, h.config.TempDir, trackPath, trackFile); err != nil {
 			trackFile.Close()
-			os.RemoveAll(multiTrackFolder) // Clean up on error
+			h.fs.RemoveAll(multiTrackFolder) // Clean up on error
 			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to save track file: %s", trackFileHeader.Filename)})
 			return
 		}
-
-		if _, err = io.Copy(trackDst, trackFile); err != nil {
-			trackDst.Close()
-			trackFile.Close()
-			os.RemoveAll(multiTrackFolder) // Clean up on error
-			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to save track file: %s", trackFileHeader.Filename)})
-			return
-		}
-
-		trackDst.Close()
 		trackFile.Close()
 
 		// Store first track path for main audio_path field (for backward compatibility)
@@ -593,31 +967,57 @@ func (h *Handler) UploadMultiTrack(c *gin.Context) {
 		})
 	}
 
+	if err := applyTrackSpeakers(multiTrackFiles, speakers); err != nil {
+		h.fs.RemoveAll(multiTrackFolder) // Clean up on error
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
 	// Create transcription job record
 	job := models.TranscriptionJob{
-		ID:               jobID,
-		Title:            &title,
-		AudioPath:        firstTrackPath, // Point to first track initially
-		Status:           models.StatusUploaded,
-		IsMultiTrack:     true,
-		AupFilePath:      &aupFilePath,
-		MultiTrackFolder: &multiTrackFolder,
-		MergeStatus:      "none", // No merge processing yet
+		ID:                jobID,
+		Title:             &title,
+		AudioPath:         firstTrackPath, // Point to first track initially
+		Status:            models.StatusUploaded,
+		IsMultiTrack:      true,
+		AupFilePath:       &aupFilePath,
+		MultiTrackFolder:  &multiTrackFolder,
+		MergeStatus:       "none", // No merge processing yet
+		MergeAllTracks:    getFormBoolWithDefault(c, "merge_all_tracks", false),
+		SourceAudioAction: sourceAudioAction,
+	}
+
+	// Prefill tags and recording date from the first track's metadata; the
+	// other tracks are only stripped
+	h.ingestAudioMetadata(c.Request.Context(), &job, aupHeader.Filename, false)
+	if h.config.StripAudioMetadata {
+		for _, track := range multiTrackFiles[1:] {
+			if err := audio.StripMetadata(logger.WithJobID(c.Request.Context(), jobID), "ffmpeg", track.FilePath); err != nil {
+				logger.Warn("Failed to strip audio metadata", "job_id", jobID, "track", track.FileName, "error", err)
+			}
+		}
 	}
 
 	// Save job to database
+	job.APIKeyID = apiKeyIDFromContext(c)
+	job.UserID = userIDFromContext(c)
+	encryptJobWith(&job, jobKey)
 	if err := database.DB.Create(&job).Error; err != nil {
-		os.RemoveAll(multiTrackFolder) // Clean up on error
+		h.fs.RemoveAll(multiTrackFolder) // Clean up on error
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
 		return
 	}
+	jobstate.Created(c.Request.Context(), job.ID, job.Status, "source", "multitrack")
+	if jobKey != nil {
+		jobcrypt.Hold(job.ID, jobKey)
+	}
 
 	// Save multi-track files to database
 	for i := range multiTrackFiles {
 		if err := database.DB.Create(&multiTrackFiles[i]).Error; err != nil {
 			// Clean up job and files on error
 			database.DB.Delete(&job)
-			os.RemoveAll(multiTrackFolder)
+			h.fs.RemoveAll(multiTrackFolder)
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create track file records"})
 			return
 		}
@@ -642,8 +1042,38 @@ func (h *Handler) UploadMultiTrack(c *gin.Context) {
 	c.JSON(http.StatusOK, job)
 }
 
+// extractProjectTracks writes the audio a project holds to tracksFolder and
+// returns a track record for each of its clips
+func (h *Handler) extractProjectTracks(projectPath, tracksFolder, jobID string) ([]models.MultiTrackFile, error) {
+	extractor, ok := audio.ProjectExtractorFor(projectPath)
+	if !ok {
+		return nil, fmt.Errorf("unsupported project file: %s", filepath.Base(projectPath))
+	}
+	// The extractor reads the project and writes the tracks itself
+	if h.fs != fsys.OS {
+		return nil, errors.New("projects with embedded audio need the local filesystem")
+	}
+	tracks, err := extractor.Extract(projectPath, tracksFolder)
+	if err != nil {
+		return nil, err
+	}
+	if len(tracks) == 0 {
+		return nil, errors.New("project has no audio clips")
+	}
+	files := make([]models.MultiTrackFile, len(tracks))
+	for i, track := range tracks {
+		files[i] = models.MultiTrackFile{
+			TranscriptionJobID: jobID,
+			FileName:           strings.TrimSuffix(track.FilePath, filepath.Ext(track.FilePath)),
+			FilePath:           filepath.Join(tracksFolder, track.FilePath),
+			TrackIndex:         i,
+		}
+	}
+	return files, nil
+}
+
 // @Summary Get multi-track merge status
-// @Description Get the current merge status for a multi-track job
+// @Description Get the current merge status for a multi-track job. While the merge runs, merge_progress is the percentage done, measured from how much of the mix ffmpeg has written out of processed_seconds and total_seconds.
 // @Tags transcription
 // @Produce json
 // @Param id path string true "Job ID"
@@ -668,6 +1098,16 @@ func (h *Handler) GetMergeStatus(c *gin.Context) {
 	if errorMsg != nil {
 		response["merge_error"] = *errorMsg
 	}
+	if progress, ok := processing.MergeProgresses.Get(jobID); ok {
+		response["merge_stage"] = progress.Stage
+		response["merge_progress"] = progress.Progress
+		if progress.TotalSeconds > 0 {
+			response["processed_seconds"] = progress.ProcessedSeconds
+			response["total_seconds"] = progress.TotalSeconds
+		}
+	} else if status == "completed" {
+		response["merge_progress"] = 100.0
+	}
 
 	c.JSON(http.StatusOK, response)
 }
@@ -777,16 +1217,24 @@ func (h *Handler) GetTrackProgress(c *gin.Context) {
 // @Param audio formData file true "Audio file"
 // @Param title formData string false "Job title"
 // @Param diarization formData boolean false "Enable speaker diarization"
-// @Param model formData string false "Whisper model" default(base)
+// @Param model formData string false "Whisper model; the server default when omitted"
 // @Param language formData string false "Language code"
-// @Param batch_size formData int false "Batch size" default(16)
-// @Param compute_type formData string false "Compute type" default(float16)
-// @Param device formData string false "Device" default(auto)
+// @Param batch_size formData int false "Batch size, up to the server's limit"
+// @Param compute_type formData string false "Compute type"
+// @Param device formData string false "Device: auto, cpu or cuda"
+// @Param beam_size formData int false "Beam size, up to the server's limit"
+// @Param temperature formData number false "Sampling temperature, 0 to 1" default(0)
+// @Param vad_method formData string false "pyannote or silero" default(pyannote)
 // @Param vad_filter formData boolean false "Enable VAD filter"
 // @Param vad_onset formData number false "VAD onset" default(0.500)
 // @Param vad_offset formData number false "VAD offset" default(0.363)
 // @Param min_speakers formData int false "Minimum speakers for diarization"
 // @Param max_speakers formData int false "Maximum speakers for diarization"
+// @Param normalize formData boolean false "Normalize loudness (EBU R128) before transcription"
+// @Param trim_silence formData boolean false "Trim leading and trailing silence before transcription"
+// @Param denoise formData boolean false "Reduce background noise before transcription"
+// @Param run_after formData string false "RFC 3339 time before which the job is not transcribed"
+// @Param schedule formData string false "Cron expression; the job is not transcribed before its next match"
 // @Success 200 {object} models.TranscriptionJob
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
@@ -801,10 +1249,28 @@ func (h *Handler) SubmitJob(c *gin.Context) {
 		return
 	}
 	defer file.Close()
+	sourceAudioAction, err := h.sourceAudioAction(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	jobKey, err := jobEncryptionKey(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	runAfter, err := jobRunAfter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.checkQuota(c, header.Size) {
+		return
+	}
 
 	// Create upload directory
 	uploadDir := h.config.UploadDir
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+	if err := h.fs.MkdirAll(uploadDir, 0755); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
 		return
 	}
@@ -815,16 +1281,13 @@ func (h *Handler) SubmitJob(c *gin.Context) {
 	filename := fmt.Sprintf("%s%s", jobID, ext)
 	filePath := filepath.Join(uploadDir, filename)
 
-	// Save file
-	dst, err := os.Create(filePath)
-	if err != nil {
+	// Save file, moving it into place only once it is complete
+	if _, err := fsys.WriteFileAtomic(h.fs, h.config.TempDir, filePath, file); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
 		return
 	}
-	defer dst.Close()
-
-	if _, err = io.Copy(dst, file); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save file"})
+	probed := models.TranscriptionJob{ID: jobID, AudioPath: filePath}
+	if !h.probeUpload(c, &probed, header.Filename) {
 		return
 	}
 
@@ -835,15 +1298,20 @@ func (h *Handler) SubmitJob(c *gin.Context) {
 	} else {
 		diarize = getFormBoolWithDefault(c, "diarize", false)
 	}
-	params := models.WhisperXParams{
-		Model:       getFormValueWithDefault(c, "model", "base"),
-		BatchSize:   getFormIntWithDefault(c, "batch_size", 16),
-		ComputeType: getFormValueWithDefault(c, "compute_type", "int8"),
-		Device:      getFormValueWithDefault(c, "device", "cpu"),
-		VadOnset:    getFormFloatWithDefault(c, "vad_onset", 0.500),
-		VadOffset:   getFormFloatWithDefault(c, "vad_offset", 0.363),
-		Diarize:     diarize,
-	}
+	params := h.jobParams.Defaults()
+	params.Model = getFormValueWithDefault(c, "model", params.Model)
+	params.BatchSize = getFormIntWithDefault(c, "batch_size", params.BatchSize)
+	params.ComputeType = getFormValueWithDefault(c, "compute_type", params.ComputeType)
+	params.Device = getFormValueWithDefault(c, "device", params.Device)
+	params.BeamSize = getFormIntWithDefault(c, "beam_size", params.BeamSize)
+	params.Temperature = getFormFloatWithDefault(c, "temperature", params.Temperature)
+	params.VadMethod = getFormValueWithDefault(c, "vad_method", params.VadMethod)
+	params.VadOnset = getFormFloatWithDefault(c, "vad_onset", params.VadOnset)
+	params.VadOffset = getFormFloatWithDefault(c, "vad_offset", params.VadOffset)
+	params.Diarize = diarize
+	params.Normalize = getFormBoolWithDefault(c, "normalize", false)
+	params.TrimSilence = getFormBoolWithDefault(c, "trim_silence", false)
+	params.Denoise = getFormBoolWithDefault(c, "denoise", false)
 
 	if lang := c.PostForm("language"); lang != "" {
 		params.Language = &lang
@@ -872,28 +1340,61 @@ func (h *Handler) SubmitJob(c *gin.Context) {
 		return
 	}
 	params.DiarizeModel = diarizeModel
+	if err := h.jobParams.Validate(params); err != nil {
+		h.fs.Remove(filePath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Create job
 	job := models.TranscriptionJob{
-		ID:          jobID,
-		AudioPath:   filePath,
-		Status:      models.StatusPending,
-		Diarization: diarize,
-		Parameters:  params,
+		ID:                jobID,
+		AudioPath:         filePath,
+		Status:            models.StatusPending,
+		Diarization:       diarize,
+		Parameters:        params,
+		SourceAudioAction: sourceAudioAction,
+		AudioDuration:     probed.AudioDuration,
+		AudioSampleRate:   probed.AudioSampleRate,
+		AudioChannels:     probed.AudioChannels,
+		AudioCodec:        probed.AudioCodec,
+		AudioBitRate:      probed.AudioBitRate,
+		RunAfter:          runAfter,
 	}
 
 	if title := c.PostForm("title"); title != "" {
 		job.Title = &title
 	}
 
+	// Prefill title, tags and recording date from embedded metadata
+	h.ingestAudioMetadata(c.Request.Context(), &job, header.Filename, job.Title == nil)
+
 	// Save to database
+	job.APIKeyID = apiKeyIDFromContext(c)
+	job.UserID = userIDFromContext(c)
+	encryptJobWith(&job, jobKey)
+	if !h.checkNearDuplicate(c, &job) {
+		return
+	}
 	if err := database.DB.Create(&job).Error; err != nil {
-		os.Remove(filePath) // Clean up file
+		h.fs.Remove(filePath) // Clean up file
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
 		return
 	}
+	eventArgs := []any{"source", "submit"}
+	if job.NearDuplicateOf != nil {
+		eventArgs = append(eventArgs, "near_duplicate_of", *job.NearDuplicateOf)
+	}
+	jobstate.Created(c.Request.Context(), job.ID, job.Status, eventArgs...)
+	if jobKey != nil {
+		jobcrypt.Hold(job.ID, jobKey)
+	}
 
-	// Enqueue job
+	// Enqueue job, unless the scheduler releases it later
+	if job.RunAfter != nil {
+		c.JSON(http.StatusOK, job)
+		return
+	}
 	if err := h.taskQueue.EnqueueJob(jobID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue job"})
 		return
@@ -903,11 +1404,11 @@ func (h *Handler) SubmitJob(c *gin.Context) {
 }
 
 // @Summary Get job status
-// @Description Get the current status of a transcription job
+// @Description Get the current status of a transcription job. A queued or running job has an eta estimated from how fast its model has run on its device lately.
 // @Tags transcription
 // @Produce json
 // @Param id path string true "Job ID"
-// @Success 200 {object} models.TranscriptionJob
+// @Success 200 {object} JobWithETA
 // @Failure 404 {object} map[string]string
 // @Router /api/v1/transcription/{id}/status [get]
 // @Security ApiKeyAuth
@@ -925,14 +1426,15 @@ func (h *Handler) GetJobStatus(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, job)
+	c.JSON(http.StatusOK, h.withETAs([]models.TranscriptionJob{*job})[0])
 }
 
 // @Summary Get transcript
-// @Description Get the transcript for a completed transcription job
+// @Description Get the transcript for a completed transcription job. format=structured returns it in the versioned structured format instead: segments of words with their timing, confidence and speaker, renamed as mapped.
 // @Tags transcription
 // @Produce json
 // @Param id path string true "Job ID"
+// @Param format query string false "raw or structured" default(raw)
 // @Success 200 {object} map[string]interface{}
 // @Failure 404 {object} map[string]string
 // @Failure 400 {object} map[string]string
@@ -941,6 +1443,11 @@ func (h *Handler) GetJobStatus(c *gin.Context) {
 // @Security BearerAuth
 func (h *Handler) GetTranscript(c *gin.Context) {
 	jobID := c.Param("id")
+	format := c.DefaultQuery("format", "raw")
+	if format != "raw" && format != "structured" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be raw or structured"})
+		return
+	}
 
 	var job models.TranscriptionJob
 	if err := database.DB.Where("id = ?", jobID).First(&job).Error; err != nil {
@@ -959,11 +1466,26 @@ func (h *Handler) GetTranscript(c *gin.Context) {
 		return
 	}
 
+	key, ok := h.unlockJob(c, &job)
+	if !ok {
+		return
+	}
+	if err := openSealedJob(&job, key); err != nil {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Wrong job key"})
+		return
+	}
+
 	if job.Transcript == nil {
 		c.JSON(http.StatusNotFound, gin.H{"error": "Transcript not available"})
 		return
 	}
 
+	setJobVersion(c, job.Version)
+	if format == "structured" {
+		writeStructuredTranscript(c, job.ID, *job.Transcript)
+		return
+	}
+
 	var transcript interface{}
 	if err := json.Unmarshal([]byte(*job.Transcript), &transcript); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transcript"})
@@ -980,22 +1502,56 @@ func (h *Handler) GetTranscript(c *gin.Context) {
 }
 
 // @Summary List all transcription records
-// @Description Get a list of all transcription jobs with optional search and filtering
+// @Description Get a list of all transcription jobs with optional search and filtering. Pages are numbered, or follow on from next_cursor, which stays stable while jobs are added; total counts every matching job either way. Queued and running jobs carry an eta.
 // @Tags transcription
 // @Produce json
-// @Param page query int false "Page number" default(1)
+// @Param page query int false "Page number, when no cursor is given" default(1)
+// @Param cursor query string false "next_cursor of the previous page"
 // @Param limit query int false "Items per page" default(10)
-// @Param status query string false "Filter by status"
+// @Param status query string false "Filter by status; several may be given, comma-separated"
 // @Param q query string false "Search in title and audio filename"
+// @Param sort query string false "Sort by created_at, recorded_at, duration or status" default(created_at)
+// @Param order query string false "asc or desc" default(desc)
+// @Param created_from query string false "Only jobs created at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param created_to query string false "Only jobs created before this time (dates include the whole day)"
+// @Param recorded_from query string false "Only recordings at or after this time (RFC 3339 or YYYY-MM-DD)"
+// @Param recorded_to query string false "Only recordings before this time (dates include the whole day)"
+// @Param tag query []string false "Only jobs with every one of these tags" collectionFormat(multi)
+// @Param collection query string false "Only jobs in this collection ID, or none for jobs in no collection"
+// @Param is_multi_track query bool false "Only multi-track jobs, or only single-file ones"
+// @Param language query string false "Only jobs in this language, as requested or else as detected"
 // @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
 // @Router /api/v1/transcription/list [get]
 // @Security ApiKeyAuth
 // @Security BearerAuth
 func (h *Handler) ListJobs(c *gin.Context) {
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	status := c.Query("status")
 	search := c.Query("q") // Add search parameter
+	sortField := c.DefaultQuery("sort", "created_at")
+	sortOrder := strings.ToUpper(c.DefaultQuery("order", "desc"))
+
+	if sortOrder != "ASC" && sortOrder != "DESC" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "order must be asc or desc"})
+		return
+	}
+	sortKeys, ok := jobSortKeys(sortField, sortOrder == "DESC")
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "sort must be created_at, recorded_at, duration or status"})
+		return
+	}
+	sortName := sortField + " " + strings.ToLower(sortOrder)
+
+	var after []interface{}
+	cursor := c.Query("cursor")
+	if cursor != "" {
+		var err error
+		if after, err = decodeJobCursor(cursor, sortName, sortKeys); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
 
 	if page < 1 {
 		page = 1
@@ -1011,9 +1567,10 @@ func (h *Handler) ListJobs(c *gin.Context) {
 	// Filter out temporary track jobs (they have IDs starting with "track_")
 	query = query.Where("id NOT LIKE 'track_%'")
 
-	// Apply status filter
-	if status != "" {
-		query = query.Where("status = ?", status)
+	// Apply status, creation date, multi-track and language filters
+	query, ok = applyJobFilters(c, query)
+	if !ok {
+		return
 	}
 
 	// Apply search filter - search in title and audio_path
@@ -1022,27 +1579,62 @@ func (h *Handler) ListJobs(c *gin.Context) {
 		query = query.Where("title LIKE ? COLLATE NOCASE OR audio_path LIKE ? COLLATE NOCASE", searchPattern, searchPattern)
 	}
 
+	// Apply recording date range filter
+	query, ok = applyRecordedRange(c, query, time.UTC)
+	if !ok {
+		return
+	}
+
+	// Apply tag and collection filters
+	query, ok = applyJobOrganization(c, query)
+	if !ok {
+		return
+	}
+
 	var jobs []models.TranscriptionJob
 	var total int64
 
 	// Count total matching records
-	query.Count(&total)
+	if err := query.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
+		return
+	}
 
-	// Apply pagination and ordering
-	if err := query.Preload("MultiTrackFiles").Offset(offset).Limit(limit).Order("created_at DESC").Find(&jobs).Error; err != nil {
+	// Apply pagination and ordering; one job more than a page tells whether
+	// another page follows
+	listQuery := query.Session(&gorm.Session{})
+	if after != nil {
+		listQuery = afterJobCursor(listQuery, sortKeys, after)
+	} else {
+		listQuery = listQuery.Offset(offset)
+	}
+	if err := listQuery.Preload("MultiTrackFiles").Limit(limit + 1).Order(jobOrder(sortKeys)).Find(&jobs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list jobs"})
 		return
 	}
+	var nextCursor *string
+	if len(jobs) > limit {
+		jobs = jobs[:limit]
+		next := encodeJobCursor(sortName, sortKeys, &jobs[limit-1])
+		nextCursor = &next
+	}
+	for i := range jobs {
+		hideSealedJob(&jobs[i])
+	}
 
+	pagination := gin.H{
+		"limit":       limit,
+		"total":       total,
+		"pages":       (total + int64(limit) - 1) / int64(limit),
+		"search":      search, // Include search term in response
+		"next_cursor": nextCursor,
+	}
+	if cursor == "" {
+		pagination["page"] = page
+	}
 	c.JSON(http.StatusOK, gin.H{
-		"jobs": jobs,
-		"pagination": gin.H{
-			"page":   page,
-			"limit":  limit,
-			"total":  total,
-			"pages":  (total + int64(limit) - 1) / int64(limit),
-			"search": search, // Include search term in response
-		},
+		"jobs":       h.withETAs(jobs),
+		"pagination": pagination,
 	})
 }
 
@@ -1053,9 +1645,12 @@ func (h *Handler) ListJobs(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Job ID"
 // @Param parameters body models.WhisperXParams true "Transcription parameters"
+// @Param run_after query string false "RFC 3339 time before which the job is not transcribed"
+// @Param schedule query string false "Cron expression; the job is not transcribed before its next match"
 // @Success 200 {object} models.TranscriptionJob
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Router /api/v1/transcription/{id}/start [post]
 // @Security ApiKeyAuth
 // @Security BearerAuth
@@ -1073,62 +1668,57 @@ func (h *Handler) StartTranscription(c *gin.Context) {
 	}
 
 	// Allow transcription for uploaded, completed, and failed jobs (re-transcription)
-	if job.Status != models.StatusUploaded && job.Status != models.StatusCompleted && job.Status != models.StatusFailed {
+	if job.Status != models.StatusUploaded && job.Status != models.StatusCompleted && job.Status != models.StatusFailed && job.Status != models.StatusPaused {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Cannot start transcription: job is currently processing or pending"})
 		return
 	}
-
-	// Parse transcription parameters from request body
-	var requestParams models.WhisperXParams
-
-	// Set defaults
-	requestParams = models.WhisperXParams{
-		ModelFamily:                    "whisper", // Default to whisper for backward compatibility
-		Model:                          "small",
-		ModelCacheOnly:                 false,
-		Device:                         "cpu",
-		DeviceIndex:                    0,
-		BatchSize:                      8,
-		ComputeType:                    "float32",
-		Threads:                        0,
-		OutputFormat:                   "all",
-		Verbose:                        true,
-		Task:                           "transcribe",
-		InterpolateMethod:              "nearest",
-		NoAlign:                        false,
-		ReturnCharAlignments:           false,
-		VadMethod:                      "pyannote",
-		VadOnset:                       0.5,
-		VadOffset:                      0.363,
-		ChunkSize:                      30,
-		Diarize:                        false,
-		DiarizeModel:                   "pyannote/speaker-diarization-3.1",
-		SpeakerEmbeddings:              false,
-		Temperature:                    0,
-		BestOf:                         5,
-		BeamSize:                       5,
-		Patience:                       1.0,
-		LengthPenalty:                  1.0,
-		SuppressNumerals:               false,
-		ConditionOnPreviousText:        false,
-		Fp16:                           true,
-		TemperatureIncrementOnFallback: 0.2,
-		CompressionRatioThreshold:      2.4,
-		LogprobThreshold:               -1.0,
-		NoSpeechThreshold:              0.6,
-		HighlightWords:                 false,
-		SegmentResolution:              "sentence",
-		PrintProgress:                  false,
-		AttentionContextLeft:           256,
-		AttentionContextRight:          256,
-		IsMultiTrackEnabled:            false,
+	if job.AudioPath == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot start transcription: the job's media has not been downloaded"})
+		return
+	}
+	// Media moved to the storage backend is brought back to be transcribed
+	if job.OffloadedAt != nil && h.storage.Enabled() {
+		if err := h.storage.Restore(c.Request.Context(), job.ID); err != nil {
+			logger.Warn("Failed to restore job media", "job_id", job.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Cannot start transcription: failed to restore the job's media from storage"})
+			return
+		}
+	}
+	if job.SourceAudioRemovedAt != nil && (job.SourceAudioAction == nil || *job.SourceAudioAction != models.SourceAudioProxy) && !fsys.Exists(h.fs, job.AudioPath) {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot start transcription: the source audio was deleted after transcription"})
+		return
+	}
+	// The key is needed again to seal the new transcript
+	jobKey, ok := h.unlockJob(c, &job)
+	if !ok {
+		return
 	}
+	if job.EncryptedAt != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Cannot start transcription: the job's content is encrypted"})
+		return
+	}
+	runAfter, err := jobRunAfter(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if jobKey != nil {
+		jobcrypt.Hold(job.ID, jobKey)
+	}
+
+	// Parse transcription parameters from request body, over the server's defaults
+	requestParams := h.jobParams.Defaults()
 
 	// Parse request body parameters, overriding defaults
 	if err := c.ShouldBindJSON(&requestParams); err != nil {
 		// Use defaults if JSON parsing fails
 		logger.Debug("Failed to parse JSON parameters, using defaults", "error", err)
 	}
+	h.jobParams.Fill(&requestParams)
+	if err := h.jobParams.Validate(requestParams); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Debug: log what we received
 	logger.Debug("Parsed transcription parameters", 
@@ -1168,23 +1758,64 @@ func (h *Handler) StartTranscription(c *gin.Context) {
 		return
 	}
 
+	switch requestParams.SplitChannels {
+	case "", models.SplitChannelsOff, models.SplitChannelsAuto, models.SplitChannelsAlways:
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "split_channels must be off, auto or always"})
+		return
+	}
+	if job.IsMultiTrack && requestParams.SplitChannels != "" && requestParams.SplitChannels != models.SplitChannelsOff {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Channel splitting cannot be used with multi-track audio"})
+		return
+	}
+	if requestParams.TranslateTo != nil {
+		if err := translation.Validate(translation.ParseLanguages(*requestParams.TranslateTo), requestParams.TranslationProvider); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	rangeStart, rangeEnd, partial := requestParams.TimeRange()
+	if partial {
+		if err := validateTimeRange(&job, requestParams, rangeStart, rangeEnd); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
 	// Update job with parameters
 	job.Parameters = requestParams
 	job.Diarization = requestParams.Diarize
-	job.Status = models.StatusPending
+	job.RunAfter = runAfter
 
-	// Clear previous results for re-transcription
-	job.Transcript = nil
+	// Clear previous results for re-transcription; a partial run replaces
+	// only its stretch of the transcript
+	if !partial {
+		job.Transcript = nil
+	}
 	job.Summary = nil
 	job.ErrorMessage = nil
-
-	// Save updated job
-	if err := database.DB.Save(&job).Error; err != nil {
+	// Starting again with new parameters drops a paused job's checkpoint
+	job.ResumeFrom = nil
+
+	// Save updated job and move it to pending in one transaction
+	if _, err := jobstate.Transition(jobID, models.StatusPending, jobstate.WithTx(func(tx *gorm.DB) error {
+		return tx.Omit("status").Save(&job).Error
+	})); err != nil {
+		if errors.Is(err, jobstate.ErrInvalidTransition) || errors.Is(err, jobstate.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Job cannot be started in its current state"})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update job"})
 		return
 	}
+	job.Status = models.StatusPending
 
-	// Enqueue job for transcription
+	// Enqueue job for transcription, unless the scheduler releases it later
+	if job.RunAfter != nil {
+		logger.Info("Scheduled job for transcription", "job_id", jobID, "run_after", *job.RunAfter)
+		c.JSON(http.StatusOK, job)
+		return
+	}
 	if err := h.taskQueue.EnqueueJob(jobID); err != nil {
 		logger.Error("Failed to enqueue job", "job_id", jobID, "error", err)
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue job"})
@@ -1208,6 +1839,24 @@ func (h *Handler) StartTranscription(c *gin.Context) {
 	c.JSON(http.StatusOK, job)
 }
 
+// validateTimeRange checks the stretch of a job's recording a partial
+// transcription asks for
+func validateTimeRange(job *models.TranscriptionJob, params models.WhisperXParams, start, end float64) error {
+	switch {
+	case job.IsMultiTrack:
+		return errors.New("A time range cannot be transcribed from multi-track audio")
+	case params.SplitChannels != "" && params.SplitChannels != models.SplitChannelsOff:
+		return errors.New("A time range cannot be transcribed with channel splitting")
+	case start < 0:
+		return errors.New("range_start cannot be negative")
+	case params.RangeEnd != nil && end <= start:
+		return errors.New("range_end must be after range_start")
+	case job.AudioDuration != nil && start >= *job.AudioDuration:
+		return fmt.Errorf("range_start is past the end of the recording (%s)", audio.FormatOffset(*job.AudioDuration))
+	}
+	return nil
+}
+
 // @Summary Kill running transcription job
 // @Description Cancel a currently running transcription job
 // @Tags transcription
@@ -1255,9 +1904,11 @@ func (h *Handler) KillJob(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Job ID"
 // @Param request body map[string]string true "Title update request"
+// @Param If-Match header string false "Job version the edit is based on"
 // @Success 200 {object} map[string]interface{}
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Router /api/v1/transcription/{id}/title [put]
 // @Security ApiKeyAuth
 // @Security BearerAuth
@@ -1275,6 +1926,10 @@ func (h *Handler) UpdateTranscriptionTitle(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
 
 	var job models.TranscriptionJob
 	if err := database.DB.Where("id = ?", jobID).First(&job).Error; err != nil {
@@ -1287,17 +1942,22 @@ func (h *Handler) UpdateTranscriptionTitle(c *gin.Context) {
 	}
 
 	job.Title = &body.Title
-	if err := database.DB.Model(&job).Update("title", body.Title).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update title"})
+	version, err := jobversion.Update(database.DB, job.ID, expected, map[string]interface{}{"title": body.Title})
+	if err != nil {
+		if !writeVersionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update title"})
+		}
 		return
 	}
 
+	setJobVersion(c, version)
 	c.JSON(http.StatusOK, gin.H{
 		"id":         job.ID,
 		"title":      job.Title,
 		"status":     job.Status,
 		"created_at": job.CreatedAt,
 		"audio_path": job.AudioPath,
+		"version":    version,
 	})
 }
 
@@ -1333,7 +1993,7 @@ func (h *Handler) DeleteJob(c *gin.Context) {
 
 	// Delete the audio file from filesystem
 	if job.AudioPath != "" {
-		if err := os.Remove(job.AudioPath); err != nil && !os.IsNotExist(err) {
+		if err := h.fs.Remove(job.AudioPath); err != nil && !os.IsNotExist(err) {
 			// Log the error but don't fail the request - database cleanup is more important
 			fmt.Printf("Warning: Failed to delete audio file %s: %v\n", job.AudioPath, err)
 		}
@@ -1341,27 +2001,46 @@ func (h *Handler) DeleteJob(c *gin.Context) {
 
 	// Delete multi-track files and folders if this is a multi-track job
 	if job.IsMultiTrack && job.MultiTrackFolder != nil {
-		if err := os.RemoveAll(*job.MultiTrackFolder); err != nil && !os.IsNotExist(err) {
+		if err := h.fs.RemoveAll(*job.MultiTrackFolder); err != nil && !os.IsNotExist(err) {
 			fmt.Printf("Warning: Failed to delete multi-track folder %s: %v\n", *job.MultiTrackFolder, err)
 		}
 	}
 
 	// Delete merged audio file if it exists
 	if job.MergedAudioPath != nil && *job.MergedAudioPath != "" {
-		if err := os.Remove(*job.MergedAudioPath); err != nil && !os.IsNotExist(err) {
+		if err := h.fs.Remove(*job.MergedAudioPath); err != nil && !os.IsNotExist(err) {
 			fmt.Printf("Warning: Failed to delete merged audio file %s: %v\n", *job.MergedAudioPath, err)
 		}
 	}
 
+	// Delete the playback proxy if it exists
+	if job.ProxyAudioPath != nil && *job.ProxyAudioPath != "" {
+		if err := h.fs.Remove(*job.ProxyAudioPath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Failed to delete playback proxy %s: %v\n", *job.ProxyAudioPath, err)
+		}
+	}
+
+	// Delete the waveform peaks if they exist
+	if job.WaveformPath != nil && *job.WaveformPath != "" {
+		if err := h.fs.Remove(*job.WaveformPath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Failed to delete waveform %s: %v\n", *job.WaveformPath, err)
+		}
+	}
+
 	// Delete any transcript files
 	if job.Transcript != nil {
 		// Remove transcript directory if it exists (assume it's in data/transcripts)
 		transcriptDir := filepath.Join("data", "transcripts", jobID)
-		if err := os.RemoveAll(transcriptDir); err != nil && !os.IsNotExist(err) {
+		if err := h.fs.RemoveAll(transcriptDir); err != nil && !os.IsNotExist(err) {
 			fmt.Printf("Warning: Failed to delete transcript directory %s: %v\n", transcriptDir, err)
 		}
 	}
 
+	// Delete the copies kept in the storage backend
+	if _, err := h.storage.Purge(c.Request.Context(), jobID); err != nil {
+		fmt.Printf("Warning: Failed to delete stored files of job %s: %v\n", jobID, err)
+	}
+
 	// Delete all related records first to avoid foreign key constraint failures
 	// Start a transaction to ensure atomicity
 	tx := database.DB.Begin()
@@ -1396,6 +2075,24 @@ func (h *Handler) DeleteJob(c *gin.Context) {
 		return
 	}
 
+	if err := tx.Where("transcription_id = ?", jobID).Delete(&models.Translation{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete translations"})
+		return
+	}
+
+	if err := tx.Where("transcription_id = ?", jobID).Delete(&models.TranscriptRevision{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete transcript revisions"})
+		return
+	}
+
+	if err := tx.Where("transcription_id = ?", jobID).Delete(&models.TranscriptInsights{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete insights"})
+		return
+	}
+
 	// Delete chat sessions and their messages
 	var chatSessions []models.ChatSession
 	if err := tx.Where("transcription_id = ?", jobID).Find(&chatSessions).Error; err != nil {
@@ -1418,6 +2115,53 @@ func (h *Handler) DeleteJob(c *gin.Context) {
 		return
 	}
 
+	if err := tx.Where("job_id = ?", jobID).Delete(&models.JobEvent{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete job events"})
+		return
+	}
+
+	if err := tx.Where("job_id = ?", jobID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook deliveries"})
+		return
+	}
+
+	// Archive members stay recorded so a retried archive does not ingest them
+	// again; they just no longer point at the job
+	if err := tx.Model(&models.IngestedArchiveMember{}).Where("job_id = ?", jobID).Update("job_id", nil).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unlink ingested archive members"})
+		return
+	}
+
+	if err := tx.Where("job_id = ?", jobID).Delete(&models.RemoteDownload{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete media download"})
+		return
+	}
+
+	if err := tx.Where("job_id = ?", jobID).Delete(&models.StoredObject{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete stored file records"})
+		return
+	}
+
+	// Conversions of the job's audio go with it; their files are removed once
+	// the deletion is committed
+	var conversions []models.AudioConversion
+	if err := tx.Where("job_id = ?", jobID).Find(&conversions).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to find audio conversions"})
+		return
+	}
+
+	if err := tx.Where("job_id = ?", jobID).Delete(&models.AudioConversion{}).Error; err != nil {
+		tx.Rollback()
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete audio conversions"})
+		return
+	}
+
 	// Finally delete the main job record
 	if err := tx.Delete(&job).Error; err != nil {
 		tx.Rollback()
@@ -1430,6 +2174,15 @@ func (h *Handler) DeleteJob(c *gin.Context) {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit deletion transaction"})
 		return
 	}
+	for _, conversion := range conversions {
+		if conversion.OutputPath == "" {
+			continue
+		}
+		if err := h.fs.Remove(conversion.OutputPath); err != nil && !os.IsNotExist(err) {
+			fmt.Printf("Warning: Failed to delete converted audio %s: %v\n", conversion.OutputPath, err)
+		}
+	}
+	reindexTranscript(jobID)
 
 	c.JSON(http.StatusOK, gin.H{"message": "Job deleted successfully"})
 }
@@ -1458,6 +2211,21 @@ func (h *Handler) GetJobByID(c *gin.Context) {
 		return
 	}
 
+	// Encrypted content is only returned to requests carrying the job's key
+	if job.Encrypted && c.GetHeader(jobcrypt.KeyHeader) != "" {
+		key, ok := h.unlockJob(c, &job)
+		if !ok {
+			return
+		}
+		if err := openSealedJob(&job, key); err != nil {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Wrong job key"})
+			return
+		}
+	} else {
+		hideSealedJob(&job)
+	}
+
+	setJobVersion(c, job.Version)
 	c.JSON(http.StatusOK, job)
 }
 
@@ -1533,12 +2301,16 @@ func (h *Handler) GetJobExecutionData(c *gin.Context) {
 }
 
 // @Summary Get audio file
-// @Description Serve the audio file for a transcription job
+// @Description Serve the audio file for a transcription job, preferring the small playback proxy when one exists. Supports Range requests.
 // @Tags transcription
-// @Produce audio/mpeg,audio/wav,audio/mp4
+// @Produce audio/mpeg,audio/wav,audio/mp4,audio/ogg
 // @Param id path string true "Job ID"
+// @Param source query string false "auto (default), original or proxy"
 // @Success 200 {file} binary
+// @Success 206 {file} binary
+// @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
 // @Router /api/v1/transcription/{id}/audio [get]
 // @Security ApiKeyAuth
 func (h *Handler) GetAudioFile(c *gin.Context) {
@@ -1554,61 +2326,125 @@ func (h *Handler) GetAudioFile(c *gin.Context) {
 		return
 	}
 
-	// Debug logging
-	fmt.Printf("DEBUG: GetAudioFile for job %s\n", jobID)
-	fmt.Printf("DEBUG: Job status: %s\n", job.Status)
-	fmt.Printf("DEBUG: Audio path: '%s'\n", job.AudioPath)
+	// Playback uses the small proxy when there is one; ?source=original or
+	// ?source=proxy asks for one explicitly
+	source := c.DefaultQuery("source", "auto")
+	if source != "auto" && source != "original" && source != "proxy" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "source must be auto, original or proxy"})
+		return
+	}
+
+	key, ok := h.unlockJob(c, &job)
+	if !ok {
+		return
+	}
+	if job.EncryptedAt != nil {
+		h.serveSealedAudio(c, &job, key)
+		return
+	}
+
+	logger.Debug("Getting audio file", "job_id", jobID, "status", job.Status, "audio_path", job.AudioPath, "source", source)
 
 	// For multi-track jobs, prefer merged audio if available
-	audioPath := job.AudioPath
+	originalPath := job.AudioPath
 	if job.IsMultiTrack && job.MergedAudioPath != nil && *job.MergedAudioPath != "" {
 		// Check if merged audio file exists
-		if _, err := os.Stat(*job.MergedAudioPath); err == nil {
-			audioPath = *job.MergedAudioPath
-			fmt.Printf("DEBUG: Using merged audio: %s\n", audioPath)
+		if fsys.Exists(h.fs, *job.MergedAudioPath) {
+			originalPath = *job.MergedAudioPath
+			logger.Debug("Using merged audio", "job_id", jobID, "path", originalPath)
 		} else {
-			fmt.Printf("DEBUG: Merged audio not found, falling back to original: %s\n", job.AudioPath)
+			logger.Debug("Merged audio not found, falling back to original", "job_id", jobID, "path", job.AudioPath)
 		}
 	}
+	originalAvailable := originalPath != "" && fsys.Exists(h.fs, originalPath)
+	proxyAvailable := job.ProxyAudioPath != nil && fsys.Exists(h.fs, *job.ProxyAudioPath)
 
-	// Check if audio file exists
-	if audioPath == "" {
-		fmt.Printf("DEBUG: Audio path is empty\n")
-		c.JSON(http.StatusNotFound, gin.H{"error": "Audio file path not found"})
-		return
+	// Audio moved to the storage backend is served from there
+	var stored *models.StoredObject
+	if !originalAvailable && job.OffloadedAt != nil && h.storage.Enabled() {
+		var err error
+		if stored, err = h.storedAudio(&job); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up stored audio"})
+			return
+		}
 	}
 
-	// Check if file exists on filesystem
-	if _, err := os.Stat(audioPath); os.IsNotExist(err) {
-		fmt.Printf("DEBUG: Audio file does not exist on disk: %s\n", audioPath)
+	var audioPath string
+	switch {
+	case source != "original" && proxyAvailable:
+		audioPath = *job.ProxyAudioPath
+		source = "proxy"
+	case source == "proxy":
+		c.JSON(http.StatusNotFound, gin.H{"error": "Playback proxy not available"})
+		return
+	case originalAvailable:
+		audioPath = originalPath
+		source = "original"
+	case stored != nil:
+		h.serveStoredAudio(c, stored, "")
+		return
+	case job.SourceAudioRemovedAt != nil:
+		c.JSON(http.StatusGone, gin.H{"error": "The source audio was deleted after transcription"})
+		return
+	case originalPath == "":
+		logger.Debug("Audio path is empty", "job_id", jobID)
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audio file path not found"})
+		return
+	default:
+		logger.Debug("Audio file does not exist on disk", "job_id", jobID, "path", originalPath)
 		c.JSON(http.StatusNotFound, gin.H{"error": "Audio file not found on disk"})
 		return
 	}
 
-	fmt.Printf("DEBUG: Audio file exists, serving: %s\n", audioPath)
+	logger.Debug("Serving audio file", "job_id", jobID, "source", source, "path", audioPath)
 
 	// Set appropriate content type based on file extension
-	ext := filepath.Ext(job.AudioPath)
-	switch ext {
-	case ".mp3":
-		c.Header("Content-Type", "audio/mpeg")
-	case ".wav":
-		c.Header("Content-Type", "audio/wav")
-	case ".m4a":
-		c.Header("Content-Type", "audio/mp4")
-	case ".ogg":
-		c.Header("Content-Type", "audio/ogg")
-	default:
-		c.Header("Content-Type", "audio/mpeg")
-	}
+	c.Header("Content-Type", audioContentType(audioPath))
+	c.Header("X-Audio-Source", source)
 
 	// Add CORS headers for audio
 	c.Header("Access-Control-Allow-Origin", "*")
 	c.Header("Access-Control-Allow-Methods", "GET")
-	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-API-Key")
+	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-API-Key, Range")
+	c.Header("Access-Control-Expose-Headers", "Content-Range, Accept-Ranges, Content-Length, X-Audio-Source")
+
+	// Serve the audio file; Range requests get partial content
+	c.File(audioPath)
+}
+
+// serveSealedAudio decrypts an encrypted job's audio while sending it.
+// Sealed files keep their plaintext offsets, so Range requests still work.
+func (h *Handler) serveSealedAudio(c *gin.Context, job *models.TranscriptionJob, key []byte) {
+	if !fsys.Exists(h.fs, job.AudioPath) {
+		if job.SourceAudioRemovedAt != nil {
+			c.JSON(http.StatusGone, gin.H{"error": "The source audio was deleted after transcription"})
+			return
+		}
+		c.JSON(http.StatusNotFound, gin.H{"error": "Audio file not found on disk"})
+		return
+	}
+	info, err := h.fs.Stat(job.AudioPath)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read audio file"})
+		return
+	}
+	reader, closer, err := jobcrypt.OpenFile(h.fs, job.AudioPath, key)
+	if err != nil {
+		if errors.Is(err, jobcrypt.ErrWrongKey) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Wrong job key"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to decrypt audio file"})
+		return
+	}
+	defer closer.Close()
 
-	// Serve the audio file
-	c.File(job.AudioPath)
+	c.Header("X-Audio-Source", "original")
+	c.Header("Access-Control-Allow-Origin", "*")
+	c.Header("Access-Control-Allow-Methods", "GET")
+	c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Authorization, X-API-Key, Range, "+jobcrypt.KeyHeader)
+	c.Header("Access-Control-Expose-Headers", "Content-Range, Accept-Ranges, Content-Length, X-Audio-Source")
+	http.ServeContent(c.Writer, c.Request, filepath.Base(jobcrypt.PlainName(job.AudioPath)), info.ModTime(), reader)
 }
 
 // @Summary Login
@@ -1683,6 +2519,7 @@ func (h *Handler) Logout(c *gin.Context) {
 		SameSite: http.SameSiteLaxMode,
 		Secure:   false,
 	})
+	middleware.ClearCSRFToken(c)
 	c.JSON(http.StatusOK, gin.H{"message": "Logged out successfully"})
 }
 
@@ -1817,6 +2654,89 @@ func (h *Handler) Refresh(c *gin.Context) {
 	c.JSON(http.StatusOK, RefreshTokenResponse{Token: token})
 }
 
+// CSRFTokenResponse represents the CSRF token issuance response
+type CSRFTokenResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// @Summary Issue CSRF token
+// @Description Issue a double-submit CSRF token. The token is set as a cookie and must be echoed in the X-CSRF-Token header on state-changing requests authenticated by the session cookie
+// @Tags auth
+// @Produce json
+// @Success 200 {object} CSRFTokenResponse
+// @Router /api/v1/auth/csrf [get]
+func (h *Handler) GetCSRFToken(c *gin.Context) {
+	token, err := middleware.IssueCSRFToken(c)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate CSRF token"})
+		return
+	}
+	c.JSON(http.StatusOK, CSRFTokenResponse{CSRFToken: token})
+}
+
+// ingestAudioMetadata reads embedded tags into the job and, when configured,
+// strips them from the stored copy. Failures are logged and never block the upload.
+func (h *Handler) ingestAudioMetadata(ctx context.Context, job *models.TranscriptionJob, originalName string, overrideTitle bool) {
+	if _, err := audio.IngestMetadata(h.fs, job, originalName, overrideTitle); err != nil {
+		logger.Warn("Failed to read audio metadata", "job_id", job.ID, "error", err)
+	}
+	if h.config.StripAudioMetadata {
+		if err := audio.StripMetadata(logger.WithJobID(ctx, job.ID), "ffmpeg", job.AudioPath); err != nil {
+			logger.Warn("Failed to strip audio metadata", "job_id", job.ID, "error", err)
+		}
+	}
+}
+
+// probeUpload stores the duration, sample rate, channels, codec and bit rate
+// of a job's uploaded audio, and rejects a file whose content is not the
+// container its name claims, e.g. a WAV file renamed to .mp3, or whose audio
+// cannot be decoded. It writes the error response and removes the file
+// itself, and reports whether the upload may go on. Nothing is checked when
+// ffprobe and ffmpeg are not installed.
+func (h *Handler) probeUpload(c *gin.Context, job *models.TranscriptionJob, originalName string) bool {
+	if h.fs != fsys.OS {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(logger.WithJobID(c.Request.Context(), job.ID), probeTimeout)
+	defer cancel()
+	result, err := audio.Probe(ctx, h.ffprobePath, job.AudioPath)
+	if err != nil {
+		if !errors.Is(err, exec.ErrNotFound) {
+			logger.Warn("Failed to probe upload", "job_id", job.ID, "error", err)
+		}
+		return h.checkDecodable(c, job, 0)
+	}
+	if err := result.CheckContainer(originalName); err != nil {
+		h.fs.Remove(job.AudioPath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+	result.Apply(job)
+	return h.checkDecodable(c, job, result.Duration)
+}
+
+// checkDecodable test-decodes the start of an upload, writing the error
+// response and removing the file itself when it is truncated or corrupt.
+// Uploads are let through when ffmpeg is missing or too slow to tell.
+func (h *Handler) checkDecodable(c *gin.Context, job *models.TranscriptionJob, duration float64) bool {
+	if h.config.DecodeCheckSeconds <= 0 {
+		return true
+	}
+	ctx, cancel := context.WithTimeout(logger.WithJobID(c.Request.Context(), job.ID), probeTimeout)
+	defer cancel()
+	err := audio.CheckDecodable(ctx, h.ffmpegPath, job.AudioPath, h.config.DecodeCheckSeconds, duration)
+	var decodeErr *audio.DecodeError
+	if errors.As(err, &decodeErr) {
+		h.fs.Remove(job.AudioPath)
+		c.JSON(http.StatusBadRequest, gin.H{"error": decodeErr.Error()})
+		return false
+	}
+	if err != nil && !errors.Is(err, exec.ErrNotFound) {
+		logger.Warn("Failed to test-decode upload", "job_id", job.ID, "error", err)
+	}
+	return true
+}
+
 // issueRefreshToken creates a refresh token and sets cookie
 func (h *Handler) issueRefreshToken(c *gin.Context, userID uint) error {
 	tokenValue := generateSecureAPIKey(64)
@@ -1840,6 +2760,10 @@ func (h *Handler) issueRefreshToken(c *gin.Context, userID uint) error {
 		SameSite: http.SameSiteLaxMode,
 		Secure:   false,
 	})
+	// Pair every session cookie with a fresh CSRF token for the web UI
+	if _, err := middleware.IssueCSRFToken(c); err != nil {
+		return err
+	}
 	return nil
 }
 
@@ -2004,8 +2928,16 @@ func (h *Handler) ListAPIKeys(c *gin.Context) {
 
 	// Transform API keys to list response format
 	var responseKeys []APIKeyListResponse
+	since := time.Now().Add(-24 * time.Hour)
 	for _, apiKey := range apiKeys {
-		responseKeys = append(responseKeys, transformAPIKeyForList(apiKey))
+		response := transformAPIKeyForList(apiKey)
+		if totals, err := h.usageTracker.Usage(apiKey.ID, since); err == nil {
+			response.Usage = &totals
+		}
+		database.DB.Model(&models.APIKeyAlert{}).
+			Where("api_key_id = ? AND resolved_at IS NULL", apiKey.ID).
+			Count(&response.OpenAlerts)
+		responseKeys = append(responseKeys, response)
 	}
 
 	c.JSON(http.StatusOK, APIKeysWrapper{APIKeys: responseKeys})
@@ -2209,7 +3141,7 @@ func generateSecureAPIKey(length int) string {
 }
 
 // @Summary Get queue statistics
-// @Description Get current queue statistics
+// @Description Get current queue statistics, including each device jobs run on with its memory, utilization and running jobs
 // @Tags admin
 // @Produce json
 // @Success 200 {object} map[string]interface{}
@@ -2241,16 +3173,23 @@ func (h *Handler) GetSupportedModels(c *gin.Context) {
 
 // Health check endpoint
 // @Summary Health check
-// @Description Check if the API is healthy
+// @Description Check if the API is healthy. Once ffmpeg has been checked at startup its version, location and missing filters are reported, and the status is degraded when it cannot be used.
 // @Tags health
 // @Produce json
-// @Success 200 {object} map[string]string
+// @Success 200 {object} map[string]interface{}
 // @Router /health [get]
 func (h *Handler) HealthCheck(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
+	response := gin.H{
 		"status":  "healthy",
 		"version": "1.0.0",
-	})
+	}
+	if status, ok := ffmpegbin.Current(); ok {
+		response["ffmpeg"] = status
+		if !status.Healthy {
+			response["status"] = "degraded"
+		}
+	}
+	c.JSON(http.StatusOK, response)
 }
 
 // Helper functions
@@ -2330,6 +3269,10 @@ func (h *Handler) CreateProfile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile name is required"})
 		return
 	}
+	if err := h.checkProfileParameters(profile.Parameters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Check if profile name already exists
 	var existingProfile models.TranscriptionProfile
@@ -2347,6 +3290,13 @@ func (h *Handler) CreateProfile(c *gin.Context) {
 	c.JSON(http.StatusOK, profile)
 }
 
+// checkProfileParameters validates a profile's parameters as those of a job
+// using it; options it leaves out take the server defaults
+func (h *Handler) checkProfileParameters(params models.WhisperXParams) error {
+	h.jobParams.Fill(&params)
+	return h.jobParams.Validate(params)
+}
+
 // @Summary Get transcription profile
 // @Description Get a transcription profile by ID
 // @Tags profiles
@@ -2410,6 +3360,10 @@ func (h *Handler) UpdateProfile(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Profile name is required"})
 		return
 	}
+	if err := h.checkProfileParameters(updatedProfile.Parameters); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Check if profile name already exists (excluding current profile)
 	var nameCheck models.TranscriptionProfile
@@ -2521,11 +3475,71 @@ type QuickTranscriptionRequest struct {
 // @Security ApiKeyAuth
 // @Security BearerAuth
 func (h *Handler) SubmitQuickTranscription(c *gin.Context) {
+	job, ok := h.submitQuickTranscription(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// @Summary Transcribe a short clip synchronously
+// @Description Transcribe a short clip, such as a voicemail or voice note, and return the transcript inline. When the transcript is not ready within the timeout the job keeps running and 202 is returned with the job to poll.
+// @Tags transcription
+// @Accept multipart/form-data
+// @Produce json
+// @Param audio formData file true "Audio file"
+// @Param parameters formData string false "JSON string of transcription parameters"
+// @Param profile_name formData string false "Profile name to use for transcription"
+// @Param timeout formData int false "Seconds to wait for the transcript, at most the configured QUICK_SYNC_TIMEOUT_SECONDS"
+// @Success 200 {object} transcription.QuickTranscriptionJob
+// @Success 202 {object} transcription.QuickTranscriptionJob
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/quick/sync [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) SubmitQuickTranscriptionSync(c *gin.Context) {
+	timeout := time.Duration(h.config.QuickSyncTimeoutSeconds) * time.Second
+	if value := c.PostForm("timeout"); value != "" {
+		seconds, err := strconv.Atoi(value)
+		if err != nil || seconds < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "timeout must be a number of seconds"})
+			return
+		}
+		timeout = min(timeout, time.Duration(seconds)*time.Second)
+	}
+
+	job, ok := h.submitQuickTranscription(c)
+	if !ok {
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), timeout)
+	defer cancel()
+	finished, err := h.quickTranscription.WaitQuickJob(ctx, job.ID)
+	switch {
+	case errors.Is(err, context.DeadlineExceeded):
+		c.Header("Location", "/api/v1/transcription/quick/"+job.ID)
+		c.JSON(http.StatusAccepted, finished)
+	case err != nil:
+		// The client went away; the job is left to finish for a later poll
+		if c.Request.Context().Err() == nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to wait for quick transcription"})
+		}
+	default:
+		c.JSON(http.StatusOK, finished)
+	}
+}
+
+// submitQuickTranscription starts a quick transcription of the uploaded
+// audio, answering the request itself when it cannot
+func (h *Handler) submitQuickTranscription(c *gin.Context) (*transcription.QuickTranscriptionJob, bool) {
 	// Parse multipart form
 	file, header, err := c.Request.FormFile("audio")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Audio file is required"})
-		return
+		return nil, false
 	}
 	defer file.Close()
 
@@ -2538,17 +3552,17 @@ func (h *Handler) SubmitQuickTranscription(c *gin.Context) {
 		if err := database.DB.Where("name = ?", profileName).First(&profile).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
 				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Profile '%s' not found", profileName)})
-				return
+				return nil, false
 			}
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load profile"})
-			return
+			return nil, false
 		}
 		params = profile.Parameters
 	} else if parametersJSON := c.PostForm("parameters"); parametersJSON != "" {
 		// Parse parameters from JSON string
 		if err := json.Unmarshal([]byte(parametersJSON), &params); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid parameters JSON"})
-			return
+			return nil, false
 		}
 	} else {
 		// Use default parameters with all required fields
@@ -2612,10 +3626,9 @@ func (h *Handler) SubmitQuickTranscription(c *gin.Context) {
 	job, err := h.quickTranscription.SubmitQuickJob(file, header.Filename, params)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to submit quick transcription: %v", err)})
-		return
+		return nil, false
 	}
-
-	c.JSON(http.StatusOK, job)
+	return job, true
 }
 
 // @Summary Get quick transcription status
@@ -2644,13 +3657,15 @@ func (h *Handler) GetQuickTranscriptionStatus(c *gin.Context) {
 	c.JSON(http.StatusOK, job)
 }
 
+// DownloadFromYouTube creates a job from a YouTube video, through the same
+// guarded download path as SubmitURL
 // @Summary Download audio from YouTube URL
-// @Description Download audio from a YouTube video URL and prepare it for transcription
+// @Description Create a job whose audio is downloaded from a YouTube video in the background. Only accepted when the server enables YouTube downloads; once downloaded and checked the job is queued for transcription.
 // @Tags transcription
 // @Accept json
 // @Produce json
 // @Param request body YouTubeDownloadRequest true "YouTube download request"
-// @Success 200 {object} models.Transcription
+// @Success 202 {object} SubmitURLResponse
 // @Failure 400 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Router /api/v1/transcription/youtube [post]
@@ -2662,157 +3677,7 @@ func (h *Handler) DownloadFromYouTube(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
-
-	// Validate YouTube URL
-	if !strings.Contains(req.URL, "youtube.com") && !strings.Contains(req.URL, "youtu.be") {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube URL"})
-		return
-	}
-
-	// Create upload directory
-	uploadDir := h.config.UploadDir
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload directory"})
-		return
-	}
-
-	// Generate unique job ID and filename
-	jobID := uuid.New().String()
-	filename := fmt.Sprintf("%s.%%(ext)s", jobID)
-	filePath := filepath.Join(uploadDir, filename)
-
-	// Get video title if not provided
-	var title string
-	if req.Title != nil && *req.Title != "" {
-		title = *req.Title
-	} else {
-		// Get title from yt-dlp
-		cmd := exec.Command(h.config.UVPath, "run", "--native-tls", "--project", h.config.WhisperXEnv, "python", "-m", "yt_dlp", "--get-title", req.URL)
-		titleBytes, err := cmd.Output()
-		if err != nil {
-			title = "YouTube Audio"
-		} else {
-			title = strings.TrimSpace(string(titleBytes))
-		}
-	}
-
-	// Download audio using yt-dlp in Python environment
-	// Use extractor args to bypass bot detection and avoid requiring cookies
-	ytDlpCmd := exec.Command(h.config.UVPath, "run", "--native-tls", "--project", h.config.WhisperXEnv, "python", "-m", "yt_dlp",
-		"--extract-audio",
-		"--audio-format", "mp3",
-		"--audio-quality", "0", // best quality
-		"--output", filePath,
-		"--no-playlist",
-		"--extractor-args", "youtube:player_client=default,web",
-		"--user-agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
-		req.URL,
-	)
-
-	// Capture stderr for debugging
-	var stderr bytes.Buffer
-	ytDlpCmd.Stderr = &stderr
-
-	// Execute download
-	if err := ytDlpCmd.Run(); err != nil {
-		logger.Error("YouTube download failed", "error", err, "stderr", stderr.String())
-		c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to download YouTube audio: %v. Details: %s", err, stderr.String())})
-		return
-	}
-
-	// Find the actual downloaded file (yt-dlp changes the extension)
-	pattern := fmt.Sprintf("%s.*", jobID)
-	matches, err := filepath.Glob(filepath.Join(uploadDir, pattern))
-	if err != nil || len(matches) == 0 {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Downloaded file not found"})
-		return
-	}
-
-	actualFilePath := matches[0]
-
-	// Create transcription record
-	job := models.TranscriptionJob{
-		ID:        jobID,
-		AudioPath: actualFilePath,
-		Status:    models.StatusPending, // Automatically start pending
-	}
-
-	// Set title
-	if title != "" {
-		job.Title = &title
-	}
-
-	// Set default transcription parameters for automatic processing
-	defaultParams := models.WhisperXParams{
-		ModelFamily:                    "whisper",
-		Model:                          "small",
-		ModelCacheOnly:                 false,
-		Device:                         "cpu",
-		DeviceIndex:                    0,
-		BatchSize:                      8,
-		ComputeType:                    "float32",
-		Threads:                        0,
-		OutputFormat:                   "all",
-		Verbose:                        true,
-		Task:                           "transcribe",
-		InterpolateMethod:              "nearest",
-		NoAlign:                        false,
-		ReturnCharAlignments:           false,
-		VadMethod:                      "pyannote",
-		VadOnset:                       0.5,
-		VadOffset:                      0.363,
-		ChunkSize:                      30,
-		Diarize:                        false,
-		DiarizeModel:                   "pyannote/speaker-diarization-3.1",
-		SpeakerEmbeddings:              false,
-		Temperature:                    0,
-		BestOf:                         5,
-		BeamSize:                       5,
-		Patience:                       1.0,
-		LengthPenalty:                  1.0,
-		SuppressNumerals:               false,
-		ConditionOnPreviousText:        false,
-		Fp16:                           true,
-		TemperatureIncrementOnFallback: 0.2,
-		CompressionRatioThreshold:      2.4,
-		LogprobThreshold:               -1.0,
-		NoSpeechThreshold:              0.6,
-		HighlightWords:                 false,
-		SegmentResolution:              "sentence",
-		PrintProgress:                  false,
-		AttentionContextLeft:           256,
-		AttentionContextRight:          256,
-		IsMultiTrackEnabled:            false,
-	}
-	job.Parameters = defaultParams
-
-	// Save to database
-	if err := database.DB.Create(&job).Error; err != nil {
-		// Clean up downloaded file on database error
-		os.Remove(actualFilePath)
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save transcription record"})
-		return
-	}
-
-	// Enqueue job for transcription immediately
-	if err := h.taskQueue.EnqueueJob(jobID); err != nil {
-		logger.Error("Failed to enqueue YouTube job", "job_id", jobID, "error", err)
-		// We don't fail the request, but the job will remain in pending state without being in queue
-		// User might need to manually retry or we should have a recovery mechanism
-	} else {
-		logger.Info("Automatically enqueued YouTube job", "job_id", jobID)
-		
-		// Log job started
-		params := make(map[string]any)
-		params["model"] = defaultParams.Model
-		params["model_family"] = defaultParams.ModelFamily
-		params["source"] = "youtube"
-		
-		filename := filepath.Base(job.AudioPath)
-		logger.JobStarted(jobID, filename, defaultParams.ModelFamily, params)
-	}
-
-	c.JSON(http.StatusOK, job)
+	h.submitURL(c, SubmitURLRequest{URL: req.URL, Title: req.Title}, models.DownloadSourceYouTube)
 }
 
 // @Summary Get user's default profile
@@ -2930,12 +3795,16 @@ type UserSettingsResponse struct {
 	AutoTranscriptionEnabled bool    `json:"auto_transcription_enabled"`
 	FastFinalizeEnabled      bool    `json:"fast_finalize_enabled"`
 	DefaultProfileID         *string `json:"default_profile_id,omitempty"`
+	SourceAudioAction        *string `json:"source_audio_action,omitempty"` // keep, delete or proxy; unset uses the server default
+	Email                    *string `json:"email,omitempty"`               // Recordings mailed in from this address become the user's jobs
 }
 
 // UpdateUserSettingsRequest represents the request to update user settings
 type UpdateUserSettingsRequest struct {
-	AutoTranscriptionEnabled *bool `json:"auto_transcription_enabled,omitempty"`
-	FastFinalizeEnabled      *bool `json:"fast_finalize_enabled,omitempty"`
+	AutoTranscriptionEnabled *bool   `json:"auto_transcription_enabled,omitempty"`
+	FastFinalizeEnabled      *bool   `json:"fast_finalize_enabled,omitempty"`
+	SourceAudioAction        *string `json:"source_audio_action,omitempty"` // keep, delete or proxy; "" clears it
+	Email                    *string `json:"email,omitempty"`               // "" clears it
 }
 
 // @Summary Get user settings
@@ -2964,6 +3833,8 @@ func (h *Handler) GetUserSettings(c *gin.Context) {
 		AutoTranscriptionEnabled: user.AutoTranscriptionEnabled,
 		FastFinalizeEnabled:      user.FastFinalizeEnabled,
 		DefaultProfileID:         user.DefaultProfileID,
+		SourceAudioAction:        user.SourceAudioAction,
+		Email:                    user.Email,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -2978,6 +3849,7 @@ func (h *Handler) GetUserSettings(c *gin.Context) {
 // @Success 200 {object} UserSettingsResponse
 // @Failure 400 {object} map[string]string
 // @Failure 401 {object} map[string]string
+// @Failure 409 {object} map[string]string
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Router /api/v1/user/settings [put]
@@ -3007,6 +3879,36 @@ func (h *Handler) UpdateUserSettings(c *gin.Context) {
 	if req.FastFinalizeEnabled != nil {
 		user.FastFinalizeEnabled = *req.FastFinalizeEnabled
 	}
+	if req.SourceAudioAction != nil {
+		switch action := *req.SourceAudioAction; {
+		case action == "":
+			user.SourceAudioAction = nil
+		case sourceaudio.ValidAction(action):
+			user.SourceAudioAction = &action
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "source_audio_action must be keep, delete or proxy"})
+			return
+		}
+	}
+	if req.Email != nil {
+		if *req.Email == "" {
+			user.Email = nil
+		} else {
+			address, err := mail.ParseAddress(*req.Email)
+			if err != nil || address.Name != "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "email must be a plain email address"})
+				return
+			}
+			email := strings.ToLower(address.Address)
+			var taken int64
+			database.DB.Model(&models.User{}).Where("email = ? AND id <> ?", email, user.ID).Count(&taken)
+			if taken > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": "email is already used by another account"})
+				return
+			}
+			user.Email = &email
+		}
+	}
 
 	// Save updated user
 	if err := database.DB.Save(&user).Error; err != nil {
@@ -3018,6 +3920,8 @@ func (h *Handler) UpdateUserSettings(c *gin.Context) {
 		AutoTranscriptionEnabled: user.AutoTranscriptionEnabled,
 		FastFinalizeEnabled:      user.FastFinalizeEnabled,
 		DefaultProfileID:         user.DefaultProfileID,
+		SourceAudioAction:        user.SourceAudioAction,
+		Email:                    user.Email,
 	}
 
 	c.JSON(http.StatusOK, response)
@@ -3103,9 +4007,11 @@ func (h *Handler) GetSpeakerMappings(c *gin.Context) {
 // @Produce json
 // @Param id path string true "Transcription Job ID"
 // @Param request body SpeakerMappingsUpdateRequest true "Speaker mappings to update"
+// @Param If-Match header string false "Job version the edit is based on"
 // @Success 200 {array} SpeakerMappingResponse
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]string
 // @Security BearerAuth
 // @Security ApiKeyAuth
@@ -3118,6 +4024,10 @@ func (h *Handler) UpdateSpeakerMappings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
 		return
 	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
 
 	// Verify the transcription job exists and has diarization enabled
 	var job models.TranscriptionJob
@@ -3182,7 +4092,16 @@ func (h *Handler) UpdateSpeakerMappings(c *gin.Context) {
 		updatedMappings = append(updatedMappings, speakerMapping)
 	}
 
+	version, err := jobversion.Bump(tx, jobID, expected)
+	if err != nil {
+		tx.Rollback()
+		if !writeVersionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update speaker mappings"})
+		}
+		return
+	}
 	tx.Commit()
+	setJobVersion(c, version)
 
 	// Convert to response format
 	response := make([]SpeakerMappingResponse, len(updatedMappings))
@@ -0,0 +1,102 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/postprocess"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// InsightsResponse is a job's post-processing status with its artifacts
+type InsightsResponse struct {
+	models.TranscriptInsights
+	ActionItems []postprocess.ActionItem `json:"action_items"`
+	Chapters    []postprocess.Chapter    `json:"chapters"`
+}
+
+// SetPostProcessor overrides the service drawing insights from transcripts, mainly for tests
+func (h *Handler) SetPostProcessor(postprocessor *postprocess.Service) {
+	h.postprocessor = postprocessor
+}
+
+// TrackInsights draws insights from jobs as they complete, until the
+// returned function is called
+func (h *Handler) TrackInsights() func() {
+	return h.postprocessor.Track()
+}
+
+// ResumeInsights continues post-processing interrupted by a restart
+func (h *Handler) ResumeInsights() {
+	h.postprocessor.Resume()
+}
+
+// GetInsights returns the summary, action items and chapters of a transcript
+// @Summary Get transcript insights
+// @Description Get what post-processing drew from a transcript: a summary, action items and chapter markers, with the status of the run
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} InsightsResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/insights [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetInsights(c *gin.Context) {
+	var stored models.TranscriptInsights
+	if err := database.DB.Where("transcription_id = ?", c.Param("id")).First(&stored).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "No insights for this transcription"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get insights"})
+		return
+	}
+
+	insights, err := postprocess.Decode(&stored)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse insights"})
+		return
+	}
+	c.JSON(http.StatusOK, InsightsResponse{TranscriptInsights: stored, ActionItems: insights.ActionItems, Chapters: insights.Chapters})
+}
+
+// RequestInsights draws the insights of a transcript again
+// @Summary Post-process a transcript
+// @Description Draw a summary, action items and chapter markers from a completed transcript in the background with the configured LLM endpoint, replacing earlier ones
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} models.TranscriptInsights
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Failure 501 {object} map[string]string
+// @Router /api/v1/transcription/{id}/insights [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) RequestInsights(c *gin.Context) {
+	insights, err := h.postprocessor.Start(c.Param("id"))
+	if err != nil {
+		switch {
+		case errors.Is(err, postprocess.ErrDisabled):
+			c.JSON(http.StatusNotImplemented, gin.H{"error": "Post-processing is not enabled"})
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		case errors.Is(err, postprocess.ErrRunning):
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		case errors.Is(err, postprocess.ErrNotTranscribed), errors.Is(err, postprocess.ErrEncrypted):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start post-processing"})
+		}
+		return
+	}
+	c.JSON(http.StatusAccepted, insights)
+}
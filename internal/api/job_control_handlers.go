@@ -0,0 +1,132 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// controlledJob loads the job a cancel, pause or resume request names,
+// answering the request itself when it cannot
+func controlledJob(c *gin.Context) (*models.TranscriptionJob, bool) {
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return nil, false
+	}
+	return &job, true
+}
+
+// CancelJob stops a job for good
+// @Summary Cancel a transcription job
+// @Description Cancel a queued, running or paused job. A running job's model processes are killed and it fails once they have stopped, freeing its worker; a queued or paused job fails straight away.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} map[string]string
+// @Success 202 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/transcription/{id}/cancel [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) CancelJob(c *gin.Context) {
+	job, ok := controlledJob(c)
+	if !ok {
+		return
+	}
+	running := h.taskQueue.IsJobRunning(job.ID)
+	if !running && job.Status != models.StatusPending && job.Status != models.StatusPaused {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only queued, running or paused jobs can be cancelled", "status": job.Status})
+		return
+	}
+	if err := h.taskQueue.CancelJob(job.ID); err != nil {
+		if errors.Is(err, jobstate.ErrInvalidTransition) || errors.Is(err, jobstate.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Job cannot be cancelled in its current state"})
+			return
+		}
+		logger.Error("Failed to cancel job", "job_id", job.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job"})
+		return
+	}
+	if running {
+		c.JSON(http.StatusAccepted, gin.H{"message": "Job cancellation requested"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Job cancelled"})
+}
+
+// PauseJob stops a running job so it can be resumed later
+// @Summary Pause a transcription job
+// @Description Stop a running job, killing its model processes and freeing its worker. The segments transcribed so far are kept when the job is a single recording transcribed without diarization, chunking or encryption, and resuming carries on after them; other jobs start over when resumed.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/transcription/{id}/pause [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) PauseJob(c *gin.Context) {
+	job, ok := controlledJob(c)
+	if !ok {
+		return
+	}
+	if err := h.taskQueue.PauseJob(job.ID); err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is not currently running"})
+		return
+	}
+	c.JSON(http.StatusAccepted, gin.H{"message": "Job pause requested"})
+}
+
+// ResumeJob queues a paused job again
+// @Summary Resume a paused transcription job
+// @Description Queue a paused job again with the parameters it was started with. It carries on from its checkpoint when it has one.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.TranscriptionJob
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/resume [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) ResumeJob(c *gin.Context) {
+	job, ok := controlledJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != models.StatusPaused {
+		c.JSON(http.StatusConflict, gin.H{"error": "Job is not paused", "status": job.Status})
+		return
+	}
+	if _, err := jobstate.Transition(job.ID, models.StatusPending); err != nil {
+		if errors.Is(err, jobstate.ErrInvalidTransition) || errors.Is(err, jobstate.ErrConflict) {
+			c.JSON(http.StatusConflict, gin.H{"error": "Job cannot be resumed in its current state"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update job"})
+		return
+	}
+	job.Status = models.StatusPending
+
+	if err := h.taskQueue.EnqueueJob(job.ID); err != nil {
+		logger.Error("Failed to enqueue job", "job_id", job.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to enqueue job"})
+		return
+	}
+	c.JSON(http.StatusOK, job)
+}
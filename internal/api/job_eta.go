@@ -0,0 +1,44 @@
+package api
+
+import (
+	"synthezia/internal/eta"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+)
+
+// JobWithETA is a job with when it is expected to complete, while it is
+// queued or running
+type JobWithETA struct {
+	models.TranscriptionJob
+	ETA *eta.Estimate `json:"eta,omitempty"`
+}
+
+// withETAs pairs jobs with their estimates. Estimating is best effort: when
+// it fails, the jobs are returned without one.
+func (h *Handler) withETAs(jobs []models.TranscriptionJob) []JobWithETA {
+	result := make([]JobWithETA, len(jobs))
+	var active []string
+	for i := range jobs {
+		result[i].TranscriptionJob = jobs[i]
+		if jobs[i].Status == models.StatusPending || jobs[i].Status == models.StatusProcessing {
+			active = append(active, jobs[i].ID)
+		}
+	}
+	if len(active) == 0 {
+		return result
+	}
+
+	workers := 1
+	if h.taskQueue != nil {
+		workers = h.taskQueue.CurrentWorkers()
+	}
+	estimates, err := eta.Default.Estimate(active, workers)
+	if err != nil {
+		logger.Warn("Failed to estimate job completion", "error", err)
+		return result
+	}
+	for i := range result {
+		result[i].ETA = estimates[result[i].ID]
+	}
+	return result
+}
@@ -0,0 +1,57 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+)
+
+// JobEventsResponse is a job's recorded lifecycle
+type JobEventsResponse struct {
+	JobID    string            `json:"job_id"`
+	Recorded bool              `json:"recorded"` // False when JOB_EVENTS_DB is off
+	Events   []models.JobEvent `json:"events"`
+}
+
+// GetJobEvents returns the timeline of a job's state transitions
+// @Summary Get job lifecycle events
+// @Description Get the stored state transitions of a job (uploaded, queued, transcribing, completed, failed), oldest first. Events are only stored when JOB_EVENTS_DB is enabled.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} JobEventsResponse
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/events [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetJobEvents(c *gin.Context) {
+	jobID := c.Param("id")
+
+	var job models.TranscriptionJob
+	if err := database.DB.Select("id").Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+
+	events, err := jobstate.Timeline(jobID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job events"})
+		return
+	}
+	if events == nil {
+		events = []models.JobEvent{}
+	}
+
+	c.JSON(http.StatusOK, JobEventsResponse{JobID: jobID, Recorded: h.config.JobEventsDB, Events: events})
+}
@@ -0,0 +1,225 @@
+package api
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// jobSortKey is one column of a job listing's order
+type jobSortKey struct {
+	column   string
+	desc     bool
+	nullable bool // NULLs sort last whatever the direction
+}
+
+// jobSortKeys returns the order of a job listing sorted by field, ending in
+// columns that make it total so cursors can resume it exactly
+func jobSortKeys(field string, desc bool) ([]jobSortKey, bool) {
+	var first jobSortKey
+	switch field {
+	case "created_at":
+		return []jobSortKey{{column: "created_at", desc: desc}, {column: "id", desc: desc}}, true
+	case "recorded_at":
+		first = jobSortKey{column: "recorded_at", desc: desc, nullable: true}
+	case "duration":
+		first = jobSortKey{column: "audio_duration", desc: desc, nullable: true}
+	case "status":
+		first = jobSortKey{column: "status", desc: desc}
+	default:
+		return nil, false
+	}
+	// Ties list the newest uploads first
+	return []jobSortKey{first, {column: "created_at", desc: true}, {column: "id", desc: true}}, true
+}
+
+// jobOrder is the ORDER BY clause of sort keys
+func jobOrder(keys []jobSortKey) string {
+	parts := make([]string, 0, len(keys)+1)
+	for _, key := range keys {
+		if key.nullable {
+			parts = append(parts, key.column+" IS NULL")
+		}
+		direction := " ASC"
+		if key.desc {
+			direction = " DESC"
+		}
+		parts = append(parts, key.column+direction)
+	}
+	return strings.Join(parts, ", ")
+}
+
+// jobCursor marks where a page of a job listing ended: the sort it belongs
+// to and the sort key values of its last job
+type jobCursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+}
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+// encodeJobCursor makes the cursor that resumes a listing after job
+func encodeJobCursor(sort string, keys []jobSortKey, job *models.TranscriptionJob) string {
+	values := make([]interface{}, len(keys))
+	for i, key := range keys {
+		switch key.column {
+		case "created_at":
+			values[i] = job.CreatedAt.Format(time.RFC3339Nano)
+		case "recorded_at":
+			if job.RecordedAt != nil {
+				values[i] = job.RecordedAt.Format(time.RFC3339Nano)
+			}
+		case "audio_duration":
+			if job.AudioDuration != nil {
+				values[i] = *job.AudioDuration
+			}
+		case "status":
+			values[i] = string(job.Status)
+		case "id":
+			values[i] = job.ID
+		}
+	}
+	data, _ := json.Marshal(jobCursor{Sort: sort, Values: values})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+// decodeJobCursor reads a cursor made for the same sort, turning times back
+// into times so they compare as stored
+func decodeJobCursor(value, sort string, keys []jobSortKey) ([]interface{}, error) {
+	data, err := base64.RawURLEncoding.DecodeString(value)
+	if err != nil {
+		return nil, errInvalidCursor
+	}
+	var cursor jobCursor
+	if err := json.Unmarshal(data, &cursor); err != nil || len(cursor.Values) != len(keys) {
+		return nil, errInvalidCursor
+	}
+	if cursor.Sort != sort {
+		return nil, fmt.Errorf("the cursor belongs to a listing sorted by %s", cursor.Sort)
+	}
+	for i, key := range keys {
+		v := cursor.Values[i]
+		if v == nil {
+			if !key.nullable {
+				return nil, errInvalidCursor
+			}
+			continue
+		}
+		switch key.column {
+		case "created_at", "recorded_at":
+			s, ok := v.(string)
+			t, err := time.Parse(time.RFC3339Nano, s)
+			if !ok || err != nil {
+				return nil, errInvalidCursor
+			}
+			cursor.Values[i] = t
+		case "audio_duration":
+			if _, ok := v.(float64); !ok {
+				return nil, errInvalidCursor
+			}
+		default:
+			if _, ok := v.(string); !ok {
+				return nil, errInvalidCursor
+			}
+		}
+	}
+	return cursor.Values, nil
+}
+
+// afterJobCursor limits a query to the jobs that sort after the cursor
+// values: those that tie on the first keys and sort after it on the next
+func afterJobCursor(query *gorm.DB, keys []jobSortKey, values []interface{}) *gorm.DB {
+	var clauses []string
+	var args []interface{}
+	for i, key := range keys {
+		var equal []string
+		var equalArgs []interface{}
+		for j := 0; j < i; j++ {
+			if values[j] == nil {
+				equal = append(equal, keys[j].column+" IS NULL")
+			} else {
+				equal = append(equal, keys[j].column+" = ?")
+				equalArgs = append(equalArgs, values[j])
+			}
+		}
+		// Nothing sorts after NULL, which is last
+		if values[i] == nil {
+			continue
+		}
+		operator := " > ?"
+		if key.desc {
+			operator = " < ?"
+		}
+		after := key.column + operator
+		if key.nullable {
+			after = "(" + key.column + " IS NULL OR " + after + ")"
+		}
+		clauses = append(clauses, "("+strings.Join(append(equal, after), " AND ")+")")
+		args = append(append(args, equalArgs...), values[i])
+	}
+	if len(clauses) == 0 {
+		return query.Where("1 = 0")
+	}
+	return query.Where(strings.Join(clauses, " OR "), args...)
+}
+
+// applyJobFilters filters a job query by status, created_from/created_to,
+// is_multi_track and language, responding when a filter is invalid
+func applyJobFilters(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	if status := c.Query("status"); status != "" {
+		statuses := []string{}
+		for _, s := range strings.Split(status, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				statuses = append(statuses, s)
+			}
+		}
+		query = query.Where("status IN ?", statuses)
+	}
+
+	if from := c.Query("created_from"); from != "" {
+		t, ok := parseArchiveTime(from, time.UTC)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid created_from, use RFC 3339 or YYYY-MM-DD"})
+			return nil, false
+		}
+		query = query.Where("created_at >= ?", t)
+	}
+	if to := c.Query("created_to"); to != "" {
+		t, ok := parseArchiveTime(to, time.UTC)
+		if !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid created_to, use RFC 3339 or YYYY-MM-DD"})
+			return nil, false
+		}
+		if len(to) == len("2006-01-02") {
+			t = t.AddDate(0, 0, 1)
+		}
+		query = query.Where("created_at < ?", t)
+	}
+
+	if value := c.Query("is_multi_track"); value != "" {
+		multiTrack, err := strconv.ParseBool(value)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "is_multi_track must be true or false"})
+			return nil, false
+		}
+		query = query.Where("is_multi_track = ?", multiTrack)
+	}
+
+	// The language asked for, or the one detected when none was
+	if language := strings.TrimSpace(c.Query("language")); language != "" {
+		query = query.Where(`language = ? COLLATE NOCASE OR (
+			(language IS NULL OR language = '') AND json_valid(transcript) AND json_extract(transcript, '$.language') = ? COLLATE NOCASE)`,
+			language, language)
+	}
+	return query, true
+}
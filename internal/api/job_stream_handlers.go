@@ -0,0 +1,153 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/internal/processing"
+	"synthezia/internal/transcription"
+	"synthezia/internal/transcription/interfaces"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// jobEventsPollInterval is how often a job event stream re-reads the job, to
+// end when it finishes without the stream hearing of it, and keeps the
+// connection alive through proxies
+const jobEventsPollInterval = 15 * time.Second
+
+// TranscriptionProgressEvent is how far the transcription of a job has got
+type TranscriptionProgressEvent struct {
+	Percent float64 `json:"percent"`
+}
+
+// jobFinished reports whether a job status ends its event stream
+func jobFinished(status models.JobStatus) bool {
+	return status == models.StatusCompleted || status == models.StatusFailed
+}
+
+// StreamJobEvents pushes what happens to a job as server-sent events
+// @Summary Stream job progress
+// @Description Stream server-sent events for a job: "status" on each status change, "merge" with multi-track merge progress, "progress" with the percentage of the recording transcribed and "segment" with each segment as it is transcribed. The stream opens with the job's current state, including the segments so far, and ends once the job completes or fails. Browsers authenticate with a ticket.
+// @Tags transcription
+// @Produce text/event-stream
+// @Param id path string true "Job ID"
+// @Success 200 {string} string "Event stream"
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/job/{id}/events [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) StreamJobEvents(c *gin.Context) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming not supported"})
+		return
+	}
+	jobID := c.Param("id")
+
+	// Subscribe before reading the job so nothing falls in between. Status
+	// listeners must not block; a dropped event is caught up on by polling.
+	statuses := make(chan jobstate.Event, 16)
+	stopStatuses := jobstate.Subscribe(func(event jobstate.Event) {
+		if event.JobID != jobID {
+			return
+		}
+		select {
+		case statuses <- event:
+		default:
+		}
+	})
+	defer stopStatuses()
+	merges, stopMerges := processing.MergeProgresses.Subscribe(jobID)
+	defer stopMerges()
+	progresses, stopProgresses := transcription.Progresses.Subscribe(jobID)
+	defer stopProgresses()
+
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", jobID).First(&job).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	send := func(event string, data interface{}) {
+		c.SSEvent(event, data)
+		flusher.Flush()
+	}
+
+	current := jobstate.Event{JobID: job.ID, To: job.Status, At: job.UpdatedAt}
+	if job.ErrorMessage != nil {
+		current.Error = *job.ErrorMessage
+	}
+	send("status", current)
+	if jobFinished(job.Status) {
+		return
+	}
+	if progress, ok := processing.MergeProgresses.Get(jobID); ok {
+		send("merge", mergeStatusUpdate(progress))
+	}
+	if snapshot, ok := transcription.Progresses.Get(jobID); ok {
+		for i := range snapshot.Segments {
+			send("segment", snapshot.Segments[i])
+		}
+		send("progress", TranscriptionProgressEvent{Percent: snapshot.Percent})
+	}
+
+	ticker := time.NewTicker(jobEventsPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case event := <-statuses:
+			current = event
+			send("status", event)
+			if jobFinished(event.To) {
+				return
+			}
+		case progress := <-merges:
+			send("merge", mergeStatusUpdate(progress))
+		case progress := <-progresses:
+			sendTranscriptionProgress(send, progress)
+		case <-ticker.C:
+			var latest models.TranscriptionJob
+			if err := database.DB.Where("id = ?", jobID).First(&latest).Error; err != nil {
+				return
+			}
+			if latest.Status != current.To {
+				current = jobstate.Event{JobID: jobID, From: current.To, To: latest.Status, At: latest.UpdatedAt}
+				if latest.ErrorMessage != nil {
+					current.Error = *latest.ErrorMessage
+				}
+				send("status", current)
+				if jobFinished(latest.Status) {
+					return
+				}
+				continue
+			}
+			c.Writer.WriteString(": keepalive\n\n")
+			flusher.Flush()
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// sendTranscriptionProgress sends a transcription update as its segment, if
+// it brought one, and the percentage reached
+func sendTranscriptionProgress(send func(string, interface{}), progress interfaces.TranscriptionProgress) {
+	if progress.Segment != nil {
+		send("segment", *progress.Segment)
+	}
+	send("progress", TranscriptionProgressEvent{Percent: progress.Percent})
+}
@@ -0,0 +1,48 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"synthezia/internal/jobversion"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// expectedVersion reads the job version an edit was based on from If-Match,
+// responding 400 when it is malformed. Nil means the edit applies to
+// whatever version the job is at.
+func expectedVersion(c *gin.Context) (*int, bool) {
+	version, err := jobversion.ParseETag(c.GetHeader("If-Match"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return nil, false
+	}
+	return version, true
+}
+
+// setJobVersion tells the client the job's version, to send back in If-Match
+func setJobVersion(c *gin.Context, version int) {
+	c.Header("ETag", jobversion.ETag(version))
+}
+
+// writeVersionError responds to a failed versioned update: 404 for a job
+// that is gone, 409 with the current version for a stale edit. It reports
+// false for other errors, which the caller responds to.
+func writeVersionError(c *gin.Context, err error) bool {
+	var conflict *jobversion.Conflict
+	switch {
+	case errors.As(err, &conflict):
+		setJobVersion(c, conflict.Current)
+		c.JSON(http.StatusConflict, gin.H{
+			"error":   "The job was changed by someone else; reload it and apply your edit again",
+			"version": conflict.Current,
+		})
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	default:
+		return false
+	}
+	return true
+}
@@ -7,6 +7,7 @@ import (
 	"time"
 
 	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
 	"synthezia/internal/models"
 	"synthezia/internal/transcription"
 	"synthezia/pkg/logger"
@@ -209,6 +210,7 @@ func (h *Handler) FinalizeLiveSession(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create final job"})
 			return
 		}
+		jobstate.Created(c.Request.Context(), job.ID, job.Status, "source", "live")
 
 		// Create execution record for consistency
 		now := time.Now()
@@ -229,6 +231,7 @@ func (h *Handler) FinalizeLiveSession(c *gin.Context) {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create final job"})
 			return
 		}
+		jobstate.Created(c.Request.Context(), job.ID, job.Status, "source", "live")
 
 		if err := h.taskQueue.EnqueueJob(jobID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to enqueue job"})
@@ -0,0 +1,78 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"synthezia/pkg/logger"
+)
+
+// RecentLogsResponse holds log entries from the in-memory buffer
+type RecentLogsResponse struct {
+	Entries  []logger.Entry `json:"entries"`
+	Capacity int            `json:"capacity"`
+}
+
+// GetRecentLogs returns the latest log entries kept in memory
+// @Summary Recent log entries
+// @Description Return recent log entries from the in-memory buffer, oldest first, for debugging without shell access
+// @Tags admin
+// @Produce json
+// @Param level query string false "Minimum level: debug, info, warn or error" default(debug)
+// @Param since query string false "RFC 3339 timestamp or duration such as 15m"
+// @Param module query string false "Only entries from this module (http, queue, dropzone)"
+// @Param limit query int false "Return at most this many of the newest entries"
+// @Success 200 {object} RecentLogsResponse
+// @Failure 400 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/logs [get]
+func (h *Handler) GetRecentLogs(c *gin.Context) {
+	filter := logger.EntryFilter{Module: c.Query("module")}
+
+	if level := c.Query("level"); level != "" {
+		switch strings.ToLower(level) {
+		case "debug":
+			filter.MinLevel = logger.LevelDebug
+		case "info":
+			filter.MinLevel = logger.LevelInfo
+		case "warn", "warning":
+			filter.MinLevel = logger.LevelWarn
+		case "error":
+			filter.MinLevel = logger.LevelError
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "level must be debug, info, warn or error"})
+			return
+		}
+	}
+
+	if since := c.Query("since"); since != "" {
+		if t, err := time.Parse(time.RFC3339, since); err == nil {
+			filter.Since = t
+		} else if d, err := time.ParseDuration(since); err == nil && d > 0 {
+			filter.Since = time.Now().Add(-d)
+		} else {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "since must be an RFC 3339 timestamp or a duration such as 15m"})
+			return
+		}
+	}
+
+	if limit := c.Query("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a non-negative integer"})
+			return
+		}
+		filter.Limit = n
+	}
+
+	buffer := logger.Recent()
+	c.JSON(http.StatusOK, RecentLogsResponse{
+		Entries:  buffer.Entries(filter),
+		Capacity: buffer.Capacity(),
+	})
+}
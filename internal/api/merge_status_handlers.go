@@ -0,0 +1,268 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/processing"
+	"synthezia/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// mergeStatusPollInterval is how often a merge status stream re-reads the
+// job, to end when a merge fails before it reports any progress
+const mergeStatusPollInterval = 5 * time.Second
+
+// MergeStatusUpdate is one line of a merge status stream
+type MergeStatusUpdate struct {
+	MergeStatus      string  `json:"merge_status"`
+	MergeError       string  `json:"merge_error,omitempty"`
+	Stage            string  `json:"merge_stage,omitempty"`
+	Progress         float64 `json:"merge_progress"`
+	ProcessedSeconds float64 `json:"processed_seconds,omitempty"`
+	TotalSeconds     float64 `json:"total_seconds,omitempty"`
+}
+
+// mergeStatusUpdate describes a merge from its progress
+func mergeStatusUpdate(progress audio.MergeProgress) MergeStatusUpdate {
+	status := "processing"
+	switch progress.Stage {
+	case "completed", "failed", "cancelled":
+		status = progress.Stage
+	case "queued":
+		status = "merge_queued"
+	}
+	return MergeStatusUpdate{
+		MergeStatus:      status,
+		MergeError:       progress.ErrorMsg,
+		Stage:            progress.Stage,
+		Progress:         progress.Progress,
+		ProcessedSeconds: progress.ProcessedSeconds,
+		TotalSeconds:     progress.TotalSeconds,
+	}
+}
+
+// mergeOngoing reports whether a merge status is that of a merge yet to end
+func mergeOngoing(status string) bool {
+	return status == "pending" || status == "merge_queued" || status == "processing"
+}
+
+// StreamMergeStatus streams the progress of a multi-track job's merge
+// @Summary Stream multi-track merge progress
+// @Description Stream JSON lines with the merge status and percentage of a multi-track job as ffmpeg reports them, ending once the merge completes or fails. Browsers authenticate with a ticket.
+// @Tags transcription
+// @Produce plain
+// @Param id path string true "Job ID"
+// @Success 200 {object} MergeStatusUpdate
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/transcription/{id}/merge-status/stream [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) StreamMergeStatus(c *gin.Context) {
+	flusher, ok := c.Writer.(http.Flusher)
+	if !ok {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Streaming not supported"})
+		return
+	}
+
+	jobID := c.Param("id")
+	// Subscribe before reading the status so no update falls in between
+	updates, unsubscribe := processing.MergeProgresses.Subscribe(jobID)
+	defer unsubscribe()
+
+	status, errorMsg, err := h.multiTrackProcessor.GetMergeStatus(jobID)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return
+	}
+
+	c.Header("Content-Type", "text/plain")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+
+	writeUpdate := func(update MergeStatusUpdate) bool {
+		data, err := json.Marshal(update)
+		if err != nil {
+			return false
+		}
+		if _, err := c.Writer.Write(append(data, '\n')); err != nil {
+			return false
+		}
+		flusher.Flush()
+		return true
+	}
+	storedUpdate := func(status string, errorMsg *string) MergeStatusUpdate {
+		update := MergeStatusUpdate{MergeStatus: status}
+		if errorMsg != nil {
+			update.MergeError = *errorMsg
+		}
+		if status == "completed" {
+			update.Progress = 100
+		}
+		return update
+	}
+
+	if progress, ok := processing.MergeProgresses.Get(jobID); ok {
+		if !writeUpdate(mergeStatusUpdate(progress)) {
+			return
+		}
+	} else {
+		if !writeUpdate(storedUpdate(status, errorMsg)) {
+			return
+		}
+		if !mergeOngoing(status) {
+			return
+		}
+	}
+
+	ticker := time.NewTicker(mergeStatusPollInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case progress := <-updates:
+			update := mergeStatusUpdate(progress)
+			if !writeUpdate(update) || !mergeOngoing(update.MergeStatus) {
+				return
+			}
+		case <-ticker.C:
+			status, errorMsg, err := h.multiTrackProcessor.GetMergeStatus(jobID)
+			if err != nil {
+				return
+			}
+			if !mergeOngoing(status) {
+				writeUpdate(storedUpdate(status, errorMsg))
+				return
+			}
+		case <-c.Request.Context().Done():
+			return
+		}
+	}
+}
+
+// RetryMerge merges a multi-track job whose merge failed or was cancelled again
+// @Summary Retry a failed multi-track merge
+// @Description Start the merge of a multi-track job again after it failed or was cancelled. Tracks a failed run finished rendering are reused, unless their clips or files changed since.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 202 {object} MergeStatusUpdate
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/transcription/{id}/merge/retry [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) RetryMerge(c *gin.Context) {
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+	if !job.IsMultiTrack || job.AupFilePath == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Job is not a multi-track project"})
+		return
+	}
+	if job.MergeStatus != "failed" && job.MergeStatus != "cancelled" {
+		c.JSON(http.StatusConflict, gin.H{"error": "Only failed or cancelled merges can be retried", "merge_status": job.MergeStatus})
+		return
+	}
+
+	if err := database.DB.Model(&job).Updates(map[string]interface{}{"merge_status": "pending", "merge_error": nil}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update merge status"})
+		return
+	}
+	go func() {
+		if err := h.multiTrackProcessor.ProcessMultiTrackJob(context.Background(), job.ID); err != nil {
+			logger.Error("Multi-track merge retry failed", "job_id", job.ID, "error", err)
+		}
+	}()
+
+	c.JSON(http.StatusAccepted, MergeStatusUpdate{MergeStatus: "pending"})
+}
+
+// CancelMerge cancels the merge of a multi-track job
+// @Summary Cancel a multi-track merge
+// @Description Cancel a queued or running merge of a multi-track job, killing its ffmpeg process and removing its intermediate files. The merge is marked cancelled and can be retried.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} MergeStatusUpdate
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/transcription/{id}/merge/cancel [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) CancelMerge(c *gin.Context) {
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+	if !job.IsMultiTrack || job.AupFilePath == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Job is not a multi-track project"})
+		return
+	}
+	if !mergeOngoing(job.MergeStatus) {
+		c.JSON(http.StatusConflict, gin.H{"error": "No merge in progress to cancel", "merge_status": job.MergeStatus})
+		return
+	}
+
+	if err := h.multiTrackProcessor.CancelMerge(job.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel merge"})
+		return
+	}
+	c.JSON(http.StatusOK, MergeStatusUpdate{MergeStatus: "cancelled"})
+}
+
+// ValidateMerge checks whether a multi-track job can be merged, without merging it
+// @Summary Dry-run a multi-track merge
+// @Description Parse the job's project, check the track files it mixes are present and readable, and estimate the length of the mix and the disk the merge needs, without starting it. Errors stop the merge; warnings, such as files the project uses that were not uploaded or tracks at different sample rates, do not.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} processing.MergeValidation
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/transcription/{id}/merge/validate [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) ValidateMerge(c *gin.Context) {
+	var job models.TranscriptionJob
+	if err := database.DB.Preload("MultiTrackFiles", func(db *gorm.DB) *gorm.DB {
+		return db.Order("track_index")
+	}).Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+	if !job.IsMultiTrack || job.AupFilePath == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Job is not a multi-track project"})
+		return
+	}
+
+	validation, err := processing.ValidateMerge(c.Request.Context(), h.ffprobePath, &job)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate merge"})
+		return
+	}
+	c.JSON(http.StatusOK, validation)
+}
@@ -0,0 +1,22 @@
+package api
+
+import (
+	"net/http"
+
+	"synthezia/pkg/metrics"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Metrics exposes pipeline and SLO metrics for Prometheus
+// @Summary Prometheus metrics
+// @Description Pipeline counters, histograms and rolling-window SLO series (job success ratio, p95 queue wait, p95 processing ratio) in Prometheus text format
+// @Tags health
+// @Produce plain
+// @Success 200 {string} string
+// @Router /metrics [get]
+func (h *Handler) Metrics(c *gin.Context) {
+	c.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	c.Status(http.StatusOK)
+	metrics.WritePrometheus(c.Writer)
+}
@@ -0,0 +1,110 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"synthezia/internal/transcription"
+	"synthezia/internal/whispermodels"
+	"synthezia/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ModelListResponse lists the Whisper models and where they are cached
+type ModelListResponse struct {
+	CacheDir string                `json:"cache_dir"`
+	Models   []whispermodels.Model `json:"models"`
+}
+
+// SetWhisperModels overrides the Whisper model manager, mainly for tests
+func (h *Handler) SetWhisperModels(manager *whispermodels.Manager) {
+	h.whisperModels = manager
+}
+
+// WarmUpDefaultModel downloads and loads the default Whisper model in the
+// background, so the first job does not wait for it. The fake and remote
+// backends load no models here and are skipped.
+func (h *Handler) WarmUpDefaultModel() {
+	if h.config.TranscriptionBackend == transcription.BackendFake || h.config.TranscriptionBackend == transcription.BackendRemote {
+		return
+	}
+	go func() {
+		if err := h.whisperModels.WarmUp(context.Background()); err != nil {
+			logger.Warn("Failed to warm up the default Whisper model", "model", h.jobParams.Model, "error", err)
+		}
+	}()
+}
+
+// ListModels returns every Whisper model and whether it is downloaded
+// @Summary List Whisper models
+// @Description List the Whisper models jobs can use, smallest first, with whether each is downloaded, being downloaded, the default or allowed for jobs, and how much disk it takes
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ModelListResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/models [get]
+func (h *Handler) ListModels(c *gin.Context) {
+	c.JSON(http.StatusOK, ModelListResponse{
+		CacheDir: h.whisperModels.CacheDir(),
+		Models:   h.whisperModels.List(),
+	})
+}
+
+// DownloadModel fetches a Whisper model ahead of the jobs that need it
+// @Summary Download a Whisper model
+// @Description Start downloading a Whisper model into the model cache in the background. List the models to follow the download.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Model name, e.g. large-v3"
+// @Success 200 {object} whispermodels.Model
+// @Success 202 {object} whispermodels.Model
+// @Failure 404 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/models/{name}/download [post]
+func (h *Handler) DownloadModel(c *gin.Context) {
+	started, err := h.whisperModels.Download(c.Param("name"))
+	if errors.Is(err, whispermodels.ErrUnknownModel) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+		return
+	}
+	model, _ := h.whisperModels.Get(c.Param("name"))
+	if started || model.Downloading {
+		c.JSON(http.StatusAccepted, model)
+		return
+	}
+	c.JSON(http.StatusOK, model)
+}
+
+// DeleteModel frees the disk a Whisper model takes
+// @Summary Delete a Whisper model
+// @Description Delete a downloaded Whisper model from the model cache. The default model and models picked by unfinished jobs cannot be deleted.
+// @Tags admin
+// @Produce json
+// @Param name path string true "Model name"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/models/{name} [delete]
+func (h *Handler) DeleteModel(c *gin.Context) {
+	err := h.whisperModels.Delete(c.Param("name"))
+	switch {
+	case err == nil:
+		c.JSON(http.StatusOK, gin.H{"message": "Model deleted"})
+	case errors.Is(err, whispermodels.ErrUnknownModel):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model not found"})
+	case errors.Is(err, whispermodels.ErrNotDownloaded):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Model is not downloaded"})
+	case errors.Is(err, whispermodels.ErrInUse), errors.Is(err, whispermodels.ErrDownloading):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	default:
+		logger.Error("Failed to delete Whisper model", "model", c.Param("name"), "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete model"})
+	}
+}
@@ -10,6 +10,7 @@ import (
 	"gorm.io/gorm"
 
 	"synthezia/internal/database"
+	"synthezia/internal/jobversion"
 	"synthezia/internal/models"
 )
 
@@ -179,9 +180,11 @@ func (h *Handler) GetNote(c *gin.Context) {
 // @Produce json
 // @Param note_id path string true "Note ID"
 // @Param request body NoteUpdateRequest true "Note update payload"
+// @Param If-Match header string false "Job version the edit is based on"
 // @Success 200 {object} models.Note
 // @Failure 400 {object} map[string]string
 // @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
 // @Security ApiKeyAuth
 // @Security BearerAuth
 // @Router /api/v1/notes/{note_id} [put]
@@ -192,6 +195,10 @@ func (h *Handler) UpdateNote(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
 
 	var n models.Note
 	if err := database.DB.Where("id = ?", noteID).First(&n).Error; err != nil {
@@ -206,11 +213,25 @@ func (h *Handler) UpdateNote(c *gin.Context) {
 	n.Content = req.Content
 	n.UpdatedAt = time.Now()
 
-	if err := database.DB.Save(&n).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+	// Notes are versioned with their job, so an edit based on a stale copy
+	// of the job is refused instead of overwriting a newer one
+	var version int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(&n).Error; err != nil {
+			return err
+		}
+		var err error
+		version, err = jobversion.Bump(tx, n.TranscriptionID, expected)
+		return err
+	})
+	if err != nil {
+		if !writeVersionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update note"})
+		}
 		return
 	}
 
+	setJobVersion(c, version)
 	c.JSON(http.StatusOK, n)
 }
 
@@ -220,16 +241,42 @@ func (h *Handler) UpdateNote(c *gin.Context) {
 // @Tags notes
 // @Produce json
 // @Param note_id path string true "Note ID"
+// @Param If-Match header string false "Job version the edit is based on"
 // @Success 204 {string} string "No Content"
+// @Failure 409 {object} map[string]interface{}
 // @Failure 500 {object} map[string]string
 // @Security ApiKeyAuth
 // @Router /api/v1/notes/{note_id} [delete]
 func (h *Handler) DeleteNote(c *gin.Context) {
 	noteID := c.Param("note_id")
-	if err := database.DB.Delete(&models.Note{}, "id = ?", noteID).Error; err != nil {
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+	var n models.Note
+	if err := database.DB.Where("id = ?", noteID).First(&n).Error; err != nil && err != gorm.ErrRecordNotFound {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
 		return
 	}
+	// A note already gone is not an edit of its job
+	if n.ID != "" {
+		var version int
+		err := database.DB.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Delete(&models.Note{}, "id = ?", noteID).Error; err != nil {
+				return err
+			}
+			var err error
+			version, err = jobversion.Bump(tx, n.TranscriptionID, expected)
+			return err
+		})
+		if err != nil {
+			if !writeVersionError(c, err) {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete note"})
+			}
+			return
+		}
+		setJobVersion(c, version)
+	}
 	// Tests expect 200 on deletion
 	c.JSON(http.StatusOK, gin.H{"message": "Note deleted"})
 }
@@ -0,0 +1,160 @@
+package api
+
+import (
+	"io"
+	"net/http"
+	"path/filepath"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// DownloadURLResponse is a link that downloads a job's file straight from
+// the storage backend
+type DownloadURLResponse struct {
+	URL       string    `json:"url"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Size      int64     `json:"size"`
+}
+
+// audioContentType is the Content-Type to serve audio with, by extension
+func audioContentType(path string) string {
+	switch filepath.Ext(path) {
+	case ".mp3":
+		return "audio/mpeg"
+	case ".wav":
+		return "audio/wav"
+	case ".m4a":
+		return "audio/mp4"
+	case ".ogg":
+		return "audio/ogg"
+	case ".opus":
+		return "audio/ogg; codecs=opus"
+	case ".flac":
+		return "audio/flac"
+	case ".aac":
+		return "audio/aac"
+	case ".webm":
+		return "audio/webm"
+	default:
+		return "audio/mpeg"
+	}
+}
+
+// storedAudio returns the stored object of a job's audio, preferring the
+// mixdown of multi-track jobs, or nil when it is not stored
+func (h *Handler) storedAudio(job *models.TranscriptionJob) (*models.StoredObject, error) {
+	if job.IsMultiTrack && job.MergedAudioPath != nil && *job.MergedAudioPath != "" {
+		object, err := h.storage.Find(job.ID, *job.MergedAudioPath)
+		if err != nil || object != nil {
+			return object, err
+		}
+	}
+	return h.storage.Find(job.ID, job.AudioPath)
+}
+
+// serveStoredAudio redirects to a link to stored audio, saved as filename
+// when one is given, or streams it when the backend cannot make links
+func (h *Handler) serveStoredAudio(c *gin.Context, object *models.StoredObject, filename string) {
+	c.Header("X-Audio-Source", "original")
+	url, err := h.storage.URL(object, filename)
+	if err != nil {
+		logger.Warn("Failed to sign stored audio link", "job_id", object.JobID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stored audio"})
+		return
+	}
+	if url != "" {
+		c.Redirect(http.StatusTemporaryRedirect, url)
+		return
+	}
+	body, err := h.storage.Open(c.Request.Context(), object)
+	if err != nil {
+		logger.Warn("Failed to read stored audio", "job_id", object.JobID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get stored audio"})
+		return
+	}
+	defer body.Close()
+	// Files of the local backend can seek, so Range requests still work
+	if seeker, ok := body.(io.ReadSeeker); ok {
+		c.Header("Content-Type", audioContentType(object.LocalPath))
+		http.ServeContent(c.Writer, c.Request, filepath.Base(object.LocalPath), object.UpdatedAt, seeker)
+		return
+	}
+	c.DataFromReader(http.StatusOK, object.Size, audioContentType(object.LocalPath), body, nil)
+}
+
+// GetDownloadURL links to a job's file in the storage backend
+// @Summary Get a download link
+// @Description Get a presigned link that downloads a job's audio or transcript straight from the storage backend, without credentials, until it expires. Only files of completed jobs moved to a backend that can sign links have one.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param file query string false "audio (default) or transcript"
+// @Success 200 {object} DownloadURLResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /api/v1/transcription/{id}/download-url [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetDownloadURL(c *gin.Context) {
+	file := c.DefaultQuery("file", "audio")
+	if file != "audio" && file != "transcript" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file must be audio or transcript"})
+		return
+	}
+
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+	if !h.storage.Enabled() || job.OffloadedAt == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The job's files are not in a storage backend"})
+		return
+	}
+
+	var object *models.StoredObject
+	var err error
+	filename := ""
+	if file == "transcript" {
+		object, err = h.storage.Transcript(c.Request.Context(), &job)
+		filename = job.ID + ".json"
+	} else {
+		object, err = h.storedAudio(&job)
+		if object != nil {
+			filename = filepath.Base(object.LocalPath)
+		}
+	}
+	if err != nil {
+		logger.Warn("Failed to look up stored file", "job_id", job.ID, "file", file, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to look up stored file"})
+		return
+	}
+	if object == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "The job's " + file + " is not in the storage backend"})
+		return
+	}
+
+	expiry := h.storage.URLExpiry()
+	url, err := h.storage.URL(object, filename)
+	if err != nil {
+		logger.Warn("Failed to sign download link", "job_id", job.ID, "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign download link"})
+		return
+	}
+	if url == "" {
+		c.JSON(http.StatusConflict, gin.H{"error": "The storage backend cannot make download links; download through the API instead"})
+		return
+	}
+	c.JSON(http.StatusOK, DownloadURLResponse{URL: url, ExpiresAt: time.Now().Add(expiry), Size: object.Size})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription"
+	"synthezia/pkg/logger"
+)
+
+// GetQualityReport returns the audio quality report of a job
+// @Summary Get audio quality report
+// @Description Get the integrated loudness, true peak, clipping, SNR estimate and dropouts of the job's audio, with warnings about what is likely to hurt the transcript. The report is made when the job is processed, or on the first request.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} audio.QualityReport
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/quality [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetQualityReport(c *gin.Context) {
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+
+	// Kept in the clear, like waveforms, so not made for sealed content
+	if job.Encrypted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Quality reports are not available for encrypted jobs"})
+		return
+	}
+
+	if job.QualityReport != nil {
+		var report audio.QualityReport
+		if err := json.Unmarshal([]byte(*job.QualityReport), &report); err == nil {
+			c.JSON(http.StatusOK, report)
+			return
+		}
+		logger.Warn("Stored quality report is invalid, analyzing the audio again", "job_id", job.ID)
+	}
+
+	report, err := transcription.GenerateQualityReport(logger.WithJobID(c.Request.Context(), job.ID), h.ffmpegPath, &job)
+	if err != nil {
+		if errors.Is(err, transcription.ErrNoAudio) {
+			if job.SourceAudioRemovedAt != nil {
+				c.JSON(http.StatusGone, gin.H{"error": "The source audio was deleted after transcription"})
+				return
+			}
+			c.JSON(http.StatusNotFound, gin.H{"error": "Audio file not found on disk"})
+			return
+		}
+		if report == nil {
+			logger.Error("Failed to analyze audio quality", "job_id", job.ID, "error", err)
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to analyze audio quality"})
+			return
+		}
+		logger.Warn("Failed to store quality report", "job_id", job.ID, "error", err)
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,109 @@
+package api
+
+import (
+	"net/http"
+
+	"synthezia/internal/queue"
+
+	"github.com/gin-gonic/gin"
+)
+
+// QueueWorkersResponse is the worker pool's limits and size
+type QueueWorkersResponse struct {
+	queue.WorkerLimits
+	CurrentWorkers int `json:"current_workers"`
+}
+
+// UpdateQueueWorkersRequest changes the worker pool. Workers fixes the
+// number of workers; otherwise the limits given replace the current ones.
+type UpdateQueueWorkersRequest struct {
+	Workers    *int  `json:"workers,omitempty"`
+	MinWorkers *int  `json:"min_workers,omitempty"`
+	MaxWorkers *int  `json:"max_workers,omitempty"`
+	AutoScale  *bool `json:"auto_scale,omitempty"`
+}
+
+// GetQueue reports the state of the transcription queue
+// @Summary Inspect the queue
+// @Description Report how many jobs wait for a worker, broken down by the fair-share weight of the users who submitted them, how long the oldest has waited, what each worker is doing and for how long, and how many jobs the workers finished in the last 5 minutes, 15 minutes and hour
+// @Tags transcription
+// @Produce json
+// @Success 200 {object} queue.Snapshot
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/queue [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetQueue(c *gin.Context) {
+	if h.taskQueue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The task queue is not running"})
+		return
+	}
+	c.JSON(http.StatusOK, h.taskQueue.Snapshot())
+}
+
+func (h *Handler) queueWorkers() QueueWorkersResponse {
+	return QueueWorkersResponse{WorkerLimits: h.taskQueue.WorkerLimits(), CurrentWorkers: h.taskQueue.CurrentWorkers()}
+}
+
+// GetQueueWorkers reports the transcription worker pool
+// @Summary Get the worker pool
+// @Description Get the number of transcription workers and the limits auto-scaling keeps it within
+// @Tags admin
+// @Produce json
+// @Success 200 {object} QueueWorkersResponse
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/admin/queue/workers [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetQueueWorkers(c *gin.Context) {
+	if h.taskQueue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The task queue is not running"})
+		return
+	}
+	c.JSON(http.StatusOK, h.queueWorkers())
+}
+
+// UpdateQueueWorkers resizes the transcription worker pool
+// @Summary Resize the worker pool
+// @Description Set the number of transcription workers, or the limits auto-scaling keeps it within by queue depth and system load. Workers above the new count stop once their current job finishes. The change lasts until the server restarts.
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body UpdateQueueWorkersRequest true "Worker count or limits"
+// @Success 200 {object} QueueWorkersResponse
+// @Failure 400 {object} map[string]string
+// @Failure 503 {object} map[string]string
+// @Router /api/v1/admin/queue/workers [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) UpdateQueueWorkers(c *gin.Context) {
+	if h.taskQueue == nil {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"error": "The task queue is not running"})
+		return
+	}
+	var req UpdateQueueWorkersRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	limits := h.taskQueue.WorkerLimits()
+	if req.Workers != nil {
+		limits = queue.WorkerLimits{Min: *req.Workers, Max: *req.Workers}
+	} else {
+		if req.MinWorkers != nil {
+			limits.Min = *req.MinWorkers
+		}
+		if req.MaxWorkers != nil {
+			limits.Max = *req.MaxWorkers
+		}
+		if req.AutoScale != nil {
+			limits.AutoScale = *req.AutoScale
+		}
+	}
+	if err := h.taskQueue.SetWorkerLimits(limits); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, h.queueWorkers())
+}
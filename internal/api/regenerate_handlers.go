@@ -0,0 +1,126 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/regenerate"
+)
+
+// RegenerateSummariesRequest selects the transcriptions to re-summarize
+type RegenerateSummariesRequest struct {
+	// Limit the run to these transcriptions; defaults to every transcription
+	// summarized with the template
+	TranscriptionIDs []string `json:"transcription_ids,omitempty"`
+	// Also redo summaries created after the template's last change
+	IncludeCurrent bool `json:"include_current"`
+	// Model override; defaults to the template's model, then the default model
+	Model string `json:"model,omitempty"`
+}
+
+// ResumeSummaryRegenerations continues runs interrupted by a restart
+func (h *Handler) ResumeSummaryRegenerations() {
+	h.regenerator.Resume()
+}
+
+// RegenerateSummaries starts a background run re-applying a template
+// @Summary Regenerate summaries for a template
+// @Description Re-summarize transcriptions with the current version of a template as a background run. By default only summaries older than the template's last change are redone.
+// @Tags summaries
+// @Accept json
+// @Produce json
+// @Param id path string true "Template ID"
+// @Param request body RegenerateSummariesRequest false "Selection"
+// @Success 202 {object} models.SummaryRegeneration
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/summaries/{id}/regenerate [post]
+func (h *Handler) RegenerateSummaries(c *gin.Context) {
+	var req RegenerateSummariesRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	run, err := h.regenerator.Start(c.Param("id"), regenerate.Selection{
+		TranscriptionIDs: req.TranscriptionIDs,
+		OnlyStale:        !req.IncludeCurrent,
+		Model:            req.Model,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Template not found"})
+		case errors.Is(err, regenerate.ErrNothingToRegenerate), errors.Is(err, regenerate.ErrNoModel):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start regeneration"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, run)
+}
+
+// ListSummaryRegenerations lists regeneration runs, newest first
+// @Summary List summary regenerations
+// @Description List bulk summary regeneration runs with their progress
+// @Tags summaries
+// @Produce json
+// @Param template_id query string false "Only runs for this template"
+// @Success 200 {array} models.SummaryRegeneration
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/summaries/regenerations [get]
+func (h *Handler) ListSummaryRegenerations(c *gin.Context) {
+	query := database.DB.Order("created_at DESC")
+	if templateID := c.Query("template_id"); templateID != "" {
+		query = query.Where("template_id = ?", templateID)
+	}
+
+	var runs []models.SummaryRegeneration
+	if err := query.Find(&runs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list regenerations"})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+// GetSummaryRegeneration returns a run with per-transcription results
+// @Summary Get summary regeneration
+// @Description Get progress of a regeneration run, including which transcriptions failed and why
+// @Tags summaries
+// @Produce json
+// @Param run_id path string true "Regeneration ID"
+// @Success 200 {object} models.SummaryRegeneration
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/summaries/regenerations/{run_id} [get]
+func (h *Handler) GetSummaryRegeneration(c *gin.Context) {
+	var run models.SummaryRegeneration
+	err := database.DB.Preload("Items", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id ASC")
+	}).Where("id = ?", c.Param("run_id")).First(&run).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Regeneration not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch regeneration"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
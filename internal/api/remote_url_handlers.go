@@ -0,0 +1,194 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/fetch"
+	"synthezia/internal/jobcrypt"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// SubmitURLRequest submits a job whose media is fetched from a URL
+type SubmitURLRequest struct {
+	// A direct media link, including presigned S3 URLs, or a YouTube page
+	// when REMOTE_URL_YOUTUBE is set
+	URL   string  `json:"url" binding:"required"`
+	Title *string `json:"title,omitempty"`
+	// Transcription parameters; the user's default profile, or the server
+	// defaults, when omitted. Options left out take the server defaults.
+	Parameters *models.WhisperXParams `json:"parameters,omitempty"`
+	// When the job may be transcribed: an RFC 3339 time, or a cron
+	// expression whose next match is taken. The media is downloaded straight away.
+	RunAfter string `json:"run_after,omitempty"`
+	Schedule string `json:"schedule,omitempty"`
+}
+
+// SubmitURLResponse is a job waiting for its media to download
+type SubmitURLResponse struct {
+	Job      models.TranscriptionJob `json:"job"`
+	Download models.RemoteDownload   `json:"download"`
+}
+
+// ResumeRemoteDownloads restarts media downloads interrupted by a restart
+func (h *Handler) ResumeRemoteDownloads() {
+	h.downloads.Resume()
+}
+
+// Downloads returns the service fetching media of jobs submitted by URL, mainly for tests
+func (h *Handler) Downloads() *fetch.Service {
+	return h.downloads
+}
+
+// SubmitURL creates a job from the media at a URL
+// @Summary Transcribe media from a URL
+// @Description Create a job whose media is downloaded from a URL in the background. Direct links and presigned S3 URLs are fetched over HTTP; YouTube pages are fetched with yt-dlp when the server enables it. Once downloaded the job is queued for transcription.
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param request body SubmitURLRequest true "Media URL"
+// @Success 202 {object} SubmitURLResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/url [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) SubmitURL(c *gin.Context) {
+	var req SubmitURLRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	h.submitURL(c, req, "")
+}
+
+// submitURL creates a job from the media at a URL and starts its download.
+// A non-empty source refuses URLs the download service classifies otherwise.
+func (h *Handler) submitURL(c *gin.Context, req SubmitURLRequest, only string) {
+	source, host, err := h.downloads.Classify(req.URL)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if only != "" && source != only {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid YouTube URL"})
+		return
+	}
+	runAfter, err := scheduler.ResolveRunAfter(req.RunAfter, req.Schedule, time.Now())
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	sourceAudioAction, err := h.sourceAudioAction(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	jobKey, err := jobEncryptionKey(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	params, err := h.urlJobParameters(c, req.Parameters)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if !h.checkQuota(c, 0) {
+		return
+	}
+
+	// The job waits in the uploaded state, without audio, until the
+	// download moves it to pending
+	job := models.TranscriptionJob{
+		ID:                uuid.New().String(),
+		Status:            models.StatusUploaded,
+		SourceAudioAction: sourceAudioAction,
+		Parameters:        params,
+		RunAfter:          runAfter,
+	}
+	job.Diarization = job.Parameters.Diarize
+	if req.Title != nil && *req.Title != "" {
+		job.Title = req.Title
+	}
+	job.APIKeyID = apiKeyIDFromContext(c)
+	job.UserID = userIDFromContext(c)
+	encryptJobWith(&job, jobKey)
+
+	download := models.RemoteDownload{
+		JobID:  job.ID,
+		Source: source,
+		URL:    req.URL,
+		Host:   host,
+		Status: models.DownloadPending,
+	}
+	if err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		return tx.Create(&download).Error
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
+		return
+	}
+	jobstate.Created(c.Request.Context(), job.ID, job.Status, "source", "url", "host", host)
+	if jobKey != nil {
+		jobcrypt.Hold(job.ID, jobKey)
+	}
+
+	h.downloads.Start(job.ID)
+	c.JSON(http.StatusAccepted, SubmitURLResponse{Job: job, Download: download})
+}
+
+// urlJobParameters picks the parameters of a job submitted by URL: those
+// given, else the user's default profile, else the server defaults. Options
+// left out are filled from the server defaults and the result validated.
+func (h *Handler) urlJobParameters(c *gin.Context, given *models.WhisperXParams) (models.WhisperXParams, error) {
+	params := h.jobParams.Defaults()
+	if given != nil {
+		params = *given
+	} else if userID, ok := c.Get("user_id"); ok {
+		var user models.User
+		if err := database.DB.Select("id", "default_profile_id").First(&user, userID).Error; err == nil && user.DefaultProfileID != nil {
+			var profile models.TranscriptionProfile
+			if err := database.DB.Where("id = ?", *user.DefaultProfileID).First(&profile).Error; err == nil {
+				params = profile.Parameters
+			}
+		}
+	}
+	h.jobParams.Fill(&params)
+	return params, h.jobParams.Validate(params)
+}
+
+// GetRemoteDownload reports the download of a job submitted by URL
+// @Summary Get media download progress
+// @Description Get the status and progress of the download of a job submitted by URL. Progress stays 0 while the size of the media is unknown.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.RemoteDownload
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/download [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetRemoteDownload(c *gin.Context) {
+	var download models.RemoteDownload
+	if err := database.DB.Where("job_id = ?", c.Param("id")).First(&download).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job was not submitted by URL"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get download"})
+		return
+	}
+	c.JSON(http.StatusOK, download)
+}
@@ -0,0 +1,45 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GetRetentionReport reports what the retention policies would remove now
+// @Summary Preview retention
+// @Description List the completed jobs whose audio, and transcripts where the policy says so, the retention policies would delete or archive now, without changing anything
+// @Tags admin
+// @Produce json
+// @Success 200 {object} retention.Report
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/retention [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetRetentionReport(c *gin.Context) {
+	report, err := h.retention.Sweep(c.Request.Context(), true)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check retention policies"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
+
+// RunRetention applies the retention policies now
+// @Summary Run retention
+// @Description Apply the retention policies now instead of waiting for the janitor, even when it only reports. Jobs that could not be cleaned up carry an error.
+// @Tags admin
+// @Produce json
+// @Success 200 {object} retention.Report
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/retention/run [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) RunRetention(c *gin.Context) {
+	report, err := h.retention.Sweep(c.Request.Context(), false)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to apply retention policies"})
+		return
+	}
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,245 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobversion"
+	"synthezia/internal/models"
+	"synthezia/internal/revision"
+	"synthezia/internal/transcript"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// EditTranscriptRequest edits segments of a transcript
+type EditTranscriptRequest struct {
+	Segments []transcript.SegmentEdit `json:"segments" binding:"required,min=1"`
+	// Optional description of the correction
+	Note string `json:"note,omitempty"`
+}
+
+// TranscriptDiffResponse lists how two revisions of a transcript differ
+type TranscriptDiffResponse struct {
+	From     int                      `json:"from"`
+	To       int                      `json:"to"`
+	Segments []transcript.SegmentDiff `json:"segments"`
+}
+
+// revisionAuthor names who is making a request: the user, or the API key
+func revisionAuthor(c *gin.Context) string {
+	if username := c.GetString("username"); username != "" {
+		return username
+	}
+	if id := apiKeyIDFromContext(c); id != nil {
+		return fmt.Sprintf("api_key:%d", *id)
+	}
+	return ""
+}
+
+// writeRevisionError responds with the status matching a revision error
+func writeRevisionError(c *gin.Context, err error, action string) {
+	switch {
+	case errors.Is(err, jobversion.ErrConflict):
+		writeVersionError(c, err)
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+	case errors.Is(err, revision.ErrNoRevision):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+	case errors.Is(err, revision.ErrNotTranscribed), errors.Is(err, revision.ErrEncrypted), errors.Is(err, transcript.ErrInvalidEdit):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to " + action})
+	}
+}
+
+// EditTranscript corrects segments of a transcript as a new revision
+// @Summary Edit transcript segments
+// @Description Change the text, speaker or timing of transcript segments, numbered as in the structured format. The edit is stored as a new revision; the transcript it replaces, down to the machine original, stays available.
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body EditTranscriptRequest true "Segment edits"
+// @Param If-Match header string false "Job version the edit is based on"
+// @Success 200 {object} models.TranscriptRevision
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/transcript/segments [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) EditTranscript(c *gin.Context) {
+	var req EditTranscriptRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	rev, err := revision.Edit(database.DB, c.Param("id"), req.Segments, revisionAuthor(c), req.Note, expected)
+	if err != nil {
+		writeRevisionError(c, err, "edit transcript")
+		return
+	}
+	reindexTranscript(c.Param("id"))
+	setJobVersion(c, rev.JobVersion)
+	c.JSON(http.StatusOK, rev)
+}
+
+// ListTranscriptRevisions lists the revisions of a transcript
+// @Summary List transcript revisions
+// @Description List the revisions of a transcript, oldest first, without their text. Transcripts never edited have none.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {array} models.TranscriptRevision
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/transcript/revisions [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) ListTranscriptRevisions(c *gin.Context) {
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		writeRevisionError(c, err, "get job")
+		return
+	}
+	revisions, err := revision.List(database.DB, job.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list revisions"})
+		return
+	}
+	c.JSON(http.StatusOK, revisions)
+}
+
+// GetTranscriptRevision returns a transcript as of one revision
+// @Summary Get a transcript revision
+// @Description Get a revision of a transcript with the transcript as it read then
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param number path int true "Revision number"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/transcript/revisions/{number} [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetTranscriptRevision(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return
+	}
+	rev, err := revision.Get(database.DB, c.Param("id"), number)
+	if err != nil {
+		writeRevisionError(c, err, "get revision")
+		return
+	}
+
+	var stored interface{}
+	if err := json.Unmarshal([]byte(rev.Transcript), &stored); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transcript"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"revision":   rev,
+		"transcript": stored,
+	})
+}
+
+// RevertTranscript makes an earlier revision the transcript again
+// @Summary Revert a transcript
+// @Description Restore the transcript as of an earlier revision. The restore is itself a new revision, so nothing after it is lost.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param number path int true "Revision number to restore"
+// @Param If-Match header string false "Job version the edit is based on"
+// @Success 200 {object} models.TranscriptRevision
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/transcript/revisions/{number}/revert [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) RevertTranscript(c *gin.Context) {
+	number, err := strconv.Atoi(c.Param("number"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid revision number"})
+		return
+	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+	rev, err := revision.Revert(database.DB, c.Param("id"), number, revisionAuthor(c), expected)
+	if err != nil {
+		writeRevisionError(c, err, "revert transcript")
+		return
+	}
+	reindexTranscript(c.Param("id"))
+	setJobVersion(c, rev.JobVersion)
+	c.JSON(http.StatusOK, rev)
+}
+
+// DiffTranscriptRevisions compares two revisions of a transcript
+// @Summary Diff transcript revisions
+// @Description List the segments that differ between two revisions. By default the machine original, revision 1, is compared with the latest revision.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param from query int false "Revision to compare from" default(1)
+// @Param to query int false "Revision to compare to; the latest by default"
+// @Success 200 {object} TranscriptDiffResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/transcript/diff [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) DiffTranscriptRevisions(c *gin.Context) {
+	jobID := c.Param("id")
+	from, err := strconv.Atoi(c.DefaultQuery("from", "1"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid from revision"})
+		return
+	}
+	var to int
+	if value := c.Query("to"); value != "" {
+		if to, err = strconv.Atoi(value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid to revision"})
+			return
+		}
+	} else {
+		latest, err := revision.Latest(database.DB, jobID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get revisions"})
+			return
+		}
+		if latest == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "The transcript has no revisions"})
+			return
+		}
+		to = latest.Number
+	}
+
+	diffs, err := revision.Diff(database.DB, jobID, from, to)
+	if err != nil {
+		writeRevisionError(c, err, "diff revisions")
+		return
+	}
+	c.JSON(http.StatusOK, TranscriptDiffResponse{From: from, To: to, Segments: diffs})
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"synthezia/internal/auth"
+	"synthezia/internal/errreport"
+	"synthezia/internal/telemetry"
 	"synthezia/internal/web"
 	"synthezia/pkg/logger"
 	"synthezia/pkg/middleware"
@@ -20,8 +22,12 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 	// Create Gin router without default middleware
 	router := gin.New()
 
-	// Add recovery middleware
-	router.Use(gin.Recovery())
+	// Recover from panics and report them, along with errors logged while
+	// serving the request, to the error tracker when one is configured
+	router.Use(errreport.GinRecovery())
+
+	// Trace every request so log lines below can carry trace_id/span_id
+	router.Use(telemetry.GinMiddleware())
 
 	// Add custom logger middleware
 	router.Use(logger.GinLogger())
@@ -29,11 +35,17 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 	// Add compression middleware first for maximum benefit
 	router.Use(middleware.CompressionMiddleware())
 
+	// Debug capture sits inside compression so it records uncompressed bodies
+	router.Use(middleware.DebugCaptureMiddleware(handler.captureStore))
+
+	// Count requests per API key for usage analytics and anomaly alerts
+	router.Use(middleware.APIKeyUsageMiddleware(handler.usageTracker))
+
 	// Add CORS middleware
 	router.Use(func(c *gin.Context) {
 		c.Header("Access-Control-Allow-Origin", "*")
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key")
+		c.Header("Access-Control-Allow-Headers", "Origin, Content-Type, Content-Length, Accept-Encoding, X-CSRF-Token, Authorization, X-API-Key, X-Upload-Token, X-Job-Key")
 
 		if c.Request.Method == "OPTIONS" {
 			c.AbortWithStatus(204)
@@ -46,11 +58,23 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 	// Health check endpoint (no auth required)
 	router.GET("/health", handler.HealthCheck)
 
+	// Prometheus metrics endpoint (no auth required, like /health)
+	router.GET("/metrics", handler.Metrics)
+
 	// Swagger documentation
 	router.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
+	// Browser uploads authenticate with a single-use upload token rather than
+	// the session cookie, so they sit outside the CSRF-protected group
+	router.POST("/api/v1/browser-upload", middleware.NoCompressionMiddleware(), handler.BrowserUpload)
+
 	// API v1 routes
 	v1 := router.Group("/api/v1")
+
+	// Double-submit CSRF protection for requests authenticated by the session cookie
+	if handler.config.CSRFEnabled {
+		v1.Use(middleware.CSRFMiddleware("synthezia_refresh_token"))
+	}
 	{
 		// Authentication routes (no auth required)
 		auth := v1.Group("/auth")
@@ -60,6 +84,10 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 			auth.POST("/login", handler.Login)
 			auth.POST("/refresh", handler.Refresh)
 			auth.POST("/logout", handler.Logout)
+			auth.GET("/csrf", handler.GetCSRFToken)
+
+			// Single-use tickets for EventSource streams, which cannot send headers
+			auth.POST("/ticket", middleware.AuthMiddleware(authService), handler.CreateTicket)
 
 			// Account management routes (require authentication)
 			authProtected := auth.Group("")
@@ -79,6 +107,40 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 			apiKeys.GET("/", handler.ListAPIKeys)
 			apiKeys.POST("/", handler.CreateAPIKey)
 			apiKeys.DELETE("/:id", handler.DeleteAPIKey)
+			apiKeys.GET("/:id/usage", handler.GetAPIKeyUsage)
+			apiKeys.GET("/alerts", handler.ListAPIKeyAlerts)
+			apiKeys.POST("/alerts/:alert_id/revoke", handler.RevokeAPIKeyFromAlert)
+			apiKeys.POST("/alerts/:alert_id/dismiss", handler.DismissAPIKeyAlert)
+		}
+
+		// Webhooks belong to a user, so they require JWT like API keys
+		webhooks := v1.Group("/webhooks")
+		webhooks.Use(middleware.JWTOnlyMiddleware(authService))
+		{
+			webhooks.GET("", handler.ListWebhooks)
+			webhooks.POST("", handler.CreateWebhook)
+			webhooks.PUT("/:id", handler.UpdateWebhook)
+			webhooks.DELETE("/:id", handler.DeleteWebhook)
+			webhooks.GET("/:id/deliveries", handler.ListWebhookDeliveries)
+		}
+
+		// Tags and collections organize jobs
+		v1.GET("/tags", middleware.AuthMiddleware(authService), handler.ListTags)
+		collections := v1.Group("/collections")
+		collections.Use(middleware.AuthMiddleware(authService))
+		{
+			collections.GET("", handler.ListCollections)
+			collections.POST("", handler.CreateCollection)
+			collections.GET("/:id", handler.GetCollection)
+			collections.PUT("/:id", handler.UpdateCollection)
+			collections.DELETE("/:id", handler.DeleteCollection)
+		}
+
+		// Upload tokens are minted by a backend for its browser clients
+		uploadTokens := v1.Group("/upload-tokens")
+		uploadTokens.Use(middleware.AuthMiddleware(authService))
+		{
+			uploadTokens.POST("", handler.CreateUploadToken)
 		}
 
 		// Transcription routes (require authentication)
@@ -93,23 +155,54 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 				uploadRoutes.POST("/upload-video", handler.UploadVideo)
 				uploadRoutes.POST("/upload-multitrack", handler.UploadMultiTrack)
 				uploadRoutes.GET("/:id/audio", handler.GetAudioFile) // Audio streaming shouldn't be compressed
+				uploadRoutes.GET("/:id/audio/original", handler.DownloadOriginalAudio)
+				uploadRoutes.GET("/:id/audio/merged", handler.DownloadMergedAudio)
+				uploadRoutes.GET("/:id/merge-status/stream", handler.StreamMergeStatus)
 			}
 
 			// Regular API routes with compression
 			transcription.POST("/youtube", handler.DownloadFromYouTube)
+			transcription.POST("/url", handler.SubmitURL)
 			transcription.POST("/submit", handler.SubmitJob)
 			transcription.POST("/:id/start", handler.StartTranscription)
 			transcription.POST("/:id/kill", handler.KillJob)
+			transcription.POST("/:id/cancel", handler.CancelJob)
+			transcription.POST("/:id/pause", handler.PauseJob)
+			transcription.POST("/:id/resume", handler.ResumeJob)
 			transcription.GET("/:id/status", handler.GetJobStatus)
 			transcription.GET("/:id/transcript", handler.GetTranscript)
+			transcription.PUT("/:id/transcript/segments", handler.EditTranscript)
+			transcription.GET("/:id/transcript/revisions", handler.ListTranscriptRevisions)
+			transcription.GET("/:id/transcript/revisions/:number", handler.GetTranscriptRevision)
+			transcription.POST("/:id/transcript/revisions/:number/revert", handler.RevertTranscript)
+			transcription.GET("/:id/transcript/diff", handler.DiffTranscriptRevisions)
 			transcription.GET("/:id/execution", handler.GetJobExecutionData)
+			transcription.GET("/:id/events", handler.GetJobEvents)
+			transcription.GET("/:id/download", handler.GetRemoteDownload)
+			transcription.GET("/:id/download-url", handler.GetDownloadURL)
 			transcription.GET("/:id/merge-status", handler.GetMergeStatus)
+			transcription.POST("/:id/merge/retry", handler.RetryMerge)
+			transcription.POST("/:id/merge/cancel", handler.CancelMerge)
+			transcription.GET("/:id/merge/validate", handler.ValidateMerge)
 			transcription.GET("/:id/track-progress", handler.GetTrackProgress)
+			transcription.GET("/:id/waveform", handler.GetWaveform)
+			transcription.GET("/:id/quality", handler.GetQualityReport)
 			transcription.PUT("/:id/title", handler.UpdateTranscriptionTitle)
+			transcription.PUT("/:id/recorded-at", handler.UpdateRecordedAt)
+			transcription.PUT("/:id/tags", handler.SetJobTags)
+			transcription.POST("/:id/tags", handler.AddJobTags)
+			transcription.DELETE("/:id/tags/:tag", handler.RemoveJobTag)
+			transcription.PUT("/:id/collection", handler.SetJobCollection)
 			transcription.GET("/:id/summary", handler.GetSummaryForTranscription)
+			transcription.GET("/:id/insights", handler.GetInsights)
+			transcription.POST("/:id/insights", handler.RequestInsights)
+			transcription.GET("/:id/translations", handler.ListTranslations)
+			transcription.POST("/:id/translations", handler.RequestTranslations)
+			transcription.GET("/:id/translations/:language", handler.GetTranslation)
 			transcription.GET("/:id", handler.GetJobByID)
 			transcription.DELETE("/:id", handler.DeleteJob)
 			transcription.GET("/list", handler.ListJobs)
+			transcription.GET("/calendar", handler.GetRecordingCalendar)
 			transcription.GET("/models", handler.GetSupportedModels)
 			// Notes for a transcription
 			transcription.GET("/:id/notes", handler.ListNotes)
@@ -118,9 +211,11 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 			// Speaker mappings for a transcription
 			transcription.GET("/:id/speakers", handler.GetSpeakerMappings)
 			transcription.POST("/:id/speakers", handler.UpdateSpeakerMappings)
+			transcription.PUT("/:id/track-speakers", handler.UpdateTrackSpeakers)
 
 			// Quick transcription endpoints
 			transcription.POST("/quick", handler.SubmitQuickTranscription)
+			transcription.POST("/quick/sync", handler.SubmitQuickTranscriptionSync)
 			transcription.GET("/quick/:id", handler.GetQuickTranscriptionStatus)
 
 			// Live transcription endpoints
@@ -139,6 +234,13 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 			}
 		}
 
+		// Job progress streams; browsers authenticate with a ticket
+		job := v1.Group("/job")
+		job.Use(middleware.AuthMiddleware(authService), middleware.NoCompressionMiddleware())
+		{
+			job.GET("/:id/events", handler.StreamJobEvents)
+		}
+
 		// Profile routes (require authentication)
 		profiles := v1.Group("/profiles")
 		profiles.Use(middleware.AuthMiddleware(authService))
@@ -159,16 +261,82 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 			user.POST("/default-profile", handler.SetUserDefaultProfile)
 			user.GET("/settings", handler.GetUserSettings)
 			user.PUT("/settings", handler.UpdateUserSettings)
+			user.GET("/storage", handler.GetStorageUsage)
 		}
 
 		// Admin routes (require authentication)
 		admin := v1.Group("/admin")
 		admin.Use(middleware.AuthMiddleware(authService))
 		{
+			admin.GET("/storage", handler.GetStorageReport)
+			admin.GET("/retention", handler.GetRetentionReport)
+			admin.POST("/retention/run", handler.RunRetention)
+
 			queue := admin.Group("/queue")
 			{
 				queue.GET("/stats", handler.GetQueueStats)
+				queue.GET("/schedule", handler.GetSchedule)
+				queue.GET("/workers", handler.GetQueueWorkers)
+				queue.PUT("/workers", handler.UpdateQueueWorkers)
+			}
+
+			debug := admin.Group("/debug")
+			{
+				debug.GET("/capture-rules", handler.ListCaptureRules)
+				debug.POST("/capture-rules", handler.CreateCaptureRule)
+				debug.DELETE("/capture-rules/:id", handler.DeleteCaptureRule)
+				debug.GET("/captures", handler.ListCaptures)
+				debug.GET("/captures/:id", handler.GetCapture)
+				debug.DELETE("/captures", handler.ClearCaptures)
+			}
+
+			models := admin.Group("/models")
+			{
+				models.GET("", handler.ListModels)
+				models.POST("/:name/download", handler.DownloadModel)
+				models.DELETE("/:name", handler.DeleteModel)
 			}
+
+			admin.GET("/workers", handler.ListWorkers)
+			admin.GET("/logs", handler.GetRecentLogs)
+		}
+
+		// Remote transcription workers authenticate with the worker token
+		workers := v1.Group("/workers")
+		workers.Use(handler.WorkerAuthMiddleware())
+		{
+			workers.POST("/register", handler.RegisterWorker)
+			workers.POST("/:id/heartbeat", handler.WorkerHeartbeat)
+			workers.GET("/:id/tasks/next", handler.NextWorkerTask)
+			workers.GET("/:id/tasks/:task/audio", middleware.NoCompressionMiddleware(), handler.GetWorkerTaskAudio)
+			workers.POST("/:id/tasks/:task/progress", handler.ReportWorkerTaskProgress)
+			workers.POST("/:id/tasks/:task/result", handler.CompleteWorkerTask)
+			workers.POST("/:id/tasks/:task/fail", handler.FailWorkerTask)
+		}
+
+		// Audio format conversion (require authentication)
+		audioRoutes := v1.Group("/audio")
+		audioRoutes.Use(middleware.AuthMiddleware(authService), middleware.NoCompressionMiddleware())
+		{
+			audioRoutes.POST("/convert", handler.ConvertAudio)
+			audioRoutes.GET("/convert/:id", handler.GetAudioConversion)
+			audioRoutes.GET("/convert/:id/download", handler.DownloadAudioConversion)
+		}
+
+		// Full-text search of transcripts (require authentication)
+		v1.GET("/search", middleware.AuthMiddleware(authService), handler.SearchTranscripts)
+
+		// Optional subsystems, so clients can adapt their UI (require authentication)
+		v1.GET("/capabilities", middleware.AuthMiddleware(authService), handler.GetCapabilities)
+
+		// What the transcription queue holds and what its workers are doing
+		v1.GET("/queue", middleware.AuthMiddleware(authService), handler.GetQueue)
+
+		// Dropzone ingest status (require authentication)
+		dropzone := v1.Group("/dropzone")
+		dropzone.Use(middleware.AuthMiddleware(authService))
+		{
+			dropzone.GET("/status", handler.GetDropzoneStatus)
 		}
 
 		// LLM configuration routes (require authentication)
@@ -190,6 +358,9 @@ func SetupRoutes(handler *Handler, authService *auth.AuthService) *gin.Engine {
 			summaries.DELETE("/:id", handler.DeleteSummaryTemplate)
 			summaries.GET("/settings", handler.GetSummarySettings)
 			summaries.POST("/settings", handler.SaveSummarySettings)
+			summaries.POST("/:id/regenerate", handler.RegenerateSummaries)
+			summaries.GET("/regenerations", handler.ListSummaryRegenerations)
+			summaries.GET("/regenerations/:run_id", handler.GetSummaryRegeneration)
 		}
 
 		// Chat routes (require authentication)
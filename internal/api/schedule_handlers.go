@@ -0,0 +1,49 @@
+package api
+
+import (
+	"net/http"
+	"time"
+
+	"synthezia/internal/scheduler"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ScheduleResponse lists the recurring tasks and the jobs waiting to run
+type ScheduleResponse struct {
+	Tasks []scheduler.TaskStatus   `json:"tasks"`
+	Jobs  []scheduler.ScheduledJob `json:"jobs"`
+}
+
+// jobRunAfter reads when a job being started may run from the run_after and
+// schedule query or form values; nil means straight away
+func jobRunAfter(c *gin.Context) (*time.Time, error) {
+	runAfter := c.Query("run_after")
+	if runAfter == "" {
+		runAfter = c.PostForm("run_after")
+	}
+	spec := c.Query("schedule")
+	if spec == "" {
+		spec = c.PostForm("schedule")
+	}
+	return scheduler.ResolveRunAfter(runAfter, spec, time.Now())
+}
+
+// GetSchedule lists scheduled work
+// @Summary Get the schedule
+// @Description List the recurring tasks with their next and last runs, and the pending jobs held back until their run_after time
+// @Tags admin
+// @Produce json
+// @Success 200 {object} ScheduleResponse
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/queue/schedule [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetSchedule(c *gin.Context) {
+	jobs, err := h.scheduler.ScheduledJobs()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list scheduled jobs"})
+		return
+	}
+	c.JSON(http.StatusOK, ScheduleResponse{Tasks: h.scheduler.Tasks(), Jobs: jobs})
+}
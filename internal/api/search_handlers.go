@@ -0,0 +1,57 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"synthezia/internal/search"
+	"synthezia/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// SearchTranscripts finds the recordings where something was said
+// @Summary Search transcripts
+// @Description Search the text of every transcript. Jobs are returned best match first, each with its best matching segments: their timestamps, speaker and a snippet with the matched words wrapped in <mark> tags. The snippet text is not HTML-escaped. Words match other forms of the same word, a trailing * matches a prefix and "quoted phrases" match in order.
+// @Tags transcription
+// @Produce json
+// @Param q query string true "What to search for"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Jobs per page" default(10)
+// @Success 200 {object} search.Results
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/search [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) SearchTranscripts(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"})
+		return
+	}
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	results, err := search.Default.Search(query, limit, (page-1)*limit)
+	if err != nil {
+		logger.Error("Failed to search transcripts", "error", err)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to search transcripts"})
+		return
+	}
+	c.JSON(http.StatusOK, results)
+}
+
+// reindexTranscript updates the search index after a transcript changed
+func reindexTranscript(jobID string) {
+	if err := search.Default.Index(jobID); err != nil {
+		logger.Warn("Failed to index transcript", "job_id", jobID, "error", err)
+	}
+}
@@ -0,0 +1,96 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"synthezia/internal/quota"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StorageReport is the storage used by every user
+type StorageReport struct {
+	QuotaBytes int64         `json:"quota_bytes"` // 0 when unlimited
+	Users      []quota.Usage `json:"users"`
+	// Unowned is the jobs of API keys and of dropzones without a user, which
+	// no quota limits
+	Unowned quota.Usage `json:"unowned"`
+}
+
+// userIDFromContext is the signed-in user of a request, if any
+func userIDFromContext(c *gin.Context) *uint {
+	if value, ok := c.Get("user_id"); ok {
+		if id, ok := value.(uint); ok {
+			return &id
+		}
+	}
+	return nil
+}
+
+// checkQuota makes sure the signed-in user may store incoming more bytes,
+// responding with 507 Insufficient Storage when they may not
+func (h *Handler) checkQuota(c *gin.Context, incoming int64) bool {
+	err := h.quotas.Check(userIDFromContext(c), incoming)
+	if err == nil {
+		return true
+	}
+	var exceeded *quota.ExceededError
+	if errors.As(err, &exceeded) {
+		c.JSON(http.StatusInsufficientStorage, gin.H{"error": exceeded.Error(), "usage": exceeded.Usage})
+		return false
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check storage quota"})
+	return false
+}
+
+// GetStorageUsage reports the storage used by the current user
+// @Summary Get storage usage
+// @Description Get the disk space taken by the current user's jobs, split into the audio as submitted and everything made from it, against the storage quota
+// @Tags user
+// @Produce json
+// @Success 200 {object} quota.Usage
+// @Failure 401 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/user/storage [get]
+// @Security BearerAuth
+func (h *Handler) GetStorageUsage(c *gin.Context) {
+	userID := userIDFromContext(c)
+	if userID == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "User not authenticated"})
+		return
+	}
+	usage, err := h.quotas.Usage(*userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to measure storage usage"})
+		return
+	}
+	if username, ok := c.Get("username"); ok {
+		usage.Username, _ = username.(string)
+	}
+	c.JSON(http.StatusOK, usage)
+}
+
+// GetStorageReport reports the storage used by every user
+// @Summary Get storage report
+// @Description Get the disk space taken by each user's jobs against the storage quota, and by the jobs no user owns
+// @Tags admin
+// @Produce json
+// @Success 200 {object} StorageReport
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/admin/storage [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetStorageReport(c *gin.Context) {
+	usages, err := h.quotas.Report()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to measure storage usage"})
+		return
+	}
+	last := len(usages) - 1
+	c.JSON(http.StatusOK, StorageReport{
+		QuotaBytes: h.quotas.Limit(),
+		Users:      usages[:last],
+		Unowned:    usages[last],
+	})
+}
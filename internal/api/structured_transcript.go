@@ -0,0 +1,36 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/transcript"
+	"synthezia/internal/transcription/interfaces"
+)
+
+// writeStructuredTranscript responds with a stored transcript of a job, or
+// of one of its translations, in the structured format, with its speakers
+// renamed as mapped
+func writeStructuredTranscript(c *gin.Context, jobID, stored string) {
+	var result interfaces.TranscriptResult
+	if err := json.Unmarshal([]byte(stored), &result); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse transcript"})
+		return
+	}
+
+	var mappings []models.SpeakerMapping
+	if err := database.DB.Where("transcription_job_id = ?", jobID).Find(&mappings).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get speaker mappings"})
+		return
+	}
+	speakerNames := make(map[string]string, len(mappings))
+	for _, mapping := range mappings {
+		speakerNames[mapping.OriginalSpeaker] = mapping.CustomName
+	}
+
+	c.JSON(http.StatusOK, transcript.Structure(jobID, &result, speakerNames))
+}
@@ -42,6 +42,11 @@ func (h *Handler) Summarize(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	var job models.TranscriptionJob
+	if err := database.DB.Select("id", "encrypted").Where("id = ?", req.TranscriptionID).First(&job).Error; err == nil && job.Encrypted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Encrypted transcriptions cannot be summarized"})
+		return
+	}
 
 	svc, provider, err := h.getLLMService()
 	if err != nil {
@@ -0,0 +1,69 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"synthezia/internal/auth"
+)
+
+// CreateTicketRequest names the endpoint a ticket opens
+type CreateTicketRequest struct {
+	Path string `json:"path" binding:"required"` // e.g. /api/v1/transcription/live/sessions/<id>/stream
+}
+
+// TicketResponse holds a single-use connection ticket
+type TicketResponse struct {
+	Ticket    string    `json:"ticket"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// CreateTicket issues a short-lived ticket for opening a stream
+// @Summary Create connection ticket
+// @Description Issue a single-use ticket, valid for 30 seconds, that authenticates one GET connection to the given path, such as an EventSource stream. Pass it as the ticket query parameter, so long-lived tokens never appear in URLs.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Param request body CreateTicketRequest true "Endpoint the ticket opens"
+// @Success 201 {object} TicketResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/auth/ticket [post]
+func (h *Handler) CreateTicket(c *gin.Context) {
+	var req CreateTicketRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	if !strings.HasPrefix(req.Path, "/api/v1/") {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "path must be an /api/v1/ endpoint"})
+		return
+	}
+
+	identity := auth.TicketIdentity{AuthType: c.GetString("auth_type")}
+	if identity.AuthType == "api_key" {
+		identity.APIKey = c.GetString("api_key")
+		if id := apiKeyIDFromContext(c); id != nil {
+			identity.APIKeyID = *id
+		}
+	} else {
+		identity.UserID = c.GetUint("user_id")
+		identity.Username = c.GetString("username")
+	}
+
+	ticket, expiresAt, err := h.authService.Tickets().Issue(identity, req.Path)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create ticket"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, TicketResponse{
+		Ticket:    ticket,
+		ExpiresAt: expiresAt,
+	})
+}
@@ -0,0 +1,137 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobversion"
+	"synthezia/internal/models"
+)
+
+// TrackSpeakersUpdateRequest names who speaks on the tracks of a multi-track job
+type TrackSpeakersUpdateRequest struct {
+	// Speaker names by track file name, with or without its extension. An
+	// empty name clears the track's speaker, leaving it named after its file.
+	Speakers map[string]string `json:"speakers" binding:"required"`
+}
+
+// UpdateTrackSpeakers sets the speaker of each track of a multi-track job
+// @Summary Set the speakers of a multi-track job's tracks
+// @Description Name who speaks on each track of a multi-track job. Each track is transcribed on its own and its words attributed to its speaker, so the names apply from the next transcription; rename speakers of a finished transcript with the speakers endpoint.
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body TrackSpeakersUpdateRequest true "Speaker names by track file name"
+// @Param If-Match header string false "Job version the edit is based on"
+// @Success 200 {array} models.MultiTrackFile
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/track-speakers [put]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) UpdateTrackSpeakers(c *gin.Context) {
+	var req TrackSpeakersUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+	expected, ok := expectedVersion(c)
+	if !ok {
+		return
+	}
+
+	var job models.TranscriptionJob
+	if err := database.DB.Preload("MultiTrackFiles").Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transcription job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get transcription job"})
+		return
+	}
+	if !job.IsMultiTrack {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Only multi-track jobs have track speakers"})
+		return
+	}
+	if job.Status == models.StatusProcessing {
+		c.JSON(http.StatusConflict, gin.H{"error": "Track speakers cannot change while the job is transcribing"})
+		return
+	}
+
+	tracks := job.MultiTrackFiles
+	if err := applyTrackSpeakers(tracks, req.Speakers); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var version int
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		for _, track := range tracks {
+			if err := tx.Model(&models.MultiTrackFile{}).Where("id = ?", track.ID).Update("speaker_name", track.SpeakerName).Error; err != nil {
+				return err
+			}
+		}
+		var err error
+		version, err = jobversion.Bump(tx, job.ID, expected)
+		return err
+	})
+	if err != nil {
+		if !writeVersionError(c, err) {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update track speakers"})
+		}
+		return
+	}
+	setJobVersion(c, version)
+	c.JSON(http.StatusOK, tracks)
+}
+
+// parseTrackSpeakers reads the speakers form field of a multi-track upload:
+// a JSON object of speaker names by track file name
+func parseTrackSpeakers(raw string) (map[string]string, error) {
+	if strings.TrimSpace(raw) == "" {
+		return nil, nil
+	}
+	var speakers map[string]string
+	if err := json.Unmarshal([]byte(raw), &speakers); err != nil {
+		return nil, errors.New("speakers must be a JSON object of speaker names by track file name")
+	}
+	return speakers, nil
+}
+
+// applyTrackSpeakers sets the speaker names of tracks, matching each key to a
+// track's file name with or without its extension. Names for tracks the job
+// does not have are an error.
+func applyTrackSpeakers(tracks []models.MultiTrackFile, speakers map[string]string) error {
+	for key, name := range speakers {
+		found := false
+		for i := range tracks {
+			fileName := tracks[i].FileName
+			if key != fileName && key != fileName+filepath.Ext(tracks[i].FilePath) {
+				continue
+			}
+			found = true
+			if name = strings.TrimSpace(name); name == "" {
+				tracks[i].SpeakerName = nil
+			} else if len(name) > 255 {
+				return fmt.Errorf("speaker name for track %s is too long", key)
+			} else {
+				speaker := name
+				tracks[i].SpeakerName = &speaker
+			}
+		}
+		if !found {
+			return fmt.Errorf("no track named %s", key)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,178 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/translation"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// TranslateRequest picks the languages to translate a transcript into
+type TranslateRequest struct {
+	Languages []string `json:"languages" binding:"required,min=1"`
+	// One of llm (default) or whisper, which only translates into English
+	Provider string `json:"provider,omitempty"`
+	// LLM model override; defaults to the default summary model
+	Model string `json:"model,omitempty"`
+}
+
+// TrackTranslations translates completed jobs into the languages their
+// parameters ask for, until the returned function is called
+func (h *Handler) TrackTranslations() func() {
+	return h.translator.Track()
+}
+
+// ResumeTranslations continues translations interrupted by a restart
+func (h *Handler) ResumeTranslations() {
+	h.translator.Resume()
+}
+
+// Translator returns the service translating transcripts, mainly for tests
+func (h *Handler) Translator() *translation.Service {
+	return h.translator
+}
+
+// RequestTranslations translates a transcript into more languages
+// @Summary Translate a transcript
+// @Description Translate a completed transcript into the given languages in the background. Each language is stored as its own translation, replacing an earlier one.
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param request body TranslateRequest true "Languages"
+// @Success 202 {array} models.Translation
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/translations [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) RequestTranslations(c *gin.Context) {
+	var req TranslateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	languages := translation.NormalizeLanguages(req.Languages)
+	if len(languages) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "No languages given"})
+		return
+	}
+	if err := translation.Validate(languages, req.Provider); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	translations, err := h.translator.Start(c.Param("id"), translation.Request{
+		Languages: languages,
+		Provider:  req.Provider,
+		Model:     req.Model,
+	})
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		case errors.Is(err, translation.ErrNotTranscribed), errors.Is(err, translation.ErrEncrypted), errors.Is(err, translation.ErrNoModel):
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start translations"})
+		}
+		return
+	}
+
+	c.JSON(http.StatusAccepted, translations)
+}
+
+// ListTranslations lists the translations of a transcript
+// @Summary List translations
+// @Description List the translations of a transcript with their status, without their text
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Success 200 {array} models.Translation
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/translations [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) ListTranslations(c *gin.Context) {
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+
+	translations := []models.Translation{}
+	if err := database.DB.Where("transcription_id = ?", job.ID).Order("target_language ASC").Find(&translations).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list translations"})
+		return
+	}
+	c.JSON(http.StatusOK, translations)
+}
+
+// GetTranslation returns a transcript translated into one language
+// @Summary Get a translation
+// @Description Get a transcript translated into a language. format=structured returns it in the versioned structured format; translated segments carry no words.
+// @Tags transcription
+// @Produce json
+// @Param id path string true "Job ID"
+// @Param language path string true "Target language code"
+// @Param format query string false "raw or structured" default(raw)
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/translations/{language} [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetTranslation(c *gin.Context) {
+	format := c.DefaultQuery("format", "raw")
+	if format != "raw" && format != "structured" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be raw or structured"})
+		return
+	}
+
+	var t models.Translation
+	if err := database.DB.Where("transcription_id = ? AND target_language = ?", c.Param("id"), c.Param("language")).First(&t).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Translation not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get translation"})
+		return
+	}
+	if t.Status != models.TranslationCompleted || t.Transcript == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Translation not completed, current status: " + t.Status})
+		return
+	}
+
+	if format == "structured" {
+		writeStructuredTranscript(c, t.TranscriptionID, *t.Transcript)
+		return
+	}
+
+	var transcript interface{}
+	if err := json.Unmarshal([]byte(*t.Transcript), &transcript); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to parse translation"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{
+		"job_id":          t.TranscriptionID,
+		"target_language": t.TargetLanguage,
+		"provider":        t.Provider,
+		"model":           t.Model,
+		"transcript":      transcript,
+		"created_at":      t.CreatedAt,
+		"completed_at":    t.CompletedAt,
+	})
+}
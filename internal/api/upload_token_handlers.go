@@ -0,0 +1,222 @@
+package api
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+)
+
+const (
+	// UploadTokenHeader carries a browser upload token
+	UploadTokenHeader = "X-Upload-Token"
+
+	uploadTokenPrefix              = "sut_"
+	defaultUploadTokenExpiry       = 15 * time.Minute
+	maxUploadTokenExpiry           = time.Hour
+	multipartOverhead        int64 = 1 << 20 // Form fields and part headers around the file
+)
+
+// CreateUploadTokenRequest describes the single upload a token allows
+type CreateUploadTokenRequest struct {
+	MaxBytes      int64  `json:"max_bytes" binding:"required,min=1"`
+	ExpiresIn     int    `json:"expires_in,omitempty"` // Seconds, default 900, at most 3600
+	AllowedOrigin string `json:"allowed_origin,omitempty"`
+	Title         string `json:"title,omitempty"`
+}
+
+// UploadTokenResponse returns a minted token. The token itself is shown only once.
+type UploadTokenResponse struct {
+	Token         string    `json:"token"`
+	UploadURL     string    `json:"upload_url"`
+	MaxBytes      int64     `json:"max_bytes"`
+	AllowedOrigin *string   `json:"allowed_origin,omitempty"`
+	ExpiresAt     time.Time `json:"expires_at"`
+}
+
+func hashUploadToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
+
+// CreateUploadToken mints a short-lived token for one browser upload
+// @Summary Create browser upload token
+// @Description Mint a short-lived token a browser can use for exactly one audio upload of bounded size, so frontend code never holds a real API key. Meant to be called by your backend.
+// @Tags transcription
+// @Accept json
+// @Produce json
+// @Param request body CreateUploadTokenRequest true "Upload limits"
+// @Success 201 {object} UploadTokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/upload-tokens [post]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) CreateUploadToken(c *gin.Context) {
+	var req CreateUploadTokenRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request: " + err.Error()})
+		return
+	}
+
+	if limit := int64(h.config.UploadTokenMaxMB) << 20; limit > 0 && req.MaxBytes > limit {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "max_bytes exceeds the server limit", "limit": limit})
+		return
+	}
+	expiry := defaultUploadTokenExpiry
+	if req.ExpiresIn != 0 {
+		expiry = time.Duration(req.ExpiresIn) * time.Second
+		if expiry < time.Second || expiry > maxUploadTokenExpiry {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must be between 1 and 3600 seconds"})
+			return
+		}
+	}
+
+	token := uploadTokenPrefix + generateSecureAPIKey(40)
+	record := models.UploadToken{
+		TokenHash: hashUploadToken(token),
+		APIKeyID:  apiKeyIDFromContext(c),
+		MaxBytes:  req.MaxBytes,
+		ExpiresAt: time.Now().Add(expiry),
+	}
+	if userID, ok := c.Get("user_id"); ok {
+		if id, ok := userID.(uint); ok {
+			record.UserID = &id
+		}
+	}
+	if origin := strings.TrimRight(req.AllowedOrigin, "/"); origin != "" {
+		record.AllowedOrigin = &origin
+	}
+	if req.Title != "" {
+		record.Title = &req.Title
+	}
+
+	if err := database.DB.Create(&record).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create upload token"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, UploadTokenResponse{
+		Token:         token,
+		UploadURL:     "/api/v1/browser-upload",
+		MaxBytes:      record.MaxBytes,
+		AllowedOrigin: record.AllowedOrigin,
+		ExpiresAt:     record.ExpiresAt,
+	})
+}
+
+// BrowserUpload uploads an audio file with an upload token
+// @Summary Upload audio with an upload token
+// @Description Upload one audio file using a token from POST /upload-tokens, passed in the X-Upload-Token header or the token query parameter. The token is consumed by a successful upload.
+// @Tags transcription
+// @Accept multipart/form-data
+// @Produce json
+// @Param audio formData file true "Audio file"
+// @Param title formData string false "Job title"
+// @Param token query string false "Upload token, if not sent in the X-Upload-Token header"
+// @Success 200 {object} models.TranscriptionJob
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 413 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/browser-upload [post]
+func (h *Handler) BrowserUpload(c *gin.Context) {
+	token := c.GetHeader(UploadTokenHeader)
+	if token == "" {
+		token = c.Query("token")
+	}
+	if !strings.HasPrefix(token, uploadTokenPrefix) {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Upload token required"})
+		return
+	}
+
+	var record models.UploadToken
+	if err := database.DB.Where("token_hash = ?", hashUploadToken(token)).First(&record).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid upload token"})
+		return
+	}
+
+	// Revoking the minting key revokes its outstanding tokens too
+	if record.APIKeyID != nil {
+		var active int64
+		database.DB.Model(&models.APIKey{}).Where("id = ? AND is_active = ?", *record.APIKeyID, true).Count(&active)
+		if active == 0 {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid upload token"})
+			return
+		}
+	}
+
+	if record.AllowedOrigin != nil {
+		if c.GetHeader("Origin") != *record.AllowedOrigin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Upload token is not valid for this origin"})
+			return
+		}
+		c.Header("Access-Control-Allow-Origin", *record.AllowedOrigin)
+		c.Header("Vary", "Origin")
+	}
+
+	// Claim the token before reading the body so it cannot be used twice
+	// concurrently; a failed upload releases it again
+	now := time.Now()
+	claim := database.DB.Model(&models.UploadToken{}).
+		Where("id = ? AND used_at IS NULL AND expires_at > ?", record.ID, now).
+		Update("used_at", now)
+	if claim.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to verify upload token"})
+		return
+	}
+	if claim.RowsAffected == 0 {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Upload token expired or already used"})
+		return
+	}
+	release := func() {
+		database.DB.Model(&models.UploadToken{}).Where("id = ?", record.ID).Update("used_at", nil)
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, record.MaxBytes+multipartOverhead)
+	if err := c.Request.ParseMultipartForm(32 << 20); err != nil {
+		release()
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the upload token's size limit"})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Audio file is required"})
+		return
+	}
+	if files := c.Request.MultipartForm.File["audio"]; len(files) > 0 && files[0].Size > record.MaxBytes {
+		release()
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "File exceeds the upload token's size limit"})
+		return
+	}
+
+	// The upload counts as made by whoever minted the token
+	if record.APIKeyID != nil {
+		c.Set("api_key_id", *record.APIKeyID)
+	}
+	if record.UserID != nil {
+		c.Set("user_id", *record.UserID)
+	}
+
+	job, ok := h.saveAudioUpload(c)
+	if !ok {
+		release()
+		return
+	}
+	// A title chosen by the minting backend wins over the form field
+	if record.Title != nil {
+		job.Title = record.Title
+		database.DB.Model(job).Update("title", *record.Title)
+	}
+	database.DB.Model(&models.UploadToken{}).Where("id = ?", record.ID).Update("job_id", job.ID)
+
+	c.JSON(http.StatusOK, job)
+}
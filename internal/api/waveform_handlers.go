@@ -0,0 +1,125 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"net/http"
+	"path/filepath"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+)
+
+// GetWaveform returns the peaks of a job's audio for drawing its waveform
+// @Summary Get waveform peaks
+// @Description Get min/max peak pairs of the job's audio in the audiowaveform format, as JSON or as a binary .dat file (format=dat), so players can draw the waveform without downloading the audio. The peaks are computed on the first request and stored with the job.
+// @Tags transcription
+// @Produce json
+// @Produce application/octet-stream
+// @Param id path string true "Job ID"
+// @Param format query string false "json or dat" default(json)
+// @Success 200 {object} audio.Waveform
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/transcription/{id}/waveform [get]
+// @Security ApiKeyAuth
+// @Security BearerAuth
+func (h *Handler) GetWaveform(c *gin.Context) {
+	format := c.DefaultQuery("format", "json")
+	if format != "json" && format != "dat" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be json or dat"})
+		return
+	}
+
+	var job models.TranscriptionJob
+	if err := database.DB.Where("id = ?", c.Param("id")).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get job"})
+		return
+	}
+	// Peaks stored in the clear would reveal the shape of sealed audio
+	if job.Encrypted {
+		c.JSON(http.StatusConflict, gin.H{"error": "Waveforms are not available for encrypted jobs"})
+		return
+	}
+
+	waveform, status, err := h.loadWaveform(c, &job)
+	if err != nil {
+		c.JSON(status, gin.H{"error": err.Error()})
+		return
+	}
+
+	if format == "dat" {
+		data, _ := waveform.MarshalBinary()
+		c.Header("Content-Disposition", `attachment; filename="`+job.ID+`.dat"`)
+		c.Data(http.StatusOK, "application/octet-stream", data)
+		return
+	}
+	c.JSON(http.StatusOK, waveform)
+}
+
+// loadWaveform returns the job's stored waveform, computing and storing it
+// first when there is none, with the HTTP status to report on failure
+func (h *Handler) loadWaveform(c *gin.Context, job *models.TranscriptionJob) (*audio.Waveform, int, error) {
+	if job.WaveformPath != nil && *job.WaveformPath != "" {
+		if data, err := fsys.ReadFile(h.fs, *job.WaveformPath); err == nil {
+			var waveform audio.Waveform
+			if err := waveform.UnmarshalBinary(data); err == nil {
+				return &waveform, http.StatusOK, nil
+			}
+			logger.Warn("Stored waveform is invalid, computing it again", "job_id", job.ID, "path", *job.WaveformPath)
+		}
+	}
+
+	// Multi-track jobs are drawn from their mix; the playback proxy will do
+	// once the original is gone
+	source := ""
+	candidates := []*string{&job.AudioPath, job.ProxyAudioPath}
+	if job.IsMultiTrack {
+		candidates = append([]*string{job.MergedAudioPath}, candidates...)
+	}
+	for _, candidate := range candidates {
+		if candidate != nil && *candidate != "" && fsys.Exists(h.fs, *candidate) {
+			source = *candidate
+			break
+		}
+	}
+	if source == "" {
+		if job.SourceAudioRemovedAt != nil {
+			return nil, http.StatusGone, errors.New("The source audio was deleted after transcription")
+		}
+		return nil, http.StatusNotFound, errors.New("Audio file not found on disk")
+	}
+
+	waveform, err := h.waveforms.Generate(c.Request.Context(), source)
+	if err != nil {
+		logger.Error("Failed to generate waveform", "job_id", job.ID, "error", err)
+		return nil, http.StatusInternalServerError, errors.New("Failed to generate waveform")
+	}
+
+	// Keep the peaks next to the audio they were taken from
+	data, _ := waveform.MarshalBinary()
+	path := strings.TrimSuffix(source, filepath.Ext(source)) + ".waveform.dat"
+	if _, err := fsys.WriteFileAtomic(h.fs, h.config.TempDir, path, bytes.NewReader(data)); err != nil {
+		logger.Warn("Failed to store waveform", "job_id", job.ID, "error", err)
+		return waveform, http.StatusOK, nil
+	}
+	if err := database.DB.Model(&models.TranscriptionJob{}).Where("id = ?", job.ID).Update("waveform_path", path).Error; err != nil {
+		logger.Warn("Failed to record waveform", "job_id", job.ID, "error", err)
+		h.fs.Remove(path)
+	}
+	return waveform, http.StatusOK, nil
+}
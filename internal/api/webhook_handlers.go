@@ -0,0 +1,282 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/webhook"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// maxWebhookDeliveries caps how many deliveries one log request returns
+const maxWebhookDeliveries = 200
+
+// CreateWebhookRequest registers a webhook
+type CreateWebhookRequest struct {
+	URL string `json:"url" binding:"required"`
+	// Optional signing secret; one is generated when empty
+	Secret string `json:"secret,omitempty"`
+	// Events to deliver, such as job.completed and job.failed; every event when empty
+	Events []string `json:"events,omitempty"`
+}
+
+// UpdateWebhookRequest changes a webhook; fields left out are kept
+type UpdateWebhookRequest struct {
+	URL      *string   `json:"url,omitempty"`
+	Secret   *string   `json:"secret,omitempty"`
+	Events   *[]string `json:"events,omitempty"`
+	IsActive *bool     `json:"is_active,omitempty"`
+}
+
+// WebhookResponse is a webhook with its event filter
+type WebhookResponse struct {
+	models.Webhook
+	Events []string `json:"events"`
+}
+
+// CreateWebhookResponse is a new webhook with the secret its deliveries are
+// signed with, which is not shown again
+type CreateWebhookResponse struct {
+	WebhookResponse
+	Secret string `json:"secret"`
+}
+
+func webhookResponse(hook models.Webhook) WebhookResponse {
+	return WebhookResponse{Webhook: hook, Events: webhook.SplitEvents(hook.Events)}
+}
+
+// SetWebhooks overrides the webhook delivery service, mainly for tests
+func (h *Handler) SetWebhooks(webhooks *webhook.Service) {
+	h.webhooks = webhooks
+}
+
+// TrackWebhooks delivers job events to webhooks until the returned function is called
+func (h *Handler) TrackWebhooks() func() {
+	return h.webhooks.Track()
+}
+
+// ResumeWebhooks carries on with deliveries interrupted by a restart
+func (h *Handler) ResumeWebhooks() {
+	h.webhooks.Resume()
+}
+
+// findWebhook loads a webhook of the requesting user, responding when it cannot
+func findWebhook(c *gin.Context) (*models.Webhook, bool) {
+	id, err := strconv.ParseUint(c.Param("id"), 10, 32)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid webhook ID"})
+		return nil, false
+	}
+	var hook models.Webhook
+	if err := database.DB.Where("id = ? AND user_id = ?", uint(id), c.GetUint("user_id")).First(&hook).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+			return nil, false
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		return nil, false
+	}
+	return &hook, true
+}
+
+// ListWebhooks lists the webhooks of the current user
+// @Summary List webhooks
+// @Description List the webhooks registered by the current user
+// @Tags webhooks
+// @Produce json
+// @Success 200 {array} WebhookResponse
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/webhooks [get]
+// @Security BearerAuth
+func (h *Handler) ListWebhooks(c *gin.Context) {
+	var hooks []models.Webhook
+	if err := database.DB.Where("user_id = ?", c.GetUint("user_id")).Order("id ASC").Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list webhooks"})
+		return
+	}
+	response := make([]WebhookResponse, 0, len(hooks))
+	for _, hook := range hooks {
+		response = append(response, webhookResponse(hook))
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// CreateWebhook registers a webhook for the current user
+// @Summary Create a webhook
+// @Description Register a URL to be told when jobs complete or fail. Each delivery is a JSON POST carrying X-Synthezia-Event, X-Synthezia-Delivery, X-Synthezia-Timestamp and X-Synthezia-Signature headers; the signature is "sha256=" and the hex HMAC-SHA256 of the timestamp, a dot and the body, keyed with the secret. Failed deliveries are retried with backoff.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param request body CreateWebhookRequest true "Webhook"
+// @Success 201 {object} CreateWebhookResponse
+// @Failure 400 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/webhooks [post]
+// @Security BearerAuth
+func (h *Handler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := webhook.ValidateURL(req.URL); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	events, err := webhook.NormalizeEvents(req.Events)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	secret := req.Secret
+	if secret == "" {
+		secret = generateSecureAPIKey(32)
+	}
+
+	hook := models.Webhook{
+		UserID:   c.GetUint("user_id"),
+		URL:      req.URL,
+		Secret:   secret,
+		Events:   strings.Join(events, ","),
+		IsActive: true,
+	}
+	if err := database.DB.Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create webhook"})
+		return
+	}
+	c.JSON(http.StatusCreated, CreateWebhookResponse{WebhookResponse: webhookResponse(hook), Secret: secret})
+}
+
+// UpdateWebhook changes a webhook of the current user
+// @Summary Update a webhook
+// @Description Change the URL, secret or event filter of a webhook, or pause it. Pending deliveries to a paused webhook fail.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param request body UpdateWebhookRequest true "Changes"
+// @Success 200 {object} WebhookResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/webhooks/{id} [put]
+// @Security BearerAuth
+func (h *Handler) UpdateWebhook(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	var req UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.URL != nil {
+		if err := webhook.ValidateURL(*req.URL); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["url"] = *req.URL
+	}
+	if req.Secret != nil {
+		if *req.Secret == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "secret cannot be empty"})
+			return
+		}
+		updates["secret"] = *req.Secret
+	}
+	if req.Events != nil {
+		events, err := webhook.NormalizeEvents(*req.Events)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["events"] = strings.Join(events, ",")
+	}
+	if req.IsActive != nil {
+		updates["is_active"] = *req.IsActive
+	}
+
+	if len(updates) > 0 {
+		if err := database.DB.Model(hook).Updates(updates).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update webhook"})
+			return
+		}
+	}
+	var updated models.Webhook
+	if err := database.DB.Where("id = ?", hook.ID).First(&updated).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, webhookResponse(updated))
+}
+
+// DeleteWebhook removes a webhook of the current user with its delivery log
+// @Summary Delete a webhook
+// @Description Remove a webhook and its delivery log
+// @Tags webhooks
+// @Param id path int true "Webhook ID"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/webhooks/{id} [delete]
+// @Security BearerAuth
+func (h *Handler) DeleteWebhook(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete webhook"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"message": "Webhook deleted successfully"})
+}
+
+// ListWebhookDeliveries returns the delivery log of a webhook
+// @Summary List webhook deliveries
+// @Description List the deliveries to a webhook, newest first, with the number of attempts, the receiver's last response and when the next retry is due
+// @Tags webhooks
+// @Produce json
+// @Param id path int true "Webhook ID"
+// @Param limit query int false "Maximum deliveries to return" default(50)
+// @Success 200 {array} models.WebhookDelivery
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /api/v1/webhooks/{id}/deliveries [get]
+// @Security BearerAuth
+func (h *Handler) ListWebhookDeliveries(c *gin.Context) {
+	hook, ok := findWebhook(c)
+	if !ok {
+		return
+	}
+	limit, err := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if err != nil || limit < 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid limit"})
+		return
+	}
+	limit = min(limit, maxWebhookDeliveries)
+
+	deliveries := []models.WebhookDelivery{}
+	if err := database.DB.Where("webhook_id = ?", hook.ID).Order("id DESC").Limit(limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to list deliveries"})
+		return
+	}
+	c.JSON(http.StatusOK, deliveries)
+}
@@ -0,0 +1,237 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strings"
+
+	"synthezia/internal/remoteworker"
+	"synthezia/internal/transcription/interfaces"
+
+	"github.com/gin-gonic/gin"
+)
+
+// WorkerListResponse lists the remote workers and the tasks waiting for them
+type WorkerListResponse struct {
+	Workers      []remoteworker.Worker `json:"workers"`
+	WaitingTasks int                   `json:"waiting_tasks"`
+}
+
+// SetRemoteWorkers sets the dispatcher handing transcriptions to remote workers
+func (h *Handler) SetRemoteWorkers(dispatcher *remoteworker.Dispatcher) {
+	h.remoteWorkers = dispatcher
+}
+
+// WorkerAuthMiddleware admits remote workers presenting the worker token.
+// Without a token or a dispatcher the worker endpoints do not exist.
+func (h *Handler) WorkerAuthMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if h.config.WorkerToken == "" || h.remoteWorkers == nil {
+			c.AbortWithStatusJSON(http.StatusNotFound, gin.H{"error": "Remote workers are not enabled"})
+			return
+		}
+		token, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(h.config.WorkerToken)) != 1 {
+			c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Invalid worker token"})
+			return
+		}
+		c.Next()
+	}
+}
+
+// workerError answers a request about an unknown worker or task
+func workerError(c *gin.Context, err error) {
+	switch {
+	case errors.Is(err, remoteworker.ErrUnknownWorker):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Worker not registered"})
+	case errors.Is(err, remoteworker.ErrUnknownTask):
+		c.JSON(http.StatusGone, gin.H{"error": "Task is no longer running on this worker"})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	}
+}
+
+// RegisterWorker adds a remote worker
+// @Summary Register a remote worker
+// @Description Register a worker machine that runs transcriptions for this server. Workers authenticate with the server's worker token and must heartbeat at the returned interval.
+// @Tags workers
+// @Accept json
+// @Produce json
+// @Param request body remoteworker.RegisterRequest true "Worker"
+// @Success 201 {object} remoteworker.RegisterResponse
+// @Failure 400 {object} map[string]string
+// @Failure 401 {object} map[string]string
+// @Router /api/v1/workers/register [post]
+func (h *Handler) RegisterWorker(c *gin.Context) {
+	var req remoteworker.RegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Name == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "name is required"})
+		return
+	}
+	c.JSON(http.StatusCreated, h.remoteWorkers.Register(req, c.ClientIP()))
+}
+
+// WorkerHeartbeat keeps a remote worker registered
+// @Summary Remote worker heartbeat
+// @Description Keep a worker registered and report the tasks it runs. The response lists the tasks it must stop.
+// @Tags workers
+// @Accept json
+// @Produce json
+// @Param id path string true "Worker ID"
+// @Param request body remoteworker.HeartbeatRequest true "Running tasks"
+// @Success 200 {object} remoteworker.HeartbeatResponse
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/workers/{id}/heartbeat [post]
+func (h *Handler) WorkerHeartbeat(c *gin.Context) {
+	var req remoteworker.HeartbeatRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	resp, err := h.remoteWorkers.Heartbeat(c.Param("id"), req)
+	if err != nil {
+		workerError(c, err)
+		return
+	}
+	c.JSON(http.StatusOK, resp)
+}
+
+// NextWorkerTask hands a remote worker its next task
+// @Summary Take the next task
+// @Description Long-poll for the next transcription. The request is held until a task is waiting, and answers 204 when none came.
+// @Tags workers
+// @Produce json
+// @Param id path string true "Worker ID"
+// @Success 200 {object} remoteworker.Task
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Router /api/v1/workers/{id}/tasks/next [get]
+func (h *Handler) NextWorkerTask(c *gin.Context) {
+	ctx, cancel := context.WithTimeout(c.Request.Context(), remoteworker.PollTimeout)
+	defer cancel()
+	task, err := h.remoteWorkers.Next(ctx, c.Param("id"))
+	switch {
+	case err != nil:
+		workerError(c, err)
+	case task == nil:
+		c.Status(http.StatusNoContent)
+	default:
+		c.JSON(http.StatusOK, task)
+	}
+}
+
+// GetWorkerTaskAudio sends a remote worker the audio of its task
+// @Summary Download a task's audio
+// @Tags workers
+// @Produce octet-stream
+// @Param id path string true "Worker ID"
+// @Param task path string true "Task ID"
+// @Success 200 {file} binary
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Router /api/v1/workers/{id}/tasks/{task}/audio [get]
+func (h *Handler) GetWorkerTaskAudio(c *gin.Context) {
+	path, err := h.remoteWorkers.AudioPath(c.Param("id"), c.Param("task"))
+	if err != nil {
+		workerError(c, err)
+		return
+	}
+	c.File(path)
+}
+
+// ReportWorkerTaskProgress passes a remote worker's progress on to the job
+// @Summary Report task progress
+// @Description Report how far a task has got and the segments transcribed since the last report. 410 tells the worker to stop the task.
+// @Tags workers
+// @Accept json
+// @Param id path string true "Worker ID"
+// @Param task path string true "Task ID"
+// @Param request body remoteworker.ProgressRequest true "Progress"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Router /api/v1/workers/{id}/tasks/{task}/progress [post]
+func (h *Handler) ReportWorkerTaskProgress(c *gin.Context) {
+	var req remoteworker.ProgressRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.remoteWorkers.Progress(c.Param("id"), c.Param("task"), req.Updates); err != nil {
+		workerError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// CompleteWorkerTask takes the transcript a remote worker produced
+// @Summary Upload a task's transcript
+// @Tags workers
+// @Accept json
+// @Param id path string true "Worker ID"
+// @Param task path string true "Task ID"
+// @Param request body interfaces.TranscriptResult true "Transcript"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Router /api/v1/workers/{id}/tasks/{task}/result [post]
+func (h *Handler) CompleteWorkerTask(c *gin.Context) {
+	var result interfaces.TranscriptResult
+	if err := c.ShouldBindJSON(&result); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.remoteWorkers.Complete(c.Param("id"), c.Param("task"), &result); err != nil {
+		workerError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// FailWorkerTask fails the job of a task a remote worker could not run
+// @Summary Report a failed task
+// @Tags workers
+// @Accept json
+// @Param id path string true "Worker ID"
+// @Param task path string true "Task ID"
+// @Param request body remoteworker.FailRequest true "Error"
+// @Success 204
+// @Failure 404 {object} map[string]string
+// @Failure 410 {object} map[string]string
+// @Router /api/v1/workers/{id}/tasks/{task}/fail [post]
+func (h *Handler) FailWorkerTask(c *gin.Context) {
+	var req remoteworker.FailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.remoteWorkers.Fail(c.Param("id"), c.Param("task"), req.Error); err != nil {
+		workerError(c, err)
+		return
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// ListWorkers returns the registered remote workers
+// @Summary List remote workers
+// @Description List the worker machines registered with this server, their devices and the tasks they run, and how many tasks wait for a worker
+// @Tags admin
+// @Produce json
+// @Success 200 {object} WorkerListResponse
+// @Security ApiKeyAuth
+// @Security BearerAuth
+// @Router /api/v1/admin/workers [get]
+func (h *Handler) ListWorkers(c *gin.Context) {
+	resp := WorkerListResponse{Workers: []remoteworker.Worker{}}
+	if h.remoteWorkers != nil {
+		resp.Workers = h.remoteWorkers.Workers()
+		resp.WaitingTasks = h.remoteWorkers.Waiting()
+	}
+	c.JSON(http.StatusOK, resp)
+}
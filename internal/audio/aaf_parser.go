@@ -0,0 +1,413 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// AafParser handles parsing of AAF interchange files, as exported by Pro
+// Tools, Media Composer and most other editors. An AAF file is a compound
+// file holding a tree of objects, each a storage with a "properties" stream.
+// The timeline is a composition mob whose audio slots hold sequences of
+// source clips; each clip refers through a master mob to a file source mob
+// whose locator names the audio file. Every clip that reaches a file becomes
+// a TrackInfo. Audio embedded in the AAF file is not read, and neither are
+// OMF files, whose older container Pro Tools can replace by exporting AAF.
+type AafParser struct{}
+
+// NewAafParser creates a new AAF parser
+func NewAafParser() *AafParser {
+	return &AafParser{}
+}
+
+// Property IDs of the AAF object model
+const (
+	aafPidHeader          = 0x0002
+	aafPidDataDefinition  = 0x0201
+	aafPidLength          = 0x0202
+	aafPidInputSegments   = 0x0B02
+	aafPidSelected        = 0x0F01
+	aafPidComponents      = 0x1001
+	aafPidSourceID        = 0x1101
+	aafPidSourceMobSlotID = 0x1102
+	aafPidTransitionOp    = 0x1801
+	aafPidMobs            = 0x1901
+	aafPidLocators        = 0x2F01
+	aafPidContent         = 0x3B03
+	aafPidURLString       = 0x4001
+	aafPidMobID           = 0x4401
+	aafPidSlots           = 0x4403
+	aafPidEssenceDesc     = 0x4701
+	aafPidSlotID          = 0x4801
+	aafPidSlotName        = 0x4802
+	aafPidSegment         = 0x4803
+	aafPidEditRate        = 0x4B01
+	aafPidOrigin          = 0x4B02
+)
+
+// Stored forms of AAF properties that refer to other objects
+const (
+	aafStrongReference       = 0x22
+	aafStrongReferenceVector = 0x32
+	aafStrongReferenceSet    = 0x3A
+)
+
+// aafSoundDefinitions are the data definitions of audio slots: the SMPTE
+// label for sound and the one AAF used before it
+var aafSoundDefinitions = [][]byte{
+	{0x02, 0x02, 0x03, 0x01, 0x00, 0x02, 0x00, 0x00, 0x06, 0x0e, 0x2b, 0x34, 0x04, 0x01, 0x01, 0x01},
+	{0xe1, 0xeb, 0xe1, 0x78, 0xef, 0x6c, 0xd2, 0x11, 0x80, 0x7d, 0x00, 0x60, 0x08, 0x14, 0x3e, 0x6f},
+}
+
+// aafCompositionMob is the start of the class ID of composition mobs
+var aafCompositionMob = []byte{0x01, 0x01, 0x01, 0x0d, 0x01, 0x01, 0x00, 0x35}
+
+// aafObject is an object of an AAF file
+type aafObject struct {
+	file  *cfbFile
+	entry uint32
+	order binary.ByteOrder
+	props map[uint16]aafProperty
+}
+
+// aafProperty is the stored form and the value of a property
+type aafProperty struct {
+	form  uint16
+	value []byte
+}
+
+// Parse extracts the clips of the timeline of the contents of an .aaf file
+func (p *AafParser) Parse(data []byte) ([]TrackInfo, error) {
+	file, err := readCFB(data)
+	if err != nil {
+		return nil, fmt.Errorf("not an AAF file: %w", err)
+	}
+	root, err := readAAFObject(file, 0)
+	if err != nil {
+		return nil, err
+	}
+	header, err := root.ref(aafPidHeader)
+	if err != nil {
+		return nil, err
+	}
+	content, err := header.ref(aafPidContent)
+	if err != nil {
+		return nil, err
+	}
+	mobs, err := content.refs(aafPidMobs)
+	if err != nil {
+		return nil, err
+	}
+
+	r := &aafReader{mobs: make(map[string]*aafObject)}
+	var compositions []*aafObject
+	for _, mob := range mobs {
+		r.mobs[string(mob.props[aafPidMobID].value)] = mob
+		if bytes.HasPrefix(mob.class(), aafCompositionMob) {
+			compositions = append(compositions, mob)
+		}
+	}
+
+	// Compositions used inside others, like nested sequences, are not the timeline
+	used := make(map[string]bool)
+	for _, mob := range compositions {
+		r.eachClip(mob, func(clip *aafObject, _ float64, _ int, _ string) {
+			used[string(clip.props[aafPidSourceID].value)] = true
+		})
+	}
+	var tracks []TrackInfo
+	unlinked := 0
+	trackBase := 0
+	for _, mob := range compositions {
+		if used[string(mob.props[aafPidMobID].value)] {
+			continue
+		}
+		// Each slot of each timeline is a track
+		slots, _ := mob.refs(aafPidSlots)
+		r.eachClip(mob, func(clip *aafObject, offset float64, slot int, name string) {
+			path, ok := r.resolve(clip, 0)
+			if !ok {
+				unlinked++
+				return
+			}
+			tracks = append(tracks, TrackInfo{FilePath: path, Offset: offset, Gain: 1, Name: name, Track: trackBase + slot + 1})
+		})
+		trackBase += len(slots)
+	}
+	if len(tracks) == 0 && unlinked > 0 {
+		return nil, errors.New("AAF file embeds its audio; export it with linked media files")
+	}
+	return tracks, nil
+}
+
+// aafReader follows the references between the mobs of an AAF file
+type aafReader struct {
+	mobs map[string]*aafObject
+}
+
+// eachClip calls fn with every source clip on the audio slots of a mob, its
+// offset in seconds and the index and name of its slot
+func (r *aafReader) eachClip(mob *aafObject, fn func(clip *aafObject, offset float64, slotIndex int, slotName string)) {
+	slots, _ := mob.refs(aafPidSlots)
+	for i, slot := range slots {
+		rate, ok := slot.props[aafPidEditRate]
+		if !ok || len(rate.value) < 8 {
+			continue
+		}
+		num := int64(int32(slot.order.Uint32(rate.value)))
+		den := int64(int32(slot.order.Uint32(rate.value[4:])))
+		if num <= 0 || den <= 0 {
+			continue
+		}
+		origin := slot.int64Prop(aafPidOrigin)
+		segment, err := slot.ref(aafPidSegment)
+		if err != nil || !segment.isSound() {
+			continue
+		}
+		name := slot.stringProp(aafPidSlotName)
+		r.walk(segment, 0, 0, func(clip *aafObject, position int64) {
+			fn(clip, float64(position-origin)*float64(den)/float64(num), i, name)
+		})
+	}
+}
+
+// walk calls fn with the source clips of a segment and their position in
+// edit units, and returns the segment's length
+func (r *aafReader) walk(segment *aafObject, position int64, depth int, fn func(*aafObject, int64)) int64 {
+	length := segment.int64Prop(aafPidLength)
+	if depth > 16 {
+		return length
+	}
+	switch {
+	case segment.has(aafPidComponents):
+		components, _ := segment.refs(aafPidComponents)
+		at := position
+		for _, component := range components {
+			// Transitions overlap the clips on either side
+			if component.has(aafPidTransitionOp) {
+				at -= component.int64Prop(aafPidLength)
+				continue
+			}
+			at += r.walk(component, at, depth+1, fn)
+		}
+	case segment.has(aafPidSourceID):
+		fn(segment, position)
+	case segment.has(aafPidInputSegments):
+		// Effects, like clip gain, wrap the clip they apply to
+		if inputs, _ := segment.refs(aafPidInputSegments); len(inputs) > 0 {
+			r.walk(inputs[0], position, depth+1, fn)
+		}
+	case segment.has(aafPidSelected):
+		if selected, err := segment.ref(aafPidSelected); err == nil {
+			r.walk(selected, position, depth+1, fn)
+		}
+	}
+	return length
+}
+
+// resolve follows a source clip down to the file its media is in
+func (r *aafReader) resolve(clip *aafObject, depth int) (string, bool) {
+	mob, ok := r.mobs[string(clip.props[aafPidSourceID].value)]
+	if !ok || depth > 8 {
+		return "", false
+	}
+	if descriptor, err := mob.ref(aafPidEssenceDesc); err == nil {
+		locators, _ := descriptor.refs(aafPidLocators)
+		for _, locator := range locators {
+			if location := locator.stringProp(aafPidURLString); location != "" {
+				return aafLocation(location), true
+			}
+		}
+	}
+
+	// Master mobs and import sources point further down
+	slotID := clip.uint32Prop(aafPidSourceMobSlotID)
+	slots, _ := mob.refs(aafPidSlots)
+	for _, slot := range slots {
+		if slot.uint32Prop(aafPidSlotID) != slotID {
+			continue
+		}
+		segment, err := slot.ref(aafPidSegment)
+		if err != nil {
+			return "", false
+		}
+		var next *aafObject
+		r.walk(segment, 0, 0, func(found *aafObject, _ int64) {
+			if next == nil {
+				next = found
+			}
+		})
+		if next == nil {
+			return "", false
+		}
+		return r.resolve(next, depth+1)
+	}
+	return "", false
+}
+
+// aafLocation turns a locator's URL into a file path
+func aafLocation(location string) string {
+	if strings.HasPrefix(location, "file:") {
+		if u, err := url.Parse(location); err == nil && u.Path != "" {
+			return u.Path
+		}
+	}
+	return location
+}
+
+// readAAFObject reads the properties of the object stored in a storage
+func readAAFObject(file *cfbFile, entry uint32) (*aafObject, error) {
+	stream, ok := file.children(entry)["properties"]
+	if !ok {
+		return nil, fmt.Errorf("AAF object %q has no properties", file.entries[entry].name)
+	}
+	data, err := file.stream(stream)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read AAF object %q: %w", file.entries[entry].name, err)
+	}
+	if len(data) < 4 {
+		return nil, fmt.Errorf("AAF object %q is corrupt", file.entries[entry].name)
+	}
+
+	object := &aafObject{file: file, entry: entry, props: make(map[uint16]aafProperty)}
+	switch data[0] {
+	case 'L':
+		object.order = binary.LittleEndian
+	case 'B':
+		object.order = binary.BigEndian
+	default:
+		return nil, fmt.Errorf("AAF object %q has an unknown byte order", file.entries[entry].name)
+	}
+	count := int(object.order.Uint16(data[2:]))
+	index, values := 4, 4+count*6
+	if values > len(data) {
+		return nil, fmt.Errorf("AAF object %q is corrupt", file.entries[entry].name)
+	}
+	for i := 0; i < count; i++ {
+		pid := object.order.Uint16(data[index:])
+		form := object.order.Uint16(data[index+2:])
+		length := int(object.order.Uint16(data[index+4:]))
+		index += 6
+		if values+length > len(data) {
+			return nil, fmt.Errorf("AAF object %q is corrupt", file.entries[entry].name)
+		}
+		object.props[pid] = aafProperty{form: form, value: data[values : values+length]}
+		values += length
+	}
+	return object, nil
+}
+
+// class returns the class ID of the object
+func (o *aafObject) class() []byte {
+	return o.file.entries[o.entry].clsid[:]
+}
+
+// has reports whether the object has a property
+func (o *aafObject) has(pid uint16) bool {
+	_, ok := o.props[pid]
+	return ok
+}
+
+// ref returns the object a strong reference property holds
+func (o *aafObject) ref(pid uint16) (*aafObject, error) {
+	prop, ok := o.props[pid]
+	if !ok || prop.form != aafStrongReference {
+		return nil, fmt.Errorf("AAF object %q has no property %#04x", o.file.entries[o.entry].name, pid)
+	}
+	return o.child(decodeUTF16(prop.value))
+}
+
+// refs returns the objects a strong reference vector or set holds, in order
+func (o *aafObject) refs(pid uint16) ([]*aafObject, error) {
+	prop, ok := o.props[pid]
+	if !ok || (prop.form != aafStrongReferenceVector && prop.form != aafStrongReferenceSet) {
+		return nil, nil
+	}
+	name := decodeUTF16(prop.value)
+	indexEntry, ok := o.file.children(o.entry)[name+" index"]
+	if !ok {
+		return nil, fmt.Errorf("AAF collection %q has no index", name)
+	}
+	index, err := o.file.stream(indexEntry)
+	if err != nil || len(index) < 12 {
+		return nil, fmt.Errorf("AAF collection %q has a corrupt index", name)
+	}
+
+	// Vector entries are local keys; set entries add a reference count and
+	// the key of the element
+	count := int(o.order.Uint32(index))
+	pos, step := 12, 4
+	if prop.form == aafStrongReferenceSet {
+		if len(index) < 15 {
+			return nil, fmt.Errorf("AAF collection %q has a corrupt index", name)
+		}
+		pos, step = 15, 8+int(index[14])
+	}
+	objects := make([]*aafObject, 0, count)
+	for i := 0; i < count; i++ {
+		if pos+4 > len(index) {
+			return nil, fmt.Errorf("AAF collection %q has a corrupt index", name)
+		}
+		key := o.order.Uint32(index[pos:])
+		pos += step
+		object, err := o.child(fmt.Sprintf("%s{%x}", name, key))
+		if err != nil {
+			return nil, err
+		}
+		objects = append(objects, object)
+	}
+	return objects, nil
+}
+
+// child reads the object in a storage of this object's storage
+func (o *aafObject) child(name string) (*aafObject, error) {
+	entry, ok := o.file.children(o.entry)[name]
+	if !ok || o.file.entries[entry].kind != cfbStorage {
+		return nil, fmt.Errorf("AAF object %q is missing", name)
+	}
+	return readAAFObject(o.file, entry)
+}
+
+// int64 returns an integer property, or 0
+func (o *aafObject) int64Prop(pid uint16) int64 {
+	if prop, ok := o.props[pid]; ok && len(prop.value) >= 8 {
+		return int64(o.order.Uint64(prop.value))
+	}
+	return 0
+}
+
+// uint32 returns an integer property, or 0
+func (o *aafObject) uint32Prop(pid uint16) uint32 {
+	if prop, ok := o.props[pid]; ok && len(prop.value) >= 4 {
+		return o.order.Uint32(prop.value)
+	}
+	return 0
+}
+
+// string returns a string property, or ""
+func (o *aafObject) stringProp(pid uint16) string {
+	if prop, ok := o.props[pid]; ok {
+		return decodeUTF16(prop.value)
+	}
+	return ""
+}
+
+// isSound reports whether a segment carries audio. Segments whose data
+// definition cannot be read are assumed to.
+func (o *aafObject) isSound() bool {
+	prop, ok := o.props[aafPidDataDefinition]
+	if !ok || len(prop.value) < 16 {
+		return true
+	}
+	def := prop.value[len(prop.value)-16:]
+	for _, sound := range aafSoundDefinitions {
+		if bytes.Equal(def, sound) {
+			return true
+		}
+	}
+	return false
+}
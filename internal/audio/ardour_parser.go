@@ -0,0 +1,260 @@
+package audio
+
+import (
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// ArdourParser handles parsing of Ardour session files. A .ardour file is
+// XML listing the session's sources, its routes and the playlists holding
+// each track's regions:
+//
+//	<Session sample-rate="48000">
+//	  <Sources>
+//	    <Source id="10" name="Host-1.wav" type="audio"/>
+//	  </Sources>
+//	  <Routes>
+//	    <Route id="20" name="Host" audio-playlist="30">
+//	      <Controllable name="mute" value="0"/>
+//	      <Processor type="amp"><Controllable name="gaincontrol" value="0.5"/></Processor>
+//	      <Pannable><Controllable name="pan-azimuth" value="0.5"/></Pannable>
+//	    </Route>
+//	  </Routes>
+//	  <Playlists>
+//	    <Playlist id="30" name="Host" type="audio">
+//	      <Region position="96000" muted="0" scale-amplitude="1" source-0="10"/>
+//	    </Playlist>
+//	  </Playlists>
+//	</Session>
+//
+// Every channel of every region becomes a TrackInfo, its gain and mute
+// combined with those of its track. Positions are in samples, or in
+// superclock ticks prefixed with "a" since Ardour 7.
+type ArdourParser struct{}
+
+// NewArdourParser creates a new Ardour session parser
+func NewArdourParser() *ArdourParser {
+	return &ArdourParser{}
+}
+
+// ardourSuperclockRate is Ardour's default number of superclock ticks per second
+const ardourSuperclockRate = 282240000
+
+type ardourSession struct {
+	XMLName    xml.Name         `xml:"Session"`
+	SampleRate string           `xml:"sample-rate,attr"`
+	Superclock string           `xml:"superclock-ticks-per-second,attr"`
+	Sources    []ardourSource   `xml:"Sources>Source"`
+	Routes     []ardourRoute    `xml:"Routes>Route"`
+	Playlists  []ardourPlaylist `xml:"Playlists>Playlist"`
+}
+
+type ardourSource struct {
+	ID   string `xml:"id,attr"`
+	Name string `xml:"name,attr"`
+}
+
+type ardourControllable struct {
+	Name  string `xml:"name,attr"`
+	Value string `xml:"value,attr"`
+}
+
+type ardourRoute struct {
+	ID            string               `xml:"id,attr"`
+	Name          string               `xml:"name,attr"`
+	AudioPlaylist string               `xml:"audio-playlist,attr"`
+	Controllables []ardourControllable `xml:"Controllable"`
+	Processors    []struct {
+		Type          string               `xml:"type,attr"`
+		Controllables []ardourControllable `xml:"Controllable"`
+	} `xml:"Processor"`
+	Pannable struct {
+		Controllables []ardourControllable `xml:"Controllable"`
+	} `xml:"Pannable"`
+	Diskstream struct {
+		Playlist string `xml:"playlist,attr"`
+	} `xml:"Diskstream"` // Sessions before Ardour 6
+}
+
+type ardourPlaylist struct {
+	ID          string         `xml:"id,attr"`
+	Name        string         `xml:"name,attr"`
+	Type        string         `xml:"type,attr"`
+	OrigTrackID string         `xml:"orig-track-id,attr"`
+	Regions     []ardourRegion `xml:"Region"`
+}
+
+type ardourRegion struct {
+	Position       string     `xml:"position,attr"`
+	Start          string     `xml:"start,attr"`
+	Length         string     `xml:"length,attr"`
+	Muted          string     `xml:"muted,attr"`
+	ScaleAmplitude string     `xml:"scale-amplitude,attr"`
+	Attrs          []xml.Attr `xml:",any,attr"`
+}
+
+// Parse extracts the regions of the contents of a .ardour file
+func (p *ArdourParser) Parse(data []byte) ([]TrackInfo, error) {
+	var session ardourSession
+	if err := xml.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("failed to parse Ardour session XML: %w", err)
+	}
+	if session.XMLName.Local != "Session" {
+		return nil, errors.New("not an Ardour session")
+	}
+	sampleRate, err := strconv.ParseFloat(session.SampleRate, 64)
+	if err != nil || sampleRate <= 0 {
+		return nil, errors.New("Ardour session has no sample rate")
+	}
+	superclock, err := strconv.ParseFloat(session.Superclock, 64)
+	if err != nil || superclock <= 0 {
+		superclock = ardourSuperclockRate
+	}
+
+	sources := make(map[string]string)
+	for _, source := range session.Sources {
+		sources[source.ID] = source.Name
+	}
+
+	var tracks []TrackInfo
+	for index, route := range session.Routes {
+		playlist := route.playlist(session.Playlists)
+		if playlist == nil {
+			continue // Buses and the master have no playlist
+		}
+		gain, pan, mute, solo := route.mix()
+		for _, region := range playlist.Regions {
+			offset, ok := ardourPosition(region.Position, sampleRate, superclock)
+			if !ok {
+				continue // Regions placed in music time follow the tempo map
+			}
+			// The part of the sources the region plays is in the same units
+			start, _ := ardourPosition(region.Start, sampleRate, superclock)
+			length, _ := ardourPosition(region.Length, sampleRate, superclock)
+			regionGain := ardourFloat(region.ScaleAmplitude, 1)
+			for _, id := range region.sources() {
+				name, ok := sources[id]
+				if !ok {
+					return nil, fmt.Errorf("Ardour session references unknown source %s", id)
+				}
+				tracks = append(tracks, TrackInfo{
+					FilePath: name,
+					Offset:   offset,
+					Gain:     gain * regionGain,
+					Pan:      pan,
+					Mute:     mute || region.Muted == "1",
+					Solo:     solo,
+					Name:     route.Name,
+					Track:    index + 1,
+					Start:    start,
+					Duration: length,
+				})
+			}
+		}
+	}
+	return tracks, nil
+}
+
+// playlist returns the audio playlist the route plays
+func (r *ardourRoute) playlist(playlists []ardourPlaylist) *ardourPlaylist {
+	for i := range playlists {
+		playlist := &playlists[i]
+		if playlist.Type != "" && playlist.Type != "audio" {
+			continue
+		}
+		switch {
+		case r.AudioPlaylist != "" && playlist.ID == r.AudioPlaylist:
+			return playlist
+		case r.AudioPlaylist == "" && r.Diskstream.Playlist != "" && playlist.Name == r.Diskstream.Playlist:
+			return playlist
+		}
+	}
+	if r.AudioPlaylist != "" || r.Diskstream.Playlist != "" {
+		return nil
+	}
+	for i := range playlists {
+		if playlists[i].OrigTrackID == r.ID && (playlists[i].Type == "" || playlists[i].Type == "audio") {
+			return &playlists[i]
+		}
+	}
+	return nil
+}
+
+// mix returns the fader gain, the pan between -1 and 1, the mute and the solo
+// of the route
+func (r *ardourRoute) mix() (gain, pan float64, mute, solo bool) {
+	gain = 1
+	for _, processor := range r.Processors {
+		if processor.Type != "amp" {
+			continue
+		}
+		for _, control := range processor.Controllables {
+			if control.Name == "gaincontrol" {
+				gain = ardourFloat(control.Value, 1)
+			}
+		}
+	}
+	for _, control := range r.Pannable.Controllables {
+		if control.Name == "pan-azimuth" {
+			// The azimuth runs from 0 on the left to 1 on the right
+			pan = math.Max(-1, math.Min(1, ardourFloat(control.Value, 0.5)*2-1))
+		}
+	}
+	for _, control := range r.Controllables {
+		switch control.Name {
+		case "mute":
+			mute = ardourFloat(control.Value, 0) != 0
+		case "solo":
+			solo = ardourFloat(control.Value, 0) != 0
+		}
+	}
+	return gain, pan, mute, solo
+}
+
+// sources returns the IDs of the sources of each channel of the region
+func (r *ardourRegion) sources() []string {
+	byChannel := make(map[int]string)
+	for _, attr := range r.Attrs {
+		if channel, ok := strings.CutPrefix(attr.Name.Local, "source-"); ok {
+			if n, err := strconv.Atoi(channel); err == nil {
+				byChannel[n] = attr.Value
+			}
+		}
+	}
+	ids := make([]string, 0, len(byChannel))
+	for n := 0; n < len(byChannel); n++ {
+		id, ok := byChannel[n]
+		if !ok {
+			break
+		}
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+// ardourPosition converts a region position to seconds. Positions in beats
+// cannot be converted without the tempo map.
+func ardourPosition(value string, sampleRate, superclock float64) (float64, bool) {
+	switch {
+	case strings.HasPrefix(value, "a"):
+		ticks, err := strconv.ParseInt(value[1:], 10, 64)
+		return float64(ticks) / superclock, err == nil
+	case strings.HasPrefix(value, "b"):
+		return 0, false
+	}
+	samples, err := strconv.ParseInt(value, 10, 64)
+	return float64(samples) / sampleRate, err == nil
+}
+
+// ardourFloat parses a value, or returns def
+func ardourFloat(value string, def float64) float64 {
+	parsed, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return def
+	}
+	return parsed
+}
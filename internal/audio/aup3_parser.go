@@ -0,0 +1,598 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode/utf16"
+
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// Aup3Parser handles Audacity 3 projects. An .aup3 file is an SQLite
+// database: the project table holds the project document, an XML tree in
+// Audacity's binary serialization, and the sampleblocks table the audio its
+// wave blocks refer to. Every clip becomes a track of its own, named after
+// its Audacity track; the two channels of a stereo track are mixed into one.
+type Aup3Parser struct{}
+
+// NewAup3Parser creates a new Audacity 3 project parser
+func NewAup3Parser() *Aup3Parser {
+	return &Aup3Parser{}
+}
+
+// Sample formats of Audacity sample blocks
+const (
+	aup3Int16Sample = 0x00020001
+	aup3Int24Sample = 0x00040001
+	aup3FloatSample = 0x0004000F
+)
+
+// aup3Element is an element of the project document
+type aup3Element struct {
+	name     string
+	attrs    map[string]string
+	children []*aup3Element
+}
+
+// aup3Block is a wave block of a clip: samples stored in the sampleblocks
+// table, or silence of -id samples when id is negative
+type aup3Block struct {
+	start int64
+	id    int64
+}
+
+// aup3Clip is a clip of a track with the blocks holding its audio
+type aup3Clip struct {
+	offset    float64 // Where the audible part of the clip starts, in seconds
+	skip      int64   // Samples hidden at the start of the clip
+	samples   int64   // Samples audible
+	rate      float64
+	channels  [][]aup3Block // Blocks of each channel; one entry for mono tracks
+	trackInfo TrackInfo
+}
+
+// ParseFile reads the clips of the .aup3 project at path
+func (p *Aup3Parser) ParseFile(path string) ([]TrackInfo, error) {
+	db, err := openAup3(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAup3(db)
+
+	clips, err := readAup3Clips(db)
+	if err != nil {
+		return nil, err
+	}
+	tracks := make([]TrackInfo, len(clips))
+	for i, clip := range clips {
+		tracks[i] = clip.trackInfo
+	}
+	return tracks, nil
+}
+
+// Extract writes each clip of the .aup3 project at path to dir as a 16-bit
+// mono WAV file at the rate of its track
+func (p *Aup3Parser) Extract(path, dir string) ([]TrackInfo, error) {
+	db, err := openAup3(path)
+	if err != nil {
+		return nil, err
+	}
+	defer closeAup3(db)
+
+	clips, err := readAup3Clips(db)
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create track directory: %w", err)
+	}
+	tracks := make([]TrackInfo, 0, len(clips))
+	for _, clip := range clips {
+		dest := filepath.Join(dir, clip.trackInfo.FilePath)
+		if err := clip.write(db, dest); err != nil {
+			for _, done := range tracks {
+				os.Remove(filepath.Join(dir, done.FilePath))
+			}
+			return nil, fmt.Errorf("failed to extract %s: %w", clip.trackInfo.FilePath, err)
+		}
+		tracks = append(tracks, clip.trackInfo)
+	}
+	return tracks, nil
+}
+
+// openAup3 opens an .aup3 project read-only
+func openAup3(path string) (*gorm.DB, error) {
+	if _, err := os.Stat(path); err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+	db, err := gorm.Open(sqlite.Open("file:"+filepath.ToSlash(path)+"?mode=ro"), &gorm.Config{
+		Logger: gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open Audacity 3 project: %w", err)
+	}
+	return db, nil
+}
+
+func closeAup3(db *gorm.DB) {
+	if sqlDB, err := db.DB(); err == nil {
+		sqlDB.Close()
+	}
+}
+
+// readAup3Clips decodes the project document and lists its clips
+func readAup3Clips(db *gorm.DB) ([]*aup3Clip, error) {
+	var row struct {
+		Dict []byte
+		Doc  []byte
+	}
+	if err := db.Raw("SELECT dict, doc FROM project WHERE id = 1").Scan(&row).Error; err != nil {
+		return nil, errors.New("not an Audacity 3 project")
+	}
+	if len(row.Doc) == 0 {
+		return nil, errors.New("Audacity 3 project has no document")
+	}
+	root, err := decodeAup3Doc(append(append([]byte{}, row.Dict...), row.Doc...))
+	if err != nil {
+		return nil, err
+	}
+	if root.name != "project" {
+		return nil, errors.New("not an Audacity 3 project")
+	}
+
+	var clips []*aup3Clip
+	names := make(map[string]int)
+	trackIndex := 0
+	for i := 0; i < len(root.children); i++ {
+		track := root.children[i]
+		if track.name != "wavetrack" {
+			continue
+		}
+		trackIndex++
+		// The right channel of a stereo track follows its left channel
+		var partner *aup3Element
+		if track.attrs["linked"] == "1" && i+1 < len(root.children) && root.children[i+1].name == "wavetrack" {
+			partner = root.children[i+1]
+			i++
+		}
+
+		trackClips := aup3TrackClips(track)
+		if partner != nil {
+			partnerClips := aup3TrackClips(partner)
+			if len(partnerClips) == len(trackClips) {
+				for j, clip := range trackClips {
+					if clip.samples == partnerClips[j].samples {
+						clip.channels = append(clip.channels, partnerClips[j].channels...)
+					}
+				}
+			}
+		}
+
+		name := strings.TrimSpace(track.attrs["name"])
+		if name == "" {
+			name = "Track"
+		}
+		for _, clip := range trackClips {
+			clip.trackInfo = TrackInfo{
+				FilePath: aup3FileName(name, names),
+				Offset:   clip.offset,
+				Gain:     aup3Float(track.attrs, "gain", 1),
+				Pan:      aup3Float(track.attrs, "pan", 0),
+				Mute:     track.attrs["mute"] == "1",
+				Solo:     track.attrs["solo"] == "1",
+				Track:    trackIndex,
+				Name:     name,
+			}
+			clips = append(clips, clip)
+		}
+	}
+	return clips, nil
+}
+
+// aup3TrackClips reads the clips of a wavetrack element
+func aup3TrackClips(track *aup3Element) []*aup3Clip {
+	rate := aup3Float(track.attrs, "rate", 44100)
+	var clips []*aup3Clip
+	for _, element := range track.children {
+		if element.name != "waveclip" {
+			continue
+		}
+		var sequence *aup3Element
+		for _, child := range element.children {
+			if child.name == "sequence" {
+				sequence = child
+				break
+			}
+		}
+		if sequence == nil {
+			continue
+		}
+
+		var blocks []aup3Block
+		for _, child := range sequence.children {
+			if child.name != "waveblock" {
+				continue
+			}
+			start, _ := strconv.ParseInt(child.attrs["start"], 10, 64)
+			id, err := strconv.ParseInt(child.attrs["blockid"], 10, 64)
+			if err != nil {
+				continue
+			}
+			blocks = append(blocks, aup3Block{start: start, id: id})
+		}
+		sort.Slice(blocks, func(i, j int) bool { return blocks[i].start < blocks[j].start })
+
+		// Trimmed audio stays in the project but is not heard
+		total, _ := strconv.ParseInt(sequence.attrs["numsamples"], 10, 64)
+		skip := int64(math.Round(aup3Float(element.attrs, "trimLeft", 0) * rate))
+		cut := int64(math.Round(aup3Float(element.attrs, "trimRight", 0) * rate))
+		samples := total - skip - cut
+		if samples <= 0 {
+			continue
+		}
+		clips = append(clips, &aup3Clip{
+			offset:   aup3Float(element.attrs, "offset", 0) + aup3Float(element.attrs, "trimLeft", 0),
+			skip:     skip,
+			samples:  samples,
+			rate:     rate,
+			channels: [][]aup3Block{blocks},
+		})
+	}
+	return clips
+}
+
+// aup3FileName names the file of a clip after its track, numbering the
+// clips after the first that share a name
+func aup3FileName(track string, names map[string]int) string {
+	safe := strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`/\:*?"<>|`, r) || r < 32 {
+			return '_'
+		}
+		return r
+	}, track)
+	names[safe]++
+	if n := names[safe]; n > 1 {
+		return fmt.Sprintf("%s (%d).wav", safe, n)
+	}
+	return safe + ".wav"
+}
+
+// aup3Float parses a numeric attribute, or returns def
+func aup3Float(attrs map[string]string, name string, def float64) float64 {
+	value, err := strconv.ParseFloat(attrs[name], 64)
+	if err != nil {
+		return def
+	}
+	return value
+}
+
+// write stores the audible samples of the clip in a WAV file, averaging the
+// channels of stereo clips
+func (c *aup3Clip) write(db *gorm.DB, dest string) error {
+	f, err := os.Create(dest)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	format := wavFormat{channels: 1, sampleRate: uint32(math.Round(c.rate)), bits: 16}
+	if err := writeWAVHeader(w, format, c.samples*2); err != nil {
+		f.Close()
+		os.Remove(dest)
+		return err
+	}
+
+	readers := make([]*aup3SampleReader, len(c.channels))
+	for i, blocks := range c.channels {
+		readers[i] = &aup3SampleReader{db: db, blocks: blocks}
+		if err := readers[i].discard(c.skip); err != nil {
+			f.Close()
+			os.Remove(dest)
+			return err
+		}
+	}
+	buf := make([]byte, 2)
+	for n := int64(0); n < c.samples; n++ {
+		sum := 0.0
+		for _, r := range readers {
+			sample, err := r.next()
+			if err != nil {
+				f.Close()
+				os.Remove(dest)
+				return err
+			}
+			sum += sample
+		}
+		value := math.Max(-1, math.Min(1, sum/float64(len(readers))))
+		binary.LittleEndian.PutUint16(buf, uint16(int16(math.Round(value*32767))))
+		w.Write(buf)
+	}
+	if err := w.Flush(); err != nil {
+		f.Close()
+		os.Remove(dest)
+		return err
+	}
+	return f.Close()
+}
+
+// aup3SampleReader reads the samples of a channel one block at a time
+type aup3SampleReader struct {
+	db      *gorm.DB
+	blocks  []aup3Block
+	current []float64
+	pos     int
+}
+
+// next returns the next sample, between -1 and 1. Samples past the last
+// block are silent.
+func (r *aup3SampleReader) next() (float64, error) {
+	for r.pos >= len(r.current) {
+		if len(r.blocks) == 0 {
+			return 0, nil
+		}
+		samples, err := r.load(r.blocks[0])
+		if err != nil {
+			return 0, err
+		}
+		r.blocks = r.blocks[1:]
+		r.current, r.pos = samples, 0
+	}
+	sample := r.current[r.pos]
+	r.pos++
+	return sample, nil
+}
+
+// discard skips n samples
+func (r *aup3SampleReader) discard(n int64) error {
+	for ; n > 0; n-- {
+		if _, err := r.next(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// load reads the samples of a block
+func (r *aup3SampleReader) load(block aup3Block) ([]float64, error) {
+	if block.id < 0 {
+		return make([]float64, -block.id), nil
+	}
+	var row struct {
+		SampleFormat int64 `gorm:"column:sampleformat"`
+		Samples      []byte
+	}
+	result := r.db.Raw("SELECT sampleformat, samples FROM sampleblocks WHERE blockid = ?", block.id).Scan(&row)
+	if result.Error != nil {
+		return nil, fmt.Errorf("failed to read sample block %d: %w", block.id, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return nil, fmt.Errorf("sample block %d is missing", block.id)
+	}
+
+	switch row.SampleFormat {
+	case aup3Int16Sample:
+		samples := make([]float64, len(row.Samples)/2)
+		for i := range samples {
+			samples[i] = float64(int16(binary.LittleEndian.Uint16(row.Samples[i*2:]))) / 32768
+		}
+		return samples, nil
+	case aup3Int24Sample:
+		// 24-bit samples are stored in 32-bit integers
+		samples := make([]float64, len(row.Samples)/4)
+		for i := range samples {
+			samples[i] = float64(int32(binary.LittleEndian.Uint32(row.Samples[i*4:]))) / (1 << 23)
+		}
+		return samples, nil
+	case aup3FloatSample:
+		samples := make([]float64, len(row.Samples)/4)
+		for i := range samples {
+			samples[i] = float64(math.Float32frombits(binary.LittleEndian.Uint32(row.Samples[i*4:])))
+		}
+		return samples, nil
+	}
+	return nil, fmt.Errorf("sample block %d has unknown sample format %#x", block.id, row.SampleFormat)
+}
+
+// Field types of Audacity's binary project serialization
+const (
+	aup3CharSize = iota
+	aup3StartTag
+	aup3EndTag
+	aup3String
+	aup3Int
+	aup3Bool
+	aup3Long
+	aup3LongLong
+	aup3SizeT
+	aup3FloatField
+	aup3Double
+	aup3Data
+	aup3Raw
+	aup3Push
+	aup3Pop
+	aup3Name
+)
+
+// decodeAup3Doc rebuilds the element tree of a project from its serialized
+// dictionary and document. The dictionary declares the names of elements and
+// attributes, which the document refers to by number.
+func decodeAup3Doc(data []byte) (*aup3Element, error) {
+	r := bytes.NewReader(data)
+	names := make(map[uint16]string)
+	charSize := 1
+	var root *aup3Element
+	var stack []*aup3Element
+
+	readName := func() (string, error) {
+		var id uint16
+		if err := binary.Read(r, binary.LittleEndian, &id); err != nil {
+			return "", err
+		}
+		name, ok := names[id]
+		if !ok {
+			return "", fmt.Errorf("undeclared name %d", id)
+		}
+		return name, nil
+	}
+	readBytes := func(n int64) ([]byte, error) {
+		if n < 0 || n > int64(r.Len()) {
+			return nil, io.ErrUnexpectedEOF
+		}
+		buf := make([]byte, n)
+		_, err := io.ReadFull(r, buf)
+		return buf, err
+	}
+	setAttr := func(name, value string) {
+		if len(stack) > 0 {
+			stack[len(stack)-1].attrs[name] = value
+		}
+	}
+
+	for r.Len() > 0 {
+		kind, _ := r.ReadByte()
+		var err error
+		switch kind {
+		case aup3CharSize:
+			var size byte
+			if size, err = r.ReadByte(); err == nil {
+				charSize = int(size)
+			}
+		case aup3StartTag:
+			var name string
+			if name, err = readName(); err == nil {
+				element := &aup3Element{name: name, attrs: make(map[string]string)}
+				if len(stack) > 0 {
+					parent := stack[len(stack)-1]
+					parent.children = append(parent.children, element)
+				} else if root == nil {
+					root = element
+				}
+				stack = append(stack, element)
+			}
+		case aup3EndTag:
+			if _, err = readName(); err == nil && len(stack) > 0 {
+				stack = stack[:len(stack)-1]
+			}
+		case aup3String:
+			var name string
+			var length int32
+			var raw []byte
+			if name, err = readName(); err == nil {
+				if err = binary.Read(r, binary.LittleEndian, &length); err == nil {
+					if raw, err = readBytes(int64(length)); err == nil {
+						setAttr(name, decodeAup3String(raw, charSize))
+					}
+				}
+			}
+		case aup3Int, aup3Long:
+			var name string
+			var value int32
+			if name, err = readName(); err == nil {
+				if err = binary.Read(r, binary.LittleEndian, &value); err == nil {
+					setAttr(name, strconv.FormatInt(int64(value), 10))
+				}
+			}
+		case aup3Bool:
+			var name string
+			var value byte
+			if name, err = readName(); err == nil {
+				if value, err = r.ReadByte(); err == nil {
+					setAttr(name, strconv.Itoa(int(value)))
+				}
+			}
+		case aup3LongLong:
+			var name string
+			var value int64
+			if name, err = readName(); err == nil {
+				if err = binary.Read(r, binary.LittleEndian, &value); err == nil {
+					setAttr(name, strconv.FormatInt(value, 10))
+				}
+			}
+		case aup3SizeT:
+			var name string
+			var value uint32
+			if name, err = readName(); err == nil {
+				if err = binary.Read(r, binary.LittleEndian, &value); err == nil {
+					setAttr(name, strconv.FormatUint(uint64(value), 10))
+				}
+			}
+		case aup3FloatField:
+			var name string
+			var value float32
+			var digits int32
+			if name, err = readName(); err == nil {
+				if err = binary.Read(r, binary.LittleEndian, &value); err == nil {
+					if err = binary.Read(r, binary.LittleEndian, &digits); err == nil {
+						setAttr(name, strconv.FormatFloat(float64(value), 'f', -1, 32))
+					}
+				}
+			}
+		case aup3Double:
+			var name string
+			var value float64
+			var digits int32
+			if name, err = readName(); err == nil {
+				if err = binary.Read(r, binary.LittleEndian, &value); err == nil {
+					if err = binary.Read(r, binary.LittleEndian, &digits); err == nil {
+						setAttr(name, strconv.FormatFloat(value, 'f', -1, 64))
+					}
+				}
+			}
+		case aup3Data, aup3Raw:
+			var length int32
+			if err = binary.Read(r, binary.LittleEndian, &length); err == nil {
+				_, err = readBytes(int64(length))
+			}
+		case aup3Push, aup3Pop:
+		case aup3Name:
+			var id, length uint16
+			var raw []byte
+			if err = binary.Read(r, binary.LittleEndian, &id); err == nil {
+				if err = binary.Read(r, binary.LittleEndian, &length); err == nil {
+					if raw, err = readBytes(int64(length)); err == nil {
+						names[id] = decodeAup3String(raw, charSize)
+					}
+				}
+			}
+		default:
+			return nil, fmt.Errorf("Audacity 3 project document has unknown field type %d", kind)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Audacity 3 project document is corrupt: %w", err)
+		}
+	}
+	if root == nil {
+		return nil, errors.New("Audacity 3 project document is empty")
+	}
+	return root, nil
+}
+
+// decodeAup3String decodes a string stored with characters of charSize bytes
+func decodeAup3String(raw []byte, charSize int) string {
+	switch charSize {
+	case 2:
+		units := make([]uint16, len(raw)/2)
+		for i := range units {
+			units[i] = binary.LittleEndian.Uint16(raw[i*2:])
+		}
+		return string(utf16.Decode(units))
+	case 4:
+		runes := make([]rune, len(raw)/4)
+		for i := range runes {
+			runes[i] = rune(binary.LittleEndian.Uint32(raw[i*4:]))
+		}
+		return string(runes)
+	}
+	return string(raw)
+}
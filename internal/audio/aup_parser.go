@@ -10,13 +10,16 @@ import (
 
 // AupTrack represents a track imported in the Audacity project
 type AupTrack struct {
-	Filename string  `xml:"filename,attr"`
-	Offset   float64 // Parsed from offset attribute (in seconds)
-	Channel  int     `xml:"channel,attr"`
-	Mute     int     `xml:"mute,attr"`
-	Solo     int     `xml:"solo,attr"`
-	Gain     float64 // Parsed from gain attribute
-	Pan      float64 // Parsed from pan attribute
+	Filename string          `xml:"filename,attr"`
+	Offset   float64         // Parsed from offset attribute (in seconds)
+	Channel  int             `xml:"channel,attr"`
+	Mute     int             `xml:"mute,attr"`
+	Solo     int             `xml:"solo,attr"`
+	Gain     float64         // Parsed from gain attribute
+	Pan      float64         // Parsed from pan attribute
+	Name     string          // Name of the wavetrack
+	Envelope []EnvelopePoint // Volume automation of the clip, from its start
+	Track    int             // Index of the wavetrack, from 1
 }
 
 // AupWaveTrack represents a wavetrack element in the AUP file
@@ -43,6 +46,26 @@ type WaveClip struct {
 		Offset   string `xml:"offset,attr"`
 		Channel  int    `xml:"channel,attr"`
 	} `xml:"import"`
+	Envelope struct {
+		ControlPoints []struct {
+			T   string `xml:"t,attr"`
+			Val string `xml:"val,attr"`
+		} `xml:"controlpoint"`
+	} `xml:"envelope"`
+}
+
+// envelope returns the clip's volume automation, its times from the start of the clip
+func (clip *WaveClip) envelope() []EnvelopePoint {
+	var points []EnvelopePoint
+	for _, point := range clip.Envelope.ControlPoints {
+		t, errT := strconv.ParseFloat(point.T, 64)
+		val, errVal := strconv.ParseFloat(point.Val, 64)
+		if errT != nil || errVal != nil {
+			continue
+		}
+		points = append(points, EnvelopePoint{Time: t, Value: val})
+	}
+	return points
 }
 
 // AudacityProject represents the root structure of an AUP file
@@ -69,7 +92,11 @@ func (p *AupParser) ParseAupFile(filepath string) ([]AupTrack, error) {
 	if err != nil {
 		return nil, fmt.Errorf("failed to read AUP file: %w", err)
 	}
+	return p.ParseAup(data)
+}
 
+// ParseAup extracts track information from the contents of an .aup file
+func (p *AupParser) ParseAup(data []byte) ([]AupTrack, error) {
 	// Parse XML
 	var project AudacityProject
 	if err := xml.Unmarshal(data, &project); err != nil {
@@ -78,7 +105,7 @@ func (p *AupParser) ParseAupFile(filepath string) ([]AupTrack, error) {
 
 	// Extract tracks
 	var tracks []AupTrack
-	for _, waveTrack := range project.WaveTracks {
+	for i, waveTrack := range project.WaveTracks {
 		// Parse gain and pan from string to float64
 		gain, _ := strconv.ParseFloat(waveTrack.Gain, 64)
 		pan, _ := strconv.ParseFloat(waveTrack.Pan, 64)
@@ -97,6 +124,9 @@ func (p *AupParser) ParseAupFile(filepath string) ([]AupTrack, error) {
 					Solo:     waveTrack.Solo,
 					Gain:     gain,
 					Pan:      pan,
+					Name:     waveTrack.Name,
+					Envelope: clip.envelope(),
+					Track:    i + 1,
 				}
 				tracks = append(tracks, track)
 			}
@@ -106,6 +136,29 @@ func (p *AupParser) ParseAupFile(filepath string) ([]AupTrack, error) {
 	return tracks, nil
 }
 
+// Parse extracts the clips of the contents of an .aup file, implementing ProjectParser
+func (p *AupParser) Parse(data []byte) ([]TrackInfo, error) {
+	aupTracks, err := p.ParseAup(data)
+	if err != nil {
+		return nil, err
+	}
+	tracks := make([]TrackInfo, len(aupTracks))
+	for i, track := range aupTracks {
+		tracks[i] = TrackInfo{
+			FilePath: track.Filename,
+			Offset:   track.Offset,
+			Gain:     track.Gain,
+			Pan:      track.Pan,
+			Mute:     track.Mute == 1,
+			Solo:     track.Solo == 1,
+			Name:     track.Name,
+			Envelope: track.Envelope,
+			Track:    track.Track,
+		}
+	}
+	return tracks, nil
+}
+
 // ValidateTracksExist checks if all referenced tracks exist in the given directory
 func (p *AupParser) ValidateTracksExist(tracks []AupTrack, tracksDir string) error {
 	for _, track := range tracks {
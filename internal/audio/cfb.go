@@ -0,0 +1,223 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"unicode/utf16"
+)
+
+// cfbSignature starts every Compound File Binary file, the container of AAF
+// and of older Microsoft Office documents
+var cfbSignature = []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1}
+
+// Special sector numbers of compound files
+const (
+	cfbEndOfChain = 0xFFFFFFFE
+	cfbNoStream   = 0xFFFFFFFF
+)
+
+// Directory entry types of compound files
+const (
+	cfbStorage = 1
+	cfbStream  = 2
+	cfbRoot    = 5
+)
+
+// cfbEntry is a storage or a stream of a compound file
+type cfbEntry struct {
+	name  string
+	kind  byte
+	left  uint32
+	right uint32
+	child uint32
+	clsid [16]byte
+	start uint32
+	size  uint64
+}
+
+// cfbFile reads a Compound File Binary file: a small filesystem of storages,
+// which are folders, and streams kept in the sectors of one file
+type cfbFile struct {
+	data       []byte
+	sectorSize int
+	fat        []uint32
+	miniFAT    []uint32
+	miniStream []byte
+	cutoff     uint64
+	entries    []cfbEntry
+}
+
+// readCFB reads the directory of a compound file
+func readCFB(data []byte) (*cfbFile, error) {
+	if len(data) < 512 || !bytes.Equal(data[:8], cfbSignature) {
+		return nil, errors.New("not a compound file")
+	}
+	shift := binary.LittleEndian.Uint16(data[0x1E:])
+	if shift != 9 && shift != 12 {
+		return nil, errors.New("compound file has an invalid sector size")
+	}
+	f := &cfbFile{
+		data:       data,
+		sectorSize: 1 << shift,
+		cutoff:     uint64(binary.LittleEndian.Uint32(data[0x38:])),
+	}
+
+	// The first 109 FAT sectors are listed in the header, the others in a
+	// chain of DIFAT sectors
+	fatSectors := int(binary.LittleEndian.Uint32(data[0x2C:]))
+	var difat []uint32
+	for i := 0; i < 109 && len(difat) < fatSectors; i++ {
+		difat = append(difat, binary.LittleEndian.Uint32(data[0x4C+i*4:]))
+	}
+	perSector := f.sectorSize/4 - 1
+	for next, n := binary.LittleEndian.Uint32(data[0x44:]), 0; len(difat) < fatSectors && next < cfbEndOfChain; n++ {
+		sector, err := f.sector(next)
+		if err != nil || n > len(data)/f.sectorSize {
+			return nil, errors.New("compound file has a corrupt DIFAT")
+		}
+		for i := 0; i < perSector && len(difat) < fatSectors; i++ {
+			difat = append(difat, binary.LittleEndian.Uint32(sector[i*4:]))
+		}
+		next = binary.LittleEndian.Uint32(sector[perSector*4:])
+	}
+	for _, n := range difat {
+		sector, err := f.sector(n)
+		if err != nil {
+			return nil, errors.New("compound file has a corrupt FAT")
+		}
+		for i := 0; i < f.sectorSize/4; i++ {
+			f.fat = append(f.fat, binary.LittleEndian.Uint32(sector[i*4:]))
+		}
+	}
+
+	dir, err := f.chain(binary.LittleEndian.Uint32(data[0x30:]), f.fat, f.sectorSize, nil)
+	if err != nil {
+		return nil, errors.New("compound file has a corrupt directory")
+	}
+	for i := 0; i+128 <= len(dir); i += 128 {
+		raw := dir[i : i+128]
+		entry := cfbEntry{
+			kind:  raw[0x42],
+			left:  binary.LittleEndian.Uint32(raw[0x44:]),
+			right: binary.LittleEndian.Uint32(raw[0x48:]),
+			child: binary.LittleEndian.Uint32(raw[0x4C:]),
+			start: binary.LittleEndian.Uint32(raw[0x74:]),
+			size:  binary.LittleEndian.Uint64(raw[0x78:]),
+		}
+		copy(entry.clsid[:], raw[0x50:0x60])
+		if nameLen := int(binary.LittleEndian.Uint16(raw[0x40:])); nameLen >= 2 && nameLen <= 64 {
+			entry.name = decodeUTF16(raw[:nameLen-2])
+		}
+		if shift == 9 {
+			entry.size &= 0xFFFFFFFF // Version 3 files leave the high half undefined
+		}
+		f.entries = append(f.entries, entry)
+	}
+	if len(f.entries) == 0 || f.entries[0].kind != cfbRoot {
+		return nil, errors.New("compound file has no root storage")
+	}
+
+	// Small streams live in the mini stream, itself stored as the root's data
+	root := f.entries[0]
+	if f.miniStream, err = f.chain(root.start, f.fat, f.sectorSize, nil); err != nil {
+		return nil, errors.New("compound file has a corrupt mini stream")
+	}
+	miniFAT, err := f.chain(binary.LittleEndian.Uint32(data[0x3C:]), f.fat, f.sectorSize, nil)
+	if err != nil {
+		return nil, errors.New("compound file has a corrupt mini FAT")
+	}
+	for i := 0; i+4 <= len(miniFAT); i += 4 {
+		f.miniFAT = append(f.miniFAT, binary.LittleEndian.Uint32(miniFAT[i:]))
+	}
+	return f, nil
+}
+
+// sector returns the contents of a regular sector
+func (f *cfbFile) sector(n uint32) ([]byte, error) {
+	start := (int64(n) + 1) * int64(f.sectorSize)
+	if n >= cfbEndOfChain-3 || start+int64(f.sectorSize) > int64(len(f.data)) {
+		return nil, errors.New("sector out of range")
+	}
+	return f.data[start : start+int64(f.sectorSize)], nil
+}
+
+// chain reads a chain of sectors starting at start, from the file itself or,
+// when mini is set, from the mini stream
+func (f *cfbFile) chain(start uint32, table []uint32, size int, mini []byte) ([]byte, error) {
+	var out []byte
+	for n, steps := start, 0; n != cfbEndOfChain && n != cfbNoStream; steps++ {
+		if steps > len(table) {
+			return nil, errors.New("sector chain loops")
+		}
+		if mini != nil {
+			begin := int(n) * size
+			if begin+size > len(mini) {
+				return nil, errors.New("mini sector out of range")
+			}
+			out = append(out, mini[begin:begin+size]...)
+		} else {
+			sector, err := f.sector(n)
+			if err != nil {
+				return nil, err
+			}
+			out = append(out, sector...)
+		}
+		if int(n) >= len(table) {
+			return nil, errors.New("sector chain leaves the FAT")
+		}
+		n = table[n]
+	}
+	return out, nil
+}
+
+// children returns the entries in a storage by name
+func (f *cfbFile) children(storage uint32) map[string]uint32 {
+	children := make(map[string]uint32)
+	var walk func(id uint32, depth int)
+	walk = func(id uint32, depth int) {
+		if id == cfbNoStream || int(id) >= len(f.entries) || depth > len(f.entries) {
+			return
+		}
+		entry := f.entries[id]
+		walk(entry.left, depth+1)
+		children[entry.name] = id
+		walk(entry.right, depth+1)
+	}
+	if int(storage) < len(f.entries) {
+		walk(f.entries[storage].child, 0)
+	}
+	return children
+}
+
+// stream returns the contents of a stream
+func (f *cfbFile) stream(id uint32) ([]byte, error) {
+	entry := f.entries[id]
+	var data []byte
+	var err error
+	if entry.size < f.cutoff {
+		data, err = f.chain(entry.start, f.miniFAT, 64, f.miniStream)
+	} else {
+		data, err = f.chain(entry.start, f.fat, f.sectorSize, nil)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if uint64(len(data)) < entry.size {
+		return nil, errors.New("stream is truncated")
+	}
+	return data[:entry.size], nil
+}
+
+// decodeUTF16 decodes a little-endian UTF-16 string, stopping at a NUL
+func decodeUTF16(raw []byte) string {
+	units := make([]uint16, 0, len(raw)/2)
+	for i := 0; i+1 < len(raw); i += 2 {
+		unit := binary.LittleEndian.Uint16(raw[i:])
+		if unit == 0 {
+			break
+		}
+		units = append(units, unit)
+	}
+	return string(utf16.Decode(units))
+}
@@ -0,0 +1,133 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// channelAnalysisSeconds is how much of a recording AnalyzeChannels listens to
+const channelAnalysisSeconds = 600
+
+// channelSilenceDB is the RMS level below which a channel counts as unused
+const channelSilenceDB = -60.0
+
+// distinctChannelsMaxGapDB is how far the level of the channels' difference
+// may fall below that of their sum for them to still count as separate
+// microphones. Two microphones each picking up the other speaker faintly
+// stay within a few dB; a stereo pair hearing the same room falls well
+// below, and a mono recording copied to both channels has no difference.
+const distinctChannelsMaxGapDB = 6.0
+
+var (
+	astatsChannelLine = regexp.MustCompile(`Channel: (\d+)`)
+	astatsRMSLine     = regexp.MustCompile(`RMS level dB: (-?inf|-?\d+(?:\.\d+)?)`)
+)
+
+// ChannelAnalysis holds the RMS levels, in dB, of a stereo recording's
+// channels and of their sum and difference
+type ChannelAnalysis struct {
+	Left  float64
+	Right float64
+	Sum   float64
+	Diff  float64
+}
+
+// Distinct reports whether the channels look like two separate microphones,
+// e.g. an interview recorded with one speaker on each side: both carry sound
+// and they are not copies of the same signal
+func (a ChannelAnalysis) Distinct() bool {
+	if a.Left < channelSilenceDB || a.Right < channelSilenceDB {
+		return false
+	}
+	return a.Diff >= a.Sum-distinctChannelsMaxGapDB
+}
+
+// AnalyzeChannels measures the channels of the start of a stereo recording.
+// An error wrapping exec.ErrNotFound means ffmpeg is not installed.
+func AnalyzeChannels(ctx context.Context, ffmpegPath, path string) (*ChannelAnalysis, error) {
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-nostats",
+		"-t", strconv.Itoa(channelAnalysisSeconds),
+		"-i", path,
+		"-filter_complex", "[0:a]pan=4c|c0=c0|c1=c1|c2=0.5*c0+0.5*c1|c3=0.5*c0-0.5*c1,astats",
+		"-f", "null", "-")
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "analyze_channels", start, "path", path)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, err
+		}
+		return nil, fmt.Errorf("ffmpeg cannot analyze the channels: %s", lastLine(string(output)))
+	}
+	levels, err := ParseChannelLevels(string(output))
+	if err != nil {
+		return nil, err
+	}
+	if len(levels) < 4 {
+		return nil, fmt.Errorf("ffmpeg reported %d channel levels, expected 4", len(levels))
+	}
+	return &ChannelAnalysis{Left: levels[0], Right: levels[1], Sum: levels[2], Diff: levels[3]}, nil
+}
+
+// ParseChannelLevels reads the per-channel RMS levels from the log of
+// ffmpeg's astats filter, in channel order. Silent channels are -Inf.
+func ParseChannelLevels(output string) ([]float64, error) {
+	var levels []float64
+	channel := 0
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.Contains(line, "Overall") {
+			break
+		}
+		if m := astatsChannelLine.FindStringSubmatch(line); m != nil {
+			channel, _ = strconv.Atoi(m[1])
+			continue
+		}
+		m := astatsRMSLine.FindStringSubmatch(line)
+		if m == nil || channel != len(levels)+1 {
+			continue
+		}
+		level := math.Inf(-1)
+		if !strings.HasSuffix(m[1], "inf") {
+			level, _ = strconv.ParseFloat(m[1], 64)
+		}
+		levels = append(levels, level)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if len(levels) == 0 {
+		return nil, errors.New("no channel levels in ffmpeg output")
+	}
+	return levels, nil
+}
+
+// SplitStereo writes the left and right channels of a recording to two mono
+// files, encoded as their extensions say
+func SplitStereo(ctx context.Context, ffmpegPath, path, leftPath, rightPath string) error {
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-y",
+		"-i", path,
+		"-filter_complex", "[0:a]channelsplit=channel_layout=stereo[left][right]",
+		"-map", "[left]", leftPath,
+		"-map", "[right]", rightPath)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "split_channels", start, "path", path)
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed to split channels: %v - %s", err, lastLine(string(output)))
+	}
+	return nil
+}
@@ -0,0 +1,311 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/internal/faults"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/logger"
+)
+
+// Silence is a quiet stretch of a recording, in seconds
+type Silence struct {
+	Start float64
+	End   float64
+}
+
+// Chunk is a piece of a long recording, in seconds. It spans a little more
+// than the stretch it is responsible for, so a word cut at a boundary is
+// heard whole by one of its neighbours; segments are kept from the chunk
+// whose stretch their midpoint falls in.
+type Chunk struct {
+	Index     int     `json:"index"`
+	Start     float64 `json:"start"`      // Where the chunk's audio starts in the recording
+	End       float64 `json:"end"`        // Where the chunk's audio ends
+	KeepStart float64 `json:"keep_start"` // The stretch of the recording this chunk transcribes
+	KeepEnd   float64 `json:"keep_end"`
+	Path      string  `json:"path"` // The chunk's audio, once split
+}
+
+// Chunker splits long recordings at silences so they can be transcribed in
+// parallel
+type Chunker struct {
+	ffmpegPath string
+	length     time.Duration // Cut at the silence nearest this length
+	maxLength  time.Duration // Furthest a cut may move to reach a silence
+	overlap    time.Duration // Extra audio on each side of a cut
+	noise      string        // Level below which audio counts as silence
+	minSilence time.Duration // Shortest pause counted as silence
+}
+
+// NewChunker creates a chunker aiming for 10 minute chunks of at most 15
+// minutes, overlapping by 5 seconds
+func NewChunker() *Chunker {
+	return NewChunkerWithPath("ffmpeg")
+}
+
+// NewChunkerWithPath creates a chunker with a custom ffmpeg path
+func NewChunkerWithPath(ffmpegPath string) *Chunker {
+	return &Chunker{
+		ffmpegPath: ffmpegPath,
+		length:     10 * time.Minute,
+		maxLength:  15 * time.Minute,
+		overlap:    5 * time.Second,
+		noise:      "-35dB",
+		minSilence: 500 * time.Millisecond,
+	}
+}
+
+// SetLength sets how long chunks should be; they are at most half as long again
+func (c *Chunker) SetLength(length time.Duration) {
+	if length > 0 {
+		c.length = length
+		c.maxLength = length * 3 / 2
+	}
+}
+
+// SetOverlap sets how much audio each chunk shares with its neighbours
+func (c *Chunker) SetOverlap(overlap time.Duration) {
+	if overlap >= 0 {
+		c.overlap = overlap
+	}
+}
+
+// Plan divides a recording of duration seconds into chunks, cutting each at
+// the silence closest to the target length. Where there is no silence before
+// the maximum length the cut falls at the target length, and the overlap
+// keeps the words on either side intact.
+func (c *Chunker) Plan(duration float64, silences []Silence) []Chunk {
+	target, maxLength := c.length.Seconds(), c.maxLength.Seconds()
+	overlap := c.overlap.Seconds()
+
+	bounds := []float64{0}
+	for start := 0.0; duration-start > maxLength; {
+		cut := start + target
+		best := -1.0
+		for _, silence := range silences {
+			mid := (silence.Start + silence.End) / 2
+			// Chunks are never cut shorter than half the target
+			if mid < start+target/2 || mid > start+maxLength {
+				continue
+			}
+			if best < 0 || math.Abs(mid-start-target) < math.Abs(best-start-target) {
+				best = mid
+			}
+		}
+		if best >= 0 {
+			cut = best
+		}
+		bounds = append(bounds, cut)
+		start = cut
+	}
+	bounds = append(bounds, duration)
+
+	chunks := make([]Chunk, 0, len(bounds)-1)
+	for i := 0; i+1 < len(bounds); i++ {
+		chunks = append(chunks, Chunk{
+			Index:     i,
+			Start:     math.Max(bounds[i]-overlap, 0),
+			End:       math.Min(bounds[i+1]+overlap, duration),
+			KeepStart: bounds[i],
+			KeepEnd:   bounds[i+1],
+		})
+	}
+	return chunks
+}
+
+// Split plans the chunks of the recording at path and writes each one to dir
+// as a 16 kHz mono WAV file. A recording short enough for a single chunk is
+// not copied: its one chunk points at path itself.
+func (c *Chunker) Split(ctx context.Context, path, dir string) ([]Chunk, error) {
+	silences, duration, err := c.DetectSilences(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	chunks := c.Plan(duration, silences)
+	if len(chunks) == 1 {
+		chunks[0].Path = path
+		return chunks, nil
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create chunk directory: %w", err)
+	}
+	for i := range chunks {
+		chunks[i].Path = filepath.Join(dir, fmt.Sprintf("chunk_%03d.wav", i))
+		if err := c.extract(ctx, path, chunks[i]); err != nil {
+			for _, done := range chunks[:i] {
+				os.Remove(done.Path)
+			}
+			return nil, err
+		}
+	}
+	logger.Debug("Split recording into chunks", "path", path, "chunks", len(chunks), "duration", duration)
+	return chunks, nil
+}
+
+// extract writes the audio of one chunk to its path
+func (c *Chunker) extract(ctx context.Context, path string, chunk Chunk) error {
+	if err := faults.Inject(faults.FFmpeg); err != nil {
+		return fmt.Errorf("ffmpeg failed to extract chunk %d: %w", chunk.Index, err)
+	}
+	cmd := exec.CommandContext(ctx, c.ffmpegPath,
+		"-v", "error",
+		"-y",
+		"-ss", strconv.FormatFloat(chunk.Start, 'f', 3, 64),
+		"-t", strconv.FormatFloat(chunk.End-chunk.Start, 'f', 3, 64),
+		"-i", path,
+		"-vn",
+		"-ar", strconv.Itoa(PreprocessSampleRate),
+		"-ac", "1",
+		"-c:a", "pcm_s16le",
+		"-f", "wav",
+		chunk.Path)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "chunk", start, "path", path, "chunk", chunk.Index)
+	if err != nil {
+		os.Remove(chunk.Path)
+		return fmt.Errorf("ffmpeg failed to extract chunk %d: %w: %s", chunk.Index, err, lastLine(string(output)))
+	}
+	return nil
+}
+
+// DetectSilences runs ffmpeg's silencedetect filter over the recording at
+// path and returns its silences and duration in seconds
+func (c *Chunker) DetectSilences(ctx context.Context, path string) ([]Silence, float64, error) {
+	if err := faults.Inject(faults.FFmpeg); err != nil {
+		return nil, 0, fmt.Errorf("ffmpeg failed to detect silence: %w", err)
+	}
+	filter := fmt.Sprintf("silencedetect=noise=%s:d=%s", c.noise, strconv.FormatFloat(c.minSilence.Seconds(), 'f', -1, 64))
+	cmd := exec.CommandContext(ctx, c.ffmpegPath,
+		"-hide_banner",
+		"-nostats",
+		"-i", path,
+		"-vn",
+		"-af", filter,
+		"-f", "null",
+		"-")
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "silencedetect", start, "path", path)
+	if err != nil {
+		return nil, 0, fmt.Errorf("ffmpeg failed to detect silence: %w: %s", err, lastLine(string(output)))
+	}
+	silences, duration := ParseSilenceDetect(string(output))
+	if duration <= 0 {
+		return nil, 0, fmt.Errorf("ffmpeg did not report the duration of %s", filepath.Base(path))
+	}
+	return silences, duration, nil
+}
+
+var (
+	durationLine     = regexp.MustCompile(`Duration: (\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+	silenceStartLine = regexp.MustCompile(`silence_start: (-?[\d.]+)`)
+	silenceEndLine   = regexp.MustCompile(`silence_end: ([\d.]+)`)
+)
+
+// ParseSilenceDetect reads the silences and the input duration from the log
+// of an ffmpeg run with the silencedetect filter. A silence still running
+// at the end of the recording ends with it.
+func ParseSilenceDetect(output string) ([]Silence, float64) {
+	var silences []Silence
+	duration := 0.0
+	open := -1.0
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if m := durationLine.FindStringSubmatch(line); m != nil && duration == 0 {
+			hours, _ := strconv.Atoi(m[1])
+			minutes, _ := strconv.Atoi(m[2])
+			seconds, _ := strconv.ParseFloat(m[3], 64)
+			duration = float64(hours*3600+minutes*60) + seconds
+		}
+		if m := silenceStartLine.FindStringSubmatch(line); m != nil {
+			if start, err := strconv.ParseFloat(m[1], 64); err == nil {
+				open = math.Max(start, 0)
+			}
+		}
+		if m := silenceEndLine.FindStringSubmatch(line); m != nil && open >= 0 {
+			if end, err := strconv.ParseFloat(m[1], 64); err == nil {
+				silences = append(silences, Silence{Start: open, End: end})
+			}
+			open = -1
+		}
+	}
+	if open >= 0 && duration > open {
+		silences = append(silences, Silence{Start: open, End: duration})
+	}
+	return silences, duration
+}
+
+// Stitch joins the transcripts of a recording's chunks, in the order of
+// chunks, into one. Timestamps are moved from each chunk's audio to the
+// recording, and of the segments and words heard by two chunks only those
+// of the chunk whose stretch holds their midpoint are kept.
+func Stitch(chunks []Chunk, results []*interfaces.TranscriptResult) *interfaces.TranscriptResult {
+	stitched := &interfaces.TranscriptResult{Metadata: map[string]string{}}
+	var texts []string
+	confidence, transcribed := 0.0, 0
+	for i, chunk := range chunks {
+		if i >= len(results) || results[i] == nil {
+			continue
+		}
+		result := results[i]
+		last := i == len(chunks)-1
+		keep := func(start, end float64) bool {
+			mid := chunk.Start + (start+end)/2
+			return mid >= chunk.KeepStart && (mid < chunk.KeepEnd || last)
+		}
+
+		for _, segment := range result.Segments {
+			if !keep(segment.Start, segment.End) {
+				continue
+			}
+			segment.Start += chunk.Start
+			segment.End += chunk.Start
+			stitched.Segments = append(stitched.Segments, segment)
+			if text := strings.TrimSpace(segment.Text); text != "" {
+				texts = append(texts, text)
+			}
+		}
+		for _, word := range result.WordSegments {
+			if !keep(word.Start, word.End) {
+				continue
+			}
+			word.Start += chunk.Start
+			word.End += chunk.Start
+			stitched.WordSegments = append(stitched.WordSegments, word)
+		}
+
+		if stitched.Language == "" {
+			stitched.Language = result.Language
+		}
+		if stitched.ModelUsed == "" {
+			stitched.ModelUsed = result.ModelUsed
+		}
+		for key, value := range result.Metadata {
+			if _, ok := stitched.Metadata[key]; !ok {
+				stitched.Metadata[key] = value
+			}
+		}
+		confidence += result.Confidence
+		transcribed++
+	}
+	stitched.Text = strings.Join(texts, " ")
+	if transcribed > 0 {
+		stitched.Confidence = confidence / float64(transcribed)
+	}
+	stitched.Metadata["chunks"] = strconv.Itoa(len(chunks))
+	return stitched
+}
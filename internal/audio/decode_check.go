@@ -0,0 +1,88 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// DecodeError is a recording that cannot be decoded past Offset, e.g. a
+// truncated upload or a file with a corrupt header
+type DecodeError struct {
+	Offset float64 // Seconds decoded before the failure
+	Reason string
+}
+
+func (e *DecodeError) Error() string {
+	return fmt.Sprintf("file unreadable at %s: %s", FormatOffset(e.Offset), e.Reason)
+}
+
+// FormatOffset formats seconds into a recording as MM:SS, or H:MM:SS from an
+// hour on
+func FormatOffset(seconds float64) string {
+	total := int(math.Max(seconds, 0))
+	if total >= 3600 {
+		return fmt.Sprintf("%d:%02d:%02d", total/3600, total/60%60, total%60)
+	}
+	return fmt.Sprintf("%02d:%02d", total/60, total%60)
+}
+
+// CheckDecodable decodes the first seconds of path's first audio stream with
+// ffmpeg, the way transcription will, and returns a *DecodeError when ffmpeg
+// gives up or the audio stops well short of the expected duration (0 when
+// unknown). Glitches ffmpeg recovers from are let through, as transcription
+// recovers from them too. An error wrapping exec.ErrNotFound means ffmpeg is
+// not installed.
+func CheckDecodable(ctx context.Context, ffmpegPath, path string, seconds int, expected float64) error {
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-nostdin", "-nostats",
+		"-v", "error",
+		"-t", strconv.Itoa(seconds),
+		"-i", path,
+		"-map", "0:a:0",
+		"-progress", "pipe:1",
+		"-f", "null", "-")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	decoded := 0.0
+	ReadFFmpegProgress(stdout, func(position float64, done bool) {
+		decoded = position
+	})
+	err = cmd.Wait()
+	logger.FFmpegStage(ctx, "decode_check", start, "path", path)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	if err != nil {
+		reason := lastLine(stderr.String())
+		if reason == "" {
+			reason = err.Error()
+		}
+		return &DecodeError{Offset: decoded, Reason: reason}
+	}
+
+	if expected > 0 {
+		want := math.Min(float64(seconds), expected)
+		if decoded < want*0.9-1 {
+			return &DecodeError{
+				Offset: decoded,
+				Reason: fmt.Sprintf("audio ends early, the file claims %s", FormatOffset(expected)),
+			}
+		}
+	}
+	return nil
+}
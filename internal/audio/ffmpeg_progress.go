@@ -0,0 +1,114 @@
+package audio
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	inputLine          = regexp.MustCompile(`^Input #(\d+),`)
+	outputLine         = regexp.MustCompile(`^(Output #\d+,|Stream mapping:)`)
+	inputDurationLine  = regexp.MustCompile(`^\s+Duration: (\d+):(\d{2}):(\d{2}(?:\.\d+)?)`)
+	progressTimeFields = []string{"out_time_us", "out_time_ms"} // Both in microseconds
+)
+
+// ReadFFmpegProgress reads the key=value blocks ffmpeg writes with -progress
+// and calls report with how far into the output each block got, in seconds.
+// done is set on the block ending the run.
+func ReadFFmpegProgress(r io.Reader, report func(seconds float64, done bool)) error {
+	block := make(map[string]string)
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		key, value, ok := strings.Cut(strings.TrimSpace(scanner.Text()), "=")
+		if !ok {
+			continue
+		}
+		if key != "progress" {
+			block[key] = value
+			continue
+		}
+		if seconds, ok := progressSeconds(block); ok {
+			report(seconds, value == "end")
+		}
+		block = make(map[string]string)
+	}
+	return scanner.Err()
+}
+
+// progressSeconds returns the output time of a progress block. Early blocks
+// report N/A or a negative time before the first packet is written.
+func progressSeconds(block map[string]string) (float64, bool) {
+	for _, field := range progressTimeFields {
+		if us, err := strconv.ParseInt(block[field], 10, 64); err == nil {
+			return math.Max(float64(us)/1e6, 0), true
+		}
+	}
+	if seconds, ok := parseClock(block["out_time"]); ok {
+		return math.Max(seconds, 0), true
+	}
+	return 0, false
+}
+
+// ReadFFmpegInputDurations reads the durations ffmpeg logs for its inputs,
+// by input index, stopping at the output header that follows them. Inputs of
+// unknown length are left out.
+func ReadFFmpegInputDurations(r io.Reader) (map[int]float64, error) {
+	durations := make(map[int]float64)
+	input := -1
+	scanner := bufio.NewScanner(r)
+	// ffmpeg ends stats lines with a carriage return
+	scanner.Split(scanLogLines)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if outputLine.MatchString(line) {
+			break
+		}
+		if m := inputLine.FindStringSubmatch(line); m != nil {
+			input, _ = strconv.Atoi(m[1])
+			continue
+		}
+		if m := inputDurationLine.FindStringSubmatch(line); m != nil && input >= 0 {
+			if seconds, ok := parseClock(m[1] + ":" + m[2] + ":" + m[3]); ok {
+				durations[input] = seconds
+			}
+			input = -1
+		}
+	}
+	return durations, scanner.Err()
+}
+
+// MergedDuration returns how long the mix of tracks lasts, given the length
+// of each track's file by its position in tracks
+func MergedDuration(tracks []TrackInfo, durations map[int]float64) float64 {
+	total := 0.0
+	for i, track := range tracks {
+		duration, ok := durations[i]
+		if !ok {
+			continue
+		}
+		// A clip plays the part of its file it was trimmed to
+		duration = math.Max(duration-track.Start, 0)
+		if track.Duration > 0 {
+			duration = math.Min(duration, track.Duration)
+		}
+		total = math.Max(total, track.Offset+duration)
+	}
+	return total
+}
+
+// scanLogLines splits on both newlines and carriage returns
+func scanLogLines(data []byte, atEOF bool) (int, []byte, error) {
+	for i, b := range data {
+		if b == '\n' || b == '\r' {
+			return i + 1, data[:i], nil
+		}
+	}
+	if atEOF && len(data) > 0 {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
@@ -0,0 +1,131 @@
+package audio
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/bits"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// FingerprintSeconds is how much of a recording is fingerprinted; copies of
+// a recording agree from the start, so its beginning is enough to tell them
+const FingerprintSeconds = 120
+
+// fingerprintMaxShift is how many fingerprint items (about 0.12 s each) two
+// recordings may be shifted against each other, e.g. by encoder padding
+const fingerprintMaxShift = 16
+
+// fingerprintMinOverlap is the fewest items two fingerprints must share to
+// be compared, about five seconds
+const fingerprintMinOverlap = 40
+
+// Fingerprint is the Chromaprint fingerprint of the start of a recording.
+// Re-encoding or resampling changes few of its bits, so copies of the same
+// audio have nearly equal fingerprints even when their files differ.
+type Fingerprint struct {
+	Duration float64 // Seconds of the whole recording, as fpcalc reports it
+	Values   []uint32
+}
+
+// fpcalcOutput is the JSON fpcalc -raw -json writes
+type fpcalcOutput struct {
+	Duration    float64  `json:"duration"`
+	Fingerprint []uint32 `json:"fingerprint"`
+}
+
+// ComputeFingerprint runs Chromaprint's fpcalc on the first
+// FingerprintSeconds of path. An error wrapping exec.ErrNotFound means
+// fpcalc is not installed.
+func ComputeFingerprint(ctx context.Context, fpcalcPath, path string) (*Fingerprint, error) {
+	cmd := exec.CommandContext(ctx, fpcalcPath, "-raw", "-json", "-length", strconv.Itoa(FingerprintSeconds), path)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	start := time.Now()
+	output, err := cmd.Output()
+	logger.FFmpegStage(ctx, "fingerprint", start, "path", path)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, err
+		}
+		if reason := lastLine(stderr.String()); reason != "" {
+			return nil, fmt.Errorf("fpcalc cannot read the file: %s", reason)
+		}
+		return nil, fmt.Errorf("fpcalc cannot read the file: %w", err)
+	}
+	var data fpcalcOutput
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse fpcalc output: %w", err)
+	}
+	if len(data.Fingerprint) == 0 {
+		return nil, errors.New("fpcalc found no audio to fingerprint")
+	}
+	return &Fingerprint{Duration: data.Duration, Values: data.Fingerprint}, nil
+}
+
+// Encode packs the fingerprint's values for storage
+func (f *Fingerprint) Encode() string {
+	buf := make([]byte, 4*len(f.Values))
+	for i, value := range f.Values {
+		binary.LittleEndian.PutUint32(buf[4*i:], value)
+	}
+	return base64.StdEncoding.EncodeToString(buf)
+}
+
+// DecodeFingerprint unpacks values stored by Encode
+func DecodeFingerprint(encoded string) ([]uint32, error) {
+	buf, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, err
+	}
+	if len(buf)%4 != 0 {
+		return nil, errors.New("fingerprint is not a whole number of values")
+	}
+	values := make([]uint32, len(buf)/4)
+	for i := range values {
+		values[i] = binary.LittleEndian.Uint32(buf[4*i:])
+	}
+	return values, nil
+}
+
+// FingerprintSimilarity returns the share of bits two fingerprints agree on,
+// from 0 to 1, at the best of small shifts against each other. Unrelated
+// audio agrees on about half; copies of the same audio on well over 0.9.
+// Fingerprints too short to compare score 0.
+func FingerprintSimilarity(a, b []uint32) float64 {
+	best := 0.0
+	for shift := -fingerprintMaxShift; shift <= fingerprintMaxShift; shift++ {
+		x, y := a, b
+		if shift > 0 {
+			if shift >= len(x) {
+				continue
+			}
+			x = x[shift:]
+		} else if shift < 0 {
+			if -shift >= len(y) {
+				continue
+			}
+			y = y[-shift:]
+		}
+		n := min(len(x), len(y))
+		if n < fingerprintMinOverlap {
+			continue
+		}
+		differing := 0
+		for i := 0; i < n; i++ {
+			differing += bits.OnesCount32(x[i] ^ y[i])
+		}
+		if similarity := 1 - float64(differing)/float64(32*n); similarity > best {
+			best = similarity
+		}
+	}
+	return best
+}
@@ -0,0 +1,261 @@
+package audio
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"synthezia/internal/faults"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+)
+
+// The share of the overall progress taken by rendering the tracks of a
+// resumable merge; mixing the renders takes the rest up to mergeProcessingEnd
+const (
+	mergeRenderStart = 20.0
+	mergeRenderEnd   = 60.0
+)
+
+// renderCheckpointFile lists the finished renders of a render directory
+const renderCheckpointFile = "checkpoint.json"
+
+// renderCheckpoint records, for each finished track render, the key of what
+// it was rendered from
+type renderCheckpoint struct {
+	Renders map[string]string `json:"renders"`
+}
+
+// MergeTracksResumable merges tracks like MergeTracksWithOffsets, but in two
+// stages: each project track is first rendered on its own to renderDir, with
+// its clips placed, automated, amplified and panned, then the renders are
+// mixed into outputPath. Renders are checkpointed as they finish, so a merge
+// that fails or is cancelled picks up from the last finished render when it
+// is run again with the same renderDir. Renders whose clips or files changed
+// since are made again. renderDir is removed once the mix is written.
+func (m *AudioMerger) MergeTracksResumable(ctx context.Context, tracks []TrackInfo, outputPath, renderDir string, progressCallback func(MergeProgress)) error {
+	if len(tracks) == 0 {
+		return fmt.Errorf("no tracks provided for merging")
+	}
+	report := func(progress MergeProgress) {
+		if progressCallback != nil {
+			progressCallback(progress)
+		}
+	}
+	report(MergeProgress{Stage: "starting", Progress: 0})
+
+	for i, track := range tracks {
+		if _, err := m.fs.Stat(track.FilePath); os.IsNotExist(err) {
+			return fmt.Errorf("input file does not exist: %s", track.FilePath)
+		}
+		if track.Mute {
+			continue
+		}
+		report(MergeProgress{Stage: "validating", Progress: float64(i+1) / float64(len(tracks)) * mergeRenderStart})
+	}
+
+	activeTracks := AudibleTracks(tracks)
+	if len(activeTracks) == 0 {
+		return fmt.Errorf("no active (non-muted) tracks to merge")
+	}
+
+	if err := m.fs.MkdirAll(renderDir, 0755); err != nil {
+		return fmt.Errorf("failed to create render directory: %w", err)
+	}
+	checkpoint := m.readCheckpoint(renderDir)
+
+	timelines := trackTimelines(activeTracks)
+	renders := make([]TrackInfo, len(timelines))
+	for i, indexes := range timelines {
+		clips := make([]TrackInfo, len(indexes))
+		for j, index := range indexes {
+			clips[j] = activeTracks[index]
+		}
+		name := fmt.Sprintf("track_%02d.flac", i+1)
+		path := filepath.Join(renderDir, name)
+		renders[i] = TrackInfo{FilePath: path, Gain: 1.0}
+
+		key, err := m.renderKey(clips)
+		if err != nil {
+			return err
+		}
+		if checkpoint.Renders[name] == key {
+			if _, err := m.fs.Stat(path); err == nil {
+				logger.Debug("Reusing track render", "render", name)
+				report(MergeProgress{Stage: "rendering", Progress: renderProgress(i+1, len(timelines))})
+				continue
+			}
+		}
+
+		if err := m.renderTimeline(ctx, clips, path); err != nil {
+			return err
+		}
+		checkpoint.Renders[name] = key
+		if err := m.writeCheckpoint(renderDir, checkpoint); err != nil {
+			return fmt.Errorf("failed to checkpoint track render: %w", err)
+		}
+		report(MergeProgress{Stage: "rendering", Progress: renderProgress(i+1, len(timelines))})
+	}
+
+	tempPath := fsys.TempPath(m.tempDir, outputPath) + filepath.Ext(outputPath)
+	defer m.fs.Remove(tempPath)
+	report(MergeProgress{Stage: "processing", Progress: mergeRenderEnd})
+
+	start := time.Now()
+	err := m.executeFFmpegCommand(ctx, m.buildMixCommand(renders, tempPath), renders, mergeRenderEnd, mergeProcessingEnd, progressCallback)
+	logger.FFmpegStage(ctx, "merge_mix", start, "tracks", len(renders), "output", outputPath)
+	if err != nil {
+		return fmt.Errorf("ffmpeg execution failed: %w", err)
+	}
+	if _, err := m.fs.Stat(tempPath); os.IsNotExist(err) {
+		return fmt.Errorf("output file was not created: %s", outputPath)
+	}
+	if err := m.fs.Rename(tempPath, outputPath); err != nil {
+		return fmt.Errorf("failed to move merged file into place: %w", err)
+	}
+
+	// The renders only matter until the mix is written
+	if err := m.fs.RemoveAll(renderDir); err != nil {
+		logger.Warn("Failed to remove track renders", "dir", renderDir, "error", err)
+	}
+	report(MergeProgress{Stage: "completed", Progress: 100, OutputPath: outputPath})
+	return nil
+}
+
+// renderProgress is the overall progress once done of total renders are finished
+func renderProgress(done, total int) float64 {
+	return mergeRenderStart + float64(done)/float64(total)*(mergeRenderEnd-mergeRenderStart)
+}
+
+// renderTimeline renders the clips of one project track, laid on its
+// timeline, to path. The render is written aside and moved into place once
+// complete, so an interrupted render is never mistaken for a finished one.
+func (m *AudioMerger) renderTimeline(ctx context.Context, clips []TrackInfo, path string) error {
+	if err := faults.Inject(faults.FFmpeg); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	args := []string{"-hide_banner", "-nostdin", "-nostats", "-y"}
+	var filterParts, clipLabels []string
+	for i, clip := range clips {
+		args = append(args, "-i", clip.FilePath)
+		label := fmt.Sprintf("[c%d]", i)
+		filterParts = append(filterParts, clipFilter(clip, i)+label)
+		clipLabels = append(clipLabels, label)
+	}
+	filterParts = append(filterParts, fmt.Sprintf("%samix=inputs=%d:duration=longest:normalize=0[aout]",
+		strings.Join(clipLabels, ""), len(clips)))
+
+	tempPath := fsys.TempPath(m.tempDir, path) + filepath.Ext(path)
+	defer m.fs.Remove(tempPath)
+	args = append(args,
+		"-filter_complex", strings.Join(filterParts, ";"),
+		"-map", "[aout]",
+		"-c:a", "flac", // Lossless, so the mix is encoded only once
+		tempPath,
+	)
+
+	cmd := exec.CommandContext(ctx, m.ffmpegPath, args...)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "merge_render", start, "clips", len(clips), "output", path)
+	if ctx.Err() != nil {
+		return fmt.Errorf("merge operation cancelled")
+	}
+	if err != nil {
+		return fmt.Errorf("ffmpeg failed to render track: %v - %s", err, lastLine(string(output)))
+	}
+	if _, err := m.fs.Stat(tempPath); os.IsNotExist(err) {
+		return fmt.Errorf("track render was not created: %s", path)
+	}
+	if err := m.fs.Rename(tempPath, path); err != nil {
+		return fmt.Errorf("failed to move track render into place: %w", err)
+	}
+	return nil
+}
+
+// buildMixCommand constructs the ffmpeg command mixing track renders into
+// the merged file
+func (m *AudioMerger) buildMixCommand(renders []TrackInfo, outputPath string) *exec.Cmd {
+	args := []string{"-y"}
+	var inputs []string
+	for i, render := range renders {
+		args = append(args, "-i", render.FilePath)
+		inputs = append(inputs, fmt.Sprintf("[%d:a]", i))
+	}
+	args = append(args,
+		"-filter_complex", fmt.Sprintf("%samix=inputs=%d:duration=longest:normalize=0[aout]", strings.Join(inputs, ""), len(renders)),
+		"-map", "[aout]",
+		"-c:a", "libmp3lame",
+		"-b:a", "192k",
+		"-progress", "pipe:1",
+		"-nostats",
+		outputPath,
+	)
+	return exec.Command(m.ffmpegPath, args...)
+}
+
+// renderKey identifies what a track render is made from: its clips and the
+// size and modification time of their files
+func (m *AudioMerger) renderKey(clips []TrackInfo) (string, error) {
+	type fileStamp struct {
+		Size    int64
+		ModTime time.Time
+	}
+	stamps := make([]fileStamp, len(clips))
+	for i, clip := range clips {
+		info, err := m.fs.Stat(clip.FilePath)
+		if err != nil {
+			return "", fmt.Errorf("failed to stat %s: %w", clip.FilePath, err)
+		}
+		stamps[i] = fileStamp{Size: info.Size(), ModTime: info.ModTime().UTC()}
+	}
+	data, err := json.Marshal(struct {
+		Clips []TrackInfo
+		Files []fileStamp
+	}{clips, stamps})
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:]), nil
+}
+
+// readCheckpoint reads the checkpoint of a render directory. A missing or
+// unreadable checkpoint has no renders, so they are all made again.
+func (m *AudioMerger) readCheckpoint(renderDir string) *renderCheckpoint {
+	checkpoint := &renderCheckpoint{Renders: make(map[string]string)}
+	f, err := m.fs.Open(filepath.Join(renderDir, renderCheckpointFile))
+	if err != nil {
+		return checkpoint
+	}
+	defer f.Close()
+	data, err := io.ReadAll(f)
+	if err != nil {
+		return checkpoint
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil || checkpoint.Renders == nil {
+		logger.Warn("Ignoring invalid render checkpoint", "dir", renderDir)
+		return &renderCheckpoint{Renders: make(map[string]string)}
+	}
+	return checkpoint
+}
+
+// writeCheckpoint replaces the checkpoint of a render directory
+func (m *AudioMerger) writeCheckpoint(renderDir string, checkpoint *renderCheckpoint) error {
+	data, err := json.Marshal(checkpoint)
+	if err != nil {
+		return err
+	}
+	_, err = fsys.WriteFileAtomic(m.fs, renderDir, filepath.Join(renderDir, renderCheckpointFile), bytes.NewReader(data))
+	return err
+}
@@ -3,9 +3,20 @@ package audio
 import (
 	"context"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"os/exec"
+	"path/filepath"
+	"sort"
 	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/faults"
+	"synthezia/internal/telemetry"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
 )
 
 // TrackInfo represents information needed for merging a track
@@ -15,25 +26,65 @@ type TrackInfo struct {
 	Gain     float64
 	Pan      float64
 	Mute     bool
+	Solo     bool   // When any track is soloed, only soloed tracks are heard
+	Name     string // Name of the track in the project, usually its speaker
+
+	// Track numbers the project track holding the clip, from 1. Clips of the
+	// same track are laid on one timeline; 0 is a track of its own.
+	Track int
+	// Start and Duration trim the file to the part the clip plays, in
+	// seconds. A zero Duration plays to the end of the file.
+	Start    float64
+	Duration float64
+
+	// Volume automation applied on top of Gain, such as fades and level rides
+	Envelope []EnvelopePoint
 }
 
+// EnvelopePoint is a point of a track's volume automation: the gain at a time
+// in seconds from the start of the clip. As in Audacity, the gain
+// before the first point and after the last is that of the point, and gains
+// between points are interpolated on a logarithmic scale.
+type EnvelopePoint struct {
+	Time  float64 `json:"t"`
+	Value float64 `json:"value"`
+}
+
+// envelopeFloor is the lowest gain of an envelope, about -140 dB, since
+// silence cannot be interpolated on a logarithmic scale
+const envelopeFloor = 1e-7
+
 // MergeProgress represents the progress of an audio merge operation
 type MergeProgress struct {
-	Stage       string  // "starting", "processing", "completed", "failed"
-	Progress    float64 // 0-100 percentage
-	ErrorMsg    string
-	OutputPath  string
+	Stage       string  `json:"stage"`    // "starting", "processing", "completed", "failed"
+	Progress    float64 `json:"progress"` // 0-100 percentage
+	ErrorMsg    string  `json:"error,omitempty"`
+	OutputPath  string  `json:"-"`
+
+	// How much of the mix ffmpeg has written, out of how long it lasts, in
+	// seconds. Set while processing once ffmpeg reports the input durations.
+	ProcessedSeconds float64 `json:"processed_seconds,omitempty"`
+	TotalSeconds     float64 `json:"total_seconds,omitempty"`
 }
 
+// The share of the overall progress taken by ffmpeg's run
+const (
+	mergeProcessingStart = 25.0
+	mergeProcessingEnd   = 95.0
+)
+
 // AudioMerger handles merging multiple audio tracks with timing offsets
 type AudioMerger struct {
 	ffmpegPath string
+	fs         fsys.FS
+	tempDir    string
 }
 
 // NewAudioMerger creates a new audio merger instance
 func NewAudioMerger() *AudioMerger {
 	return &AudioMerger{
 		ffmpegPath: "ffmpeg", // Assumes ffmpeg is in PATH
+		fs:         fsys.OS,
 	}
 }
 
@@ -41,11 +92,36 @@ func NewAudioMerger() *AudioMerger {
 func NewAudioMergerWithPath(ffmpegPath string) *AudioMerger {
 	return &AudioMerger{
 		ffmpegPath: ffmpegPath,
+		fs:         fsys.OS,
 	}
 }
 
+// SetFS overrides the filesystem used for input/output file checks, mainly for tests
+func (m *AudioMerger) SetFS(fs fsys.FS) {
+	m.fs = fs
+}
+
+// SetFFmpegPath overrides the ffmpeg binary merges run
+func (m *AudioMerger) SetFFmpegPath(path string) {
+	m.ffmpegPath = path
+}
+
+// SetTempDir sets where merges are written before being renamed to their
+// output path. Empty writes them next to the output.
+func (m *AudioMerger) SetTempDir(dir string) {
+	m.tempDir = dir
+}
+
 // MergeTracksWithOffsets merges audio tracks using their offset information
-func (m *AudioMerger) MergeTracksWithOffsets(ctx context.Context, tracks []TrackInfo, outputPath string, progressCallback func(MergeProgress)) error {
+func (m *AudioMerger) MergeTracksWithOffsets(ctx context.Context, tracks []TrackInfo, outputPath string, progressCallback func(MergeProgress)) (err error) {
+	ctx, span := telemetry.Start(ctx, "ffmpeg.merge", telemetry.SpanKindInternal,
+		telemetry.Int("audio.track_count", len(tracks)),
+		telemetry.String("audio.output_path", outputPath))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	if len(tracks) == 0 {
 		return fmt.Errorf("no tracks provided for merging")
 	}
@@ -57,7 +133,7 @@ func (m *AudioMerger) MergeTracksWithOffsets(ctx context.Context, tracks []Track
 
 	// Validate all input files exist
 	for i, track := range tracks {
-		if _, err := os.Stat(track.FilePath); os.IsNotExist(err) {
+		if _, err := m.fs.Stat(track.FilePath); os.IsNotExist(err) {
 			return fmt.Errorf("input file does not exist: %s", track.FilePath)
 		}
 		// Skip muted tracks
@@ -73,26 +149,26 @@ func (m *AudioMerger) MergeTracksWithOffsets(ctx context.Context, tracks []Track
 	}
 
 	// Filter out muted tracks
-	activeTracks := make([]TrackInfo, 0, len(tracks))
-	for _, track := range tracks {
-		if !track.Mute {
-			activeTracks = append(activeTracks, track)
-		}
-	}
-
+	activeTracks := AudibleTracks(tracks)
 	if len(activeTracks) == 0 {
 		return fmt.Errorf("no active (non-muted) tracks to merge")
 	}
 
-	// Build ffmpeg command
-	cmd := m.buildFFmpegCommand(activeTracks, outputPath)
+	// Build ffmpeg command. ffmpeg picks the format from the extension, so the
+	// temporary file keeps the output's.
+	tempPath := fsys.TempPath(m.tempDir, outputPath) + filepath.Ext(outputPath)
+	defer m.fs.Remove(tempPath)
+	cmd := m.buildFFmpegCommand(activeTracks, tempPath)
 
 	if progressCallback != nil {
-		progressCallback(MergeProgress{Stage: "processing", Progress: 25})
+		progressCallback(MergeProgress{Stage: "processing", Progress: mergeProcessingStart})
 	}
 
 	// Execute ffmpeg command
-	if err := m.executeFFmpegCommand(ctx, cmd, progressCallback); err != nil {
+	start := time.Now()
+	err = m.executeFFmpegCommand(ctx, cmd, activeTracks, mergeProcessingStart, mergeProcessingEnd, progressCallback)
+	logger.FFmpegStage(ctx, "merge", start, "tracks", len(activeTracks), "output", outputPath)
+	if err != nil {
 		if progressCallback != nil {
 			progressCallback(MergeProgress{Stage: "failed", Progress: 0, ErrorMsg: err.Error()})
 		}
@@ -100,12 +176,18 @@ func (m *AudioMerger) MergeTracksWithOffsets(ctx context.Context, tracks []Track
 	}
 
 	// Verify output file was created
-	if _, err := os.Stat(outputPath); os.IsNotExist(err) {
+	if _, err := m.fs.Stat(tempPath); os.IsNotExist(err) {
 		if progressCallback != nil {
 			progressCallback(MergeProgress{Stage: "failed", Progress: 0, ErrorMsg: "output file was not created"})
 		}
 		return fmt.Errorf("output file was not created: %s", outputPath)
 	}
+	if err := m.fs.Rename(tempPath, outputPath); err != nil {
+		if progressCallback != nil {
+			progressCallback(MergeProgress{Stage: "failed", Progress: 0, ErrorMsg: err.Error()})
+		}
+		return fmt.Errorf("failed to move merged file into place: %w", err)
+	}
 
 	if progressCallback != nil {
 		progressCallback(MergeProgress{Stage: "completed", Progress: 100, OutputPath: outputPath})
@@ -114,13 +196,38 @@ func (m *AudioMerger) MergeTracksWithOffsets(ctx context.Context, tracks []Track
 	return nil
 }
 
+// AudibleTracks returns the tracks heard in the mix. Muted tracks are
+// silent and, as in Audacity, soloing any track silences all unsoloed ones.
+func AudibleTracks(tracks []TrackInfo) []TrackInfo {
+	audible := make([]TrackInfo, 0, len(tracks))
+	for i, heard := range Audible(tracks) {
+		if heard {
+			audible = append(audible, tracks[i])
+		}
+	}
+	return audible
+}
+
+// Audible reports, for each track, whether it is heard in the mix
+func Audible(tracks []TrackInfo) []bool {
+	soloed := false
+	for _, track := range tracks {
+		soloed = soloed || (track.Solo && !track.Mute)
+	}
+	heard := make([]bool, len(tracks))
+	for i, track := range tracks {
+		heard[i] = !track.Mute && (track.Solo || !soloed)
+	}
+	return heard
+}
+
 // buildFFmpegCommand constructs the ffmpeg command for merging tracks
 func (m *AudioMerger) buildFFmpegCommand(tracks []TrackInfo, outputPath string) *exec.Cmd {
 	args := []string{
 		"-y", // Overwrite output file if it exists
 	}
 
-	// Add input files
+	// Add input files, one per clip so a file placed twice is read twice
 	for _, track := range tracks {
 		args = append(args, "-i", track.FilePath)
 	}
@@ -129,31 +236,34 @@ func (m *AudioMerger) buildFFmpegCommand(tracks []TrackInfo, outputPath string)
 	var filterParts []string
 	var mixInputs []string
 
-	for i, track := range tracks {
-		// Create adelay filter for each track
-		delayFilter := fmt.Sprintf("[%d:a]adelay=%.3fs:all=1", i, track.Offset)
-		
-		// Apply gain if not default (1.0)
-		if track.Gain != 1.0 && track.Gain != 0.0 {
-			delayFilter += fmt.Sprintf(",volume=%.3f", track.Gain)
-		}
-		
-		// Apply pan if not center (0.0)
-		if track.Pan != 0.0 {
-			// Convert pan value (-1.0 to 1.0) to ffmpeg pan format
-			panValue := (track.Pan + 1.0) / 2.0 // Convert to 0-1 range
-			delayFilter += fmt.Sprintf(",pan=stereo|c0<%g*c0+%g*c1|c1<%g*c0+%g*c1",
-				1-panValue, panValue, panValue, 1-panValue)
-		}
-		
-		trackLabel := fmt.Sprintf("[a%d]", i)
-		filterParts = append(filterParts, delayFilter+trackLabel)
+	timelines := trackTimelines(tracks)
+	for _, clips := range timelines {
+		var clipLabels []string
+		for _, i := range clips {
+			delayFilter := clipFilter(tracks[i], i)
+
+			clipLabel := fmt.Sprintf("[c%d]", i)
+			if len(clips) == 1 {
+				clipLabel = fmt.Sprintf("[a%d]", i)
+			}
+			filterParts = append(filterParts, delayFilter+clipLabel)
+			clipLabels = append(clipLabels, clipLabel)
+		}
+
+		// Lay the clips of a track on one timeline, silent in the gaps
+		// between them and summed where they overlap
+		trackLabel := clipLabels[0]
+		if len(clips) > 1 {
+			trackLabel = fmt.Sprintf("[a%d]", clips[0])
+			filterParts = append(filterParts, fmt.Sprintf("%samix=inputs=%d:duration=longest:normalize=0%s",
+				strings.Join(clipLabels, ""), len(clips), trackLabel))
+		}
 		mixInputs = append(mixInputs, trackLabel)
 	}
 
 	// Add amix filter to combine all tracks
 	amixFilter := fmt.Sprintf("%samix=inputs=%d:duration=longest:normalize=0[aout]",
-		strings.Join(mixInputs, ""), len(tracks))
+		strings.Join(mixInputs, ""), len(timelines))
 	filterParts = append(filterParts, amixFilter)
 
 	// Combine all filter parts
@@ -164,57 +274,184 @@ func (m *AudioMerger) buildFFmpegCommand(tracks []TrackInfo, outputPath string)
 		"-map", "[aout]",
 		"-c:a", "libmp3lame", // Use MP3 for output (smaller file size)
 		"-b:a", "192k",       // 192 kbps bitrate
+		"-progress", "pipe:1", // Machine-readable progress on stdout
+		"-nostats",
 		outputPath,
 	)
 
 	return exec.Command(m.ffmpegPath, args...)
 }
 
-// executeFFmpegCommand runs the ffmpeg command with progress tracking
-func (m *AudioMerger) executeFFmpegCommand(ctx context.Context, cmd *exec.Cmd, progressCallback func(MergeProgress)) error {
-	// Create pipes for stderr to capture ffmpeg output
+// clipFilter returns the filter chain reading a clip from ffmpeg input and
+// placing it on the mix's timeline, trimmed, automated, delayed, amplified
+// and panned as the project says
+func clipFilter(track TrackInfo, input int) string {
+	delayFilter := fmt.Sprintf("[%d:a]", input)
+
+	// Cut the clip out of its file, restarting its time at zero
+	if track.Start > 0 || track.Duration > 0 {
+		delayFilter += fmt.Sprintf("atrim=start=%.3f", track.Start)
+		if track.Duration > 0 {
+			delayFilter += fmt.Sprintf(":duration=%.3f", track.Duration)
+		}
+		delayFilter += ",asetpts=PTS-STARTPTS,"
+	}
+
+	// Apply volume automation while the time is still that of the clip.
+	// Quoting keeps the filtergraph from splitting the expression at commas.
+	if expr := envelopeExpression(track.Envelope); expr != "" {
+		delayFilter += fmt.Sprintf("volume='%s':eval=frame,", expr)
+	}
+
+	// Create adelay filter for each track
+	delayFilter += fmt.Sprintf("adelay=%.3fs:all=1", track.Offset)
+
+	// Apply gain if not default (1.0)
+	if track.Gain != 1.0 && track.Gain != 0.0 {
+		delayFilter += fmt.Sprintf(",volume=%.3f", track.Gain)
+	}
+
+	// Apply pan if not center (0.0)
+	if track.Pan != 0.0 {
+		// Convert pan value (-1.0 to 1.0) to ffmpeg pan format
+		panValue := (track.Pan + 1.0) / 2.0 // Convert to 0-1 range
+		delayFilter += fmt.Sprintf(",pan=stereo|c0<%g*c0+%g*c1|c1<%g*c0+%g*c1",
+			1-panValue, panValue, panValue, 1-panValue)
+	}
+	return delayFilter
+}
+
+// trackTimelines groups the clips by the project track holding them, in the
+// order the tracks first appear, returning the indexes of each track's clips
+func trackTimelines(tracks []TrackInfo) [][]int {
+	var timelines [][]int
+	byTrack := make(map[int]int)
+	for i, track := range tracks {
+		if track.Track == 0 {
+			timelines = append(timelines, []int{i})
+			continue
+		}
+		timeline, ok := byTrack[track.Track]
+		if !ok {
+			timeline = len(timelines)
+			byTrack[track.Track] = timeline
+			timelines = append(timelines, nil)
+		}
+		timelines[timeline] = append(timelines[timeline], i)
+	}
+	return timelines
+}
+
+// envelopeExpression returns an ffmpeg expression of the gain of an envelope
+// at time t, or "" when the envelope leaves the volume alone. Each segment is
+// a separate term, rather than a nested if, so long envelopes stay shallow.
+func envelopeExpression(points []EnvelopePoint) string {
+	if len(points) == 0 {
+		return ""
+	}
+	points = append([]EnvelopePoint(nil), points...)
+	sort.SliceStable(points, func(i, j int) bool { return points[i].Time < points[j].Time })
+	flat := true
+	for i := range points {
+		points[i].Value = math.Max(points[i].Value, envelopeFloor)
+		flat = flat && points[i].Value == 1
+	}
+	if flat {
+		return ""
+	}
+
+	first, last := points[0], points[len(points)-1]
+	if len(points) == 1 {
+		return fmt.Sprintf("%g", first.Value)
+	}
+	terms := []string{
+		fmt.Sprintf("lt(t,%g)*%g", first.Time, first.Value),
+		fmt.Sprintf("gte(t,%g)*%g", last.Time, last.Value),
+	}
+	for i := 0; i+1 < len(points); i++ {
+		from, to := points[i], points[i+1]
+		if to.Time <= from.Time {
+			continue // A jump: the next segment starts at the new gain
+		}
+		term := fmt.Sprintf("gte(t,%g)*lt(t,%g)*%g", from.Time, to.Time, from.Value)
+		if to.Value != from.Value {
+			term += fmt.Sprintf("*pow(%g,(t-%g)/%g)", to.Value/from.Value, from.Time, to.Time-from.Time)
+		}
+		terms = append(terms, term)
+	}
+	return strings.Join(terms, "+")
+}
+
+// executeFFmpegCommand runs the ffmpeg command, reporting how much of the
+// mix of tracks it has written as progress from one percentage to another
+func (m *AudioMerger) executeFFmpegCommand(ctx context.Context, cmd *exec.Cmd, tracks []TrackInfo, from, to float64, progressCallback func(MergeProgress)) error {
+	if err := faults.Inject(faults.FFmpeg); err != nil {
+		return fmt.Errorf("ffmpeg failed: %w", err)
+	}
+
+	// ffmpeg logs the length of each input on stderr and writes -progress
+	// blocks on stdout
 	stderr, err := cmd.StderrPipe()
 	if err != nil {
 		return fmt.Errorf("failed to create stderr pipe: %w", err)
 	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return fmt.Errorf("failed to create stdout pipe: %w", err)
+	}
 
 	// Start the command
 	if err := cmd.Start(); err != nil {
 		return fmt.Errorf("failed to start ffmpeg: %w", err)
 	}
 
-	// Monitor stderr for progress (ffmpeg outputs progress to stderr)
+	// The inputs are logged before any output is written
+	var durations map[int]float64
+	inputsRead := make(chan struct{})
+	var readers sync.WaitGroup
+	readers.Add(2)
+	go func() {
+		defer readers.Done()
+		durations, _ = ReadFFmpegInputDurations(stderr)
+		close(inputsRead)
+		io.Copy(io.Discard, stderr)
+	}()
 	go func() {
-		buf := make([]byte, 1024)
-		progressReported := 25.0 // Start from 25% (after validation and setup)
-		
-		for {
-			n, err := stderr.Read(buf)
-			if n > 0 {
-				output := string(buf[:n])
-				
-				// Simple progress estimation based on ffmpeg output
-				// In a production system, you'd parse the actual progress info
-				if strings.Contains(output, "time=") && progressCallback != nil {
-					progressReported += 2.0 // Increment progress
-					if progressReported > 95.0 {
-						progressReported = 95.0 // Cap at 95% until completion
-					}
-					progressCallback(MergeProgress{
-						Stage:    "processing", 
-						Progress: progressReported,
-					})
-				}
+		defer readers.Done()
+		reported := -1.0
+		ReadFFmpegProgress(stdout, func(seconds float64, done bool) {
+			if progressCallback == nil {
+				return
 			}
-			if err != nil {
-				break
+			<-inputsRead
+			total := MergedDuration(tracks, durations)
+			if total <= 0 {
+				return
 			}
-		}
+			fraction := math.Min(seconds/total, 1)
+			if done {
+				fraction = 1
+			}
+			progress := from + fraction*(to-from)
+			// Blocks arrive twice a second; only whole percents are news
+			if math.Floor(progress) <= reported {
+				return
+			}
+			reported = math.Floor(progress)
+			progressCallback(MergeProgress{
+				Stage:            "processing",
+				Progress:         progress,
+				ProcessedSeconds: math.Min(seconds, total),
+				TotalSeconds:     total,
+			})
+		})
 	}()
 
 	// Wait for the command to complete or context to be cancelled
 	done := make(chan error, 1)
 	go func() {
+		// Wait closes the pipes, so the readers must finish first
+		readers.Wait()
 		done <- cmd.Wait()
 	}()
 
@@ -0,0 +1,108 @@
+package audio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+)
+
+// IngestMetadata reads embedded tags from the job's audio file and prefills
+// empty job fields. The tag title replaces the current title only when
+// overrideTitle is set (e.g. when the title is just the filename). Without a
+// recording date in the tags, one is parsed from originalName.
+func IngestMetadata(fs fsys.FS, job *models.TranscriptionJob, originalName string, overrideTitle bool) (*Tags, error) {
+	tags, err := readJobTags(fs, job.AudioPath)
+	if tags != nil {
+		ApplyTags(job, tags, overrideTitle)
+	}
+	if job.RecordedAt == nil {
+		if recordedAt := ParseFilenameDate(originalName); recordedAt != nil {
+			source := RecordedAtSourceFilename
+			job.RecordedAt = recordedAt
+			job.RecordedAtSource = &source
+		}
+	}
+	return tags, err
+}
+
+func readJobTags(fs fsys.FS, path string) (*Tags, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	tags, err := ReadTags(f)
+	if errors.Is(err, ErrNoTags) {
+		return nil, nil
+	}
+	return tags, err
+}
+
+// ApplyTags copies tag values into a job without overwriting fields the
+// user already provided
+func ApplyTags(job *models.TranscriptionJob, tags *Tags, overrideTitle bool) {
+	if tags.Title != "" && (job.Title == nil || overrideTitle) {
+		title := tags.Title
+		job.Title = &title
+	}
+	if job.RecordedAt == nil && tags.RecordedAt != nil {
+		recordedAt := *tags.RecordedAt
+		source := RecordedAtSourceMetadata
+		job.RecordedAt = &recordedAt
+		job.RecordedAtSource = &source
+	}
+	if keywords := tags.Keywords(); job.Tags == nil && len(keywords) > 0 {
+		if data, err := json.Marshal(keywords); err == nil {
+			encoded := string(data)
+			job.Tags = &encoded
+		}
+	}
+	if data, err := json.Marshal(tags); err == nil {
+		encoded := string(data)
+		job.AudioMetadata = &encoded
+	}
+}
+
+// StripMetadata removes tags, cover art and chapters from a file in place
+// by remuxing it with ffmpeg; the audio streams are copied untouched
+func StripMetadata(ctx context.Context, ffmpegPath, path string) error {
+	ext := filepath.Ext(path)
+	tmpPath := strings.TrimSuffix(path, ext) + ".stripped" + ext
+
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-y",
+		"-i", path,
+		"-map", "0:a",
+		"-map_metadata", "-1",
+		"-map_chapters", "-1",
+		"-c", "copy",
+		tmpPath)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "strip_metadata", start, "path", path)
+	if err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("ffmpeg failed to strip metadata: %w: %s", err, lastLine(string(output)))
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return fmt.Errorf("failed to replace file with stripped copy: %w", err)
+	}
+	return nil
+}
+
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return lines[len(lines)-1]
+}
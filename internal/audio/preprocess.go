@@ -0,0 +1,248 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/internal/faults"
+	"synthezia/pkg/logger"
+)
+
+// PreprocessSampleRate is the rate Preprocess resamples to, the one the
+// speech models expect
+const PreprocessSampleRate = 16000
+
+const (
+	// silenceThreshold is the level, about -50 dBFS, below which a 16-bit
+	// sample counts as silence
+	silenceThreshold = 100
+	// silencePad is how much of the trimmed silence is kept, so the first
+	// and last words are not clipped
+	silencePad = 250 * time.Millisecond
+)
+
+// PreprocessOptions selects the clean-up applied to a recording before it is
+// transcribed. The output is always 16 kHz mono, which is enough on its own
+// to resample a recording.
+type PreprocessOptions struct {
+	Normalize   bool // EBU R128 loudness normalization
+	TrimSilence bool // Cut the silence before the first and after the last sound
+	Denoise     bool // Cut rumble and reduce broadband noise
+}
+
+// PreprocessFilters returns the ffmpeg audio filter chain for o, or "" when
+// no filter is selected. Noise is reduced before the loudness is normalized
+// so the noise floor is not raised with the speech.
+func PreprocessFilters(o PreprocessOptions) string {
+	var filters []string
+	if o.Denoise {
+		filters = append(filters, "highpass=f=80", "afftdn=nf=-25")
+	}
+	if o.Normalize {
+		filters = append(filters, "loudnorm=I=-16:TP=-1.5:LRA=11")
+	}
+	return strings.Join(filters, ",")
+}
+
+// Preprocess writes a 16 kHz mono 16-bit WAV copy of src to dst with o
+// applied. It returns how many seconds of silence were trimmed from the
+// start, which timestamps in a transcript of dst must be shifted by to
+// match src.
+func Preprocess(ctx context.Context, ffmpegPath, src, dst string, o PreprocessOptions) (float64, error) {
+	args := []string{"-y", "-i", src, "-vn"}
+	if filters := PreprocessFilters(o); filters != "" {
+		args = append(args, "-af", filters)
+	}
+	args = append(args,
+		"-ar", strconv.Itoa(PreprocessSampleRate),
+		"-ac", "1",
+		"-c:a", "pcm_s16le",
+		"-f", "wav",
+		dst)
+
+	if err := faults.Inject(faults.FFmpeg); err != nil {
+		return 0, fmt.Errorf("ffmpeg failed to preprocess audio: %w", err)
+	}
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "preprocess", start, "path", src)
+	if err != nil {
+		os.Remove(dst)
+		return 0, fmt.Errorf("ffmpeg failed to preprocess audio: %w: %s", err, lastLine(string(output)))
+	}
+
+	if !o.TrimSilence {
+		return 0, nil
+	}
+	offset, err := TrimSilence(dst)
+	if err != nil {
+		os.Remove(dst)
+		return 0, err
+	}
+	return offset, nil
+}
+
+// wavFormat is the part of a WAV header TrimSilence needs
+type wavFormat struct {
+	channels   uint16
+	sampleRate uint32
+	bits       uint16
+	dataStart  int64
+	dataLen    int64
+}
+
+// TrimSilence cuts the silence before the first and after the last sound of
+// a 16-bit PCM WAV file in place, keeping a short pad, and returns how many
+// seconds were cut from the start. A file that is silent throughout is left
+// as it is.
+func TrimSilence(path string) (float64, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return 0, err
+	}
+	defer f.Close()
+
+	format, err := readWAVFormat(f)
+	if err != nil {
+		return 0, err
+	}
+	frameSize := int64(format.channels) * 2
+	frames := format.dataLen / frameSize
+
+	// Find the first and last frame with a sample above the threshold
+	first, last := int64(-1), int64(-1)
+	r := io.NewSectionReader(f, format.dataStart, frames*frameSize)
+	buf := make([]byte, 4096*frameSize)
+	for done := int64(0); done < frames; {
+		n, err := io.ReadFull(r, buf[:min(int64(len(buf)), (frames-done)*frameSize)])
+		if err != nil {
+			return 0, fmt.Errorf("failed to read audio: %w", err)
+		}
+		for i := 0; i < n; i += 2 {
+			sample := int16(binary.LittleEndian.Uint16(buf[i:]))
+			if sample > silenceThreshold || sample < -silenceThreshold {
+				frame := done + int64(i)/frameSize
+				if first < 0 {
+					first = frame
+				}
+				last = frame
+			}
+		}
+		done += int64(n) / frameSize
+	}
+	if first < 0 {
+		return 0, nil
+	}
+
+	pad := int64(silencePad.Seconds() * float64(format.sampleRate))
+	first = max(first-pad, 0)
+	last = min(last+pad, frames-1)
+	if first == 0 && last == frames-1 {
+		return 0, nil
+	}
+
+	// Write the kept frames to a new file and swap it in
+	tmpPath := path + ".trimmed"
+	out, err := os.Create(tmpPath)
+	if err != nil {
+		return 0, err
+	}
+	keep := (last - first + 1) * frameSize
+	w := bufio.NewWriter(out)
+	err = writeWAVHeader(w, format, keep)
+	if err == nil {
+		_, err = io.Copy(w, io.NewSectionReader(f, format.dataStart+first*frameSize, keep))
+	}
+	if err == nil {
+		err = w.Flush()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmpPath, path)
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return 0, fmt.Errorf("failed to write trimmed audio: %w", err)
+	}
+	return float64(first) / float64(format.sampleRate), nil
+}
+
+// readWAVFormat reads the format of a 16-bit PCM WAV file and finds its data
+func readWAVFormat(f *os.File) (wavFormat, error) {
+	var format wavFormat
+	header := make([]byte, 12)
+	if _, err := io.ReadFull(f, header); err != nil || string(header[:4]) != "RIFF" || string(header[8:]) != "WAVE" {
+		return format, errors.New("not a WAV file")
+	}
+	offset := int64(12)
+	haveFormat := false
+	for {
+		chunk := make([]byte, 8)
+		if _, err := f.ReadAt(chunk, offset); err != nil {
+			return format, errors.New("WAV file has no audio data")
+		}
+		id, size := string(chunk[:4]), int64(binary.LittleEndian.Uint32(chunk[4:]))
+		offset += 8
+		switch id {
+		case "fmt ":
+			body := make([]byte, 16)
+			if _, err := f.ReadAt(body, offset); err != nil {
+				return format, errors.New("truncated WAV format")
+			}
+			if binary.LittleEndian.Uint16(body[0:]) != 1 || binary.LittleEndian.Uint16(body[14:]) != 16 {
+				return format, errors.New("WAV audio is not 16-bit PCM")
+			}
+			format.channels = binary.LittleEndian.Uint16(body[2:])
+			format.sampleRate = binary.LittleEndian.Uint32(body[4:])
+			format.bits = 16
+			if format.channels == 0 || format.sampleRate == 0 {
+				return format, errors.New("invalid WAV format")
+			}
+			haveFormat = true
+		case "data":
+			if !haveFormat {
+				return format, errors.New("WAV data before its format")
+			}
+			format.dataStart = offset
+			format.dataLen = size
+			// ffmpeg leaves the size unset when it could not seek back
+			if info, err := f.Stat(); err == nil && (size == 0 || size == 0xFFFFFFFF || offset+size > info.Size()) {
+				format.dataLen = info.Size() - offset
+			}
+			return format, nil
+		}
+		offset += size + size%2
+	}
+}
+
+// writeWAVHeader writes the header of a PCM WAV file holding dataLen bytes
+func writeWAVHeader(w io.Writer, format wavFormat, dataLen int64) error {
+	blockAlign := format.channels * format.bits / 8
+	header := make([]byte, 44)
+	copy(header[0:], "RIFF")
+	binary.LittleEndian.PutUint32(header[4:], uint32(36+dataLen))
+	copy(header[8:], "WAVEfmt ")
+	binary.LittleEndian.PutUint32(header[16:], 16)
+	binary.LittleEndian.PutUint16(header[20:], 1)
+	binary.LittleEndian.PutUint16(header[22:], format.channels)
+	binary.LittleEndian.PutUint32(header[24:], format.sampleRate)
+	binary.LittleEndian.PutUint32(header[28:], format.sampleRate*uint32(blockAlign))
+	binary.LittleEndian.PutUint16(header[32:], blockAlign)
+	binary.LittleEndian.PutUint16(header[34:], format.bits)
+	copy(header[36:], "data")
+	binary.LittleEndian.PutUint32(header[40:], uint32(dataLen))
+	_, err := w.Write(header)
+	return err
+}
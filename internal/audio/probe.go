@@ -0,0 +1,184 @@
+package audio
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+)
+
+// ErrNoAudioStream is returned by ParseProbe for files without audio
+var ErrNoAudioStream = errors.New("no audio stream found")
+
+// ProbeResult holds what ffprobe reports about a recording
+type ProbeResult struct {
+	Container  string  `json:"container"` // ffprobe format names, e.g. "mov,mp4,m4a,3gp,3g2,mj2"
+	Codec      string  `json:"codec"`     // Codec of the first audio stream
+	Duration   float64 `json:"duration"`  // Seconds
+	SampleRate int     `json:"sample_rate"`
+	Channels   int     `json:"channels"`
+	BitRate    int64   `json:"bit_rate"` // Bits per second; 0 when unknown
+}
+
+// probeOutput is the part of ffprobe's JSON output ParseProbe reads
+type probeOutput struct {
+	Streams []struct {
+		CodecType  string `json:"codec_type"`
+		CodecName  string `json:"codec_name"`
+		SampleRate string `json:"sample_rate"`
+		Channels   int    `json:"channels"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"streams"`
+	Format struct {
+		FormatName string `json:"format_name"`
+		Duration   string `json:"duration"`
+		BitRate    string `json:"bit_rate"`
+	} `json:"format"`
+}
+
+// Probe runs ffprobe on path and returns its first audio stream. An error
+// wrapping exec.ErrNotFound means ffprobe is not installed.
+func Probe(ctx context.Context, ffprobePath, path string) (*ProbeResult, error) {
+	cmd := exec.CommandContext(ctx, ffprobePath,
+		"-v", "error",
+		"-print_format", "json",
+		"-show_format",
+		"-show_streams",
+		path)
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	start := time.Now()
+	output, err := cmd.Output()
+	logger.FFmpegStage(ctx, "probe", start, "path", path)
+	if err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			return nil, err
+		}
+		if reason := lastLine(stderr.String()); reason != "" {
+			return nil, fmt.Errorf("ffprobe cannot read the file: %s", reason)
+		}
+		return nil, fmt.Errorf("ffprobe cannot read the file: %w", err)
+	}
+	return ParseProbe(output)
+}
+
+// ParseProbe reads the JSON output of ffprobe -show_format -show_streams.
+// Durations and bit rates missing from the stream are taken from the
+// container.
+func ParseProbe(output []byte) (*ProbeResult, error) {
+	var data probeOutput
+	if err := json.Unmarshal(output, &data); err != nil {
+		return nil, fmt.Errorf("failed to parse ffprobe output: %w", err)
+	}
+	for _, stream := range data.Streams {
+		if stream.CodecType != "audio" {
+			continue
+		}
+		result := &ProbeResult{
+			Container: data.Format.FormatName,
+			Codec:     stream.CodecName,
+			Channels:  stream.Channels,
+		}
+		result.SampleRate, _ = strconv.Atoi(stream.SampleRate)
+		if duration, err := strconv.ParseFloat(stream.Duration, 64); err == nil {
+			result.Duration = duration
+		} else if duration, err := strconv.ParseFloat(data.Format.Duration, 64); err == nil {
+			result.Duration = duration
+		}
+		if bitRate, err := strconv.ParseInt(stream.BitRate, 10, 64); err == nil {
+			result.BitRate = bitRate
+		} else if bitRate, err := strconv.ParseInt(data.Format.BitRate, 10, 64); err == nil {
+			result.BitRate = bitRate
+		}
+		return result, nil
+	}
+	return nil, ErrNoAudioStream
+}
+
+// containerNames maps file extensions to the ffprobe format names their
+// content may be detected as. Extensions not listed are not checked.
+var containerNames = map[string][]string{
+	".mp3":  {"mp3"},
+	".wav":  {"wav"},
+	".flac": {"flac"},
+	".ogg":  {"ogg"},
+	".oga":  {"ogg"},
+	".opus": {"ogg"},
+	".m4a":  {"mp4", "m4a", "mov"},
+	".m4b":  {"mp4", "m4a", "mov"},
+	".mp4":  {"mp4", "m4a", "mov"},
+	".mov":  {"mp4", "m4a", "mov"},
+	".aac":  {"aac"},
+	".webm": {"webm", "matroska"},
+	".mkv":  {"webm", "matroska"},
+	".mka":  {"webm", "matroska"},
+	".wma":  {"asf"},
+	".wmv":  {"asf"},
+	".avi":  {"avi"},
+	".aiff": {"aiff"},
+	".aif":  {"aiff"},
+	".amr":  {"amr"},
+}
+
+// ContainerMismatchError is a file whose content is not what its extension claims
+type ContainerMismatchError struct {
+	Extension string // The claimed extension, e.g. ".mp3"
+	Container string // What ffprobe detected
+}
+
+func (e *ContainerMismatchError) Error() string {
+	return fmt.Sprintf("file has a %s extension but contains %s data", e.Extension, e.Container)
+}
+
+// CheckContainer returns a *ContainerMismatchError when the container ffprobe
+// detected is not one a file named filename should hold, e.g. a WAV file
+// renamed to .mp3
+func (r *ProbeResult) CheckContainer(filename string) error {
+	ext := strings.ToLower(filepath.Ext(filename))
+	expected, ok := containerNames[ext]
+	if !ok || r.Container == "" {
+		return nil
+	}
+	// ffprobe lists every name of a demuxer, e.g. "matroska,webm"
+	for _, name := range strings.Split(r.Container, ",") {
+		for _, want := range expected {
+			if name == want {
+				return nil
+			}
+		}
+	}
+	return &ContainerMismatchError{Extension: ext, Container: r.Container}
+}
+
+// Apply stores the probed properties on a job
+func (r *ProbeResult) Apply(job *models.TranscriptionJob) {
+	if r.Duration > 0 {
+		duration := r.Duration
+		job.AudioDuration = &duration
+	}
+	if r.SampleRate > 0 {
+		sampleRate := r.SampleRate
+		job.AudioSampleRate = &sampleRate
+	}
+	if r.Channels > 0 {
+		channels := r.Channels
+		job.AudioChannels = &channels
+	}
+	if r.Codec != "" {
+		codec := r.Codec
+		job.AudioCodec = &codec
+	}
+	if r.BitRate > 0 {
+		bitRate := r.BitRate
+		job.AudioBitRate = &bitRate
+	}
+}
@@ -0,0 +1,111 @@
+package audio
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ProjectParser reads the clips of a multi-track project saved by an audio
+// editor. Each clip becomes a TrackInfo whose FilePath is the audio file as
+// the project records it, often an absolute path on the machine the project
+// was made on; match it to the uploaded tracks with ProjectFileName.
+type ProjectParser interface {
+	Parse(data []byte) ([]TrackInfo, error)
+}
+
+// projectParsers maps project file extensions to their parsers
+var projectParsers = map[string]func() ProjectParser{
+	".aaf":    func() ProjectParser { return NewAafParser() },
+	".ardour": func() ProjectParser { return NewArdourParser() },
+	".aup":    func() ProjectParser { return NewAupParser() },
+	".rpp":    func() ProjectParser { return NewRppParser() },
+}
+
+// ProjectExtractor reads a project that keeps its audio inside the project
+// file itself. Extract writes each clip to a WAV file in dir and returns its
+// TrackInfo, with FilePath the name of the file written; ParseFile returns
+// the same tracks without writing any audio.
+type ProjectExtractor interface {
+	ParseFile(path string) ([]TrackInfo, error)
+	Extract(path, dir string) ([]TrackInfo, error)
+}
+
+// projectExtractors maps project file extensions to their extractors
+var projectExtractors = map[string]func() ProjectExtractor{
+	".aup3": func() ProjectExtractor { return NewAup3Parser() },
+}
+
+// ProjectParserFor returns the parser for a project file, chosen by its extension
+func ProjectParserFor(filename string) (ProjectParser, bool) {
+	newParser, ok := projectParsers[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return nil, false
+	}
+	return newParser(), true
+}
+
+// ProjectExtractorFor returns the extractor for a project file that embeds
+// its audio, chosen by its extension
+func ProjectExtractorFor(filename string) (ProjectExtractor, bool) {
+	newExtractor, ok := projectExtractors[strings.ToLower(filepath.Ext(filename))]
+	if !ok {
+		return nil, false
+	}
+	return newExtractor(), true
+}
+
+// IsProjectFile reports whether filename is a project a parser or an
+// extractor can read
+func IsProjectFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	_, parsed := projectParsers[ext]
+	_, extracted := projectExtractors[ext]
+	return parsed || extracted
+}
+
+// EmbedsAudio reports whether filename is a project that holds its own
+// audio, to be extracted rather than uploaded alongside it
+func EmbedsAudio(filename string) bool {
+	_, ok := projectExtractors[strings.ToLower(filepath.Ext(filename))]
+	return ok
+}
+
+// ProjectExtensions returns the extensions of the supported project files
+func ProjectExtensions() []string {
+	extensions := make([]string, 0, len(projectParsers)+len(projectExtractors))
+	for ext := range projectParsers {
+		extensions = append(extensions, ext)
+	}
+	for ext := range projectExtractors {
+		extensions = append(extensions, ext)
+	}
+	sort.Strings(extensions)
+	return extensions
+}
+
+// ParseProjectFile reads the project file at path with the parser or the
+// extractor for its extension
+func ParseProjectFile(path string) ([]TrackInfo, error) {
+	if extractor, ok := ProjectExtractorFor(path); ok {
+		return extractor.ParseFile(path)
+	}
+	parser, ok := ProjectParserFor(path)
+	if !ok {
+		return nil, fmt.Errorf("unsupported project file: %s", filepath.Base(path))
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read project file: %w", err)
+	}
+	return parser.Parse(data)
+}
+
+// ProjectFileName returns the name of a file a project references, whether
+// the project was saved on Windows or elsewhere
+func ProjectFileName(file string) string {
+	return path.Base(strings.ReplaceAll(file, "\\", "/"))
+}
@@ -0,0 +1,191 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"math"
+	"os/exec"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// Where a QualityReport starts warning about a recording
+const (
+	quietLoudnessLUFS = -35.0 // Integrated loudness below which speech is easily missed
+	clippingPeakDB    = -0.1  // Peak level at which the signal counts as clipped
+	clippingMinCount  = 10    // Clipped peaks before clipping is worth a warning
+	noisySNRDB        = 15.0  // Signal-to-noise ratio below which noise competes with speech
+)
+
+// dropoutFilter finds stretches of near-digital silence, as a failing
+// connection or recorder leaves, that are too short to be a pause
+const dropoutFilter = "silencedetect=noise=-90dB:d=0.05"
+
+// dropoutEdgeSeconds is how close to the start or end of a recording silence
+// is taken for lead-in or tail rather than a dropout
+const dropoutEdgeSeconds = 0.5
+
+var (
+	logPrefix         = regexp.MustCompile(`^\[[^\]]+ @ [^\]]+\]\s?`)
+	ebur128Integrated = regexp.MustCompile(`^\s*I:\s+(-?inf|-?\d+(?:\.\d+)?) LUFS`)
+	ebur128Range      = regexp.MustCompile(`^\s*LRA:\s+(-?\d+(?:\.\d+)?) LU`)
+	ebur128Peak       = regexp.MustCompile(`^\s*Peak:\s+(-?inf|-?\d+(?:\.\d+)?) dBFS`)
+	astatsValue       = regexp.MustCompile(`^(Peak level dB|Peak count|RMS level dB|Noise floor dB): (-?inf|-?\d+(?:\.\d+)?)`)
+)
+
+// Dropout is a stretch, in seconds, where a recording cuts out to silence
+type Dropout struct {
+	Start float64 `json:"start"`
+	End   float64 `json:"end"`
+}
+
+// QualityReport describes how well a recording lends itself to
+// transcription. Levels that are minus infinity, as in digital silence, are
+// left out.
+type QualityReport struct {
+	Duration           float64   `json:"duration"`                      // Seconds
+	IntegratedLoudness *float64  `json:"integrated_loudness,omitempty"` // LUFS (EBU R128)
+	LoudnessRange      *float64  `json:"loudness_range,omitempty"`      // LU
+	TruePeak           *float64  `json:"true_peak,omitempty"`           // dBTP
+	PeakLevel          *float64  `json:"peak_level,omitempty"`          // dBFS, sample peak
+	RMSLevel           *float64  `json:"rms_level,omitempty"`           // dBFS
+	NoiseFloor         *float64  `json:"noise_floor,omitempty"`         // dBFS
+	SNR                *float64  `json:"snr,omitempty"`                 // dB, RMS level over the noise floor
+	ClippingCount      int64     `json:"clipping_count"`                // Times the signal hit full scale
+	Dropouts           []Dropout `json:"dropouts"`
+	Warnings           []string  `json:"warnings"`
+	GeneratedAt        time.Time `json:"generated_at"`
+}
+
+// AnalyzeQuality measures the loudness, peaks, noise and dropouts of the
+// recording at path in one ffmpeg pass
+func AnalyzeQuality(ctx context.Context, ffmpegPath, path string) (*QualityReport, error) {
+	cmd := exec.CommandContext(ctx, ffmpegPath,
+		"-hide_banner", "-nostdin", "-nostats",
+		"-i", path,
+		"-map", "0:a:0",
+		"-af", "ebur128=peak=true,astats,"+dropoutFilter,
+		"-f", "null", "-")
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "quality_report", start, "path", path)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to analyze audio quality: %v - %s", err, lastLine(string(output)))
+	}
+	return ParseQualityReport(string(output))
+}
+
+// ParseQualityReport builds a report from the log of an ffmpeg run with the
+// ebur128, astats and silencedetect filters, and adds its warnings
+func ParseQualityReport(output string) (*QualityReport, error) {
+	report := &QualityReport{Dropouts: []Dropout{}, Warnings: []string{}, GeneratedAt: time.Now().UTC()}
+	section := ""
+	peakLevel := math.Inf(-1)
+	var peakCount int64
+	found := false
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := logPrefix.ReplaceAllString(scanner.Text(), "")
+		switch {
+		case strings.HasPrefix(line, "Summary:"):
+			section = "summary"
+			continue
+		case strings.HasPrefix(line, "Overall"):
+			section = "overall"
+			continue
+		case strings.HasPrefix(line, "Channel:"):
+			section = "channel"
+			continue
+		}
+
+		switch section {
+		case "summary":
+			if m := ebur128Integrated.FindStringSubmatch(line); m != nil {
+				report.IntegratedLoudness = finiteLevel(m[1])
+				found = true
+			} else if m := ebur128Range.FindStringSubmatch(line); m != nil {
+				report.LoudnessRange = finiteLevel(m[1])
+			} else if m := ebur128Peak.FindStringSubmatch(line); m != nil {
+				report.TruePeak = finiteLevel(m[1])
+			}
+		case "overall":
+			m := astatsValue.FindStringSubmatch(line)
+			if m == nil {
+				continue
+			}
+			found = true
+			switch m[1] {
+			case "Peak level dB":
+				report.PeakLevel = finiteLevel(m[2])
+				if report.PeakLevel != nil {
+					peakLevel = *report.PeakLevel
+				}
+			case "Peak count":
+				peakCount, _ = strconv.ParseInt(m[2], 10, 64)
+			case "RMS level dB":
+				report.RMSLevel = finiteLevel(m[2])
+			case "Noise floor dB":
+				report.NoiseFloor = finiteLevel(m[2])
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if !found {
+		return nil, fmt.Errorf("no loudness statistics in ffmpeg output")
+	}
+
+	if peakLevel >= clippingPeakDB {
+		report.ClippingCount = peakCount
+	}
+	if report.RMSLevel != nil && report.NoiseFloor != nil {
+		snr := math.Round((*report.RMSLevel-*report.NoiseFloor)*10) / 10
+		report.SNR = &snr
+	}
+	silences, duration := ParseSilenceDetect(output)
+	report.Duration = duration
+	for _, silence := range silences {
+		if silence.Start <= dropoutEdgeSeconds || (duration > 0 && silence.End >= duration-dropoutEdgeSeconds) {
+			continue
+		}
+		report.Dropouts = append(report.Dropouts, Dropout{Start: silence.Start, End: silence.End})
+	}
+	report.Warnings = report.warnings()
+	return report, nil
+}
+
+// warnings explains in words what in the report is likely to hurt a transcript
+func (r *QualityReport) warnings() []string {
+	warnings := []string{}
+	if r.IntegratedLoudness != nil && *r.IntegratedLoudness < quietLoudnessLUFS {
+		warnings = append(warnings, fmt.Sprintf("Very quiet recording (%.1f LUFS); quiet speech may be missed", *r.IntegratedLoudness))
+	}
+	if r.ClippingCount >= clippingMinCount {
+		warnings = append(warnings, fmt.Sprintf("Clipping: the signal hits full scale %d times, distorting loud passages", r.ClippingCount))
+	}
+	if r.SNR != nil && *r.SNR < noisySNRDB {
+		warnings = append(warnings, fmt.Sprintf("High background noise (SNR about %.0f dB)", *r.SNR))
+	}
+	switch n := len(r.Dropouts); {
+	case n == 1:
+		warnings = append(warnings, fmt.Sprintf("Dropout: the audio cuts out at %s", FormatOffset(r.Dropouts[0].Start)))
+	case n > 1:
+		warnings = append(warnings, fmt.Sprintf("Dropouts: the audio cuts out %d times, first at %s", n, FormatOffset(r.Dropouts[0].Start)))
+	}
+	return warnings
+}
+
+// finiteLevel parses a level in dB, returning nil for minus infinity
+func finiteLevel(value string) *float64 {
+	level, err := strconv.ParseFloat(value, 64)
+	if err != nil || math.IsInf(level, 0) || math.IsNaN(level) {
+		return nil
+	}
+	return &level
+}
@@ -0,0 +1,47 @@
+package audio
+
+import (
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Sources of a job's recorded_at value
+const (
+	RecordedAtSourceMetadata = "metadata"
+	RecordedAtSourceFilename = "filename"
+	RecordedAtSourceManual   = "manual"
+)
+
+// filenameDatePattern matches the dates recorders and phones put in file
+// names: 20240912_143000, 2024-09-12 14.30.00, REC_2024-09-12-1430, 20240912
+var filenameDatePattern = regexp.MustCompile(
+	`(?:^|[^0-9])((?:19|20)\d{2})[-_.]?(0[1-9]|1[0-2])[-_.]?(0[1-9]|[12]\d|3[01])` +
+		`(?:[T _.-]?([01]\d|2[0-3])[-_.:]?([0-5]\d)(?:[-_.:]?([0-5]\d))?)?(?:[^0-9]|$)`)
+
+// ParseFilenameDate extracts a recording time from a file name. Times are
+// read as local time, which is what recorders write.
+func ParseFilenameDate(name string) *time.Time {
+	base := strings.TrimSuffix(filepath.Base(name), filepath.Ext(name))
+	match := filenameDatePattern.FindStringSubmatch(base)
+	if match == nil {
+		return nil
+	}
+
+	value := match[1] + "-" + match[2] + "-" + match[3]
+	layout := "2006-01-02"
+	if match[4] != "" {
+		value += " " + match[4] + ":" + match[5]
+		layout += " 15:04"
+		if match[6] != "" {
+			value += ":" + match[6]
+			layout += ":05"
+		}
+	}
+	t, err := time.ParseInLocation(layout, value, time.Local)
+	if err != nil {
+		return nil // e.g. February 30th
+	}
+	return &t
+}
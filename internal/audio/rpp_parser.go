@@ -0,0 +1,236 @@
+package audio
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// RppParser handles parsing of Reaper project files. A .rpp file is a tree
+// of blocks, each opened by a "<NAME ..." line and closed by a ">" line,
+// holding lines of space-separated values:
+//
+//	<REAPER_PROJECT 0.1 "7.0/linux-x86_64" 1700000000
+//	  <TRACK
+//	    NAME Host
+//	    VOLPAN 0.5 -0.25 -1 -1 1
+//	    MUTESOLO 0 0 0
+//	    <ITEM
+//	      POSITION 2.5
+//	      MUTE 0 0
+//	      VOLPAN 1 0 1 -1
+//	      <SOURCE WAVE
+//	        FILE "Audio/host.wav"
+//	      >
+//	    >
+//	  >
+//	>
+//
+// Every media item becomes a TrackInfo, its volume and pan combined with
+// those of its track.
+type RppParser struct{}
+
+// NewRppParser creates a new Reaper project parser
+func NewRppParser() *RppParser {
+	return &RppParser{}
+}
+
+// rppTrack is the mix of the track being read
+type rppTrack struct {
+	index  int
+	name   string
+	volume float64
+	pan    float64
+	mute   bool
+	solo   bool
+}
+
+// rppItem is the media item being read
+type rppItem struct {
+	position float64
+	length   float64
+	soffs    float64 // Start of the take being read in its source
+	start    float64 // Start of the chosen take in its source
+	volume   float64
+	pan      float64
+	mute     bool
+	file     string
+	selected bool // The file is that of the take chosen in Reaper
+	takeSel  bool // The next source is that of the chosen take
+}
+
+// Parse extracts the media items of the contents of an .rpp file
+func (p *RppParser) Parse(data []byte) ([]TrackInfo, error) {
+	var blocks []string
+	var track *rppTrack
+	var item *rppItem
+	var tracks []TrackInfo
+	trackCount := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024) // Embedded MIDI and peaks make long lines
+	first := true
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if first {
+			if !strings.HasPrefix(line, "<REAPER_PROJECT") {
+				return nil, errors.New("not a Reaper project")
+			}
+			first = false
+		}
+
+		switch {
+		case strings.HasPrefix(line, "<"):
+			fields := rppFields(line[1:])
+			name := ""
+			if len(fields) > 0 {
+				name = fields[0]
+			}
+			blocks = append(blocks, name)
+			switch name {
+			case "TRACK":
+				trackCount++
+				track = &rppTrack{index: trackCount, volume: 1}
+			case "ITEM":
+				item = &rppItem{volume: 1}
+			}
+			continue
+		case line == ">":
+			if len(blocks) == 0 {
+				return nil, errors.New("unbalanced block in Reaper project")
+			}
+			switch blocks[len(blocks)-1] {
+			case "TRACK":
+				track = nil
+			case "ITEM":
+				if item != nil && item.file != "" {
+					tracks = append(tracks, item.trackInfo(track))
+				}
+				item = nil
+			}
+			blocks = blocks[:len(blocks)-1]
+			continue
+		}
+
+		fields := rppFields(line)
+		if len(fields) < 2 || len(blocks) == 0 {
+			continue
+		}
+		switch block := blocks[len(blocks)-1]; {
+		case block == "TRACK" && track != nil:
+			switch fields[0] {
+			case "NAME":
+				track.name = fields[1]
+			case "VOLPAN":
+				track.volume = rppFloat(fields, 1, 1)
+				track.pan = rppFloat(fields, 2, 0)
+			case "MUTESOLO":
+				track.mute = fields[1] == "1"
+				// Solo is 1, or 2 for solo in place
+				track.solo = len(fields) > 2 && fields[2] != "0"
+			}
+		case block == "ITEM" && item != nil:
+			switch fields[0] {
+			case "POSITION":
+				item.position = rppFloat(fields, 1, 0)
+			case "LENGTH":
+				item.length = rppFloat(fields, 1, 0)
+			case "SOFFS":
+				item.soffs = rppFloat(fields, 1, 0)
+			case "VOLPAN":
+				item.volume = rppFloat(fields, 1, 1)
+				item.pan = rppFloat(fields, 2, 0)
+			case "MUTE":
+				item.mute = fields[1] == "1"
+			case "TAKE":
+				// Items with several takes play the selected one, or else the first
+				item.takeSel = fields[1] == "SEL"
+			}
+		case block == "SOURCE" && item != nil:
+			// A section or reversed source wraps the one naming the file
+			if fields[0] == "FILE" && !item.selected && (item.file == "" || item.takeSel) {
+				item.file = fields[1]
+				item.start = item.soffs
+				item.selected = item.takeSel
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read Reaper project: %w", err)
+	}
+	if first {
+		return nil, errors.New("not a Reaper project")
+	}
+	if len(blocks) != 0 {
+		return nil, errors.New("Reaper project is truncated")
+	}
+	return tracks, nil
+}
+
+// trackInfo combines an item with the mix of its track
+func (item *rppItem) trackInfo(track *rppTrack) TrackInfo {
+	info := TrackInfo{
+		FilePath: item.file,
+		Offset:   item.position,
+		Start:    item.start,
+		Duration: item.length,
+		Gain:     item.volume,
+		Pan:      item.pan,
+		Mute:     item.mute,
+	}
+	if track != nil {
+		info.Gain *= track.volume
+		info.Pan = math.Max(-1, math.Min(1, info.Pan+track.pan))
+		info.Mute = info.Mute || track.mute
+		info.Solo = track.solo
+		info.Name = track.name
+		info.Track = track.index
+	}
+	return info
+}
+
+// rppFields splits a project line into its values. Values holding spaces
+// are quoted with ", ' or `, whichever the value does not contain.
+func rppFields(line string) []string {
+	var fields []string
+	for {
+		line = strings.TrimLeft(line, " \t")
+		if line == "" {
+			return fields
+		}
+		if quote := line[0]; quote == '"' || quote == '\'' || quote == '`' {
+			end := strings.IndexByte(line[1:], quote)
+			if end < 0 {
+				return append(fields, line[1:])
+			}
+			fields = append(fields, line[1:end+1])
+			line = line[end+2:]
+			continue
+		}
+		end := strings.IndexAny(line, " \t")
+		if end < 0 {
+			return append(fields, line)
+		}
+		fields = append(fields, line[:end])
+		line = line[end:]
+	}
+}
+
+// rppFloat parses the i-th value of a line, or returns def
+func rppFloat(fields []string, i int, def float64) float64 {
+	if i >= len(fields) {
+		return def
+	}
+	value, err := strconv.ParseFloat(fields[i], 64)
+	if err != nil {
+		return def
+	}
+	return value
+}
@@ -0,0 +1,671 @@
+package audio
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// ErrNoTags is returned when a file carries no metadata we can read
+var ErrNoTags = errors.New("no supported metadata found")
+
+// maxTagSize bounds how much tag data is read; embedded cover art can be large
+const maxTagSize = 32 << 20
+
+// Tags holds the descriptive metadata embedded in an audio file
+type Tags struct {
+	Format     string     `json:"format"` // id3v2, id3v1, vorbis, mp4
+	Title      string     `json:"title,omitempty"`
+	Artist     string     `json:"artist,omitempty"`
+	Album      string     `json:"album,omitempty"`
+	Genre      string     `json:"genre,omitempty"`
+	Date       string     `json:"date,omitempty"` // Recording date as written in the file
+	RecordedAt *time.Time `json:"recorded_at,omitempty"`
+	Chapters   []Chapter  `json:"chapters,omitempty"`
+}
+
+// Chapter is a named section of the recording, in seconds
+type Chapter struct {
+	Title string  `json:"title"`
+	Start float64 `json:"start"`
+	End   float64 `json:"end,omitempty"`
+}
+
+// IsEmpty reports whether no useful fields were found
+func (t *Tags) IsEmpty() bool {
+	return t.Title == "" && t.Artist == "" && t.Album == "" && t.Genre == "" && t.Date == "" && len(t.Chapters) == 0
+}
+
+// Keywords returns the artist, album and genre as de-duplicated job tags
+func (t *Tags) Keywords() []string {
+	var keywords []string
+	seen := map[string]bool{}
+	for _, value := range []string{t.Artist, t.Album, t.Genre} {
+		if value != "" && !seen[strings.ToLower(value)] {
+			seen[strings.ToLower(value)] = true
+			keywords = append(keywords, value)
+		}
+	}
+	return keywords
+}
+
+// ReadTags detects the container and reads ID3 (MP3), Vorbis comment (FLAC,
+// Ogg Vorbis, Opus) or iTunes-style (MP4/M4A) metadata
+func ReadTags(r io.ReadSeeker) (*Tags, error) {
+	header := make([]byte, 12)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) {
+		return nil, ErrNoTags
+	}
+	header = header[:n]
+
+	var tags *Tags
+	switch {
+	case bytes.HasPrefix(header, []byte("ID3")):
+		tags, err = readID3v2(r)
+		if err == nil {
+			// Fill gaps from a trailing ID3v1 tag
+			if v1, v1Err := readID3v1(r); v1Err == nil {
+				mergeMissing(tags, v1)
+			}
+		}
+	case bytes.HasPrefix(header, []byte("fLaC")):
+		tags, err = readFLAC(r)
+	case bytes.HasPrefix(header, []byte("OggS")):
+		tags, err = readOgg(r)
+	case len(header) >= 8 && string(header[4:8]) == "ftyp":
+		tags, err = readMP4(r)
+	default:
+		tags, err = readID3v1(r)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if tags.IsEmpty() {
+		return nil, ErrNoTags
+	}
+	if tags.Date != "" {
+		tags.RecordedAt = parseTagDate(tags.Date)
+	}
+	return tags, nil
+}
+
+func mergeMissing(dst, src *Tags) {
+	if dst.Title == "" {
+		dst.Title = src.Title
+	}
+	if dst.Artist == "" {
+		dst.Artist = src.Artist
+	}
+	if dst.Album == "" {
+		dst.Album = src.Album
+	}
+	if dst.Date == "" {
+		dst.Date = src.Date
+	}
+}
+
+// dateLayouts are tried in order; a bare year is too coarse for a recording
+// date and is kept only in Tags.Date
+var dateLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04:05",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+func parseTagDate(value string) *time.Time {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if t, err := time.Parse(layout, value); err == nil {
+			return &t
+		}
+	}
+	return nil
+}
+
+// ID3v2
+
+func syncsafe(b []byte) int {
+	return int(b[0]&0x7f)<<21 | int(b[1]&0x7f)<<14 | int(b[2]&0x7f)<<7 | int(b[3]&0x7f)
+}
+
+func readID3v2(r io.ReadSeeker) (*Tags, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 10)
+	if _, err := io.ReadFull(r, header); err != nil {
+		return nil, ErrNoTags
+	}
+	version := header[3]
+	flags := header[5]
+	size := syncsafe(header[6:10])
+	if version < 2 || version > 4 || size > maxTagSize {
+		return nil, ErrNoTags
+	}
+
+	data := make([]byte, size)
+	if _, err := io.ReadFull(r, data); err != nil {
+		return nil, fmt.Errorf("truncated ID3v2 tag: %w", err)
+	}
+	if flags&0x80 != 0 {
+		data = bytes.ReplaceAll(data, []byte{0xff, 0x00}, []byte{0xff})
+	}
+	if flags&0x40 != 0 && version >= 3 && len(data) >= 4 {
+		extSize := int(binary.BigEndian.Uint32(data[:4]))
+		if version == 4 {
+			extSize = syncsafe(data[:4])
+		} else {
+			extSize += 4
+		}
+		if extSize > len(data) {
+			return nil, ErrNoTags
+		}
+		data = data[extSize:]
+	}
+
+	tags := &Tags{Format: "id3v2"}
+	var year, dayMonth, hourMinute string
+	for _, frame := range id3Frames(data, version) {
+		switch frame.id {
+		case "TIT2", "TT2":
+			tags.Title = id3Text(frame.data)
+		case "TPE1", "TP1":
+			tags.Artist = id3Text(frame.data)
+		case "TALB", "TAL":
+			tags.Album = id3Text(frame.data)
+		case "TCON", "TCO":
+			tags.Genre = id3Genre(id3Text(frame.data))
+		case "TDRC":
+			tags.Date = id3Text(frame.data)
+		case "TYER", "TYE":
+			year = id3Text(frame.data)
+		case "TDAT", "TDA":
+			dayMonth = id3Text(frame.data)
+		case "TIME", "TIM":
+			hourMinute = id3Text(frame.data)
+		case "CHAP":
+			if chapter, ok := id3Chapter(frame.data, version); ok {
+				tags.Chapters = append(tags.Chapters, chapter)
+			}
+		}
+	}
+
+	// ID3v2.3 splits the date across TYER (YYYY), TDAT (DDMM) and TIME (HHMM)
+	if tags.Date == "" && year != "" {
+		tags.Date = year
+		if len(dayMonth) == 4 {
+			tags.Date = fmt.Sprintf("%s-%s-%s", year, dayMonth[2:4], dayMonth[0:2])
+			if len(hourMinute) == 4 {
+				tags.Date += fmt.Sprintf("T%s:%s", hourMinute[0:2], hourMinute[2:4])
+			}
+		}
+	}
+	return tags, nil
+}
+
+type id3Frame struct {
+	id   string
+	data []byte
+}
+
+func id3Frames(data []byte, version byte) []id3Frame {
+	var frames []id3Frame
+	idLen, headerLen := 4, 10
+	if version == 2 {
+		idLen, headerLen = 3, 6
+	}
+	for len(data) >= headerLen {
+		id := string(data[:idLen])
+		if data[0] == 0 {
+			break // Padding
+		}
+		var size int
+		switch version {
+		case 2:
+			size = int(data[3])<<16 | int(data[4])<<8 | int(data[5])
+		case 3:
+			size = int(binary.BigEndian.Uint32(data[4:8]))
+		default:
+			size = syncsafe(data[4:8])
+		}
+		if size < 0 || headerLen+size > len(data) {
+			break
+		}
+		body := data[headerLen : headerLen+size]
+		keep := true
+		switch version {
+		case 3:
+			keep = data[9]&0xc0 == 0 // Compressed or encrypted
+		case 4:
+			keep = data[9]&0x0c == 0
+			if data[9]&0x02 != 0 {
+				body = bytes.ReplaceAll(body, []byte{0xff, 0x00}, []byte{0xff})
+			}
+			if data[9]&0x01 != 0 && len(body) >= 4 {
+				body = body[4:] // Data length indicator
+			}
+		}
+		if keep {
+			frames = append(frames, id3Frame{id: id, data: body})
+		}
+		data = data[headerLen+size:]
+	}
+	return frames
+}
+
+// id3Text decodes a text frame, returning its first value
+func id3Text(data []byte) string {
+	if len(data) == 0 {
+		return ""
+	}
+	text := decodeID3String(data[0], data[1:])
+	if i := strings.IndexByte(text, 0); i >= 0 {
+		text = text[:i]
+	}
+	return strings.TrimSpace(text)
+}
+
+func decodeID3String(encoding byte, data []byte) string {
+	switch encoding {
+	case 1, 2:
+		bigEndian := encoding == 2
+		if len(data) >= 2 {
+			if data[0] == 0xff && data[1] == 0xfe {
+				bigEndian, data = false, data[2:]
+			} else if data[0] == 0xfe && data[1] == 0xff {
+				bigEndian, data = true, data[2:]
+			}
+		}
+		units := make([]uint16, 0, len(data)/2)
+		for i := 0; i+1 < len(data); i += 2 {
+			if bigEndian {
+				units = append(units, binary.BigEndian.Uint16(data[i:]))
+			} else {
+				units = append(units, binary.LittleEndian.Uint16(data[i:]))
+			}
+		}
+		return string(utf16.Decode(units))
+	case 3:
+		return string(data)
+	default:
+		return latin1(data)
+	}
+}
+
+func latin1(data []byte) string {
+	runes := make([]rune, len(data))
+	for i, b := range data {
+		runes[i] = rune(b)
+	}
+	return string(runes)
+}
+
+// id3Genre resolves "(17)" style numeric references for the common genres
+// used with spoken audio, leaving anything else untouched
+func id3Genre(value string) string {
+	names := map[string]string{"(12)": "Other", "(101)": "Speech", "(28)": "Vocal", "(186)": "Podcast"}
+	if name, ok := names[value]; ok {
+		return name
+	}
+	return value
+}
+
+// id3Chapter parses a CHAP frame: element ID, start/end milliseconds, byte
+// offsets and embedded sub-frames carrying the chapter title
+func id3Chapter(data []byte, version byte) (Chapter, bool) {
+	end := bytes.IndexByte(data, 0)
+	if end < 0 || len(data) < end+17 {
+		return Chapter{}, false
+	}
+	times := data[end+1:]
+	chapter := Chapter{
+		Start: float64(binary.BigEndian.Uint32(times[0:4])) / 1000,
+		End:   float64(binary.BigEndian.Uint32(times[4:8])) / 1000,
+		Title: string(data[:end]),
+	}
+	for _, sub := range id3Frames(times[16:], version) {
+		if sub.id == "TIT2" {
+			if title := id3Text(sub.data); title != "" {
+				chapter.Title = title
+			}
+		}
+	}
+	return chapter, true
+}
+
+// ID3v1
+
+func readID3v1(r io.ReadSeeker) (*Tags, error) {
+	if _, err := r.Seek(-128, io.SeekEnd); err != nil {
+		return nil, ErrNoTags
+	}
+	block := make([]byte, 128)
+	if _, err := io.ReadFull(r, block); err != nil || string(block[:3]) != "TAG" {
+		return nil, ErrNoTags
+	}
+	field := func(b []byte) string {
+		if i := bytes.IndexByte(b, 0); i >= 0 {
+			b = b[:i]
+		}
+		return strings.TrimSpace(latin1(b))
+	}
+	return &Tags{
+		Format: "id3v1",
+		Title:  field(block[3:33]),
+		Artist: field(block[33:63]),
+		Album:  field(block[63:93]),
+		Date:   field(block[93:97]),
+	}, nil
+}
+
+// Vorbis comments (FLAC, Ogg Vorbis, Opus)
+
+func readFLAC(r io.ReadSeeker) (*Tags, error) {
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 4)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, ErrNoTags
+		}
+		last := header[0]&0x80 != 0
+		blockType := header[0] & 0x7f
+		size := int(header[1])<<16 | int(header[2])<<8 | int(header[3])
+		if blockType == 4 {
+			data := make([]byte, size)
+			if _, err := io.ReadFull(r, data); err != nil {
+				return nil, fmt.Errorf("truncated FLAC comment block: %w", err)
+			}
+			return parseVorbisComments(data)
+		}
+		if last {
+			return nil, ErrNoTags
+		}
+		if _, err := r.Seek(int64(size), io.SeekCurrent); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// readOgg reassembles the second logical packet, which holds the comment
+// header for both Vorbis and Opus streams
+func readOgg(r io.ReadSeeker) (*Tags, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	var packets [][]byte
+	var current []byte
+	header := make([]byte, 27)
+	read := 0
+	for len(packets) < 2 {
+		if _, err := io.ReadFull(r, header); err != nil || string(header[:4]) != "OggS" {
+			return nil, ErrNoTags
+		}
+		segments := make([]byte, header[26])
+		if _, err := io.ReadFull(r, segments); err != nil {
+			return nil, ErrNoTags
+		}
+		for _, lacing := range segments {
+			chunk := make([]byte, lacing)
+			if _, err := io.ReadFull(r, chunk); err != nil {
+				return nil, ErrNoTags
+			}
+			read += int(lacing)
+			if read > maxTagSize {
+				return nil, ErrNoTags
+			}
+			current = append(current, chunk...)
+			if lacing < 255 {
+				packets = append(packets, current)
+				current = nil
+			}
+		}
+	}
+
+	comment := packets[1]
+	switch {
+	case bytes.HasPrefix(comment, []byte("\x03vorbis")):
+		return parseVorbisComments(comment[7:])
+	case bytes.HasPrefix(comment, []byte("OpusTags")):
+		return parseVorbisComments(comment[8:])
+	default:
+		return nil, ErrNoTags
+	}
+}
+
+func parseVorbisComments(data []byte) (*Tags, error) {
+	next := func() ([]byte, bool) {
+		if len(data) < 4 {
+			return nil, false
+		}
+		n := int(binary.LittleEndian.Uint32(data))
+		if n < 0 || 4+n > len(data) {
+			return nil, false
+		}
+		value := data[4 : 4+n]
+		data = data[4+n:]
+		return value, true
+	}
+	if _, ok := next(); !ok { // Vendor string
+		return nil, ErrNoTags
+	}
+	if len(data) < 4 {
+		return nil, ErrNoTags
+	}
+	count := int(binary.LittleEndian.Uint32(data))
+	data = data[4:]
+
+	tags := &Tags{Format: "vorbis"}
+	chapterStarts := map[string]float64{}
+	chapterNames := map[string]string{}
+	for i := 0; i < count; i++ {
+		entry, ok := next()
+		if !ok {
+			break
+		}
+		key, value, found := strings.Cut(string(entry), "=")
+		if !found {
+			continue
+		}
+		key = strings.ToUpper(key)
+		value = strings.TrimSpace(value)
+		switch {
+		case key == "TITLE":
+			tags.Title = value
+		case key == "ARTIST":
+			tags.Artist = value
+		case key == "ALBUM":
+			tags.Album = value
+		case key == "GENRE":
+			tags.Genre = value
+		case key == "DATE":
+			tags.Date = value
+		case strings.HasPrefix(key, "CHAPTER") && strings.HasSuffix(key, "NAME"):
+			chapterNames[strings.TrimSuffix(key, "NAME")] = value
+		case strings.HasPrefix(key, "CHAPTER"):
+			if start, ok := parseClock(value); ok {
+				chapterStarts[key] = start
+			}
+		}
+	}
+
+	// CHAPTERxxx=HH:MM:SS.mmm with CHAPTERxxxNAME=title
+	for i := 0; i < 1000; i++ {
+		key := fmt.Sprintf("CHAPTER%03d", i)
+		start, ok := chapterStarts[key]
+		if !ok {
+			if i == 0 {
+				continue // Numbering may start at 001
+			}
+			break
+		}
+		tags.Chapters = append(tags.Chapters, Chapter{Title: chapterNames[key], Start: start})
+	}
+	fillChapterEnds(tags.Chapters)
+	return tags, nil
+}
+
+// parseClock parses HH:MM:SS(.fff)
+func parseClock(value string) (float64, bool) {
+	parts := strings.Split(value, ":")
+	if len(parts) != 3 {
+		return 0, false
+	}
+	hours, err1 := strconv.Atoi(parts[0])
+	minutes, err2 := strconv.Atoi(parts[1])
+	seconds, err3 := strconv.ParseFloat(parts[2], 64)
+	if err1 != nil || err2 != nil || err3 != nil {
+		return 0, false
+	}
+	return float64(hours*3600+minutes*60) + seconds, true
+}
+
+// fillChapterEnds sets each chapter's end to the next chapter's start
+func fillChapterEnds(chapters []Chapter) {
+	for i := 0; i+1 < len(chapters); i++ {
+		if chapters[i].End == 0 {
+			chapters[i].End = chapters[i+1].Start
+		}
+	}
+}
+
+// MP4 / M4A
+
+// readMP4 walks top-level boxes to moov, then reads moov/udta/meta/ilst for
+// iTunes-style items and moov/udta/chpl for Nero chapters
+func readMP4(r io.ReadSeeker) (*Tags, error) {
+	if _, err := r.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(r, header); err != nil {
+			return nil, ErrNoTags
+		}
+		size := int64(binary.BigEndian.Uint32(header[:4]))
+		boxType := string(header[4:8])
+		headerLen := int64(8)
+		if size == 1 {
+			large := make([]byte, 8)
+			if _, err := io.ReadFull(r, large); err != nil {
+				return nil, ErrNoTags
+			}
+			size = int64(binary.BigEndian.Uint64(large))
+			headerLen = 16
+		}
+		if size != 0 && size < headerLen {
+			return nil, ErrNoTags
+		}
+		if boxType == "moov" {
+			if size == 0 || size-headerLen > maxTagSize {
+				return nil, ErrNoTags
+			}
+			moov := make([]byte, size-headerLen)
+			if _, err := io.ReadFull(r, moov); err != nil {
+				return nil, fmt.Errorf("truncated moov box: %w", err)
+			}
+			return parseMoov(moov), nil
+		}
+		if size == 0 {
+			return nil, ErrNoTags
+		}
+		if _, err := r.Seek(size-headerLen, io.SeekCurrent); err != nil {
+			return nil, ErrNoTags
+		}
+	}
+}
+
+type mp4Box struct {
+	kind string
+	data []byte
+}
+
+func mp4Boxes(data []byte) []mp4Box {
+	var boxes []mp4Box
+	for len(data) >= 8 {
+		size := int(binary.BigEndian.Uint32(data[:4]))
+		if size < 8 || size > len(data) {
+			break
+		}
+		boxes = append(boxes, mp4Box{kind: string(data[4:8]), data: data[8:size]})
+		data = data[size:]
+	}
+	return boxes
+}
+
+func findBox(data []byte, kind string) []byte {
+	for _, box := range mp4Boxes(data) {
+		if box.kind == kind {
+			return box.data
+		}
+	}
+	return nil
+}
+
+func parseMoov(moov []byte) *Tags {
+	tags := &Tags{Format: "mp4"}
+	udta := findBox(moov, "udta")
+	if udta == nil {
+		return tags
+	}
+
+	if meta := findBox(udta, "meta"); len(meta) > 4 {
+		// meta is a full box: skip version and flags
+		for _, item := range mp4Boxes(findBox(meta[4:], "ilst")) {
+			value := mp4ItemText(item.data)
+			switch item.kind {
+			case "\xa9nam":
+				tags.Title = value
+			case "\xa9ART":
+				tags.Artist = value
+			case "\xa9alb":
+				tags.Album = value
+			case "\xa9gen":
+				tags.Genre = value
+			case "\xa9day":
+				tags.Date = value
+			}
+		}
+	}
+
+	// chpl: version/flags, 4 reserved bytes, count, then 100ns start + title
+	if chpl := findBox(udta, "chpl"); len(chpl) >= 9 {
+		count := int(chpl[8])
+		rest := chpl[9:]
+		for i := 0; i < count && len(rest) >= 9; i++ {
+			start := binary.BigEndian.Uint64(rest[:8])
+			titleLen := int(rest[8])
+			if len(rest) < 9+titleLen {
+				break
+			}
+			tags.Chapters = append(tags.Chapters, Chapter{
+				Title: string(rest[9 : 9+titleLen]),
+				Start: float64(start) / 1e7,
+			})
+			rest = rest[9+titleLen:]
+		}
+		fillChapterEnds(tags.Chapters)
+	}
+	return tags
+}
+
+// mp4ItemText reads the UTF-8 payload of an item's data box, which starts
+// with a 4-byte type indicator and a 4-byte locale
+func mp4ItemText(item []byte) string {
+	data := findBox(item, "data")
+	if len(data) < 8 {
+		return ""
+	}
+	return strings.TrimSpace(string(data[8:]))
+}
@@ -0,0 +1,108 @@
+package audio
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/logger"
+)
+
+// ExtractRange writes the stretch of a recording from start to end seconds
+// to outputPath as 16 kHz mono WAV; an end of 0 runs to the end of the
+// recording
+func ExtractRange(ctx context.Context, ffmpegPath, path, outputPath string, start, end float64) error {
+	args := []string{"-v", "error", "-y", "-ss", strconv.FormatFloat(start, 'f', 3, 64)}
+	if end > 0 {
+		args = append(args, "-t", strconv.FormatFloat(end-start, 'f', 3, 64))
+	}
+	args = append(args,
+		"-i", path,
+		"-vn",
+		"-ar", strconv.Itoa(PreprocessSampleRate),
+		"-ac", "1",
+		"-c:a", "pcm_s16le",
+		"-f", "wav",
+		outputPath)
+	cmd := exec.CommandContext(ctx, ffmpegPath, args...)
+	began := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "extract_range", began, "path", path, "start", start, "end", end)
+	if err != nil {
+		os.Remove(outputPath)
+		return fmt.Errorf("ffmpeg failed to extract audio from %s: %w: %s", FormatOffset(start), err, lastLine(string(output)))
+	}
+	return nil
+}
+
+// Splice replaces the stretch from start to end seconds of a transcript with
+// the transcript of that stretch alone, whose timestamps count from start.
+// Segments and words of existing whose midpoint falls in the stretch are
+// dropped; an end of 0 runs to the end of the recording. With no existing
+// transcript the partial one is returned, moved to the recording's time.
+func Splice(existing, partial *interfaces.TranscriptResult, start, end float64) *interfaces.TranscriptResult {
+	if end <= 0 {
+		end = math.Inf(1)
+	}
+	inside := func(from, to float64) bool {
+		mid := (from + to) / 2
+		return mid >= start && mid < end
+	}
+
+	spliced := &interfaces.TranscriptResult{
+		Language:       partial.Language,
+		Confidence:     partial.Confidence,
+		ProcessingTime: partial.ProcessingTime,
+		ModelUsed:      partial.ModelUsed,
+		Metadata:       map[string]string{},
+	}
+	if existing != nil {
+		for key, value := range existing.Metadata {
+			spliced.Metadata[key] = value
+		}
+		for _, segment := range existing.Segments {
+			if !inside(segment.Start, segment.End) {
+				spliced.Segments = append(spliced.Segments, segment)
+			}
+		}
+		for _, word := range existing.WordSegments {
+			if !inside(word.Start, word.End) {
+				spliced.WordSegments = append(spliced.WordSegments, word)
+			}
+		}
+		if spliced.Language == "" {
+			spliced.Language = existing.Language
+		}
+	}
+	for key, value := range partial.Metadata {
+		spliced.Metadata[key] = value
+	}
+	for _, segment := range partial.Segments {
+		segment.Start += start
+		segment.End += start
+		spliced.Segments = append(spliced.Segments, segment)
+	}
+	for _, word := range partial.WordSegments {
+		word.Start += start
+		word.End += start
+		spliced.WordSegments = append(spliced.WordSegments, word)
+	}
+	sort.SliceStable(spliced.Segments, func(i, j int) bool { return spliced.Segments[i].Start < spliced.Segments[j].Start })
+	sort.SliceStable(spliced.WordSegments, func(i, j int) bool { return spliced.WordSegments[i].Start < spliced.WordSegments[j].Start })
+
+	var texts []string
+	for _, segment := range spliced.Segments {
+		if text := strings.TrimSpace(segment.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	spliced.Text = strings.Join(texts, " ")
+	return spliced
+}
@@ -0,0 +1,231 @@
+package audio
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// waveformFlag8Bit marks 8-bit data in the binary waveform header
+const waveformFlag8Bit = 0x1
+
+// Waveform holds the peaks of a recording in the audiowaveform data format
+// (https://github.com/bbc/audiowaveform), which players such as peaks.js
+// read directly. Data alternates the minimum and maximum of each bucket of
+// SamplesPerPixel samples, as 8-bit values.
+type Waveform struct {
+	Version         int    `json:"version"`
+	Channels        int    `json:"channels"`
+	SampleRate      int    `json:"sample_rate"`
+	SamplesPerPixel int    `json:"samples_per_pixel"`
+	Bits            int    `json:"bits"`
+	Length          int    `json:"length"`
+	Data            []int8 `json:"data"`
+}
+
+// Duration returns how long the recording the peaks were taken from lasts,
+// to the nearest bucket
+func (w *Waveform) Duration() time.Duration {
+	if w.SampleRate == 0 {
+		return 0
+	}
+	return time.Duration(float64(w.Length*w.SamplesPerPixel) / float64(w.SampleRate) * float64(time.Second))
+}
+
+// MarshalBinary encodes the waveform as an audiowaveform .dat file
+func (w *Waveform) MarshalBinary() ([]byte, error) {
+	data := make([]byte, 24, 24+len(w.Data))
+	binary.LittleEndian.PutUint32(data[0:], 2)
+	binary.LittleEndian.PutUint32(data[4:], waveformFlag8Bit)
+	binary.LittleEndian.PutUint32(data[8:], uint32(w.SampleRate))
+	binary.LittleEndian.PutUint32(data[12:], uint32(w.SamplesPerPixel))
+	binary.LittleEndian.PutUint32(data[16:], uint32(w.Length))
+	binary.LittleEndian.PutUint32(data[20:], uint32(w.Channels))
+	for _, v := range w.Data {
+		data = append(data, byte(v))
+	}
+	return data, nil
+}
+
+// UnmarshalBinary decodes an 8-bit audiowaveform .dat file
+func (w *Waveform) UnmarshalBinary(data []byte) error {
+	if len(data) < 20 {
+		return errors.New("waveform data is truncated")
+	}
+	version := binary.LittleEndian.Uint32(data[0:])
+	if version != 1 && version != 2 {
+		return fmt.Errorf("unsupported waveform version %d", version)
+	}
+	if binary.LittleEndian.Uint32(data[4:])&waveformFlag8Bit == 0 {
+		return errors.New("only 8-bit waveform data is supported")
+	}
+	header, channels := 20, 1
+	if version == 2 {
+		if len(data) < 24 {
+			return errors.New("waveform data is truncated")
+		}
+		header, channels = 24, int(binary.LittleEndian.Uint32(data[20:]))
+	}
+	length := int(binary.LittleEndian.Uint32(data[16:]))
+	if len(data)-header != 2*length*channels {
+		return errors.New("waveform length does not match its data")
+	}
+	*w = Waveform{
+		Version:         int(version),
+		Channels:        channels,
+		SampleRate:      int(binary.LittleEndian.Uint32(data[8:])),
+		SamplesPerPixel: int(binary.LittleEndian.Uint32(data[12:])),
+		Bits:            8,
+		Length:          length,
+		Data:            make([]int8, len(data)-header),
+	}
+	for i, v := range data[header:] {
+		w.Data[i] = int8(v)
+	}
+	return nil
+}
+
+// WaveformGenerator computes the peaks of recordings with ffmpeg
+type WaveformGenerator struct {
+	ffmpegPath string
+	sampleRate int // Audio is decoded to mono at this rate
+	maxLength  int // At most this many buckets, however long the recording
+}
+
+// NewWaveformGenerator creates a generator decoding at 8 kHz, which is
+// plenty for drawing, into at most 4000 buckets
+func NewWaveformGenerator() *WaveformGenerator {
+	return NewWaveformGeneratorWithPath("ffmpeg")
+}
+
+// NewWaveformGeneratorWithPath creates a generator with a custom ffmpeg path
+func NewWaveformGeneratorWithPath(ffmpegPath string) *WaveformGenerator {
+	return &WaveformGenerator{
+		ffmpegPath: ffmpegPath,
+		sampleRate: 8000,
+		maxLength:  4000,
+	}
+}
+
+// SetMaxLength sets how many buckets a waveform has at most
+func (g *WaveformGenerator) SetMaxLength(n int) {
+	if n > 0 {
+		g.maxLength = n
+	}
+}
+
+// Generate decodes the audio of path and returns its peaks
+func (g *WaveformGenerator) Generate(ctx context.Context, path string) (*Waveform, error) {
+	cmd := exec.CommandContext(ctx, g.ffmpegPath,
+		"-v", "error",
+		"-i", path,
+		"-vn",
+		"-ac", "1",
+		"-ar", strconv.Itoa(g.sampleRate),
+		"-f", "s16le",
+		"-")
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	start := time.Now()
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start ffmpeg: %w", err)
+	}
+	waveform, readErr := g.FromPCM(stdout)
+	// Drain what is left so ffmpeg can exit
+	io.Copy(io.Discard, stdout)
+	err = cmd.Wait()
+	logger.FFmpegStage(ctx, "waveform", start, "input", path)
+	if err != nil {
+		return nil, fmt.Errorf("ffmpeg failed to decode audio: %w: %s", err, lastLine(stderr.String()))
+	}
+	if readErr != nil {
+		return nil, readErr
+	}
+	return waveform, nil
+}
+
+// FromPCM computes the peaks of 16-bit little-endian mono samples at the
+// generator's sample rate. Buckets start at a hundredth of a second and
+// double in size whenever there would be more than the maximum, so long
+// recordings are read in one pass.
+func (g *WaveformGenerator) FromPCM(r io.Reader) (*Waveform, error) {
+	samplesPerPixel := max(g.sampleRate/100, 1)
+	var peaks []int16 // min, max of each complete bucket
+	var low, high int16
+	count := 0
+
+	br := bufio.NewReaderSize(r, 1<<16)
+	sample := make([]byte, 2)
+	for {
+		if _, err := io.ReadFull(br, sample); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				break
+			}
+			return nil, fmt.Errorf("failed to read audio: %w", err)
+		}
+		v := int16(binary.LittleEndian.Uint16(sample))
+		if count == 0 || v < low {
+			low = v
+		}
+		if count == 0 || v > high {
+			high = v
+		}
+		count++
+		if count < samplesPerPixel {
+			continue
+		}
+		peaks = append(peaks, low, high)
+		count = 0
+
+		// Too many buckets: merge them in pairs
+		if len(peaks)/2 > g.maxLength {
+			peaks, low, high, count = mergePeaks(peaks, samplesPerPixel)
+			samplesPerPixel *= 2
+		}
+	}
+	if count > 0 {
+		peaks = append(peaks, low, high)
+	}
+
+	waveform := &Waveform{
+		Version:         2,
+		Channels:        1,
+		SampleRate:      g.sampleRate,
+		SamplesPerPixel: samplesPerPixel,
+		Bits:            8,
+		Length:          len(peaks) / 2,
+		Data:            make([]int8, len(peaks)),
+	}
+	for i, v := range peaks {
+		waveform.Data[i] = int8(v >> 8)
+	}
+	return waveform, nil
+}
+
+// mergePeaks halves the number of buckets by merging neighbours. An odd
+// bucket left over becomes the start of the bucket being filled, returned
+// with how many samples it already holds.
+func mergePeaks(peaks []int16, samplesPerPixel int) ([]int16, int16, int16, int) {
+	merged := peaks[:0]
+	n := len(peaks) / 2
+	for i := 0; i+1 < n; i += 2 {
+		merged = append(merged, min(peaks[2*i], peaks[2*i+2]), max(peaks[2*i+1], peaks[2*i+3]))
+	}
+	if n%2 == 1 {
+		return merged, peaks[2*n-2], peaks[2*n-1], samplesPerPixel
+	}
+	return merged, 0, 0, 0
+}
@@ -13,12 +13,14 @@ import (
 // AuthService handles authentication operations
 type AuthService struct {
 	jwtSecret []byte
+	tickets   *TicketStore
 }
 
 // NewAuthService creates a new authentication service
 func NewAuthService(jwtSecret string) *AuthService {
 	return &AuthService{
 		jwtSecret: []byte(jwtSecret),
+		tickets:   NewTicketStore(),
 	}
 }
 
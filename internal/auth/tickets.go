@@ -0,0 +1,98 @@
+package auth
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"sync"
+	"time"
+
+	"synthezia/pkg/clock"
+)
+
+// TicketTTL is how long a connection ticket can be redeemed
+const TicketTTL = 30 * time.Second
+
+// ErrInvalidTicket is returned for unknown, expired, reused or misdirected tickets
+var ErrInvalidTicket = errors.New("invalid or expired ticket")
+
+// ErrTicketPath is returned when a ticket is issued without the path it opens
+var ErrTicketPath = errors.New("a ticket must be bound to a path")
+
+// TicketIdentity is who a ticket authenticates as
+type TicketIdentity struct {
+	AuthType string // "jwt" or "api_key"
+	UserID   uint
+	Username string
+	APIKey   string
+	APIKeyID uint
+}
+
+type ticket struct {
+	identity  TicketIdentity
+	path      string
+	expiresAt time.Time
+}
+
+// TicketStore holds short-lived, single-use tickets that let browsers open
+// streaming connections without putting a JWT in the URL
+type TicketStore struct {
+	mu      sync.Mutex
+	tickets map[string]ticket
+	clock   clock.Clock
+}
+
+// NewTicketStore creates an empty store
+func NewTicketStore() *TicketStore {
+	return &TicketStore{tickets: map[string]ticket{}, clock: clock.Real}
+}
+
+// SetClock overrides the time source, mainly for tests
+func (s *TicketStore) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// Issue creates a ticket for identity that opens a connection to exactly path
+func (s *TicketStore) Issue(identity TicketIdentity, path string) (string, time.Time, error) {
+	if path == "" {
+		return "", time.Time{}, ErrTicketPath
+	}
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return "", time.Time{}, err
+	}
+	value := hex.EncodeToString(raw)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.clock.Now()
+	for key, t := range s.tickets {
+		if !now.Before(t.expiresAt) {
+			delete(s.tickets, key)
+		}
+	}
+	expiresAt := now.Add(TicketTTL)
+	s.tickets[value] = ticket{identity: identity, path: path, expiresAt: expiresAt}
+	return value, expiresAt, nil
+}
+
+// Redeem consumes a ticket for a connection to path. Each ticket opens one
+// connection only.
+func (s *TicketStore) Redeem(value, path string) (TicketIdentity, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t, ok := s.tickets[value]
+	if !ok {
+		return TicketIdentity{}, ErrInvalidTicket
+	}
+	delete(s.tickets, value)
+	if !s.clock.Now().Before(t.expiresAt) || t.path != path {
+		return TicketIdentity{}, ErrInvalidTicket
+	}
+	return t.identity, nil
+}
+
+// Tickets returns the service's connection ticket store
+func (as *AuthService) Tickets() *TicketStore {
+	return as.tickets
+}
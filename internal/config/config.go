@@ -19,50 +19,488 @@ type Config struct {
 	// Server configuration
 	Port string
 	Host string
+	// Where users reach the web UI, e.g. "https://synthezia.example.com", for
+	// links sent outside the app; empty uses http://Host:Port
+	PublicURL string
 
 	// Database configuration
 	DatabasePath string
+	// Leave schema migrations to the "migrate" command instead of applying
+	// them on startup; the server refuses to start while any are pending
+	DatabaseManualMigrations bool
 
 	// JWT configuration
 	JWTSecret string
 
+	// CSRF protection for cookie-authenticated requests
+	CSRFEnabled bool
+
 	// File storage
 	UploadDir string
+	// TempDir holds files while they are being written; keep it on the same
+	// filesystem as UploadDir so moving them into place is an atomic rename
+	TempDir string
+
+	// Largest file a browser upload token may allow, in megabytes
+	UploadTokenMaxMB int
+
+	// StripAudioMetadata removes embedded tags from stored uploads once they have been read
+	StripAudioMetadata bool
+
+	// What an upload whose Chromaprint fingerprint (computed with fpcalc at
+	// FpcalcPath) matches an existing job's to DuplicateAudioSimilarity
+	// percent or more does: off, warn (record the match on the new job) or
+	// block (reject the upload)
+	DuplicateAudioCheck      string
+	DuplicateAudioSimilarity int
+	FpcalcPath               string
+
+	// DecodeCheckSeconds is how much of each upload and dropzone file is
+	// decoded with ffmpeg before it is accepted, so truncated or corrupt
+	// files are refused up front instead of failing once queued; 0 skips it
+	DecodeCheckSeconds int
 
 	// Python/WhisperX configuration
 	UVPath      string
 	WhisperXEnv string
 
+	// TranscriptionBackend selects "models" (default), "remote" to run the
+	// models on remote workers, or "fake" for CI and demos
+	TranscriptionBackend string
+	// Split recordings longer than this many minutes at silences and
+	// transcribe the pieces in parallel; 0 transcribes them whole
+	TranscriptionChunkMinutes int
+	// How many pieces of one recording are transcribed at once
+	TranscriptionChunkWorkers int
+	// How long the synchronous quick transcription endpoint waits for the
+	// transcript before answering 202 and leaving the job to be polled; a
+	// request may ask for less
+	QuickSyncTimeoutSeconds int
+	// WhisperX options a job gets when it leaves them out, and the limits it
+	// may not go past. TranscriptionModels lists the Whisper models jobs may
+	// pick, comma-separated; empty allows any.
+	TranscriptionDefaultModel       string
+	TranscriptionDefaultComputeType string
+	TranscriptionDefaultDevice      string
+	TranscriptionDefaultBatchSize   int
+	TranscriptionDefaultBeamSize    int
+	TranscriptionModels             string
+	TranscriptionMaxBatchSize       int
+	TranscriptionMaxBeamSize        int
+	// Download and load the default Whisper model at startup, so the first
+	// job does not wait for it
+	ModelWarmup bool
+	// Devices jobs are assigned to: nvidia-smi finds the CUDA GPUs (empty
+	// skips them), GPUJobsPerDevice jobs share one GPU, and CPUJobs run on
+	// the CPU at once (0 does not limit them)
+	NvidiaSMIPath    string
+	GPUJobsPerDevice int
+	CPUJobs          int
+	// Remote workers authenticate with WorkerToken, which also enables their
+	// endpoints, and heartbeat every WorkerHeartbeatSeconds. A worker
+	// (cmd/worker) connects to WorkerServerURL as WorkerName and runs
+	// WorkerJobs tasks at once.
+	WorkerToken            string
+	WorkerHeartbeatSeconds int
+	WorkerServerURL        string
+	WorkerName             string
+	WorkerJobs             int
+	// How many multi-track merges run ffmpeg at once; further merges wait
+	// in the merge_queued state. 0 does not limit them.
+	MaxConcurrentMerges int
+	// How many audio conversions and remote downloads run at once, each
+	// apart from the transcription workers
+	MaxConcurrentConversions int
+	MaxConcurrentDownloads   int
+	// What startup does with jobs the last shutdown or crash left processing:
+	// requeue them, or fail them. A job requeued QueueRecoveryMaxAttempts
+	// times fails instead, so one that crashes the server cannot loop.
+	QueueRecoveryPolicy      string
+	QueueRecoveryMaxAttempts int
+	// Waiting jobs are taken in turns by the users who submitted them rather
+	// than in order, weighted by QueueUserWeights, e.g. "alice=3,bob=2";
+	// users not listed weigh 1
+	QueueFairShare   bool
+	QueueUserWeights string
+
 	// LLM Configuration
 	LLMProvider   string
 	OllamaBaseURL string
 	OpenAIAPIKey  string
 
+	// OpenAI-compatible endpoint turning finished transcripts into insights:
+	// a summary, action items and chapters. Off when the URL is empty.
+	PostProcessURL    string
+	PostProcessAPIKey string
+	PostProcessModel  string
+
 	// YouTube configuration
 	YoutubeCookiesPath string
+
+	// Jobs submitted by URL: the largest download in megabytes, and whether
+	// YouTube and other yt-dlp sites are accepted besides direct media URLs
+	RemoteURLMaxMB   int
+	RemoteURLYoutube bool
+
+	// Comma-separated host names, IP addresses and CIDR networks direct
+	// links may reach although they are loopback, private or link-local
+	RemoteURLAllowedHosts string
+	// HTTP proxy direct links are fetched through. HTTP_PROXY and
+	// HTTPS_PROXY are not used for them, only this setting.
+	RemoteURLProxy string
+
+	// Disk space each user's jobs may take, audio and artifacts, in
+	// megabytes; 0 is unlimited
+	StorageQuotaMB int
+
+	// Where the media and transcripts of completed jobs are kept: empty
+	// leaves them in the data directory, local moves them under
+	// StorageLocalDir and s3 uploads them to an S3-compatible bucket, under
+	// StorageS3Prefix. Local copies are dropped once stored unless
+	// StorageKeepLocal is on, and brought back when a job is transcribed
+	// again. Download links stay valid for StorageURLExpiryMinutes.
+	StorageBackend          string
+	StorageLocalDir         string
+	StorageS3Endpoint       string
+	StorageS3Region         string
+	StorageS3Bucket         string
+	StorageS3Prefix         string
+	StorageS3AccessKey      string
+	StorageS3SecretKey      string
+	StorageS3PathStyle      bool
+	StorageKeepLocal        bool
+	StorageURLExpiryMinutes int
+
+	// OpenTelemetry tracing; disabled when OTLPEndpoint is empty
+	OTLPEndpoint     string
+	OTLPHeaders      string
+	TraceServiceName string
+
+	// Sentry-compatible error reporting; disabled when SentryDSN is empty
+	SentryDSN         string
+	SentryEnvironment string
+
+	// Debug request/response capture limits
+	DebugCaptureMaxEntries   int
+	DebugCaptureMaxBodyBytes int
+
+	// Log outputs: comma-separated stdout, syslog, journald
+	LogOutput      string
+	SyslogAddress  string
+	SyslogFacility string
+	JournaldSocket string
+	// Recent log entries kept in memory for the admin log endpoint; 0 disables
+	LogBufferSize int
+
+	// Per-path request log sampling, e.g. "/health=100,*/status=0"; empty keeps the defaults
+	LogHTTPSampling string
+
+	// Budgets in milliseconds above which operations are logged at WARN; 0 disables
+	SlowRequestMs int
+	SlowQueryMs   int
+	SlowFFmpegMs  int
+
+	// Job lifecycle events: JSON lines file ("stdout" for standard output; empty
+	// disables) and whether to also keep them in the job_events table
+	JobEventsLog string
+	JobEventsDB  bool
+
+	// Directories watched for audio to ingest, each optionally with its own
+	// default user, language and auto-transcribe policy, e.g.
+	// "/mnt/sales;user=alice;language=en,/mnt/legal;auto_transcribe=false".
+	// The dropzone only runs when this is set.
+	DropzonePaths string
+
+	// Dropzone files are ingested once their size and modification time have
+	// not changed for DropzoneSettleSeconds; with DropzoneLockProbe they must
+	// also not be locked by a writer
+	DropzoneSettleSeconds int
+	DropzoneLockProbe     bool
+
+	// Dropzone files and folders whose name matches a glob in DropzoneIgnore
+	// (comma-separated, e.g. ".*,~*,*.part") are never ingested, which keeps
+	// out the temporary files of rsync, Syncthing and browsers. Only files
+	// at most DropzoneMaxDepth levels below a root are ingested, counting
+	// the files directly in the root as level 1 (0 for no limit).
+	DropzoneIgnore   string
+	DropzoneMaxDepth int
+
+	// A JSON or YAML file giving subfolders of the dropzone roots their own
+	// job settings (transcription profile, language, model, diarization,
+	// auto-transcription, source audio action), e.g. {"interviews":
+	// {"language": "fr", "diarize": true}}
+	DropzoneProfiles string
+
+	// Move dropzone files that are empty or that ffprobe cannot read into a
+	// quarantine folder in their root instead of ingesting them
+	DropzoneQuarantine bool
+
+	// What happens to a dropzone file with the same content as an existing
+	// job: off ingests it anyway, skip discards it and link creates a job
+	// marked as a duplicate that reuses the existing transcript
+	DropzoneDedupe string
+
+	// At most DropzoneMaxInFlight dropzone files are ingested at once (0 for
+	// no limit), and ingest pauses while DropzoneQueueHighWater or more jobs
+	// wait to be transcribed (0 never pauses)
+	DropzoneMaxInFlight    int
+	DropzoneQueueHighWater int
+
+	// What happens to dropzone files once ingested: delete, archive moves them
+	// to a "processed/<date>" folder in their root, and keep leaves them in
+	// place next to a "<name>.ingested" marker. Archived days older than
+	// DropzoneArchiveDays are removed (0 keeps them forever).
+	DropzoneRetention   string
+	DropzoneArchiveDays int
+
+	// Cron expression ("0 2 * * *") on which the dropzone roots are swept
+	// for files the watcher missed; empty only sweeps at startup
+	DropzoneSweepSchedule string
+
+	// An S3-compatible bucket (AWS S3, MinIO, ...) polled every
+	// DropzoneS3PollSeconds for audio under DropzoneS3Prefix. Ingested objects
+	// are deleted when DropzoneS3Delete is on and otherwise remembered, so
+	// each is ingested once. DropzoneS3Options takes the same options as a
+	// dropzone root, e.g. "user=alice;language=en". Only runs when a bucket is set.
+	DropzoneS3Endpoint    string
+	DropzoneS3Region      string
+	DropzoneS3Bucket      string
+	DropzoneS3Prefix      string
+	DropzoneS3AccessKey   string
+	DropzoneS3SecretKey   string
+	DropzoneS3PathStyle   bool
+	DropzoneS3PollSeconds int
+	DropzoneS3Delete      bool
+	DropzoneS3Options     string
+
+	// Embedded SFTP server for devices that can only push over SFTP, e.g.
+	// ":2022"; empty disables it. Users log in with their own credentials.
+	// The host key is created at DropzoneSFTPHostKey on first start, and
+	// DropzoneSFTPOptions takes "language=..;auto_transcribe=..".
+	DropzoneSFTPAddr    string
+	DropzoneSFTPHostKey string
+	DropzoneSFTPOptions string
+
+	// An IMAP mailbox, e.g. "imap.example.com:993", polled every
+	// DropzoneIMAPPollSeconds for unread mail, over TLS unless DropzoneIMAPTLS
+	// is off. Each audio attachment becomes a job of the user whose email
+	// matches the sender; mail from anyone else is ignored. Handled mail is
+	// marked read. DropzoneIMAPOptions takes "language=..;auto_transcribe=..".
+	DropzoneIMAPAddr        string
+	DropzoneIMAPTLS         bool
+	DropzoneIMAPUsername    string
+	DropzoneIMAPPassword    string
+	DropzoneIMAPMailbox     string
+	DropzoneIMAPPollSeconds int
+	DropzoneIMAPOptions     string
+
+	// SMTP server, e.g. "smtp.example.com:587", that replies to mailed-in
+	// recordings with a link once they are transcribed; empty sends no mail
+	SMTPAddr     string
+	SMTPUsername string
+	SMTPPassword string
+	SMTPFrom     string
+
+	// Playback proxies: a small Opus copy of each job's audio served to the
+	// browser instead of the original, encoded at PlaybackProxyBitrate
+	PlaybackProxyEnabled bool
+	PlaybackProxyBitrate string
+
+	// What to do with a job's audio once its transcript is final when neither
+	// the job nor its owner chooses: keep, delete or proxy
+	SourceAudioAction string
+
+	// Retention: policies separated by commas, each a scope ("*" for every
+	// job, "user=alice" or "tag=legal") followed by ";key=value" options,
+	// e.g. "*;days=90,tag=legal;days=365;audio=archive". The janitor checks
+	// them every RetentionIntervalMinutes, moving archived audio under
+	// RetentionArchiveDir; with RetentionDryRun it only logs what it would do.
+	RetentionPolicies        string
+	RetentionArchiveDir      string
+	RetentionIntervalMinutes int
+	RetentionDryRun          bool
+
+	// Directory holding the ffmpeg and ffprobe to use ahead of those on the
+	// PATH. With FFmpegAutoInstall, a missing or unusable ffmpeg is replaced
+	// by the pinned static build, downloaded into FFmpegInstallDir from
+	// FFmpegDownloadURL and checked against FFmpegDownloadSHA256 when set,
+	// e.g. "ffmpeg=<hex>,ffprobe=<hex>".
+	FFmpegDir            string
+	FFmpegAutoInstall    bool
+	FFmpegInstallDir     string
+	FFmpegDownloadURL    string
+	FFmpegDownloadSHA256 string
 }
 
-// Load loads configuration from environment variables and .env file
+// Load loads configuration from environment variables, the .env file and
+// the config file, in that order of precedence. A config file that cannot
+// be read stops the process, as running without its settings could do harm.
 func Load() *Config {
 	// Load .env file if it exists
 	if err := godotenv.Load(); err != nil {
 		logger.Debug("No .env file found, using system environment variables")
 	}
 
+	// Settings from the config file fill in what the environment leaves unset
+	if path := FindFile(); path != "" {
+		if err := ApplyFile(path); err != nil {
+			logger.Error("Invalid config file", "path", path, "error", err)
+			os.Exit(1)
+		}
+		logger.Debug("Loaded config file", "path", path)
+	}
+
 	return &Config{
 		Port:               getEnv("PORT", "8080"),
 		Host:               getEnv("HOST", "localhost"),
+		PublicURL:          getEnv("PUBLIC_URL", ""),
 		DatabasePath:       getEnv("DATABASE_PATH", "data/synthezia.db"),
+		DatabaseManualMigrations: getEnvAsBool("DB_MANUAL_MIGRATIONS", false),
 		JWTSecret:          getJWTSecret(),
+		CSRFEnabled:        getEnvAsBool("CSRF_ENABLED", true),
 		UploadDir:          getEnv("UPLOAD_DIR", "data/uploads"),
+		TempDir:            getEnv("TEMP_DIR", "data/temp"),
+		UploadTokenMaxMB:   getEnvAsInt("UPLOAD_TOKEN_MAX_MB", 500),
+		StripAudioMetadata: getEnvAsBool("STRIP_AUDIO_METADATA", false),
+		DuplicateAudioCheck:      getEnv("DUPLICATE_AUDIO_CHECK", "warn"),
+		DuplicateAudioSimilarity: getEnvAsInt("DUPLICATE_AUDIO_SIMILARITY", 90),
+		FpcalcPath:               getEnv("FPCALC_PATH", "fpcalc"),
+		DecodeCheckSeconds:       getEnvAsInt("DECODE_CHECK_SECONDS", 30),
 		UVPath:             findUVPath(),
 		WhisperXEnv:        getEnv("WHISPERX_ENV", "whisperx-env/WhisperX"),
+		TranscriptionBackend: getEnv("TRANSCRIPTION_BACKEND", "models"),
+		TranscriptionChunkMinutes: getEnvAsInt("TRANSCRIPTION_CHUNK_MINUTES", 0),
+		TranscriptionChunkWorkers: getEnvAsInt("TRANSCRIPTION_CHUNK_WORKERS", 2),
+		QuickSyncTimeoutSeconds:   getEnvAsInt("QUICK_SYNC_TIMEOUT_SECONDS", 30),
+		TranscriptionDefaultModel:       getEnv("TRANSCRIPTION_DEFAULT_MODEL", "small"),
+		TranscriptionDefaultComputeType: getEnv("TRANSCRIPTION_DEFAULT_COMPUTE_TYPE", "float32"),
+		TranscriptionDefaultDevice:      getEnv("TRANSCRIPTION_DEFAULT_DEVICE", "cpu"),
+		TranscriptionDefaultBatchSize:   getEnvAsInt("TRANSCRIPTION_DEFAULT_BATCH_SIZE", 8),
+		TranscriptionDefaultBeamSize:    getEnvAsInt("TRANSCRIPTION_DEFAULT_BEAM_SIZE", 5),
+		TranscriptionModels:             getEnv("TRANSCRIPTION_MODELS", ""),
+		TranscriptionMaxBatchSize:       getEnvAsInt("TRANSCRIPTION_MAX_BATCH_SIZE", 32),
+		TranscriptionMaxBeamSize:        getEnvAsInt("TRANSCRIPTION_MAX_BEAM_SIZE", 10),
+		ModelWarmup:                     getEnvAsBool("MODEL_WARMUP", true),
+		NvidiaSMIPath:                   getEnv("NVIDIA_SMI_PATH", "nvidia-smi"),
+		GPUJobsPerDevice:                getEnvAsInt("GPU_JOBS_PER_DEVICE", 1),
+		CPUJobs:                         getEnvAsInt("CPU_JOBS", 0),
+		WorkerToken:                     getEnv("WORKER_TOKEN", ""),
+		WorkerHeartbeatSeconds:          getEnvAsInt("WORKER_HEARTBEAT_SECONDS", 10),
+		WorkerServerURL:                 getEnv("WORKER_SERVER_URL", ""),
+		WorkerName:                      getEnv("WORKER_NAME", hostname()),
+		WorkerJobs:                      getEnvAsInt("WORKER_JOBS", 1),
+		MaxConcurrentMerges:       getEnvAsInt("MAX_CONCURRENT_MERGES", 2),
+		MaxConcurrentConversions:  getEnvAsInt("MAX_CONCURRENT_CONVERSIONS", 2),
+		MaxConcurrentDownloads:    getEnvAsInt("MAX_CONCURRENT_DOWNLOADS", 2),
+		QueueRecoveryPolicy:       getEnv("QUEUE_RECOVERY_POLICY", "requeue"),
+		QueueRecoveryMaxAttempts:  getEnvAsInt("QUEUE_RECOVERY_MAX_ATTEMPTS", 3),
+		QueueFairShare:            getEnvAsBool("QUEUE_FAIR_SHARE", true),
+		QueueUserWeights:          getEnv("QUEUE_USER_WEIGHTS", ""),
 		
 		LLMProvider:   		getEnv("LLM_PROVIDER", ""),
 		OllamaBaseURL: 		getEnv("OLLAMA_BASE_URL", "http://localhost:11434"),
 		OpenAIAPIKey:  		getEnv("OPENAI_API_KEY", ""),
 
-		YoutubeCookiesPath: getEnv("YOUTUBE_COOKIES_PATH", ""),
+		PostProcessURL:    getEnv("POSTPROCESS_LLM_URL", ""),
+		PostProcessAPIKey: getEnv("POSTPROCESS_LLM_API_KEY", ""),
+		PostProcessModel:  getEnv("POSTPROCESS_LLM_MODEL", "gpt-4o-mini"),
+
+		YoutubeCookiesPath:    getEnv("YOUTUBE_COOKIES_PATH", ""),
+		RemoteURLMaxMB:        getEnvAsInt("REMOTE_URL_MAX_MB", 2048),
+		RemoteURLYoutube:      getEnvAsBool("REMOTE_URL_YOUTUBE", false),
+		RemoteURLAllowedHosts: getEnv("REMOTE_URL_ALLOWED_HOSTS", ""),
+		RemoteURLProxy:        getEnv("REMOTE_URL_PROXY", ""),
+		StorageQuotaMB:        getEnvAsInt("STORAGE_QUOTA_MB", 0),
+		StorageBackend:          getEnv("STORAGE_BACKEND", ""),
+		StorageLocalDir:         getEnv("STORAGE_LOCAL_DIR", "data/storage"),
+		StorageS3Endpoint:       getEnv("STORAGE_S3_ENDPOINT", ""),
+		StorageS3Region:         getEnv("STORAGE_S3_REGION", "us-east-1"),
+		StorageS3Bucket:         getEnv("STORAGE_S3_BUCKET", ""),
+		StorageS3Prefix:         getEnv("STORAGE_S3_PREFIX", ""),
+		StorageS3AccessKey:      getEnv("STORAGE_S3_ACCESS_KEY", ""),
+		StorageS3SecretKey:      getEnv("STORAGE_S3_SECRET_KEY", ""),
+		StorageS3PathStyle:      getEnvAsBool("STORAGE_S3_PATH_STYLE", true),
+		StorageKeepLocal:        getEnvAsBool("STORAGE_KEEP_LOCAL", false),
+		StorageURLExpiryMinutes: getEnvAsInt("STORAGE_URL_EXPIRY_MINUTES", 15),
+
+		OTLPEndpoint:     getEnv("OTEL_EXPORTER_OTLP_ENDPOINT", ""),
+		OTLPHeaders:      getEnv("OTEL_EXPORTER_OTLP_HEADERS", ""),
+		TraceServiceName: getEnv("OTEL_SERVICE_NAME", "synthezia"),
+
+		SentryDSN:         getEnv("SENTRY_DSN", ""),
+		SentryEnvironment: getEnv("SENTRY_ENVIRONMENT", "production"),
+
+		DebugCaptureMaxEntries:   getEnvAsInt("DEBUG_CAPTURE_MAX_ENTRIES", 200),
+		DebugCaptureMaxBodyBytes: getEnvAsInt("DEBUG_CAPTURE_MAX_BODY_BYTES", 64*1024),
+
+		LogOutput:       getEnv("LOG_OUTPUT", "stdout"),
+		SyslogAddress:   getEnv("LOG_SYSLOG_ADDRESS", ""),
+		SyslogFacility:  getEnv("LOG_SYSLOG_FACILITY", "daemon"),
+		JournaldSocket:  getEnv("LOG_JOURNALD_SOCKET", ""),
+		LogBufferSize:   getEnvAsInt("LOG_BUFFER_SIZE", 1000),
+		LogHTTPSampling: getEnv("LOG_HTTP_SAMPLING", ""),
+
+		SlowRequestMs: getEnvAsInt("SLOW_REQUEST_MS", 2000),
+		SlowQueryMs:   getEnvAsInt("SLOW_QUERY_MS", 500),
+		SlowFFmpegMs:  getEnvAsInt("SLOW_FFMPEG_MS", 120000),
+
+		JobEventsLog: getEnv("JOB_EVENTS_LOG", ""),
+		JobEventsDB:  getEnvAsBool("JOB_EVENTS_DB", false),
+
+		DropzonePaths:           getEnv("DROPZONE_PATHS", ""),
+		DropzoneSettleSeconds:   getEnvAsInt("DROPZONE_SETTLE_SECONDS", 2),
+		DropzoneLockProbe:       getEnvAsBool("DROPZONE_LOCK_PROBE", false),
+		DropzoneIgnore:          getEnv("DROPZONE_IGNORE", ".*,~*,*.part,*.partial,*.tmp,*.crdownload"),
+		DropzoneMaxDepth:        getEnvAsInt("DROPZONE_MAX_DEPTH", 0),
+		DropzoneProfiles:        getEnv("DROPZONE_PROFILES", ""),
+		DropzoneQuarantine:      getEnvAsBool("DROPZONE_QUARANTINE", true),
+		DropzoneDedupe:          getEnv("DROPZONE_DEDUPE", "off"),
+		DropzoneMaxInFlight:     getEnvAsInt("DROPZONE_MAX_IN_FLIGHT", 4),
+		DropzoneQueueHighWater:  getEnvAsInt("DROPZONE_QUEUE_HIGH_WATER", 0),
+		DropzoneRetention:       getEnv("DROPZONE_RETENTION", "delete"),
+		DropzoneArchiveDays:     getEnvAsInt("DROPZONE_ARCHIVE_DAYS", 0),
+		DropzoneSweepSchedule:   getEnv("DROPZONE_SWEEP_SCHEDULE", ""),
+		DropzoneS3Endpoint:      getEnv("DROPZONE_S3_ENDPOINT", "https://s3.amazonaws.com"),
+		DropzoneS3Region:        getEnv("DROPZONE_S3_REGION", "us-east-1"),
+		DropzoneS3Bucket:        getEnv("DROPZONE_S3_BUCKET", ""),
+		DropzoneS3Prefix:        getEnv("DROPZONE_S3_PREFIX", ""),
+		DropzoneS3AccessKey:     getEnv("DROPZONE_S3_ACCESS_KEY", ""),
+		DropzoneS3SecretKey:     getEnv("DROPZONE_S3_SECRET_KEY", ""),
+		DropzoneS3PathStyle:     getEnvAsBool("DROPZONE_S3_PATH_STYLE", true),
+		DropzoneS3PollSeconds:   getEnvAsInt("DROPZONE_S3_POLL_SECONDS", 30),
+		DropzoneS3Delete:        getEnvAsBool("DROPZONE_S3_DELETE", true),
+		DropzoneS3Options:       getEnv("DROPZONE_S3_OPTIONS", ""),
+		DropzoneSFTPAddr:        getEnv("DROPZONE_SFTP_ADDR", ""),
+		DropzoneSFTPHostKey:     getEnv("DROPZONE_SFTP_HOST_KEY", filepath.Join("data", "sftp_host_key")),
+		DropzoneSFTPOptions:     getEnv("DROPZONE_SFTP_OPTIONS", ""),
+		DropzoneIMAPAddr:        getEnv("DROPZONE_IMAP_ADDR", ""),
+		DropzoneIMAPTLS:         getEnvAsBool("DROPZONE_IMAP_TLS", true),
+		DropzoneIMAPUsername:    getEnv("DROPZONE_IMAP_USERNAME", ""),
+		DropzoneIMAPPassword:    getEnv("DROPZONE_IMAP_PASSWORD", ""),
+		DropzoneIMAPMailbox:     getEnv("DROPZONE_IMAP_MAILBOX", "INBOX"),
+		DropzoneIMAPPollSeconds: getEnvAsInt("DROPZONE_IMAP_POLL_SECONDS", 60),
+		DropzoneIMAPOptions:     getEnv("DROPZONE_IMAP_OPTIONS", ""),
+
+		SMTPAddr:     getEnv("SMTP_ADDR", ""),
+		SMTPUsername: getEnv("SMTP_USERNAME", ""),
+		SMTPPassword: getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:     getEnv("SMTP_FROM", ""),
+
+		PlaybackProxyEnabled: getEnvAsBool("PLAYBACK_PROXY_ENABLED", true),
+		PlaybackProxyBitrate: getEnv("PLAYBACK_PROXY_BITRATE", "24k"),
+
+		SourceAudioAction: getEnv("SOURCE_AUDIO_ACTION", "keep"),
+
+		RetentionPolicies:        getEnv("RETENTION_POLICIES", ""),
+		RetentionArchiveDir:      getEnv("RETENTION_ARCHIVE_DIR", "data/archive"),
+		RetentionIntervalMinutes: getEnvAsInt("RETENTION_INTERVAL_MINUTES", 60),
+		RetentionDryRun:          getEnvAsBool("RETENTION_DRY_RUN", false),
+
+		FFmpegDir:            getEnv("FFMPEG_DIR", ""),
+		FFmpegAutoInstall:    getEnvAsBool("FFMPEG_AUTO_INSTALL", false),
+		FFmpegInstallDir:     getEnv("FFMPEG_INSTALL_DIR", "data/ffmpeg"),
+		FFmpegDownloadURL:    getEnv("FFMPEG_DOWNLOAD_URL", ""),
+		FFmpegDownloadSHA256: getEnv("FFMPEG_DOWNLOAD_SHA256", ""),
 	}
 }
 
@@ -94,6 +532,15 @@ func getEnvAsBool(key string, defaultValue bool) bool {
 	return defaultValue
 }
 
+// hostname names this machine, for the default worker name
+func hostname() string {
+	name, err := os.Hostname()
+	if err != nil {
+		return "worker"
+	}
+	return name
+}
+
 // getJWTSecret gets JWT secret from env or generates a secure random one
 func getJWTSecret() string {
 	if secret := os.Getenv("JWT_SECRET"); secret != "" {
@@ -0,0 +1,305 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultFiles are the config files looked for in the working directory
+// when CONFIG_FILE is not set
+var defaultFiles = []string{"config.yaml", "config.yml", "config.toml"}
+
+// fileSettings maps each setting of a config file, by its path in the
+// tree, to the environment variable it stands for
+var fileSettings = map[string]string{
+	"server.port":                       "PORT",
+	"server.host":                       "HOST",
+	"server.public_url":                 "PUBLIC_URL",
+	"server.upload_dir":                 "UPLOAD_DIR",
+	"server.temp_dir":                   "TEMP_DIR",
+	"server.upload_token_max_mb":        "UPLOAD_TOKEN_MAX_MB",
+	"server.strip_audio_metadata":       "STRIP_AUDIO_METADATA",
+	"server.decode_check_seconds":       "DECODE_CHECK_SECONDS",
+	"server.quick_sync_timeout_seconds": "QUICK_SYNC_TIMEOUT_SECONDS",
+	"server.source_audio_action":        "SOURCE_AUDIO_ACTION",
+	"server.playback_proxy.enabled":     "PLAYBACK_PROXY_ENABLED",
+	"server.playback_proxy.bitrate":     "PLAYBACK_PROXY_BITRATE",
+	"server.duplicate_audio.check":      "DUPLICATE_AUDIO_CHECK",
+	"server.duplicate_audio.similarity": "DUPLICATE_AUDIO_SIMILARITY",
+	"server.duplicate_audio.fpcalc":     "FPCALC_PATH",
+	"server.remote_url.max_mb":          "REMOTE_URL_MAX_MB",
+	"server.remote_url.youtube":         "REMOTE_URL_YOUTUBE",
+	"server.remote_url.youtube_cookies": "YOUTUBE_COOKIES_PATH",
+	"server.remote_url.allowed_hosts":   "REMOTE_URL_ALLOWED_HOSTS",
+	"server.remote_url.proxy":           "REMOTE_URL_PROXY",
+
+	"database.path":              "DATABASE_PATH",
+	"database.manual_migrations": "DB_MANUAL_MIGRATIONS",
+
+	"auth.jwt_secret":      "JWT_SECRET",
+	"auth.jwt_secret_file": "JWT_SECRET_FILE",
+	"auth.csrf_enabled":    "CSRF_ENABLED",
+
+	"queue.workers":                    "QUEUE_WORKERS",
+	"queue.auto_scale":                 "QUEUE_AUTO_SCALE",
+	"queue.max_load":                   "QUEUE_MAX_LOAD",
+	"queue.fair_share":                 "QUEUE_FAIR_SHARE",
+	"queue.user_weights":               "QUEUE_USER_WEIGHTS",
+	"queue.recovery_policy":            "QUEUE_RECOVERY_POLICY",
+	"queue.recovery_max_attempts":      "QUEUE_RECOVERY_MAX_ATTEMPTS",
+	"queue.max_concurrent_merges":      "MAX_CONCURRENT_MERGES",
+	"queue.max_concurrent_conversions": "MAX_CONCURRENT_CONVERSIONS",
+	"queue.max_concurrent_downloads":   "MAX_CONCURRENT_DOWNLOADS",
+
+	"whisperx.env":                  "WHISPERX_ENV",
+	"whisperx.uv_path":              "UV_PATH",
+	"whisperx.backend":              "TRANSCRIPTION_BACKEND",
+	"whisperx.chunk_minutes":        "TRANSCRIPTION_CHUNK_MINUTES",
+	"whisperx.chunk_workers":        "TRANSCRIPTION_CHUNK_WORKERS",
+	"whisperx.default_model":        "TRANSCRIPTION_DEFAULT_MODEL",
+	"whisperx.default_compute_type": "TRANSCRIPTION_DEFAULT_COMPUTE_TYPE",
+	"whisperx.default_device":       "TRANSCRIPTION_DEFAULT_DEVICE",
+	"whisperx.default_batch_size":   "TRANSCRIPTION_DEFAULT_BATCH_SIZE",
+	"whisperx.default_beam_size":    "TRANSCRIPTION_DEFAULT_BEAM_SIZE",
+	"whisperx.models":               "TRANSCRIPTION_MODELS",
+	"whisperx.max_batch_size":       "TRANSCRIPTION_MAX_BATCH_SIZE",
+	"whisperx.max_beam_size":        "TRANSCRIPTION_MAX_BEAM_SIZE",
+	"whisperx.model_warmup":         "MODEL_WARMUP",
+	"whisperx.nvidia_smi_path":      "NVIDIA_SMI_PATH",
+	"whisperx.gpu_jobs_per_device":  "GPU_JOBS_PER_DEVICE",
+	"whisperx.cpu_jobs":             "CPU_JOBS",
+
+	"worker.token":             "WORKER_TOKEN",
+	"worker.heartbeat_seconds": "WORKER_HEARTBEAT_SECONDS",
+	"worker.server_url":        "WORKER_SERVER_URL",
+	"worker.name":              "WORKER_NAME",
+	"worker.jobs":              "WORKER_JOBS",
+
+	"llm.provider":            "LLM_PROVIDER",
+	"llm.ollama_base_url":     "OLLAMA_BASE_URL",
+	"llm.openai_api_key":      "OPENAI_API_KEY",
+	"llm.postprocess.url":     "POSTPROCESS_LLM_URL",
+	"llm.postprocess.api_key": "POSTPROCESS_LLM_API_KEY",
+	"llm.postprocess.model":   "POSTPROCESS_LLM_MODEL",
+
+	"storage.quota_mb":           "STORAGE_QUOTA_MB",
+	"storage.backend":            "STORAGE_BACKEND",
+	"storage.local_dir":          "STORAGE_LOCAL_DIR",
+	"storage.keep_local":         "STORAGE_KEEP_LOCAL",
+	"storage.url_expiry_minutes": "STORAGE_URL_EXPIRY_MINUTES",
+	"storage.s3.endpoint":        "STORAGE_S3_ENDPOINT",
+	"storage.s3.region":          "STORAGE_S3_REGION",
+	"storage.s3.bucket":          "STORAGE_S3_BUCKET",
+	"storage.s3.prefix":          "STORAGE_S3_PREFIX",
+	"storage.s3.access_key":      "STORAGE_S3_ACCESS_KEY",
+	"storage.s3.secret_key":      "STORAGE_S3_SECRET_KEY",
+	"storage.s3.path_style":      "STORAGE_S3_PATH_STYLE",
+
+	"dropzone.paths":             "DROPZONE_PATHS",
+	"dropzone.settle_seconds":    "DROPZONE_SETTLE_SECONDS",
+	"dropzone.lock_probe":        "DROPZONE_LOCK_PROBE",
+	"dropzone.ignore":            "DROPZONE_IGNORE",
+	"dropzone.max_depth":         "DROPZONE_MAX_DEPTH",
+	"dropzone.profiles":          "DROPZONE_PROFILES",
+	"dropzone.quarantine":        "DROPZONE_QUARANTINE",
+	"dropzone.dedupe":            "DROPZONE_DEDUPE",
+	"dropzone.max_in_flight":     "DROPZONE_MAX_IN_FLIGHT",
+	"dropzone.queue_high_water":  "DROPZONE_QUEUE_HIGH_WATER",
+	"dropzone.retention":         "DROPZONE_RETENTION",
+	"dropzone.archive_days":      "DROPZONE_ARCHIVE_DAYS",
+	"dropzone.sweep_schedule":    "DROPZONE_SWEEP_SCHEDULE",
+	"dropzone.s3.endpoint":       "DROPZONE_S3_ENDPOINT",
+	"dropzone.s3.region":         "DROPZONE_S3_REGION",
+	"dropzone.s3.bucket":         "DROPZONE_S3_BUCKET",
+	"dropzone.s3.prefix":         "DROPZONE_S3_PREFIX",
+	"dropzone.s3.access_key":     "DROPZONE_S3_ACCESS_KEY",
+	"dropzone.s3.secret_key":     "DROPZONE_S3_SECRET_KEY",
+	"dropzone.s3.path_style":     "DROPZONE_S3_PATH_STYLE",
+	"dropzone.s3.poll_seconds":   "DROPZONE_S3_POLL_SECONDS",
+	"dropzone.s3.delete":         "DROPZONE_S3_DELETE",
+	"dropzone.s3.options":        "DROPZONE_S3_OPTIONS",
+	"dropzone.sftp.addr":         "DROPZONE_SFTP_ADDR",
+	"dropzone.sftp.host_key":     "DROPZONE_SFTP_HOST_KEY",
+	"dropzone.sftp.options":      "DROPZONE_SFTP_OPTIONS",
+	"dropzone.imap.addr":         "DROPZONE_IMAP_ADDR",
+	"dropzone.imap.tls":          "DROPZONE_IMAP_TLS",
+	"dropzone.imap.username":     "DROPZONE_IMAP_USERNAME",
+	"dropzone.imap.password":     "DROPZONE_IMAP_PASSWORD",
+	"dropzone.imap.mailbox":      "DROPZONE_IMAP_MAILBOX",
+	"dropzone.imap.poll_seconds": "DROPZONE_IMAP_POLL_SECONDS",
+	"dropzone.imap.options":      "DROPZONE_IMAP_OPTIONS",
+
+	"smtp.addr":     "SMTP_ADDR",
+	"smtp.username": "SMTP_USERNAME",
+	"smtp.password": "SMTP_PASSWORD",
+	"smtp.from":     "SMTP_FROM",
+
+	"retention.policies":         "RETENTION_POLICIES",
+	"retention.archive_dir":      "RETENTION_ARCHIVE_DIR",
+	"retention.interval_minutes": "RETENTION_INTERVAL_MINUTES",
+	"retention.dry_run":          "RETENTION_DRY_RUN",
+
+	"ffmpeg.dir":             "FFMPEG_DIR",
+	"ffmpeg.auto_install":    "FFMPEG_AUTO_INSTALL",
+	"ffmpeg.install_dir":     "FFMPEG_INSTALL_DIR",
+	"ffmpeg.download_url":    "FFMPEG_DOWNLOAD_URL",
+	"ffmpeg.download_sha256": "FFMPEG_DOWNLOAD_SHA256",
+
+	"logging.level":                        "LOG_LEVEL",
+	"logging.modules.http":                 "LOG_LEVEL_HTTP",
+	"logging.modules.dropzone":             "LOG_LEVEL_DROPZONE",
+	"logging.modules.queue":                "LOG_LEVEL_QUEUE",
+	"logging.modules.jobs":                 "LOG_LEVEL_JOBS",
+	"logging.output":                       "LOG_OUTPUT",
+	"logging.syslog_address":               "LOG_SYSLOG_ADDRESS",
+	"logging.syslog_facility":              "LOG_SYSLOG_FACILITY",
+	"logging.journald_socket":              "LOG_JOURNALD_SOCKET",
+	"logging.buffer_size":                  "LOG_BUFFER_SIZE",
+	"logging.http_sampling":                "LOG_HTTP_SAMPLING",
+	"logging.slow_request_ms":              "SLOW_REQUEST_MS",
+	"logging.slow_query_ms":                "SLOW_QUERY_MS",
+	"logging.slow_ffmpeg_ms":               "SLOW_FFMPEG_MS",
+	"logging.job_events_log":               "JOB_EVENTS_LOG",
+	"logging.job_events_db":                "JOB_EVENTS_DB",
+	"logging.debug_capture_max_entries":    "DEBUG_CAPTURE_MAX_ENTRIES",
+	"logging.debug_capture_max_body_bytes": "DEBUG_CAPTURE_MAX_BODY_BYTES",
+
+	"telemetry.otlp_endpoint":      "OTEL_EXPORTER_OTLP_ENDPOINT",
+	"telemetry.otlp_headers":       "OTEL_EXPORTER_OTLP_HEADERS",
+	"telemetry.service_name":       "OTEL_SERVICE_NAME",
+	"telemetry.sentry_dsn":         "SENTRY_DSN",
+	"telemetry.sentry_environment": "SENTRY_ENVIRONMENT",
+}
+
+// FindFile returns the config file to read: CONFIG_FILE if set, else the
+// first of config.yaml, config.yml and config.toml in the working
+// directory, else "" for none
+func FindFile() string {
+	if path := os.Getenv("CONFIG_FILE"); path != "" {
+		return path
+	}
+	for _, name := range defaultFiles {
+		if _, err := os.Stat(name); err == nil {
+			return name
+		}
+	}
+	return ""
+}
+
+// ReadFile reads a YAML or TOML config file, by its extension, into the
+// environment variables its settings stand for. Lists are joined with
+// commas. Unknown settings are an error, so typos do not go unnoticed.
+func ReadFile(path string) (map[string]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	tree := map[string]interface{}{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(data, &tree)
+	case ".toml":
+		err = toml.Unmarshal(data, &tree)
+	default:
+		return nil, fmt.Errorf("config file %s must be .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flattenSettings("", tree, values); err != nil {
+		return nil, fmt.Errorf("config file %s: %w", path, err)
+	}
+	return values, nil
+}
+
+// flattenSettings walks a tree of settings, recording each leaf under the
+// environment variable it stands for
+func flattenSettings(prefix string, tree map[string]interface{}, values map[string]string) error {
+	keys := make([]string, 0, len(tree))
+	for key := range tree {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		path := key
+		if prefix != "" {
+			path = prefix + "." + key
+		}
+		switch value := tree[key].(type) {
+		case nil:
+			continue
+		case map[string]interface{}:
+			if err := flattenSettings(path, value, values); err != nil {
+				return err
+			}
+			continue
+		case []interface{}:
+			items := make([]string, len(value))
+			for i, item := range value {
+				text, ok := settingValue(item)
+				if !ok {
+					return fmt.Errorf("setting %s must list plain values", path)
+				}
+				items[i] = text
+			}
+			if err := setSetting(path, strings.Join(items, ","), values); err != nil {
+				return err
+			}
+		default:
+			text, ok := settingValue(value)
+			if !ok {
+				return fmt.Errorf("setting %s has an unsupported value", path)
+			}
+			if err := setSetting(path, text, values); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func setSetting(path, value string, values map[string]string) error {
+	env, ok := fileSettings[path]
+	if !ok {
+		return fmt.Errorf("unknown setting %s", path)
+	}
+	values[env] = value
+	return nil
+}
+
+// settingValue formats a plain value the way it would be written in the environment
+func settingValue(value interface{}) (string, bool) {
+	switch value := value.(type) {
+	case string:
+		return value, true
+	case bool, int, int64, uint64, float64:
+		return fmt.Sprint(value), true
+	}
+	return "", false
+}
+
+// ApplyFile sets the environment variables a config file's settings stand
+// for, leaving those already set alone so the environment overrides the file
+func ApplyFile(path string) error {
+	values, err := ReadFile(path)
+	if err != nil {
+		return err
+	}
+	for env, value := range values {
+		if os.Getenv(env) == "" {
+			if err := os.Setenv(env, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,262 @@
+// Package convert re-encodes audio into another format on the task queue,
+// for example to get a browser-playable copy of a recording in an exotic
+// codec, and keeps the result for download.
+package convert
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Format is an output format conversions can produce
+type Format struct {
+	Extension   string
+	ContentType string
+	Codec       string
+	// DefaultBitrate is used when a conversion names none; lossless formats
+	// have none and take no bitrate
+	DefaultBitrate string
+}
+
+// Formats are the output formats by name
+var Formats = map[string]Format{
+	"mp3":  {Extension: ".mp3", ContentType: "audio/mpeg", Codec: "libmp3lame", DefaultBitrate: "192k"},
+	"aac":  {Extension: ".m4a", ContentType: "audio/mp4", Codec: "aac", DefaultBitrate: "160k"},
+	"opus": {Extension: ".opus", ContentType: "audio/ogg", Codec: "libopus", DefaultBitrate: "64k"},
+	"ogg":  {Extension: ".ogg", ContentType: "audio/ogg", Codec: "libvorbis", DefaultBitrate: "160k"},
+	"wav":  {Extension: ".wav", ContentType: "audio/wav", Codec: "pcm_s16le"},
+	"flac": {Extension: ".flac", ContentType: "audio/flac", Codec: "flac"},
+}
+
+var bitratePattern = regexp.MustCompile(`^(\d+)k$`)
+
+// Bitrate bounds in kbps
+const (
+	minBitrate = 8
+	maxBitrate = 512
+)
+
+// FormatNames lists the supported formats in order
+func FormatNames() []string {
+	names := make([]string, 0, len(Formats))
+	for name := range Formats {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Validate checks a format and bitrate, returning the bitrate to encode at
+func Validate(format, bitrate string) (string, error) {
+	f, ok := Formats[format]
+	if !ok {
+		return "", fmt.Errorf("format must be one of %s", strings.Join(FormatNames(), ", "))
+	}
+	if f.DefaultBitrate == "" {
+		if bitrate != "" {
+			return "", fmt.Errorf("%s is lossless and takes no bitrate", format)
+		}
+		return "", nil
+	}
+	if bitrate == "" {
+		return f.DefaultBitrate, nil
+	}
+	m := bitratePattern.FindStringSubmatch(bitrate)
+	if m == nil {
+		return "", errors.New("bitrate must be given in kbps, e.g. 128k")
+	}
+	if kbps, _ := strconv.Atoi(m[1]); kbps < minBitrate || kbps > maxBitrate {
+		return "", fmt.Errorf("bitrate must be between %dk and %dk", minBitrate, maxBitrate)
+	}
+	return bitrate, nil
+}
+
+// DownloadName is the file name a conversion's artifact is offered under
+func DownloadName(conversion *models.AudioConversion) string {
+	name := strings.TrimSuffix(conversion.SourceName, filepath.Ext(conversion.SourceName))
+	if name == "" {
+		name = conversion.ID
+	}
+	return name + Formats[conversion.Format].Extension
+}
+
+// Service runs conversions
+type Service struct {
+	db         *gorm.DB
+	ffmpegPath string
+	outputDir  string
+	tempDir    string
+}
+
+// NewService creates a service keeping artifacts in data/uploads/conversions;
+// a nil db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:         db,
+		ffmpegPath: "ffmpeg",
+		outputDir:  filepath.Join("data", "uploads", "conversions"),
+	}
+}
+
+// Default is the process-wide service
+var Default = NewService(nil)
+
+// SetFFmpegPath overrides the ffmpeg binary, mainly for tests
+func (s *Service) SetFFmpegPath(path string) {
+	s.ffmpegPath = path
+}
+
+// SetOutputDir sets where uploaded sources and artifacts are kept
+func (s *Service) SetOutputDir(dir string) {
+	s.outputDir = dir
+}
+
+// OutputDir is where uploaded sources and artifacts are kept
+func (s *Service) OutputDir() string {
+	return s.outputDir
+}
+
+// SetTempDir sets where artifacts are encoded before being moved into place
+func (s *Service) SetTempDir(dir string) {
+	s.tempDir = dir
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// task runs one conversion on the task queue
+type task struct {
+	service *Service
+	id      string
+}
+
+func (t task) TaskID() string { return "conversion:" + t.id }
+
+func (t task) Run(ctx context.Context) error { return t.service.Run(ctx, t.id) }
+
+// Task returns the queue task running a conversion
+func (s *Service) Task(conversionID string) queue.Task {
+	return task{service: s, id: conversionID}
+}
+
+// Resume queues the conversions a restart interrupted, returning how many
+func (s *Service) Resume(enqueue func(queue.Task) error) (int, error) {
+	var conversions []models.AudioConversion
+	if err := s.conn().Where("status IN ?", []string{models.ConversionPending, models.ConversionProcessing}).
+		Order("created_at").Find(&conversions).Error; err != nil {
+		return 0, err
+	}
+	for i, conversion := range conversions {
+		if err := enqueue(s.Task(conversion.ID)); err != nil {
+			return i, err
+		}
+	}
+	return len(conversions), nil
+}
+
+// Run encodes a conversion's source into its format. The outcome is
+// recorded on the conversion; finished conversions are left as they are.
+func (s *Service) Run(ctx context.Context, conversionID string) error {
+	db := s.conn()
+	var conversion models.AudioConversion
+	if err := db.Where("id = ?", conversionID).First(&conversion).Error; err != nil {
+		return err
+	}
+	if conversion.Status == models.ConversionCompleted || conversion.Status == models.ConversionFailed {
+		return nil
+	}
+	if err := db.Model(&conversion).Update("status", models.ConversionProcessing).Error; err != nil {
+		return err
+	}
+
+	outputPath, size, err := s.encode(ctx, &conversion)
+	if conversion.UploadedSource {
+		os.Remove(conversion.SourcePath)
+	}
+	now := time.Now()
+	if err != nil {
+		msg := err.Error()
+		if updateErr := db.Model(&conversion).Updates(map[string]interface{}{
+			"status":       models.ConversionFailed,
+			"error":        &msg,
+			"completed_at": &now,
+		}).Error; updateErr != nil {
+			logger.Error("Failed to record failed conversion", "conversion_id", conversionID, "error", updateErr)
+		}
+		return err
+	}
+	return db.Model(&conversion).Updates(map[string]interface{}{
+		"status":       models.ConversionCompleted,
+		"output_path":  outputPath,
+		"output_size":  size,
+		"completed_at": &now,
+	}).Error
+}
+
+// encode runs ffmpeg into a temporary file and moves the result into the
+// output directory
+func (s *Service) encode(ctx context.Context, conversion *models.AudioConversion) (string, int64, error) {
+	format, ok := Formats[conversion.Format]
+	if !ok {
+		return "", 0, fmt.Errorf("unsupported format %q", conversion.Format)
+	}
+	if _, err := os.Stat(conversion.SourcePath); err != nil {
+		return "", 0, fmt.Errorf("source audio not found: %w", err)
+	}
+	if err := os.MkdirAll(s.outputDir, 0755); err != nil {
+		return "", 0, fmt.Errorf("failed to create conversion directory: %w", err)
+	}
+
+	outputPath := filepath.Join(s.outputDir, conversion.ID+format.Extension)
+	// ffmpeg picks the container from the extension
+	tmp := fsys.TempPath(s.tempDir, outputPath) + format.Extension
+	args := []string{"-y", "-i", conversion.SourcePath, "-vn", "-c:a", format.Codec}
+	if conversion.Bitrate != "" {
+		args = append(args, "-b:a", conversion.Bitrate)
+	}
+	args = append(args, tmp)
+
+	start := time.Now()
+	output, err := exec.CommandContext(ctx, s.ffmpegPath, args...).CombinedOutput()
+	logger.FFmpegStage(ctx, "convert", start, "conversion_id", conversion.ID, "format", conversion.Format)
+	if err != nil {
+		os.Remove(tmp)
+		return "", 0, fmt.Errorf("ffmpeg failed: %v - %s", err, lastLine(output))
+	}
+	info, err := os.Stat(tmp)
+	if err != nil {
+		return "", 0, fmt.Errorf("ffmpeg wrote no output: %w", err)
+	}
+	if err := os.Rename(tmp, outputPath); err != nil {
+		os.Remove(tmp)
+		return "", 0, err
+	}
+	return outputPath, info.Size(), nil
+}
+
+// lastLine returns the last line of ffmpeg's log, which names the error
+func lastLine(output []byte) string {
+	lines := strings.Split(strings.TrimSpace(string(output)), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,712 @@
+-- Schema of migration 1 (baseline): the tables and indexes the models had when
+-- versioned migrations replaced AutoMigrate. Frozen; later changes go in new
+-- migrations. Every statement ends with a semicolon at the end of a line.
+
+CREATE TABLE IF NOT EXISTS `transcription_jobs` (
+  `id` varchar(36),
+  `title` text,
+  `status` varchar(20) NOT NULL DEFAULT "pending",
+  `audio_path` text NOT NULL,
+  `transcript` text,
+  `diarization` boolean DEFAULT false,
+  `summary` text,
+  `error_message` text,
+  `is_multi_track` boolean DEFAULT false,
+  `aup_file_path` text,
+  `multi_track_folder` text,
+  `merged_audio_path` text,
+  `merge_status` varchar(20) DEFAULT "none",
+  `merge_error` text,
+  `merge_all_tracks` boolean DEFAULT false,
+  `individual_transcripts` text,
+  `recorded_at` datetime,
+  `recorded_at_source` varchar(20),
+  `tags` text,
+  `collection_id` integer,
+  `audio_metadata` text,
+  `audio_duration` real,
+  `audio_sample_rate` integer,
+  `audio_channels` integer,
+  `audio_codec` varchar(32),
+  `audio_bit_rate` integer,
+  `api_key_id` integer,
+  `user_id` integer,
+  `source_audio_action` varchar(10),
+  `source_audio_removed_at` datetime,
+  `retention_applied_at` datetime,
+  `offloaded_at` datetime,
+  `proxy_audio_path` text,
+  `waveform_path` text,
+  `quality_report` text,
+  `encrypted` boolean DEFAULT false,
+  `encryption_key_hash` varchar(64),
+  `encrypted_at` datetime,
+  `audio_hash` varchar(64),
+  `duplicate_of` varchar(36),
+  `audio_fingerprint` text,
+  `near_duplicate_of` varchar(36),
+  `run_after` datetime,
+  `resume_from` real,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `model_family` varchar(20) DEFAULT "whisper",
+  `model` varchar(50) DEFAULT "small",
+  `model_cache_only` boolean DEFAULT false,
+  `model_dir` text,
+  `device` varchar(20) DEFAULT "cpu",
+  `device_index` integer DEFAULT 0,
+  `batch_size` integer DEFAULT 8,
+  `compute_type` varchar(20) DEFAULT "float32",
+  `threads` integer DEFAULT 0,
+  `output_format` varchar(20) DEFAULT "all",
+  `verbose` boolean DEFAULT true,
+  `task` varchar(20) DEFAULT "transcribe",
+  `language` varchar(10),
+  `align_model` varchar(100),
+  `interpolate_method` varchar(20) DEFAULT "nearest",
+  `no_align` boolean DEFAULT false,
+  `return_char_alignments` boolean DEFAULT false,
+  `normalize` boolean DEFAULT false,
+  `trim_silence` boolean DEFAULT false,
+  `denoise` boolean DEFAULT false,
+  `vad_method` varchar(20) DEFAULT "pyannote",
+  `vad_onset` real DEFAULT 0.5,
+  `vad_offset` real DEFAULT 0.363,
+  `chunk_size` integer DEFAULT 30,
+  `diarize` boolean DEFAULT false,
+  `min_speakers` integer,
+  `max_speakers` integer,
+  `diarize_model` varchar(50) DEFAULT "pyannote",
+  `speaker_embeddings` boolean DEFAULT false,
+  `temperature` real DEFAULT 0,
+  `best_of` integer DEFAULT 5,
+  `beam_size` integer DEFAULT 5,
+  `patience` real DEFAULT 1,
+  `length_penalty` real DEFAULT 1,
+  `suppress_tokens` text,
+  `suppress_numerals` boolean DEFAULT false,
+  `initial_prompt` text,
+  `condition_on_previous_text` boolean DEFAULT false,
+  `fp16` boolean DEFAULT true,
+  `temperature_increment_on_fallback` real DEFAULT 0.2,
+  `compression_ratio_threshold` real DEFAULT 2.4,
+  `logprob_threshold` real DEFAULT -1,
+  `no_speech_threshold` real DEFAULT 0.6,
+  `max_line_width` integer,
+  `max_line_count` integer,
+  `highlight_words` boolean DEFAULT false,
+  `segment_resolution` varchar(20) DEFAULT "sentence",
+  `hf_token` text,
+  `print_progress` boolean DEFAULT false,
+  `attention_context_left` integer DEFAULT 256,
+  `attention_context_right` integer DEFAULT 256,
+  `is_multi_track_enabled` boolean DEFAULT false,
+  `split_channels` varchar(10),
+  `range_start` real,
+  `range_end` real,
+  `translate_to` text,
+  `translation_provider` varchar(20),
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_transcription_jobs_run_after` ON `transcription_jobs`(`run_after`);
+CREATE INDEX IF NOT EXISTS `idx_transcription_jobs_near_duplicate_of` ON `transcription_jobs`(`near_duplicate_of`);
+CREATE INDEX IF NOT EXISTS `idx_transcription_jobs_duplicate_of` ON `transcription_jobs`(`duplicate_of`);
+CREATE INDEX IF NOT EXISTS `idx_transcription_jobs_audio_hash` ON `transcription_jobs`(`audio_hash`);
+CREATE INDEX IF NOT EXISTS `idx_transcription_jobs_user_id` ON `transcription_jobs`(`user_id`);
+CREATE INDEX IF NOT EXISTS `idx_transcription_jobs_api_key_id` ON `transcription_jobs`(`api_key_id`);
+CREATE INDEX IF NOT EXISTS `idx_transcription_jobs_collection_id` ON `transcription_jobs`(`collection_id`);
+CREATE INDEX IF NOT EXISTS `idx_transcription_jobs_recorded_at` ON `transcription_jobs`(`recorded_at`);
+CREATE TABLE IF NOT EXISTS `transcription_job_executions` (
+  `id` varchar(36),
+  `transcription_job_id` varchar(36) NOT NULL,
+  `started_at` datetime NOT NULL,
+  `completed_at` datetime,
+  `processing_duration` integer,
+  `multi_track_timings` text,
+  `merge_start_time` datetime,
+  `merge_end_time` datetime,
+  `merge_duration` integer,
+  `actual_model_family` varchar(20) DEFAULT "whisper",
+  `actual_model` varchar(50) DEFAULT "small",
+  `actual_model_cache_only` boolean DEFAULT false,
+  `actual_model_dir` text,
+  `actual_device` varchar(20) DEFAULT "cpu",
+  `actual_device_index` integer DEFAULT 0,
+  `actual_batch_size` integer DEFAULT 8,
+  `actual_compute_type` varchar(20) DEFAULT "float32",
+  `actual_threads` integer DEFAULT 0,
+  `actual_output_format` varchar(20) DEFAULT "all",
+  `actual_verbose` boolean DEFAULT true,
+  `actual_task` varchar(20) DEFAULT "transcribe",
+  `actual_language` varchar(10),
+  `actual_align_model` varchar(100),
+  `actual_interpolate_method` varchar(20) DEFAULT "nearest",
+  `actual_no_align` boolean DEFAULT false,
+  `actual_return_char_alignments` boolean DEFAULT false,
+  `actual_normalize` boolean DEFAULT false,
+  `actual_trim_silence` boolean DEFAULT false,
+  `actual_denoise` boolean DEFAULT false,
+  `actual_vad_method` varchar(20) DEFAULT "pyannote",
+  `actual_vad_onset` real DEFAULT 0.5,
+  `actual_vad_offset` real DEFAULT 0.363,
+  `actual_chunk_size` integer DEFAULT 30,
+  `actual_diarize` boolean DEFAULT false,
+  `actual_min_speakers` integer,
+  `actual_max_speakers` integer,
+  `actual_diarize_model` varchar(50) DEFAULT "pyannote",
+  `actual_speaker_embeddings` boolean DEFAULT false,
+  `actual_temperature` real DEFAULT 0,
+  `actual_best_of` integer DEFAULT 5,
+  `actual_beam_size` integer DEFAULT 5,
+  `actual_patience` real DEFAULT 1,
+  `actual_length_penalty` real DEFAULT 1,
+  `actual_suppress_tokens` text,
+  `actual_suppress_numerals` boolean DEFAULT false,
+  `actual_initial_prompt` text,
+  `actual_condition_on_previous_text` boolean DEFAULT false,
+  `actual_fp16` boolean DEFAULT true,
+  `actual_temperature_increment_on_fallback` real DEFAULT 0.2,
+  `actual_compression_ratio_threshold` real DEFAULT 2.4,
+  `actual_logprob_threshold` real DEFAULT -1,
+  `actual_no_speech_threshold` real DEFAULT 0.6,
+  `actual_max_line_width` integer,
+  `actual_max_line_count` integer,
+  `actual_highlight_words` boolean DEFAULT false,
+  `actual_segment_resolution` varchar(20) DEFAULT "sentence",
+  `actual_hf_token` text,
+  `actual_print_progress` boolean DEFAULT false,
+  `actual_attention_context_left` integer DEFAULT 256,
+  `actual_attention_context_right` integer DEFAULT 256,
+  `actual_is_multi_track_enabled` boolean DEFAULT false,
+  `actual_split_channels` varchar(10),
+  `actual_range_start` real,
+  `actual_range_end` real,
+  `actual_translate_to` text,
+  `actual_translation_provider` varchar(20),
+  `status` varchar(20) NOT NULL,
+  `error_message` text,
+  `created_at` datetime,
+  `updated_at` datetime,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_transcription_job_executions_transcription_job` FOREIGN KEY (`transcription_job_id`) REFERENCES `transcription_jobs`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_transcription_job_executions_transcription_job_id` ON `transcription_job_executions`(`transcription_job_id`);
+CREATE TABLE IF NOT EXISTS `speaker_mappings` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `transcription_job_id` varchar(36) NOT NULL,
+  `original_speaker` varchar(50) NOT NULL,
+  `custom_name` varchar(100) NOT NULL,
+  `created_at` datetime,
+  `updated_at` datetime,
+  CONSTRAINT `fk_speaker_mappings_transcription_job` FOREIGN KEY (`transcription_job_id`) REFERENCES `transcription_jobs`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_speaker_mappings_transcription_job_id` ON `speaker_mappings`(`transcription_job_id`);
+CREATE TABLE IF NOT EXISTS `multi_track_files` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `transcription_job_id` varchar(36) NOT NULL,
+  `file_name` varchar(255) NOT NULL,
+  `file_path` text NOT NULL,
+  `track_index` integer NOT NULL,
+  `offset` real DEFAULT 0,
+  `gain` real DEFAULT 1,
+  `pan` real DEFAULT 0,
+  `mute` boolean DEFAULT false,
+  `solo` boolean DEFAULT false,
+  `volume_envelope` text,
+  `clips` text,
+  `speaker_name` varchar(255),
+  `created_at` datetime,
+  `updated_at` datetime,
+  CONSTRAINT `fk_transcription_jobs_multi_track_files` FOREIGN KEY (`transcription_job_id`) REFERENCES `transcription_jobs`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_multi_track_files_transcription_job_id` ON `multi_track_files`(`transcription_job_id`);
+CREATE TABLE IF NOT EXISTS `users` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `username` varchar(50) NOT NULL,
+  `password` varchar(255) NOT NULL,
+  `email` varchar(255),
+  `default_profile_id` varchar(36),
+  `auto_transcription_enabled` numeric NOT NULL DEFAULT false,
+  `fast_finalize_enabled` numeric NOT NULL DEFAULT true,
+  `source_audio_action` varchar(10),
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_email` ON `users`(`email`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_users_username` ON `users`(`username`);
+CREATE TABLE IF NOT EXISTS `api_keys` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `key` varchar(255) NOT NULL,
+  `name` varchar(100) NOT NULL,
+  `description` text,
+  `is_active` boolean NOT NULL,
+  `last_used` datetime,
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_api_keys_key` ON `api_keys`(`key`);
+CREATE TABLE IF NOT EXISTS `api_key_usages` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `api_key_id` integer NOT NULL,
+  `hour` datetime NOT NULL,
+  `requests` integer NOT NULL DEFAULT 0,
+  `errors` integer NOT NULL DEFAULT 0,
+  `audio_seconds` real NOT NULL DEFAULT 0
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_api_key_usage_hour` ON `api_key_usages`(`api_key_id`,`hour`);
+CREATE TABLE IF NOT EXISTS `api_key_alerts` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `api_key_id` integer NOT NULL,
+  `kind` varchar(30) NOT NULL,
+  `message` text NOT NULL,
+  `observed` real,
+  `baseline` real,
+  `resolution` varchar(20),
+  `resolved_at` datetime,
+  `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_api_key_alerts_api_key_id` ON `api_key_alerts`(`api_key_id`);
+CREATE TABLE IF NOT EXISTS `upload_tokens` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `token_hash` varchar(64) NOT NULL,
+  `api_key_id` integer,
+  `user_id` integer,
+  `max_bytes` integer NOT NULL,
+  `allowed_origin` varchar(255),
+  `title` text,
+  `expires_at` datetime NOT NULL,
+  `used_at` datetime,
+  `job_id` varchar(36),
+  `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_upload_tokens_expires_at` ON `upload_tokens`(`expires_at`);
+CREATE INDEX IF NOT EXISTS `idx_upload_tokens_user_id` ON `upload_tokens`(`user_id`);
+CREATE INDEX IF NOT EXISTS `idx_upload_tokens_api_key_id` ON `upload_tokens`(`api_key_id`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_upload_tokens_token_hash` ON `upload_tokens`(`token_hash`);
+CREATE TABLE IF NOT EXISTS `job_events` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `job_id` varchar(36) NOT NULL,
+  `event` varchar(32) NOT NULL,
+  `attrs` text,
+  `created_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_job_events_created_at` ON `job_events`(`created_at`);
+CREATE INDEX IF NOT EXISTS `idx_job_events_job_id` ON `job_events`(`job_id`);
+CREATE TABLE IF NOT EXISTS `ingested_objects` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `bucket` varchar(255) NOT NULL,
+  `key` text NOT NULL,
+  `e_tag` varchar(255) NOT NULL,
+  `job_id` varchar(36),
+  `error` text,
+  `created_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_ingested_object` ON `ingested_objects`(`bucket`,`key`,`e_tag`);
+CREATE TABLE IF NOT EXISTS `ingested_mails` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `job_id` varchar(36) NOT NULL,
+  `sender` varchar(255) NOT NULL,
+  `message_id` text,
+  `subject` text,
+  `replied_at` datetime,
+  `created_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_ingested_mails_job_id` ON `ingested_mails`(`job_id`);
+CREATE TABLE IF NOT EXISTS `transcription_profiles` (
+  `id` varchar(36),
+  `name` varchar(255) NOT NULL,
+  `description` text,
+  `is_default` boolean DEFAULT false,
+  `model_family` varchar(20) DEFAULT "whisper",
+  `model` varchar(50) DEFAULT "small",
+  `model_cache_only` boolean DEFAULT false,
+  `model_dir` text,
+  `device` varchar(20) DEFAULT "cpu",
+  `device_index` integer DEFAULT 0,
+  `batch_size` integer DEFAULT 8,
+  `compute_type` varchar(20) DEFAULT "float32",
+  `threads` integer DEFAULT 0,
+  `output_format` varchar(20) DEFAULT "all",
+  `verbose` boolean DEFAULT true,
+  `task` varchar(20) DEFAULT "transcribe",
+  `language` varchar(10),
+  `align_model` varchar(100),
+  `interpolate_method` varchar(20) DEFAULT "nearest",
+  `no_align` boolean DEFAULT false,
+  `return_char_alignments` boolean DEFAULT false,
+  `normalize` boolean DEFAULT false,
+  `trim_silence` boolean DEFAULT false,
+  `denoise` boolean DEFAULT false,
+  `vad_method` varchar(20) DEFAULT "pyannote",
+  `vad_onset` real DEFAULT 0.5,
+  `vad_offset` real DEFAULT 0.363,
+  `chunk_size` integer DEFAULT 30,
+  `diarize` boolean DEFAULT false,
+  `min_speakers` integer,
+  `max_speakers` integer,
+  `diarize_model` varchar(50) DEFAULT "pyannote",
+  `speaker_embeddings` boolean DEFAULT false,
+  `temperature` real DEFAULT 0,
+  `best_of` integer DEFAULT 5,
+  `beam_size` integer DEFAULT 5,
+  `patience` real DEFAULT 1,
+  `length_penalty` real DEFAULT 1,
+  `suppress_tokens` text,
+  `suppress_numerals` boolean DEFAULT false,
+  `initial_prompt` text,
+  `condition_on_previous_text` boolean DEFAULT false,
+  `fp16` boolean DEFAULT true,
+  `temperature_increment_on_fallback` real DEFAULT 0.2,
+  `compression_ratio_threshold` real DEFAULT 2.4,
+  `logprob_threshold` real DEFAULT -1,
+  `no_speech_threshold` real DEFAULT 0.6,
+  `max_line_width` integer,
+  `max_line_count` integer,
+  `highlight_words` boolean DEFAULT false,
+  `segment_resolution` varchar(20) DEFAULT "sentence",
+  `hf_token` text,
+  `print_progress` boolean DEFAULT false,
+  `attention_context_left` integer DEFAULT 256,
+  `attention_context_right` integer DEFAULT 256,
+  `is_multi_track_enabled` boolean DEFAULT false,
+  `split_channels` varchar(10),
+  `range_start` real,
+  `range_end` real,
+  `translate_to` text,
+  `translation_provider` varchar(20),
+  `created_at` datetime,
+  `updated_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE TABLE IF NOT EXISTS `llm_configs` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `provider` varchar(50) NOT NULL,
+  `base_url` text,
+  `api_key` text,
+  `is_active` boolean DEFAULT false,
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE TABLE IF NOT EXISTS `chat_sessions` (
+  `id` varchar(36),
+  `job_id` varchar(36) NOT NULL,
+  `transcription_id` varchar(36) NOT NULL,
+  `title` varchar(255) NOT NULL,
+  `model` varchar(100) NOT NULL,
+  `provider` varchar(50) NOT NULL DEFAULT "openai",
+  `system_context` text,
+  `message_count` integer DEFAULT 0,
+  `last_activity_at` datetime,
+  `is_active` boolean DEFAULT true,
+  `created_at` datetime,
+  `updated_at` datetime,
+  PRIMARY KEY (`id`),
+  CONSTRAINT `fk_chat_sessions_transcription` FOREIGN KEY (`transcription_id`) REFERENCES `transcription_jobs`(`id`),
+  CONSTRAINT `fk_chat_sessions_job` FOREIGN KEY (`job_id`) REFERENCES `transcription_jobs`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_chat_sessions_transcription_id` ON `chat_sessions`(`transcription_id`);
+CREATE TABLE IF NOT EXISTS `chat_messages` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `session_id` varchar(36) NOT NULL,
+  `chat_session_id` varchar(36) NOT NULL,
+  `role` varchar(20) NOT NULL,
+  `content` text NOT NULL,
+  `tokens_used` integer,
+  `created_at` datetime,
+  CONSTRAINT `fk_chat_sessions_messages` FOREIGN KEY (`chat_session_id`) REFERENCES `chat_sessions`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_chat_messages_chat_session_id` ON `chat_messages`(`chat_session_id`);
+CREATE INDEX IF NOT EXISTS `idx_chat_messages_session_id` ON `chat_messages`(`session_id`);
+CREATE TABLE IF NOT EXISTS `summary_templates` (
+  `id` varchar(36),
+  `name` varchar(255) NOT NULL,
+  `description` text,
+  `model` varchar(255) NOT NULL DEFAULT "",
+  `prompt` text NOT NULL,
+  `created_at` datetime,
+  `updated_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE TABLE IF NOT EXISTS `summary_settings` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `default_model` varchar(255) NOT NULL DEFAULT "",
+  `updated_at` datetime
+);
+CREATE TABLE IF NOT EXISTS `summaries` (
+  `id` varchar(36),
+  `transcription_id` varchar(36) NOT NULL,
+  `template_id` varchar(36),
+  `model` varchar(255) NOT NULL,
+  `content` text NOT NULL,
+  `created_at` datetime,
+  `updated_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_summaries_transcription_id` ON `summaries`(`transcription_id`);
+CREATE TABLE IF NOT EXISTS `summary_regenerations` (
+  `id` varchar(36),
+  `template_id` varchar(36) NOT NULL,
+  `model` varchar(255) NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT "pending",
+  `total` integer NOT NULL DEFAULT 0,
+  `succeeded` integer NOT NULL DEFAULT 0,
+  `failed` integer NOT NULL DEFAULT 0,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `completed_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_summary_regenerations_template_id` ON `summary_regenerations`(`template_id`);
+CREATE TABLE IF NOT EXISTS `summary_regeneration_items` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `regeneration_id` varchar(36) NOT NULL,
+  `transcription_id` varchar(36) NOT NULL,
+  `status` varchar(20) NOT NULL DEFAULT "pending",
+  `summary_id` varchar(36),
+  `error` text,
+  `updated_at` datetime,
+  CONSTRAINT `fk_summary_regenerations_items` FOREIGN KEY (`regeneration_id`) REFERENCES `summary_regenerations`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_summary_regeneration_items_regeneration_id` ON `summary_regeneration_items`(`regeneration_id`);
+CREATE TABLE IF NOT EXISTS `notes` (
+  `id` varchar(36),
+  `transcription_id` varchar(36) NOT NULL,
+  `start_word_index` integer NOT NULL,
+  `end_word_index` integer NOT NULL,
+  `start_time` real NOT NULL,
+  `end_time` real NOT NULL,
+  `quote` text NOT NULL,
+  `content` text NOT NULL,
+  `created_at` datetime,
+  `updated_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_notes_transcription_id` ON `notes`(`transcription_id`);
+CREATE TABLE IF NOT EXISTS `refresh_tokens` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `hashed` varchar(128) NOT NULL,
+  `expires_at` datetime NOT NULL,
+  `revoked` numeric NOT NULL DEFAULT false,
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_refresh_tokens_revoked` ON `refresh_tokens`(`revoked`);
+CREATE INDEX IF NOT EXISTS `idx_refresh_tokens_expires_at` ON `refresh_tokens`(`expires_at`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_refresh_tokens_hashed` ON `refresh_tokens`(`hashed`);
+CREATE INDEX IF NOT EXISTS `idx_refresh_tokens_user_id` ON `refresh_tokens`(`user_id`);
+CREATE TABLE IF NOT EXISTS `live_transcription_sessions` (
+  `id` varchar(36),
+  `title` text,
+  `status` varchar(20) NOT NULL DEFAULT "active",
+  `model_family` varchar(20) DEFAULT "whisper",
+  `model` varchar(50) DEFAULT "small",
+  `model_cache_only` boolean DEFAULT false,
+  `model_dir` text,
+  `device` varchar(20) DEFAULT "cpu",
+  `device_index` integer DEFAULT 0,
+  `batch_size` integer DEFAULT 8,
+  `compute_type` varchar(20) DEFAULT "float32",
+  `threads` integer DEFAULT 0,
+  `output_format` varchar(20) DEFAULT "all",
+  `verbose` boolean DEFAULT true,
+  `task` varchar(20) DEFAULT "transcribe",
+  `language` varchar(10),
+  `align_model` varchar(100),
+  `interpolate_method` varchar(20) DEFAULT "nearest",
+  `no_align` boolean DEFAULT false,
+  `return_char_alignments` boolean DEFAULT false,
+  `normalize` boolean DEFAULT false,
+  `trim_silence` boolean DEFAULT false,
+  `denoise` boolean DEFAULT false,
+  `vad_method` varchar(20) DEFAULT "pyannote",
+  `vad_onset` real DEFAULT 0.5,
+  `vad_offset` real DEFAULT 0.363,
+  `chunk_size` integer DEFAULT 30,
+  `diarize` boolean DEFAULT false,
+  `min_speakers` integer,
+  `max_speakers` integer,
+  `diarize_model` varchar(50) DEFAULT "pyannote",
+  `speaker_embeddings` boolean DEFAULT false,
+  `temperature` real DEFAULT 0,
+  `best_of` integer DEFAULT 5,
+  `beam_size` integer DEFAULT 5,
+  `patience` real DEFAULT 1,
+  `length_penalty` real DEFAULT 1,
+  `suppress_tokens` text,
+  `suppress_numerals` boolean DEFAULT false,
+  `initial_prompt` text,
+  `condition_on_previous_text` boolean DEFAULT false,
+  `fp16` boolean DEFAULT true,
+  `temperature_increment_on_fallback` real DEFAULT 0.2,
+  `compression_ratio_threshold` real DEFAULT 2.4,
+  `logprob_threshold` real DEFAULT -1,
+  `no_speech_threshold` real DEFAULT 0.6,
+  `max_line_width` integer,
+  `max_line_count` integer,
+  `highlight_words` boolean DEFAULT false,
+  `segment_resolution` varchar(20) DEFAULT "sentence",
+  `hf_token` text,
+  `print_progress` boolean DEFAULT false,
+  `attention_context_left` integer DEFAULT 256,
+  `attention_context_right` integer DEFAULT 256,
+  `is_multi_track_enabled` boolean DEFAULT false,
+  `split_channels` varchar(10),
+  `range_start` real,
+  `range_end` real,
+  `translate_to` text,
+  `translation_provider` varchar(20),
+  `chunk_count` integer NOT NULL DEFAULT 0,
+  `last_sequence` integer NOT NULL DEFAULT 0,
+  `accumulated_transcript` text,
+  `output_audio_path` text,
+  `final_job_id` varchar(36),
+  `created_at` datetime,
+  `updated_at` datetime,
+  `completed_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE TABLE IF NOT EXISTS `live_transcription_chunks` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `session_id` varchar(36) NOT NULL,
+  `sequence` integer NOT NULL,
+  `start_offset` real,
+  `end_offset` real,
+  `audio_path` text NOT NULL,
+  `transcript_json` text,
+  `created_at` datetime,
+  CONSTRAINT `fk_live_transcription_sessions_chunks` FOREIGN KEY (`session_id`) REFERENCES `live_transcription_sessions`(`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_live_transcription_chunks_session_id` ON `live_transcription_chunks`(`session_id`);
+CREATE TABLE IF NOT EXISTS `audio_conversions` (
+  `id` varchar(36),
+  `job_id` varchar(36),
+  `user_id` integer,
+  `source_name` text,
+  `source_path` text NOT NULL,
+  `uploaded_source` numeric NOT NULL DEFAULT false,
+  `format` varchar(10) NOT NULL,
+  `bitrate` varchar(10),
+  `status` varchar(20) NOT NULL DEFAULT "pending",
+  `output_path` text,
+  `output_size` integer,
+  `error` text,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `completed_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_audio_conversions_status` ON `audio_conversions`(`status`);
+CREATE INDEX IF NOT EXISTS `idx_audio_conversions_user_id` ON `audio_conversions`(`user_id`);
+CREATE INDEX IF NOT EXISTS `idx_audio_conversions_job_id` ON `audio_conversions`(`job_id`);
+CREATE TABLE IF NOT EXISTS `translations` (
+  `id` varchar(36),
+  `transcription_id` varchar(36) NOT NULL,
+  `target_language` varchar(10) NOT NULL,
+  `provider` varchar(20) NOT NULL,
+  `model` varchar(255) NOT NULL DEFAULT "",
+  `status` varchar(20) NOT NULL,
+  `error` text,
+  `transcript` text,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `completed_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_translations_status` ON `translations`(`status`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_translations_target` ON `translations`(`transcription_id`,`target_language`);
+CREATE TABLE IF NOT EXISTS `transcript_revisions` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `transcription_id` varchar(36) NOT NULL,
+  `number` integer NOT NULL,
+  `source` varchar(20) NOT NULL,
+  `author` varchar(255),
+  `note` text,
+  `segment_ids` text,
+  `reverted_to` integer,
+  `transcript` text NOT NULL,
+  `created_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_transcript_revisions_number` ON `transcript_revisions`(`transcription_id`,`number`);
+CREATE TABLE IF NOT EXISTS `transcript_insights` (
+  `id` varchar(36),
+  `transcription_id` varchar(36) NOT NULL,
+  `model` varchar(255) NOT NULL DEFAULT "",
+  `status` varchar(20) NOT NULL,
+  `error` text,
+  `summary` text,
+  `action_items` text,
+  `chapters` text,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `completed_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_transcript_insights_status` ON `transcript_insights`(`status`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_transcript_insights_transcription_id` ON `transcript_insights`(`transcription_id`);
+CREATE TABLE IF NOT EXISTS `webhooks` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `user_id` integer NOT NULL,
+  `url` text NOT NULL,
+  `secret` varchar(255) NOT NULL,
+  `events` text NOT NULL DEFAULT "",
+  `is_active` boolean NOT NULL,
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_webhooks_user_id` ON `webhooks`(`user_id`);
+CREATE TABLE IF NOT EXISTS `webhook_deliveries` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `webhook_id` integer NOT NULL,
+  `job_id` varchar(36) NOT NULL,
+  `event` varchar(50) NOT NULL,
+  `payload` text NOT NULL,
+  `status` varchar(20) NOT NULL,
+  `attempts` integer NOT NULL DEFAULT 0,
+  `response_status` integer,
+  `error` text,
+  `next_attempt_at` datetime,
+  `delivered_at` datetime,
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE INDEX IF NOT EXISTS `idx_webhook_deliveries_status` ON `webhook_deliveries`(`status`);
+CREATE INDEX IF NOT EXISTS `idx_webhook_deliveries_job_id` ON `webhook_deliveries`(`job_id`);
+CREATE INDEX IF NOT EXISTS `idx_webhook_deliveries_webhook_id` ON `webhook_deliveries`(`webhook_id`);
+CREATE TABLE IF NOT EXISTS `remote_downloads` (
+  `id` varchar(36),
+  `job_id` varchar(36) NOT NULL,
+  `source` varchar(20) NOT NULL,
+  `url` text NOT NULL,
+  `host` varchar(255),
+  `status` varchar(20) NOT NULL,
+  `bytes_done` integer,
+  `bytes_total` integer,
+  `progress` real,
+  `error` text,
+  `created_at` datetime,
+  `updated_at` datetime,
+  `completed_at` datetime,
+  PRIMARY KEY (`id`)
+);
+CREATE INDEX IF NOT EXISTS `idx_remote_downloads_status` ON `remote_downloads`(`status`);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_remote_downloads_job_id` ON `remote_downloads`(`job_id`);
+CREATE TABLE IF NOT EXISTS `collections` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `name` varchar(255) NOT NULL,
+  `description` text,
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_collections_name` ON `collections`(`name`);
+CREATE TABLE IF NOT EXISTS `stored_objects` (
+  `id` integer PRIMARY KEY AUTOINCREMENT,
+  `job_id` varchar(36) NOT NULL,
+  `kind` varchar(20) NOT NULL,
+  `key` text NOT NULL,
+  `local_path` text NOT NULL,
+  `size` integer,
+  `created_at` datetime,
+  `updated_at` datetime
+);
+CREATE UNIQUE INDEX IF NOT EXISTS `idx_stored_object` ON `stored_objects`(`job_id`,`local_path`);
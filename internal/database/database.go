@@ -7,18 +7,57 @@ import (
 	"time"
 
 	"synthezia/internal/config"
+	"synthezia/internal/faults"
 	"synthezia/internal/models"
 
 	"github.com/glebarez/sqlite"
 	"gorm.io/gorm"
-	"gorm.io/gorm/logger"
 )
 
 // DB is the global database instance
 var DB *gorm.DB
 
-// Initialize initializes the database connection with optimized settings
+// Initialize opens the database, brings its schema up to date and seeds
+// defaults. With DatabaseManualMigrations it only checks that no migrations
+// are pending.
 func Initialize(cfg *config.Config) error {
+	if err := Open(cfg); err != nil {
+		return err
+	}
+
+	if cfg.DatabaseManualMigrations {
+		pending, err := Pending(DB)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("%d database migrations are pending (next: %d %s); run \"synthezia migrate up\"", len(pending), pending[0].Version, pending[0].Name)
+		}
+	} else if _, err := Migrate(DB); err != nil {
+		return err
+	}
+
+	// Create default transcription profile if none exists
+	if err := ensureDefaultProfile(); err != nil {
+		return fmt.Errorf("failed to create default profile: %v", err)
+	}
+
+	// Seed LLM config from environment variables
+	if err := seedLLMConfig(cfg); err != nil {
+		return fmt.Errorf("failed to seed LLM config: %v", err)
+	}
+
+	// Hook fault injection after setup so startup itself is never affected
+	if err := registerFaultCallbacks(); err != nil {
+		return fmt.Errorf("failed to register fault injection callbacks: %v", err)
+	}
+
+	return nil
+}
+
+// Open connects to the database with optimized settings, without touching
+// its schema
+func Open(cfg *config.Config) error {
 	var err error
 
 	// Create database directory if it doesn't exist
@@ -39,7 +78,7 @@ func Initialize(cfg *config.Config) error {
 
 	// Open database connection with optimized config
 	DB, err = gorm.Open(sqlite.Open(dsn), &gorm.Config{
-		Logger:          logger.Default.LogMode(logger.Warn), // Reduce logging overhead
+		Logger:          newQueryLogger(),                    // Slow queries go through the app logger
 		CreateBatchSize: 100,                                 // Optimize batch inserts
 	})
 	if err != nil {
@@ -58,45 +97,32 @@ func Initialize(cfg *config.Config) error {
 	sqlDB.SetConnMaxLifetime(30 * time.Minute) // Reset connections every 30 minutes
 	sqlDB.SetConnMaxIdleTime(5 * time.Minute)  // Close idle connections after 5 minutes
 
-	// Auto migrate the schema
-	if err := DB.AutoMigrate(
-		&models.TranscriptionJob{},
-		&models.TranscriptionJobExecution{},
-		&models.SpeakerMapping{},
-		&models.MultiTrackFile{},
-		&models.User{},
-		&models.APIKey{},
-		&models.TranscriptionProfile{},
-		&models.LLMConfig{},
-		&models.ChatSession{},
-		&models.ChatMessage{},
-		&models.SummaryTemplate{},
-		&models.SummarySetting{},
-		&models.Summary{},
-		&models.Note{},
-		&models.RefreshToken{},
-		&models.LiveTranscriptionSession{},
-		&models.LiveTranscriptionChunk{},
-	); err != nil {
-		return fmt.Errorf("failed to auto migrate: %v", err)
-	}
-
-	// Add unique constraint for speaker mappings (transcription_job_id + original_speaker)
-	if err := DB.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_speaker_mappings_unique ON speaker_mappings(transcription_job_id, original_speaker)").Error; err != nil {
-		return fmt.Errorf("failed to create unique constraint for speaker mappings: %v", err)
-	}
+	return nil
+}
 
-	// Create default transcription profile if none exists
-	if err := ensureDefaultProfile(); err != nil {
-		return fmt.Errorf("failed to create default profile: %v", err)
+// registerFaultCallbacks lets fault injection simulate lock contention on any
+// statement. The callbacks are no-ops unless fault injection is enabled.
+func registerFaultCallbacks() error {
+	inject := func(db *gorm.DB) {
+		if err := faults.Inject(faults.DBLock); err != nil {
+			db.AddError(err)
+		}
 	}
 
-	// Seed LLM config from environment variables
-	if err := seedLLMConfig(cfg); err != nil {
-		return fmt.Errorf("failed to seed LLM config: %v", err)
+	callbacks := DB.Callback()
+	if err := callbacks.Create().Before("gorm:create").Register("faults:db_lock", inject); err != nil {
+		return err
 	}
-
-	return nil
+	if err := callbacks.Query().Before("gorm:query").Register("faults:db_lock", inject); err != nil {
+		return err
+	}
+	if err := callbacks.Update().Before("gorm:update").Register("faults:db_lock", inject); err != nil {
+		return err
+	}
+	if err := callbacks.Delete().Before("gorm:delete").Register("faults:db_lock", inject); err != nil {
+		return err
+	}
+	return callbacks.Raw().Before("gorm:raw").Register("faults:db_lock", inject)
 }
 
 // seedLLMConfig seeds the LLM configuration from environment variables if not present
@@ -0,0 +1,214 @@
+package database
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is one versioned change to the schema. Up applies it and Down
+// reverts it; each runs in its own transaction.
+type Migration struct {
+	Version int
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// SchemaMigration records an applied migration in schema_migrations
+type SchemaMigration struct {
+	Version   int       `json:"version" gorm:"primaryKey;autoIncrement:false"`
+	Name      string    `json:"name" gorm:"type:varchar(255);not null"`
+	AppliedAt time.Time `json:"applied_at"`
+}
+
+// MigrationStatus is a known migration and when it was applied, if it was
+type MigrationStatus struct {
+	Version   int        `json:"version"`
+	Name      string     `json:"name"`
+	AppliedAt *time.Time `json:"applied_at,omitempty"`
+}
+
+// LatestVersion is the version of the newest known migration
+func LatestVersion() int {
+	if len(migrations) == 0 {
+		return 0
+	}
+	return migrations[len(migrations)-1].Version
+}
+
+// CurrentVersion is the newest applied migration, or 0 when none are
+func CurrentVersion(db *gorm.DB) (int, error) {
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+	version := 0
+	for v := range done {
+		if v > version {
+			version = v
+		}
+	}
+	return version, nil
+}
+
+// applied returns the applied migrations by version, creating
+// schema_migrations on first use
+func applied(db *gorm.DB) (map[int]SchemaMigration, error) {
+	if err := db.AutoMigrate(&SchemaMigration{}); err != nil {
+		return nil, fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+	var rows []SchemaMigration
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read schema_migrations: %v", err)
+	}
+	done := make(map[int]SchemaMigration, len(rows))
+	for _, row := range rows {
+		done[row.Version] = row
+	}
+	return done, nil
+}
+
+// Status lists every known migration, oldest first, and applied versions
+// this build does not know, which a newer build left behind
+func Status(db *gorm.DB) ([]MigrationStatus, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	statuses := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		status := MigrationStatus{Version: m.Version, Name: m.Name}
+		if row, ok := done[m.Version]; ok {
+			status.AppliedAt = &row.AppliedAt
+			delete(done, m.Version)
+		}
+		statuses = append(statuses, status)
+	}
+	for _, row := range done {
+		appliedAt := row.AppliedAt
+		statuses = append(statuses, MigrationStatus{Version: row.Version, Name: row.Name + " (unknown)", AppliedAt: &appliedAt})
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Version < statuses[j].Version })
+	return statuses, nil
+}
+
+// Pending lists the migrations that have not been applied, oldest first
+func Pending(db *gorm.DB) ([]Migration, error) {
+	done, err := applied(db)
+	if err != nil {
+		return nil, err
+	}
+	var pending []Migration
+	for _, m := range migrations {
+		if _, ok := done[m.Version]; !ok {
+			pending = append(pending, m)
+		}
+	}
+	return pending, nil
+}
+
+// Migrate applies every pending migration, returning how many ran
+func Migrate(db *gorm.DB) (int, error) {
+	return MigrateTo(db, LatestVersion())
+}
+
+// Rollback reverts the newest steps applied migrations, returning how many
+// were reverted
+func Rollback(db *gorm.DB, steps int) (int, error) {
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+	versions := make([]int, 0, len(done))
+	for version := range done {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+	if steps >= len(versions) {
+		return MigrateTo(db, 0)
+	}
+	return MigrateTo(db, versions[len(versions)-steps-1])
+}
+
+// MigrateTo brings the schema to version: migrations up to it are applied
+// oldest first and newer ones reverted newest first. Version 0 reverts
+// everything.
+func MigrateTo(db *gorm.DB, version int) (int, error) {
+	if version != 0 && find(version) == nil {
+		return 0, fmt.Errorf("unknown migration version %d", version)
+	}
+	done, err := applied(db)
+	if err != nil {
+		return 0, err
+	}
+	for v, row := range done {
+		if v > version && find(v) == nil {
+			return 0, fmt.Errorf("migration %d (%s) was applied by a newer version and cannot be reverted by this one", v, row.Name)
+		}
+	}
+
+	count := 0
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, ok := done[m.Version]; !ok || m.Version <= version {
+			continue
+		}
+		if m.Down == nil {
+			return count, fmt.Errorf("migration %d (%s) cannot be reverted", m.Version, m.Name)
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		}); err != nil {
+			return count, fmt.Errorf("failed to revert migration %d (%s): %v", m.Version, m.Name, err)
+		}
+		count++
+	}
+	for _, m := range migrations {
+		if _, ok := done[m.Version]; ok || m.Version > version {
+			continue
+		}
+		if err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		}); err != nil {
+			return count, fmt.Errorf("failed to apply migration %d (%s): %v", m.Version, m.Name, err)
+		}
+		count++
+	}
+	return count, nil
+}
+
+// find returns the known migration with version, or nil
+func find(version int) *Migration {
+	for i := range migrations {
+		if migrations[i].Version == version {
+			return &migrations[i]
+		}
+	}
+	return nil
+}
+
+// addColumn runs an ALTER TABLE ... ADD COLUMN statement unless the table
+// already has the column, as a database AutoMigrate created may
+func addColumn(tx *gorm.DB, table, column, statement string) error {
+	if tx.Migrator().HasColumn(table, column) {
+		return nil
+	}
+	return tx.Exec(statement).Error
+}
+
+// dropColumn drops a column if the table has it
+func dropColumn(tx *gorm.DB, table, column string) error {
+	if !tx.Migrator().HasColumn(table, column) {
+		return nil
+	}
+	return tx.Exec(fmt.Sprintf("ALTER TABLE `%s` DROP COLUMN `%s`", table, column)).Error
+}
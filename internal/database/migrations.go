@@ -0,0 +1,173 @@
+package database
+
+import (
+	_ "embed"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// migrations is the schema history, oldest first. Append new migrations with
+// the next version; never edit or renumber one that has shipped.
+var migrations = []Migration{
+	{
+		Version: 1,
+		Name:    "baseline",
+		Up:      applyBaseline,
+		Down: func(tx *gorm.DB) error {
+			tables := baselineTables()
+			for i := len(tables) - 1; i >= 0; i-- {
+				if err := tx.Migrator().DropTable(tables[i]); err != nil {
+					return err
+				}
+			}
+			return nil
+		},
+	},
+	{
+		Version: 2,
+		Name:    "speaker_mappings_unique",
+		Up: func(tx *gorm.DB) error {
+			return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS idx_speaker_mappings_unique ON speaker_mappings(transcription_job_id, original_speaker)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP INDEX IF EXISTS idx_speaker_mappings_unique").Error
+		},
+	},
+	{
+		Version: 3,
+		Name:    "transcript_search",
+		Up: func(tx *gorm.DB) error {
+			// Full-text index of transcript segments, kept by the search package
+			return tx.Exec("CREATE VIRTUAL TABLE IF NOT EXISTS transcript_search USING fts5(job_id UNINDEXED, segment UNINDEXED, start_time UNINDEXED, end_time UNINDEXED, speaker UNINDEXED, text, tokenize = 'porter unicode61 remove_diacritics 2')").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE IF EXISTS transcript_search").Error
+		},
+	},
+	{
+		Version: 4,
+		Name:    "job_versions",
+		Up: func(tx *gorm.DB) error {
+			return addColumn(tx, "transcription_jobs", "version", "ALTER TABLE `transcription_jobs` ADD COLUMN `version` integer NOT NULL DEFAULT 1")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "transcription_jobs", "version")
+		},
+	},
+	{
+		Version: 5,
+		Name:    "job_recoveries",
+		Up: func(tx *gorm.DB) error {
+			return addColumn(tx, "transcription_jobs", "recoveries", "ALTER TABLE `transcription_jobs` ADD COLUMN `recoveries` integer NOT NULL DEFAULT 0")
+		},
+		Down: func(tx *gorm.DB) error {
+			return dropColumn(tx, "transcription_jobs", "recoveries")
+		},
+	},
+	{
+		Version: 6,
+		Name:    "job_progress",
+		Up: func(tx *gorm.DB) error {
+			if err := addColumn(tx, "transcription_jobs", "progress", "ALTER TABLE `transcription_jobs` ADD COLUMN `progress` real NOT NULL DEFAULT 0"); err != nil {
+				return err
+			}
+			return addColumn(tx, "transcription_jobs", "processed_audio_seconds", "ALTER TABLE `transcription_jobs` ADD COLUMN `processed_audio_seconds` real NOT NULL DEFAULT 0")
+		},
+		Down: func(tx *gorm.DB) error {
+			if err := dropColumn(tx, "transcription_jobs", "processed_audio_seconds"); err != nil {
+				return err
+			}
+			return dropColumn(tx, "transcription_jobs", "progress")
+		},
+	},
+	{
+		Version: 7,
+		Name:    "ingested_archive_members",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Exec("CREATE TABLE IF NOT EXISTS `ingested_archive_members` (`id` integer PRIMARY KEY AUTOINCREMENT, `archive_hash` varchar(64) NOT NULL, `member` text NOT NULL, `job_id` varchar(36), `created_at` datetime)").Error; err != nil {
+				return err
+			}
+			return tx.Exec("CREATE UNIQUE INDEX IF NOT EXISTS `idx_ingested_archive_member` ON `ingested_archive_members`(`archive_hash`,`member`)").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Exec("DROP TABLE IF EXISTS `ingested_archive_members`").Error
+		},
+	},
+}
+
+// baselineSQL is the schema of migration 1, frozen as it was when versioned
+// migrations replaced AutoMigrate, so later model changes cannot alter it
+//
+//go:embed baseline.sql
+var baselineSQL string
+
+var baselineTablePattern = regexp.MustCompile("^CREATE TABLE IF NOT EXISTS `([^`]+)`")
+
+// baselineStatements splits baselineSQL into its statements, leaving out comments
+func baselineStatements() []string {
+	var statements []string
+	var current strings.Builder
+	for _, line := range strings.Split(baselineSQL, "\n") {
+		if strings.HasPrefix(line, "--") || strings.TrimSpace(line) == "" {
+			continue
+		}
+		current.WriteString(line)
+		current.WriteString("\n")
+		if strings.HasSuffix(line, ";") {
+			statements = append(statements, strings.TrimSpace(current.String()))
+			current.Reset()
+		}
+	}
+	return statements
+}
+
+// baselineTables are the tables migration 1 creates, in creation order
+func baselineTables() []string {
+	var tables []string
+	for _, statement := range baselineStatements() {
+		if match := baselineTablePattern.FindStringSubmatch(statement); match != nil {
+			tables = append(tables, match[1])
+		}
+	}
+	return tables
+}
+
+// applyBaseline creates the baseline schema. A database created by AutoMigrate
+// before versioned migrations keeps its tables, gaining any baseline columns
+// an older release had not added yet.
+func applyBaseline(tx *gorm.DB) error {
+	for _, statement := range baselineStatements() {
+		if match := baselineTablePattern.FindStringSubmatch(statement); match != nil && tx.Migrator().HasTable(match[1]) {
+			if err := addBaselineColumns(tx, match[1], statement); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := tx.Exec(statement).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addBaselineColumns adds the columns of a baseline CREATE TABLE statement,
+// one definition per line, that an existing table lacks
+func addBaselineColumns(tx *gorm.DB, table, statement string) error {
+	for _, line := range strings.Split(statement, "\n") {
+		definition := strings.TrimSuffix(strings.TrimSpace(line), ",")
+		if !strings.HasPrefix(definition, "`") {
+			continue
+		}
+		column := definition[1 : strings.Index(definition[1:], "`")+1]
+		if tx.Migrator().HasColumn(table, column) {
+			continue
+		}
+		if err := tx.Exec(fmt.Sprintf("ALTER TABLE `%s` ADD COLUMN %s", table, definition)).Error; err != nil {
+			return fmt.Errorf("failed to add %s.%s: %v", table, column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,41 @@
+package database
+
+import (
+	"context"
+	"log"
+	"os"
+	"time"
+
+	gormlogger "gorm.io/gorm/logger"
+
+	applogger "synthezia/pkg/logger"
+)
+
+// slowQueryLogger reports queries over the configured budget through the
+// application logger and leaves errors to gorm's own logger
+type slowQueryLogger struct {
+	gormlogger.Interface
+}
+
+// newQueryLogger builds gorm's default warn-level logger without its fixed
+// 200ms slow query report
+func newQueryLogger() gormlogger.Interface {
+	return &slowQueryLogger{gormlogger.New(log.New(os.Stdout, "\r\n", log.LstdFlags), gormlogger.Config{
+		LogLevel: gormlogger.Warn,
+		Colorful: true,
+	})}
+}
+
+func (l *slowQueryLogger) LogMode(level gormlogger.LogLevel) gormlogger.Interface {
+	return &slowQueryLogger{l.Interface.LogMode(level)}
+}
+
+func (l *slowQueryLogger) Trace(ctx context.Context, begin time.Time, fc func() (string, int64), err error) {
+	if budget := applogger.CurrentSlowThresholds().Query; budget > 0 {
+		if elapsed := time.Since(begin); elapsed > budget {
+			sql, rows := fc()
+			applogger.SlowQuery(ctx, elapsed, sql, rows, err)
+		}
+	}
+	l.Interface.Trace(ctx, begin, fc, err)
+}
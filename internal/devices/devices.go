@@ -0,0 +1,473 @@
+// Package devices finds the devices transcription can run on, CUDA GPUs
+// through nvidia-smi, the Apple GPU and the CPU, and hands them out to jobs.
+// A job is given the device with the most free memory among those with a
+// free slot and room for the memory its model needs, or waits until one has,
+// so several GPUs are all kept busy and jobs sharing a GPU do not run it out
+// of memory.
+package devices
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+)
+
+// Device kinds
+const (
+	KindCUDA = "cuda"
+	KindMPS  = "mps" // Apple GPU through Metal
+	KindCPU  = "cpu"
+)
+
+// ErrNoDevice means no device of this host can ever run a job
+var ErrNoDevice = errors.New("no device can run the job")
+
+// queryTimeout bounds one run of nvidia-smi
+const queryTimeout = 10 * time.Second
+
+// refreshInterval is how often a job waiting for a device re-reads the
+// memory in use, which other processes may have freed
+const refreshInterval = 5 * time.Second
+
+// Device describes one device and the jobs running on it
+type Device struct {
+	ID    string `json:"id"` // cuda:0, mps or cpu
+	Kind  string `json:"kind"`
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+	// Memory, in MB, of CUDA devices; others are not tracked
+	TotalMB int `json:"total_mb,omitempty"`
+	// UsedMB is in use by every process, as last measured
+	UsedMB int `json:"used_mb,omitempty"`
+	// ReservedMB is set aside for the jobs running on the device
+	ReservedMB  int `json:"reserved_mb,omitempty"`
+	Utilization int `json:"utilization_percent"`
+	// Slots is how many jobs may run on the device at once; 0 is unlimited
+	Slots int      `json:"slots"`
+	Jobs  []string `json:"jobs"`
+}
+
+// Options configure a device manager
+type Options struct {
+	// NvidiaSMI is the nvidia-smi binary; empty skips CUDA discovery
+	NvidiaSMI string
+	// GPUSlots is how many jobs share one GPU; 0 means 1
+	GPUSlots int
+	// CPUSlots is how many jobs run on the CPU at once; 0 is unlimited
+	CPUSlots int
+}
+
+// device is a Device with the memory each of its jobs reserved
+type device struct {
+	Device
+	externalMB int // in use by processes other than our jobs
+	jobs       map[string]int
+}
+
+// available is the memory a new job may reserve; untracked devices have no limit
+func (d *device) available() int {
+	if d.TotalMB == 0 {
+		return int(^uint(0) >> 1)
+	}
+	return d.TotalMB - d.externalMB - d.ReservedMB
+}
+
+func (d *device) hasSlot() bool {
+	return d.Slots == 0 || len(d.jobs) < d.Slots
+}
+
+// Manager hands devices out to jobs
+type Manager struct {
+	opts Options
+
+	mu      sync.Mutex
+	devices []*device
+	// changed is closed and replaced whenever a device is released or
+	// re-measured, waking the jobs waiting for one
+	changed chan struct{}
+}
+
+// NewManager creates a device manager knowing only the CPU; Discover finds
+// the GPUs
+func NewManager(opts Options) *Manager {
+	if opts.GPUSlots <= 0 {
+		opts.GPUSlots = 1
+	}
+	return &Manager{
+		opts:    opts,
+		devices: []*device{newDevice(Device{ID: KindCPU, Kind: KindCPU, Name: fmt.Sprintf("CPU (%d cores)", runtime.NumCPU()), Slots: opts.CPUSlots})},
+		changed: make(chan struct{}),
+	}
+}
+
+func newDevice(info Device) *device {
+	return &device{Device: info, jobs: map[string]int{}}
+}
+
+// Discover finds the CUDA devices nvidia-smi reports, keeping those
+// CUDA_VISIBLE_DEVICES lists and numbering them as CUDA does, and the Apple
+// GPU on Apple silicon. A host without nvidia-smi simply has no CUDA devices.
+func (m *Manager) Discover(ctx context.Context) error {
+	var found []*device
+	if runtime.GOOS == "darwin" && runtime.GOARCH == "arm64" {
+		found = append(found, newDevice(Device{ID: KindMPS, Kind: KindMPS, Name: "Apple GPU", Slots: m.opts.GPUSlots}))
+	}
+
+	gpus, err := m.query(ctx)
+	if err != nil && !errors.Is(err, exec.ErrNotFound) {
+		return err
+	}
+	for _, gpu := range visible(gpus, os.Getenv("CUDA_VISIBLE_DEVICES")) {
+		gpu.Slots = m.opts.GPUSlots
+		found = append(found, newDevice(gpu))
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.devices = append(found, m.devices[len(m.devices)-1])
+	for _, d := range m.devices {
+		logger.Info("Found device", "device", d.ID, "name", d.Name, "memory_mb", d.TotalMB, "slots", d.Slots)
+	}
+	return nil
+}
+
+// query runs nvidia-smi, returning one device per GPU with its physical index
+func (m *Manager) query(ctx context.Context) ([]Device, error) {
+	if m.opts.NvidiaSMI == "" {
+		return nil, exec.ErrNotFound
+	}
+	path, err := exec.LookPath(m.opts.NvidiaSMI)
+	if err != nil {
+		return nil, exec.ErrNotFound
+	}
+	ctx, cancel := context.WithTimeout(ctx, queryTimeout)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, "--query-gpu=index,name,memory.total,memory.used,utilization.gpu", "--format=csv,noheader,nounits")
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("nvidia-smi failed: %w: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return parseQuery(string(out))
+}
+
+// parseQuery reads lines such as "0, NVIDIA A100-SXM4-40GB, 40960, 1024, 3"
+func parseQuery(out string) ([]Device, error) {
+	var gpus []Device
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		fields := strings.Split(line, ",")
+		if len(fields) != 5 {
+			return nil, fmt.Errorf("unexpected nvidia-smi line %q", line)
+		}
+		var numbers [4]int
+		for i, field := range []string{fields[0], fields[2], fields[3], fields[4]} {
+			n, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				// Utilization is [N/A] on some devices
+				if i == 3 {
+					continue
+				}
+				return nil, fmt.Errorf("unexpected nvidia-smi line %q", line)
+			}
+			numbers[i] = n
+		}
+		gpus = append(gpus, Device{
+			Kind:        KindCUDA,
+			Index:       numbers[0],
+			Name:        strings.TrimSpace(fields[1]),
+			TotalMB:     numbers[1],
+			UsedMB:      numbers[2],
+			Utilization: numbers[3],
+		})
+	}
+	return gpus, nil
+}
+
+// visible keeps the GPUs a CUDA_VISIBLE_DEVICES list of indices names, in
+// its order, numbering them from 0 the way CUDA programs see them
+func visible(gpus []Device, list string) []Device {
+	if strings.TrimSpace(list) == "" {
+		for i := range gpus {
+			gpus[i].ID = fmt.Sprintf("cuda:%d", gpus[i].Index)
+		}
+		return gpus
+	}
+	var kept []Device
+	for _, field := range strings.Split(list, ",") {
+		physical, err := strconv.Atoi(strings.TrimSpace(field))
+		if err != nil {
+			continue
+		}
+		for _, gpu := range gpus {
+			if gpu.Index == physical {
+				gpu.Index = len(kept)
+				gpu.ID = fmt.Sprintf("cuda:%d", gpu.Index)
+				kept = append(kept, gpu)
+			}
+		}
+	}
+	return kept
+}
+
+// Refresh re-measures the memory in use and the utilization of the CUDA
+// devices. Memory used beyond what running jobs reserved belongs to other
+// processes and is not handed out.
+func (m *Manager) Refresh(ctx context.Context) error {
+	m.mu.Lock()
+	hasCUDA := slices.ContainsFunc(m.devices, func(d *device) bool { return d.Kind == KindCUDA })
+	m.mu.Unlock()
+	if !hasCUDA {
+		return nil
+	}
+
+	gpus, err := m.query(ctx)
+	if err != nil {
+		return err
+	}
+	gpus = visible(gpus, os.Getenv("CUDA_VISIBLE_DEVICES"))
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, d := range m.devices {
+		for _, gpu := range gpus {
+			if d.ID == gpu.ID {
+				d.UsedMB = gpu.UsedMB
+				d.Utilization = gpu.Utilization
+				d.externalMB = max(0, gpu.UsedMB-d.ReservedMB)
+			}
+		}
+	}
+	m.notify()
+	return nil
+}
+
+// notify wakes the jobs waiting for a device; the caller holds m.mu
+func (m *Manager) notify() {
+	close(m.changed)
+	m.changed = make(chan struct{})
+}
+
+// Devices describes every device, GPUs first
+func (m *Manager) Devices() []Device {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	list := make([]Device, 0, len(m.devices))
+	for _, d := range m.devices {
+		info := d.Device
+		info.Jobs = make([]string, 0, len(d.jobs))
+		for jobID := range d.jobs {
+			info.Jobs = append(info.Jobs, jobID)
+		}
+		slices.Sort(info.Jobs)
+		list = append(list, info)
+	}
+	return list
+}
+
+// GPUSlots is how many jobs the GPUs run at once, so the queue can start
+// enough workers to keep them all busy
+func (m *Manager) GPUSlots() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	slots := 0
+	for _, d := range m.devices {
+		if d.Kind != KindCPU {
+			slots += d.Slots
+		}
+	}
+	return slots
+}
+
+// Request describes what a job needs from a device
+type Request struct {
+	JobID string
+	// Device is the device the job asked for: cuda, cpu, or auto
+	Device      string
+	ModelFamily string
+	// MemoryMB is the GPU memory the job's models need
+	MemoryMB int
+}
+
+// RequestFor describes the device a job with these parameters needs
+func RequestFor(jobID string, params models.WhisperXParams) Request {
+	return Request{JobID: jobID, Device: params.Device, ModelFamily: params.ModelFamily, MemoryMB: EstimateMB(params)}
+}
+
+// Acquire waits until a device can run a job and reserves it. A job asking
+// for auto prefers a GPU, waiting for one it fits on, and runs on the CPU
+// when no GPU is large enough; Whisper models never run on the Apple GPU,
+// which CTranslate2 cannot use.
+func (m *Manager) Acquire(ctx context.Context, req Request) (*Lease, error) {
+	if err := m.Refresh(ctx); err != nil {
+		logger.Warn("Failed to measure GPU memory", "error", err)
+	}
+	for {
+		m.mu.Lock()
+		d, err := m.pick(req)
+		if err != nil {
+			m.mu.Unlock()
+			return nil, err
+		}
+		if d != nil {
+			d.jobs[req.JobID] = req.MemoryMB
+			d.ReservedMB += req.MemoryMB
+			lease := &Lease{Device: d.Device, JobID: req.JobID, manager: m}
+			m.mu.Unlock()
+			logger.Debug("Assigned device", "job_id", req.JobID, "device", d.ID, "memory_mb", req.MemoryMB)
+			return lease, nil
+		}
+		changed := m.changed
+		m.mu.Unlock()
+
+		select {
+		case <-changed:
+		case <-time.After(refreshInterval):
+			if err := m.Refresh(ctx); err != nil {
+				logger.Warn("Failed to measure GPU memory", "error", err)
+			}
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// pick chooses the device for a request: nil without error when the job
+// must wait; the caller holds m.mu
+func (m *Manager) pick(req Request) (*device, error) {
+	var kinds []string
+	switch req.Device {
+	case KindCPU:
+		kinds = []string{KindCPU}
+	case KindCUDA:
+		kinds = []string{KindCUDA}
+	default:
+		kinds = []string{KindCUDA, KindMPS, KindCPU}
+	}
+
+	for _, kind := range kinds {
+		if kind == KindMPS && (req.ModelFamily == "" || req.ModelFamily == "whisper") {
+			continue
+		}
+		var fits bool
+		var best *device
+		for _, d := range m.devices {
+			if d.Kind != kind || (d.TotalMB > 0 && d.TotalMB < req.MemoryMB) {
+				continue
+			}
+			fits = true
+			if d.hasSlot() && d.available() >= req.MemoryMB && (best == nil || d.available() > best.available()) {
+				best = d
+			}
+		}
+		if fits {
+			return best, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: it asks for %s and needs %d MB of GPU memory", ErrNoDevice, req.Device, req.MemoryMB)
+}
+
+// Lease is a device reserved for one job
+type Lease struct {
+	Device  Device
+	JobID   string
+	manager *Manager
+	once    sync.Once
+}
+
+// Release gives the device back; releasing twice is harmless
+func (l *Lease) Release() {
+	l.once.Do(func() {
+		m := l.manager
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		for _, d := range m.devices {
+			if d.ID != l.Device.ID {
+				continue
+			}
+			if reserved, ok := d.jobs[l.JobID]; ok {
+				d.ReservedMB -= reserved
+				delete(d.jobs, l.JobID)
+			}
+		}
+		m.notify()
+	})
+}
+
+// Apply points a job's parameters at the leased device
+func (l *Lease) Apply(params *models.WhisperXParams) {
+	params.Device = l.Device.Kind
+	params.DeviceIndex = l.Device.Index
+}
+
+type leaseKey struct{}
+
+// WithLease attaches the device a job runs on to its context
+func WithLease(ctx context.Context, lease *Lease) context.Context {
+	return context.WithValue(ctx, leaseKey{}, lease)
+}
+
+// FromContext returns the device a job was given, if any
+func FromContext(ctx context.Context) (*Lease, bool) {
+	lease, ok := ctx.Value(leaseKey{}).(*Lease)
+	return lease, ok
+}
+
+// whisperMB is roughly the GPU memory faster-whisper takes for each model
+// at float16, batched transcription included
+var whisperMB = map[string]int{
+	"tiny": 1000, "tiny.en": 1000,
+	"base": 1000, "base.en": 1000,
+	"small": 2000, "small.en": 2000,
+	"medium": 5000, "medium.en": 5000,
+	"distil-small.en": 1500, "distil-medium.en": 3000,
+	"distil-large-v2": 6000, "distil-large-v3": 6000,
+	"large-v3-turbo": 6000, "turbo": 6000,
+}
+
+// familyMB is the GPU memory of the other model families
+var familyMB = map[string]int{
+	"nvidia_parakeet": 4000,
+	"nvidia_canary":   6000,
+	"fake":            0,
+}
+
+// diarizationMB is what pyannote adds when a job is diarized
+const diarizationMB = 1500
+
+// EstimateMB guesses the GPU memory a job needs. Whisper models not listed
+// are taken to be large ones; float32 doubles their size and int8 halves it.
+func EstimateMB(params models.WhisperXParams) int {
+	need, ok := familyMB[params.ModelFamily]
+	if !ok {
+		need, ok = whisperMB[params.Model]
+		if !ok {
+			need = 10000
+		}
+		switch {
+		case params.ComputeType == "float32":
+			need *= 2
+		case strings.HasPrefix(params.ComputeType, "int8"):
+			need /= 2
+		}
+	}
+	if params.Diarize && params.ModelFamily != "fake" {
+		need += diarizationMB
+	}
+	return need
+}
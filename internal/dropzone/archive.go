@@ -0,0 +1,482 @@
+package dropzone
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+
+	"github.com/google/uuid"
+)
+
+// archivePrefix marks directories in the staging area that archives are
+// unpacked into; RecoverStaged removes any a crash left behind
+const archivePrefix = ".dropzone-archive-"
+
+// maxArchiveSize caps how much one archive may unpack to, so a small
+// compressed file cannot fill the disk
+const maxArchiveSize int64 = 16 << 30
+
+// MultiTrackProcessor merges the tracks of a multi-track job
+type MultiTrackProcessor interface {
+	ProcessMultiTrackJob(ctx context.Context, jobID string) error
+}
+
+// SetMultiTrackProcessor sets what merges multi-track jobs unpacked from
+// archives; without one they wait with merge status "none"
+func (s *Service) SetMultiTrackProcessor(p MultiTrackProcessor) {
+	s.multiTrack = p
+}
+
+// isArchiveFile reports whether filename is an archive the dropzone unpacks
+func isArchiveFile(filename string) bool {
+	lower := strings.ToLower(filename)
+	return strings.HasSuffix(lower, ".zip") || strings.HasSuffix(lower, ".tar.gz") || strings.HasSuffix(lower, ".tgz")
+}
+
+// archiveSet is a multi-track project and the track files it references, in
+// project order
+type archiveSet struct {
+	project string
+	tracks  []string
+}
+
+// ingestArchive unpacks an archive and ingests what it holds: each Audacity
+// project together with the tracks it references becomes a multi-track job,
+// and every other audio file its own job. Members that fail validation are
+// quarantined. It returns an error if any member could not be ingested,
+// leaving the archive in place for another attempt; members already handled
+// are recorded against the archive's hash and skipped when it is retried.
+func (s *Service) ingestArchive(archivePath string) error {
+	root := s.settingsFor(archivePath)
+	archiveName := filepath.Base(archivePath)
+	hash, err := s.hashArchive(archivePath)
+	if err != nil {
+		return err
+	}
+	handled, err := handledMembers(hash)
+	if err != nil {
+		return err
+	}
+	dir := filepath.Join(s.stagingDir(), archivePrefix+uuid.New().String())
+	defer s.fs.RemoveAll(dir)
+
+	files, err := s.extractArchive(archivePath, dir)
+	if err != nil {
+		return err
+	}
+	sets, singles, err := s.groupArchiveFiles(files)
+	if err != nil {
+		return err
+	}
+	dzLog.Info("Unpacked archive", "file", archiveName, "multitrack_sets", len(sets), "files", len(singles), "already_ingested", len(handled))
+
+	// memberKey names a member by its path inside the archive
+	memberKey := func(path string) string {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return path
+		}
+		return filepath.ToSlash(rel)
+	}
+
+	failed := 0
+	for _, set := range sets {
+		key := memberKey(set.project)
+		if handled[key] {
+			continue
+		}
+		jobID, err := s.createMultiTrackJob(set, root, archiveName)
+		if err != nil {
+			dzLog.Error("Failed to ingest multi-track set from archive", "file", archiveName, "project", filepath.Base(set.project), "error", err)
+			failed++
+			continue
+		}
+		recordMember(hash, key, jobID)
+	}
+	for _, single := range singles {
+		key := memberKey(single)
+		if handled[key] {
+			continue
+		}
+		member := archiveName + "/" + filepath.Base(single)
+		info, err := s.fs.Stat(single)
+		if err != nil {
+			failed++
+			continue
+		}
+		if err := s.validateFile(single, info); err != nil {
+			var invalid *invalidFileError
+			if errors.As(err, &invalid) {
+				if err := s.quarantine(root, single, member, invalid); err != nil {
+					dzLog.Error("Failed to quarantine archive member", "file", member, "error", err)
+				}
+				recordMember(hash, key, "")
+				continue
+			}
+			dzLog.Warn("Failed to validate archive member", "file", member, "error", err)
+			failed++
+			continue
+		}
+		jobID, err := s.uploadFile(single, filepath.Base(single), root)
+		if err != nil {
+			dzLog.Error("Failed to ingest file from archive", "file", member, "error", err)
+			failed++
+			continue
+		}
+		recordMember(hash, key, jobID)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d items in %s could not be ingested", failed, len(sets)+len(singles), archiveName)
+	}
+
+	// Once the whole archive is in, the same archive dropped again later is a
+	// new ingest rather than a retry
+	if err := database.DB.Where("archive_hash = ?", hash).Delete(&models.IngestedArchiveMember{}).Error; err != nil {
+		dzLog.Warn("Failed to clear ingested archive members", "file", archiveName, "error", err)
+	}
+	return nil
+}
+
+// hashArchive returns the SHA-256 of an archive's content
+func (s *Service) hashArchive(archivePath string) (string, error) {
+	f, err := s.fs.Open(archivePath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	hasher := sha256.New()
+	if _, err := io.Copy(hasher, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hasher.Sum(nil)), nil
+}
+
+// handledMembers returns the members of the archive with the given hash that
+// an earlier attempt already ingested or quarantined
+func handledMembers(hash string) (map[string]bool, error) {
+	var members []models.IngestedArchiveMember
+	if err := database.DB.Where("archive_hash = ?", hash).Find(&members).Error; err != nil {
+		return nil, fmt.Errorf("failed to look up ingested archive members: %v", err)
+	}
+	handled := make(map[string]bool, len(members))
+	for _, member := range members {
+		handled[member.Member] = true
+	}
+	return handled, nil
+}
+
+// recordMember remembers that an archive member was handled, with the job it
+// became if any
+func recordMember(hash, member, jobID string) {
+	entry := models.IngestedArchiveMember{ArchiveHash: hash, Member: member}
+	if jobID != "" {
+		entry.JobID = &jobID
+	}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		dzLog.Error("Failed to record ingested archive member", "member", member, "error", err)
+	}
+}
+
+// extractArchive unpacks a .zip or .tar.gz into dir and returns the paths of
+// the regular files it held. Archives that cannot be read, that write outside
+// dir or that unpack to more than maxArchiveSize are invalid.
+func (s *Service) extractArchive(archivePath, dir string) ([]string, error) {
+	f, err := s.fs.Open(archivePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var files []string
+	var total int64
+	extract := func(name string, r io.Reader) error {
+		dest, err := archiveMemberPath(dir, name)
+		if err != nil || dest == "" {
+			return err
+		}
+		if err := s.fs.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+			return err
+		}
+		out, err := s.fs.Create(dest)
+		if err != nil {
+			return err
+		}
+		n, err := io.Copy(out, io.LimitReader(r, maxArchiveSize-total+1))
+		if closeErr := out.Close(); err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			return &invalidFileError{reason: fmt.Sprintf("failed to unpack %s: %v", name, err)}
+		}
+		total += n
+		if total > maxArchiveSize {
+			return &invalidFileError{reason: fmt.Sprintf("archive unpacks to more than %d bytes", maxArchiveSize)}
+		}
+		files = append(files, dest)
+		return nil
+	}
+
+	if strings.HasSuffix(strings.ToLower(archivePath), ".zip") {
+		info, err := f.Stat()
+		if err != nil {
+			return nil, err
+		}
+		zr, err := zip.NewReader(fsys.ReaderAt(f), info.Size())
+		if err != nil {
+			return nil, &invalidFileError{reason: "unreadable zip archive: " + err.Error()}
+		}
+		for _, member := range zr.File {
+			if !member.Mode().IsRegular() {
+				continue
+			}
+			r, err := member.Open()
+			if err != nil {
+				return nil, &invalidFileError{reason: fmt.Sprintf("unreadable zip member %s: %v", member.Name, err)}
+			}
+			err = extract(member.Name, r)
+			r.Close()
+			if err != nil {
+				return nil, err
+			}
+		}
+		return files, nil
+	}
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, &invalidFileError{reason: "unreadable gzip archive: " + err.Error()}
+	}
+	defer gz.Close()
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			return files, nil
+		}
+		if err != nil {
+			return nil, &invalidFileError{reason: "unreadable tar archive: " + err.Error()}
+		}
+		if header.Typeflag != tar.TypeReg {
+			continue
+		}
+		if err := extract(header.Name, tr); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// archiveMemberPath returns where a member is unpacked to, or "" for members
+// that are skipped, like the resource forks macOS adds to zip files
+func archiveMemberPath(dir, name string) (string, error) {
+	name = strings.ReplaceAll(name, "\\", "/")
+	if strings.HasPrefix(name, "__MACOSX/") || strings.HasPrefix(path.Base(name), "._") {
+		return "", nil
+	}
+	clean := path.Clean(name)
+	if path.IsAbs(clean) || clean == "." || clean == ".." || strings.HasPrefix(clean, "../") {
+		return "", &invalidFileError{reason: "unsafe path in archive: " + name}
+	}
+	return filepath.Join(dir, filepath.FromSlash(clean)), nil
+}
+
+// groupArchiveFiles splits unpacked files into Audacity projects with their
+// tracks and independent audio files. A track is looked for next to its
+// project first, then anywhere in the archive; projects holding their own
+// audio have it extracted next to them. Other files are ignored,
+// except that sidecars still apply to the audio they sit next to.
+func (s *Service) groupArchiveFiles(files []string) ([]archiveSet, []string, error) {
+	byName := make(map[string][]string)
+	var projects []string
+	for _, file := range files {
+		name := filepath.Base(file)
+		switch {
+		case isProjectFile(name):
+			projects = append(projects, file)
+		case s.isAudioFile(name):
+			byName[name] = append(byName[name], file)
+		}
+	}
+
+	used := make(map[string]bool)
+	var sets []archiveSet
+	for _, project := range projects {
+		if audio.EmbedsAudio(project) {
+			tracks, err := s.extractProject(project)
+			if err != nil {
+				return nil, nil, err
+			}
+			sets = append(sets, archiveSet{project: project, tracks: tracks})
+			continue
+		}
+		data, err := fsys.ReadFile(s.fs, project)
+		if err != nil {
+			return nil, nil, err
+		}
+		tracks, err := parseProject(project, data)
+		if err != nil {
+			return nil, nil, &invalidFileError{reason: fmt.Sprintf("invalid project %s: %v", filepath.Base(project), err)}
+		}
+		set := archiveSet{project: project}
+		seen := make(map[string]bool)
+		for _, track := range tracks {
+			name := trackFileName(track)
+			file := pickTrack(byName[name], filepath.Dir(project))
+			if file == "" {
+				return nil, nil, &invalidFileError{reason: fmt.Sprintf("project %s references %s, which is not in the archive", filepath.Base(project), name)}
+			}
+			if !seen[file] {
+				seen[file] = true
+				set.tracks = append(set.tracks, file)
+			}
+			used[file] = true
+		}
+		if len(set.tracks) == 0 {
+			return nil, nil, &invalidFileError{reason: fmt.Sprintf("project %s has no tracks", filepath.Base(project))}
+		}
+		sets = append(sets, set)
+	}
+
+	var singles []string
+	for _, file := range files {
+		if s.isAudioFile(filepath.Base(file)) && !used[file] {
+			singles = append(singles, file)
+		}
+	}
+	return sets, singles, nil
+}
+
+// pickTrack prefers the candidate in dir, else the first one
+func pickTrack(candidates []string, dir string) string {
+	for _, candidate := range candidates {
+		if filepath.Dir(candidate) == dir {
+			return candidate
+		}
+	}
+	if len(candidates) > 0 {
+		return candidates[0]
+	}
+	return ""
+}
+
+// createMultiTrackJob lays out a project and its tracks like a multi-track
+// upload, creates the job and starts merging it, returning the job's ID.
+// Multi-track jobs are never queued for transcription straight away.
+func (s *Service) createMultiTrackJob(set archiveSet, root Root, archiveName string) (string, error) {
+	user := s.rootUser(root)
+	if user != nil {
+		if err := s.checkQuota(user, append([]string{set.project}, set.tracks...)...); err != nil {
+			return "", err
+		}
+	}
+
+	jobID := uuid.New().String()
+	folder := filepath.Join(s.config.UploadDir, jobID)
+	tracksFolder := filepath.Join(folder, "tracks")
+	if err := s.fs.MkdirAll(tracksFolder, 0755); err != nil {
+		return "", fmt.Errorf("failed to create job directory: %v", err)
+	}
+
+	// The unpacked files are in the staging area, on the same filesystem as
+	// the uploads, so they are moved rather than copied
+	aupPath := filepath.Join(folder, "project"+strings.ToLower(filepath.Ext(set.project)))
+	if err := s.fs.Rename(set.project, aupPath); err != nil {
+		s.fs.RemoveAll(folder)
+		return "", fmt.Errorf("failed to move project file: %v", err)
+	}
+	var trackFiles []models.MultiTrackFile
+	for i, track := range set.tracks {
+		name := filepath.Base(track)
+		trackPath := filepath.Join(tracksFolder, name)
+		if err := s.fs.Rename(track, trackPath); err != nil {
+			s.fs.RemoveAll(folder)
+			return "", fmt.Errorf("failed to move track %s: %v", name, err)
+		}
+		trackFiles = append(trackFiles, models.MultiTrackFile{
+			TranscriptionJobID: jobID,
+			FileName:           strings.TrimSuffix(name, filepath.Ext(name)),
+			FilePath:           trackPath,
+			TrackIndex:         i,
+		})
+	}
+
+	title := strings.TrimSuffix(filepath.Base(set.project), filepath.Ext(set.project))
+	job := models.TranscriptionJob{
+		ID:               jobID,
+		Title:            &title,
+		AudioPath:        trackFiles[0].FilePath,
+		Status:           models.StatusUploaded,
+		IsMultiTrack:     true,
+		AupFilePath:      &aupPath,
+		MultiTrackFolder: &folder,
+		MergeStatus:      "none",
+		MultiTrackFiles:  trackFiles,
+	}
+	if user != nil {
+		job.UserID = &user.ID
+		job.SourceAudioAction = user.SourceAudioAction
+	}
+	if root.Folder != nil && root.Folder.SourceAudioAction != nil {
+		action := *root.Folder.SourceAudioAction
+		job.SourceAudioAction = &action
+	}
+
+	// Embedded tags give a better title than the project's filename
+	if _, err := audio.IngestMetadata(s.fs, &job, filepath.Base(set.project), true); err != nil {
+		dzLog.Warn("Failed to read audio metadata", "file", archiveName, "error", err)
+	}
+	if s.config.StripAudioMetadata {
+		for _, track := range trackFiles {
+			if err := audio.StripMetadata(context.Background(), "ffmpeg", track.FilePath); err != nil {
+				dzLog.Warn("Failed to strip audio metadata", "file", archiveName, "track", track.FileName, "error", err)
+			}
+		}
+	}
+
+	// The tracks are inserted with the job, in one transaction
+	if err := database.DB.Create(&job).Error; err != nil {
+		s.fs.RemoveAll(folder)
+		return "", fmt.Errorf("failed to create job record: %v", err)
+	}
+	jobstate.Created(context.Background(), job.ID, job.Status, "source", "dropzone", "file", archiveName, "root", root.Path, "tracks", len(trackFiles))
+	dzLog.Info("Created multi-track job", "file", archiveName, "job_id", jobID, "tracks", len(trackFiles))
+
+	if s.multiTrack != nil {
+		go func() {
+			if err := s.multiTrack.ProcessMultiTrackJob(context.Background(), jobID); err != nil {
+				dzLog.Error("Multi-track merge failed", "job_id", jobID, "error", err)
+			}
+		}()
+	}
+	return jobID, nil
+}
+
+// removeArchiveDirs deletes unpacked archives a crash left in the staging area
+func (s *Service) removeArchiveDirs(entries []os.FileInfo) {
+	for _, entry := range entries {
+		if !entry.IsDir() || !strings.HasPrefix(entry.Name(), archivePrefix) {
+			continue
+		}
+		path := filepath.Join(s.stagingDir(), entry.Name())
+		if err := s.fs.RemoveAll(path); err != nil {
+			dzLog.Warn("Failed to remove abandoned archive directory", "path", path, "error", err)
+			continue
+		}
+		dzLog.Info("Removed abandoned archive directory", "path", path)
+	}
+}
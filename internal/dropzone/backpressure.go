@@ -0,0 +1,54 @@
+package dropzone
+
+import (
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+)
+
+// backpressurePoll is how often a paused ingest checks the queue again
+const backpressurePoll = 5 * time.Second
+
+// throttle blocks until the file may be ingested: fewer than
+// DROPZONE_MAX_IN_FLIGHT files are being ingested and fewer than
+// DROPZONE_QUEUE_HIGH_WATER jobs wait to be transcribed. Files keep waiting
+// where they are, so a flood of them neither piles up goroutines copying
+// files nor floods the queue. The caller runs the returned function once done.
+func (s *Service) throttle() func() {
+	if s.slots != nil {
+		s.slots <- struct{}{}
+	}
+	s.waitForQueue()
+	return func() {
+		if s.slots != nil {
+			<-s.slots
+		}
+	}
+}
+
+// waitForQueue blocks while the transcription backlog is at the high water
+// mark, logging once when ingest pauses and once when it resumes
+func (s *Service) waitForQueue() {
+	highWater := int64(s.config.DropzoneQueueHighWater)
+	if highWater <= 0 {
+		return
+	}
+	for {
+		var pending int64
+		if err := database.DB.Model(&models.TranscriptionJob{}).Where("status = ?", models.StatusPending).Count(&pending).Error; err != nil {
+			dzLog.Warn("Failed to check transcription backlog", "error", err)
+			return
+		}
+		if pending < highWater {
+			if s.paused.CompareAndSwap(true, false) {
+				dzLog.Info("Resuming dropzone ingest", "pending_jobs", pending)
+			}
+			return
+		}
+		if s.paused.CompareAndSwap(false, true) {
+			dzLog.Warn("Pausing dropzone ingest until the transcription backlog drains", "pending_jobs", pending, "high_water", highWater)
+		}
+		s.clock.Sleep(backpressurePoll)
+	}
+}
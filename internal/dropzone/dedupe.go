@@ -0,0 +1,71 @@
+package dropzone
+
+import (
+	"context"
+	"fmt"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// What to do with a dropzone file whose content matches an existing job
+const (
+	DedupeOff  = "off"
+	DedupeSkip = "skip" // Discard the file
+	DedupeLink = "link" // Ingest it as a duplicate of the existing job, without transcribing it again
+)
+
+// EventDuplicateSkipped is recorded on the existing job when a copy of its
+// audio is dropped again and discarded
+const EventDuplicateSkipped = "dropzone_duplicate_skipped"
+
+// dedupeMode returns the configured mode, or an error if it is not one of
+// off, skip or link
+func (s *Service) dedupeMode() (string, error) {
+	switch s.config.DropzoneDedupe {
+	case "", DedupeOff:
+		return DedupeOff, nil
+	case DedupeSkip, DedupeLink:
+		return s.config.DropzoneDedupe, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q, expected off, skip or link", s.config.DropzoneDedupe)
+	}
+}
+
+// findDuplicate returns the oldest job holding audio with hash, or nil if
+// there is none. Failed jobs do not count, so dropping a file again retries it.
+func findDuplicate(hash string) (*models.TranscriptionJob, error) {
+	var job models.TranscriptionJob
+	err := database.DB.Where("audio_hash = ? AND status <> ?", hash, models.StatusFailed).
+		Order("created_at ASC").First(&job).Error
+	if err == gorm.ErrRecordNotFound {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// linkDuplicate marks job as a copy of original. A finished, unencrypted
+// transcript is reused; otherwise the job waits as uploaded, so it is only
+// transcribed if someone asks for it.
+func linkDuplicate(job, original *models.TranscriptionJob) {
+	job.DuplicateOf = &original.ID
+	job.Status = models.StatusUploaded
+	if original.Status == models.StatusCompleted && !original.Encrypted && original.Transcript != nil {
+		transcript := *original.Transcript
+		job.Transcript = &transcript
+		job.Status = models.StatusCompleted
+	}
+}
+
+// skipDuplicate records that a copy of original's audio was discarded
+func skipDuplicate(original *models.TranscriptionJob, filename, rootPath string) {
+	ctx := logger.WithJobID(context.Background(), original.ID)
+	logger.JobEvent(ctx, original.ID, EventDuplicateSkipped, "file", filename, "root", rootPath)
+	dzLog.Info("Skipping duplicate of existing job", "file", filename, "job_id", original.ID)
+}
@@ -1,22 +1,38 @@
 package dropzone
 
 import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
-	"log"
 	"os"
 	"path/filepath"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
+	"synthezia/internal/audio"
 	"synthezia/internal/config"
 	"synthezia/internal/database"
+	"synthezia/internal/faults"
+	"synthezia/internal/jobstate"
 	"synthezia/internal/models"
+	"synthezia/internal/quota"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
 
 	"github.com/fsnotify/fsnotify"
 	"github.com/google/uuid"
+	"gorm.io/gorm"
 )
 
+// dzLog tags dropzone output so LOG_LEVEL_DROPZONE can tune it separately
+var dzLog = logger.Module(logger.ModuleDropzone)
+
 // TaskQueue interface for enqueueing transcription jobs
 type TaskQueue interface {
 	EnqueueJob(jobID string) error
@@ -24,31 +40,122 @@ type TaskQueue interface {
 
 // Service manages the dropzone file monitoring
 type Service struct {
-	config       *config.Config
-	watcher      *fsnotify.Watcher
-	dropzonePath string
-	taskQueue    TaskQueue
+	config    *config.Config
+	watcher   *fsnotify.Watcher
+	roots     []Root
+	rootsErr  error
+	ignore    []string
+	ignoreErr error
+	profiles  map[string]*FolderProfile
+	taskQueue TaskQueue
+	clock     clock.Clock
+	fs        fsys.FS
+	quotas    *quota.Service
+
+	ffprobePath string
+	ffmpegPath  string
+	multiTrack  MultiTrackProcessor
+
+	// Files waiting to settle, so repeated events start one wait
+	settling   map[string]bool
+	settlingMu sync.Mutex
+
+	// Held from the duplicate check to the job insert, so copies dropped
+	// together are still caught
+	dedupeMu sync.Mutex
+
+	// One token per file being ingested, bounding how many run at once; nil
+	// means no limit. paused is set while the transcription backlog is full.
+	slots  chan struct{}
+	paused atomic.Bool
+
+	// Recently detected files and how far their ingest got
+	status statusLog
 }
 
-// NewService creates a new dropzone service
+// NewService creates a new dropzone service watching the roots in
+// DROPZONE_PATHS; Start reports an invalid setting
 func NewService(cfg *config.Config, taskQueue TaskQueue) *Service {
+	roots, err := ParseRoots(cfg.DropzonePaths)
+	ignore, ignoreErr := parseIgnore(cfg.DropzoneIgnore)
+	var slots chan struct{}
+	if cfg.DropzoneMaxInFlight > 0 {
+		slots = make(chan struct{}, cfg.DropzoneMaxInFlight)
+	}
+	quotas := quota.NewService(nil)
+	quotas.SetLimit(int64(cfg.StorageQuotaMB) << 20)
 	return &Service{
-		config:       cfg,
-		taskQueue:    taskQueue,
-		dropzonePath: filepath.Join("data", "dropzone"),
+		config:    cfg,
+		taskQueue: taskQueue,
+		roots:     roots,
+		rootsErr:  err,
+		ignore:    ignore,
+		ignoreErr: ignoreErr,
+		clock:     clock.Real,
+		fs:        fsys.OS,
+		quotas:    quotas,
+		settling:  make(map[string]bool),
+		slots:     slots,
+
+		ffprobePath: "ffprobe",
+		ffmpegPath:  "ffmpeg",
 	}
 }
 
+// Roots returns the watched directories
+func (s *Service) Roots() []Root {
+	return s.roots
+}
+
+// SetFFprobePath overrides the ffprobe binary used to validate files, mainly for tests
+func (s *Service) SetFFprobePath(path string) {
+	s.ffprobePath = path
+}
+
+// SetFFmpegPath overrides the ffmpeg binary used to test-decode files, mainly for tests
+func (s *Service) SetFFmpegPath(path string) {
+	s.ffmpegPath = path
+}
+
+// SetClock overrides the time source, mainly for tests
+func (s *Service) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetFS overrides the filesystem used for the dropzone and uploads, mainly for tests
+func (s *Service) SetFS(fs fsys.FS) {
+	s.fs = fs
+	s.quotas.SetFS(fs)
+}
+
 // Start initializes the dropzone directory and starts file monitoring
 func (s *Service) Start() error {
-	log.Printf("Starting dropzone service...")
-
-	// Create dropzone directory if it doesn't exist
-	if err := os.MkdirAll(s.dropzonePath, 0755); err != nil {
-		return fmt.Errorf("failed to create dropzone directory: %v", err)
+	dzLog.Info("Starting dropzone service")
+	if s.rootsErr != nil {
+		return fmt.Errorf("invalid DROPZONE_PATHS: %v", s.rootsErr)
+	}
+	if s.ignoreErr != nil {
+		return fmt.Errorf("invalid DROPZONE_IGNORE: %v", s.ignoreErr)
 	}
+	if _, err := s.dedupeMode(); err != nil {
+		return fmt.Errorf("invalid DROPZONE_DEDUPE: %v", err)
+	}
+	if _, err := s.retentionMode(); err != nil {
+		return fmt.Errorf("invalid DROPZONE_RETENTION: %v", err)
+	}
+	profiles, err := s.loadFolderProfiles()
+	if err != nil {
+		return fmt.Errorf("invalid DROPZONE_PROFILES: %v", err)
+	}
+	s.profiles = profiles
 
-	log.Printf("Dropzone directory created/verified at: %s", s.dropzonePath)
+	// Create dropzone directories if they don't exist
+	for _, root := range s.roots {
+		if err := s.fs.MkdirAll(root.Path, 0755); err != nil {
+			return fmt.Errorf("failed to create dropzone directory %s: %v", root.Path, err)
+		}
+		dzLog.Debug("Dropzone directory created/verified", "path", root.Path)
+	}
 
 	// Initialize file watcher
 	watcher, err := fsnotify.NewWatcher()
@@ -57,28 +164,37 @@ func (s *Service) Start() error {
 	}
 	s.watcher = watcher
 
-	// Add dropzone directory and all subdirectories to watcher recursively
-	if err := s.addDirectoryRecursively(s.dropzonePath); err != nil {
-		s.watcher.Close()
-		return fmt.Errorf("failed to add directories to watcher: %v", err)
+	// Add each root and all its subdirectories to watcher recursively
+	for _, root := range s.roots {
+		if err := s.addDirectoryRecursively(root.Path); err != nil {
+			s.watcher.Close()
+			return fmt.Errorf("failed to add directories to watcher: %v", err)
+		}
+	}
+
+	// Finish or undo ingests interrupted by a crash before taking new files
+	if err := s.RecoverStaged(); err != nil {
+		dzLog.Warn("Failed to recover staged ingests", "error", err)
 	}
 
 	// Process existing files recursively on startup
 	if err := s.processExistingFiles(); err != nil {
-		log.Printf("Warning: failed to process some existing files: %v", err)
+		dzLog.Warn("Failed to process some existing files", "error", err)
 	}
 
 	// Start monitoring in a goroutine
 	go s.watchFiles()
 
-	log.Printf("Dropzone service started, monitoring recursively: %s", s.dropzonePath)
+	for _, root := range s.roots {
+		dzLog.Info("Dropzone service started, monitoring recursively", "path", root.Path, "user", root.User, "language", root.Language)
+	}
 	return nil
 }
 
 // Stop stops the dropzone service
 func (s *Service) Stop() error {
 	if s.watcher != nil {
-		log.Printf("Stopping dropzone service...")
+		dzLog.Info("Stopping dropzone service")
 		return s.watcher.Close()
 	}
 	return nil
@@ -86,38 +202,56 @@ func (s *Service) Stop() error {
 
 // addDirectoryRecursively adds a directory and all its subdirectories to the watcher
 func (s *Service) addDirectoryRecursively(root string) error {
-	return filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+	return fsys.Walk(s.fs, root, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Printf("Warning: error accessing path %s: %v", path, err)
+			dzLog.Warn("Error accessing path", "path", path, "error", err)
 			return nil // Continue walking despite errors
 		}
 
-		// Only add directories to the watcher
+		// Only add directories to the watcher, leaving quarantined,
+		// processed, ignored and too deep files alone
 		if info.IsDir() {
+			if s.isQuarantined(path) || s.isProcessed(path) || s.skipDir(path) {
+				return filepath.SkipDir
+			}
 			if err := s.watcher.Add(path); err != nil {
-				log.Printf("Warning: failed to watch directory %s: %v", path, err)
+				dzLog.Warn("Failed to watch directory", "path", path, "error", err)
 				return nil // Continue despite individual directory failures
 			}
-			log.Printf("Added directory to watcher: %s", path)
+			dzLog.Debug("Added directory to watcher", "path", path)
 		}
 
 		return nil
 	})
 }
 
-// processExistingFiles processes all existing audio files in the dropzone on startup
+// processExistingFiles processes all existing audio files in the dropzone roots on startup
 func (s *Service) processExistingFiles() error {
-	return filepath.Walk(s.dropzonePath, func(path string, info os.FileInfo, err error) error {
+	var firstErr error
+	for _, root := range s.roots {
+		if err := s.processExistingFilesIn(root.Path); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+// processExistingFilesIn processes the audio files already under one root
+func (s *Service) processExistingFilesIn(rootPath string) error {
+	return fsys.Walk(s.fs, rootPath, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
-			log.Printf("Warning: error accessing path %s: %v", path, err)
+			dzLog.Warn("Error accessing path", "path", path, "error", err)
 			return nil // Continue walking despite errors
 		}
 
 		// Only process files, not directories
+		if info.IsDir() && (s.isQuarantined(path) || s.isProcessed(path) || s.skipDir(path)) {
+			return filepath.SkipDir
+		}
 		if !info.IsDir() {
 			filename := filepath.Base(path)
-			if s.isAudioFile(filename) {
-				log.Printf("Processing existing audio file: %s", path)
+			if s.isAudioFile(filename) || isArchiveFile(filename) || isProjectFile(filename) {
+				dzLog.Info("Processing existing audio file", "path", path)
 				s.processFile(path)
 			}
 		}
@@ -126,6 +260,13 @@ func (s *Service) processExistingFiles() error {
 	})
 }
 
+// Sweep ingests the audio already in the roots, picking up files the watcher
+// missed, such as those synced onto a share that does not report changes
+func (s *Service) Sweep() error {
+	dzLog.Info("Sweeping dropzone roots")
+	return s.processExistingFiles()
+}
+
 // watchFiles monitors the dropzone directory for new files
 func (s *Service) watchFiles() {
 	for {
@@ -138,15 +279,23 @@ func (s *Service) watchFiles() {
 			// Handle creation events for both files and directories
 			if event.Op&fsnotify.Create == fsnotify.Create {
 				// Check if the created item is a directory
-				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
-					log.Printf("Detected new directory in dropzone: %s", event.Name)
+				if info, err := s.fs.Stat(event.Name); err == nil && info.IsDir() {
+					dzLog.Debug("Detected new directory in dropzone", "path", event.Name)
 					// Add the new directory to the watcher recursively
 					if err := s.addDirectoryRecursively(event.Name); err != nil {
-						log.Printf("Failed to watch new directory %s: %v", event.Name, err)
+						dzLog.Error("Failed to watch new directory", "path", event.Name, "error", err)
 					}
+					// Files moved in with the directory, or written before it
+					// was watched, raise no events of their own
+					go func(dir string) {
+						if err := s.processExistingFilesIn(dir); err != nil {
+							dzLog.Warn("Failed to process some files in new directory", "path", dir, "error", err)
+						}
+					}(event.Name)
 				} else {
-					log.Printf("Detected new file in dropzone: %s", event.Name)
-					s.processFile(event.Name)
+					dzLog.Debug("Detected new file in dropzone", "path", event.Name)
+					// Waiting for a slow copy must not hold up other files
+					go s.processFile(event.Name)
 				}
 			}
 
@@ -154,7 +303,7 @@ func (s *Service) watchFiles() {
 			if !ok {
 				return
 			}
-			log.Printf("Dropzone watcher error: %v", err)
+			dzLog.Error("Dropzone watcher error", "error", err)
 		}
 	}
 }
@@ -177,51 +326,263 @@ func (s *Service) isAudioFile(filename string) bool {
 
 // processFile handles a newly detected file in the dropzone
 func (s *Service) processFile(filePath string) {
-	// Small delay to ensure file is fully written
-	time.Sleep(500 * time.Millisecond)
-
 	filename := filepath.Base(filePath)
+	if s.isQuarantined(filePath) || s.isProcessed(filePath) {
+		return
+	}
+	if s.isIgnored(filePath) || s.tooDeep(filePath) {
+		dzLog.Debug("Skipping ignored file", "file", filename)
+		return
+	}
+	if s.hasMarker(filePath) {
+		dzLog.Debug("Skipping file kept after ingest", "file", filename)
+		return
+	}
 
-	// Check if it's an audio file
-	if !s.isAudioFile(filename) {
-		log.Printf("Skipping non-audio file: %s", filename)
+	// Check if it's an audio file, an archive of them or a multi-track project
+	project := isProjectFile(filename)
+	if !s.isAudioFile(filename) && !isArchiveFile(filename) && !project {
+		// A sidecar dropped after its audio, or fixed after failing to
+		// parse, ingests the audio waiting next to it
+		if isSidecarFile(filename) {
+			if audioPath := s.audioForSidecar(filePath); audioPath != "" {
+				dzLog.Debug("Detected sidecar for waiting audio file", "sidecar", filename, "path", audioPath)
+				s.processFile(audioPath)
+				return
+			}
+		}
+		dzLog.Debug("Skipping non-audio file", "file", filename)
 		return
 	}
 
-	// Check if file exists and is accessible
-	fileInfo, err := os.Stat(filePath)
+	s.settlingMu.Lock()
+	if s.settling[filePath] {
+		s.settlingMu.Unlock()
+		return
+	}
+	s.settling[filePath] = true
+	s.settlingMu.Unlock()
+	defer func() {
+		s.settlingMu.Lock()
+		delete(s.settling, filePath)
+		s.settlingMu.Unlock()
+	}()
+
+	// Wait until the file is fully written. A stray event for a file already
+	// ingested, e.g. as the track of a project, is not shown as waiting.
+	if fsys.Exists(s.fs, filePath) {
+		s.setState(filePath, StateWaiting, "settling")
+	}
+	fileInfo, err := s.waitForSettle(filePath)
+	if errors.Is(err, os.ErrNotExist) {
+		// Already ingested, e.g. as the track of a project
+		dzLog.Debug("File removed before it settled", "path", filePath)
+		s.setRemoved(filePath)
+		return
+	}
 	if err != nil {
-		log.Printf("Error accessing file %s: %v", filePath, err)
+		dzLog.Error("Error accessing file", "path", filePath, "error", err)
+		s.setFailed(filePath, err)
 		return
 	}
 
 	// Skip if it's a directory
 	if fileInfo.IsDir() {
+		s.forgetStatus(filePath)
 		return
 	}
 
-	log.Printf("Processing audio file: %s", filename)
+	// Tracks of a multi-track project are ingested with the project
+	if !project && s.isAudioFile(filename) {
+		if projectPath := s.projectFor(filePath); projectPath != "" {
+			dzLog.Debug("Detected track of project", "file", filename, "project", filepath.Base(projectPath))
+			s.setState(filePath, StateWaiting, "track of project "+filepath.Base(projectPath))
+			s.processFile(projectPath)
+			return
+		}
+	}
 
-	// Upload the file using the same logic as the API handler
-	if err := s.uploadFile(filePath, filename); err != nil {
-		log.Printf("Failed to upload file %s: %v", filename, err)
-		return
+	s.setState(filePath, StateWaiting, "waiting for an ingest slot")
+	release := s.throttle()
+	defer release()
+	s.setState(filePath, StateUploading, "")
+
+	// Files that can never be transcribed are moved aside rather than
+	// retried on every restart
+	archive := isArchiveFile(filename)
+	if !archive && !project {
+		if err := s.validateFile(filePath, fileInfo); err != nil {
+			s.reject(filePath, err)
+			return
+		}
+	}
+
+	switch {
+	case archive:
+		dzLog.Info("Processing archive", "file", filename)
+		if err := s.ingestArchive(filePath); err != nil {
+			s.reject(filePath, err)
+			return
+		}
+		s.setState(filePath, StateJobCreated, "unpacked")
+	case project:
+		dzLog.Info("Processing multi-track project", "file", filename)
+		jobID, err := s.ingestProject(filePath)
+		if err != nil {
+			// A track dropped later picks the project up again
+			var missing *missingTracksError
+			if errors.As(err, &missing) {
+				dzLog.Info("Waiting for project tracks", "file", filename, "missing", strings.Join(missing.names, ", "))
+				s.setState(filePath, StateWaiting, missing.Error())
+				return
+			}
+			s.reject(filePath, err)
+			return
+		}
+		s.setJob(filePath, jobID)
+	default:
+		dzLog.Info("Processing audio file", "file", filename)
+
+		// Upload the file using the same logic as the API handler
+		jobID, err := s.uploadFile(filePath, filename, s.settingsFor(filePath))
+		if err != nil {
+			s.reject(filePath, err)
+			return
+		}
+		s.setJob(filePath, jobID)
 	}
 
-	// Delete the original file from dropzone after successful upload
-	if err := os.Remove(filePath); err != nil {
-		log.Printf("Warning: Failed to delete file from dropzone %s: %v", filePath, err)
+	// Delete, archive or mark the original file and its sidecar after
+	// successful upload
+	root := s.rootFor(filePath)
+	sidecarPath := s.findSidecar(filePath)
+	if err := s.retain(root, filePath); err != nil {
+		dzLog.Warn("Failed to apply retention to file in dropzone", "path", filePath, "error", err)
 	} else {
-		log.Printf("Successfully processed and removed file: %s", filename)
+		dzLog.Info("Successfully processed file", "file", filename)
+	}
+	if sidecarPath != "" && !s.hasMarker(filePath) {
+		if err := s.retain(root, sidecarPath); err != nil {
+			dzLog.Warn("Failed to apply retention to sidecar in dropzone", "path", sidecarPath, "error", err)
+		}
 	}
 }
 
-// uploadFile uploads the file using the existing pipeline logic
-func (s *Service) uploadFile(sourcePath, originalFilename string) error {
-	// Create upload directory
+// reject quarantines a file that is invalid, when quarantine is on, and
+// otherwise leaves it in place to be retried
+func (s *Service) reject(filePath string, err error) {
+	filename := filepath.Base(filePath)
+	s.setFailed(filePath, err)
+	var invalid *invalidFileError
+	if !errors.As(err, &invalid) || !s.config.DropzoneQuarantine {
+		dzLog.Error("Failed to ingest file, leaving it in place", "file", filename, "error", err)
+		return
+	}
+	if err := s.quarantine(s.rootFor(filePath), filePath, filePath, invalid); err != nil {
+		dzLog.Error("Failed to quarantine invalid file", "file", filename, "error", err)
+	}
+}
+
+// settlePoll is how often a file is checked while it settles
+const settlePoll = 500 * time.Millisecond
+
+// waitForSettle blocks until filePath has kept the same size and modification
+// time for the configured settle period, and is not locked by a writer when
+// lock probing is on. Files still being copied, e.g. over a slow network
+// share, are therefore not ingested half-written.
+func (s *Service) waitForSettle(filePath string) (os.FileInfo, error) {
+	settle := time.Duration(s.config.DropzoneSettleSeconds) * time.Second
+	last, err := s.fs.Stat(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var stable time.Duration
+	for {
+		s.clock.Sleep(settlePoll)
+		info, err := s.fs.Stat(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if info.Size() != last.Size() || !info.ModTime().Equal(last.ModTime()) {
+			dzLog.Debug("Waiting for file to settle", "path", filePath, "size", info.Size())
+			last, stable = info, 0
+			continue
+		}
+		stable += settlePoll
+		if stable < settle {
+			continue
+		}
+		if s.locked(filePath) {
+			dzLog.Debug("Waiting for writer to release file", "path", filePath)
+			continue
+		}
+		return info, nil
+	}
+}
+
+// locked reports whether lock probing is on and another process holds filePath
+func (s *Service) locked(filePath string) bool {
+	if !s.config.DropzoneLockProbe || s.fs != fsys.OS {
+		return false
+	}
+	locked, err := fsys.Locked(filePath)
+	if err != nil {
+		dzLog.Debug("Lock probe failed", "path", filePath, "error", err)
+		return false
+	}
+	return locked
+}
+
+// stagedPrefix marks files copied out of the dropzone whose job may not be
+// committed yet; the job ID follows the prefix
+const stagedPrefix = ".dropzone-"
+
+// stagingDir holds ingests in progress. It is TEMP_DIR, on the same
+// filesystem as the upload directory, so moving a file into place is a rename.
+func (s *Service) stagingDir() string {
+	if s.config.TempDir != "" {
+		return s.config.TempDir
+	}
+	return s.config.UploadDir
+}
+
+// uploadFile ingests a dropzone file and the sidecar next to it, returning
+// the job's ID as ingest does
+func (s *Service) uploadFile(sourcePath, originalFilename string, root Root) (string, error) {
+	// A sidecar that cannot be read fails the ingest, leaving the audio for
+	// a corrected sidecar to pick up, rather than using the wrong settings
+	sidecar, sidecarPath, err := s.loadSidecar(sourcePath)
+	if err != nil {
+		return "", err
+	}
+
+	sourceFile, err := s.fs.Open(sourcePath)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy file: %v", err)
+	}
+	defer sourceFile.Close()
+
+	eventArgs := []any{"source", "dropzone", "file", originalFilename, "root", root.Path}
+	if sidecarPath != "" {
+		eventArgs = append(eventArgs, "sidecar", filepath.Base(sidecarPath))
+	}
+	return s.ingest(sourceFile, originalFilename, root, sidecar, eventArgs...)
+}
+
+// ingest creates a job from audio read from r as one unit: the audio is
+// staged, the job row is committed, then the staged file is renamed into the
+// upload directory. A failure undoes the earlier steps, and RecoverStaged
+// finishes or discards ingests interrupted by a crash, so no job points at
+// missing audio and no upload lacks a job. It returns the new job's ID, or ""
+// when the audio was discarded as a duplicate.
+func (s *Service) ingest(r io.Reader, originalFilename string, root Root, sidecar *Sidecar, eventArgs ...any) (string, error) {
+	// Create upload and staging directories
 	uploadDir := s.config.UploadDir
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return fmt.Errorf("failed to create upload directory: %v", err)
+	if err := s.fs.MkdirAll(uploadDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %v", err)
+	}
+	if err := s.fs.MkdirAll(s.stagingDir(), 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %v", err)
 	}
 
 	// Generate unique filename
@@ -229,52 +590,236 @@ func (s *Service) uploadFile(sourcePath, originalFilename string) error {
 	ext := filepath.Ext(originalFilename)
 	filename := fmt.Sprintf("%s%s", jobID, ext)
 	destPath := filepath.Join(uploadDir, filename)
+	stagedPath := filepath.Join(s.stagingDir(), stagedPrefix+filename)
 
-	// Copy file from dropzone to upload directory
-	if err := s.copyFile(sourcePath, destPath); err != nil {
-		return fmt.Errorf("failed to copy file: %v", err)
+	// Copy the audio to the staging area, hashing it on the way
+	hash, err := s.stageFile(r, stagedPath)
+	if err != nil {
+		return "", fmt.Errorf("failed to copy file: %v", err)
 	}
 
 	// Create job record with "uploaded" status
 	job := models.TranscriptionJob{
 		ID:        jobID,
-		AudioPath: destPath,
+		AudioPath: stagedPath,
 		Status:    models.StatusUploaded,
 		Title:     &originalFilename, // Use original filename as title
+		AudioHash: &hash,
+	}
+	if err := s.probeFile(stagedPath, originalFilename, &job); err != nil {
+		s.fs.Remove(stagedPath)
+		return "", err
 	}
 
-	// Save to database
-	if err := database.DB.Create(&job).Error; err != nil {
-		os.Remove(destPath) // Clean up file on database error
-		return fmt.Errorf("failed to create job record: %v", err)
+	// Apply the defaults of the root the file was dropped into
+	user := s.rootUser(root)
+	if user != nil {
+		// The file is left in the dropzone, and ingested once space is freed
+		if err := s.checkQuota(user, stagedPath); err != nil {
+			s.fs.Remove(stagedPath)
+			return "", err
+		}
+		job.UserID = &user.ID
+		var profile models.TranscriptionProfile
+		if user.DefaultProfileID != nil && database.DB.Where("id = ?", *user.DefaultProfileID).First(&profile).Error == nil {
+			job.Parameters = profile.Parameters
+			job.Diarization = profile.Parameters.Diarize
+		}
+		job.SourceAudioAction = user.SourceAudioAction
+	}
+	if root.Language != "" {
+		language := root.Language
+		job.Parameters.Language = &language
+	}
+
+	// Then those of the folder it was dropped into
+	if root.Folder != nil {
+		if err := root.Folder.Apply(&job); err != nil {
+			s.fs.Remove(stagedPath)
+			return "", err
+		}
+	}
+
+	// Embedded tags give a better title than the filename
+	if _, err := audio.IngestMetadata(s.fs, &job, originalFilename, true); err != nil {
+		dzLog.Warn("Failed to read audio metadata", "file", originalFilename, "error", err)
+	}
+
+	// The sidecar dropped with the file has the final say
+	if sidecar != nil {
+		sidecar.Apply(&job)
+		dzLog.Debug("Applied sidecar metadata", "file", originalFilename)
+	}
+	if s.config.StripAudioMetadata {
+		if err := audio.StripMetadata(context.Background(), "ffmpeg", stagedPath); err != nil {
+			dzLog.Warn("Failed to strip audio metadata", "file", originalFilename, "error", err)
+		}
 	}
+	job.AudioPath = destPath
 
-	// Check if auto-transcription is enabled
-	if s.isAutoTranscriptionEnabled() {
-		// Multi-track files should never be auto-transcribed
+	// Queue the job in the same insert when auto-transcription is on. Multi-track
+	// files should never be auto-transcribed.
+	autoTranscribe := false
+	if s.autoTranscribe(root, user) {
 		if job.IsMultiTrack {
-			log.Printf("Skipping auto-transcription for multi-track job %s", jobID)
+			dzLog.Info("Skipping auto-transcription for multi-track job", "job_id", jobID)
 		} else {
-			log.Printf("Auto-transcription enabled, enqueueing job %s", jobID)
+			autoTranscribe = true
+			job.Status = models.StatusPending
+			job.RunAfter = root.Folder.RunAfter(s.clock.Now())
+		}
+	}
 
-			// Update job status to pending before enqueueing
-			if err := database.DB.Model(&job).Update("status", models.StatusPending).Error; err != nil {
-				log.Printf("Warning: Failed to update job status to pending: %v", err)
+	// A re-synced folder must not transcribe the same recording twice
+	dedupe, _ := s.dedupeMode()
+	if dedupe != DedupeOff {
+		s.dedupeMu.Lock()
+		defer s.dedupeMu.Unlock()
+		original, err := findDuplicate(hash)
+		if err != nil {
+			s.fs.Remove(stagedPath)
+			return "", fmt.Errorf("failed to check for duplicates: %v", err)
+		}
+		if original != nil {
+			if dedupe == DedupeSkip {
+				s.fs.Remove(stagedPath)
+				skipDuplicate(original, originalFilename, root.Path)
+				return "", nil
 			}
+			linkDuplicate(&job, original)
+			autoTranscribe = false
+		}
+	}
+
+	// Save to database
+	if err := database.DB.Create(&job).Error; err != nil {
+		s.fs.Remove(stagedPath) // Clean up file on database error
+		return "", fmt.Errorf("failed to create job record: %v", err)
+	}
 
-			// Enqueue the job for transcription
-			if err := s.taskQueue.EnqueueJob(jobID); err != nil {
-				log.Printf("Failed to enqueue job %s for transcription: %v", jobID, err)
-			} else {
-				log.Printf("Job %s enqueued for auto-transcription", jobID)
+	// Move the audio into place; if that fails, drop the job again so the
+	// dropzone file is retried rather than leaving a job without audio
+	if err := s.fs.Rename(stagedPath, destPath); err != nil {
+		if delErr := database.DB.Delete(&models.TranscriptionJob{}, "id = ?", jobID).Error; delErr != nil {
+			// Keep the staged file so RecoverStaged can still complete the job
+			dzLog.Error("Failed to roll back job after move failed", "job_id", jobID, "error", delErr)
+			return "", fmt.Errorf("failed to move file into uploads: %v", err)
+		}
+		s.fs.Remove(stagedPath)
+		return "", fmt.Errorf("failed to move file into uploads: %v", err)
+	}
+	if job.DuplicateOf != nil {
+		eventArgs = append(eventArgs, "duplicate_of", *job.DuplicateOf)
+	}
+	jobstate.Created(context.Background(), job.ID, job.Status, eventArgs...)
+
+	// The pending job is already durable; if the queue cannot take it now the
+	// job scanner picks it up later
+	if autoTranscribe && job.RunAfter != nil {
+		dzLog.Info("Job scheduled for auto-transcription", "job_id", jobID, "run_after", *job.RunAfter)
+	} else if autoTranscribe {
+		dzLog.Debug("Auto-transcription enabled, enqueueing job", "job_id", jobID)
+		if err := s.taskQueue.EnqueueJob(jobID); err != nil {
+			dzLog.Warn("Failed to enqueue job for transcription, leaving it for the job scanner", "job_id", jobID, "error", err)
+		} else {
+			dzLog.Info("Job enqueued for auto-transcription", "job_id", jobID)
+		}
+	}
+
+	dzLog.Info("Successfully uploaded file", "file", originalFilename, "job_id", jobID)
+	return jobID, nil
+}
+
+// RecoverStaged resolves ingests a crash interrupted. A staged file whose job
+// was committed is moved into place; any other staged file is removed.
+func (s *Service) RecoverStaged() error {
+	entries, err := s.fs.ReadDir(s.stagingDir())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read staging directory: %v", err)
+	}
+	s.removeArchiveDirs(entries)
+
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, stagedPrefix) {
+			continue
+		}
+		stagedPath := filepath.Join(s.stagingDir(), name)
+		filename := strings.TrimPrefix(name, stagedPrefix)
+		jobID := strings.TrimSuffix(filename, filepath.Ext(filename))
+
+		var job models.TranscriptionJob
+		err := database.DB.Select("id", "audio_path").Where("id = ?", jobID).First(&job).Error
+		if err != nil && err != gorm.ErrRecordNotFound {
+			dzLog.Warn("Failed to look up staged ingest", "file", name, "error", err)
+			continue
+		}
+		if err == nil && !fsys.Exists(s.fs, job.AudioPath) {
+			if err := s.fs.Rename(stagedPath, job.AudioPath); err != nil {
+				dzLog.Error("Failed to complete interrupted ingest", "job_id", jobID, "error", err)
+				continue
 			}
+			dzLog.Info("Completed interrupted ingest", "job_id", jobID)
+			continue
+		}
+		if err := s.fs.Remove(stagedPath); err != nil {
+			dzLog.Warn("Failed to remove abandoned staged file", "file", name, "error", err)
+			continue
 		}
+		dzLog.Info("Removed abandoned staged file", "file", name)
+	}
+	return nil
+}
+
+// rootUser loads the root's default user, or nil when it has none or the
+// user does not exist
+func (s *Service) rootUser(root Root) *models.User {
+	if root.User == "" {
+		return nil
 	}
+	var user models.User
+	if err := database.DB.Where("username = ?", root.User).First(&user).Error; err != nil {
+		dzLog.Warn("Dropzone default user not found", "root", root.Path, "user", root.User, "error", err)
+		return nil
+	}
+	return &user
+}
 
-	log.Printf("Successfully uploaded file %s as job %s", originalFilename, jobID)
+// checkQuota makes sure the user may store the staged files on top of their
+// jobs; the error says how much space is used when they may not
+func (s *Service) checkQuota(user *models.User, paths ...string) error {
+	var incoming int64
+	for _, path := range paths {
+		info, err := s.fs.Stat(path)
+		if err != nil {
+			return fmt.Errorf("failed to read staged file: %v", err)
+		}
+		incoming += info.Size()
+	}
+	if err := s.quotas.Check(&user.ID, incoming); err != nil {
+		return fmt.Errorf("%s: %w", user.Username, err)
+	}
 	return nil
 }
 
+// autoTranscribe applies a root's policy: its folder's setting, else its
+// own, else its user's, else whether any user has auto-transcription on
+func (s *Service) autoTranscribe(root Root, user *models.User) bool {
+	if root.Folder != nil && root.Folder.AutoTranscribe != nil {
+		return *root.Folder.AutoTranscribe
+	}
+	if root.AutoTranscribe != nil {
+		return *root.AutoTranscribe
+	}
+	if user != nil {
+		return user.AutoTranscriptionEnabled
+	}
+	return s.isAutoTranscriptionEnabled()
+}
+
 // isAutoTranscriptionEnabled checks if auto-transcription is enabled for any user
 func (s *Service) isAutoTranscriptionEnabled() bool {
 	var count int64
@@ -285,31 +830,20 @@ func (s *Service) isAutoTranscriptionEnabled() bool {
 		Count(&count).Error
 
 	if err != nil {
-		log.Printf("Error checking auto-transcription settings: %v", err)
+		dzLog.Error("Error checking auto-transcription settings", "error", err)
 		return false
 	}
 
 	return count > 0
 }
 
-// copyFile copies a file from source to destination
-func (s *Service) copyFile(src, dst string) error {
-	sourceFile, err := os.Open(src)
-	if err != nil {
-		return err
-	}
-	defer sourceFile.Close()
-
-	destFile, err := os.Create(dst)
-	if err != nil {
-		return err
+// stageFile copies r to dst, which only appears once it is complete, and
+// returns the SHA-256 of its content
+func (s *Service) stageFile(r io.Reader, dst string) (string, error) {
+	faults.Delay()
+	hasher := sha256.New()
+	if _, err := fsys.WriteFileAtomic(s.fs, s.config.TempDir, dst, io.TeeReader(r, hasher)); err != nil {
+		return "", err
 	}
-	defer destFile.Close()
-
-	_, err = io.Copy(destFile, sourceFile)
-	if err != nil {
-		return err
-	}
-
-	return destFile.Sync()
+	return hex.EncodeToString(hasher.Sum(nil)), nil
 }
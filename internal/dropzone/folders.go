@@ -0,0 +1,192 @@
+package dropzone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/cron"
+	"synthezia/pkg/fsys"
+
+	"gopkg.in/yaml.v3"
+)
+
+// FolderProfile holds the job settings for files dropped into a subfolder
+// of a root, e.g. diarization and French for "interviews" or the tiny model
+// and deleting the audio for "voicemail". Fields left out keep the root's
+// defaults; a sidecar dropped with a file still has the final say.
+type FolderProfile struct {
+	// Name of a saved transcription profile used instead of the user's default
+	Profile           *string  `json:"profile" yaml:"profile"`
+	Language          *string  `json:"language" yaml:"language"`
+	Model             *string  `json:"model" yaml:"model"`
+	Diarize           *bool    `json:"diarize" yaml:"diarize"`
+	Speakers          *int     `json:"speakers" yaml:"speakers"` // Exact speaker count; turns on diarization
+	MinSpeakers       *int     `json:"min_speakers" yaml:"min_speakers"`
+	MaxSpeakers       *int     `json:"max_speakers" yaml:"max_speakers"`
+	Tags              []string `json:"tags" yaml:"tags"`
+	AutoTranscribe    *bool    `json:"auto_transcribe" yaml:"auto_transcribe"`
+	SourceAudioAction *string  `json:"source_audio_action" yaml:"source_audio_action"` // keep, delete or proxy once transcribed
+	Schedule          *string  `json:"schedule" yaml:"schedule"`                       // Cron expression; auto-transcription waits for its next match
+}
+
+// ParseFolderProfiles decodes a DROPZONE_PROFILES file, choosing JSON or YAML
+// from its name. It maps folders, relative to the root they are in, to their
+// profiles:
+//
+//	interviews:
+//	  language: fr
+//	  diarize: true
+//	voicemail:
+//	  model: tiny
+//	  source_audio_action: delete
+//	archive:
+//	  schedule: "0 1 * * *" # transcribe overnight
+//
+// A profile applies to its folder and the folders below it, the deepest
+// one winning. Unknown fields are rejected so typos do not go unnoticed.
+func ParseFolderProfiles(name string, data []byte) (map[string]*FolderProfile, error) {
+	var raw map[string]*FolderProfile
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, err
+		}
+	default:
+		return nil, fmt.Errorf("unsupported format: %s", filepath.Base(name))
+	}
+
+	profiles := make(map[string]*FolderProfile, len(raw))
+	for folder, profile := range raw {
+		key := filepath.Clean(filepath.FromSlash(strings.TrimSpace(folder)))
+		if key == "." || filepath.IsAbs(key) || key == ".." || strings.HasPrefix(key, ".."+string(filepath.Separator)) {
+			return nil, fmt.Errorf("folder %q must be a subfolder of a root", folder)
+		}
+		if profile == nil {
+			profile = &FolderProfile{}
+		}
+		if err := profile.validate(); err != nil {
+			return nil, fmt.Errorf("folder %q: %v", folder, err)
+		}
+		if _, ok := profiles[key]; ok {
+			return nil, fmt.Errorf("folder %q is listed twice", folder)
+		}
+		profiles[key] = profile
+	}
+	return profiles, nil
+}
+
+// validate rejects settings no job could use
+func (p *FolderProfile) validate() error {
+	for field, value := range map[string]*int{"speakers": p.Speakers, "min_speakers": p.MinSpeakers, "max_speakers": p.MaxSpeakers} {
+		if value != nil && *value < 1 {
+			return fmt.Errorf("%s must be at least 1", field)
+		}
+	}
+	if p.MinSpeakers != nil && p.MaxSpeakers != nil && *p.MinSpeakers > *p.MaxSpeakers {
+		return fmt.Errorf("min_speakers is greater than max_speakers")
+	}
+	if p.Schedule != nil {
+		if _, err := cron.Parse(*p.Schedule); err != nil {
+			return fmt.Errorf("schedule: %v", err)
+		}
+	}
+	if p.SourceAudioAction != nil {
+		switch *p.SourceAudioAction {
+		case models.SourceAudioKeep, models.SourceAudioDelete, models.SourceAudioProxy:
+		default:
+			return fmt.Errorf("unknown source_audio_action %q, expected keep, delete or proxy", *p.SourceAudioAction)
+		}
+	}
+	return nil
+}
+
+// Apply copies the profile's settings into a job. A named transcription
+// profile that does not exist fails the ingest, leaving the file to be
+// retried once it is created.
+func (p *FolderProfile) Apply(job *models.TranscriptionJob) error {
+	if p.Profile != nil && *p.Profile != "" {
+		var profile models.TranscriptionProfile
+		if err := database.DB.Where("name = ?", *p.Profile).First(&profile).Error; err != nil {
+			return fmt.Errorf("transcription profile %q not found", *p.Profile)
+		}
+		job.Parameters = profile.Parameters
+		job.Diarization = profile.Parameters.Diarize
+	}
+	if p.Diarize != nil {
+		job.Parameters.Diarize = *p.Diarize
+		job.Diarization = *p.Diarize
+	}
+	// The remaining fields mean the same as in a sidecar
+	sidecar := Sidecar{
+		Language:    p.Language,
+		Model:       p.Model,
+		Speakers:    p.Speakers,
+		MinSpeakers: p.MinSpeakers,
+		MaxSpeakers: p.MaxSpeakers,
+		Tags:        p.Tags,
+	}
+	sidecar.Apply(job)
+	if p.SourceAudioAction != nil {
+		action := *p.SourceAudioAction
+		job.SourceAudioAction = &action
+	}
+	return nil
+}
+
+// RunAfter returns when jobs auto-transcribed from the folder may start:
+// the next match of its schedule after now, or nil to start straight away
+func (p *FolderProfile) RunAfter(now time.Time) *time.Time {
+	if p == nil || p.Schedule == nil {
+		return nil
+	}
+	schedule, err := cron.Parse(*p.Schedule)
+	if err != nil {
+		return nil
+	}
+	next := schedule.Next(now)
+	if next.IsZero() {
+		return nil
+	}
+	next = next.UTC()
+	return &next
+}
+
+// loadFolderProfiles reads the DROPZONE_PROFILES file, if one is set
+func (s *Service) loadFolderProfiles() (map[string]*FolderProfile, error) {
+	if s.config.DropzoneProfiles == "" {
+		return nil, nil
+	}
+	data, err := fsys.ReadFile(s.fs, s.config.DropzoneProfiles)
+	if err != nil {
+		return nil, err
+	}
+	return ParseFolderProfiles(s.config.DropzoneProfiles, data)
+}
+
+// settingsFor returns the root a dropped file belongs to, carrying the
+// profile of the folder it was dropped into
+func (s *Service) settingsFor(path string) Root {
+	root := s.rootFor(path)
+	dirs := s.relToRoot(filepath.Dir(path))
+	for i := len(dirs); i > 0; i-- {
+		if profile, ok := s.profiles[filepath.Join(dirs[:i]...)]; ok {
+			root.Folder = profile
+			break
+		}
+	}
+	return root
+}
@@ -0,0 +1,65 @@
+package dropzone
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// parseIgnore parses DROPZONE_IGNORE: glob patterns separated by commas,
+// matched against the name of each file and folder
+func parseIgnore(spec string) ([]string, error) {
+	var patterns []string
+	for _, pattern := range strings.Split(spec, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if pattern == "" {
+			continue
+		}
+		if strings.ContainsAny(pattern, `/\`) {
+			return nil, fmt.Errorf("pattern %q must match a name, not a path", pattern)
+		}
+		if _, err := filepath.Match(pattern, ""); err != nil {
+			return nil, fmt.Errorf("pattern %q: %v", pattern, err)
+		}
+		patterns = append(patterns, pattern)
+	}
+	return patterns, nil
+}
+
+// relToRoot returns the names from path's root down to path itself
+func (s *Service) relToRoot(path string) []string {
+	rel, err := filepath.Rel(s.rootFor(path).Path, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return nil
+	}
+	return strings.Split(rel, string(filepath.Separator))
+}
+
+// isIgnored reports whether path, or a folder it is in below its root,
+// matches an ignore pattern. Temporary files that rsync, Syncthing and
+// browsers write before renaming them into place are ignored this way.
+func (s *Service) isIgnored(path string) bool {
+	for _, name := range s.relToRoot(path) {
+		for _, pattern := range s.ignore {
+			if ok, _ := filepath.Match(pattern, name); ok {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// tooDeep reports whether path is more than DropzoneMaxDepth levels below
+// its root, counting the files directly in the root as level 1
+func (s *Service) tooDeep(path string) bool {
+	return s.config.DropzoneMaxDepth > 0 && len(s.relToRoot(path)) > s.config.DropzoneMaxDepth
+}
+
+// skipDir reports whether the folder at path is left unwatched: it is
+// ignored, or the files in it would be too deep
+func (s *Service) skipDir(path string) bool {
+	if s.isIgnored(path) {
+		return true
+	}
+	return s.config.DropzoneMaxDepth > 0 && len(s.relToRoot(path)) >= s.config.DropzoneMaxDepth
+}
@@ -0,0 +1,370 @@
+package dropzone
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/smtp"
+	"net/textproto"
+	"path"
+	"strings"
+	"time"
+
+	"synthezia/internal/config"
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/imap"
+)
+
+// mailTimeout bounds each exchange with the mail servers
+const mailTimeout = 2 * time.Minute
+
+// maxMIMEDepth bounds how deeply multipart messages are searched for
+// attachments
+const maxMIMEDepth = 8
+
+// MailboxWatcher ingests audio attached to mail sent to an IMAP mailbox.
+// Each attachment becomes a job of the user whose email matches the sender,
+// and the sender gets a reply with a link to the job once it is transcribed.
+// The From address is trusted as it is, so the mailbox should only accept
+// mail its server has authenticated.
+type MailboxWatcher struct {
+	service  *Service
+	config   *config.Config
+	defaults Root
+	sendMail func(to string, msg []byte) error
+}
+
+// NewMailboxWatcher creates a watcher for the DROPZONE_IMAP_* settings,
+// ingesting through service and replying through SMTP_ADDR
+func NewMailboxWatcher(service *Service, cfg *config.Config) (*MailboxWatcher, error) {
+	defaults := Root{Path: "imap://" + path.Join(cfg.DropzoneIMAPAddr, cfg.DropzoneIMAPMailbox)}
+	if strings.TrimSpace(cfg.DropzoneIMAPOptions) != "" {
+		if err := parseRootOptions(&defaults, strings.Split(cfg.DropzoneIMAPOptions, ";")); err != nil {
+			return nil, fmt.Errorf("invalid DROPZONE_IMAP_OPTIONS: %v", err)
+		}
+		if defaults.User != "" {
+			return nil, errors.New("invalid DROPZONE_IMAP_OPTIONS: the user is whoever sent the mail and cannot be set")
+		}
+	}
+	if _, err := service.dedupeMode(); err != nil {
+		return nil, fmt.Errorf("invalid DROPZONE_DEDUPE: %v", err)
+	}
+
+	w := &MailboxWatcher{service: service, config: cfg, defaults: defaults}
+	if cfg.SMTPAddr != "" {
+		w.sendMail = w.sendSMTP
+	}
+	return w, nil
+}
+
+// SetSendMail overrides how replies are sent, mainly for tests
+func (w *MailboxWatcher) SetSendMail(send func(to string, msg []byte) error) {
+	w.sendMail = send
+}
+
+// Run sends the replies missed while the server was down, then polls the
+// mailbox straight away and every interval until stop is closed
+func (w *MailboxWatcher) Run(stop <-chan struct{}, interval time.Duration) {
+	w.SendPendingReplies()
+
+	ticker := w.service.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Poll(); err != nil {
+			dzLog.Warn("Failed to poll mailbox", "mailbox", w.config.DropzoneIMAPMailbox, "error", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Poll ingests the audio attached to unread mail and returns how many jobs
+// it created. Mail is marked read once handled, including mail from unknown
+// senders or without audio; mail none of whose audio could be ingested is
+// left unread to be retried on the next poll.
+func (w *MailboxWatcher) Poll() (int, error) {
+	client, err := imap.Dial(w.config.DropzoneIMAPAddr, w.config.DropzoneIMAPTLS, mailTimeout)
+	if err != nil {
+		return 0, err
+	}
+	defer client.Close()
+	if err := client.Login(w.config.DropzoneIMAPUsername, w.config.DropzoneIMAPPassword); err != nil {
+		return 0, err
+	}
+	if err := client.Select(w.config.DropzoneIMAPMailbox); err != nil {
+		return 0, err
+	}
+	uids, err := client.SearchUnseen()
+	if err != nil {
+		return 0, err
+	}
+
+	ingested := 0
+	for _, uid := range uids {
+		data, err := client.Fetch(uid)
+		if err != nil {
+			dzLog.Error("Failed to download mail", "uid", uid, "error", err)
+			continue
+		}
+		w.service.waitForQueue()
+		count, err := w.ingestMessage(data)
+		ingested += count
+		if err != nil {
+			if count == 0 {
+				dzLog.Error("Failed to ingest mail, leaving it unread", "uid", uid, "error", err)
+				continue
+			}
+			dzLog.Error("Failed to ingest some attachments of mail", "uid", uid, "error", err)
+		}
+		if err := client.MarkSeen(uid); err != nil {
+			return ingested, err
+		}
+	}
+	return ingested, client.Logout()
+}
+
+// ingestMessage creates a job for each audio attachment of a raw message
+// from a known sender and returns how many it created
+func (w *MailboxWatcher) ingestMessage(data []byte) (int, error) {
+	msg, err := mail.ReadMessage(bytes.NewReader(data))
+	if err != nil {
+		dzLog.Warn("Skipping unreadable mail", "error", err)
+		return 0, nil
+	}
+	from, err := mail.ParseAddress(msg.Header.Get("From"))
+	if err != nil {
+		dzLog.Warn("Skipping mail without a valid sender", "from", msg.Header.Get("From"))
+		return 0, nil
+	}
+	var user models.User
+	if err := database.DB.Where("email = ?", strings.ToLower(from.Address)).First(&user).Error; err != nil {
+		dzLog.Warn("Ignoring mail from unknown sender", "from", from.Address)
+		return 0, nil
+	}
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	if err != nil {
+		subject = msg.Header.Get("Subject")
+	}
+	messageID := msg.Header.Get("Message-Id")
+
+	root := w.defaults
+	root.User = user.Username
+	ingested := 0
+	var firstErr error
+	err = w.walkAttachments(textproto.MIMEHeader(msg.Header), msg.Body, 0, func(name string, r io.Reader) {
+		jobID, err := w.service.ingest(r, name, root, nil, "source", "imap", "from", from.Address, "file", name)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s: %v", name, err)
+			}
+			return
+		}
+		ingested++
+		if jobID == "" {
+			return
+		}
+		record := models.IngestedMail{JobID: jobID, Sender: from.Address, MessageID: messageID, Subject: subject}
+		if err := database.DB.Create(&record).Error; err != nil {
+			dzLog.Warn("Failed to record mailed-in job, no reply will be sent", "job_id", jobID, "error", err)
+		}
+	})
+	if err != nil && firstErr == nil {
+		firstErr = err
+	}
+	if ingested == 0 && firstErr == nil {
+		dzLog.Info("Mail has no audio attachments", "from", from.Address, "subject", subject)
+	}
+	return ingested, firstErr
+}
+
+// walkAttachments calls fn with the name and decoded content of each part
+// of a message named like an audio file
+func (w *MailboxWatcher) walkAttachments(header textproto.MIMEHeader, body io.Reader, depth int, fn func(name string, r io.Reader)) error {
+	mediaType, params, err := mime.ParseMediaType(header.Get("Content-Type"))
+	if err != nil {
+		mediaType, params = "text/plain", nil
+	}
+	if strings.HasPrefix(mediaType, "multipart/") {
+		if depth >= maxMIMEDepth {
+			return nil
+		}
+		parts := multipart.NewReader(body, params["boundary"])
+		for {
+			// Raw parts keep their transfer encoding, which decodeTransfer
+			// undoes for every encoding alike
+			part, err := parts.NextRawPart()
+			if err == io.EOF {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+			if err := w.walkAttachments(part.Header, part, depth+1, fn); err != nil {
+				return err
+			}
+		}
+	}
+
+	name := attachmentName(header, params)
+	if name == "" || !w.service.isAudioFile(name) {
+		return nil
+	}
+	fn(name, decodeTransfer(header.Get("Content-Transfer-Encoding"), body))
+	return nil
+}
+
+// attachmentName returns the file name a part is attached under, if any
+func attachmentName(header textproto.MIMEHeader, typeParams map[string]string) string {
+	name := ""
+	if _, params, err := mime.ParseMediaType(header.Get("Content-Disposition")); err == nil {
+		name = params["filename"]
+	}
+	if name == "" {
+		name = typeParams["name"]
+	}
+	if decoded, err := new(mime.WordDecoder).DecodeHeader(name); err == nil {
+		name = decoded
+	}
+	name = path.Base(strings.ReplaceAll(name, "\\", "/"))
+	if name == "." || name == "/" {
+		return ""
+	}
+	return name
+}
+
+// decodeTransfer undoes a part's Content-Transfer-Encoding
+func decodeTransfer(encoding string, r io.Reader) io.Reader {
+	switch strings.ToLower(strings.TrimSpace(encoding)) {
+	case "base64":
+		return base64.NewDecoder(base64.StdEncoding, r)
+	case "quoted-printable":
+		return quotedprintable.NewReader(r)
+	default:
+		return r
+	}
+}
+
+// Track replies to senders as their jobs complete, returning a function
+// that stops it
+func (w *MailboxWatcher) Track() func() {
+	return jobstate.Subscribe(func(event jobstate.Event) {
+		if event.To != models.StatusCompleted {
+			return
+		}
+		go func() {
+			if err := w.reply(event.JobID); err != nil {
+				dzLog.Warn("Failed to reply to mailed-in recording", "job_id", event.JobID, "error", err)
+			}
+		}()
+	})
+}
+
+// SendPendingReplies replies for completed mailed-in jobs whose reply was
+// never sent, e.g. because the server stopped before sending it
+func (w *MailboxWatcher) SendPendingReplies() {
+	var pending []models.IngestedMail
+	err := database.DB.Joins("JOIN transcription_jobs ON transcription_jobs.id = ingested_mails.job_id").
+		Where("ingested_mails.replied_at IS NULL AND transcription_jobs.status = ?", models.StatusCompleted).
+		Find(&pending).Error
+	if err != nil {
+		dzLog.Warn("Failed to look up pending mail replies", "error", err)
+		return
+	}
+	for _, record := range pending {
+		if err := w.reply(record.JobID); err != nil {
+			dzLog.Warn("Failed to reply to mailed-in recording", "job_id", record.JobID, "error", err)
+		}
+	}
+}
+
+// reply tells the sender of a mailed-in job where to find its transcript.
+// The reply is claimed before it is sent, so it goes out at most once.
+func (w *MailboxWatcher) reply(jobID string) error {
+	if w.sendMail == nil {
+		return nil
+	}
+	var record models.IngestedMail
+	if err := database.DB.Where("job_id = ? AND replied_at IS NULL", jobID).First(&record).Error; err != nil {
+		return nil // Not mailed in, or already answered
+	}
+	now := w.service.clock.Now()
+	claim := database.DB.Model(&models.IngestedMail{}).
+		Where("id = ? AND replied_at IS NULL", record.ID).
+		Update("replied_at", now)
+	if claim.Error != nil || claim.RowsAffected == 0 {
+		return claim.Error
+	}
+
+	var job models.TranscriptionJob
+	if err := database.DB.Select("id", "title").Where("id = ?", jobID).First(&job).Error; err != nil {
+		database.DB.Model(&record).Update("replied_at", nil)
+		return err
+	}
+	if err := w.sendMail(record.Sender, w.replyMessage(record, job, now)); err != nil {
+		database.DB.Model(&record).Update("replied_at", nil)
+		return err
+	}
+	dzLog.Info("Replied to mailed-in recording", "job_id", jobID, "to", record.Sender)
+	return nil
+}
+
+// replyMessage builds the reply telling the sender where the job is
+func (w *MailboxWatcher) replyMessage(record models.IngestedMail, job models.TranscriptionJob, now time.Time) []byte {
+	title := job.ID
+	if job.Title != nil {
+		title = *job.Title
+	}
+	subject := record.Subject
+	if !strings.HasPrefix(strings.ToLower(subject), "re:") {
+		subject = "Re: " + subject
+	}
+	clean := strings.NewReplacer("\r", "", "\n", " ")
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", w.config.SMTPFrom)
+	fmt.Fprintf(&msg, "To: %s\r\n", record.Sender)
+	fmt.Fprintf(&msg, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", clean.Replace(subject)))
+	fmt.Fprintf(&msg, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	if record.MessageID != "" {
+		id := clean.Replace(record.MessageID)
+		fmt.Fprintf(&msg, "In-Reply-To: %s\r\nReferences: %s\r\n", id, id)
+	}
+	msg.WriteString("MIME-Version: 1.0\r\nContent-Type: text/plain; charset=utf-8\r\n\r\n")
+	fmt.Fprintf(&msg, "Your recording %q has been transcribed:\r\n\r\n%s\r\n", title, publicURL(w.config)+"/audio/"+job.ID)
+	return msg.Bytes()
+}
+
+// sendSMTP sends a message through SMTP_ADDR, logging in when a username is
+// set
+func (w *MailboxWatcher) sendSMTP(to string, msg []byte) error {
+	var auth smtp.Auth
+	if w.config.SMTPUsername != "" {
+		host, _, _ := net.SplitHostPort(w.config.SMTPAddr)
+		auth = smtp.PlainAuth("", w.config.SMTPUsername, w.config.SMTPPassword, host)
+	}
+	from := w.config.SMTPFrom
+	if address, err := mail.ParseAddress(from); err == nil {
+		from = address.Address
+	}
+	return smtp.SendMail(w.config.SMTPAddr, auth, from, []string{to}, msg)
+}
+
+// publicURL is where users reach the web UI
+func publicURL(cfg *config.Config) string {
+	if cfg.PublicURL != "" {
+		return strings.TrimSuffix(cfg.PublicURL, "/")
+	}
+	return "http://" + net.JoinHostPort(cfg.Host, cfg.Port)
+}
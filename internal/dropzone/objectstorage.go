@@ -0,0 +1,220 @@
+package dropzone
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"synthezia/internal/config"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/s3"
+)
+
+// maxSidecarSize bounds how much of a sidecar object is read
+const maxSidecarSize = 1 << 20
+
+// ObjectStorageWatcher ingests audio uploaded to an S3-compatible bucket,
+// streaming each object straight into the upload directory. Objects only
+// appear in a listing once fully uploaded, so unlike dropzone files they need
+// no settle time. Sidecars are looked up next to each object as in a dropzone.
+type ObjectStorageWatcher struct {
+	service     *Service
+	client      *s3.Client
+	prefix      string
+	root        Root
+	deleteAfter bool
+}
+
+// NewObjectStorageWatcher creates a watcher for the DROPZONE_S3_* settings,
+// ingesting through service
+func NewObjectStorageWatcher(service *Service, cfg *config.Config) (*ObjectStorageWatcher, error) {
+	client, err := s3.New(s3.Config{
+		Endpoint:  cfg.DropzoneS3Endpoint,
+		Region:    cfg.DropzoneS3Region,
+		Bucket:    cfg.DropzoneS3Bucket,
+		AccessKey: cfg.DropzoneS3AccessKey,
+		SecretKey: cfg.DropzoneS3SecretKey,
+		PathStyle: cfg.DropzoneS3PathStyle,
+	})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := service.dedupeMode(); err != nil {
+		return nil, fmt.Errorf("invalid DROPZONE_DEDUPE: %v", err)
+	}
+
+	root := Root{Path: "s3://" + path.Join(cfg.DropzoneS3Bucket, cfg.DropzoneS3Prefix)}
+	if strings.TrimSpace(cfg.DropzoneS3Options) != "" {
+		if err := parseRootOptions(&root, strings.Split(cfg.DropzoneS3Options, ";")); err != nil {
+			return nil, fmt.Errorf("invalid DROPZONE_S3_OPTIONS: %v", err)
+		}
+	}
+	return &ObjectStorageWatcher{
+		service:     service,
+		client:      client,
+		prefix:      cfg.DropzoneS3Prefix,
+		root:        root,
+		deleteAfter: cfg.DropzoneS3Delete,
+	}, nil
+}
+
+// Run polls the bucket straight away and then every interval until stop is
+// closed, which also aborts a download in progress
+func (w *ObjectStorageWatcher) Run(stop <-chan struct{}, interval time.Duration) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go func() {
+		select {
+		case <-stop:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	ticker := w.service.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := w.Poll(ctx); err != nil && ctx.Err() == nil {
+			dzLog.Warn("Failed to poll bucket", "bucket", w.client.Bucket(), "error", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// Poll ingests the audio objects not handled yet and returns how many it
+// took. An object that fails to download is retried on the next poll; an
+// empty one is recorded as invalid and left in the bucket.
+func (w *ObjectStorageWatcher) Poll(ctx context.Context) (int, error) {
+	objects, err := w.client.List(ctx, w.prefix)
+	if err != nil {
+		return 0, err
+	}
+	keys := make(map[string]bool, len(objects))
+	for _, object := range objects {
+		keys[object.Key] = true
+	}
+
+	ingested := 0
+	for _, object := range objects {
+		if ctx.Err() != nil {
+			return ingested, ctx.Err()
+		}
+		name := path.Base(object.Key)
+		if strings.HasSuffix(object.Key, "/") || !w.service.isAudioFile(name) || w.handled(object) {
+			continue
+		}
+		if object.Size == 0 {
+			dzLog.Warn("Skipping invalid object", "bucket", w.client.Bucket(), "key", object.Key, "reason", "file is empty")
+			w.record(object, nil, "file is empty")
+			continue
+		}
+
+		w.service.waitForQueue()
+		sidecar, sidecarKey, err := w.loadSidecar(ctx, object.Key, keys)
+		if err != nil {
+			dzLog.Error("Failed to read sidecar, leaving object in place", "key", object.Key, "error", err)
+			continue
+		}
+		body, err := w.client.Get(ctx, object.Key)
+		if err != nil {
+			dzLog.Error("Failed to download object", "key", object.Key, "error", err)
+			continue
+		}
+		eventArgs := []any{"source", "s3", "bucket", w.client.Bucket(), "key", object.Key}
+		if sidecarKey != "" {
+			eventArgs = append(eventArgs, "sidecar", path.Base(sidecarKey))
+		}
+		jobID, err := w.service.ingest(body, name, w.root, sidecar, eventArgs...)
+		body.Close()
+		if err != nil {
+			dzLog.Error("Failed to ingest object", "key", object.Key, "error", err)
+			continue
+		}
+		ingested++
+
+		// A deleted object cannot be listed again, so only kept ones are recorded
+		if w.deleteAfter && w.delete(ctx, object.Key, sidecarKey) {
+			continue
+		}
+		var jobIDPtr *string
+		if jobID != "" {
+			jobIDPtr = &jobID
+		}
+		w.record(object, jobIDPtr, "")
+	}
+	return ingested, nil
+}
+
+// handled reports whether this version of object was already ingested or
+// found invalid
+func (w *ObjectStorageWatcher) handled(object s3.Object) bool {
+	var count int64
+	err := database.DB.Model(&models.IngestedObject{}).
+		Where("bucket = ? AND key = ? AND e_tag = ?", w.client.Bucket(), object.Key, object.ETag).
+		Count(&count).Error
+	if err != nil {
+		dzLog.Warn("Failed to look up ingested object", "key", object.Key, "error", err)
+		return true // Retry on the next poll rather than risk ingesting twice
+	}
+	return count > 0
+}
+
+// record remembers object so later polls skip it
+func (w *ObjectStorageWatcher) record(object s3.Object, jobID *string, reason string) {
+	entry := models.IngestedObject{
+		Bucket: w.client.Bucket(),
+		Key:    object.Key,
+		ETag:   object.ETag,
+		JobID:  jobID,
+	}
+	if reason != "" {
+		entry.Error = &reason
+	}
+	if err := database.DB.Create(&entry).Error; err != nil {
+		dzLog.Error("Failed to record ingested object", "key", object.Key, "error", err)
+	}
+}
+
+// delete removes an ingested object and its sidecar, reporting whether the
+// object itself is gone
+func (w *ObjectStorageWatcher) delete(ctx context.Context, key, sidecarKey string) bool {
+	if err := w.client.Delete(ctx, key); err != nil {
+		dzLog.Warn("Failed to delete object from bucket", "key", key, "error", err)
+		return false
+	}
+	if sidecarKey != "" {
+		if err := w.client.Delete(ctx, sidecarKey); err != nil {
+			dzLog.Warn("Failed to delete sidecar from bucket", "key", sidecarKey, "error", err)
+		}
+	}
+	return true
+}
+
+// loadSidecar downloads and parses the sidecar of key, if keys lists one
+func (w *ObjectStorageWatcher) loadSidecar(ctx context.Context, key string, keys map[string]bool) (*Sidecar, string, error) {
+	for _, candidate := range sidecarCandidates(key) {
+		if !keys[candidate] {
+			continue
+		}
+		body, err := w.client.Get(ctx, candidate)
+		if err != nil {
+			return nil, candidate, fmt.Errorf("failed to read sidecar: %v", err)
+		}
+		data, err := io.ReadAll(io.LimitReader(body, maxSidecarSize))
+		body.Close()
+		if err != nil {
+			return nil, candidate, fmt.Errorf("failed to read sidecar: %v", err)
+		}
+		sidecar, err := ParseSidecar(candidate, data)
+		return sidecar, candidate, err
+	}
+	return nil, "", nil
+}
@@ -0,0 +1,267 @@
+package dropzone
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"synthezia/internal/audio"
+	"synthezia/pkg/fsys"
+
+	"github.com/google/uuid"
+)
+
+// isProjectFile reports whether filename is a multi-track project, made in
+// Audacity, Reaper, Ardour or exported as AAF
+func isProjectFile(filename string) bool {
+	return audio.IsProjectFile(filename)
+}
+
+// projectDataDir returns the folder Audacity keeps next to a project for
+// its data
+func projectDataDir(projectPath string) string {
+	return strings.TrimSuffix(projectPath, filepath.Ext(projectPath)) + "_data"
+}
+
+// trackFileName returns the name of the file a project track imports.
+// Projects record absolute paths from the machine they were made on.
+func trackFileName(track audio.TrackInfo) string {
+	return audio.ProjectFileName(track.FilePath)
+}
+
+// parseProject reads the tracks of a project with the parser for its extension
+func parseProject(name string, data []byte) ([]audio.TrackInfo, error) {
+	parser, ok := audio.ProjectParserFor(name)
+	if !ok {
+		return nil, fmt.Errorf("unsupported project file: %s", filepath.Base(name))
+	}
+	return parser.Parse(data)
+}
+
+// missingTracksError reports project tracks that have not been dropped yet
+type missingTracksError struct {
+	names []string
+}
+
+func (e *missingTracksError) Error() string {
+	return "project tracks not found: " + strings.Join(e.names, ", ")
+}
+
+// projectTrackNames returns the names of the files a dropped project
+// imports, in project order and once each
+func (s *Service) projectTrackNames(projectPath string) ([]string, error) {
+	data, err := fsys.ReadFile(s.fs, projectPath)
+	if err != nil {
+		return nil, err
+	}
+	tracks, err := parseProject(projectPath, data)
+	if err != nil {
+		return nil, &invalidFileError{reason: fmt.Sprintf("invalid project: %v", err)}
+	}
+	var names []string
+	seen := make(map[string]bool)
+	for _, track := range tracks {
+		name := trackFileName(track)
+		if !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+	if len(names) == 0 {
+		return nil, &invalidFileError{reason: "project has no tracks"}
+	}
+	return names, nil
+}
+
+// findProjectTracks looks for the named tracks of a dropped project next to
+// it and anywhere in its _data folder, preferring the copy next to it
+func (s *Service) findProjectTracks(projectPath string, names []string) ([]string, error) {
+	byName := make(map[string][]string)
+	dir := filepath.Dir(projectPath)
+	if entries, err := s.fs.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if !entry.IsDir() {
+				byName[entry.Name()] = append(byName[entry.Name()], filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	fsys.Walk(s.fs, projectDataDir(projectPath), func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			byName[info.Name()] = append(byName[info.Name()], path)
+		}
+		return nil
+	})
+
+	var tracks, missing []string
+	for _, name := range names {
+		if file := pickTrack(byName[name], dir); file != "" {
+			tracks = append(tracks, file)
+		} else {
+			missing = append(missing, name)
+		}
+	}
+	if len(missing) > 0 {
+		return nil, &missingTracksError{names: missing}
+	}
+	return tracks, nil
+}
+
+// projectFor returns the dropped project that imports audioPath,
+// looking next to the file and above it for the _data folder it sits in, or
+// "" if the file belongs to no project
+func (s *Service) projectFor(audioPath string) string {
+	name := filepath.Base(audioPath)
+	dir := filepath.Dir(audioPath)
+	var projects []string
+	if entries, err := s.fs.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			// Projects holding their own audio import no files
+			if !entry.IsDir() && isProjectFile(entry.Name()) && !audio.EmbedsAudio(entry.Name()) {
+				projects = append(projects, filepath.Join(dir, entry.Name()))
+			}
+		}
+	}
+	rootPath := s.rootFor(audioPath).Path
+	for d := dir; d != rootPath && filepath.Dir(d) != d; d = filepath.Dir(d) {
+		if strings.HasSuffix(d, "_data") {
+			project := strings.TrimSuffix(d, "_data") + ".aup"
+			if fsys.Exists(s.fs, project) {
+				projects = append(projects, project)
+			}
+		}
+	}
+
+	for _, project := range projects {
+		names, err := s.projectTrackNames(project)
+		if err != nil {
+			continue
+		}
+		for _, track := range names {
+			if track == name {
+				return project
+			}
+		}
+	}
+	return ""
+}
+
+// ingestProject creates a multi-track job from a dropped project
+// and the tracks it imports, then applies the retention to the tracks; the
+// caller applies it to the project itself. The files are copied to the
+// staging area first, as the dropzone may be on another filesystem than the
+// uploads.
+// It returns the job's ID, or a *missingTracksError while tracks are still
+// to be dropped.
+func (s *Service) ingestProject(projectPath string) (string, error) {
+	if audio.EmbedsAudio(projectPath) {
+		return s.ingestEmbeddedProject(projectPath)
+	}
+	names, err := s.projectTrackNames(projectPath)
+	if err != nil {
+		return "", err
+	}
+	tracks, err := s.findProjectTracks(projectPath, names)
+	if err != nil {
+		return "", err
+	}
+
+	dir := filepath.Join(s.stagingDir(), archivePrefix+uuid.New().String())
+	defer s.fs.RemoveAll(dir)
+	if err := s.fs.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	set := archiveSet{project: filepath.Join(dir, filepath.Base(projectPath))}
+	if err := s.copyToStaging(projectPath, set.project); err != nil {
+		return "", err
+	}
+	for _, track := range tracks {
+		// Tracks may still be copying after the project has settled
+		if _, err := s.waitForSettle(track); err != nil {
+			return "", err
+		}
+		staged := filepath.Join(dir, filepath.Base(track))
+		if err := s.copyToStaging(track, staged); err != nil {
+			return "", err
+		}
+		set.tracks = append(set.tracks, staged)
+	}
+
+	root := s.settingsFor(projectPath)
+	jobID, err := s.createMultiTrackJob(set, root, filepath.Base(projectPath))
+	if err != nil {
+		return "", err
+	}
+	for _, track := range tracks {
+		s.setJob(track, jobID)
+		if err := s.retain(root, track); err != nil {
+			dzLog.Warn("Failed to apply retention to project track", "path", track, "error", err)
+		}
+	}
+	// The data folder goes once nothing else is left in it
+	dataDir := projectDataDir(projectPath)
+	if entries, err := s.fs.ReadDir(dataDir); err == nil && len(entries) == 0 {
+		s.fs.Remove(dataDir)
+	}
+	return jobID, nil
+}
+
+// ingestEmbeddedProject creates a multi-track job from a dropped project
+// that holds its own audio, like an Audacity 3 project, extracting its
+// clips in the staging area
+func (s *Service) ingestEmbeddedProject(projectPath string) (string, error) {
+	dir := filepath.Join(s.stagingDir(), archivePrefix+uuid.New().String())
+	defer s.fs.RemoveAll(dir)
+	if err := s.fs.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create staging directory: %v", err)
+	}
+	set := archiveSet{project: filepath.Join(dir, filepath.Base(projectPath))}
+	if err := s.copyToStaging(projectPath, set.project); err != nil {
+		return "", err
+	}
+	tracks, err := s.extractProject(set.project)
+	if err != nil {
+		return "", err
+	}
+	set.tracks = tracks
+	return s.createMultiTrackJob(set, s.settingsFor(projectPath), filepath.Base(projectPath))
+}
+
+// extractProject writes the clips of a staged project holding its own audio
+// to a folder next to it and returns their paths
+func (s *Service) extractProject(projectPath string) ([]string, error) {
+	extractor, ok := audio.ProjectExtractorFor(projectPath)
+	if !ok {
+		return nil, fmt.Errorf("unsupported project file: %s", filepath.Base(projectPath))
+	}
+	// The extractor reads the project and writes the tracks itself
+	if s.fs != fsys.OS {
+		return nil, fmt.Errorf("cannot extract %s outside the local filesystem", filepath.Base(projectPath))
+	}
+	dir := strings.TrimSuffix(projectPath, filepath.Ext(projectPath)) + "_tracks"
+	tracks, err := extractor.Extract(projectPath, dir)
+	if err != nil {
+		return nil, &invalidFileError{reason: fmt.Sprintf("invalid project: %v", err)}
+	}
+	if len(tracks) == 0 {
+		return nil, &invalidFileError{reason: "project has no tracks"}
+	}
+	paths := make([]string, len(tracks))
+	for i, track := range tracks {
+		paths[i] = filepath.Join(dir, track.FilePath)
+	}
+	return paths, nil
+}
+
+// copyToStaging copies a dropzone file into the staging area
+func (s *Service) copyToStaging(src, dst string) error {
+	f, err := s.fs.Open(src)
+	if err != nil {
+		return fmt.Errorf("failed to copy %s: %v", filepath.Base(src), err)
+	}
+	defer f.Close()
+	if _, err := s.stageFile(f, dst); err != nil {
+		return fmt.Errorf("failed to copy %s: %v", filepath.Base(src), err)
+	}
+	return nil
+}
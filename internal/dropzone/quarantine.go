@@ -0,0 +1,207 @@
+package dropzone
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+)
+
+// QuarantineDir is the subfolder of each root that files failing validation
+// are moved to, each with a "<name>.error" file explaining why. It is not
+// watched, so files there are only retried when moved back out.
+const QuarantineDir = "quarantine"
+
+// errorExt is appended to a quarantined file's name for its explanation
+const errorExt = ".error"
+
+// probeTimeout bounds how long ffprobe may take to read a file's header
+const probeTimeout = 30 * time.Second
+
+// invalidFileError is a file that can never be transcribed as it is
+type invalidFileError struct {
+	reason string
+}
+
+func (e *invalidFileError) Error() string {
+	return e.reason
+}
+
+// isQuarantined reports whether path is inside a root's quarantine folder
+func (s *Service) isQuarantined(path string) bool {
+	return s.inRootFolder(path, QuarantineDir)
+}
+
+// inRootFolder reports whether path is inside the folder dir of its root
+func (s *Service) inRootFolder(path, dir string) bool {
+	root := s.rootFor(path)
+	rel, err := filepath.Rel(filepath.Join(root.Path, dir), path)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// validateFile rejects empty files and, on the real filesystem, files ffprobe
+// cannot read, that have no audio stream it can name, or whose first
+// DecodeCheckSeconds ffmpeg cannot decode. It returns an *invalidFileError
+// for those, and nil when validation is off or the tools are not installed.
+func (s *Service) validateFile(path string, info os.FileInfo) error {
+	if !s.config.DropzoneQuarantine {
+		return nil
+	}
+	if info.Size() == 0 {
+		return &invalidFileError{reason: "file is empty"}
+	}
+	if s.fs != fsys.OS {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, s.ffprobePath,
+		"-v", "error",
+		"-select_streams", "a",
+		"-show_entries", "stream=codec_name",
+		"-of", "csv=p=0",
+		path)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		if errors.Is(err, exec.ErrNotFound) {
+			dzLog.Debug("ffprobe not found, skipping file validation", "path", path)
+			return s.checkDecodable(path)
+		}
+		if ctx.Err() != nil {
+			return fmt.Errorf("ffprobe timed out: %w", ctx.Err())
+		}
+		reason := strings.TrimSpace(stderr.String())
+		if reason == "" {
+			reason = err.Error()
+		}
+		return &invalidFileError{reason: "unreadable audio: " + lastLine(reason)}
+	}
+
+	codec := strings.TrimSpace(strings.SplitN(stdout.String(), "\n", 2)[0])
+	switch codec {
+	case "":
+		return &invalidFileError{reason: "no audio stream found"}
+	case "none", "unknown":
+		return &invalidFileError{reason: "unsupported audio codec"}
+	}
+	return s.checkDecodable(path)
+}
+
+// checkDecodable test-decodes the start of a file, returning an
+// *invalidFileError when it is truncated or corrupt
+func (s *Service) checkDecodable(path string) error {
+	if s.config.DecodeCheckSeconds <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	duration := 0.0
+	if result, err := audio.Probe(ctx, s.ffprobePath, path); err == nil {
+		duration = result.Duration
+	}
+	err := audio.CheckDecodable(ctx, s.ffmpegPath, path, s.config.DecodeCheckSeconds, duration)
+	var decodeErr *audio.DecodeError
+	switch {
+	case errors.As(err, &decodeErr):
+		return &invalidFileError{reason: decodeErr.Error()}
+	case errors.Is(err, exec.ErrNotFound):
+		dzLog.Debug("ffmpeg not found, skipping decode check", "path", path)
+		return nil
+	case err != nil:
+		return fmt.Errorf("decode check failed: %w", err)
+	}
+	return nil
+}
+
+// probeFile stores the duration, sample rate, channels, codec and bit rate
+// of a staged file on its job. On the real filesystem it returns an
+// *invalidFileError when the file's content is not the container its
+// original name claims; files ffprobe cannot read are left to validateFile.
+func (s *Service) probeFile(path, originalFilename string, job *models.TranscriptionJob) error {
+	if s.fs != fsys.OS {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+	defer cancel()
+	result, err := audio.Probe(ctx, s.ffprobePath, path)
+	if err != nil {
+		if !errors.Is(err, exec.ErrNotFound) {
+			dzLog.Debug("Failed to probe file", "file", originalFilename, "error", err)
+		}
+		return nil
+	}
+	if err := result.CheckContainer(originalFilename); err != nil {
+		return &invalidFileError{reason: err.Error()}
+	}
+	result.Apply(job)
+	return nil
+}
+
+// quarantine moves a file that failed validation, and its sidecar, into the
+// root's quarantine folder and writes the reason next to it. source names the
+// file in the explanation, e.g. its dropzone path or "<archive>/<member>".
+func (s *Service) quarantine(root Root, path, source string, cause error) error {
+	dir := filepath.Join(root.Path, QuarantineDir)
+	if err := s.fs.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create quarantine directory: %v", err)
+	}
+
+	// Never overwrite an earlier file of the same name
+	now := s.clock.Now()
+	dest := filepath.Join(dir, filepath.Base(path))
+	if fsys.Exists(s.fs, dest) {
+		dest = filepath.Join(dir, now.UTC().Format("20060102T150405Z")+"-"+filepath.Base(path))
+	}
+	sidecarPath := s.findSidecar(path)
+	if err := s.moveFile(path, dest); err != nil {
+		return fmt.Errorf("failed to move file to quarantine: %v", err)
+	}
+	if sidecarPath != "" {
+		sidecarDest := filepath.Join(dir, strings.TrimSuffix(filepath.Base(dest), filepath.Base(path))+filepath.Base(sidecarPath))
+		if err := s.moveFile(sidecarPath, sidecarDest); err != nil {
+			dzLog.Warn("Failed to move sidecar to quarantine", "path", sidecarPath, "error", err)
+		}
+	}
+
+	report := fmt.Sprintf("file: %s\nquarantined_at: %s\nerror: %s\n", source, now.UTC().Format(time.RFC3339), cause)
+	if err := fsys.WriteFile(s.fs, dest+errorExt, []byte(report)); err != nil {
+		dzLog.Warn("Failed to write quarantine error file", "path", dest+errorExt, "error", err)
+	}
+	dzLog.Warn("Quarantined invalid file", "file", source, "quarantine_path", dest, "reason", cause)
+	return nil
+}
+
+// moveFile renames src to dst, copying it instead when they are on different
+// filesystems, as unpacked archive members and the dropzone may be
+func (s *Service) moveFile(src, dst string) error {
+	if err := s.fs.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := s.fs.Open(src)
+	if err != nil {
+		return err
+	}
+	_, err = fsys.WriteFileAtomic(s.fs, "", dst, in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+	return s.fs.Remove(src)
+}
+
+// lastLine returns the last non-empty line of tool output
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
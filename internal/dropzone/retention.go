@@ -0,0 +1,131 @@
+package dropzone
+
+import (
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"synthezia/pkg/fsys"
+)
+
+// What happens to a dropzone file once it has been ingested
+const (
+	RetentionDelete  = "delete"
+	RetentionArchive = "archive" // Move it to the root's processed folder, under the day it was ingested
+	RetentionKeep    = "keep"    // Leave it in place, next to a marker that stops it being ingested again
+)
+
+// ProcessedDir is the subfolder of each root that ingested files are moved
+// to with the archive retention, in one folder per day named like
+// "2006-01-02". Like the quarantine it is not watched.
+const ProcessedDir = "processed"
+
+// processedDayLayout names the day folders in ProcessedDir
+const processedDayLayout = "2006-01-02"
+
+// markerExt is appended to a kept file's name for its marker
+const markerExt = ".ingested"
+
+// retentionMode returns the configured retention, or an error if it is not
+// one of delete, archive or keep
+func (s *Service) retentionMode() (string, error) {
+	switch s.config.DropzoneRetention {
+	case "", RetentionDelete:
+		return RetentionDelete, nil
+	case RetentionArchive, RetentionKeep:
+		return s.config.DropzoneRetention, nil
+	default:
+		return "", fmt.Errorf("unknown mode %q, expected delete, archive or keep", s.config.DropzoneRetention)
+	}
+}
+
+// isProcessed reports whether path is inside a root's processed folder
+func (s *Service) isProcessed(path string) bool {
+	return s.inRootFolder(path, ProcessedDir)
+}
+
+// hasMarker reports whether path was kept in place after being ingested
+func (s *Service) hasMarker(path string) bool {
+	return fsys.Exists(s.fs, path+markerExt)
+}
+
+// retain applies the retention to an ingested file in root: it is deleted,
+// moved to today's processed folder or marked as ingested
+func (s *Service) retain(root Root, path string) error {
+	mode, _ := s.retentionMode()
+	switch mode {
+	case RetentionArchive:
+		now := s.clock.Now()
+		dir := filepath.Join(root.Path, ProcessedDir, now.Format(processedDayLayout))
+		if err := s.fs.MkdirAll(dir, 0755); err != nil {
+			return fmt.Errorf("failed to create processed directory: %v", err)
+		}
+		// Never overwrite a file of the same name ingested earlier that day
+		dest := filepath.Join(dir, filepath.Base(path))
+		if fsys.Exists(s.fs, dest) {
+			dest = filepath.Join(dir, now.UTC().Format("20060102T150405Z")+"-"+filepath.Base(path))
+		}
+		return s.moveFile(path, dest)
+	case RetentionKeep:
+		marker := fmt.Sprintf("ingested_at: %s\n", s.clock.Now().UTC().Format(time.RFC3339))
+		return fsys.WriteFile(s.fs, path+markerExt, []byte(marker))
+	default:
+		return s.fs.Remove(path)
+	}
+}
+
+// CleanProcessed removes the day folders of each root's processed folder
+// older than DropzoneArchiveDays and returns how many it removed. It does
+// nothing when DropzoneArchiveDays is 0.
+func (s *Service) CleanProcessed() (int, error) {
+	if s.config.DropzoneArchiveDays <= 0 {
+		return 0, nil
+	}
+	now := s.clock.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	cutoff := today.AddDate(0, 0, -s.config.DropzoneArchiveDays)
+
+	removed := 0
+	var firstErr error
+	for _, root := range s.roots {
+		dir := filepath.Join(root.Path, ProcessedDir)
+		entries, err := s.fs.ReadDir(dir)
+		if err != nil {
+			continue // Nothing was archived in this root yet
+		}
+		for _, entry := range entries {
+			// Anything else in the folder was put there by hand and is left alone
+			day, err := time.ParseInLocation(processedDayLayout, entry.Name(), now.Location())
+			if !entry.IsDir() || err != nil || !day.Before(cutoff) {
+				continue
+			}
+			path := filepath.Join(dir, entry.Name())
+			if err := s.fs.RemoveAll(path); err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				continue
+			}
+			dzLog.Info("Removed expired processed files", "path", path)
+			removed++
+		}
+	}
+	return removed, firstErr
+}
+
+// RunProcessedCleanup removes expired processed folders straight away and
+// then every interval until stop is closed
+func (s *Service) RunProcessedCleanup(stop <-chan struct{}, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.CleanProcessed(); err != nil {
+			dzLog.Warn("Failed to clean up processed files", "error", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,108 @@
+package dropzone
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// DefaultRoot is watched when DROPZONE_PATHS is empty
+var DefaultRoot = filepath.Join("data", "dropzone")
+
+// Root is one watched directory and the defaults for files dropped into it
+type Root struct {
+	Path string
+	// User whose settings (default profile, auto-transcription, source audio
+	// action) apply to jobs from this root; empty means none
+	User string
+	// Language passed to transcription; empty lets the model detect it
+	Language string
+	// AutoTranscribe overrides whether jobs are queued straight away; nil
+	// follows the user's setting, or any user's when no user is set
+	AutoTranscribe *bool
+	// Folder is the profile of the subfolder a file was dropped into, from
+	// DROPZONE_PROFILES; nil when it has none
+	Folder *FolderProfile
+}
+
+// ParseRoots parses DROPZONE_PATHS: roots separated by commas, each a path
+// optionally followed by ";key=value" options, e.g.
+// "/mnt/sales;user=alice;language=en,/mnt/legal;auto_transcribe=false".
+// An empty spec returns DefaultRoot.
+func ParseRoots(spec string) ([]Root, error) {
+	if strings.TrimSpace(spec) == "" {
+		return []Root{{Path: DefaultRoot}}, nil
+	}
+	var roots []Root
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ";")
+		root := Root{Path: filepath.Clean(strings.TrimSpace(parts[0]))}
+		if strings.TrimSpace(parts[0]) == "" {
+			return nil, fmt.Errorf("invalid dropzone root %q: missing path", entry)
+		}
+		if seen[root.Path] {
+			return nil, fmt.Errorf("dropzone root %q is listed twice", root.Path)
+		}
+		seen[root.Path] = true
+
+		if err := parseRootOptions(&root, parts[1:]); err != nil {
+			return nil, err
+		}
+		roots = append(roots, root)
+	}
+	if len(roots) == 0 {
+		return []Root{{Path: DefaultRoot}}, nil
+	}
+	return roots, nil
+}
+
+// parseRootOptions applies "key=value" options to root
+func parseRootOptions(root *Root, options []string) error {
+	for _, option := range options {
+		key, value, ok := strings.Cut(option, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return fmt.Errorf("invalid dropzone option %q for %s: expected key=value", option, root.Path)
+		}
+		switch key {
+		case "user":
+			root.User = value
+		case "language":
+			root.Language = value
+		case "auto_transcribe":
+			auto, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid auto_transcribe for %s: %q is not true or false", root.Path, value)
+			}
+			root.AutoTranscribe = &auto
+		default:
+			return fmt.Errorf("unknown dropzone option %q for %s", key, root.Path)
+		}
+	}
+	return nil
+}
+
+// rootFor returns the root a dropped file belongs to, preferring the deepest
+// one when roots are nested
+func (s *Service) rootFor(path string) Root {
+	best := -1
+	for i, root := range s.roots {
+		rel, err := filepath.Rel(root.Path, path)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			continue
+		}
+		if best < 0 || len(root.Path) > len(s.roots[best].Path) {
+			best = i
+		}
+	}
+	if best < 0 {
+		return Root{Path: filepath.Dir(path)}
+	}
+	return s.roots[best]
+}
@@ -0,0 +1,631 @@
+package dropzone
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"synthezia/internal/auth"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+
+	"github.com/google/uuid"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP packet types and status codes, protocol version 3
+// (draft-ietf-secsh-filexfer-02), which every client speaks
+const (
+	sftpInit     = 1
+	sftpVersion  = 2
+	sftpOpen     = 3
+	sftpClose    = 4
+	sftpWrite    = 6
+	sftpLstat    = 7
+	sftpFstat    = 8
+	sftpSetstat  = 9
+	sftpFsetstat = 10
+	sftpOpendir  = 11
+	sftpReaddir  = 12
+	sftpRemove   = 13
+	sftpMkdir    = 14
+	sftpRmdir    = 15
+	sftpRealpath = 16
+	sftpStat     = 17
+	sftpRename   = 18
+	sftpStatus   = 101
+	sftpHandle   = 102
+	sftpName     = 104
+	sftpAttrs    = 105
+
+	sftpOK               = 0
+	sftpEOF              = 1
+	sftpNoSuchFile       = 2
+	sftpPermissionDenied = 3
+	sftpFailure          = 4
+	sftpBadMessage       = 5
+	sftpOpUnsupported    = 8
+
+	sftpFlagWrite       = 0x02
+	sftpAttrSize        = 0x01
+	sftpAttrPermissions = 0x04
+)
+
+// sftpMaxPacket bounds a request; clients write in chunks of 32-256 KiB
+const sftpMaxPacket = 4 << 20
+
+// SFTPServer is an embedded SFTP server for devices, such as field
+// recorders, that can push recordings over SFTP but cannot reach a shared
+// folder. Users log in with their username and password, and each audio file
+// they upload becomes one of their jobs once it is closed, so the client
+// learns whether the ingest worked. The server shows an empty, write-only
+// tree in which any path without an extension is a directory; uploads cannot
+// be listed or read back.
+type SFTPServer struct {
+	service  *Service
+	config   *ssh.ServerConfig
+	defaults Root
+
+	mu       sync.Mutex
+	listener net.Listener
+	conns    map[net.Conn]bool
+	closed   bool
+	wg       sync.WaitGroup
+}
+
+// NewSFTPServer creates a server ingesting through service. options takes
+// the language and auto_transcribe options of a dropzone root; the user is
+// whoever logs in.
+func NewSFTPServer(service *Service, hostKey ssh.Signer, options string) (*SFTPServer, error) {
+	defaults := Root{Path: "sftp://"}
+	if strings.TrimSpace(options) != "" {
+		if err := parseRootOptions(&defaults, strings.Split(options, ";")); err != nil {
+			return nil, fmt.Errorf("invalid DROPZONE_SFTP_OPTIONS: %v", err)
+		}
+		if defaults.User != "" {
+			return nil, errors.New("invalid DROPZONE_SFTP_OPTIONS: the user is whoever logs in and cannot be set")
+		}
+	}
+	if _, err := service.dedupeMode(); err != nil {
+		return nil, fmt.Errorf("invalid DROPZONE_DEDUPE: %v", err)
+	}
+
+	srv := &SFTPServer{
+		service:  service,
+		defaults: defaults,
+		conns:    make(map[net.Conn]bool),
+	}
+	srv.config = &ssh.ServerConfig{PasswordCallback: srv.checkPassword}
+	srv.config.AddHostKey(hostKey)
+	return srv, nil
+}
+
+// LoadHostKey reads the server's private key from path, creating an Ed25519
+// key there on first use so clients see the same host key after a restart
+func LoadHostKey(path string) (ssh.Signer, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		_, private, genErr := ed25519.GenerateKey(rand.Reader)
+		if genErr != nil {
+			return nil, genErr
+		}
+		block, genErr := ssh.MarshalPrivateKey(private, "synthezia dropzone")
+		if genErr != nil {
+			return nil, genErr
+		}
+		data = pem.EncodeToMemory(block)
+		if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+			return nil, fmt.Errorf("failed to create host key directory: %v", err)
+		}
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			return nil, fmt.Errorf("failed to save host key: %v", err)
+		}
+		dzLog.Info("Generated SFTP host key", "path", path)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read host key: %v", err)
+	}
+	return ssh.ParsePrivateKey(data)
+}
+
+// checkPassword accepts a user's own login
+func (srv *SFTPServer) checkPassword(meta ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+	var user models.User
+	err := database.DB.Where("username = ?", meta.User()).First(&user).Error
+	if err != nil || !auth.CheckPassword(string(password), user.Password) {
+		dzLog.Warn("SFTP login failed", "user", meta.User(), "remote", meta.RemoteAddr().String())
+		return nil, errors.New("invalid username or password")
+	}
+	return nil, nil
+}
+
+// Serve accepts connections on l until Close is called
+func (srv *SFTPServer) Serve(l net.Listener) error {
+	srv.mu.Lock()
+	srv.listener = l
+	srv.mu.Unlock()
+	dzLog.Info("SFTP server listening", "address", l.Addr().String())
+
+	for {
+		conn, err := l.Accept()
+		if err != nil {
+			srv.mu.Lock()
+			closed := srv.closed
+			srv.mu.Unlock()
+			if closed {
+				return nil
+			}
+			return err
+		}
+		srv.mu.Lock()
+		srv.conns[conn] = true
+		srv.mu.Unlock()
+		srv.wg.Add(1)
+		go func() {
+			defer srv.wg.Done()
+			srv.handleConn(conn)
+			srv.mu.Lock()
+			delete(srv.conns, conn)
+			srv.mu.Unlock()
+		}()
+	}
+}
+
+// Close stops accepting connections, drops open ones and waits for their
+// sessions to clean up
+func (srv *SFTPServer) Close() error {
+	srv.mu.Lock()
+	srv.closed = true
+	var err error
+	if srv.listener != nil {
+		err = srv.listener.Close()
+	}
+	for conn := range srv.conns {
+		conn.Close()
+	}
+	srv.mu.Unlock()
+	srv.wg.Wait()
+	return err
+}
+
+func (srv *SFTPServer) handleConn(conn net.Conn) {
+	sshConn, channels, requests, err := ssh.NewServerConn(conn, srv.config)
+	if err != nil {
+		dzLog.Debug("SFTP handshake failed", "remote", conn.RemoteAddr().String(), "error", err)
+		conn.Close()
+		return
+	}
+	defer sshConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	var sessions sync.WaitGroup
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "session" {
+			newChannel.Reject(ssh.UnknownChannelType, "only sessions are supported")
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			continue
+		}
+		sessions.Add(1)
+		go func() {
+			defer sessions.Done()
+			srv.handleSession(sshConn.User(), channel, channelRequests)
+		}()
+	}
+	sessions.Wait()
+}
+
+// handleSession runs the sftp subsystem, refusing shells and commands
+func (srv *SFTPServer) handleSession(username string, channel ssh.Channel, requests <-chan *ssh.Request) {
+	defer channel.Close()
+	for req := range requests {
+		payload := &sftpReader{data: req.Payload}
+		if req.Type != "subsystem" || payload.string() != "sftp" || payload.err != nil {
+			req.Reply(false, nil)
+			continue
+		}
+		req.Reply(true, nil)
+		go ssh.DiscardRequests(requests)
+
+		session := &sftpSession{
+			srv:     srv,
+			user:    username,
+			rw:      channel,
+			handles: make(map[string]*sftpFile),
+			pending: make(map[string]string),
+		}
+		dzLog.Debug("SFTP session started", "user", username)
+		if err := session.serve(); err != nil && !errors.Is(err, io.EOF) {
+			dzLog.Debug("SFTP session ended", "user", username, "error", err)
+		}
+		session.cleanup()
+		return
+	}
+}
+
+// ingest creates a job for user from an upload staged at stagedPath
+func (srv *SFTPServer) ingest(user, stagedPath, filename string) error {
+	service := srv.service
+	info, err := service.fs.Stat(stagedPath)
+	if err != nil {
+		return err
+	}
+	if err := service.validateFile(stagedPath, info); err != nil {
+		dzLog.Warn("Rejected invalid SFTP upload", "user", user, "file", filename, "reason", err)
+		return err
+	}
+	f, err := service.fs.Open(stagedPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	root := srv.defaults
+	root.Path = "sftp://" + user
+	root.User = user
+	_, err = service.ingest(f, filename, root, nil, "source", "sftp", "user", user, "file", filename)
+	return err
+}
+
+// sftpFile is an open directory, or an upload being written to a staged file
+type sftpFile struct {
+	path   string
+	file   fsys.File
+	staged string
+}
+
+// sftpSession serves one client's requests in order
+type sftpSession struct {
+	srv     *SFTPServer
+	user    string
+	rw      io.ReadWriter
+	handles map[string]*sftpFile
+	// Closed uploads whose name is not audio yet, e.g. "take1.wav.filepart",
+	// waiting to be renamed; path -> staged file
+	pending    map[string]string
+	nextHandle int
+}
+
+func (ss *sftpSession) serve() error {
+	for {
+		packetType, data, err := ss.readPacket()
+		if err != nil {
+			return err
+		}
+		if packetType == sftpInit {
+			if err := ss.send(sftpVersion, binary.BigEndian.AppendUint32(nil, 3)); err != nil {
+				return err
+			}
+			continue
+		}
+		r := &sftpReader{data: data}
+		id := r.uint32()
+		if err := ss.dispatch(packetType, id, r); err != nil {
+			return err
+		}
+	}
+}
+
+func (ss *sftpSession) dispatch(packetType byte, id uint32, r *sftpReader) error {
+	switch packetType {
+	case sftpRealpath:
+		p := cleanSFTPPath(r.string())
+		if r.err != nil {
+			break
+		}
+		return ss.sendName(id, p, dirAttrs())
+	case sftpStat, sftpLstat:
+		p := cleanSFTPPath(r.string())
+		if r.err != nil {
+			break
+		}
+		return ss.stat(id, p)
+	case sftpFstat:
+		h := ss.handles[r.string()]
+		if r.err != nil {
+			break
+		}
+		if h == nil {
+			return ss.sendStatus(id, sftpFailure, "invalid handle")
+		}
+		if h.file == nil {
+			return ss.send(sftpAttrs, appendUint32(nil, id), dirAttrs()...)
+		}
+		info, err := h.file.Stat()
+		if err != nil {
+			return ss.sendStatus(id, sftpFailure, err.Error())
+		}
+		return ss.send(sftpAttrs, appendUint32(nil, id), fileAttrs(info.Size())...)
+	case sftpOpendir:
+		p := cleanSFTPPath(r.string())
+		if r.err != nil {
+			break
+		}
+		if !isSFTPDir(p) {
+			return ss.sendStatus(id, sftpNoSuchFile, "no such directory")
+		}
+		return ss.sendHandle(id, &sftpFile{path: p})
+	case sftpReaddir:
+		// Uploads are not listed, so every directory looks empty
+		r.string()
+		if r.err != nil {
+			break
+		}
+		return ss.sendStatus(id, sftpEOF, "end of directory")
+	case sftpOpen:
+		p := cleanSFTPPath(r.string())
+		flags := r.uint32()
+		if r.err != nil {
+			break
+		}
+		return ss.open(id, p, flags)
+	case sftpWrite:
+		h := ss.handles[r.string()]
+		offset := r.uint64()
+		data := r.string()
+		if r.err != nil {
+			break
+		}
+		if h == nil || h.file == nil {
+			return ss.sendStatus(id, sftpFailure, "invalid handle")
+		}
+		if _, err := h.file.Seek(int64(offset), io.SeekStart); err != nil {
+			return ss.sendStatus(id, sftpFailure, err.Error())
+		}
+		if _, err := io.WriteString(h.file, data); err != nil {
+			return ss.sendStatus(id, sftpFailure, err.Error())
+		}
+		return ss.sendStatus(id, sftpOK, "")
+	case sftpClose:
+		handle := r.string()
+		if r.err != nil {
+			break
+		}
+		return ss.close(id, handle)
+	case sftpRename:
+		from, to := cleanSFTPPath(r.string()), cleanSFTPPath(r.string())
+		if r.err != nil {
+			break
+		}
+		staged, ok := ss.pending[from]
+		if !ok {
+			return ss.sendStatus(id, sftpNoSuchFile, "no such file")
+		}
+		delete(ss.pending, from)
+		if replaced, ok := ss.pending[to]; ok {
+			ss.srv.service.fs.Remove(replaced)
+		}
+		ss.pending[to] = staged
+		return ss.finish(id, to)
+	case sftpRemove:
+		p := cleanSFTPPath(r.string())
+		if r.err != nil {
+			break
+		}
+		staged, ok := ss.pending[p]
+		if !ok {
+			return ss.sendStatus(id, sftpNoSuchFile, "no such file")
+		}
+		delete(ss.pending, p)
+		ss.srv.service.fs.Remove(staged)
+		return ss.sendStatus(id, sftpOK, "")
+	case sftpSetstat, sftpFsetstat, sftpMkdir, sftpRmdir:
+		// Times, permissions and directories have no meaning here; accepting
+		// them keeps clients that set them after an upload from failing
+		return ss.sendStatus(id, sftpOK, "")
+	default:
+		return ss.sendStatus(id, sftpOpUnsupported, "operation not supported")
+	}
+	return ss.sendStatus(id, sftpBadMessage, "malformed request")
+}
+
+func (ss *sftpSession) stat(id uint32, p string) error {
+	if staged, ok := ss.pending[p]; ok {
+		info, err := ss.srv.service.fs.Stat(staged)
+		if err != nil {
+			return ss.sendStatus(id, sftpFailure, err.Error())
+		}
+		return ss.send(sftpAttrs, appendUint32(nil, id), fileAttrs(info.Size())...)
+	}
+	if isSFTPDir(p) {
+		return ss.send(sftpAttrs, appendUint32(nil, id), dirAttrs()...)
+	}
+	return ss.sendStatus(id, sftpNoSuchFile, "no such file")
+}
+
+// open starts an upload into a staged file. Uploading to a name again starts over.
+func (ss *sftpSession) open(id uint32, p string, flags uint32) error {
+	if flags&sftpFlagWrite == 0 {
+		return ss.sendStatus(id, sftpPermissionDenied, "uploads cannot be read back")
+	}
+	service := ss.srv.service
+	if staged, ok := ss.pending[p]; ok {
+		delete(ss.pending, p)
+		service.fs.Remove(staged)
+	}
+	if err := service.fs.MkdirAll(service.stagingDir(), 0755); err != nil {
+		return ss.sendStatus(id, sftpFailure, "failed to create staging directory")
+	}
+	staged := filepath.Join(service.stagingDir(), stagedPrefix+"sftp-"+uuid.New().String()+".part")
+	file, err := service.fs.Create(staged)
+	if err != nil {
+		return ss.sendStatus(id, sftpFailure, "failed to start upload")
+	}
+	return ss.sendHandle(id, &sftpFile{path: p, file: file, staged: staged})
+}
+
+// close ends an upload, ingesting it if it is named as audio
+func (ss *sftpSession) close(id uint32, handle string) error {
+	h := ss.handles[handle]
+	if h == nil {
+		return ss.sendStatus(id, sftpFailure, "invalid handle")
+	}
+	delete(ss.handles, handle)
+	if h.file == nil {
+		return ss.sendStatus(id, sftpOK, "")
+	}
+	if err := h.file.Close(); err != nil {
+		ss.srv.service.fs.Remove(h.staged)
+		return ss.sendStatus(id, sftpFailure, err.Error())
+	}
+	ss.pending[h.path] = h.staged
+	return ss.finish(id, h.path)
+}
+
+// finish ingests the pending upload at p once its name is audio; other
+// names stay pending until renamed, or are dropped with the session
+func (ss *sftpSession) finish(id uint32, p string) error {
+	filename := path.Base(p)
+	if !ss.srv.service.isAudioFile(filename) {
+		return ss.sendStatus(id, sftpOK, "")
+	}
+	staged := ss.pending[p]
+	delete(ss.pending, p)
+	defer ss.srv.service.fs.Remove(staged)
+
+	if err := ss.srv.ingest(ss.user, staged, filename); err != nil {
+		var invalid *invalidFileError
+		if !errors.As(err, &invalid) {
+			dzLog.Error("Failed to ingest SFTP upload", "user", ss.user, "file", filename, "error", err)
+		}
+		return ss.sendStatus(id, sftpFailure, err.Error())
+	}
+	return ss.sendStatus(id, sftpOK, "")
+}
+
+// cleanup discards uploads the client did not finish
+func (ss *sftpSession) cleanup() {
+	fs := ss.srv.service.fs
+	for _, h := range ss.handles {
+		if h.file != nil {
+			h.file.Close()
+			fs.Remove(h.staged)
+		}
+	}
+	for p, staged := range ss.pending {
+		dzLog.Debug("Discarding unfinished SFTP upload", "user", ss.user, "path", p)
+		fs.Remove(staged)
+	}
+}
+
+func (ss *sftpSession) readPacket() (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(ss.rw, header[:]); err != nil {
+		return 0, nil, err
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > sftpMaxPacket {
+		return 0, nil, fmt.Errorf("invalid packet length %d", length)
+	}
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(ss.rw, packet); err != nil {
+		return 0, nil, err
+	}
+	return packet[0], packet[1:], nil
+}
+
+func (ss *sftpSession) send(packetType byte, payload []byte, more ...byte) error {
+	packet := binary.BigEndian.AppendUint32(nil, uint32(1+len(payload)+len(more)))
+	packet = append(packet, packetType)
+	packet = append(packet, payload...)
+	packet = append(packet, more...)
+	_, err := ss.rw.Write(packet)
+	return err
+}
+
+func (ss *sftpSession) sendStatus(id uint32, code uint32, message string) error {
+	payload := appendUint32(appendUint32(nil, id), code)
+	payload = appendString(appendString(payload, message), "en")
+	return ss.send(sftpStatus, payload)
+}
+
+func (ss *sftpSession) sendHandle(id uint32, h *sftpFile) error {
+	ss.nextHandle++
+	handle := strconv.Itoa(ss.nextHandle)
+	ss.handles[handle] = h
+	return ss.send(sftpHandle, appendString(appendUint32(nil, id), handle))
+}
+
+func (ss *sftpSession) sendName(id uint32, name string, attrs []byte) error {
+	payload := appendUint32(appendUint32(nil, id), 1)
+	payload = appendString(appendString(payload, name), name)
+	return ss.send(sftpName, payload, attrs...)
+}
+
+// cleanSFTPPath makes a client path absolute, so "x.wav" and "/x.wav" match
+func cleanSFTPPath(p string) string {
+	return path.Clean("/" + p)
+}
+
+// isSFTPDir reports whether p is a directory of the virtual tree
+func isSFTPDir(p string) bool {
+	return path.Ext(p) == ""
+}
+
+func fileAttrs(size int64) []byte {
+	attrs := appendUint32(nil, sftpAttrSize|sftpAttrPermissions)
+	attrs = binary.BigEndian.AppendUint64(attrs, uint64(size))
+	return appendUint32(attrs, 0100644)
+}
+
+func dirAttrs() []byte {
+	return appendUint32(appendUint32(nil, sftpAttrPermissions), 040755)
+}
+
+func appendUint32(b []byte, v uint32) []byte {
+	return binary.BigEndian.AppendUint32(b, v)
+}
+
+func appendString(b []byte, s string) []byte {
+	return append(appendUint32(b, uint32(len(s))), s...)
+}
+
+// sftpReader decodes request fields, remembering the first error
+type sftpReader struct {
+	data []byte
+	err  error
+}
+
+func (r *sftpReader) uint32() uint32 {
+	if len(r.data) < 4 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint32(r.data)
+	r.data = r.data[4:]
+	return v
+}
+
+func (r *sftpReader) uint64() uint64 {
+	if len(r.data) < 8 {
+		r.err = io.ErrUnexpectedEOF
+		return 0
+	}
+	v := binary.BigEndian.Uint64(r.data)
+	r.data = r.data[8:]
+	return v
+}
+
+func (r *sftpReader) string() string {
+	n := r.uint32()
+	if r.err != nil || uint32(len(r.data)) < n {
+		r.err = io.ErrUnexpectedEOF
+		return ""
+	}
+	s := string(r.data[:n])
+	r.data = r.data[n:]
+	return s
+}
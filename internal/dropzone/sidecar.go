@@ -0,0 +1,172 @@
+package dropzone
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+
+	"gopkg.in/yaml.v3"
+)
+
+// sidecarExtensions are the formats a sidecar may be written in
+var sidecarExtensions = []string{".json", ".yaml", ".yml"}
+
+// Sidecar holds job settings dropped next to an audio file as
+// "<audio>.json" or "<audio>.yaml", e.g. meeting.mp3.json or meeting.yaml.
+// Fields left out keep their defaults.
+type Sidecar struct {
+	Title       *string  `json:"title" yaml:"title"`
+	Language    *string  `json:"language" yaml:"language"`
+	Speakers    *int     `json:"speakers" yaml:"speakers"` // Exact speaker count; turns on diarization
+	MinSpeakers *int     `json:"min_speakers" yaml:"min_speakers"`
+	MaxSpeakers *int     `json:"max_speakers" yaml:"max_speakers"`
+	Model       *string  `json:"model" yaml:"model"`
+	Tags        []string `json:"tags" yaml:"tags"`
+}
+
+// ParseSidecar decodes a sidecar, choosing JSON or YAML from the file name.
+// Unknown fields are rejected so typos do not go unnoticed.
+func ParseSidecar(name string, data []byte) (*Sidecar, error) {
+	var sidecar Sidecar
+	switch strings.ToLower(filepath.Ext(name)) {
+	case ".json":
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&sidecar); err != nil {
+			return nil, fmt.Errorf("invalid sidecar %s: %v", filepath.Base(name), err)
+		}
+	case ".yaml", ".yml":
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&sidecar); err != nil {
+			return nil, fmt.Errorf("invalid sidecar %s: %v", filepath.Base(name), err)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported sidecar format: %s", filepath.Base(name))
+	}
+
+	for field, value := range map[string]*int{"speakers": sidecar.Speakers, "min_speakers": sidecar.MinSpeakers, "max_speakers": sidecar.MaxSpeakers} {
+		if value != nil && *value < 1 {
+			return nil, fmt.Errorf("invalid sidecar %s: %s must be at least 1", filepath.Base(name), field)
+		}
+	}
+	if sidecar.MinSpeakers != nil && sidecar.MaxSpeakers != nil && *sidecar.MinSpeakers > *sidecar.MaxSpeakers {
+		return nil, fmt.Errorf("invalid sidecar %s: min_speakers is greater than max_speakers", filepath.Base(name))
+	}
+	return &sidecar, nil
+}
+
+// Apply copies the sidecar's settings into a job, overriding embedded tags
+// and root defaults
+func (sc *Sidecar) Apply(job *models.TranscriptionJob) {
+	if sc.Title != nil && strings.TrimSpace(*sc.Title) != "" {
+		title := strings.TrimSpace(*sc.Title)
+		job.Title = &title
+	}
+	if sc.Language != nil && *sc.Language != "" {
+		language := *sc.Language
+		job.Parameters.Language = &language
+	}
+	if sc.Model != nil && *sc.Model != "" {
+		job.Parameters.Model = *sc.Model
+	}
+	if sc.Speakers != nil {
+		speakers := *sc.Speakers
+		job.Parameters.MinSpeakers = &speakers
+		job.Parameters.MaxSpeakers = &speakers
+	}
+	if sc.MinSpeakers != nil {
+		minSpeakers := *sc.MinSpeakers
+		job.Parameters.MinSpeakers = &minSpeakers
+	}
+	if sc.MaxSpeakers != nil {
+		maxSpeakers := *sc.MaxSpeakers
+		job.Parameters.MaxSpeakers = &maxSpeakers
+	}
+	if sc.Speakers != nil || sc.MinSpeakers != nil || sc.MaxSpeakers != nil {
+		job.Parameters.Diarize = true
+		job.Diarization = true
+	}
+	if len(sc.Tags) > 0 {
+		if data, err := json.Marshal(sc.Tags); err == nil {
+			encoded := string(data)
+			job.Tags = &encoded
+		}
+	}
+}
+
+// sidecarCandidates lists where the sidecar of audioPath may be, in order
+func sidecarCandidates(audioPath string) []string {
+	stem := strings.TrimSuffix(audioPath, filepath.Ext(audioPath))
+	var candidates []string
+	for _, base := range []string{audioPath, stem} {
+		for _, ext := range sidecarExtensions {
+			candidates = append(candidates, base+ext)
+		}
+	}
+	return candidates
+}
+
+// findSidecar returns the path of audioPath's sidecar, or "" if it has none
+func (s *Service) findSidecar(audioPath string) string {
+	for _, candidate := range sidecarCandidates(audioPath) {
+		if fsys.Exists(s.fs, candidate) {
+			return candidate
+		}
+	}
+	return ""
+}
+
+// loadSidecar reads and parses audioPath's sidecar; it returns nil and no
+// error when there is none
+func (s *Service) loadSidecar(audioPath string) (*Sidecar, string, error) {
+	path := s.findSidecar(audioPath)
+	if path == "" {
+		return nil, "", nil
+	}
+	data, err := fsys.ReadFile(s.fs, path)
+	if err != nil {
+		return nil, path, fmt.Errorf("failed to read sidecar: %v", err)
+	}
+	sidecar, err := ParseSidecar(path, data)
+	return sidecar, path, err
+}
+
+// isSidecarFile reports whether filename could be a sidecar
+func isSidecarFile(filename string) bool {
+	ext := strings.ToLower(filepath.Ext(filename))
+	for _, sidecarExt := range sidecarExtensions {
+		if ext == sidecarExt {
+			return true
+		}
+	}
+	return false
+}
+
+// audioForSidecar returns the audio file waiting in the dropzone that a
+// sidecar belongs to, or "" if there is none
+func (s *Service) audioForSidecar(sidecarPath string) string {
+	base := strings.TrimSuffix(sidecarPath, filepath.Ext(sidecarPath))
+	if s.isAudioFile(base) && fsys.Exists(s.fs, base) {
+		return base
+	}
+	entries, err := s.fs.ReadDir(filepath.Dir(sidecarPath))
+	if err != nil {
+		return ""
+	}
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !s.isAudioFile(name) {
+			continue
+		}
+		if strings.TrimSuffix(name, filepath.Ext(name)) == filepath.Base(base) {
+			return filepath.Join(filepath.Dir(sidecarPath), name)
+		}
+	}
+	return ""
+}
@@ -0,0 +1,144 @@
+package dropzone
+
+import (
+	"errors"
+	"sort"
+	"sync"
+	"time"
+)
+
+// Ingest states of a detected file
+const (
+	StateWaiting    = "waiting"     // Settling, held back by a full queue or missing project tracks
+	StateUploading  = "uploading"   // Being copied into the uploads
+	StateJobCreated = "job-created" // Ingested, or discarded as a duplicate
+	StateFailed     = "failed"      // Rejected, quarantined or removed before it was ingested
+)
+
+// maxStatusEntries bounds how many files the status remembers
+const maxStatusEntries = 500
+
+// FileStatus is what happened to a file the dropzone detected
+type FileStatus struct {
+	Path       string    `json:"path"`
+	State      string    `json:"state"`
+	Detail     string    `json:"detail,omitempty"` // What a waiting file waits for, or why no job was created
+	JobID      string    `json:"job_id,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	DetectedAt time.Time `json:"detected_at"`
+	UpdatedAt  time.Time `json:"updated_at"`
+}
+
+// statusLog remembers the latest state of recently detected files
+type statusLog struct {
+	mu    sync.Mutex
+	files map[string]*FileStatus
+}
+
+// setState records that path is now in state. A file detected again after
+// it was ingested or failed starts a fresh entry.
+func (s *Service) setState(path, state, detail string) {
+	now := s.clock.Now()
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	if s.status.files == nil {
+		s.status.files = make(map[string]*FileStatus)
+	}
+	entry := s.status.files[path]
+	if entry == nil || (state == StateWaiting && (entry.State == StateJobCreated || entry.State == StateFailed)) {
+		entry = &FileStatus{Path: path, DetectedAt: now}
+		s.status.files[path] = entry
+		s.pruneStatusLocked()
+	}
+	entry.State, entry.Detail, entry.Error, entry.UpdatedAt = state, detail, "", now
+}
+
+// setJob records that a job was created for path; an empty jobID means the
+// file was discarded as a duplicate
+func (s *Service) setJob(path, jobID string) {
+	detail := ""
+	if jobID == "" {
+		detail = "skipped as a duplicate"
+	}
+	s.setState(path, StateJobCreated, detail)
+	s.status.mu.Lock()
+	s.status.files[path].JobID = jobID
+	s.status.mu.Unlock()
+}
+
+// setFailed records why path was not ingested
+func (s *Service) setFailed(path string, err error) {
+	detail := "left in place to be retried"
+	var invalid *invalidFileError
+	if errors.As(err, &invalid) && s.config.DropzoneQuarantine {
+		detail = "quarantined"
+	}
+	s.setState(path, StateFailed, detail)
+	s.status.mu.Lock()
+	s.status.files[path].Error = err.Error()
+	s.status.mu.Unlock()
+}
+
+// setRemoved marks a file that disappeared while it waited as failed, unless
+// it was already ingested, e.g. as the track of a project
+func (s *Service) setRemoved(path string) {
+	s.status.mu.Lock()
+	entry := s.status.files[path]
+	waiting := entry != nil && entry.State == StateWaiting
+	s.status.mu.Unlock()
+	if waiting {
+		s.setFailed(path, errors.New("removed before it was ingested"))
+	}
+}
+
+// forgetStatus drops the entry for a path that turned out not to be a file
+func (s *Service) forgetStatus(path string) {
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	delete(s.status.files, path)
+}
+
+// pruneStatusLocked forgets the least recently updated files beyond
+// maxStatusEntries, keeping those still in progress where possible
+func (s *Service) pruneStatusLocked() {
+	if len(s.status.files) <= maxStatusEntries {
+		return
+	}
+	entries := make([]*FileStatus, 0, len(s.status.files))
+	for _, entry := range s.status.files {
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		iDone := entries[i].State == StateJobCreated || entries[i].State == StateFailed
+		jDone := entries[j].State == StateJobCreated || entries[j].State == StateFailed
+		if iDone != jDone {
+			return iDone
+		}
+		return entries[i].UpdatedAt.Before(entries[j].UpdatedAt)
+	})
+	for _, entry := range entries[:len(entries)-maxStatusEntries] {
+		delete(s.status.files, entry.Path)
+	}
+}
+
+// Status returns the recently detected files, most recently updated first
+func (s *Service) Status() []FileStatus {
+	s.status.mu.Lock()
+	defer s.status.mu.Unlock()
+	files := make([]FileStatus, 0, len(s.status.files))
+	for _, entry := range s.status.files {
+		files = append(files, *entry)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].UpdatedAt.Equal(files[j].UpdatedAt) {
+			return files[i].UpdatedAt.After(files[j].UpdatedAt)
+		}
+		return files[i].Path < files[j].Path
+	})
+	return files
+}
+
+// Paused reports whether ingest is held back by a full transcription queue
+func (s *Service) Paused() bool {
+	return s.paused.Load()
+}
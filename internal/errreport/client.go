@@ -0,0 +1,262 @@
+package errreport
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+const (
+	defaultEnvironment = "production"
+	eventQueueSize     = 256
+	clientName         = "synthezia-errreport/1.0"
+)
+
+// Event levels
+const (
+	levelError = "error"
+	levelFatal = "fatal"
+)
+
+// dsn is a parsed Sentry DSN
+type dsn struct {
+	scheme    string
+	publicKey string
+	host      string
+	path      string
+	projectID string
+}
+
+// parseDSN parses scheme://public_key@host[:port][/path]/project_id
+func parseDSN(raw string) (dsn, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return dsn{}, fmt.Errorf("invalid error reporting DSN: %w", err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return dsn{}, fmt.Errorf("invalid error reporting DSN %q: scheme must be http or https", raw)
+	}
+	if u.User == nil || u.User.Username() == "" {
+		return dsn{}, fmt.Errorf("invalid error reporting DSN %q: missing public key", raw)
+	}
+	path := strings.TrimRight(u.Path, "/")
+	slash := strings.LastIndex(path, "/")
+	projectID := path[slash+1:]
+	if u.Host == "" || projectID == "" {
+		return dsn{}, fmt.Errorf("invalid error reporting DSN %q: expected %s://<key>@<host>/<project>", raw, u.Scheme)
+	}
+	return dsn{
+		scheme:    u.Scheme,
+		publicKey: u.User.Username(),
+		host:      u.Host,
+		path:      path[:slash],
+		projectID: projectID,
+	}, nil
+}
+
+// storeURL is the endpoint events are posted to
+func (d dsn) storeURL() string {
+	return fmt.Sprintf("%s://%s%s/api/%s/store/", d.scheme, d.host, d.path, d.projectID)
+}
+
+// authHeader is the X-Sentry-Auth header value
+func (d dsn) authHeader() string {
+	return fmt.Sprintf("Sentry sentry_version=7, sentry_client=%s, sentry_key=%s", clientName, d.publicKey)
+}
+
+// Client sends events to a Sentry-compatible store endpoint in the background
+type Client struct {
+	dsn         dsn
+	environment string
+	release     string
+	serverName  string
+	http        *http.Client
+
+	events  chan *event
+	flushCh chan chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewClient creates and starts a client
+func NewClient(cfg Config) (*Client, error) {
+	parsed, err := parseDSN(cfg.DSN)
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{
+		dsn:         parsed,
+		environment: cfg.Environment,
+		release:     cfg.Release,
+		serverName:  cfg.ServerName,
+		http:        &http.Client{Timeout: 10 * time.Second},
+		events:      make(chan *event, eventQueueSize),
+		flushCh:     make(chan chan struct{}),
+		done:        make(chan struct{}),
+	}
+	if c.environment == "" {
+		c.environment = defaultEnvironment
+	}
+	if c.serverName == "" {
+		c.serverName = defaultServerName()
+	}
+
+	c.wg.Add(1)
+	go c.run()
+	return c, nil
+}
+
+// enqueue hands an event to the send loop, dropping it if the queue is full
+func (c *Client) enqueue(e *event) {
+	select {
+	case c.events <- e:
+	default:
+		logger.Warn("Dropping error report, queue full", "event_id", e.EventID)
+	}
+}
+
+// Flush sends all queued events
+func (c *Client) Flush() {
+	reply := make(chan struct{})
+	select {
+	case c.flushCh <- reply:
+		<-reply
+	case <-c.done:
+	}
+}
+
+// Shutdown sends remaining events and stops the send loop
+func (c *Client) Shutdown(ctx context.Context) error {
+	c.once.Do(func() { close(c.done) })
+	finished := make(chan struct{})
+	go func() {
+		c.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (c *Client) run() {
+	defer c.wg.Done()
+	drain := func() {
+		for {
+			select {
+			case e := <-c.events:
+				c.send(e)
+			default:
+				return
+			}
+		}
+	}
+
+	for {
+		select {
+		case e := <-c.events:
+			c.send(e)
+		case reply := <-c.flushCh:
+			drain()
+			close(reply)
+		case <-c.done:
+			drain()
+			return
+		}
+	}
+}
+
+// send posts one event. Failures are logged at WARN so they are not
+// reported in turn.
+func (c *Client) send(e *event) {
+	body, err := json.Marshal(e)
+	if err != nil {
+		logger.Warn("Failed to encode error report", "error", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.dsn.storeURL(), bytes.NewReader(body))
+	if err != nil {
+		logger.Warn("Failed to build error report request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", c.dsn.authHeader())
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		logger.Warn("Failed to send error report", "host", c.dsn.host, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logger.Warn("Error report rejected", "host", c.dsn.host, "status", resp.StatusCode)
+	}
+}
+
+// event is the subset of the Sentry event payload we send
+type event struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger,omitempty"`
+	Message     string            `json:"message,omitempty"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Exception   []exception       `json:"exception,omitempty"`
+	Request     *request          `json:"request,omitempty"`
+	User        *user             `json:"user,omitempty"`
+	Tags        map[string]string `json:"tags,omitempty"`
+	Extra       map[string]string `json:"extra,omitempty"`
+}
+
+type exception struct {
+	Type       string      `json:"type"`
+	Value      string      `json:"value"`
+	Stacktrace *stacktrace `json:"stacktrace,omitempty"`
+	Mechanism  *mechanism  `json:"mechanism,omitempty"`
+}
+
+type mechanism struct {
+	Type    string `json:"type"`
+	Handled bool   `json:"handled"`
+}
+
+type stacktrace struct {
+	Frames []frame `json:"frames"`
+}
+
+type frame struct {
+	Function string `json:"function"`
+	Module   string `json:"module,omitempty"`
+	Filename string `json:"filename"`
+	AbsPath  string `json:"abs_path,omitempty"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+type request struct {
+	Method      string            `json:"method"`
+	URL         string            `json:"url"`
+	QueryString string            `json:"query_string,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty"`
+	Env         map[string]string `json:"env,omitempty"`
+}
+
+type user struct {
+	ID       string `json:"id,omitempty"`
+	Username string `json:"username,omitempty"`
+}
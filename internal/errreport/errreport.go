@@ -0,0 +1,324 @@
+// Package errreport forwards errors and recovered panics to a
+// Sentry-compatible service (Sentry, GlitchTip, Bugsink, ...). Events carry
+// a stack trace, the log attributes and, for HTTP requests, the request
+// context. Without a configured DSN reporting is off and every function is a
+// no-op.
+package errreport
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// Config controls error reporting
+type Config struct {
+	// DSN is the project DSN, e.g. https://<key>@sentry.example.com/<project>.
+	// Reporting is disabled when empty.
+	DSN         string
+	Environment string
+	Release     string
+	ServerName  string
+}
+
+var client atomic.Pointer[Client]
+
+func currentClient() *Client {
+	return client.Load()
+}
+
+// Enabled reports whether errors are being reported
+func Enabled() bool {
+	return currentClient() != nil
+}
+
+// Init starts reporting errors logged at ERROR and panics recovered by
+// GinRecovery to cfg.DSN. It is a no-op when cfg.DSN is empty.
+func Init(cfg Config) error {
+	if cfg.DSN == "" {
+		return nil
+	}
+	c, err := NewClient(cfg)
+	if err != nil {
+		return err
+	}
+	if old := client.Swap(c); old != nil {
+		old.Shutdown(context.Background())
+	}
+	logger.SetErrorHook(logHook)
+	logger.Info("Error reporting enabled", "host", c.dsn.host, "project", c.dsn.projectID, "environment", c.environment)
+	return nil
+}
+
+// Shutdown sends pending events and disables reporting
+func Shutdown(ctx context.Context) error {
+	c := client.Swap(nil)
+	if c == nil {
+		return nil
+	}
+	logger.SetErrorHook(nil)
+	return c.Shutdown(ctx)
+}
+
+// Flush sends all queued events
+func Flush() {
+	if c := currentClient(); c != nil {
+		c.Flush()
+	}
+}
+
+// logHook reports an ERROR log entry
+func logHook(ctx context.Context, entry logger.Entry) {
+	c := currentClient()
+	if c == nil || reported(ctx) {
+		return
+	}
+
+	event := c.newEvent(ctx, levelError)
+	event.Logger = entry.Module
+	event.Message = entry.Message
+	event.Timestamp = entry.Time.UTC().Format(time.RFC3339Nano)
+
+	value := entry.Message
+	if errText := entry.Attrs["error"]; errText != "" {
+		value = entry.Message + ": " + errText
+	}
+	event.Exception = []exception{{
+		Type:       entry.Message,
+		Value:      value,
+		Stacktrace: &stacktrace{Frames: callerFrames(0)},
+	}}
+	for key, val := range entry.Attrs {
+		if key == "job_id" || key == "trace_id" {
+			event.Tags[key] = val
+			continue
+		}
+		event.Extra[key] = val
+	}
+	if entry.Module != "" {
+		event.Tags["module"] = entry.Module
+	}
+	c.enqueue(event)
+}
+
+// CapturePanic reports a recovered panic with the stack it unwound. Call it
+// from the deferred function that recovered.
+func CapturePanic(ctx context.Context, recovered any) {
+	c := currentClient()
+	if c == nil {
+		return
+	}
+
+	event := c.newEvent(ctx, levelFatal)
+	event.Message = fmt.Sprint(recovered)
+	typeName := fmt.Sprintf("%T", recovered)
+	if err, ok := recovered.(error); ok {
+		typeName = fmt.Sprintf("%T", err)
+	}
+	event.Exception = []exception{{
+		Type:       typeName,
+		Value:      event.Message,
+		Stacktrace: &stacktrace{Frames: callerFrames(0)},
+		Mechanism:  &mechanism{Type: "panic", Handled: false},
+	}}
+	c.enqueue(event)
+}
+
+type reportedKey struct{}
+
+// MarkReported returns a context whose ERROR log lines are not reported
+// again, for logging an error that was already captured
+func MarkReported(ctx context.Context) context.Context {
+	return context.WithValue(ctx, reportedKey{}, true)
+}
+
+func reported(ctx context.Context) bool {
+	if ctx == nil {
+		return false
+	}
+	done, _ := ctx.Value(reportedKey{}).(bool)
+	return done
+}
+
+type requestKey struct{}
+
+// sensitiveHeaders are never sent along with an event
+var sensitiveHeaders = map[string]bool{
+	"Authorization": true,
+	"Cookie":        true,
+	"Set-Cookie":    true,
+	"X-Api-Key":     true,
+	"X-Csrf-Token":  true,
+}
+
+// WithRequest attaches an HTTP request's details to a context so errors
+// logged with it carry the request
+func WithRequest(ctx context.Context, r *http.Request) context.Context {
+	req := &request{
+		Method:      r.Method,
+		URL:         requestURL(r),
+		QueryString: r.URL.RawQuery,
+		Headers:     map[string]string{},
+		Env:         map[string]string{"REMOTE_ADDR": r.RemoteAddr},
+	}
+	for name, values := range r.Header {
+		if sensitiveHeaders[http.CanonicalHeaderKey(name)] {
+			continue
+		}
+		req.Headers[name] = strings.Join(values, ", ")
+	}
+	return context.WithValue(ctx, requestKey{}, req)
+}
+
+func requestFromContext(ctx context.Context) *request {
+	if ctx == nil {
+		return nil
+	}
+	req, _ := ctx.Value(requestKey{}).(*request)
+	return req
+}
+
+func requestURL(r *http.Request) string {
+	scheme := "http"
+	if r.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + r.Host + r.URL.Path
+}
+
+// newEvent starts an event with the client defaults and whatever the
+// context knows about the request and job
+func (c *Client) newEvent(ctx context.Context, level string) *event {
+	event := &event{
+		EventID:     newEventID(),
+		Timestamp:   time.Now().UTC().Format(time.RFC3339Nano),
+		Level:       level,
+		Platform:    "go",
+		Environment: c.environment,
+		Release:     c.release,
+		ServerName:  c.serverName,
+		Tags:        map[string]string{},
+		Extra:       map[string]string{},
+	}
+	event.Request = requestFromContext(ctx)
+	event.User = userFromContext(ctx)
+	if jobID := logger.JobIDFromContext(ctx); jobID != "" {
+		event.Tags["job_id"] = jobID
+	}
+	return event
+}
+
+type userKey struct{}
+
+// WithUser records who made a request, for events raised while serving it
+func WithUser(ctx context.Context, id, username string) context.Context {
+	return context.WithValue(ctx, userKey{}, &user{ID: id, Username: username})
+}
+
+func userFromContext(ctx context.Context) *user {
+	if ctx == nil {
+		return nil
+	}
+	u, _ := ctx.Value(userKey{}).(*user)
+	return u
+}
+
+// ignoredPackages are skipped at the top of a stack: the logger and the
+// reporter itself, and the runtime's panic machinery
+var ignoredPackages = []string{
+	"runtime.",
+	"log/slog.",
+	"synthezia/pkg/logger.",
+	"synthezia/internal/errreport.",
+}
+
+func ignoredFrame(function string) bool {
+	for _, prefix := range ignoredPackages {
+		if strings.HasPrefix(function, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// callerFrames returns the current stack in Sentry's order (outermost call
+// first), starting at the first frame outside ignoredPackages
+func callerFrames(skip int) []frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(skip+2, pcs)
+	frames := runtime.CallersFrames(pcs[:n])
+
+	var result []frame
+	skipping := true
+	for {
+		f, more := frames.Next()
+		if skipping && ignoredFrame(f.Function) {
+			if !more {
+				break
+			}
+			continue
+		}
+		skipping = false
+		if f.Function != "" {
+			result = append(result, frame{
+				Function: functionName(f.Function),
+				Module:   packageName(f.Function),
+				Filename: shortFile(f.File),
+				AbsPath:  f.File,
+				Lineno:   f.Line,
+				InApp:    strings.HasPrefix(f.Function, "synthezia/"),
+			})
+		}
+		if !more {
+			break
+		}
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// packageName returns the import path part of a qualified function name
+func packageName(function string) string {
+	slash := strings.LastIndex(function, "/")
+	if dot := strings.Index(function[slash+1:], "."); dot >= 0 {
+		return function[:slash+1+dot]
+	}
+	return function
+}
+
+// functionName returns the function name without its import path
+func functionName(function string) string {
+	return strings.TrimPrefix(function, packageName(function)+".")
+}
+
+// shortFile trims a path to its last two elements, e.g. api/handlers.go
+func shortFile(path string) string {
+	parts := strings.Split(path, "/")
+	if len(parts) > 2 {
+		parts = parts[len(parts)-2:]
+	}
+	return strings.Join(parts, "/")
+}
+
+func newEventID() string {
+	var id [16]byte
+	rand.Read(id[:])
+	return hex.EncodeToString(id[:])
+}
+
+// defaultServerName is the host name reported when none is configured
+func defaultServerName() string {
+	name, _ := os.Hostname()
+	return name
+}
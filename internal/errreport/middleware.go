@@ -0,0 +1,72 @@
+package errreport
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"syscall"
+
+	"synthezia/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+)
+
+// GinRecovery recovers from panics in later handlers, reports them with the
+// request context and answers 500. It also attaches the request to the
+// context so errors logged with ErrorContext while serving it carry it.
+func GinRecovery() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Request = c.Request.WithContext(WithRequest(c.Request.Context(), c.Request))
+
+		defer func() {
+			recovered := recover()
+			if recovered == nil {
+				return
+			}
+
+			// A client that went away is not a server error
+			if brokenPipe(recovered) {
+				logger.Warn("Client connection lost", "method", c.Request.Method, "path", c.Request.URL.Path, "error", fmt.Sprint(recovered))
+				c.Abort()
+				return
+			}
+
+			ctx := c.Request.Context()
+			if userID, ok := c.Get("user_id"); ok {
+				ctx = WithUser(ctx, fmt.Sprint(userID), c.GetString("username"))
+			}
+			CapturePanic(ctx, recovered)
+			logger.ErrorContext(MarkReported(ctx), "Panic recovered",
+				"method", c.Request.Method,
+				"path", c.Request.URL.Path,
+				"panic", fmt.Sprint(recovered))
+
+			c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": "Internal server error"})
+		}()
+
+		c.Next()
+	}
+}
+
+// brokenPipe reports whether a panic came from writing to a closed connection
+func brokenPipe(recovered any) bool {
+	err, ok := recovered.(error)
+	if !ok {
+		return false
+	}
+	if errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var opErr *net.OpError
+	if errors.As(err, &opErr) {
+		var sysErr *os.SyscallError
+		if errors.As(opErr.Err, &sysErr) {
+			msg := strings.ToLower(sysErr.Error())
+			return strings.Contains(msg, "broken pipe") || strings.Contains(msg, "connection reset by peer")
+		}
+	}
+	return false
+}
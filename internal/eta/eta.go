@@ -0,0 +1,243 @@
+package eta
+
+import (
+	"sort"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+
+	"gorm.io/gorm"
+)
+
+// DefaultRealtimeFactor is the processing seconds per second of audio
+// assumed before any job has completed
+const DefaultRealtimeFactor = 1.0
+
+// historyWindow bounds the executions speeds are learned from, so they
+// follow hardware and model changes
+const historyWindow = 30 * 24 * time.Hour
+
+// Where a realtime factor came from, most specific first
+const (
+	BasisModelDevice = "model_device" // Jobs with the same model on the same device
+	BasisModel       = "model"        // Jobs with the same model on any device
+	BasisAll         = "all"          // Every job
+	BasisDefault     = "default"      // No jobs have completed yet
+)
+
+// Speed is how fast jobs have been transcribed
+type Speed struct {
+	RealtimeFactor float64 `json:"realtime_factor"` // Processing seconds per second of audio
+	Basis          string  `json:"basis"`
+	Samples        int     `json:"samples"` // Completed executions it was measured on
+}
+
+// Service estimates when queued and running jobs complete from how fast
+// past jobs were transcribed
+type Service struct {
+	db    *gorm.DB
+	clock clock.Clock
+}
+
+// NewService creates a service; a nil db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, clock: clock.Real}
+}
+
+// Default is the process-wide service
+var Default = NewService(nil)
+
+// SetClock overrides the time source, mainly for tests
+func (s *Service) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// speedKey identifies the jobs one realtime factor is measured on
+type speedKey struct {
+	model  string
+	device string
+}
+
+// sample sums the processing and audio seconds of some executions
+type sample struct {
+	processing float64
+	audio      float64
+	count      int
+}
+
+func (a *sample) add(b sample) {
+	a.processing += b.processing
+	a.audio += b.audio
+	a.count += b.count
+}
+
+// Speeds learns realtime factors from the executions completed lately
+type Speeds struct {
+	byDevice map[speedKey]sample
+	byModel  map[string]sample
+	all      sample
+}
+
+// Speeds measures how fast each model ran on each device lately
+func (s *Service) Speeds() (*Speeds, error) {
+	var rows []struct {
+		Model      string
+		Device     string
+		Processing float64
+		Audio      float64
+		Count      int
+	}
+	err := s.conn().Table("transcription_job_executions AS e").
+		Select("e.actual_model AS model, e.actual_device AS device, SUM(e.processing_duration) / 1000.0 AS processing, SUM(j.audio_duration) AS audio, COUNT(*) AS count").
+		Joins("JOIN transcription_jobs AS j ON j.id = e.transcription_job_id").
+		Where("e.status = ? AND e.processing_duration IS NOT NULL AND j.audio_duration > 0 AND e.started_at >= ?", models.StatusCompleted, s.clock.Now().Add(-historyWindow)).
+		Group("e.actual_model, e.actual_device").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	speeds := &Speeds{byDevice: map[speedKey]sample{}, byModel: map[string]sample{}}
+	for _, row := range rows {
+		measured := sample{processing: row.Processing, audio: row.Audio, count: row.Count}
+		speeds.byDevice[speedKey{row.Model, row.Device}] = measured
+		byModel := speeds.byModel[row.Model]
+		byModel.add(measured)
+		speeds.byModel[row.Model] = byModel
+		speeds.all.add(measured)
+	}
+	return speeds, nil
+}
+
+// For returns the realtime factor of a model on a device, falling back to
+// the model on any device, then to every job
+func (s *Speeds) For(model, device string) Speed {
+	if measured, ok := s.byDevice[speedKey{model, device}]; ok {
+		return measured.speed(BasisModelDevice)
+	}
+	if measured, ok := s.byModel[model]; ok {
+		return measured.speed(BasisModel)
+	}
+	if s.all.count > 0 {
+		return s.all.speed(BasisAll)
+	}
+	return Speed{RealtimeFactor: DefaultRealtimeFactor, Basis: BasisDefault}
+}
+
+func (m sample) speed(basis string) Speed {
+	return Speed{RealtimeFactor: m.processing / m.audio, Basis: basis, Samples: m.count}
+}
+
+// Estimate is when a job is expected to complete
+type Estimate struct {
+	CompletesAt      time.Time  `json:"completes_at"`
+	RemainingSeconds float64    `json:"remaining_seconds"`
+	StartsAt         *time.Time `json:"starts_at,omitempty"` // When a queued job is expected to reach a worker
+	JobsAhead        int        `json:"jobs_ahead"`          // Jobs that reach a worker first
+	Speed            Speed      `json:"speed"`
+}
+
+// Estimate returns when each pending or processing job among jobIDs is
+// expected to complete, given how many jobs run at once. Jobs are expected
+// to reach workers in the order they were created; jobs without a known
+// audio duration get no estimate and are not counted in the wait of others.
+func (s *Service) Estimate(jobIDs []string, workers int) (map[string]*Estimate, error) {
+	estimates := map[string]*Estimate{}
+	if len(jobIDs) == 0 {
+		return estimates, nil
+	}
+	wanted := make(map[string]bool, len(jobIDs))
+	for _, id := range jobIDs {
+		wanted[id] = true
+	}
+
+	speeds, err := s.Speeds()
+	if err != nil {
+		return nil, err
+	}
+	// Running jobs first, then queued ones in the order workers take them
+	var active []models.TranscriptionJob
+	err = s.conn().
+		Where("status IN ? AND id NOT LIKE 'track_%'", []models.JobStatus{models.StatusPending, models.StatusProcessing}).
+		Order("CASE WHEN status = 'processing' THEN 0 ELSE 1 END, created_at ASC, id ASC").
+		Find(&active).Error
+	if err != nil {
+		return nil, err
+	}
+	var open []models.TranscriptionJobExecution
+	err = s.conn().
+		Where("completed_at IS NULL AND transcription_job_id IN (?)", s.conn().Model(&models.TranscriptionJob{}).Select("id").Where("status = ?", models.StatusProcessing)).
+		Find(&open).Error
+	if err != nil {
+		return nil, err
+	}
+	startedAt := map[string]time.Time{}
+	for _, execution := range open {
+		if execution.StartedAt.After(startedAt[execution.TranscriptionJobID]) {
+			startedAt[execution.TranscriptionJobID] = execution.StartedAt
+		}
+	}
+
+	now := s.clock.Now()
+	// free holds when each worker is next free, soonest first
+	free := make([]time.Time, max(workers, 1))
+	for i := range free {
+		free[i] = now
+	}
+	ahead := 0
+	for _, job := range active {
+		if job.AudioDuration == nil {
+			continue
+		}
+		audio := *job.AudioDuration
+		if job.ResumeFrom != nil {
+			audio = max(audio-*job.ResumeFrom, 0)
+		}
+		speed := speeds.For(job.Parameters.Model, job.Parameters.Device)
+		processing := time.Duration(audio * speed.RealtimeFactor * float64(time.Second))
+
+		estimate := &Estimate{JobsAhead: ahead, Speed: speed}
+		if job.Status == models.StatusProcessing {
+			started, ok := startedAt[job.ID]
+			if !ok {
+				started = now
+			}
+			estimate.CompletesAt = maxTime(started.Add(processing), now)
+		} else {
+			start := free[0]
+			if job.RunAfter != nil {
+				start = maxTime(start, *job.RunAfter)
+			}
+			estimate.StartsAt = &start
+			estimate.CompletesAt = start.Add(processing)
+		}
+		estimate.RemainingSeconds = estimate.CompletesAt.Sub(now).Seconds()
+		// A job scheduled for later leaves its worker to others until then
+		if estimate.StartsAt == nil || !estimate.StartsAt.After(free[0]) {
+			free[0] = estimate.CompletesAt
+			sort.Slice(free, func(i, j int) bool { return free[i].Before(free[j]) })
+			ahead++
+		}
+
+		if wanted[job.ID] {
+			estimates[job.ID] = estimate
+		}
+	}
+	return estimates, nil
+}
+
+func maxTime(a, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,183 @@
+// Package faults provides env-gated fault injection for exercising failure
+// handling (retries, dead-lettering, recovery) in integration tests. It is
+// inert unless FAULT_INJECTION is set and must never be enabled in production.
+package faults
+
+import (
+	"fmt"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// Point identifies a place in the pipeline where a fault can be injected
+type Point string
+
+const (
+	// FFmpeg simulates ffmpeg exiting with an error
+	FFmpeg Point = "ffmpeg"
+	// WhisperXOOM simulates WhisperX running out of GPU memory
+	WhisperXOOM Point = "whisperx_oom"
+	// SlowDisk adds latency to file writes
+	SlowDisk Point = "slow_disk"
+	// DBLock simulates SQLite returning "database is locked"
+	DBLock Point = "db_lock"
+)
+
+// defaultSlowDiskDelay is used when FAULT_INJECTION_DELAY is not set
+const defaultSlowDiskDelay = 2 * time.Second
+
+// InjectedError marks an error produced by fault injection
+type InjectedError struct {
+	Point   Point
+	Message string
+}
+
+func (e *InjectedError) Error() string {
+	return fmt.Sprintf("%s (injected fault: %s)", e.Message, e.Point)
+}
+
+var (
+	enabled       atomic.Bool
+	mu            sync.RWMutex
+	probabilities = map[Point]float64{}
+	slowDiskDelay = defaultSlowDiskDelay
+	rng           = rand.New(rand.NewSource(time.Now().UnixNano()))
+	rngMu         sync.Mutex
+)
+
+// LoadFromEnv configures fault injection from FAULT_INJECTION, e.g.
+// "ffmpeg:0.2,whisperx_oom:0.1,slow_disk:0.5,db_lock:0.05", and the optional
+// FAULT_INJECTION_DELAY duration used for slow disk faults
+func LoadFromEnv() error {
+	spec := os.Getenv("FAULT_INJECTION")
+	if spec == "" {
+		return nil
+	}
+	if err := Configure(spec); err != nil {
+		return err
+	}
+	if delay := os.Getenv("FAULT_INJECTION_DELAY"); delay != "" {
+		d, err := time.ParseDuration(delay)
+		if err != nil {
+			return fmt.Errorf("invalid FAULT_INJECTION_DELAY: %w", err)
+		}
+		SetSlowDiskDelay(d)
+	}
+	logger.Warn("Fault injection is ENABLED - do not use in production", "spec", spec)
+	return nil
+}
+
+// Configure parses a "point:probability" list and enables injection
+func Configure(spec string) error {
+	parsed := map[Point]float64{}
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.SplitN(entry, ":", 2)
+		if len(parts) != 2 {
+			return fmt.Errorf("invalid fault spec %q, expected point:probability", entry)
+		}
+		point := Point(strings.TrimSpace(parts[0]))
+		switch point {
+		case FFmpeg, WhisperXOOM, SlowDisk, DBLock:
+		default:
+			return fmt.Errorf("unknown fault point %q", point)
+		}
+		p, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || p < 0 || p > 1 {
+			return fmt.Errorf("invalid probability for %s: %q", point, parts[1])
+		}
+		parsed[point] = p
+	}
+
+	mu.Lock()
+	probabilities = parsed
+	mu.Unlock()
+	enabled.Store(len(parsed) > 0)
+	return nil
+}
+
+// Set changes the probability of a single fault point and enables injection
+func Set(point Point, probability float64) {
+	mu.Lock()
+	probabilities[point] = probability
+	mu.Unlock()
+	enabled.Store(true)
+}
+
+// SetSlowDiskDelay changes how long a slow disk fault stalls
+func SetSlowDiskDelay(d time.Duration) {
+	mu.Lock()
+	slowDiskDelay = d
+	mu.Unlock()
+}
+
+// Reset disables all fault injection
+func Reset() {
+	mu.Lock()
+	probabilities = map[Point]float64{}
+	slowDiskDelay = defaultSlowDiskDelay
+	mu.Unlock()
+	enabled.Store(false)
+}
+
+// Enabled reports whether any fault injection is configured
+func Enabled() bool {
+	return enabled.Load()
+}
+
+// triggered rolls the dice for a fault point
+func triggered(point Point) bool {
+	if !enabled.Load() {
+		return false
+	}
+	mu.RLock()
+	p := probabilities[point]
+	mu.RUnlock()
+	if p <= 0 {
+		return false
+	}
+	rngMu.Lock()
+	roll := rng.Float64()
+	rngMu.Unlock()
+	return roll < p
+}
+
+// Inject returns a simulated error for the point when the fault fires
+func Inject(point Point) error {
+	if !triggered(point) {
+		return nil
+	}
+	logger.Warn("Injecting fault", "point", point)
+
+	switch point {
+	case FFmpeg:
+		return &InjectedError{Point: point, Message: "ffmpeg exited with status 1"}
+	case WhisperXOOM:
+		return &InjectedError{Point: point, Message: "CUDA out of memory"}
+	case DBLock:
+		return &InjectedError{Point: point, Message: "database is locked"}
+	}
+	return &InjectedError{Point: point, Message: "simulated failure"}
+}
+
+// Delay stalls the caller when a slow disk fault fires
+func Delay() {
+	if !triggered(SlowDisk) {
+		return
+	}
+	mu.RLock()
+	d := slowDiskDelay
+	mu.RUnlock()
+	logger.Warn("Injecting slow disk delay", "delay", d)
+	time.Sleep(d)
+}
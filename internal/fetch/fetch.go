@@ -0,0 +1,571 @@
+// Package fetch downloads the media of jobs submitted by URL. Direct links,
+// including presigned S3 URLs, are fetched over HTTP; YouTube and the other
+// sites yt-dlp understands are fetched with yt-dlp when enabled. Direct links
+// may not reach internal addresses unless an operator allows them. A job waits
+// in the uploaded state without audio until its download completes, then it
+// is marked pending and queued like an upload.
+package fetch
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// ErrInvalidURL means a submitted URL is not an absolute http(s) URL
+var ErrInvalidURL = errors.New("url must be an absolute http or https URL")
+
+// ErrYouTubeDisabled means a URL needs yt-dlp, which is not enabled
+var ErrYouTubeDisabled = errors.New("YouTube downloads are disabled on this server")
+
+// ErrTooLarge means the media is larger than downloads may be
+var ErrTooLarge = errors.New("media is larger than the download limit")
+
+// DefaultConcurrency is how many downloads run at once unless Options say otherwise
+const DefaultConcurrency = 2
+
+// progressInterval is how often download progress is written to the database
+const progressInterval = time.Second
+
+// probeTimeout bounds probing a downloaded file
+const probeTimeout = 30 * time.Second
+
+// youtubeHosts are the hosts whose URLs are pages to be fetched with yt-dlp
+var youtubeHosts = map[string]bool{
+	"youtube.com":       true,
+	"www.youtube.com":   true,
+	"m.youtube.com":     true,
+	"music.youtube.com": true,
+	"youtu.be":          true,
+}
+
+// contentTypeExtensions picks a file extension when the URL path has none
+var contentTypeExtensions = map[string]string{
+	"audio/mpeg":      ".mp3",
+	"audio/mp3":       ".mp3",
+	"audio/mp4":       ".m4a",
+	"audio/x-m4a":     ".m4a",
+	"audio/aac":       ".aac",
+	"audio/wav":       ".wav",
+	"audio/x-wav":     ".wav",
+	"audio/wave":      ".wav",
+	"audio/flac":      ".flac",
+	"audio/x-flac":    ".flac",
+	"audio/ogg":       ".ogg",
+	"audio/opus":      ".opus",
+	"audio/webm":      ".webm",
+	"video/mp4":       ".mp4",
+	"video/webm":      ".webm",
+	"video/quicktime": ".mov",
+}
+
+// ytdlpProgressLine matches the percentage in yt-dlp's "[download]  42.0% of ..." lines
+var ytdlpProgressLine = regexp.MustCompile(`^\[download\]\s+(\d+(?:\.\d+)?)%`)
+
+// ytdlpTitlePrefix marks the line yt-dlp prints with the title before downloading
+const ytdlpTitlePrefix = "title:"
+
+// Options configure a download service
+type Options struct {
+	UploadDir string
+	TempDir   string
+	// MaxBytes caps a download; 0 means no limit
+	MaxBytes int64
+	// YouTube accepts URLs of sites fetched with yt-dlp
+	YouTube bool
+	// YtDlp is the command that runs yt-dlp, before its arguments
+	YtDlp       []string
+	CookiesPath string
+	FFprobePath string
+	// Concurrency is how many downloads run at once; 0 uses DefaultConcurrency
+	Concurrency int
+	// AllowedHosts are host names, IP addresses and CIDR networks direct
+	// links may reach even though they are internal
+	AllowedHosts []string
+	// Proxy is the URL of an HTTP proxy direct links are fetched through;
+	// empty connects directly. HTTP_PROXY and HTTPS_PROXY are not used.
+	Proxy string
+}
+
+// Service runs downloads in the background
+type Service struct {
+	db      *gorm.DB
+	client  *http.Client
+	guard   *addressGuard
+	opts    Options
+	enqueue func(jobID string) error
+
+	slots   chan struct{}
+	mu      sync.Mutex
+	running map[string]chan struct{}
+}
+
+// NewService creates a download service; a nil db uses database.DB at call time
+func NewService(db *gorm.DB, opts Options) *Service {
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = DefaultConcurrency
+	}
+	guard := newAddressGuard(opts.AllowedHosts)
+	// Every connection, redirects included, goes through the guard; without
+	// a proxy, so the guard sees the real destination
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = nil
+	transport.DialContext = guard.dialContext
+	client := &http.Client{Transport: transport}
+	if opts.Proxy != "" {
+		proxyURL, err := url.Parse(opts.Proxy)
+		if err != nil || proxyURL.Host == "" {
+			logger.Warn("Ignoring invalid download proxy", "error", err)
+		} else {
+			// The proxy connects to the destination, so each request's host
+			// is resolved and checked before it is sent instead
+			transport.Proxy = http.ProxyURL(proxyURL)
+			transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+			client.Transport = &guardedTransport{guard: guard, next: transport}
+		}
+	}
+	return &Service{
+		db:      db,
+		client:  client,
+		guard:   guard,
+		opts:    opts,
+		slots:   make(chan struct{}, opts.Concurrency),
+		running: map[string]chan struct{}{},
+	}
+}
+
+// SetEnqueue sets how a job is queued once its media is downloaded
+func (s *Service) SetEnqueue(enqueue func(jobID string) error) {
+	s.enqueue = enqueue
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Classify checks a submitted URL and tells how it would be downloaded,
+// returning the source and the host to show for it
+func (s *Service) Classify(raw string) (string, string, error) {
+	u, err := url.Parse(strings.TrimSpace(raw))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "", "", ErrInvalidURL
+	}
+	host := strings.ToLower(u.Hostname())
+	if youtubeHosts[host] {
+		if !s.opts.YouTube {
+			return "", "", ErrYouTubeDisabled
+		}
+		return models.DownloadSourceYouTube, host, nil
+	}
+	if err := s.guard.checkHost(host); err != nil {
+		return "", "", err
+	}
+	return models.DownloadSourceHTTP, host, nil
+}
+
+// Start begins downloading the media of a job in the background
+func (s *Service) Start(jobID string) {
+	s.launch(jobID)
+}
+
+// Resume restarts downloads interrupted by a restart from the beginning
+func (s *Service) Resume() {
+	var jobIDs []string
+	s.conn().Model(&models.RemoteDownload{}).
+		Where("status IN ?", []string{models.DownloadPending, models.DownloadDownloading}).
+		Pluck("job_id", &jobIDs)
+	for _, jobID := range jobIDs {
+		logger.Info("Resuming media download", "job_id", jobID)
+		s.launch(jobID)
+	}
+}
+
+// Wait blocks until the download of a job is no longer running
+func (s *Service) Wait(jobID string) {
+	s.mu.Lock()
+	done, ok := s.running[jobID]
+	s.mu.Unlock()
+	if ok {
+		<-done
+	}
+}
+
+func (s *Service) launch(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.running[jobID]; ok {
+		return
+	}
+	done := make(chan struct{})
+	s.running[jobID] = done
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, jobID)
+			s.mu.Unlock()
+			close(done)
+		}()
+		s.slots <- struct{}{}
+		defer func() { <-s.slots }()
+		s.run(jobID)
+	}()
+}
+
+// run downloads the media of a job and hands the job on to transcription,
+// or fails both the download and the job
+func (s *Service) run(jobID string) {
+	db := s.conn()
+	var download models.RemoteDownload
+	if err := db.Where("job_id = ?", jobID).First(&download).Error; err != nil {
+		logger.Error("Failed to load media download", "job_id", jobID, "error", err)
+		return
+	}
+	db.Model(&download).Updates(map[string]interface{}{
+		"status":      models.DownloadDownloading,
+		"bytes_done":  0,
+		"bytes_total": 0,
+		"progress":    0,
+		"error":       nil,
+	})
+
+	var lastWrite time.Time
+	report := func(done, total int64, percent float64) {
+		if time.Since(lastWrite) < progressInterval {
+			return
+		}
+		lastWrite = time.Now()
+		db.Model(&download).Updates(map[string]interface{}{"bytes_done": done, "bytes_total": total, "progress": percent})
+	}
+
+	ctx := logger.WithJobID(context.Background(), jobID)
+	var (
+		path, title string
+		size        int64
+		err         error
+	)
+	switch download.Source {
+	case models.DownloadSourceYouTube:
+		path, title, err = s.fetchYtDlp(ctx, &download, report)
+	default:
+		path, title, err = s.fetchHTTP(ctx, &download, report)
+	}
+	if err == nil {
+		var info os.FileInfo
+		if info, err = os.Stat(path); err == nil {
+			size = info.Size()
+		}
+	}
+	if err != nil {
+		s.fail(&download, err)
+		return
+	}
+
+	if err := s.complete(&download, path, title, size); err != nil {
+		os.Remove(path)
+		s.fail(&download, err)
+		return
+	}
+	logger.Info("Downloaded job media", "job_id", jobID, "source", download.Source, "host", download.Host, "bytes", size)
+	if s.enqueue != nil {
+		if err := s.enqueue(jobID); err != nil {
+			logger.Error("Failed to enqueue downloaded job", "job_id", jobID, "error", err)
+		}
+	}
+}
+
+// complete records the downloaded file on the job and moves it to pending.
+// A file that cannot be probed or holds no audio fails the download, so only
+// media ever becomes a job's audio.
+func (s *Service) complete(download *models.RemoteDownload, path, title string, size int64) error {
+	var job models.TranscriptionJob
+	if err := s.conn().Select("id", "title").Where("id = ?", download.JobID).First(&job).Error; err != nil {
+		return fmt.Errorf("failed to load job: %w", err)
+	}
+
+	probed := models.TranscriptionJob{}
+	if s.opts.FFprobePath != "" {
+		ctx, cancel := context.WithTimeout(context.Background(), probeTimeout)
+		result, err := audio.Probe(ctx, s.opts.FFprobePath, path)
+		cancel()
+		switch {
+		case err == nil:
+			result.Apply(&probed)
+		case errors.Is(err, exec.ErrNotFound):
+			logger.Debug("ffprobe not found, skipping check of downloaded media", "job_id", download.JobID)
+		case errors.Is(err, audio.ErrNoAudioStream):
+			return errors.New("downloaded file has no audio")
+		default:
+			return fmt.Errorf("downloaded file is not playable media: %w", err)
+		}
+	}
+
+	fields := map[string]interface{}{"audio_path": path}
+	if (job.Title == nil || *job.Title == "") && title != "" {
+		fields["title"] = title
+	}
+	if probed.AudioDuration != nil {
+		fields["audio_duration"] = *probed.AudioDuration
+	}
+	if probed.AudioSampleRate != nil {
+		fields["audio_sample_rate"] = *probed.AudioSampleRate
+	}
+	if probed.AudioChannels != nil {
+		fields["audio_channels"] = *probed.AudioChannels
+	}
+	if probed.AudioCodec != nil {
+		fields["audio_codec"] = *probed.AudioCodec
+	}
+	if probed.AudioBitRate != nil {
+		fields["audio_bit_rate"] = *probed.AudioBitRate
+	}
+
+	now := time.Now()
+	_, err := jobstate.Transition(download.JobID, models.StatusPending, jobstate.WithFields(fields), jobstate.WithTx(func(tx *gorm.DB) error {
+		return tx.Model(download).Updates(map[string]interface{}{
+			"status":       models.DownloadCompleted,
+			"bytes_done":   size,
+			"bytes_total":  size,
+			"progress":     100,
+			"completed_at": now,
+		}).Error
+	}))
+	return err
+}
+
+// fail records why a download failed and fails its job
+func (s *Service) fail(download *models.RemoteDownload, err error) {
+	message := err.Error()
+	logger.Warn("Media download failed", "job_id", download.JobID, "host", download.Host, "error", message)
+	s.conn().Model(download).Updates(map[string]interface{}{"status": models.DownloadFailed, "error": message})
+	if _, err := jobstate.Transition(download.JobID, models.StatusFailed, jobstate.WithError("Download failed: "+message)); err != nil {
+		logger.Error("Failed to mark job failed", "job_id", download.JobID, "error", err)
+	}
+}
+
+// fetchHTTP downloads a direct link into the upload directory
+func (s *Service) fetchHTTP(ctx context.Context, download *models.RemoteDownload, report func(done, total int64, percent float64)) (string, string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, download.URL, nil)
+	if err != nil {
+		return "", "", ErrInvalidURL
+	}
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return "", "", fmt.Errorf("request failed: %w", redactURLError(err))
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return "", "", fmt.Errorf("server answered %d", resp.StatusCode)
+	}
+	max := s.opts.MaxBytes
+	total := resp.ContentLength
+	if max > 0 && total > max {
+		return "", "", ErrTooLarge
+	}
+
+	if err := os.MkdirAll(s.opts.UploadDir, 0755); err != nil {
+		return "", "", err
+	}
+	name := urlFileName(req.URL)
+	dest := filepath.Join(s.opts.UploadDir, download.JobID+mediaExtension(name, resp.Header.Get("Content-Type")))
+	tmp := fsys.TempPath(s.opts.TempDir, dest)
+	f, err := os.Create(tmp)
+	if err != nil {
+		return "", "", err
+	}
+	defer os.Remove(tmp)
+
+	body := io.Reader(resp.Body)
+	if max > 0 {
+		body = io.LimitReader(resp.Body, max+1)
+	}
+	counter := &progressWriter{total: total, report: report}
+	n, err := io.Copy(io.MultiWriter(f, counter), body)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return "", "", fmt.Errorf("download interrupted: %w", redactURLError(err))
+	}
+	if max > 0 && n > max {
+		return "", "", ErrTooLarge
+	}
+	if err := os.Rename(tmp, dest); err != nil {
+		return "", "", err
+	}
+	return dest, strings.TrimSuffix(name, path.Ext(name)), nil
+}
+
+// fetchYtDlp downloads the audio of a page with yt-dlp into the upload directory
+func (s *Service) fetchYtDlp(ctx context.Context, download *models.RemoteDownload, report func(done, total int64, percent float64)) (string, string, error) {
+	if len(s.opts.YtDlp) == 0 {
+		return "", "", errors.New("yt-dlp is not configured")
+	}
+	if err := os.MkdirAll(s.opts.UploadDir, 0755); err != nil {
+		return "", "", err
+	}
+	workDir := s.opts.TempDir
+	if workDir == "" {
+		workDir = s.opts.UploadDir
+	}
+	workDir, err := os.MkdirTemp(workDir, "."+download.JobID+"-")
+	if err != nil {
+		return "", "", err
+	}
+	defer os.RemoveAll(workDir)
+
+	args := append([]string{}, s.opts.YtDlp[1:]...)
+	args = append(args,
+		"--extract-audio",
+		"--audio-format", "mp3",
+		"--audio-quality", "0",
+		"--no-playlist",
+		"--newline",
+		"--progress",
+		"--print", "before_dl:"+ytdlpTitlePrefix+"%(title)s",
+		"--output", filepath.Join(workDir, download.JobID+".%(ext)s"),
+	)
+	if s.opts.MaxBytes > 0 {
+		args = append(args, "--max-filesize", strconv.FormatInt(s.opts.MaxBytes, 10))
+	}
+	if s.opts.CookiesPath != "" {
+		args = append(args, "--cookies", s.opts.CookiesPath)
+	}
+	args = append(args, download.URL)
+
+	cmd := exec.CommandContext(ctx, s.opts.YtDlp[0], args...)
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return "", "", err
+	}
+	if err := cmd.Start(); err != nil {
+		return "", "", fmt.Errorf("failed to start yt-dlp: %w", err)
+	}
+
+	var title string
+	var lastLine string
+	scanner := bufio.NewScanner(stdout)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		lastLine = line
+		if t, ok := strings.CutPrefix(line, ytdlpTitlePrefix); ok {
+			title = strings.TrimSpace(t)
+			continue
+		}
+		if m := ytdlpProgressLine.FindStringSubmatch(line); m != nil {
+			if percent, err := strconv.ParseFloat(m[1], 64); err == nil {
+				report(0, 0, min(percent, 100))
+			}
+		}
+	}
+	if err := cmd.Wait(); err != nil {
+		detail := lastStderrLine(stderr.String())
+		if detail == "" {
+			detail = lastLine
+		}
+		return "", "", fmt.Errorf("yt-dlp failed: %s", detail)
+	}
+
+	matches, _ := filepath.Glob(filepath.Join(workDir, download.JobID+".*"))
+	if len(matches) == 0 {
+		// yt-dlp skips files over --max-filesize without failing
+		if s.opts.MaxBytes > 0 {
+			return "", "", ErrTooLarge
+		}
+		return "", "", errors.New("yt-dlp produced no file")
+	}
+	dest := filepath.Join(s.opts.UploadDir, filepath.Base(matches[0]))
+	if err := os.Rename(matches[0], dest); err != nil {
+		return "", "", err
+	}
+	return dest, title, nil
+}
+
+// progressWriter counts the bytes written and reports them
+type progressWriter struct {
+	done   int64
+	total  int64
+	report func(done, total int64, percent float64)
+}
+
+func (w *progressWriter) Write(p []byte) (int, error) {
+	w.done += int64(len(p))
+	percent := 0.0
+	if w.total > 0 {
+		percent = min(float64(w.done)/float64(w.total)*100, 100)
+	}
+	w.report(w.done, max(w.total, 0), percent)
+	return len(p), nil
+}
+
+// urlFileName is the unescaped last element of a URL path, or "" when there is none
+func urlFileName(u *url.URL) string {
+	name := path.Base(u.Path)
+	if name == "/" || name == "." {
+		return ""
+	}
+	return name
+}
+
+// mediaExtension picks the extension of a downloaded file from its name, or
+// else from the type the server gave it
+func mediaExtension(name, contentType string) string {
+	if ext := strings.ToLower(path.Ext(name)); ext != "" && len(ext) <= 6 {
+		return ext
+	}
+	if mediaType, _, err := mime.ParseMediaType(contentType); err == nil {
+		if ext, ok := contentTypeExtensions[mediaType]; ok {
+			return ext
+		}
+	}
+	return ".bin"
+}
+
+// redactURLError drops the URL from a request error, since presigned URLs
+// carry credentials and errors end up on the job
+func redactURLError(err error) error {
+	var urlErr *url.Error
+	if errors.As(err, &urlErr) {
+		return urlErr.Err
+	}
+	return err
+}
+
+// lastStderrLine returns the last non-empty line yt-dlp wrote to stderr
+func lastStderrLine(stderr string) string {
+	lines := strings.Split(strings.TrimSpace(stderr), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
@@ -0,0 +1,204 @@
+package fetch
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// ErrInternalAddress means a URL points into the server's own network, which
+// downloads only reach on hosts an operator allows
+var ErrInternalAddress = errors.New("url points at an internal address")
+
+// addressGuard keeps downloads off loopback, private, link-local and other
+// internal addresses, so a submitted URL cannot read services only the
+// server can reach, such as a cloud metadata endpoint. Hosts allowed by name,
+// and addresses in allowed networks, are reached anyway.
+type addressGuard struct {
+	hosts    map[string]bool
+	networks []*net.IPNet
+}
+
+// newAddressGuard builds a guard allowing the given host names, IP addresses
+// and CIDR networks
+func newAddressGuard(allowed []string) *addressGuard {
+	g := &addressGuard{hosts: map[string]bool{}}
+	for _, entry := range allowed {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if strings.Contains(entry, "/") {
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				logger.Warn("Ignoring invalid allowed download network", "network", entry, "error", err)
+				continue
+			}
+			g.networks = append(g.networks, network)
+			continue
+		}
+		if ip := net.ParseIP(entry); ip != nil {
+			bits := 8 * len(ip)
+			g.networks = append(g.networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		g.hosts[entry] = true
+	}
+	return g
+}
+
+// blockedNetworks are the ranges downloads may not reach: this host, private
+// and shared address space, link-local, benchmarking, documentation,
+// multicast and reserved ranges, and the IPv6 ranges that embed IPv4
+// addresses, such as NAT64
+var blockedNetworks = mustParseCIDRs(
+	"0.0.0.0/8",
+	"10.0.0.0/8",
+	"100.64.0.0/10",
+	"127.0.0.0/8",
+	"169.254.0.0/16",
+	"172.16.0.0/12",
+	"192.0.0.0/24",
+	"192.0.2.0/24",
+	"192.168.0.0/16",
+	"198.18.0.0/15",
+	"198.51.100.0/24",
+	"203.0.113.0/24",
+	"224.0.0.0/4",
+	"240.0.0.0/4",
+	"::/96",
+	"64:ff9b::/96",
+	"64:ff9b:1::/48",
+	"100::/64",
+	"2001:db8::/32",
+	"2002::/16",
+	"fc00::/7",
+	"fe80::/10",
+	"ff00::/8",
+)
+
+func mustParseCIDRs(cidrs ...string) []*net.IPNet {
+	networks := make([]*net.IPNet, len(cidrs))
+	for i, cidr := range cidrs {
+		_, network, err := net.ParseCIDR(cidr)
+		if err != nil {
+			panic(err)
+		}
+		networks[i] = network
+	}
+	return networks
+}
+
+// isInternal reports whether ip is in one of the blocked ranges. IPv4
+// addresses mapped into IPv6 are checked as the IPv4 address they carry.
+func isInternal(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil {
+		ip = ip4
+	}
+	for _, network := range blockedNetworks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsHost reports whether host was allowed by name
+func (g *addressGuard) allowsHost(host string) bool {
+	return g.hosts[strings.ToLower(host)]
+}
+
+// allowsIP reports whether downloads may connect to ip
+func (g *addressGuard) allowsIP(ip net.IP) bool {
+	if !isInternal(ip) {
+		return true
+	}
+	for _, network := range g.networks {
+		if network.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// checkHost refuses a URL host that is plainly internal: localhost or a
+// literal internal address. Other names are checked once resolved, when dialing.
+func (g *addressGuard) checkHost(host string) error {
+	if g.allowsHost(host) {
+		return nil
+	}
+	host = strings.ToLower(host)
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return ErrInternalAddress
+	}
+	if ip := net.ParseIP(host); ip != nil && !g.allowsIP(ip) {
+		return ErrInternalAddress
+	}
+	return nil
+}
+
+// dialContext connects like the default transport, but refuses internal
+// addresses after the name is resolved, so a name that resolves inward, or
+// a redirect to one, is caught too
+func (g *addressGuard) dialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if host, _, err := net.SplitHostPort(addr); err == nil && g.allowsHost(host) {
+		return dialer.DialContext(ctx, network, addr)
+	}
+	dialer.Control = func(network, address string, _ syscall.RawConn) error {
+		host, _, err := net.SplitHostPort(address)
+		if err != nil {
+			return err
+		}
+		if ip := net.ParseIP(host); ip == nil || !g.allowsIP(ip) {
+			return ErrInternalAddress
+		}
+		return nil
+	}
+	return dialer.DialContext(ctx, network, addr)
+}
+
+// checkTarget refuses a request whose host resolves to an internal address.
+// It stands in for the check when dialing when downloads go through a
+// proxy, which resolves the host itself.
+func (g *addressGuard) checkTarget(ctx context.Context, host string) error {
+	if err := g.checkHost(host); err != nil || g.allowsHost(host) {
+		return err
+	}
+	if net.ParseIP(host) != nil {
+		return nil
+	}
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return err
+	}
+	for _, addr := range addrs {
+		if !g.allowsIP(addr.IP) {
+			return ErrInternalAddress
+		}
+	}
+	return nil
+}
+
+// guardedTransport checks the target of every request, redirects included,
+// before handing it to a transport that goes through a proxy
+type guardedTransport struct {
+	guard *addressGuard
+	next  http.RoundTripper
+}
+
+func (t *guardedTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if err := t.guard.checkTarget(req.Context(), req.URL.Hostname()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, err
+	}
+	return t.next.RoundTrip(req)
+}
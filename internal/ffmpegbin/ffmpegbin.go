@@ -0,0 +1,232 @@
+// Package ffmpegbin locates the ffmpeg and ffprobe binaries every audio step
+// runs, checks that they are recent enough and have the filters synthezia
+// uses, and can install a pinned static build when they are missing.
+package ffmpegbin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// RequiredFilters are the filters multi-track merging and preprocessing use
+var RequiredFilters = []string{"amix", "adelay", "loudnorm"}
+
+// MinVersion is the oldest release whose amix takes normalize=0, which the
+// merger relies on
+var MinVersion = [2]int{4, 4}
+
+// checkTimeout bounds each run of ffmpeg or ffprobe during the check
+const checkTimeout = 10 * time.Second
+
+var versionLine = regexp.MustCompile(`^ff(?:mpeg|probe) version (\S+)`)
+
+// releaseVersion finds the release in version strings such as "6.1.1",
+// "n7.0" or "4.4.2-0ubuntu0.22.04.1"
+var releaseVersion = regexp.MustCompile(`^n?(\d+)\.(\d+)`)
+
+// Status describes the ffmpeg this process runs
+type Status struct {
+	FFmpegPath     string   `json:"ffmpeg_path,omitempty"`
+	FFprobePath    string   `json:"ffprobe_path,omitempty"`
+	Version        string   `json:"version,omitempty"`
+	MissingFilters []string `json:"missing_filters,omitempty"`
+	// Installed is set when the binaries were downloaded by auto-install
+	Installed bool   `json:"installed,omitempty"`
+	Healthy   bool   `json:"healthy"`
+	Error     string `json:"error,omitempty"`
+}
+
+// Options configure Startup
+type Options struct {
+	// Dir is searched for ffmpeg and ffprobe before the PATH
+	Dir string
+	// AutoInstall downloads the pinned build into InstallDir when no usable
+	// ffmpeg is found
+	AutoInstall bool
+	InstallDir  string
+	// DownloadURL overrides the pinned build; see Install
+	DownloadURL string
+	// SHA256 holds the expected checksums of the downloads by binary name
+	SHA256 map[string]string
+}
+
+var (
+	mu      sync.RWMutex
+	current *Status
+)
+
+// Current returns the status found by the last Startup, if any
+func Current() (Status, bool) {
+	mu.RLock()
+	defer mu.RUnlock()
+	if current == nil {
+		return Status{}, false
+	}
+	return *current, true
+}
+
+// Startup finds the ffmpeg to use, installing it if allowed, and records its
+// status for Current. Directories holding the chosen binaries are put at the
+// front of the PATH, so every later run of "ffmpeg" and "ffprobe" uses them.
+func Startup(ctx context.Context, opts Options) Status {
+	if opts.Dir != "" {
+		prependPath(opts.Dir)
+	}
+	status := Check(ctx)
+
+	if !status.Healthy && opts.AutoInstall && opts.InstallDir != "" {
+		// A build installed by an earlier start is used as it is
+		prependPath(opts.InstallDir)
+		if status = Check(ctx); !status.Healthy {
+			logger.Info("Installing ffmpeg", "dir", opts.InstallDir, "reason", status.Error)
+			if err := Install(ctx, opts.InstallDir, opts.DownloadURL, opts.SHA256); err != nil {
+				status.Error = fmt.Sprintf("auto-install failed: %v", err)
+			} else {
+				status = Check(ctx)
+				status.Installed = true
+			}
+		}
+	}
+
+	mu.Lock()
+	current = &status
+	mu.Unlock()
+	return status
+}
+
+// Check runs the ffmpeg and ffprobe on the PATH and reports whether they
+// can be used
+func Check(ctx context.Context) Status {
+	var status Status
+	ffmpegPath, err := exec.LookPath("ffmpeg")
+	if err != nil {
+		status.Error = "ffmpeg not found"
+		return status
+	}
+	status.FFmpegPath = ffmpegPath
+	ffprobePath, err := exec.LookPath("ffprobe")
+	if err != nil {
+		status.Error = "ffprobe not found"
+		return status
+	}
+	status.FFprobePath = ffprobePath
+
+	out, err := run(ctx, ffmpegPath, "-hide_banner", "-version")
+	if err != nil {
+		status.Error = fmt.Sprintf("ffmpeg -version failed: %v", err)
+		return status
+	}
+	status.Version = ParseVersion(out)
+	if status.Version == "" {
+		status.Error = "unrecognized ffmpeg -version output"
+		return status
+	}
+	if !supportedVersion(status.Version) {
+		status.Error = fmt.Sprintf("ffmpeg %s is older than %d.%d", status.Version, MinVersion[0], MinVersion[1])
+		return status
+	}
+	if _, err := run(ctx, ffprobePath, "-hide_banner", "-version"); err != nil {
+		status.Error = fmt.Sprintf("ffprobe -version failed: %v", err)
+		return status
+	}
+
+	out, err = run(ctx, ffmpegPath, "-hide_banner", "-filters")
+	if err != nil {
+		status.Error = fmt.Sprintf("ffmpeg -filters failed: %v", err)
+		return status
+	}
+	filters := ParseFilters(out)
+	for _, name := range RequiredFilters {
+		if !filters[name] {
+			status.MissingFilters = append(status.MissingFilters, name)
+		}
+	}
+	if len(status.MissingFilters) > 0 {
+		status.Error = "ffmpeg lacks filters: " + strings.Join(status.MissingFilters, ", ")
+		return status
+	}
+
+	status.Healthy = true
+	return status
+}
+
+// ParseVersion returns the version from the first line of ffmpeg -version
+func ParseVersion(output string) string {
+	line, _, _ := strings.Cut(output, "\n")
+	if m := versionLine.FindStringSubmatch(strings.TrimSpace(line)); m != nil {
+		return m[1]
+	}
+	return ""
+}
+
+// supportedVersion reports whether a version is at least MinVersion. Builds
+// from git, versioned like "N-113000-g1234abcd", are taken to be recent.
+func supportedVersion(version string) bool {
+	m := releaseVersion.FindStringSubmatch(version)
+	if m == nil {
+		return true
+	}
+	major, _ := strconv.Atoi(m[1])
+	minor, _ := strconv.Atoi(m[2])
+	return major > MinVersion[0] || (major == MinVersion[0] && minor >= MinVersion[1])
+}
+
+// ParseFilters returns the names listed by ffmpeg -filters, whose lines give
+// flags, the name, the input and output kinds, and a description, e.g.
+// " ... amix              N->A       Audio mixing."
+func ParseFilters(output string) map[string]bool {
+	filters := make(map[string]bool)
+	for _, line := range strings.Split(output, "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 3 && strings.Contains(fields[2], "->") {
+			filters[fields[1]] = true
+		}
+	}
+	return filters
+}
+
+// run runs a binary and returns what it wrote to stdout
+func run(ctx context.Context, path string, args ...string) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, path, args...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("%w: %s", err, msg)
+		}
+		return "", err
+	}
+	return stdout.String(), nil
+}
+
+// prependPath puts dir at the front of the PATH
+func prependPath(dir string) {
+	if abs, err := filepath.Abs(dir); err == nil {
+		dir = abs
+	}
+	path := os.Getenv("PATH")
+	for _, entry := range filepath.SplitList(path) {
+		if entry == dir {
+			return
+		}
+	}
+	if path == "" {
+		os.Setenv("PATH", dir)
+		return
+	}
+	os.Setenv("PATH", dir+string(os.PathListSeparator)+path)
+}
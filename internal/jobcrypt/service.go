@@ -0,0 +1,302 @@
+package jobcrypt
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// EventSealed is recorded in a job's timeline once its content is encrypted
+const EventSealed = "encrypted"
+
+// sealedExt is appended to the name of a sealed file
+const sealedExt = ".enc"
+
+// Service holds job keys while jobs are processed and seals finished jobs
+type Service struct {
+	db      *gorm.DB
+	fs      fsys.FS
+	clock   clock.Clock
+	tempDir string
+
+	keys   map[string][]byte
+	keysMu sync.Mutex
+
+	onSealed func(jobID string)
+}
+
+// NewService creates a service; a nil db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:    db,
+		fs:    fsys.OS,
+		clock: clock.Real,
+		keys:  make(map[string][]byte),
+	}
+}
+
+// Default is the process-wide service
+var Default = NewService(nil)
+
+// SetFS overrides the filesystem, mainly for tests
+func (s *Service) SetFS(fs fsys.FS) {
+	s.fs = fs
+}
+
+// SetClock overrides the time source, mainly for tests
+func (s *Service) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetTempDir sets where sealed files are written before replacing the originals
+func (s *Service) SetTempDir(dir string) {
+	s.tempDir = dir
+}
+
+// OnSealed registers a function called after a job is sealed
+func (s *Service) OnSealed(fn func(jobID string)) {
+	s.onSealed = fn
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Hold keeps a job's key in memory until the job is sealed. Keys are never
+// written to disk, so a restart loses them; the job is then sealed the next
+// time its owner sends the key.
+func (s *Service) Hold(jobID string, key []byte) {
+	s.keysMu.Lock()
+	s.keys[jobID] = append([]byte(nil), key...)
+	s.keysMu.Unlock()
+}
+
+// Forget drops a held key
+func (s *Service) Forget(jobID string) {
+	s.keysMu.Lock()
+	if key, ok := s.keys[jobID]; ok {
+		clear(key)
+		delete(s.keys, jobID)
+	}
+	s.keysMu.Unlock()
+}
+
+func (s *Service) heldKey(jobID string) []byte {
+	s.keysMu.Lock()
+	defer s.keysMu.Unlock()
+	return s.keys[jobID]
+}
+
+// Verify reports whether key is the one the job was submitted with
+func Verify(job *models.TranscriptionJob, key []byte) bool {
+	return job.EncryptionKeyHash != nil && *job.EncryptionKeyHash == Fingerprint(key)
+}
+
+// Track seals encrypted jobs as they complete and drops the keys of jobs
+// that fail. It returns a function that stops tracking.
+func (s *Service) Track() func() {
+	return jobstate.Subscribe(func(event jobstate.Event) {
+		switch event.To {
+		case models.StatusCompleted:
+			key := s.heldKey(event.JobID)
+			if key == nil {
+				return
+			}
+			go func() {
+				if err := s.Seal(context.Background(), event.JobID, key); err != nil {
+					logger.Error("Failed to encrypt job", "job_id", event.JobID, "error", err)
+				}
+			}()
+		case models.StatusFailed:
+			s.Forget(event.JobID)
+		}
+	})
+}
+
+// Seal encrypts a completed job's audio files and transcripts with key,
+// removes the plaintext and forgets the held key. Jobs that are not
+// encrypted, not completed or already sealed are left alone.
+func (s *Service) Seal(ctx context.Context, jobID string, key []byte) error {
+	var job models.TranscriptionJob
+	if err := s.conn().Preload("MultiTrackFiles").Where("id = ?", jobID).First(&job).Error; err != nil {
+		return err
+	}
+	if !job.Encrypted || job.EncryptedAt != nil || job.Status != models.StatusCompleted {
+		return nil
+	}
+	if !Verify(&job, key) {
+		return ErrWrongKey
+	}
+
+	// Seal each file next to the original; the originals are only removed
+	// once the job points at the sealed copies
+	sealedPaths := make(map[string]string)
+	var created []string
+	sealFile := func(path string) (string, error) {
+		if path == "" || strings.HasSuffix(path, sealedExt) {
+			return path, nil
+		}
+		if sealed, ok := sealedPaths[path]; ok {
+			return sealed, nil
+		}
+		if !fsys.Exists(s.fs, path) {
+			return path, nil
+		}
+		sealed := path + sealedExt
+		if err := s.sealFile(path, sealed, key); err != nil {
+			return "", err
+		}
+		sealedPaths[path] = sealed
+		created = append(created, sealed)
+		return sealed, nil
+	}
+	undo := func() {
+		for _, path := range created {
+			s.fs.Remove(path)
+		}
+	}
+
+	updates := map[string]interface{}{"encrypted_at": s.clock.Now()}
+	audioPath, err := sealFile(job.AudioPath)
+	if err != nil {
+		undo()
+		return err
+	}
+	updates["audio_path"] = audioPath
+	if job.MergedAudioPath != nil {
+		mergedPath, err := sealFile(*job.MergedAudioPath)
+		if err != nil {
+			undo()
+			return err
+		}
+		updates["merged_audio_path"] = mergedPath
+	}
+	trackPaths := make(map[uint]string)
+	for _, track := range job.MultiTrackFiles {
+		trackPath, err := sealFile(track.FilePath)
+		if err != nil {
+			undo()
+			return err
+		}
+		trackPaths[track.ID] = trackPath
+	}
+
+	for column, value := range map[string]*string{
+		"transcript":             job.Transcript,
+		"summary":                job.Summary,
+		"individual_transcripts": job.IndividualTranscripts,
+	} {
+		if value == nil || IsSealedText(*value) {
+			continue
+		}
+		sealed, err := SealText(*value, key)
+		if err != nil {
+			undo()
+			return err
+		}
+		updates[column] = sealed
+	}
+
+	err = s.conn().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.TranscriptionJob{}).Where("id = ?", jobID).Updates(updates).Error; err != nil {
+			return err
+		}
+		for trackID, trackPath := range trackPaths {
+			if err := tx.Model(&models.MultiTrackFile{}).Where("id = ?", trackID).Update("file_path", trackPath).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		undo()
+		return err
+	}
+
+	for original := range sealedPaths {
+		if err := s.fs.Remove(original); err != nil {
+			logger.Warn("Failed to remove plaintext after encrypting", "job_id", jobID, "path", original, "error", err)
+		}
+	}
+	s.Forget(jobID)
+	logger.JobEvent(logger.WithJobID(ctx, jobID), jobID, EventSealed, "files", len(sealedPaths))
+	if s.onSealed != nil {
+		s.onSealed(jobID)
+	}
+	return nil
+}
+
+// sealFile writes an encrypted copy of src to dst
+func (s *Service) sealFile(src, dst string, key []byte) error {
+	in, err := s.fs.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	pr, pw := io.Pipe()
+	go func() {
+		w, err := NewWriter(pw, key)
+		if err == nil {
+			_, err = io.Copy(w, in)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		pw.CloseWithError(err)
+	}()
+	if _, err := fsys.WriteFileAtomic(s.fs, s.tempDir, dst, pr); err != nil {
+		pr.CloseWithError(err)
+		return fmt.Errorf("failed to encrypt %s: %w", src, err)
+	}
+	return nil
+}
+
+// OpenFile opens a sealed file for reading with key. The returned reader
+// seeks within the plaintext; close the returned closer when done.
+func OpenFile(fs fsys.FS, path string, key []byte) (*Reader, io.Closer, error) {
+	f, err := fs.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	info, err := fs.Stat(path)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	r, err := NewReader(fsys.ReaderAt(f), info.Size(), key)
+	if err != nil {
+		f.Close()
+		return nil, nil, err
+	}
+	return r, f, nil
+}
+
+// IsSealedPath reports whether a file path points at a sealed file
+func IsSealedPath(path string) bool {
+	return strings.HasSuffix(path, sealedExt)
+}
+
+// PlainName returns the name a sealed file had before sealing
+func PlainName(path string) string {
+	return strings.TrimSuffix(path, sealedExt)
+}
+
+// Hold keeps a job's key using the default service
+func Hold(jobID string, key []byte) {
+	Default.Hold(jobID, key)
+}
@@ -0,0 +1,303 @@
+// Package jobcrypt keeps a job's audio and transcripts encrypted at rest with
+// a key only the submitting client holds. The server sees the key while the
+// job is processed, then seals the job's files and text and forgets it, so
+// reading them afterwards needs the client to send the key again.
+package jobcrypt
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// KeyHeader carries the client's job key, base64 encoded
+const KeyHeader = "X-Job-Key"
+
+// KeySize is the length of a job key in bytes (AES-256)
+const KeySize = 32
+
+// Sealed data starts with magic and a random nonce prefix, followed by
+// chunks of up to chunkSize plaintext bytes, each sealed with AES-GCM. Every
+// chunk but the last is full, and the last one is always present (possibly
+// empty) and authenticated as final, so truncation is detected. Fixed-size
+// chunks also allow seeking without decrypting what comes before.
+const (
+	magic       = "SZE1"
+	prefixSize  = 8
+	headerSize  = len(magic) + prefixSize
+	chunkSize   = 64 * 1024
+	tagSize     = 16
+	sealedChunk = chunkSize + tagSize
+)
+
+// textPrefix marks a sealed database value
+const textPrefix = "sze1:"
+
+// ErrWrongKey means the key does not decrypt the data
+var ErrWrongKey = errors.New("job key does not match")
+
+// ParseKey decodes a job key sent by a client
+func ParseKey(encoded string) ([]byte, error) {
+	encoded = strings.TrimSpace(encoded)
+	for _, encoding := range []*base64.Encoding{base64.StdEncoding, base64.URLEncoding, base64.RawStdEncoding, base64.RawURLEncoding} {
+		if key, err := encoding.DecodeString(encoded); err == nil {
+			if len(key) != KeySize {
+				return nil, fmt.Errorf("job key must be %d bytes, got %d", KeySize, len(key))
+			}
+			return key, nil
+		}
+	}
+	return nil, errors.New("job key must be base64 encoded")
+}
+
+// Fingerprint identifies a key without revealing it; it is what the server stores
+func Fingerprint(key []byte) string {
+	sum := sha256.Sum256(key)
+	return hex.EncodeToString(sum[:])
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+func chunkNonce(prefix []byte, index uint32) []byte {
+	nonce := make([]byte, prefixSize+4)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[prefixSize:], index)
+	return nonce
+}
+
+func chunkAAD(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+// Writer encrypts everything written to it; Close writes the final chunk
+type Writer struct {
+	w      io.Writer
+	aead   cipher.AEAD
+	prefix []byte
+	buf    []byte
+	index  uint32
+	closed bool
+}
+
+// NewWriter returns a Writer sealing into w with key
+func NewWriter(w io.Writer, key []byte) (*Writer, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, prefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+	if _, err := io.WriteString(w, magic); err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(prefix); err != nil {
+		return nil, err
+	}
+	return &Writer{w: w, aead: aead, prefix: prefix, buf: make([]byte, 0, chunkSize)}, nil
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("jobcrypt: write after close")
+	}
+	written := 0
+	for len(p) > 0 {
+		// A full buffer is only flushed once more data arrives, so the last
+		// chunk is never written before Close
+		if len(w.buf) == chunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):chunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+func (w *Writer) flush(final bool) error {
+	sealed := w.aead.Seal(nil, chunkNonce(w.prefix, w.index), w.buf, chunkAAD(final))
+	if _, err := w.w.Write(sealed); err != nil {
+		return err
+	}
+	w.index++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// Close seals the final chunk; it does not close the underlying writer
+func (w *Writer) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// Reader decrypts sealed data with random access
+type Reader struct {
+	r      io.ReaderAt
+	aead   cipher.AEAD
+	prefix []byte
+	sealed int64
+	chunks int64
+	size   int64
+	offset int64
+
+	cached      int64
+	cachedPlain []byte
+}
+
+// NewReader opens sealed data of the given length. It checks the key against
+// the first chunk, returning ErrWrongKey if it does not match.
+func NewReader(r io.ReaderAt, sealedSize int64, key []byte) (*Reader, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	header := make([]byte, headerSize)
+	if sealedSize < int64(headerSize+tagSize) {
+		return nil, errors.New("jobcrypt: data is not sealed")
+	}
+	if _, err := r.ReadAt(header, 0); err != nil {
+		return nil, err
+	}
+	if string(header[:len(magic)]) != magic {
+		return nil, errors.New("jobcrypt: data is not sealed")
+	}
+
+	body := sealedSize - int64(headerSize)
+	chunks := (body + sealedChunk - 1) / sealedChunk
+	if last := body - (chunks-1)*sealedChunk; last < tagSize {
+		return nil, errors.New("jobcrypt: sealed data is truncated")
+	}
+	reader := &Reader{
+		r:      r,
+		aead:   aead,
+		prefix: header[len(magic):],
+		sealed: sealedSize,
+		chunks: chunks,
+		size:   body - chunks*tagSize,
+		cached: -1,
+	}
+	if _, err := reader.chunk(0); err != nil {
+		return nil, err
+	}
+	return reader, nil
+}
+
+// Size returns the length of the plaintext
+func (r *Reader) Size() int64 {
+	return r.size
+}
+
+func (r *Reader) chunk(index int64) ([]byte, error) {
+	if index == r.cached {
+		return r.cachedPlain, nil
+	}
+	start := int64(headerSize) + index*sealedChunk
+	sealed := make([]byte, min(sealedChunk, r.sealed-start))
+	if n, err := r.r.ReadAt(sealed, start); n < len(sealed) {
+		return nil, err
+	}
+	plain, err := r.aead.Open(nil, chunkNonce(r.prefix, uint32(index)), sealed, chunkAAD(index == r.chunks-1))
+	if err != nil {
+		return nil, ErrWrongKey
+	}
+	r.cached, r.cachedPlain = index, plain
+	return plain, nil
+}
+
+func (r *Reader) Read(p []byte) (int, error) {
+	if r.offset >= r.size {
+		return 0, io.EOF
+	}
+	plain, err := r.chunk(r.offset / chunkSize)
+	if err != nil {
+		return 0, err
+	}
+	n := copy(p, plain[r.offset%chunkSize:])
+	r.offset += int64(n)
+	return n, nil
+}
+
+// Seek moves within the plaintext
+func (r *Reader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.offset
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("jobcrypt: invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("jobcrypt: negative position")
+	}
+	r.offset = offset
+	return offset, nil
+}
+
+// SealText encrypts a database value
+func SealText(plaintext string, key []byte) (string, error) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, key)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.WriteString(w, plaintext); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	return textPrefix + base64.StdEncoding.EncodeToString(buf.Bytes()), nil
+}
+
+// OpenText decrypts a value sealed by SealText
+func OpenText(sealed string, key []byte) (string, error) {
+	if !IsSealedText(sealed) {
+		return "", errors.New("jobcrypt: value is not sealed")
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(sealed, textPrefix))
+	if err != nil {
+		return "", err
+	}
+	r, err := NewReader(bytes.NewReader(data), int64(len(data)), key)
+	if err != nil {
+		return "", err
+	}
+	plain, err := io.ReadAll(r)
+	if err != nil {
+		return "", err
+	}
+	return string(plain), nil
+}
+
+// IsSealedText reports whether a database value was sealed by SealText
+func IsSealedText(value string) bool {
+	return strings.HasPrefix(value, textPrefix)
+}
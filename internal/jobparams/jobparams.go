@@ -0,0 +1,222 @@
+// Package jobparams holds the server's defaults and limits for the WhisperX
+// options a job may set: model, batch size, compute type, beam size,
+// temperature and voice activity detection. A job's parameters are filled
+// from the defaults where it leaves them out and checked against the limits
+// before it is queued, so one job cannot ask for more than the host allows.
+package jobparams
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"synthezia/internal/config"
+	"synthezia/internal/models"
+)
+
+// ComputeTypes are the CTranslate2 compute types WhisperX accepts
+var ComputeTypes = []string{"default", "auto", "int8", "int8_float16", "int8_float32", "int8_bfloat16", "int16", "float16", "bfloat16", "float32"}
+
+// Devices are the devices a job may ask for; auto picks CUDA when present
+var Devices = []string{"auto", "cpu", "cuda"}
+
+// VadMethods are the voice activity detectors WhisperX can run
+var VadMethods = []string{"pyannote", "silero"}
+
+// ModelFamilies are the transcription model families a job may pick. A
+// server on the fake backend also allows FakeModelFamily.
+var ModelFamilies = []string{"whisper", "nvidia_parakeet", "nvidia_canary"}
+
+// FakeModelFamily is the canned-transcript family of TRANSCRIPTION_BACKEND=fake
+const FakeModelFamily = "fake"
+
+// maxChunkSize is the longest stretch Whisper transcribes at once, in seconds
+const maxChunkSize = 30
+
+// Options are the defaults a job gets and the limits it must stay within
+type Options struct {
+	Model       string `json:"default_model"`
+	ComputeType string `json:"default_compute_type"`
+	Device      string `json:"default_device"`
+	BatchSize   int    `json:"default_batch_size"`
+	BeamSize    int    `json:"default_beam_size"`
+	// Whisper models jobs may pick; empty allows any
+	Models       []string `json:"models,omitempty"`
+	MaxBatchSize int      `json:"max_batch_size"`
+	// Also bounds best_of, the candidates sampled at a non-zero temperature
+	MaxBeamSize int `json:"max_beam_size"`
+	// The server runs the fake backend, so jobs may pick FakeModelFamily
+	FakeBackend bool `json:"-"`
+}
+
+// Default are the options of a server that configures none
+var Default = Options{
+	Model:        "small",
+	ComputeType:  "float32",
+	Device:       "cpu",
+	BatchSize:    8,
+	BeamSize:     5,
+	MaxBatchSize: 32,
+	MaxBeamSize:  10,
+}
+
+// FromConfig reads the options from the server configuration; settings left
+// empty or zero keep their Default
+func FromConfig(cfg *config.Config) Options {
+	options := Default
+	if cfg.TranscriptionDefaultModel != "" {
+		options.Model = cfg.TranscriptionDefaultModel
+	}
+	if cfg.TranscriptionDefaultComputeType != "" {
+		options.ComputeType = cfg.TranscriptionDefaultComputeType
+	}
+	if cfg.TranscriptionDefaultDevice != "" {
+		options.Device = cfg.TranscriptionDefaultDevice
+	}
+	if cfg.TranscriptionDefaultBatchSize > 0 {
+		options.BatchSize = cfg.TranscriptionDefaultBatchSize
+	}
+	if cfg.TranscriptionDefaultBeamSize > 0 {
+		options.BeamSize = cfg.TranscriptionDefaultBeamSize
+	}
+	if cfg.TranscriptionMaxBatchSize > 0 {
+		options.MaxBatchSize = cfg.TranscriptionMaxBatchSize
+	}
+	if cfg.TranscriptionMaxBeamSize > 0 {
+		options.MaxBeamSize = cfg.TranscriptionMaxBeamSize
+	}
+	options.FakeBackend = cfg.TranscriptionBackend == FakeModelFamily
+	for _, model := range strings.Split(cfg.TranscriptionModels, ",") {
+		if model = strings.TrimSpace(model); model != "" {
+			options.Models = append(options.Models, model)
+		}
+	}
+	return options
+}
+
+// Check reports options whose defaults break their own limits
+func (o Options) Check() error {
+	return o.Validate(o.Defaults())
+}
+
+// Defaults returns the parameters of a job that sets none; the options Fill
+// covers come from the server's defaults
+func (o Options) Defaults() models.WhisperXParams {
+	params := models.WhisperXParams{
+		ModelFamily:                    "whisper",
+		OutputFormat:                   "all",
+		Verbose:                        true,
+		Task:                           "transcribe",
+		InterpolateMethod:              "nearest",
+		DiarizeModel:                   "pyannote/speaker-diarization-3.1",
+		Patience:                       1.0,
+		LengthPenalty:                  1.0,
+		Fp16:                           true,
+		TemperatureIncrementOnFallback: 0.2,
+		CompressionRatioThreshold:      2.4,
+		LogprobThreshold:               -1.0,
+		NoSpeechThreshold:              0.6,
+		SegmentResolution:              "sentence",
+		AttentionContextLeft:           256,
+		AttentionContextRight:          256,
+	}
+	o.Fill(&params)
+	return params
+}
+
+// Fill sets the options a job left empty, or zero where zero is meaningless,
+// to the server's defaults
+func (o Options) Fill(params *models.WhisperXParams) {
+	if params.ModelFamily == "" {
+		params.ModelFamily = "whisper"
+	}
+	if params.Model == "" {
+		params.Model = o.Model
+	}
+	if params.ComputeType == "" {
+		params.ComputeType = o.ComputeType
+	}
+	if params.Device == "" {
+		params.Device = o.Device
+	}
+	if params.BatchSize == 0 {
+		params.BatchSize = o.BatchSize
+	}
+	if params.BeamSize == 0 {
+		params.BeamSize = o.BeamSize
+	}
+	if params.BestOf == 0 {
+		params.BestOf = min(5, o.MaxBeamSize)
+	}
+	if params.ChunkSize == 0 {
+		params.ChunkSize = maxChunkSize
+	}
+	if params.VadMethod == "" {
+		params.VadMethod = "pyannote"
+	}
+	if params.VadOnset == 0 {
+		params.VadOnset = 0.5
+	}
+	if params.VadOffset == 0 {
+		params.VadOffset = 0.363
+	}
+}
+
+// Validate checks a job's parameters against the limits, naming every
+// option that is out of bounds
+func (o Options) Validate(params models.WhisperXParams) error {
+	var problems []string
+	add := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	families := ModelFamilies
+	if o.FakeBackend {
+		families = append(slices.Clone(ModelFamilies), FakeModelFamily)
+	}
+	if !slices.Contains(families, params.ModelFamily) {
+		add("model_family must be one of %s", strings.Join(families, ", "))
+	}
+	if params.ModelFamily == "whisper" && len(o.Models) > 0 && !slices.Contains(o.Models, params.Model) {
+		add("model must be one of %s", strings.Join(o.Models, ", "))
+	}
+	if params.BatchSize < 1 || params.BatchSize > o.MaxBatchSize {
+		add("batch_size must be between 1 and %d", o.MaxBatchSize)
+	}
+	if !slices.Contains(ComputeTypes, params.ComputeType) {
+		add("compute_type must be one of %s", strings.Join(ComputeTypes, ", "))
+	}
+	if !slices.Contains(Devices, params.Device) {
+		add("device must be one of %s", strings.Join(Devices, ", "))
+	}
+	if params.BeamSize < 1 || params.BeamSize > o.MaxBeamSize {
+		add("beam_size must be between 1 and %d", o.MaxBeamSize)
+	}
+	if params.BestOf < 1 || params.BestOf > o.MaxBeamSize {
+		add("best_of must be between 1 and %d", o.MaxBeamSize)
+	}
+	if params.Temperature < 0 || params.Temperature > 1 {
+		add("temperature must be between 0 and 1")
+	}
+	if params.TemperatureIncrementOnFallback < 0 || params.TemperatureIncrementOnFallback > 1 {
+		add("temperature_increment_on_fallback must be between 0 and 1")
+	}
+	if !slices.Contains(VadMethods, params.VadMethod) {
+		add("vad_method must be one of %s", strings.Join(VadMethods, ", "))
+	}
+	if params.VadOnset <= 0 || params.VadOnset >= 1 {
+		add("vad_onset must be between 0 and 1")
+	}
+	if params.VadOffset <= 0 || params.VadOffset >= 1 {
+		add("vad_offset must be between 0 and 1")
+	}
+	if params.ChunkSize < 1 || params.ChunkSize > maxChunkSize {
+		add("chunk_size must be between 1 and %d seconds", maxChunkSize)
+	}
+
+	if len(problems) > 0 {
+		return errors.New(strings.Join(problems, "; "))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package jobstate
+
+import (
+	"context"
+	"encoding/json"
+
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+)
+
+// eventNames maps each status to the lifecycle event logged on entering it
+var eventNames = map[models.JobStatus]string{
+	models.StatusUploaded:   logger.JobEventUploaded,
+	models.StatusPending:    logger.JobEventQueued,
+	models.StatusProcessing: logger.JobEventTranscribing,
+	models.StatusCompleted:  logger.JobEventCompleted,
+	models.StatusFailed:     logger.JobEventFailed,
+	models.StatusPaused:     logger.JobEventPaused,
+}
+
+// EventName returns the lifecycle event for entering a status
+func EventName(status models.JobStatus) string {
+	if name, ok := eventNames[status]; ok {
+		return name
+	}
+	return string(status)
+}
+
+// Created records the start of a new job's timeline. Call it once the job
+// row has been committed; a job created past the uploaded stage also gets
+// the event for its initial status.
+func Created(ctx context.Context, jobID string, status models.JobStatus, args ...any) {
+	logger.JobEvent(ctx, jobID, logger.JobEventUploaded, args...)
+	if status != models.StatusUploaded {
+		logger.JobEvent(ctx, jobID, EventName(status))
+	}
+}
+
+// RecordEvents stores every job event in the job_events table until the
+// returned function is called
+func (s *Service) RecordEvents() func() {
+	logger.SetJobEventHook(func(ctx context.Context, event logger.JobEventRecord) {
+		row := models.JobEvent{JobID: event.JobID, Event: event.Event, CreatedAt: event.Time}
+		if len(event.Attrs) > 0 {
+			if data, err := json.Marshal(event.Attrs); err == nil {
+				attrs := string(data)
+				row.Attrs = &attrs
+			}
+		}
+		if err := s.conn().WithContext(ctx).Create(&row).Error; err != nil {
+			logger.Warn("Failed to store job event", "job_id", event.JobID, "event", event.Event, "error", err)
+		}
+	})
+	return func() { logger.SetJobEventHook(nil) }
+}
+
+// Timeline returns a job's stored events, oldest first
+func (s *Service) Timeline(jobID string) ([]models.JobEvent, error) {
+	var events []models.JobEvent
+	err := s.conn().Where("job_id = ?", jobID).Order("created_at ASC, id ASC").Find(&events).Error
+	return events, err
+}
+
+// RecordEvents stores job events using the default service
+func RecordEvents() func() {
+	return Default.RecordEvents()
+}
+
+// Timeline returns a job's stored events using the default service
+func Timeline(jobID string) ([]models.JobEvent, error) {
+	return Default.Timeline(jobID)
+}
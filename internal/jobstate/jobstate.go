@@ -0,0 +1,241 @@
+// Package jobstate owns every transcription job status change. Transitions are
+// validated against a state machine, applied with a compare-and-set inside a
+// transaction, and announced to subscribers after commit so history, webhook
+// and outbox consumers all see the same sequence of events.
+package jobstate
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Event describes a committed status change
+type Event struct {
+	JobID string           `json:"job_id"`
+	From  models.JobStatus `json:"from"`
+	To    models.JobStatus `json:"to"`
+	Error string           `json:"error,omitempty"`
+	At    time.Time        `json:"at"`
+}
+
+// Listener receives events after the transition has been committed. Listeners
+// run synchronously and must not block.
+type Listener func(Event)
+
+// ErrInvalidTransition is matched by TransitionError via errors.Is
+var ErrInvalidTransition = errors.New("invalid job status transition")
+
+// ErrConflict means the status changed concurrently and the transition was not applied
+var ErrConflict = errors.New("job status changed concurrently")
+
+// TransitionError reports a transition the state machine does not allow
+type TransitionError struct {
+	JobID string
+	From  models.JobStatus
+	To    models.JobStatus
+}
+
+func (e *TransitionError) Error() string {
+	return fmt.Sprintf("job %s: cannot move from %s to %s", e.JobID, e.From, e.To)
+}
+
+func (e *TransitionError) Is(target error) bool {
+	return target == ErrInvalidTransition
+}
+
+// transitions lists the allowed moves. Staying in the same status is always
+// allowed and only applies the extra fields.
+var transitions = map[models.JobStatus][]models.JobStatus{
+	models.StatusUploaded:   {models.StatusPending, models.StatusProcessing, models.StatusFailed},
+	models.StatusPending:    {models.StatusProcessing, models.StatusUploaded, models.StatusFailed},
+	models.StatusProcessing: {models.StatusCompleted, models.StatusFailed, models.StatusPending, models.StatusPaused},
+	models.StatusCompleted:  {models.StatusPending},
+	models.StatusFailed:     {models.StatusPending},
+	models.StatusPaused:     {models.StatusPending, models.StatusFailed},
+}
+
+// CanTransition reports whether a job may move from one status to another
+func CanTransition(from, to models.JobStatus) bool {
+	if from == to {
+		return true
+	}
+	for _, allowed := range transitions[from] {
+		if allowed == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Option adjusts a transition
+type Option func(*transition)
+
+type transition struct {
+	fields map[string]interface{}
+	errMsg *string
+	txFns  []func(tx *gorm.DB) error
+}
+
+// WithError records an error message on the job
+func WithError(msg string) Option {
+	return func(t *transition) {
+		t.errMsg = &msg
+		t.fields["error_message"] = msg
+	}
+}
+
+// WithFields writes extra columns in the same update as the status
+func WithFields(fields map[string]interface{}) Option {
+	return func(t *transition) {
+		for key, value := range fields {
+			t.fields[key] = value
+		}
+	}
+}
+
+// WithTx runs additional writes inside the transition's transaction, after
+// the status has been changed
+func WithTx(fn func(tx *gorm.DB) error) Option {
+	return func(t *transition) {
+		t.txFns = append(t.txFns, fn)
+	}
+}
+
+// Service applies status transitions and fans out events
+type Service struct {
+	db        *gorm.DB
+	mu        sync.RWMutex
+	listeners map[int]Listener
+	nextID    int
+}
+
+// NewService creates a state service; a nil db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, listeners: make(map[int]Listener)}
+}
+
+// Default is the process-wide state service
+var Default = NewService(nil)
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Subscribe registers a listener and returns a function that removes it
+func (s *Service) Subscribe(listener Listener) func() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	id := s.nextID
+	s.nextID++
+	s.listeners[id] = listener
+	return func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		delete(s.listeners, id)
+	}
+}
+
+// Transition moves a job to a new status. The current status is re-read and
+// compared inside the transaction, so concurrent writers cannot both win.
+// Staying in the same status applies the options without emitting an event.
+func (s *Service) Transition(jobID string, to models.JobStatus, opts ...Option) (Event, error) {
+	t := &transition{fields: map[string]interface{}{}}
+	for _, opt := range opts {
+		opt(t)
+	}
+
+	event := Event{JobID: jobID, To: to}
+	err := s.conn().Transaction(func(tx *gorm.DB) error {
+		var job models.TranscriptionJob
+		if err := tx.Select("id", "status").Where("id = ?", jobID).First(&job).Error; err != nil {
+			return err
+		}
+		event.From = job.Status
+
+		if !CanTransition(job.Status, to) {
+			return &TransitionError{JobID: jobID, From: job.Status, To: to}
+		}
+
+		updates := map[string]interface{}{"status": to}
+		for key, value := range t.fields {
+			updates[key] = value
+		}
+		result := tx.Model(&models.TranscriptionJob{}).
+			Where("id = ? AND status = ?", jobID, job.Status).
+			Updates(updates)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrConflict
+		}
+
+		for _, fn := range t.txFns {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return event, fmt.Errorf("failed to move job %s to %s: %w", jobID, to, err)
+	}
+
+	if event.From == event.To {
+		return event, nil
+	}
+
+	event.At = time.Now()
+	if t.errMsg != nil {
+		event.Error = *t.errMsg
+	}
+	eventArgs := []any{"from", string(event.From)}
+	if event.Error != "" {
+		eventArgs = append(eventArgs, "error", event.Error)
+	}
+	logger.JobEvent(context.Background(), jobID, EventName(to), eventArgs...)
+	s.emit(event)
+	return event, nil
+}
+
+func (s *Service) emit(event Event) {
+	s.mu.RLock()
+	listeners := make([]Listener, 0, len(s.listeners))
+	for _, listener := range s.listeners {
+		listeners = append(listeners, listener)
+	}
+	s.mu.RUnlock()
+
+	for _, listener := range listeners {
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					logger.Error("Job state listener panicked", "job_id", event.JobID, "panic", r)
+				}
+			}()
+			listener(event)
+		}()
+	}
+}
+
+// Transition moves a job to a new status using the default service
+func Transition(jobID string, to models.JobStatus, opts ...Option) (Event, error) {
+	return Default.Transition(jobID, to, opts...)
+}
+
+// Subscribe registers a listener on the default service
+func Subscribe(listener Listener) func() {
+	return Default.Subscribe(listener)
+}
@@ -0,0 +1,97 @@
+// Package jobversion keeps concurrent edits of a job from overwriting each
+// other. Every edit bumps the job's version; an edit made against an older
+// version than the job's current one fails with a Conflict instead of
+// silently replacing the newer change.
+package jobversion
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"synthezia/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// ErrConflict means the job changed since the version the edit was based on
+var ErrConflict = errors.New("the job was changed by someone else")
+
+// Conflict reports the job's version when an edit based on an older one was
+// refused
+type Conflict struct {
+	Expected int
+	Current  int
+}
+
+func (e *Conflict) Error() string {
+	return fmt.Sprintf("%v: edit was based on version %d, the job is at version %d", ErrConflict, e.Expected, e.Current)
+}
+
+func (e *Conflict) Is(target error) bool { return target == ErrConflict }
+
+// Update applies updates to a job and bumps its version, returning the new
+// version. With expected set the job must still be at that version. A job
+// that does not exist is gorm.ErrRecordNotFound.
+func Update(tx *gorm.DB, jobID string, expected *int, updates map[string]interface{}) (int, error) {
+	values := make(map[string]interface{}, len(updates)+1)
+	for column, value := range updates {
+		values[column] = value
+	}
+	values["version"] = gorm.Expr("version + 1")
+
+	var version int
+	// The version is read back in the same transaction as the bump, so
+	// another edit cannot land in between and be reported instead
+	err := tx.Transaction(func(tx *gorm.DB) error {
+		query := tx.Model(&models.TranscriptionJob{}).Where("id = ?", jobID)
+		if expected != nil {
+			query = query.Where("version = ?", *expected)
+		}
+		result := query.Updates(values)
+		if result.Error != nil {
+			return result.Error
+		}
+
+		var job models.TranscriptionJob
+		if err := tx.Select("version").Where("id = ?", jobID).First(&job).Error; err != nil {
+			return err
+		}
+		if result.RowsAffected == 0 && expected != nil {
+			return &Conflict{Expected: *expected, Current: job.Version}
+		}
+		version = job.Version
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	return version, nil
+}
+
+// Bump bumps a job's version alone, for edits stored outside the job row
+// such as speaker mappings
+func Bump(tx *gorm.DB, jobID string, expected *int) (int, error) {
+	return Update(tx, jobID, expected, nil)
+}
+
+// ParseETag reads a version from an If-Match value such as "3", W/"3" or 3.
+// Empty and * match any version and give nil.
+func ParseETag(value string) (*int, error) {
+	value = strings.TrimSpace(value)
+	if value == "" || value == "*" {
+		return nil, nil
+	}
+	value = strings.Trim(strings.TrimPrefix(value, "W/"), `"`)
+	version, err := strconv.Atoi(value)
+	if err != nil || version < 1 {
+		return nil, fmt.Errorf("invalid job version %q", value)
+	}
+	return &version, nil
+}
+
+// ETag formats a version as an entity tag
+func ETag(version int) string {
+	return `"` + strconv.Itoa(version) + `"`
+}
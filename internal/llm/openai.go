@@ -31,6 +31,14 @@ func NewOpenAIService(apiKey string) *OpenAIService {
 	}
 }
 
+// NewOpenAICompatibleService creates a service for any server speaking the
+// OpenAI API, such as vLLM, LM Studio or a proxy, at baseURL (ending in /v1)
+func NewOpenAICompatibleService(baseURL, apiKey string) *OpenAIService {
+	s := NewOpenAIService(apiKey)
+	s.baseURL = strings.TrimRight(baseURL, "/")
+	return s
+}
+
 // ChatMessage represents a chat message for OpenAI API
 type ChatMessage struct {
 	Role    string `json:"role"`
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Collection groups jobs that belong together, such as the recordings of one
+// client, show or case. A job is in at most one collection.
+type Collection struct {
+	ID          uint    `json:"id" gorm:"primaryKey"`
+	Name        string  `json:"name" gorm:"type:varchar(255);not null;uniqueIndex"`
+	Description *string `json:"description,omitempty" gorm:"type:text"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Audio conversion statuses
+const (
+	ConversionPending    = "pending"
+	ConversionProcessing = "processing"
+	ConversionCompleted  = "completed"
+	ConversionFailed     = "failed"
+)
+
+// AudioConversion is a request to re-encode a job's audio or an uploaded
+// file, and the artifact it produced
+type AudioConversion struct {
+	ID     string  `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	JobID  *string `json:"job_id,omitempty" gorm:"type:varchar(36);index"`
+	UserID *uint   `json:"user_id,omitempty" gorm:"index"`
+	// SourceName is the uploaded file's name or the job's audio file name
+	SourceName string `json:"source_name" gorm:"type:text"`
+	SourcePath string `json:"-" gorm:"type:text;not null"`
+	// UploadedSource is set when SourcePath is a copy of an upload, removed
+	// once the conversion finishes
+	UploadedSource bool   `json:"-" gorm:"not null;default:false"`
+	Format         string `json:"format" gorm:"type:varchar(10);not null"`
+	Bitrate        string `json:"bitrate,omitempty" gorm:"type:varchar(10)"`
+
+	Status      string     `json:"status" gorm:"type:varchar(20);not null;default:'pending';index"`
+	OutputPath  string     `json:"-" gorm:"type:text"`
+	OutputSize  int64      `json:"output_size,omitempty"`
+	Error       *string    `json:"error,omitempty" gorm:"type:text"`
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// BeforeCreate ensures AudioConversion has a UUID primary key
+func (c *AudioConversion) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Post-processing statuses
+const (
+	InsightsPending   = "pending"
+	InsightsRunning   = "running"
+	InsightsCompleted = "completed"
+	InsightsFailed    = "failed"
+)
+
+// TranscriptInsights is what the post-processing LLM made of a finished
+// transcript: a summary, action items and chapter markers. A transcription
+// has at most one; processing it again replaces it.
+type TranscriptInsights struct {
+	ID              string  `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	TranscriptionID string  `json:"transcription_id" gorm:"type:varchar(36);not null;uniqueIndex"`
+	Model           string  `json:"model" gorm:"type:varchar(255);not null;default:''"`
+	Status          string  `json:"status" gorm:"type:varchar(20);not null;index"`
+	Error           *string `json:"error,omitempty" gorm:"type:text"`
+
+	Summary     *string `json:"summary,omitempty" gorm:"type:text"`
+	ActionItems *string `json:"-" gorm:"type:text"` // JSON array of postprocess.ActionItem
+	Chapters    *string `json:"-" gorm:"type:text"` // JSON array of postprocess.Chapter
+
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// BeforeCreate ensures TranscriptInsights has a UUID primary key
+func (ti *TranscriptInsights) BeforeCreate(tx *gorm.DB) error {
+	if ti.ID == "" {
+		ti.ID = uuid.New().String()
+	}
+	return nil
+}
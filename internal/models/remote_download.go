@@ -0,0 +1,51 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Remote download statuses
+const (
+	DownloadPending     = "pending"
+	DownloadDownloading = "downloading"
+	DownloadCompleted   = "completed"
+	DownloadFailed      = "failed"
+)
+
+// Remote download sources
+const (
+	DownloadSourceHTTP    = "http"
+	DownloadSourceYouTube = "youtube"
+)
+
+// RemoteDownload fetches the media of a job submitted by URL. The job waits
+// in the uploaded state without audio until the download completes.
+type RemoteDownload struct {
+	ID     string `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	JobID  string `json:"job_id" gorm:"type:varchar(36);not null;uniqueIndex"`
+	Source string `json:"source" gorm:"type:varchar(20);not null"`
+	// URL is kept out of responses: presigned URLs carry credentials
+	URL  string `json:"-" gorm:"type:text;not null"`
+	Host string `json:"host" gorm:"type:varchar(255)"`
+
+	Status     string  `json:"status" gorm:"type:varchar(20);not null;index"`
+	BytesDone  int64   `json:"bytes_done"`
+	BytesTotal int64   `json:"bytes_total,omitempty"`
+	Progress   float64 `json:"progress"` // 0-100; stays 0 while the size is unknown
+	Error      *string `json:"error,omitempty" gorm:"type:text"`
+
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// BeforeCreate ensures RemoteDownload has a UUID primary key
+func (d *RemoteDownload) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
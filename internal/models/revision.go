@@ -0,0 +1,38 @@
+package models
+
+import "time"
+
+// Where a transcript revision came from
+const (
+	RevisionSourceTranscription = "transcription" // The transcript as the models produced it
+	RevisionSourceEdit          = "edit"
+	RevisionSourceRevert        = "revert"
+)
+
+// TranscriptRevision is one version of a job's transcript. Edits add a
+// revision instead of overwriting the transcript, and the first edit also
+// records the transcript it started from, so the machine original is kept.
+// The job's Transcript is always the latest revision.
+type TranscriptRevision struct {
+	ID              uint   `json:"id" gorm:"primaryKey"`
+	TranscriptionID string `json:"transcription_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_transcript_revisions_number"`
+	Number          int    `json:"number" gorm:"not null;uniqueIndex:idx_transcript_revisions_number"` // From 1, in order
+	Source          string `json:"source" gorm:"type:varchar(20);not null"`
+
+	// Who made the revision: a username, or the API key for key requests
+	Author string  `json:"author,omitempty" gorm:"type:varchar(255)"`
+	Note   *string `json:"note,omitempty" gorm:"type:text"`
+
+	// The segments an edit changed, and the revision a revert went back to
+	SegmentIDs *string `json:"segment_ids,omitempty" gorm:"type:text"` // Comma-separated
+	RevertedTo *int    `json:"reverted_to,omitempty"`
+
+	// The whole transcript as of this revision
+	Transcript string `json:"-" gorm:"type:text;not null"`
+
+	// The job's version once the revision was made, set only in the response
+	// to the edit or revert that made it
+	JobVersion int `json:"job_version,omitempty" gorm:"-"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
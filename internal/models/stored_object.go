@@ -0,0 +1,27 @@
+package models
+
+import "time"
+
+// What a stored object holds
+const (
+	StoredAudio      = "audio"      // The job's audio as submitted
+	StoredProject    = "project"    // An Audacity project file
+	StoredTrack      = "track"      // One track of a multi-track job
+	StoredMerged     = "merged"     // The mixdown of a multi-track job
+	StoredTranscript = "transcript" // The transcript as JSON
+)
+
+// StoredObject is a file of a job kept in the storage backend
+type StoredObject struct {
+	ID    uint   `json:"id" gorm:"primaryKey"`
+	JobID string `json:"job_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_stored_object"`
+	Kind  string `json:"kind" gorm:"type:varchar(20);not null"`
+	Key   string `json:"key" gorm:"type:text;not null"`
+	// LocalPath is where the file lives on disk while the job needs it, and
+	// where it is restored to; empty for the transcript
+	LocalPath string `json:"local_path" gorm:"type:text;not null;uniqueIndex:idx_stored_object"`
+	Size      int64  `json:"size"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
@@ -49,3 +49,46 @@ func (s *Summary) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// Summary regeneration statuses
+const (
+	RegenerationPending   = "pending"
+	RegenerationRunning   = "running"
+	RegenerationCompleted = "completed"
+	RegenerationFailed    = "failed"
+)
+
+// SummaryRegeneration is a background run that re-applies a template to
+// transcriptions after the template changed
+type SummaryRegeneration struct {
+	ID          string                    `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	TemplateID  string                    `json:"template_id" gorm:"type:varchar(36);index;not null"`
+	Model       string                    `json:"model" gorm:"type:varchar(255);not null"`
+	Status      string                    `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	Total       int                       `json:"total" gorm:"not null;default:0"`
+	Succeeded   int                       `json:"succeeded" gorm:"not null;default:0"`
+	Failed      int                       `json:"failed" gorm:"not null;default:0"`
+	CreatedAt   time.Time                 `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time                 `json:"updated_at" gorm:"autoUpdateTime"`
+	CompletedAt *time.Time                `json:"completed_at,omitempty"`
+	Items       []SummaryRegenerationItem `json:"items,omitempty" gorm:"foreignKey:RegenerationID"`
+}
+
+// BeforeCreate ensures SummaryRegeneration has a UUID primary key
+func (r *SummaryRegeneration) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// SummaryRegenerationItem tracks one transcription within a regeneration run
+type SummaryRegenerationItem struct {
+	ID              uint      `json:"id" gorm:"primaryKey"`
+	RegenerationID  string    `json:"regeneration_id" gorm:"type:varchar(36);index;not null"`
+	TranscriptionID string    `json:"transcription_id" gorm:"type:varchar(36);not null"`
+	Status          string    `json:"status" gorm:"type:varchar(20);not null;default:'pending'"`
+	SummaryID       *string   `json:"summary_id,omitempty" gorm:"type:varchar(36)"`
+	Error           *string   `json:"error,omitempty" gorm:"type:text"`
+	UpdatedAt       time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
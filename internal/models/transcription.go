@@ -21,9 +21,42 @@ type TranscriptionJob struct {
 	AupFilePath      *string   `json:"aup_file_path,omitempty" gorm:"type:text"`
 	MultiTrackFolder *string   `json:"multi_track_folder,omitempty" gorm:"type:text"`
 	MergedAudioPath  *string   `json:"merged_audio_path,omitempty" gorm:"type:text"`
-	MergeStatus           string `json:"merge_status" gorm:"type:varchar(20);default:'none'"` // none, pending, processing, completed, failed
+	MergeStatus           string `json:"merge_status" gorm:"type:varchar(20);default:'none'"` // none, pending, merge_queued, processing, completed, failed, cancelled
 	MergeError            *string `json:"merge_error,omitempty" gorm:"type:text"`
+	MergeAllTracks        bool    `json:"merge_all_tracks" gorm:"type:boolean;default:false"` // Mix unsoloed tracks even when the project solos some
 	IndividualTranscripts *string `json:"individual_transcripts,omitempty" gorm:"type:text"` // JSON-serialized map[string]*string
+	RecordedAt            *time.Time `json:"recorded_at,omitempty" gorm:"index"`
+	RecordedAtSource      *string `json:"recorded_at_source,omitempty" gorm:"type:varchar(20)"` // metadata, filename, manual
+	Tags                  *string `json:"tags,omitempty" gorm:"type:text"`           // JSON-serialized []string
+	CollectionID          *uint   `json:"collection_id,omitempty" gorm:"index"`      // Collection the job was filed in, if any
+	AudioMetadata         *string `json:"audio_metadata,omitempty" gorm:"type:text"` // JSON-serialized audio.Tags from the uploaded file
+	AudioDuration         *float64 `json:"audio_duration,omitempty"`                         // Seconds, probed on upload
+	AudioSampleRate       *int     `json:"audio_sample_rate,omitempty"`                      // Hz, probed on upload
+	AudioChannels         *int     `json:"audio_channels,omitempty"`                         // Probed on upload
+	AudioCodec            *string  `json:"audio_codec,omitempty" gorm:"type:varchar(32)"`    // e.g. mp3, aac, pcm_s16le, probed on upload
+	AudioBitRate          *int64   `json:"audio_bit_rate,omitempty"`                         // Bits per second, probed on upload
+	APIKeyID              *uint   `json:"api_key_id,omitempty" gorm:"index"`         // API key that submitted the job, if any
+	UserID                *uint   `json:"user_id,omitempty" gorm:"index"`            // User the job belongs to, whose storage quota it counts against
+	SourceAudioAction     *string    `json:"source_audio_action,omitempty" gorm:"type:varchar(10)"` // keep, delete or proxy once transcribed; nil uses the server default
+	SourceAudioRemovedAt  *time.Time `json:"source_audio_removed_at,omitempty"`                      // When the original audio was deleted or replaced by a proxy
+	RetentionAppliedAt    *time.Time `json:"retention_applied_at,omitempty"`                         // When the retention policy deleted or archived the job's audio
+	OffloadedAt           *time.Time `json:"offloaded_at,omitempty"`                                 // When the job's media was moved to the storage backend
+	ProxyAudioPath        *string    `json:"proxy_audio_path,omitempty" gorm:"type:text"`            // Small Opus copy served for playback
+	WaveformPath          *string    `json:"waveform_path,omitempty" gorm:"type:text"`               // Peaks for drawing the waveform, in audiowaveform .dat format
+	QualityReport         *string    `json:"-" gorm:"type:text"`                                     // JSON-serialized audio.QualityReport of the audio
+	Encrypted             bool       `json:"encrypted" gorm:"type:boolean;default:false"`            // Submitted with a client-held key; content is sealed once processed
+	EncryptionKeyHash     *string    `json:"-" gorm:"type:varchar(64)"`                              // SHA-256 of the client's key, to check keys sent later
+	EncryptedAt           *time.Time `json:"encrypted_at,omitempty"`                                 // When the audio and transcripts were sealed
+	AudioHash             *string    `json:"audio_hash,omitempty" gorm:"type:varchar(64);index"`     // SHA-256 of the file as received, for dropzone ingests
+	DuplicateOf           *string    `json:"duplicate_of,omitempty" gorm:"type:varchar(36);index"`   // Job with the same audio this one was linked to
+	AudioFingerprint      *string    `json:"-" gorm:"type:text"`                                     // Chromaprint fingerprint of the start of the audio, packed by audio.Fingerprint.Encode
+	NearDuplicateOf       *string    `json:"near_duplicate_of,omitempty" gorm:"type:varchar(36);index"` // Existing job with nearly identical audio found on upload
+	RunAfter              *time.Time `json:"run_after,omitempty" gorm:"index"`                          // A pending job is not transcribed before this time
+	ResumeFrom            *float64   `json:"resume_from,omitempty"`                                     // Seconds transcribed before the job was paused; the transcript holds them
+	Version               int        `json:"version" gorm:"not null;default:1"`                         // Bumped by every edit, so edits made from a stale copy can be refused
+	Recoveries            int        `json:"recoveries,omitempty" gorm:"not null;default:0"`            // Times startup requeued the job after a shutdown or crash interrupted it
+	Progress              float64    `json:"progress" gorm:"not null;default:0"`                        // 0-100 share of the audio transcribed, written as the job runs
+	ProcessedAudioSeconds float64    `json:"processed_audio_seconds" gorm:"not null;default:0"`         // Seconds of the audio transcribed so far
 	CreatedAt        time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt        time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 
@@ -34,6 +67,21 @@ type TranscriptionJob struct {
 	MultiTrackFiles []MultiTrackFile `json:"multi_track_files,omitempty" gorm:"foreignKey:TranscriptionJobID"`
 }
 
+// What happens to a job's original audio once its transcript is final
+const (
+	SourceAudioKeep   = "keep"
+	SourceAudioDelete = "delete"
+	SourceAudioProxy  = "proxy" // Replace with a small mono MP3 that can still be played back
+)
+
+// Whether a stereo job is transcribed one channel at a time, each channel
+// being one speaker's microphone
+const (
+	SplitChannelsOff    = "off"
+	SplitChannelsAuto   = "auto"   // Split when the channels carry different signals
+	SplitChannelsAlways = "always" // Split any two-channel recording
+)
+
 // JobStatus represents the status of a transcription job
 type JobStatus string
 
@@ -43,6 +91,7 @@ const (
 	StatusProcessing JobStatus = "processing"
 	StatusCompleted  JobStatus = "completed"
 	StatusFailed     JobStatus = "failed"
+	StatusPaused     JobStatus = "paused" // Stopped by the user; resuming carries on from ResumeFrom
 )
 
 // WhisperXParams contains parameters for WhisperX transcription
@@ -76,6 +125,12 @@ type WhisperXParams struct {
 	NoAlign              bool    `json:"no_align" gorm:"type:boolean;default:false"`
 	ReturnCharAlignments bool    `json:"return_char_alignments" gorm:"type:boolean;default:false"`
 
+	// Clean-up applied to the audio before transcription; any of them also
+	// resamples it to 16 kHz mono
+	Normalize   bool `json:"normalize" gorm:"type:boolean;default:false"`    // EBU R128 loudness normalization
+	TrimSilence bool `json:"trim_silence" gorm:"type:boolean;default:false"` // Timestamps still match the original audio
+	Denoise     bool `json:"denoise" gorm:"type:boolean;default:false"`
+
 	// VAD (Voice Activity Detection) settings
 	VadMethod string  `json:"vad_method" gorm:"type:varchar(20);default:'pyannote'"`
 	VadOnset  float64 `json:"vad_onset" gorm:"type:real;default:0.5"`
@@ -121,6 +176,34 @@ type WhisperXParams struct {
 
 	// Multi-track transcription settings
 	IsMultiTrackEnabled bool `json:"is_multi_track_enabled" gorm:"type:boolean;default:false"`
+
+	// Stereo interviews: transcribe each channel as its own speaker
+	SplitChannels string `json:"split_channels,omitempty" gorm:"type:varchar(10)"` // off (default), auto or always
+
+	// Transcribe only this stretch of the recording, in seconds; a missing
+	// end runs to the end. An existing transcript keeps everything outside it.
+	RangeStart *float64 `json:"range_start,omitempty"`
+	RangeEnd   *float64 `json:"range_end,omitempty"`
+
+	// Languages to translate the finished transcript into, as comma-separated
+	// codes, and the TranslationProvider* doing it (llm by default)
+	TranslateTo         *string `json:"translate_to,omitempty" gorm:"type:text"`
+	TranslationProvider string  `json:"translation_provider,omitempty" gorm:"type:varchar(20)"`
+}
+
+// TimeRange returns the stretch of the recording to transcribe, with an end
+// of 0 for the end of the recording, and whether one was asked for
+func (p WhisperXParams) TimeRange() (start, end float64, ok bool) {
+	if p.RangeStart == nil && p.RangeEnd == nil {
+		return 0, 0, false
+	}
+	if p.RangeStart != nil {
+		start = *p.RangeStart
+	}
+	if p.RangeEnd != nil {
+		end = *p.RangeEnd
+	}
+	return start, end, true
 }
 
 // BeforeCreate sets the ID if not already set
@@ -136,9 +219,11 @@ type User struct {
 	ID                       uint      `json:"id" gorm:"primaryKey"`
 	Username                 string    `json:"username" gorm:"uniqueIndex;not null;type:varchar(50)"`
 	Password                 string    `json:"-" gorm:"not null;type:varchar(255)"`
+	Email                    *string   `json:"email,omitempty" gorm:"uniqueIndex;type:varchar(255)"` // Lower-cased; matches mailed-in recordings to their sender
 	DefaultProfileID         *string   `json:"default_profile_id,omitempty" gorm:"type:varchar(36)"`
 	AutoTranscriptionEnabled bool      `json:"auto_transcription_enabled" gorm:"not null;default:false"`
 	FastFinalizeEnabled      bool      `json:"fast_finalize_enabled" gorm:"not null;default:true"`
+	SourceAudioAction        *string   `json:"source_audio_action,omitempty" gorm:"type:varchar(10)"` // Applied to this user's uploads; nil uses the server default
 	CreatedAt                time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt                time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 }
@@ -157,6 +242,88 @@ type APIKey struct {
 	UpdatedAt time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
 }
 
+// APIKeyUsage counts the traffic of one API key within one hour
+type APIKeyUsage struct {
+	ID           uint      `json:"-" gorm:"primaryKey"`
+	APIKeyID     uint      `json:"api_key_id" gorm:"not null;uniqueIndex:idx_api_key_usage_hour"`
+	Hour         time.Time `json:"hour" gorm:"not null;uniqueIndex:idx_api_key_usage_hour"`
+	Requests     int64     `json:"requests" gorm:"not null;default:0"`
+	Errors       int64     `json:"errors" gorm:"not null;default:0"`
+	AudioSeconds float64   `json:"audio_seconds" gorm:"not null;default:0"`
+}
+
+// APIKeyAlert flags a sharp change in how an API key is used
+type APIKeyAlert struct {
+	ID         uint       `json:"id" gorm:"primaryKey"`
+	APIKeyID   uint       `json:"api_key_id" gorm:"not null;index"`
+	Kind       string     `json:"kind" gorm:"type:varchar(30);not null"`
+	Message    string     `json:"message" gorm:"type:text;not null"`
+	Observed   float64    `json:"observed"`
+	Baseline   float64    `json:"baseline"`
+	Resolution *string    `json:"resolution,omitempty" gorm:"type:varchar(20)"` // "revoked" or "dismissed"
+	ResolvedAt *time.Time `json:"resolved_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// UploadToken lets a browser upload one file without holding a real API key.
+// Only a hash of the token is stored.
+type UploadToken struct {
+	ID            uint       `json:"id" gorm:"primaryKey"`
+	TokenHash     string     `json:"-" gorm:"uniqueIndex;not null;type:varchar(64)"`
+	APIKeyID      *uint      `json:"api_key_id,omitempty" gorm:"index"`
+	UserID        *uint      `json:"user_id,omitempty" gorm:"index"`
+	MaxBytes      int64      `json:"max_bytes" gorm:"not null"`
+	AllowedOrigin *string    `json:"allowed_origin,omitempty" gorm:"type:varchar(255)"`
+	Title         *string    `json:"title,omitempty" gorm:"type:text"`
+	ExpiresAt     time.Time  `json:"expires_at" gorm:"not null;index"`
+	UsedAt        *time.Time `json:"used_at,omitempty"`
+	JobID         *string    `json:"job_id,omitempty" gorm:"type:varchar(36)"`
+	CreatedAt     time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// JobEvent is a stored step of a job's lifecycle, kept when JOB_EVENTS_DB is on
+type JobEvent struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	JobID     string    `json:"job_id" gorm:"type:varchar(36);not null;index"`
+	Event     string    `json:"event" gorm:"type:varchar(32);not null"`
+	Attrs     *string   `json:"attrs,omitempty" gorm:"type:text"` // JSON object
+	CreatedAt time.Time `json:"created_at" gorm:"index"`
+}
+
+// IngestedObject records an object the bucket watcher has handled, so it is
+// not ingested again while it stays in the bucket
+type IngestedObject struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Bucket    string    `json:"bucket" gorm:"type:varchar(255);not null;uniqueIndex:idx_ingested_object"`
+	Key       string    `json:"key" gorm:"type:text;not null;uniqueIndex:idx_ingested_object"`
+	ETag      string    `json:"etag" gorm:"type:varchar(255);not null;uniqueIndex:idx_ingested_object"`
+	JobID     *string   `json:"job_id,omitempty" gorm:"type:varchar(36)"`
+	Error     *string   `json:"error,omitempty" gorm:"type:text"` // Why an invalid object was not ingested
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// IngestedArchiveMember records a member of a dropzone archive that was
+// handled, so retrying an archive that partly failed does not ingest it twice
+type IngestedArchiveMember struct {
+	ID          uint      `json:"id" gorm:"primaryKey"`
+	ArchiveHash string    `json:"archive_hash" gorm:"type:varchar(64);not null;uniqueIndex:idx_ingested_archive_member"`
+	Member      string    `json:"member" gorm:"type:text;not null;uniqueIndex:idx_ingested_archive_member"`
+	JobID       *string   `json:"job_id,omitempty" gorm:"type:varchar(36)"`
+	CreatedAt   time.Time `json:"created_at" gorm:"autoCreateTime"`
+}
+
+// IngestedMail links a job to the email its audio was attached to, so the
+// sender can be told once it is transcribed
+type IngestedMail struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	JobID     string     `json:"job_id" gorm:"type:varchar(36);not null;uniqueIndex"`
+	Sender    string     `json:"sender" gorm:"type:varchar(255);not null"`
+	MessageID string     `json:"message_id" gorm:"type:text"`
+	Subject   string     `json:"subject" gorm:"type:text"`
+	RepliedAt *time.Time `json:"replied_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at" gorm:"autoCreateTime"`
+}
+
 // BeforeCreate sets the API key if not already set
 func (ak *APIKey) BeforeCreate(tx *gorm.DB) error {
 	if ak.Key == "" {
@@ -359,6 +526,10 @@ type MultiTrackFile struct {
 	Gain               float64   `json:"gain" gorm:"type:real;default:1.0"`           // Gain value from .aup file
 	Pan                float64   `json:"pan" gorm:"type:real;default:0.0"`            // Pan value from .aup file (-1.0 to 1.0)
 	Mute               bool      `json:"mute" gorm:"type:boolean;default:false"`      // Whether track is muted
+	Solo               bool      `json:"solo" gorm:"type:boolean;default:false"`      // Whether track is soloed
+	VolumeEnvelope     *string   `json:"volume_envelope,omitempty" gorm:"type:text"`  // JSON-serialized []audio.EnvelopePoint from the project
+	Clips              *string   `json:"clips,omitempty" gorm:"type:text"`            // JSON-serialized placements of the file on the project's tracks
+	SpeakerName        *string   `json:"speaker_name,omitempty" gorm:"type:varchar(255)"` // Who speaks on the track, from the project or the user
 	CreatedAt          time.Time `json:"created_at" gorm:"autoCreateTime"`
 	UpdatedAt          time.Time `json:"updated_at" gorm:"autoUpdateTime"`
 
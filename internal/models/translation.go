@@ -0,0 +1,50 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// How a transcript is translated
+const (
+	TranslationProviderLLM     = "llm"     // The active LLM, one segment per line
+	TranslationProviderWhisper = "whisper" // Whisper's translate task on the audio; English only
+)
+
+// Translation statuses
+const (
+	TranslationPending   = "pending"
+	TranslationRunning   = "running"
+	TranslationCompleted = "completed"
+	TranslationFailed    = "failed"
+)
+
+// Translation is a transcript translated into another language, kept apart
+// from the original. A transcription has at most one per target language.
+type Translation struct {
+	ID              string  `json:"id" gorm:"primaryKey;type:varchar(36)"`
+	TranscriptionID string  `json:"transcription_id" gorm:"type:varchar(36);not null;uniqueIndex:idx_translations_target"`
+	TargetLanguage  string  `json:"target_language" gorm:"type:varchar(10);not null;uniqueIndex:idx_translations_target"`
+	Provider        string  `json:"provider" gorm:"type:varchar(20);not null"`
+	Model           string  `json:"model" gorm:"type:varchar(255);not null;default:''"`
+	Status          string  `json:"status" gorm:"type:varchar(20);not null;index"`
+	Error           *string `json:"error,omitempty" gorm:"type:text"`
+
+	// The translated transcript, in the format of TranscriptionJob.Transcript.
+	// Segments keep their timing and speakers; word timings are dropped.
+	Transcript *string `json:"-" gorm:"type:text"`
+
+	CreatedAt   time.Time  `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt   time.Time  `json:"updated_at" gorm:"autoUpdateTime"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
+}
+
+// BeforeCreate ensures Translation has a UUID primary key
+func (t *Translation) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
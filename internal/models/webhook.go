@@ -0,0 +1,44 @@
+package models
+
+import "time"
+
+// Webhook delivery statuses
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliverySucceeded = "succeeded"
+	WebhookDeliveryFailed    = "failed"
+)
+
+// Webhook is a URL a user wants told when jobs finish. Deliveries are signed
+// with the secret so the receiver can check they came from this server.
+type Webhook struct {
+	ID       uint   `json:"id" gorm:"primaryKey"`
+	UserID   uint   `json:"user_id" gorm:"not null;index"`
+	URL      string `json:"url" gorm:"type:text;not null"`
+	Secret   string `json:"-" gorm:"type:varchar(255);not null"`
+	Events   string `json:"-" gorm:"type:text;not null;default:''"` // Comma-separated event names; empty for every event
+	IsActive bool   `json:"is_active" gorm:"type:boolean;not null"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
+
+// WebhookDelivery is one event sent, or still to be sent, to a webhook, with
+// the outcome of its latest attempt
+type WebhookDelivery struct {
+	ID        uint   `json:"id" gorm:"primaryKey"`
+	WebhookID uint   `json:"webhook_id" gorm:"not null;index"`
+	JobID     string `json:"job_id" gorm:"type:varchar(36);not null;index"`
+	Event     string `json:"event" gorm:"type:varchar(50);not null"`
+	Payload   string `json:"-" gorm:"type:text;not null"`
+	Status    string `json:"status" gorm:"type:varchar(20);not null;index"`
+	Attempts  int    `json:"attempts" gorm:"not null;default:0"`
+
+	ResponseStatus *int       `json:"response_status,omitempty"`
+	Error          *string    `json:"error,omitempty" gorm:"type:text"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+
+	CreatedAt time.Time `json:"created_at" gorm:"autoCreateTime"`
+	UpdatedAt time.Time `json:"updated_at" gorm:"autoUpdateTime"`
+}
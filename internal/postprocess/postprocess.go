@@ -0,0 +1,403 @@
+// Package postprocess runs finished transcripts through an OpenAI-compatible
+// LLM endpoint to draw insights from them: a summary, action items and
+// chapter markers. Insights are stored next to the transcript, and the
+// summary also becomes the job's latest summary.
+package postprocess
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/llm"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// ErrDisabled means no post-processing endpoint is configured
+var ErrDisabled = errors.New("post-processing is not configured")
+
+// ErrNotTranscribed means the job has no completed transcript to process
+var ErrNotTranscribed = errors.New("transcription has no completed transcript")
+
+// ErrEncrypted means the job's transcript is sealed and cannot be sent out
+var ErrEncrypted = errors.New("transcription is encrypted")
+
+// ErrRunning means the job's insights are being drawn already
+var ErrRunning = errors.New("transcription is being post-processed")
+
+// jobTimeout bounds processing one transcript
+const jobTimeout = 10 * time.Minute
+
+// prompt asks for the insights as JSON; the transcript follows it
+const prompt = `Read the transcript below. Each line starts with the time in seconds at which it is spoken.
+Reply with a single JSON object and nothing else, with these fields:
+- "summary": a concise summary of the conversation, in its language
+- "action_items": the tasks agreed on, as objects with "text" and, when known, "owner" and "due"
+- "chapters": the topics in order, as objects with "start" (seconds, taken from the line the topic starts at), "title" and a one-sentence "summary"
+
+Transcript:
+`
+
+// ActionItem is a task the conversation agreed on
+type ActionItem struct {
+	Text  string `json:"text"`
+	Owner string `json:"owner,omitempty"`
+	Due   string `json:"due,omitempty"` // As said, e.g. "next Friday"
+}
+
+// Chapter is a stretch of the recording about one topic; chapters follow
+// each other without gaps, from the first one's start to the end
+type Chapter struct {
+	Start   float64 `json:"start"` // Seconds
+	End     float64 `json:"end"`
+	Title   string  `json:"title"`
+	Summary string  `json:"summary,omitempty"`
+}
+
+// Insights are the decoded artifacts of a TranscriptInsights
+type Insights struct {
+	Summary     string       `json:"summary"`
+	ActionItems []ActionItem `json:"action_items"`
+	Chapters    []Chapter    `json:"chapters"`
+}
+
+// Decode reads the stored artifacts of a job's insights
+func Decode(stored *models.TranscriptInsights) (*Insights, error) {
+	insights := &Insights{ActionItems: []ActionItem{}, Chapters: []Chapter{}}
+	if stored.Summary != nil {
+		insights.Summary = *stored.Summary
+	}
+	if stored.ActionItems != nil {
+		if err := json.Unmarshal([]byte(*stored.ActionItems), &insights.ActionItems); err != nil {
+			return nil, fmt.Errorf("failed to parse action items: %w", err)
+		}
+	}
+	if stored.Chapters != nil {
+		if err := json.Unmarshal([]byte(*stored.Chapters), &insights.Chapters); err != nil {
+			return nil, fmt.Errorf("failed to parse chapters: %w", err)
+		}
+	}
+	return insights, nil
+}
+
+// Service starts and tracks post-processing
+type Service struct {
+	db      *gorm.DB
+	service llm.Service
+	model   string
+
+	mu      sync.Mutex
+	running map[string]chan struct{}
+}
+
+// NewService creates a service processing with model on service; a nil
+// service turns post-processing off, and a nil db uses database.DB at call time
+func NewService(db *gorm.DB, service llm.Service, model string) *Service {
+	return &Service{db: db, service: service, model: model, running: map[string]chan struct{}{}}
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Enabled reports whether an endpoint is configured
+func (s *Service) Enabled() bool {
+	return s.service != nil
+}
+
+// Model returns the model insights are drawn with
+func (s *Service) Model() string {
+	return s.model
+}
+
+// Track processes every job that completes. It returns a function that
+// stops tracking.
+func (s *Service) Track() func() {
+	return jobstate.Subscribe(func(event jobstate.Event) {
+		if event.To != models.StatusCompleted || !s.Enabled() {
+			return
+		}
+		go func() {
+			if _, err := s.Start(event.JobID); err != nil && !errors.Is(err, ErrEncrypted) {
+				logger.Warn("Failed to start post-processing", "job_id", event.JobID, "error", err)
+			}
+		}()
+	})
+}
+
+// Start records pending insights for a job, replacing earlier ones, and
+// draws them in the background
+func (s *Service) Start(jobID string) (*models.TranscriptInsights, error) {
+	if !s.Enabled() {
+		return nil, ErrDisabled
+	}
+	db := s.conn()
+	if _, err := processableJob(db, jobID); err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	_, running := s.running[jobID]
+	s.mu.Unlock()
+	if running {
+		return nil, ErrRunning
+	}
+
+	insights := models.TranscriptInsights{TranscriptionID: jobID, Model: s.model, Status: models.InsightsPending}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("transcription_id = ?", jobID).Delete(&models.TranscriptInsights{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&insights).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record insights: %w", err)
+	}
+	s.launch(jobID)
+	return &insights, nil
+}
+
+// Resume restarts processing interrupted, e.g. by a server restart
+func (s *Service) Resume() {
+	if !s.Enabled() {
+		return
+	}
+	var ids []string
+	s.conn().Model(&models.TranscriptInsights{}).
+		Where("status IN ?", []string{models.InsightsPending, models.InsightsRunning}).
+		Pluck("transcription_id", &ids)
+	for _, id := range ids {
+		logger.Info("Resuming post-processing", "job_id", id)
+		s.launch(id)
+	}
+}
+
+// Wait blocks until the job is no longer being processed
+func (s *Service) Wait(jobID string) {
+	s.mu.Lock()
+	done, ok := s.running[jobID]
+	s.mu.Unlock()
+	if ok {
+		<-done
+	}
+}
+
+func (s *Service) launch(jobID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.running[jobID]; ok {
+		return
+	}
+	done := make(chan struct{})
+	s.running[jobID] = done
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, jobID)
+			s.mu.Unlock()
+			close(done)
+		}()
+		s.process(jobID)
+	}()
+}
+
+// process draws the insights of a job and stores the outcome
+func (s *Service) process(jobID string) {
+	db := s.conn()
+	var stored models.TranscriptInsights
+	if err := db.Where("transcription_id = ?", jobID).First(&stored).Error; err != nil {
+		logger.Error("Failed to load insights", "job_id", jobID, "error", err)
+		return
+	}
+	db.Model(&stored).Update("status", models.InsightsRunning)
+
+	ctx, cancel := context.WithTimeout(logger.WithJobID(context.Background(), jobID), jobTimeout)
+	defer cancel()
+	start := time.Now()
+	insights, err := s.draw(ctx, jobID, stored.Model)
+
+	now := time.Now()
+	if err == nil {
+		err = s.save(&stored, insights, now)
+	}
+	if err != nil {
+		db.Model(&stored).Updates(map[string]interface{}{"status": models.InsightsFailed, "error": err.Error(), "completed_at": &now})
+		logger.Warn("Post-processing failed", "job_id", jobID, "error", err)
+		return
+	}
+	logger.Info("Post-processing finished", "job_id", jobID,
+		"action_items", len(insights.ActionItems), "chapters", len(insights.Chapters), "duration", time.Since(start))
+}
+
+// draw asks the model for the insights of a job's transcript
+func (s *Service) draw(ctx context.Context, jobID, model string) (*Insights, error) {
+	job, err := processableJob(s.conn(), jobID)
+	if err != nil {
+		return nil, err
+	}
+	var result interfaces.TranscriptResult
+	if err := json.Unmarshal([]byte(*job.Transcript), &result); err != nil {
+		return nil, fmt.Errorf("failed to parse transcript: %w", err)
+	}
+	lines, duration := s.timedLines(job, &result)
+	if lines == "" {
+		return nil, fmt.Errorf("transcript is empty")
+	}
+
+	resp, err := s.service.ChatCompletion(ctx, model, []llm.ChatMessage{{Role: "user", Content: prompt + lines}}, 0.0)
+	if err != nil {
+		return nil, err
+	}
+	if resp == nil || len(resp.Choices) == 0 {
+		return nil, fmt.Errorf("model returned no insights")
+	}
+	return parseInsights(resp.Choices[0].Message.Content, duration)
+}
+
+// timedLines renders a transcript as "[seconds] Speaker: text" lines with
+// the job's speaker names, and returns when it ends
+func (s *Service) timedLines(job *models.TranscriptionJob, result *interfaces.TranscriptResult) (string, float64) {
+	names := map[string]string{}
+	var mappings []models.SpeakerMapping
+	s.conn().Where("transcription_job_id = ?", job.ID).Find(&mappings)
+	for _, mapping := range mappings {
+		names[mapping.OriginalSpeaker] = mapping.CustomName
+	}
+
+	segments := append([]interfaces.TranscriptSegment(nil), result.Segments...)
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	var b strings.Builder
+	duration := 0.0
+	for _, segment := range segments {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+		duration = math.Max(duration, segment.End)
+		fmt.Fprintf(&b, "[%.1f] ", segment.Start)
+		if segment.Speaker != nil && *segment.Speaker != "" {
+			speaker := *segment.Speaker
+			if name, ok := names[speaker]; ok && name != "" {
+				speaker = name
+			}
+			b.WriteString(speaker + ": ")
+		}
+		b.WriteString(text + "\n")
+	}
+	return b.String(), duration
+}
+
+// parseInsights reads the model's JSON reply, tolerating a code fence or
+// prose around it, and fits the chapters to the recording: in order, inside
+// it, and each running to the start of the next
+func parseInsights(reply string, duration float64) (*Insights, error) {
+	start, end := strings.Index(reply, "{"), strings.LastIndex(reply, "}")
+	if start < 0 || end < start {
+		return nil, fmt.Errorf("model reply is not JSON")
+	}
+	var raw struct {
+		Summary     string       `json:"summary"`
+		ActionItems []ActionItem `json:"action_items"`
+		Chapters    []Chapter    `json:"chapters"`
+	}
+	if err := json.Unmarshal([]byte(reply[start:end+1]), &raw); err != nil {
+		return nil, fmt.Errorf("model reply is not valid JSON: %w", err)
+	}
+
+	insights := &Insights{Summary: strings.TrimSpace(raw.Summary), ActionItems: []ActionItem{}, Chapters: []Chapter{}}
+	if insights.Summary == "" {
+		return nil, fmt.Errorf("model returned no summary")
+	}
+	for _, item := range raw.ActionItems {
+		item.Text = strings.TrimSpace(item.Text)
+		if item.Text != "" {
+			insights.ActionItems = append(insights.ActionItems, item)
+		}
+	}
+
+	chapters := make([]Chapter, 0, len(raw.Chapters))
+	for _, chapter := range raw.Chapters {
+		chapter.Title = strings.TrimSpace(chapter.Title)
+		if chapter.Title == "" || chapter.Start < 0 || chapter.Start >= duration {
+			continue
+		}
+		chapters = append(chapters, chapter)
+	}
+	sort.SliceStable(chapters, func(i, j int) bool { return chapters[i].Start < chapters[j].Start })
+	for i, chapter := range chapters {
+		if i > 0 && chapter.Start == chapters[i-1].Start {
+			continue
+		}
+		if len(insights.Chapters) == 0 {
+			chapter.Start = 0
+		}
+		insights.Chapters = append(insights.Chapters, chapter)
+	}
+	for i := range insights.Chapters {
+		insights.Chapters[i].End = duration
+		if i+1 < len(insights.Chapters) {
+			insights.Chapters[i].End = insights.Chapters[i+1].Start
+		}
+	}
+	return insights, nil
+}
+
+// save stores completed insights, and their summary as the job's latest summary
+func (s *Service) save(stored *models.TranscriptInsights, insights *Insights, now time.Time) error {
+	actionItems, err := json.Marshal(insights.ActionItems)
+	if err != nil {
+		return err
+	}
+	chapters, err := json.Marshal(insights.Chapters)
+	if err != nil {
+		return err
+	}
+	return s.conn().Transaction(func(tx *gorm.DB) error {
+		err := tx.Model(stored).Updates(map[string]interface{}{
+			"status":       models.InsightsCompleted,
+			"summary":      insights.Summary,
+			"action_items": string(actionItems),
+			"chapters":     string(chapters),
+			"completed_at": &now,
+		}).Error
+		if err != nil {
+			return err
+		}
+		summary := models.Summary{TranscriptionID: stored.TranscriptionID, Model: stored.Model, Content: insights.Summary}
+		if err := tx.Create(&summary).Error; err != nil {
+			return err
+		}
+		// Keep the cached copy on the job in step, as the summarizer does
+		return tx.Model(&models.TranscriptionJob{}).Where("id = ?", stored.TranscriptionID).Update("summary", insights.Summary).Error
+	})
+}
+
+// processableJob loads a job whose transcript can be sent to the endpoint
+func processableJob(db *gorm.DB, jobID string) (*models.TranscriptionJob, error) {
+	var job models.TranscriptionJob
+	if err := db.Where("id = ?", jobID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	if job.Encrypted {
+		return nil, ErrEncrypted
+	}
+	if job.Status != models.StatusCompleted || job.Transcript == nil || *job.Transcript == "" {
+		return nil, ErrNotTranscribed
+	}
+	return &job, nil
+}
@@ -0,0 +1,90 @@
+package processing
+
+import (
+	"sync"
+
+	"synthezia/internal/audio"
+)
+
+// MergeProgressTracker keeps the latest progress of the merges running in
+// this process and fans updates out to subscribers, so status requests and
+// streams see how far ffmpeg has got without a write per update
+type MergeProgressTracker struct {
+	mu          sync.RWMutex
+	latest      map[string]audio.MergeProgress
+	subscribers map[string]map[int]chan audio.MergeProgress
+	nextID      int
+}
+
+// NewMergeProgressTracker creates an empty tracker
+func NewMergeProgressTracker() *MergeProgressTracker {
+	return &MergeProgressTracker{
+		latest:      make(map[string]audio.MergeProgress),
+		subscribers: make(map[string]map[int]chan audio.MergeProgress),
+	}
+}
+
+// MergeProgresses is the process-wide tracker, shared by every processor
+var MergeProgresses = NewMergeProgressTracker()
+
+// Report records the progress of a job's merge and passes it to subscribers.
+// Finished merges are forgotten; their outcome is the job's merge status.
+func (t *MergeProgressTracker) Report(jobID string, progress audio.MergeProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if isFinalMergeStage(progress.Stage) {
+		delete(t.latest, jobID)
+	} else {
+		t.latest[jobID] = progress
+	}
+	for _, ch := range t.subscribers[jobID] {
+		select {
+		case ch <- progress:
+		default:
+			// A slow subscriber skips to a later update
+		}
+	}
+}
+
+// Clear forgets the progress of a merge that stopped without ending, such as
+// one put back to pending, without telling subscribers
+func (t *MergeProgressTracker) Clear(jobID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.latest, jobID)
+}
+
+// Get returns the progress of a running merge
+func (t *MergeProgressTracker) Get(jobID string) (audio.MergeProgress, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	progress, ok := t.latest[jobID]
+	return progress, ok
+}
+
+// Subscribe returns a channel receiving the progress updates of a job's
+// merge, and a function that stops them
+func (t *MergeProgressTracker) Subscribe(jobID string) (<-chan audio.MergeProgress, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ch := make(chan audio.MergeProgress, 16)
+	id := t.nextID
+	t.nextID++
+	if t.subscribers[jobID] == nil {
+		t.subscribers[jobID] = make(map[int]chan audio.MergeProgress)
+	}
+	t.subscribers[jobID][id] = ch
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.subscribers[jobID], id)
+		if len(t.subscribers[jobID]) == 0 {
+			delete(t.subscribers, jobID)
+		}
+	}
+}
+
+// isFinalMergeStage reports whether a merge stage ends the merge
+func isFinalMergeStage(stage string) bool {
+	return stage == "completed" || stage == "failed" || stage == "cancelled"
+}
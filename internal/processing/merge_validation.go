@@ -0,0 +1,217 @@
+package processing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"os/exec"
+	"sort"
+	"strings"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/models"
+)
+
+// Rates used to estimate the disk a merge needs: the merged MP3 is encoded
+// at 192 kbps, and FLAC track renders take roughly this share of 16-bit PCM
+const (
+	mergedBitRate        = 192000
+	renderFLACRatio      = 0.6
+	defaultRenderRate    = 44100
+	renderBytesPerSample = 2 // 16-bit samples
+)
+
+// MergeValidation is what a dry run of a multi-track merge found: whether
+// the merge can run, how long the mix will last and how much disk it needs.
+// Durations and sizes are 0 when ffprobe could not measure the tracks.
+type MergeValidation struct {
+	Valid              bool                   `json:"valid"`
+	Tracks             []MergeValidationTrack `json:"tracks"`
+	EstimatedDuration  float64                `json:"estimated_duration"`   // Seconds
+	EstimatedSize      int64                  `json:"estimated_size"`       // Bytes of the merged file
+	EstimatedDiskUsage int64                  `json:"estimated_disk_usage"` // Bytes at the peak of the merge, renders included
+	Errors             []string               `json:"errors"`
+	Warnings           []string               `json:"warnings"`
+}
+
+// MergeValidationTrack describes one uploaded track file of a dry run
+type MergeValidationTrack struct {
+	FileName   string  `json:"file_name"`
+	Exists     bool    `json:"exists"`
+	InProject  bool    `json:"in_project"`
+	Clips      int     `json:"clips"`
+	Audible    bool    `json:"audible"`
+	Duration   float64 `json:"duration,omitempty"` // Seconds
+	SampleRate int     `json:"sample_rate,omitempty"`
+	Channels   int     `json:"channels,omitempty"`
+}
+
+// ValidateMerge checks, without merging anything, that a multi-track job's
+// project parses and that the files it will mix are there, and estimates
+// the length and size of the mix. Problems that stop the merge are errors;
+// ones it works around, such as tracks at different sample rates, are
+// warnings.
+func ValidateMerge(ctx context.Context, ffprobePath string, job *models.TranscriptionJob) (*MergeValidation, error) {
+	if !job.IsMultiTrack || job.AupFilePath == nil {
+		return nil, fmt.Errorf("job %s is not a multi-track job", job.ID)
+	}
+	result := &MergeValidation{Tracks: []MergeValidationTrack{}, Errors: []string{}, Warnings: []string{}}
+
+	projectTracks, err := audio.ParseProjectFile(*job.AupFilePath)
+	if err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("Cannot read the project: %v", err))
+		return result, nil
+	}
+	projectClips := projectClipsByFile(projectTracks)
+
+	// Clips as the merge will place them, with the files they come from
+	var clips []audio.TrackInfo
+	var clipFiles []int
+	uploaded := make(map[string]bool)
+	probes := make(map[int]*audio.ProbeResult)
+	probeMissing := false
+	for i, tf := range job.MultiTrackFiles {
+		name := trackFileName(tf)
+		uploaded[name] = true
+		track := MergeValidationTrack{FileName: name}
+
+		fileClips := []audio.TrackInfo{{FilePath: tf.FilePath, Gain: 1.0}}
+		if placed, ok := projectClips[name]; ok {
+			track.InProject = true
+			fileClips = make([]audio.TrackInfo, len(placed))
+			for j, clip := range placed {
+				clip.FilePath = tf.FilePath
+				clip.Solo = clip.Solo && !job.MergeAllTracks
+				fileClips[j] = clip
+			}
+		} else {
+			result.Warnings = append(result.Warnings, fmt.Sprintf("%s is not in the project; it is mixed in whole from the start", name))
+		}
+		track.Clips = len(fileClips)
+		clips = append(clips, fileClips...)
+		for range fileClips {
+			clipFiles = append(clipFiles, i)
+		}
+
+		if _, err := os.Stat(tf.FilePath); err != nil {
+			result.Errors = append(result.Errors, fmt.Sprintf("Track file %s is missing", name))
+		} else {
+			track.Exists = true
+			if !probeMissing {
+				probe, err := audio.Probe(ctx, ffprobePath, tf.FilePath)
+				switch {
+				case errors.Is(err, exec.ErrNotFound):
+					probeMissing = true
+					result.Warnings = append(result.Warnings, "ffprobe is not installed; durations and sample rates were not checked")
+				case err != nil:
+					result.Errors = append(result.Errors, fmt.Sprintf("Track file %s cannot be read: %v", name, err))
+				default:
+					probes[i] = probe
+					track.Duration = probe.Duration
+					track.SampleRate = probe.SampleRate
+					track.Channels = probe.Channels
+				}
+			}
+		}
+		result.Tracks = append(result.Tracks, track)
+	}
+
+	// Files the project plays that were not uploaded are left out of the mix
+	var notUploaded []string
+	for name := range projectClips {
+		if !uploaded[name] {
+			notUploaded = append(notUploaded, name)
+		}
+	}
+	sort.Strings(notUploaded)
+	for _, name := range notUploaded {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("The project uses %s, which was not uploaded; it is left out of the mix", name))
+	}
+
+	heard := audio.Audible(clips)
+	audibleCount := 0
+	for j, audible := range heard {
+		if audible {
+			audibleCount++
+			result.Tracks[clipFiles[j]].Audible = true
+		}
+	}
+	if len(clips) == 0 {
+		result.Errors = append(result.Errors, "The job has no track files")
+	} else if audibleCount == 0 {
+		result.Errors = append(result.Errors, "Every track is muted")
+	}
+
+	if rates := sampleRates(probes); len(rates) > 1 {
+		result.Warnings = append(result.Warnings, fmt.Sprintf("Tracks have different sample rates (%s); they are resampled when mixed", strings.Join(rates, ", ")))
+	}
+	if !probeMissing {
+		estimateMerge(result, clips, clipFiles, heard, probes)
+	}
+	result.Valid = len(result.Errors) == 0
+	return result, nil
+}
+
+// sampleRates lists the distinct sample rates of the probed files
+func sampleRates(probes map[int]*audio.ProbeResult) []string {
+	seen := make(map[int]bool)
+	var rates []int
+	for _, probe := range probes {
+		if probe.SampleRate > 0 && !seen[probe.SampleRate] {
+			seen[probe.SampleRate] = true
+			rates = append(rates, probe.SampleRate)
+		}
+	}
+	sort.Ints(rates)
+	names := make([]string, len(rates))
+	for i, rate := range rates {
+		names[i] = fmt.Sprintf("%d Hz", rate)
+	}
+	return names
+}
+
+// estimateMerge estimates the length of the mix of the audible clips, the
+// size of the merged file, and the disk the merge peaks at: a FLAC render
+// of every project track plus the merged file
+func estimateMerge(result *MergeValidation, clips []audio.TrackInfo, clipFiles []int, heard []bool, probes map[int]*audio.ProbeResult) {
+	var audible []audio.TrackInfo
+	durations := make(map[int]float64)
+	renderSeconds := make(map[int]float64)
+	renderFrameBytes := make(map[int]int)
+	for i, clip := range clips {
+		probe, ok := probes[clipFiles[i]]
+		if !heard[i] || !ok {
+			continue
+		}
+		durations[len(audible)] = probe.Duration
+		audible = append(audible, clip)
+
+		// Clips outside any project track are rendered on their own
+		render := clip.Track
+		if render == 0 {
+			render = -len(audible)
+		}
+		end := audio.MergedDuration([]audio.TrackInfo{clip}, map[int]float64{0: probe.Duration})
+		renderSeconds[render] = math.Max(renderSeconds[render], end)
+		rate, channels := probe.SampleRate, probe.Channels
+		if rate <= 0 {
+			rate = defaultRenderRate
+		}
+		if channels <= 0 || clip.Pan != 0 {
+			channels = 2
+		}
+		if frameBytes := rate * channels * renderBytesPerSample; frameBytes > renderFrameBytes[render] {
+			renderFrameBytes[render] = frameBytes
+		}
+	}
+
+	result.EstimatedDuration = math.Round(audio.MergedDuration(audible, durations)*1000) / 1000
+	result.EstimatedSize = int64(result.EstimatedDuration * mergedBitRate / 8)
+	renderBytes := 0.0
+	for render, seconds := range renderSeconds {
+		renderBytes += seconds * float64(renderFrameBytes[render]) * renderFLACRatio
+	}
+	result.EstimatedDiskUsage = int64(renderBytes) + result.EstimatedSize
+}
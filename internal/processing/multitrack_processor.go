@@ -2,8 +2,13 @@ package processing
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 
 	"synthezia/internal/audio"
 	"synthezia/internal/database"
@@ -13,24 +18,73 @@ import (
 	"gorm.io/gorm"
 )
 
+// renderDirName is the folder of a multi-track job where its tracks are
+// rendered before being mixed, kept across failed and interrupted merges
+const renderDirName = "renders"
+
+// ErrProcessorStopped is returned for merges started or interrupted after
+// the processor was stopped
+var ErrProcessorStopped = errors.New("multi-track processor is stopped")
+
+// ErrMergeCancelled is returned for merges cancelled with CancelMerge
+var ErrMergeCancelled = errors.New("merge cancelled")
+
+// runningMerge is a merge in progress in this process
+type runningMerge struct {
+	cancel    context.CancelFunc
+	done      chan struct{} // Closed once the merge has returned
+	cancelled bool          // Set by CancelMerge, unlike a shutdown
+}
+
 // MultiTrackProcessor handles processing of multi-track audio jobs
 type MultiTrackProcessor struct {
-	aupParser   *audio.AupParser
 	audioMerger *audio.AudioMerger
 	db          *gorm.DB
+
+	mu      sync.Mutex
+	running map[string]*runningMerge
+	stopped bool
+	wg      sync.WaitGroup
+
+	// slots holds a token per merge running ffmpeg; nil does not limit them
+	slots chan struct{}
 }
 
 // NewMultiTrackProcessor creates a new multi-track processor
 func NewMultiTrackProcessor() *MultiTrackProcessor {
 	return &MultiTrackProcessor{
-		aupParser:   audio.NewAupParser(),
 		audioMerger: audio.NewAudioMerger(),
 		db:          database.DB,
+		running:     make(map[string]*runningMerge),
+	}
+}
+
+// SetMaxConcurrentMerges limits how many merges run ffmpeg at once, so a
+// burst of multi-track uploads does not exhaust the host's CPU and memory.
+// Merges over the limit wait in the merge_queued state, in the order they
+// arrived. 0 or less does not limit them. Set it before the first merge.
+func (p *MultiTrackProcessor) SetMaxConcurrentMerges(n int) {
+	if n <= 0 {
+		p.slots = nil
+		return
 	}
+	p.slots = make(chan struct{}, n)
 }
 
-// ProcessMultiTrackJob processes a multi-track job by parsing the .aup file and merging audio
+// SetFFmpegPath overrides the ffmpeg binary merges run, mainly for tests
+func (p *MultiTrackProcessor) SetFFmpegPath(path string) {
+	p.audioMerger.SetFFmpegPath(path)
+}
+
+// SetTempDir sets where merged audio is staged before it is moved into the job folder
+func (p *MultiTrackProcessor) SetTempDir(dir string) {
+	p.audioMerger.SetTempDir(dir)
+}
+
+// ProcessMultiTrackJob processes a multi-track job by parsing its project file and merging audio
 func (p *MultiTrackProcessor) ProcessMultiTrackJob(ctx context.Context, jobID string) error {
+	ctx = logger.WithJobID(ctx, jobID)
+
 	// Get the job from database
 	var job models.TranscriptionJob
 	if err := p.db.Preload("MultiTrackFiles").Where("id = ?", jobID).First(&job).Error; err != nil {
@@ -42,6 +96,18 @@ func (p *MultiTrackProcessor) ProcessMultiTrackJob(ctx context.Context, jobID st
 		return fmt.Errorf("job %s is not a multi-track job", jobID)
 	}
 
+	ctx, done, err := p.begin(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	defer done()
+
+	release, err := p.acquireSlot(ctx, jobID)
+	if err != nil {
+		return err
+	}
+	defer release()
+
 	logger.Info("Starting multi-track processing", "job_id", jobID)
 
 	// Update status to processing
@@ -49,18 +115,18 @@ func (p *MultiTrackProcessor) ProcessMultiTrackJob(ctx context.Context, jobID st
 		return fmt.Errorf("failed to update status to processing: %w", err)
 	}
 
-	// Parse the .aup file to get track information
-	aupTracks, err := p.aupParser.ParseAupFile(*job.AupFilePath)
+	// Parse the project file (.aup, .rpp, .ardour, ...) to get track information
+	projectTracks, err := audio.ParseProjectFile(*job.AupFilePath)
 	if err != nil {
 		errMsg := err.Error()
 		p.updateMergeStatus(jobID, "failed", &errMsg)
-		return fmt.Errorf("failed to parse AUP file: %w", err)
+		return fmt.Errorf("failed to parse %s file: %w", strings.ToUpper(strings.TrimPrefix(filepath.Ext(*job.AupFilePath), ".")), err)
 	}
 
-	logger.Info("Parsed AUP file", "job_id", jobID, "tracks_count", len(aupTracks))
+	logger.Info("Parsed project file", "job_id", jobID, "tracks_count", len(projectTracks))
 
 	// Update MultiTrackFile records with offset information
-	if err := p.updateTrackOffsets(jobID, aupTracks); err != nil {
+	if err := p.updateTrackOffsets(jobID, projectTracks); err != nil {
 		errMsg := err.Error()
 		p.updateMergeStatus(jobID, "failed", &errMsg)
 		return fmt.Errorf("failed to update track offsets: %w", err)
@@ -74,31 +140,37 @@ func (p *MultiTrackProcessor) ProcessMultiTrackJob(ctx context.Context, jobID st
 		return fmt.Errorf("failed to get track files: %w", err)
 	}
 
-	// Convert to TrackInfo for merger
-	trackInfos := make([]audio.TrackInfo, len(trackFiles))
-	for i, tf := range trackFiles {
-		trackInfos[i] = audio.TrackInfo{
-			FilePath: tf.FilePath,
-			Offset:   tf.Offset,
-			Gain:     tf.Gain,
-			Pan:      tf.Pan,
-			Mute:     tf.Mute,
-		}
+	// Convert to TrackInfo for merger, one per clip of each file
+	var trackInfos []audio.TrackInfo
+	for _, tf := range trackFiles {
+		trackInfos = append(trackInfos, fileClips(tf)...)
+	}
+	for i := range trackInfos {
+		// The job can ask for every unmuted track despite the project's solos
+		trackInfos[i].Solo = trackInfos[i].Solo && !job.MergeAllTracks
 	}
 
-	// Define output path
+	// Define output path; tracks are rendered next to it so a merge run
+	// again resumes from the renders already made
 	outputPath := filepath.Join(*job.MultiTrackFolder, "merged.mp3")
+	renderDir := filepath.Join(*job.MultiTrackFolder, renderDirName)
 
 	// Merge the audio tracks
 	progressCallback := func(progress audio.MergeProgress) {
-		logger.Info("Merge progress", "job_id", jobID, "stage", progress.Stage, "progress", progress.Progress)
-		// In a production system, you might want to store intermediate progress
-		// or emit progress events via websockets/SSE
+		logger.Debug("Merge progress", "job_id", jobID, "stage", progress.Stage, "progress", progress.Progress)
+		// Completion is announced once the job points at the merged audio
+		if progress.Stage != "completed" {
+			MergeProgresses.Report(jobID, progress)
+		}
 	}
 
-	if err := p.audioMerger.MergeTracksWithOffsets(ctx, trackInfos, outputPath, progressCallback); err != nil {
+	if err := p.audioMerger.MergeTracksResumable(ctx, trackInfos, outputPath, renderDir, progressCallback); err != nil {
+		if ctx.Err() != nil {
+			return p.interrupted(jobID, renderDir, err)
+		}
 		errMsg := err.Error()
 		p.updateMergeStatus(jobID, "failed", &errMsg)
+		MergeProgresses.Report(jobID, audio.MergeProgress{Stage: "failed", ErrorMsg: errMsg})
 		return fmt.Errorf("failed to merge audio tracks: %w", err)
 	}
 
@@ -108,18 +180,181 @@ func (p *MultiTrackProcessor) ProcessMultiTrackJob(ctx context.Context, jobID st
 		"merge_status":      "completed",
 		"merge_error":       nil,
 		"audio_path":        outputPath, // Update main audio path to point to merged file
+		"proxy_audio_path":  nil,        // Re-encode the playback proxy from the new mix
 	}
 
 	if err := p.db.Model(&models.TranscriptionJob{}).Where("id = ?", jobID).Updates(updates).Error; err != nil {
 		errMsg := err.Error()
 		p.updateMergeStatus(jobID, "failed", &errMsg)
+		MergeProgresses.Report(jobID, audio.MergeProgress{Stage: "failed", ErrorMsg: errMsg})
 		return fmt.Errorf("failed to update job with merged path: %w", err)
 	}
+	MergeProgresses.Report(jobID, audio.MergeProgress{Stage: "completed", Progress: 100})
 
 	logger.Info("Successfully completed multi-track processing", "job_id", jobID, "output_path", outputPath)
 	return nil
 }
 
+// begin registers a merge of a job, returning the context it runs in and a
+// function to call once it returns. A job is merged once at a time.
+func (p *MultiTrackProcessor) begin(ctx context.Context, jobID string) (context.Context, func(), error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.stopped {
+		return nil, nil, ErrProcessorStopped
+	}
+	if _, ok := p.running[jobID]; ok {
+		return nil, nil, fmt.Errorf("job %s is already being merged", jobID)
+	}
+	ctx, cancel := context.WithCancel(ctx)
+	merge := &runningMerge{cancel: cancel, done: make(chan struct{})}
+	p.running[jobID] = merge
+	p.wg.Add(1)
+	return ctx, func() {
+		p.mu.Lock()
+		delete(p.running, jobID)
+		p.mu.Unlock()
+		cancel()
+		close(merge.done)
+		p.wg.Done()
+	}, nil
+}
+
+// interrupted settles a merge whose context ended before it did. On
+// shutdown the job is left pending, with its finished renders, for
+// ResumeMerges; a merge cancelled with CancelMerge is marked cancelled and
+// its renders are removed. Otherwise the caller gave up on the merge and it
+// is left pending.
+func (p *MultiTrackProcessor) interrupted(jobID, renderDir string, cause error) error {
+	MergeProgresses.Clear(jobID)
+	if p.isStopped() {
+		p.updateMergeStatus(jobID, "pending", nil)
+		logger.Info("Checkpointed multi-track merge for shutdown", "job_id", jobID, "render_dir", renderDir)
+		return fmt.Errorf("%w: %v", ErrProcessorStopped, cause)
+	}
+
+	p.mu.Lock()
+	cancelled := p.running[jobID] != nil && p.running[jobID].cancelled
+	p.mu.Unlock()
+	if cancelled {
+		if renderDir != "" {
+			if err := os.RemoveAll(renderDir); err != nil {
+				logger.Warn("Failed to remove track renders of cancelled merge", "job_id", jobID, "error", err)
+			}
+		}
+		p.updateMergeStatus(jobID, "cancelled", nil)
+		MergeProgresses.Report(jobID, audio.MergeProgress{Stage: "cancelled"})
+		logger.Info("Cancelled multi-track merge", "job_id", jobID)
+		return ErrMergeCancelled
+	}
+
+	p.updateMergeStatus(jobID, "pending", nil)
+	return fmt.Errorf("merge interrupted: %w", cause)
+}
+
+// CancelMerge cancels the merge of a job: a running merge has its ffmpeg
+// killed and returns before CancelMerge does. Either way the job is marked
+// cancelled and its track renders are removed, so a merge a previous run
+// left unfinished is not resumed either. Cancelled merges can be retried.
+func (p *MultiTrackProcessor) CancelMerge(jobID string) error {
+	p.mu.Lock()
+	merge := p.running[jobID]
+	if merge != nil {
+		merge.cancelled = true
+		merge.cancel()
+	}
+	p.mu.Unlock()
+	if merge != nil {
+		<-merge.done
+		return nil
+	}
+
+	var job models.TranscriptionJob
+	if err := p.db.Select("id", "multi_track_folder").Where("id = ?", jobID).First(&job).Error; err != nil {
+		return fmt.Errorf("failed to get job: %w", err)
+	}
+	if job.MultiTrackFolder != nil {
+		if err := os.RemoveAll(filepath.Join(*job.MultiTrackFolder, renderDirName)); err != nil {
+			logger.Warn("Failed to remove track renders of cancelled merge", "job_id", jobID, "error", err)
+		}
+	}
+	if err := p.updateMergeStatus(jobID, "cancelled", nil); err != nil {
+		return fmt.Errorf("failed to update merge status: %w", err)
+	}
+	MergeProgresses.Report(jobID, audio.MergeProgress{Stage: "cancelled"})
+	return nil
+}
+
+// acquireSlot waits for a merge to be allowed to run ffmpeg, queueing the
+// job while the limit is reached, and returns a function giving the slot back
+func (p *MultiTrackProcessor) acquireSlot(ctx context.Context, jobID string) (func(), error) {
+	if p.slots == nil {
+		return func() {}, nil
+	}
+	release := func() { <-p.slots }
+	select {
+	case p.slots <- struct{}{}:
+		return release, nil
+	default:
+	}
+
+	logger.Info("Merge limit reached, queueing multi-track merge", "job_id", jobID, "limit", cap(p.slots))
+	if err := p.updateMergeStatus(jobID, "merge_queued", nil); err != nil {
+		return nil, fmt.Errorf("failed to update status to merge_queued: %w", err)
+	}
+	MergeProgresses.Report(jobID, audio.MergeProgress{Stage: "queued"})
+	select {
+	case p.slots <- struct{}{}:
+		return release, nil
+	case <-ctx.Done():
+		// Nothing was rendered yet
+		return nil, p.interrupted(jobID, "", ctx.Err())
+	}
+}
+
+// isStopped reports whether Stop was called
+func (p *MultiTrackProcessor) isStopped() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.stopped
+}
+
+// Stop cancels the merges in progress and waits for them to return. Their
+// finished track renders are kept and their jobs left pending, so
+// ResumeMerges carries on from there on the next start. No merge starts
+// after Stop.
+func (p *MultiTrackProcessor) Stop() {
+	p.mu.Lock()
+	p.stopped = true
+	for _, merge := range p.running {
+		merge.cancel()
+	}
+	p.mu.Unlock()
+	p.wg.Wait()
+}
+
+// ResumeMerges starts again, in the background, the merges a shutdown or
+// crash interrupted: those of multi-track jobs still pending, queued or
+// processing. Each reuses the track renders its last run finished. It
+// returns how many merges were started.
+func (p *MultiTrackProcessor) ResumeMerges() (int, error) {
+	var jobIDs []string
+	err := p.db.Model(&models.TranscriptionJob{}).
+		Where("is_multi_track = ? AND aup_file_path IS NOT NULL AND multi_track_folder IS NOT NULL AND merge_status IN ?", true, []string{"pending", "merge_queued", "processing"}).
+		Pluck("id", &jobIDs).Error
+	if err != nil {
+		return 0, fmt.Errorf("failed to find interrupted merges: %w", err)
+	}
+	for _, jobID := range jobIDs {
+		go func(jobID string) {
+			if err := p.ProcessMultiTrackJob(context.Background(), jobID); err != nil && !errors.Is(err, ErrProcessorStopped) {
+				logger.Error("Resumed multi-track merge failed", "job_id", jobID, "error", err)
+			}
+		}(jobID)
+	}
+	return len(jobIDs), nil
+}
+
 // updateMergeStatus updates the merge status of a job
 func (p *MultiTrackProcessor) updateMergeStatus(jobID, status string, errorMsg *string) error {
 	updates := map[string]interface{}{
@@ -135,53 +370,73 @@ func (p *MultiTrackProcessor) updateMergeStatus(jobID, status string, errorMsg *
 	return p.db.Model(&models.TranscriptionJob{}).Where("id = ?", jobID).Updates(updates).Error
 }
 
-// updateTrackOffsets updates the MultiTrackFile records with information from .aup file
-func (p *MultiTrackProcessor) updateTrackOffsets(jobID string, aupTracks []audio.AupTrack) error {
+// updateTrackOffsets updates the MultiTrackFile records with information from the project file
+func (p *MultiTrackProcessor) updateTrackOffsets(jobID string, projectTracks []audio.TrackInfo) error {
 	// Get existing track files
 	var trackFiles []models.MultiTrackFile
 	if err := p.db.Where("transcription_job_id = ?", jobID).Find(&trackFiles).Error; err != nil {
 		return fmt.Errorf("failed to get existing track files: %w", err)
 	}
 
-	// Create a map of filename to aup track for quick lookup
-	aupTrackMap := make(map[string]audio.AupTrack)
-	for _, track := range aupTracks {
-		// Use base filename for matching
-		baseFilename := filepath.Base(track.Filename)
-		aupTrackMap[baseFilename] = track
-	}
+	// Create a map of filename to the clips playing it for quick lookup
+	projectTrackMap := projectClipsByFile(projectTracks)
 
 	// Update each track file with offset information
 	for _, trackFile := range trackFiles {
-		// Try to find matching aup track
-		originalFilename := trackFile.FileName + filepath.Ext(trackFile.FilePath)
-		if aupTrack, exists := aupTrackMap[originalFilename]; exists {
+		// Try to find matching project track
+		originalFilename := trackFileName(trackFile)
+		if clips, exists := projectTrackMap[originalFilename]; exists {
+			// The file's first clip describes it; all of them are merged
+			projectTrack := clips[0]
 			updates := map[string]interface{}{
-				"offset": aupTrack.Offset,
-				"gain":   aupTrack.Gain,
-				"pan":    aupTrack.Pan,
-				"mute":   aupTrack.Mute == 1, // Convert int to bool
+				"offset":          projectTrack.Offset,
+				"gain":            projectTrack.Gain,
+				"pan":             projectTrack.Pan,
+				"mute":            projectTrack.Mute,
+				"solo":            projectTrack.Solo,
+				"volume_envelope": nil,
+			}
+			encodedClips, err := json.Marshal(newTrackClips(clips))
+			if err != nil {
+				return fmt.Errorf("failed to encode clips of track file %d: %w", trackFile.ID, err)
+			}
+			updates["clips"] = string(encodedClips)
+			if len(projectTrack.Envelope) > 0 {
+				envelope, err := json.Marshal(projectTrack.Envelope)
+				if err != nil {
+					return fmt.Errorf("failed to encode volume envelope of track file %d: %w", trackFile.ID, err)
+				}
+				updates["volume_envelope"] = string(envelope)
+			}
+			// The project's track names who speaks, unless the user already did
+			if trackFile.SpeakerName == nil && strings.TrimSpace(projectTrack.Name) != "" {
+				updates["speaker_name"] = strings.TrimSpace(projectTrack.Name)
 			}
 
 			if err := p.db.Model(&models.MultiTrackFile{}).Where("id = ?", trackFile.ID).Updates(updates).Error; err != nil {
 				return fmt.Errorf("failed to update track file %d: %w", trackFile.ID, err)
 			}
 
-			logger.Info("Updated track with AUP info", 
+			logger.Info("Updated track with project info", 
 				"track_id", trackFile.ID, 
 				"filename", originalFilename, 
-				"offset", aupTrack.Offset,
-				"gain", aupTrack.Gain,
-				"pan", aupTrack.Pan,
-				"mute", aupTrack.Mute == 1)
+				"offset", projectTrack.Offset,
+				"gain", projectTrack.Gain,
+				"pan", projectTrack.Pan,
+				"mute", projectTrack.Mute,
+				"solo", projectTrack.Solo,
+				"clips", len(clips))
 		} else {
-			logger.Warn("No matching AUP track found for file", "filename", originalFilename, "track_id", trackFile.ID)
-			// Set default values for tracks not found in AUP
+			logger.Warn("No matching project track found for file", "filename", originalFilename, "track_id", trackFile.ID)
+			// Set default values for tracks not found in the project
 			updates := map[string]interface{}{
-				"offset": 0.0,
-				"gain":   1.0,
-				"pan":    0.0,
-				"mute":   false,
+				"offset":          0.0,
+				"gain":            1.0,
+				"pan":             0.0,
+				"mute":            false,
+				"solo":            false,
+				"volume_envelope": nil,
+				"clips":           nil,
 			}
 			if err := p.db.Model(&models.MultiTrackFile{}).Where("id = ?", trackFile.ID).Updates(updates).Error; err != nil {
 				return fmt.Errorf("failed to set default values for track file %d: %w", trackFile.ID, err)
@@ -192,6 +447,22 @@ func (p *MultiTrackProcessor) updateTrackOffsets(jobID string, aupTracks []audio
 	return nil
 }
 
+// projectClipsByFile groups a project's clips by the base name of the file
+// they play, the name its uploaded track file is matched by
+func projectClipsByFile(projectTracks []audio.TrackInfo) map[string][]audio.TrackInfo {
+	clips := make(map[string][]audio.TrackInfo)
+	for _, track := range projectTracks {
+		baseFilename := audio.ProjectFileName(track.FilePath)
+		clips[baseFilename] = append(clips[baseFilename], track)
+	}
+	return clips
+}
+
+// trackFileName returns the name a track file was uploaded as
+func trackFileName(tf models.MultiTrackFile) string {
+	return tf.FileName + filepath.Ext(tf.FilePath)
+}
+
 // GetMergeStatus returns the current merge status of a job
 func (p *MultiTrackProcessor) GetMergeStatus(jobID string) (string, *string, error) {
 	var job models.TranscriptionJob
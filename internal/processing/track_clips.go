@@ -0,0 +1,87 @@
+package processing
+
+import (
+	"encoding/json"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+)
+
+// trackClip is a placement of a track file in the project, as kept in
+// MultiTrackFile.Clips. A file cut into several clips, or used on several
+// tracks, has one per placement.
+type trackClip struct {
+	Track    int                   `json:"track,omitempty"`
+	Offset   float64               `json:"offset"`
+	Start    float64               `json:"start,omitempty"`
+	Duration float64               `json:"duration,omitempty"`
+	Gain     float64               `json:"gain"`
+	Pan      float64               `json:"pan,omitempty"`
+	Mute     bool                  `json:"mute,omitempty"`
+	Solo     bool                  `json:"solo,omitempty"`
+	Envelope []audio.EnvelopePoint `json:"envelope,omitempty"`
+}
+
+// newTrackClips keeps the placements of the project's clips of one file
+func newTrackClips(tracks []audio.TrackInfo) []trackClip {
+	clips := make([]trackClip, len(tracks))
+	for i, track := range tracks {
+		clips[i] = trackClip{
+			Track:    track.Track,
+			Offset:   track.Offset,
+			Start:    track.Start,
+			Duration: track.Duration,
+			Gain:     track.Gain,
+			Pan:      track.Pan,
+			Mute:     track.Mute,
+			Solo:     track.Solo,
+			Envelope: track.Envelope,
+		}
+	}
+	return clips
+}
+
+// fileClips returns the clips to merge for a track file: its placements in
+// the project, or the file as a whole at its offset when it has none
+func fileClips(tf models.MultiTrackFile) []audio.TrackInfo {
+	if tf.Clips != nil {
+		var clips []trackClip
+		if err := json.Unmarshal([]byte(*tf.Clips), &clips); err != nil {
+			logger.Warn("Ignoring unreadable track clips", "track_id", tf.ID, "error", err)
+		} else if len(clips) > 0 {
+			tracks := make([]audio.TrackInfo, len(clips))
+			for i, clip := range clips {
+				tracks[i] = audio.TrackInfo{
+					FilePath: tf.FilePath,
+					Offset:   clip.Offset,
+					Gain:     clip.Gain,
+					Pan:      clip.Pan,
+					Mute:     clip.Mute,
+					Solo:     clip.Solo,
+					Envelope: clip.Envelope,
+					Track:    clip.Track,
+					Start:    clip.Start,
+					Duration: clip.Duration,
+				}
+			}
+			return tracks
+		}
+	}
+
+	var envelope []audio.EnvelopePoint
+	if tf.VolumeEnvelope != nil {
+		if err := json.Unmarshal([]byte(*tf.VolumeEnvelope), &envelope); err != nil {
+			logger.Warn("Ignoring unreadable volume envelope", "track_id", tf.ID, "error", err)
+		}
+	}
+	return []audio.TrackInfo{{
+		FilePath: tf.FilePath,
+		Offset:   tf.Offset,
+		Gain:     tf.Gain,
+		Pan:      tf.Pan,
+		Mute:     tf.Mute,
+		Solo:     tf.Solo,
+		Envelope: envelope,
+	}}
+}
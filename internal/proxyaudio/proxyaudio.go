@@ -0,0 +1,212 @@
+// Package proxyaudio keeps a small Opus copy of each job's audio for playback
+// in the browser, so players and clip previews do not fetch the original,
+// which may be large or kept in cold storage.
+package proxyaudio
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// EventCreated is recorded in a job's timeline when its proxy is ready
+const EventCreated = "playback_proxy_created"
+
+// Service encodes playback proxies for jobs that lack one
+type Service struct {
+	db         *gorm.DB
+	fs         fsys.FS
+	clock      clock.Clock
+	ffmpegPath string
+	tempDir    string
+	bitrate    string
+	batchSize  int
+
+	// Jobs whose proxy failed to encode are not retried until restart
+	failed   map[string]bool
+	failedMu sync.Mutex
+}
+
+// NewService creates a service encoding 24 kbps proxies; a nil db uses
+// database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:         db,
+		fs:         fsys.OS,
+		clock:      clock.Real,
+		ffmpegPath: "ffmpeg",
+		bitrate:    "24k",
+		batchSize:  10,
+		failed:     make(map[string]bool),
+	}
+}
+
+// Default is the process-wide service
+var Default = NewService(nil)
+
+// SetFS overrides the filesystem, mainly for tests
+func (s *Service) SetFS(fs fsys.FS) {
+	s.fs = fs
+}
+
+// SetClock overrides the time source, mainly for tests
+func (s *Service) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetFFmpegPath overrides the ffmpeg binary, mainly for tests
+func (s *Service) SetFFmpegPath(path string) {
+	s.ffmpegPath = path
+}
+
+// SetTempDir sets where proxies are encoded before being moved into place
+func (s *Service) SetTempDir(dir string) {
+	s.tempDir = dir
+}
+
+// SetBitrate sets the Opus bitrate, e.g. "24k"
+func (s *Service) SetBitrate(bitrate string) {
+	s.bitrate = bitrate
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Run encodes missing proxies every interval until stop is closed
+func (s *Service) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			if _, err := s.GenerateMissing(context.Background()); err != nil {
+				logger.Warn("Failed to generate playback proxies", "error", err)
+			}
+		}
+	}
+}
+
+// GenerateMissing encodes proxies for up to one batch of jobs without one and
+// returns how many it created. Multi-track jobs wait until they are merged.
+func (s *Service) GenerateMissing(ctx context.Context) (int, error) {
+	query := s.conn().Model(&models.TranscriptionJob{}).
+		Where("proxy_audio_path IS NULL AND source_audio_removed_at IS NULL AND offloaded_at IS NULL AND audio_path <> ''").
+		Where("is_multi_track = ? OR merged_audio_path IS NOT NULL", false).
+		Where("encrypted = ?", false) // A plaintext proxy would defeat the job key
+	if failed := s.failedIDs(); len(failed) > 0 {
+		query = query.Where("id NOT IN ?", failed)
+	}
+	var jobIDs []string
+	if err := query.Order("created_at DESC").Limit(s.batchSize).Pluck("id", &jobIDs).Error; err != nil {
+		return 0, err
+	}
+
+	created := 0
+	for _, jobID := range jobIDs {
+		if _, err := s.Generate(ctx, jobID); err != nil {
+			s.markFailed(jobID)
+			logger.Warn("Failed to generate playback proxy", "job_id", jobID, "error", err)
+			continue
+		}
+		created++
+	}
+	return created, nil
+}
+
+// Generate encodes the playback proxy for one job and returns its path. The
+// proxy is made from the merged mix for multi-track jobs, and is replaced if
+// one already exists.
+func (s *Service) Generate(ctx context.Context, jobID string) (string, error) {
+	var job models.TranscriptionJob
+	if err := s.conn().Where("id = ?", jobID).First(&job).Error; err != nil {
+		return "", err
+	}
+	source := job.AudioPath
+	if job.IsMultiTrack {
+		if job.MergedAudioPath == nil || *job.MergedAudioPath == "" {
+			return "", fmt.Errorf("multi-track job has not been merged yet")
+		}
+		source = *job.MergedAudioPath
+	}
+	if !fsys.Exists(s.fs, source) {
+		return "", fmt.Errorf("audio file not found: %s", source)
+	}
+
+	ctx = logger.WithJobID(ctx, jobID)
+	proxyPath := strings.TrimSuffix(source, filepath.Ext(source)) + ".playback.opus"
+	if err := s.encode(ctx, source, proxyPath); err != nil {
+		return "", err
+	}
+	if err := s.conn().Model(&models.TranscriptionJob{}).Where("id = ?", jobID).
+		Update("proxy_audio_path", proxyPath).Error; err != nil {
+		s.fs.Remove(proxyPath)
+		return "", err
+	}
+
+	var size int64
+	if info, err := s.fs.Stat(proxyPath); err == nil {
+		size = info.Size()
+	}
+	logger.JobEvent(ctx, jobID, EventCreated, "proxy_path", proxyPath, "bytes", size)
+	return proxyPath, nil
+}
+
+// encode writes a mono Opus file tuned for speech to proxyPath
+func (s *Service) encode(ctx context.Context, source, proxyPath string) error {
+	tempPath := fsys.TempPath(s.tempDir, proxyPath) + ".opus"
+	cmd := exec.CommandContext(ctx, s.ffmpegPath,
+		"-y",
+		"-i", source,
+		"-vn",
+		"-ac", "1",
+		"-c:a", "libopus",
+		"-b:a", s.bitrate,
+		"-application", "voip",
+		tempPath)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "playback_proxy", start, "input", source)
+	if err != nil {
+		s.fs.Remove(tempPath)
+		return fmt.Errorf("ffmpeg failed to encode playback proxy: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if err := s.fs.Rename(tempPath, proxyPath); err != nil {
+		s.fs.Remove(tempPath)
+		return err
+	}
+	return nil
+}
+
+func (s *Service) markFailed(jobID string) {
+	s.failedMu.Lock()
+	s.failed[jobID] = true
+	s.failedMu.Unlock()
+}
+
+func (s *Service) failedIDs() []string {
+	s.failedMu.Lock()
+	defer s.failedMu.Unlock()
+	ids := make([]string, 0, len(s.failed))
+	for id := range s.failed {
+		ids = append(ids, id)
+	}
+	return ids
+}
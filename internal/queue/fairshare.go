@@ -0,0 +1,216 @@
+package queue
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"synthezia/internal/database"
+)
+
+// fairQueue holds the jobs waiting for a worker, one line per owner, and
+// hands them out by stride scheduling: each owner's pass grows by the
+// inverse of its weight with every job it is handed, and the owner with
+// the lowest pass goes next, the oldest job breaking ties. An owner with
+// weight 2 thus gets twice the turns of one with weight 1, and one with a
+// long batch cannot hold the others back.
+type fairQueue struct {
+	mu     sync.Mutex
+	owners map[string]*ownerLine
+	queued map[string]bool // jobs waiting, so repeated enqueues are dropped
+	pass   float64         // pass of the owner served last
+	seq    uint64
+}
+
+// ownerLine is one owner's waiting jobs, oldest first
+type ownerLine struct {
+	jobs   []queuedJob
+	pass   float64
+	weight float64
+}
+
+type queuedJob struct {
+	id  string
+	seq uint64
+}
+
+// enqueuedJob is a job on its way to the dispatcher, with the line it joins
+type enqueuedJob struct {
+	id     string
+	owner  string
+	weight int
+}
+
+func newFairQueue() *fairQueue {
+	return &fairQueue{owners: make(map[string]*ownerLine), queued: make(map[string]bool)}
+}
+
+// push adds a job to the end of its owner's line, reporting false for a job
+// already waiting. An owner whose line was empty starts from the current
+// pass, so time spent idle is not banked.
+func (q *fairQueue) push(owner string, weight int, jobID string) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.queued[jobID] {
+		return false
+	}
+	q.queued[jobID] = true
+	line, exists := q.owners[owner]
+	if !exists {
+		line = &ownerLine{}
+		q.owners[owner] = line
+	}
+	if len(line.jobs) == 0 {
+		line.pass = max(line.pass, q.pass)
+	}
+	line.weight = float64(max(weight, 1))
+	q.seq++
+	line.jobs = append(line.jobs, queuedJob{id: jobID, seq: q.seq})
+	return true
+}
+
+// next returns the owner whose job goes next, or nil when none wait
+func (q *fairQueue) next() *ownerLine {
+	var best *ownerLine
+	for _, line := range q.owners {
+		if len(line.jobs) == 0 {
+			continue
+		}
+		if best == nil || line.pass < best.pass || (line.pass == best.pass && line.jobs[0].seq < best.jobs[0].seq) {
+			best = line
+		}
+	}
+	return best
+}
+
+// peek returns the job that goes next
+func (q *fairQueue) peek() (string, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if line := q.next(); line != nil {
+		return line.jobs[0].id, true
+	}
+	return "", false
+}
+
+// pop removes the job peek returned and charges its owner for it
+func (q *fairQueue) pop() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	line := q.next()
+	if line == nil {
+		return
+	}
+	delete(q.queued, line.jobs[0].id)
+	line.jobs = line.jobs[1:]
+	q.pass = line.pass
+	line.pass += 1 / line.weight
+}
+
+// ParseUserWeights reads queue weights as "username=weight,...". Users
+// not listed weigh 1.
+func ParseUserWeights(value string) (map[string]int, error) {
+	weights := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		username, weight, found := strings.Cut(entry, "=")
+		n, err := strconv.Atoi(strings.TrimSpace(weight))
+		if !found || strings.TrimSpace(username) == "" || err != nil || n < 1 {
+			return nil, fmt.Errorf("invalid queue weight %q, use username=weight with a weight of 1 or more", entry)
+		}
+		weights[strings.TrimSpace(username)] = n
+	}
+	return weights, nil
+}
+
+// SetFairShare has waiting jobs taken in turns by the users who submitted
+// them instead of first come, first served, each user getting turns in
+// proportion to their weight (1 unless listed). Jobs without a user, such
+// as dropzone ingests, share a turn. Call it before Start.
+func (tq *TaskQueue) SetFairShare(enabled bool, weights map[string]int) {
+	tq.fairShare = enabled
+	tq.userWeights = weights
+}
+
+// jobOwner returns the line a job waits in and that line's weight. It is
+// looked up by whoever enqueues the job, keeping the database out of the
+// dispatcher.
+func (tq *TaskQueue) jobOwner(jobID string) (string, int) {
+	if !tq.fairShare {
+		return "", 1
+	}
+	var owner struct {
+		UserID   *uint
+		Username *string
+	}
+	err := database.DB.Table("transcription_jobs").
+		Select("transcription_jobs.user_id, users.username").
+		Joins("LEFT JOIN users ON users.id = transcription_jobs.user_id").
+		Where("transcription_jobs.id = ?", jobID).
+		Scan(&owner).Error
+	if err != nil || owner.UserID == nil {
+		return "", 1
+	}
+	weight := 1
+	if owner.Username != nil {
+		if w, ok := tq.userWeights[*owner.Username]; ok {
+			weight = w
+		}
+	}
+	return strconv.FormatUint(uint64(*owner.UserID), 10), weight
+}
+
+// offer takes a queue slot for a job and sends it to the dispatcher,
+// reporting false when every slot is taken
+func (tq *TaskQueue) offer(jobID string) bool {
+	select {
+	case tq.queueSlots <- struct{}{}:
+	default:
+		return false
+	}
+	owner, weight := tq.jobOwner(jobID)
+	// Never blocks: the channel holds as many jobs as there are slots
+	tq.jobChannel <- enqueuedJob{id: jobID, owner: owner, weight: weight}
+	return true
+}
+
+// dispatcher moves enqueued jobs into the fair queue and hands them to
+// workers one at a time as they become free. A job keeps its queue slot
+// until a worker takes it, so the channel and the fair queue together hold
+// no more than queueCapacity jobs.
+func (tq *TaskQueue) dispatcher() {
+	defer tq.wg.Done()
+	defer close(tq.readyChannel)
+
+	for {
+		var ready chan string
+		next, ok := tq.waiting.peek()
+		if ok {
+			ready = tq.readyChannel
+		}
+
+		select {
+		case job := <-tq.jobChannel:
+			if !tq.waiting.push(job.owner, job.weight, job.id) {
+				<-tq.queueSlots
+			}
+		case ready <- next:
+			tq.waiting.pop()
+			<-tq.queueSlots
+		case <-tq.ctx.Done():
+			return
+		}
+	}
+}
+
+// queueDepth returns how many jobs wait for a worker
+func (tq *TaskQueue) queueDepth() int {
+	return len(tq.queueSlots)
+}
@@ -0,0 +1,187 @@
+package queue
+
+import (
+	"sort"
+	"time"
+)
+
+// What a worker is doing
+const (
+	WorkerIdle             = "idle"
+	WorkerWaitingForDevice = "waiting_for_device"
+	WorkerRunning          = "running"
+)
+
+// WorkerState is what one worker is doing
+type WorkerState struct {
+	ID             int        `json:"id"`
+	State          string     `json:"state"`
+	JobID          string     `json:"job_id,omitempty"`
+	StartedAt      *time.Time `json:"started_at,omitempty"`      // When the worker took the job
+	RuntimeSeconds float64    `json:"runtime_seconds,omitempty"` // How long it has had it
+}
+
+// PriorityDepth counts the waiting jobs of the users with one fair-share weight
+type PriorityDepth struct {
+	Weight int `json:"weight"`
+	Jobs   int `json:"jobs"`
+	Users  int `json:"users"` // Users with jobs waiting; jobs without a user count as one
+}
+
+// Throughput counts the jobs workers finished within a window
+type Throughput struct {
+	Window      string  `json:"window"`
+	Completed   int     `json:"completed"`
+	Failed      int     `json:"failed"` // Including cancelled jobs
+	JobsPerHour float64 `json:"jobs_per_hour"`
+}
+
+// Snapshot is the state of the queue at one moment
+type Snapshot struct {
+	Depth              int             `json:"depth"` // Jobs waiting for a worker
+	Capacity           int             `json:"capacity"`
+	ByPriority         []PriorityDepth `json:"by_priority"` // Heaviest first
+	OldestPendingJobID string          `json:"oldest_pending_job_id,omitempty"`
+	OldestPendingAge   float64         `json:"oldest_pending_age_seconds"` // How long it has waited for a worker
+	Workers            []WorkerState   `json:"workers"`
+	Throughput         []Throughput    `json:"throughput"`
+}
+
+// throughputWindows are the windows Snapshot reports throughput over; the
+// longest bounds how long finished jobs are remembered
+var throughputWindows = []struct {
+	name   string
+	length time.Duration
+}{
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+	{"1h", time.Hour},
+}
+
+// finishedJob is when a worker finished a job and whether it succeeded
+type finishedJob struct {
+	at        time.Time
+	succeeded bool
+}
+
+// trackWorker records what a worker is doing with a job; WorkerIdle
+// forgets its job
+func (tq *TaskQueue) trackWorker(id int, jobID, state string) {
+	tq.jobsMutex.Lock()
+	defer tq.jobsMutex.Unlock()
+
+	if state == WorkerIdle {
+		delete(tq.workerJobs, id)
+		return
+	}
+	startedAt := tq.clock.Now()
+	if current, exists := tq.workerJobs[id]; exists && current.JobID == jobID {
+		startedAt = *current.StartedAt
+	}
+	tq.workerJobs[id] = WorkerState{ID: id, State: state, JobID: jobID, StartedAt: &startedAt}
+}
+
+// recordFinished counts a finished job towards the throughput
+func (tq *TaskQueue) recordFinished(succeeded bool) {
+	tq.jobsMutex.Lock()
+	defer tq.jobsMutex.Unlock()
+
+	now := tq.clock.Now()
+	cutoff := now.Add(-throughputWindows[len(throughputWindows)-1].length)
+	keep := tq.finished[:0]
+	for _, job := range tq.finished {
+		if job.at.After(cutoff) {
+			keep = append(keep, job)
+		}
+	}
+	tq.finished = append(keep, finishedJob{at: now, succeeded: succeeded})
+}
+
+// byWeight counts the waiting jobs and their owners by weight, heaviest first
+func (q *fairQueue) byWeight() []PriorityDepth {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	depths := map[int]*PriorityDepth{}
+	for _, line := range q.owners {
+		if len(line.jobs) == 0 {
+			continue
+		}
+		weight := int(line.weight)
+		depth, exists := depths[weight]
+		if !exists {
+			depth = &PriorityDepth{Weight: weight}
+			depths[weight] = depth
+		}
+		depth.Jobs += len(line.jobs)
+		depth.Users++
+	}
+	result := make([]PriorityDepth, 0, len(depths))
+	for _, depth := range depths {
+		result = append(result, *depth)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Weight > result[j].Weight })
+	return result
+}
+
+// Snapshot reports how many jobs wait and for how long, what each worker
+// is doing, and how many jobs the workers finished lately
+func (tq *TaskQueue) Snapshot() Snapshot {
+	now := tq.clock.Now()
+	snapshot := Snapshot{
+		Depth:      tq.queueDepth(),
+		Capacity:   queueCapacity,
+		ByPriority: tq.waiting.byWeight(),
+		Workers:    []WorkerState{},
+	}
+
+	tq.workerMutex.Lock()
+	ids := make(map[int]bool, len(tq.workerStops))
+	for id := range tq.workerStops {
+		ids[id] = true
+	}
+	tq.workerMutex.Unlock()
+
+	tq.jobsMutex.RLock()
+	var oldest time.Time
+	for jobID, at := range tq.enqueuedAt {
+		if snapshot.OldestPendingJobID == "" || at.Before(oldest) {
+			snapshot.OldestPendingJobID, oldest = jobID, at
+		}
+	}
+	// Workers being scaled down still show while they finish their job
+	for id := range tq.workerJobs {
+		ids[id] = true
+	}
+	for id := range ids {
+		state, busy := tq.workerJobs[id]
+		if !busy {
+			state = WorkerState{ID: id, State: WorkerIdle}
+		} else {
+			state.RuntimeSeconds = now.Sub(*state.StartedAt).Seconds()
+		}
+		snapshot.Workers = append(snapshot.Workers, state)
+	}
+	for _, window := range throughputWindows {
+		throughput := Throughput{Window: window.name}
+		for _, job := range tq.finished {
+			if now.Sub(job.at) > window.length {
+				continue
+			}
+			if job.succeeded {
+				throughput.Completed++
+			} else {
+				throughput.Failed++
+			}
+		}
+		throughput.JobsPerHour = float64(throughput.Completed+throughput.Failed) / window.length.Hours()
+		snapshot.Throughput = append(snapshot.Throughput, throughput)
+	}
+	tq.jobsMutex.RUnlock()
+
+	if snapshot.OldestPendingJobID != "" {
+		snapshot.OldestPendingAge = now.Sub(oldest).Seconds()
+	}
+	sort.Slice(snapshot.Workers, func(i, j int) bool { return snapshot.Workers[i].ID < snapshot.Workers[j].ID })
+	return snapshot
+}
@@ -0,0 +1,29 @@
+//go:build linux
+// +build linux
+
+package queue
+
+import (
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// loadPerCPU reads the one-minute load average from /proc/loadavg and
+// divides it by the number of CPUs
+func loadPerCPU() (float64, bool) {
+	data, err := os.ReadFile("/proc/loadavg")
+	if err != nil {
+		return 0, false
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) == 0 {
+		return 0, false
+	}
+	load, err := strconv.ParseFloat(fields[0], 64)
+	if err != nil {
+		return 0, false
+	}
+	return load / float64(runtime.NumCPU()), true
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+// +build !linux
+
+package queue
+
+// loadPerCPU is not reported on this system, so auto-scaling goes by the
+// queue alone
+func loadPerCPU() (float64, bool) {
+	return 0, false
+}
@@ -2,8 +2,8 @@ package queue
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
 	"os"
 	"os/exec"
 	"runtime"
@@ -13,14 +13,36 @@ import (
 	"time"
 
 	"synthezia/internal/database"
+	"synthezia/internal/devices"
+	"synthezia/internal/jobstate"
 	"synthezia/internal/models"
+	"synthezia/internal/telemetry"
+	"synthezia/pkg/clock"
 	"synthezia/pkg/logger"
+	"synthezia/pkg/metrics"
 )
 
+// qLog tags queue output so LOG_LEVEL_QUEUE can tune it separately
+var qLog = logger.Module(logger.ModuleQueue)
+
+// ErrPaused is the cause of the cancellation of a job stopped by PauseJob;
+// processors check for it with context.Cause to checkpoint their progress
+var ErrPaused = errors.New("job paused")
+
+// errDuplicate means a dequeued job is already waiting for a device in
+// another worker, running or finished
+var errDuplicate = errors.New("duplicate job in queue")
+
+// queueCapacity is how many jobs may wait for a worker before EnqueueJob
+// reports the queue as full
+const queueCapacity = 200
+
 // RunningJob tracks both context cancellation and OS process
 type RunningJob struct {
 	Cancel  context.CancelFunc
 	Process *exec.Cmd
+	// cancel stops the job with a cause, such as ErrPaused
+	cancel context.CancelCauseFunc
 }
 
 // TaskQueue manages transcription job processing
@@ -28,16 +50,35 @@ type TaskQueue struct {
 	minWorkers    int
 	maxWorkers    int
 	currentWorkers int64 // Use atomic for thread-safe access
-	jobChannel    chan string
+	jobChannel    chan enqueuedJob // jobs enqueued, taken in by the dispatcher
+	queueSlots    chan struct{}    // one per job enqueued and not yet handed to a worker
+	readyChannel  chan string // jobs the dispatcher hands to free workers
+	waiting       *fairQueue  // jobs taken in, waiting for a worker
+	fairShare     bool
+	userWeights   map[string]int
+	taskChannel   chan Task // work other than transcription jobs, run by the task workers
+	taskWorkers   int       // how many tasks run at once
 	ctx           context.Context
 	cancel        context.CancelFunc
 	wg            sync.WaitGroup
 	processor     JobProcessor
 	runningJobs   map[string]*RunningJob
+	enqueuedAt    map[string]time.Time // first time a job entered the channel, for queue wait metrics
+	waitingJobs   map[string]bool      // jobs a worker holds while they wait for a device
+	workerJobs    map[int]WorkerState  // what each busy worker does
+	finished      []finishedJob        // jobs finished within the last throughput window
 	jobsMutex     sync.RWMutex
-	workerMutex   sync.Mutex
+	workerMutex   sync.Mutex // guards the worker limits, stops and scaling
+	workerStops   map[int]chan struct{} // closed to stop each live worker after its current job
+	nextWorkerID  int
+	started       bool
+	scaling       bool // the auto-scaling monitor runs
 	autoScale     bool
 	lastScaleTime time.Time
+	maxLoad       float64                // load average per CPU above which auto-scaling sheds workers; 0 ignores load
+	systemLoad    func() (float64, bool) // load average per CPU, if the system reports it
+	clock         clock.Clock
+	devices       *devices.Manager // nil runs jobs wherever they asked
 }
 
 // JobProcessor defines the interface for processing jobs
@@ -46,6 +87,15 @@ type JobProcessor interface {
 	ProcessJobWithProcess(ctx context.Context, jobID string, registerProcess func(*exec.Cmd)) error
 }
 
+// Task is CPU-bound work other than a transcription job, such as an audio
+// conversion. Tasks run on their own workers, so a burst of them cannot hold
+// transcriptions back from the GPU.
+type Task interface {
+	// TaskID names the task in logs
+	TaskID() string
+	Run(ctx context.Context) error
+}
+
 // MultiTrackJobProcessor extends JobProcessor with multi-track specific methods
 type MultiTrackJobProcessor interface {
 	JobProcessor
@@ -93,25 +143,69 @@ func NewTaskQueue(legacyWorkers int, processor JobProcessor) *TaskQueue {
 	if min == max {
 		autoScale = false // Disable auto-scaling if min == max
 	}
+	maxLoad := 1.0
+	if value, err := strconv.ParseFloat(os.Getenv("QUEUE_MAX_LOAD"), 64); err == nil && value >= 0 {
+		maxLoad = value
+	}
 
 	return &TaskQueue{
 		minWorkers:     min,
 		maxWorkers:     max,
 		currentWorkers: int64(min),
-		jobChannel:     make(chan string, 200), // Increased buffer for better throughput
+		jobChannel:     make(chan enqueuedJob, queueCapacity),
+		queueSlots:     make(chan struct{}, queueCapacity),
+		readyChannel:   make(chan string),
+		waiting:        newFairQueue(),
+		taskChannel:    make(chan Task, 200),
+		taskWorkers:    2,
 		ctx:            ctx,
 		cancel:         cancel,
 		processor:      processor,
 		runningJobs:    make(map[string]*RunningJob),
+		enqueuedAt:     make(map[string]time.Time),
+		waitingJobs:    make(map[string]bool),
+		workerJobs:     make(map[int]WorkerState),
+		workerStops:    make(map[int]chan struct{}),
 		autoScale:      autoScale,
 		lastScaleTime:  time.Now(),
+		maxLoad:        maxLoad,
+		systemLoad:     loadPerCPU,
+		clock:          clock.Real,
 	}
 }
 
+// SetClock overrides the time source used for scanning, scaling and queue
+// wait tracking. Call it before Start; mainly for tests.
+func (tq *TaskQueue) SetClock(c clock.Clock) {
+	tq.clock = c
+	tq.lastScaleTime = c.Now()
+}
+
+// SetDevices has each job wait for a device able to run it before it
+// starts. Call it before Start.
+func (tq *TaskQueue) SetDevices(manager *devices.Manager) {
+	tq.devices = manager
+}
+
+// SetTaskConcurrency sets how many tasks run at once, apart from the
+// transcription workers; less than 1 runs one at a time. Call it before Start.
+func (tq *TaskQueue) SetTaskConcurrency(n int) {
+	tq.taskWorkers = max(n, 1)
+}
+
+// SetSystemLoad overrides how the load average per CPU is read for
+// auto-scaling; mainly for tests.
+func (tq *TaskQueue) SetSystemLoad(load func() (float64, bool)) {
+	tq.workerMutex.Lock()
+	defer tq.workerMutex.Unlock()
+	tq.systemLoad = load
+}
+
 // Start starts the task queue workers
 func (tq *TaskQueue) Start() {
+	tq.workerMutex.Lock()
 	workers := int(atomic.LoadInt64(&tq.currentWorkers))
-	logger.Debug("Starting task queue", 
+	qLog.Debug("Starting task queue", 
 		"workers", workers, 
 		"min_workers", tq.minWorkers, 
 		"max_workers", tq.maxWorkers, 
@@ -119,28 +213,84 @@ func (tq *TaskQueue) Start() {
 
 	// Start initial workers
 	for i := 0; i < workers; i++ {
+		tq.startWorker()
+	}
+	tq.started = true
+	if tq.autoScale {
+		tq.startAutoScaler()
+	}
+	tq.workerMutex.Unlock()
+
+	for i := 0; i < tq.taskWorkers; i++ {
 		tq.wg.Add(1)
-		go tq.worker(i)
+		go tq.taskWorker(i)
 	}
 
+	// Start handing enqueued jobs to the workers
+	tq.wg.Add(1)
+	go tq.dispatcher()
+
 	// Start the job scanner
 	tq.wg.Add(1)
 	go tq.jobScanner()
+}
 
-	// Start auto-scaling monitor if enabled
-	if tq.autoScale {
-		tq.wg.Add(1)
-		go tq.autoScaler()
+// startAutoScaler starts the auto-scaling monitor unless it runs already;
+// it idles while auto-scaling is turned off. The caller holds workerMutex.
+func (tq *TaskQueue) startAutoScaler() {
+	if tq.scaling {
+		return
+	}
+	tq.scaling = true
+	tq.wg.Add(1)
+	go tq.autoScaler()
+}
+
+// startWorker starts one more worker; the caller holds workerMutex
+func (tq *TaskQueue) startWorker() {
+	id := tq.nextWorkerID
+	tq.nextWorkerID++
+	stop := make(chan struct{})
+	tq.workerStops[id] = stop
+	tq.wg.Add(1)
+	go tq.worker(id, stop)
+}
+
+// stopWorker has the newest worker exit once it finishes its current job;
+// the caller holds workerMutex
+func (tq *TaskQueue) stopWorker() {
+	newest := -1
+	for id := range tq.workerStops {
+		newest = max(newest, id)
+	}
+	if newest >= 0 {
+		close(tq.workerStops[newest])
+		delete(tq.workerStops, newest)
+	}
+}
+
+// resize starts or stops workers until count run; the caller holds workerMutex
+func (tq *TaskQueue) resize(count int) {
+	atomic.StoreInt64(&tq.currentWorkers, int64(count))
+	if !tq.started {
+		return
+	}
+	for len(tq.workerStops) < count {
+		tq.startWorker()
+	}
+	for len(tq.workerStops) > count {
+		tq.stopWorker()
 	}
 }
 
 // Stop stops the task queue
 func (tq *TaskQueue) Stop() {
-	logger.Debug("Stopping task queue")
+	qLog.Debug("Stopping task queue")
+	// The job channel stays open, as the scanner and EnqueueJob may still
+	// send to it; the dispatcher and workers stop with the context
 	tq.cancel()
-	close(tq.jobChannel)
 	tq.wg.Wait()
-	logger.Debug("Task queue stopped")
+	qLog.Debug("Task queue stopped")
 }
 
 // EnqueueJob adds a job to the queue
@@ -150,8 +300,22 @@ func (tq *TaskQueue) EnqueueJob(jobID string) error {
 		return fmt.Errorf("queue is shutting down")
 	}
 
+	marked := tq.markEnqueued(jobID)
+	if !tq.offer(jobID) {
+		tq.unmarkEnqueued(jobID, marked)
+		return fmt.Errorf("queue is full")
+	}
+	return nil
+}
+
+// EnqueueTask adds a task to the queue
+func (tq *TaskQueue) EnqueueTask(task Task) error {
+	if tq.ctx.Err() != nil {
+		return fmt.Errorf("queue is shutting down")
+	}
+
 	select {
-	case tq.jobChannel <- jobID:
+	case tq.taskChannel <- task:
 		return nil
 	case <-tq.ctx.Done():
 		return fmt.Errorf("queue is shutting down")
@@ -160,33 +324,103 @@ func (tq *TaskQueue) EnqueueJob(jobID string) error {
 	}
 }
 
-// worker processes jobs from the channel
-func (tq *TaskQueue) worker(id int) {
+// taskWorker runs tasks until the queue stops, logging their failures
+func (tq *TaskQueue) taskWorker(id int) {
 	defer tq.wg.Done()
 
-	logger.Debug("Worker started", "worker_id", id)
+	for {
+		select {
+		case <-tq.ctx.Done():
+			return
+		case task := <-tq.taskChannel:
+			qLog.Debug("Task worker operation", "task_worker_id", id, "task_id", task.TaskID(), "operation", "start")
+			if err := task.Run(tq.ctx); err != nil {
+				qLog.Error("Task failed", "task_worker_id", id, "task_id", task.TaskID(), "error", err)
+				continue
+			}
+			qLog.Debug("Task processed successfully", "task_worker_id", id, "task_id", task.TaskID())
+		}
+	}
+}
+
+// worker processes jobs from the channel until stop is closed
+func (tq *TaskQueue) worker(id int, stop <-chan struct{}) {
+	defer tq.wg.Done()
+
+	qLog.Debug("Worker started", "worker_id", id)
 
 	for {
+		// A stopped worker takes no more work, even with some waiting
+		select {
+		case <-stop:
+			qLog.Debug("Worker stopped", "worker_id", id, "reason", "scaled_down")
+			return
+		default:
+		}
+
 		select {
-		case jobID, ok := <-tq.jobChannel:
+		case <-stop:
+			qLog.Debug("Worker stopped", "worker_id", id, "reason", "scaled_down")
+			return
+
+		case jobID, ok := <-tq.readyChannel:
 			if !ok {
-				logger.Debug("Worker stopped", "worker_id", id)
+				qLog.Debug("Worker stopped", "worker_id", id)
 				return
 			}
 
-			logger.WorkerOperation(id, jobID, "start")
+			// A job scheduled for later is enqueued again by the scheduler
+			if tq.notYetDue(jobID) {
+				tq.forgetEnqueued(jobID)
+				qLog.Debug("Holding back scheduled job", "worker_id", id, "job_id", jobID)
+				continue
+			}
 
-			// Update job status to processing
-			if err := tq.updateJobStatus(jobID, models.StatusProcessing); err != nil {
-				logger.Error("Failed to update job status", "worker_id", id, "job_id", jobID, "error", err)
+			qLog.Debug("Worker operation", "worker_id", id, "job_id", jobID, "operation", "start")
+			tq.observeQueueWait(jobID)
+
+			// Wait for a device with room for the job's models
+			var lease *devices.Lease
+			if tq.devices != nil {
+				tq.trackWorker(id, jobID, WorkerWaitingForDevice)
+				var err error
+				if lease, err = tq.acquireDevice(jobID); err != nil {
+					tq.trackWorker(id, "", WorkerIdle)
+					switch {
+					case errors.Is(err, devices.ErrNoDevice):
+						tq.failJob(jobID, err.Error())
+					case !errors.Is(err, errDuplicate) && tq.ctx.Err() == nil:
+						qLog.Error("Failed to assign a device", "worker_id", id, "job_id", jobID, "error", err)
+					}
+					continue
+				}
+			}
+
+			// Move the job to processing; this also drops duplicate
+			// enqueues of jobs another worker already picked up or finished
+			if _, err := jobstate.Transition(jobID, models.StatusProcessing); err != nil {
+				qLog.Error("Failed to update job status", "worker_id", id, "job_id", jobID, "error", err)
+				tq.trackWorker(id, "", WorkerIdle)
+				if lease != nil {
+					lease.Release()
+				}
 				continue
 			}
+			tq.trackWorker(id, jobID, WorkerRunning)
 
 			// Create context for this job and track it
-			jobCtx, jobCancel := context.WithCancel(tq.ctx)
+			spanCtx, span := telemetry.Start(logger.WithJobID(tq.ctx, jobID), "queue.process_job", telemetry.SpanKindConsumer,
+				telemetry.String("job.id", jobID),
+				telemetry.Int("worker.id", id))
+			if lease != nil {
+				spanCtx = devices.WithLease(spanCtx, lease)
+				span.SetAttributes(telemetry.String("device.id", lease.Device.ID))
+			}
+			jobCtx, jobCancel := context.WithCancelCause(spanCtx)
 			runningJob := &RunningJob{
-				Cancel:  jobCancel,
+				Cancel:  func() { jobCancel(nil) },
 				Process: nil, // Will be set by registerProcess callback
+				cancel:  jobCancel,
 			}
 
 			tq.jobsMutex.Lock()
@@ -212,67 +446,170 @@ func (tq *TaskQueue) worker(id int) {
 
 			// Handle result
 			if err != nil {
-				if jobCtx.Err() == context.Canceled {
-					logger.Info("Job cancelled", "worker_id", id, "job_id", jobID)
-					tq.updateJobStatus(jobID, models.StatusFailed)
-					tq.updateJobError(jobID, "Job was cancelled by user")
-				} else {
-					logger.Error("Job processing failed", "worker_id", id, "job_id", jobID, "error", err)
-					tq.updateJobStatus(jobID, models.StatusFailed)
-					tq.updateJobError(jobID, err.Error())
+				span.RecordError(err)
+				switch {
+				case errors.Is(context.Cause(jobCtx), ErrPaused):
+					qLog.InfoContext(jobCtx, "Job paused", "worker_id", id, "job_id", jobID)
+					if _, err := jobstate.Transition(jobID, models.StatusPaused); err != nil {
+						qLog.Error("Failed to update job status", "worker_id", id, "job_id", jobID, "error", err)
+					}
+				case tq.ctx.Err() != nil:
+					// The queue is stopping, not the user cancelling: the job
+					// stays processing for RecoverJobs to pick up on restart
+					qLog.InfoContext(jobCtx, "Job interrupted by shutdown", "worker_id", id, "job_id", jobID)
+				case jobCtx.Err() == context.Canceled:
+					qLog.InfoContext(jobCtx, "Job cancelled", "worker_id", id, "job_id", jobID)
+					tq.failJob(jobID, "Job was cancelled by user")
+					metrics.ObserveJobOutcome(metrics.OutcomeCancelled)
+					tq.recordFinished(false)
+				default:
+					qLog.ErrorContext(jobCtx, "Job processing failed", "worker_id", id, "job_id", jobID, "error", err)
+					tq.failJob(jobID, err.Error())
+					metrics.ObserveJobOutcome(metrics.OutcomeFailed)
+					tq.recordFinished(false)
 				}
 			} else {
-				logger.Debug("Job processed successfully", "worker_id", id, "job_id", jobID)
-				tq.updateJobStatus(jobID, models.StatusCompleted)
+				qLog.DebugContext(jobCtx, "Job processed successfully", "worker_id", id, "job_id", jobID)
+				if _, err := jobstate.Transition(jobID, models.StatusCompleted); err != nil {
+					qLog.Error("Failed to update job status", "worker_id", id, "job_id", jobID, "error", err)
+				}
+				metrics.ObserveJobOutcome(metrics.OutcomeSucceeded)
+				tq.recordFinished(true)
 			}
+			tq.trackWorker(id, "", WorkerIdle)
+			jobCancel(nil)
+			if lease != nil {
+				lease.Release()
+			}
+			span.End()
 
 		case <-tq.ctx.Done():
-			logger.Debug("Worker stopped", "worker_id", id, "reason", "context_cancelled")
+			qLog.Debug("Worker stopped", "worker_id", id, "reason", "context_cancelled")
 			return
 		}
 	}
 }
 
+// acquireDevice waits for a device able to run a job, or for the queue to
+// stop. Copies of the job the scanner enqueues meanwhile are dropped.
+func (tq *TaskQueue) acquireDevice(jobID string) (*devices.Lease, error) {
+	tq.jobsMutex.Lock()
+	if tq.waitingJobs[jobID] {
+		tq.jobsMutex.Unlock()
+		return nil, errDuplicate
+	}
+	tq.waitingJobs[jobID] = true
+	tq.jobsMutex.Unlock()
+	defer func() {
+		tq.jobsMutex.Lock()
+		delete(tq.waitingJobs, jobID)
+		tq.jobsMutex.Unlock()
+	}()
+
+	var job models.TranscriptionJob
+	if err := database.DB.Select("id", "status", "model_family", "model", "device", "compute_type", "diarize").
+		Where("id = ?", jobID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	// A duplicate of a job already running or finished must not wait
+	if !jobstate.CanTransition(job.Status, models.StatusProcessing) || job.Status == models.StatusProcessing {
+		return nil, errDuplicate
+	}
+	return tq.devices.Acquire(tq.ctx, devices.RequestFor(jobID, job.Parameters))
+}
+
 // jobScanner scans for pending jobs and adds them to the queue
 func (tq *TaskQueue) jobScanner() {
 	defer tq.wg.Done()
 
-	ticker := time.NewTicker(10 * time.Second) // Scan every 10 seconds
+	ticker := tq.clock.NewTicker(10 * time.Second) // Scan every 10 seconds
 	defer ticker.Stop()
 
-	logger.Debug("Job scanner started")
+	qLog.Debug("Job scanner started")
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			tq.scanPendingJobs()
 		case <-tq.ctx.Done():
-			logger.Debug("Job scanner stopped")
+			qLog.Debug("Job scanner stopped")
 			return
 		}
 	}
 }
 
-// scanPendingJobs finds pending jobs and enqueues them
+// scanPendingJobs finds pending jobs that are due and enqueues them
 func (tq *TaskQueue) scanPendingJobs() {
 	var jobs []models.TranscriptionJob
 
-	if err := database.DB.Where("status = ?", models.StatusPending).Find(&jobs).Error; err != nil {
-		logger.Error("Failed to scan pending jobs", "error", err)
+	if err := database.DB.Where("status = ? AND (run_after IS NULL OR run_after <= ?)", models.StatusPending, tq.clock.Now().UTC()).Find(&jobs).Error; err != nil {
+		qLog.Error("Failed to scan pending jobs", "error", err)
 		return
 	}
 
 	for _, job := range jobs {
-		select {
-		case tq.jobChannel <- job.ID:
-			logger.Debug("Enqueued pending job", "job_id", job.ID)
-		default:
-			logger.Warn("Queue full, skipping job", "job_id", job.ID)
-			break
+		marked := tq.markEnqueued(job.ID)
+		if tq.offer(job.ID) {
+			qLog.Debug("Enqueued pending job", "job_id", job.ID)
+		} else {
+			tq.unmarkEnqueued(job.ID, marked)
+			qLog.Warn("Queue full, skipping job", "job_id", job.ID)
 		}
 	}
 }
 
+// markEnqueued remembers when a job first entered the queue, reporting
+// whether this call recorded it. It runs before the send, so a worker
+// taking the job straight away still finds the time.
+func (tq *TaskQueue) markEnqueued(jobID string) bool {
+	tq.jobsMutex.Lock()
+	defer tq.jobsMutex.Unlock()
+
+	if _, exists := tq.enqueuedAt[jobID]; exists {
+		return false
+	}
+	tq.enqueuedAt[jobID] = tq.clock.Now()
+	return true
+}
+
+// unmarkEnqueued undoes markEnqueued for a job that never made it into the
+// channel. A time recorded by an earlier send is kept, as that copy of the
+// job is still waiting.
+func (tq *TaskQueue) unmarkEnqueued(jobID string, marked bool) {
+	if marked {
+		tq.forgetEnqueued(jobID)
+	}
+}
+
+// forgetEnqueued drops the enqueue time of a job that left the queue unprocessed
+func (tq *TaskQueue) forgetEnqueued(jobID string) {
+	tq.jobsMutex.Lock()
+	defer tq.jobsMutex.Unlock()
+
+	delete(tq.enqueuedAt, jobID)
+}
+
+// notYetDue reports whether a job is scheduled to run after now
+func (tq *TaskQueue) notYetDue(jobID string) bool {
+	var job models.TranscriptionJob
+	if err := database.DB.Select("id", "run_after").Where("id = ?", jobID).First(&job).Error; err != nil {
+		return false
+	}
+	return job.RunAfter != nil && job.RunAfter.After(tq.clock.Now())
+}
+
+// observeQueueWait reports how long a job sat in the queue before a worker took it
+func (tq *TaskQueue) observeQueueWait(jobID string) {
+	tq.jobsMutex.Lock()
+	enqueuedAt, exists := tq.enqueuedAt[jobID]
+	delete(tq.enqueuedAt, jobID)
+	tq.jobsMutex.Unlock()
+
+	if exists {
+		metrics.ObserveQueueWait(tq.clock.Since(enqueuedAt))
+	}
+}
+
 // KillJob aggressively terminates a running job
 func (tq *TaskQueue) KillJob(jobID string) error {
 	tq.jobsMutex.Lock()
@@ -283,36 +620,81 @@ func (tq *TaskQueue) KillJob(jobID string) error {
 		return fmt.Errorf("job %s is not currently running", jobID)
 	}
 
-	logger.Info("Killing job", "job_id", jobID)
+	qLog.Info("Killing job", "job_id", jobID)
+	tq.stopJob(jobID, runningJob, nil)
 
+	// Immediately update job status without waiting for process to finish
+	go func() {
+		tq.failJob(jobID, "Job was forcefully terminated by user")
+	}()
+
+	return nil
+}
+
+// stopJob kills the processes of a running job and cancels its context
+// with cause; the caller holds jobsMutex
+func (tq *TaskQueue) stopJob(jobID string, runningJob *RunningJob, cause error) {
 	// Check if this is a multi-track job and handle accordingly
 	if mtProcessor, ok := tq.processor.(MultiTrackJobProcessor); ok && mtProcessor.IsMultiTrackJob(jobID) {
-		logger.Debug("Terminating multi-track job", "job_id", jobID)
+		qLog.Debug("Terminating multi-track job", "job_id", jobID)
 		
 		// Terminate all individual track jobs
 		if err := mtProcessor.TerminateMultiTrackJob(jobID); err != nil {
-			logger.Error("Failed to terminate multi-track job", "job_id", jobID, "error", err)
+			qLog.Error("Failed to terminate multi-track job", "job_id", jobID, "error", err)
 		}
 	}
 
 	// First, try to kill the OS process group (or process on non-Unix)
 	if runningJob.Process != nil && runningJob.Process.Process != nil {
-		logger.Debug("Terminating process tree", "pid", runningJob.Process.Process.Pid, "job_id", jobID)
+		qLog.Debug("Terminating process tree", "pid", runningJob.Process.Process.Pid, "job_id", jobID)
 		if err := killProcessTree(runningJob.Process.Process); err != nil {
-			log.Printf("Failed to terminate process tree for job %s: %v, trying direct kill()", jobID, err)
+			qLog.Warn("Failed to terminate process tree, trying direct kill", "job_id", jobID, "error", err)
 			_ = runningJob.Process.Process.Kill()
 		}
 	}
 
 	// Also cancel the context for cleanup
-	runningJob.Cancel()
+	runningJob.cancel(cause)
+}
 
-	// Immediately update job status without waiting for process to finish
-	go func() {
-		tq.updateJobStatus(jobID, models.StatusFailed)
-		tq.updateJobError(jobID, "Job was forcefully terminated by user")
-	}()
+// CancelJob stops a job for good. A running job is cancelled, taking its
+// model processes down with it, and fails once its worker returns; a pending
+// or paused job fails straight away, so no worker picks it up.
+func (tq *TaskQueue) CancelJob(jobID string) error {
+	tq.jobsMutex.Lock()
+	if runningJob, exists := tq.runningJobs[jobID]; exists {
+		qLog.Info("Cancelling job", "job_id", jobID)
+		tq.stopJob(jobID, runningJob, nil)
+		tq.jobsMutex.Unlock()
+		return nil
+	}
+	delete(tq.enqueuedAt, jobID)
+	tq.jobsMutex.Unlock()
+
+	job, err := tq.GetJobStatus(jobID)
+	if err != nil {
+		return err
+	}
+	if job.Status != models.StatusPending && job.Status != models.StatusPaused {
+		return fmt.Errorf("job %s is %s and cannot be cancelled", jobID, job.Status)
+	}
+	_, err = jobstate.Transition(jobID, models.StatusFailed, jobstate.WithError("Job was cancelled by user"))
+	return err
+}
+
+// PauseJob stops a running job so it can be resumed later. The processor
+// sees ErrPaused as the cause of the cancellation and may checkpoint what it
+// has transcribed; the job is paused once its worker returns.
+func (tq *TaskQueue) PauseJob(jobID string) error {
+	tq.jobsMutex.Lock()
+	defer tq.jobsMutex.Unlock()
 
+	runningJob, exists := tq.runningJobs[jobID]
+	if !exists {
+		return fmt.Errorf("job %s is not currently running", jobID)
+	}
+	qLog.Info("Pausing job", "job_id", jobID)
+	tq.stopJob(jobID, runningJob, ErrPaused)
 	return nil
 }
 
@@ -325,18 +707,11 @@ func (tq *TaskQueue) IsJobRunning(jobID string) bool {
 	return exists
 }
 
-// updateJobStatus updates the status of a job
-func (tq *TaskQueue) updateJobStatus(jobID string, status models.JobStatus) error {
-	return database.DB.Model(&models.TranscriptionJob{}).
-		Where("id = ?", jobID).
-		Update("status", status).Error
-}
-
-// updateJobError updates the error message of a job
-func (tq *TaskQueue) updateJobError(jobID string, errorMsg string) error {
-	return database.DB.Model(&models.TranscriptionJob{}).
-		Where("id = ?", jobID).
-		Update("error_message", errorMsg).Error
+// failJob marks a job as failed with the given error message
+func (tq *TaskQueue) failJob(jobID string, errorMsg string) {
+	if _, err := jobstate.Transition(jobID, models.StatusFailed, jobstate.WithError(errorMsg)); err != nil {
+		qLog.Error("Failed to mark job as failed", "job_id", jobID, "error", err)
+	}
 }
 
 // GetJobStatus gets the status of a job
@@ -353,65 +728,139 @@ func (tq *TaskQueue) GetJobStatus(jobID string) (*models.TranscriptionJob, error
 func (tq *TaskQueue) autoScaler() {
 	defer tq.wg.Done()
 
-	ticker := time.NewTicker(30 * time.Second) // Check every 30 seconds
+	ticker := tq.clock.NewTicker(30 * time.Second) // Check every 30 seconds
 	defer ticker.Stop()
 
-	log.Println("Auto-scaler started")
+	qLog.Debug("Auto-scaler started")
 
 	for {
 		select {
-		case <-ticker.C:
+		case <-ticker.C():
 			tq.checkAndScale()
 		case <-tq.ctx.Done():
-			log.Println("Auto-scaler stopped")
+			qLog.Debug("Auto-scaler stopped")
 			return
 		}
 	}
 }
 
-// checkAndScale evaluates current load and adjusts worker count
+// checkAndScale adds a worker while jobs wait for one and the system has
+// CPU to spare, and removes one while workers idle or the system is
+// overloaded, staying within the worker limits
 func (tq *TaskQueue) checkAndScale() {
+	tq.workerMutex.Lock()
+	defer tq.workerMutex.Unlock()
+
 	// Prevent too frequent scaling
-	if time.Since(tq.lastScaleTime) < 1*time.Minute {
+	if !tq.autoScale || tq.clock.Since(tq.lastScaleTime) < 1*time.Minute {
 		return
 	}
 
-	queueSize := len(tq.jobChannel)
+	queueSize := tq.queueDepth()
 	currentWorkers := int(atomic.LoadInt64(&tq.currentWorkers))
 	
 	tq.jobsMutex.RLock()
 	runningJobsCount := len(tq.runningJobs)
 	tq.jobsMutex.RUnlock()
 
-	// Scale up if queue is building up and we have capacity
-	if queueSize > 10 && currentWorkers < tq.maxWorkers {
-		newWorkerCount := currentWorkers + 1
-		log.Printf("Scaling up workers: %d -> %d (queue size: %d)", currentWorkers, newWorkerCount, queueSize)
-		
-		atomic.StoreInt64(&tq.currentWorkers, int64(newWorkerCount))
-		tq.wg.Add(1)
-		go tq.worker(newWorkerCount - 1)
-		tq.lastScaleTime = time.Now()
-		
-	// Scale down if queue is empty and minimal jobs running
-	} else if queueSize == 0 && runningJobsCount <= 1 && currentWorkers > tq.minWorkers {
-		newWorkerCount := currentWorkers - 1
-		log.Printf("Scaling down workers: %d -> %d (queue size: %d, running: %d)", 
-			currentWorkers, newWorkerCount, queueSize, runningJobsCount)
-		
-		atomic.StoreInt64(&tq.currentWorkers, int64(newWorkerCount))
-		tq.lastScaleTime = time.Now()
-		
-		// Note: We don't actively stop workers here. They will naturally exit 
-		// when no more jobs are available and the queue empties.
+	load, hasLoad := tq.systemLoad()
+	overloaded := hasLoad && tq.maxLoad > 0 && load > tq.maxLoad
+
+	switch {
+	// Scale up if jobs are waiting for a worker and the CPUs have room
+	case queueSize > 0 && !overloaded && currentWorkers < tq.maxWorkers:
+		qLog.Info("Scaling up workers", "from", currentWorkers, "to", currentWorkers+1,
+			"queue_size", queueSize, "load", load)
+		tq.resize(currentWorkers + 1)
+		tq.lastScaleTime = tq.clock.Now()
+
+	// Scale down if workers idle or more of them would only add to the load
+	case (overloaded || (queueSize == 0 && runningJobsCount < currentWorkers)) && currentWorkers > tq.minWorkers:
+		qLog.Info("Scaling down workers", "from", currentWorkers, "to", currentWorkers-1,
+			"queue_size", queueSize, "running", runningJobsCount, "load", load)
+		tq.resize(currentWorkers - 1)
+		tq.lastScaleTime = tq.clock.Now()
+	}
+}
+
+// MaxWorkerLimit caps the worker limits SetWorkerLimits accepts
+const MaxWorkerLimit = 64
+
+// WorkerLimits is how many workers the queue runs: between Min and Max,
+// scaled with the load when AutoScale is set, otherwise Min
+type WorkerLimits struct {
+	Min       int  `json:"min_workers"`
+	Max       int  `json:"max_workers"`
+	AutoScale bool `json:"auto_scale"`
+}
+
+// WorkerLimits returns the current worker limits
+func (tq *TaskQueue) WorkerLimits() WorkerLimits {
+	tq.workerMutex.Lock()
+	defer tq.workerMutex.Unlock()
+	return WorkerLimits{Min: tq.minWorkers, Max: tq.maxWorkers, AutoScale: tq.autoScale}
+}
+
+// CurrentWorkers returns how many workers the queue runs
+func (tq *TaskQueue) CurrentWorkers() int {
+	return int(atomic.LoadInt64(&tq.currentWorkers))
+}
+
+// SetWorkerLimits changes the worker limits at runtime, starting workers or
+// stopping them once their current job finishes to bring the count within
+// them; without auto-scaling the count becomes the minimum
+func (tq *TaskQueue) SetWorkerLimits(limits WorkerLimits) error {
+	if limits.Min < 1 || limits.Max < limits.Min || limits.Max > MaxWorkerLimit {
+		return fmt.Errorf("worker limits must satisfy 1 <= min <= max <= %d", MaxWorkerLimit)
+	}
+	if tq.ctx.Err() != nil {
+		return fmt.Errorf("queue is shutting down")
 	}
+
+	tq.workerMutex.Lock()
+	defer tq.workerMutex.Unlock()
+
+	tq.minWorkers = limits.Min
+	tq.maxWorkers = limits.Max
+	tq.autoScale = limits.AutoScale && limits.Min < limits.Max
+
+	current := int(atomic.LoadInt64(&tq.currentWorkers))
+	count := min(max(current, limits.Min), limits.Max)
+	if !tq.autoScale {
+		count = limits.Min
+	}
+	if tq.autoScale && tq.started {
+		tq.startAutoScaler()
+	}
+	if count != current {
+		qLog.Info("Resizing workers", "from", current, "to", count, "min_workers", limits.Min, "max_workers", limits.Max)
+		tq.resize(count)
+		tq.lastScaleTime = tq.clock.Now()
+	}
+	return nil
+}
+
+// RegisterMetrics exposes live queue gauges on the metrics endpoint
+func (tq *TaskQueue) RegisterMetrics() {
+	metrics.RegisterGauge("synthezia_queue_depth", "Jobs waiting in the in-memory queue.", func() float64 {
+		return float64(tq.queueDepth())
+	})
+	metrics.RegisterGauge("synthezia_queue_running_jobs", "Jobs currently being processed by workers.", func() float64 {
+		tq.jobsMutex.RLock()
+		defer tq.jobsMutex.RUnlock()
+		return float64(len(tq.runningJobs))
+	})
+	metrics.RegisterGauge("synthezia_queue_workers", "Current number of queue workers.", func() float64 {
+		return float64(atomic.LoadInt64(&tq.currentWorkers))
+	})
 }
 
 // GetQueueStats returns queue statistics
 func (tq *TaskQueue) GetQueueStats() map[string]interface{} {
-	var pendingCount, processingCount, completedCount, failedCount int64
+	var pendingCount, scheduledCount, processingCount, completedCount, failedCount int64
 
 	database.DB.Model(&models.TranscriptionJob{}).Where("status = ?", models.StatusPending).Count(&pendingCount)
+	database.DB.Model(&models.TranscriptionJob{}).Where("status = ? AND run_after > ?", models.StatusPending, tq.clock.Now().UTC()).Count(&scheduledCount)
 	database.DB.Model(&models.TranscriptionJob{}).Where("status = ?", models.StatusProcessing).Count(&processingCount)
 	database.DB.Model(&models.TranscriptionJob{}).Where("status = ?", models.StatusCompleted).Count(&completedCount)
 	database.DB.Model(&models.TranscriptionJob{}).Where("status = ?", models.StatusFailed).Count(&failedCount)
@@ -419,18 +868,26 @@ func (tq *TaskQueue) GetQueueStats() map[string]interface{} {
 	tq.jobsMutex.RLock()
 	runningJobsCount := len(tq.runningJobs)
 	tq.jobsMutex.RUnlock()
+	limits := tq.WorkerLimits()
 
-	return map[string]interface{}{
-		"queue_size":       len(tq.jobChannel),
-		"queue_capacity":   cap(tq.jobChannel),
+	stats := map[string]interface{}{
+		"queue_size":       tq.queueDepth(),
+		"queue_capacity":   queueCapacity,
 		"current_workers":  int(atomic.LoadInt64(&tq.currentWorkers)),
-		"min_workers":      tq.minWorkers,
-		"max_workers":      tq.maxWorkers,
-		"auto_scale":       tq.autoScale,
+		"min_workers":      limits.Min,
+		"max_workers":      limits.Max,
+		"auto_scale":       limits.AutoScale,
+		"fair_share":       tq.fairShare,
+		"task_workers":     tq.taskWorkers,
 		"running_jobs":     runningJobsCount,
 		"pending_jobs":     pendingCount,
+		"scheduled_jobs":   scheduledCount, // Pending jobs waiting for their run_after time
 		"processing_jobs":  processingCount,
 		"completed_jobs":   completedCount,
 		"failed_jobs":      failedCount,
 	}
+	if tq.devices != nil {
+		stats["devices"] = tq.devices.Devices()
+	}
+	return stats
 }
@@ -0,0 +1,131 @@
+package queue
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// RecoveryPolicy is what startup does with jobs a shutdown or crash left processing
+type RecoveryPolicy string
+
+const (
+	RecoveryRequeue RecoveryPolicy = "requeue" // Transcribe them again from the start
+	RecoveryFail    RecoveryPolicy = "fail"    // Fail them, to be retried by hand
+)
+
+// ParseRecoveryPolicy reads a recovery policy, "requeue" or "fail"
+func ParseRecoveryPolicy(value string) (RecoveryPolicy, error) {
+	switch policy := RecoveryPolicy(strings.ToLower(strings.TrimSpace(value))); policy {
+	case RecoveryRequeue, RecoveryFail:
+		return policy, nil
+	default:
+		return "", fmt.Errorf("unknown recovery policy %q, use requeue or fail", value)
+	}
+}
+
+// RecoveryReport counts what RecoverJobs did
+type RecoveryReport struct {
+	Requeued int // Interrupted jobs made pending again
+	Failed   int // Interrupted jobs failed by the policy or their attempts
+	Enqueued int // Pending jobs handed to the workers
+}
+
+// trackJobPrefix starts the IDs of the temporary jobs multi-track jobs
+// transcribe their tracks in
+const trackJobPrefix = "track_"
+
+// RecoverJobs settles the jobs the last shutdown or crash left behind; call
+// it before Start, while no job of this process is processing. Jobs left
+// processing are requeued, or failed under RecoveryFail or once they were
+// requeued maxAttempts times (0 does not limit them), and their unfinished
+// executions are closed as failed. Temporary track jobs are deleted, as
+// their multi-track job transcribes its tracks again. Pending jobs that are
+// due are enqueued at once rather than at the first scan.
+func (tq *TaskQueue) RecoverJobs(policy RecoveryPolicy, maxAttempts int) (RecoveryReport, error) {
+	var report RecoveryReport
+
+	var jobs []models.TranscriptionJob
+	if err := database.DB.Select("id", "recoveries").Where("status = ?", models.StatusProcessing).Find(&jobs).Error; err != nil {
+		return report, fmt.Errorf("failed to find interrupted jobs: %w", err)
+	}
+
+	for _, job := range jobs {
+		if strings.HasPrefix(job.ID, trackJobPrefix) {
+			if err := deleteTrackJob(job.ID); err != nil {
+				qLog.Warn("Failed to delete interrupted track job", "job_id", job.ID, "error", err)
+			}
+			continue
+		}
+
+		closeErr := closeExecutions(job.ID)
+		var err error
+		switch {
+		case policy == RecoveryFail:
+			_, err = jobstate.Transition(job.ID, models.StatusFailed, jobstate.WithError("Job was interrupted by a server restart"))
+			report.Failed++
+		case maxAttempts > 0 && job.Recoveries >= maxAttempts:
+			msg := fmt.Sprintf("Job was interrupted by %d server restarts", job.Recoveries+1)
+			_, err = jobstate.Transition(job.ID, models.StatusFailed, jobstate.WithError(msg))
+			report.Failed++
+		default:
+			_, err = jobstate.Transition(job.ID, models.StatusPending, jobstate.WithFields(map[string]interface{}{
+				"recoveries": gorm.Expr("recoveries + 1"),
+			}))
+			report.Requeued++
+		}
+		if err != nil {
+			return report, fmt.Errorf("failed to recover job %s: %w", job.ID, err)
+		}
+		if closeErr != nil {
+			qLog.Warn("Failed to close interrupted executions", "job_id", job.ID, "error", closeErr)
+		}
+		qLog.Info("Recovered interrupted job", "job_id", job.ID, "policy", policy, "recoveries", job.Recoveries)
+	}
+
+	var pending []string
+	if err := database.DB.Model(&models.TranscriptionJob{}).
+		Where("status = ? AND (run_after IS NULL OR run_after <= ?)", models.StatusPending, tq.clock.Now().UTC()).
+		Order("created_at ASC").Pluck("id", &pending).Error; err != nil {
+		return report, fmt.Errorf("failed to find pending jobs: %w", err)
+	}
+	for _, jobID := range pending {
+		// The scanner enqueues what does not fit
+		if err := tq.EnqueueJob(jobID); err != nil {
+			break
+		}
+		report.Enqueued++
+	}
+	return report, nil
+}
+
+// closeExecutions marks the executions a job had running as failed
+func closeExecutions(jobID string) error {
+	now := time.Now()
+	return database.DB.Model(&models.TranscriptionJobExecution{}).
+		Where("transcription_job_id = ? AND completed_at IS NULL", jobID).
+		Updates(map[string]interface{}{
+			"completed_at":  now,
+			"status":        models.StatusFailed,
+			"error_message": "Interrupted by a server restart",
+		}).Error
+}
+
+// deleteTrackJob removes a temporary track job and its records
+func deleteTrackJob(jobID string) error {
+	return database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("transcription_job_id = ?", jobID).Delete(&models.TranscriptionJobExecution{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("transcription_job_id = ?", jobID).Delete(&models.SpeakerMapping{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&models.TranscriptionJob{}, "id = ?", jobID).Error
+	})
+}
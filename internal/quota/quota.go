@@ -0,0 +1,245 @@
+// Package quota measures the disk space each user's jobs take, the audio and
+// everything derived from it, and enforces the per-user storage quota so one
+// user cannot fill the disk.
+package quota
+
+import (
+	"fmt"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+
+	"gorm.io/gorm"
+)
+
+// Usage is the space taken by a set of jobs
+type Usage struct {
+	UserID   *uint  `json:"user_id,omitempty"` // nil for jobs no user owns
+	Username string `json:"username,omitempty"`
+	Jobs     int64  `json:"jobs"`
+	// AudioBytes is the audio as submitted: uploads, project files and tracks
+	AudioBytes int64 `json:"audio_bytes"`
+	// ArtifactBytes is what was made from it: proxies, mixdowns, waveforms,
+	// and transcripts, revisions, translations and summaries
+	ArtifactBytes int64 `json:"artifact_bytes"`
+	TotalBytes    int64 `json:"total_bytes"`
+	// QuotaBytes is the user's quota; 0 when unlimited
+	QuotaBytes     int64  `json:"quota_bytes"`
+	RemainingBytes *int64 `json:"remaining_bytes,omitempty"` // nil when unlimited
+}
+
+// ExceededError is returned when new audio would take a user over quota
+type ExceededError struct {
+	Usage    Usage
+	Incoming int64
+}
+
+func (e *ExceededError) Error() string {
+	if e.Incoming > 0 {
+		return fmt.Sprintf("storage quota exceeded: %s of %s used, and this upload needs %s more",
+			FormatBytes(e.Usage.TotalBytes), FormatBytes(e.Usage.QuotaBytes), FormatBytes(e.Incoming))
+	}
+	return fmt.Sprintf("storage quota exceeded: %s of %s used",
+		FormatBytes(e.Usage.TotalBytes), FormatBytes(e.Usage.QuotaBytes))
+}
+
+// FormatBytes writes a size for people, such as "1.5 GB"
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// Service measures usage and checks it against the quota
+type Service struct {
+	db    *gorm.DB
+	fs    fsys.FS
+	limit int64
+}
+
+// NewService creates a service without a quota; a nil db uses database.DB at
+// call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db, fs: fsys.OS}
+}
+
+// SetFS overrides the filesystem, mainly for tests
+func (s *Service) SetFS(fs fsys.FS) {
+	s.fs = fs
+}
+
+// SetLimit sets the bytes each user may store; 0 is unlimited
+func (s *Service) SetLimit(bytes int64) {
+	s.limit = max(0, bytes)
+}
+
+// Limit is the bytes each user may store; 0 is unlimited
+func (s *Service) Limit() int64 {
+	return s.limit
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Usage measures the jobs a user owns
+func (s *Service) Usage(userID uint) (*Usage, error) {
+	usage, err := s.measure(s.conn().Where("user_id = ?", userID))
+	if err != nil {
+		return nil, err
+	}
+	usage.UserID = &userID
+	s.applyLimit(usage)
+	return usage, nil
+}
+
+// Report measures every user's jobs, users without jobs included, and last
+// the jobs no user owns
+func (s *Service) Report() ([]Usage, error) {
+	var users []models.User
+	if err := s.conn().Select("id", "username").Order("username ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	report := make([]Usage, 0, len(users)+1)
+	for _, user := range users {
+		usage, err := s.Usage(user.ID)
+		if err != nil {
+			return nil, err
+		}
+		usage.Username = user.Username
+		report = append(report, *usage)
+	}
+	unowned, err := s.measure(s.conn().Where("user_id IS NULL"))
+	if err != nil {
+		return nil, err
+	}
+	return append(report, *unowned), nil
+}
+
+// Check returns an *ExceededError when storing incoming more bytes would
+// take the user over quota, or the user is over it already. Jobs without a
+// user and servers without a quota are not limited.
+func (s *Service) Check(userID *uint, incoming int64) error {
+	if userID == nil || s.limit <= 0 {
+		return nil
+	}
+	usage, err := s.Usage(*userID)
+	if err != nil {
+		return fmt.Errorf("failed to measure storage usage: %w", err)
+	}
+	if usage.TotalBytes+incoming > usage.QuotaBytes || (incoming == 0 && usage.TotalBytes >= usage.QuotaBytes) {
+		return &ExceededError{Usage: *usage, Incoming: incoming}
+	}
+	return nil
+}
+
+func (s *Service) applyLimit(usage *Usage) {
+	usage.QuotaBytes = s.limit
+	if s.limit > 0 {
+		remaining := max(0, s.limit-usage.TotalBytes)
+		usage.RemainingBytes = &remaining
+	}
+}
+
+// jobFiles is what measure reads of a job
+type jobFiles struct {
+	ID              string
+	AudioPath       string
+	AupFilePath     *string
+	ProxyAudioPath  *string
+	WaveformPath    *string
+	MergedAudioPath *string
+	TextBytes       int64
+}
+
+// measure adds up the files and stored text of the jobs the scope selects.
+// Files shared by several jobs, such as a mixdown that is also the job's
+// audio, count once; files moved to the storage backend count at their
+// stored size, and files already removed as nothing.
+func (s *Service) measure(scope *gorm.DB) (*Usage, error) {
+	var jobs []jobFiles
+	err := scope.Model(&models.TranscriptionJob{}).
+		Select(`id, audio_path, aup_file_path, proxy_audio_path, waveform_path, merged_audio_path,
+			COALESCE(LENGTH(CAST(transcript AS BLOB)), 0) + COALESCE(LENGTH(CAST(summary AS BLOB)), 0) +
+			COALESCE(LENGTH(CAST(individual_transcripts AS BLOB)), 0) AS text_bytes`).
+		Where("id NOT LIKE 'track_%'").
+		Scan(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+	usage := &Usage{Jobs: int64(len(jobs))}
+	if len(jobs) == 0 {
+		return usage, nil
+	}
+
+	ids := make([]string, len(jobs))
+	for i, job := range jobs {
+		ids[i] = job.ID
+	}
+	var tracks []string
+	if err := s.conn().Model(&models.MultiTrackFile{}).Where("transcription_job_id IN ?", ids).Pluck("file_path", &tracks).Error; err != nil {
+		return nil, err
+	}
+	var revisionBytes, translationBytes int64
+	if err := s.conn().Model(&models.TranscriptRevision{}).Where("transcription_id IN ?", ids).
+		Select("COALESCE(SUM(LENGTH(CAST(transcript AS BLOB))), 0)").Scan(&revisionBytes).Error; err != nil {
+		return nil, err
+	}
+	if err := s.conn().Model(&models.Translation{}).Where("transcription_id IN ?", ids).
+		Select("COALESCE(SUM(LENGTH(CAST(transcript AS BLOB))), 0)").Scan(&translationBytes).Error; err != nil {
+		return nil, err
+	}
+	usage.ArtifactBytes = revisionBytes + translationBytes
+
+	counted := make(map[string]bool)
+	size := func(path *string) int64 {
+		if path == nil || *path == "" || counted[*path] {
+			return 0
+		}
+		info, err := s.fs.Stat(*path)
+		if err != nil || info.IsDir() {
+			return 0
+		}
+		counted[*path] = true
+		return info.Size()
+	}
+	for _, job := range jobs {
+		usage.AudioBytes += size(&job.AudioPath) + size(job.AupFilePath)
+		usage.ArtifactBytes += job.TextBytes
+	}
+	for i := range tracks {
+		usage.AudioBytes += size(&tracks[i])
+	}
+	for _, job := range jobs {
+		usage.ArtifactBytes += size(job.ProxyAudioPath) + size(job.WaveformPath) + size(job.MergedAudioPath)
+	}
+
+	var stored []models.StoredObject
+	if err := s.conn().Where("job_id IN ? AND kind <> ?", ids, models.StoredTranscript).Find(&stored).Error; err != nil {
+		return nil, err
+	}
+	for _, object := range stored {
+		if counted[object.LocalPath] {
+			continue // The local copy was kept and is counted already
+		}
+		counted[object.LocalPath] = true
+		if object.Kind == models.StoredMerged {
+			usage.ArtifactBytes += object.Size
+		} else {
+			usage.AudioBytes += object.Size
+		}
+	}
+	usage.TotalBytes = usage.AudioBytes + usage.ArtifactBytes
+	return usage, nil
+}
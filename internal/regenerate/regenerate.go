@@ -0,0 +1,328 @@
+// Package regenerate re-applies a summary template to existing transcriptions
+// in the background, so stored summaries follow the template after it has
+// been edited. Each run records per-transcription progress and failures.
+package regenerate
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/llm"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// ErrNothingToRegenerate means the selection matched no transcriptions
+var ErrNothingToRegenerate = errors.New("no transcriptions match the selection")
+
+// ErrNoModel means neither the request, the template nor the settings name a model
+var ErrNoModel = errors.New("no model specified and no default model configured")
+
+// itemTimeout bounds a single LLM call, matching the interactive summarizer
+const itemTimeout = 5 * time.Minute
+
+// Selection picks the transcriptions to regenerate
+type Selection struct {
+	// TranscriptionIDs limits the run to these transcriptions; when empty,
+	// every transcription with a summary from the template is selected
+	TranscriptionIDs []string
+	// OnlyStale skips transcriptions whose latest summary from the template
+	// is newer than the template's last change
+	OnlyStale bool
+	// Model overrides the template's model
+	Model string
+}
+
+// ServiceFunc returns the LLM service to use for a run
+type ServiceFunc func() (llm.Service, error)
+
+// Runner starts and tracks regeneration runs
+type Runner struct {
+	db      *gorm.DB
+	service ServiceFunc
+
+	mu      sync.Mutex
+	running map[string]chan struct{}
+}
+
+// NewRunner creates a runner; a nil db uses database.DB at call time
+func NewRunner(db *gorm.DB, service ServiceFunc) *Runner {
+	return &Runner{db: db, service: service, running: map[string]chan struct{}{}}
+}
+
+func (r *Runner) conn() *gorm.DB {
+	if r.db != nil {
+		return r.db
+	}
+	return database.DB
+}
+
+// Start records a run for the template and processes it in the background
+func (r *Runner) Start(templateID string, sel Selection) (*models.SummaryRegeneration, error) {
+	db := r.conn()
+
+	var tpl models.SummaryTemplate
+	if err := db.Where("id = ?", templateID).First(&tpl).Error; err != nil {
+		return nil, err
+	}
+
+	model := sel.Model
+	if model == "" {
+		model = tpl.Model
+	}
+	if model == "" {
+		var settings models.SummarySetting
+		if err := db.First(&settings).Error; err == nil {
+			model = settings.DefaultModel
+		}
+	}
+	if model == "" {
+		return nil, ErrNoModel
+	}
+
+	ids, err := r.selectTranscriptions(&tpl, sel)
+	if err != nil {
+		return nil, err
+	}
+	if len(ids) == 0 {
+		return nil, ErrNothingToRegenerate
+	}
+
+	run := models.SummaryRegeneration{
+		TemplateID: tpl.ID,
+		Model:      model,
+		Status:     models.RegenerationPending,
+		Total:      len(ids),
+	}
+	for _, id := range ids {
+		run.Items = append(run.Items, models.SummaryRegenerationItem{TranscriptionID: id, Status: models.RegenerationPending})
+	}
+	if err := db.Create(&run).Error; err != nil {
+		return nil, fmt.Errorf("failed to record regeneration: %w", err)
+	}
+
+	r.launch(run.ID)
+	return &run, nil
+}
+
+// selectTranscriptions returns completed transcriptions matching the selection
+func (r *Runner) selectTranscriptions(tpl *models.SummaryTemplate, sel Selection) ([]string, error) {
+	db := r.conn()
+
+	query := db.Model(&models.TranscriptionJob{}).
+		Where("status = ? AND transcript IS NOT NULL", models.StatusCompleted)
+	if len(sel.TranscriptionIDs) > 0 {
+		query = query.Where("id IN ?", sel.TranscriptionIDs)
+	} else {
+		query = query.Where("id IN (?)", db.Model(&models.Summary{}).Select("transcription_id").Where("template_id = ?", tpl.ID))
+	}
+	if sel.OnlyStale {
+		// Keep transcriptions without a summary from the template newer than the template itself
+		query = query.Where("id NOT IN (?)", db.Model(&models.Summary{}).
+			Select("transcription_id").
+			Where("template_id = ? AND created_at >= ?", tpl.ID, tpl.UpdatedAt))
+	}
+
+	var ids []string
+	if err := query.Order("created_at ASC").Pluck("id", &ids).Error; err != nil {
+		return nil, fmt.Errorf("failed to select transcriptions: %w", err)
+	}
+	return ids, nil
+}
+
+// Resume restarts runs that were interrupted, e.g. by a server restart
+func (r *Runner) Resume() {
+	var ids []string
+	r.conn().Model(&models.SummaryRegeneration{}).
+		Where("status IN ?", []string{models.RegenerationPending, models.RegenerationRunning}).
+		Pluck("id", &ids)
+	for _, id := range ids {
+		logger.Info("Resuming summary regeneration", "regeneration_id", id)
+		r.launch(id)
+	}
+}
+
+// Wait blocks until the run is no longer being processed
+func (r *Runner) Wait(id string) {
+	r.mu.Lock()
+	done, ok := r.running[id]
+	r.mu.Unlock()
+	if ok {
+		<-done
+	}
+}
+
+func (r *Runner) launch(id string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.running[id]; ok {
+		return
+	}
+	done := make(chan struct{})
+	r.running[id] = done
+
+	go func() {
+		defer func() {
+			r.mu.Lock()
+			delete(r.running, id)
+			r.mu.Unlock()
+			close(done)
+		}()
+		r.process(id)
+	}()
+}
+
+// process works through the pending items of a run one at a time
+func (r *Runner) process(id string) {
+	db := r.conn()
+
+	var run models.SummaryRegeneration
+	if err := db.Where("id = ?", id).First(&run).Error; err != nil {
+		logger.Error("Failed to load summary regeneration", "regeneration_id", id, "error", err)
+		return
+	}
+	db.Model(&run).Update("status", models.RegenerationRunning)
+
+	var tpl models.SummaryTemplate
+	tplErr := db.Where("id = ?", run.TemplateID).First(&tpl).Error
+	service, svcErr := r.service()
+
+	var items []models.SummaryRegenerationItem
+	db.Where("regeneration_id = ? AND status = ?", id, models.RegenerationPending).Order("id ASC").Find(&items)
+
+	for _, item := range items {
+		var err error
+		var summaryID string
+		switch {
+		case tplErr != nil:
+			err = fmt.Errorf("template not found: %w", tplErr)
+		case svcErr != nil:
+			err = svcErr
+		default:
+			summaryID, err = r.regenerate(service, &tpl, run.Model, item.TranscriptionID)
+		}
+
+		counter := "succeeded"
+		updates := map[string]interface{}{"status": models.RegenerationCompleted, "summary_id": summaryID}
+		if err != nil {
+			counter = "failed"
+			updates = map[string]interface{}{"status": models.RegenerationFailed, "error": err.Error()}
+			logger.Warn("Summary regeneration failed",
+				"regeneration_id", id, "transcription_id", item.TranscriptionID, "error", err)
+		}
+		db.Model(&item).Updates(updates)
+		db.Model(&run).UpdateColumn(counter, gorm.Expr(counter+" + 1"))
+	}
+
+	db.Where("id = ?", id).First(&run)
+	status := models.RegenerationCompleted
+	if run.Total > 0 && run.Failed == run.Total {
+		status = models.RegenerationFailed
+	}
+	now := time.Now()
+	db.Model(&run).Updates(map[string]interface{}{"status": status, "completed_at": &now})
+	logger.Info("Summary regeneration finished",
+		"regeneration_id", id, "succeeded", run.Succeeded, "failed", run.Failed)
+}
+
+// regenerate summarizes one transcription with the template and stores the
+// result as its latest summary
+func (r *Runner) regenerate(service llm.Service, tpl *models.SummaryTemplate, model, transcriptionID string) (string, error) {
+	db := r.conn()
+
+	var job models.TranscriptionJob
+	if err := db.Where("id = ?", transcriptionID).First(&job).Error; err != nil {
+		return "", fmt.Errorf("transcription not found: %w", err)
+	}
+	if job.Transcript == nil || *job.Transcript == "" {
+		return "", fmt.Errorf("transcription has no transcript")
+	}
+	if job.Encrypted {
+		return "", fmt.Errorf("transcription is encrypted")
+	}
+
+	content := tpl.Prompt + "\n\n" + TranscriptText(db, &job)
+	ctx, cancel := context.WithTimeout(context.Background(), itemTimeout)
+	defer cancel()
+
+	resp, err := service.ChatCompletion(ctx, model, []llm.ChatMessage{{Role: "user", Content: content}}, 0.0)
+	if err != nil {
+		return "", err
+	}
+	if resp == nil || len(resp.Choices) == 0 || resp.Choices[0].Message.Content == "" {
+		return "", fmt.Errorf("model returned an empty summary")
+	}
+	text := resp.Choices[0].Message.Content
+
+	templateID := tpl.ID
+	summary := models.Summary{
+		TranscriptionID: transcriptionID,
+		TemplateID:      &templateID,
+		Model:           model,
+		Content:         text,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&summary).Error; err != nil {
+			return err
+		}
+		// Keep the cached copy on the job in step, as the summarizer does
+		return tx.Model(&models.TranscriptionJob{}).Where("id = ?", transcriptionID).Update("summary", text).Error
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to save summary: %w", err)
+	}
+	return summary.ID, nil
+}
+
+// TranscriptText renders a stored transcript as "Speaker: text" lines using
+// the job's speaker names; transcripts that are not segment JSON are used as is
+func TranscriptText(db *gorm.DB, job *models.TranscriptionJob) string {
+	var result struct {
+		Text     string `json:"text"`
+		Segments []struct {
+			Text    string  `json:"text"`
+			Speaker *string `json:"speaker"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal([]byte(*job.Transcript), &result); err != nil {
+		return *job.Transcript
+	}
+	if len(result.Segments) == 0 {
+		if result.Text != "" {
+			return result.Text
+		}
+		return *job.Transcript
+	}
+
+	names := map[string]string{}
+	var mappings []models.SpeakerMapping
+	db.Where("transcription_job_id = ?", job.ID).Find(&mappings)
+	for _, mapping := range mappings {
+		names[mapping.OriginalSpeaker] = mapping.CustomName
+	}
+
+	var b strings.Builder
+	for _, segment := range result.Segments {
+		text := strings.TrimSpace(segment.Text)
+		if text == "" {
+			continue
+		}
+		if segment.Speaker != nil && *segment.Speaker != "" {
+			speaker := *segment.Speaker
+			if name, ok := names[speaker]; ok {
+				speaker = name
+			}
+			b.WriteString(speaker + ": ")
+		}
+		b.WriteString(text + "\n")
+	}
+	return b.String()
+}
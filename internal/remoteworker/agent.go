@@ -0,0 +1,449 @@
+package remoteworker
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/devices"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/logger"
+)
+
+// TranscribeFunc runs the models on a downloaded task's audio
+type TranscribeFunc func(ctx context.Context, taskID, audioPath string, params models.WhisperXParams, progress func(interfaces.TranscriptionProgress)) (*interfaces.TranscriptResult, error)
+
+// PollTimeout is how long the server holds a request for the next task
+const PollTimeout = 25 * time.Second
+
+// progressInterval is how often a task's progress is sent to the server
+const progressInterval = time.Second
+
+// retryInterval is how long the agent waits after the server could not be reached
+const retryInterval = 5 * time.Second
+
+// errCancelled stops a task the server no longer wants
+var errCancelled = errors.New("task cancelled by the server")
+
+// AgentOptions configure a worker agent
+type AgentOptions struct {
+	// ServerURL is the API server, e.g. https://synthezia.example.com
+	ServerURL string
+	// Token is the server's WORKER_TOKEN
+	Token   string
+	Name    string
+	Version string
+	// Slots is how many tasks run at once; 0 means 1
+	Slots int
+	// TempDir holds the downloaded audio
+	TempDir string
+	// Devices, when set, assigns each task a device
+	Devices    *devices.Manager
+	Transcribe TranscribeFunc
+	Client     *http.Client
+}
+
+// Agent runs on a worker machine, taking tasks from the server and running
+// them until its context is done
+type Agent struct {
+	opts AgentOptions
+
+	registering sync.Mutex
+	mu          sync.Mutex
+	workerID    string
+	heartbeat   time.Duration
+	running     map[string]context.CancelCauseFunc
+}
+
+// NewAgent creates a worker agent
+func NewAgent(opts AgentOptions) *Agent {
+	opts.ServerURL = strings.TrimRight(opts.ServerURL, "/")
+	opts.Slots = max(1, opts.Slots)
+	if opts.TempDir == "" {
+		opts.TempDir = os.TempDir()
+	}
+	if opts.Client == nil {
+		opts.Client = &http.Client{}
+	}
+	return &Agent{opts: opts, running: make(map[string]context.CancelCauseFunc)}
+}
+
+// statusError is an unexpected response from the server
+type statusError struct {
+	status int
+	body   string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("server returned %d: %s", e.status, e.body)
+}
+
+// call sends a JSON request to the server and decodes the JSON response
+// into out, when set. Unknown workers and tasks map to their errors.
+func (a *Agent) call(ctx context.Context, method, path string, in, out any) (int, error) {
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return 0, err
+		}
+		body = bytes.NewReader(data)
+	}
+	req, err := http.NewRequestWithContext(ctx, method, a.opts.ServerURL+"/api/v1/workers"+path, body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.opts.Token)
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	resp, err := a.opts.Client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	switch {
+	case resp.StatusCode == http.StatusNotFound && path != "/register":
+		return resp.StatusCode, ErrUnknownWorker
+	case resp.StatusCode == http.StatusGone:
+		return resp.StatusCode, ErrUnknownTask
+	case resp.StatusCode >= 300:
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return resp.StatusCode, &statusError{status: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	if out != nil && resp.StatusCode != http.StatusNoContent {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return resp.StatusCode, fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+	return resp.StatusCode, nil
+}
+
+func (a *Agent) id() string {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.workerID
+}
+
+func (a *Agent) devices() []devices.Device {
+	if a.opts.Devices == nil {
+		return nil
+	}
+	return a.opts.Devices.Devices()
+}
+
+// register introduces the agent to the server, retrying until it answers.
+// Tasks still running were forgotten by the server and are stopped.
+func (a *Agent) register(ctx context.Context) error {
+	for {
+		var resp RegisterResponse
+		_, err := a.call(ctx, http.MethodPost, "/register", RegisterRequest{
+			Name:    a.opts.Name,
+			Version: a.opts.Version,
+			Slots:   a.opts.Slots,
+			Devices: a.devices(),
+		}, &resp)
+		if err == nil {
+			a.mu.Lock()
+			a.workerID = resp.WorkerID
+			a.heartbeat = time.Duration(resp.HeartbeatSeconds) * time.Second
+			if a.heartbeat <= 0 {
+				a.heartbeat = DefaultHeartbeatInterval
+			}
+			for _, cancel := range a.running {
+				cancel(errCancelled)
+			}
+			a.mu.Unlock()
+			logger.Info("Registered with server", "server", a.opts.ServerURL, "worker_id", resp.WorkerID)
+			return nil
+		}
+		logger.Warn("Failed to register with server", "server", a.opts.ServerURL, "error", err)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(retryInterval):
+		}
+	}
+}
+
+// reregister registers again unless another slot already did since workerID
+// was rejected
+func (a *Agent) reregister(ctx context.Context, workerID string) error {
+	a.registering.Lock()
+	defer a.registering.Unlock()
+	if a.id() != workerID {
+		return nil
+	}
+	return a.register(ctx)
+}
+
+// Run registers the agent and runs tasks until ctx is done
+func (a *Agent) Run(ctx context.Context) error {
+	if err := a.register(ctx); err != nil {
+		return err
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		a.heartbeats(ctx)
+	}()
+	for range a.opts.Slots {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			a.poll(ctx)
+		}()
+	}
+	wg.Wait()
+	return ctx.Err()
+}
+
+// heartbeats keeps the agent registered and stops the tasks the server
+// no longer wants
+func (a *Agent) heartbeats(ctx context.Context) {
+	for {
+		a.mu.Lock()
+		interval := a.heartbeat
+		a.mu.Unlock()
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		workerID := a.id()
+		a.mu.Lock()
+		req := HeartbeatRequest{Running: make([]string, 0, len(a.running))}
+		for id := range a.running {
+			req.Running = append(req.Running, id)
+		}
+		a.mu.Unlock()
+		req.Devices = a.devices()
+
+		var resp HeartbeatResponse
+		_, err := a.call(ctx, http.MethodPost, "/"+workerID+"/heartbeat", req, &resp)
+		switch {
+		case errors.Is(err, ErrUnknownWorker):
+			if err := a.reregister(ctx, workerID); err != nil {
+				return
+			}
+		case err != nil:
+			if ctx.Err() == nil {
+				logger.Warn("Failed to send heartbeat", "error", err)
+			}
+		default:
+			a.cancel(resp.Cancel...)
+		}
+	}
+}
+
+// cancel stops running tasks
+func (a *Agent) cancel(taskIDs ...string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	for _, id := range taskIDs {
+		if cancel, ok := a.running[id]; ok {
+			logger.Info("Server cancelled task", "task_id", id)
+			cancel(errCancelled)
+		}
+	}
+}
+
+// poll takes tasks from the server one at a time and runs them
+func (a *Agent) poll(ctx context.Context) {
+	for ctx.Err() == nil {
+		workerID := a.id()
+		pollCtx, cancel := context.WithTimeout(ctx, PollTimeout+10*time.Second)
+		var task Task
+		status, err := a.call(pollCtx, http.MethodGet, "/"+workerID+"/tasks/next", nil, &task)
+		cancel()
+		switch {
+		case ctx.Err() != nil:
+			return
+		case errors.Is(err, ErrUnknownWorker):
+			if err := a.reregister(ctx, workerID); err != nil {
+				return
+			}
+		case err != nil:
+			logger.Warn("Failed to fetch task", "error", err)
+			select {
+			case <-ctx.Done():
+			case <-time.After(retryInterval):
+			}
+		case status == http.StatusOK:
+			a.runTask(ctx, workerID, task)
+		}
+	}
+}
+
+// runTask downloads a task's audio, transcribes it and reports the outcome
+func (a *Agent) runTask(ctx context.Context, workerID string, task Task) {
+	taskCtx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+	a.mu.Lock()
+	a.running[task.ID] = cancel
+	a.mu.Unlock()
+	defer func() {
+		a.mu.Lock()
+		delete(a.running, task.ID)
+		a.mu.Unlock()
+	}()
+
+	logger.Info("Running task", "task_id", task.ID, "job_id", task.JobID)
+	result, err := a.transcribe(taskCtx, workerID, task)
+	switch {
+	case ctx.Err() != nil:
+		return
+	case errors.Is(context.Cause(taskCtx), errCancelled):
+		logger.Info("Stopped cancelled task", "task_id", task.ID)
+		return
+	case err != nil:
+		logger.Warn("Task failed", "task_id", task.ID, "error", err)
+		_, err = a.call(ctx, http.MethodPost, "/"+workerID+"/tasks/"+task.ID+"/fail", FailRequest{Error: err.Error()}, nil)
+	default:
+		logger.Info("Task completed", "task_id", task.ID, "segments", len(result.Segments))
+		_, err = a.call(ctx, http.MethodPost, "/"+workerID+"/tasks/"+task.ID+"/result", result, nil)
+	}
+	if err != nil && ctx.Err() == nil {
+		logger.Warn("Failed to report task outcome", "task_id", task.ID, "error", err)
+	}
+}
+
+func (a *Agent) transcribe(ctx context.Context, workerID string, task Task) (*interfaces.TranscriptResult, error) {
+	audioPath, err := a.download(ctx, workerID, task)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download audio: %w", err)
+	}
+	defer os.Remove(audioPath)
+
+	params := task.Parameters
+	if a.opts.Devices != nil {
+		lease, err := a.opts.Devices.Acquire(ctx, devices.RequestFor(task.JobID, params))
+		if err != nil {
+			return nil, fmt.Errorf("failed to assign device: %w", err)
+		}
+		defer lease.Release()
+		lease.Apply(&params)
+	}
+
+	reporter := newProgressReporter(a, workerID, task.ID)
+	go reporter.run(ctx)
+	result, err := a.opts.Transcribe(ctx, task.ID, audioPath, params, reporter.add)
+	reporter.stop()
+	return result, err
+}
+
+// download saves a task's audio to the temp directory
+func (a *Agent) download(ctx context.Context, workerID string, task Task) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, a.opts.ServerURL+"/api/v1/workers/"+workerID+"/tasks/"+task.ID+"/audio", nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Authorization", "Bearer "+a.opts.Token)
+	resp, err := a.opts.Client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+
+	if err := os.MkdirAll(a.opts.TempDir, 0755); err != nil {
+		return "", err
+	}
+	path := filepath.Join(a.opts.TempDir, "task-"+task.ID+filepath.Ext(task.AudioName))
+	file, err := os.Create(path)
+	if err != nil {
+		return "", err
+	}
+	if _, err := io.Copy(file, resp.Body); err != nil {
+		file.Close()
+		os.Remove(path)
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		os.Remove(path)
+		return "", err
+	}
+	return path, nil
+}
+
+// progressReporter batches a task's progress and sends it to the server
+// every progressInterval, stopping the task when the server no longer
+// knows it
+type progressReporter struct {
+	agent    *Agent
+	workerID string
+	taskID   string
+
+	mu      sync.Mutex
+	pending []interfaces.TranscriptionProgress
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+func newProgressReporter(a *Agent, workerID, taskID string) *progressReporter {
+	return &progressReporter{agent: a, workerID: workerID, taskID: taskID, done: make(chan struct{}), stopped: make(chan struct{})}
+}
+
+func (r *progressReporter) add(progress interfaces.TranscriptionProgress) {
+	r.mu.Lock()
+	r.pending = append(r.pending, progress)
+	r.mu.Unlock()
+}
+
+func (r *progressReporter) run(ctx context.Context) {
+	defer close(r.stopped)
+	ticker := time.NewTicker(progressInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.done:
+			r.flush(ctx)
+			return
+		case <-ticker.C:
+			r.flush(ctx)
+		}
+	}
+}
+
+// stop sends the progress not yet sent and waits for it to go
+func (r *progressReporter) stop() {
+	close(r.done)
+	<-r.stopped
+}
+
+func (r *progressReporter) flush(ctx context.Context) {
+	r.mu.Lock()
+	updates := r.pending
+	r.pending = nil
+	r.mu.Unlock()
+	if len(updates) == 0 {
+		return
+	}
+
+	_, err := r.agent.call(ctx, http.MethodPost, "/"+r.workerID+"/tasks/"+r.taskID+"/progress", ProgressRequest{Updates: updates}, nil)
+	switch {
+	case errors.Is(err, ErrUnknownTask), errors.Is(err, ErrUnknownWorker):
+		r.agent.cancel(r.taskID)
+	case err != nil && ctx.Err() == nil:
+		logger.Warn("Failed to report progress", "task_id", r.taskID, "error", err)
+	}
+}
@@ -0,0 +1,365 @@
+package remoteworker
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"slices"
+	"sync"
+	"time"
+
+	"synthezia/internal/devices"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/logger"
+
+	"github.com/google/uuid"
+)
+
+var (
+	// ErrUnknownWorker means the worker never registered or was expired; it
+	// must register again
+	ErrUnknownWorker = errors.New("unknown worker")
+	// ErrUnknownTask means the task is not running on the worker, because it
+	// finished, its job was cancelled or it was handed to another worker
+	ErrUnknownTask = errors.New("unknown task")
+)
+
+// DefaultHeartbeatInterval is how often workers heartbeat unless configured
+const DefaultHeartbeatInterval = 10 * time.Second
+
+// missedHeartbeats is how many heartbeats a worker may miss before it is
+// expired and its tasks handed to other workers
+const missedHeartbeats = 3
+
+// outcome is how a task ended
+type outcome struct {
+	result *interfaces.TranscriptResult
+	err    error
+}
+
+// task is a Task waiting for a worker or running on one
+type task struct {
+	Task
+	audioPath string
+	progress  func(interfaces.TranscriptionProgress)
+	workerID  string // empty while waiting
+	done      chan outcome
+}
+
+// worker is a registered Worker and when it was last heard from
+type worker struct {
+	Worker
+	tasks map[string]*task
+}
+
+// Dispatcher hands transcriptions to remote workers. It implements the
+// transcription service's Executor, so the queue runs jobs as usual and
+// only the models run remotely.
+type Dispatcher struct {
+	heartbeat time.Duration
+	now       func() time.Time
+
+	mu      sync.Mutex
+	workers map[string]*worker
+	tasks   map[string]*task
+	waiting []*task
+	wake    chan struct{} // closed when a task is queued
+}
+
+// NewDispatcher creates a dispatcher expecting workers to heartbeat at the
+// given interval; 0 uses DefaultHeartbeatInterval
+func NewDispatcher(heartbeat time.Duration) *Dispatcher {
+	if heartbeat <= 0 {
+		heartbeat = DefaultHeartbeatInterval
+	}
+	return &Dispatcher{
+		heartbeat: heartbeat,
+		now:       time.Now,
+		workers:   make(map[string]*worker),
+		tasks:     make(map[string]*task),
+		wake:      make(chan struct{}),
+	}
+}
+
+// SetClock overrides the dispatcher's clock, mainly for tests
+func (d *Dispatcher) SetClock(now func() time.Time) {
+	d.now = now
+}
+
+// notify wakes the workers waiting for a task; call with mu held
+func (d *Dispatcher) notify() {
+	close(d.wake)
+	d.wake = make(chan struct{})
+}
+
+// Transcribe queues the audio for the next free worker and waits for the
+// transcript, passing the worker's progress on to procCtx. Cancelling ctx
+// withdraws the task; the worker is told to stop on its next report.
+func (d *Dispatcher) Transcribe(ctx context.Context, audioPath string, params models.WhisperXParams, procCtx interfaces.ProcessingContext) (*interfaces.TranscriptResult, error) {
+	t := &task{
+		Task: Task{
+			ID:         uuid.New().String(),
+			JobID:      procCtx.JobID,
+			Parameters: params,
+			AudioName:  filepath.Base(audioPath),
+		},
+		audioPath: audioPath,
+		progress:  procCtx.Progress,
+		done:      make(chan outcome, 1),
+	}
+
+	d.mu.Lock()
+	d.tasks[t.ID] = t
+	d.waiting = append(d.waiting, t)
+	d.notify()
+	d.mu.Unlock()
+	logger.Debug("Queued task for remote workers", "task_id", t.ID, "job_id", t.JobID)
+
+	select {
+	case o := <-t.done:
+		return o.result, o.err
+	case <-ctx.Done():
+		d.mu.Lock()
+		d.remove(t)
+		d.mu.Unlock()
+		return nil, ctx.Err()
+	}
+}
+
+// remove forgets a task; call with mu held
+func (d *Dispatcher) remove(t *task) {
+	delete(d.tasks, t.ID)
+	d.waiting = slices.DeleteFunc(d.waiting, func(w *task) bool { return w == t })
+	if w, ok := d.workers[t.workerID]; ok {
+		delete(w.tasks, t.ID)
+	}
+}
+
+// Register adds a worker and returns its ID and heartbeat interval
+func (d *Dispatcher) Register(req RegisterRequest, address string) RegisterResponse {
+	now := d.now()
+	w := &worker{
+		Worker: Worker{
+			ID:           uuid.New().String(),
+			Name:         req.Name,
+			Version:      req.Version,
+			Address:      address,
+			Slots:        max(1, req.Slots),
+			Devices:      req.Devices,
+			RegisteredAt: now,
+			LastSeen:     now,
+		},
+		tasks: make(map[string]*task),
+	}
+
+	d.mu.Lock()
+	d.workers[w.ID] = w
+	d.mu.Unlock()
+	logger.Info("Remote worker registered", "worker_id", w.ID, "name", w.Name, "address", address, "slots", w.Slots)
+
+	return RegisterResponse{WorkerID: w.ID, HeartbeatSeconds: int(d.heartbeat / time.Second)}
+}
+
+// seen looks up a worker and records that it was heard from; call with mu held
+func (d *Dispatcher) seen(workerID string) (*worker, error) {
+	w, ok := d.workers[workerID]
+	if !ok {
+		return nil, ErrUnknownWorker
+	}
+	w.LastSeen = d.now()
+	return w, nil
+}
+
+// Heartbeat keeps a worker registered and returns the tasks it is running
+// that it must stop
+func (d *Dispatcher) Heartbeat(workerID string, req HeartbeatRequest) (HeartbeatResponse, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	w, err := d.seen(workerID)
+	if err != nil {
+		return HeartbeatResponse{}, err
+	}
+	if req.Devices != nil {
+		w.Devices = req.Devices
+	}
+	resp := HeartbeatResponse{Cancel: []string{}}
+	for _, id := range req.Running {
+		if _, ok := w.tasks[id]; !ok {
+			resp.Cancel = append(resp.Cancel, id)
+		}
+	}
+	return resp, nil
+}
+
+// Next hands a worker the oldest waiting task, waiting for one until ctx is
+// done. It returns nil when no task came, or the worker has no free slot.
+func (d *Dispatcher) Next(ctx context.Context, workerID string) (*Task, error) {
+	for {
+		d.mu.Lock()
+		w, err := d.seen(workerID)
+		if err != nil {
+			d.mu.Unlock()
+			return nil, err
+		}
+		if len(d.waiting) > 0 && len(w.tasks) < w.Slots {
+			t := d.waiting[0]
+			d.waiting = d.waiting[1:]
+			t.workerID = w.ID
+			w.tasks[t.ID] = t
+			d.mu.Unlock()
+			logger.Info("Task assigned to remote worker", "task_id", t.ID, "job_id", t.JobID, "worker", w.Name)
+			assigned := t.Task
+			return &assigned, nil
+		}
+		wake := d.wake
+		d.mu.Unlock()
+
+		select {
+		case <-wake:
+		case <-ctx.Done():
+			return nil, nil
+		}
+	}
+}
+
+// running looks up a task running on a worker; call with mu held
+func (d *Dispatcher) running(workerID, taskID string) (*task, error) {
+	w, err := d.seen(workerID)
+	if err != nil {
+		return nil, err
+	}
+	t, ok := w.tasks[taskID]
+	if !ok {
+		return nil, ErrUnknownTask
+	}
+	return t, nil
+}
+
+// AudioPath returns the audio of a task running on a worker
+func (d *Dispatcher) AudioPath(workerID, taskID string) (string, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, err := d.running(workerID, taskID)
+	if err != nil {
+		return "", err
+	}
+	return t.audioPath, nil
+}
+
+// Progress passes the progress a worker reports on to the task's job
+func (d *Dispatcher) Progress(workerID, taskID string, updates []interfaces.TranscriptionProgress) error {
+	d.mu.Lock()
+	t, err := d.running(workerID, taskID)
+	d.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if t.progress != nil {
+		for _, update := range updates {
+			t.progress(update)
+		}
+	}
+	return nil
+}
+
+// Complete hands a worker's transcript to the job waiting for it
+func (d *Dispatcher) Complete(workerID, taskID string, result *interfaces.TranscriptResult) error {
+	return d.finish(workerID, taskID, outcome{result: result})
+}
+
+// Fail fails the job waiting for a task with the worker's error
+func (d *Dispatcher) Fail(workerID, taskID, message string) error {
+	d.mu.Lock()
+	name := ""
+	if w, ok := d.workers[workerID]; ok {
+		name = w.Name
+	}
+	d.mu.Unlock()
+	return d.finish(workerID, taskID, outcome{err: fmt.Errorf("remote worker %s: %s", name, message)})
+}
+
+func (d *Dispatcher) finish(workerID, taskID string, o outcome) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	t, err := d.running(workerID, taskID)
+	if err != nil {
+		return err
+	}
+	d.remove(t)
+	t.done <- o
+	return nil
+}
+
+// Expire forgets the workers that missed their heartbeats and queues their
+// tasks again, ahead of the tasks that have not started
+func (d *Dispatcher) Expire() {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	deadline := d.now().Add(-missedHeartbeats * d.heartbeat)
+	var requeued []*task
+	for id, w := range d.workers {
+		if !w.LastSeen.Before(deadline) {
+			continue
+		}
+		logger.Warn("Remote worker stopped responding", "worker_id", id, "name", w.Name, "tasks", len(w.tasks))
+		for _, t := range w.tasks {
+			t.workerID = ""
+			requeued = append(requeued, t)
+		}
+		delete(d.workers, id)
+	}
+	if len(requeued) > 0 {
+		d.waiting = append(requeued, d.waiting...)
+		d.notify()
+	}
+}
+
+// Run expires silent workers every interval until stop is closed
+func (d *Dispatcher) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			d.Expire()
+		}
+	}
+}
+
+// Workers lists the registered workers, oldest first
+func (d *Dispatcher) Workers() []Worker {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	list := make([]Worker, 0, len(d.workers))
+	for _, w := range d.workers {
+		info := w.Worker
+		info.Devices = slices.Clone(w.Devices)
+		if info.Devices == nil {
+			info.Devices = []devices.Device{}
+		}
+		info.Tasks = make([]string, 0, len(w.tasks))
+		for id := range w.tasks {
+			info.Tasks = append(info.Tasks, id)
+		}
+		slices.Sort(info.Tasks)
+		list = append(list, info)
+	}
+	slices.SortFunc(list, func(a, b Worker) int { return a.RegisteredAt.Compare(b.RegisteredAt) })
+	return list
+}
+
+// Waiting is how many tasks are waiting for a worker
+func (d *Dispatcher) Waiting() int {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return len(d.waiting)
+}
@@ -0,0 +1,77 @@
+// Package remoteworker runs WhisperX on worker machines rather than on the
+// API server. A worker registers with the server, heartbeats, long-polls for
+// tasks, downloads each task's audio, streams progress back while the models
+// run and uploads the transcript, all over plain HTTP with a shared token, so
+// transcription can run on a GPU box while the server stays on a small VM.
+//
+// The server side is the Dispatcher, which the transcription service uses as
+// its executor; the worker side is the Agent.
+package remoteworker
+
+import (
+	"time"
+
+	"synthezia/internal/devices"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription/interfaces"
+)
+
+// RegisterRequest introduces a worker to the server
+type RegisterRequest struct {
+	Name    string `json:"name"`
+	Version string `json:"version,omitempty"`
+	// Slots is how many tasks the worker runs at once
+	Slots   int              `json:"slots"`
+	Devices []devices.Device `json:"devices,omitempty"`
+}
+
+// RegisterResponse names the worker and how often it must heartbeat
+type RegisterResponse struct {
+	WorkerID         string `json:"worker_id"`
+	HeartbeatSeconds int    `json:"heartbeat_seconds"`
+}
+
+// HeartbeatRequest lists the tasks a worker is running and how busy its
+// devices are
+type HeartbeatRequest struct {
+	Running []string         `json:"running"`
+	Devices []devices.Device `json:"devices,omitempty"`
+}
+
+// HeartbeatResponse lists the running tasks the worker must stop, because
+// their jobs were cancelled or handed to another worker
+type HeartbeatResponse struct {
+	Cancel []string `json:"cancel"`
+}
+
+// Task is one transcription handed to a worker
+type Task struct {
+	ID         string                `json:"id"`
+	JobID      string                `json:"job_id"`
+	Parameters models.WhisperXParams `json:"parameters"`
+	// AudioName is the file name of the audio, for its extension
+	AudioName string `json:"audio_name"`
+}
+
+// ProgressRequest carries the progress a task made since the last report
+type ProgressRequest struct {
+	Updates []interfaces.TranscriptionProgress `json:"updates"`
+}
+
+// FailRequest reports why a task failed
+type FailRequest struct {
+	Error string `json:"error"`
+}
+
+// Worker describes a registered worker and the tasks it runs
+type Worker struct {
+	ID           string           `json:"id"`
+	Name         string           `json:"name"`
+	Version      string           `json:"version,omitempty"`
+	Address      string           `json:"address"`
+	Slots        int              `json:"slots"`
+	Devices      []devices.Device `json:"devices"`
+	Tasks        []string         `json:"tasks"`
+	RegisteredAt time.Time        `json:"registered_at"`
+	LastSeen     time.Time        `json:"last_seen"`
+}
@@ -0,0 +1,612 @@
+// Package retention removes what completed jobs no longer need once they are
+// old enough: their audio is deleted or moved to an archive directory, and
+// optionally their transcripts are cleared, according to policies set per
+// user or per tag, so disk usage stays bounded.
+package retention
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/search"
+	"synthezia/internal/storage"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// What a policy does with a job's audio
+const (
+	AudioDelete  = "delete"
+	AudioArchive = "archive" // Move it under the archive directory, where it can still be played
+	AudioKeep    = "keep"    // Only useful with transcripts=true
+)
+
+// Job events recorded when a policy is applied
+const (
+	EventAudioDeleted       = "retention_audio_deleted"
+	EventAudioArchived      = "retention_audio_archived"
+	EventTranscriptsCleared = "retention_transcripts_cleared"
+)
+
+// Policy is how long the jobs of a scope are kept once completed, and what
+// is removed after that
+type Policy struct {
+	User        string `json:"user,omitempty"` // Empty with Tag empty: every job
+	Tag         string `json:"tag,omitempty"`
+	Days        int    `json:"days"`
+	Audio       string `json:"audio"`
+	Transcripts bool   `json:"transcripts"` // Also clear the transcript, summary, revisions and translations
+}
+
+// String writes the policy back in RETENTION_POLICIES form
+func (p Policy) String() string {
+	scope := "*"
+	switch {
+	case p.Tag != "":
+		scope = "tag=" + p.Tag
+	case p.User != "":
+		scope = "user=" + p.User
+	}
+	spec := fmt.Sprintf("%s;days=%d;audio=%s", scope, p.Days, p.Audio)
+	if p.Transcripts {
+		spec += ";transcripts=true"
+	}
+	return spec
+}
+
+// ParsePolicies parses RETENTION_POLICIES: policies separated by commas,
+// each a scope ("*", "user=alice" or "tag=legal") followed by ";key=value"
+// options, e.g. "*;days=90,tag=legal;days=365;audio=archive". days is
+// required; audio is delete (the default), archive or keep, and
+// transcripts=true also clears the transcripts.
+func ParsePolicies(spec string) ([]Policy, error) {
+	var policies []Policy
+	seen := make(map[string]bool)
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		parts := strings.Split(entry, ";")
+		policy := Policy{Audio: AudioDelete}
+		scope := strings.TrimSpace(parts[0])
+		if scope != "*" {
+			key, value, ok := strings.Cut(scope, "=")
+			key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+			switch {
+			case ok && key == "user" && value != "":
+				policy.User = value
+			case ok && key == "tag" && value != "":
+				policy.Tag = value
+			default:
+				return nil, fmt.Errorf("invalid retention scope %q: expected *, user=<name> or tag=<tag>", scope)
+			}
+		}
+		scopeKey := strings.ToLower(policy.User + "\x00" + policy.Tag)
+		if seen[scopeKey] {
+			return nil, fmt.Errorf("retention scope %q is listed twice", scope)
+		}
+		seen[scopeKey] = true
+
+		if err := parsePolicyOptions(&policy, scope, parts[1:]); err != nil {
+			return nil, err
+		}
+		policies = append(policies, policy)
+	}
+	return policies, nil
+}
+
+// parsePolicyOptions applies "key=value" options to policy
+func parsePolicyOptions(policy *Policy, scope string, options []string) error {
+	hasDays := false
+	for _, option := range options {
+		key, value, ok := strings.Cut(option, "=")
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+		if !ok || value == "" {
+			return fmt.Errorf("invalid retention option %q for %s: expected key=value", option, scope)
+		}
+		switch key {
+		case "days":
+			days, err := strconv.Atoi(value)
+			if err != nil || days < 1 {
+				return fmt.Errorf("invalid days for %s: %q is not a positive number", scope, value)
+			}
+			policy.Days = days
+			hasDays = true
+		case "audio":
+			switch value {
+			case AudioDelete, AudioArchive, AudioKeep:
+				policy.Audio = value
+			default:
+				return fmt.Errorf("invalid audio for %s: %q is not delete, archive or keep", scope, value)
+			}
+		case "transcripts":
+			transcripts, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("invalid transcripts for %s: %q is not true or false", scope, value)
+			}
+			policy.Transcripts = transcripts
+		default:
+			return fmt.Errorf("unknown retention option %q for %s", key, scope)
+		}
+	}
+	if !hasDays {
+		return fmt.Errorf("retention policy for %s has no days", scope)
+	}
+	if policy.Audio == AudioKeep && !policy.Transcripts {
+		return fmt.Errorf("retention policy for %s removes nothing", scope)
+	}
+	return nil
+}
+
+// Action is what a sweep did, or would do, to one job
+type Action struct {
+	JobID       string    `json:"job_id"`
+	Title       string    `json:"title,omitempty"`
+	Username    string    `json:"username,omitempty"`
+	Policy      string    `json:"policy"`
+	CompletedAt time.Time `json:"completed_at"`
+	Audio       string    `json:"audio"`
+	Transcripts bool      `json:"transcripts"`
+	// Bytes is the audio deleted or moved to the archive
+	Bytes int64  `json:"bytes"`
+	Error string `json:"error,omitempty"`
+}
+
+// Report lists the jobs a sweep applied a policy to
+type Report struct {
+	DryRun   bool      `json:"dry_run"`
+	Policies []Policy  `json:"policies"`
+	RanAt    time.Time `json:"ran_at"`
+	Jobs     []Action  `json:"jobs"`
+	// BytesFreed is the audio deleted or moved to the archive
+	BytesFreed int64 `json:"bytes_freed"`
+}
+
+// Service applies the retention policies to completed jobs
+type Service struct {
+	db         *gorm.DB
+	fs         fsys.FS
+	clock      clock.Clock
+	policies   []Policy
+	archiveDir string
+	dryRun     bool
+}
+
+// NewService creates a service without policies, which removes nothing; a
+// nil db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:         db,
+		fs:         fsys.OS,
+		clock:      clock.Real,
+		archiveDir: filepath.Join("data", "archive"),
+	}
+}
+
+// Default is the process-wide service
+var Default = NewService(nil)
+
+// SetFS overrides the filesystem, mainly for tests
+func (s *Service) SetFS(fs fsys.FS) {
+	s.fs = fs
+}
+
+// SetClock overrides the time source, mainly for tests
+func (s *Service) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetPolicies replaces the policies
+func (s *Service) SetPolicies(policies []Policy) {
+	s.policies = policies
+}
+
+// Policies returns the policies in effect
+func (s *Service) Policies() []Policy {
+	return s.policies
+}
+
+// SetArchiveDir sets where archived audio is moved, in one folder per job
+func (s *Service) SetArchiveDir(dir string) {
+	s.archiveDir = dir
+}
+
+// SetDryRun makes Run only report what it would do
+func (s *Service) SetDryRun(dryRun bool) {
+	s.dryRun = dryRun
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// policyFor returns the policy of a job: the longest of its tags' policies,
+// else its owner's, else the one for every job. Tags and user names match
+// without regard to case.
+func (s *Service) policyFor(username string, tags []string) *Policy {
+	var best, user, all *Policy
+	for i := range s.policies {
+		policy := &s.policies[i]
+		switch {
+		case policy.Tag != "":
+			for _, tag := range tags {
+				if strings.EqualFold(tag, policy.Tag) && (best == nil || policy.Days > best.Days) {
+					best = policy
+				}
+			}
+		case policy.User != "":
+			if username != "" && strings.EqualFold(username, policy.User) {
+				user = policy
+			}
+		default:
+			all = policy
+		}
+	}
+	if best != nil {
+		return best
+	}
+	if user != nil {
+		return user
+	}
+	return all
+}
+
+// Sweep applies the policies to the completed jobs that have outlived them;
+// with dryRun it only reports what it would do. Each job is handled once,
+// and encrypted jobs still being sealed wait for the next sweep.
+func (s *Service) Sweep(ctx context.Context, dryRun bool) (*Report, error) {
+	now := s.clock.Now()
+	report := &Report{DryRun: dryRun, Policies: s.policies, RanAt: now, Jobs: []Action{}}
+	if len(s.policies) == 0 {
+		return report, nil
+	}
+
+	var users []models.User
+	if err := s.conn().Select("id", "username").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	usernames := make(map[uint]string, len(users))
+	for _, user := range users {
+		usernames[user.ID] = user.Username
+	}
+
+	var jobs []models.TranscriptionJob
+	err := s.conn().Preload("MultiTrackFiles").
+		Where("status = ? AND retention_applied_at IS NULL AND id NOT LIKE 'track_%'", models.StatusCompleted).
+		Where("encrypted = ? OR encrypted_at IS NOT NULL", false).
+		Order("created_at ASC").
+		Find(&jobs).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range jobs {
+		job := &jobs[i]
+		username := ""
+		if job.UserID != nil {
+			username = usernames[*job.UserID]
+		}
+		var tags []string
+		if job.Tags != nil {
+			json.Unmarshal([]byte(*job.Tags), &tags)
+		}
+		policy := s.policyFor(username, tags)
+		if policy == nil {
+			continue
+		}
+		completedAt, err := s.completedAt(job)
+		if err != nil {
+			return nil, err
+		}
+		if now.Sub(completedAt) < time.Duration(policy.Days)*24*time.Hour {
+			continue
+		}
+
+		action := Action{
+			JobID:       job.ID,
+			Username:    username,
+			Policy:      policy.String(),
+			CompletedAt: completedAt,
+			Audio:       policy.Audio,
+			Transcripts: policy.Transcripts,
+		}
+		if job.Title != nil {
+			action.Title = *job.Title
+		}
+		if dryRun {
+			if policy.Audio != AudioKeep {
+				action.Bytes = s.size(sources(job))
+			}
+			if policy.Audio == AudioDelete && job.OffloadedAt != nil {
+				stored, err := storage.Default.StoredBytes(job.ID, storage.MediaKinds...)
+				if err != nil {
+					return nil, err
+				}
+				action.Bytes += stored
+			}
+		} else if err := s.apply(ctx, job, policy, &action); err != nil {
+			action.Error = err.Error()
+			logger.Warn("Failed to apply retention policy", "job_id", job.ID, "policy", action.Policy, "error", err)
+		}
+		report.BytesFreed += action.Bytes
+		report.Jobs = append(report.Jobs, action)
+	}
+	return report, nil
+}
+
+// completedAt is when the job last finished transcribing, or when it was
+// last updated for jobs completed without an execution record
+func (s *Service) completedAt(job *models.TranscriptionJob) (time.Time, error) {
+	var executions []models.TranscriptionJobExecution
+	err := s.conn().Select("completed_at").
+		Where("transcription_job_id = ? AND completed_at IS NOT NULL", job.ID).
+		Order("completed_at DESC").Limit(1).
+		Find(&executions).Error
+	if err != nil {
+		return time.Time{}, err
+	}
+	if len(executions) > 0 && executions[0].CompletedAt != nil {
+		return *executions[0].CompletedAt, nil
+	}
+	return job.UpdatedAt, nil
+}
+
+// sources is every copy of a job's audio: the upload or project file, a
+// multi-track job's tracks and their mixdown, and the playback proxy
+func sources(job *models.TranscriptionJob) []string {
+	paths := []string{job.AudioPath}
+	if job.AupFilePath != nil {
+		paths = append(paths, *job.AupFilePath)
+	}
+	for _, track := range job.MultiTrackFiles {
+		paths = append(paths, track.FilePath)
+	}
+	if job.MergedAudioPath != nil {
+		paths = append(paths, *job.MergedAudioPath)
+	}
+	if job.ProxyAudioPath != nil {
+		paths = append(paths, *job.ProxyAudioPath)
+	}
+	return paths
+}
+
+// size adds up the files that exist, counting each path once
+func (s *Service) size(paths []string) int64 {
+	var total int64
+	counted := make(map[string]bool)
+	for _, path := range paths {
+		if path == "" || counted[path] {
+			continue
+		}
+		counted[path] = true
+		if info, err := s.fs.Stat(path); err == nil && !info.IsDir() {
+			total += info.Size()
+		}
+	}
+	return total
+}
+
+// apply carries out a policy on one job and records it in action
+func (s *Service) apply(ctx context.Context, job *models.TranscriptionJob, policy *Policy, action *Action) error {
+	ctx = logger.WithJobID(ctx, job.ID)
+	now := s.clock.Now()
+	updates := map[string]interface{}{"retention_applied_at": now}
+
+	switch policy.Audio {
+	case AudioDelete:
+		// Media moved to the storage backend is deleted there too
+		freed, err := storage.Default.Purge(ctx, job.ID, storage.MediaKinds...)
+		if err != nil {
+			return fmt.Errorf("failed to delete stored audio: %w", err)
+		}
+		action.Bytes = freed + s.remove(sources(job))
+		updates["source_audio_removed_at"] = now
+		updates["proxy_audio_path"] = nil
+	case AudioArchive:
+		moved, bytes, err := s.archive(job)
+		action.Bytes = bytes
+		if err != nil {
+			return err
+		}
+		if err := s.conn().Transaction(func(tx *gorm.DB) error {
+			for _, track := range job.MultiTrackFiles {
+				if dest, ok := moved[track.FilePath]; ok {
+					if err := tx.Model(&models.MultiTrackFile{}).Where("id = ?", track.ID).Update("file_path", dest).Error; err != nil {
+						return err
+					}
+				}
+			}
+			return nil
+		}); err != nil {
+			return err
+		}
+		if dest, ok := moved[job.AudioPath]; ok {
+			updates["audio_path"] = dest
+		}
+		if job.AupFilePath != nil {
+			if dest, ok := moved[*job.AupFilePath]; ok {
+				updates["aup_file_path"] = dest
+			}
+		}
+		if job.MergedAudioPath != nil {
+			if dest, ok := moved[*job.MergedAudioPath]; ok {
+				updates["merged_audio_path"] = dest
+			}
+		}
+		if job.ProxyAudioPath != nil {
+			if dest, ok := moved[*job.ProxyAudioPath]; ok {
+				updates["proxy_audio_path"] = dest
+			}
+		}
+	}
+
+	if policy.Transcripts {
+		if _, err := storage.Default.Purge(ctx, job.ID, models.StoredTranscript); err != nil {
+			return fmt.Errorf("failed to delete stored transcript: %w", err)
+		}
+		if err := s.clearTranscripts(job.ID); err != nil {
+			return err
+		}
+		updates["transcript"] = nil
+		updates["summary"] = nil
+		updates["individual_transcripts"] = nil
+	}
+
+	if err := s.conn().Model(&models.TranscriptionJob{}).Where("id = ?", job.ID).Updates(updates).Error; err != nil {
+		return err
+	}
+
+	switch policy.Audio {
+	case AudioDelete:
+		logger.JobEvent(ctx, job.ID, EventAudioDeleted, "policy", action.Policy, "bytes_freed", action.Bytes)
+	case AudioArchive:
+		logger.JobEvent(ctx, job.ID, EventAudioArchived, "policy", action.Policy, "archive_dir", s.jobArchiveDir(job.ID), "bytes_moved", action.Bytes)
+	}
+	if policy.Transcripts {
+		if err := search.Default.Remove(job.ID); err != nil {
+			logger.Warn("Failed to remove transcript from search index", "job_id", job.ID, "error", err)
+		}
+		logger.JobEvent(ctx, job.ID, EventTranscriptsCleared, "policy", action.Policy)
+	}
+	return nil
+}
+
+// remove deletes the files that exist and returns how many bytes that freed
+func (s *Service) remove(paths []string) int64 {
+	freed := s.size(paths)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		if err := s.fs.Remove(path); err != nil && fsys.Exists(s.fs, path) {
+			logger.Warn("Failed to remove audio", "path", path, "error", err)
+			if info, err := s.fs.Stat(path); err == nil {
+				freed -= info.Size()
+			}
+		}
+	}
+	return freed
+}
+
+func (s *Service) jobArchiveDir(jobID string) string {
+	return filepath.Join(s.archiveDir, jobID)
+}
+
+// archive moves the job's audio into its archive folder and returns where
+// each file went and the bytes moved. Files with the same name, such as
+// tracks from different folders, are numbered.
+func (s *Service) archive(job *models.TranscriptionJob) (map[string]string, int64, error) {
+	dir := s.jobArchiveDir(job.ID)
+	if err := s.fs.MkdirAll(dir, 0755); err != nil {
+		return nil, 0, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	moved := make(map[string]string)
+	var bytes int64
+	for _, path := range sources(job) {
+		if path == "" {
+			continue
+		}
+		if _, done := moved[path]; done {
+			continue
+		}
+		info, err := s.fs.Stat(path)
+		if err != nil || info.IsDir() {
+			continue
+		}
+		dest := filepath.Join(dir, filepath.Base(path))
+		for n := 1; fsys.Exists(s.fs, dest); n++ {
+			ext := filepath.Ext(path)
+			dest = filepath.Join(dir, fmt.Sprintf("%s-%d%s", strings.TrimSuffix(filepath.Base(path), ext), n, ext))
+		}
+		if err := s.moveFile(path, dest); err != nil {
+			return moved, bytes, fmt.Errorf("failed to archive %s: %w", path, err)
+		}
+		moved[path] = dest
+		bytes += info.Size()
+	}
+	return moved, bytes, nil
+}
+
+// moveFile renames src to dst, copying it when the archive is on another
+// device
+func (s *Service) moveFile(src, dst string) error {
+	if err := s.fs.Rename(src, dst); err == nil {
+		return nil
+	}
+	in, err := s.fs.Open(src)
+	if err != nil {
+		return err
+	}
+	_, err = fsys.WriteFileAtomic(s.fs, "", dst, in)
+	in.Close()
+	if err != nil {
+		return err
+	}
+	return s.fs.Remove(src)
+}
+
+// clearTranscripts deletes what was derived from a job's transcript
+func (s *Service) clearTranscripts(jobID string) error {
+	return s.conn().Transaction(func(tx *gorm.DB) error {
+		for _, model := range []interface{}{
+			&models.TranscriptRevision{},
+			&models.Translation{},
+			&models.TranscriptInsights{},
+			&models.Summary{},
+			&models.Note{},
+		} {
+			if err := tx.Where("transcription_id = ?", jobID).Delete(model).Error; err != nil {
+				return err
+			}
+		}
+		var sessions []string
+		if err := tx.Model(&models.ChatSession{}).Where("transcription_id = ?", jobID).Pluck("id", &sessions).Error; err != nil {
+			return err
+		}
+		if len(sessions) > 0 {
+			if err := tx.Where("chat_session_id IN ?", sessions).Delete(&models.ChatMessage{}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Where("transcription_id = ?", jobID).Delete(&models.ChatSession{}).Error
+	})
+}
+
+// Run sweeps straight away and then every interval until stop is closed,
+// logging what was removed
+func (s *Service) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		report, err := s.Sweep(context.Background(), s.dryRun)
+		if err != nil {
+			logger.Warn("Failed to apply retention policies", "error", err)
+		} else if len(report.Jobs) > 0 {
+			if report.DryRun {
+				for _, action := range report.Jobs {
+					logger.Info("Retention would apply", "job_id", action.JobID, "policy", action.Policy, "bytes", action.Bytes)
+				}
+			}
+			logger.Info("Applied retention policies", "dry_run", report.DryRun, "jobs", len(report.Jobs), "bytes_freed", report.BytesFreed)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
@@ -0,0 +1,212 @@
+// Package revision keeps the history of hand edits to transcripts. Every
+// edit or revert stores a new revision of the whole transcript rather than
+// overwriting it, so corrections can be compared and undone, and the
+// transcript the models produced is never lost.
+package revision
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"synthezia/internal/jobversion"
+	"synthezia/internal/models"
+	"synthezia/internal/transcript"
+	"synthezia/internal/transcription/interfaces"
+
+	"gorm.io/gorm"
+)
+
+// ErrNotTranscribed means the job has no completed transcript to edit
+var ErrNotTranscribed = errors.New("transcription has no completed transcript")
+
+// ErrEncrypted means the job's transcript is sealed; revisions would keep
+// plaintext copies of it
+var ErrEncrypted = errors.New("encrypted transcripts cannot be edited")
+
+// ErrNoRevision means the job has no revision with the given number
+var ErrNoRevision = errors.New("revision not found")
+
+// Edit applies segment edits to a job's transcript and stores the result as
+// a new revision. The first edit, and the first after the job was
+// transcribed again, also records the transcript being edited. With
+// expected set the job must still be at that version; see jobversion.
+func Edit(db *gorm.DB, jobID string, edits []transcript.SegmentEdit, author, note string, expected *int) (*models.TranscriptRevision, error) {
+	if len(edits) == 0 {
+		return nil, fmt.Errorf("%w: no segments given", transcript.ErrInvalidEdit)
+	}
+	var revision *models.TranscriptRevision
+	err := db.Transaction(func(tx *gorm.DB) error {
+		job, err := editableJob(tx, jobID)
+		if err != nil {
+			return err
+		}
+		var result interfaces.TranscriptResult
+		if err := json.Unmarshal([]byte(*job.Transcript), &result); err != nil {
+			return fmt.Errorf("failed to parse transcript: %w", err)
+		}
+		if err := transcript.ApplyEdits(&result, edits); err != nil {
+			return err
+		}
+		data, err := json.Marshal(&result)
+		if err != nil {
+			return err
+		}
+
+		ids := make([]int, len(edits))
+		for i, edit := range edits {
+			ids[i] = edit.ID
+		}
+		sort.Ints(ids)
+		names := make([]string, len(ids))
+		for i, id := range ids {
+			names[i] = strconv.Itoa(id)
+		}
+		segmentIDs := strings.Join(names, ",")
+
+		revision = &models.TranscriptRevision{
+			Source:     models.RevisionSourceEdit,
+			Author:     author,
+			SegmentIDs: &segmentIDs,
+			Transcript: string(data),
+		}
+		if note != "" {
+			revision.Note = &note
+		}
+		return record(tx, job, revision, expected)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// Revert makes an earlier revision the job's transcript again, as a new
+// revision, so the revisions after it are kept too. Expected is checked as
+// in Edit.
+func Revert(db *gorm.DB, jobID string, number int, author string, expected *int) (*models.TranscriptRevision, error) {
+	var revision *models.TranscriptRevision
+	err := db.Transaction(func(tx *gorm.DB) error {
+		job, err := editableJob(tx, jobID)
+		if err != nil {
+			return err
+		}
+		target, err := Get(tx, jobID, number)
+		if err != nil {
+			return err
+		}
+		revision = &models.TranscriptRevision{
+			Source:     models.RevisionSourceRevert,
+			Author:     author,
+			RevertedTo: &target.Number,
+			Transcript: target.Transcript,
+		}
+		return record(tx, job, revision, expected)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return revision, nil
+}
+
+// List returns a job's revisions, oldest first
+func List(db *gorm.DB, jobID string) ([]models.TranscriptRevision, error) {
+	revisions := []models.TranscriptRevision{}
+	err := db.Where("transcription_id = ?", jobID).Order("number ASC").Find(&revisions).Error
+	return revisions, err
+}
+
+// Get returns one revision of a job
+func Get(db *gorm.DB, jobID string, number int) (*models.TranscriptRevision, error) {
+	var revision models.TranscriptRevision
+	if err := db.Where("transcription_id = ? AND number = ?", jobID, number).First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("%w: %d", ErrNoRevision, number)
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// Latest returns the newest revision of a job, or nil when it has none
+func Latest(db *gorm.DB, jobID string) (*models.TranscriptRevision, error) {
+	var revision models.TranscriptRevision
+	if err := db.Where("transcription_id = ?", jobID).Order("number DESC").First(&revision).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &revision, nil
+}
+
+// Diff lists the segments that differ between two revisions of a job
+func Diff(db *gorm.DB, jobID string, from, to int) ([]transcript.SegmentDiff, error) {
+	results := make([]interfaces.TranscriptResult, 2)
+	for i, number := range []int{from, to} {
+		revision, err := Get(db, jobID, number)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(revision.Transcript), &results[i]); err != nil {
+			return nil, fmt.Errorf("failed to parse revision %d: %w", number, err)
+		}
+	}
+	return transcript.Diff(&results[0], &results[1]), nil
+}
+
+// editableJob loads a job whose transcript can be edited
+func editableJob(tx *gorm.DB, jobID string) (*models.TranscriptionJob, error) {
+	var job models.TranscriptionJob
+	if err := tx.Where("id = ?", jobID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	if job.Encrypted {
+		return nil, ErrEncrypted
+	}
+	if job.Status != models.StatusCompleted || job.Transcript == nil || *job.Transcript == "" {
+		return nil, ErrNotTranscribed
+	}
+	return &job, nil
+}
+
+// record numbers and stores a revision and makes it the job's transcript,
+// first keeping the job's transcript as a revision if it is not the latest
+// one, as after the job was transcribed again
+func record(tx *gorm.DB, job *models.TranscriptionJob, revision *models.TranscriptRevision, expected *int) error {
+	latest, err := Latest(tx, job.ID)
+	if err != nil {
+		return err
+	}
+	number := 0
+	if latest != nil {
+		number = latest.Number
+	}
+	if latest == nil || latest.Transcript != *job.Transcript {
+		number++
+		original := models.TranscriptRevision{
+			TranscriptionID: job.ID,
+			Number:          number,
+			Source:          models.RevisionSourceTranscription,
+			Transcript:      *job.Transcript,
+		}
+		if err := tx.Create(&original).Error; err != nil {
+			return fmt.Errorf("failed to record the transcript: %w", err)
+		}
+	}
+
+	revision.TranscriptionID = job.ID
+	revision.Number = number + 1
+	if err := tx.Create(revision).Error; err != nil {
+		return fmt.Errorf("failed to record revision: %w", err)
+	}
+	version, err := jobversion.Update(tx, job.ID, expected, map[string]interface{}{"transcript": revision.Transcript})
+	if err != nil {
+		return err
+	}
+	revision.JobVersion = version
+	return nil
+}
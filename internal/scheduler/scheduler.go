@@ -0,0 +1,276 @@
+// Package scheduler holds back jobs that should run later and runs recurring
+// tasks on cron schedules. A pending job with a run_after time in the future
+// is skipped by the queue; the scheduler enqueues it once that time comes, so
+// heavy batches can be left to run overnight. Recurring tasks, such as
+// sweeping the dropzone for files the watcher missed, are registered with a
+// cron expression at startup.
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/cron"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// ErrConflictingSchedule means both a time and a cron expression were given
+var ErrConflictingSchedule = errors.New("give run_after or schedule, not both")
+
+// TaskStatus describes a recurring task
+type TaskStatus struct {
+	Name      string     `json:"name"`
+	Schedule  string     `json:"schedule"`
+	NextRun   *time.Time `json:"next_run,omitempty"`
+	LastRun   *time.Time `json:"last_run,omitempty"`
+	LastError string     `json:"last_error,omitempty"`
+	Running   bool       `json:"running"`
+}
+
+// ScheduledJob is a pending job waiting for its run_after time
+type ScheduledJob struct {
+	ID       string    `json:"id"`
+	Title    *string   `json:"title,omitempty"`
+	RunAfter time.Time `json:"run_after"`
+}
+
+// task is a recurring task and when it runs next
+type task struct {
+	name     string
+	schedule *cron.Schedule
+	run      func() error
+	next     time.Time
+	lastRun  *time.Time
+	lastErr  string
+	running  bool
+}
+
+// Scheduler releases scheduled jobs and runs recurring tasks
+type Scheduler struct {
+	db      *gorm.DB
+	clock   clock.Clock
+	enqueue func(jobID string) error
+
+	mu    sync.Mutex
+	tasks []*task
+	// Jobs due up to this time were already released
+	released time.Time
+	wg       sync.WaitGroup
+}
+
+// New creates a scheduler; a nil db uses database.DB at call time
+func New(db *gorm.DB) *Scheduler {
+	return &Scheduler{db: db, clock: clock.Real}
+}
+
+// Default is the process-wide scheduler
+var Default = New(nil)
+
+// SetClock overrides the time source, mainly for tests
+func (s *Scheduler) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetEnqueue sets how a job is queued once its time comes
+func (s *Scheduler) SetEnqueue(enqueue func(jobID string) error) {
+	s.enqueue = enqueue
+}
+
+func (s *Scheduler) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Add registers a task run whenever the cron expression spec matches. A run
+// still going when the next one is due makes that one skip.
+func (s *Scheduler) Add(name, spec string, run func() error) error {
+	schedule, err := cron.Parse(spec)
+	if err != nil {
+		return err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		if t.name == name {
+			return fmt.Errorf("task %q is already scheduled", name)
+		}
+	}
+	s.tasks = append(s.tasks, &task{name: name, schedule: schedule, run: run, next: schedule.Next(s.clock.Now())})
+	return nil
+}
+
+// Run releases due jobs and runs due tasks straight away and then every
+// interval until stop is closed
+func (s *Scheduler) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.RunDue()
+		select {
+		case <-stop:
+			s.wg.Wait()
+			return
+		case <-ticker.C():
+		}
+	}
+}
+
+// RunDue enqueues the jobs whose time has come and starts the tasks that are due
+func (s *Scheduler) RunDue() {
+	now := s.clock.Now()
+	s.releaseJobs(now.UTC())
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, t := range s.tasks {
+		if t.next.IsZero() || t.next.After(now) {
+			continue
+		}
+		t.next = t.schedule.Next(now)
+		if t.running {
+			logger.Warn("Skipping scheduled task, the last run has not finished", "task", t.name)
+			continue
+		}
+		t.running = true
+		s.wg.Add(1)
+		go s.runTask(t, now)
+	}
+}
+
+// Wait blocks until no task is running
+func (s *Scheduler) Wait() {
+	s.wg.Wait()
+}
+
+func (s *Scheduler) runTask(t *task, started time.Time) {
+	defer s.wg.Done()
+	logger.Info("Running scheduled task", "task", t.name)
+	err := t.run()
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	t.running = false
+	t.lastRun = &started
+	t.lastErr = ""
+	if err != nil {
+		t.lastErr = err.Error()
+		logger.Warn("Scheduled task failed", "task", t.name, "error", err)
+	}
+}
+
+// releaseJobs enqueues the pending jobs that became due since the last call
+func (s *Scheduler) releaseJobs(now time.Time) {
+	s.mu.Lock()
+	since := s.released
+	s.mu.Unlock()
+
+	query := s.conn().Model(&models.TranscriptionJob{}).
+		Where("status = ? AND run_after IS NOT NULL AND run_after <= ?", models.StatusPending, now)
+	if !since.IsZero() {
+		query = query.Where("run_after > ?", since)
+	}
+	var jobIDs []string
+	if err := query.Order("run_after ASC").Pluck("id", &jobIDs).Error; err != nil {
+		logger.Error("Failed to find scheduled jobs", "error", err)
+		return
+	}
+
+	s.mu.Lock()
+	s.released = now
+	s.mu.Unlock()
+	for _, jobID := range jobIDs {
+		logger.Info("Releasing scheduled job", "job_id", jobID)
+		if s.enqueue == nil {
+			continue
+		}
+		// A job the queue cannot take now is found by its job scanner later
+		if err := s.enqueue(jobID); err != nil {
+			logger.Warn("Failed to enqueue scheduled job", "job_id", jobID, "error", err)
+		}
+	}
+}
+
+// Tasks lists the recurring tasks by name
+func (s *Scheduler) Tasks() []TaskStatus {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]TaskStatus, 0, len(s.tasks))
+	for _, t := range s.tasks {
+		status := TaskStatus{
+			Name:      t.name,
+			Schedule:  t.schedule.String(),
+			LastRun:   t.lastRun,
+			LastError: t.lastErr,
+			Running:   t.running,
+		}
+		if !t.next.IsZero() {
+			next := t.next
+			status.NextRun = &next
+		}
+		statuses = append(statuses, status)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+// ScheduledJobs lists the pending jobs waiting for their time, soonest first
+func (s *Scheduler) ScheduledJobs() ([]ScheduledJob, error) {
+	var jobs []models.TranscriptionJob
+	if err := s.conn().Select("id", "title", "run_after").
+		Where("status = ? AND run_after > ?", models.StatusPending, s.clock.Now().UTC()).
+		Order("run_after ASC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	scheduled := make([]ScheduledJob, 0, len(jobs))
+	for _, job := range jobs {
+		scheduled = append(scheduled, ScheduledJob{ID: job.ID, Title: job.Title, RunAfter: *job.RunAfter})
+	}
+	return scheduled, nil
+}
+
+// ResolveRunAfter turns the run_after and schedule options of a job into the
+// time it may start: runAfter is an RFC 3339 time and spec a cron expression
+// whose next match after now, in now's location, is taken. Both empty means
+// the job runs as soon as it can, and nil is returned.
+func ResolveRunAfter(runAfter, spec string, now time.Time) (*time.Time, error) {
+	runAfter = strings.TrimSpace(runAfter)
+	spec = strings.TrimSpace(spec)
+	switch {
+	case runAfter != "" && spec != "":
+		return nil, ErrConflictingSchedule
+	case runAfter != "":
+		t, err := time.Parse(time.RFC3339, runAfter)
+		if err != nil {
+			return nil, errors.New("run_after must be an RFC 3339 time such as 2006-01-02T22:00:00Z")
+		}
+		// A time already past runs the job straight away
+		if !t.After(now) {
+			return nil, nil
+		}
+		t = t.UTC()
+		return &t, nil
+	case spec != "":
+		schedule, err := cron.Parse(spec)
+		if err != nil {
+			return nil, err
+		}
+		next := schedule.Next(now)
+		if next.IsZero() {
+			return nil, fmt.Errorf("cron expression %q never matches", spec)
+		}
+		next = next.UTC()
+		return &next, nil
+	}
+	return nil, nil
+}
@@ -0,0 +1,329 @@
+// Package search keeps a full-text index of transcript segments, an SQLite
+// FTS5 table with a row per segment, so recordings can be searched for what
+// was said and each match points at the moment it was said.
+package search
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/internal/transcript"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Table is the FTS5 table segments are indexed in; database.Initialize creates it
+const Table = "transcript_search"
+
+// Snippets mark the matched words with these
+const (
+	HighlightStart = "<mark>"
+	HighlightEnd   = "</mark>"
+)
+
+// maxMatchesPerJob bounds the segments listed for one job
+const maxMatchesPerJob = 5
+
+// maxSegments bounds the matching segments read for one search
+const maxSegments = 2000
+
+// Match is a segment that matched a search
+type Match struct {
+	Segment int     `json:"segment"` // Numbered as in the structured transcript
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Speaker *string `json:"speaker"` // Renamed as the job's speaker mappings say
+	// Snippet is the text around the matched words, which are wrapped in
+	// HighlightStart and HighlightEnd; the text itself is not escaped
+	Snippet string `json:"snippet"`
+}
+
+// JobResult is a job with segments that matched, best match first
+type JobResult struct {
+	JobID      string     `json:"job_id"`
+	Title      *string    `json:"title,omitempty"`
+	RecordedAt *time.Time `json:"recorded_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+	Matches    []Match    `json:"matches"`
+	// TotalMatches counts every matching segment, beyond those listed
+	TotalMatches int `json:"total_matches"`
+}
+
+// Results are the jobs matching a search, best first
+type Results struct {
+	Query string      `json:"query"`
+	Total int         `json:"total"` // Matching jobs, beyond this page
+	Jobs  []JobResult `json:"jobs"`
+}
+
+// Service indexes and searches transcripts
+type Service struct {
+	db *gorm.DB
+}
+
+// NewService creates a search service; a nil db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{db: db}
+}
+
+// Default is the process-wide search service
+var Default = NewService(nil)
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Index replaces a job's segments in the index with those of its current
+// transcript. Jobs without a transcript, encrypted jobs, whose transcripts
+// must not be kept in the clear, and the temporary jobs of multi-track
+// tracks are removed instead.
+func (s *Service) Index(jobID string) error {
+	if strings.HasPrefix(jobID, "track_") {
+		return s.Remove(jobID)
+	}
+	var job models.TranscriptionJob
+	if err := s.conn().Select("id", "status", "transcript", "encrypted").Where("id = ?", jobID).First(&job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return s.Remove(jobID)
+		}
+		return err
+	}
+	if job.Encrypted || job.Transcript == nil || job.Status != models.StatusCompleted {
+		return s.Remove(jobID)
+	}
+
+	var result interfaces.TranscriptResult
+	if err := json.Unmarshal([]byte(*job.Transcript), &result); err != nil {
+		return fmt.Errorf("failed to parse transcript: %w", err)
+	}
+	structured := transcript.Structure(jobID, &result, nil)
+
+	return s.conn().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM "+Table+" WHERE job_id = ?", jobID).Error; err != nil {
+			return err
+		}
+		for _, segment := range structured.Segments {
+			if strings.TrimSpace(segment.Text) == "" {
+				continue
+			}
+			speaker := ""
+			if segment.Speaker != nil {
+				speaker = *segment.Speaker
+			}
+			if err := tx.Exec("INSERT INTO "+Table+" (job_id, segment, start_time, end_time, speaker, text) VALUES (?, ?, ?, ?, ?, ?)",
+				jobID, segment.ID, segment.Start, segment.End, speaker, segment.Text).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// Remove drops a job's segments from the index
+func (s *Service) Remove(jobID string) error {
+	return s.conn().Exec("DELETE FROM "+Table+" WHERE job_id = ?", jobID).Error
+}
+
+// Backfill indexes the completed jobs missing from the index, such as those
+// finished before it existed, and drops the segments of deleted jobs. It
+// returns how many jobs were indexed.
+func (s *Service) Backfill() (int, error) {
+	if err := s.conn().Exec("DELETE FROM " + Table + " WHERE job_id NOT IN (SELECT id FROM transcription_jobs)").Error; err != nil {
+		return 0, fmt.Errorf("failed to prune index: %w", err)
+	}
+
+	var ids []string
+	if err := s.conn().Model(&models.TranscriptionJob{}).
+		Where("status = ? AND transcript IS NOT NULL AND encrypted = ?", models.StatusCompleted, false).
+		Where("id NOT IN (SELECT DISTINCT job_id FROM "+Table+")").
+		Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	indexed := 0
+	for _, id := range ids {
+		if err := s.Index(id); err != nil {
+			logger.Warn("Failed to index transcript", "job_id", id, "error", err)
+			continue
+		}
+		indexed++
+	}
+	return indexed, nil
+}
+
+// TrackJobs indexes the transcripts of jobs as they complete. It returns a
+// function that stops tracking.
+func (s *Service) TrackJobs() func() {
+	return jobstate.Subscribe(func(event jobstate.Event) {
+		if event.To != models.StatusCompleted {
+			return
+		}
+		go func() {
+			if err := s.Index(event.JobID); err != nil {
+				logger.Warn("Failed to index transcript", "job_id", event.JobID, "error", err)
+			}
+		}()
+	})
+}
+
+// stopWords are left out of searches, so asking in a sentence does not match
+// every segment with "the" in it
+var stopWords = map[string]bool{
+	"a": true, "an": true, "and": true, "are": true, "as": true, "at": true,
+	"be": true, "but": true, "by": true, "did": true, "do": true, "does": true,
+	"for": true, "from": true, "had": true, "has": true, "have": true, "how": true,
+	"i": true, "if": true, "in": true, "is": true, "it": true, "of": true,
+	"on": true, "or": true, "our": true, "so": true, "that": true, "the": true,
+	"this": true, "to": true, "us": true, "was": true, "we": true, "were": true,
+	"what": true, "when": true, "where": true, "which": true, "who": true,
+	"why": true, "with": true, "you": true,
+}
+
+// MatchQuery turns what a user typed into an FTS5 query. Words match any
+// form sharing their stem, a trailing * matches any word starting with the
+// prefix and "quoted phrases" match in order. A segment matching any of
+// them is found; those matching more, and rarer, words rank higher. Common
+// English words are left out unless nothing else is left. It returns ""
+// when nothing searchable is left.
+func MatchQuery(input string) string {
+	var terms, common []string
+	for i, part := range strings.Split(input, `"`) {
+		if i%2 == 1 {
+			// Inside quotes: one phrase
+			if words := strings.Fields(part); len(words) > 0 {
+				terms = append(terms, quote(strings.Join(words, " ")))
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			prefix := strings.HasSuffix(word, "*")
+			word = strings.Trim(word, "*")
+			if word == "" {
+				continue
+			}
+			term := quote(word)
+			if prefix {
+				term += "*"
+			}
+			if !prefix && stopWords[strings.ToLower(strings.Trim(word, ".,;:!?'()"))] {
+				common = append(common, term)
+				continue
+			}
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		terms = common
+	}
+	return strings.Join(terms, " OR ")
+}
+
+// quote makes text one FTS5 string, so its punctuation is never syntax
+func quote(text string) string {
+	return `"` + strings.ReplaceAll(text, `"`, `""`) + `"`
+}
+
+// segmentRow is a matching segment as read from the index
+type segmentRow struct {
+	JobID     string
+	Segment   int
+	StartTime float64
+	EndTime   float64
+	Speaker   string
+	Snippet   string
+}
+
+// Search finds the jobs whose transcripts match the query, best first, and
+// returns a page of them with their best matching segments
+func (s *Service) Search(query string, limit, offset int) (*Results, error) {
+	results := &Results{Query: query, Jobs: []JobResult{}}
+	match := MatchQuery(query)
+	if match == "" {
+		return results, nil
+	}
+
+	// Rows of deleted jobs are skipped until the next backfill prunes them
+	var rows []segmentRow
+	err := s.conn().Raw(`SELECT `+Table+`.job_id, segment, start_time, end_time, speaker,
+			snippet(`+Table+`, 5, ?, ?, '…', 16) AS snippet
+		FROM `+Table+` JOIN transcription_jobs ON transcription_jobs.id = `+Table+`.job_id
+		WHERE `+Table+` MATCH ?
+		ORDER BY bm25(`+Table+`)
+		LIMIT ?`, HighlightStart, HighlightEnd, match, maxSegments).Scan(&rows).Error
+	if err != nil {
+		return nil, fmt.Errorf("failed to search transcripts: %w", err)
+	}
+
+	// Jobs rank by their best segment
+	byJob := make(map[string]*JobResult)
+	var order []string
+	for _, row := range rows {
+		job, ok := byJob[row.JobID]
+		if !ok {
+			job = &JobResult{JobID: row.JobID, Matches: []Match{}}
+			byJob[row.JobID] = job
+			order = append(order, row.JobID)
+		}
+		job.TotalMatches++
+		if len(job.Matches) < maxMatchesPerJob {
+			m := Match{Segment: row.Segment, Start: row.StartTime, End: row.EndTime, Snippet: row.Snippet}
+			if row.Speaker != "" {
+				speaker := row.Speaker
+				m.Speaker = &speaker
+			}
+			job.Matches = append(job.Matches, m)
+		}
+	}
+	results.Total = len(order)
+	if offset >= len(order) {
+		return results, nil
+	}
+	order = order[offset:]
+	if limit > 0 && limit < len(order) {
+		order = order[:limit]
+	}
+
+	var jobs []models.TranscriptionJob
+	if err := s.conn().Select("id", "title", "recorded_at", "created_at").Where("id IN ?", order).Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	for _, job := range jobs {
+		byJob[job.ID].Title = job.Title
+		byJob[job.ID].RecordedAt = job.RecordedAt
+		byJob[job.ID].CreatedAt = job.CreatedAt
+	}
+	var mappings []models.SpeakerMapping
+	if err := s.conn().Where("transcription_job_id IN ?", order).Find(&mappings).Error; err != nil {
+		return nil, err
+	}
+	names := make(map[string]map[string]string)
+	for _, mapping := range mappings {
+		if names[mapping.TranscriptionJobID] == nil {
+			names[mapping.TranscriptionJobID] = make(map[string]string)
+		}
+		names[mapping.TranscriptionJobID][mapping.OriginalSpeaker] = mapping.CustomName
+	}
+
+	for _, id := range order {
+		job := byJob[id]
+		for i, m := range job.Matches {
+			if m.Speaker == nil {
+				continue
+			}
+			if name := names[id][*m.Speaker]; name != "" {
+				job.Matches[i].Speaker = &name
+			}
+		}
+		results.Jobs = append(results.Jobs, *job)
+	}
+	return results, nil
+}
@@ -0,0 +1,215 @@
+// Package sourceaudio deletes a job's original audio, or replaces it with a
+// low-bitrate proxy, once the transcript is final, reclaiming space for users
+// who only need the text.
+package sourceaudio
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Job events recorded when the source audio is removed
+const (
+	EventDeleted = "source_audio_deleted"
+	EventProxied = "source_audio_proxied"
+)
+
+// ValidAction reports whether action is one of the models.SourceAudio* values
+func ValidAction(action string) bool {
+	switch action {
+	case models.SourceAudioKeep, models.SourceAudioDelete, models.SourceAudioProxy:
+		return true
+	}
+	return false
+}
+
+// Service applies the source audio action to completed jobs
+type Service struct {
+	db            *gorm.DB
+	fs            fsys.FS
+	clock         clock.Clock
+	ffmpegPath    string
+	tempDir       string
+	defaultAction string
+}
+
+// NewService creates a service that keeps audio unless told otherwise; a nil
+// db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:            db,
+		fs:            fsys.OS,
+		clock:         clock.Real,
+		ffmpegPath:    "ffmpeg",
+		defaultAction: models.SourceAudioKeep,
+	}
+}
+
+// Default is the process-wide service
+var Default = NewService(nil)
+
+// SetFS overrides the filesystem, mainly for tests
+func (s *Service) SetFS(fs fsys.FS) {
+	s.fs = fs
+}
+
+// SetClock overrides the time source, mainly for tests
+func (s *Service) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetTempDir sets where proxies are encoded before replacing the original
+func (s *Service) SetTempDir(dir string) {
+	s.tempDir = dir
+}
+
+// SetDefaultAction sets the action for jobs that do not choose one
+func (s *Service) SetDefaultAction(action string) error {
+	if !ValidAction(action) {
+		return fmt.Errorf("invalid source audio action %q (expected keep, delete or proxy)", action)
+	}
+	s.defaultAction = action
+	return nil
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Track applies the action to every job that completes. It returns a
+// function that stops tracking.
+func (s *Service) Track() func() {
+	return jobstate.Subscribe(func(event jobstate.Event) {
+		if event.To != models.StatusCompleted {
+			return
+		}
+		go func() {
+			if err := s.Apply(context.Background(), event.JobID); err != nil {
+				logger.Warn("Failed to apply source audio action", "job_id", event.JobID, "error", err)
+			}
+		}()
+	})
+}
+
+// Apply deletes or proxies a completed job's audio according to its action.
+// Jobs set to keep, not yet completed, or already handled are left alone.
+func (s *Service) Apply(ctx context.Context, jobID string) error {
+	var job models.TranscriptionJob
+	if err := s.conn().Preload("MultiTrackFiles").Where("id = ?", jobID).First(&job).Error; err != nil {
+		return err
+	}
+	if job.Status != models.StatusCompleted || job.SourceAudioRemovedAt != nil {
+		return nil
+	}
+	// Encrypted jobs are handled once sealed, so the files are not removed
+	// while being encrypted
+	if job.Encrypted && job.EncryptedAt == nil {
+		return nil
+	}
+	action := s.defaultAction
+	if job.SourceAudioAction != nil {
+		action = *job.SourceAudioAction
+	}
+	// A proxy would be a plaintext copy of sealed audio
+	if job.Encrypted && action == models.SourceAudioProxy {
+		return nil
+	}
+
+	// Every copy of the source: the upload itself, or a multi-track job's
+	// tracks and their mixdown
+	sources := []string{job.AudioPath}
+	for _, track := range job.MultiTrackFiles {
+		sources = append(sources, track.FilePath)
+	}
+	if job.MergedAudioPath != nil && *job.MergedAudioPath != job.AudioPath {
+		sources = append(sources, *job.MergedAudioPath)
+	}
+
+	ctx = logger.WithJobID(ctx, jobID)
+	switch action {
+	case models.SourceAudioDelete:
+		freed := s.remove(sources)
+		if err := s.conn().Model(&models.TranscriptionJob{}).Where("id = ?", jobID).
+			Update("source_audio_removed_at", s.clock.Now()).Error; err != nil {
+			return err
+		}
+		logger.JobEvent(ctx, jobID, EventDeleted, "bytes_freed", freed)
+	case models.SourceAudioProxy:
+		proxyPath, err := s.encodeProxy(ctx, job.AudioPath)
+		if err != nil {
+			return err
+		}
+		freed := s.remove(sources)
+		if info, err := s.fs.Stat(proxyPath); err == nil {
+			freed -= info.Size()
+		}
+		if err := s.conn().Model(&models.TranscriptionJob{}).Where("id = ?", jobID).Updates(map[string]interface{}{
+			"audio_path":              proxyPath,
+			"source_audio_removed_at": s.clock.Now(),
+		}).Error; err != nil {
+			return err
+		}
+		logger.JobEvent(ctx, jobID, EventProxied, "proxy_path", proxyPath, "bytes_freed", freed)
+	}
+	return nil
+}
+
+// remove deletes the files that exist and returns how many bytes that freed
+func (s *Service) remove(paths []string) int64 {
+	var freed int64
+	for _, path := range paths {
+		info, err := s.fs.Stat(path)
+		if err != nil {
+			continue
+		}
+		if err := s.fs.Remove(path); err != nil {
+			logger.Warn("Failed to remove source audio", "path", path, "error", err)
+			continue
+		}
+		freed += info.Size()
+	}
+	return freed
+}
+
+// encodeProxy writes a 32 kbps mono MP3 of source next to it and returns its path
+func (s *Service) encodeProxy(ctx context.Context, source string) (string, error) {
+	proxyPath := strings.TrimSuffix(source, filepath.Ext(source)) + ".proxy.mp3"
+	tempPath := fsys.TempPath(s.tempDir, proxyPath) + ".mp3"
+
+	cmd := exec.CommandContext(ctx, s.ffmpegPath,
+		"-y",
+		"-i", source,
+		"-vn",
+		"-ac", "1",
+		"-ar", "22050",
+		"-b:a", "32k",
+		tempPath)
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "source_proxy", start, "input", source)
+	if err != nil {
+		s.fs.Remove(tempPath)
+		return "", fmt.Errorf("ffmpeg failed to encode proxy: %w: %s", err, strings.TrimSpace(string(output)))
+	}
+	if err := s.fs.Rename(tempPath, proxyPath); err != nil {
+		s.fs.Remove(tempPath)
+		return "", err
+	}
+	return proxyPath, nil
+}
@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"synthezia/internal/config"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/s3"
+)
+
+// ErrNotFound is returned when an object does not exist
+var ErrNotFound = errors.New("storage: object not found")
+
+// Backend keeps objects under slash-separated keys such as
+// "jobs/<id>/audio.mp3"
+type Backend interface {
+	// Name is "local" or "s3"
+	Name() string
+	// Put writes size bytes from r to key, replacing any object there
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Open reads an object; the caller closes it
+	Open(ctx context.Context, key string) (io.ReadCloser, error)
+	// Size returns an object's size, or ErrNotFound
+	Size(ctx context.Context, key string) (int64, error)
+	// Delete removes an object; deleting a missing object is not an error
+	Delete(ctx context.Context, key string) error
+	// URL returns a link that downloads key without credentials until expiry
+	// passes, saved as filename, or "" when the backend cannot make one and
+	// downloads go through the API instead
+	URL(key string, filename string, expiry time.Duration) (string, error)
+}
+
+// NewBackend creates the backend StorageBackend names, or nil when it is
+// empty and media stays in the data directory
+func NewBackend(cfg *config.Config) (Backend, error) {
+	switch cfg.StorageBackend {
+	case "":
+		return nil, nil
+	case "local":
+		return NewLocal(fsys.OS, cfg.StorageLocalDir), nil
+	case "s3":
+		client, err := s3.New(s3.Config{
+			Endpoint:  cfg.StorageS3Endpoint,
+			Region:    cfg.StorageS3Region,
+			Bucket:    cfg.StorageS3Bucket,
+			AccessKey: cfg.StorageS3AccessKey,
+			SecretKey: cfg.StorageS3SecretKey,
+			PathStyle: cfg.StorageS3PathStyle,
+		})
+		if err != nil {
+			return nil, err
+		}
+		return NewS3(client, cfg.StorageS3Prefix), nil
+	default:
+		return nil, fmt.Errorf("unknown storage backend %q, expected local or s3", cfg.StorageBackend)
+	}
+}
+
+// Local keeps objects as files under a directory, such as a mounted share
+type Local struct {
+	fs  fsys.FS
+	dir string
+}
+
+// NewLocal creates a backend storing objects under dir
+func NewLocal(fs fsys.FS, dir string) *Local {
+	return &Local{fs: fs, dir: dir}
+}
+
+func (l *Local) Name() string { return "local" }
+
+// path maps a key to its file, refusing keys that would leave the directory
+func (l *Local) path(key string) (string, error) {
+	clean := path.Clean("/" + key)
+	if clean == "/" || clean != "/"+key {
+		return "", fmt.Errorf("storage: invalid key %q", key)
+	}
+	return filepath.Join(l.dir, filepath.FromSlash(key)), nil
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := l.fs.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	_, err = fsys.WriteFileAtomic(l.fs, "", name, r)
+	return err
+}
+
+func (l *Local) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return nil, err
+	}
+	f, err := l.fs.Open(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrNotFound
+	}
+	return f, err
+}
+
+func (l *Local) Size(ctx context.Context, key string) (int64, error) {
+	name, err := l.path(key)
+	if err != nil {
+		return 0, err
+	}
+	info, err := l.fs.Stat(name)
+	if errors.Is(err, os.ErrNotExist) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	name, err := l.path(key)
+	if err != nil {
+		return err
+	}
+	if err := l.fs.Remove(name); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) URL(key string, filename string, expiry time.Duration) (string, error) {
+	return "", nil
+}
+
+// S3 keeps objects in a bucket, under a prefix such as "synthezia/"
+type S3 struct {
+	client *s3.Client
+	prefix string
+}
+
+// NewS3 creates a backend storing objects in the client's bucket
+func NewS3(client *s3.Client, prefix string) *S3 {
+	prefix = strings.Trim(prefix, "/")
+	if prefix != "" {
+		prefix += "/"
+	}
+	return &S3{client: client, prefix: prefix}
+}
+
+func (b *S3) Name() string { return "s3" }
+
+func (b *S3) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	return b.client.Put(ctx, b.prefix+key, r, size, mime.TypeByExtension(path.Ext(key)))
+}
+
+func (b *S3) Open(ctx context.Context, key string) (io.ReadCloser, error) {
+	body, err := b.client.Get(ctx, b.prefix+key)
+	if errors.Is(err, s3.ErrNotFound) {
+		return nil, ErrNotFound
+	}
+	return body, err
+}
+
+func (b *S3) Size(ctx context.Context, key string) (int64, error) {
+	object, err := b.client.Head(ctx, b.prefix+key)
+	if errors.Is(err, s3.ErrNotFound) {
+		return 0, ErrNotFound
+	}
+	if err != nil {
+		return 0, err
+	}
+	return object.Size, nil
+}
+
+func (b *S3) Delete(ctx context.Context, key string) error {
+	return b.client.Delete(ctx, b.prefix+key)
+}
+
+func (b *S3) URL(key string, filename string, expiry time.Duration) (string, error) {
+	return b.client.PresignGet(b.prefix+key, expiry, filename)
+}
@@ -0,0 +1,463 @@
+// Package storage moves the media and transcripts of completed jobs to a
+// storage backend, another directory or an S3-compatible bucket, and brings
+// the media back when a job needs it again, so the server only holds large
+// media while it works on it.
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Job events recorded when media moves
+const (
+	EventOffloaded = "media_offloaded"
+	EventRestored  = "media_restored"
+)
+
+// MediaKinds are the kinds of stored objects that hold audio
+var MediaKinds = []string{models.StoredAudio, models.StoredProject, models.StoredTrack, models.StoredMerged}
+
+// ErrDisabled is returned when no backend is configured
+var ErrDisabled = errors.New("storage: no backend configured")
+
+// Service moves job files between the data directory and the backend
+type Service struct {
+	db        *gorm.DB
+	fs        fsys.FS
+	clock     clock.Clock
+	backend   Backend
+	keepLocal bool
+	urlExpiry time.Duration
+	batchSize int
+	// Jobs that failed to be stored wait retryDelay before the next attempt,
+	// so an unreachable backend does not hold up the batch
+	retryAt    map[string]time.Time
+	retryMu    sync.Mutex
+	retryDelay time.Duration
+}
+
+// NewService creates a service without a backend, which leaves media where
+// it is; a nil db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:         db,
+		fs:         fsys.OS,
+		clock:      clock.Real,
+		urlExpiry:  15 * time.Minute,
+		batchSize:  20,
+		retryAt:    make(map[string]time.Time),
+		retryDelay: 10 * time.Minute,
+	}
+}
+
+// Default is the process-wide service
+var Default = NewService(nil)
+
+// SetFS overrides the filesystem, mainly for tests
+func (s *Service) SetFS(fs fsys.FS) {
+	s.fs = fs
+}
+
+// SetClock overrides the time source, mainly for tests
+func (s *Service) SetClock(c clock.Clock) {
+	s.clock = c
+}
+
+// SetBackend sets where job files are stored; nil disables storing them
+func (s *Service) SetBackend(backend Backend) {
+	s.backend = backend
+}
+
+// SetKeepLocal keeps the local copies of stored files
+func (s *Service) SetKeepLocal(keep bool) {
+	s.keepLocal = keep
+}
+
+// SetURLExpiry sets how long download links stay valid
+func (s *Service) SetURLExpiry(expiry time.Duration) {
+	if expiry > 0 {
+		s.urlExpiry = expiry
+	}
+}
+
+// URLExpiry is how long download links stay valid
+func (s *Service) URLExpiry() time.Duration {
+	return s.urlExpiry
+}
+
+// Enabled reports whether a backend is configured
+func (s *Service) Enabled() bool {
+	return s.backend != nil
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// jobFile is a file of a job and the kind of object it is stored as
+type jobFile struct {
+	kind string
+	path string
+}
+
+// mediaFiles lists the audio of a job, each path once: the upload or project
+// file, a multi-track job's tracks and their mixdown. Playback proxies and
+// waveforms are small and stay local.
+func mediaFiles(job *models.TranscriptionJob) []jobFile {
+	var files []jobFile
+	seen := make(map[string]bool)
+	add := func(kind, path string) {
+		if path != "" && !seen[path] {
+			seen[path] = true
+			files = append(files, jobFile{kind, path})
+		}
+	}
+	add(models.StoredAudio, job.AudioPath)
+	if job.AupFilePath != nil {
+		add(models.StoredProject, *job.AupFilePath)
+	}
+	for _, track := range job.MultiTrackFiles {
+		add(models.StoredTrack, track.FilePath)
+	}
+	if job.MergedAudioPath != nil {
+		add(models.StoredMerged, *job.MergedAudioPath)
+	}
+	return files
+}
+
+// objectKey names the object of a job's file, such as
+// "jobs/<id>/track/guest.wav"
+func objectKey(jobID, kind, localPath string) string {
+	if kind == models.StoredTranscript {
+		return path.Join("jobs", jobID, "transcript.json")
+	}
+	return path.Join("jobs", jobID, kind, filepath.Base(localPath))
+}
+
+// Offload stores a completed job's media and transcript in the backend and,
+// unless local copies are kept, removes the media from disk. Encrypted jobs
+// are left alone, since their audio is decrypted from disk while served.
+func (s *Service) Offload(ctx context.Context, jobID string) error {
+	if s.backend == nil {
+		return ErrDisabled
+	}
+	var job models.TranscriptionJob
+	if err := s.conn().Preload("MultiTrackFiles").Where("id = ?", jobID).First(&job).Error; err != nil {
+		return err
+	}
+	if job.Status != models.StatusCompleted || job.OffloadedAt != nil || job.Encrypted || strings.HasPrefix(job.ID, "track_") {
+		return nil
+	}
+
+	var stored []string
+	var total int64
+	for _, file := range mediaFiles(&job) {
+		info, err := s.fs.Stat(file.path)
+		if err != nil || info.IsDir() {
+			continue // Already removed, such as source audio deleted after transcription
+		}
+		if err := s.upload(ctx, &job, file, info.Size()); err != nil {
+			return fmt.Errorf("failed to store %s: %w", file.path, err)
+		}
+		stored = append(stored, file.path)
+		total += info.Size()
+	}
+	if job.Transcript != nil {
+		if _, err := s.storeTranscript(ctx, &job); err != nil {
+			return fmt.Errorf("failed to store transcript: %w", err)
+		}
+	}
+
+	if !s.keepLocal {
+		for _, localPath := range stored {
+			if err := s.fs.Remove(localPath); err != nil {
+				logger.Warn("Failed to remove stored media", "path", localPath, "error", err)
+			}
+		}
+	}
+	// Moving the media is not an edit, so updated_at is left alone
+	if err := s.conn().Model(&models.TranscriptionJob{}).Where("id = ?", jobID).
+		UpdateColumn("offloaded_at", s.clock.Now()).Error; err != nil {
+		return err
+	}
+	logger.JobEvent(logger.WithJobID(ctx, jobID), jobID, EventOffloaded, "backend", s.backend.Name(), "files", len(stored), "bytes", total)
+	return nil
+}
+
+// upload puts one file in the backend and records it
+func (s *Service) upload(ctx context.Context, job *models.TranscriptionJob, file jobFile, size int64) error {
+	f, err := s.fs.Open(file.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	key := objectKey(job.ID, file.kind, file.path)
+	if err := s.backend.Put(ctx, key, f, size); err != nil {
+		return err
+	}
+	return s.record(job.ID, file.kind, key, file.path, size)
+}
+
+// record saves or updates the object of a job's file
+func (s *Service) record(jobID, kind, key, localPath string, size int64) error {
+	var object models.StoredObject
+	err := s.conn().Where("job_id = ? AND local_path = ?", jobID, localPath).First(&object).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		object = models.StoredObject{JobID: jobID, Kind: kind, Key: key, LocalPath: localPath, Size: size}
+		return s.conn().Create(&object).Error
+	}
+	if err != nil {
+		return err
+	}
+	return s.conn().Model(&object).Updates(map[string]interface{}{"kind": kind, "key": key, "size": size}).Error
+}
+
+// storeTranscript puts the job's transcript in the backend
+func (s *Service) storeTranscript(ctx context.Context, job *models.TranscriptionJob) (*models.StoredObject, error) {
+	data := []byte(*job.Transcript)
+	key := objectKey(job.ID, models.StoredTranscript, "")
+	if err := s.backend.Put(ctx, key, bytes.NewReader(data), int64(len(data))); err != nil {
+		return nil, err
+	}
+	if err := s.record(job.ID, models.StoredTranscript, key, "", int64(len(data))); err != nil {
+		return nil, err
+	}
+	return s.Find(job.ID, "")
+}
+
+// Find returns the stored object of a job's file, or nil when it is not
+// stored; an empty localPath finds the transcript
+func (s *Service) Find(jobID, localPath string) (*models.StoredObject, error) {
+	var objects []models.StoredObject
+	if err := s.conn().Where("job_id = ? AND local_path = ?", jobID, localPath).Limit(1).Find(&objects).Error; err != nil {
+		return nil, err
+	}
+	if len(objects) == 0 {
+		return nil, nil
+	}
+	return &objects[0], nil
+}
+
+// Transcript returns the stored transcript of a job, storing it again first
+// when it was edited since
+func (s *Service) Transcript(ctx context.Context, job *models.TranscriptionJob) (*models.StoredObject, error) {
+	if s.backend == nil {
+		return nil, ErrDisabled
+	}
+	if job.Transcript == nil {
+		return nil, nil
+	}
+	object, err := s.Find(job.ID, "")
+	if err != nil {
+		return nil, err
+	}
+	if object != nil && !job.UpdatedAt.After(object.UpdatedAt) {
+		return object, nil
+	}
+	return s.storeTranscript(ctx, job)
+}
+
+// URL returns a link that downloads an object without credentials, saved
+// as filename, or "" when the backend cannot make one
+func (s *Service) URL(object *models.StoredObject, filename string) (string, error) {
+	if s.backend == nil {
+		return "", ErrDisabled
+	}
+	return s.backend.URL(object.Key, filename, s.urlExpiry)
+}
+
+// Open reads an object from the backend; the caller closes it
+func (s *Service) Open(ctx context.Context, object *models.StoredObject) (io.ReadCloser, error) {
+	if s.backend == nil {
+		return nil, ErrDisabled
+	}
+	return s.backend.Open(ctx, object.Key)
+}
+
+// Restore brings back the stored media of a job missing from disk, so it
+// can be transcribed again. The job is stored again once it completes.
+func (s *Service) Restore(ctx context.Context, jobID string) error {
+	if s.backend == nil {
+		return ErrDisabled
+	}
+	var objects []models.StoredObject
+	if err := s.conn().Where("job_id = ? AND local_path <> ''", jobID).Find(&objects).Error; err != nil {
+		return err
+	}
+	restored := 0
+	for _, object := range objects {
+		if fsys.Exists(s.fs, object.LocalPath) {
+			continue
+		}
+		if err := s.download(ctx, &object); err != nil {
+			return fmt.Errorf("failed to restore %s: %w", object.LocalPath, err)
+		}
+		restored++
+	}
+	if err := s.conn().Model(&models.TranscriptionJob{}).Where("id = ?", jobID).
+		UpdateColumn("offloaded_at", nil).Error; err != nil {
+		return err
+	}
+	if restored > 0 {
+		logger.JobEvent(logger.WithJobID(ctx, jobID), jobID, EventRestored, "backend", s.backend.Name(), "files", restored)
+	}
+	return nil
+}
+
+// Fetch brings back a stored file missing from disk for a one-off use, such
+// as translating a job's audio, without marking the job as restored. It
+// returns whether the file was fetched, in which case the caller removes it
+// when done.
+func (s *Service) Fetch(ctx context.Context, localPath string) (bool, error) {
+	if s.backend == nil || localPath == "" || fsys.Exists(s.fs, localPath) {
+		return false, nil
+	}
+	var objects []models.StoredObject
+	if err := s.conn().Where("local_path = ?", localPath).Limit(1).Find(&objects).Error; err != nil {
+		return false, err
+	}
+	if len(objects) == 0 {
+		return false, nil
+	}
+	if err := s.download(ctx, &objects[0]); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+func (s *Service) download(ctx context.Context, object *models.StoredObject) error {
+	body, err := s.backend.Open(ctx, object.Key)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+	if err := s.fs.MkdirAll(filepath.Dir(object.LocalPath), 0755); err != nil {
+		return err
+	}
+	_, err = fsys.WriteFileAtomic(s.fs, "", object.LocalPath, body)
+	return err
+}
+
+// StoredBytes adds up the size of a job's stored objects of the given kinds,
+// or of all of them when none are given
+func (s *Service) StoredBytes(jobID string, kinds ...string) (int64, error) {
+	query := s.conn().Model(&models.StoredObject{}).Where("job_id = ?", jobID)
+	if len(kinds) > 0 {
+		query = query.Where("kind IN ?", kinds)
+	}
+	var total int64
+	err := query.Select("COALESCE(SUM(size), 0)").Scan(&total).Error
+	return total, err
+}
+
+// Purge deletes a job's stored objects of the given kinds, or all of them
+// when none are given, and returns the bytes freed in the backend
+func (s *Service) Purge(ctx context.Context, jobID string, kinds ...string) (int64, error) {
+	query := s.conn().Where("job_id = ?", jobID)
+	if len(kinds) > 0 {
+		query = query.Where("kind IN ?", kinds)
+	}
+	var objects []models.StoredObject
+	if err := query.Find(&objects).Error; err != nil {
+		return 0, err
+	}
+	if len(objects) == 0 {
+		return 0, nil
+	}
+	if s.backend == nil {
+		return 0, ErrDisabled
+	}
+	var freed int64
+	for _, object := range objects {
+		if err := s.backend.Delete(ctx, object.Key); err != nil {
+			return freed, err
+		}
+		if err := s.conn().Delete(&object).Error; err != nil {
+			return freed, err
+		}
+		freed += object.Size
+	}
+	return freed, nil
+}
+
+// OffloadPending stores up to one batch of completed jobs not stored yet and
+// returns how many it stored
+func (s *Service) OffloadPending(ctx context.Context) (int, error) {
+	if s.backend == nil {
+		return 0, nil
+	}
+	query := s.conn().Model(&models.TranscriptionJob{}).
+		Where("status = ? AND offloaded_at IS NULL AND encrypted = ? AND id NOT LIKE 'track_%'", models.StatusCompleted, false)
+	if waiting := s.waitingIDs(); len(waiting) > 0 {
+		query = query.Where("id NOT IN ?", waiting)
+	}
+	var jobIDs []string
+	if err := query.Order("updated_at ASC").Limit(s.batchSize).Pluck("id", &jobIDs).Error; err != nil {
+		return 0, err
+	}
+	offloaded := 0
+	for _, jobID := range jobIDs {
+		if err := s.Offload(ctx, jobID); err != nil {
+			s.retryMu.Lock()
+			s.retryAt[jobID] = s.clock.Now().Add(s.retryDelay)
+			s.retryMu.Unlock()
+			logger.Warn("Failed to store job media", "job_id", jobID, "error", err)
+			continue
+		}
+		offloaded++
+	}
+	return offloaded, nil
+}
+
+// waitingIDs returns the jobs that failed to be stored too recently to retry
+func (s *Service) waitingIDs() []string {
+	s.retryMu.Lock()
+	defer s.retryMu.Unlock()
+	now := s.clock.Now()
+	ids := make([]string, 0, len(s.retryAt))
+	for id, at := range s.retryAt {
+		if now.Before(at) {
+			ids = append(ids, id)
+		} else {
+			delete(s.retryAt, id)
+		}
+	}
+	return ids
+}
+
+// Run stores completed jobs straight away and then every interval until
+// stop is closed
+func (s *Service) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := s.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if _, err := s.OffloadPending(context.Background()); err != nil {
+			logger.Warn("Failed to store job media", "error", err)
+		}
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+		}
+	}
+}
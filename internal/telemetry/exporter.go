@@ -0,0 +1,318 @@
+package telemetry
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+const (
+	defaultServiceName   = "synthezia"
+	defaultBatchSize     = 512
+	defaultFlushInterval = 5 * time.Second
+	spanQueueSize        = 4096
+)
+
+// Config controls the OTLP exporter
+type Config struct {
+	// Endpoint is the collector base URL (e.g. http://localhost:4318) or a
+	// full /v1/traces URL. Tracing is disabled when empty.
+	Endpoint    string
+	ServiceName string
+	// Headers are sent with every export request, e.g. for collector auth
+	Headers       map[string]string
+	BatchSize     int
+	FlushInterval time.Duration
+}
+
+// ParseHeaders parses the OTEL_EXPORTER_OTLP_HEADERS "key=value,key2=value2" format
+func ParseHeaders(spec string) map[string]string {
+	headers := map[string]string{}
+	for _, pair := range strings.Split(spec, ",") {
+		key, value, ok := strings.Cut(pair, "=")
+		if !ok || strings.TrimSpace(key) == "" {
+			continue
+		}
+		headers[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return headers
+}
+
+// Exporter batches finished spans and posts them as OTLP/HTTP JSON
+type Exporter struct {
+	endpoint      string
+	serviceName   string
+	headers       map[string]string
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+
+	spans   chan *Span
+	flushCh chan chan struct{}
+	done    chan struct{}
+	wg      sync.WaitGroup
+	once    sync.Once
+}
+
+// NewExporter creates and starts an exporter
+func NewExporter(cfg Config) (*Exporter, error) {
+	endpoint := strings.TrimRight(cfg.Endpoint, "/")
+	if !strings.HasPrefix(endpoint, "http://") && !strings.HasPrefix(endpoint, "https://") {
+		return nil, fmt.Errorf("invalid OTLP endpoint %q: must be an http(s) URL", cfg.Endpoint)
+	}
+	if !strings.HasSuffix(endpoint, "/v1/traces") {
+		endpoint += "/v1/traces"
+	}
+
+	e := &Exporter{
+		endpoint:      endpoint,
+		serviceName:   cfg.ServiceName,
+		headers:       cfg.Headers,
+		batchSize:     cfg.BatchSize,
+		flushInterval: cfg.FlushInterval,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		spans:         make(chan *Span, spanQueueSize),
+		flushCh:       make(chan chan struct{}),
+		done:          make(chan struct{}),
+	}
+	if e.serviceName == "" {
+		e.serviceName = defaultServiceName
+	}
+	if e.batchSize <= 0 {
+		e.batchSize = defaultBatchSize
+	}
+	if e.flushInterval <= 0 {
+		e.flushInterval = defaultFlushInterval
+	}
+
+	e.wg.Add(1)
+	go e.run()
+	return e, nil
+}
+
+// enqueue hands a finished span to the export loop, dropping it if the queue is full
+func (e *Exporter) enqueue(span *Span) {
+	select {
+	case e.spans <- span:
+	default:
+		logger.Debug("Dropping span, export queue full", "span", span.name)
+	}
+}
+
+// Flush exports all queued spans
+func (e *Exporter) Flush() {
+	reply := make(chan struct{})
+	select {
+	case e.flushCh <- reply:
+		<-reply
+	case <-e.done:
+	}
+}
+
+// Shutdown flushes remaining spans and stops the export loop
+func (e *Exporter) Shutdown(ctx context.Context) error {
+	e.once.Do(func() { close(e.done) })
+	finished := make(chan struct{})
+	go func() {
+		e.wg.Wait()
+		close(finished)
+	}()
+	select {
+	case <-finished:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (e *Exporter) run() {
+	defer e.wg.Done()
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, e.batchSize)
+	drain := func() {
+		for {
+			select {
+			case span := <-e.spans:
+				batch = append(batch, span)
+			default:
+				return
+			}
+		}
+	}
+	flush := func() {
+		if len(batch) > 0 {
+			e.export(batch)
+			batch = batch[:0]
+		}
+	}
+
+	for {
+		select {
+		case span := <-e.spans:
+			batch = append(batch, span)
+			if len(batch) >= e.batchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		case reply := <-e.flushCh:
+			drain()
+			flush()
+			close(reply)
+		case <-e.done:
+			drain()
+			flush()
+			return
+		}
+	}
+}
+
+func (e *Exporter) export(batch []*Span) {
+	body, err := json.Marshal(e.buildRequest(batch))
+	if err != nil {
+		logger.Warn("Failed to encode spans", "error", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		logger.Warn("Failed to build span export request", "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range e.headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		logger.Warn("Failed to export spans", "endpoint", e.endpoint, "spans", len(batch), "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logger.Warn("Span export rejected", "endpoint", e.endpoint, "status", resp.StatusCode)
+	}
+}
+
+// OTLP/JSON payload types (opentelemetry-proto, ExportTraceServiceRequest)
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpKeyValue `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string         `json:"traceId"`
+	SpanID            string         `json:"spanId"`
+	ParentSpanID      string         `json:"parentSpanId,omitempty"`
+	Name              string         `json:"name"`
+	Kind              int            `json:"kind"`
+	StartTimeUnixNano string         `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string         `json:"endTimeUnixNano"`
+	Attributes        []otlpKeyValue `json:"attributes,omitempty"`
+	Status            otlpStatus     `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 0 unset, 1 ok, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpKeyValue struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string  `json:"stringValue,omitempty"`
+	IntValue    *string  `json:"intValue,omitempty"`
+	DoubleValue *float64 `json:"doubleValue,omitempty"`
+	BoolValue   *bool    `json:"boolValue,omitempty"`
+}
+
+func (e *Exporter) buildRequest(batch []*Span) otlpRequest {
+	spans := make([]otlpSpan, 0, len(batch))
+	for _, span := range batch {
+		spans = append(spans, span.toOTLP())
+	}
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource: otlpResource{Attributes: []otlpKeyValue{
+			toOTLPAttribute(String("service.name", e.serviceName)),
+		}},
+		ScopeSpans: []otlpScopeSpans{{
+			Scope: otlpScope{Name: "synthezia/internal/telemetry"},
+			Spans: spans,
+		}},
+	}}}
+}
+
+func (s *Span) toOTLP() otlpSpan {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	out := otlpSpan{
+		TraceID:           s.traceID.String(),
+		SpanID:            s.spanID.String(),
+		Name:              s.name,
+		Kind:              int(s.kind),
+		StartTimeUnixNano: strconv.FormatInt(s.start.UnixNano(), 10),
+		EndTimeUnixNano:   strconv.FormatInt(s.end.UnixNano(), 10),
+	}
+	if s.parentID.IsValid() {
+		out.ParentSpanID = s.parentID.String()
+	}
+	for _, attr := range s.attributes {
+		out.Attributes = append(out.Attributes, toOTLPAttribute(attr))
+	}
+	if s.statusError {
+		out.Status = otlpStatus{Code: 2, Message: s.statusMsg}
+	}
+	return out
+}
+
+func toOTLPAttribute(attr Attribute) otlpKeyValue {
+	kv := otlpKeyValue{Key: attr.Key}
+	switch v := attr.Value.(type) {
+	case string:
+		kv.Value.StringValue = &v
+	case int64:
+		s := strconv.FormatInt(v, 10)
+		kv.Value.IntValue = &s
+	case float64:
+		kv.Value.DoubleValue = &v
+	case bool:
+		kv.Value.BoolValue = &v
+	default:
+		s := fmt.Sprint(v)
+		kv.Value.StringValue = &s
+	}
+	return kv
+}
@@ -0,0 +1,49 @@
+package telemetry
+
+import (
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// TraceIDHeader returns the request's trace ID so clients can quote it in bug reports
+const TraceIDHeader = "X-Trace-Id"
+
+// GinMiddleware creates a server span for every HTTP request, continuing any
+// trace passed in a W3C traceparent header
+func GinMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !Enabled() {
+			c.Next()
+			return
+		}
+
+		route := c.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+
+		ctx := ContextWithTraceparent(c.Request.Context(), c.GetHeader("traceparent"))
+		ctx, span := Start(ctx, c.Request.Method+" "+route, SpanKindServer,
+			String("http.request.method", c.Request.Method),
+			String("http.route", route),
+			String("url.path", c.Request.URL.Path),
+			String("client.address", c.ClientIP()),
+			String("user_agent.original", c.Request.UserAgent()),
+		)
+		defer span.End()
+
+		c.Request = c.Request.WithContext(ctx)
+		c.Header(TraceIDHeader, span.TraceID())
+
+		c.Next()
+
+		status := c.Writer.Status()
+		span.SetAttributes(Int("http.response.status_code", status))
+		if status >= 500 {
+			span.RecordError(fmt.Errorf("HTTP %d", status))
+		} else if len(c.Errors) > 0 {
+			span.RecordError(c.Errors.Last())
+		}
+	}
+}
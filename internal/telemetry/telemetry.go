@@ -0,0 +1,284 @@
+// Package telemetry provides lightweight distributed tracing. Spans follow the
+// OpenTelemetry data model, propagate via W3C traceparent headers and are
+// exported to an OTLP/HTTP collector. Without a configured OTLP endpoint
+// tracing is off: Start returns a nil span and every span method is a no-op.
+package telemetry
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"synthezia/pkg/logger"
+)
+
+// SpanKind mirrors the OTLP span kinds
+type SpanKind int
+
+const (
+	SpanKindInternal SpanKind = 1
+	SpanKindServer   SpanKind = 2
+	SpanKindClient   SpanKind = 3
+	SpanKindProducer SpanKind = 4
+	SpanKindConsumer SpanKind = 5
+)
+
+// TraceID identifies a trace
+type TraceID [16]byte
+
+// SpanID identifies a span within a trace
+type SpanID [8]byte
+
+func (t TraceID) String() string { return hex.EncodeToString(t[:]) }
+func (s SpanID) String() string  { return hex.EncodeToString(s[:]) }
+
+// IsValid reports whether the ID is non-zero
+func (t TraceID) IsValid() bool { return t != TraceID{} }
+
+// IsValid reports whether the ID is non-zero
+func (s SpanID) IsValid() bool { return s != SpanID{} }
+
+// Attribute is a key/value pair attached to a span
+type Attribute struct {
+	Key   string
+	Value interface{}
+}
+
+// String creates a string attribute
+func String(key, value string) Attribute { return Attribute{Key: key, Value: value} }
+
+// Int creates an integer attribute
+func Int(key string, value int) Attribute { return Attribute{Key: key, Value: int64(value)} }
+
+// Float creates a floating point attribute
+func Float(key string, value float64) Attribute { return Attribute{Key: key, Value: value} }
+
+// Bool creates a boolean attribute
+func Bool(key string, value bool) Attribute { return Attribute{Key: key, Value: value} }
+
+// Span is a single timed operation. A nil *Span is valid and records nothing.
+type Span struct {
+	mu          sync.Mutex
+	name        string
+	kind        SpanKind
+	traceID     TraceID
+	spanID      SpanID
+	parentID    SpanID
+	start       time.Time
+	end         time.Time
+	attributes  []Attribute
+	statusError bool
+	statusMsg   string
+	ended       bool
+}
+
+// SetAttributes adds attributes to the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attributes = append(s.attributes, attrs...)
+}
+
+// RecordError marks the span as failed
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.statusError = true
+	s.statusMsg = err.Error()
+}
+
+// End finishes the span and queues it for export
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if exp := currentExporter(); exp != nil {
+		exp.enqueue(s)
+	}
+}
+
+// TraceID returns the hex trace ID, or "" for a nil span
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return s.traceID.String()
+}
+
+// SpanID returns the hex span ID, or "" for a nil span
+func (s *Span) SpanID() string {
+	if s == nil {
+		return ""
+	}
+	return s.spanID.String()
+}
+
+// remoteParent is a span context received from another process
+type remoteParent struct {
+	traceID TraceID
+	spanID  SpanID
+}
+
+type spanContextKey struct{}
+type remoteContextKey struct{}
+
+// ContextWithSpan returns a context carrying the span
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanContextKey{}, span)
+}
+
+// SpanFromContext returns the active span, or nil
+func SpanFromContext(ctx context.Context) *Span {
+	if ctx == nil {
+		return nil
+	}
+	span, _ := ctx.Value(spanContextKey{}).(*Span)
+	return span
+}
+
+var exporter atomic.Pointer[Exporter]
+
+func currentExporter() *Exporter {
+	return exporter.Load()
+}
+
+// Enabled reports whether spans are being recorded
+func Enabled() bool {
+	return currentExporter() != nil
+}
+
+// Start begins a span as a child of the span in ctx (or of a remote parent
+// extracted from a traceparent header). When tracing is disabled it returns
+// ctx unchanged and a nil span.
+func Start(ctx context.Context, name string, kind SpanKind, attrs ...Attribute) (context.Context, *Span) {
+	if !Enabled() {
+		return ctx, nil
+	}
+
+	span := &Span{
+		name:       name,
+		kind:       kind,
+		spanID:     newSpanID(),
+		start:      time.Now(),
+		attributes: attrs,
+	}
+
+	if parent := SpanFromContext(ctx); parent != nil {
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+	} else if remote, ok := ctx.Value(remoteContextKey{}).(remoteParent); ok {
+		span.traceID = remote.traceID
+		span.parentID = remote.spanID
+	} else {
+		span.traceID = newTraceID()
+	}
+
+	return ContextWithSpan(ctx, span), span
+}
+
+// Traceparent formats the span as a W3C traceparent header value
+func Traceparent(span *Span) string {
+	if span == nil {
+		return ""
+	}
+	return fmt.Sprintf("00-%s-%s-01", span.traceID, span.spanID)
+}
+
+// ContextWithTraceparent attaches a remote parent parsed from a W3C
+// traceparent header; invalid headers are ignored
+func ContextWithTraceparent(ctx context.Context, header string) context.Context {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) != 4 || parts[0] != "00" {
+		return ctx
+	}
+	traceBytes, err := hex.DecodeString(parts[1])
+	if err != nil || len(traceBytes) != 16 {
+		return ctx
+	}
+	spanBytes, err := hex.DecodeString(parts[2])
+	if err != nil || len(spanBytes) != 8 {
+		return ctx
+	}
+	var remote remoteParent
+	copy(remote.traceID[:], traceBytes)
+	copy(remote.spanID[:], spanBytes)
+	if !remote.traceID.IsValid() || !remote.spanID.IsValid() {
+		return ctx
+	}
+	return context.WithValue(ctx, remoteContextKey{}, remote)
+}
+
+// LogAttrs returns trace_id/span_id logging attributes for the active span
+func LogAttrs(ctx context.Context) []any {
+	span := SpanFromContext(ctx)
+	if span == nil {
+		return nil
+	}
+	return []any{"trace_id", span.TraceID(), "span_id", span.SpanID()}
+}
+
+// Init starts exporting spans to the configured OTLP endpoint. It is a no-op
+// when cfg.Endpoint is empty.
+func Init(cfg Config) error {
+	if cfg.Endpoint == "" {
+		return nil
+	}
+	exp, err := NewExporter(cfg)
+	if err != nil {
+		return err
+	}
+	if old := exporter.Swap(exp); old != nil {
+		old.Shutdown(context.Background())
+	}
+	logger.SetContextAttrs(LogAttrs)
+	logger.Info("Tracing enabled", "endpoint", exp.endpoint, "service", exp.serviceName)
+	return nil
+}
+
+// Shutdown flushes pending spans and disables tracing
+func Shutdown(ctx context.Context) error {
+	exp := exporter.Swap(nil)
+	if exp == nil {
+		return nil
+	}
+	return exp.Shutdown(ctx)
+}
+
+func newTraceID() TraceID {
+	var id TraceID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
+
+func newSpanID() SpanID {
+	var id SpanID
+	for !id.IsValid() {
+		rand.Read(id[:])
+	}
+	return id
+}
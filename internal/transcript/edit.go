@@ -0,0 +1,205 @@
+package transcript
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"synthezia/internal/transcription/interfaces"
+)
+
+// ErrInvalidEdit is matched by the errors of edits that cannot be applied
+var ErrInvalidEdit = errors.New("invalid transcript edit")
+
+// SegmentEdit changes one segment of a transcript. Segments are numbered as
+// in the structured format, in order of start time. Fields left nil keep
+// their value; an empty speaker clears it.
+type SegmentEdit struct {
+	ID      int      `json:"id"`
+	Text    *string  `json:"text,omitempty"`
+	Speaker *string  `json:"speaker,omitempty"`
+	Start   *float64 `json:"start,omitempty"`
+	End     *float64 `json:"end,omitempty"`
+}
+
+// ApplyEdits edits the segments of a transcript in place, leaving them in
+// order of start time. Words follow their segment: they are stretched to
+// new timing and take a new speaker, and rewritten text gets new words
+// spread evenly over the segment, unscored since nothing aligned them.
+// Nothing is changed when an edit is invalid.
+func ApplyEdits(result *interfaces.TranscriptResult, edits []SegmentEdit) error {
+	original := sortedSegments(result)
+	segments := append([]interfaces.TranscriptSegment(nil), original...)
+
+	edited := make(map[int]bool, len(edits))
+	for _, edit := range edits {
+		if edit.ID < 0 || edit.ID >= len(segments) {
+			return fmt.Errorf("%w: segment %d does not exist", ErrInvalidEdit, edit.ID)
+		}
+		if edited[edit.ID] {
+			return fmt.Errorf("%w: segment %d is edited twice", ErrInvalidEdit, edit.ID)
+		}
+		edited[edit.ID] = true
+
+		segment := &segments[edit.ID]
+		if edit.Text != nil {
+			text := strings.TrimSpace(*edit.Text)
+			if text == "" {
+				return fmt.Errorf("%w: segment %d cannot be empty", ErrInvalidEdit, edit.ID)
+			}
+			segment.Text = text
+		}
+		if edit.Speaker != nil {
+			segment.Speaker = nil
+			if speaker := strings.TrimSpace(*edit.Speaker); speaker != "" {
+				segment.Speaker = &speaker
+			}
+		}
+		if edit.Start != nil {
+			segment.Start = *edit.Start
+		}
+		if edit.End != nil {
+			segment.End = *edit.End
+		}
+		if segment.Start < 0 || segment.End <= segment.Start {
+			return fmt.Errorf("%w: segment %d must end after it starts", ErrInvalidEdit, edit.ID)
+		}
+	}
+	for i := 1; i < len(segments); i++ {
+		if segments[i].Start < segments[i-1].Start {
+			return fmt.Errorf("%w: segment %d would start before segment %d", ErrInvalidEdit, i, i-1)
+		}
+	}
+
+	// Words go with the segment they fell in before the edits
+	var words []interfaces.TranscriptWord
+	byOwner := make(map[int][]interfaces.TranscriptWord)
+	for _, word := range result.WordSegments {
+		owner := -1
+		if len(original) > 0 {
+			owner = segmentOf(original, (word.Start+word.End)/2)
+		}
+		if edited[owner] {
+			byOwner[owner] = append(byOwner[owner], word)
+		} else {
+			words = append(words, word)
+		}
+	}
+	for id := range edited {
+		words = append(words, editedWords(original[id], segments[id], byOwner[id])...)
+	}
+	sort.SliceStable(words, func(i, j int) bool { return words[i].Start < words[j].Start })
+
+	texts := make([]string, 0, len(segments))
+	for _, segment := range segments {
+		if text := strings.TrimSpace(segment.Text); text != "" {
+			texts = append(texts, text)
+		}
+	}
+	result.Segments = segments
+	if result.WordSegments != nil || len(words) > 0 {
+		result.WordSegments = words
+	}
+	result.Text = strings.Join(texts, " ")
+	return nil
+}
+
+// editedWords moves the words of a segment to its edited version
+func editedWords(before, after interfaces.TranscriptSegment, words []interfaces.TranscriptWord) []interfaces.TranscriptWord {
+	if strings.TrimSpace(after.Text) != strings.TrimSpace(before.Text) {
+		fields := strings.Fields(after.Text)
+		step := (after.End - after.Start) / float64(len(fields))
+		spread := make([]interfaces.TranscriptWord, len(fields))
+		for i, field := range fields {
+			spread[i] = interfaces.TranscriptWord{
+				Start:   after.Start + float64(i)*step,
+				End:     after.Start + float64(i+1)*step,
+				Word:    field,
+				Speaker: after.Speaker,
+			}
+		}
+		return spread
+	}
+
+	scale := 1.0
+	if span := before.End - before.Start; span > 0 {
+		scale = (after.End - after.Start) / span
+	}
+	moved := make([]interfaces.TranscriptWord, len(words))
+	for i, word := range words {
+		word.Start = after.Start + (word.Start-before.Start)*scale
+		word.End = after.Start + (word.End-before.Start)*scale
+		word.Speaker = after.Speaker
+		moved[i] = word
+	}
+	return moved
+}
+
+// SegmentDiff is how one segment differs between two versions of a transcript
+type SegmentDiff struct {
+	ID     int          `json:"id"`
+	Change string       `json:"change"`           // modified, added or removed
+	Fields []string     `json:"fields,omitempty"` // What a modification changed: text, speaker, start, end
+	Before *DiffSegment `json:"before,omitempty"`
+	After  *DiffSegment `json:"after,omitempty"`
+}
+
+// DiffSegment is a segment as it reads in one version
+type DiffSegment struct {
+	Start   float64 `json:"start"`
+	End     float64 `json:"end"`
+	Text    string  `json:"text"`
+	Speaker *string `json:"speaker"`
+}
+
+// Diff lists the segments that differ between two versions of a transcript,
+// pairing them up by their position in order of start time
+func Diff(from, to *interfaces.TranscriptResult) []SegmentDiff {
+	before, after := sortedSegments(from), sortedSegments(to)
+	diffs := []SegmentDiff{}
+	for i := 0; i < len(before) || i < len(after); i++ {
+		switch {
+		case i >= len(after):
+			diffs = append(diffs, SegmentDiff{ID: i, Change: "removed", Before: diffSegment(before[i])})
+		case i >= len(before):
+			diffs = append(diffs, SegmentDiff{ID: i, Change: "added", After: diffSegment(after[i])})
+		default:
+			b, a := diffSegment(before[i]), diffSegment(after[i])
+			var fields []string
+			if b.Text != a.Text {
+				fields = append(fields, "text")
+			}
+			if stringValue(b.Speaker) != stringValue(a.Speaker) {
+				fields = append(fields, "speaker")
+			}
+			if b.Start != a.Start {
+				fields = append(fields, "start")
+			}
+			if b.End != a.End {
+				fields = append(fields, "end")
+			}
+			if len(fields) > 0 {
+				diffs = append(diffs, SegmentDiff{ID: i, Change: "modified", Fields: fields, Before: b, After: a})
+			}
+		}
+	}
+	return diffs
+}
+
+func sortedSegments(result *interfaces.TranscriptResult) []interfaces.TranscriptSegment {
+	segments := append([]interfaces.TranscriptSegment(nil), result.Segments...)
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	return segments
+}
+
+func diffSegment(segment interfaces.TranscriptSegment) *DiffSegment {
+	return &DiffSegment{Start: segment.Start, End: segment.End, Text: strings.TrimSpace(segment.Text), Speaker: segment.Speaker}
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
@@ -0,0 +1,149 @@
+// Package transcript defines the structured transcript format served to
+// downstream tools, independent of how transcripts are stored, and the
+// segment edits and diffs made on stored transcripts
+package transcript
+
+import (
+	"math"
+	"sort"
+	"strings"
+
+	"synthezia/internal/transcription/interfaces"
+)
+
+// SchemaVersion is the version of the structured format. Fields may be added
+// within a major version; removing or changing one bumps it.
+const SchemaVersion = "1.0"
+
+// Structured is a transcript as segments of timed, scored words
+type Structured struct {
+	SchemaVersion string    `json:"schema_version"`
+	JobID         string    `json:"job_id"`
+	Language      string    `json:"language,omitempty"`
+	Duration      float64   `json:"duration"` // Seconds, to the end of the last segment
+	Text          string    `json:"text"`
+	Speakers      []string  `json:"speakers"` // In order of first appearance
+	Segments      []Segment `json:"segments"`
+}
+
+// Segment is a stretch of speech, usually a sentence
+type Segment struct {
+	ID         int      `json:"id"`
+	Start      float64  `json:"start"`
+	End        float64  `json:"end"`
+	Text       string   `json:"text"`
+	Speaker    *string  `json:"speaker"`    // Null when speakers were not identified
+	Confidence *float64 `json:"confidence"` // Mean confidence of the scored words, null without any
+	Words      []Word   `json:"words"`
+}
+
+// Word is a word of a segment with its timing
+type Word struct {
+	Start      float64  `json:"start"`
+	End        float64  `json:"end"`
+	Word       string   `json:"word"`
+	Confidence *float64 `json:"confidence"` // 0 to 1; null for words the aligner could not score
+	Speaker    *string  `json:"speaker"`
+}
+
+// Structure converts a stored transcript to the structured format. Each word
+// goes to the segment its midpoint falls in, or the nearest one. Speakers
+// are renamed as in speakerNames, keyed by the original label.
+func Structure(jobID string, result *interfaces.TranscriptResult, speakerNames map[string]string) *Structured {
+	rename := func(speaker *string) *string {
+		if speaker == nil || *speaker == "" {
+			return nil
+		}
+		name := *speaker
+		if custom, ok := speakerNames[name]; ok && custom != "" {
+			name = custom
+		}
+		return &name
+	}
+
+	segments := append([]interfaces.TranscriptSegment(nil), result.Segments...)
+	sort.SliceStable(segments, func(i, j int) bool { return segments[i].Start < segments[j].Start })
+	structured := &Structured{
+		SchemaVersion: SchemaVersion,
+		JobID:         jobID,
+		Language:      result.Language,
+		Speakers:      []string{},
+		Segments:      make([]Segment, len(segments)),
+	}
+	seen := make(map[string]bool)
+	var texts []string
+	for i, segment := range segments {
+		speaker := rename(segment.Speaker)
+		if speaker != nil && !seen[*speaker] {
+			seen[*speaker] = true
+			structured.Speakers = append(structured.Speakers, *speaker)
+		}
+		text := strings.TrimSpace(segment.Text)
+		texts = append(texts, text)
+		structured.Segments[i] = Segment{
+			ID:      i,
+			Start:   segment.Start,
+			End:     segment.End,
+			Text:    text,
+			Speaker: speaker,
+			Words:   []Word{},
+		}
+		structured.Duration = math.Max(structured.Duration, segment.End)
+	}
+	structured.Text = strings.Join(texts, " ")
+	if len(segments) == 0 {
+		return structured
+	}
+
+	for _, word := range result.WordSegments {
+		segment := &structured.Segments[segmentOf(segments, (word.Start+word.End)/2)]
+		w := Word{
+			Start:   word.Start,
+			End:     word.End,
+			Word:    strings.TrimSpace(word.Word),
+			Speaker: rename(word.Speaker),
+		}
+		if word.Score > 0 {
+			score := word.Score
+			w.Confidence = &score
+		}
+		if w.Speaker == nil {
+			w.Speaker = segment.Speaker
+		}
+		segment.Words = append(segment.Words, w)
+	}
+	for i := range structured.Segments {
+		segment := &structured.Segments[i]
+		sort.SliceStable(segment.Words, func(a, b int) bool { return segment.Words[a].Start < segment.Words[b].Start })
+		total, scored := 0.0, 0
+		for _, word := range segment.Words {
+			if word.Confidence != nil {
+				total += *word.Confidence
+				scored++
+			}
+		}
+		if scored > 0 {
+			mean := math.Round(total/float64(scored)*1000) / 1000
+			segment.Confidence = &mean
+		}
+	}
+	return structured
+}
+
+// segmentOf returns the index of the segment holding a time, or of the
+// nearest one when it falls between segments
+func segmentOf(segments []interfaces.TranscriptSegment, at float64) int {
+	best, bestDistance := 0, math.Inf(1)
+	for i, segment := range segments {
+		distance := 0.0
+		if at < segment.Start {
+			distance = segment.Start - at
+		} else if at > segment.End {
+			distance = at - segment.End
+		}
+		if distance < bestDistance {
+			best, bestDistance = i, distance
+		}
+	}
+	return best
+}
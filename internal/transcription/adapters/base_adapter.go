@@ -16,6 +16,10 @@ import (
 	"synthezia/pkg/logger"
 )
 
+// processWaitDelay bounds how long a cancelled model run may take to close
+// its output before it is abandoned
+const processWaitDelay = 10 * time.Second
+
 // Environment readiness cache to avoid repeated expensive UV checks
 var (
 	envCacheMutex sync.RWMutex
@@ -550,6 +550,7 @@ func (c *CanaryAdapter) Transcribe(ctx context.Context, input interfaces.AudioIn
 	// Execute Canary
 	cmd := exec.CommandContext(ctx, "uv", args...)
 	cmd.Env = append(os.Environ(), "PYTHONUNBUFFERED=1")
+	killGroupOnCancel(cmd)
 
 	logger.Info("Executing Canary command", "args", strings.Join(args, " "))
 	
@@ -488,6 +488,7 @@ func (p *ParakeetAdapter) Transcribe(ctx context.Context, input interfaces.Audio
 	// Execute Parakeet
 	cmd := exec.CommandContext(ctx, "uv", args...)
 	cmd.Env = append(os.Environ(), "PYTHONUNBUFFERED=1")
+	killGroupOnCancel(cmd)
 
 	logger.Info("Executing Parakeet command", "args", strings.Join(args, " "))
 	
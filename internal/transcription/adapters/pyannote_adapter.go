@@ -597,6 +597,7 @@ func (p *PyAnnoteAdapter) Diarize(ctx context.Context, input interfaces.AudioInp
 	// Execute PyAnnote
 	cmd := exec.CommandContext(ctx, "uv", args...)
 	cmd.Env = append(os.Environ(), "PYTHONUNBUFFERED=1")
+	killGroupOnCancel(cmd)
 
 	logger.Info("Executing PyAnnote command", "args", strings.Join(args, " "))
 	
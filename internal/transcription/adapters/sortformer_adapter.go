@@ -668,6 +668,7 @@ func (s *SortformerAdapter) Diarize(ctx context.Context, input interfaces.AudioI
 	// Execute Sortformer
 	cmd := exec.CommandContext(ctx, "uv", args...)
 	cmd.Env = append(os.Environ(), "PYTHONUNBUFFERED=1")
+	killGroupOnCancel(cmd)
 
 	logger.Info("Executing Sortformer command", "args", strings.Join(args, " "))
 	
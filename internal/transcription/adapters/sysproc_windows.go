@@ -0,0 +1,12 @@
+//go:build windows
+// +build windows
+
+package adapters
+
+import "os/exec"
+
+// killGroupOnCancel only bounds the wait for output on Windows; cancelling
+// the context kills uv but not the process tree under it.
+func killGroupOnCancel(cmd *exec.Cmd) {
+	cmd.WaitDelay = processWaitDelay
+}
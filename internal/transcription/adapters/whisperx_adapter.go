@@ -1,16 +1,22 @@
 package adapters
 
 import (
+	"bufio"
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
 
+	"synthezia/internal/faults"
+	"synthezia/internal/telemetry"
 	"synthezia/internal/transcription/interfaces"
 	"synthezia/internal/transcription/registry"
 	"synthezia/pkg/logger"
@@ -387,7 +393,16 @@ func (w *WhisperXAdapter) uvSyncWhisperX(whisperxPath string) error {
 }
 
 // Transcribe processes audio using WhisperX
-func (w *WhisperXAdapter) Transcribe(ctx context.Context, input interfaces.AudioInput, params map[string]interface{}, procCtx interfaces.ProcessingContext) (*interfaces.TranscriptResult, error) {
+func (w *WhisperXAdapter) Transcribe(ctx context.Context, input interfaces.AudioInput, params map[string]interface{}, procCtx interfaces.ProcessingContext) (result *interfaces.TranscriptResult, err error) {
+	ctx, span := telemetry.Start(ctx, "whisperx.transcribe", telemetry.SpanKindInternal,
+		telemetry.String("job.id", procCtx.JobID),
+		telemetry.String("whisperx.model", w.GetStringParameter(params, "model")),
+		telemetry.Float("audio.duration_seconds", input.Duration.Seconds()))
+	defer func() {
+		span.RecordError(err)
+		span.End()
+	}()
+
 	startTime := time.Now()
 	w.LogProcessingStart(input, procCtx)
 	defer func() {
@@ -404,6 +419,10 @@ func (w *WhisperXAdapter) Transcribe(ctx context.Context, input interfaces.Audio
 		return nil, fmt.Errorf("invalid parameters: %w", err)
 	}
 
+	if err := faults.Inject(faults.WhisperXOOM); err != nil {
+		return nil, fmt.Errorf("WhisperX execution failed: %w", err)
+	}
+
 	// Create temporary directory
 	tempDir, err := w.CreateTempDirectory(procCtx)
 	if err != nil {
@@ -420,20 +439,21 @@ func (w *WhisperXAdapter) Transcribe(ctx context.Context, input interfaces.Audio
 	// Execute WhisperX
 	cmd := exec.CommandContext(ctx, "uv", args...)
 	cmd.Env = append(os.Environ(), "PYTHONUNBUFFERED=1")
+	killGroupOnCancel(cmd)
 
-	logger.Info("Executing WhisperX command", "args", strings.Join(args, " "))
+	logger.InfoContext(ctx, "Executing WhisperX command", "args", strings.Join(args, " "))
 	
-	output, err := cmd.CombinedOutput()
+	output, err := runWhisperX(cmd, input.Duration, procCtx)
 	if ctx.Err() == context.Canceled {
 		return nil, fmt.Errorf("transcription was cancelled")
 	}
 	if err != nil {
-		logger.Error("WhisperX execution failed", "output", string(output), "error", err)
+		logger.ErrorContext(ctx, "WhisperX execution failed", "output", string(output), "error", err)
 		return nil, fmt.Errorf("WhisperX execution failed: %w", err)
 	}
 
 	// Parse result
-	result, err := w.parseResult(tempDir, input, params)
+	result, err = w.parseResult(tempDir, input, params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to parse result: %w", err)
 	}
@@ -450,6 +470,65 @@ func (w *WhisperXAdapter) Transcribe(ctx context.Context, input interfaces.Audio
 	return result, nil
 }
 
+// whisperxSegmentLine matches a segment WhisperX prints in verbose mode,
+// such as "Transcript: [12.345 --> 15.678]  Hello there"
+var whisperxSegmentLine = regexp.MustCompile(`\[\s*(\d+(?:\.\d+)?)\s*-->\s*(\d+(?:\.\d+)?)\s*\]\s*(.*)$`)
+
+// runWhisperX runs WhisperX, reporting each segment it prints as progress
+// through the recording, and returns its combined output
+func runWhisperX(cmd *exec.Cmd, duration time.Duration, procCtx interfaces.ProcessingContext) ([]byte, error) {
+	reader, writer := io.Pipe()
+	cmd.Stdout = writer
+	cmd.Stderr = writer
+
+	var output bytes.Buffer
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		scanner := bufio.NewScanner(reader)
+		scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+		for scanner.Scan() {
+			line := scanner.Text()
+			output.WriteString(line)
+			output.WriteByte('\n')
+			if progress, ok := whisperxProgress(line, duration); ok {
+				procCtx.ReportProgress(progress)
+			}
+		}
+		// Keep draining so WhisperX never blocks on a line too long to scan
+		io.Copy(&output, reader)
+	}()
+
+	err := cmd.Run()
+	writer.Close()
+	<-done
+	return output.Bytes(), err
+}
+
+// whisperxProgress reads a segment from a line of WhisperX output
+func whisperxProgress(line string, duration time.Duration) (interfaces.TranscriptionProgress, bool) {
+	match := whisperxSegmentLine.FindStringSubmatch(line)
+	if match == nil {
+		return interfaces.TranscriptionProgress{}, false
+	}
+	start, err := strconv.ParseFloat(match[1], 64)
+	if err != nil {
+		return interfaces.TranscriptionProgress{}, false
+	}
+	end, err := strconv.ParseFloat(match[2], 64)
+	if err != nil {
+		return interfaces.TranscriptionProgress{}, false
+	}
+
+	progress := interfaces.TranscriptionProgress{
+		Segment: &interfaces.TranscriptSegment{Start: start, End: end, Text: strings.TrimSpace(match[3])},
+	}
+	if seconds := duration.Seconds(); seconds > 0 {
+		progress.Percent = min(end/seconds*100, 100)
+	}
+	return progress, true
+}
+
 // buildWhisperXArgs builds the command arguments for WhisperX
 func (w *WhisperXAdapter) buildWhisperXArgs(input interfaces.AudioInput, params map[string]interface{}, outputDir string) ([]string, error) {
 	whisperxPath := filepath.Join(w.envPath, "WhisperX")
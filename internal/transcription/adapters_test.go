@@ -276,6 +276,25 @@ func TestUnifiedTranscriptionService(t *testing.T) {
 	t.Logf("Model status: %+v", status)
 }
 
+func TestFakeModelFamilyNeedsFakeBackend(t *testing.T) {
+	service := NewUnifiedTranscriptionService()
+	params := models.WhisperXParams{ModelFamily: BackendFake}
+
+	// A real server never hands a job to the fake adapter
+	if modelID, _, err := service.selectModels(params); err == nil {
+		t.Errorf("Expected the fake model family to be refused, got %q", modelID)
+	}
+
+	if err := service.SetBackend(BackendFake); err != nil {
+		t.Fatalf("Failed to select the fake backend: %v", err)
+	}
+	params.ModelFamily = "whisper"
+	modelID, _, err := service.selectModels(params)
+	if err != nil || modelID != "fake" {
+		t.Errorf("Expected the fake backend to select the fake adapter, got %q, %v", modelID, err)
+	}
+}
+
 func TestAudioInputCreation(t *testing.T) {
 	service := NewUnifiedTranscriptionService()
 
@@ -0,0 +1,109 @@
+package transcription
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gorm.io/gorm"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+)
+
+// stereoChannels names the tracks a split stereo recording becomes, in
+// channel order; the names are the speaker labels of the transcript
+var stereoChannels = []struct {
+	file    string
+	speaker string
+	pan     float64
+}{
+	{file: "left.flac", speaker: "Left", pan: -1},
+	{file: "right.flac", speaker: "Right", pan: 1},
+}
+
+// SplitJobChannels turns a stereo job whose channels are separate
+// microphones into a two-track multi-track job, one track per channel, so
+// each channel is transcribed alone and the transcripts are interleaved by
+// speaker. The job keeps its original recording for playback. It reports
+// whether the job was split; jobs that are not stereo, or whose channels
+// carry the same signal in auto mode, are left alone.
+func SplitJobChannels(ctx context.Context, ffmpegPath string, job *models.TranscriptionJob) (bool, error) {
+	mode := job.Parameters.SplitChannels
+	if job.IsMultiTrack || (mode != models.SplitChannelsAuto && mode != models.SplitChannelsAlways) {
+		return false, nil
+	}
+	if job.AudioChannels != nil && *job.AudioChannels != 2 {
+		logger.Info("Not splitting channels of a recording that is not stereo", "job_id", job.ID, "channels", *job.AudioChannels)
+		return false, nil
+	}
+
+	if mode == models.SplitChannelsAuto || job.AudioChannels == nil {
+		analysis, err := audio.AnalyzeChannels(ctx, ffmpegPath, job.AudioPath)
+		if err != nil {
+			// Mono files have no second channel to analyze
+			logger.Warn("Failed to analyze channels; transcribing as one track", "job_id", job.ID, "error", err)
+			return false, nil
+		}
+		if mode == models.SplitChannelsAuto && !analysis.Distinct() {
+			logger.Info("Channels are not separate microphones; transcribing as one track",
+				"job_id", job.ID, "left_db", analysis.Left, "right_db", analysis.Right, "sum_db", analysis.Sum, "diff_db", analysis.Diff)
+			return false, nil
+		}
+	}
+
+	folder := filepath.Join(filepath.Dir(job.AudioPath), job.ID+"_channels")
+	if err := os.MkdirAll(folder, 0755); err != nil {
+		return false, fmt.Errorf("failed to create channel directory: %w", err)
+	}
+	paths := make([]string, len(stereoChannels))
+	for i, channel := range stereoChannels {
+		paths[i] = filepath.Join(folder, channel.file)
+	}
+	if err := audio.SplitStereo(ctx, ffmpegPath, job.AudioPath, paths[0], paths[1]); err != nil {
+		os.RemoveAll(folder)
+		return false, err
+	}
+
+	err := database.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("transcription_job_id = ?", job.ID).Delete(&models.MultiTrackFile{}).Error; err != nil {
+			return err
+		}
+		for i, channel := range stereoChannels {
+			speaker := channel.speaker
+			track := models.MultiTrackFile{
+				TranscriptionJobID: job.ID,
+				FileName:           channel.file,
+				FilePath:           paths[i],
+				TrackIndex:         i,
+				Gain:               1.0,
+				Pan:                channel.pan,
+				SpeakerName:        &speaker,
+			}
+			if err := tx.Create(&track).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Model(&models.TranscriptionJob{}).Where("id = ?", job.ID).Updates(map[string]interface{}{
+			"is_multi_track":         true,
+			"multi_track_folder":     folder,
+			"merged_audio_path":      job.AudioPath,
+			"merge_status":           "completed",
+			"is_multi_track_enabled": true,
+			"diarize":                false,
+			"diarization":            false,
+		}).Error
+	})
+	if err != nil {
+		os.RemoveAll(folder)
+		return false, fmt.Errorf("failed to record channel tracks: %w", err)
+	}
+	if err := database.DB.Preload("MultiTrackFiles").Where("id = ?", job.ID).First(job).Error; err != nil {
+		return false, fmt.Errorf("failed to reload job: %w", err)
+	}
+	logger.Info("Split stereo recording into one track per channel", "job_id", job.ID, "mode", mode)
+	return true, nil
+}
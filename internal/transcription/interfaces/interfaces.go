@@ -110,6 +110,22 @@ type ProcessingContext struct {
 	OutputDirectory string            `json:"output_directory"`
 	TempDirectory   string            `json:"temp_directory"`
 	Metadata        map[string]string `json:"metadata"`
+
+	// Progress, when set, receives how far the adapter has got while it runs
+	Progress func(TranscriptionProgress) `json:"-"`
+}
+
+// TranscriptionProgress is how far a running transcription has got
+type TranscriptionProgress struct {
+	Percent float64            `json:"percent"`           // 0-100 share of the recording transcribed
+	Segment *TranscriptSegment `json:"segment,omitempty"` // Set when a segment has just been transcribed
+}
+
+// ReportProgress passes progress on to the context's Progress listener, if any
+func (p ProcessingContext) ReportProgress(progress TranscriptionProgress) {
+	if p.Progress != nil {
+		p.Progress(progress)
+	}
 }
 
 // ModelAdapter is the base interface that all model adapters must implement
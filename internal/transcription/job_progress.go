@@ -0,0 +1,96 @@
+package transcription
+
+import (
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// progressInterval is how often a running job's progress is written to the database
+const progressInterval = time.Second
+
+// JobProgressWriter stores how far a job's transcription has got on the
+// job, as the seconds of audio transcribed over the stretch of the
+// recording the job covers
+type JobProgressWriter struct {
+	db         *gorm.DB
+	clock      clock.Clock
+	jobID      string
+	start, end float64 // The stretch of the recording the job covers, in seconds
+	from       float64 // Where this run began, past any checkpoint
+	processed  float64 // Seconds of the stretch transcribed; never goes backwards
+	written    time.Time
+}
+
+// NewJobProgressWriter creates a writer for a job covering start to end of
+// its recording; a nil db uses database.DB at call time
+func NewJobProgressWriter(db *gorm.DB, c clock.Clock, jobID string, start, end float64) *JobProgressWriter {
+	return &JobProgressWriter{db: db, clock: c, jobID: jobID, start: start, end: end, from: start}
+}
+
+func (w *JobProgressWriter) conn() *gorm.DB {
+	if w.db != nil {
+		return w.db
+	}
+	return database.DB
+}
+
+// Begin records where this run starts from, which a resumed job's
+// checkpoint puts past the start
+func (w *JobProgressWriter) Begin(from float64) {
+	w.from = max(from, w.start)
+	w.processed = w.from - w.start
+	w.write()
+}
+
+// Report takes progress from the adapter. A segment's end, timed within
+// the whole recording, gives the audio transcribed; without one, the
+// adapter's percentage of what this run transcribes does.
+func (w *JobProgressWriter) Report(progress interfaces.TranscriptionProgress) {
+	at := w.processed
+	switch {
+	case progress.Segment != nil:
+		at = progress.Segment.End - w.start
+	case progress.Percent > 0 && w.end > w.from:
+		at = w.from - w.start + progress.Percent/100*(w.end-w.from)
+	}
+	if at <= w.processed {
+		return
+	}
+	w.processed = at
+	if w.end > w.start {
+		w.processed = min(at, w.end-w.start)
+	}
+	if w.clock.Since(w.written) >= progressInterval || w.percent() >= 100 {
+		w.write()
+	}
+}
+
+// Finish records the whole stretch as transcribed
+func (w *JobProgressWriter) Finish() {
+	w.processed = max(w.end-w.start, w.processed)
+	w.write()
+}
+
+// percent is the share of the stretch transcribed, 0 while its length is unknown
+func (w *JobProgressWriter) percent() float64 {
+	if w.end <= w.start {
+		return 0
+	}
+	return min(w.processed/(w.end-w.start)*100, 100)
+}
+
+func (w *JobProgressWriter) write() {
+	w.written = w.clock.Now()
+	err := w.conn().Model(&models.TranscriptionJob{}).Where("id = ?", w.jobID).
+		UpdateColumns(map[string]interface{}{"progress": w.percent(), "processed_audio_seconds": w.processed}).Error
+	if err != nil {
+		logger.Warn("Failed to store job progress", "job_id", w.jobID, "error", err)
+	}
+}
@@ -16,6 +16,7 @@ import (
 	"synthezia/internal/database"
 	"synthezia/internal/models"
 	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/fsys"
 	"synthezia/pkg/logger"
 )
 
@@ -483,7 +484,10 @@ func (s *LiveTranscriptionService) persistChunk(sessionID string, sequence int,
 		filename = baseName + ".webm"
 	}
 
-	rawPath := filepath.Join(sessionDir, baseName+"_raw"+filepath.Ext(filename))
+	// The raw upload is only needed for conversion, so it stays in the temp
+	// directory and never appears in the session folder
+	rawPath := fsys.TempPath(s.cfg.TempDir, filepath.Join(sessionDir, baseName+"_raw")) + filepath.Ext(filename)
+	defer os.Remove(rawPath)
 	rawFile, err := os.Create(rawPath)
 	if err != nil {
 		return "", err
@@ -528,22 +532,28 @@ func (s *LiveTranscriptionService) convertToWav(inputPath, outputPath string) er
 	}
 
 	// With stop/start cycling, each chunk should be a complete WebM container
+	tempPath := fsys.TempPath(s.cfg.TempDir, outputPath) + filepath.Ext(outputPath)
+	defer os.Remove(tempPath)
 	cmd := exec.Command("ffmpeg",
 		"-y",
 		"-i", inputPath,
 		"-ar", "16000",
 		"-ac", "1",
 		"-c:a", "pcm_s16le",
-		outputPath,
+		tempPath,
 	)
-	if out, err := cmd.CombinedOutput(); err != nil {
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logger.FFmpegStage(context.Background(), "live_convert", start, "input", inputPath)
+	if err != nil {
 		return fmt.Errorf("ffmpeg convert failed: %v (%s)", err, string(out))
 	}
-	return nil
+	return os.Rename(tempPath, outputPath)
 }
 
 func (s *LiveTranscriptionService) concatChunks(chunks []models.LiveTranscriptionChunk, outputPath string) error {
-	listPath := outputPath + ".txt"
+	listPath := fsys.TempPath(s.cfg.TempDir, outputPath) + ".txt"
+	defer os.Remove(listPath)
 	listFile, err := os.Create(listPath)
 	if err != nil {
 		return err
@@ -564,18 +574,27 @@ func (s *LiveTranscriptionService) concatChunks(chunks []models.LiveTranscriptio
 		}
 	}
 
+	if err := listFile.Close(); err != nil {
+		return err
+	}
+
+	tempPath := fsys.TempPath(s.cfg.TempDir, outputPath) + filepath.Ext(outputPath)
+	defer os.Remove(tempPath)
 	cmd := exec.Command("ffmpeg",
 		"-y",
 		"-f", "concat",
 		"-safe", "0",
 		"-i", listPath,
 		"-c", "copy",
-		outputPath,
+		tempPath,
 	)
-	if out, err := cmd.CombinedOutput(); err != nil {
+	start := time.Now()
+	out, err := cmd.CombinedOutput()
+	logger.FFmpegStage(context.Background(), "live_concat", start, "output", outputPath, "chunks", len(chunks))
+	if err != nil {
 		return fmt.Errorf("ffmpeg concat failed: %v (%s)", err, string(out))
 	}
-	return nil
+	return os.Rename(tempPath, outputPath)
 }
 
 func (s *LiveTranscriptionService) getSessionLock(sessionID string) *sync.Mutex {
@@ -13,6 +13,7 @@ import (
 	"time"
 
 	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
 	"synthezia/internal/models"
 	"synthezia/internal/transcription/interfaces"
 	"synthezia/pkg/logger"
@@ -143,12 +144,13 @@ func (mt *MultiTrackTranscriber) ProcessMultiTrackTranscription(ctx context.Cont
 		}
 
 		// Log individual transcript details for debugging
-		mt.logIndividualTranscript(trackFile.FileName, trackResult, trackFile.Offset)
+		speaker := trackSpeaker(&trackFile)
+		mt.logIndividualTranscript(trackFile.FileName, speaker, trackResult, trackFile.Offset)
 
 		// Create track transcript with metadata
 		trackTranscript := TrackTranscript{
 			FileName: trackFile.FileName,
-			Speaker:  getBaseFileName(trackFile.FileName), // Use filename as speaker name
+			Speaker:  speaker,
 			Offset:   trackFile.Offset,
 			Result:   trackResult,
 		}
@@ -196,10 +198,9 @@ func (mt *MultiTrackTranscriber) ProcessMultiTrackTranscription(ctx context.Cont
 	updates := map[string]interface{}{
 		"transcript":             &mergedTranscriptStr,
 		"individual_transcripts": &individualTranscriptsStr,
-		"status":                 models.StatusCompleted,
 	}
 
-	if err := mt.db.Model(&models.TranscriptionJob{}).Where("id = ?", jobID).Updates(updates).Error; err != nil {
+	if _, err := jobstate.Transition(jobID, models.StatusCompleted, jobstate.WithFields(updates)); err != nil {
 		return fmt.Errorf("failed to save transcription results: %w", err)
 	}
 
@@ -385,12 +386,7 @@ func (mt *MultiTrackTranscriber) TerminateMultiTrackJob(jobID string) error {
 	mt.trackJobsMutex.Unlock()
 	
 	// Update main job status to failed
-	if err := mt.db.Model(&models.TranscriptionJob{}).
-		Where("id = ?", jobID).
-		Updates(map[string]interface{}{
-			"status": models.StatusFailed,
-			"error_message": "Job was terminated by user",
-		}).Error; err != nil {
+	if _, err := jobstate.Transition(jobID, models.StatusFailed, jobstate.WithError("Job was terminated by user")); err != nil {
 		logger.Warn("Failed to update main job status after termination", "job_id", jobID, "error", err)
 	}
 	
@@ -516,6 +512,18 @@ func (mt *MultiTrackTranscriber) mergeTrackTranscripts(trackTranscripts []TrackT
 	return mergedResult, nil
 }
 
+// trackSpeaker returns who speaks on a track: the name the project or the
+// user gave it, or else one made from its file name. Tracks sharing a name,
+// like the clips of one Audacity track, are one speaker.
+func trackSpeaker(trackFile *models.MultiTrackFile) string {
+	if trackFile.SpeakerName != nil {
+		if name := strings.TrimSpace(*trackFile.SpeakerName); name != "" {
+			return name
+		}
+	}
+	return getBaseFileName(trackFile.FileName)
+}
+
 // getBaseFileName extracts the filename without extension to use as speaker name
 func getBaseFileName(filename string) string {
 	base := filepath.Base(filename)
@@ -646,9 +654,7 @@ func (mt *MultiTrackTranscriber) createTurnFromWords(words []interfaces.Word, sp
 }
 
 // logIndividualTranscript provides detailed logging of individual track transcripts
-func (mt *MultiTrackTranscriber) logIndividualTranscript(fileName string, result *interfaces.TranscriptResult, offset float64) {
-	speaker := getBaseFileName(fileName)
-
+func (mt *MultiTrackTranscriber) logIndividualTranscript(fileName, speaker string, result *interfaces.TranscriptResult, offset float64) {
 	logger.Info("=== INDIVIDUAL TRANSCRIPT DETAILS ===",
 		"file", fileName,
 		"speaker", speaker,
@@ -733,8 +739,13 @@ func (mt *MultiTrackTranscriber) createSpeakerMappings(jobID string, trackTransc
 		return fmt.Errorf("failed to clear existing speaker mappings: %w", err)
 	}
 
-	// Create speaker mappings for each track
+	// Create a speaker mapping for each speaker, who may have several tracks
+	created := make(map[string]bool)
 	for _, trackTranscript := range trackTranscripts {
+		if created[trackTranscript.Speaker] {
+			continue
+		}
+		created[trackTranscript.Speaker] = true
 		speakerMapping := &models.SpeakerMapping{
 			TranscriptionJobID: jobID,
 			OriginalSpeaker:    trackTranscript.Speaker,
@@ -753,7 +764,7 @@ func (mt *MultiTrackTranscriber) createSpeakerMappings(jobID string, trackTransc
 
 	logger.Info("Successfully created speaker mappings for multi-track job",
 		"job_id", jobID,
-		"speaker_count", len(trackTranscripts))
+		"speaker_count", len(created))
 
 	return nil
 }
@@ -8,7 +8,9 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
+	"time"
 
+	"synthezia/internal/faults"
 	"synthezia/internal/transcription/interfaces"
 	"synthezia/pkg/logger"
 )
@@ -66,8 +68,9 @@ type AudioFormatPreprocessor struct{}
 
 // AppliesTo checks if this preprocessor should be used for the given model
 func (a *AudioFormatPreprocessor) AppliesTo(capabilities interfaces.ModelCapabilities) bool {
-	// Apply to all models for consistent audio format (mono 16kHz)
-	return true
+	// Apply to all models for consistent audio format (mono 16kHz), except
+	// those that consume the original file as-is
+	return !capabilities.Features["raw_input"]
 }
 
 // GetRequiredFormats returns the output formats this preprocessor can produce
@@ -111,8 +114,13 @@ func (a *AudioFormatPreprocessor) Process(ctx context.Context, input interfaces.
 	}
 
 	// Execute FFmpeg
+	if err := faults.Inject(faults.FFmpeg); err != nil {
+		return input, fmt.Errorf("audio conversion failed: %w", err)
+	}
 	cmd := exec.CommandContext(ctx, "ffmpeg", args...)
+	start := time.Now()
 	output, err := cmd.CombinedOutput()
+	logger.FFmpegStage(ctx, "convert", start, "input", input.FilePath)
 	if err != nil {
 		logger.Error("FFmpeg conversion failed", "output", string(output), "error", err)
 		return input, fmt.Errorf("audio conversion failed: %w", err)
@@ -0,0 +1,103 @@
+package transcription
+
+import (
+	"sync"
+
+	"synthezia/internal/transcription/interfaces"
+)
+
+// TranscriptionSnapshot is how far a running transcription has got, with the
+// segments transcribed so far
+type TranscriptionSnapshot struct {
+	Percent  float64                        `json:"percent"`
+	Segments []interfaces.TranscriptSegment `json:"segments"`
+}
+
+// ProgressTracker keeps the progress of the transcriptions running in this
+// process and fans updates out to subscribers, so streams can show segments
+// as adapters produce them
+type ProgressTracker struct {
+	mu          sync.RWMutex
+	latest      map[string]*TranscriptionSnapshot
+	subscribers map[string]map[int]chan interfaces.TranscriptionProgress
+	nextID      int
+}
+
+// NewProgressTracker creates an empty tracker
+func NewProgressTracker() *ProgressTracker {
+	return &ProgressTracker{
+		latest:      make(map[string]*TranscriptionSnapshot),
+		subscribers: make(map[string]map[int]chan interfaces.TranscriptionProgress),
+	}
+}
+
+// Progresses is the process-wide tracker, shared by every transcription
+var Progresses = NewProgressTracker()
+
+// Report records the progress of a job's transcription and passes it to
+// subscribers. The percentage never goes backwards.
+func (t *ProgressTracker) Report(jobID string, progress interfaces.TranscriptionProgress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	snapshot := t.latest[jobID]
+	if snapshot == nil {
+		snapshot = &TranscriptionSnapshot{}
+		t.latest[jobID] = snapshot
+	}
+	if progress.Percent < snapshot.Percent {
+		progress.Percent = snapshot.Percent
+	}
+	snapshot.Percent = progress.Percent
+	if progress.Segment != nil {
+		snapshot.Segments = append(snapshot.Segments, *progress.Segment)
+	}
+	for _, ch := range t.subscribers[jobID] {
+		select {
+		case ch <- progress:
+		default:
+			// A slow subscriber misses the update; the snapshot still has it
+		}
+	}
+}
+
+// Clear forgets a transcription once it has ended; its outcome is the job's
+// status and transcript
+func (t *ProgressTracker) Clear(jobID string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.latest, jobID)
+}
+
+// Get returns a copy of the progress of a running transcription
+func (t *ProgressTracker) Get(jobID string) (TranscriptionSnapshot, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	snapshot, ok := t.latest[jobID]
+	if !ok {
+		return TranscriptionSnapshot{}, false
+	}
+	segments := append([]interfaces.TranscriptSegment(nil), snapshot.Segments...)
+	return TranscriptionSnapshot{Percent: snapshot.Percent, Segments: segments}, true
+}
+
+// Subscribe returns a channel receiving the progress updates of a job's
+// transcription, and a function that stops them
+func (t *ProgressTracker) Subscribe(jobID string) (<-chan interfaces.TranscriptionProgress, func()) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	ch := make(chan interfaces.TranscriptionProgress, 64)
+	id := t.nextID
+	t.nextID++
+	if t.subscribers[jobID] == nil {
+		t.subscribers[jobID] = make(map[int]chan interfaces.TranscriptionProgress)
+	}
+	t.subscribers[jobID][id] = ch
+	return ch, func() {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		delete(t.subscribers[jobID], id)
+		if len(t.subscribers[jobID]) == 0 {
+			delete(t.subscribers, jobID)
+		}
+	}
+}
@@ -0,0 +1,54 @@
+package transcription
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+)
+
+// ErrNoAudio is returned by GenerateQualityReport for jobs whose audio is no
+// longer on disk
+var ErrNoAudio = errors.New("audio file not found on disk")
+
+// GenerateQualityReport analyzes a job's audio, the mix of multi-track jobs,
+// and stores the report with the job
+func GenerateQualityReport(ctx context.Context, ffmpegPath string, job *models.TranscriptionJob) (*audio.QualityReport, error) {
+	candidates := []*string{&job.AudioPath}
+	if job.IsMultiTrack {
+		candidates = append([]*string{job.MergedAudioPath}, candidates...)
+	}
+	source := ""
+	for _, candidate := range candidates {
+		if candidate == nil || *candidate == "" {
+			continue
+		}
+		if _, err := os.Stat(*candidate); err == nil {
+			source = *candidate
+			break
+		}
+	}
+	if source == "" {
+		return nil, ErrNoAudio
+	}
+
+	report, err := audio.AnalyzeQuality(ctx, ffmpegPath, source)
+	if err != nil {
+		return nil, err
+	}
+	data, err := json.Marshal(report)
+	if err != nil {
+		return nil, err
+	}
+	encoded := string(data)
+	if err := database.DB.Model(&models.TranscriptionJob{}).Where("id = ?", job.ID).Update("quality_report", &encoded).Error; err != nil {
+		return report, fmt.Errorf("failed to store quality report: %w", err)
+	}
+	job.QualityReport = &encoded
+	return report, nil
+}
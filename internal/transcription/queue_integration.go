@@ -3,6 +3,7 @@ package transcription
 import (
 	"context"
 	"os/exec"
+	"time"
 
 	"synthezia/pkg/logger"
 )
@@ -19,6 +20,27 @@ func NewUnifiedJobProcessor() *UnifiedJobProcessor {
 	}
 }
 
+// SetBackend selects the real models or the fake backend; call before initializing
+func (u *UnifiedJobProcessor) SetBackend(backend string) error {
+	return u.unifiedService.SetBackend(backend)
+}
+
+// SetExecutor sets what runs the models for the remote backend
+func (u *UnifiedJobProcessor) SetExecutor(executor Executor) {
+	u.unifiedService.SetExecutor(executor)
+}
+
+// SetTempDirectory sets where intermediate files are written; call before initializing
+func (u *UnifiedJobProcessor) SetTempDirectory(dir string) {
+	u.unifiedService.SetTempDirectory(dir)
+}
+
+// SetChunking makes recordings longer than length be split at silences and
+// transcribed by up to workers adapter runs at once; 0 turns it off
+func (u *UnifiedJobProcessor) SetChunking(length time.Duration, workers int) {
+	u.unifiedService.SetChunking(length, workers)
+}
+
 // Initialize prepares the job processor
 func (u *UnifiedJobProcessor) Initialize(ctx context.Context) error {
 	return u.unifiedService.Initialize(ctx)
@@ -12,6 +12,7 @@ import (
 	"synthezia/internal/config"
 	"synthezia/internal/database"
 	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
 
 	"github.com/google/uuid"
 )
@@ -26,6 +27,8 @@ type QuickTranscriptionJob struct {
 	CreatedAt    time.Time             `json:"created_at"`
 	ExpiresAt    time.Time             `json:"expires_at"`
 	ErrorMessage *string               `json:"error_message,omitempty"`
+
+	done chan struct{} // closed once the job completes or fails
 }
 
 // QuickTranscriptionService handles temporary transcriptions without database persistence
@@ -37,6 +40,7 @@ type QuickTranscriptionService struct {
 	tempDir          string
 	cleanupTicker    *time.Ticker
 	stopCleanup      chan bool
+	process          func(ctx context.Context, jobID string) error
 }
 
 // NewQuickTranscriptionService creates a new quick transcription service
@@ -53,6 +57,7 @@ func NewQuickTranscriptionService(cfg *config.Config, unifiedProcessor *UnifiedJ
 		jobs:             make(map[string]*QuickTranscriptionJob),
 		tempDir:          tempDir,
 		stopCleanup:      make(chan bool),
+		process:          unifiedProcessor.ProcessJob,
 	}
 
 	// Start cleanup routine (run every hour)
@@ -72,14 +77,7 @@ func (qs *QuickTranscriptionService) SubmitQuickJob(audioData io.Reader, filenam
 	audioPath := filepath.Join(qs.tempDir, audioFilename)
 
 	// Save audio file
-	audioFile, err := os.Create(audioPath)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create audio file: %v", err)
-	}
-	defer audioFile.Close()
-
-	if _, err := io.Copy(audioFile, audioData); err != nil {
-		os.Remove(audioPath)
+	if _, err := fsys.WriteFileAtomic(fsys.OS, qs.config.TempDir, audioPath, audioData); err != nil {
 		return nil, fmt.Errorf("failed to save audio file: %v", err)
 	}
 
@@ -92,6 +90,7 @@ func (qs *QuickTranscriptionService) SubmitQuickJob(audioData io.Reader, filenam
 		Parameters: params,
 		CreatedAt:  now,
 		ExpiresAt:  now.Add(6 * time.Hour),
+		done:       make(chan struct{}),
 	}
 
 	// Store in memory
@@ -123,6 +122,32 @@ func (qs *QuickTranscriptionService) GetQuickJob(jobID string) (*QuickTranscript
 	return job, nil
 }
 
+// WaitQuickJob waits until a quick transcription job completes or fails, or
+// ctx is done, and returns the job as it then stands along with ctx's error
+// when the job is still running
+func (qs *QuickTranscriptionService) WaitQuickJob(ctx context.Context, jobID string) (*QuickTranscriptionJob, error) {
+	job, err := qs.GetQuickJob(jobID)
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case <-job.done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	qs.jobsMutex.RLock()
+	defer qs.jobsMutex.RUnlock()
+	snapshot := *job
+	return &snapshot, err
+}
+
+// SetProcessor overrides what transcribes quick jobs, mainly for tests
+func (qs *QuickTranscriptionService) SetProcessor(process func(ctx context.Context, jobID string) error) {
+	qs.process = process
+}
+
 // processQuickJob processes a quick transcription job
 func (qs *QuickTranscriptionService) processQuickJob(jobID string) {
 	// Update job status to processing
@@ -133,6 +158,7 @@ func (qs *QuickTranscriptionService) processQuickJob(jobID string) {
 		return
 	}
 	job.Status = models.StatusProcessing
+	defer close(job.done)
 	qs.jobsMutex.Unlock()
 
 	// Ensure Python environment and embedded assets are ready
@@ -171,7 +197,7 @@ func (qs *QuickTranscriptionService) processQuickJob(jobID string) {
 	}
 	
 	// Process with unified service
-	err := qs.unifiedProcessor.ProcessJob(ctx, jobID)
+	err := qs.process(ctx, jobID)
 	
 	// Load the processed result back
 	var processedJob models.TranscriptionJob
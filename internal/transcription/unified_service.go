@@ -3,6 +3,7 @@ package transcription
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"os/exec"
@@ -12,12 +13,17 @@ import (
 	"sync"
 	"time"
 
+	"synthezia/internal/audio"
 	"synthezia/internal/database"
+	"synthezia/internal/devices"
 	"synthezia/internal/models"
+	"synthezia/internal/queue"
 	"synthezia/internal/transcription/interfaces"
 	"synthezia/internal/transcription/pipeline"
 	"synthezia/internal/transcription/registry"
+	"synthezia/pkg/clock"
 	"synthezia/pkg/logger"
+	"synthezia/pkg/metrics"
 
 	"github.com/google/uuid"
 )
@@ -32,6 +38,26 @@ type UnifiedTranscriptionService struct {
 	outputDirectory       string
 	defaultModelIDs       map[string]string      // Default model IDs for each task type
 	multiTrackTranscriber *MultiTrackTranscriber // For termination support
+	backend               string                 // BackendModels, BackendFake or BackendRemote
+	executor              Executor               // Runs the models for BackendRemote
+	chunkLength           time.Duration          // Split longer recordings at silences; 0 never splits
+	chunkWorkers          int                    // Chunks of one recording transcribed at once
+}
+
+const (
+	// BackendModels runs the real transcription and diarization models
+	BackendModels = "models"
+	// BackendFake routes every job to the deterministic fake adapter
+	BackendFake = "fake"
+	// BackendRemote hands the models' work to the Executor, so they run on
+	// remote workers rather than in this process
+	BackendRemote = "remote"
+)
+
+// Executor runs the transcription and diarization models on an audio file
+// somewhere other than this process, reporting progress through procCtx
+type Executor interface {
+	Transcribe(ctx context.Context, audioPath string, params models.WhisperXParams, procCtx interfaces.ProcessingContext) (*interfaces.TranscriptResult, error)
 }
 
 // NewUnifiedTranscriptionService creates a new unified transcription service
@@ -47,6 +73,47 @@ func NewUnifiedTranscriptionService() *UnifiedTranscriptionService {
 			"transcription": "whisperx",
 			"diarization":   "pyannote",
 		},
+		backend:      BackendModels,
+		chunkWorkers: 2,
+	}
+}
+
+// SetBackend selects between the real models and the fake backend
+func (u *UnifiedTranscriptionService) SetBackend(backend string) error {
+	switch backend {
+	case "", BackendModels:
+		u.backend = BackendModels
+	case BackendFake:
+		u.backend = BackendFake
+		logger.Warn("Using fake transcription backend - transcripts are synthetic")
+	case BackendRemote:
+		u.backend = BackendRemote
+	default:
+		return fmt.Errorf("unknown transcription backend %q", backend)
+	}
+	return nil
+}
+
+// SetExecutor sets what runs the models for the remote backend
+func (u *UnifiedTranscriptionService) SetExecutor(executor Executor) {
+	u.executor = executor
+}
+
+// SetTempDirectory overrides the directory for intermediate files. Keep it
+// on the same filesystem as the upload directory so results can be renamed
+// into place.
+func (u *UnifiedTranscriptionService) SetTempDirectory(dir string) {
+	if dir != "" {
+		u.tempDirectory = dir
+	}
+}
+
+// SetChunking makes recordings longer than length be split at silences and
+// transcribed by up to workers adapter runs at once; 0 turns it off
+func (u *UnifiedTranscriptionService) SetChunking(length time.Duration, workers int) {
+	u.chunkLength = length
+	if workers > 0 {
+		u.chunkWorkers = workers
 	}
 }
 
@@ -62,8 +129,10 @@ func (u *UnifiedTranscriptionService) Initialize(ctx context.Context) error {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
-	// Initialize all registered models
-	if err := u.registry.InitializeModels(ctx); err != nil {
+	// The fake and remote backends need no model environments here
+	if u.backend == BackendFake || u.backend == BackendRemote {
+		logger.Info("Skipping model initialization", "backend", u.backend)
+	} else if err := u.registry.InitializeModels(ctx); err != nil {
 		return fmt.Errorf("failed to initialize models: %w", err)
 	}
 
@@ -82,6 +151,11 @@ func (u *UnifiedTranscriptionService) ProcessJob(ctx context.Context, jobID stri
 		return fmt.Errorf("failed to get job: %w", err)
 	}
 
+	// Run on the device the queue assigned, if it did
+	if lease, ok := devices.FromContext(ctx); ok {
+		lease.Apply(&job.Parameters)
+	}
+
 	// Create execution record
 	execution := &models.TranscriptionJobExecution{
 		TranscriptionJobID: jobID,
@@ -108,19 +182,33 @@ func (u *UnifiedTranscriptionService) ProcessJob(ctx context.Context, jobID stri
 		database.DB.Save(execution)
 	}
 
+	// Measured before transcribing, so jobs that fail have one too
+	if job.QualityReport == nil && !job.Encrypted {
+		if _, err := GenerateQualityReport(logger.WithJobID(ctx, job.ID), "ffmpeg", &job); err != nil {
+			logger.Warn("Failed to generate audio quality report", "job_id", job.ID, "error", err)
+		}
+	}
+
+	// Stereo interviews become one track per channel
+	if _, err := SplitJobChannels(logger.WithJobID(ctx, job.ID), "ffmpeg", &job); err != nil {
+		errMsg := fmt.Sprintf("channel splitting failed: %v", err)
+		updateExecutionStatus(models.StatusFailed, errMsg)
+		return fmt.Errorf("%s", errMsg)
+	}
+
 	// Check for multi-track processing
 	if job.IsMultiTrack && job.Parameters.IsMultiTrackEnabled {
 		logger.Info("Processing multi-track job", "job_id", jobID)
 		if err := u.processMultiTrackJob(ctx, &job); err != nil {
 			errMsg := fmt.Sprintf("multi-track processing failed: %v", err)
-			updateExecutionStatus(models.StatusFailed, errMsg)
+			updateExecutionStatus(stoppedStatus(ctx), errMsg)
 			return fmt.Errorf("%s", errMsg)
 		}
 	} else {
 		// Process single track
 		if err := u.processSingleTrackJob(ctx, &job); err != nil {
 			errMsg := fmt.Sprintf("single-track processing failed: %v", err)
-			updateExecutionStatus(models.StatusFailed, errMsg)
+			updateExecutionStatus(stoppedStatus(ctx), errMsg)
 			return fmt.Errorf("%s", errMsg)
 		}
 	}
@@ -131,6 +219,15 @@ func (u *UnifiedTranscriptionService) ProcessJob(ctx context.Context, jobID stri
 	return nil
 }
 
+// stoppedStatus is the status of an execution that returned an error: paused
+// when the queue paused the job, failed otherwise
+func stoppedStatus(ctx context.Context) models.JobStatus {
+	if errors.Is(context.Cause(ctx), queue.ErrPaused) {
+		return models.StatusPaused
+	}
+	return models.StatusFailed
+}
+
 // processSingleTrackJob handles single audio file transcription
 func (u *UnifiedTranscriptionService) processSingleTrackJob(ctx context.Context, job *models.TranscriptionJob) error {
 	logger.Info("Processing single-track job", "job_id", job.ID, "model_family", job.Parameters.ModelFamily)
@@ -142,32 +239,147 @@ func (u *UnifiedTranscriptionService) processSingleTrackJob(ctx context.Context,
 		TempDirectory:   u.tempDirectory,
 		Metadata:        map[string]string{},
 	}
+	if job.Title != nil {
+		procCtx.Metadata["title"] = *job.Title
+	}
 
 	// Create output directory
 	if err := os.MkdirAll(procCtx.OutputDirectory, 0755); err != nil {
 		return fmt.Errorf("failed to create output directory: %w", err)
 	}
 
+	// A partial run transcribes its stretch of the recording alone
+	audioPath := job.AudioPath
+	rangeStart, rangeEnd, partial := job.Parameters.TimeRange()
+	// A paused job carries on from its checkpoint
+	if job.ResumeFrom != nil && *job.ResumeFrom > rangeStart && (rangeEnd <= 0 || *job.ResumeFrom < rangeEnd) {
+		rangeStart, partial = *job.ResumeFrom, true
+		logger.Info("Resuming paused job", "job_id", job.ID, "from", rangeStart)
+	}
+	if partial {
+		if err := os.MkdirAll(u.tempDirectory, 0755); err != nil {
+			return fmt.Errorf("failed to create temp directory: %w", err)
+		}
+		audioPath = filepath.Join(u.tempDirectory, job.ID+"_range.wav")
+		if err := audio.ExtractRange(ctx, "ffmpeg", job.AudioPath, audioPath, rangeStart, rangeEnd); err != nil {
+			return err
+		}
+		defer os.Remove(audioPath)
+		logger.Info("Transcribing part of the recording", "job_id", job.ID, "start", rangeStart, "end", rangeEnd)
+	}
+
 	// Create audio input
-	audioInput, err := u.createAudioInput(job.AudioPath)
+	audioInput, err := u.createAudioInput(audioPath)
 	if err != nil {
 		return fmt.Errorf("failed to create audio input: %w", err)
 	}
 
+	// Progress is stored on the job as the share of its stretch of the
+	// recording transcribed
+	spanStart, _, _ := job.Parameters.TimeRange()
+	spanEnd := rangeEnd
+	if spanEnd <= 0 {
+		if audioInput.Duration > 0 {
+			spanEnd = rangeStart + audioInput.Duration.Seconds()
+		} else if job.AudioDuration != nil {
+			spanEnd = *job.AudioDuration
+		}
+	}
+	jobProgress := NewJobProgressWriter(nil, clock.Real, job.ID, spanStart, spanEnd)
+	jobProgress.Begin(rangeStart)
+
+	// Segments are passed on as they are transcribed, timed within the whole recording
+	procCtx.Progress = shiftProgress(func(progress interfaces.TranscriptionProgress) {
+		Progresses.Report(job.ID, progress)
+		jobProgress.Report(progress)
+	}, rangeStart)
+	defer Progresses.Clear(job.ID)
+
+	transcribeStart := time.Now()
 	transcriptResult, err := u.transcribeAudioInput(ctx, audioInput, job.Parameters, procCtx)
 	if err != nil {
+		if errors.Is(context.Cause(ctx), queue.ErrPaused) && u.canCheckpoint(job, audioInput) {
+			if err := u.checkpoint(job, rangeStart); err != nil {
+				logger.Warn("Failed to checkpoint paused job, it will start over", "job_id", job.ID, "error", err)
+			}
+		}
 		return err
 	}
+	metrics.ObserveProcessing(time.Since(transcribeStart), audioInput.Duration)
+
+	if transcriptResult != nil && partial {
+		var existing *interfaces.TranscriptResult
+		if job.Transcript != nil {
+			existing = &interfaces.TranscriptResult{}
+			if err := json.Unmarshal([]byte(*job.Transcript), existing); err != nil {
+				logger.Warn("Failed to read existing transcript, keeping only the new stretch", "job_id", job.ID, "error", err)
+				existing = nil
+			}
+		}
+		transcriptResult = audio.Splice(existing, transcriptResult, rangeStart, rangeEnd)
+	}
 
 	if transcriptResult != nil {
 		if err := u.saveTranscriptionResults(job.ID, transcriptResult); err != nil {
 			return fmt.Errorf("failed to save transcription results: %w", err)
 		}
 	}
+	if job.ResumeFrom != nil {
+		if err := database.DB.Model(&models.TranscriptionJob{}).Where("id = ?", job.ID).Update("resume_from", nil).Error; err != nil {
+			return fmt.Errorf("failed to clear checkpoint: %w", err)
+		}
+	}
+	jobProgress.Finish()
 
 	return nil
 }
 
+// canCheckpoint reports whether the segments a job reports as it runs can
+// stand in for its transcript. Speakers are only assigned at the end, chunks
+// run out of order, and encrypted jobs must not store plaintext.
+func (u *UnifiedTranscriptionService) canCheckpoint(job *models.TranscriptionJob, input interfaces.AudioInput) bool {
+	if job.Encrypted || job.Parameters.Diarize {
+		return false
+	}
+	return u.chunkLength <= 0 || (input.Duration > 0 && input.Duration <= u.chunkLength)
+}
+
+// checkpoint stores the segments a paused job transcribed from start on in
+// its transcript, and records where they end so resuming carries on from there
+func (u *UnifiedTranscriptionService) checkpoint(job *models.TranscriptionJob, start float64) error {
+	snapshot, ok := Progresses.Get(job.ID)
+	if !ok || len(snapshot.Segments) == 0 {
+		return nil
+	}
+
+	// Splice takes the new stretch timed from its start
+	done := &interfaces.TranscriptResult{}
+	resumeFrom := start
+	for _, segment := range snapshot.Segments {
+		resumeFrom = max(resumeFrom, segment.End)
+		segment.Start -= start
+		segment.End -= start
+		done.Segments = append(done.Segments, segment)
+	}
+	var existing *interfaces.TranscriptResult
+	if job.Transcript != nil {
+		existing = &interfaces.TranscriptResult{}
+		if err := json.Unmarshal([]byte(*job.Transcript), existing); err != nil {
+			existing = nil
+		}
+	}
+	resultJSON, err := u.convertTranscriptResultToJSON(audio.Splice(existing, done, start, resumeFrom))
+	if err != nil {
+		return err
+	}
+	if err := database.DB.Model(&models.TranscriptionJob{}).Where("id = ?", job.ID).
+		Updates(map[string]interface{}{"transcript": resultJSON, "resume_from": resumeFrom}).Error; err != nil {
+		return err
+	}
+	logger.Info("Checkpointed paused job", "job_id", job.ID, "resume_from", resumeFrom, "segments", len(done.Segments))
+	return nil
+}
+
 // TranscribeFile runs the unified pipeline against an arbitrary audio file and returns the transcript
 func (u *UnifiedTranscriptionService) TranscribeFile(ctx context.Context, audioPath string, params models.WhisperXParams) (*interfaces.TranscriptResult, error) {
 	jobID := fmt.Sprintf("live-%s", uuid.New().String())
@@ -197,8 +409,38 @@ func (u *UnifiedTranscriptionService) TranscribeFile(ctx context.Context, audioP
 	return result, nil
 }
 
+// TranscribeTask runs the models on an audio file for a remote worker,
+// passing on progress as it goes; taskID names its output directory
+func (u *UnifiedTranscriptionService) TranscribeTask(ctx context.Context, taskID, audioPath string, params models.WhisperXParams, progress func(interfaces.TranscriptionProgress)) (*interfaces.TranscriptResult, error) {
+	procCtx := interfaces.ProcessingContext{
+		JobID:           taskID,
+		OutputDirectory: filepath.Join(u.outputDirectory, "tasks", taskID),
+		TempDirectory:   u.tempDirectory,
+		Metadata:        map[string]string{"source": "remote-worker"},
+		Progress:        progress,
+	}
+	if err := os.MkdirAll(procCtx.OutputDirectory, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create task output directory: %w", err)
+	}
+	defer os.RemoveAll(procCtx.OutputDirectory)
+
+	audioInput, err := u.createAudioInput(audioPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create audio input: %w", err)
+	}
+	return u.transcribeAudioInput(ctx, audioInput, params, procCtx)
+}
+
 // transcribeAudioInput centralizes preprocessing, adapter invocation, and optional diarization merging.
 func (u *UnifiedTranscriptionService) transcribeAudioInput(ctx context.Context, audioInput interfaces.AudioInput, params models.WhisperXParams, procCtx interfaces.ProcessingContext) (*interfaces.TranscriptResult, error) {
+	// Remote workers clean the audio up and run the models themselves
+	if u.backend == BackendRemote {
+		if u.executor == nil {
+			return nil, fmt.Errorf("no remote executor configured")
+		}
+		return u.executor.Transcribe(ctx, audioInput.FilePath, params, procCtx)
+	}
+
 	transcriptionModelID, diarizationModelID, err := u.selectModels(params)
 	if err != nil {
 		return nil, fmt.Errorf("failed to select models: %w", err)
@@ -209,6 +451,29 @@ func (u *UnifiedTranscriptionService) transcribeAudioInput(ctx context.Context,
 	}
 
 	var tempFilesToCleanup []string
+	defer func() {
+		for _, tempFile := range tempFilesToCleanup {
+			if err := os.Remove(tempFile); err != nil {
+				logger.Warn("Failed to clean up temporary file", "file", tempFile, "error", err)
+			} else {
+				logger.Info("Cleaned up temporary file", "file", tempFile)
+			}
+		}
+	}()
+
+	// The job's own clean-up runs first; its output is already in the format
+	// the conversion below produces
+	offset := 0.0
+	if options := preprocessOptions(params); options != (audio.PreprocessOptions{}) {
+		cleaned, trimmed, err := u.preprocessAudio(ctx, audioInput, options, procCtx)
+		if err != nil {
+			logger.Warn("Audio clean-up failed, using original", "job_id", procCtx.JobID, "error", err)
+		} else {
+			tempFilesToCleanup = append(tempFilesToCleanup, cleaned.TempFilePath)
+			audioInput, offset = cleaned, trimmed
+			procCtx.Progress = shiftProgress(procCtx.Progress, offset)
+		}
+	}
 
 	// Determine preprocessing target capabilities
 	var capabilities interfaces.ModelCapabilities
@@ -237,16 +502,6 @@ func (u *UnifiedTranscriptionService) transcribeAudioInput(ctx context.Context,
 			"converted_channels", preprocessedInput.Channels)
 	}
 
-	defer func() {
-		for _, tempFile := range tempFilesToCleanup {
-			if err := os.Remove(tempFile); err != nil {
-				logger.Warn("Failed to clean up temporary file", "file", tempFile, "error", err)
-			} else {
-				logger.Info("Cleaned up temporary file", "file", tempFile)
-			}
-		}
-	}()
-
 	var transcriptResult *interfaces.TranscriptResult
 	var diarizationResult *interfaces.DiarizationResult
 
@@ -258,7 +513,7 @@ func (u *UnifiedTranscriptionService) transcribeAudioInput(ctx context.Context,
 		}
 
 		paramsForModel := u.convertParametersForModel(params, transcriptionModelID)
-		transcriptResult, err = transcriptionAdapter.Transcribe(ctx, preprocessedInput, paramsForModel, procCtx)
+		transcriptResult, err = u.transcribe(ctx, transcriptionAdapter, preprocessedInput, paramsForModel, procCtx, params.Diarize)
 		if err != nil {
 			return nil, fmt.Errorf("transcription failed: %w", err)
 		}
@@ -284,9 +539,197 @@ func (u *UnifiedTranscriptionService) transcribeAudioInput(ctx context.Context,
 		}
 	}
 
+	if transcriptResult != nil && offset > 0 {
+		shiftTranscript(transcriptResult, offset)
+	}
 	return transcriptResult, nil
 }
 
+// preprocessOptions returns the clean-up a job asked for
+func preprocessOptions(params models.WhisperXParams) audio.PreprocessOptions {
+	return audio.PreprocessOptions{
+		Normalize:   params.Normalize,
+		TrimSilence: params.TrimSilence,
+		Denoise:     params.Denoise,
+	}
+}
+
+// preprocessAudio writes a cleaned-up 16 kHz mono copy of the input to the
+// temp directory, returning it and the seconds trimmed from its start
+func (u *UnifiedTranscriptionService) preprocessAudio(ctx context.Context, input interfaces.AudioInput, options audio.PreprocessOptions, procCtx interfaces.ProcessingContext) (interfaces.AudioInput, float64, error) {
+	if err := os.MkdirAll(procCtx.TempDirectory, 0755); err != nil {
+		return input, 0, fmt.Errorf("failed to create temp directory: %w", err)
+	}
+	outputPath := filepath.Join(procCtx.TempDirectory, procCtx.JobID+"_preprocessed.wav")
+	offset, err := audio.Preprocess(ctx, "ffmpeg", input.FilePath, outputPath, options)
+	if err != nil {
+		return input, 0, err
+	}
+
+	cleaned := interfaces.AudioInput{
+		FilePath:     outputPath,
+		Format:       "wav",
+		SampleRate:   audio.PreprocessSampleRate,
+		Channels:     1,
+		Duration:     input.Duration,
+		Metadata:     input.Metadata,
+		TempFilePath: outputPath,
+	}
+	if stat, err := os.Stat(outputPath); err == nil {
+		cleaned.Size = stat.Size()
+	}
+	logger.Info("Audio cleaned up",
+		"job_id", procCtx.JobID,
+		"normalize", options.Normalize,
+		"trim_silence", options.TrimSilence,
+		"denoise", options.Denoise,
+		"trimmed_seconds", offset)
+	return cleaned, offset, nil
+}
+
+// transcribe runs the adapter over the recording. Long recordings are split
+// at silences and their chunks transcribed in parallel, unless speakers are
+// to be told apart: each chunk would number its speakers on its own.
+func (u *UnifiedTranscriptionService) transcribe(ctx context.Context, adapter interfaces.TranscriptionAdapter, input interfaces.AudioInput, params map[string]interface{}, procCtx interfaces.ProcessingContext, diarize bool) (*interfaces.TranscriptResult, error) {
+	if u.chunkLength <= 0 || diarize || (input.Duration > 0 && input.Duration <= u.chunkLength) {
+		return adapter.Transcribe(ctx, input, params, procCtx)
+	}
+
+	dir := filepath.Join(procCtx.TempDirectory, procCtx.JobID+"_chunks")
+	defer os.RemoveAll(dir)
+	chunker := audio.NewChunker()
+	chunker.SetLength(u.chunkLength)
+	chunks, err := chunker.Split(ctx, input.FilePath, dir)
+	if err != nil {
+		logger.Warn("Failed to split recording, transcribing it whole", "job_id", procCtx.JobID, "error", err)
+		return adapter.Transcribe(ctx, input, params, procCtx)
+	}
+	if len(chunks) == 1 {
+		return adapter.Transcribe(ctx, input, params, procCtx)
+	}
+	logger.Info("Transcribing recording in chunks", "job_id", procCtx.JobID, "chunks", len(chunks), "workers", u.chunkWorkers)
+
+	// Each chunk reports progress through itself; the recording's is the
+	// share of its length done across them
+	var progressMu sync.Mutex
+	done := make([]float64, len(chunks))
+	total := 0.0
+	for _, chunk := range chunks {
+		total += chunk.End - chunk.Start
+	}
+	chunkProgress := func(i int, chunk audio.Chunk) func(interfaces.TranscriptionProgress) {
+		if procCtx.Progress == nil || total <= 0 {
+			return nil
+		}
+		report := shiftProgress(procCtx.Progress, chunk.Start)
+		last := i == len(chunks)-1
+		return func(progress interfaces.TranscriptionProgress) {
+			// Segments in the overlap with a neighbour are kept by one chunk, as Stitch does
+			if segment := progress.Segment; segment != nil {
+				mid := chunk.Start + (segment.Start+segment.End)/2
+				if mid < chunk.KeepStart || (mid >= chunk.KeepEnd && !last) {
+					progress.Segment = nil
+				}
+			}
+			progressMu.Lock()
+			done[i] = progress.Percent / 100 * (chunk.End - chunk.Start)
+			sum := 0.0
+			for _, seconds := range done {
+				sum += seconds
+			}
+			progressMu.Unlock()
+			progress.Percent = sum / total * 100
+			report(progress)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	start := time.Now()
+	results := make([]*interfaces.TranscriptResult, len(chunks))
+	errs := make([]error, len(chunks))
+	slots := make(chan struct{}, u.chunkWorkers)
+	var wg sync.WaitGroup
+	for i, chunk := range chunks {
+		wg.Add(1)
+		go func(i int, chunk audio.Chunk) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+			if ctx.Err() != nil {
+				errs[i] = ctx.Err()
+				return
+			}
+
+			// Adapters keep their files in a folder named after the job
+			chunkCtx := procCtx
+			chunkCtx.TempDirectory = filepath.Join(procCtx.TempDirectory, fmt.Sprintf("%s_chunk%03d", procCtx.JobID, i))
+			chunkCtx.Progress = chunkProgress(i, chunk)
+			chunkInput := interfaces.AudioInput{
+				FilePath:   chunk.Path,
+				Format:     "wav",
+				SampleRate: audio.PreprocessSampleRate,
+				Channels:   1,
+				Duration:   time.Duration((chunk.End - chunk.Start) * float64(time.Second)),
+				Metadata:   input.Metadata,
+			}
+			if stat, err := os.Stat(chunk.Path); err == nil {
+				chunkInput.Size = stat.Size()
+			}
+			results[i], errs[i] = adapter.Transcribe(ctx, chunkInput, params, chunkCtx)
+			os.RemoveAll(chunkCtx.TempDirectory)
+			if errs[i] != nil {
+				cancel()
+			}
+		}(i, chunk)
+	}
+	wg.Wait()
+	// Report the chunk that failed rather than those cancelled after it
+	failed := -1
+	for i, err := range errs {
+		if err != nil && (failed < 0 || errors.Is(errs[failed], context.Canceled) && !errors.Is(err, context.Canceled)) {
+			failed = i
+		}
+	}
+	if failed >= 0 {
+		return nil, fmt.Errorf("chunk %d of %d: %w", failed+1, len(chunks), errs[failed])
+	}
+
+	result := audio.Stitch(chunks, results)
+	result.ProcessingTime = time.Since(start)
+	return result, nil
+}
+
+// shiftTranscript moves every timestamp later by offset seconds, so a
+// transcript of trimmed audio lines up with the original
+func shiftTranscript(result *interfaces.TranscriptResult, offset float64) {
+	for i := range result.Segments {
+		result.Segments[i].Start += offset
+		result.Segments[i].End += offset
+	}
+	for i := range result.WordSegments {
+		result.WordSegments[i].Start += offset
+		result.WordSegments[i].End += offset
+	}
+}
+
+// shiftProgress moves the segments passed to a progress listener later by
+// offset seconds, as shiftTranscript does for the finished transcript
+func shiftProgress(listener func(interfaces.TranscriptionProgress), offset float64) func(interfaces.TranscriptionProgress) {
+	if listener == nil || offset == 0 {
+		return listener
+	}
+	return func(progress interfaces.TranscriptionProgress) {
+		if progress.Segment != nil {
+			segment := *progress.Segment
+			segment.Start += offset
+			segment.End += offset
+			progress.Segment = &segment
+		}
+		listener(progress)
+	}
+}
+
 // processMultiTrackJob handles multi-track audio processing
 func (u *UnifiedTranscriptionService) processMultiTrackJob(ctx context.Context, job *models.TranscriptionJob) error {
 	logger.Info("Processing multi-track job", "job_id", job.ID, "track_count", len(job.MultiTrackFiles))
@@ -323,6 +766,15 @@ func (u *UnifiedTranscriptionService) IsMultiTrackJob(jobID string) bool {
 
 // selectModels determines which models to use based on job parameters
 func (u *UnifiedTranscriptionService) selectModels(params models.WhisperXParams) (transcriptionModelID, diarizationModelID string, err error) {
+	if u.backend == BackendFake {
+		logger.Info("Selected models", "transcription", "fake")
+		return "fake", "", nil
+	}
+	// Canned transcripts must never pass for real ones on a real server
+	if params.ModelFamily == BackendFake {
+		return "", "", fmt.Errorf("model family %q needs the fake transcription backend", BackendFake)
+	}
+
 	// Determine transcription model
 	switch params.ModelFamily {
 	case "nvidia_parakeet":
@@ -630,6 +1082,11 @@ var detectedCuda struct {
 	available bool
 }
 
+// GPUAvailable reports whether a CUDA device was detected on this host
+func GPUAvailable() bool {
+	return cudaAvailable()
+}
+
 func cudaAvailable() bool {
 	detectedCuda.once.Do(func() {
 		if _, err := exec.LookPath("nvidia-smi"); err == nil {
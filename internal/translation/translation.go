@@ -0,0 +1,418 @@
+// Package translation translates finished transcripts into other languages
+// in the background, either with the active LLM or by running Whisper's
+// translate task on the audio. Each translation is stored on its own, so a
+// job keeps its original-language transcript alongside them.
+package translation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/llm"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// ErrNoModel means neither the request nor the settings name an LLM model
+var ErrNoModel = errors.New("no model specified and no default model configured")
+
+// ErrNotTranscribed means the job has no transcript to translate yet
+var ErrNotTranscribed = errors.New("transcription has no transcript")
+
+// ErrEncrypted means the job's transcript is sealed and cannot be translated
+var ErrEncrypted = errors.New("transcription is encrypted")
+
+// batchSize is how many segments are sent to the LLM in one prompt
+const batchSize = 40
+
+// itemTimeout bounds translating one transcript into one language
+const itemTimeout = 15 * time.Minute
+
+// ServiceFunc returns the LLM service to translate with
+type ServiceFunc func() (llm.Service, error)
+
+// TranscribeFunc transcribes an audio file with the given parameters
+type TranscribeFunc func(ctx context.Context, audioPath string, params models.WhisperXParams) (*interfaces.TranscriptResult, error)
+
+// Request picks the languages to translate a transcription into
+type Request struct {
+	Languages []string
+	// Provider is one of the models.TranslationProvider* values; llm when empty
+	Provider string
+	// Model overrides the default LLM model
+	Model string
+}
+
+// Service starts and tracks translations
+type Service struct {
+	db         *gorm.DB
+	service    ServiceFunc
+	transcribe TranscribeFunc
+
+	mu      sync.Mutex
+	running map[string]chan struct{}
+}
+
+// NewService creates a service; a nil db uses database.DB at call time
+func NewService(db *gorm.DB, service ServiceFunc, transcribe TranscribeFunc) *Service {
+	return &Service{db: db, service: service, transcribe: transcribe, running: map[string]chan struct{}{}}
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// ParseLanguages splits a comma-separated list of language codes, dropping
+// blanks and repeats
+func ParseLanguages(list string) []string {
+	return NormalizeLanguages(strings.Split(list, ","))
+}
+
+// NormalizeLanguages lowercases language codes, dropping blanks and repeats
+func NormalizeLanguages(codes []string) []string {
+	var languages []string
+	seen := map[string]bool{}
+	for _, language := range codes {
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language != "" && !seen[language] {
+			seen[language] = true
+			languages = append(languages, language)
+		}
+	}
+	return languages
+}
+
+// Validate checks that the provider exists and can translate into every language
+func Validate(languages []string, provider string) error {
+	for _, language := range languages {
+		if len(language) > 10 {
+			return fmt.Errorf("invalid language code %q", language)
+		}
+	}
+	switch provider {
+	case "", models.TranslationProviderLLM:
+	case models.TranslationProviderWhisper:
+		for _, language := range languages {
+			if language != "en" {
+				return fmt.Errorf("the whisper provider only translates into English, not %q", language)
+			}
+		}
+	default:
+		return fmt.Errorf("translation_provider must be llm or whisper")
+	}
+	return nil
+}
+
+// Track translates every job that completes into the languages its
+// parameters ask for. It returns a function that stops tracking.
+func (s *Service) Track() func() {
+	return jobstate.Subscribe(func(event jobstate.Event) {
+		if event.To != models.StatusCompleted {
+			return
+		}
+		go func() {
+			var job models.TranscriptionJob
+			if err := s.conn().Where("id = ?", event.JobID).First(&job).Error; err != nil {
+				return
+			}
+			if job.Parameters.TranslateTo == nil {
+				return
+			}
+			languages := ParseLanguages(*job.Parameters.TranslateTo)
+			if len(languages) == 0 {
+				return
+			}
+			if _, err := s.Start(job.ID, Request{Languages: languages, Provider: job.Parameters.TranslationProvider}); err != nil {
+				logger.Warn("Failed to start translations", "job_id", job.ID, "error", err)
+			}
+		}()
+	})
+}
+
+// Start records a pending translation of the job into each language,
+// replacing earlier ones, and translates them in the background
+func (s *Service) Start(jobID string, req Request) ([]models.Translation, error) {
+	db := s.conn()
+
+	var job models.TranscriptionJob
+	if err := db.Where("id = ?", jobID).First(&job).Error; err != nil {
+		return nil, err
+	}
+	if job.Transcript == nil || *job.Transcript == "" {
+		return nil, ErrNotTranscribed
+	}
+	if job.Encrypted {
+		return nil, ErrEncrypted
+	}
+
+	provider := req.Provider
+	if provider == "" {
+		provider = models.TranslationProviderLLM
+	}
+	if err := Validate(req.Languages, provider); err != nil {
+		return nil, err
+	}
+	model := req.Model
+	if provider == models.TranslationProviderWhisper {
+		model = whisperParams(job.Parameters).Model
+	} else if model == "" {
+		var settings models.SummarySetting
+		if err := db.First(&settings).Error; err == nil {
+			model = settings.DefaultModel
+		}
+		if model == "" {
+			return nil, ErrNoModel
+		}
+	}
+
+	translations := make([]models.Translation, 0, len(req.Languages))
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, language := range req.Languages {
+			if err := tx.Where("transcription_id = ? AND target_language = ?", jobID, language).Delete(&models.Translation{}).Error; err != nil {
+				return err
+			}
+			translation := models.Translation{
+				TranscriptionID: jobID,
+				TargetLanguage:  language,
+				Provider:        provider,
+				Model:           model,
+				Status:          models.TranslationPending,
+			}
+			if err := tx.Create(&translation).Error; err != nil {
+				return err
+			}
+			translations = append(translations, translation)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to record translations: %w", err)
+	}
+
+	for _, translation := range translations {
+		s.launch(translation.ID)
+	}
+	return translations, nil
+}
+
+// Resume restarts translations that were interrupted, e.g. by a server restart
+func (s *Service) Resume() {
+	var ids []string
+	s.conn().Model(&models.Translation{}).
+		Where("status IN ?", []string{models.TranslationPending, models.TranslationRunning}).
+		Pluck("id", &ids)
+	for _, id := range ids {
+		logger.Info("Resuming translation", "translation_id", id)
+		s.launch(id)
+	}
+}
+
+// Wait blocks until the translation is no longer being processed
+func (s *Service) Wait(id string) {
+	s.mu.Lock()
+	done, ok := s.running[id]
+	s.mu.Unlock()
+	if ok {
+		<-done
+	}
+}
+
+func (s *Service) launch(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.running[id]; ok {
+		return
+	}
+	done := make(chan struct{})
+	s.running[id] = done
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, id)
+			s.mu.Unlock()
+			close(done)
+		}()
+		s.process(id)
+	}()
+}
+
+// process translates one pending translation and stores the outcome
+func (s *Service) process(id string) {
+	db := s.conn()
+
+	var translation models.Translation
+	if err := db.Where("id = ?", id).First(&translation).Error; err != nil {
+		logger.Error("Failed to load translation", "translation_id", id, "error", err)
+		return
+	}
+	db.Model(&translation).Update("status", models.TranslationRunning)
+
+	ctx, cancel := context.WithTimeout(context.Background(), itemTimeout)
+	defer cancel()
+	result, err := s.translate(ctx, &translation)
+
+	now := time.Now()
+	updates := map[string]interface{}{"status": models.TranslationCompleted, "completed_at": &now}
+	if err == nil {
+		var data []byte
+		if data, err = json.Marshal(result); err == nil {
+			updates["transcript"] = string(data)
+		}
+	}
+	if err != nil {
+		updates = map[string]interface{}{"status": models.TranslationFailed, "error": err.Error(), "completed_at": &now}
+		logger.Warn("Translation failed",
+			"translation_id", id, "transcription_id", translation.TranscriptionID, "language", translation.TargetLanguage, "error", err)
+	} else {
+		logger.Info("Translation finished",
+			"translation_id", id, "transcription_id", translation.TranscriptionID, "language", translation.TargetLanguage)
+	}
+	db.Model(&translation).Updates(updates)
+}
+
+// translate produces the translated transcript with the translation's provider
+func (s *Service) translate(ctx context.Context, translation *models.Translation) (*interfaces.TranscriptResult, error) {
+	var job models.TranscriptionJob
+	if err := s.conn().Where("id = ?", translation.TranscriptionID).First(&job).Error; err != nil {
+		return nil, fmt.Errorf("transcription not found: %w", err)
+	}
+	if job.Transcript == nil || *job.Transcript == "" {
+		return nil, ErrNotTranscribed
+	}
+	if job.Encrypted {
+		return nil, ErrEncrypted
+	}
+
+	if translation.Provider == models.TranslationProviderWhisper {
+		return s.translateAudio(ctx, &job)
+	}
+	var source interfaces.TranscriptResult
+	if err := json.Unmarshal([]byte(*job.Transcript), &source); err != nil {
+		return nil, fmt.Errorf("failed to parse transcript: %w", err)
+	}
+	service, err := s.service()
+	if err != nil {
+		return nil, err
+	}
+	return translateSegments(ctx, service, translation.Model, &source, translation.TargetLanguage)
+}
+
+// whisperParams are the job's parameters switched to Whisper's translate task
+func whisperParams(params models.WhisperXParams) models.WhisperXParams {
+	if params.ModelFamily != "" && params.ModelFamily != "whisper" {
+		params.ModelFamily = "whisper"
+		params.Model = "small"
+	}
+	params.Task = "translate"
+	params.RangeStart, params.RangeEnd = nil, nil
+	return params
+}
+
+// translateAudio runs Whisper's translate task on the job's audio, which
+// gives an English transcript with its own segments
+func (s *Service) translateAudio(ctx context.Context, job *models.TranscriptionJob) (*interfaces.TranscriptResult, error) {
+	if s.transcribe == nil {
+		return nil, fmt.Errorf("transcription is not available")
+	}
+	if job.SourceAudioRemovedAt != nil {
+		return nil, fmt.Errorf("the source audio was removed")
+	}
+	audioPath := job.AudioPath
+	if job.MergedAudioPath != nil && *job.MergedAudioPath != "" {
+		audioPath = *job.MergedAudioPath
+	}
+	result, err := s.transcribe(ctx, audioPath, whisperParams(job.Parameters))
+	if err != nil {
+		return nil, err
+	}
+	result.Language = "en"
+	return result, nil
+}
+
+// numberedLine matches a "12: text" line of the model's reply
+var numberedLine = regexp.MustCompile(`^\s*(\d+)\s*[:.)]\s?(.*)$`)
+
+// translateSegments translates the text of each segment with the LLM, a
+// batch of numbered lines at a time. The result keeps the segments' timing
+// and speakers; word timings do not carry over to another language.
+func translateSegments(ctx context.Context, service llm.Service, model string, source *interfaces.TranscriptResult, language string) (*interfaces.TranscriptResult, error) {
+	result := &interfaces.TranscriptResult{
+		Language:  language,
+		Segments:  make([]interfaces.TranscriptSegment, len(source.Segments)),
+		ModelUsed: model,
+	}
+	copy(result.Segments, source.Segments)
+
+	from := source.Language
+	if from == "" {
+		from = "the original language"
+	}
+	var texts []string
+	for start := 0; start < len(result.Segments); start += batchSize {
+		end := start + batchSize
+		if end > len(result.Segments) {
+			end = len(result.Segments)
+		}
+		var lines strings.Builder
+		for i := start; i < end; i++ {
+			fmt.Fprintf(&lines, "%d: %s\n", i+1-start, strings.Join(strings.Fields(result.Segments[i].Text), " "))
+		}
+		prompt := fmt.Sprintf("Translate each numbered line from %s into the language with code %q. "+
+			"Reply with the translated lines only, numbered the same way, one per line.\n\n%s", from, language, lines.String())
+
+		resp, err := service.ChatCompletion(ctx, model, []llm.ChatMessage{{Role: "user", Content: prompt}}, 0.0)
+		if err != nil {
+			return nil, err
+		}
+		if resp == nil || len(resp.Choices) == 0 {
+			return nil, fmt.Errorf("model returned an empty translation")
+		}
+		translated := parseNumberedLines(resp.Choices[0].Message.Content)
+		for i := start; i < end; i++ {
+			text, ok := translated[i+1-start]
+			if !ok {
+				return nil, fmt.Errorf("model left out line %d of segments %d-%d", i+1-start, start+1, end)
+			}
+			result.Segments[i].Text = text
+			result.Segments[i].Language = &language
+			texts = append(texts, text)
+		}
+	}
+	result.Text = strings.Join(texts, " ")
+	return result, nil
+}
+
+// parseNumberedLines reads "n: text" lines, ignoring anything else the model added
+func parseNumberedLines(reply string) map[int]string {
+	lines := map[int]string{}
+	for _, line := range strings.Split(reply, "\n") {
+		match := numberedLine.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		n, err := strconv.Atoi(match[1])
+		if err != nil {
+			continue
+		}
+		if _, ok := lines[n]; !ok {
+			lines[n] = strings.TrimSpace(match[2])
+		}
+	}
+	return lines
+}
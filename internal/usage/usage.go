@@ -0,0 +1,323 @@
+// Package usage tracks per-API-key traffic in hourly buckets and raises
+// alerts when a key's usage changes sharply, which often means it leaked.
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Alert kinds
+const (
+	AlertRequestSpike = "request_spike"
+	AlertErrorSpike   = "error_spike"
+)
+
+// Alert resolutions
+const (
+	ResolutionRevoked   = "revoked"
+	ResolutionDismissed = "dismissed"
+)
+
+// Thresholds control when an alert is raised
+type Thresholds struct {
+	// SpikeFactor is how many times the hourly baseline counts as a spike
+	SpikeFactor float64
+	// MinRequests is the smallest hourly request count worth alerting on
+	MinRequests int64
+	// ErrorRate is the share of failed requests that counts as an error spike
+	ErrorRate float64
+	// MinErrorRequests is the smallest hourly request count for error alerts
+	MinErrorRequests int64
+	// MinHistory is how long a key must have existed before it is compared
+	// against its own baseline
+	MinHistory time.Duration
+}
+
+// DefaultThresholds suit a self-hosted instance with a handful of integrations
+var DefaultThresholds = Thresholds{
+	SpikeFactor:      5,
+	MinRequests:      100,
+	ErrorRate:        0.5,
+	MinErrorRequests: 20,
+	MinHistory:       24 * time.Hour,
+}
+
+// baselineWindow is how much history the baseline averages over
+const baselineWindow = 7 * 24 * time.Hour
+
+// alertCooldown suppresses repeated alerts of the same kind for a key
+const alertCooldown = 24 * time.Hour
+
+// Totals summarizes a key's usage over a period
+type Totals struct {
+	Requests     int64   `json:"requests"`
+	Errors       int64   `json:"errors"`
+	ErrorRate    float64 `json:"error_rate"`
+	AudioMinutes float64 `json:"audio_minutes"`
+}
+
+// Tracker records usage and checks it for anomalies
+type Tracker struct {
+	db         *gorm.DB
+	clock      clock.Clock
+	thresholds Thresholds
+}
+
+// NewTracker creates a tracker; a nil db uses database.DB at call time
+func NewTracker(db *gorm.DB) *Tracker {
+	return &Tracker{db: db, clock: clock.Real, thresholds: DefaultThresholds}
+}
+
+// Default is the process-wide tracker
+var Default = NewTracker(nil)
+
+// SetClock overrides the time source, mainly for tests
+func (t *Tracker) SetClock(c clock.Clock) {
+	t.clock = c
+}
+
+// SetThresholds overrides the alert thresholds
+func (t *Tracker) SetThresholds(th Thresholds) {
+	t.thresholds = th
+}
+
+func (t *Tracker) conn() *gorm.DB {
+	if t.db != nil {
+		return t.db
+	}
+	return database.DB
+}
+
+// currentHour is the start of the bucket for now
+func (t *Tracker) currentHour() time.Time {
+	return t.clock.Now().UTC().Truncate(time.Hour)
+}
+
+// add increments the current hour's bucket for a key
+func (t *Tracker) add(keyID uint, requests, errors int64, audioSeconds float64) error {
+	bucket := models.APIKeyUsage{
+		APIKeyID:     keyID,
+		Hour:         t.currentHour(),
+		Requests:     requests,
+		Errors:       errors,
+		AudioSeconds: audioSeconds,
+	}
+	return t.conn().Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "api_key_id"}, {Name: "hour"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests":      gorm.Expr("requests + ?", requests),
+			"errors":        gorm.Expr("errors + ?", errors),
+			"audio_seconds": gorm.Expr("audio_seconds + ?", audioSeconds),
+		}),
+	}).Create(&bucket).Error
+}
+
+// Record counts one request made with a key
+func (t *Tracker) Record(keyID uint, failed bool) error {
+	var errors int64
+	if failed {
+		errors = 1
+	}
+	return t.add(keyID, 1, errors, 0)
+}
+
+// RecordAudio adds transcribed audio to a key's usage
+func (t *Tracker) RecordAudio(keyID uint, seconds float64) error {
+	if seconds <= 0 {
+		return nil
+	}
+	return t.add(keyID, 0, 0, seconds)
+}
+
+// Hourly returns a key's buckets since the given time, oldest first
+func (t *Tracker) Hourly(keyID uint, since time.Time) ([]models.APIKeyUsage, error) {
+	var buckets []models.APIKeyUsage
+	err := t.conn().
+		Where("api_key_id = ? AND hour >= ?", keyID, since.UTC().Truncate(time.Hour)).
+		Order("hour ASC").
+		Find(&buckets).Error
+	return buckets, err
+}
+
+// Usage sums a key's buckets since the given time
+func (t *Tracker) Usage(keyID uint, since time.Time) (Totals, error) {
+	buckets, err := t.Hourly(keyID, since)
+	if err != nil {
+		return Totals{}, err
+	}
+	return sum(buckets), nil
+}
+
+func sum(buckets []models.APIKeyUsage) Totals {
+	var totals Totals
+	var seconds float64
+	for _, bucket := range buckets {
+		totals.Requests += bucket.Requests
+		totals.Errors += bucket.Errors
+		seconds += bucket.AudioSeconds
+	}
+	if totals.Requests > 0 {
+		totals.ErrorRate = float64(totals.Errors) / float64(totals.Requests)
+	}
+	totals.AudioMinutes = seconds / 60
+	return totals
+}
+
+// Check compares a key's last hour against its baseline and records an
+// alert for each anomaly found
+func (t *Tracker) Check(key models.APIKey) ([]models.APIKeyAlert, error) {
+	current := t.currentHour()
+	recentStart := current.Add(-time.Hour)
+
+	history := recentStart.Sub(key.CreatedAt.UTC())
+	if history < t.thresholds.MinHistory {
+		return nil, nil
+	}
+	if history > baselineWindow {
+		history = baselineWindow
+	}
+	hours := history.Hours()
+
+	buckets, err := t.Hourly(key.ID, recentStart.Add(-history))
+	if err != nil {
+		return nil, err
+	}
+
+	// The busier of the previous and the current hour, so a spike is seen
+	// before the hour is over
+	var baseline []models.APIKeyUsage
+	var observed models.APIKeyUsage
+	for _, bucket := range buckets {
+		if bucket.Hour.Before(recentStart) {
+			baseline = append(baseline, bucket)
+		} else if bucket.Requests > observed.Requests {
+			observed = bucket
+		}
+	}
+	base := sum(baseline)
+	baselineRate := float64(base.Requests) / hours
+
+	var alerts []models.APIKeyAlert
+	if observed.Requests >= t.thresholds.MinRequests &&
+		float64(observed.Requests) >= t.thresholds.SpikeFactor*max(baselineRate, 1) {
+		alerts = append(alerts, models.APIKeyAlert{
+			APIKeyID: key.ID,
+			Kind:     AlertRequestSpike,
+			Message: fmt.Sprintf("API key %q made %d requests in an hour, against a baseline of %.1f per hour",
+				key.Name, observed.Requests, baselineRate),
+			Observed: float64(observed.Requests),
+			Baseline: baselineRate,
+		})
+	}
+	if observed.Requests >= t.thresholds.MinErrorRequests {
+		errorRate := float64(observed.Errors) / float64(observed.Requests)
+		if errorRate >= t.thresholds.ErrorRate && base.ErrorRate < t.thresholds.ErrorRate/2 {
+			alerts = append(alerts, models.APIKeyAlert{
+				APIKeyID: key.ID,
+				Kind:     AlertErrorSpike,
+				Message: fmt.Sprintf("%.0f%% of requests with API key %q failed in the last hour, against %.0f%% usually",
+					errorRate*100, key.Name, base.ErrorRate*100),
+				Observed: errorRate,
+				Baseline: base.ErrorRate,
+			})
+		}
+	}
+
+	var created []models.APIKeyAlert
+	for _, alert := range alerts {
+		var recent int64
+		t.conn().Model(&models.APIKeyAlert{}).
+			Where("api_key_id = ? AND kind = ? AND created_at >= ?", key.ID, alert.Kind, t.clock.Now().Add(-alertCooldown)).
+			Count(&recent)
+		if recent > 0 {
+			continue
+		}
+		alert.CreatedAt = t.clock.Now()
+		if err := t.conn().Create(&alert).Error; err != nil {
+			return created, err
+		}
+		logger.Warn("API key usage anomaly", "api_key_id", key.ID, "kind", alert.Kind, "alert_id", alert.ID, "message", alert.Message)
+		created = append(created, alert)
+	}
+	return created, nil
+}
+
+// CheckAll checks every active key
+func (t *Tracker) CheckAll() {
+	var keys []models.APIKey
+	if err := t.conn().Where("is_active = ?", true).Find(&keys).Error; err != nil {
+		logger.Error("Failed to load API keys for usage check", "error", err)
+		return
+	}
+	for _, key := range keys {
+		if _, err := t.Check(key); err != nil {
+			logger.Error("API key usage check failed", "api_key_id", key.ID, "error", err)
+		}
+	}
+}
+
+// Run checks all keys every interval until stop is closed
+func (t *Tracker) Run(stop <-chan struct{}, interval time.Duration) {
+	ticker := t.clock.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C():
+			t.CheckAll()
+		}
+	}
+}
+
+// TrackJobs adds the audio of completed jobs to the submitting key's usage.
+// It returns a function that stops tracking.
+func (t *Tracker) TrackJobs() func() {
+	return jobstate.Subscribe(func(event jobstate.Event) {
+		if event.To != models.StatusCompleted {
+			return
+		}
+		go t.recordJobAudio(event.JobID)
+	})
+}
+
+func (t *Tracker) recordJobAudio(jobID string) {
+	var job models.TranscriptionJob
+	if err := t.conn().Select("id", "api_key_id", "transcript").Where("id = ?", jobID).First(&job).Error; err != nil {
+		return
+	}
+	if job.APIKeyID == nil || job.Transcript == nil {
+		return
+	}
+	if err := t.RecordAudio(*job.APIKeyID, TranscriptSeconds(*job.Transcript)); err != nil {
+		logger.Warn("Failed to record API key audio usage", "job_id", jobID, "error", err)
+	}
+}
+
+// TranscriptSeconds returns the end of the last segment of a stored transcript
+func TranscriptSeconds(transcript string) float64 {
+	var result struct {
+		Segments []struct {
+			End float64 `json:"end"`
+		} `json:"segments"`
+	}
+	if err := json.Unmarshal([]byte(transcript), &result); err != nil {
+		return 0
+	}
+	var end float64
+	for _, segment := range result.Segments {
+		end = max(end, segment.End)
+	}
+	return end
+}
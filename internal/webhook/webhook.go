@@ -0,0 +1,399 @@
+// Package webhook tells external systems when transcription jobs finish.
+// Users register URLs with a secret and the events they want; each event is
+// recorded as a delivery, posted with an HMAC signature and retried with
+// backoff until the receiver accepts it or the attempts run out.
+package webhook
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// Events a webhook can subscribe to
+const (
+	EventJobCompleted = "job.completed"
+	EventJobFailed    = "job.failed"
+)
+
+// Events lists every event, in the order they are documented
+var Events = []string{EventJobCompleted, EventJobFailed}
+
+// Headers sent with every delivery
+const (
+	HeaderEvent     = "X-Synthezia-Event"
+	HeaderDelivery  = "X-Synthezia-Delivery"
+	HeaderTimestamp = "X-Synthezia-Timestamp"
+	HeaderSignature = "X-Synthezia-Signature"
+)
+
+// ErrInvalidURL means a webhook URL is not an absolute http(s) URL
+var ErrInvalidURL = errors.New("webhook URL must be an absolute http or https URL")
+
+// ErrUnknownEvent means an event filter names an event that does not exist
+var ErrUnknownEvent = errors.New("unknown webhook event")
+
+// DefaultBackoff is how long to wait before each retry of a failed delivery.
+// A delivery is attempted once more than there are steps.
+var DefaultBackoff = []time.Duration{30 * time.Second, 2 * time.Minute, 10 * time.Minute, time.Hour, 6 * time.Hour}
+
+// deliveryTimeout bounds a single attempt
+const deliveryTimeout = 10 * time.Second
+
+// maxLoggedResponse caps how much of a failed response is kept as its error
+const maxLoggedResponse = 512
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	Event      string           `json:"event"`
+	DeliveryID uint             `json:"delivery_id"`
+	JobID      string           `json:"job_id"`
+	Title      string           `json:"title,omitempty"`
+	Status     models.JobStatus `json:"status"`
+	Error      string           `json:"error,omitempty"`
+	OccurredAt time.Time        `json:"occurred_at"`
+}
+
+// ValidateURL checks a webhook URL can be delivered to
+func ValidateURL(raw string) error {
+	parsed, err := url.Parse(raw)
+	if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+		return ErrInvalidURL
+	}
+	return nil
+}
+
+// NormalizeEvents checks an event filter and returns it sorted and without
+// duplicates; an empty filter subscribes to every event
+func NormalizeEvents(events []string) ([]string, error) {
+	wanted := map[string]bool{}
+	for _, event := range events {
+		event = strings.TrimSpace(event)
+		if event == "" {
+			continue
+		}
+		known := false
+		for _, name := range Events {
+			known = known || name == event
+		}
+		if !known {
+			return nil, fmt.Errorf("%w: %s", ErrUnknownEvent, event)
+		}
+		wanted[event] = true
+	}
+	normalized := []string{}
+	for _, name := range Events {
+		if wanted[name] {
+			normalized = append(normalized, name)
+		}
+	}
+	return normalized, nil
+}
+
+// SplitEvents returns the event filter stored on a webhook
+func SplitEvents(stored string) []string {
+	if stored == "" {
+		return []string{}
+	}
+	return strings.Split(stored, ",")
+}
+
+// Subscribed reports whether a webhook wants an event
+func Subscribed(hook *models.Webhook, event string) bool {
+	events := SplitEvents(hook.Events)
+	if len(events) == 0 {
+		return true
+	}
+	for _, name := range events {
+		if name == event {
+			return true
+		}
+	}
+	return false
+}
+
+// Sign returns the signature of a delivery: the hex HMAC-SHA256, keyed with
+// the webhook secret, of the timestamp, a dot and the body
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// eventFor names the webhook event of a job status change, if it has one
+func eventFor(status models.JobStatus) (string, bool) {
+	switch status {
+	case models.StatusCompleted:
+		return EventJobCompleted, true
+	case models.StatusFailed:
+		return EventJobFailed, true
+	}
+	return "", false
+}
+
+// Service records and sends webhook deliveries
+type Service struct {
+	db      *gorm.DB
+	client  *http.Client
+	backoff []time.Duration
+
+	mu      sync.Mutex
+	running map[uint]chan struct{}
+}
+
+// NewService creates a delivery service; a nil db uses database.DB at call time
+func NewService(db *gorm.DB) *Service {
+	return &Service{
+		db:      db,
+		client:  &http.Client{Timeout: deliveryTimeout},
+		backoff: DefaultBackoff,
+		running: map[uint]chan struct{}{},
+	}
+}
+
+// SetBackoff overrides the waits between attempts, mainly for tests
+func (s *Service) SetBackoff(backoff []time.Duration) {
+	s.backoff = backoff
+}
+
+func (s *Service) conn() *gorm.DB {
+	if s.db != nil {
+		return s.db
+	}
+	return database.DB
+}
+
+// Track delivers an event to the subscribed webhooks whenever a job completes
+// or fails, until the returned function is called
+func (s *Service) Track() func() {
+	return jobstate.Subscribe(func(event jobstate.Event) {
+		if event.From == event.To {
+			return
+		}
+		if _, ok := eventFor(event.To); !ok {
+			return
+		}
+		go func() {
+			if _, err := s.Notify(event); err != nil {
+				logger.Error("Failed to record webhook deliveries", "job_id", event.JobID, "error", err)
+			}
+		}()
+	})
+}
+
+// Notify records a delivery of a job status change for every active webhook
+// subscribed to it and starts sending them
+func (s *Service) Notify(event jobstate.Event) ([]models.WebhookDelivery, error) {
+	name, ok := eventFor(event.To)
+	if !ok {
+		return nil, nil
+	}
+	db := s.conn()
+
+	var hooks []models.Webhook
+	if err := db.Where("is_active = ?", true).Order("id ASC").Find(&hooks).Error; err != nil {
+		return nil, err
+	}
+	var job models.TranscriptionJob
+	if err := db.Select("id", "title").Where("id = ?", event.JobID).First(&job).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	var deliveries []models.WebhookDelivery
+	for i := range hooks {
+		if !Subscribed(&hooks[i], name) {
+			continue
+		}
+		delivery := models.WebhookDelivery{WebhookID: hooks[i].ID, JobID: event.JobID, Event: name, Status: models.WebhookDeliveryPending}
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&delivery).Error; err != nil {
+				return err
+			}
+			// The payload carries the delivery ID, so it is written once that is known
+			payload := Payload{Event: name, DeliveryID: delivery.ID, JobID: event.JobID, Status: event.To, Error: event.Error, OccurredAt: event.At.UTC()}
+			if job.Title != nil {
+				payload.Title = *job.Title
+			}
+			body, err := json.Marshal(payload)
+			if err != nil {
+				return err
+			}
+			delivery.Payload = string(body)
+			return tx.Model(&delivery).Update("payload", delivery.Payload).Error
+		})
+		if err != nil {
+			return deliveries, fmt.Errorf("failed to record delivery to webhook %d: %w", hooks[i].ID, err)
+		}
+		deliveries = append(deliveries, delivery)
+	}
+	for _, delivery := range deliveries {
+		s.launch(delivery.ID)
+	}
+	return deliveries, nil
+}
+
+// Resume carries on with deliveries still pending, e.g. after a restart
+func (s *Service) Resume() {
+	var ids []uint
+	s.conn().Model(&models.WebhookDelivery{}).Where("status = ?", models.WebhookDeliveryPending).Pluck("id", &ids)
+	for _, id := range ids {
+		logger.Info("Resuming webhook delivery", "delivery_id", id)
+		s.launch(id)
+	}
+}
+
+// Wait blocks until a delivery is no longer being sent or waiting to be retried
+func (s *Service) Wait(id uint) {
+	s.mu.Lock()
+	done, ok := s.running[id]
+	s.mu.Unlock()
+	if ok {
+		<-done
+	}
+}
+
+func (s *Service) launch(id uint) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.running[id]; ok {
+		return
+	}
+	done := make(chan struct{})
+	s.running[id] = done
+
+	go func() {
+		defer func() {
+			s.mu.Lock()
+			delete(s.running, id)
+			s.mu.Unlock()
+			close(done)
+		}()
+		s.deliver(id)
+	}()
+}
+
+// deliver attempts a delivery until it succeeds or runs out of retries
+func (s *Service) deliver(id uint) {
+	db := s.conn()
+	for {
+		var delivery models.WebhookDelivery
+		if err := db.Where("id = ?", id).First(&delivery).Error; err != nil {
+			logger.Error("Failed to load webhook delivery", "delivery_id", id, "error", err)
+			return
+		}
+		if delivery.Status != models.WebhookDeliveryPending {
+			return
+		}
+		if delivery.NextAttemptAt != nil {
+			if wait := time.Until(*delivery.NextAttemptAt); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		var hook models.Webhook
+		if err := db.Where("id = ?", delivery.WebhookID).First(&hook).Error; err != nil {
+			s.finish(&delivery, models.WebhookDeliveryFailed, nil, "webhook no longer exists")
+			return
+		}
+		if !hook.IsActive {
+			s.finish(&delivery, models.WebhookDeliveryFailed, nil, "webhook was disabled")
+			return
+		}
+
+		status, err := s.post(&hook, &delivery)
+		delivery.Attempts++
+		if err == nil {
+			s.finish(&delivery, models.WebhookDeliverySucceeded, status, "")
+			return
+		}
+		if delivery.Attempts > len(s.backoff) {
+			logger.Warn("Webhook delivery failed", "delivery_id", id, "webhook_id", hook.ID, "attempts", delivery.Attempts, "error", err)
+			s.finish(&delivery, models.WebhookDeliveryFailed, status, err.Error())
+			return
+		}
+
+		next := time.Now().Add(s.backoff[delivery.Attempts-1])
+		message := err.Error()
+		if err := db.Model(&delivery).Updates(map[string]interface{}{
+			"attempts":        delivery.Attempts,
+			"response_status": status,
+			"error":           message,
+			"next_attempt_at": next,
+		}).Error; err != nil {
+			logger.Error("Failed to record webhook attempt", "delivery_id", id, "error", err)
+			return
+		}
+	}
+}
+
+// post sends a delivery once, returning the response status if there was one
+func (s *Service) post(hook *models.Webhook, delivery *models.WebhookDelivery) (*int, error) {
+	body := []byte(delivery.Payload)
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "Synthezia-Webhook/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, strconv.FormatUint(uint64(delivery.ID), 10))
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, Sign(hook.Secret, timestamp, body))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	status := resp.StatusCode
+	if status < 200 || status >= 300 {
+		excerpt, _ := io.ReadAll(io.LimitReader(resp.Body, maxLoggedResponse))
+		if text := strings.TrimSpace(string(excerpt)); text != "" {
+			return &status, fmt.Errorf("receiver answered %d: %s", status, text)
+		}
+		return &status, fmt.Errorf("receiver answered %d", status)
+	}
+	io.Copy(io.Discard, resp.Body)
+	return &status, nil
+}
+
+// finish records the final outcome of a delivery
+func (s *Service) finish(delivery *models.WebhookDelivery, status string, responseStatus *int, message string) {
+	updates := map[string]interface{}{
+		"status":          status,
+		"attempts":        delivery.Attempts,
+		"response_status": responseStatus,
+		"next_attempt_at": nil,
+		"error":           nil,
+	}
+	if message != "" {
+		updates["error"] = message
+	}
+	if status == models.WebhookDeliverySucceeded {
+		updates["delivered_at"] = time.Now()
+	}
+	if err := s.conn().Model(delivery).Updates(updates).Error; err != nil {
+		logger.Error("Failed to record webhook delivery", "delivery_id", delivery.ID, "error", err)
+	}
+}
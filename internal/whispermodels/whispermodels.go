@@ -0,0 +1,388 @@
+// Package whispermodels manages the Whisper models WhisperX loads through
+// faster-whisper: which exist, which are already in the local Hugging Face
+// cache, downloading one ahead of the first job that needs it, deleting those
+// no job uses, and warming up the default model at startup so the first job
+// does not pay for the download and the cold load.
+package whispermodels
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"synthezia/internal/database"
+	"synthezia/internal/jobparams"
+	"synthezia/internal/models"
+	"synthezia/pkg/logger"
+
+	"gorm.io/gorm"
+)
+
+// ErrUnknownModel means a name is not a Whisper model faster-whisper knows
+var ErrUnknownModel = errors.New("unknown Whisper model")
+
+// ErrNotDownloaded means a model is not in the cache
+var ErrNotDownloaded = errors.New("model is not downloaded")
+
+// ErrInUse means a model is the default or is picked by a job still to run
+var ErrInUse = errors.New("model is in use")
+
+// ErrDownloading means a model is being downloaded
+var ErrDownloading = errors.New("model is being downloaded")
+
+// downloadTimeout bounds downloading and loading one model
+const downloadTimeout = time.Hour
+
+// Repos maps the model names faster-whisper accepts to the Hugging Face
+// repositories holding their CTranslate2 weights
+var Repos = map[string]string{
+	"tiny.en":          "Systran/faster-whisper-tiny.en",
+	"tiny":             "Systran/faster-whisper-tiny",
+	"base.en":          "Systran/faster-whisper-base.en",
+	"base":             "Systran/faster-whisper-base",
+	"small.en":         "Systran/faster-whisper-small.en",
+	"small":            "Systran/faster-whisper-small",
+	"medium.en":        "Systran/faster-whisper-medium.en",
+	"medium":           "Systran/faster-whisper-medium",
+	"large-v1":         "Systran/faster-whisper-large-v1",
+	"large-v2":         "Systran/faster-whisper-large-v2",
+	"large-v3":         "Systran/faster-whisper-large-v3",
+	"large":            "Systran/faster-whisper-large-v3",
+	"distil-large-v2":  "Systran/faster-distil-whisper-large-v2",
+	"distil-medium.en": "Systran/faster-distil-whisper-medium.en",
+	"distil-small.en":  "Systran/faster-distil-whisper-small.en",
+	"distil-large-v3":  "Systran/faster-distil-whisper-large-v3",
+	"large-v3-turbo":   "mobiuslabsgmbh/faster-whisper-large-v3-turbo",
+	"turbo":            "mobiuslabsgmbh/faster-whisper-large-v3-turbo",
+}
+
+// Names lists the models from smallest to largest
+var Names = []string{
+	"tiny.en", "tiny", "base.en", "base", "small.en", "small", "medium.en", "medium",
+	"large-v1", "large-v2", "large-v3", "large",
+	"distil-small.en", "distil-medium.en", "distil-large-v2", "distil-large-v3",
+	"large-v3-turbo", "turbo",
+}
+
+// fetchScript downloads a model into the cache, then loads it once when a
+// device is given. Its arguments are the repository, the cache directory and
+// optionally the device and compute type.
+const fetchScript = `import sys
+from faster_whisper import WhisperModel, download_model
+path = download_model(sys.argv[1], cache_dir=sys.argv[2])
+if len(sys.argv) > 4:
+    WhisperModel(path, device=sys.argv[3], compute_type=sys.argv[4])
+`
+
+// Model describes one Whisper model and its place in the cache
+type Model struct {
+	Name string `json:"name"`
+	Repo string `json:"repo"`
+	// Default is set on the model jobs get when they pick none
+	Default bool `json:"default"`
+	// Allowed is set when jobs may pick the model
+	Allowed     bool  `json:"allowed"`
+	Downloaded  bool  `json:"downloaded"`
+	Downloading bool  `json:"downloading"`
+	SizeBytes   int64 `json:"size_bytes,omitempty"`
+	// Error is why the last download failed
+	Error string `json:"error,omitempty"`
+}
+
+// Options configure a model manager
+type Options struct {
+	// CacheDir is the Hugging Face hub cache; empty uses the one
+	// faster-whisper finds from the environment, see DefaultCacheDir
+	CacheDir string
+	// Python is the command that runs Python in the WhisperX environment,
+	// before its arguments
+	Python []string
+	// Jobs are the job defaults, naming the default model and those allowed
+	Jobs jobparams.Options
+}
+
+// Manager lists, downloads and deletes Whisper models
+type Manager struct {
+	db   *gorm.DB
+	opts Options
+
+	mu          sync.Mutex
+	downloading map[string]chan struct{}
+	errors      map[string]string
+}
+
+// NewManager creates a model manager; a nil db uses database.DB at call time
+func NewManager(db *gorm.DB, opts Options) *Manager {
+	if opts.CacheDir == "" {
+		opts.CacheDir = DefaultCacheDir()
+	}
+	return &Manager{
+		db:          db,
+		opts:        opts,
+		downloading: map[string]chan struct{}{},
+		errors:      map[string]string{},
+	}
+}
+
+// DefaultCacheDir finds the Hugging Face hub cache the way huggingface_hub
+// does, so the models listed are the ones WhisperX loads
+func DefaultCacheDir() string {
+	if dir := os.Getenv("HF_HUB_CACHE"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("HUGGINGFACE_HUB_CACHE"); dir != "" {
+		return dir
+	}
+	if dir := os.Getenv("HF_HOME"); dir != "" {
+		return filepath.Join(dir, "hub")
+	}
+	if dir := os.Getenv("XDG_CACHE_HOME"); dir != "" {
+		return filepath.Join(dir, "huggingface", "hub")
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return filepath.Join(".cache", "huggingface", "hub")
+	}
+	return filepath.Join(home, ".cache", "huggingface", "hub")
+}
+
+func (m *Manager) conn() *gorm.DB {
+	if m.db != nil {
+		return m.db
+	}
+	return database.DB
+}
+
+// CacheDir returns the directory models are downloaded to
+func (m *Manager) CacheDir() string {
+	return m.opts.CacheDir
+}
+
+// repoDir is where the hub cache keeps a repository
+func (m *Manager) repoDir(repo string) string {
+	return filepath.Join(m.opts.CacheDir, "models--"+strings.ReplaceAll(repo, "/", "--"))
+}
+
+// List describes every model, smallest first
+func (m *Manager) List() []Model {
+	list := make([]Model, 0, len(Names))
+	for _, name := range Names {
+		model, _ := m.Get(name)
+		list = append(list, model)
+	}
+	return list
+}
+
+// Get describes one model
+func (m *Manager) Get(name string) (Model, error) {
+	repo, ok := Repos[name]
+	if !ok {
+		return Model{}, ErrUnknownModel
+	}
+	model := Model{
+		Name:    name,
+		Repo:    repo,
+		Default: name == m.opts.Jobs.Model,
+		Allowed: len(m.opts.Jobs.Models) == 0 || slices.Contains(m.opts.Jobs.Models, name),
+	}
+	model.Downloaded, model.SizeBytes = m.cached(repo)
+
+	m.mu.Lock()
+	_, model.Downloading = m.downloading[repo]
+	model.Error = m.errors[repo]
+	m.mu.Unlock()
+	return model, nil
+}
+
+// cached reports whether a repository has a complete snapshot in the cache,
+// and how much space it takes. Snapshots link to blobs, so only regular
+// files are counted.
+func (m *Manager) cached(repo string) (bool, int64) {
+	dir := m.repoDir(repo)
+	snapshots, _ := filepath.Glob(filepath.Join(dir, "snapshots", "*", "model.bin"))
+	if len(snapshots) == 0 {
+		return false, 0
+	}
+	var size int64
+	filepath.WalkDir(dir, func(_ string, entry fs.DirEntry, err error) error {
+		if err != nil || !entry.Type().IsRegular() {
+			return nil
+		}
+		if info, err := entry.Info(); err == nil {
+			size += info.Size()
+		}
+		return nil
+	})
+	return true, size
+}
+
+// Download starts fetching a model into the cache in the background. It
+// returns false when the model is already downloaded or being downloaded.
+func (m *Manager) Download(name string) (bool, error) {
+	model, err := m.Get(name)
+	if err != nil {
+		return false, err
+	}
+	if model.Downloaded {
+		return false, nil
+	}
+	done, started := m.begin(model.Repo)
+	if !started {
+		return false, nil
+	}
+	go func() {
+		defer m.finish(model.Repo, done)
+		ctx, cancel := context.WithTimeout(context.Background(), downloadTimeout)
+		defer cancel()
+		if err := m.fetch(ctx, model.Repo); err != nil {
+			logger.Error("Failed to download Whisper model", "model", name, "error", err)
+			m.fail(model.Repo, err)
+			return
+		}
+		logger.Info("Downloaded Whisper model", "model", name)
+	}()
+	return true, nil
+}
+
+// Wait blocks until a model is no longer being downloaded
+func (m *Manager) Wait(name string) {
+	m.mu.Lock()
+	done, ok := m.downloading[Repos[name]]
+	m.mu.Unlock()
+	if ok {
+		<-done
+	}
+}
+
+// begin marks a repository as downloading unless it already is
+func (m *Manager) begin(repo string) (chan struct{}, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.downloading[repo]; ok {
+		return nil, false
+	}
+	done := make(chan struct{})
+	m.downloading[repo] = done
+	delete(m.errors, repo)
+	return done, true
+}
+
+func (m *Manager) finish(repo string, done chan struct{}) {
+	m.mu.Lock()
+	delete(m.downloading, repo)
+	m.mu.Unlock()
+	close(done)
+}
+
+func (m *Manager) fail(repo string, err error) {
+	m.mu.Lock()
+	m.errors[repo] = err.Error()
+	m.mu.Unlock()
+}
+
+// fetch runs the fetch script; extra arguments name the device and compute
+// type to load the model with
+func (m *Manager) fetch(ctx context.Context, repo string, load ...string) error {
+	if len(m.opts.Python) == 0 {
+		return fmt.Errorf("no Python command configured")
+	}
+	if err := os.MkdirAll(m.opts.CacheDir, 0755); err != nil {
+		return fmt.Errorf("failed to create model cache: %w", err)
+	}
+	args := append(slices.Clone(m.opts.Python[1:]), "-c", fetchScript, repo, m.opts.CacheDir)
+	args = append(args, load...)
+	cmd := exec.CommandContext(ctx, m.opts.Python[0], args...)
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("%w: %s", err, lastLine(output.String()))
+	}
+	return nil
+}
+
+// lastLine keeps the end of a Python traceback, which names the error
+func lastLine(output string) string {
+	lines := strings.Split(strings.TrimSpace(output), "\n")
+	return strings.TrimSpace(lines[len(lines)-1])
+}
+
+// Delete removes a downloaded model from the cache. The default model and
+// models picked by jobs that have yet to finish are kept, as are models
+// sharing their weights under another name.
+func (m *Manager) Delete(name string) error {
+	model, err := m.Get(name)
+	if err != nil {
+		return err
+	}
+	if model.Downloading {
+		return ErrDownloading
+	}
+	if !model.Downloaded {
+		return ErrNotDownloaded
+	}
+
+	var aliases []string
+	for alias, repo := range Repos {
+		if repo == model.Repo {
+			aliases = append(aliases, alias)
+		}
+	}
+	if slices.Contains(aliases, m.opts.Jobs.Model) {
+		return fmt.Errorf("%w: it is the default model", ErrInUse)
+	}
+	var jobs int64
+	if err := m.conn().Model(&models.TranscriptionJob{}).
+		Where("status IN ? AND model_family = ? AND model IN ?",
+			[]models.JobStatus{models.StatusUploaded, models.StatusPending, models.StatusProcessing, models.StatusPaused},
+			"whisper", aliases).
+		Count(&jobs).Error; err != nil {
+		return fmt.Errorf("failed to check jobs using the model: %w", err)
+	}
+	if jobs > 0 {
+		return fmt.Errorf("%w: %d unfinished jobs use it", ErrInUse, jobs)
+	}
+
+	if err := os.RemoveAll(m.repoDir(model.Repo)); err != nil {
+		return fmt.Errorf("failed to delete model: %w", err)
+	}
+	os.RemoveAll(filepath.Join(m.opts.CacheDir, ".locks", filepath.Base(m.repoDir(model.Repo))))
+	logger.Info("Deleted Whisper model", "model", name, "freed_bytes", model.SizeBytes)
+	return nil
+}
+
+// WarmUp downloads the default model if it is missing and loads it once
+// with the default device and compute type, so the weights are on disk and
+// in the page cache before the first job starts
+func (m *Manager) WarmUp(ctx context.Context) error {
+	name := m.opts.Jobs.Model
+	repo, ok := Repos[name]
+	if !ok {
+		return fmt.Errorf("%w: %s", ErrUnknownModel, name)
+	}
+	done, started := m.begin(repo)
+	if !started {
+		m.Wait(name)
+		return nil
+	}
+	defer m.finish(repo, done)
+
+	start := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, downloadTimeout)
+	defer cancel()
+	if err := m.fetch(ctx, repo, m.opts.Jobs.Device, m.opts.Jobs.ComputeType); err != nil {
+		m.fail(repo, err)
+		return err
+	}
+	logger.Info("Warmed up default Whisper model", "model", name, "duration", time.Since(start).Round(time.Millisecond).String())
+	return nil
+}
@@ -0,0 +1,45 @@
+package fsys
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"path/filepath"
+)
+
+// TempPath returns a fresh path in tempDir for building a file that will end
+// up at name. An empty tempDir uses name's own directory.
+func TempPath(tempDir, name string) string {
+	if tempDir == "" {
+		tempDir = filepath.Dir(name)
+	}
+	suffix := make([]byte, 6)
+	rand.Read(suffix)
+	return filepath.Join(tempDir, "."+filepath.Base(name)+"."+hex.EncodeToString(suffix)+".part")
+}
+
+// WriteFileAtomic copies r into a temporary file in tempDir and renames it to
+// name, so readers never see a partly written file. tempDir should be on the
+// same filesystem as name for the rename to be atomic.
+func WriteFileAtomic(fs FS, tempDir, name string, r io.Reader) (int64, error) {
+	tmp := TempPath(tempDir, name)
+	f, err := fs.Create(tmp)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = fs.Rename(tmp, name)
+	}
+	if err != nil {
+		fs.Remove(tmp)
+		return n, err
+	}
+	return n, nil
+}
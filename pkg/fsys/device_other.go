@@ -0,0 +1,17 @@
+//go:build !unix
+
+package fsys
+
+import "os"
+
+// SameDevice reports whether two existing paths are on the same filesystem.
+// Without device numbers it only checks that both exist and assumes so.
+func SameDevice(a, b string) (bool, error) {
+	if _, err := os.Stat(a); err != nil {
+		return false, err
+	}
+	if _, err := os.Stat(b); err != nil {
+		return false, err
+	}
+	return true, nil
+}
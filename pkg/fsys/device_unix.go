@@ -0,0 +1,27 @@
+//go:build unix
+
+package fsys
+
+import (
+	"os"
+	"syscall"
+)
+
+// SameDevice reports whether two existing paths are on the same filesystem,
+// i.e. whether renaming between them is atomic
+func SameDevice(a, b string) (bool, error) {
+	infoA, err := os.Stat(a)
+	if err != nil {
+		return false, err
+	}
+	infoB, err := os.Stat(b)
+	if err != nil {
+		return false, err
+	}
+	statA, okA := infoA.Sys().(*syscall.Stat_t)
+	statB, okB := infoB.Sys().(*syscall.Stat_t)
+	if !okA || !okB {
+		return true, nil
+	}
+	return statA.Dev == statB.Dev, nil
+}
@@ -0,0 +1,20 @@
+//go:build !unix
+
+package fsys
+
+import "os"
+
+// Locked reports whether name is still open for writing elsewhere. Without
+// advisory locks it tries to open the file for writing, which Windows refuses
+// while a copy is in progress.
+func Locked(name string) (bool, error) {
+	if _, err := os.Stat(name); err != nil {
+		return false, err
+	}
+	f, err := os.OpenFile(name, os.O_RDWR, 0)
+	if err != nil {
+		return true, nil
+	}
+	f.Close()
+	return false, nil
+}
@@ -0,0 +1,27 @@
+//go:build unix
+
+package fsys
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+// Locked reports whether another process holds an exclusive advisory lock on
+// name, as writers that lock their output do while copying
+func Locked(name string) (bool, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return false, err
+	}
+	defer f.Close()
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_SH|syscall.LOCK_NB); err != nil {
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return true, nil
+		}
+		return false, err
+	}
+	syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+	return false, nil
+}
@@ -0,0 +1,303 @@
+package fsys
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemFS is an in-memory FS for tests. Paths are cleaned, so relative and
+// absolute names are distinct but "a/./b" and "a/b" are the same file.
+type MemFS struct {
+	mu    sync.RWMutex
+	nodes map[string]*memNode
+}
+
+type memNode struct {
+	name    string
+	data    []byte
+	mode    os.FileMode
+	modTime time.Time
+	dir     bool
+}
+
+// NewMemFS creates an empty in-memory filesystem
+func NewMemFS() *MemFS {
+	return &MemFS{nodes: map[string]*memNode{}}
+}
+
+func cleanPath(name string) string {
+	return filepath.Clean(name)
+}
+
+func pathErr(op, name string, err error) error {
+	return &os.PathError{Op: op, Path: name, Err: err}
+}
+
+// parentExistsLocked reports whether the parent of name is a directory; callers must hold mu
+func (m *MemFS) parentExistsLocked(name string) bool {
+	parent := filepath.Dir(name)
+	if parent == name || parent == "." || parent == string(filepath.Separator) {
+		return true
+	}
+	node, ok := m.nodes[parent]
+	return ok && node.dir
+}
+
+// Open opens a file for reading
+func (m *MemFS) Open(name string) (File, error) {
+	name = cleanPath(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, pathErr("open", name, os.ErrNotExist)
+	}
+	return &memFile{fs: m, node: node, path: name, readOnly: true}, nil
+}
+
+// Create creates or truncates a file; its directory must already exist
+func (m *MemFS) Create(name string) (File, error) {
+	name = cleanPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if !m.parentExistsLocked(name) {
+		return nil, pathErr("open", name, os.ErrNotExist)
+	}
+	if node, ok := m.nodes[name]; ok && node.dir {
+		return nil, pathErr("open", name, errIsDir)
+	}
+	node := &memNode{name: filepath.Base(name), mode: 0644, modTime: time.Now()}
+	m.nodes[name] = node
+	return &memFile{fs: m, node: node, path: name}, nil
+}
+
+// Stat describes a file or directory
+func (m *MemFS) Stat(name string) (os.FileInfo, error) {
+	name = cleanPath(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, pathErr("stat", name, os.ErrNotExist)
+	}
+	return node.info(), nil
+}
+
+// ReadDir lists the direct children of a directory
+func (m *MemFS) ReadDir(name string) ([]os.FileInfo, error) {
+	name = cleanPath(name)
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	node, ok := m.nodes[name]
+	if !ok {
+		return nil, pathErr("readdir", name, os.ErrNotExist)
+	}
+	if !node.dir {
+		return nil, pathErr("readdir", name, errNotDir)
+	}
+	var infos []os.FileInfo
+	for path, child := range m.nodes {
+		if path != name && filepath.Dir(path) == name {
+			infos = append(infos, child.info())
+		}
+	}
+	return infos, nil
+}
+
+// MkdirAll creates a directory and any missing parents
+func (m *MemFS) MkdirAll(path string, perm os.FileMode) error {
+	path = cleanPath(path)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for dir := path; ; dir = filepath.Dir(dir) {
+		if node, ok := m.nodes[dir]; ok {
+			if !node.dir {
+				return pathErr("mkdir", dir, errNotDir)
+			}
+		} else if dir != "." && dir != string(filepath.Separator) {
+			m.nodes[dir] = &memNode{name: filepath.Base(dir), mode: os.ModeDir | perm, modTime: time.Now(), dir: true}
+		}
+		if parent := filepath.Dir(dir); parent == dir || dir == "." {
+			return nil
+		}
+	}
+}
+
+// Remove deletes a file or an empty directory
+func (m *MemFS) Remove(name string) error {
+	name = cleanPath(name)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.nodes[name]
+	if !ok {
+		return pathErr("remove", name, os.ErrNotExist)
+	}
+	if node.dir {
+		for path := range m.nodes {
+			if path != name && filepath.Dir(path) == name {
+				return pathErr("remove", name, errNotEmpty)
+			}
+		}
+	}
+	delete(m.nodes, name)
+	return nil
+}
+
+// RemoveAll deletes a path and everything below it; missing paths are not an error
+func (m *MemFS) RemoveAll(path string) error {
+	path = cleanPath(path)
+	prefix := path + string(filepath.Separator)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for name := range m.nodes {
+		if name == path || strings.HasPrefix(name, prefix) {
+			delete(m.nodes, name)
+		}
+	}
+	return nil
+}
+
+// Rename moves a file or directory tree
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	oldpath, newpath = cleanPath(oldpath), cleanPath(newpath)
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	node, ok := m.nodes[oldpath]
+	if !ok {
+		return pathErr("rename", oldpath, os.ErrNotExist)
+	}
+	if !m.parentExistsLocked(newpath) {
+		return pathErr("rename", newpath, os.ErrNotExist)
+	}
+
+	oldPrefix := oldpath + string(filepath.Separator)
+	for name, child := range m.nodes {
+		if strings.HasPrefix(name, oldPrefix) {
+			delete(m.nodes, name)
+			m.nodes[newpath+string(filepath.Separator)+strings.TrimPrefix(name, oldPrefix)] = child
+		}
+	}
+	delete(m.nodes, oldpath)
+	node.name = filepath.Base(newpath)
+	m.nodes[newpath] = node
+	return nil
+}
+
+type memError string
+
+func (e memError) Error() string { return string(e) }
+
+const (
+	errIsDir    memError = "is a directory"
+	errNotDir   memError = "not a directory"
+	errNotEmpty memError = "directory not empty"
+	errReadOnly memError = "file opened read-only"
+	errClosed   memError = "file already closed"
+)
+
+func (n *memNode) info() os.FileInfo {
+	return memInfo{name: n.name, size: int64(len(n.data)), mode: n.mode, modTime: n.modTime, dir: n.dir}
+}
+
+type memInfo struct {
+	name    string
+	size    int64
+	mode    os.FileMode
+	modTime time.Time
+	dir     bool
+}
+
+func (i memInfo) Name() string       { return i.name }
+func (i memInfo) Size() int64        { return i.size }
+func (i memInfo) Mode() os.FileMode  { return i.mode }
+func (i memInfo) ModTime() time.Time { return i.modTime }
+func (i memInfo) IsDir() bool        { return i.dir }
+func (i memInfo) Sys() interface{}   { return nil }
+
+// memFile reads and writes a node's data in place
+type memFile struct {
+	fs       *MemFS
+	node     *memNode
+	path     string
+	offset   int64
+	readOnly bool
+	closed   bool
+}
+
+func (f *memFile) Name() string { return f.path }
+
+func (f *memFile) Read(p []byte) (int, error) {
+	if f.closed {
+		return 0, pathErr("read", f.path, errClosed)
+	}
+	if f.node.dir {
+		return 0, pathErr("read", f.path, errIsDir)
+	}
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	if f.offset >= int64(len(f.node.data)) {
+		return 0, io.EOF
+	}
+	n := copy(p, f.node.data[f.offset:])
+	f.offset += int64(n)
+	return n, nil
+}
+
+func (f *memFile) Write(p []byte) (int, error) {
+	if f.closed {
+		return 0, pathErr("write", f.path, errClosed)
+	}
+	if f.readOnly {
+		return 0, pathErr("write", f.path, errReadOnly)
+	}
+	f.fs.mu.Lock()
+	defer f.fs.mu.Unlock()
+	end := f.offset + int64(len(p))
+	if end > int64(len(f.node.data)) {
+		grown := make([]byte, end)
+		copy(grown, f.node.data)
+		f.node.data = grown
+	}
+	copy(f.node.data[f.offset:], p)
+	f.offset = end
+	f.node.modTime = time.Now()
+	return len(p), nil
+}
+
+func (f *memFile) Seek(offset int64, whence int) (int64, error) {
+	f.fs.mu.RLock()
+	size := int64(len(f.node.data))
+	f.fs.mu.RUnlock()
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += size
+	}
+	if offset < 0 {
+		return 0, pathErr("seek", f.path, os.ErrInvalid)
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *memFile) Stat() (os.FileInfo, error) {
+	f.fs.mu.RLock()
+	defer f.fs.mu.RUnlock()
+	return f.node.info(), nil
+}
+
+func (f *memFile) Sync() error { return nil }
+
+func (f *memFile) Close() error {
+	if f.closed {
+		return pathErr("close", f.path, errClosed)
+	}
+	f.closed = true
+	return nil
+}
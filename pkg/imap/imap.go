@@ -0,0 +1,220 @@
+// Package imap is a small IMAP4rev1 client (RFC 3501) covering what a
+// mailbox poller needs: logging in, finding unread messages, downloading them
+// and marking them read. Messages are addressed by UID throughout.
+package imap
+
+import (
+	"bufio"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// MaxLiteral bounds a literal the server may send, such as a message body
+const MaxLiteral = 256 << 20
+
+// Error is a NO or BAD reply to a command
+type Error struct {
+	Command string
+	Status  string
+	Text    string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("imap: %s: %s %s", e.Command, e.Status, e.Text)
+}
+
+// Client is one connection to a server
+type Client struct {
+	conn    net.Conn
+	r       *bufio.Reader
+	tag     int
+	timeout time.Duration
+}
+
+// Dial connects to addr ("host:port"), over TLS when useTLS is set, and
+// reads the server's greeting. timeout bounds each exchange with the server.
+func Dial(addr string, useTLS bool, timeout time.Duration) (*Client, error) {
+	dialer := &net.Dialer{Timeout: timeout}
+	var conn net.Conn
+	var err error
+	if useTLS {
+		host, _, _ := net.SplitHostPort(addr)
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, &tls.Config{ServerName: host})
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c := &Client{conn: conn, r: bufio.NewReader(conn), timeout: timeout}
+	c.conn.SetDeadline(time.Now().Add(timeout))
+	greeting, _, err := c.readLine()
+	if err != nil {
+		conn.Close()
+		return nil, err
+	}
+	if !strings.HasPrefix(greeting, "* OK") && !strings.HasPrefix(greeting, "* PREAUTH") {
+		conn.Close()
+		return nil, fmt.Errorf("imap: unexpected greeting %q", greeting)
+	}
+	return c, nil
+}
+
+// Close drops the connection without logging out
+func (c *Client) Close() error {
+	return c.conn.Close()
+}
+
+// Login authenticates with a username and password
+func (c *Client) Login(username, password string) error {
+	_, err := c.command("LOGIN", quote(username), quote(password))
+	return err
+}
+
+// Select opens mailbox for reading and writing
+func (c *Client) Select(mailbox string) error {
+	_, err := c.command("SELECT", quote(mailbox))
+	return err
+}
+
+// SearchUnseen returns the UIDs of the unread messages in the mailbox
+func (c *Client) SearchUnseen() ([]uint32, error) {
+	replies, err := c.command("UID SEARCH", "UNSEEN")
+	if err != nil {
+		return nil, err
+	}
+	var uids []uint32
+	for _, reply := range replies {
+		fields := strings.Fields(reply.line)
+		if len(fields) < 2 || fields[0] != "*" || !strings.EqualFold(fields[1], "SEARCH") {
+			continue
+		}
+		for _, field := range fields[2:] {
+			uid, err := strconv.ParseUint(field, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("imap: invalid UID %q in search reply", field)
+			}
+			uids = append(uids, uint32(uid))
+		}
+	}
+	return uids, nil
+}
+
+// Fetch downloads the whole message with uid, without marking it read
+func (c *Client) Fetch(uid uint32) ([]byte, error) {
+	replies, err := c.command("UID FETCH", strconv.FormatUint(uint64(uid), 10), "BODY.PEEK[]")
+	if err != nil {
+		return nil, err
+	}
+	for _, reply := range replies {
+		if strings.Contains(strings.ToUpper(reply.line), "FETCH") && len(reply.literals) > 0 {
+			return reply.literals[0], nil
+		}
+	}
+	return nil, fmt.Errorf("imap: message %d not found", uid)
+}
+
+// MarkSeen flags the message with uid as read
+func (c *Client) MarkSeen(uid uint32) error {
+	_, err := c.command("UID STORE", strconv.FormatUint(uint64(uid), 10), "+FLAGS.SILENT", `(\Seen)`)
+	return err
+}
+
+// Logout ends the session and closes the connection
+func (c *Client) Logout() error {
+	_, err := c.command("LOGOUT")
+	c.conn.Close()
+	return err
+}
+
+// reply is an untagged response line, with any literals it carried
+type reply struct {
+	line     string
+	literals [][]byte
+}
+
+// command sends a tagged command and returns the untagged replies that came
+// before its completion, or an *Error if the server refused it
+func (c *Client) command(name string, args ...string) ([]reply, error) {
+	c.tag++
+	tag := "a" + strconv.Itoa(c.tag)
+	c.conn.SetDeadline(time.Now().Add(c.timeout))
+	line := strings.Join(append([]string{tag, name}, args...), " ")
+	if _, err := io.WriteString(c.conn, line+"\r\n"); err != nil {
+		return nil, err
+	}
+
+	var replies []reply
+	for {
+		line, literals, err := c.readLine()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(line, tag+" ") {
+			replies = append(replies, reply{line: line, literals: literals})
+			continue
+		}
+		status, text, _ := strings.Cut(strings.TrimPrefix(line, tag+" "), " ")
+		if strings.EqualFold(status, "OK") {
+			return replies, nil
+		}
+		return nil, &Error{Command: name, Status: strings.ToUpper(status), Text: text}
+	}
+}
+
+// readLine reads one response line. A line ending in a literal "{n}" is
+// followed by n bytes and then the rest of the line; the literals are
+// returned separately and the line keeps only the "{n}" markers.
+func (c *Client) readLine() (string, [][]byte, error) {
+	var line strings.Builder
+	var literals [][]byte
+	for {
+		part, err := c.r.ReadString('\n')
+		if err != nil {
+			return "", nil, err
+		}
+		part = strings.TrimRight(part, "\r\n")
+		line.WriteString(part)
+
+		size, ok := literalSize(part)
+		if !ok {
+			return line.String(), literals, nil
+		}
+		if size > MaxLiteral {
+			return "", nil, fmt.Errorf("imap: literal of %d bytes is too large", size)
+		}
+		// Reading a large message may take longer than one exchange
+		c.conn.SetDeadline(time.Now().Add(c.timeout))
+		literal := make([]byte, size)
+		if _, err := io.ReadFull(c.r, literal); err != nil {
+			return "", nil, err
+		}
+		literals = append(literals, literal)
+	}
+}
+
+// literalSize parses the "{n}" a line ends with when a literal follows
+func literalSize(line string) (int, bool) {
+	if !strings.HasSuffix(line, "}") {
+		return 0, false
+	}
+	open := strings.LastIndexByte(line, '{')
+	if open < 0 {
+		return 0, false
+	}
+	size, err := strconv.Atoi(line[open+1 : len(line)-1])
+	if err != nil || size < 0 {
+		return 0, false
+	}
+	return size, true
+}
+
+// quote makes s a quoted string
+func quote(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
@@ -0,0 +1,57 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"sync"
+)
+
+// ErrorHook receives every ERROR entry, e.g. to forward it to an error
+// tracker. ctx is the context passed to ErrorContext, or context.Background.
+type ErrorHook func(ctx context.Context, entry Entry)
+
+var (
+	errorHook   ErrorHook
+	errorHookMu sync.RWMutex
+)
+
+// SetErrorHook registers a hook called synchronously for each ERROR entry;
+// nil removes it. The hook must not log at ERROR itself.
+func SetErrorHook(hook ErrorHook) {
+	errorHookMu.Lock()
+	errorHook = hook
+	errorHookMu.Unlock()
+	defaultLogger = &Logger{slog.New(newHandler())}
+}
+
+func currentErrorHook() ErrorHook {
+	errorHookMu.RLock()
+	defer errorHookMu.RUnlock()
+	return errorHook
+}
+
+// hookHandler hands ERROR records to an ErrorHook
+type hookHandler struct {
+	hook  ErrorHook
+	attrs attrState
+}
+
+func (h *hookHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= slog.LevelError
+}
+
+func (h *hookHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &hookHandler{hook: h.hook, attrs: h.attrs.withAttrs(attrs)}
+}
+
+func (h *hookHandler) WithGroup(name string) slog.Handler {
+	return &hookHandler{hook: h.hook, attrs: h.attrs.withGroup(name)}
+}
+
+func (h *hookHandler) Handle(ctx context.Context, r slog.Record) error {
+	if r.Level < slog.LevelError {
+		return nil
+	}
+	h.hook(ctx, newEntry(h.attrs, r))
+	return nil
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"sync"
+	"time"
+)
+
+// Job lifecycle events, in the order a transcription normally goes through them
+const (
+	JobEventUploaded     = "uploaded"
+	JobEventQueued       = "queued"
+	JobEventTranscribing = "transcribing"
+	JobEventCompleted    = "completed"
+	JobEventFailed       = "failed"
+	JobEventPaused       = "paused"
+)
+
+// JobEventRecord is one step in a job's timeline
+type JobEventRecord struct {
+	Time  time.Time         `json:"time"`
+	JobID string            `json:"job_id"`
+	Event string            `json:"event"`
+	Attrs map[string]string `json:"attrs,omitempty"`
+}
+
+// JobEventHook receives every job event, e.g. to store it in the database
+type JobEventHook func(ctx context.Context, event JobEventRecord)
+
+var (
+	jobEventHandler slog.Handler
+	jobEventHook    JobEventHook
+	jobEventMu      sync.RWMutex
+)
+
+// SetJobEventOutput writes the job event stream to w as JSON lines, one per
+// event; nil turns the stream off. Events also reach the main log at DEBUG
+// under the jobs module.
+func SetJobEventOutput(w io.Writer) {
+	jobEventMu.Lock()
+	defer jobEventMu.Unlock()
+	if w == nil {
+		jobEventHandler = nil
+		return
+	}
+	jobEventHandler = slog.NewJSONHandler(w, nil)
+}
+
+// SetJobEventHook registers a hook called synchronously for each job event;
+// nil removes it
+func SetJobEventHook(hook JobEventHook) {
+	jobEventMu.Lock()
+	jobEventHook = hook
+	jobEventMu.Unlock()
+}
+
+var jobsLog = Module(ModuleJobs)
+
+// JobEvent records that a job reached a point in its lifecycle, such as
+// JobEventQueued. args are slog-style key/value pairs stored with the event.
+func JobEvent(ctx context.Context, jobID, event string, args ...any) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	jobsLog.DebugContext(ctx, "Job event", append([]any{"job_id", jobID, "event", event}, args...)...)
+
+	jobEventMu.RLock()
+	handler, hook := jobEventHandler, jobEventHook
+	jobEventMu.RUnlock()
+	if handler == nil && hook == nil {
+		return
+	}
+
+	r := slog.NewRecord(time.Now(), slog.LevelInfo, event, 0)
+	r.Add(args...)
+	if handler != nil {
+		stream := r.Clone()
+		stream.Message = "job_event"
+		stream.Add("job_id", jobID, "event", event)
+		handler.Handle(ctx, stream)
+	}
+	if hook != nil {
+		entry := newEntry(attrState{}, r)
+		hook(ctx, JobEventRecord{Time: r.Time, JobID: jobID, Event: event, Attrs: entry.Attrs})
+	}
+}
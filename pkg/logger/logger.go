@@ -1,11 +1,13 @@
 package logger
 
 import (
+	"context"
 	"fmt"
 	"io"
 	"log/slog"
 	"os"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -26,45 +28,95 @@ const (
 	LevelError
 )
 
+// Module names with their own LOG_LEVEL_<MODULE> override
+const (
+	ModuleHTTP     = "http"
+	ModuleDropzone = "dropzone"
+	ModuleQueue    = "queue"
+	ModuleJobs     = "jobs"
+)
+
 var (
 	// Default logger instance
 	defaultLogger *Logger
 	// Current log level
 	currentLevel = LevelInfo
+	// Destination for log lines
+	output io.Writer = os.Stdout
+	// Per-module overrides of currentLevel, keyed by lower-case module name
+	moduleLevels   = map[string]LogLevel{}
+	moduleLevelsMu sync.RWMutex
+	// Extracts extra attributes (e.g. trace IDs) from a context
+	contextAttrs func(ctx context.Context) []any
 )
 
-// Init initializes the global logger with specified level
+// SetContextAttrs registers a function that adds attributes from a context to
+// log lines written with the *Context helpers
+func SetContextAttrs(fn func(ctx context.Context) []any) {
+	contextAttrs = fn
+}
+
+// withContextAttrs appends context attributes, including the job ID set by
+// WithJobID unless args already name one, to args
+func withContextAttrs(ctx context.Context, args []any) []any {
+	if ctx == nil {
+		return args
+	}
+	if jobID := JobIDFromContext(ctx); jobID != "" && !hasKey(args, "job_id") {
+		args = append(args, "job_id", jobID)
+	}
+	if contextAttrs == nil {
+		return args
+	}
+	return append(args, contextAttrs(ctx)...)
+}
+
+// hasKey reports whether key appears as a key in slog-style args
+func hasKey(args []any, key string) bool {
+	for i := 0; i+1 < len(args); i += 2 {
+		if k, ok := args[i].(string); ok && k == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Init initializes the global logger with specified level. Module overrides
+// are read from LOG_LEVEL_<MODULE> environment variables.
 func Init(level string) {
 	// Parse log level from environment or parameter
-	switch strings.ToLower(level) {
-	case "debug":
-		currentLevel = LevelDebug
-	case "info", "":
-		currentLevel = LevelInfo
-	case "warn", "warning":
-		currentLevel = LevelWarn
-	case "error":
-		currentLevel = LevelError
-	default:
-		currentLevel = LevelInfo
+	currentLevel, _ = parseLevel(level)
+
+	moduleLevelsMu.Lock()
+	moduleLevels = map[string]LogLevel{}
+	for _, env := range os.Environ() {
+		key, value, _ := strings.Cut(env, "=")
+		module, ok := strings.CutPrefix(key, "LOG_LEVEL_")
+		if !ok || module == "" {
+			continue
+		}
+		if moduleLevel, valid := parseLevel(value); valid {
+			moduleLevels[strings.ToLower(module)] = moduleLevel
+		}
 	}
+	moduleLevelsMu.Unlock()
 
-	// Configure slog level
-	var slogLevel slog.Level
-	switch currentLevel {
-	case LevelDebug:
-		slogLevel = slog.LevelDebug
-	case LevelInfo:
-		slogLevel = slog.LevelInfo
-	case LevelWarn:
-		slogLevel = slog.LevelWarn
-	case LevelError:
-		slogLevel = slog.LevelError
-	}
+	defaultLogger = &Logger{slog.New(newHandler())}
+}
+
+// SetOutput redirects log lines to w
+func SetOutput(w io.Writer) {
+	output = w
+	defaultLogger = &Logger{slog.New(newHandler())}
+}
 
+// newHandler builds the text handler. It accepts every level and leaves
+// filtering to moduleHandler so module overrides can be more verbose than
+// the global level.
+func newHandler() slog.Handler {
 	// Create handler with optimized settings
 	opts := &slog.HandlerOptions{
-		Level:     slogLevel,
+		Level:     slog.LevelDebug,
 		AddSource: false, // Clean logs without source info
 		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
 			// Clean timestamp format
@@ -93,8 +145,96 @@ func Init(level string) {
 	}
 
 	// Use text handler for clean, readable output
-	handler := slog.NewTextHandler(os.Stdout, opts)
-	defaultLogger = &Logger{slog.New(handler)}
+	handlers := fanoutHandler(sinkHandlers())
+	if stdoutEnabled {
+		handlers = append(fanoutHandler{slog.NewTextHandler(output, opts)}, handlers...)
+	}
+	switch len(handlers) {
+	case 0:
+		return &moduleHandler{Handler: slog.NewTextHandler(io.Discard, opts)}
+	case 1:
+		return &moduleHandler{Handler: handlers[0]}
+	}
+	return &moduleHandler{Handler: handlers}
+}
+
+// parseLevel converts a level name; unknown names fall back to info
+func parseLevel(level string) (LogLevel, bool) {
+	switch strings.ToLower(strings.TrimSpace(level)) {
+	case "debug":
+		return LevelDebug, true
+	case "info", "":
+		return LevelInfo, true
+	case "warn", "warning":
+		return LevelWarn, true
+	case "error":
+		return LevelError, true
+	default:
+		return LevelInfo, false
+	}
+}
+
+func (l LogLevel) slogLevel() slog.Level {
+	switch l {
+	case LevelDebug:
+		return slog.LevelDebug
+	case LevelWarn:
+		return slog.LevelWarn
+	case LevelError:
+		return slog.LevelError
+	default:
+		return slog.LevelInfo
+	}
+}
+
+// SetModuleLevel overrides the level for one module; an empty level removes the override
+func SetModuleLevel(module, level string) {
+	moduleLevelsMu.Lock()
+	defer moduleLevelsMu.Unlock()
+	module = strings.ToLower(module)
+	if level == "" {
+		delete(moduleLevels, module)
+		return
+	}
+	moduleLevels[module], _ = parseLevel(level)
+}
+
+// ModuleLevel returns the effective level for a module
+func ModuleLevel(module string) LogLevel {
+	if module == "" {
+		return currentLevel
+	}
+	moduleLevelsMu.RLock()
+	defer moduleLevelsMu.RUnlock()
+	if level, ok := moduleLevels[strings.ToLower(module)]; ok {
+		return level
+	}
+	return currentLevel
+}
+
+// moduleHandler filters records by the level of the module the logger was
+// tagged with via a "module" attribute
+type moduleHandler struct {
+	slog.Handler
+	module string
+}
+
+func (h *moduleHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= ModuleLevel(h.module).slogLevel()
+}
+
+func (h *moduleHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	module := h.module
+	for _, attr := range attrs {
+		if attr.Key == "module" {
+			module = attr.Value.String()
+		}
+	}
+	return &moduleHandler{Handler: h.Handler.WithAttrs(attrs), module: module}
+}
+
+func (h *moduleHandler) WithGroup(name string) slog.Handler {
+	return &moduleHandler{Handler: h.Handler.WithGroup(name), module: h.module}
 }
 
 // Get returns the default logger instance
@@ -136,11 +276,77 @@ func Error(msg string, args ...any) {
 	}
 }
 
-// WithContext creates a logger with additional context
+// Context-aware variants attach attributes such as trace_id and span_id
+
+func DebugContext(ctx context.Context, msg string, args ...any) {
+	if currentLevel <= LevelDebug {
+		Get().DebugContext(ctx, msg, withContextAttrs(ctx, args)...)
+	}
+}
+
+func InfoContext(ctx context.Context, msg string, args ...any) {
+	if currentLevel <= LevelInfo {
+		Get().InfoContext(ctx, msg, withContextAttrs(ctx, args)...)
+	}
+}
+
+func WarnContext(ctx context.Context, msg string, args ...any) {
+	if currentLevel <= LevelWarn {
+		Get().WarnContext(ctx, msg, withContextAttrs(ctx, args)...)
+	}
+}
+
+func ErrorContext(ctx context.Context, msg string, args ...any) {
+	if currentLevel <= LevelError {
+		Get().ErrorContext(ctx, msg, withContextAttrs(ctx, args)...)
+	}
+}
+
+// WithContext creates a logger with additional context. Using the "module"
+// key tags the logger with a module and applies its LOG_LEVEL_<MODULE> override.
 func WithContext(key string, value any) *Logger {
 	return &Logger{Get().With(key, value)}
 }
 
+// Module returns a logger tagged with a module name. It writes through
+// whichever default logger is current, so it can be kept in a package
+// variable that outlives later Init or SetOutput calls.
+func Module(name string) *Logger {
+	return &Logger{slog.New(&deferredHandler{module: name})}
+}
+
+// deferredHandler resolves the default handler on every record
+type deferredHandler struct {
+	module string
+	wraps  []func(slog.Handler) slog.Handler
+}
+
+func (h *deferredHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return level >= ModuleLevel(h.module).slogLevel()
+}
+
+func (h *deferredHandler) Handle(ctx context.Context, r slog.Record) error {
+	r.Add(withContextAttrs(ctx, nil)...)
+	handler := Get().Handler().WithAttrs([]slog.Attr{slog.String("module", h.module)})
+	for _, wrap := range h.wraps {
+		handler = wrap(handler)
+	}
+	return handler.Handle(ctx, r)
+}
+
+func (h *deferredHandler) with(wrap func(slog.Handler) slog.Handler) *deferredHandler {
+	wraps := append(append([]func(slog.Handler) slog.Handler{}, h.wraps...), wrap)
+	return &deferredHandler{module: h.module, wraps: wraps}
+}
+
+func (h *deferredHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithAttrs(attrs) })
+}
+
+func (h *deferredHandler) WithGroup(name string) slog.Handler {
+	return h.with(func(next slog.Handler) slog.Handler { return next.WithGroup(name) })
+}
+
 // Startup logging for key initialization steps
 func Startup(step, message string, args ...any) {
 	// Simple message at INFO level, technical details at DEBUG
@@ -179,24 +385,21 @@ func JobFailed(jobID string, duration time.Duration, err error) {
 		"error", err.Error())
 }
 
-// HTTP request logging - filtered for INFO level
+// httpLog carries the http module tag for request logging
+var httpLog = Module(ModuleHTTP)
+
+// HTTP request logging - sampled at INFO level
 func HTTPRequest(method, path string, status int, duration time.Duration, userAgent string) {
-	// Skip noisy endpoints at INFO level
-	if currentLevel <= LevelInfo {
-		switch path {
-		case "/api/v1/transcription/list", "/health":
-			// Skip logging frequent status checks at INFO level
-			return
-		}
-		if strings.HasSuffix(path, "/status") || strings.HasSuffix(path, "/track-progress") {
-			// Skip job status polling at INFO level
-			return
-		}
+	level := ModuleLevel(ModuleHTTP)
+
+	// Thin out noisy endpoints at INFO level
+	if level <= LevelInfo && status < 400 && !sampleHTTP(path) {
+		return
 	}
 	
 	// Log all requests at DEBUG level
-	if currentLevel <= LevelDebug {
-		Debug("API request", 
+	if level <= LevelDebug {
+		httpLog.Debug("API request", 
 			"method", method,
 			"path", path,
 			"status", status,
@@ -248,44 +451,102 @@ func GinLogger() gin.HandlerFunc {
 			path = path + "?" + raw
 		}
 
-		// Format log message based on level
-		if currentLevel <= LevelInfo {
-			// Clean format for INFO level, skip noisy endpoints
-			switch {
-			case strings.Contains(path, "/status") || strings.Contains(path, "/track-progress"):
-				return // Skip status polling
-			case path == "/api/v1/transcription/list" || path == "/health":
-				return // Skip frequent list calls
+		// Requests over budget are reported, including the polling endpoints skipped below
+		if budget := CurrentSlowThresholds().Request; budget > 0 && duration > budget {
+			jobID := JobIDFromContext(c.Request.Context())
+			if id := c.Param("id"); jobID == "" && strings.Contains(c.FullPath(), "/transcription/") {
+				jobID = id
 			}
+			args := []any{
+				"method", c.Request.Method,
+				"path", path,
+				"route", c.FullPath(),
+				"status", c.Writer.Status(),
+				"duration", formatMs(duration),
+				"budget", formatMs(budget),
+				"ip", c.ClientIP(),
+				"user_agent", c.Request.UserAgent()}
+			if jobID != "" {
+				args = append(args, "job_id", jobID)
+			}
+			httpLog.Warn("Slow request", withContextAttrs(c.Request.Context(), args)...)
 		}
 
-		// Log request
+		// Format log message based on the http module level
+		level := ModuleLevel(ModuleHTTP)
 		status := c.Writer.Status()
+
+		// At INFO and below noisy endpoints are sampled. Failed
+		// requests are always logged.
+		if level <= LevelInfo && status < 400 && !sampleHTTP(c.Request.URL.Path) {
+			return
+		}
+
+		// Log request
 		statusColor := getStatusColor(status)
+
+		// Above INFO only failed requests are worth a line
+		if (level == LevelWarn && status < 400) || (level >= LevelError && status < 500) {
+			return
+		}
 		
-		if currentLevel <= LevelDebug {
+		if level <= LevelDebug {
 			// Detailed logging for DEBUG
-			Debug("API request",
+			httpLog.Debug("API request", withContextAttrs(c.Request.Context(), []any{
 				"method", c.Request.Method,
 				"path", path,
 				"status", status,
 				"duration", fmt.Sprintf("%.2fms", float64(duration.Nanoseconds())/1e6),
 				"ip", c.ClientIP(),
-				"user_agent", c.Request.UserAgent())
+				"user_agent", c.Request.UserAgent()})...)
 		} else {
 			// Clean format for INFO: "INFO  15:04:05 GET /api/v1/transcription/submit 200 5.13ms"
-			fmt.Printf("INFO  %s %s %s %s%d%s %s\n",
-				time.Now().Format("15:04:05"),
-				c.Request.Method,
-				path,
-				statusColor,
-				status,
-				"\033[0m", // Reset color
-				fmt.Sprintf("%.2fms", float64(duration.Nanoseconds())/1e6))
+			label := "INFO "
+			if level == LevelWarn {
+				label = "WARN "
+			} else if level >= LevelError {
+				label = "ERROR"
+			}
+			if stdoutEnabled {
+				fmt.Fprintf(output, "%s %s %s %s %s%d%s %s%s\n",
+					label,
+					time.Now().Format("15:04:05"),
+					c.Request.Method,
+					path,
+					statusColor,
+					status,
+					"\033[0m", // Reset color
+					fmt.Sprintf("%.2fms", float64(duration.Nanoseconds())/1e6),
+					formatContextAttrs(c.Request.Context()))
+			}
+
+			// Syslog and journald get a structured record, prioritized by status
+			sinkLevel := slog.LevelInfo
+			if status >= 500 {
+				sinkLevel = slog.LevelError
+			} else if status >= 400 {
+				sinkLevel = slog.LevelWarn
+			}
+			logToSinks(c.Request.Context(), sinkLevel, "API request", withContextAttrs(c.Request.Context(), []any{
+				"module", ModuleHTTP,
+				"method", c.Request.Method,
+				"path", path,
+				"status", status,
+				"duration", fmt.Sprintf("%.2fms", float64(duration.Nanoseconds())/1e6)})...)
 		}
 	}
 }
 
+// formatContextAttrs renders context attributes as " key=value" pairs
+func formatContextAttrs(ctx context.Context) string {
+	attrs := withContextAttrs(ctx, nil)
+	var b strings.Builder
+	for i := 0; i+1 < len(attrs); i += 2 {
+		fmt.Fprintf(&b, " %v=%v", attrs[i], attrs[i+1])
+	}
+	return b.String()
+}
+
 // getStatusColor returns ANSI color codes for HTTP status codes
 func getStatusColor(status int) string {
 	switch {
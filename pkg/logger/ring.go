@@ -0,0 +1,165 @@
+package logger
+
+import (
+	"context"
+	"log/slog"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultBufferSize is how many recent entries are kept in memory
+const DefaultBufferSize = 1000
+
+// Entry is a log record kept in the in-memory buffer
+type Entry struct {
+	Time    time.Time         `json:"time"`
+	Level   string            `json:"level"`
+	Module  string            `json:"module,omitempty"`
+	Message string            `json:"message"`
+	Attrs   map[string]string `json:"attrs,omitempty"`
+}
+
+// EntryFilter selects entries from the buffer
+type EntryFilter struct {
+	MinLevel LogLevel
+	Since    time.Time
+	Module   string
+	Limit    int // Newest entries to return; 0 returns all matches
+}
+
+// RingBuffer keeps the last N log entries
+type RingBuffer struct {
+	mu      sync.RWMutex
+	entries []Entry
+	next    int
+	full    bool
+}
+
+// NewRingBuffer creates a buffer holding up to size entries
+func NewRingBuffer(size int) *RingBuffer {
+	return &RingBuffer{entries: make([]Entry, size)}
+}
+
+// Add stores an entry, replacing the oldest when the buffer is full
+func (b *RingBuffer) Add(entry Entry) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if len(b.entries) == 0 {
+		return
+	}
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Capacity returns the maximum number of entries kept
+func (b *RingBuffer) Capacity() int {
+	return len(b.entries)
+}
+
+// Entries returns matching entries, oldest first
+func (b *RingBuffer) Entries(filter EntryFilter) []Entry {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	ordered := b.entries[:b.next]
+	if b.full {
+		ordered = append(append([]Entry{}, b.entries[b.next:]...), b.entries[:b.next]...)
+	}
+
+	result := []Entry{}
+	for _, entry := range ordered {
+		level, _ := parseLevel(entry.Level)
+		if level < filter.MinLevel || entry.Time.Before(filter.Since) {
+			continue
+		}
+		if filter.Module != "" && !strings.EqualFold(entry.Module, filter.Module) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	if filter.Limit > 0 && len(result) > filter.Limit {
+		result = result[len(result)-filter.Limit:]
+	}
+	return result
+}
+
+// Clear drops all entries
+func (b *RingBuffer) Clear() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries = make([]Entry, len(b.entries))
+	b.next, b.full = 0, false
+}
+
+// recent holds the latest entries for the admin log endpoint
+var recent = NewRingBuffer(DefaultBufferSize)
+
+// SetBufferSize resizes the in-memory log buffer, dropping its contents;
+// 0 disables it
+func SetBufferSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	recent = NewRingBuffer(size)
+	defaultLogger = &Logger{slog.New(newHandler())}
+}
+
+// Recent returns the in-memory log buffer
+func Recent() *RingBuffer {
+	return recent
+}
+
+// ringHandler records into a RingBuffer
+type ringHandler struct {
+	buffer *RingBuffer
+	attrs  attrState
+}
+
+func (h *ringHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *ringHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &ringHandler{buffer: h.buffer, attrs: h.attrs.withAttrs(attrs)}
+}
+
+func (h *ringHandler) WithGroup(name string) slog.Handler {
+	return &ringHandler{buffer: h.buffer, attrs: h.attrs.withGroup(name)}
+}
+
+func (h *ringHandler) Handle(_ context.Context, r slog.Record) error {
+	h.buffer.Add(newEntry(h.attrs, r))
+	return nil
+}
+
+// newEntry flattens a record and the handler's attributes into an Entry
+func newEntry(attrs attrState, r slog.Record) Entry {
+	entry := Entry{Time: r.Time, Level: levelName(r.Level), Message: r.Message}
+	for _, f := range attrs.recordFields(r) {
+		if f.key == "module" {
+			entry.Module = f.value
+			continue
+		}
+		if entry.Attrs == nil {
+			entry.Attrs = map[string]string{}
+		}
+		entry.Attrs[f.key] = f.value
+	}
+	return entry
+}
+
+// levelName returns the lower-case name accepted by parseLevel
+func levelName(level slog.Level) string {
+	switch {
+	case level >= slog.LevelError:
+		return "error"
+	case level >= slog.LevelWarn:
+		return "warn"
+	case level >= slog.LevelInfo:
+		return "info"
+	default:
+		return "debug"
+	}
+}
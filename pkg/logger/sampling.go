@@ -0,0 +1,118 @@
+package logger
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// SampleRule logs one in Rate successful requests whose path matches
+// Pattern. A Rate of 0 drops them all, 1 keeps them all. In Pattern, *
+// matches any run of characters, including slashes.
+type SampleRule struct {
+	Pattern string
+	Rate    uint64
+}
+
+// DefaultHTTPSampling drops the polling and health check endpoints that
+// would otherwise flood INFO output
+var DefaultHTTPSampling = []SampleRule{
+	{Pattern: "/health", Rate: 0},
+	{Pattern: "/api/v1/transcription/list", Rate: 0},
+	{Pattern: "*/status", Rate: 0},
+	{Pattern: "*/track-progress", Rate: 0},
+}
+
+type sampler struct {
+	rule SampleRule
+	seen atomic.Uint64
+}
+
+var (
+	httpSamplers   = newSamplers(DefaultHTTPSampling)
+	httpSamplersMu sync.RWMutex
+)
+
+func newSamplers(rules []SampleRule) []*sampler {
+	samplers := make([]*sampler, len(rules))
+	for i, rule := range rules {
+		samplers[i] = &sampler{rule: rule}
+	}
+	return samplers
+}
+
+// SetHTTPSampling replaces the request sampling rules. The first matching
+// rule applies; paths no rule matches are always logged.
+func SetHTTPSampling(rules []SampleRule) {
+	httpSamplersMu.Lock()
+	httpSamplers = newSamplers(rules)
+	httpSamplersMu.Unlock()
+}
+
+// ParseSampleRules parses "pattern=rate" pairs separated by commas, e.g.
+// "/health=100,*/status=0". An empty spec returns DefaultHTTPSampling.
+func ParseSampleRules(spec string) ([]SampleRule, error) {
+	if strings.TrimSpace(spec) == "" {
+		return DefaultHTTPSampling, nil
+	}
+	var rules []SampleRule
+	for _, pair := range strings.Split(spec, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+		pattern, rate, ok := strings.Cut(pair, "=")
+		pattern = strings.TrimSpace(pattern)
+		if !ok || pattern == "" {
+			return nil, fmt.Errorf("invalid HTTP sampling rule %q: expected pattern=rate", pair)
+		}
+		n, err := strconv.ParseUint(strings.TrimSpace(rate), 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP sampling rate in %q: must be a whole number", pair)
+		}
+		rules = append(rules, SampleRule{Pattern: pattern, Rate: n})
+	}
+	return rules, nil
+}
+
+// sampleHTTP reports whether a successful request to path should be logged
+func sampleHTTP(path string) bool {
+	httpSamplersMu.RLock()
+	samplers := httpSamplers
+	httpSamplersMu.RUnlock()
+
+	for _, s := range samplers {
+		if !matchPattern(s.rule.Pattern, path) {
+			continue
+		}
+		if s.rule.Rate == 0 {
+			return false
+		}
+		// Log the first request of every Rate
+		return (s.seen.Add(1)-1)%s.rule.Rate == 0
+	}
+	return true
+}
+
+// matchPattern matches path against a pattern where * stands for any run
+// of characters
+func matchPattern(pattern, path string) bool {
+	parts := strings.Split(pattern, "*")
+	if len(parts) == 1 {
+		return pattern == path
+	}
+	if !strings.HasPrefix(path, parts[0]) {
+		return false
+	}
+	path = path[len(parts[0]):]
+	for _, part := range parts[1 : len(parts)-1] {
+		i := strings.Index(path, part)
+		if i < 0 {
+			return false
+		}
+		path = path[i+len(part):]
+	}
+	return strings.HasSuffix(path, parts[len(parts)-1])
+}
@@ -0,0 +1,485 @@
+package logger
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"log/slog"
+	"net"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Output targets accepted in SinkConfig.Outputs
+const (
+	OutputStdout   = "stdout"
+	OutputSyslog   = "syslog"
+	OutputJournald = "journald"
+)
+
+// DefaultJournaldSocket is where systemd-journald listens for native messages
+const DefaultJournaldSocket = "/run/systemd/journal/socket"
+
+// SinkConfig selects where log lines are written
+type SinkConfig struct {
+	Outputs        []string // Any of stdout, syslog, journald; empty means stdout
+	SyslogAddress  string   // udp://host:514 or tcp://host:601
+	SyslogFacility string   // e.g. daemon, local0; defaults to daemon
+	JournaldSocket string   // Defaults to DefaultJournaldSocket
+	AppName        string   // SYSLOG_IDENTIFIER / APP-NAME; defaults to synthezia
+}
+
+var (
+	// Non-stdout handlers receiving every record
+	sinks []slog.Handler
+	// Whether the human-readable stdout handler is active
+	stdoutEnabled = true
+)
+
+// ParseOutputs splits a comma-separated LOG_OUTPUT value
+func ParseOutputs(value string) []string {
+	var outputs []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+			outputs = append(outputs, part)
+		}
+	}
+	return outputs
+}
+
+// ConfigureSinks replaces the log outputs. On error the previous outputs
+// stay in place.
+func ConfigureSinks(cfg SinkConfig) error {
+	if cfg.AppName == "" {
+		cfg.AppName = "synthezia"
+	}
+	outputs := cfg.Outputs
+	if len(outputs) == 0 {
+		outputs = []string{OutputStdout}
+	}
+
+	var newSinks []slog.Handler
+	useStdout := false
+	for _, output := range outputs {
+		switch output {
+		case OutputStdout:
+			useStdout = true
+		case OutputSyslog:
+			handler, err := newSyslogHandler(cfg.SyslogAddress, cfg.SyslogFacility, cfg.AppName)
+			if err != nil {
+				closeSinks(newSinks)
+				return err
+			}
+			newSinks = append(newSinks, handler)
+		case OutputJournald:
+			socket := cfg.JournaldSocket
+			if socket == "" {
+				socket = DefaultJournaldSocket
+			}
+			handler, err := newJournaldHandler(socket, cfg.AppName)
+			if err != nil {
+				closeSinks(newSinks)
+				return err
+			}
+			newSinks = append(newSinks, handler)
+		default:
+			closeSinks(newSinks)
+			return fmt.Errorf("unknown log output %q (expected stdout, syslog or journald)", output)
+		}
+	}
+
+	old := sinks
+	sinks, stdoutEnabled = newSinks, useStdout
+	defaultLogger = &Logger{slog.New(newHandler())}
+	closeSinks(old)
+	return nil
+}
+
+func closeSinks(handlers []slog.Handler) {
+	for _, handler := range handlers {
+		if closer, ok := handler.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+}
+
+// logToSinks writes a record only to the non-stdout sinks; used for lines
+// that stdout renders in its own format
+func logToSinks(ctx context.Context, level slog.Level, msg string, args ...any) {
+	handlers := sinkHandlers()
+	if len(handlers) == 0 {
+		return
+	}
+	r := slog.NewRecord(time.Now(), level, msg, 0)
+	r.Add(args...)
+	for _, handler := range handlers {
+		handler.Handle(ctx, r)
+	}
+}
+
+// sinkHandlers returns the configured sinks plus the in-memory buffer
+func sinkHandlers() []slog.Handler {
+	handlers := append([]slog.Handler{}, sinks...)
+	if recent.Capacity() > 0 {
+		handlers = append(handlers, &ringHandler{buffer: recent})
+	}
+	if hook := currentErrorHook(); hook != nil {
+		handlers = append(handlers, &hookHandler{hook: hook})
+	}
+	return handlers
+}
+
+// fanoutHandler sends each record to several handlers
+type fanoutHandler []slog.Handler
+
+func (f fanoutHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return true // moduleHandler filters levels in front of us
+}
+
+func (f fanoutHandler) Handle(ctx context.Context, r slog.Record) error {
+	var firstErr error
+	for _, handler := range f {
+		if err := handler.Handle(ctx, r.Clone()); err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return firstErr
+}
+
+func (f fanoutHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, handler := range f {
+		out[i] = handler.WithAttrs(attrs)
+	}
+	return out
+}
+
+func (f fanoutHandler) WithGroup(name string) slog.Handler {
+	out := make(fanoutHandler, len(f))
+	for i, handler := range f {
+		out[i] = handler.WithGroup(name)
+	}
+	return out
+}
+
+// field is a flattened attribute; group names are joined with dots
+type field struct {
+	key   string
+	value string
+}
+
+// attrState carries attributes and groups added through WithAttrs/WithGroup
+type attrState struct {
+	fields []field
+	prefix string
+}
+
+func (s attrState) withAttrs(attrs []slog.Attr) attrState {
+	fields := append([]field{}, s.fields...)
+	for _, attr := range attrs {
+		fields = appendAttr(fields, s.prefix, attr)
+	}
+	return attrState{fields: fields, prefix: s.prefix}
+}
+
+func (s attrState) withGroup(name string) attrState {
+	if name == "" {
+		return s
+	}
+	return attrState{fields: s.fields, prefix: s.prefix + name + "."}
+}
+
+// recordFields returns the handler's fields followed by the record's
+func (s attrState) recordFields(r slog.Record) []field {
+	fields := append([]field{}, s.fields...)
+	r.Attrs(func(attr slog.Attr) bool {
+		fields = appendAttr(fields, s.prefix, attr)
+		return true
+	})
+	return fields
+}
+
+func appendAttr(fields []field, prefix string, attr slog.Attr) []field {
+	attr.Value = attr.Value.Resolve()
+	if attr.Equal(slog.Attr{}) {
+		return fields
+	}
+	if attr.Value.Kind() == slog.KindGroup {
+		groupPrefix := prefix
+		if attr.Key != "" {
+			groupPrefix += attr.Key + "."
+		}
+		for _, child := range attr.Value.Group() {
+			fields = appendAttr(fields, groupPrefix, child)
+		}
+		return fields
+	}
+	return append(fields, field{key: prefix + attr.Key, value: attr.Value.String()})
+}
+
+// severity maps slog levels to syslog severities, which journald shares
+func severity(level slog.Level) int {
+	switch {
+	case level >= slog.LevelError:
+		return 3 // err
+	case level >= slog.LevelWarn:
+		return 4 // warning
+	case level >= slog.LevelInfo:
+		return 6 // info
+	default:
+		return 7 // debug
+	}
+}
+
+// Syslog (RFC 5424)
+
+var syslogFacilities = map[string]int{
+	"kern": 0, "user": 1, "mail": 2, "daemon": 3, "auth": 4, "syslog": 5,
+	"lpr": 6, "news": 7, "uucp": 8, "cron": 9, "authpriv": 10, "ftp": 11,
+	"local0": 16, "local1": 17, "local2": 18, "local3": 19,
+	"local4": 20, "local5": 21, "local6": 22, "local7": 23,
+}
+
+// syslogSDID names the structured data element carrying log attributes;
+// 32473 is the private enterprise number reserved for documentation
+const syslogSDID = "attrs@32473"
+
+// syslogWriter owns the connection shared by derived handlers
+type syslogWriter struct {
+	mu       sync.Mutex
+	network  string
+	address  string
+	conn     net.Conn
+	facility int
+	appName  string
+	hostname string
+}
+
+type syslogHandler struct {
+	w     *syslogWriter
+	attrs attrState
+}
+
+func newSyslogHandler(address, facility, appName string) (*syslogHandler, error) {
+	if address == "" {
+		return nil, fmt.Errorf("syslog output requires a syslog address")
+	}
+	u, err := url.Parse(address)
+	if err != nil || (u.Scheme != "udp" && u.Scheme != "tcp") || u.Host == "" {
+		return nil, fmt.Errorf("invalid syslog address %q (expected udp://host:port or tcp://host:port)", address)
+	}
+	if facility == "" {
+		facility = "daemon"
+	}
+	code, ok := syslogFacilities[strings.ToLower(facility)]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", facility)
+	}
+
+	hostname, _ := os.Hostname()
+	if hostname == "" {
+		hostname = "-"
+	}
+	w := &syslogWriter{network: u.Scheme, address: u.Host, facility: code, appName: appName, hostname: hostname}
+	if err := w.connect(); err != nil {
+		return nil, err
+	}
+	return &syslogHandler{w: w}, nil
+}
+
+func (w *syslogWriter) connect() error {
+	conn, err := net.DialTimeout(w.network, w.address, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("failed to connect to syslog at %s://%s: %w", w.network, w.address, err)
+	}
+	w.conn = conn
+	return nil
+}
+
+// write sends one message; TCP uses octet-counting framing (RFC 6587) and
+// reconnects once if the server dropped the connection
+func (w *syslogWriter) write(msg []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	frame := msg
+	if w.network == "tcp" {
+		frame = append([]byte(fmt.Sprintf("%d ", len(msg))), msg...)
+	}
+	for attempt := 0; attempt < 2; attempt++ {
+		if w.conn == nil {
+			if err := w.connect(); err != nil {
+				return err
+			}
+		}
+		if _, err := w.conn.Write(frame); err == nil {
+			return nil
+		}
+		w.conn.Close()
+		w.conn = nil
+	}
+	return fmt.Errorf("failed to write to syslog at %s://%s", w.network, w.address)
+}
+
+func (h *syslogHandler) Close() error {
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	if h.w.conn == nil {
+		return nil
+	}
+	err := h.w.conn.Close()
+	h.w.conn = nil
+	return err
+}
+
+func (h *syslogHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *syslogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &syslogHandler{w: h.w, attrs: h.attrs.withAttrs(attrs)}
+}
+
+func (h *syslogHandler) WithGroup(name string) slog.Handler {
+	return &syslogHandler{w: h.w, attrs: h.attrs.withGroup(name)}
+}
+
+func (h *syslogHandler) Handle(_ context.Context, r slog.Record) error {
+	return h.w.write(h.format(r))
+}
+
+// format renders <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG
+func (h *syslogHandler) format(r slog.Record) []byte {
+	var b bytes.Buffer
+	timestamp := r.Time
+	if timestamp.IsZero() {
+		timestamp = time.Now()
+	}
+	fmt.Fprintf(&b, "<%d>1 %s %s %s %d - ",
+		h.w.facility*8+severity(r.Level),
+		timestamp.Format("2006-01-02T15:04:05.000000Z07:00"),
+		h.w.hostname, h.w.appName, os.Getpid())
+
+	fields := h.attrs.recordFields(r)
+	if len(fields) == 0 {
+		b.WriteString("-")
+	} else {
+		b.WriteString("[" + syslogSDID)
+		for _, f := range fields {
+			fmt.Fprintf(&b, ` %s="%s"`, sdName(f.key), sdEscape(f.value))
+		}
+		b.WriteString("]")
+	}
+	b.WriteString(" ")
+	b.WriteString(r.Message)
+	return b.Bytes()
+}
+
+// sdName makes a valid SD-PARAM name: printable ASCII without = ] " or
+// space, at most 32 characters
+func sdName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r <= 32 || r >= 127 || r == '=' || r == ']' || r == '"' {
+			return '_'
+		}
+		return r
+	}, key)
+	if len(name) > 32 {
+		name = name[:32]
+	}
+	if name == "" {
+		name = "_"
+	}
+	return name
+}
+
+// sdEscape escapes ", \ and ] in an SD-PARAM value
+func sdEscape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`).Replace(value)
+}
+
+// journald native protocol
+
+type journaldWriter struct {
+	mu      sync.Mutex
+	conn    *net.UnixConn
+	appName string
+}
+
+type journaldHandler struct {
+	w     *journaldWriter
+	attrs attrState
+}
+
+func newJournaldHandler(socket, appName string) (*journaldHandler, error) {
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to journald at %s: %w", socket, err)
+	}
+	return &journaldHandler{w: &journaldWriter{conn: conn, appName: appName}}, nil
+}
+
+func (h *journaldHandler) Close() error {
+	return h.w.conn.Close()
+}
+
+func (h *journaldHandler) Enabled(context.Context, slog.Level) bool { return true }
+
+func (h *journaldHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &journaldHandler{w: h.w, attrs: h.attrs.withAttrs(attrs)}
+}
+
+func (h *journaldHandler) WithGroup(name string) slog.Handler {
+	return &journaldHandler{w: h.w, attrs: h.attrs.withGroup(name)}
+}
+
+func (h *journaldHandler) Handle(_ context.Context, r slog.Record) error {
+	var b bytes.Buffer
+	writeJournalField(&b, "MESSAGE", r.Message)
+	writeJournalField(&b, "PRIORITY", fmt.Sprint(severity(r.Level)))
+	writeJournalField(&b, "SYSLOG_IDENTIFIER", h.w.appName)
+	for _, f := range h.attrs.recordFields(r) {
+		writeJournalField(&b, journalFieldName(f.key), f.value)
+	}
+
+	h.w.mu.Lock()
+	defer h.w.mu.Unlock()
+	_, err := h.w.conn.Write(b.Bytes())
+	return err
+}
+
+// writeJournalField uses KEY=value lines, switching to the length-prefixed
+// binary form when the value contains a newline
+func writeJournalField(b *bytes.Buffer, key, value string) {
+	if !strings.Contains(value, "\n") {
+		b.WriteString(key + "=" + value + "\n")
+		return
+	}
+	b.WriteString(key + "\n")
+	binary.Write(b, binary.LittleEndian, uint64(len(value)))
+	b.WriteString(value + "\n")
+}
+
+// journalFieldName makes a valid journal field name: upper-case letters,
+// digits and underscores, not starting with an underscore or digit
+func journalFieldName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		switch {
+		case r >= 'a' && r <= 'z':
+			return r - 'a' + 'A'
+		case r >= 'A' && r <= 'Z', r >= '0' && r <= '9':
+			return r
+		default:
+			return '_'
+		}
+	}, key)
+	if name == "" || name[0] == '_' || (name[0] >= '0' && name[0] <= '9') {
+		name = "X" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
@@ -0,0 +1,88 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SlowThresholds are the budgets above which an operation is logged at WARN;
+// zero disables the check
+type SlowThresholds struct {
+	Request time.Duration
+	Query   time.Duration
+	FFmpeg  time.Duration
+}
+
+var (
+	slowThresholds = SlowThresholds{
+		Request: 2 * time.Second,
+		Query:   500 * time.Millisecond,
+		FFmpeg:  2 * time.Minute,
+	}
+	slowThresholdsMu sync.RWMutex
+)
+
+// SetSlowThresholds replaces the slow operation budgets
+func SetSlowThresholds(t SlowThresholds) {
+	slowThresholdsMu.Lock()
+	slowThresholds = t
+	slowThresholdsMu.Unlock()
+}
+
+// CurrentSlowThresholds returns the slow operation budgets
+func CurrentSlowThresholds() SlowThresholds {
+	slowThresholdsMu.RLock()
+	defer slowThresholdsMu.RUnlock()
+	return slowThresholds
+}
+
+type jobIDKey struct{}
+
+// WithJobID tags a context with the job it works on, so log lines written
+// with the *Context helpers carry job_id
+func WithJobID(ctx context.Context, jobID string) context.Context {
+	return context.WithValue(ctx, jobIDKey{}, jobID)
+}
+
+// JobIDFromContext returns the job ID set by WithJobID, if any
+func JobIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	jobID, _ := ctx.Value(jobIDKey{}).(string)
+	return jobID
+}
+
+// formatMs renders a duration the way request lines do
+func formatMs(d time.Duration) string {
+	return fmt.Sprintf("%.2fms", float64(d.Nanoseconds())/1e6)
+}
+
+// SlowQuery warns when a database query exceeded its budget
+func SlowQuery(ctx context.Context, elapsed time.Duration, sql string, rows int64, err error) {
+	budget := CurrentSlowThresholds().Query
+	if budget <= 0 || elapsed <= budget {
+		return
+	}
+	args := []any{"duration", formatMs(elapsed), "budget", formatMs(budget), "rows", rows, "sql", sql}
+	if err != nil {
+		args = append(args, "error", err.Error())
+	}
+	WarnContext(ctx, "Slow query", args...)
+}
+
+// FFmpegStage warns when an ffmpeg stage that began at start exceeded its
+// budget. Call it once ffmpeg has returned.
+func FFmpegStage(ctx context.Context, stage string, start time.Time, args ...any) {
+	budget := CurrentSlowThresholds().FFmpeg
+	elapsed := time.Since(start)
+	if budget <= 0 || elapsed <= budget {
+		return
+	}
+	WarnContext(ctx, "Slow ffmpeg stage", append([]any{
+		"stage", stage,
+		"duration", formatMs(elapsed),
+		"budget", formatMs(budget)}, args...)...)
+}
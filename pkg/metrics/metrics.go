@@ -0,0 +1,268 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// Outcome describes how a job finished
+type Outcome string
+
+const (
+	OutcomeSucceeded Outcome = "succeeded"
+	OutcomeFailed    Outcome = "failed"
+	OutcomeCancelled Outcome = "cancelled"
+)
+
+// Default histogram buckets
+var (
+	queueWaitBuckets       = []float64{1, 5, 15, 30, 60, 120, 300, 900, 1800, 3600}
+	processingBuckets      = []float64{5, 15, 30, 60, 120, 300, 600, 1200, 1800, 3600, 7200}
+	processingRatioBuckets = []float64{0.05, 0.1, 0.25, 0.5, 0.75, 1, 1.5, 2, 4, 8}
+)
+
+// counter is a monotonically increasing value
+type counter struct {
+	mu    sync.Mutex
+	value float64
+}
+
+func (c *counter) add(v float64) {
+	c.mu.Lock()
+	c.value += v
+	c.mu.Unlock()
+}
+
+func (c *counter) get() float64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.value
+}
+
+// histogram tracks cumulative bucket counts the way Prometheus expects them
+type histogram struct {
+	mu      sync.Mutex
+	buckets []float64
+	counts  []uint64
+	sum     float64
+	count   uint64
+}
+
+func newHistogram(buckets []float64) *histogram {
+	return &histogram{
+		buckets: buckets,
+		counts:  make([]uint64, len(buckets)),
+	}
+}
+
+func (h *histogram) observe(v float64) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		if v <= upper {
+			h.counts[i]++
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+func (h *histogram) write(w io.Writer, name string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for i, upper := range h.buckets {
+		fmt.Fprintf(w, "%s_bucket{le=\"%s\"} %d\n", name, formatFloat(upper), h.counts[i])
+	}
+	fmt.Fprintf(w, "%s_bucket{le=\"+Inf\"} %d\n", name, h.count)
+	fmt.Fprintf(w, "%s_sum %s\n", name, formatFloat(h.sum))
+	fmt.Fprintf(w, "%s_count %d\n", name, h.count)
+}
+
+// Collector holds pipeline metrics and the SLO windows derived from them
+type Collector struct {
+	jobsMu sync.Mutex
+	jobs   map[Outcome]*counter
+
+	queueWait       *histogram
+	processing      *histogram
+	processingRatio *histogram
+
+	slo *SLOTracker
+
+	gaugesMu sync.RWMutex
+	gauges   map[string]GaugeFunc
+}
+
+// GaugeFunc reports the current value of a gauge at scrape time
+type GaugeFunc struct {
+	Help  string
+	Value func() float64
+}
+
+// NewCollector creates an empty collector with the default SLO windows
+func NewCollector() *Collector {
+	return &Collector{
+		jobs:            make(map[Outcome]*counter),
+		queueWait:       newHistogram(queueWaitBuckets),
+		processing:      newHistogram(processingBuckets),
+		processingRatio: newHistogram(processingRatioBuckets),
+		slo:             NewSLOTracker(DefaultWindows),
+		gauges:          make(map[string]GaugeFunc),
+	}
+}
+
+// SLO returns the rolling window tracker backing this collector
+func (c *Collector) SLO() *SLOTracker {
+	return c.slo
+}
+
+// ObserveJobOutcome records a finished job
+func (c *Collector) ObserveJobOutcome(outcome Outcome) {
+	c.jobsMu.Lock()
+	ctr, ok := c.jobs[outcome]
+	if !ok {
+		ctr = &counter{}
+		c.jobs[outcome] = ctr
+	}
+	c.jobsMu.Unlock()
+
+	ctr.add(1)
+	c.slo.recordOutcome(outcome)
+}
+
+// ObserveQueueWait records how long a job waited before a worker picked it up
+func (c *Collector) ObserveQueueWait(wait time.Duration) {
+	if wait < 0 {
+		return
+	}
+	c.queueWait.observe(wait.Seconds())
+	c.slo.recordQueueWait(wait)
+}
+
+// ObserveProcessing records processing time and, when the audio length is
+// known, the ratio of processing time to audio duration
+func (c *Collector) ObserveProcessing(processing, audio time.Duration) {
+	if processing < 0 {
+		return
+	}
+	c.processing.observe(processing.Seconds())
+	if audio <= 0 {
+		return
+	}
+	ratio := processing.Seconds() / audio.Seconds()
+	c.processingRatio.observe(ratio)
+	c.slo.recordProcessingRatio(ratio)
+}
+
+// RegisterGauge exposes a value that is computed at scrape time
+func (c *Collector) RegisterGauge(name, help string, value func() float64) {
+	c.gaugesMu.Lock()
+	defer c.gaugesMu.Unlock()
+	c.gauges[name] = GaugeFunc{Help: help, Value: value}
+}
+
+// WritePrometheus renders all metrics in the Prometheus text exposition format
+func (c *Collector) WritePrometheus(w io.Writer) {
+	writeHeader(w, "synthezia_jobs_total", "Finished transcription jobs by outcome.", "counter")
+	c.jobsMu.Lock()
+	outcomes := make([]string, 0, len(c.jobs))
+	for outcome := range c.jobs {
+		outcomes = append(outcomes, string(outcome))
+	}
+	c.jobsMu.Unlock()
+	sort.Strings(outcomes)
+	for _, outcome := range outcomes {
+		c.jobsMu.Lock()
+		ctr := c.jobs[Outcome(outcome)]
+		c.jobsMu.Unlock()
+		fmt.Fprintf(w, "synthezia_jobs_total{outcome=\"%s\"} %s\n", outcome, formatFloat(ctr.get()))
+	}
+
+	writeHeader(w, "synthezia_job_queue_wait_seconds", "Time jobs spent queued before a worker started them.", "histogram")
+	c.queueWait.write(w, "synthezia_job_queue_wait_seconds")
+
+	writeHeader(w, "synthezia_job_processing_seconds", "Wall-clock time spent transcribing a job.", "histogram")
+	c.processing.write(w, "synthezia_job_processing_seconds")
+
+	writeHeader(w, "synthezia_job_processing_ratio", "Processing time divided by audio duration.", "histogram")
+	c.processingRatio.write(w, "synthezia_job_processing_ratio")
+
+	c.slo.write(w)
+
+	c.gaugesMu.RLock()
+	names := make([]string, 0, len(c.gauges))
+	for name := range c.gauges {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		gauge := c.gauges[name]
+		writeHeader(w, name, gauge.Help, "gauge")
+		fmt.Fprintf(w, "%s %s\n", name, formatFloat(gauge.Value()))
+	}
+	c.gaugesMu.RUnlock()
+}
+
+func writeHeader(w io.Writer, name, help, kind string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, kind)
+}
+
+// formatFloat renders a value the way Prometheus parses it
+func formatFloat(v float64) string {
+	switch {
+	case math.IsNaN(v):
+		return "NaN"
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+// formatWindow renders a window duration as a compact label (5m, 1h, 3d)
+func formatWindow(d time.Duration) string {
+	switch {
+	case d >= 24*time.Hour && d%(24*time.Hour) == 0:
+		return strconv.Itoa(int(d/(24*time.Hour))) + "d"
+	case d >= time.Hour && d%time.Hour == 0:
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	case d >= time.Minute && d%time.Minute == 0:
+		return strconv.Itoa(int(d/time.Minute)) + "m"
+	}
+	return d.String()
+}
+
+// Default is the process-wide collector used by the queue and API
+var Default = NewCollector()
+
+// ObserveJobOutcome records a finished job on the default collector
+func ObserveJobOutcome(outcome Outcome) {
+	Default.ObserveJobOutcome(outcome)
+}
+
+// ObserveQueueWait records queue wait on the default collector
+func ObserveQueueWait(wait time.Duration) {
+	Default.ObserveQueueWait(wait)
+}
+
+// ObserveProcessing records processing time on the default collector
+func ObserveProcessing(processing, audio time.Duration) {
+	Default.ObserveProcessing(processing, audio)
+}
+
+// RegisterGauge registers a scrape-time gauge on the default collector
+func RegisterGauge(name, help string, value func() float64) {
+	Default.RegisterGauge(name, help, value)
+}
+
+// WritePrometheus renders the default collector
+func WritePrometheus(w io.Writer) {
+	Default.WritePrometheus(w)
+}
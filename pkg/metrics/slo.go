@@ -0,0 +1,205 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultWindows are the rolling windows used for SLO series. They line up
+// with the usual multi-window burn-rate alert pairs (5m/1h, 30m/6h, 6h/3d).
+var DefaultWindows = []time.Duration{
+	5 * time.Minute,
+	30 * time.Minute,
+	time.Hour,
+	6 * time.Hour,
+	24 * time.Hour,
+	72 * time.Hour,
+}
+
+// maxSamplesPerSeries bounds memory use for very busy instances
+const maxSamplesPerSeries = 50000
+
+type sample struct {
+	at    time.Time
+	value float64
+}
+
+// sampleWindow keeps timestamped samples no older than maxAge
+type sampleWindow struct {
+	mu      sync.Mutex
+	samples []sample
+	maxAge  time.Duration
+}
+
+func (sw *sampleWindow) add(at time.Time, value float64) {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.samples = append(sw.samples, sample{at: at, value: value})
+	sw.prune(at)
+}
+
+// prune drops samples outside maxAge; callers must hold mu
+func (sw *sampleWindow) prune(now time.Time) {
+	cutoff := now.Add(-sw.maxAge)
+	drop := 0
+	for drop < len(sw.samples) && sw.samples[drop].at.Before(cutoff) {
+		drop++
+	}
+	if over := len(sw.samples) - drop - maxSamplesPerSeries; over > 0 {
+		drop += over
+	}
+	if drop > 0 {
+		sw.samples = append(sw.samples[:0], sw.samples[drop:]...)
+	}
+}
+
+// since returns the values recorded at or after the given time
+func (sw *sampleWindow) since(now, from time.Time) []float64 {
+	sw.mu.Lock()
+	defer sw.mu.Unlock()
+	sw.prune(now)
+	idx := sort.Search(len(sw.samples), func(i int) bool {
+		return !sw.samples[i].at.Before(from)
+	})
+	values := make([]float64, 0, len(sw.samples)-idx)
+	for _, s := range sw.samples[idx:] {
+		values = append(values, s.value)
+	}
+	return values
+}
+
+// SLOTracker derives alert-ready series over rolling windows
+type SLOTracker struct {
+	windows         []time.Duration
+	outcomes        *sampleWindow
+	queueWait       *sampleWindow
+	processingRatio *sampleWindow
+	now             func() time.Time
+}
+
+// WindowStats is a point-in-time view of a single rolling window
+type WindowStats struct {
+	Window             time.Duration
+	Succeeded          int
+	Failed             int
+	SuccessRatio       float64 // NaN when no jobs finished in the window
+	QueueWaitP95       float64 // seconds
+	ProcessingRatioP95 float64
+}
+
+// NewSLOTracker creates a tracker for the given windows
+func NewSLOTracker(windows []time.Duration) *SLOTracker {
+	sorted := append([]time.Duration(nil), windows...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	var maxAge time.Duration
+	if len(sorted) > 0 {
+		maxAge = sorted[len(sorted)-1]
+	}
+
+	return &SLOTracker{
+		windows:         sorted,
+		outcomes:        &sampleWindow{maxAge: maxAge},
+		queueWait:       &sampleWindow{maxAge: maxAge},
+		processingRatio: &sampleWindow{maxAge: maxAge},
+		now:             time.Now,
+	}
+}
+
+// SetClock overrides the time source, mainly for tests
+func (t *SLOTracker) SetClock(now func() time.Time) {
+	t.now = now
+}
+
+// recordOutcome stores 1 for success and 0 for failure. Cancelled jobs are a
+// user decision rather than a service error and are left out of the ratio.
+func (t *SLOTracker) recordOutcome(outcome Outcome) {
+	switch outcome {
+	case OutcomeSucceeded:
+		t.outcomes.add(t.now(), 1)
+	case OutcomeFailed:
+		t.outcomes.add(t.now(), 0)
+	}
+}
+
+func (t *SLOTracker) recordQueueWait(wait time.Duration) {
+	t.queueWait.add(t.now(), wait.Seconds())
+}
+
+func (t *SLOTracker) recordProcessingRatio(ratio float64) {
+	t.processingRatio.add(t.now(), ratio)
+}
+
+// Stats computes the SLO view for every configured window
+func (t *SLOTracker) Stats() []WindowStats {
+	now := t.now()
+	stats := make([]WindowStats, 0, len(t.windows))
+	for _, window := range t.windows {
+		from := now.Add(-window)
+		ws := WindowStats{Window: window}
+
+		for _, v := range t.outcomes.since(now, from) {
+			if v > 0 {
+				ws.Succeeded++
+			} else {
+				ws.Failed++
+			}
+		}
+		if total := ws.Succeeded + ws.Failed; total > 0 {
+			ws.SuccessRatio = float64(ws.Succeeded) / float64(total)
+		} else {
+			ws.SuccessRatio = math.NaN()
+		}
+
+		ws.QueueWaitP95 = percentile(t.queueWait.since(now, from), 0.95)
+		ws.ProcessingRatioP95 = percentile(t.processingRatio.since(now, from), 0.95)
+		stats = append(stats, ws)
+	}
+	return stats
+}
+
+func (t *SLOTracker) write(w io.Writer) {
+	stats := t.Stats()
+
+	writeHeader(w, "synthezia_slo_jobs_finished", "Jobs that succeeded or failed within the rolling window.", "gauge")
+	for _, ws := range stats {
+		label := formatWindow(ws.Window)
+		fmt.Fprintf(w, "synthezia_slo_jobs_finished{window=\"%s\",outcome=\"succeeded\"} %d\n", label, ws.Succeeded)
+		fmt.Fprintf(w, "synthezia_slo_jobs_finished{window=\"%s\",outcome=\"failed\"} %d\n", label, ws.Failed)
+	}
+
+	writeHeader(w, "synthezia_slo_job_success_ratio", "Succeeded jobs divided by finished jobs within the rolling window.", "gauge")
+	for _, ws := range stats {
+		fmt.Fprintf(w, "synthezia_slo_job_success_ratio{window=\"%s\"} %s\n", formatWindow(ws.Window), formatFloat(ws.SuccessRatio))
+	}
+
+	writeHeader(w, "synthezia_slo_queue_wait_p95_seconds", "95th percentile queue wait within the rolling window.", "gauge")
+	for _, ws := range stats {
+		fmt.Fprintf(w, "synthezia_slo_queue_wait_p95_seconds{window=\"%s\"} %s\n", formatWindow(ws.Window), formatFloat(ws.QueueWaitP95))
+	}
+
+	writeHeader(w, "synthezia_slo_processing_ratio_p95", "95th percentile of processing time over audio duration within the rolling window.", "gauge")
+	for _, ws := range stats {
+		fmt.Fprintf(w, "synthezia_slo_processing_ratio_p95{window=\"%s\"} %s\n", formatWindow(ws.Window), formatFloat(ws.ProcessingRatioP95))
+	}
+}
+
+// percentile uses the nearest-rank method and returns NaN for no data
+func percentile(values []float64, p float64) float64 {
+	if len(values) == 0 {
+		return math.NaN()
+	}
+	sort.Float64s(values)
+	rank := int(math.Ceil(p*float64(len(values)))) - 1
+	if rank < 0 {
+		rank = 0
+	}
+	if rank >= len(values) {
+		rank = len(values) - 1
+	}
+	return values[rank]
+}
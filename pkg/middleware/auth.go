@@ -18,9 +18,10 @@ func AuthMiddleware(authService *auth.AuthService) gin.HandlerFunc {
 		// Check for API key first
 		apiKey := c.GetHeader("X-API-Key")
 		if apiKey != "" {
-			if validateAPIKey(apiKey) {
+			if keyID, ok := validateAPIKey(apiKey); ok {
 				c.Set("auth_type", "api_key")
 				c.Set("api_key", apiKey)
+				c.Set("api_key_id", keyID)
 				c.Next()
 				return
 			}
@@ -29,6 +30,12 @@ func AuthMiddleware(authService *auth.AuthService) gin.HandlerFunc {
 		// Check for JWT token
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
+			// Browsers opening a stream cannot set headers and present a
+			// single-use ticket instead
+			if ticket := ticketFromRequest(c); ticket != "" {
+				authenticateTicket(c, authService, ticket)
+				return
+			}
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Missing authentication"})
 			c.Abort()
 			return
@@ -57,12 +64,44 @@ func AuthMiddleware(authService *auth.AuthService) gin.HandlerFunc {
 	}
 }
 
-// validateAPIKey validates an API key against the database and updates last used timestamp
-func validateAPIKey(key string) bool {
+// ticketFromRequest returns a connection ticket from the ticket query
+// parameter. Tickets only authenticate GET requests, which is how streaming
+// connections are opened.
+func ticketFromRequest(c *gin.Context) string {
+	if c.Request.Method != http.MethodGet {
+		return ""
+	}
+	return c.Query("ticket")
+}
+
+// authenticateTicket redeems a ticket and continues as the identity it was
+// issued for
+func authenticateTicket(c *gin.Context, authService *auth.AuthService, ticket string) {
+	identity, err := authService.Tickets().Redeem(ticket, c.Request.URL.Path)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid or expired ticket"})
+		c.Abort()
+		return
+	}
+
+	c.Set("auth_type", identity.AuthType)
+	if identity.AuthType == "api_key" {
+		c.Set("api_key", identity.APIKey)
+		c.Set("api_key_id", identity.APIKeyID)
+	} else {
+		c.Set("user_id", identity.UserID)
+		c.Set("username", identity.Username)
+	}
+	c.Next()
+}
+
+// validateAPIKey validates an API key against the database, updates the last
+// used timestamp and returns the key's ID
+func validateAPIKey(key string) (uint, bool) {
 	var apiKey models.APIKey
 	result := database.DB.Where("key = ? AND is_active = ?", key, true).First(&apiKey)
 	if result.Error != nil {
-		return false
+		return 0, false
 	}
 
 	// Update last used timestamp
@@ -70,7 +109,7 @@ func validateAPIKey(key string) bool {
 	apiKey.LastUsed = &now
 	database.DB.Save(&apiKey)
 
-	return true
+	return apiKey.ID, true
 }
 
 // APIKeyOnlyMiddleware only allows API key authentication
@@ -83,7 +122,8 @@ func APIKeyOnlyMiddleware() gin.HandlerFunc {
 			return
 		}
 
-		if !validateAPIKey(apiKey) {
+		keyID, ok := validateAPIKey(apiKey)
+		if !ok {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid API key"})
 			c.Abort()
 			return
@@ -91,6 +131,7 @@ func APIKeyOnlyMiddleware() gin.HandlerFunc {
 
 		c.Set("auth_type", "api_key")
 		c.Set("api_key", apiKey)
+		c.Set("api_key_id", keyID)
 		c.Next()
 	}
 }
@@ -0,0 +1,386 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+)
+
+const (
+	defaultCaptureMaxEntries   = 200
+	defaultCaptureMaxBodyBytes = 64 * 1024
+	redactedValue              = "[REDACTED]"
+)
+
+// sensitiveHeaders are never stored in captures
+var sensitiveHeaders = map[string]bool{
+	"authorization": true,
+	"x-api-key":     true,
+	"cookie":        true,
+	"set-cookie":    true,
+	"x-csrf-token":  true,
+}
+
+// sensitiveFields are redacted from JSON bodies (matched case-insensitively by substring)
+var sensitiveFields = []string{"password", "token", "secret", "api_key", "apikey", "authorization"}
+
+// CaptureRule enables debug capture for a route prefix and/or API key
+type CaptureRule struct {
+	ID          string     `json:"id"`
+	RoutePrefix string     `json:"route_prefix,omitempty"`
+	APIKeyID    *uint      `json:"api_key_id,omitempty"`
+	SampleRate  float64    `json:"sample_rate"`
+	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
+	CreatedAt   time.Time  `json:"created_at"`
+	apiKey      string
+}
+
+// CapturedExchange is a sanitized request/response pair
+type CapturedExchange struct {
+	ID                string              `json:"id"`
+	RuleID            string              `json:"rule_id"`
+	Method            string              `json:"method"`
+	Path              string              `json:"path"`
+	Query             string              `json:"query,omitempty"`
+	Status            int                 `json:"status"`
+	DurationMs        int64               `json:"duration_ms"`
+	ClientIP          string              `json:"client_ip"`
+	AuthType          string              `json:"auth_type,omitempty"`
+	RequestHeaders    map[string][]string `json:"request_headers"`
+	RequestBody       string              `json:"request_body,omitempty"`
+	RequestTruncated  bool                `json:"request_truncated,omitempty"`
+	ResponseHeaders   map[string][]string `json:"response_headers"`
+	ResponseBody      string              `json:"response_body,omitempty"`
+	ResponseTruncated bool                `json:"response_truncated,omitempty"`
+	CapturedAt        time.Time           `json:"captured_at"`
+}
+
+// CaptureStore holds capture rules and a bounded ring of captured exchanges
+type CaptureStore struct {
+	mu           sync.RWMutex
+	rules        map[string]*CaptureRule
+	entries      []*CapturedExchange
+	next         int
+	maxEntries   int
+	maxBodyBytes int
+}
+
+// NewCaptureStore creates a store keeping at most maxEntries exchanges with
+// bodies truncated to maxBodyBytes; zero values fall back to defaults
+func NewCaptureStore(maxEntries, maxBodyBytes int) *CaptureStore {
+	if maxEntries <= 0 {
+		maxEntries = defaultCaptureMaxEntries
+	}
+	if maxBodyBytes <= 0 {
+		maxBodyBytes = defaultCaptureMaxBodyBytes
+	}
+	return &CaptureStore{
+		rules:        make(map[string]*CaptureRule),
+		maxEntries:   maxEntries,
+		maxBodyBytes: maxBodyBytes,
+	}
+}
+
+// AddRule registers a capture rule. apiKey is the raw key value matched
+// against the authenticated request and is never exposed.
+func (s *CaptureStore) AddRule(rule CaptureRule, apiKey string) *CaptureRule {
+	if rule.SampleRate <= 0 || rule.SampleRate > 1 {
+		rule.SampleRate = 1
+	}
+	rule.ID = uuid.New().String()
+	rule.CreatedAt = time.Now()
+	rule.apiKey = apiKey
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.rules[rule.ID] = &rule
+	return &rule
+}
+
+// RemoveRule deletes a rule, returning false if it did not exist
+func (s *CaptureStore) RemoveRule(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if _, ok := s.rules[id]; !ok {
+		return false
+	}
+	delete(s.rules, id)
+	return true
+}
+
+// Rules lists active rules, dropping expired ones
+func (s *CaptureStore) Rules() []CaptureRule {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := time.Now()
+	rules := make([]CaptureRule, 0, len(s.rules))
+	for id, rule := range s.rules {
+		if rule.ExpiresAt != nil && now.After(*rule.ExpiresAt) {
+			delete(s.rules, id)
+			continue
+		}
+		rules = append(rules, *rule)
+	}
+	return rules
+}
+
+// Entries returns captured exchanges, newest first
+func (s *CaptureStore) Entries() []CapturedExchange {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	result := make([]CapturedExchange, 0, len(s.entries))
+	for i := 0; i < len(s.entries); i++ {
+		idx := (s.next - 1 - i + len(s.entries)) % len(s.entries)
+		result = append(result, *s.entries[idx])
+	}
+	return result
+}
+
+// Entry looks up a single captured exchange
+func (s *CaptureStore) Entry(id string) (*CapturedExchange, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	for _, entry := range s.entries {
+		if entry.ID == id {
+			copied := *entry
+			return &copied, true
+		}
+	}
+	return nil, false
+}
+
+// Clear drops all captured exchanges
+func (s *CaptureStore) Clear() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.entries = nil
+	s.next = 0
+}
+
+func (s *CaptureStore) add(entry *CapturedExchange) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) < s.maxEntries {
+		s.entries = append(s.entries, entry)
+		s.next = len(s.entries) % s.maxEntries
+		return
+	}
+	s.entries[s.next] = entry
+	s.next = (s.next + 1) % s.maxEntries
+}
+
+func (s *CaptureStore) hasRules() bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.rules) > 0
+}
+
+// matchRule finds a rule for the finished request, applying its sample rate
+func (s *CaptureStore) matchRule(path, apiKey string) *CaptureRule {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	now := time.Now()
+	for _, rule := range s.rules {
+		if rule.ExpiresAt != nil && now.After(*rule.ExpiresAt) {
+			continue
+		}
+		if rule.RoutePrefix != "" && !strings.HasPrefix(path, rule.RoutePrefix) {
+			continue
+		}
+		if rule.apiKey != "" && rule.apiKey != apiKey {
+			continue
+		}
+		if rand.Float64() >= rule.SampleRate {
+			continue
+		}
+		return rule
+	}
+	return nil
+}
+
+// limitedBuffer keeps the first max bytes written to it
+type limitedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (b *limitedBuffer) Write(p []byte) (int, error) {
+	if remaining := b.max - b.buf.Len(); remaining > 0 {
+		if len(p) > remaining {
+			b.buf.Write(p[:remaining])
+			b.truncated = true
+		} else {
+			b.buf.Write(p)
+		}
+	} else if len(p) > 0 {
+		b.truncated = true
+	}
+	return len(p), nil
+}
+
+// teeReadCloser copies what the handler reads from the request body
+type teeReadCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// captureWriter copies the response body as it is written
+type captureWriter struct {
+	gin.ResponseWriter
+	body *limitedBuffer
+}
+
+func (w *captureWriter) Write(data []byte) (int, error) {
+	w.body.Write(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *captureWriter) WriteString(s string) (int, error) {
+	w.body.Write([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// DebugCaptureMiddleware records sanitized request/response pairs for requests
+// matching a capture rule. It costs nothing while no rules are registered.
+func DebugCaptureMiddleware(store *CaptureStore) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !store.hasRules() {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		reqBody := &limitedBuffer{max: store.maxBodyBytes}
+		if c.Request.Body != nil {
+			c.Request.Body = teeReadCloser{Reader: io.TeeReader(c.Request.Body, reqBody), Closer: c.Request.Body}
+		}
+		respBody := &limitedBuffer{max: store.maxBodyBytes}
+		c.Writer = &captureWriter{ResponseWriter: c.Writer, body: respBody}
+
+		c.Next()
+
+		rule := store.matchRule(c.Request.URL.Path, c.GetString("api_key"))
+		if rule == nil {
+			return
+		}
+
+		store.add(&CapturedExchange{
+			ID:                uuid.New().String(),
+			RuleID:            rule.ID,
+			Method:            c.Request.Method,
+			Path:              c.Request.URL.Path,
+			Query:             sanitizeQuery(c.Request.URL.Query()),
+			Status:            c.Writer.Status(),
+			DurationMs:        time.Since(start).Milliseconds(),
+			ClientIP:          c.ClientIP(),
+			AuthType:          c.GetString("auth_type"),
+			RequestHeaders:    sanitizeHeaders(c.Request.Header),
+			RequestBody:       sanitizeBody(c.Request.Header.Get("Content-Type"), reqBody.buf.Bytes()),
+			RequestTruncated:  reqBody.truncated,
+			ResponseHeaders:   sanitizeHeaders(c.Writer.Header()),
+			ResponseBody:      sanitizeBody(c.Writer.Header().Get("Content-Type"), respBody.buf.Bytes()),
+			ResponseTruncated: respBody.truncated,
+			CapturedAt:        start,
+		})
+	}
+}
+
+func sanitizeHeaders(headers http.Header) map[string][]string {
+	result := make(map[string][]string, len(headers))
+	for name, values := range headers {
+		if sensitiveHeaders[strings.ToLower(name)] {
+			result[name] = []string{redactedValue}
+			continue
+		}
+		result[name] = append([]string(nil), values...)
+	}
+	return result
+}
+
+func sanitizeQuery(values map[string][]string) string {
+	if len(values) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(values))
+	for key, vals := range values {
+		for _, v := range vals {
+			if isSensitiveField(key) {
+				v = redactedValue
+			}
+			parts = append(parts, key+"="+v)
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+// sanitizeBody redacts sensitive JSON fields and omits binary payloads
+func sanitizeBody(contentType string, body []byte) string {
+	if len(body) == 0 {
+		return ""
+	}
+	contentType = strings.ToLower(contentType)
+	switch {
+	case strings.Contains(contentType, "json"):
+		var parsed interface{}
+		if err := json.Unmarshal(body, &parsed); err != nil {
+			// Likely truncated; sensitive fields cannot be located reliably
+			return "[unparseable JSON omitted]"
+		}
+		redacted, err := json.Marshal(redactJSON(parsed))
+		if err != nil {
+			return "[unparseable JSON omitted]"
+		}
+		return string(redacted)
+	case strings.Contains(contentType, "x-www-form-urlencoded"):
+		values, err := url.ParseQuery(string(body))
+		if err != nil {
+			return "[unparseable form omitted]"
+		}
+		return sanitizeQuery(values)
+	case strings.HasPrefix(contentType, "text/"):
+		return string(body)
+	case strings.HasPrefix(contentType, "multipart/"):
+		return "[multipart body omitted]"
+	}
+	return "[binary body omitted]"
+}
+
+func redactJSON(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, inner := range v {
+			if isSensitiveField(key) {
+				v[key] = redactedValue
+			} else {
+				v[key] = redactJSON(inner)
+			}
+		}
+		return v
+	case []interface{}:
+		for i, inner := range v {
+			v[i] = redactJSON(inner)
+		}
+		return v
+	}
+	return value
+}
+
+func isSensitiveField(name string) bool {
+	lower := strings.ToLower(name)
+	for _, field := range sensitiveFields {
+		if strings.Contains(lower, field) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,24 @@
+package middleware
+
+import (
+	"github.com/gin-gonic/gin"
+
+	"synthezia/internal/usage"
+	"synthezia/pkg/logger"
+)
+
+// APIKeyUsageMiddleware counts requests and failures per API key. It must run
+// outside the auth middleware so it sees the key ID after the request.
+func APIKeyUsageMiddleware(tracker *usage.Tracker) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Next()
+
+		keyID, ok := c.Get("api_key_id")
+		if !ok {
+			return
+		}
+		if err := tracker.Record(keyID.(uint), c.Writer.Status() >= 400); err != nil {
+			logger.Debug("Failed to record API key usage", "api_key_id", keyID, "error", err)
+		}
+	}
+}
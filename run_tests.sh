@@ -137,6 +137,382 @@ else
 fi
 ((total++))
 
+# Metrics Tests
+if run_test "Metrics Package Tests" "./tests/metrics_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Fault Injection Tests
+if run_test "Fault Injection Tests" "./tests/test_helpers.go ./tests/faults_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Fake Transcription Backend Tests
+if run_test "Fake Transcription Backend Tests" "./tests/test_helpers.go ./tests/fake_backend_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Clock Tests
+if run_test "Clock Tests" "./tests/test_helpers.go ./tests/clock_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Telemetry Tests
+if run_test "Telemetry Tests" "./tests/test_helpers.go ./tests/telemetry_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Filesystem Abstraction Tests
+if run_test "Filesystem Abstraction Tests" "./tests/test_helpers.go ./tests/fsys_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Job State Tests
+if run_test "Job State Tests" "./tests/test_helpers.go ./tests/jobstate_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Audio Metadata Tests
+if run_test "Audio Metadata Tests" "./tests/test_helpers.go ./tests/audio_tags_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Logger Sink Tests
+if run_test "Logger Sink Tests" "./tests/logger_sinks_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Summary Regeneration Tests
+if run_test "Summary Regeneration Tests" "./tests/test_helpers.go ./tests/regenerate_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Translation Tests
+if run_test "Translation Tests" "./tests/test_helpers.go ./tests/translation_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Post-Processing Tests
+if run_test "Post-Processing Tests" "./tests/test_helpers.go ./tests/postprocess_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Webhook Tests
+if run_test "Webhook Tests" "./tests/test_helpers.go ./tests/webhook_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Remote Download Tests
+if run_test "Remote Download Tests" "./tests/test_helpers.go ./tests/fetch_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Scheduler Tests
+if run_test "Scheduler Tests" "./tests/test_helpers.go ./tests/scheduler_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Job Parameter Tests
+if run_test "Job Parameter Tests" "./tests/jobparams_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Whisper Model Management Tests
+if run_test "Whisper Model Management Tests" "./tests/test_helpers.go ./tests/whispermodels_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Device Scheduling Tests
+if run_test "Device Scheduling Tests" "./tests/devices_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Remote Worker Tests
+if run_test "Remote Worker Tests" "./tests/test_helpers.go ./tests/remoteworker_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Quick Transcription Sync Tests
+if run_test "Quick Transcription Sync Tests" "./tests/test_helpers.go ./tests/quick_sync_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Transcript Search Tests
+if run_test "Transcript Search Tests" "./tests/test_helpers.go ./tests/search_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Tag and Collection Tests
+if run_test "Tag and Collection Tests" "./tests/test_helpers.go ./tests/collection_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Storage Quota Tests
+if run_test "Storage Quota Tests" "./tests/test_helpers.go ./tests/quota_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Retention Tests
+if run_test "Retention Tests" "./tests/test_helpers.go ./tests/retention_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Object Storage Tests
+if run_test "Object Storage Tests" "./tests/test_helpers.go ./tests/object_storage_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Audio Download Tests
+if run_test "Audio Download Tests" "./tests/test_helpers.go ./tests/audio_download_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Migrations Tests
+if run_test "Migrations Tests" "./tests/test_helpers.go ./tests/migrations_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Job Version Tests
+if run_test "Job Version Tests" "./tests/test_helpers.go ./tests/job_version_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Job List Tests
+if run_test "Job List Tests" "./tests/test_helpers.go ./tests/job_list_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Queue Recovery Tests
+if run_test "Queue Recovery Tests" "./tests/test_helpers.go ./tests/queue_recovery_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Queue Scaling Tests
+if run_test "Queue Scaling Tests" "./tests/test_helpers.go ./tests/queue_scaling_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Fair Share Tests
+if run_test "Fair Share Tests" "./tests/test_helpers.go ./tests/fair_share_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Queue Introspection Tests
+if run_test "Queue Introspection Tests" "./tests/test_helpers.go ./tests/queue_introspection_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Resource Limits Tests
+if run_test "Resource Limits Tests" "./tests/test_helpers.go ./tests/resource_limits_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# ETA Tests
+if run_test "ETA Tests" "./tests/test_helpers.go ./tests/eta_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Job Progress Tests
+if run_test "Job Progress Tests" "./tests/test_helpers.go ./tests/job_progress_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Config File Tests
+if run_test "Config File Tests" "./tests/test_helpers.go ./tests/config_file_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# API Key Usage Tests
+if run_test "API Key Usage Tests" "./tests/test_helpers.go ./tests/usage_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Error Reporting Tests
+if run_test "Error Reporting Tests" "./tests/errreport_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Source Audio Retention Tests
+if run_test "Source Audio Retention Tests" "./tests/test_helpers.go ./tests/sourceaudio_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Playback Proxy Tests
+if run_test "Playback Proxy Tests" "./tests/test_helpers.go ./tests/proxyaudio_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Job Encryption Tests
+if run_test "Job Encryption Tests" "./tests/test_helpers.go ./tests/jobcrypt_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# S3 Bucket Watcher Tests
+if run_test "S3 Bucket Watcher Tests" "./tests/test_helpers.go ./tests/s3_watcher_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# SFTP Ingest Tests
+if run_test "SFTP Ingest Tests" "./tests/test_helpers.go ./tests/sftp_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Mail Ingest Tests
+if run_test "Mail Ingest Tests" "./tests/test_helpers.go ./tests/mailbox_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# ffmpeg Binary Tests
+if run_test "ffmpeg Binary Tests" "./tests/ffmpegbin_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
+# Audio Conversion Tests
+if run_test "Audio Conversion Tests" "./tests/test_helpers.go ./tests/convert_test.go"; then
+    ((passed++))
+else
+    ((failed++))
+fi
+((total++))
+
 # Final summary
 echo -e "\n======================================"
 echo -e "${YELLOW}📊 TEST SUMMARY${NC}"
@@ -1,7 +1,9 @@
 package tests
 
 import (
+	"bufio"
 	"bytes"
+	"encoding/base64"
 	"encoding/json"
 	"fmt"
 	"io"
@@ -9,13 +11,25 @@ import (
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
 	"synthezia/internal/api"
+	"synthezia/internal/audio"
+	"synthezia/internal/jobcrypt"
+	"synthezia/internal/jobparams"
+	"synthezia/internal/jobstate"
 	"synthezia/internal/models"
+	"synthezia/internal/postprocess"
+	"synthezia/internal/processing"
+	"synthezia/internal/transcript"
 	"synthezia/internal/queue"
 	"synthezia/internal/transcription"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/internal/whispermodels"
+	"synthezia/pkg/logger"
 	_ "synthezia/internal/transcription/adapters" // Register adapters
 
 	"github.com/gin-gonic/gin"
@@ -48,6 +62,8 @@ func (suite *APIHandlerTestSuite) SetupSuite() {
 	assert.NoError(suite.T(), err)
 
 	suite.taskQueue = queue.NewTaskQueue(1, suite.unifiedProcessor)
+	// Test servers listen on loopback, which URL downloads may not reach by default
+	suite.helper.Config.RemoteURLAllowedHosts = "127.0.0.1"
 	suite.handler = api.NewHandler(suite.helper.Config, suite.helper.AuthService, suite.taskQueue, suite.unifiedProcessor, suite.liveTranscriptionService, suite.quickTranscription)
 
 	// Set up router
@@ -271,6 +287,207 @@ func (suite *APIHandlerTestSuite) TestGetJobStatus() {
 	assert.Equal(suite.T(), models.StatusPending, response.Status)
 }
 
+// Test reading a job's recorded lifecycle events
+func (suite *APIHandlerTestSuite) TestGetJobEvents() {
+	testJob := suite.helper.CreateTestTranscriptionJob(suite.T(), "Test Job Events")
+	stop := jobstate.RecordEvents()
+	defer stop()
+	_, err := jobstate.Transition(testJob.ID, models.StatusProcessing)
+	assert.NoError(suite.T(), err)
+
+	w := suite.makeAuthenticatedRequest("GET", fmt.Sprintf("/api/v1/transcription/%s/events", testJob.ID), nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+
+	var response api.JobEventsResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), testJob.ID, response.JobID)
+	if assert.Len(suite.T(), response.Events, 1) {
+		assert.Equal(suite.T(), "transcribing", response.Events[0].Event)
+	}
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/missing-job/events", nil, false)
+	assert.Equal(suite.T(), 404, w.Code)
+}
+
+// Test choosing what happens to source audio after transcription
+func (suite *APIHandlerTestSuite) TestSourceAudioSetting() {
+	w := suite.makeAuthenticatedRequest("PUT", "/api/v1/user/settings", map[string]string{"source_audio_action": "shred"}, true)
+	assert.Equal(suite.T(), 400, w.Code)
+
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/user/settings", map[string]string{"source_audio_action": "proxy"}, true)
+	assert.Equal(suite.T(), 200, w.Code)
+	var response api.UserSettingsResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.NotNil(suite.T(), response.SourceAudioAction) {
+		assert.Equal(suite.T(), "proxy", *response.SourceAudioAction)
+	}
+
+	// An empty value falls back to the server default
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/user/settings", map[string]string{"source_audio_action": ""}, true)
+	assert.Equal(suite.T(), 200, w.Code)
+	response = api.UserSettingsResponse{}
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(suite.T(), response.SourceAudioAction)
+}
+
+// Test setting the email address mailed-in recordings are matched against
+func (suite *APIHandlerTestSuite) TestEmailSetting() {
+	w := suite.makeAuthenticatedRequest("PUT", "/api/v1/user/settings", map[string]string{"email": "not an address"}, true)
+	assert.Equal(suite.T(), 400, w.Code)
+
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/user/settings", map[string]string{"email": "Test.User@Example.com"}, true)
+	assert.Equal(suite.T(), 200, w.Code)
+	var response api.UserSettingsResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	if assert.NotNil(suite.T(), response.Email) {
+		assert.Equal(suite.T(), "test.user@example.com", *response.Email)
+	}
+
+	// Another account cannot claim the same address
+	taken := "other@example.com"
+	other := models.User{Username: "otheruser", Password: "hashed", Email: &taken}
+	assert.NoError(suite.T(), suite.helper.DB.Create(&other).Error)
+	defer suite.helper.DB.Delete(&other)
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/user/settings", map[string]string{"email": taken}, true)
+	assert.Equal(suite.T(), 409, w.Code)
+
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/user/settings", map[string]string{"email": ""}, true)
+	assert.Equal(suite.T(), 200, w.Code)
+	response = api.UserSettingsResponse{}
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Nil(suite.T(), response.Email)
+}
+
+// Test the audio endpoint picks the playback proxy or the original
+func (suite *APIHandlerTestSuite) TestGetAudioFileProxySelection() {
+	dir := suite.T().TempDir()
+	originalPath := filepath.Join(dir, "original.wav")
+	proxyPath := filepath.Join(dir, "original.playback.opus")
+	assert.NoError(suite.T(), os.WriteFile(originalPath, []byte("original audio"), 0644))
+	assert.NoError(suite.T(), os.WriteFile(proxyPath, []byte("proxy audio"), 0644))
+
+	testJob := suite.helper.CreateTestTranscriptionJob(suite.T(), "Proxy Playback")
+	assert.NoError(suite.T(), suite.helper.DB.Model(testJob).Updates(map[string]interface{}{
+		"audio_path":       originalPath,
+		"proxy_audio_path": proxyPath,
+	}).Error)
+	audioURL := fmt.Sprintf("/api/v1/transcription/%s/audio", testJob.ID)
+
+	w := suite.makeAuthenticatedRequest("GET", audioURL, nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "proxy", w.Header().Get("X-Audio-Source"))
+	assert.Equal(suite.T(), "proxy audio", w.Body.String())
+
+	w = suite.makeAuthenticatedRequest("GET", audioURL+"?source=original", nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "original", w.Header().Get("X-Audio-Source"))
+	assert.Equal(suite.T(), "original audio", w.Body.String())
+
+	w = suite.makeAuthenticatedRequest("GET", audioURL+"?source=best", nil, false)
+	assert.Equal(suite.T(), 400, w.Code)
+
+	// Range requests get partial content
+	req, _ := http.NewRequest("GET", audioURL, nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	req.Header.Set("Range", "bytes=0-4")
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusPartialContent, w.Code)
+	assert.Equal(suite.T(), "proxy", w.Body.String())
+
+	// Once the original is deleted the proxy is still served, and explicit
+	// requests for the original are refused
+	assert.NoError(suite.T(), os.Remove(originalPath))
+	assert.NoError(suite.T(), suite.helper.DB.Model(testJob).Update("source_audio_removed_at", time.Now()).Error)
+	w = suite.makeAuthenticatedRequest("GET", audioURL, nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	w = suite.makeAuthenticatedRequest("GET", audioURL+"?source=original", nil, false)
+	assert.Equal(suite.T(), http.StatusGone, w.Code)
+
+	assert.NoError(suite.T(), os.Remove(proxyPath))
+	w = suite.makeAuthenticatedRequest("GET", audioURL+"?source=proxy", nil, false)
+	assert.Equal(suite.T(), 404, w.Code)
+}
+
+// Test encrypted jobs need their key to be read, and are sealed on first use
+func (suite *APIHandlerTestSuite) TestEncryptedJobAccess() {
+	key := bytes.Repeat([]byte{7}, jobcrypt.KeySize)
+	encodedKey := base64.StdEncoding.EncodeToString(key)
+	testJob := suite.helper.CreateTestTranscriptionJob(suite.T(), "Encrypted Job")
+	assert.NoError(suite.T(), suite.helper.DB.Model(testJob).Updates(map[string]interface{}{
+		"status":              models.StatusCompleted,
+		"transcript":          `{"text":"secret words"}`,
+		"encrypted":           true,
+		"encryption_key_hash": jobcrypt.Fingerprint(key),
+	}).Error)
+	transcriptURL := fmt.Sprintf("/api/v1/transcription/%s/transcript", testJob.ID)
+
+	request := func(url, jobKey string) *httptest.ResponseRecorder {
+		req, _ := http.NewRequest("GET", url, nil)
+		req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+		if jobKey != "" {
+			req.Header.Set(jobcrypt.KeyHeader, jobKey)
+		}
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(suite.T(), http.StatusUnauthorized, request(transcriptURL, "").Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, request(transcriptURL, "c2hvcnQ=").Code)
+	wrongKey := base64.StdEncoding.EncodeToString(bytes.Repeat([]byte{8}, jobcrypt.KeySize))
+	assert.Equal(suite.T(), http.StatusForbidden, request(transcriptURL, wrongKey).Code)
+
+	w := request(transcriptURL, encodedKey)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "secret words")
+
+	// The stored transcript is sealed now, and hidden without the key
+	var stored models.TranscriptionJob
+	assert.NoError(suite.T(), suite.helper.DB.Where("id = ?", testJob.ID).First(&stored).Error)
+	assert.NotNil(suite.T(), stored.EncryptedAt)
+	if assert.NotNil(suite.T(), stored.Transcript) {
+		assert.True(suite.T(), jobcrypt.IsSealedText(*stored.Transcript))
+	}
+	jobURL := fmt.Sprintf("/api/v1/transcription/%s", testJob.ID)
+	w = request(jobURL, "")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.NotContains(suite.T(), w.Body.String(), "sze1:")
+	w = request(jobURL, encodedKey)
+	assert.Contains(suite.T(), w.Body.String(), "secret words")
+}
+
+// Test an encrypted job that completed but is not sealed yet is not readable without its key
+func (suite *APIHandlerTestSuite) TestUnsealedEncryptedJob() {
+	key := bytes.Repeat([]byte{9}, jobcrypt.KeySize)
+	testJob := suite.helper.CreateTestTranscriptionJob(suite.T(), "Unsealed Job")
+	suite.Require().NoError(suite.helper.DB.Model(testJob).Updates(map[string]interface{}{
+		"status":                 models.StatusCompleted,
+		"transcript":             `{"text":"unsealed words"}`,
+		"summary":                "unsealed summary",
+		"individual_transcripts": `{"track":"unsealed track"}`,
+		"encrypted":              true,
+		"encryption_key_hash":    jobcrypt.Fingerprint(key),
+	}).Error)
+
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+testJob.ID, nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.NotContains(suite.T(), w.Body.String(), "unsealed")
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/list?limit=100", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.NotContains(suite.T(), w.Body.String(), "unsealed")
+
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/chat/sessions", map[string]string{"transcription_id": testJob.ID, "model": "llama3"}, false)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code, "encrypted jobs cannot be chatted with")
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/summarize/", map[string]string{"transcription_id": testJob.ID, "model": "llama3", "content": "summarize"}, false)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code, "encrypted jobs cannot be summarized")
+
+	var stored models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", testJob.ID).First(&stored).Error)
+	suite.Require().NotNil(stored.Summary)
+	assert.Equal(suite.T(), "unsealed summary", *stored.Summary, "no plaintext summary is written")
+}
+
 // Test updating transcription title
 func (suite *APIHandlerTestSuite) TestUpdateTranscriptionTitle() {
 	testJob := suite.helper.CreateTestTranscriptionJob(suite.T(), "Original Title")
@@ -292,9 +509,236 @@ func (suite *APIHandlerTestSuite) TestUpdateTranscriptionTitle() {
 	assert.Equal(suite.T(), "Updated Title", *response.Title)
 }
 
+// Test manual recording times drive archive sorting and calendar views
+func (suite *APIHandlerTestSuite) TestRecordedAtArchive() {
+	early := suite.helper.CreateTestTranscriptionJob(suite.T(), "Recorded Early")
+	late := suite.helper.CreateTestTranscriptionJob(suite.T(), "Recorded Late")
+	sameMonth := suite.helper.CreateTestTranscriptionJob(suite.T(), "Recorded Same Month")
+
+	for id, value := range map[string]string{
+		early.ID:     "1999-03-01T09:00:00Z",
+		late.ID:      "1999-07-04",
+		sameMonth.ID: "1999-03-20T18:30:00Z",
+	} {
+		w := suite.makeAuthenticatedRequest("PUT", fmt.Sprintf("/api/v1/transcription/%s/recorded-at", id), map[string]string{"recorded_at": value}, false)
+		assert.Equal(suite.T(), 200, w.Code)
+	}
+
+	w := suite.makeAuthenticatedRequest("PUT", fmt.Sprintf("/api/v1/transcription/%s/recorded-at", early.ID), map[string]string{"recorded_at": "yesterday"}, false)
+	assert.Equal(suite.T(), 400, w.Code)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/list?sort=recorded_at&order=asc&recorded_from=1999-01-01&recorded_to=1999-12-31", nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	var list struct {
+		Jobs []models.TranscriptionJob `json:"jobs"`
+	}
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &list))
+	if assert.Len(suite.T(), list.Jobs, 3) {
+		assert.Equal(suite.T(), early.ID, list.Jobs[0].ID)
+		assert.Equal(suite.T(), late.ID, list.Jobs[2].ID)
+		assert.Equal(suite.T(), "manual", *list.Jobs[0].RecordedAtSource)
+	}
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/calendar?granularity=month&recorded_from=1999-01-01&recorded_to=1999-12-31", nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	var calendar api.CalendarResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &calendar))
+	if assert.Len(suite.T(), calendar.Buckets, 2) {
+		assert.Equal(suite.T(), "1999-03", calendar.Buckets[0].Period)
+		assert.Equal(suite.T(), 2, calendar.Buckets[0].Count)
+		assert.Equal(suite.T(), "1999-07", calendar.Buckets[1].Period)
+	}
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/list?sort=title", nil, false)
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
 // Test deleting transcription job
+// Test bulk summary regeneration endpoints
+func (suite *APIHandlerTestSuite) TestSummaryRegenerationEndpoints() {
+	w := suite.makeAuthenticatedRequest("POST", "/api/v1/summaries/missing-template/regenerate", nil, false)
+	assert.Equal(suite.T(), 404, w.Code)
+
+	tpl := models.SummaryTemplate{Name: "Unused", Model: "m", Prompt: "Summarize"}
+	assert.NoError(suite.T(), suite.helper.DB.Create(&tpl).Error)
+	w = suite.makeAuthenticatedRequest("POST", fmt.Sprintf("/api/v1/summaries/%s/regenerate", tpl.ID), map[string]bool{"include_current": true}, false)
+	assert.Equal(suite.T(), 400, w.Code)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/summaries/regenerations?template_id="+tpl.ID, nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.JSONEq(suite.T(), "[]", w.Body.String())
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/summaries/regenerations/missing-run", nil, false)
+	assert.Equal(suite.T(), 404, w.Code)
+}
+
+// Test recent log entries are served from the in-memory buffer
+func (suite *APIHandlerTestSuite) TestGetRecentLogs() {
+	logger.Warn("Recent log endpoint check", "marker", "recent-logs")
+
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/admin/logs?level=warn&since=5m", nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	var response api.RecentLogsResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), logger.DefaultBufferSize, response.Capacity)
+	found := false
+	for _, entry := range response.Entries {
+		assert.NotEqual(suite.T(), "info", entry.Level)
+		found = found || entry.Attrs["marker"] == "recent-logs"
+	}
+	assert.True(suite.T(), found)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/admin/logs?level=verbose", nil, false)
+	assert.Equal(suite.T(), 400, w.Code)
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/admin/logs?since=yesterday", nil, false)
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+// Test per-key usage is recorded and alerts can revoke the key
+func (suite *APIHandlerTestSuite) TestAPIKeyUsageAndAlerts() {
+	key := models.APIKey{Key: "usage-test-key", Name: "Usage test", IsActive: true}
+	assert.NoError(suite.T(), suite.helper.DB.Create(&key).Error)
+
+	for _, path := range []string{"/api/v1/transcription/list", "/api/v1/transcription/missing-job"} {
+		req := httptest.NewRequest("GET", path, nil)
+		req.Header.Set("X-API-Key", key.Key)
+		suite.router.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	w := suite.makeAuthenticatedRequest("GET", fmt.Sprintf("/api/v1/api-keys/%d/usage?hours=2", key.ID), nil, true)
+	assert.Equal(suite.T(), 200, w.Code)
+	var usageResponse api.APIKeyUsageResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &usageResponse))
+	assert.Equal(suite.T(), int64(2), usageResponse.Totals.Requests)
+	assert.Equal(suite.T(), int64(1), usageResponse.Totals.Errors)
+	assert.Equal(suite.T(), 0.5, usageResponse.Totals.ErrorRate)
+
+	alert := models.APIKeyAlert{APIKeyID: key.ID, Kind: "request_spike", Message: "spike"}
+	assert.NoError(suite.T(), suite.helper.DB.Create(&alert).Error)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/api-keys/alerts", nil, true)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"kind":"request_spike"`)
+
+	w = suite.makeAuthenticatedRequest("POST", fmt.Sprintf("/api/v1/api-keys/alerts/%d/revoke", alert.ID), nil, true)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.NoError(suite.T(), suite.helper.DB.First(&key, key.ID).Error)
+	assert.False(suite.T(), key.IsActive)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/api-keys/alerts", nil, true)
+	assert.JSONEq(suite.T(), "[]", w.Body.String())
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/api-keys/alerts/99999/dismiss", nil, true)
+	assert.Equal(suite.T(), 404, w.Code)
+}
+
+// Test a browser upload token allows exactly one bounded upload
+func (suite *APIHandlerTestSuite) TestBrowserUploadToken() {
+	w := suite.makeAuthenticatedRequest("POST", "/api/v1/upload-tokens", map[string]interface{}{
+		"max_bytes":      64,
+		"allowed_origin": "https://app.example.com/",
+		"title":          "From the browser",
+	}, false)
+	assert.Equal(suite.T(), 201, w.Code)
+	var minted api.UploadTokenResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &minted))
+	assert.True(suite.T(), strings.HasPrefix(minted.Token, "sut_"))
+	assert.Equal(suite.T(), "https://app.example.com", *minted.AllowedOrigin)
+
+	upload := func(token, origin string, size int) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, _ := writer.CreateFormFile("audio", "clip.mp3")
+		part.Write(bytes.Repeat([]byte("a"), size))
+		writer.Close()
+
+		req := httptest.NewRequest("POST", "/api/v1/browser-upload?token="+token, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("Origin", origin)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	assert.Equal(suite.T(), 401, upload("sut_unknown", "https://app.example.com", 10).Code)
+	assert.Equal(suite.T(), 403, upload(minted.Token, "https://evil.example.com", 10).Code)
+	// A rejected upload leaves the token usable
+	assert.Equal(suite.T(), 413, upload(minted.Token, "https://app.example.com", 100).Code)
+
+	w = upload(minted.Token, "https://app.example.com", 10)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), "https://app.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	var job models.TranscriptionJob
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(suite.T(), "From the browser", *job.Title)
+	assert.NotNil(suite.T(), job.APIKeyID)
+
+	var record models.UploadToken
+	assert.NoError(suite.T(), suite.helper.DB.Where("job_id = ?", job.ID).First(&record).Error)
+	assert.NotNil(suite.T(), record.UsedAt)
+	assert.NotContains(suite.T(), record.TokenHash, minted.Token)
+
+	assert.Equal(suite.T(), 401, upload(minted.Token, "https://app.example.com", 10).Code)
+
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/upload-tokens", map[string]interface{}{"max_bytes": 64, "expires_in": 7200}, false)
+	assert.Equal(suite.T(), 400, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/upload-tokens", map[string]interface{}{}, false)
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+// Test connection tickets stand in for the JWT on a single GET request to one path
+func (suite *APIHandlerTestSuite) TestConnectionTicket() {
+	mint := func(body interface{}) api.TicketResponse {
+		w := suite.makeAuthenticatedRequest("POST", "/api/v1/auth/ticket", body, true)
+		assert.Equal(suite.T(), 201, w.Code)
+		var ticket api.TicketResponse
+		assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &ticket))
+		return ticket
+	}
+	get := func(path string) int {
+		req := httptest.NewRequest("GET", path, nil)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	list := map[string]string{"path": "/api/v1/transcription/list"}
+	ticket := mint(list)
+	assert.Equal(suite.T(), 200, get("/api/v1/transcription/list?ticket="+ticket.Ticket))
+	assert.Equal(suite.T(), 401, get("/api/v1/transcription/list?ticket="+ticket.Ticket))
+
+	// A ticket opens the path it was issued for and nothing else
+	ticket = mint(list)
+	assert.Equal(suite.T(), 401, get("/api/v1/transcription/models?ticket="+ticket.Ticket))
+
+	// Tickets never authorize state-changing requests
+	ticket = mint(map[string]string{"path": "/api/v1/transcription/some-job"})
+	req := httptest.NewRequest("DELETE", "/api/v1/transcription/some-job?ticket="+ticket.Ticket, nil)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), 401, w.Code)
+
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/auth/ticket", map[string]string{}, true)
+	assert.Equal(suite.T(), 400, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/auth/ticket", map[string]string{"path": "/etc/passwd"}, true)
+	assert.Equal(suite.T(), 400, w.Code)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, httptest.NewRequest("POST", "/api/v1/auth/ticket", nil))
+	assert.Equal(suite.T(), 401, w.Code)
+}
+
 func (suite *APIHandlerTestSuite) TestDeleteTranscriptionJob() {
 	testJob := suite.helper.CreateTestTranscriptionJob(suite.T(), "Job to Delete")
+	outputPath := filepath.Join(suite.T().TempDir(), "converted.mp3")
+	assert.NoError(suite.T(), os.WriteFile(outputPath, []byte("converted"), 0644))
+	conversion := &models.AudioConversion{JobID: &testJob.ID, SourcePath: testJob.AudioPath, Format: "mp3", Status: models.ConversionCompleted, OutputPath: outputPath}
+	assert.NoError(suite.T(), suite.helper.DB.Create(conversion).Error)
+	hook := &models.Webhook{UserID: suite.helper.TestUser.ID, URL: "http://localhost/hook", Secret: "s3cret", IsActive: true}
+	assert.NoError(suite.T(), suite.helper.DB.Create(hook).Error)
+	defer suite.helper.DB.Delete(hook)
+	delivery := &models.WebhookDelivery{WebhookID: hook.ID, JobID: testJob.ID, Event: "job.completed", Payload: "{}", Status: models.WebhookDeliveryPending}
+	assert.NoError(suite.T(), suite.helper.DB.Create(delivery).Error)
+	member := &models.IngestedArchiveMember{ArchiveHash: "archive-hash", Member: "interview.mp3", JobID: &testJob.ID}
+	assert.NoError(suite.T(), suite.helper.DB.Create(member).Error)
 
 	w := suite.makeAuthenticatedRequest("DELETE", fmt.Sprintf("/api/v1/transcription/%s", testJob.ID), nil, false)
 	assert.Equal(suite.T(), 200, w.Code)
@@ -302,6 +746,21 @@ func (suite *APIHandlerTestSuite) TestDeleteTranscriptionJob() {
 	// Verify the job was deleted
 	w = suite.makeAuthenticatedRequest("GET", fmt.Sprintf("/api/v1/transcription/%s", testJob.ID), nil, false)
 	assert.Equal(suite.T(), 404, w.Code)
+
+	// Its conversions go with it, files included
+	var count int64
+	suite.helper.DB.Model(&models.AudioConversion{}).Where("job_id = ?", testJob.ID).Count(&count)
+	assert.Zero(suite.T(), count)
+	assert.NoFileExists(suite.T(), outputPath)
+
+	// So do its webhook deliveries, sent or not
+	suite.helper.DB.Model(&models.WebhookDelivery{}).Where("job_id = ?", testJob.ID).Count(&count)
+	assert.Zero(suite.T(), count)
+
+	// The archive member it came from is still known, without the job
+	var stored models.IngestedArchiveMember
+	assert.NoError(suite.T(), suite.helper.DB.Where("id = ?", member.ID).First(&stored).Error)
+	assert.Nil(suite.T(), stored.JobID)
 }
 
 // Test getting supported models
@@ -323,6 +782,188 @@ func (suite *APIHandlerTestSuite) TestGetSupportedModels() {
 	assert.Greater(suite.T(), len(languages), 0)
 }
 
+// Test the capability report lists every optional subsystem
+func (suite *APIHandlerTestSuite) TestGetCapabilities() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/capabilities", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), 401, w.Code)
+
+	suite.helper.Config.PlaybackProxyEnabled = true
+	defer func() { suite.helper.Config.PlaybackProxyEnabled = false }()
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/capabilities", nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+
+	var response api.CapabilitiesResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	for _, name := range []string{"diarization", "gpu", "live_transcription", "llm_summarization", "s3_ingest", "s3_storage"} {
+		assert.Contains(suite.T(), response.Features, name)
+	}
+	assert.True(suite.T(), response.Features["playback_proxy"].Enabled)
+	assert.False(suite.T(), response.Features["s3_storage"].Enabled)
+	assert.True(suite.T(), response.Features["live_transcription"].Enabled)
+	assert.Contains(suite.T(), response.ExportFormats, "json")
+}
+
+// Test the dropzone status reports a disabled dropzone with no files
+func (suite *APIHandlerTestSuite) TestGetDropzoneStatus() {
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/api/v1/dropzone/status", nil)
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), 401, w.Code)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/dropzone/status", nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	var response api.DropzoneStatusResponse
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.False(suite.T(), response.Enabled)
+	assert.NotNil(suite.T(), response.Files)
+	assert.Empty(suite.T(), response.Files)
+}
+
+// Test stored waveform peaks are served as JSON or in their binary format
+func (suite *APIHandlerTestSuite) TestGetWaveform() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Waveform job")
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/waveform", nil, false)
+	assert.Equal(suite.T(), 404, w.Code, "no audio to take peaks from")
+
+	waveform := audio.Waveform{Version: 2, Channels: 1, SampleRate: 8000, SamplesPerPixel: 80, Bits: 8, Length: 2, Data: []int8{-3, 4, -120, 110}}
+	data, err := waveform.MarshalBinary()
+	assert.NoError(suite.T(), err)
+	path := filepath.Join(suite.helper.Config.UploadDir, job.ID+".waveform.dat")
+	assert.NoError(suite.T(), os.WriteFile(path, data, 0644))
+	assert.NoError(suite.T(), suite.helper.DB.Model(job).Update("waveform_path", path).Error)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/waveform", nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	var response audio.Waveform
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), waveform, response)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/waveform?format=dat", nil, false)
+	assert.Equal(suite.T(), 200, w.Code)
+	assert.Equal(suite.T(), data, w.Body.Bytes())
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/waveform?format=png", nil, false)
+	assert.Equal(suite.T(), 400, w.Code)
+}
+
+// Test partial transcriptions check their range and keep the rest of the transcript
+func (suite *APIHandlerTestSuite) TestStartTranscriptionTimeRange() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Range job")
+	transcript := `{"text":"hello","segments":[{"start":0,"end":2,"text":"hello"}]}`
+	suite.Require().NoError(suite.helper.DB.Model(job).Updates(map[string]interface{}{
+		"status":         models.StatusCompleted,
+		"transcript":     transcript,
+		"audio_duration": 60.0,
+	}).Error)
+	path := "/api/v1/transcription/" + job.ID + "/start"
+
+	for _, params := range []map[string]interface{}{
+		{"range_start": -1},
+		{"range_start": 20, "range_end": 10},
+		{"range_start": 75},
+		{"range_start": 5, "split_channels": models.SplitChannelsAlways},
+	} {
+		w := suite.makeAuthenticatedRequest("POST", path, params, false)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "%v", params)
+	}
+
+	w := suite.makeAuthenticatedRequest("POST", path, map[string]interface{}{"range_start": 30, "range_end": 45.5}, false)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var stored models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	assert.Equal(suite.T(), models.StatusPending, stored.Status)
+	suite.Require().NotNil(stored.Transcript)
+	assert.Equal(suite.T(), transcript, *stored.Transcript)
+	start, end, ok := stored.Parameters.TimeRange()
+	assert.True(suite.T(), ok)
+	assert.Equal(suite.T(), 30.0, start)
+	assert.Equal(suite.T(), 45.5, end)
+}
+
+// Test per-job WhisperX options are checked against the server's limits and
+// filled from its defaults
+func (suite *APIHandlerTestSuite) TestStartTranscriptionOptions() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Options job")
+	suite.Require().NoError(suite.helper.DB.Model(job).Update("status", models.StatusUploaded).Error)
+	path := "/api/v1/transcription/" + job.ID + "/start"
+
+	for _, params := range []map[string]interface{}{
+		{"batch_size": 512},
+		{"beam_size": 50},
+		{"compute_type": "float8"},
+		{"temperature": 2},
+		{"vad_method": "webrtc"},
+		{"vad_onset": 1.5},
+	} {
+		w := suite.makeAuthenticatedRequest("POST", path, params, false)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "%v", params)
+	}
+
+	w := suite.makeAuthenticatedRequest("POST", path, map[string]interface{}{"model": "medium", "beam_size": 8, "temperature": 0.2, "vad_method": "silero"}, false)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var stored models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	assert.Equal(suite.T(), "medium", stored.Parameters.Model)
+	assert.Equal(suite.T(), 8, stored.Parameters.BeamSize)
+	assert.Equal(suite.T(), 0.2, stored.Parameters.Temperature)
+	assert.Equal(suite.T(), "silero", stored.Parameters.VadMethod)
+	assert.Equal(suite.T(), jobparams.Default.BatchSize, stored.Parameters.BatchSize)
+	assert.Equal(suite.T(), jobparams.Default.ComputeType, stored.Parameters.ComputeType)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/capabilities", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var capabilities api.CapabilitiesResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &capabilities))
+	assert.Equal(suite.T(), jobparams.Default.MaxBatchSize, capabilities.TranscriptionOptions.MaxBatchSize)
+	assert.Equal(suite.T(), jobparams.Default.Model, capabilities.TranscriptionOptions.Model)
+}
+
+// Test a job's quality report is made on the first request and then served as stored
+func (suite *APIHandlerTestSuite) TestGetQualityReport() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Quality job")
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/quality", nil, false)
+	assert.Equal(suite.T(), 404, w.Code, "no audio to analyze")
+
+	dir := suite.T().TempDir()
+	audioPath := filepath.Join(dir, "quality.wav")
+	suite.Require().NoError(os.WriteFile(audioPath, []byte("RIFF audio"), 0644))
+	suite.Require().NoError(suite.helper.DB.Model(job).Update("audio_path", audioPath).Error)
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	suite.Require().NoError(os.WriteFile(ffmpeg, []byte(`#!/bin/sh
+cat >&2 <<'EOF'
+  Duration: 00:01:00.00, bitrate: 256 kb/s
+[Parsed_ebur128_0 @ 0x1] Summary:
+    I:         -41.0 LUFS
+    LRA:         3.0 LU
+    Peak:      -12.0 dBFS
+[Parsed_astats_1 @ 0x2] Overall
+[Parsed_astats_1 @ 0x2] Peak level dB: -12.5
+[Parsed_astats_1 @ 0x2] RMS level dB: -38.0
+[Parsed_astats_1 @ 0x2] Noise floor dB: -70.0
+EOF
+`), 0755))
+	suite.handler.SetFFmpegPath(ffmpeg)
+	defer suite.handler.SetFFmpegPath("ffmpeg")
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/quality", nil, false)
+	suite.Require().Equal(200, w.Code, w.Body.String())
+	var report audio.QualityReport
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	assert.Equal(suite.T(), -41.0, *report.IntegratedLoudness)
+	assert.Equal(suite.T(), 32.0, *report.SNR)
+	assert.Equal(suite.T(), []string{"Very quiet recording (-41.0 LUFS); quiet speech may be missed"}, report.Warnings)
+
+	// Served from the job once stored
+	suite.Require().NoError(os.Remove(ffmpeg))
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/quality", nil, false)
+	suite.Require().Equal(200, w.Code)
+	var stored audio.QualityReport
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &stored))
+	assert.Equal(suite.T(), report.Warnings, stored.Warnings)
+}
+
 // Test profile management
 func (suite *APIHandlerTestSuite) TestProfileManagement() {
 	// List profiles
@@ -484,6 +1125,911 @@ func (suite *APIHandlerTestSuite) TestTranscriptionSubmit() {
 	assert.Equal(suite.T(), models.StatusPending, response.Status)
 }
 
+// Test a submitted file's embedded tags fill in the job like an upload's do
+func (suite *APIHandlerTestSuite) TestTranscriptionSubmitReadsTags() {
+	data := id3Tag(4,
+		id3TextFrame("TIT2", "Tagged interview"),
+		id3TextFrame("TDRC", "2024-09-12T14:30"),
+	)
+	data = append(data, make([]byte, 64)...) // Audio frames
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("audio", "interview.mp3")
+	suite.Require().NoError(err)
+	part.Write(data)
+	writer.Close()
+
+	req, _ := http.NewRequest("POST", "/api/v1/transcription/submit", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var job models.TranscriptionJob
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &job))
+	suite.Require().NotNil(job.Title)
+	assert.Equal(suite.T(), "Tagged interview", *job.Title)
+	suite.Require().NotNil(job.RecordedAt)
+	assert.Equal(suite.T(), time.Date(2024, 9, 12, 14, 30, 0, 0, time.UTC), job.RecordedAt.UTC())
+	assert.Equal(suite.T(), "metadata", *job.RecordedAtSource)
+}
+
+// Test uploads are probed and rejected when their content is not the claimed container
+func (suite *APIHandlerTestSuite) TestUploadProbe() {
+	ffprobe := filepath.Join(suite.T().TempDir(), "ffprobe")
+	assert.NoError(suite.T(), os.WriteFile(ffprobe, []byte(`#!/bin/sh
+cat <<'EOF'
+{"streams": [{"codec_type": "audio", "codec_name": "pcm_s16le", "sample_rate": "16000", "channels": 1, "duration": "2.5", "bit_rate": "256000"}], "format": {"format_name": "wav"}}
+EOF
+`), 0755))
+	suite.handler.SetFFprobePath(ffprobe)
+	defer suite.handler.SetFFprobePath("ffprobe")
+
+	upload := func(name string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("audio", name)
+		assert.NoError(suite.T(), err)
+		part.Write([]byte("RIFF audio"))
+		writer.Close()
+
+		req, _ := http.NewRequest("POST", "/api/v1/transcription/upload", body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+
+	w := upload("talk.wav")
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+	var job models.TranscriptionJob
+	assert.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &job))
+	defer os.Remove(job.AudioPath)
+	assert.NoError(suite.T(), suite.helper.DB.Where("id = ?", job.ID).First(&job).Error)
+	assert.Equal(suite.T(), 2.5, *job.AudioDuration)
+	assert.Equal(suite.T(), 16000, *job.AudioSampleRate)
+	assert.Equal(suite.T(), 1, *job.AudioChannels)
+	assert.Equal(suite.T(), "pcm_s16le", *job.AudioCodec)
+	assert.Equal(suite.T(), int64(256000), *job.AudioBitRate)
+
+	w = upload("talk.mp3")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "has a .mp3 extension but contains wav data")
+}
+
+// Test uploads ffmpeg cannot decode are refused before a job is created
+func (suite *APIHandlerTestSuite) TestUploadDecodeCheck() {
+	dir := suite.T().TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	suite.Require().NoError(os.WriteFile(ffmpeg, []byte(`#!/bin/sh
+echo "moov atom not found" >&2
+exit 1
+`), 0755))
+	suite.handler.SetFFprobePath(filepath.Join(dir, "missing-ffprobe"))
+	suite.handler.SetFFmpegPath(ffmpeg)
+	defer suite.handler.SetFFprobePath("ffprobe")
+	defer suite.handler.SetFFmpegPath("ffmpeg")
+	suite.helper.Config.DecodeCheckSeconds = 30
+	defer func() { suite.helper.Config.DecodeCheckSeconds = 0 }()
+	var before int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Count(&before)
+
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	part, err := writer.CreateFormFile("audio", "cut-off.m4a")
+	suite.Require().NoError(err)
+	part.Write([]byte("partial upload"))
+	writer.Close()
+	req, _ := http.NewRequest("POST", "/api/v1/transcription/upload", body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "file unreadable at 00:00: moov atom not found")
+	var after int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Count(&after)
+	assert.Equal(suite.T(), before, after)
+}
+
+// Test uploads nearly identical to an existing job are flagged or refused
+func (suite *APIHandlerTestSuite) TestUploadNearDuplicate() {
+	// The fake fpcalc reports the uploaded file's content as its output
+	fpcalc := filepath.Join(suite.T().TempDir(), "fpcalc")
+	suite.Require().NoError(os.WriteFile(fpcalc, []byte("#!/bin/sh\ncat \"$5\"\n"), 0755))
+	cfg := suite.helper.Config
+	cfg.FpcalcPath = fpcalc
+	cfg.DuplicateAudioCheck = api.DuplicateAudioWarn
+	cfg.DuplicateAudioSimilarity = 90
+	defer func() { cfg.DuplicateAudioCheck = "" }()
+
+	values := make([]uint32, 100)
+	for i := range values {
+		values[i] = uint32(i) * 2654435761
+	}
+	fingerprint := func(flipped int) string {
+		copied := append([]uint32(nil), values...)
+		for i := 0; i < flipped; i++ {
+			copied[i] = ^copied[i]
+		}
+		data, _ := json.Marshal(map[string]interface{}{"duration": 30, "fingerprint": copied})
+		return string(data)
+	}
+	upload := func(path, content string) *httptest.ResponseRecorder {
+		body := &bytes.Buffer{}
+		writer := multipart.NewWriter(body)
+		part, err := writer.CreateFormFile("audio", "call.mp3")
+		suite.Require().NoError(err)
+		part.Write([]byte(content))
+		writer.Close()
+		req, _ := http.NewRequest("POST", path, body)
+		req.Header.Set("Content-Type", writer.FormDataContentType())
+		req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+		w := httptest.NewRecorder()
+		suite.router.ServeHTTP(w, req)
+		return w
+	}
+	uploadJob := func(path, content string) models.TranscriptionJob {
+		w := upload(path, content)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		var job models.TranscriptionJob
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &job))
+		suite.T().Cleanup(func() { os.Remove(job.AudioPath) })
+		return job
+	}
+
+	original := uploadJob("/api/v1/transcription/upload", fingerprint(0))
+	assert.Nil(suite.T(), original.NearDuplicateOf)
+
+	// A copy differing in a few items is flagged, different audio is not
+	reencoded := uploadJob("/api/v1/transcription/upload", fingerprint(3))
+	suite.Require().NotNil(reencoded.NearDuplicateOf)
+	assert.Equal(suite.T(), original.ID, *reencoded.NearDuplicateOf)
+	different := uploadJob("/api/v1/transcription/upload", fingerprint(50))
+	assert.Nil(suite.T(), different.NearDuplicateOf)
+
+	cfg.DuplicateAudioCheck = api.DuplicateAudioBlock
+	w := upload("/api/v1/transcription/upload", fingerprint(2))
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	var refused map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &refused))
+	assert.Contains(suite.T(), []interface{}{original.ID, reencoded.ID}, refused["duplicate_of"])
+	assert.Greater(suite.T(), refused["similarity"], 0.9)
+
+	// Jobs submitted for transcription straight away are checked too
+	var before int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Count(&before)
+	w = upload("/api/v1/transcription/submit", fingerprint(1))
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	var after int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Count(&after)
+	assert.Equal(suite.T(), before, after)
+
+	cfg.DuplicateAudioCheck = api.DuplicateAudioWarn
+	submitted := uploadJob("/api/v1/transcription/submit", fingerprint(1))
+	suite.Require().NotNil(submitted.NearDuplicateOf)
+	assert.Contains(suite.T(), []string{original.ID, reencoded.ID}, *submitted.NearDuplicateOf)
+}
+
+// Test only failed merges of multi-track projects can be retried
+func (suite *APIHandlerTestSuite) TestRetryMerge() {
+	aupPath := "missing.aup"
+	folder := suite.T().TempDir()
+	single := &models.TranscriptionJob{Title: stringPtr("Single"), Status: models.StatusUploaded, AudioPath: "single.mp3"}
+	merging := &models.TranscriptionJob{Title: stringPtr("Merging"), Status: models.StatusUploaded, IsMultiTrack: true, AupFilePath: &aupPath, MultiTrackFolder: &folder, MergeStatus: "processing"}
+	suite.Require().NoError(suite.helper.DB.Create(single).Error)
+	suite.Require().NoError(suite.helper.DB.Create(merging).Error)
+
+	w := suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/"+single.ID+"/merge/retry", nil, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/"+merging.ID+"/merge/retry", nil, false)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/nonexistent/merge/retry", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	// The retry fails again on the missing project, as it did before
+	suite.helper.DB.Model(merging).Updates(map[string]interface{}{"merge_status": "failed", "merge_error": "ffmpeg exited"})
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/"+merging.ID+"/merge/retry", nil, false)
+	suite.Require().Equal(http.StatusAccepted, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"merge_status":"pending"`)
+	suite.Eventually(func() bool {
+		var stored models.TranscriptionJob
+		suite.helper.DB.First(&stored, "id = ?", merging.ID)
+		return stored.MergeStatus == "failed"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Test merges in progress can be cancelled, and retried once cancelled
+func (suite *APIHandlerTestSuite) TestCancelMerge() {
+	aupPath := "missing.aup"
+	folder := suite.T().TempDir()
+	job := &models.TranscriptionJob{Title: stringPtr("Merging"), Status: models.StatusUploaded, IsMultiTrack: true, AupFilePath: &aupPath, MultiTrackFolder: &folder, MergeStatus: "completed"}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+
+	w := suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/"+job.ID+"/merge/cancel", nil, false)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	suite.helper.DB.Model(job).Update("merge_status", "merge_queued")
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/"+job.ID+"/merge/cancel", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), `"merge_status":"cancelled"`)
+	var stored models.TranscriptionJob
+	suite.helper.DB.First(&stored, "id = ?", job.ID)
+	assert.Equal(suite.T(), "cancelled", stored.MergeStatus)
+
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/"+job.ID+"/merge/retry", nil, false)
+	assert.Equal(suite.T(), http.StatusAccepted, w.Code)
+	suite.Eventually(func() bool {
+		suite.helper.DB.First(&stored, "id = ?", job.ID)
+		return stored.MergeStatus == "failed"
+	}, 5*time.Second, 10*time.Millisecond)
+}
+
+// Test transcripts are served in the versioned structured format, words
+// grouped into their segments with mapped speaker names
+func (suite *APIHandlerTestSuite) TestStructuredTranscript() {
+	stored := `{"text":"Hello there. Hi.","language":"en",
+		"segments":[{"start":0,"end":1.5,"text":" Hello there.","speaker":"SPEAKER_00"},{"start":2,"end":2.5,"text":" Hi.","speaker":"SPEAKER_01"}],
+		"word_segments":[{"start":0,"end":0.5,"word":"Hello","score":0.9,"speaker":"SPEAKER_00"},{"start":0.6,"end":1.5,"word":"there.","score":0.7},{"start":2,"end":2.5,"word":"Hi."}]}`
+	job := &models.TranscriptionJob{Title: stringPtr("Structured"), Status: models.StatusCompleted, AudioPath: "structured.mp3", Transcript: &stored}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	suite.Require().NoError(suite.helper.DB.Create(&models.SpeakerMapping{TranscriptionJobID: job.ID, OriginalSpeaker: "SPEAKER_00", CustomName: "Alice"}).Error)
+
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/transcript?format=structured", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var structured transcript.Structured
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &structured))
+	assert.Equal(suite.T(), transcript.SchemaVersion, structured.SchemaVersion)
+	assert.Equal(suite.T(), job.ID, structured.JobID)
+	assert.Equal(suite.T(), "Hello there. Hi.", structured.Text)
+	assert.Equal(suite.T(), 2.5, structured.Duration)
+	assert.Equal(suite.T(), []string{"Alice", "SPEAKER_01"}, structured.Speakers)
+	suite.Require().Len(structured.Segments, 2)
+
+	first := structured.Segments[0]
+	suite.Require().Len(first.Words, 2)
+	assert.Equal(suite.T(), "Alice", *first.Speaker)
+	assert.Equal(suite.T(), "Alice", *first.Words[1].Speaker, "words without a speaker take their segment's")
+	suite.Require().NotNil(first.Confidence)
+	assert.Equal(suite.T(), 0.8, *first.Confidence)
+	second := structured.Segments[1]
+	suite.Require().Len(second.Words, 1)
+	assert.Nil(suite.T(), second.Words[0].Confidence, "unscored words have no confidence")
+	assert.Nil(suite.T(), second.Confidence)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/transcript?format=srt", nil, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+// Test transcript edits are stored as revisions that can be compared and reverted
+func (suite *APIHandlerTestSuite) TestTranscriptRevisions() {
+	stored := `{"text":"Hello there. Hi.","language":"en",
+		"segments":[{"start":2,"end":3,"text":" Hi.","speaker":"SPEAKER_01"},{"start":0,"end":1.5,"text":" Hello there.","speaker":"SPEAKER_00"}],
+		"word_segments":[{"start":0,"end":0.5,"word":"Hello","score":0.9},{"start":0.6,"end":1.5,"word":"there.","score":0.7},{"start":2,"end":3,"word":"Hi.","score":0.8}]}`
+	job := &models.TranscriptionJob{Title: stringPtr("Edited"), Status: models.StatusCompleted, AudioPath: "edited.mp3", Transcript: &stored}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	base := "/api/v1/transcription/" + job.ID + "/transcript"
+
+	w := suite.makeAuthenticatedRequest("GET", base+"/revisions", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.JSONEq(suite.T(), `[]`, w.Body.String())
+
+	// Segments are numbered by start time, so segment 1 is "Hi."
+	w = suite.makeAuthenticatedRequest("PUT", base+"/segments", map[string]interface{}{
+		"segments": []map[string]interface{}{
+			{"id": 1, "text": "Hi again, friend.", "speaker": "SPEAKER_00"},
+			{"id": 0, "start": 0.5},
+		},
+		"note": "Fixed the greeting",
+	}, true)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var rev models.TranscriptRevision
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &rev))
+	assert.Equal(suite.T(), 2, rev.Number)
+	assert.Equal(suite.T(), models.RevisionSourceEdit, rev.Source)
+	assert.Equal(suite.T(), "0,1", *rev.SegmentIDs)
+	assert.Equal(suite.T(), "Fixed the greeting", *rev.Note)
+	assert.NotEmpty(suite.T(), rev.Author)
+
+	w = suite.makeAuthenticatedRequest("GET", base+"?format=structured", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var structured transcript.Structured
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &structured))
+	assert.Equal(suite.T(), "Hello there. Hi again, friend.", structured.Text)
+	suite.Require().Len(structured.Segments, 2)
+	first, second := structured.Segments[0], structured.Segments[1]
+	assert.Equal(suite.T(), 0.5, first.Start)
+	suite.Require().Len(first.Words, 2)
+	assert.InDelta(suite.T(), 0.5, first.Words[0].Start, 1e-9, "words stretch to the new timing")
+	assert.InDelta(suite.T(), 0.9, *first.Words[0].Confidence, 1e-9)
+	suite.Require().Len(second.Words, 3, "rewritten text gets new words")
+	assert.Equal(suite.T(), "SPEAKER_00", *second.Speaker)
+	assert.Nil(suite.T(), second.Words[0].Confidence)
+	assert.Equal(suite.T(), "friend.", second.Words[2].Word)
+
+	w = suite.makeAuthenticatedRequest("GET", base+"/revisions", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var revisions []models.TranscriptRevision
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &revisions))
+	suite.Require().Len(revisions, 2)
+	assert.Equal(suite.T(), models.RevisionSourceTranscription, revisions[0].Source, "the machine original is kept")
+
+	w = suite.makeAuthenticatedRequest("GET", base+"/revisions/1", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "Hello there.")
+	assert.NotContains(suite.T(), w.Body.String(), "friend")
+
+	w = suite.makeAuthenticatedRequest("GET", base+"/diff", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var diff struct {
+		From     int                      `json:"from"`
+		To       int                      `json:"to"`
+		Segments []transcript.SegmentDiff `json:"segments"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Equal(suite.T(), 1, diff.From)
+	assert.Equal(suite.T(), 2, diff.To)
+	suite.Require().Len(diff.Segments, 2)
+	assert.Equal(suite.T(), []string{"start"}, diff.Segments[0].Fields)
+	assert.Equal(suite.T(), []string{"text", "speaker"}, diff.Segments[1].Fields)
+	assert.Equal(suite.T(), "Hi.", diff.Segments[1].Before.Text)
+
+	// Reverting is itself a revision
+	w = suite.makeAuthenticatedRequest("POST", base+"/revisions/1/revert", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &rev))
+	assert.Equal(suite.T(), 3, rev.Number)
+	assert.Equal(suite.T(), 1, *rev.RevertedTo)
+	w = suite.makeAuthenticatedRequest("GET", base+"/diff?from=1&to=3", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &diff))
+	assert.Empty(suite.T(), diff.Segments)
+	var reloaded models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", job.ID).First(&reloaded).Error)
+	assert.Equal(suite.T(), stored, *reloaded.Transcript)
+
+	for _, edit := range []map[string]interface{}{
+		{"id": 5, "text": "Nope"},
+		{"id": 0, "text": "  "},
+		{"id": 0, "start": 1.2, "end": 1},
+		{"id": 0, "start": 2.5, "end": 4},
+	} {
+		w = suite.makeAuthenticatedRequest("PUT", base+"/segments", map[string]interface{}{"segments": []map[string]interface{}{edit}}, false)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "edit %v", edit)
+	}
+	w = suite.makeAuthenticatedRequest("POST", base+"/revisions/9/revert", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/transcription/missing/transcript/segments", map[string]interface{}{"segments": []map[string]interface{}{{"id": 0, "text": "x"}}}, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// Test translations are listed and served apart from the original transcript
+func (suite *APIHandlerTestSuite) TestTranslations() {
+	stored := `{"text":"Bonjour.","language":"fr","segments":[{"start":0,"end":1,"text":"Bonjour.","speaker":"SPEAKER_00"}]}`
+	job := &models.TranscriptionJob{Title: stringPtr("Translated"), Status: models.StatusCompleted, AudioPath: "translated.mp3", Transcript: &stored}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	translated := `{"text":"Hello.","language":"en","segments":[{"start":0,"end":1,"text":"Hello.","speaker":"SPEAKER_00"}]}`
+	suite.Require().NoError(suite.helper.DB.Create(&models.Translation{TranscriptionID: job.ID, TargetLanguage: "en", Provider: models.TranslationProviderLLM, Model: "mt", Status: models.TranslationCompleted, Transcript: &translated}).Error)
+	suite.Require().NoError(suite.helper.DB.Create(&models.Translation{TranscriptionID: job.ID, TargetLanguage: "fi", Provider: models.TranslationProviderLLM, Model: "mt", Status: models.TranslationRunning}).Error)
+	suite.Require().NoError(suite.helper.DB.Create(&models.SpeakerMapping{TranscriptionJobID: job.ID, OriginalSpeaker: "SPEAKER_00", CustomName: "Alice"}).Error)
+
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/translations", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var translations []models.Translation
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &translations))
+	suite.Require().Len(translations, 2)
+	assert.Equal(suite.T(), "en", translations[0].TargetLanguage)
+	assert.NotContains(suite.T(), w.Body.String(), "Hello.", "listings leave out the text")
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/translations/en?format=structured", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var structured transcript.Structured
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &structured))
+	assert.Equal(suite.T(), "Hello.", structured.Text)
+	assert.Equal(suite.T(), "en", structured.Language)
+	assert.Equal(suite.T(), []string{"Alice"}, structured.Speakers)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/transcript", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "Bonjour.", "the original transcript is kept")
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/translations/fi", nil, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/translations/de", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/"+job.ID+"/translations", map[string]interface{}{"languages": []string{"de"}, "provider": "whisper"}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/missing/translations", map[string]interface{}{"languages": []string{"de"}}, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// Test insights are served with their action items and chapters, and that
+// asking for new ones needs a configured endpoint
+func (suite *APIHandlerTestSuite) TestInsights() {
+	stored := `{"text":"Hello.","language":"en","segments":[{"start":0,"end":1,"text":"Hello."}]}`
+	job := &models.TranscriptionJob{Title: stringPtr("Insightful"), Status: models.StatusCompleted, AudioPath: "insightful.mp3", Transcript: &stored}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/insights", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	summary := "A greeting."
+	items := `[{"text":"Say hello back","owner":"Alice"}]`
+	chapters := `[{"start":0,"end":1,"title":"Greeting"}]`
+	suite.Require().NoError(suite.helper.DB.Create(&models.TranscriptInsights{TranscriptionID: job.ID, Model: "insight-model", Status: models.InsightsCompleted, Summary: &summary, ActionItems: &items, Chapters: &chapters}).Error)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/insights", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var insights api.InsightsResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &insights))
+	assert.Equal(suite.T(), models.InsightsCompleted, insights.Status)
+	suite.Require().NotNil(insights.Summary)
+	assert.Equal(suite.T(), summary, *insights.Summary)
+	assert.Equal(suite.T(), []postprocess.ActionItem{{Text: "Say hello back", Owner: "Alice"}}, insights.ActionItems)
+	assert.Equal(suite.T(), []postprocess.Chapter{{Start: 0, End: 1, Title: "Greeting"}}, insights.Chapters)
+
+	// No post-processing endpoint is configured in tests
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/"+job.ID+"/insights", nil, false)
+	assert.Equal(suite.T(), http.StatusNotImplemented, w.Code)
+}
+
+// Test a job's event stream opens with its state so far, passes on segments
+// as they are transcribed and ends when the job completes
+func (suite *APIHandlerTestSuite) TestStreamJobEvents() {
+	job := &models.TranscriptionJob{Title: stringPtr("Streamed"), Status: models.StatusProcessing, AudioPath: "streamed.mp3"}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	transcription.Progresses.Report(job.ID, interfaces.TranscriptionProgress{Percent: 25, Segment: &interfaces.TranscriptSegment{Start: 0, End: 2.5, Text: "First."}})
+	defer transcription.Progresses.Clear(job.ID)
+
+	server := httptest.NewServer(suite.router)
+	defer server.Close()
+	req, err := http.NewRequest("GET", server.URL+"/api/v1/job/"+job.ID+"/events", nil)
+	suite.Require().NoError(err)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	resp, err := http.DefaultClient.Do(req)
+	suite.Require().NoError(err)
+	defer resp.Body.Close()
+	suite.Require().Equal(http.StatusOK, resp.StatusCode)
+	assert.Contains(suite.T(), resp.Header.Get("Content-Type"), "text/event-stream")
+
+	reader := bufio.NewReader(resp.Body)
+	next := func() (string, string) {
+		var name, data string
+		for {
+			line, err := reader.ReadString('\n')
+			suite.Require().NoError(err)
+			line = strings.TrimRight(line, "\n")
+			switch {
+			case strings.HasPrefix(line, "event:"):
+				name = strings.TrimPrefix(line, "event:")
+			case strings.HasPrefix(line, "data:"):
+				data = strings.TrimPrefix(line, "data:")
+			case line == "" && name != "":
+				return name, data
+			}
+		}
+	}
+
+	name, data := next()
+	suite.Require().Equal("status", name)
+	var status jobstate.Event
+	suite.Require().NoError(json.Unmarshal([]byte(data), &status))
+	assert.Equal(suite.T(), models.StatusProcessing, status.To)
+
+	name, data = next()
+	suite.Require().Equal("segment", name)
+	assert.Contains(suite.T(), data, "First.")
+	name, data = next()
+	suite.Require().Equal("progress", name)
+	assert.JSONEq(suite.T(), `{"percent":25}`, data)
+
+	// Updates after the stream opened follow
+	transcription.Progresses.Report(job.ID, interfaces.TranscriptionProgress{Percent: 60, Segment: &interfaces.TranscriptSegment{Start: 2.5, End: 6, Text: "Second."}})
+	name, data = next()
+	suite.Require().Equal("segment", name)
+	var segment interfaces.TranscriptSegment
+	suite.Require().NoError(json.Unmarshal([]byte(data), &segment))
+	assert.Equal(suite.T(), "Second.", segment.Text)
+	assert.Equal(suite.T(), 2.5, segment.Start)
+	name, data = next()
+	suite.Require().Equal("progress", name)
+	assert.JSONEq(suite.T(), `{"percent":60}`, data)
+
+	_, err = jobstate.Transition(job.ID, models.StatusCompleted)
+	suite.Require().NoError(err)
+	name, data = next()
+	suite.Require().Equal("status", name)
+	suite.Require().NoError(json.Unmarshal([]byte(data), &status))
+	assert.Equal(suite.T(), models.StatusProcessing, status.From)
+	assert.Equal(suite.T(), models.StatusCompleted, status.To)
+	rest, err := io.ReadAll(reader)
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), strings.TrimSpace(string(rest)), "the stream ends with the job")
+
+	// A finished job only streams its status
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/job/"+job.ID+"/events", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Equal(suite.T(), 1, strings.Count(w.Body.String(), "event:"))
+	assert.Contains(suite.T(), w.Body.String(), "event:status")
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/job/missing/events", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// Test users manage their own webhooks and read their delivery log
+func (suite *APIHandlerTestSuite) TestWebhooks() {
+	w := suite.makeAuthenticatedRequest("POST", "/api/v1/webhooks", map[string]interface{}{"url": "https://example.com/hook", "events": []string{"job.failed"}}, true)
+	suite.Require().Equal(http.StatusCreated, w.Code)
+	var created api.CreateWebhookResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &created))
+	assert.NotEmpty(suite.T(), created.Secret)
+	assert.Equal(suite.T(), []string{"job.failed"}, created.Events)
+	assert.True(suite.T(), created.IsActive)
+	id := fmt.Sprint(created.ID)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/webhooks", nil, true)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.NotContains(suite.T(), w.Body.String(), created.Secret, "the secret is only shown once")
+	var hooks []api.WebhookResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &hooks))
+	suite.Require().Len(hooks, 1)
+
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/webhooks/"+id, map[string]interface{}{"events": []string{}, "is_active": false}, true)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var updated api.WebhookResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &updated))
+	assert.Empty(suite.T(), updated.Events)
+	assert.False(suite.T(), updated.IsActive)
+	assert.Equal(suite.T(), "https://example.com/hook", updated.URL)
+
+	suite.Require().NoError(suite.helper.DB.Create(&models.WebhookDelivery{WebhookID: created.ID, JobID: "job-1", Event: "job.completed", Payload: "{}", Status: models.WebhookDeliveryFailed, Attempts: 6}).Error)
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/webhooks/"+id+"/deliveries", nil, true)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var deliveries []models.WebhookDelivery
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &deliveries))
+	suite.Require().Len(deliveries, 1)
+	assert.Equal(suite.T(), 6, deliveries[0].Attempts)
+
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/webhooks", map[string]interface{}{"url": "ftp://example.com/hook"}, true)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/webhooks", map[string]interface{}{"url": "https://example.com/hook", "events": []string{"job.started"}}, true)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/webhooks", nil, false)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code, "webhooks belong to users, not API keys")
+
+	// Other users' webhooks are out of reach
+	other := &models.Webhook{UserID: suite.helper.TestUser.ID + 1, URL: "https://example.com/other", Secret: "x", IsActive: true}
+	suite.Require().NoError(suite.helper.DB.Create(other).Error)
+	w = suite.makeAuthenticatedRequest("DELETE", fmt.Sprintf("/api/v1/webhooks/%d", other.ID), nil, true)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	w = suite.makeAuthenticatedRequest("DELETE", "/api/v1/webhooks/"+id, nil, true)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var remaining int64
+	suite.helper.DB.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", created.ID).Count(&remaining)
+	assert.Zero(suite.T(), remaining)
+}
+
+// Test jobs submitted by URL download their media, then queue for transcription
+func (suite *APIHandlerTestSuite) TestSubmitURL() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/wav")
+		w.Write([]byte("RIFF fake audio"))
+	}))
+	defer server.Close()
+
+	w := suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/url", map[string]interface{}{"url": server.URL + "/standup?X-Amz-Signature=secret", "title": "Standup"}, false)
+	suite.Require().Equal(http.StatusAccepted, w.Code, w.Body.String())
+	assert.NotContains(suite.T(), w.Body.String(), "secret", "presigned URLs are not echoed")
+	var submitted api.SubmitURLResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &submitted))
+	assert.Equal(suite.T(), models.StatusUploaded, submitted.Job.Status)
+	assert.Equal(suite.T(), models.DownloadSourceHTTP, submitted.Download.Source)
+	assert.Equal(suite.T(), "small", submitted.Job.Parameters.Model)
+	suite.handler.Downloads().Wait(submitted.Job.ID)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+submitted.Job.ID+"/download", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var download models.RemoteDownload
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &download))
+	assert.Equal(suite.T(), models.DownloadCompleted, download.Status)
+	assert.Equal(suite.T(), float64(100), download.Progress)
+
+	var job models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", submitted.Job.ID).First(&job).Error)
+	assert.Equal(suite.T(), models.StatusPending, job.Status)
+	assert.Equal(suite.T(), ".wav", filepath.Ext(job.AudioPath))
+	suite.Require().NotNil(job.Title)
+	assert.Equal(suite.T(), "Standup", *job.Title, "a given title is kept")
+	os.Remove(job.AudioPath)
+
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/url", map[string]interface{}{"url": "file:///etc/passwd"}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/url", map[string]interface{}{"url": "http://169.254.169.254/latest/meta-data/"}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "internal addresses are refused")
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/url", map[string]interface{}{"url": "https://youtu.be/abc"}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "YouTube is off unless enabled")
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/youtube", map[string]interface{}{"url": "https://youtu.be/abc"}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "the YouTube endpoint honors the same switch")
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/youtube", map[string]interface{}{"url": server.URL + "/youtube.com/watch"}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "the YouTube endpoint takes YouTube URLs only")
+
+	// A job still waiting for its media cannot be started
+	waiting := models.TranscriptionJob{ID: "url-waiting", Status: models.StatusUploaded}
+	suite.Require().NoError(suite.helper.DB.Create(&waiting).Error)
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/url-waiting/start", nil, false)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/url-waiting/download", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// Test starting a job with a schedule holds it back and lists it on the schedule
+func (suite *APIHandlerTestSuite) TestScheduledStart() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Overnight job")
+	suite.Require().NoError(suite.helper.DB.Model(job).Update("status", models.StatusUploaded).Error)
+	path := "/api/v1/transcription/" + job.ID + "/start"
+
+	w := suite.makeAuthenticatedRequest("POST", path+"?schedule=nightly", map[string]interface{}{}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", path+"?schedule=@daily&run_after=2030-01-01T00:00:00Z", map[string]interface{}{}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	w = suite.makeAuthenticatedRequest("POST", path+"?schedule=0+3+*+*+*", map[string]interface{}{}, false)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var stored models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	assert.Equal(suite.T(), models.StatusPending, stored.Status)
+	suite.Require().NotNil(stored.RunAfter)
+	assert.True(suite.T(), stored.RunAfter.After(time.Now()))
+	assert.Equal(suite.T(), 3, stored.RunAfter.Local().Hour())
+	assert.Zero(suite.T(), stored.RunAfter.Minute())
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/admin/queue/schedule", nil, true)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var schedule api.ScheduleResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &schedule))
+	ids := []string{}
+	for _, scheduled := range schedule.Jobs {
+		ids = append(ids, scheduled.ID)
+	}
+	assert.Contains(suite.T(), ids, job.ID)
+}
+
+// Test jobs can be cancelled, paused and resumed only from the states that allow it
+func (suite *APIHandlerTestSuite) TestJobControl() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Wrong submission")
+	path := "/api/v1/transcription/" + job.ID
+
+	w := suite.makeAuthenticatedRequest("POST", "/api/v1/transcription/missing-job/cancel", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+	w = suite.makeAuthenticatedRequest("POST", path+"/pause", nil, false)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code, "a queued job is not running")
+	w = suite.makeAuthenticatedRequest("POST", path+"/resume", nil, false)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	w = suite.makeAuthenticatedRequest("POST", path+"/cancel", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var stored models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	assert.Equal(suite.T(), models.StatusFailed, stored.Status)
+	w = suite.makeAuthenticatedRequest("POST", path+"/cancel", nil, false)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	resumeFrom := 42.5
+	suite.Require().NoError(suite.helper.DB.Model(job).Updates(map[string]interface{}{"status": models.StatusPaused, "resume_from": resumeFrom}).Error)
+	w = suite.makeAuthenticatedRequest("POST", path+"/resume", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var resumed models.TranscriptionJob
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resumed))
+	assert.Equal(suite.T(), models.StatusPending, resumed.Status)
+	suite.Require().NotNil(resumed.ResumeFrom, "the checkpoint is kept")
+	assert.Equal(suite.T(), resumeFrom, *resumed.ResumeFrom)
+}
+
+func (suite *APIHandlerTestSuite) TestModelManagement() {
+	dir := suite.T().TempDir()
+	python := filepath.Join(dir, "python")
+	suite.Require().NoError(os.WriteFile(python, []byte(`#!/bin/sh
+dir="$4/models--$(echo "$3" | sed 's|/|--|')"
+mkdir -p "$dir/snapshots/main"
+printf 'weights' > "$dir/snapshots/main/model.bin"
+`), 0755))
+	suite.handler.SetWhisperModels(whispermodels.NewManager(nil, whispermodels.Options{
+		CacheDir: filepath.Join(dir, "hub"),
+		Python:   []string{python},
+		Jobs:     jobparams.Default,
+	}))
+
+	w := suite.makeAuthenticatedRequest("POST", "/api/v1/admin/models/tiny.en/download", nil, false)
+	suite.Require().Equal(http.StatusAccepted, w.Code, w.Body.String())
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/admin/models/enormous/download", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	var list api.ModelListResponse
+	suite.Eventually(func() bool {
+		w = suite.makeAuthenticatedRequest("GET", "/api/v1/admin/models", nil, false)
+		suite.Require().Equal(http.StatusOK, w.Code)
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &list))
+		return list.Models[0].Name == "tiny.en" && list.Models[0].Downloaded
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(suite.T(), filepath.Join(dir, "hub"), list.CacheDir)
+
+	w = suite.makeAuthenticatedRequest("POST", "/api/v1/admin/models/tiny.en/download", nil, false)
+	assert.Equal(suite.T(), http.StatusOK, w.Code, "already downloaded")
+	w = suite.makeAuthenticatedRequest("DELETE", "/api/v1/admin/models/small", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code, "not downloaded")
+	w = suite.makeAuthenticatedRequest("DELETE", "/api/v1/admin/models/tiny.en", nil, false)
+	assert.Equal(suite.T(), http.StatusOK, w.Code, w.Body.String())
+}
+
+// Test a merge dry run reports missing files, sample rate mismatches and
+// the size of the mix without merging anything
+func (suite *APIHandlerTestSuite) TestValidateMerge() {
+	dir := suite.T().TempDir()
+	ffprobe := filepath.Join(dir, "ffprobe")
+	suite.Require().NoError(os.WriteFile(ffprobe, []byte(`#!/bin/sh
+case "$*" in
+*guest*) rate=48000 ;;
+*) rate=44100 ;;
+esac
+echo '{"streams": [{"codec_type": "audio", "codec_name": "pcm_s16le", "sample_rate": "'$rate'", "channels": 1, "duration": "10.0"}], "format": {"format_name": "wav"}}'
+`), 0755))
+	suite.handler.SetFFprobePath(ffprobe)
+	defer suite.handler.SetFFprobePath("ffprobe")
+
+	aupPath := filepath.Join(dir, "project.aup")
+	suite.Require().NoError(os.WriteFile(aupPath, []byte(`<?xml version="1.0" standalone="no" ?>
+<project xmlns="http://audacity.sourceforge.net/xml/" audacityversion="2.4.2" rate="44100">
+  <wavetrack name="Host" channel="0" linked="0" mute="0" solo="0" rate="44100" gain="1.0" pan="0.0">
+    <waveclip offset="0.0"><import filename="host.wav" offset="0.0" channel="0"/></waveclip>
+  </wavetrack>
+  <wavetrack name="Guest" channel="0" linked="0" mute="0" solo="0" rate="44100" gain="1.0" pan="0.0">
+    <waveclip offset="5.0"><import filename="guest.wav" offset="0.0" channel="0"/></waveclip>
+  </wavetrack>
+  <wavetrack name="Music" channel="0" linked="0" mute="0" solo="0" rate="44100" gain="1.0" pan="0.0">
+    <waveclip offset="0.0"><import filename="music.wav" offset="0.0" channel="0"/></waveclip>
+  </wavetrack>
+</project>`), 0644))
+	os.WriteFile(filepath.Join(dir, "host.wav"), []byte("host"), 0644)
+	os.WriteFile(filepath.Join(dir, "guest.wav"), []byte("guest"), 0644)
+	job := &models.TranscriptionJob{
+		Title:            stringPtr("Dry run"),
+		Status:           models.StatusUploaded,
+		IsMultiTrack:     true,
+		AupFilePath:      &aupPath,
+		MultiTrackFolder: &dir,
+		MergeStatus:      "none",
+		MultiTrackFiles: []models.MultiTrackFile{
+			{FileName: "host", FilePath: filepath.Join(dir, "host.wav"), TrackIndex: 0},
+			{FileName: "guest", FilePath: filepath.Join(dir, "guest.wav"), TrackIndex: 1},
+		},
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/merge/validate", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var validation processing.MergeValidation
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &validation))
+	assert.True(suite.T(), validation.Valid)
+	assert.Equal(suite.T(), 15.0, validation.EstimatedDuration, "the guest starts at 5s and lasts 10s")
+	assert.Equal(suite.T(), int64(15*192000/8), validation.EstimatedSize)
+	assert.Greater(suite.T(), validation.EstimatedDiskUsage, validation.EstimatedSize)
+	suite.Require().Len(validation.Tracks, 2)
+	assert.Equal(suite.T(), 48000, validation.Tracks[1].SampleRate)
+	assert.Len(suite.T(), validation.Warnings, 2)
+	assert.Contains(suite.T(), strings.Join(validation.Warnings, "\n"), "music.wav, which was not uploaded")
+	assert.Contains(suite.T(), strings.Join(validation.Warnings, "\n"), "44100 Hz, 48000 Hz")
+
+	// A missing track file stops the merge; nothing was started
+	os.Remove(filepath.Join(dir, "guest.wav"))
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/merge/validate", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &validation))
+	assert.False(suite.T(), validation.Valid)
+	assert.Equal(suite.T(), []string{"Track file guest.wav is missing"}, validation.Errors)
+	var stored models.TranscriptionJob
+	suite.helper.DB.First(&stored, "id = ?", job.ID)
+	assert.Equal(suite.T(), "none", stored.MergeStatus)
+}
+
+// Test the merge status reports ffmpeg's progress, polled and streamed
+func (suite *APIHandlerTestSuite) TestMergeStatusProgress() {
+	job := &models.TranscriptionJob{
+		Title:        stringPtr("Merging"),
+		Status:       models.StatusUploaded,
+		AudioPath:    "merging.mp3",
+		IsMultiTrack: true,
+		MergeStatus:  "processing",
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	processing.MergeProgresses.Report(job.ID, audio.MergeProgress{Stage: "processing", Progress: 60, ProcessedSeconds: 5, TotalSeconds: 10})
+	defer processing.MergeProgresses.Report(job.ID, audio.MergeProgress{Stage: "failed"})
+
+	w := suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/merge-status", nil, false)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var status map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(suite.T(), "processing", status["merge_status"])
+	assert.Equal(suite.T(), 60.0, status["merge_progress"])
+	assert.Equal(suite.T(), 5.0, status["processed_seconds"])
+	assert.Equal(suite.T(), 10.0, status["total_seconds"])
+
+	// The stream runs until the merge ends
+	streamed := make(chan *httptest.ResponseRecorder)
+	go func() {
+		streamed <- suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/merge-status/stream", nil, false)
+	}()
+	var stream *httptest.ResponseRecorder
+	for stream == nil {
+		select {
+		case stream = <-streamed:
+		case <-time.After(10 * time.Millisecond):
+			processing.MergeProgresses.Report(job.ID, audio.MergeProgress{Stage: "completed", Progress: 100})
+		}
+	}
+	suite.Require().Equal(http.StatusOK, stream.Code)
+	lines := strings.Split(strings.TrimSpace(stream.Body.String()), "\n")
+	var first, last api.MergeStatusUpdate
+	suite.Require().NoError(json.Unmarshal([]byte(lines[0]), &first))
+	suite.Require().NoError(json.Unmarshal([]byte(lines[len(lines)-1]), &last))
+	assert.Equal(suite.T(), "processing", first.MergeStatus)
+	assert.Equal(suite.T(), "completed", last.MergeStatus)
+	assert.Equal(suite.T(), 100.0, last.Progress)
+
+	// A finished merge streams its outcome and ends
+	suite.helper.DB.Model(job).Updates(map[string]interface{}{"merge_status": "failed", "merge_error": "ffmpeg exited"})
+	stream = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/"+job.ID+"/merge-status/stream", nil, false)
+	suite.Require().NoError(json.Unmarshal(stream.Body.Bytes(), &last))
+	assert.Equal(suite.T(), "failed", last.MergeStatus)
+	assert.Equal(suite.T(), "ffmpeg exited", last.MergeError)
+
+	w = suite.makeAuthenticatedRequest("GET", "/api/v1/transcription/missing/merge-status/stream", nil, false)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// Test naming the speakers of a multi-track job's tracks
+func (suite *APIHandlerTestSuite) TestUpdateTrackSpeakers() {
+	folder := suite.T().TempDir()
+	job := &models.TranscriptionJob{
+		ID:               "track-speakers-job",
+		Title:            stringPtr("Interview"),
+		Status:           models.StatusUploaded,
+		IsMultiTrack:     true,
+		MultiTrackFolder: &folder,
+		MergeStatus:      "completed",
+		MultiTrackFiles: []models.MultiTrackFile{
+			{FileName: "host", FilePath: filepath.Join(folder, "host.wav"), TrackIndex: 0},
+			{FileName: "guest", FilePath: filepath.Join(folder, "guest.wav"), TrackIndex: 1, SpeakerName: stringPtr("Guest")},
+		},
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+
+	w := suite.makeAuthenticatedRequest("PUT", "/api/v1/transcription/"+job.ID+"/track-speakers",
+		map[string]interface{}{"speakers": map[string]string{"host.wav": " Alice ", "guest": ""}}, false)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+
+	var tracks []models.MultiTrackFile
+	suite.helper.DB.Where("transcription_job_id = ?", job.ID).Order("track_index").Find(&tracks)
+	suite.Require().Len(tracks, 2)
+	suite.Require().NotNil(tracks[0].SpeakerName)
+	assert.Equal(suite.T(), "Alice", *tracks[0].SpeakerName)
+	assert.Nil(suite.T(), tracks[1].SpeakerName)
+
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/transcription/"+job.ID+"/track-speakers",
+		map[string]interface{}{"speakers": map[string]string{"cohost": "Bob"}}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+
+	single := suite.helper.CreateTestTranscriptionJob(suite.T(), "Single track")
+	w = suite.makeAuthenticatedRequest("PUT", "/api/v1/transcription/"+single.ID+"/track-speakers",
+		map[string]interface{}{"speakers": map[string]string{"host": "Alice"}}, false)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
 // Test error responses for non-existent resources
 func (suite *APIHandlerTestSuite) TestNotFoundErrors() {
 	endpoints := []string{
@@ -0,0 +1,104 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type AudioDownloadTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+	memFS  *fsys.MemFS
+	router *gin.Engine
+}
+
+func (suite *AudioDownloadTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "audio_download_test.db")
+	suite.memFS = fsys.NewMemFS()
+	suite.Require().NoError(suite.memFS.MkdirAll("uploads", 0755))
+
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, nil, nil, nil)
+	handler.SetFS(suite.memFS)
+	suite.router = api.SetupRoutes(handler, suite.helper.AuthService)
+}
+
+func (suite *AudioDownloadTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *AudioDownloadTestSuite) get(path string, header map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	for name, value := range header {
+		req.Header.Set(name, value)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *AudioDownloadTestSuite) TestOriginal() {
+	suite.Require().NoError(fsys.WriteFile(suite.memFS, "uploads/talk.wav", []byte("0123456789")))
+	job := &models.TranscriptionJob{AudioPath: "uploads/talk.wav", Status: models.StatusCompleted}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	path := "/api/v1/transcription/" + job.ID + "/audio/original"
+
+	w := suite.get(path, nil)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), "audio/wav", w.Header().Get("Content-Type"))
+	assert.Equal(suite.T(), "bytes", w.Header().Get("Accept-Ranges"))
+	assert.Equal(suite.T(), "0123456789", w.Body.String())
+	assert.Empty(suite.T(), w.Header().Get("Content-Disposition"))
+
+	w = suite.get(path, map[string]string{"Range": "bytes=2-5"})
+	suite.Require().Equal(http.StatusPartialContent, w.Code)
+	assert.Equal(suite.T(), "bytes 2-5/10", w.Header().Get("Content-Range"))
+	assert.Equal(suite.T(), "2345", w.Body.String())
+	assert.Equal(suite.T(), "audio/wav", w.Header().Get("Content-Type"))
+
+	w = suite.get(path, map[string]string{"Range": "bytes=20-"})
+	assert.Equal(suite.T(), http.StatusRequestedRangeNotSatisfiable, w.Code)
+
+	w = suite.get(path+"?download=true", nil)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Equal(suite.T(), `attachment; filename=talk.wav`, w.Header().Get("Content-Disposition"))
+
+	assert.Equal(suite.T(), http.StatusNotFound, suite.get(path[:len(path)-len("/original")]+"/merged", nil).Code, "single-track jobs have no mixdown")
+	assert.Equal(suite.T(), http.StatusNotFound, suite.get("/api/v1/transcription/missing/audio/original", nil).Code)
+
+	removed := time.Now()
+	suite.Require().NoError(suite.memFS.Remove("uploads/talk.wav"))
+	suite.Require().NoError(suite.helper.DB.Model(job).Update("source_audio_removed_at", &removed).Error)
+	assert.Equal(suite.T(), http.StatusGone, suite.get(path, nil).Code)
+}
+
+func (suite *AudioDownloadTestSuite) TestMerged() {
+	suite.Require().NoError(fsys.WriteFile(suite.memFS, "uploads/session.aup", []byte("project")))
+	suite.Require().NoError(fsys.WriteFile(suite.memFS, "uploads/session_merged.mp3", []byte("mixed audio")))
+	merged := "uploads/session_merged.mp3"
+	job := &models.TranscriptionJob{AudioPath: "uploads/session.aup", Status: models.StatusCompleted, IsMultiTrack: true, MergedAudioPath: &merged}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+
+	w := suite.get("/api/v1/transcription/"+job.ID+"/audio/merged", map[string]string{"Range": "bytes=-5"})
+	suite.Require().Equal(http.StatusPartialContent, w.Code, w.Body.String())
+	assert.Equal(suite.T(), "audio/mpeg", w.Header().Get("Content-Type"))
+	assert.Equal(suite.T(), "bytes 6-10/11", w.Header().Get("Content-Range"))
+	assert.Equal(suite.T(), "audio", w.Body.String())
+
+	suite.Require().NoError(suite.memFS.Remove(merged))
+	assert.Equal(suite.T(), http.StatusNotFound, suite.get("/api/v1/transcription/"+job.ID+"/audio/merged", nil).Code)
+}
+
+func TestAudioDownloadTestSuite(t *testing.T) {
+	suite.Run(t, new(AudioDownloadTestSuite))
+}
@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"synthezia/internal/audio"
+	"synthezia/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type AudioTagsTestSuite struct {
+	suite.Suite
+}
+
+func vorbisComments(comments ...string) []byte {
+	vendor := "test"
+	out := binary.LittleEndian.AppendUint32(nil, uint32(len(vendor)))
+	out = append(out, vendor...)
+	out = binary.LittleEndian.AppendUint32(out, uint32(len(comments)))
+	for _, comment := range comments {
+		out = binary.LittleEndian.AppendUint32(out, uint32(len(comment)))
+		out = append(out, comment...)
+	}
+	return out
+}
+
+func oggPage(packet []byte) []byte {
+	page := []byte("OggS")
+	page = append(page, make([]byte, 22)...)
+	var lacing []byte
+	n := len(packet)
+	for n >= 255 {
+		lacing = append(lacing, 255)
+		n -= 255
+	}
+	lacing = append(lacing, byte(n))
+	page = append(page, byte(len(lacing)))
+	page = append(page, lacing...)
+	return append(page, packet...)
+}
+
+func mp4Box(kind string, payload ...[]byte) []byte {
+	data := bytes.Join(payload, nil)
+	box := binary.BigEndian.AppendUint32(nil, uint32(8+len(data)))
+	box = append(box, kind...)
+	return append(box, data...)
+}
+
+func mp4Item(kind, value string) []byte {
+	return mp4Box(kind, mp4Box("data", []byte{0, 0, 0, 1, 0, 0, 0, 0}, []byte(value)))
+}
+
+// Test ID3v2.4 text frames and chapters
+func (suite *AudioTagsTestSuite) TestID3v24() {
+	chapter := append([]byte("ch0\x00"), 0, 0, 0, 0, 0, 0, 0x75, 0x30, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff)
+	chapter = append(chapter, id3TextFrame("TIT2", "Opening")...)
+	chapFrame := append([]byte("CHAP"), byte(len(chapter)>>21&0x7f), byte(len(chapter)>>14&0x7f), byte(len(chapter)>>7&0x7f), byte(len(chapter)&0x7f), 0, 0)
+	chapFrame = append(chapFrame, chapter...)
+
+	data := id3Tag(4,
+		id3TextFrame("TIT2", "Board meeting"),
+		id3TextFrame("TPE1", "Finance team"),
+		id3TextFrame("TALB", "Q3"),
+		id3TextFrame("TDRC", "2024-09-12T14:30"),
+		chapFrame,
+	)
+	data = append(data, make([]byte, 64)...) // Audio frames
+
+	tags, err := audio.ReadTags(bytes.NewReader(data))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "id3v2", tags.Format)
+	assert.Equal(suite.T(), "Board meeting", tags.Title)
+	assert.Equal(suite.T(), "Finance team", tags.Artist)
+	require.NotNil(suite.T(), tags.RecordedAt)
+	assert.Equal(suite.T(), time.Date(2024, 9, 12, 14, 30, 0, 0, time.UTC), *tags.RecordedAt)
+	require.Len(suite.T(), tags.Chapters, 1)
+	assert.Equal(suite.T(), "Opening", tags.Chapters[0].Title)
+	assert.Equal(suite.T(), 30.0, tags.Chapters[0].End)
+}
+
+// Test ID3v2.3 split date frames
+func (suite *AudioTagsTestSuite) TestID3v23Date() {
+	data := id3Tag(3,
+		id3TextFrame("TYER", "2023"),
+		id3TextFrame("TDAT", "0511"),
+		id3TextFrame("TIME", "0915"),
+	)
+	tags, err := audio.ReadTags(bytes.NewReader(data))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "2023-11-05T09:15", tags.Date)
+	require.NotNil(suite.T(), tags.RecordedAt)
+}
+
+// Test a bare year is kept but not used as a recording date
+func (suite *AudioTagsTestSuite) TestYearOnlyDate() {
+	tags, err := audio.ReadTags(bytes.NewReader(id3Tag(3, id3TextFrame("TYER", "2021"))))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "2021", tags.Date)
+	assert.Nil(suite.T(), tags.RecordedAt)
+}
+
+// Test FLAC Vorbis comments with chapters
+func (suite *AudioTagsTestSuite) TestFLAC() {
+	comments := vorbisComments("TITLE=Interview", "ARTIST=Alice", "DATE=2022-03-04",
+		"CHAPTER001=00:00:00.000", "CHAPTER001NAME=Intro", "CHAPTER002=00:01:30.500", "CHAPTER002NAME=Questions")
+	data := []byte("fLaC")
+	data = append(data, 0x00, 0, 0, 34) // STREAMINFO
+	data = append(data, make([]byte, 34)...)
+	data = append(data, 0x84, byte(len(comments)>>16), byte(len(comments)>>8), byte(len(comments)))
+	data = append(data, comments...)
+
+	tags, err := audio.ReadTags(bytes.NewReader(data))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "vorbis", tags.Format)
+	assert.Equal(suite.T(), "Interview", tags.Title)
+	require.Len(suite.T(), tags.Chapters, 2)
+	assert.Equal(suite.T(), 90.5, tags.Chapters[1].Start)
+	assert.Equal(suite.T(), 90.5, tags.Chapters[0].End)
+}
+
+// Test Opus comment header spread over Ogg pages
+func (suite *AudioTagsTestSuite) TestOggOpus() {
+	head := append([]byte("OpusHead"), make([]byte, 11)...)
+	comment := append([]byte("OpusTags"), vorbisComments("TITLE=Voice memo", "GENRE=Speech")...)
+	data := append(oggPage(head), oggPage(comment)...)
+
+	tags, err := audio.ReadTags(bytes.NewReader(data))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "Voice memo", tags.Title)
+	assert.Equal(suite.T(), "Speech", tags.Genre)
+}
+
+// Test MP4 ilst items and Nero chapters behind an mdat box
+func (suite *AudioTagsTestSuite) TestMP4() {
+	chpl := []byte{1, 0, 0, 0, 0, 0, 0, 0, 2}
+	chpl = binary.BigEndian.AppendUint64(chpl, 0)
+	chpl = append(chpl, 5)
+	chpl = append(chpl, "Start"...)
+	chpl = binary.BigEndian.AppendUint64(chpl, 600_000_000)
+	chpl = append(chpl, 3)
+	chpl = append(chpl, "End"...)
+
+	ilst := mp4Box("ilst", mp4Item("\xa9nam", "Lecture 4"), mp4Item("\xa9ART", "Prof. Smith"), mp4Item("\xa9day", "2020-01-15"))
+	meta := mp4Box("meta", []byte{0, 0, 0, 0}, ilst)
+	moov := mp4Box("moov", mp4Box("udta", meta, mp4Box("chpl", chpl)))
+	data := append(mp4Box("ftyp", []byte("M4A \x00\x00\x00\x00")), mp4Box("mdat", make([]byte, 128))...)
+	data = append(data, moov...)
+
+	tags, err := audio.ReadTags(bytes.NewReader(data))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "mp4", tags.Format)
+	assert.Equal(suite.T(), "Lecture 4", tags.Title)
+	assert.Equal(suite.T(), "Prof. Smith", tags.Artist)
+	require.NotNil(suite.T(), tags.RecordedAt)
+	require.Len(suite.T(), tags.Chapters, 2)
+	assert.Equal(suite.T(), 60.0, tags.Chapters[1].Start)
+}
+
+// Test files without metadata
+func (suite *AudioTagsTestSuite) TestNoTags() {
+	_, err := audio.ReadTags(bytes.NewReader([]byte("RIFF....WAVEfmt ")))
+	assert.ErrorIs(suite.T(), err, audio.ErrNoTags)
+}
+
+// Test tags fill only the fields the user left empty
+func (suite *AudioTagsTestSuite) TestApplyTags() {
+	recorded := time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC)
+	tags := &audio.Tags{Format: "id3v2", Title: "From tags", Artist: "Alice", Genre: "Podcast", RecordedAt: &recorded}
+
+	userTitle := "My title"
+	job := &models.TranscriptionJob{Title: &userTitle}
+	audio.ApplyTags(job, tags, false)
+	assert.Equal(suite.T(), "My title", *job.Title)
+	assert.Equal(suite.T(), recorded, *job.RecordedAt)
+
+	var keywords []string
+	require.NoError(suite.T(), json.Unmarshal([]byte(*job.Tags), &keywords))
+	assert.Equal(suite.T(), []string{"Alice", "Podcast"}, keywords)
+	assert.Contains(suite.T(), *job.AudioMetadata, `"format":"id3v2"`)
+
+	audio.ApplyTags(job, tags, true)
+	assert.Equal(suite.T(), "From tags", *job.Title)
+}
+
+// Test recording dates in recorder and phone file names
+func (suite *AudioTagsTestSuite) TestParseFilenameDate() {
+	cases := map[string]time.Time{
+		"20240912_143000.m4a":        time.Date(2024, 9, 12, 14, 30, 0, 0, time.Local),
+		"REC_2024-09-12-1430.wav":    time.Date(2024, 9, 12, 14, 30, 0, 0, time.Local),
+		"Voice 2023-01-05 08.15.mp3": time.Date(2023, 1, 5, 8, 15, 0, 0, time.Local),
+		"meeting-20220101.mp3":       time.Date(2022, 1, 1, 0, 0, 0, 0, time.Local),
+	}
+	for name, expected := range cases {
+		parsed := audio.ParseFilenameDate(name)
+		if assert.NotNil(suite.T(), parsed, name) {
+			assert.True(suite.T(), expected.Equal(*parsed), name)
+		}
+	}
+
+	assert.Nil(suite.T(), audio.ParseFilenameDate("interview.mp3"))
+	assert.Nil(suite.T(), audio.ParseFilenameDate("track_12345678.mp3"))
+	assert.Nil(suite.T(), audio.ParseFilenameDate("2024-02-30.mp3"))
+}
+
+func TestAudioTagsTestSuite(t *testing.T) {
+	suite.Run(t, new(AudioTagsTestSuite))
+}
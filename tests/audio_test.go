@@ -1,13 +1,24 @@
 package tests
 
 import (
+	"bytes"
 	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
 	"os"
+	"os/exec"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
+	"unicode/utf16"
 
+	"github.com/glebarez/sqlite"
+	"gorm.io/gorm"
 	"synthezia/internal/audio"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription/interfaces"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -203,6 +214,118 @@ func (suite *AudioTestSuite) TestMergeProgress() {
 	assert.Equal(suite.T(), "/path/to/output.mp3", progress.OutputPath)
 }
 
+// Test reading the progress ffmpeg writes with -progress
+func (suite *AudioTestSuite) TestReadFFmpegProgress() {
+	output := "bitrate=N/A\nout_time_us=N/A\nout_time=N/A\nprogress=continue\n" +
+		"bitrate=192.0kbits/s\nout_time_us=2500000\nout_time_ms=2500000\nout_time=00:00:02.500000\nprogress=continue\n" +
+		"out_time=00:01:00.000000\nprogress=continue\n" +
+		"out_time_us=90000000\nprogress=end\n"
+
+	var seconds []float64
+	var done []bool
+	err := audio.ReadFFmpegProgress(strings.NewReader(output), func(s float64, d bool) {
+		seconds = append(seconds, s)
+		done = append(done, d)
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []float64{2.5, 60, 90}, seconds)
+	assert.Equal(suite.T(), []bool{false, false, true}, done)
+}
+
+// Test reading input durations from the ffmpeg log and the length of the mix
+func (suite *AudioTestSuite) TestReadFFmpegInputDurations() {
+	log := "ffmpeg version 6.1\n" +
+		"Input #0, wav, from 'host.wav':\n  Duration: 00:01:30.50, bitrate: 1411 kb/s\n" +
+		"Input #1, mp3, from 'live.mp3':\n  Duration: N/A, bitrate: N/A\n" +
+		"Input #2, wav, from 'guest.wav':\n  Duration: 00:00:45.00, bitrate: 1411 kb/s\n" +
+		"Stream mapping:\n  Stream #0:0 (pcm_s16le) -> adelay\n" +
+		"Input #3, wav, from 'not-an-input.wav':\n  Duration: 00:10:00.00\n" +
+		"size=     256kB time=00:00:10.00 bitrate= 209.7kbits/s\r"
+
+	durations, err := audio.ReadFFmpegInputDurations(strings.NewReader(log))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[int]float64{0: 90.5, 2: 45}, durations)
+
+	tracks := []audio.TrackInfo{
+		{FilePath: "host.wav"},
+		{FilePath: "live.mp3", Offset: 200},
+		{FilePath: "guest.wav", Offset: 60},
+	}
+	assert.Equal(suite.T(), 105.0, audio.MergedDuration(tracks, durations))
+}
+
+// Test a merge reports how much of the mix ffmpeg has written
+func (suite *AudioTestSuite) TestMergeReportsFFmpegProgress() {
+	script := filepath.Join(suite.testDir, "progress-ffmpeg")
+	os.WriteFile(script, []byte(`#!/bin/sh
+for arg; do out="$arg"; done
+echo "Input #0, wav, from 'a.wav':" >&2
+echo "  Duration: 00:00:08.00, bitrate: 1411 kb/s" >&2
+echo "Input #1, wav, from 'b.wav':" >&2
+echo "  Duration: 00:00:06.00, bitrate: 1411 kb/s" >&2
+echo "Output #0, mp3, to '$out':" >&2
+printf 'out_time_us=0\nprogress=continue\n'
+printf 'out_time_us=5000000\nprogress=continue\n'
+printf 'out_time_us=5000000\nprogress=continue\n'
+printf 'out_time_us=10000000\nprogress=end\n'
+echo merged > "$out"
+`), 0755)
+
+	a := filepath.Join(suite.testDir, "a.wav")
+	b := filepath.Join(suite.testDir, "b.wav")
+	os.WriteFile(a, []byte("a"), 0644)
+	os.WriteFile(b, []byte("b"), 0644)
+	tracks := []audio.TrackInfo{
+		{FilePath: a, Gain: 1.0},
+		{FilePath: b, Offset: 4, Gain: 1.0},
+	}
+
+	var updates []audio.MergeProgress
+	merger := audio.NewAudioMergerWithPath(script)
+	err := merger.MergeTracksWithOffsets(context.Background(), tracks, filepath.Join(suite.testDir, "merged.mp3"), func(p audio.MergeProgress) {
+		if p.Stage == "processing" && p.TotalSeconds > 0 {
+			updates = append(updates, p)
+		}
+	})
+	suite.Require().NoError(err)
+
+	// The mix lasts until the second track ends, 4s + 6s; repeated blocks are not reported
+	if assert.Len(suite.T(), updates, 3) {
+		assert.Equal(suite.T(), 10.0, updates[0].TotalSeconds)
+		assert.Equal(suite.T(), 25.0, updates[0].Progress)
+		assert.Equal(suite.T(), 5.0, updates[1].ProcessedSeconds)
+		assert.Equal(suite.T(), 60.0, updates[1].Progress)
+		assert.Equal(suite.T(), 95.0, updates[2].Progress)
+	}
+}
+
+// Test soloing a track silences the unsoloed ones, as in Audacity
+func (suite *AudioTestSuite) TestAudibleTracks() {
+	tracks := []audio.TrackInfo{
+		{FilePath: "host.wav"},
+		{FilePath: "guest.wav", Solo: true},
+		{FilePath: "music.wav", Mute: true},
+		{FilePath: "remote.wav", Solo: true, Mute: true},
+	}
+	audible := audio.AudibleTracks(tracks)
+	if assert.Len(suite.T(), audible, 1) {
+		assert.Equal(suite.T(), "guest.wav", audible[0].FilePath)
+	}
+
+	// Without solos every unmuted track is heard
+	tracks[1].Solo = false
+	audible = audio.AudibleTracks(tracks)
+	if assert.Len(suite.T(), audible, 2) {
+		assert.Equal(suite.T(), "host.wav", audible[0].FilePath)
+		assert.Equal(suite.T(), "guest.wav", audible[1].FilePath)
+	}
+
+	// A muted solo does not silence the other tracks
+	tracks[1].Solo = false
+	tracks[3].Solo = true
+	assert.Len(suite.T(), audio.AudibleTracks(tracks), 2)
+}
+
 // Test TrackInfo structure
 func (suite *AudioTestSuite) TestTrackInfo() {
 	track := audio.TrackInfo{
@@ -330,6 +453,164 @@ func (suite *AudioTestSuite) TestParseAupFileMultipleClips() {
 	assert.Equal(suite.T(), 5.0, tracks[1].Offset)
 }
 
+// Test parsing the volume envelopes of AUP clips
+func (suite *AudioTestSuite) TestParseAupEnvelope() {
+	aupContent := `<?xml version="1.0" standalone="no" ?>
+<project xmlns="http://audacity.sourceforge.net/xml/" audacityversion="2.4.2" rate="44100">
+  <wavetrack name="Host" channel="0" linked="0" mute="0" solo="0" rate="44100" gain="1.0" pan="0.0">
+    <waveclip offset="3.0">
+      <envelope numpoints="3">
+        <controlpoint t="0.0000000000" val="0.0000000000"/>
+        <controlpoint t="2.0000000000" val="1.0000000000"/>
+        <controlpoint t="30.5000000000" val="0.5000000000"/>
+      </envelope>
+      <import filename="host.wav" offset="0.0" channel="0"/>
+    </waveclip>
+  </wavetrack>
+</project>`
+
+	tracks, err := audio.NewAupParser().Parse([]byte(aupContent))
+	suite.Require().NoError(err)
+	suite.Require().Len(tracks, 1)
+	assert.Equal(suite.T(), []audio.EnvelopePoint{{Time: 0, Value: 0}, {Time: 2, Value: 1}, {Time: 30.5, Value: 0.5}}, tracks[0].Envelope)
+}
+
+// mergeFilter merges tracks with a fake ffmpeg and returns the filter graph it was given
+func (suite *AudioTestSuite) mergeFilter(name string, tracks []audio.TrackInfo) string {
+	argsFile := filepath.Join(suite.testDir, name+"-args")
+	script := filepath.Join(suite.testDir, name+"-ffmpeg")
+	os.WriteFile(script, []byte("#!/bin/sh\nfor arg; do out=\"$arg\"; echo \"$arg\" >> "+argsFile+"; done\necho merged > \"$out\"\n"), 0755)
+
+	merger := audio.NewAudioMergerWithPath(script)
+	err := merger.MergeTracksWithOffsets(context.Background(), tracks, filepath.Join(suite.testDir, name+".mp3"), nil)
+	suite.Require().NoError(err)
+
+	args, err := os.ReadFile(argsFile)
+	suite.Require().NoError(err)
+	lines := strings.Split(string(args), "\n")
+	for i, line := range lines {
+		if line == "-filter_complex" {
+			return lines[i+1]
+		}
+	}
+	suite.FailNow("ffmpeg was given no filter graph")
+	return ""
+}
+
+// fakeRenderFFmpeg logs whether it was asked to render a track or mix
+// renders, and fails mixes while the fail file exists
+const fakeRenderFFmpeg = `#!/bin/sh
+dir=$(dirname "$0")
+for arg; do out="$arg"; done
+case "$*" in
+*libmp3lame*)
+	echo mix >> "$dir/calls"
+	[ -e "$dir/fail" ] && exit 1 ;;
+*) echo render >> "$dir/calls" ;;
+esac
+echo audio > "$out"
+`
+
+// Test a failed merge resumes from the track renders it finished, rendering
+// again only tracks that changed
+func (suite *AudioTestSuite) TestMergeTracksResumable() {
+	dir := suite.T().TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	suite.Require().NoError(os.WriteFile(ffmpeg, []byte(fakeRenderFFmpeg), 0755))
+	calls := func() []string {
+		data, _ := os.ReadFile(filepath.Join(dir, "calls"))
+		os.Remove(filepath.Join(dir, "calls"))
+		return strings.Fields(string(data))
+	}
+	a := filepath.Join(dir, "a.wav")
+	b := filepath.Join(dir, "b.wav")
+	os.WriteFile(a, []byte("a"), 0644)
+	os.WriteFile(b, []byte("b"), 0644)
+	tracks := []audio.TrackInfo{
+		{FilePath: a, Gain: 1.0, Track: 1},
+		{FilePath: a, Gain: 1.0, Track: 1, Offset: 10},
+		{FilePath: b, Gain: 0.5, Track: 2},
+	}
+	renderDir := filepath.Join(dir, "renders")
+	outputPath := filepath.Join(dir, "merged.mp3")
+	merger := audio.NewAudioMergerWithPath(ffmpeg)
+
+	// The mix fails after both tracks are rendered, leaving the renders behind
+	os.WriteFile(filepath.Join(dir, "fail"), nil, 0644)
+	err := merger.MergeTracksResumable(context.Background(), tracks, outputPath, renderDir, nil)
+	suite.Require().Error(err)
+	assert.Equal(suite.T(), []string{"render", "render", "mix"}, calls())
+	assert.FileExists(suite.T(), filepath.Join(renderDir, "track_01.flac"))
+	assert.FileExists(suite.T(), filepath.Join(renderDir, "track_02.flac"))
+	assert.NoFileExists(suite.T(), outputPath)
+
+	// A changed track is rendered again; the other is reused
+	tracks[2].Gain = 0.8
+	err = merger.MergeTracksResumable(context.Background(), tracks, outputPath, renderDir, nil)
+	suite.Require().Error(err)
+	assert.Equal(suite.T(), []string{"render", "mix"}, calls())
+
+	// Once the mix succeeds, the renders are removed
+	os.Remove(filepath.Join(dir, "fail"))
+	var stages []string
+	err = merger.MergeTracksResumable(context.Background(), tracks, outputPath, renderDir, func(p audio.MergeProgress) {
+		stages = append(stages, p.Stage)
+	})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []string{"mix"}, calls())
+	assert.FileExists(suite.T(), outputPath)
+	assert.NoDirExists(suite.T(), renderDir)
+	assert.Contains(suite.T(), stages, "rendering")
+	assert.Equal(suite.T(), "completed", stages[len(stages)-1])
+}
+
+// Test a merge turns volume envelopes into ffmpeg volume automation
+func (suite *AudioTestSuite) TestMergeAppliesEnvelope() {
+	host := filepath.Join(suite.testDir, "envelope-host.wav")
+	guest := filepath.Join(suite.testDir, "envelope-guest.wav")
+	os.WriteFile(host, []byte("host"), 0644)
+	os.WriteFile(guest, []byte("guest"), 0644)
+	filter := suite.mergeFilter("envelope", []audio.TrackInfo{
+		{FilePath: host, Offset: 3, Gain: 1.0, Envelope: []audio.EnvelopePoint{{Time: 2, Value: 1}, {Time: 0, Value: 0}, {Time: 30.5, Value: 0.5}}},
+		{FilePath: guest, Gain: 1.0, Envelope: []audio.EnvelopePoint{{Time: 0, Value: 1}, {Time: 10, Value: 1}}},
+	})
+
+	// The fade in from silence is interpolated from the floor, then the level rides down
+	assert.Contains(suite.T(), filter, "[0:a]volume='lt(t,0)*1e-07+gte(t,30.5)*0.5"+
+		"+gte(t,0)*lt(t,2)*1e-07*pow(1e+07,(t-0)/2)+gte(t,2)*lt(t,30.5)*1*pow(0.5,(t-2)/28.5)':eval=frame,adelay=3.000s:all=1[a0]")
+	// A flat envelope leaves the volume alone
+	assert.Contains(suite.T(), filter, "[1:a]adelay=0.000s:all=1[a1]")
+}
+
+// Test the clips of a project track share one timeline
+func (suite *AudioTestSuite) TestMergeTrackTimelines() {
+	host := filepath.Join(suite.testDir, "timeline-host.wav")
+	guest := filepath.Join(suite.testDir, "timeline-guest.wav")
+	os.WriteFile(host, []byte("host"), 0644)
+	os.WriteFile(guest, []byte("guest"), 0644)
+	filter := suite.mergeFilter("timeline", []audio.TrackInfo{
+		{FilePath: host, Track: 1, Offset: 0, Duration: 10, Gain: 1.0},
+		{FilePath: guest, Track: 2, Offset: 2, Gain: 0.5},
+		{FilePath: host, Track: 1, Offset: 15, Start: 12.5, Duration: 5, Gain: 1.0},
+	})
+
+	assert.Equal(suite.T(), "[0:a]atrim=start=0.000:duration=10.000,asetpts=PTS-STARTPTS,adelay=0.000s:all=1[c0];"+
+		"[2:a]atrim=start=12.500:duration=5.000,asetpts=PTS-STARTPTS,adelay=15.000s:all=1[c2];"+
+		"[c0][c2]amix=inputs=2:duration=longest:normalize=0[a0];"+
+		"[1:a]adelay=2.000s:all=1,volume=0.500[a1];"+
+		"[a0][a1]amix=inputs=2:duration=longest:normalize=0[aout]", filter)
+
+	// Progress counts the trimmed clips, not their files
+	durations := map[int]float64{0: 60, 1: 20, 2: 60, 3: 60}
+	tracks := []audio.TrackInfo{
+		{Track: 1, Duration: 10},
+		{Track: 2, Offset: 2},
+		{Track: 1, Offset: 15, Start: 12.5, Duration: 5},
+		{Track: 3, Offset: 50, Start: 55},
+	}
+	assert.Equal(suite.T(), 55.0, audio.MergedDuration(tracks, durations))
+}
+
 // Test parsing AUP with no imports
 func (suite *AudioTestSuite) TestParseAupFileNoImports() {
 	parser := audio.NewAupParser()
@@ -398,6 +679,1104 @@ func (suite *AudioTestSuite) TestMergeTracksWithOffsetsTimeout() {
 	_ = err
 }
 
+// Test the preprocessing filters run in order and only when selected
+func (suite *AudioTestSuite) TestPreprocessFilters() {
+	assert.Equal(suite.T(), "", audio.PreprocessFilters(audio.PreprocessOptions{TrimSilence: true}))
+	assert.Equal(suite.T(), "loudnorm=I=-16:TP=-1.5:LRA=11", audio.PreprocessFilters(audio.PreprocessOptions{Normalize: true}))
+	assert.Equal(suite.T(), "highpass=f=80,afftdn=nf=-25,loudnorm=I=-16:TP=-1.5:LRA=11",
+		audio.PreprocessFilters(audio.PreprocessOptions{Normalize: true, Denoise: true}))
+}
+
+// writeTestWAV writes 16 kHz mono 16-bit audio: silence, a tone, silence
+func (suite *AudioTestSuite) writeTestWAV(name string, lead, tone, tail float64) string {
+	const rate = 16000
+	var samples []int16
+	for i := 0; i < int(lead*rate); i++ {
+		samples = append(samples, 0)
+	}
+	for i := 0; i < int(tone*rate); i++ {
+		samples = append(samples, int16(8000*math.Sin(2*math.Pi*440*float64(i)/rate)))
+	}
+	for i := 0; i < int(tail*rate); i++ {
+		samples = append(samples, 0)
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("RIFF")
+	binary.Write(&buf, binary.LittleEndian, uint32(36+2*len(samples)))
+	buf.WriteString("WAVEfmt ")
+	for _, field := range []any{uint32(16), uint16(1), uint16(1), uint32(rate), uint32(2 * rate), uint16(2), uint16(16)} {
+		binary.Write(&buf, binary.LittleEndian, field)
+	}
+	buf.WriteString("data")
+	binary.Write(&buf, binary.LittleEndian, uint32(2*len(samples)))
+	binary.Write(&buf, binary.LittleEndian, samples)
+
+	path := filepath.Join(suite.testDir, name)
+	assert.NoError(suite.T(), os.WriteFile(path, buf.Bytes(), 0644))
+	return path
+}
+
+// Test silence is trimmed from both ends, keeping a short pad
+func (suite *AudioTestSuite) TestTrimSilence() {
+	path := suite.writeTestWAV("trim.wav", 2, 1, 3)
+	offset, err := audio.TrimSilence(path)
+	assert.NoError(suite.T(), err)
+	assert.InDelta(suite.T(), 1.75, offset, 0.001)
+
+	info, err := os.Stat(path)
+	assert.NoError(suite.T(), err)
+	assert.InDelta(suite.T(), 1.5, float64(info.Size()-44)/32000, 0.001)
+
+	// Trimming again finds nothing more to cut
+	offset, err = audio.TrimSilence(path)
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), offset)
+
+	// Silence throughout is left alone
+	silent := suite.writeTestWAV("silent.wav", 1, 0, 0)
+	offset, err = audio.TrimSilence(silent)
+	assert.NoError(suite.T(), err)
+	assert.Zero(suite.T(), offset)
+	info, err = os.Stat(silent)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(44+32000), info.Size())
+
+	notWAV := filepath.Join(suite.testDir, "not.wav")
+	assert.NoError(suite.T(), os.WriteFile(notWAV, []byte("ID3 not a wav"), 0644))
+	_, err = audio.TrimSilence(notWAV)
+	assert.Error(suite.T(), err)
+}
+
+// Test peaks are taken per bucket and buckets grow to stay under the maximum
+func (suite *AudioTestSuite) TestWaveformFromPCM() {
+	// One second of silence, then one second of a loud square wave, at 8 kHz
+	var pcm bytes.Buffer
+	for i := 0; i < 16000; i++ {
+		v := int16(0)
+		if i >= 8000 {
+			v = 16000
+			if i%2 == 1 {
+				v = -16000
+			}
+		}
+		binary.Write(&pcm, binary.LittleEndian, v)
+	}
+
+	generator := audio.NewWaveformGenerator()
+	generator.SetMaxLength(10)
+	waveform, err := generator.FromPCM(&pcm)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 8000, waveform.SampleRate)
+	assert.LessOrEqual(suite.T(), waveform.Length, 10)
+	assert.Len(suite.T(), waveform.Data, 2*waveform.Length)
+	assert.GreaterOrEqual(suite.T(), waveform.Length*waveform.SamplesPerPixel, 16000)
+	assert.InDelta(suite.T(), 2.0, waveform.Duration().Seconds(), float64(waveform.SamplesPerPixel)/8000)
+
+	// The first bucket is silent and the last one loud
+	assert.Equal(suite.T(), []int8{0, 0}, waveform.Data[:2])
+	assert.Equal(suite.T(), []int8{-63, 62}, waveform.Data[len(waveform.Data)-2:])
+
+	data, err := waveform.MarshalBinary()
+	assert.NoError(suite.T(), err)
+	var decoded audio.Waveform
+	assert.NoError(suite.T(), decoded.UnmarshalBinary(data))
+	assert.Equal(suite.T(), *waveform, decoded)
+	assert.Error(suite.T(), decoded.UnmarshalBinary(data[:len(data)-1]))
+}
+
+// Test ffprobe output is read from the first audio stream and checked against the extension
+func (suite *AudioTestSuite) TestParseProbe() {
+	output := []byte(`{
+		"streams": [
+			{"codec_type": "video", "codec_name": "mjpeg"},
+			{"codec_type": "audio", "codec_name": "aac", "sample_rate": "44100", "channels": 2, "bit_rate": "128000"}
+		],
+		"format": {"format_name": "mov,mp4,m4a,3gp,3g2,mj2", "duration": "61.5", "bit_rate": "130000"}
+	}`)
+	result, err := audio.ParseProbe(output)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "aac", result.Codec)
+	assert.Equal(suite.T(), 44100, result.SampleRate)
+	assert.Equal(suite.T(), 2, result.Channels)
+	assert.Equal(suite.T(), int64(128000), result.BitRate)
+	assert.Equal(suite.T(), 61.5, result.Duration) // From the container
+
+	assert.NoError(suite.T(), result.CheckContainer("talk.m4a"))
+	assert.NoError(suite.T(), result.CheckContainer("talk.MP4"))
+	assert.NoError(suite.T(), result.CheckContainer("talk.unknown"))
+	var mismatch *audio.ContainerMismatchError
+	assert.ErrorAs(suite.T(), result.CheckContainer("talk.mp3"), &mismatch)
+	assert.Equal(suite.T(), ".mp3", mismatch.Extension)
+
+	var job models.TranscriptionJob
+	result.Apply(&job)
+	assert.Equal(suite.T(), 61.5, *job.AudioDuration)
+	assert.Equal(suite.T(), 44100, *job.AudioSampleRate)
+	assert.Equal(suite.T(), 2, *job.AudioChannels)
+	assert.Equal(suite.T(), "aac", *job.AudioCodec)
+	assert.Equal(suite.T(), int64(128000), *job.AudioBitRate)
+
+	_, err = audio.ParseProbe([]byte(`{"streams": [{"codec_type": "video"}], "format": {}}`))
+	assert.ErrorIs(suite.T(), err, audio.ErrNoAudioStream)
+	_, err = audio.ParseProbe([]byte("pcm_s16le"))
+	assert.Error(suite.T(), err)
+}
+
+// Test fingerprints survive storage and copies score above unrelated audio
+func (suite *AudioTestSuite) TestFingerprintSimilarity() {
+	original := make([]uint32, 300)
+	unrelated := make([]uint32, 300)
+	for i := range original {
+		original[i] = uint32(i) * 2654435761
+		unrelated[i] = uint32(i)*40503 ^ 0x5bd1e995
+	}
+	// A re-encode flips a few bits and pads the start by three items
+	copied := append([]uint32{1, 2, 3}, original...)
+	for i := 3; i < len(copied); i += 5 {
+		copied[i] ^= 1 << (i % 32)
+	}
+
+	encoded := (&audio.Fingerprint{Values: copied}).Encode()
+	decoded, err := audio.DecodeFingerprint(encoded)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), copied, decoded)
+	_, err = audio.DecodeFingerprint("AAA=")
+	assert.Error(suite.T(), err)
+
+	assert.Equal(suite.T(), 1.0, audio.FingerprintSimilarity(original, original))
+	assert.Greater(suite.T(), audio.FingerprintSimilarity(original, decoded), 0.99)
+	assert.Less(suite.T(), audio.FingerprintSimilarity(original, unrelated), 0.9)
+	assert.Equal(suite.T(), 0.0, audio.FingerprintSimilarity(original[:10], original[:10]), "too short to compare")
+}
+
+// Test fpcalc's raw JSON output is read
+func (suite *AudioTestSuite) TestComputeFingerprint() {
+	fpcalc := filepath.Join(suite.T().TempDir(), "fpcalc")
+	suite.Require().NoError(os.WriteFile(fpcalc, []byte(`#!/bin/sh
+echo "$@" >&2
+[ "$1 $2 $3 $4" = "-raw -json -length 120" ] || exit 1
+echo '{"duration": 61.5, "fingerprint": [1, 4294967295, 7]}'
+`), 0755))
+	fingerprint, err := audio.ComputeFingerprint(context.Background(), fpcalc, "talk.wav")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 61.5, fingerprint.Duration)
+	assert.Equal(suite.T(), []uint32{1, 4294967295, 7}, fingerprint.Values)
+
+	_, err = audio.ComputeFingerprint(context.Background(), "synthezia-missing-fpcalc", "talk.wav")
+	assert.ErrorIs(suite.T(), err, exec.ErrNotFound)
+}
+
+// Test per-channel RMS levels are read from the astats log
+func (suite *AudioTestSuite) TestParseChannelLevels() {
+	output := `Input #0, wav, from 'interview.wav':
+[Parsed_astats_1 @ 0x55d0] Channel: 1
+[Parsed_astats_1 @ 0x55d0] DC offset: 0.000001
+[Parsed_astats_1 @ 0x55d0] RMS level dB: -24.5
+[Parsed_astats_1 @ 0x55d0] RMS peak dB: -10.2
+[Parsed_astats_1 @ 0x55d0] Channel: 2
+[Parsed_astats_1 @ 0x55d0] RMS level dB: -26
+[Parsed_astats_1 @ 0x55d0] Channel: 3
+[Parsed_astats_1 @ 0x55d0] RMS level dB: -28.1
+[Parsed_astats_1 @ 0x55d0] Channel: 4
+[Parsed_astats_1 @ 0x55d0] RMS level dB: -inf
+[Parsed_astats_1 @ 0x55d0] Overall
+[Parsed_astats_1 @ 0x55d0] RMS level dB: -26.1
+`
+	levels, err := audio.ParseChannelLevels(output)
+	suite.Require().NoError(err)
+	suite.Require().Len(levels, 4)
+	assert.Equal(suite.T(), []float64{-24.5, -26, -28.1}, levels[:3])
+	assert.True(suite.T(), math.IsInf(levels[3], -1))
+
+	_, err = audio.ParseChannelLevels("Input #0, wav, from 'interview.wav':")
+	assert.Error(suite.T(), err)
+}
+
+// Test separate microphones are told from stereo pairs and mono copies
+func (suite *AudioTestSuite) TestChannelAnalysisDistinct() {
+	interview := audio.ChannelAnalysis{Left: -24, Right: -26, Sum: -28, Diff: -29}
+	assert.True(suite.T(), interview.Distinct())
+
+	mono := audio.ChannelAnalysis{Left: -24, Right: -24, Sum: -24, Diff: math.Inf(-1)}
+	assert.False(suite.T(), mono.Distinct())
+
+	room := audio.ChannelAnalysis{Left: -24, Right: -25, Sum: -24.5, Diff: -38}
+	assert.False(suite.T(), room.Distinct())
+
+	oneSided := audio.ChannelAnalysis{Left: -24, Right: math.Inf(-1), Sum: -30, Diff: -30}
+	assert.False(suite.T(), oneSided.Distinct())
+}
+
+// Test truncated and corrupt files fail the decode check with where they broke
+func (suite *AudioTestSuite) TestCheckDecodable() {
+	ffmpeg := filepath.Join(suite.T().TempDir(), "ffmpeg")
+	suite.Require().NoError(os.WriteFile(ffmpeg, []byte(`#!/bin/sh
+for arg; do [ "$prev" = "-i" ] && file="$arg"; prev="$arg"; done
+case "$file" in
+corrupt*)
+	printf 'out_time_us=12000000\nprogress=continue\n'
+	echo "$file: Invalid data found when processing input" >&2
+	exit 1 ;;
+truncated*) printf 'out_time_us=4000000\nprogress=end\n' ;;
+*) printf 'out_time_us=N/A\nprogress=continue\nout_time_us=30000000\nprogress=end\n' ;;
+esac
+`), 0755))
+	ctx := context.Background()
+
+	assert.NoError(suite.T(), audio.CheckDecodable(ctx, ffmpeg, "talk.wav", 30, 600))
+	assert.NoError(suite.T(), audio.CheckDecodable(ctx, ffmpeg, "truncated.wav", 30, 4), "shorter than the check, as claimed")
+
+	err := audio.CheckDecodable(ctx, ffmpeg, "corrupt.mp3", 30, 600)
+	var decodeErr *audio.DecodeError
+	suite.Require().ErrorAs(err, &decodeErr)
+	assert.Equal(suite.T(), 12.0, decodeErr.Offset)
+	assert.Equal(suite.T(), "file unreadable at 00:12: corrupt.mp3: Invalid data found when processing input", err.Error())
+
+	err = audio.CheckDecodable(ctx, ffmpeg, "truncated.wav", 30, 3725)
+	suite.Require().ErrorAs(err, &decodeErr)
+	assert.Equal(suite.T(), "file unreadable at 00:04: audio ends early, the file claims 1:02:05", err.Error())
+
+	err = audio.CheckDecodable(ctx, "synthezia-missing-ffmpeg", "talk.wav", 30, 0)
+	assert.ErrorIs(suite.T(), err, exec.ErrNotFound)
+}
+
+// qualityLog is what ffmpeg logs for a noisy, clipped interview with a dropout
+const qualityLog = `Input #0, wav, from 'interview.wav':
+  Duration: 00:10:00.00, bitrate: 256 kb/s
+[silencedetect @ 0x55d0] silence_start: 0
+[silencedetect @ 0x55d0] silence_end: 0.3 | silence_duration: 0.3
+[silencedetect @ 0x55d0] silence_start: 125.2
+[silencedetect @ 0x55d0] silence_end: 126 | silence_duration: 0.8
+[silencedetect @ 0x55d0] silence_start: 599.8
+[Parsed_ebur128_0 @ 0x55d1] Summary:
+
+  Integrated loudness:
+    I:         -19.4 LUFS
+    Threshold: -29.6 LUFS
+
+  Loudness range:
+    LRA:         7.2 LU
+    Threshold: -39.8 LUFS
+
+  True peak:
+    Peak:        0.4 dBFS
+[Parsed_astats_1 @ 0x55d2] Channel: 1
+[Parsed_astats_1 @ 0x55d2] Peak level dB: -3.000000
+[Parsed_astats_1 @ 0x55d2] Peak count: 2
+[Parsed_astats_1 @ 0x55d2] Overall
+[Parsed_astats_1 @ 0x55d2] Peak level dB: 0.000000
+[Parsed_astats_1 @ 0x55d2] RMS level dB: -22.500000
+[Parsed_astats_1 @ 0x55d2] Peak count: 37
+[Parsed_astats_1 @ 0x55d2] Noise floor dB: -33.250000
+`
+
+// Test loudness, clipping, noise and dropouts are read and explained
+func (suite *AudioTestSuite) TestParseQualityReport() {
+	report, err := audio.ParseQualityReport(qualityLog)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 600.0, report.Duration)
+	suite.Require().NotNil(report.IntegratedLoudness)
+	assert.Equal(suite.T(), -19.4, *report.IntegratedLoudness)
+	assert.Equal(suite.T(), 7.2, *report.LoudnessRange)
+	assert.Equal(suite.T(), 0.4, *report.TruePeak)
+	assert.Equal(suite.T(), int64(37), report.ClippingCount)
+	suite.Require().NotNil(report.SNR)
+	assert.Equal(suite.T(), 10.8, *report.SNR)
+	assert.Equal(suite.T(), []audio.Dropout{{Start: 125.2, End: 126}}, report.Dropouts, "lead-in and tail silences are not dropouts")
+	suite.Require().Len(report.Warnings, 3)
+	assert.Contains(suite.T(), report.Warnings[0], "Clipping")
+	assert.Contains(suite.T(), report.Warnings[1], "SNR about 11 dB")
+	assert.Contains(suite.T(), report.Warnings[2], "the audio cuts out at 02:05")
+
+	// Silence has no finite levels to report
+	silent, err := audio.ParseQualityReport(`[Parsed_astats_1 @ 0x1] Overall
+[Parsed_astats_1 @ 0x1] Peak level dB: -inf
+[Parsed_astats_1 @ 0x1] RMS level dB: -inf
+[Parsed_astats_1 @ 0x1] Noise floor dB: -inf
+`)
+	suite.Require().NoError(err)
+	assert.Nil(suite.T(), silent.RMSLevel)
+	assert.Nil(suite.T(), silent.SNR)
+	assert.Zero(suite.T(), silent.ClippingCount)
+
+	_, err = audio.ParseQualityReport("Input #0, wav, from 'interview.wav':")
+	assert.Error(suite.T(), err)
+}
+
+// Test a stretch of a recording is cut out with -ss and -t
+func (suite *AudioTestSuite) TestExtractRange() {
+	dir := suite.T().TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	suite.Require().NoError(os.WriteFile(ffmpeg, []byte(`#!/bin/sh
+echo "$@" > "$(dirname "$0")/args"
+for arg; do out="$arg"; done
+echo wav > "$out"
+`), 0755))
+	output := filepath.Join(dir, "range.wav")
+
+	suite.Require().NoError(audio.ExtractRange(context.Background(), ffmpeg, "talk.mp3", output, 90, 120.5))
+	args, err := os.ReadFile(filepath.Join(dir, "args"))
+	suite.Require().NoError(err)
+	assert.Contains(suite.T(), string(args), "-ss 90.000 -t 30.500 -i talk.mp3")
+	assert.FileExists(suite.T(), output)
+
+	// Without an end the rest of the recording is taken
+	suite.Require().NoError(audio.ExtractRange(context.Background(), ffmpeg, "talk.mp3", output, 90, 0))
+	args, err = os.ReadFile(filepath.Join(dir, "args"))
+	suite.Require().NoError(err)
+	assert.Contains(suite.T(), string(args), "-ss 90.000 -i talk.mp3")
+}
+
+// Test a re-transcribed stretch replaces only its part of a transcript
+func (suite *AudioTestSuite) TestSplice() {
+	existing := &interfaces.TranscriptResult{
+		Language: "en",
+		Segments: []interfaces.TranscriptSegment{
+			{Start: 0, End: 5, Text: "Welcome back."},
+			{Start: 10, End: 14, Text: "Old words."},
+			{Start: 19, End: 24, Text: "Straddling the end."},
+			{Start: 30, End: 35, Text: "Goodbye."},
+		},
+		WordSegments: []interfaces.TranscriptWord{{Start: 0, End: 1, Word: "Welcome"}, {Start: 10, End: 11, Word: "Old"}},
+		Metadata:     map[string]string{"source": "first run"},
+	}
+	partial := &interfaces.TranscriptResult{
+		Segments:     []interfaces.TranscriptSegment{{Start: 1, End: 5, Text: "New words."}, {Start: 6, End: 11, Text: "And more."}},
+		WordSegments: []interfaces.TranscriptWord{{Start: 1, End: 2, Word: "New"}},
+		ModelUsed:    "small",
+	}
+
+	spliced := audio.Splice(existing, partial, 8, 20)
+	assert.Equal(suite.T(), "Welcome back. New words. And more. Straddling the end. Goodbye.", spliced.Text)
+	assert.Equal(suite.T(), 9.0, spliced.Segments[1].Start)
+	assert.Equal(suite.T(), 19.0, spliced.Segments[2].End)
+	assert.Equal(suite.T(), []string{"Welcome", "New"}, []string{spliced.WordSegments[0].Word, spliced.WordSegments[1].Word})
+	assert.Equal(suite.T(), "en", spliced.Language)
+	assert.Equal(suite.T(), "first run", spliced.Metadata["source"])
+
+	// Running to the end replaces everything after the start
+	spliced = audio.Splice(existing, partial, 8, 0)
+	assert.Equal(suite.T(), "Welcome back. New words. And more.", spliced.Text)
+
+	// Without a transcript the stretch stands alone
+	spliced = audio.Splice(nil, partial, 8, 20)
+	assert.Equal(suite.T(), "New words. And more.", spliced.Text)
+	assert.Equal(suite.T(), 9.0, spliced.Segments[0].Start)
+}
+
+// Test silences and the duration are read from the silencedetect log
+func (suite *AudioTestSuite) TestParseSilenceDetect() {
+	output := `Input #0, wav, from 'talk.wav':
+  Duration: 01:02:03.50, bitrate: 256 kb/s
+[silencedetect @ 0x55d0] silence_start: -0.01
+[silencedetect @ 0x55d0] silence_end: 1.2 | silence_duration: 1.21
+[silencedetect @ 0x55d0] silence_start: 600.5
+[silencedetect @ 0x55d0] silence_end: 601.5 | silence_duration: 1
+[silencedetect @ 0x55d0] silence_start: 3720
+`
+	silences, duration := audio.ParseSilenceDetect(output)
+	assert.Equal(suite.T(), 3723.5, duration)
+	assert.Equal(suite.T(), []audio.Silence{{Start: 0, End: 1.2}, {Start: 600.5, End: 601.5}, {Start: 3720, End: 3723.5}}, silences)
+}
+
+// Test chunks are cut at the silence nearest the target length and overlap
+func (suite *AudioTestSuite) TestChunkPlan() {
+	chunker := audio.NewChunker()
+	chunker.SetLength(10 * time.Minute)
+	chunker.SetOverlap(5 * time.Second)
+
+	// Short recordings are one chunk
+	chunks := chunker.Plan(800, nil)
+	assert.Len(suite.T(), chunks, 1)
+	assert.Equal(suite.T(), audio.Chunk{Start: 0, End: 800, KeepStart: 0, KeepEnd: 800}, chunks[0])
+
+	// Cut at the pauses nearest 10 minutes, ignoring one too early; without a
+	// pause within 15 minutes the cut falls at 10
+	silences := []audio.Silence{{Start: 100, End: 101}, {Start: 580, End: 582}, {Start: 640, End: 641}, {Start: 1190, End: 1192}}
+	chunks = chunker.Plan(2600, silences)
+	assert.Len(suite.T(), chunks, 4)
+	assert.Equal(suite.T(), []float64{0, 581, 1191, 1791}, []float64{chunks[0].KeepStart, chunks[1].KeepStart, chunks[2].KeepStart, chunks[3].KeepStart})
+	assert.Equal(suite.T(), 2600.0, chunks[3].KeepEnd)
+	assert.Equal(suite.T(), 0.0, chunks[0].Start)
+	assert.Equal(suite.T(), 586.0, chunks[0].End)
+	assert.Equal(suite.T(), 576.0, chunks[1].Start)
+	assert.Equal(suite.T(), 2600.0, chunks[3].End)
+	for i, chunk := range chunks {
+		assert.Equal(suite.T(), i, chunk.Index)
+		assert.LessOrEqual(suite.T(), chunk.KeepEnd-chunk.KeepStart, 900.0)
+	}
+}
+
+// Test chunk transcripts are shifted and segments heard twice kept once
+func (suite *AudioTestSuite) TestStitch() {
+	chunks := []audio.Chunk{
+		{Index: 0, Start: 0, End: 105, KeepStart: 0, KeepEnd: 100},
+		{Index: 1, Start: 95, End: 200, KeepStart: 100, KeepEnd: 200},
+	}
+	results := []*interfaces.TranscriptResult{
+		{
+			Language:   "en",
+			Confidence: 0.8,
+			Segments: []interfaces.TranscriptSegment{
+				{Start: 1, End: 50, Text: " Hello there."},
+				{Start: 97, End: 104, Text: " Across the cut."},
+			},
+			WordSegments: []interfaces.TranscriptWord{{Start: 1, End: 2, Word: "Hello"}, {Start: 102, End: 103, Word: "cut."}},
+		},
+		{
+			Language:   "en",
+			Confidence: 0.6,
+			Segments: []interfaces.TranscriptSegment{
+				{Start: 2, End: 9, Text: " Across the cut."},
+				{Start: 10, End: 105, Text: " The end."},
+			},
+			WordSegments: []interfaces.TranscriptWord{{Start: 7, End: 8, Word: "cut."}, {Start: 100, End: 105, Word: "end."}},
+		},
+	}
+
+	stitched := audio.Stitch(chunks, results)
+	assert.Equal(suite.T(), "Hello there. Across the cut. The end.", stitched.Text)
+	assert.Equal(suite.T(), "en", stitched.Language)
+	assert.InDelta(suite.T(), 0.7, stitched.Confidence, 1e-9)
+	assert.Equal(suite.T(), "2", stitched.Metadata["chunks"])
+	assert.Len(suite.T(), stitched.Segments, 3)
+	assert.Equal(suite.T(), 97.0, stitched.Segments[1].Start)
+	assert.Equal(suite.T(), 105.0, stitched.Segments[2].Start)
+	assert.Equal(suite.T(), 200.0, stitched.Segments[2].End)
+	assert.Len(suite.T(), stitched.WordSegments, 3)
+	assert.Equal(suite.T(), 102.0, stitched.WordSegments[1].Start)
+	assert.Equal(suite.T(), 195.0, stitched.WordSegments[2].Start)
+}
+
+// Test Reaper media items become tracks mixed with their track's volume, pan and mute
+func (suite *AudioTestSuite) TestRppParser() {
+	project := `<REAPER_PROJECT 0.1 "7.0/linux-x86_64" 1700000000
+  RIPPLE 0
+  <TRACK {0A1B}
+    NAME "Host mic"
+    VOLPAN 0.5 -0.25 -1 -1 1
+    MUTESOLO 0 2 0
+    <ITEM
+      POSITION 2.5
+      LENGTH 60
+      SOFFS 4
+      MUTE 0 0
+      VOLPAN 0.8 0.5 1 -1
+      <SOURCE SECTION
+        LENGTH 30
+        <SOURCE WAVE
+          FILE "C:\Users\me\Audio\host take.wav"
+        >
+      >
+    >
+    <ITEM
+      POSITION 90
+      <SOURCE EMPTY
+      >
+    >
+  >
+  <TRACK
+    NAME Guest
+    MUTESOLO 1 0 0
+    <ITEM
+      POSITION 0
+      <SOURCE MP3
+        FILE 'guest "remote".mp3'
+      >
+      TAKE SEL
+      <SOURCE WAVE
+        FILE Audio/guest_take2.wav
+      >
+    >
+  >
+>
+`
+	parser, ok := audio.ProjectParserFor("Episode.RPP")
+	assert.True(suite.T(), ok)
+	tracks, err := parser.Parse([]byte(project))
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), tracks, 2) {
+		assert.Equal(suite.T(), `C:\Users\me\Audio\host take.wav`, tracks[0].FilePath)
+		assert.Equal(suite.T(), "host take.wav", audio.ProjectFileName(tracks[0].FilePath))
+		assert.Equal(suite.T(), 2.5, tracks[0].Offset)
+		assert.InDelta(suite.T(), 0.4, tracks[0].Gain, 1e-9)
+		assert.InDelta(suite.T(), 0.25, tracks[0].Pan, 1e-9)
+		assert.False(suite.T(), tracks[0].Mute)
+		assert.Equal(suite.T(), "Host mic", tracks[0].Name)
+		assert.True(suite.T(), tracks[0].Solo)
+		assert.Equal(suite.T(), 1, tracks[0].Track)
+		assert.Equal(suite.T(), 4.0, tracks[0].Start)
+		assert.Equal(suite.T(), 60.0, tracks[0].Duration)
+
+		assert.Equal(suite.T(), "Audio/guest_take2.wav", tracks[1].FilePath)
+		assert.Equal(suite.T(), "Guest", tracks[1].Name)
+		assert.False(suite.T(), tracks[1].Solo)
+		assert.Equal(suite.T(), 2, tracks[1].Track)
+		assert.Equal(suite.T(), 1.0, tracks[1].Gain)
+		assert.True(suite.T(), tracks[1].Mute)
+	}
+
+	_, err = parser.Parse([]byte("<project></project>"))
+	assert.Error(suite.T(), err)
+	_, err = parser.Parse([]byte("<REAPER_PROJECT 0.1\n  <TRACK\n"))
+	assert.Error(suite.T(), err)
+
+	assert.True(suite.T(), audio.IsProjectFile("session.aup"))
+	assert.False(suite.T(), audio.IsProjectFile("session.wav"))
+	assert.Equal(suite.T(), []string{".aaf", ".ardour", ".aup", ".aup3", ".rpp"}, audio.ProjectExtensions())
+}
+
+// aup3Doc encodes a project document in Audacity's binary serialization
+type aup3Doc struct {
+	dict, doc bytes.Buffer
+	names     map[string]uint16
+}
+
+func (d *aup3Doc) name(name string) {
+	id, ok := d.names[name]
+	if !ok {
+		id = uint16(len(d.names))
+		d.names[name] = id
+		d.dict.WriteByte(15)
+		binary.Write(&d.dict, binary.LittleEndian, id)
+		binary.Write(&d.dict, binary.LittleEndian, uint16(len(name)))
+		d.dict.WriteString(name)
+	}
+	binary.Write(&d.doc, binary.LittleEndian, id)
+}
+
+func (d *aup3Doc) start(tag string, attrs ...interface{}) {
+	d.doc.WriteByte(1)
+	d.name(tag)
+	for i := 0; i+1 < len(attrs); i += 2 {
+		switch value := attrs[i+1].(type) {
+		case string:
+			d.doc.WriteByte(3)
+			d.name(attrs[i].(string))
+			binary.Write(&d.doc, binary.LittleEndian, int32(len(value)))
+			d.doc.WriteString(value)
+		case int:
+			d.doc.WriteByte(4)
+			d.name(attrs[i].(string))
+			binary.Write(&d.doc, binary.LittleEndian, int32(value))
+		case int64:
+			d.doc.WriteByte(7)
+			d.name(attrs[i].(string))
+			binary.Write(&d.doc, binary.LittleEndian, value)
+		case float64:
+			d.doc.WriteByte(10)
+			d.name(attrs[i].(string))
+			binary.Write(&d.doc, binary.LittleEndian, value)
+			binary.Write(&d.doc, binary.LittleEndian, int32(8))
+		}
+	}
+}
+
+func (d *aup3Doc) end(tag string) {
+	d.doc.WriteByte(2)
+	d.name(tag)
+}
+
+// writeTestAup3 builds an Audacity 3 project with a mono track of two clips,
+// the first trimmed and ending in a silent block, and a stereo track
+func (suite *AudioTestSuite) writeTestAup3() string {
+	path := filepath.Join(suite.testDir, "session.aup3")
+	os.Remove(path)
+	db, err := gorm.Open(sqlite.Open(path), &gorm.Config{})
+	suite.Require().NoError(err)
+	sqlDB, _ := db.DB()
+	defer sqlDB.Close()
+	suite.Require().NoError(db.Exec("CREATE TABLE project(id INTEGER PRIMARY KEY, dict BLOB, doc BLOB)").Error)
+	suite.Require().NoError(db.Exec("CREATE TABLE sampleblocks(blockid INTEGER PRIMARY KEY AUTOINCREMENT, sampleformat INTEGER, summin REAL, summax REAL, sumrms REAL, summary256 BLOB, summary64k BLOB, samples BLOB)").Error)
+
+	int16Block := func(value int16, n int) []byte {
+		data := make([]byte, n*2)
+		for i := 0; i < n; i++ {
+			binary.LittleEndian.PutUint16(data[i*2:], uint16(value))
+		}
+		return data
+	}
+	floatBlock := func(value float32, n int) []byte {
+		data := make([]byte, n*4)
+		for i := 0; i < n; i++ {
+			binary.LittleEndian.PutUint32(data[i*4:], math.Float32bits(value))
+		}
+		return data
+	}
+	blocks := []struct {
+		format int
+		data   []byte
+	}{
+		{0x00020001, int16Block(16384, 2000)},
+		{0x00020001, int16Block(-8192, 800)},
+		{0x0004000F, floatBlock(0.5, 400)},
+		{0x0004000F, floatBlock(0.25, 400)},
+	}
+	for i, block := range blocks {
+		suite.Require().NoError(db.Exec("INSERT INTO sampleblocks(blockid, sampleformat, samples) VALUES (?, ?, ?)", i+1, block.format, block.data).Error)
+	}
+
+	doc := &aup3Doc{names: map[string]uint16{}}
+	doc.dict.Write([]byte{0, 1})
+	doc.start("project", "version", "1.3.0", "rate", 8000.0)
+	doc.start("wavetrack", "name", "Host", "channel", 2, "linked", 0, "mute", 0, "rate", 8000.0, "gain", 0.5, "pan", -0.25)
+	doc.start("waveclip", "offset", 1.5, "trimLeft", 0.125, "trimRight", 0.0)
+	doc.start("sequence", "maxsamples", 262144, "sampleformat", 0x00020001, "numsamples", int64(4000))
+	doc.start("waveblock", "start", int64(0), "blockid", int64(1))
+	doc.end("waveblock")
+	doc.start("waveblock", "start", int64(2000), "blockid", int64(-2000))
+	doc.end("waveblock")
+	doc.end("sequence")
+	doc.end("waveclip")
+	doc.start("waveclip", "offset", 10.0)
+	doc.start("sequence", "maxsamples", 262144, "sampleformat", 0x00020001, "numsamples", int64(800))
+	doc.start("waveblock", "start", int64(0), "blockid", int64(2))
+	doc.end("waveblock")
+	doc.end("sequence")
+	doc.end("waveclip")
+	doc.end("wavetrack")
+	for channel, blockID := range []int64{3, 4} {
+		doc.start("wavetrack", "name", "Guest", "channel", channel, "linked", 1-channel, "mute", 1, "rate", 8000.0, "gain", 1.0, "pan", 0.0)
+		doc.start("waveclip", "offset", 0.0)
+		doc.start("sequence", "maxsamples", 262144, "sampleformat", 0x0004000F, "numsamples", int64(400))
+		doc.start("waveblock", "start", int64(0), "blockid", blockID)
+		doc.end("waveblock")
+		doc.end("sequence")
+		doc.end("waveclip")
+		doc.end("wavetrack")
+	}
+	doc.end("project")
+	suite.Require().NoError(db.Exec("INSERT INTO project(id, dict, doc) VALUES (1, ?, ?)", doc.dict.Bytes(), doc.doc.Bytes()).Error)
+	return path
+}
+
+// Test reading and extracting an Audacity 3 project
+func (suite *AudioTestSuite) TestAup3Parser() {
+	path := suite.writeTestAup3()
+	assert.True(suite.T(), audio.IsProjectFile(path))
+	assert.True(suite.T(), audio.EmbedsAudio(path))
+
+	tracks, err := audio.ParseProjectFile(path)
+	suite.Require().NoError(err)
+	suite.Require().Len(tracks, 3)
+	assert.Equal(suite.T(), "Host.wav", tracks[0].FilePath)
+	assert.InDelta(suite.T(), 1.625, tracks[0].Offset, 0.0001)
+	assert.Equal(suite.T(), 0.5, tracks[0].Gain)
+	assert.Equal(suite.T(), -0.25, tracks[0].Pan)
+	assert.Equal(suite.T(), "Host (2).wav", tracks[1].FilePath)
+	assert.Equal(suite.T(), 10.0, tracks[1].Offset)
+	assert.Equal(suite.T(), "Guest.wav", tracks[2].FilePath)
+	assert.True(suite.T(), tracks[2].Mute)
+
+	extractor, ok := audio.ProjectExtractorFor(path)
+	suite.Require().True(ok)
+	dir := filepath.Join(suite.testDir, "aup3_tracks")
+	defer os.RemoveAll(dir)
+	extracted, err := extractor.Extract(path, dir)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), tracks, extracted)
+
+	sample := func(data []byte, i int) int16 {
+		return int16(binary.LittleEndian.Uint16(data[44+i*2:]))
+	}
+	host, err := os.ReadFile(filepath.Join(dir, "Host.wav"))
+	suite.Require().NoError(err)
+	suite.Require().Len(host, 44+3000*2) // The trimmed 1000 samples are left out
+	assert.Equal(suite.T(), uint32(8000), binary.LittleEndian.Uint32(host[24:]))
+	assert.InDelta(suite.T(), 16384, sample(host, 0), 1)
+	assert.InDelta(suite.T(), 16384, sample(host, 999), 1)
+	assert.Equal(suite.T(), int16(0), sample(host, 1000))
+
+	second, err := os.ReadFile(filepath.Join(dir, "Host (2).wav"))
+	suite.Require().NoError(err)
+	suite.Require().Len(second, 44+800*2)
+	assert.InDelta(suite.T(), -8192, sample(second, 0), 1)
+
+	// Stereo channels are mixed into one
+	guest, err := os.ReadFile(filepath.Join(dir, "Guest.wav"))
+	suite.Require().NoError(err)
+	suite.Require().Len(guest, 44+400*2)
+	assert.InDelta(suite.T(), 0.375*32767, sample(guest, 0), 1)
+
+	_, err = audio.ParseProjectFile(filepath.Join(suite.testDir, "missing.aup3"))
+	assert.Error(suite.T(), err)
+}
+
+// Test parsing an Ardour session
+func (suite *AudioTestSuite) TestArdourParser() {
+	session := `<?xml version="1.0" encoding="UTF-8"?>
+<Session version="7003" name="interview" sample-rate="48000">
+  <Sources>
+    <Source name="Host-1.wav" type="audio" id="101"/>
+    <Source name="Guest-1%L.wav" type="audio" id="102"/>
+    <Source name="Guest-1%R.wav" type="audio" id="103"/>
+  </Sources>
+  <Routes>
+    <Route version="7003" id="1" name="Master" default-type="audio"/>
+    <Route version="7003" id="201" name="Host" default-type="audio" audio-playlist="301">
+      <Controllable name="mute" id="11" value="0"/>
+      <Processor id="12" name="Amp" type="amp">
+        <Controllable name="gaincontrol" id="13" value="0.5"/>
+      </Processor>
+      <Pannable>
+        <Controllable name="pan-azimuth" id="14" value="0.25"/>
+      </Pannable>
+    </Route>
+    <Route version="7003" id="202" name="Guest" default-type="audio" audio-playlist="302">
+      <Controllable name="mute" id="21" value="1"/>
+    </Route>
+  </Routes>
+  <Playlists>
+    <Playlist id="301" name="Host" type="audio" orig-track-id="201">
+      <Region name="Host-1" position="a282240000" start="a141120000" length="a564480000" muted="0" scale-amplitude="2" source-0="101" master-source-0="101"/>
+      <Region name="Host-2" position="b1920" source-0="101"/>
+    </Playlist>
+    <Playlist id="303" name="Host.1" type="audio" orig-track-id="201">
+      <Region name="Host-3" position="0" source-0="101"/>
+    </Playlist>
+    <Playlist id="302" name="Guest" type="audio" orig-track-id="202">
+      <Region name="Guest-1" position="96000" start="48000" length="96000" muted="1" source-0="102" source-1="103"/>
+    </Playlist>
+  </Playlists>
+</Session>`
+
+	parser := audio.NewArdourParser()
+	tracks, err := parser.Parse([]byte(session))
+	suite.Require().NoError(err)
+	suite.Require().Len(tracks, 3)
+
+	assert.Equal(suite.T(), "Host-1.wav", tracks[0].FilePath)
+	assert.InDelta(suite.T(), 1.0, tracks[0].Offset, 0.0001)
+	assert.InDelta(suite.T(), 1.0, tracks[0].Gain, 0.0001) // Fader at 0.5, region at 2
+	assert.InDelta(suite.T(), -0.5, tracks[0].Pan, 0.0001)
+	assert.False(suite.T(), tracks[0].Mute)
+	assert.InDelta(suite.T(), 0.5, tracks[0].Start, 0.0001)
+	assert.InDelta(suite.T(), 2.0, tracks[0].Duration, 0.0001)
+
+	// Each channel of a stereo region is a clip on the route's track; Ardour 6 positions are samples
+	assert.Equal(suite.T(), "Guest-1%L.wav", tracks[1].FilePath)
+	assert.Equal(suite.T(), "Guest-1%R.wav", tracks[2].FilePath)
+	assert.InDelta(suite.T(), 2.0, tracks[2].Offset, 0.0001)
+	assert.InDelta(suite.T(), 1.0, tracks[2].Start, 0.0001)
+	assert.InDelta(suite.T(), 2.0, tracks[2].Duration, 0.0001)
+	assert.True(suite.T(), tracks[2].Mute)
+	assert.NotEqual(suite.T(), tracks[0].Track, tracks[1].Track)
+	assert.Equal(suite.T(), tracks[1].Track, tracks[2].Track)
+
+	_, err = parser.Parse([]byte("<Session></Session>"))
+	assert.Error(suite.T(), err)
+	_, err = parser.Parse([]byte(`<Session sample-rate="48000"><Playlists><Playlist id="1" type="audio" orig-track-id="2"><Region position="0" source-0="9"/></Playlist></Playlists><Routes><Route id="2" name="A"/></Routes></Session>`))
+	assert.Error(suite.T(), err)
+
+	assert.True(suite.T(), audio.IsProjectFile("interview.ardour"))
+	assert.True(suite.T(), audio.IsProjectFile("interview.AAF"))
+}
+
+// cfbNode is a storage or, with data set, a stream of a test compound file
+type cfbNode struct {
+	name     string
+	clsid    []byte
+	data     []byte
+	stream   bool
+	children []*cfbNode
+}
+
+func (n *cfbNode) storage(name string, clsid []byte) *cfbNode {
+	child := &cfbNode{name: name, clsid: clsid}
+	n.children = append(n.children, child)
+	return child
+}
+
+func (n *cfbNode) file(name string, data []byte) {
+	n.children = append(n.children, &cfbNode{name: name, data: data, stream: true})
+}
+
+// writeCFB lays out a version 3 compound file whose streams all fit in the
+// mini stream
+func writeCFB(root *cfbNode) []byte {
+	const free, end = 0xFFFFFFFF, 0xFFFFFFFE
+	type entry struct {
+		node                      *cfbNode
+		right, child, start, size uint32
+	}
+	entries := []*entry{{node: root, right: free, child: free}}
+	var miniStream []byte
+	var miniFAT []uint32
+	var add func(parent *entry)
+	add = func(parent *entry) {
+		var previous *entry
+		for _, child := range parent.node.children {
+			e := &entry{node: child, right: free, child: free, start: end}
+			id := uint32(len(entries))
+			entries = append(entries, e)
+			if previous == nil {
+				parent.child = id
+			} else {
+				previous.right = id
+			}
+			previous = e
+			if child.stream {
+				e.size = uint32(len(child.data))
+				if len(child.data) > 0 {
+					e.start = uint32(len(miniFAT))
+					sectors := (len(child.data) + 63) / 64
+					for i := 0; i < sectors; i++ {
+						miniFAT = append(miniFAT, uint32(len(miniFAT)+1))
+					}
+					miniFAT[len(miniFAT)-1] = end
+					padded := make([]byte, sectors*64)
+					copy(padded, child.data)
+					miniStream = append(miniStream, padded...)
+				}
+			} else {
+				add(e)
+			}
+		}
+	}
+	add(entries[0])
+
+	dirSectors := (len(entries)*128 + 511) / 512
+	miniFATSectors := (len(miniFAT)*4 + 511) / 512
+	streamSectors := (len(miniStream) + 511) / 512
+	fatSectors := 1
+	for fatSectors*128 < fatSectors+dirSectors+miniFATSectors+streamSectors {
+		fatSectors++
+	}
+	total := fatSectors + dirSectors + miniFATSectors + streamSectors
+	fat := make([]uint32, fatSectors*128)
+	for i := range fat {
+		fat[i] = free
+	}
+	chain := func(first, count int) {
+		for i := first; i < first+count; i++ {
+			fat[i] = uint32(i + 1)
+		}
+		fat[first+count-1] = end
+	}
+	for i := 0; i < fatSectors; i++ {
+		fat[i] = 0xFFFFFFFD
+	}
+	chain(fatSectors, dirSectors)
+	if miniFATSectors > 0 {
+		chain(fatSectors+dirSectors, miniFATSectors)
+		chain(fatSectors+dirSectors+miniFATSectors, streamSectors)
+		entries[0].start = uint32(fatSectors + dirSectors + miniFATSectors)
+		entries[0].size = uint32(len(miniStream))
+	} else {
+		entries[0].start = end
+	}
+
+	out := make([]byte, 512*(total+1))
+	copy(out, []byte{0xD0, 0xCF, 0x11, 0xE0, 0xA1, 0xB1, 0x1A, 0xE1})
+	le := binary.LittleEndian
+	le.PutUint16(out[0x18:], 0x3E)
+	le.PutUint16(out[0x1A:], 3)
+	le.PutUint16(out[0x1C:], 0xFFFE)
+	le.PutUint16(out[0x1E:], 9)
+	le.PutUint16(out[0x20:], 6)
+	le.PutUint32(out[0x2C:], uint32(fatSectors))
+	le.PutUint32(out[0x30:], uint32(fatSectors))
+	le.PutUint32(out[0x38:], 4096)
+	le.PutUint32(out[0x3C:], end)
+	if miniFATSectors > 0 {
+		le.PutUint32(out[0x3C:], uint32(fatSectors+dirSectors))
+	}
+	le.PutUint32(out[0x40:], uint32(miniFATSectors))
+	le.PutUint32(out[0x44:], end)
+	for i := 0; i < 109; i++ {
+		value := uint32(free)
+		if i < fatSectors {
+			value = uint32(i)
+		}
+		le.PutUint32(out[0x4C+i*4:], value)
+	}
+	sector := func(n int) []byte { return out[(n+1)*512 : (n+2)*512] }
+	for i, value := range fat {
+		le.PutUint32(sector(i / 128)[(i%128)*4:], value)
+	}
+	for i, e := range entries {
+		raw := sector(fatSectors + i/4)[(i%4)*128:]
+		name := utf16.Encode([]rune(e.node.name))
+		for j, unit := range name {
+			le.PutUint16(raw[j*2:], unit)
+		}
+		le.PutUint16(raw[0x40:], uint16(len(name)*2+2))
+		raw[0x42] = 1
+		if e.node.stream {
+			raw[0x42] = 2
+		}
+		if i == 0 {
+			raw[0x42] = 5
+		}
+		raw[0x43] = 1
+		le.PutUint32(raw[0x44:], free)
+		le.PutUint32(raw[0x48:], e.right)
+		le.PutUint32(raw[0x4C:], e.child)
+		copy(raw[0x50:0x60], e.node.clsid)
+		le.PutUint32(raw[0x74:], e.start)
+		le.PutUint32(raw[0x78:], e.size)
+	}
+	for i, value := range miniFAT {
+		le.PutUint32(sector(fatSectors + dirSectors + i/128)[(i%128)*4:], value)
+	}
+	copy(out[(fatSectors+dirSectors+miniFATSectors+1)*512:], miniStream)
+	return out
+}
+
+// aafTestObject is an object of a test AAF file
+type aafTestObject struct {
+	clsid []byte
+	props []aafTestProp
+}
+
+// aafTestProp is a property holding data, an object, or a vector or a set of objects
+type aafTestProp struct {
+	pid   uint16
+	data  []byte
+	ref   *aafTestObject
+	refs  []*aafTestObject
+	isSet bool
+}
+
+func aafUTF16(s string) []byte {
+	var out []byte
+	for _, unit := range utf16.Encode([]rune(s)) {
+		out = binary.LittleEndian.AppendUint16(out, unit)
+	}
+	return append(out, 0, 0)
+}
+
+func aafInt64(v int64) []byte  { return binary.LittleEndian.AppendUint64(nil, uint64(v)) }
+func aafUint32(v uint32) []byte { return binary.LittleEndian.AppendUint32(nil, v) }
+
+// store writes the object's properties stream and the storages of the
+// objects it holds into node
+func (o *aafTestObject) store(node *cfbNode) {
+	var index, values []byte
+	for _, prop := range o.props {
+		form, value := uint16(0x82), prop.data
+		switch {
+		case prop.ref != nil:
+			name := fmt.Sprintf("P-%x", prop.pid)
+			form, value = 0x22, aafUTF16(name)
+			prop.ref.store(node.storage(name, prop.ref.clsid))
+		case prop.refs != nil:
+			name := fmt.Sprintf("C-%x", prop.pid)
+			form, value = 0x32, aafUTF16(name)
+			var idx []byte
+			idx = binary.LittleEndian.AppendUint32(idx, uint32(len(prop.refs)))
+			idx = binary.LittleEndian.AppendUint32(idx, uint32(len(prop.refs)))
+			idx = binary.LittleEndian.AppendUint32(idx, 0xFFFFFFFF)
+			if prop.isSet {
+				form = 0x3A
+				idx = binary.LittleEndian.AppendUint16(idx, 0x4401)
+				idx = append(idx, 32)
+			}
+			for i, element := range prop.refs {
+				idx = binary.LittleEndian.AppendUint32(idx, uint32(i+10))
+				if prop.isSet {
+					idx = binary.LittleEndian.AppendUint32(idx, 1)
+					idx = append(idx, element.props[0].data...)
+				}
+				element.store(node.storage(fmt.Sprintf("%s{%x}", name, i+10), element.clsid))
+			}
+			node.file(name+" index", idx)
+		}
+		index = binary.LittleEndian.AppendUint16(index, prop.pid)
+		index = binary.LittleEndian.AppendUint16(index, form)
+		index = binary.LittleEndian.AppendUint16(index, uint16(len(value)))
+		values = append(values, value...)
+	}
+	stream := []byte{'L', 0x20}
+	stream = binary.LittleEndian.AppendUint16(stream, uint16(len(o.props)))
+	node.file("properties", append(append(stream, index...), values...))
+}
+
+// Test parsing an AAF file exported with linked media
+func (suite *AudioTestSuite) TestAafParser() {
+	compositionClass := []byte{0x01, 0x01, 0x01, 0x0d, 0x01, 0x01, 0x00, 0x35, 0x06, 0x0e, 0x2b, 0x34, 0x02, 0x06, 0x01, 0x01}
+	masterClass := []byte{0x01, 0x01, 0x01, 0x0d, 0x01, 0x01, 0x00, 0x36, 0x06, 0x0e, 0x2b, 0x34, 0x02, 0x06, 0x01, 0x01}
+	sound := []byte{0x02, 0x02, 0x03, 0x01, 0x00, 0x02, 0x00, 0x00, 0x06, 0x0e, 0x2b, 0x34, 0x04, 0x01, 0x01, 0x01}
+	picture := []byte{0x02, 0x02, 0x03, 0x01, 0x00, 0x01, 0x00, 0x00, 0x06, 0x0e, 0x2b, 0x34, 0x04, 0x01, 0x01, 0x01}
+	dataDef := func(def []byte) aafTestProp {
+		return aafTestProp{pid: 0x0201, data: append([]byte{0x03, 0x00, 0x01, 0x00, 16}, def...)}
+	}
+	mobID := func(n byte) []byte {
+		id := make([]byte, 32)
+		id[31] = n
+		return id
+	}
+	clip := func(length int64, source []byte) *aafTestObject {
+		return &aafTestObject{props: []aafTestProp{
+			dataDef(sound),
+			{pid: 0x0202, data: aafInt64(length)},
+			{pid: 0x1101, data: source},
+			{pid: 0x1102, data: aafUint32(1)},
+			{pid: 0x1201, data: aafInt64(0)},
+		}}
+	}
+	slot := func(segment *aafTestObject) *aafTestObject {
+		rate := append(aafUint32(48000), aafUint32(1)...)
+		return &aafTestObject{props: []aafTestProp{
+			{pid: 0x4801, data: aafUint32(1)},
+			{pid: 0x4B01, data: rate},
+			{pid: 0x4B02, data: aafInt64(0)},
+			{pid: 0x4803, ref: segment},
+		}}
+	}
+	fileMob := func(id []byte, location string) *aafTestObject {
+		locator := &aafTestObject{props: []aafTestProp{{pid: 0x4001, data: aafUTF16(location)}}}
+		descriptor := &aafTestObject{props: []aafTestProp{{pid: 0x2F01, refs: []*aafTestObject{locator}}}}
+		return &aafTestObject{props: []aafTestProp{{pid: 0x4401, data: id}, {pid: 0x4701, ref: descriptor}}}
+	}
+	masterMob := func(id, file []byte) *aafTestObject {
+		return &aafTestObject{clsid: masterClass, props: []aafTestProp{
+			{pid: 0x4401, data: id},
+			{pid: 0x4403, refs: []*aafTestObject{slot(clip(96000, file))}},
+		}}
+	}
+
+	// One second of filler, the host's clip, a half-second crossfade and
+	// the guest's clip wrapped in a gain effect
+	filler := &aafTestObject{props: []aafTestProp{dataDef(sound), {pid: 0x0202, data: aafInt64(48000)}}}
+	transition := &aafTestObject{props: []aafTestProp{dataDef(sound), {pid: 0x0202, data: aafInt64(24000)}, {pid: 0x1801, data: make([]byte, 21)}}}
+	effect := &aafTestObject{props: []aafTestProp{dataDef(sound), {pid: 0x0202, data: aafInt64(48000)}, {pid: 0x0B02, refs: []*aafTestObject{clip(48000, mobID(2))}}}}
+	audioSequence := &aafTestObject{props: []aafTestProp{dataDef(sound), {pid: 0x0202, data: aafInt64(168000)},
+		{pid: 0x1001, refs: []*aafTestObject{filler, clip(96000, mobID(1)), transition, effect}}}}
+	videoSequence := &aafTestObject{props: []aafTestProp{dataDef(picture), {pid: 0x0202, data: aafInt64(96000)},
+		{pid: 0x1001, refs: []*aafTestObject{clip(96000, mobID(1))}}}}
+	composition := &aafTestObject{clsid: compositionClass, props: []aafTestProp{
+		{pid: 0x4401, data: mobID(9)},
+		{pid: 0x4403, refs: []*aafTestObject{slot(audioSequence), slot(videoSequence)}},
+	}}
+
+	content := &aafTestObject{props: []aafTestProp{{pid: 0x1901, isSet: true, refs: []*aafTestObject{
+		composition,
+		masterMob(mobID(1), mobID(11)),
+		masterMob(mobID(2), mobID(12)),
+		fileMob(mobID(11), "file:///Users/me/Audio%20Files/Host.wav"),
+		fileMob(mobID(12), "file:///C:/Media/Guest.wav"),
+	}}}}
+	header := &aafTestObject{props: []aafTestProp{{pid: 0x3B03, ref: content}}}
+	root := &aafTestObject{props: []aafTestProp{{pid: 0x0002, ref: header}}}
+	tree := &cfbNode{name: "Root Entry"}
+	root.store(tree)
+
+	tracks, err := audio.NewAafParser().Parse(writeCFB(tree))
+	suite.Require().NoError(err)
+	suite.Require().Len(tracks, 2)
+	assert.Equal(suite.T(), "/Users/me/Audio Files/Host.wav", tracks[0].FilePath)
+	assert.Equal(suite.T(), "Host.wav", audio.ProjectFileName(tracks[0].FilePath))
+	assert.InDelta(suite.T(), 1.0, tracks[0].Offset, 0.0001)
+	assert.Equal(suite.T(), 1.0, tracks[0].Gain)
+	assert.Equal(suite.T(), "Guest.wav", audio.ProjectFileName(tracks[1].FilePath))
+	assert.InDelta(suite.T(), 2.5, tracks[1].Offset, 0.0001)
+
+	_, err = audio.NewAafParser().Parse([]byte("not an AAF file"))
+	assert.Error(suite.T(), err)
+}
+
 func TestAudioTestSuite(t *testing.T) {
 	suite.Run(t, new(AudioTestSuite))
 }
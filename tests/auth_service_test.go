@@ -7,6 +7,7 @@ import (
 
 	"synthezia/internal/auth"
 	"synthezia/internal/models"
+	"synthezia/pkg/clock"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/stretchr/testify/assert"
@@ -280,6 +281,40 @@ func (suite *AuthServiceTestSuite) TestNewAuthService() {
 	}
 }
 
+// Test connection tickets are single-use, expire and respect their path
+func (suite *AuthServiceTestSuite) TestConnectionTickets() {
+	store := auth.NewTicketStore()
+	fakeClock := clock.NewFake(time.Now())
+	store.SetClock(fakeClock)
+	identity := auth.TicketIdentity{AuthType: "jwt", UserID: 4, Username: "alice"}
+
+	ticket, expiresAt, err := store.Issue(identity, "/api/v1/stream")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), fakeClock.Now().Add(auth.TicketTTL), expiresAt)
+
+	redeemed, err := store.Redeem(ticket, "/api/v1/stream")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), identity, redeemed)
+	_, err = store.Redeem(ticket, "/api/v1/stream")
+	assert.ErrorIs(suite.T(), err, auth.ErrInvalidTicket)
+
+	bound, _, _ := store.Issue(identity, "/api/v1/stream")
+	_, err = store.Redeem(bound, "/api/v1/other")
+	assert.ErrorIs(suite.T(), err, auth.ErrInvalidTicket)
+	// A misdirected attempt burns the ticket
+	_, err = store.Redeem(bound, "/api/v1/stream")
+	assert.ErrorIs(suite.T(), err, auth.ErrInvalidTicket)
+
+	expiring, _, _ := store.Issue(identity, "/api/v1/stream")
+	fakeClock.Advance(auth.TicketTTL)
+	_, err = store.Redeem(expiring, "/api/v1/stream")
+	assert.ErrorIs(suite.T(), err, auth.ErrInvalidTicket)
+
+	// Every ticket opens one path only
+	_, _, err = store.Issue(identity, "")
+	assert.ErrorIs(suite.T(), err, auth.ErrTicketPath)
+}
+
 func TestAuthServiceTestSuite(t *testing.T) {
 	suite.Run(t, new(AuthServiceTestSuite))
 }
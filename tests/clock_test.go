@@ -0,0 +1,86 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"synthezia/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ClockTestSuite struct {
+	suite.Suite
+	start time.Time
+	clock *clock.Fake
+}
+
+func (suite *ClockTestSuite) SetupTest() {
+	suite.start = time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	suite.clock = clock.NewFake(suite.start)
+}
+
+// Test the real clock tracks wall time
+func (suite *ClockTestSuite) TestRealClock() {
+	before := time.Now()
+	assert.False(suite.T(), clock.Real.Now().Before(before))
+}
+
+// Test time only moves when advanced
+func (suite *ClockTestSuite) TestAdvance() {
+	assert.Equal(suite.T(), suite.start, suite.clock.Now())
+
+	suite.clock.Advance(90 * time.Second)
+	assert.Equal(suite.T(), suite.start.Add(90*time.Second), suite.clock.Now())
+	assert.Equal(suite.T(), 90*time.Second, suite.clock.Since(suite.start))
+
+	suite.clock.Set(suite.start.Add(time.Hour))
+	assert.Equal(suite.T(), time.Hour, suite.clock.Since(suite.start))
+}
+
+// Test sleeping goroutines wake once the deadline passes
+func (suite *ClockTestSuite) TestSleep() {
+	done := make(chan time.Time)
+	go func() {
+		suite.clock.Sleep(500 * time.Millisecond)
+		done <- suite.clock.Now()
+	}()
+
+	suite.clock.BlockUntil(1)
+	suite.clock.Advance(499 * time.Millisecond)
+	select {
+	case <-done:
+		suite.T().Fatal("sleep returned before its deadline")
+	case <-time.After(20 * time.Millisecond):
+	}
+
+	suite.clock.Advance(time.Millisecond)
+	select {
+	case woke := <-done:
+		assert.Equal(suite.T(), suite.start.Add(500*time.Millisecond), woke)
+	case <-time.After(time.Second):
+		suite.T().Fatal("sleep did not return after advancing")
+	}
+	assert.Equal(suite.T(), 0, suite.clock.Waiters())
+}
+
+// Test tickers fire once per period and stop cleanly
+func (suite *ClockTestSuite) TestTicker() {
+	ticker := suite.clock.NewTicker(10 * time.Second)
+
+	suite.clock.Advance(10 * time.Second)
+	assert.Equal(suite.T(), suite.start.Add(10*time.Second), <-ticker.C())
+
+	// Missed ticks are dropped like time.Ticker
+	suite.clock.Advance(30 * time.Second)
+	assert.Equal(suite.T(), suite.start.Add(20*time.Second), <-ticker.C())
+	assert.Len(suite.T(), ticker.C(), 0)
+
+	ticker.Stop()
+	assert.Equal(suite.T(), 0, suite.clock.Waiters())
+}
+
+func TestClockTestSuite(t *testing.T) {
+	suite.Run(t, new(ClockTestSuite))
+}
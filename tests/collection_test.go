@@ -0,0 +1,157 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type CollectionTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+	router *gin.Engine
+}
+
+func (suite *CollectionTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "collection_test.db")
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, nil, nil, nil)
+	suite.router = api.SetupRoutes(handler, suite.helper.AuthService)
+}
+
+func (suite *CollectionTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *CollectionTestSuite) request(method, path string, body any) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		suite.Require().NoError(err)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+// listed returns the IDs of the jobs the job list returns for the query
+func (suite *CollectionTestSuite) listed(query string) []string {
+	w := suite.request(http.MethodGet, "/api/v1/transcription/list?"+query, nil)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response struct {
+		Jobs []models.TranscriptionJob `json:"jobs"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	ids := []string{}
+	for _, job := range response.Jobs {
+		ids = append(ids, job.ID)
+	}
+	return ids
+}
+
+func (suite *CollectionTestSuite) TestTags() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Interview")
+	other := suite.helper.CreateTestTranscriptionJob(suite.T(), "Episode 12")
+
+	w := suite.request(http.MethodPut, "/api/v1/transcription/"+job.ID+"/tags", api.JobTagsRequest{Tags: []string{" Acme  Corp ", "case-4411", "acme corp"}})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var response api.JobTagsResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), []string{"Acme Corp", "case-4411"}, response.Tags)
+
+	w = suite.request(http.MethodPost, "/api/v1/transcription/"+job.ID+"/tags", api.JobTagsRequest{Tags: []string{"CASE-4411", "urgent"}})
+	suite.Require().Equal(http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), []string{"Acme Corp", "case-4411", "urgent"}, response.Tags)
+
+	w = suite.request(http.MethodDelete, "/api/v1/transcription/"+job.ID+"/tags/URGENT", nil)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), []string{"Acme Corp", "case-4411"}, response.Tags)
+
+	suite.request(http.MethodPut, "/api/v1/transcription/"+other.ID+"/tags", api.JobTagsRequest{Tags: []string{"acme corp"}})
+
+	assert.ElementsMatch(suite.T(), []string{job.ID, other.ID}, suite.listed("tag=ACME+CORP"))
+	assert.Equal(suite.T(), []string{job.ID}, suite.listed("tag=acme+corp&tag=case-4411"), "every tag must match")
+	assert.Empty(suite.T(), suite.listed("tag=acme"))
+
+	w = suite.request(http.MethodGet, "/api/v1/tags", nil)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var counts []api.TagCount
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &counts))
+	suite.Require().Len(counts, 2)
+	assert.Equal(suite.T(), int64(2), counts[0].Count)
+	assert.Equal(suite.T(), "case-4411", counts[1].Tag)
+
+	assert.Equal(suite.T(), http.StatusBadRequest, suite.request(http.MethodPut, "/api/v1/transcription/"+job.ID+"/tags", api.JobTagsRequest{Tags: []string{"  "}}).Code)
+	assert.Equal(suite.T(), http.StatusNotFound, suite.request(http.MethodPut, "/api/v1/transcription/missing/tags", api.JobTagsRequest{Tags: []string{"a"}}).Code)
+
+	w = suite.request(http.MethodPut, "/api/v1/transcription/"+job.ID+"/tags", api.JobTagsRequest{Tags: []string{}})
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var stored models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	assert.Nil(suite.T(), stored.Tags, "an empty list clears the tags")
+}
+
+func (suite *CollectionTestSuite) TestCollections() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Interview")
+	other := suite.helper.CreateTestTranscriptionJob(suite.T(), "Episode 12")
+
+	name, description := "Acme Corp", "Discovery recordings"
+	w := suite.request(http.MethodPost, "/api/v1/collections", api.CollectionRequest{Name: &name, Description: &description})
+	suite.Require().Equal(http.StatusCreated, w.Code, w.Body.String())
+	var collection api.CollectionResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &collection))
+
+	duplicate := "acme corp"
+	assert.Equal(suite.T(), http.StatusConflict, suite.request(http.MethodPost, "/api/v1/collections", api.CollectionRequest{Name: &duplicate}).Code)
+	assert.Equal(suite.T(), http.StatusBadRequest, suite.request(http.MethodPost, "/api/v1/collections", api.CollectionRequest{}).Code)
+
+	path := "/api/v1/transcription/" + job.ID + "/collection"
+	w = suite.request(http.MethodPut, path, api.JobCollectionRequest{CollectionID: &collection.ID})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	missing := collection.ID + 100
+	assert.Equal(suite.T(), http.StatusBadRequest, suite.request(http.MethodPut, path, api.JobCollectionRequest{CollectionID: &missing}).Code)
+
+	assert.Equal(suite.T(), []string{job.ID}, suite.listed("collection="+strconv.FormatUint(uint64(collection.ID), 10)))
+	assert.Equal(suite.T(), []string{other.ID}, suite.listed("collection=none"))
+	assert.Equal(suite.T(), http.StatusBadRequest, suite.request(http.MethodGet, "/api/v1/transcription/list?collection=acme", nil).Code)
+
+	renamed := "Acme Corporation"
+	w = suite.request(http.MethodPut, "/api/v1/collections/"+strconv.FormatUint(uint64(collection.ID), 10), api.CollectionRequest{Name: &renamed})
+	suite.Require().Equal(http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &collection))
+	assert.Equal(suite.T(), "Acme Corporation", collection.Name)
+	assert.Equal(suite.T(), "Discovery recordings", *collection.Description)
+	assert.Equal(suite.T(), int64(1), collection.JobCount)
+
+	w = suite.request(http.MethodGet, "/api/v1/collections", nil)
+	var collections []api.CollectionResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &collections))
+	suite.Require().Len(collections, 1)
+	assert.Equal(suite.T(), int64(1), collections[0].JobCount)
+
+	w = suite.request(http.MethodDelete, "/api/v1/collections/"+strconv.FormatUint(uint64(collection.ID), 10), nil)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Equal(suite.T(), http.StatusNotFound, suite.request(http.MethodGet, "/api/v1/collections/"+strconv.FormatUint(uint64(collection.ID), 10), nil).Code)
+	assert.ElementsMatch(suite.T(), []string{job.ID, other.ID}, suite.listed("collection=none"), "jobs outlive their collection")
+}
+
+func TestCollectionTestSuite(t *testing.T) {
+	suite.Run(t, new(CollectionTestSuite))
+}
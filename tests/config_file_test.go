@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"synthezia/internal/config"
+	"synthezia/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+const yamlConfig = `
+server:
+  port: 9000
+  host: 0.0.0.0
+  playback_proxy:
+    enabled: false
+auth:
+  jwt_secret: from-the-file
+queue:
+  workers: 3
+  max_load: 1.5
+  fair_share: false
+whisperx:
+  default_model: large-v3
+dropzone:
+  paths:
+    - /srv/inbox
+    - /srv/scanner
+  s3:
+    bucket: recordings
+storage:
+  backend: s3
+  s3:
+    region: eu-west-1
+telemetry:
+  sentry_dsn: ~
+`
+
+const tomlConfig = `
+[server]
+port = 9000
+host = "0.0.0.0"
+
+[auth]
+jwt_secret = "from-the-file"
+
+[queue]
+workers = 3
+max_load = 1.5
+
+[dropzone]
+paths = ["/srv/inbox", "/srv/scanner"]
+
+[storage.s3]
+region = "eu-west-1"
+`
+
+type ConfigFileTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *ConfigFileTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	// Cleared for the test and restored after it, as applying a file sets them
+	for _, env := range []string{"CONFIG_FILE", "PORT", "HOST", "PLAYBACK_PROXY_ENABLED", "JWT_SECRET", "QUEUE_WORKERS", "QUEUE_MAX_LOAD", "QUEUE_FAIR_SHARE",
+		"TRANSCRIPTION_DEFAULT_MODEL", "DROPZONE_PATHS", "DROPZONE_S3_BUCKET", "STORAGE_BACKEND", "STORAGE_S3_REGION", "SENTRY_DSN",
+		"LOG_LEVEL", "LOG_LEVEL_QUEUE", "LOG_LEVEL_HTTP"} {
+		suite.T().Setenv(env, "")
+	}
+}
+
+func (suite *ConfigFileTestSuite) writeFile(name, content string) string {
+	path := filepath.Join(suite.dir, name)
+	suite.Require().NoError(os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+// Test YAML and TOML files give the same settings
+func (suite *ConfigFileTestSuite) TestReadFile() {
+	values, err := config.ReadFile(suite.writeFile("config.yaml", yamlConfig))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), map[string]string{
+		"PORT":                        "9000",
+		"HOST":                        "0.0.0.0",
+		"PLAYBACK_PROXY_ENABLED":      "false",
+		"JWT_SECRET":                  "from-the-file",
+		"QUEUE_WORKERS":               "3",
+		"QUEUE_MAX_LOAD":              "1.5",
+		"QUEUE_FAIR_SHARE":            "false",
+		"TRANSCRIPTION_DEFAULT_MODEL": "large-v3",
+		"DROPZONE_PATHS":              "/srv/inbox,/srv/scanner",
+		"DROPZONE_S3_BUCKET":          "recordings",
+		"STORAGE_BACKEND":             "s3",
+		"STORAGE_S3_REGION":           "eu-west-1",
+	}, values)
+
+	values, err = config.ReadFile(suite.writeFile("config.toml", tomlConfig))
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), map[string]string{
+		"PORT":              "9000",
+		"HOST":              "0.0.0.0",
+		"JWT_SECRET":        "from-the-file",
+		"QUEUE_WORKERS":     "3",
+		"QUEUE_MAX_LOAD":    "1.5",
+		"DROPZONE_PATHS":    "/srv/inbox,/srv/scanner",
+		"STORAGE_S3_REGION": "eu-west-1",
+	}, values)
+}
+
+// Test mistakes in a file are reported
+func (suite *ConfigFileTestSuite) TestInvalidFiles() {
+	_, err := config.ReadFile(suite.writeFile("typo.yaml", "queue:\n  wokers: 3\n"))
+	assert.ErrorContains(suite.T(), err, "unknown setting queue.wokers")
+
+	_, err = config.ReadFile(suite.writeFile("nested.yaml", "dropzone:\n  paths:\n    - root: /srv\n"))
+	assert.ErrorContains(suite.T(), err, "dropzone.paths")
+
+	_, err = config.ReadFile(suite.writeFile("broken.toml", "[server\nport = 1"))
+	assert.Error(suite.T(), err)
+
+	_, err = config.ReadFile(suite.writeFile("config.json", "{}"))
+	assert.ErrorContains(suite.T(), err, ".yaml, .yml or .toml")
+
+	_, err = config.ReadFile(filepath.Join(suite.dir, "missing.yaml"))
+	assert.Error(suite.T(), err)
+}
+
+// Test the environment overrides the file, which overrides the defaults
+func (suite *ConfigFileTestSuite) TestLoadWithEnvOverride() {
+	os.Setenv("CONFIG_FILE", suite.writeFile("synthezia.yaml", yamlConfig))
+	os.Setenv("PORT", "7000")
+	assert.Equal(suite.T(), os.Getenv("CONFIG_FILE"), config.FindFile())
+
+	cfg := config.Load()
+	assert.Equal(suite.T(), "7000", cfg.Port)
+	assert.Equal(suite.T(), "0.0.0.0", cfg.Host)
+	assert.Equal(suite.T(), "from-the-file", cfg.JWTSecret)
+	assert.False(suite.T(), cfg.PlaybackProxyEnabled)
+	assert.False(suite.T(), cfg.QueueFairShare)
+	assert.Equal(suite.T(), "large-v3", cfg.TranscriptionDefaultModel)
+	assert.Equal(suite.T(), "/srv/inbox,/srv/scanner", cfg.DropzonePaths)
+	assert.Equal(suite.T(), "eu-west-1", cfg.StorageS3Region)
+	assert.Equal(suite.T(), "us-east-1", cfg.DropzoneS3Region)
+	// Settings read outside the Config, such as the queue's, see the file too
+	assert.Equal(suite.T(), "3", os.Getenv("QUEUE_WORKERS"))
+}
+
+// Test the file sets the log level and the levels of single modules
+func (suite *ConfigFileTestSuite) TestLogLevels() {
+	path := suite.writeFile("logging.yaml", "logging:\n  level: warn\n  modules:\n    queue: debug\n    http: error\n")
+	values, err := config.ReadFile(path)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), map[string]string{
+		"LOG_LEVEL":       "warn",
+		"LOG_LEVEL_QUEUE": "debug",
+		"LOG_LEVEL_HTTP":  "error",
+	}, values)
+
+	_, err = config.ReadFile(suite.writeFile("module.yaml", "logging:\n  modules:\n    queu: debug\n"))
+	assert.ErrorContains(suite.T(), err, "unknown setting logging.modules.queu")
+
+	// Logging started again after loading the file takes its levels
+	os.Setenv("CONFIG_FILE", path)
+	defer func() {
+		for _, env := range []string{"LOG_LEVEL", "LOG_LEVEL_QUEUE", "LOG_LEVEL_HTTP"} {
+			os.Unsetenv(env)
+		}
+		logger.Init("")
+	}()
+	config.Load()
+	logger.Init(os.Getenv("LOG_LEVEL"))
+	assert.Equal(suite.T(), logger.LevelWarn, logger.GetLevel())
+	assert.Equal(suite.T(), logger.LevelDebug, logger.ModuleLevel(logger.ModuleQueue))
+	assert.Equal(suite.T(), logger.LevelError, logger.ModuleLevel(logger.ModuleHTTP))
+	assert.Equal(suite.T(), logger.LevelWarn, logger.ModuleLevel(logger.ModuleDropzone))
+}
+
+func TestConfigFileTestSuite(t *testing.T) {
+	suite.Run(t, new(ConfigFileTestSuite))
+}
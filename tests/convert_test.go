@@ -0,0 +1,249 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/convert"
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeConvertFFmpeg copies its input (-y -i <input> ...) to the last
+// argument and records its arguments next to it
+const fakeConvertFFmpeg = `#!/bin/sh
+for arg; do out="$arg"; done
+cp "$3" "$out"
+echo "$@" > "$(dirname "$0")/args"
+`
+
+// idleProcessor fails any transcription job; conversion tests queue none
+type idleProcessor struct{}
+
+func (idleProcessor) ProcessJob(ctx context.Context, jobID string) error {
+	return fmt.Errorf("unexpected job %s", jobID)
+}
+
+func (idleProcessor) ProcessJobWithProcess(ctx context.Context, jobID string, registerProcess func(*exec.Cmd)) error {
+	return fmt.Errorf("unexpected job %s", jobID)
+}
+
+type ConvertTestSuite struct {
+	suite.Suite
+	helper    *TestHelper
+	dir       string
+	service   *convert.Service
+	taskQueue *queue.TaskQueue
+	router    *gin.Engine
+}
+
+func (suite *ConvertTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "convert_test.db")
+	suite.dir = suite.T().TempDir()
+	ffmpeg := filepath.Join(suite.dir, "ffmpeg")
+	require.NoError(suite.T(), os.WriteFile(ffmpeg, []byte(fakeConvertFFmpeg), 0755))
+
+	suite.service = convert.NewService(suite.helper.DB)
+	suite.service.SetFFmpegPath(ffmpeg)
+	suite.service.SetOutputDir(filepath.Join(suite.dir, "conversions"))
+
+	suite.taskQueue = queue.NewTaskQueue(1, idleProcessor{})
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, suite.taskQueue, nil, nil, nil)
+	handler.SetConverter(suite.service)
+	suite.router = api.SetupRoutes(handler, suite.helper.AuthService)
+}
+
+func (suite *ConvertTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// ffmpegArgs returns the arguments of the last fake ffmpeg run
+func (suite *ConvertTestSuite) ffmpegArgs() string {
+	data, err := os.ReadFile(filepath.Join(suite.dir, "args"))
+	require.NoError(suite.T(), err)
+	return string(bytes.TrimSpace(data))
+}
+
+// request sends an authenticated request to the router
+func (suite *ConvertTestSuite) request(method, path string, body *bytes.Buffer, contentType string) *httptest.ResponseRecorder {
+	if body == nil {
+		body = &bytes.Buffer{}
+	}
+	req := httptest.NewRequest(method, path, body)
+	if contentType != "" {
+		req.Header.Set("Content-Type", contentType)
+	}
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+// form builds a multipart body with the given fields and optional file
+func (suite *ConvertTestSuite) form(fields map[string]string, fileName, fileContent string) (*bytes.Buffer, string) {
+	body := &bytes.Buffer{}
+	writer := multipart.NewWriter(body)
+	for key, value := range fields {
+		require.NoError(suite.T(), writer.WriteField(key, value))
+	}
+	if fileName != "" {
+		part, err := writer.CreateFormFile("audio", fileName)
+		require.NoError(suite.T(), err)
+		part.Write([]byte(fileContent))
+	}
+	require.NoError(suite.T(), writer.Close())
+	return body, writer.FormDataContentType()
+}
+
+// waitForConversion polls a conversion until it finishes
+func (suite *ConvertTestSuite) waitForConversion(id string) models.AudioConversion {
+	var conversion models.AudioConversion
+	require.Eventually(suite.T(), func() bool {
+		w := suite.request(http.MethodGet, "/api/v1/audio/convert/"+id, nil, "")
+		if w.Code != http.StatusOK || json.Unmarshal(w.Body.Bytes(), &conversion) != nil {
+			return false
+		}
+		return conversion.Status == models.ConversionCompleted || conversion.Status == models.ConversionFailed
+	}, 5*time.Second, 20*time.Millisecond)
+	return conversion
+}
+
+// Test formats and bitrates are checked, with per-format defaults
+func (suite *ConvertTestSuite) TestValidate() {
+	bitrate, err := convert.Validate("mp3", "")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "192k", bitrate)
+
+	bitrate, err = convert.Validate("opus", "48k")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "48k", bitrate)
+
+	bitrate, err = convert.Validate("flac", "")
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), bitrate)
+
+	for _, bad := range [][2]string{{"wma", ""}, {"flac", "128k"}, {"mp3", "128"}, {"mp3", "2k"}, {"mp3", "1000k"}} {
+		_, err := convert.Validate(bad[0], bad[1])
+		assert.Error(suite.T(), err, "%v", bad)
+	}
+}
+
+// Test an uploaded file is converted on the queue and downloaded
+func (suite *ConvertTestSuite) TestConvertUpload() {
+	suite.taskQueue.Start()
+	defer suite.taskQueue.Stop()
+
+	body, contentType := suite.form(map[string]string{"format": "opus", "bitrate": "48k"}, "interview.amr", "amr audio")
+	w := suite.request(http.MethodPost, "/api/v1/audio/convert", body, contentType)
+	require.Equal(suite.T(), http.StatusAccepted, w.Code, w.Body.String())
+	var queued models.AudioConversion
+	require.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &queued))
+	assert.Equal(suite.T(), "interview.amr", queued.SourceName)
+
+	conversion := suite.waitForConversion(queued.ID)
+	require.Equal(suite.T(), models.ConversionCompleted, conversion.Status, conversion.Error)
+	assert.Equal(suite.T(), int64(len("amr audio")), conversion.OutputSize)
+	assert.Contains(suite.T(), suite.ffmpegArgs(), "-c:a libopus -b:a 48k")
+
+	// The uploaded copy is removed once converted
+	var stored models.AudioConversion
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", queued.ID).First(&stored).Error)
+	assert.NoFileExists(suite.T(), stored.SourcePath)
+
+	w = suite.request(http.MethodGet, "/api/v1/audio/convert/"+queued.ID+"/download", nil, "")
+	require.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Equal(suite.T(), "amr audio", w.Body.String())
+	assert.Equal(suite.T(), "audio/ogg", w.Header().Get("Content-Type"))
+	assert.Contains(suite.T(), w.Header().Get("Content-Disposition"), "interview.opus")
+}
+
+// Test a job's audio is converted and bad requests are refused
+func (suite *ConvertTestSuite) TestConvertJobAudio() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Board meeting")
+	audioPath := filepath.Join(suite.dir, "meeting.wma")
+	require.NoError(suite.T(), os.WriteFile(audioPath, []byte("wma audio"), 0644))
+	require.NoError(suite.T(), suite.helper.DB.Model(job).Update("audio_path", audioPath).Error)
+
+	body, contentType := suite.form(map[string]string{"format": "mp3", "job_id": job.ID}, "", "")
+	w := suite.request(http.MethodPost, "/api/v1/audio/convert", body, contentType)
+	require.Equal(suite.T(), http.StatusAccepted, w.Code, w.Body.String())
+	var queued models.AudioConversion
+	require.NoError(suite.T(), json.Unmarshal(w.Body.Bytes(), &queued))
+	require.NotNil(suite.T(), queued.JobID)
+	assert.Equal(suite.T(), job.ID, *queued.JobID)
+	assert.Equal(suite.T(), "192k", queued.Bitrate)
+
+	// Not downloadable until the queue has run it
+	w = suite.request(http.MethodGet, "/api/v1/audio/convert/"+queued.ID+"/download", nil, "")
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	suite.taskQueue.Start()
+	defer suite.taskQueue.Stop()
+	conversion := suite.waitForConversion(queued.ID)
+	require.Equal(suite.T(), models.ConversionCompleted, conversion.Status, conversion.Error)
+	assert.FileExists(suite.T(), audioPath, "a job's own audio is kept")
+
+	w = suite.request(http.MethodGet, "/api/v1/audio/convert/"+queued.ID+"/download", nil, "")
+	require.Equal(suite.T(), http.StatusOK, w.Code)
+	assert.Contains(suite.T(), w.Header().Get("Content-Disposition"), "Board meeting.mp3")
+
+	for _, fields := range []map[string]string{
+		{"format": "wma", "job_id": job.ID},
+		{"format": "mp3"},
+	} {
+		body, contentType := suite.form(fields, "", "")
+		w := suite.request(http.MethodPost, "/api/v1/audio/convert", body, contentType)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "%v", fields)
+	}
+	body, contentType = suite.form(map[string]string{"format": "mp3", "job_id": "missing"}, "", "")
+	w = suite.request(http.MethodPost, "/api/v1/audio/convert", body, contentType)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// Test a failed encode is recorded and conversions left by a restart resume
+func (suite *ConvertTestSuite) TestFailureAndResume() {
+	missing := models.AudioConversion{Format: "wav", SourcePath: filepath.Join(suite.dir, "gone.wav"), Status: models.ConversionPending}
+	require.NoError(suite.T(), suite.helper.DB.Create(&missing).Error)
+	assert.Error(suite.T(), suite.service.Run(context.Background(), missing.ID))
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", missing.ID).First(&missing).Error)
+	assert.Equal(suite.T(), models.ConversionFailed, missing.Status)
+	require.NotNil(suite.T(), missing.Error)
+	assert.Contains(suite.T(), *missing.Error, "source audio not found")
+
+	source := filepath.Join(suite.dir, "call.wav")
+	require.NoError(suite.T(), os.WriteFile(source, []byte("wav audio"), 0644))
+	interrupted := models.AudioConversion{Format: "flac", SourcePath: source, Status: models.ConversionProcessing}
+	require.NoError(suite.T(), suite.helper.DB.Create(&interrupted).Error)
+
+	var queued []string
+	resumed, err := suite.service.Resume(func(task queue.Task) error {
+		queued = append(queued, task.TaskID())
+		return task.Run(context.Background())
+	})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, resumed)
+	assert.Equal(suite.T(), []string{"conversion:" + interrupted.ID}, queued)
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", interrupted.ID).First(&interrupted).Error)
+	assert.Equal(suite.T(), models.ConversionCompleted, interrupted.Status)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, "conversions", interrupted.ID+".flac"), interrupted.OutputPath)
+}
+
+func TestConvertTestSuite(t *testing.T) {
+	suite.Run(t, new(ConvertTestSuite))
+}
@@ -0,0 +1,178 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"synthezia/internal/devices"
+	"synthezia/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type DevicesTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *DevicesTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	suite.T().Setenv("CUDA_VISIBLE_DEVICES", "")
+}
+
+// fakeNvidiaSMI installs an nvidia-smi printing the given query output
+func (suite *DevicesTestSuite) fakeNvidiaSMI(output string) string {
+	suite.Require().NoError(os.WriteFile(filepath.Join(suite.dir, "gpus.csv"), []byte(output), 0644))
+	path := filepath.Join(suite.dir, "nvidia-smi")
+	suite.Require().NoError(os.WriteFile(path, []byte("#!/bin/sh\ncat \""+filepath.Join(suite.dir, "gpus.csv")+"\"\n"), 0755))
+	return path
+}
+
+func (suite *DevicesTestSuite) newManager(output string, opts devices.Options) *devices.Manager {
+	opts.NvidiaSMI = suite.fakeNvidiaSMI(output)
+	manager := devices.NewManager(opts)
+	suite.Require().NoError(manager.Discover(context.Background()))
+	return manager
+}
+
+func (suite *DevicesTestSuite) acquire(manager *devices.Manager, jobID, device string, memoryMB int) *devices.Lease {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	lease, err := manager.Acquire(ctx, devices.Request{JobID: jobID, Device: device, ModelFamily: "whisper", MemoryMB: memoryMB})
+	suite.Require().NoError(err)
+	return lease
+}
+
+func (suite *DevicesTestSuite) TestDiscover() {
+	manager := suite.newManager("0, NVIDIA A100-SXM4-40GB, 40960, 1024, 3\n1, NVIDIA L4, 23034, 0, [N/A]\n", devices.Options{GPUSlots: 2})
+
+	list := manager.Devices()
+	suite.Require().Len(list, 3)
+	assert.Equal(suite.T(), "cuda:0", list[0].ID)
+	assert.Equal(suite.T(), "NVIDIA A100-SXM4-40GB", list[0].Name)
+	assert.Equal(suite.T(), 40960, list[0].TotalMB)
+	assert.Equal(suite.T(), 1024, list[0].UsedMB)
+	assert.Equal(suite.T(), 2, list[0].Slots)
+	assert.Equal(suite.T(), "cuda:1", list[1].ID)
+	assert.Equal(suite.T(), devices.KindCPU, list[2].Kind)
+	assert.Equal(suite.T(), 4, manager.GPUSlots())
+
+	suite.T().Setenv("CUDA_VISIBLE_DEVICES", "1")
+	list = suite.newManager("0, NVIDIA A100-SXM4-40GB, 40960, 1024, 3\n1, NVIDIA L4, 23034, 0, 0\n", devices.Options{}).Devices()
+	suite.Require().Len(list, 2)
+	assert.Equal(suite.T(), "cuda:0", list[0].ID, "visible devices are numbered from 0")
+	assert.Equal(suite.T(), "NVIDIA L4", list[0].Name)
+
+	manager = devices.NewManager(devices.Options{NvidiaSMI: filepath.Join(suite.dir, "missing")})
+	suite.Require().NoError(manager.Discover(context.Background()), "a host without nvidia-smi has no GPUs")
+	assert.Zero(suite.T(), manager.GPUSlots())
+}
+
+func (suite *DevicesTestSuite) TestAssignsMostFreeMemory() {
+	manager := suite.newManager("0, GPU A, 16000, 0, 0\n1, GPU B, 24000, 0, 0\n", devices.Options{GPUSlots: 2})
+
+	first := suite.acquire(manager, "job-1", "auto", 10000)
+	assert.Equal(suite.T(), "cuda:1", first.Device.ID)
+	second := suite.acquire(manager, "job-2", "cuda", 10000)
+	assert.Equal(suite.T(), "cuda:0", second.Device.ID, "14000 MB is left on cuda:1 and 16000 MB on cuda:0")
+
+	params := models.WhisperXParams{Device: "auto"}
+	second.Apply(&params)
+	assert.Equal(suite.T(), "cuda", params.Device)
+	assert.Equal(suite.T(), 0, params.DeviceIndex)
+
+	list := manager.Devices()
+	assert.Equal(suite.T(), []string{"job-2"}, list[0].Jobs)
+	assert.Equal(suite.T(), 10000, list[1].ReservedMB)
+	first.Release()
+	first.Release()
+	assert.Zero(suite.T(), manager.Devices()[1].ReservedMB)
+}
+
+func (suite *DevicesTestSuite) TestWaitsForFreeDevice() {
+	manager := suite.newManager("0, GPU A, 16000, 0, 0\n", devices.Options{})
+	running := suite.acquire(manager, "job-1", "auto", 2000)
+
+	acquired := make(chan *devices.Lease)
+	go func() {
+		lease, err := manager.Acquire(context.Background(), devices.Request{JobID: "job-2", Device: "auto", ModelFamily: "whisper", MemoryMB: 2000})
+		suite.NoError(err)
+		acquired <- lease
+	}()
+	select {
+	case <-acquired:
+		suite.Fail("the only slot of the GPU is taken")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	running.Release()
+	select {
+	case lease := <-acquired:
+		assert.Equal(suite.T(), "cuda:0", lease.Device.ID)
+	case <-time.After(time.Second):
+		suite.Fail("the job did not get the released GPU")
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := manager.Acquire(ctx, devices.Request{JobID: "job-3", Device: "cuda", MemoryMB: 2000})
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+}
+
+func (suite *DevicesTestSuite) TestMemoryOfOtherProcesses() {
+	manager := suite.newManager("0, GPU A, 16000, 0, 0\n", devices.Options{GPUSlots: 4})
+	suite.fakeNvidiaSMI("0, GPU A, 16000, 12000, 90\n")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	_, err := manager.Acquire(ctx, devices.Request{JobID: "job-1", Device: "cuda", MemoryMB: 5000})
+	assert.ErrorIs(suite.T(), err, context.DeadlineExceeded, "only 4000 MB are free")
+	assert.Equal(suite.T(), 90, manager.Devices()[0].Utilization)
+
+	lease := suite.acquire(manager, "job-2", "cuda", 3000)
+	assert.Equal(suite.T(), "cuda:0", lease.Device.ID)
+}
+
+func (suite *DevicesTestSuite) TestFallbacks() {
+	manager := suite.newManager("0, GPU A, 4000, 0, 0\n", devices.Options{})
+
+	lease := suite.acquire(manager, "job-1", "auto", 10000)
+	assert.Equal(suite.T(), devices.KindCPU, lease.Device.ID, "no GPU is large enough")
+	lease = suite.acquire(manager, "job-2", "cpu", 1000)
+	assert.Equal(suite.T(), devices.KindCPU, lease.Device.ID)
+
+	_, err := manager.Acquire(context.Background(), devices.Request{JobID: "job-3", Device: "cuda", MemoryMB: 10000})
+	assert.ErrorIs(suite.T(), err, devices.ErrNoDevice)
+
+	cpuOnly := devices.NewManager(devices.Options{})
+	_, err = cpuOnly.Acquire(context.Background(), devices.Request{JobID: "job-4", Device: "cuda", MemoryMB: 1000})
+	assert.ErrorIs(suite.T(), err, devices.ErrNoDevice)
+	lease, err = cpuOnly.Acquire(context.Background(), devices.Request{JobID: "job-5", Device: "auto", MemoryMB: 1000})
+	suite.Require().NoError(err)
+	params := models.WhisperXParams{Device: "auto", DeviceIndex: 3}
+	lease.Apply(&params)
+	assert.Equal(suite.T(), "cpu", params.Device)
+	assert.Equal(suite.T(), 0, params.DeviceIndex)
+}
+
+func (suite *DevicesTestSuite) TestEstimateMB() {
+	small := models.WhisperXParams{ModelFamily: "whisper", Model: "small", ComputeType: "float16"}
+	assert.Equal(suite.T(), 2000, devices.EstimateMB(small))
+	small.ComputeType = "float32"
+	assert.Equal(suite.T(), 4000, devices.EstimateMB(small))
+	small.ComputeType = "int8_float16"
+	small.Diarize = true
+	assert.Equal(suite.T(), 2500, devices.EstimateMB(small))
+
+	assert.Equal(suite.T(), 10000, devices.EstimateMB(models.WhisperXParams{ModelFamily: "whisper", Model: "large-v3", ComputeType: "float16"}))
+	assert.Equal(suite.T(), 4000, devices.EstimateMB(models.WhisperXParams{ModelFamily: "nvidia_parakeet"}))
+	assert.Zero(suite.T(), devices.EstimateMB(models.WhisperXParams{ModelFamily: "fake", Diarize: true}))
+}
+
+func TestDevicesTestSuite(t *testing.T) {
+	suite.Run(t, new(DevicesTestSuite))
+}
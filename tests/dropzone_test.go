@@ -1,13 +1,27 @@
 package tests
 
 import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
 	"testing"
 	"time"
 
+	"gorm.io/gorm"
 	"synthezia/internal/dropzone"
+	"synthezia/internal/faults"
+	"synthezia/internal/jobstate"
 	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -79,243 +93,222 @@ func (suite *DropzoneTestSuite) TestServiceStart() {
 	assert.NoError(suite.T(), err)
 }
 
+// startOnDropzone starts a service watching the suite's dropzone on a fake
+// clock, letting each of the audio files already there settle in turn
+func (suite *DropzoneTestSuite) startOnDropzone(existing int) (*dropzone.Service, *clock.Fake) {
+	cfg := *suite.helper.Config
+	cfg.DropzonePaths = suite.dropzonePath
+	cfg.UploadDir = filepath.Join("test_dropzone_data", "uploads")
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(&cfg, suite.mockQueue)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < existing; i++ {
+		suite.settle(fakeClock, 1)
+	}
+	suite.Require().NoError(<-started)
+	return service, fakeClock
+}
+
+// settle waits for the given number of dropped files to start settling, then
+// lets them all settle at once
+func (suite *DropzoneTestSuite) settle(fakeClock *clock.Fake, files int) {
+	fakeClock.BlockUntil(files)
+	fakeClock.Advance(500 * time.Millisecond)
+}
+
+// drop writes a file outside the dropzone and renames it into dir, so it is
+// complete when the watcher sees it
+func (suite *DropzoneTestSuite) drop(dir, name, content string) string {
+	staging := filepath.Join("test_dropzone_data", "staging")
+	suite.Require().NoError(os.MkdirAll(staging, 0755))
+	staged := filepath.Join(staging, name)
+	suite.Require().NoError(os.WriteFile(staged, []byte(content), 0644))
+	path := filepath.Join(dir, name)
+	suite.Require().NoError(os.Rename(staged, path))
+	return path
+}
+
+// waitForIngest waits until the file at path has been ingested and removed
+// from the dropzone, and returns its job
+func (suite *DropzoneTestSuite) waitForIngest(path string) *models.TranscriptionJob {
+	suite.Require().Eventually(func() bool {
+		_, err := os.Stat(path)
+		return os.IsNotExist(err)
+	}, 5*time.Second, 10*time.Millisecond, "%s was not ingested", path)
+	var job models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("title = ?", filepath.Base(path)).Order("created_at DESC").First(&job).Error)
+	return &job
+}
+
 // Test processing existing audio files on startup
 func (suite *DropzoneTestSuite) TestProcessExistingFiles() {
-	// Create dropzone directory with audio files
 	os.MkdirAll(suite.dropzonePath, 0755)
-	
-	// Create test audio files
-	audioFile1 := filepath.Join(suite.dropzonePath, "test1.mp3")
-	audioFile2 := filepath.Join(suite.dropzonePath, "test2.wav")
+
+	audioFile1 := filepath.Join(suite.dropzonePath, "existing1.mp3")
+	audioFile2 := filepath.Join(suite.dropzonePath, "existing2.wav")
 	nonAudioFile := filepath.Join(suite.dropzonePath, "document.txt")
-	
 	os.WriteFile(audioFile1, []byte("dummy audio 1"), 0644)
 	os.WriteFile(audioFile2, []byte("dummy audio 2"), 0644)
 	os.WriteFile(nonAudioFile, []byte("text document"), 0644)
-	
-	// Disable auto-transcription for this test
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	
-	// Mock queue to return no error
+
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	
-	err := service.Start()
-	assert.NoError(suite.T(), err)
-	
-	// Give it time to process files
-	time.Sleep(1 * time.Second)
-	
-	// Stop service
+
+	// Start ingests the files already there before returning
+	service, _ := suite.startOnDropzone(2)
 	service.Stop()
-	
-	// Verify audio files were processed
-	// They should be moved from dropzone and uploaded
-	_, err1 := os.Stat(audioFile1)
-	_, err2 := os.Stat(audioFile2)
-	
-	// Audio files should be removed from dropzone after processing
-	assert.True(suite.T(), os.IsNotExist(err1) || os.IsNotExist(err2), "At least one audio file should be processed")
-	
-	// Non-audio file should still exist
-	_, err = os.Stat(nonAudioFile)
+
+	for _, path := range []string{audioFile1, audioFile2} {
+		_, err := os.Stat(path)
+		assert.True(suite.T(), os.IsNotExist(err), "%s should be removed once ingested", path)
+		var count int64
+		suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", filepath.Base(path)).Count(&count)
+		assert.Equal(suite.T(), int64(1), count)
+	}
+
+	_, err := os.Stat(nonAudioFile)
 	assert.NoError(suite.T(), err)
 }
 
 // Test file detection with various audio formats
 func (suite *DropzoneTestSuite) TestAudioFileDetection() {
 	os.MkdirAll(suite.dropzonePath, 0755)
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	err := service.Start()
-	assert.NoError(suite.T(), err)
+
+	service, fakeClock := suite.startOnDropzone(0)
 	defer service.Stop()
-	
-	// Test various audio formats
+
 	audioFormats := []string{
-		"test.mp3", "test.wav", "test.flac", "test.m4a",
-		"test.aac", "test.ogg", "test.wma", "test.mp4",
+		"format.mp3", "format.wav", "format.flac", "format.m4a",
+		"format.aac", "format.ogg", "format.wma", "format.mp4",
 	}
-	
 	for _, format := range audioFormats {
-		filePath := filepath.Join(suite.dropzonePath, format)
-		os.WriteFile(filePath, []byte("dummy audio"), 0644)
+		suite.drop(suite.dropzonePath, format, "dummy audio")
+	}
+	suite.settle(fakeClock, len(audioFormats))
+
+	for _, format := range audioFormats {
+		suite.waitForIngest(filepath.Join(suite.dropzonePath, format))
 	}
-	
-	// Give time to process
-	time.Sleep(1500 * time.Millisecond)
-	
-	// Check that jobs were created in database
-	var jobs []models.TranscriptionJob
-	suite.helper.DB.Find(&jobs)
-	
-	// Should have created jobs for all audio files
-	assert.GreaterOrEqual(suite.T(), len(jobs), 1, "At least some audio files should be processed")
 }
 
 // Test non-audio file is ignored
 func (suite *DropzoneTestSuite) TestNonAudioFilesIgnored() {
 	os.MkdirAll(suite.dropzonePath, 0755)
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	err := service.Start()
-	assert.NoError(suite.T(), err)
+
+	service, fakeClock := suite.startOnDropzone(0)
 	defer service.Stop()
-	
-	// Create non-audio files
-	textFile := filepath.Join(suite.dropzonePath, "document.txt")
-	pdfFile := filepath.Join(suite.dropzonePath, "document.pdf")
-	
+
+	textFile := filepath.Join(suite.dropzonePath, "notes.txt")
+	pdfFile := filepath.Join(suite.dropzonePath, "notes.pdf")
 	os.WriteFile(textFile, []byte("text content"), 0644)
 	os.WriteFile(pdfFile, []byte("pdf content"), 0644)
-	
-	// Give time for potential processing
-	time.Sleep(1 * time.Second)
-	
-	// Files should still exist (not processed)
+
+	// An audio file dropped after them shows the watcher has seen them
+	audioFile := suite.drop(suite.dropzonePath, "after_notes.mp3", "dummy audio")
+	suite.settle(fakeClock, 1)
+	suite.waitForIngest(audioFile)
+
 	_, err1 := os.Stat(textFile)
 	_, err2 := os.Stat(pdfFile)
 	assert.NoError(suite.T(), err1)
 	assert.NoError(suite.T(), err2)
-	
-	// No jobs should be created
-	var jobs []models.TranscriptionJob
-	suite.helper.DB.Find(&jobs)
-	assert.Equal(suite.T(), 0, len(jobs))
+
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title IN ?", []string{"notes.txt", "notes.pdf"}).Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
 }
 
 // Test subdirectory creation and monitoring
 func (suite *DropzoneTestSuite) TestSubdirectoryMonitoring() {
 	os.MkdirAll(suite.dropzonePath, 0755)
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	err := service.Start()
-	assert.NoError(suite.T(), err)
+
+	service, fakeClock := suite.startOnDropzone(0)
 	defer service.Stop()
-	
-	// Create a subdirectory
+
+	// A file dropped straight after its directory is created may come before
+	// the directory is watched, and is picked up either way
 	subDir := filepath.Join(suite.dropzonePath, "subfolder")
 	os.MkdirAll(subDir, 0755)
-	
-	// Give time for directory to be detected
-	time.Sleep(500 * time.Millisecond)
-	
-	// Create audio file in subdirectory
-	audioFile := filepath.Join(subDir, "subdir_audio.mp3")
-	os.WriteFile(audioFile, []byte("dummy audio in subdir"), 0644)
-	
-	// Give time to process
-	time.Sleep(1500 * time.Millisecond)
-	
-	// File should be processed and removed
-	_, err = os.Stat(audioFile)
-	// File might be removed if processed successfully
-	_ = err
+	audioFile := suite.drop(subDir, "subdir_audio.mp3", "dummy audio in subdir")
+	suite.settle(fakeClock, 1)
+	suite.waitForIngest(audioFile)
+
+	// Files moved in with a directory raise no events of their own
+	staged := filepath.Join("test_dropzone_data", "moved")
+	os.MkdirAll(staged, 0755)
+	os.WriteFile(filepath.Join(staged, "moved_audio.mp3"), []byte("dummy audio moved in"), 0644)
+	movedDir := filepath.Join(suite.dropzonePath, "moved")
+	suite.Require().NoError(os.Rename(staged, movedDir))
+	suite.settle(fakeClock, 1)
+	suite.waitForIngest(filepath.Join(movedDir, "moved_audio.mp3"))
 }
 
 // Test auto-transcription disabled
 func (suite *DropzoneTestSuite) TestAutoTranscriptionDisabled() {
 	os.MkdirAll(suite.dropzonePath, 0755)
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	
+
 	// Ensure no users have auto-transcription enabled
 	suite.helper.DB.Model(&models.User{}).Where("1=1").Update("auto_transcription_enabled", false)
-	
+
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	err := service.Start()
-	assert.NoError(suite.T(), err)
+
+	service, fakeClock := suite.startOnDropzone(0)
 	defer service.Stop()
-	
-	// Create audio file
-	audioFile := filepath.Join(suite.dropzonePath, "no_auto.mp3")
-	os.WriteFile(audioFile, []byte("dummy audio"), 0644)
-	
-	// Give time to process
-	time.Sleep(1500 * time.Millisecond)
-	
-	// Job should be created but not enqueued (status should be "uploaded")
-	var job models.TranscriptionJob
-	result := suite.helper.DB.Where("status = ?", models.StatusUploaded).First(&job)
-	
-	if result.Error == nil {
-		// Job created but not auto-started
-		assert.Equal(suite.T(), models.StatusUploaded, job.Status)
-	}
-	
-	// Queue should not be called
-	assert.Equal(suite.T(), 0, len(suite.mockQueue.enqueuedJobs))
+
+	audioFile := suite.drop(suite.dropzonePath, "no_auto.mp3", "dummy audio")
+	suite.settle(fakeClock, 1)
+
+	// The job is created but not started
+	job := suite.waitForIngest(audioFile)
+	assert.Equal(suite.T(), models.StatusUploaded, job.Status)
+	assert.NotContains(suite.T(), suite.mockQueue.enqueuedJobs, job.ID)
 }
 
 // Test auto-transcription enabled
 func (suite *DropzoneTestSuite) TestAutoTranscriptionEnabled() {
 	os.MkdirAll(suite.dropzonePath, 0755)
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	
+
 	// Enable auto-transcription for test user
 	suite.helper.DB.Model(&models.User{}).Where("username = ?", suite.helper.TestUser.Username).
 		Update("auto_transcription_enabled", true)
-	
+	defer suite.helper.DB.Model(&models.User{}).Where("1=1").Update("auto_transcription_enabled", false)
+
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	err := service.Start()
-	assert.NoError(suite.T(), err)
+
+	service, fakeClock := suite.startOnDropzone(0)
 	defer service.Stop()
-	
-	// Create audio file
-	audioFile := filepath.Join(suite.dropzonePath, "auto_transcribe.mp3")
-	os.WriteFile(audioFile, []byte("dummy audio"), 0644)
-	
-	// Give time to process
-	time.Sleep(1500 * time.Millisecond)
-	
-	// Job should be enqueued
-	if len(suite.mockQueue.enqueuedJobs) > 0 {
-		assert.Greater(suite.T(), len(suite.mockQueue.enqueuedJobs), 0, "Job should be enqueued")
-	}
+
+	audioFile := suite.drop(suite.dropzonePath, "auto_transcribe.mp3", "dummy audio")
+	suite.settle(fakeClock, 1)
+
+	job := suite.waitForIngest(audioFile)
+	assert.Equal(suite.T(), models.StatusPending, job.Status)
+	assert.Contains(suite.T(), suite.mockQueue.enqueuedJobs, job.ID)
 }
 
 // Test file upload creates correct database record
 func (suite *DropzoneTestSuite) TestFileUploadCreatesJob() {
 	os.MkdirAll(suite.dropzonePath, 0755)
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	os.MkdirAll(suite.helper.Config.UploadDir, 0755)
-	
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	err := service.Start()
-	assert.NoError(suite.T(), err)
+
+	service, fakeClock := suite.startOnDropzone(0)
 	defer service.Stop()
-	
-	// Create audio file with specific name
+
 	originalFilename := "my_recording.mp3"
-	audioFile := filepath.Join(suite.dropzonePath, originalFilename)
-	os.WriteFile(audioFile, []byte("dummy audio content"), 0644)
-	
-	// Give time to process
-	time.Sleep(1500 * time.Millisecond)
-	
-	// Check database for job
-	var job models.TranscriptionJob
-	result := suite.helper.DB.Where("title = ?", originalFilename).First(&job)
-	
-	if result.Error == nil {
-		assert.Equal(suite.T(), originalFilename, *job.Title)
-		assert.Contains(suite.T(), job.AudioPath, suite.helper.Config.UploadDir)
-	}
+	audioFile := suite.drop(suite.dropzonePath, originalFilename, "dummy audio content")
+	suite.settle(fakeClock, 1)
+
+	job := suite.waitForIngest(audioFile)
+	assert.Equal(suite.T(), originalFilename, *job.Title)
+	assert.Contains(suite.T(), job.AudioPath, filepath.Join("test_dropzone_data", "uploads"))
+	data, err := os.ReadFile(job.AudioPath)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "dummy audio content", string(data))
 }
 
 // Test service stop
@@ -336,61 +329,37 @@ func (suite *DropzoneTestSuite) TestServiceStop() {
 // Test concurrent file additions
 func (suite *DropzoneTestSuite) TestConcurrentFileAdditions() {
 	os.MkdirAll(suite.dropzonePath, 0755)
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	err := service.Start()
-	assert.NoError(suite.T(), err)
+
+	service, fakeClock := suite.startOnDropzone(0)
 	defer service.Stop()
-	
-	// Add multiple files quickly
+
+	// Files dropped together settle side by side
+	var files []string
 	for i := 0; i < 5; i++ {
-		audioFile := filepath.Join(suite.dropzonePath, "concurrent_"+string(rune(i))+"_test.mp3")
-		os.WriteFile(audioFile, []byte("dummy audio"), 0644)
-		time.Sleep(100 * time.Millisecond)
+		files = append(files, suite.drop(suite.dropzonePath, fmt.Sprintf("concurrent_%d_test.mp3", i), fmt.Sprintf("dummy audio %d", i)))
+	}
+	suite.settle(fakeClock, len(files))
+
+	for _, audioFile := range files {
+		suite.waitForIngest(audioFile)
 	}
-	
-	// Give time to process all
-	time.Sleep(2 * time.Second)
-	
-	// Check that jobs were created
-	var jobs []models.TranscriptionJob
-	suite.helper.DB.Find(&jobs)
-	
-	// At least some files should have been processed
-	assert.GreaterOrEqual(suite.T(), len(jobs), 1)
 }
 
 // Test case-insensitive file extension matching
 func (suite *DropzoneTestSuite) TestCaseInsensitiveExtensions() {
 	os.MkdirAll(suite.dropzonePath, 0755)
-	suite.helper.Config.UploadDir = filepath.Join("test_dropzone_data", "uploads")
-	
 	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
-	
-	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
-	err := service.Start()
-	assert.NoError(suite.T(), err)
+
+	service, fakeClock := suite.startOnDropzone(0)
 	defer service.Stop()
-	
-	// Test case variations
-	uppercaseFile := filepath.Join(suite.dropzonePath, "test.MP3")
-	mixedCaseFile := filepath.Join(suite.dropzonePath, "test.WaV")
-	
-	os.WriteFile(uppercaseFile, []byte("dummy audio"), 0644)
-	os.WriteFile(mixedCaseFile, []byte("dummy audio"), 0644)
-	
-	// Give time to process
-	time.Sleep(1500 * time.Millisecond)
-	
-	// Files should be processed regardless of case
-	_, err1 := os.Stat(uppercaseFile)
-	_, err2 := os.Stat(mixedCaseFile)
-	
-	// At least one should be removed (processed)
-	assert.True(suite.T(), os.IsNotExist(err1) || os.IsNotExist(err2))
+
+	uppercaseFile := suite.drop(suite.dropzonePath, "upper.MP3", "dummy audio")
+	mixedCaseFile := suite.drop(suite.dropzonePath, "mixed.WaV", "dummy audio")
+	suite.settle(fakeClock, 2)
+
+	suite.waitForIngest(uppercaseFile)
+	suite.waitForIngest(mixedCaseFile)
 }
 
 // Test multitrack files are not auto-transcribed
@@ -423,6 +392,1162 @@ func (suite *DropzoneTestSuite) TestMultiTrackNotAutoTranscribed() {
 	assert.Equal(suite.T(), models.StatusUploaded, job.Status)
 }
 
+// Test existing files are ingested from an in-memory filesystem with a fake clock
+func (suite *DropzoneTestSuite) TestProcessExistingFilesInMemory() {
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	assert.NoError(suite.T(), memFS.MkdirAll(filepath.Join(dropzonePath, "nested"), 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "memory_one.mp3"), []byte("audio one")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "nested", "memory_two.wav"), []byte("audio two")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "notes.txt"), []byte("not audio")))
+
+	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
+	fakeClock := clock.NewFake(time.Now())
+
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+
+	// Each audio file waits for its settle delay before being ingested
+	for i := 0; i < 2; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	assert.False(suite.T(), fsys.Exists(memFS, filepath.Join(dropzonePath, "memory_one.mp3")))
+	assert.False(suite.T(), fsys.Exists(memFS, filepath.Join(dropzonePath, "nested", "memory_two.wav")))
+	assert.True(suite.T(), fsys.Exists(memFS, filepath.Join(dropzonePath, "notes.txt")))
+
+	var job models.TranscriptionJob
+	err := suite.helper.DB.Where("title = ?", "memory_two.wav").First(&job).Error
+	if assert.NoError(suite.T(), err) {
+		data, err := fsys.ReadFile(memFS, job.AudioPath)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "audio two", string(data))
+	}
+}
+
+// Test a file still being written is only ingested once it stops changing
+func (suite *DropzoneTestSuite) TestWaitsForFileToSettle() {
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	sourcePath := filepath.Join(dropzonePath, "slow_copy.mp3")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, sourcePath, []byte("partial")))
+
+	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
+	cfg := *suite.helper.Config
+	cfg.DropzoneSettleSeconds = 1
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(&cfg, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+
+	// The copy grows during the first poll, which restarts the settle period
+	fakeClock.BlockUntil(1)
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, sourcePath, []byte("partial audio, now complete")))
+	fakeClock.Advance(500 * time.Millisecond)
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	fakeClock.BlockUntil(1)
+
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "slow_copy.mp3").Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+	assert.True(suite.T(), fsys.Exists(memFS, sourcePath))
+
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	var job models.TranscriptionJob
+	if assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "slow_copy.mp3").First(&job).Error) {
+		data, err := fsys.ReadFile(memFS, job.AudioPath)
+		assert.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "partial audio, now complete", string(data))
+	}
+}
+
+// Test DROPZONE_PATHS parsing
+func (suite *DropzoneTestSuite) TestParseRoots() {
+	roots, err := dropzone.ParseRoots("")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []dropzone.Root{{Path: dropzone.DefaultRoot}}, roots)
+
+	roots, err = dropzone.ParseRoots("/mnt/sales;user=alice;language=en, /mnt/legal/;auto_transcribe=false")
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), roots, 2) {
+		assert.Equal(suite.T(), "/mnt/sales", roots[0].Path)
+		assert.Equal(suite.T(), "alice", roots[0].User)
+		assert.Equal(suite.T(), "en", roots[0].Language)
+		assert.Nil(suite.T(), roots[0].AutoTranscribe)
+		assert.Equal(suite.T(), "/mnt/legal", roots[1].Path)
+		assert.Equal(suite.T(), boolPtr(false), roots[1].AutoTranscribe)
+	}
+
+	for _, spec := range []string{
+		"/mnt/sales;team=a",
+		"/mnt/sales;auto_transcribe=maybe",
+		"/mnt/sales;user",
+		"/mnt/sales,/mnt/sales/",
+		";user=alice",
+	} {
+		_, err := dropzone.ParseRoots(spec)
+		assert.Error(suite.T(), err, spec)
+	}
+}
+
+// Test each root applies its own user, language and auto-transcribe policy
+func (suite *DropzoneTestSuite) TestRootDefaults() {
+	memFS := fsys.NewMemFS()
+	salesPath := filepath.Join("data", "sales")
+	legalPath := filepath.Join("data", "legal")
+	assert.NoError(suite.T(), memFS.MkdirAll(salesPath, 0755))
+	assert.NoError(suite.T(), memFS.MkdirAll(legalPath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(salesPath, "sales_call.mp3"), []byte("sales audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(legalPath, "deposition.mp3"), []byte("legal audio")))
+	assert.NoError(suite.T(), suite.helper.DB.Model(suite.helper.TestUser).
+		Update("source_audio_action", models.SourceAudioDelete).Error)
+
+	suite.mockQueue.On("EnqueueJob", mock.Anything).Return(nil)
+	cfg := *suite.helper.Config
+	cfg.DropzonePaths = salesPath + ";user=testuser;language=de;auto_transcribe=true," + legalPath + ";auto_transcribe=false"
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(&cfg, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < 2; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	var sales models.TranscriptionJob
+	if assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "sales_call.mp3").First(&sales).Error) {
+		assert.Equal(suite.T(), models.StatusPending, sales.Status)
+		assert.Equal(suite.T(), stringPtr("de"), sales.Parameters.Language)
+		assert.Equal(suite.T(), stringPtr(models.SourceAudioDelete), sales.SourceAudioAction)
+		assert.Contains(suite.T(), suite.mockQueue.enqueuedJobs, sales.ID)
+	}
+
+	var legal models.TranscriptionJob
+	if assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "deposition.mp3").First(&legal).Error) {
+		assert.Equal(suite.T(), models.StatusUploaded, legal.Status)
+		assert.Nil(suite.T(), legal.Parameters.Language)
+		assert.Nil(suite.T(), legal.SourceAudioAction)
+	}
+}
+
+// Test an invalid DROPZONE_PATHS setting stops the service from starting
+func (suite *DropzoneTestSuite) TestInvalidRootsFailStart() {
+	cfg := *suite.helper.Config
+	cfg.DropzonePaths = "data/sales;colour=blue"
+	service := dropzone.NewService(&cfg, suite.mockQueue)
+	service.SetFS(fsys.NewMemFS())
+	assert.Error(suite.T(), service.Start())
+}
+
+// Test sidecar parsing in both formats
+func (suite *DropzoneTestSuite) TestParseSidecar() {
+	sidecar, err := dropzone.ParseSidecar("call.yaml", []byte("title: Weekly sync\nspeakers: 3\ntags: [team, weekly]\n"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), stringPtr("Weekly sync"), sidecar.Title)
+	assert.Equal(suite.T(), intPtr(3), sidecar.Speakers)
+	assert.Equal(suite.T(), []string{"team", "weekly"}, sidecar.Tags)
+
+	sidecar, err = dropzone.ParseSidecar("call.mp3.JSON", []byte(`{"language": "fr", "model": "large-v3"}`))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), stringPtr("fr"), sidecar.Language)
+	assert.Equal(suite.T(), stringPtr("large-v3"), sidecar.Model)
+
+	for name, data := range map[string]string{
+		"typo.json":     `{"titel": "x"}`,
+		"typo.yaml":     "langauge: en\n",
+		"broken.json":   `{"title":`,
+		"speakers.json": `{"speakers": 0}`,
+		"range.yml":     "min_speakers: 4\nmax_speakers: 2\n",
+		"notes.txt":     "title: x",
+	} {
+		_, err := dropzone.ParseSidecar(name, []byte(data))
+		assert.Error(suite.T(), err, name)
+	}
+}
+
+// Test a sidecar's settings are applied to the job and the sidecar is consumed
+func (suite *DropzoneTestSuite) TestSidecarMetadata() {
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "board_meeting.mp3"), []byte("board audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "board_meeting.mp3.json"),
+		[]byte(`{"title": "Board meeting", "language": "nl", "speakers": 4, "model": "large-v3", "tags": ["board", "q3"]}`)))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "interview.wav"), []byte("interview audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "interview.yaml"), []byte("title: Interview\n")))
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < 2; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	var job models.TranscriptionJob
+	if assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "Board meeting").First(&job).Error) {
+		assert.Equal(suite.T(), stringPtr("nl"), job.Parameters.Language)
+		assert.Equal(suite.T(), "large-v3", job.Parameters.Model)
+		assert.Equal(suite.T(), intPtr(4), job.Parameters.MinSpeakers)
+		assert.Equal(suite.T(), intPtr(4), job.Parameters.MaxSpeakers)
+		assert.True(suite.T(), job.Diarization)
+		assert.JSONEq(suite.T(), `["board", "q3"]`, *job.Tags)
+	}
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "Interview").Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+
+	entries, err := memFS.ReadDir(dropzonePath)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries)
+}
+
+// Test a sidecar that does not parse holds its audio back instead of ingesting it with the wrong settings
+func (suite *DropzoneTestSuite) TestInvalidSidecarHoldsAudio() {
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	audioPath := filepath.Join(dropzonePath, "held.mp3")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, audioPath, []byte("held audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "held.json"), []byte(`{"speakers": "two"}`)))
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	assert.True(suite.T(), fsys.Exists(memFS, audioPath))
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "held.mp3").Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+}
+
+// ingestOnce starts a dropzone service on memFS, lets its one waiting file
+// settle and returns the stop function
+func (suite *DropzoneTestSuite) ingestOnce(memFS *fsys.MemFS) func() error {
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.NoError(suite.T(), <-started)
+	return service.Stop
+}
+
+// existingJobWithHash creates a completed job whose audio hashes like content
+func (suite *DropzoneTestSuite) existingJobWithHash(title, content string) *models.TranscriptionJob {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), title)
+	assert.NoError(suite.T(), suite.helper.DB.Model(job).Updates(map[string]interface{}{
+		"status":     models.StatusCompleted,
+		"transcript": `{"text":"` + title + `"}`,
+		"audio_hash": *contentHash(content),
+	}).Error)
+	return job
+}
+
+// Test a file whose content was already ingested is discarded when dedupe skips
+func (suite *DropzoneTestSuite) TestDuplicateSkipped() {
+	stopEvents := jobstate.RecordEvents()
+	defer stopEvents()
+	suite.helper.Config.DropzoneDedupe = dropzone.DedupeSkip
+	defer func() { suite.helper.Config.DropzoneDedupe = dropzone.DedupeOff }()
+	original := suite.existingJobWithHash("Skipped original", "resynced audio")
+
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "resynced.mp3"), []byte("resynced audio")))
+	defer suite.ingestOnce(memFS)()
+
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("audio_hash = ?", *contentHash("resynced audio")).Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+	entries, err := memFS.ReadDir(dropzonePath)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries)
+	uploads, _ := memFS.ReadDir(suite.helper.Config.UploadDir)
+	assert.Empty(suite.T(), uploads)
+
+	events, err := jobstate.Timeline(original.ID)
+	assert.NoError(suite.T(), err)
+	if assert.Len(suite.T(), events, 1) {
+		assert.Equal(suite.T(), dropzone.EventDuplicateSkipped, events[0].Event)
+	}
+}
+
+// Test a linked duplicate gets its own job that reuses the existing transcript
+func (suite *DropzoneTestSuite) TestDuplicateLinked() {
+	suite.helper.Config.DropzoneDedupe = dropzone.DedupeLink
+	defer func() { suite.helper.Config.DropzoneDedupe = dropzone.DedupeOff }()
+	original := suite.existingJobWithHash("Linked original", "linked audio")
+
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "copy.mp3"), []byte("linked audio")))
+	defer suite.ingestOnce(memFS)()
+
+	var job models.TranscriptionJob
+	if assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "copy.mp3").First(&job).Error) {
+		assert.Equal(suite.T(), &original.ID, job.DuplicateOf)
+		assert.Equal(suite.T(), models.StatusCompleted, job.Status)
+		assert.Equal(suite.T(), stringPtr(`{"text":"Linked original"}`), job.Transcript)
+		assert.Equal(suite.T(), contentHash("linked audio"), job.AudioHash)
+	}
+	assert.NotContains(suite.T(), suite.mockQueue.enqueuedJobs, job.ID)
+}
+
+// Test an unknown dedupe mode stops the dropzone from starting
+func (suite *DropzoneTestSuite) TestInvalidDedupeFailsStart() {
+	suite.helper.Config.DropzoneDedupe = "sometimes"
+	defer func() { suite.helper.Config.DropzoneDedupe = dropzone.DedupeOff }()
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(fsys.NewMemFS())
+
+	err := service.Start()
+	assert.ErrorContains(suite.T(), err, "invalid DROPZONE_DEDUPE")
+}
+
+// contentHash returns the hex SHA-256 of content
+func contentHash(content string) *string {
+	sum := sha256.Sum256([]byte(content))
+	hash := hex.EncodeToString(sum[:])
+	return &hash
+}
+
+// Test an empty file is moved to quarantine with its sidecar and an explanation
+func (suite *DropzoneTestSuite) TestEmptyFileQuarantined() {
+	suite.helper.Config.DropzoneQuarantine = true
+	defer func() { suite.helper.Config.DropzoneQuarantine = false }()
+
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "silent.mp3"), nil))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "silent.mp3.json"), []byte(`{"title": "Silent"}`)))
+	defer suite.ingestOnce(memFS)()
+
+	quarantinePath := filepath.Join(dropzonePath, dropzone.QuarantineDir)
+	assert.True(suite.T(), fsys.Exists(memFS, filepath.Join(quarantinePath, "silent.mp3")))
+	assert.True(suite.T(), fsys.Exists(memFS, filepath.Join(quarantinePath, "silent.mp3.json")))
+	report, err := fsys.ReadFile(memFS, filepath.Join(quarantinePath, "silent.mp3.error"))
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), string(report), "error: file is empty")
+	assert.False(suite.T(), fsys.Exists(memFS, filepath.Join(dropzonePath, "silent.mp3")))
+
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title IN ?", []string{"silent.mp3", "Silent"}).Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+}
+
+// Test files ffprobe cannot read are quarantined while readable ones are ingested
+func (suite *DropzoneTestSuite) TestUnreadableFileQuarantined() {
+	dir := suite.T().TempDir()
+	root := filepath.Join(dir, "dropzone")
+	assert.NoError(suite.T(), os.MkdirAll(root, 0755))
+	assert.NoError(suite.T(), os.WriteFile(filepath.Join(root, "probe_broken.wav"), []byte("not audio"), 0644))
+	assert.NoError(suite.T(), os.WriteFile(filepath.Join(root, "probe_ok.wav"), []byte("fine audio"), 0644))
+	ffprobe := filepath.Join(dir, "ffprobe")
+	assert.NoError(suite.T(), os.WriteFile(ffprobe, []byte(`#!/bin/sh
+for arg; do file="$arg"; done
+case "$file" in
+*broken*) echo "$file: Invalid data found when processing input" >&2; exit 1 ;;
+*) echo pcm_s16le ;;
+esac
+`), 0755))
+
+	cfg := *suite.helper.Config
+	cfg.DropzonePaths = root + ";auto_transcribe=false"
+	cfg.DropzoneQuarantine = true
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(&cfg, suite.mockQueue)
+	service.SetClock(fakeClock)
+	service.SetFFprobePath(ffprobe)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < 2; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	report, err := os.ReadFile(filepath.Join(root, dropzone.QuarantineDir, "probe_broken.wav.error"))
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), string(report), "unreadable audio")
+	assert.Contains(suite.T(), string(report), "Invalid data found when processing input")
+
+	var job models.TranscriptionJob
+	assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "probe_ok.wav").First(&job).Error)
+	if job.AudioPath != "" {
+		os.Remove(job.AudioPath)
+	}
+	_, err = os.Stat(filepath.Join(root, "probe_ok.wav"))
+	assert.True(suite.T(), os.IsNotExist(err))
+}
+
+// recordingMultiTrackProcessor reports the jobs it is asked to merge
+type recordingMultiTrackProcessor struct {
+	jobs chan string
+}
+
+func (p *recordingMultiTrackProcessor) ProcessMultiTrackJob(ctx context.Context, jobID string) error {
+	p.jobs <- jobID
+	return nil
+}
+
+// zipArchive builds a zip file holding the given members
+func (suite *DropzoneTestSuite) zipArchive(members map[string]string) []byte {
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range members {
+		w, err := zw.Create(name)
+		assert.NoError(suite.T(), err)
+		_, err = w.Write([]byte(content))
+		assert.NoError(suite.T(), err)
+	}
+	assert.NoError(suite.T(), zw.Close())
+	return buf.Bytes()
+}
+
+// tarGzArchive builds a gzipped tar file holding the given members
+func (suite *DropzoneTestSuite) tarGzArchive(members map[string]string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	tw := tar.NewWriter(gz)
+	for name, content := range members {
+		assert.NoError(suite.T(), tw.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}))
+		_, err := tw.Write([]byte(content))
+		assert.NoError(suite.T(), err)
+	}
+	assert.NoError(suite.T(), tw.Close())
+	assert.NoError(suite.T(), gz.Close())
+	return buf.Bytes()
+}
+
+// Test a zip is split into a multi-track job for its project and single jobs for loose files
+func (suite *DropzoneTestSuite) TestArchiveIngest() {
+	project := `<?xml version="1.0"?>
+<project audacityversion="2.4.2" rate="44100">
+  <wavetrack name="Host" gain="1.0" pan="0.0"><waveclip offset="0.0"><import filename="C:\Recordings\archive_host.wav" channel="0"/></waveclip></wavetrack>
+  <wavetrack name="Guest" gain="1.0" pan="0.0"><waveclip offset="1.5"><import filename="archive_guest.wav" channel="0"/></waveclip></wavetrack>
+</project>`
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	archivePath := filepath.Join(dropzonePath, "episode.zip")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, archivePath, suite.zipArchive(map[string]string{
+		"session/Archive Episode.aup":  project,
+		"session/archive_host.wav":     "host audio",
+		"session/archive_guest.wav":    "guest audio",
+		"extra/archive_loose.mp3":      "loose audio",
+		"extra/archive_loose.mp3.json": `{"title": "Loose clip"}`,
+		"__MACOSX/extra/._archive.mp3": "resource fork",
+		"readme.txt":                   "notes",
+	})))
+
+	processor := &recordingMultiTrackProcessor{jobs: make(chan string, 1)}
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+	service.SetMultiTrackProcessor(processor)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	var job models.TranscriptionJob
+	if assert.NoError(suite.T(), suite.helper.DB.Preload("MultiTrackFiles", func(db *gorm.DB) *gorm.DB {
+		return db.Order("track_index")
+	}).Where("title = ?", "Archive Episode").First(&job).Error) {
+		assert.True(suite.T(), job.IsMultiTrack)
+		assert.Equal(suite.T(), "none", job.MergeStatus)
+		if assert.Len(suite.T(), job.MultiTrackFiles, 2) {
+			assert.Equal(suite.T(), "archive_host", job.MultiTrackFiles[0].FileName)
+			assert.Equal(suite.T(), "archive_guest", job.MultiTrackFiles[1].FileName)
+			data, err := fsys.ReadFile(memFS, job.MultiTrackFiles[1].FilePath)
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), "guest audio", string(data))
+		}
+		assert.True(suite.T(), fsys.Exists(memFS, *job.AupFilePath))
+		select {
+		case merged := <-processor.jobs:
+			assert.Equal(suite.T(), job.ID, merged)
+		case <-time.After(time.Second):
+			suite.T().Error("multi-track job was not merged")
+		}
+	}
+
+	var loose models.TranscriptionJob
+	assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "Loose clip").First(&loose).Error)
+	assert.False(suite.T(), loose.IsMultiTrack)
+
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title IN ?", []string{"archive_host.wav", "archive_guest.wav", "readme.txt"}).Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+	assert.False(suite.T(), fsys.Exists(memFS, archivePath))
+	staged, _ := memFS.ReadDir(suite.helper.Config.UploadDir)
+	for _, entry := range staged {
+		assert.False(suite.T(), strings.HasPrefix(entry.Name(), ".dropzone-"), entry.Name())
+	}
+}
+
+// Test retrying an archive that partly failed skips the members already ingested
+func (suite *DropzoneTestSuite) TestArchiveRetrySkipsIngestedMembers() {
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	archivePath := filepath.Join(dropzonePath, "partial.zip")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, archivePath, suite.zipArchive(map[string]string{
+		"retry_good.mp3":     "good audio",
+		"retry_bad.mp3":      "bad audio",
+		"retry_bad.mp3.json": "{not json",
+		"retry_other.mp3":    "other audio",
+	})))
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	countJobs := func(title string) int64 {
+		var count int64
+		suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", title).Count(&count)
+		return count
+	}
+	assert.Equal(suite.T(), int64(1), countJobs("retry_good.mp3"))
+	assert.Equal(suite.T(), int64(1), countJobs("retry_other.mp3"))
+	assert.Equal(suite.T(), int64(0), countJobs("retry_bad.mp3"))
+	assert.True(suite.T(), fsys.Exists(memFS, archivePath), "a partly failed archive is kept for another attempt")
+
+	swept := make(chan error, 1)
+	go func() { swept <- service.Sweep() }()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.NoError(suite.T(), <-swept)
+
+	assert.Equal(suite.T(), int64(1), countJobs("retry_good.mp3"))
+	assert.Equal(suite.T(), int64(1), countJobs("retry_other.mp3"))
+	var recorded []models.IngestedArchiveMember
+	assert.NoError(suite.T(), suite.helper.DB.Order("member").Find(&recorded).Error)
+	if assert.Len(suite.T(), recorded, 2) {
+		assert.Equal(suite.T(), "retry_good.mp3", recorded[0].Member)
+		assert.Equal(suite.T(), "retry_other.mp3", recorded[1].Member)
+	}
+}
+
+// Test a tar.gz is unpacked, and one that would write outside its folder is quarantined
+func (suite *DropzoneTestSuite) TestArchiveTarGzAndUnsafePaths() {
+	suite.helper.Config.DropzoneQuarantine = true
+	defer func() { suite.helper.Config.DropzoneQuarantine = false }()
+
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "tape.tar.gz"), suite.tarGzArchive(map[string]string{
+		"tape_side_a.mp3": "side a",
+	})))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "sneaky.tgz"), suite.tarGzArchive(map[string]string{
+		"../../escaped.mp3": "escaped",
+	})))
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < 2; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "tape_side_a.mp3").Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "escaped.mp3").Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+	assert.False(suite.T(), fsys.Exists(memFS, "escaped.mp3"))
+
+	report, err := fsys.ReadFile(memFS, filepath.Join(dropzonePath, dropzone.QuarantineDir, "sneaky.tgz.error"))
+	assert.NoError(suite.T(), err)
+	assert.Contains(suite.T(), string(report), "unsafe path in archive")
+}
+
+// Test a loose Audacity project waits for its tracks, then becomes one
+// multi-track job taking the tracks next to it and in its _data folder
+func (suite *DropzoneTestSuite) TestAudacityProjectIngest() {
+	project := `<?xml version="1.0"?>
+<project audacityversion="2.4.2" rate="44100">
+  <wavetrack name="Host" gain="1.0" pan="0.0"><waveclip offset="0.0"><import filename="/Users/me/loose_host.wav" channel="0"/></waveclip></wavetrack>
+  <wavetrack name="Guest" gain="1.0" pan="0.0"><waveclip offset="2.0"><import filename="loose_guest.wav" channel="0"/></waveclip></wavetrack>
+</project>`
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	projectPath := filepath.Join(dropzonePath, "Loose Episode.aup")
+	hostPath := filepath.Join(dropzonePath, "loose_host.wav")
+	guestPath := filepath.Join(dropzonePath, "Loose Episode_data", "loose_guest.wav")
+	assert.NoError(suite.T(), memFS.MkdirAll(filepath.Dir(guestPath), 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, projectPath, []byte(project)))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, hostPath, []byte("host audio")))
+
+	processor := &recordingMultiTrackProcessor{jobs: make(chan string, 1)}
+	start := func(settles int) *dropzone.Service {
+		fakeClock := clock.NewFake(time.Now())
+		service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+		service.SetFS(memFS)
+		service.SetClock(fakeClock)
+		service.SetMultiTrackProcessor(processor)
+
+		started := make(chan error, 1)
+		go func() { started <- service.Start() }()
+		for i := 0; i < settles; i++ {
+			fakeClock.BlockUntil(1)
+			fakeClock.Advance(500 * time.Millisecond)
+		}
+		assert.NoError(suite.T(), <-started)
+		return service
+	}
+
+	// The guest track is missing, so neither the project nor the host track
+	// is ingested yet
+	service := start(3)
+	service.Stop()
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title IN ?", []string{"Loose Episode", "loose_host.wav"}).Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+	assert.True(suite.T(), fsys.Exists(memFS, projectPath))
+	assert.True(suite.T(), fsys.Exists(memFS, hostPath))
+
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, guestPath, []byte("guest audio")))
+	service = start(3)
+	defer service.Stop()
+
+	var job models.TranscriptionJob
+	if assert.NoError(suite.T(), suite.helper.DB.Preload("MultiTrackFiles", func(db *gorm.DB) *gorm.DB {
+		return db.Order("track_index")
+	}).Where("title = ?", "Loose Episode").First(&job).Error) {
+		assert.True(suite.T(), job.IsMultiTrack)
+		if assert.Len(suite.T(), job.MultiTrackFiles, 2) {
+			assert.Equal(suite.T(), "loose_host", job.MultiTrackFiles[0].FileName)
+			assert.Equal(suite.T(), "loose_guest", job.MultiTrackFiles[1].FileName)
+			data, err := fsys.ReadFile(memFS, job.MultiTrackFiles[1].FilePath)
+			assert.NoError(suite.T(), err)
+			assert.Equal(suite.T(), "guest audio", string(data))
+		}
+		select {
+		case merged := <-processor.jobs:
+			assert.Equal(suite.T(), job.ID, merged)
+		case <-time.After(time.Second):
+			suite.T().Error("multi-track job was not merged")
+		}
+	}
+
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title IN ?", []string{"loose_host.wav", "loose_guest.wav"}).Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+	assert.False(suite.T(), fsys.Exists(memFS, projectPath))
+	assert.False(suite.T(), fsys.Exists(memFS, hostPath))
+	assert.False(suite.T(), fsys.Exists(memFS, filepath.Dir(guestPath)))
+}
+
+// Test a dropped Reaper project becomes one multi-track job with its tracks
+func (suite *DropzoneTestSuite) TestReaperProjectIngest() {
+	project := `<REAPER_PROJECT 0.1 "7.0/linux-x86_64" 1700000000
+  <TRACK
+    NAME Host
+    <ITEM
+      POSITION 0
+      <SOURCE WAVE
+        FILE "C:\Users\me\Session\Audio\rpp_host.wav"
+      >
+    >
+  >
+  <TRACK
+    NAME Guest
+    <ITEM
+      POSITION 1.5
+      <SOURCE WAVE
+        FILE "Audio/rpp_guest.wav"
+      >
+    >
+  >
+>`
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "Reaper Episode.rpp"), []byte(project)))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "rpp_host.wav"), []byte("host audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "rpp_guest.wav"), []byte("guest audio")))
+
+	processor := &recordingMultiTrackProcessor{jobs: make(chan string, 1)}
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+	service.SetMultiTrackProcessor(processor)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < 3; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	var job models.TranscriptionJob
+	if assert.NoError(suite.T(), suite.helper.DB.Preload("MultiTrackFiles", func(db *gorm.DB) *gorm.DB {
+		return db.Order("track_index")
+	}).Where("title = ?", "Reaper Episode").First(&job).Error) {
+		assert.True(suite.T(), job.IsMultiTrack)
+		assert.Equal(suite.T(), ".rpp", filepath.Ext(*job.AupFilePath))
+		if assert.Len(suite.T(), job.MultiTrackFiles, 2) {
+			assert.Equal(suite.T(), "rpp_host", job.MultiTrackFiles[0].FileName)
+			assert.Equal(suite.T(), "rpp_guest", job.MultiTrackFiles[1].FileName)
+		}
+		select {
+		case merged := <-processor.jobs:
+			assert.Equal(suite.T(), job.ID, merged)
+		case <-time.After(time.Second):
+			suite.T().Error("multi-track job was not merged")
+		}
+	}
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title IN ?", []string{"rpp_host.wav", "rpp_guest.wav"}).Count(&count)
+	assert.Equal(suite.T(), int64(0), count)
+}
+
+// Test a failed job insert leaves no files behind and keeps the source for a retry
+func (suite *DropzoneTestSuite) TestFailedIngestRollsBack() {
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	sourcePath := filepath.Join(dropzonePath, "rollback.mp3")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, sourcePath, []byte("audio")))
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	faults.Set(faults.DBLock, 1)
+	defer faults.Reset()
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	assert.True(suite.T(), fsys.Exists(memFS, sourcePath))
+	entries, err := memFS.ReadDir(suite.helper.Config.UploadDir)
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries)
+}
+
+// Test interrupted ingests are completed when their job was committed and discarded otherwise
+func (suite *DropzoneTestSuite) TestRecoverStagedIngests() {
+	memFS := fsys.NewMemFS()
+	uploadDir := suite.helper.Config.UploadDir
+	assert.NoError(suite.T(), memFS.MkdirAll(uploadDir, 0755))
+
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Interrupted ingest")
+	job.AudioPath = filepath.Join(uploadDir, job.ID+".mp3")
+	assert.NoError(suite.T(), suite.helper.DB.Model(&job).Update("audio_path", job.AudioPath).Error)
+
+	committed := filepath.Join(uploadDir, ".dropzone-"+job.ID+".mp3")
+	abandoned := filepath.Join(uploadDir, ".dropzone-no-such-job.wav")
+	unrelated := filepath.Join(uploadDir, "keep.mp3")
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, committed, []byte("committed audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, abandoned, []byte("abandoned audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, unrelated, []byte("other")))
+
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	assert.NoError(suite.T(), service.RecoverStaged())
+
+	data, err := fsys.ReadFile(memFS, job.AudioPath)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "committed audio", string(data))
+	assert.False(suite.T(), fsys.Exists(memFS, committed))
+	assert.False(suite.T(), fsys.Exists(memFS, abandoned))
+	assert.True(suite.T(), fsys.Exists(memFS, unrelated))
+}
+
+// Test ingest waits while the transcription backlog is at the high water mark
+func (suite *DropzoneTestSuite) TestQueueBackpressure() {
+	var pending int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("status = ?", models.StatusPending).Count(&pending)
+	backlog := suite.helper.CreateTestTranscriptionJob(suite.T(), "Backlog job")
+	assert.NoError(suite.T(), suite.helper.DB.Model(backlog).Update("status", models.StatusPending).Error)
+	suite.helper.Config.DropzoneQueueHighWater = int(pending) + 1
+	defer func() { suite.helper.Config.DropzoneQueueHighWater = 0 }()
+
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	filePath := filepath.Join(dropzonePath, "held_back.mp3")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filePath, []byte("held back audio")))
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	defer service.Stop()
+
+	// Settled, then held back by the full queue
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	fakeClock.BlockUntil(1)
+	assert.True(suite.T(), fsys.Exists(memFS, filePath))
+
+	// Once the backlog drains the file is ingested
+	assert.NoError(suite.T(), suite.helper.DB.Model(backlog).Update("status", models.StatusCompleted).Error)
+	fakeClock.Advance(5 * time.Second)
+	assert.NoError(suite.T(), <-started)
+	assert.False(suite.T(), fsys.Exists(memFS, filePath))
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "held_back.mp3").Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+}
+
+// Test archived files move to a dated processed folder, which is cleaned up
+// once older than DropzoneArchiveDays
+func (suite *DropzoneTestSuite) TestRetentionArchive() {
+	suite.helper.Config.DropzoneRetention = dropzone.RetentionArchive
+	suite.helper.Config.DropzoneArchiveDays = 7
+	defer func() {
+		suite.helper.Config.DropzoneRetention = dropzone.RetentionDelete
+		suite.helper.Config.DropzoneArchiveDays = 0
+	}()
+
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	processedPath := filepath.Join(dropzonePath, dropzone.ProcessedDir)
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "archived_take.mp3"), []byte("archived audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "archived_take.mp3.json"), []byte(`{"language": "en"}`)))
+	assert.NoError(suite.T(), memFS.MkdirAll(filepath.Join(processedPath, "2026-03-01"), 0755))
+	assert.NoError(suite.T(), memFS.MkdirAll(filepath.Join(processedPath, "2026-03-04"), 0755))
+	assert.NoError(suite.T(), memFS.MkdirAll(filepath.Join(processedPath, "keep me"), 0755))
+
+	fakeClock := clock.NewFake(time.Date(2026, 3, 11, 9, 30, 0, 0, time.Local))
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	day := filepath.Join(processedPath, "2026-03-11")
+	data, err := fsys.ReadFile(memFS, filepath.Join(day, "archived_take.mp3"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "archived audio", string(data))
+	assert.True(suite.T(), fsys.Exists(memFS, filepath.Join(day, "archived_take.mp3.json")))
+	assert.False(suite.T(), fsys.Exists(memFS, filepath.Join(dropzonePath, "archived_take.mp3")))
+
+	removed, err := service.CleanProcessed()
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, removed)
+	assert.False(suite.T(), fsys.Exists(memFS, filepath.Join(processedPath, "2026-03-01")))
+	assert.True(suite.T(), fsys.Exists(memFS, filepath.Join(processedPath, "2026-03-04")))
+	assert.True(suite.T(), fsys.Exists(memFS, filepath.Join(processedPath, "keep me")))
+	assert.True(suite.T(), fsys.Exists(memFS, day))
+}
+
+// Test kept files stay in place with a marker and are not ingested again
+func (suite *DropzoneTestSuite) TestRetentionKeep() {
+	suite.helper.Config.DropzoneRetention = dropzone.RetentionKeep
+	defer func() { suite.helper.Config.DropzoneRetention = dropzone.RetentionDelete }()
+
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	filePath := filepath.Join(dropzonePath, "kept_take.mp3")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filePath, []byte("kept audio")))
+
+	suite.ingestOnce(memFS)()
+	assert.True(suite.T(), fsys.Exists(memFS, filePath))
+	assert.True(suite.T(), fsys.Exists(memFS, filePath+".ingested"))
+
+	// A restart finds nothing new to ingest, so no settle wait is started
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(clock.NewFake(time.Now()))
+	assert.NoError(suite.T(), service.Start())
+	service.Stop()
+
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "kept_take.mp3").Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+}
+
+// Test an unknown retention fails Start
+func (suite *DropzoneTestSuite) TestInvalidRetentionFailsStart() {
+	suite.helper.Config.DropzoneRetention = "shred"
+	defer func() { suite.helper.Config.DropzoneRetention = dropzone.RetentionDelete }()
+
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(fsys.NewMemFS())
+	assert.ErrorContains(suite.T(), service.Start(), "DROPZONE_RETENTION")
+}
+
+// Test the status shows the job a file became and why another was held back
+func (suite *DropzoneTestSuite) TestIngestStatus() {
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	goodPath := filepath.Join(dropzonePath, "status_good.mp3")
+	heldPath := filepath.Join(dropzonePath, "status_held.mp3")
+	assert.NoError(suite.T(), memFS.MkdirAll(dropzonePath, 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, goodPath, []byte("good audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, heldPath, []byte("held audio")))
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, filepath.Join(dropzonePath, "status_held.json"), []byte(`{"speakers": "two"}`)))
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < 2; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	byPath := make(map[string]dropzone.FileStatus)
+	for _, file := range service.Status() {
+		byPath[file.Path] = file
+	}
+	assert.Len(suite.T(), byPath, 2)
+
+	var job models.TranscriptionJob
+	assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "status_good.mp3").First(&job).Error)
+	assert.Equal(suite.T(), dropzone.StateJobCreated, byPath[goodPath].State)
+	assert.Equal(suite.T(), job.ID, byPath[goodPath].JobID)
+	assert.Empty(suite.T(), byPath[goodPath].Error)
+
+	assert.Equal(suite.T(), dropzone.StateFailed, byPath[heldPath].State)
+	assert.Equal(suite.T(), "left in place to be retried", byPath[heldPath].Detail)
+	assert.Contains(suite.T(), byPath[heldPath].Error, "sidecar")
+	assert.Empty(suite.T(), byPath[heldPath].JobID)
+	assert.False(suite.T(), service.Paused())
+}
+
+// Test ignored names and files below the depth limit are left alone
+func (suite *DropzoneTestSuite) TestIgnoreAndDepth() {
+	suite.helper.Config.DropzoneIgnore = ".*,~*,*.part"
+	suite.helper.Config.DropzoneMaxDepth = 2
+	defer func() {
+		suite.helper.Config.DropzoneIgnore = ""
+		suite.helper.Config.DropzoneMaxDepth = 0
+	}()
+
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	files := map[string]bool{
+		"depth_root.mp3":                                 true,
+		filepath.Join("sub", "depth_sub.mp3"):            true,
+		filepath.Join("sub", "deeper", "depth_deep.mp3"): false,
+		"._depth_mac.mp3":                                false,
+		"~depth_office.wav":                              false,
+		filepath.Join(".stversions", "depth_old.mp3"):    false,
+	}
+	for name := range files {
+		path := filepath.Join(dropzonePath, name)
+		assert.NoError(suite.T(), memFS.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(suite.T(), fsys.WriteFile(memFS, path, []byte("audio of "+name)))
+	}
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < 2; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	for name, ingested := range files {
+		var count int64
+		suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", filepath.Base(name)).Count(&count)
+		if ingested {
+			assert.Equal(suite.T(), int64(1), count, name)
+		} else {
+			assert.Zero(suite.T(), count, name)
+			assert.True(suite.T(), fsys.Exists(memFS, filepath.Join(dropzonePath, name)), name)
+		}
+	}
+}
+
+// Test a malformed ignore pattern fails Start
+func (suite *DropzoneTestSuite) TestInvalidIgnoreFailsStart() {
+	suite.helper.Config.DropzoneIgnore = "*.part,[unclosed"
+	defer func() { suite.helper.Config.DropzoneIgnore = "" }()
+
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(fsys.NewMemFS())
+	assert.ErrorContains(suite.T(), service.Start(), "DROPZONE_IGNORE")
+}
+
+// Test files get the settings of the folder they were dropped into
+func (suite *DropzoneTestSuite) TestFolderProfiles() {
+	profile := models.TranscriptionProfile{Name: "Interview profile", Parameters: models.WhisperXParams{Model: "large-v3", Diarize: true}}
+	assert.NoError(suite.T(), suite.helper.DB.Create(&profile).Error)
+	defer suite.helper.DB.Delete(&profile)
+
+	memFS := fsys.NewMemFS()
+	assert.NoError(suite.T(), fsys.WriteFile(memFS, "profiles.yaml", []byte(`
+interviews/:
+  profile: Interview profile
+  language: fr
+voicemail:
+  model: tiny
+  source_audio_action: delete
+  auto_transcribe: false
+`)))
+	suite.helper.Config.DropzoneProfiles = "profiles.yaml"
+	defer func() { suite.helper.Config.DropzoneProfiles = "" }()
+
+	dropzonePath := filepath.Join("data", "dropzone")
+	for _, name := range []string{
+		filepath.Join("interviews", "2024", "folder_interview.mp3"),
+		filepath.Join("voicemail", "folder_voicemail.mp3"),
+		"folder_plain.mp3",
+	} {
+		path := filepath.Join(dropzonePath, name)
+		assert.NoError(suite.T(), memFS.MkdirAll(filepath.Dir(path), 0755))
+		assert.NoError(suite.T(), fsys.WriteFile(memFS, path, []byte("audio of "+name)))
+	}
+
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(suite.helper.Config, suite.mockQueue)
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	for i := 0; i < 3; i++ {
+		fakeClock.BlockUntil(1)
+		fakeClock.Advance(500 * time.Millisecond)
+	}
+	assert.NoError(suite.T(), <-started)
+	defer service.Stop()
+
+	var interview, voicemail, plain models.TranscriptionJob
+	assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "folder_interview.mp3").First(&interview).Error)
+	assert.Equal(suite.T(), "large-v3", interview.Parameters.Model)
+	assert.True(suite.T(), interview.Diarization)
+	assert.Equal(suite.T(), stringPtr("fr"), interview.Parameters.Language)
+
+	assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "folder_voicemail.mp3").First(&voicemail).Error)
+	assert.Equal(suite.T(), "tiny", voicemail.Parameters.Model)
+	assert.Equal(suite.T(), stringPtr(models.SourceAudioDelete), voicemail.SourceAudioAction)
+	assert.Equal(suite.T(), models.StatusUploaded, voicemail.Status)
+
+	assert.NoError(suite.T(), suite.helper.DB.Where("title = ?", "folder_plain.mp3").First(&plain).Error)
+	assert.False(suite.T(), plain.Diarization)
+	assert.Nil(suite.T(), plain.SourceAudioAction)
+}
+
+// Test profiles files that could not be applied are rejected
+func (suite *DropzoneTestSuite) TestParseFolderProfiles() {
+	profiles, err := dropzone.ParseFolderProfiles("profiles.json", []byte(`{"calls/sales": {"speakers": 2}}`))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), intPtr(2), profiles[filepath.Join("calls", "sales")].Speakers)
+
+	for name, data := range map[string]string{
+		"typo.yaml":     "interviews:\n  langauge: fr\n",
+		"action.yaml":   "voicemail:\n  source_audio_action: shred\n",
+		"escape.json":   `{"../elsewhere": {"model": "tiny"}}`,
+		"speakers.json": `{"calls": {"min_speakers": 3, "max_speakers": 2}}`,
+		"profiles.toml": `calls = {}`,
+		"schedule.yaml": "archive:\n  schedule: tonight\n",
+	} {
+		_, err := dropzone.ParseFolderProfiles(name, []byte(data))
+		assert.Error(suite.T(), err, name)
+	}
+}
+
+// Test a folder's schedule holds its auto-transcribed jobs until the next match
+func (suite *DropzoneTestSuite) TestFolderScheduleRunAfter() {
+	profiles, err := dropzone.ParseFolderProfiles("profiles.yaml", []byte("archive:\n  schedule: \"0 1 * * *\"\n"))
+	suite.Require().NoError(err)
+	now := time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC)
+	runAfter := profiles["archive"].RunAfter(now)
+	suite.Require().NotNil(runAfter)
+	assert.Equal(suite.T(), time.Date(2024, 3, 2, 1, 0, 0, 0, time.UTC), *runAfter)
+
+	var none *dropzone.FolderProfile
+	assert.Nil(suite.T(), none.RunAfter(now))
+	assert.Nil(suite.T(), (&dropzone.FolderProfile{}).RunAfter(now))
+}
+
 func TestDropzoneTestSuite(t *testing.T) {
 	suite.Run(t, new(DropzoneTestSuite))
 }
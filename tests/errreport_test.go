@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"synthezia/internal/errreport"
+	"synthezia/pkg/logger"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// reportedEvent is the subset of the Sentry event payload the tests inspect
+type reportedEvent struct {
+	EventID     string `json:"event_id"`
+	Level       string `json:"level"`
+	Message     string `json:"message"`
+	Logger      string `json:"logger"`
+	Environment string `json:"environment"`
+	Release     string `json:"release"`
+	Exception   []struct {
+		Type       string `json:"type"`
+		Value      string `json:"value"`
+		Stacktrace struct {
+			Frames []struct {
+				Function string `json:"function"`
+				Module   string `json:"module"`
+				Lineno   int    `json:"lineno"`
+				InApp    bool   `json:"in_app"`
+			} `json:"frames"`
+		} `json:"stacktrace"`
+		Mechanism *struct {
+			Type    string `json:"type"`
+			Handled bool   `json:"handled"`
+		} `json:"mechanism"`
+	} `json:"exception"`
+	Request *struct {
+		Method      string            `json:"method"`
+		URL         string            `json:"url"`
+		QueryString string            `json:"query_string"`
+		Headers     map[string]string `json:"headers"`
+	} `json:"request"`
+	User *struct {
+		ID string `json:"id"`
+	} `json:"user"`
+	Tags  map[string]string `json:"tags"`
+	Extra map[string]string `json:"extra"`
+}
+
+type ErrorReportTestSuite struct {
+	suite.Suite
+	server *httptest.Server
+	mu     sync.Mutex
+	events []reportedEvent
+	paths  []string
+	auth   []string
+}
+
+func (suite *ErrorReportTestSuite) SetupTest() {
+	logger.Init("info")
+	suite.events = nil
+	suite.paths = nil
+	suite.auth = nil
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var event reportedEvent
+		json.Unmarshal(body, &event)
+
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+		suite.events = append(suite.events, event)
+		suite.paths = append(suite.paths, r.URL.Path)
+		suite.auth = append(suite.auth, r.Header.Get("X-Sentry-Auth"))
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	dsn := strings.Replace(suite.server.URL, "http://", "http://publickey@", 1) + "/42"
+	err := errreport.Init(errreport.Config{DSN: dsn, Environment: "test", Release: "synthezia@test"})
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *ErrorReportTestSuite) TearDownTest() {
+	errreport.Shutdown(context.Background())
+	suite.server.Close()
+}
+
+// flush sends every queued event and returns what the server received
+func (suite *ErrorReportTestSuite) flush() []reportedEvent {
+	errreport.Flush()
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	return suite.events
+}
+
+func (suite *ErrorReportTestSuite) TestInvalidDSN() {
+	for _, dsn := range []string{"not a url", "ftp://key@host/1", "https://sentry.example.com/1", "https://key@sentry.example.com"} {
+		assert.Error(suite.T(), errreport.Init(errreport.Config{DSN: dsn}), dsn)
+	}
+	// A failed Init keeps the working configuration
+	assert.True(suite.T(), errreport.Enabled())
+}
+
+func (suite *ErrorReportTestSuite) TestDisabledWithoutDSN() {
+	errreport.Shutdown(context.Background())
+	assert.NoError(suite.T(), errreport.Init(errreport.Config{}))
+	assert.False(suite.T(), errreport.Enabled())
+
+	logger.Error("Nothing listens", "error", "boom")
+	assert.Empty(suite.T(), suite.flush())
+}
+
+func (suite *ErrorReportTestSuite) TestLoggedErrorIsReported() {
+	ctx := logger.WithJobID(context.Background(), "job-9")
+	logger.Module(logger.ModuleQueue).ErrorContext(ctx, "Transcription failed", "error", "model crashed", "attempt", 2)
+	logger.Warn("Warnings are not reported")
+
+	events := suite.flush()
+	if !assert.Len(suite.T(), events, 1) {
+		return
+	}
+	event := events[0]
+	assert.Len(suite.T(), event.EventID, 32)
+	assert.Equal(suite.T(), "error", event.Level)
+	assert.Equal(suite.T(), "Transcription failed", event.Message)
+	assert.Equal(suite.T(), "queue", event.Logger)
+	assert.Equal(suite.T(), "test", event.Environment)
+	assert.Equal(suite.T(), "synthezia@test", event.Release)
+	assert.Equal(suite.T(), "job-9", event.Tags["job_id"])
+	assert.Equal(suite.T(), "queue", event.Tags["module"])
+	assert.Equal(suite.T(), "2", event.Extra["attempt"])
+	assert.Nil(suite.T(), event.Request)
+
+	if assert.Len(suite.T(), event.Exception, 1) {
+		assert.Equal(suite.T(), "Transcription failed: model crashed", event.Exception[0].Value)
+		frames := event.Exception[0].Stacktrace.Frames
+		if assert.NotEmpty(suite.T(), frames) {
+			// Frames run outermost first and end at the logging call
+			last := frames[len(frames)-1]
+			assert.Equal(suite.T(), "synthezia/tests", last.Module)
+			assert.Contains(suite.T(), last.Function, "TestLoggedErrorIsReported")
+			assert.True(suite.T(), last.InApp)
+		}
+	}
+
+	assert.Equal(suite.T(), []string{"/api/42/store/"}, suite.paths)
+	assert.Contains(suite.T(), suite.auth[0], "sentry_key=publickey")
+}
+
+func (suite *ErrorReportTestSuite) TestPanicIsRecoveredAndReported() {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(errreport.GinRecovery())
+	router.Use(func(c *gin.Context) {
+		c.Set("user_id", uint(7))
+		c.Next()
+	})
+	router.GET("/boom", func(c *gin.Context) {
+		panic(errors.New("nil transcript"))
+	})
+	router.GET("/logged", func(c *gin.Context) {
+		logger.ErrorContext(c.Request.Context(), "Failed to load job", "error", "record not found")
+		c.Status(http.StatusNotFound)
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/boom?verbose=1", nil)
+	req.Header.Set("Authorization", "Bearer secret")
+	req.Header.Set("User-Agent", "errreport-test")
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusInternalServerError, w.Code)
+	assert.Contains(suite.T(), w.Body.String(), "Internal server error")
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/logged", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+
+	// The panic is reported once, not again through the ERROR log line
+	events := suite.flush()
+	if !assert.Len(suite.T(), events, 2) {
+		return
+	}
+
+	panicEvent := events[0]
+	assert.Equal(suite.T(), "fatal", panicEvent.Level)
+	assert.Equal(suite.T(), "nil transcript", panicEvent.Message)
+	if assert.Len(suite.T(), panicEvent.Exception, 1) {
+		exception := panicEvent.Exception[0]
+		assert.Equal(suite.T(), "*errors.errorString", exception.Type)
+		if assert.NotNil(suite.T(), exception.Mechanism) {
+			assert.Equal(suite.T(), "panic", exception.Mechanism.Type)
+			assert.False(suite.T(), exception.Mechanism.Handled)
+		}
+		frames := exception.Stacktrace.Frames
+		if assert.NotEmpty(suite.T(), frames) {
+			assert.Contains(suite.T(), frames[len(frames)-1].Function, "TestPanicIsRecoveredAndReported")
+		}
+	}
+	if assert.NotNil(suite.T(), panicEvent.Request) {
+		assert.Equal(suite.T(), "GET", panicEvent.Request.Method)
+		assert.Equal(suite.T(), "http:///boom", panicEvent.Request.URL)
+		assert.Equal(suite.T(), "verbose=1", panicEvent.Request.QueryString)
+		assert.Equal(suite.T(), "errreport-test", panicEvent.Request.Headers["User-Agent"])
+		assert.NotContains(suite.T(), panicEvent.Request.Headers, "Authorization")
+	}
+	if assert.NotNil(suite.T(), panicEvent.User) {
+		assert.Equal(suite.T(), "7", panicEvent.User.ID)
+	}
+
+	loggedEvent := events[1]
+	assert.Equal(suite.T(), "Failed to load job", loggedEvent.Message)
+	if assert.NotNil(suite.T(), loggedEvent.Request) {
+		assert.Equal(suite.T(), "http:///logged", loggedEvent.Request.URL)
+	}
+}
+
+func TestErrorReportTestSuite(t *testing.T) {
+	suite.Run(t, new(ErrorReportTestSuite))
+}
@@ -0,0 +1,169 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/eta"
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+	"synthezia/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type ETATestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	now     time.Time
+	service *eta.Service
+}
+
+func (suite *ETATestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "eta_test.db")
+	suite.now = time.Now().UTC().Truncate(time.Second)
+	suite.service = eta.NewService(suite.helper.DB)
+	suite.service.SetClock(clock.NewFake(suite.now))
+}
+
+func (suite *ETATestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// createJob creates a job of the given audio length, in seconds; 0 leaves
+// the length unknown
+func (suite *ETATestSuite) createJob(id string, status models.JobStatus, model, device string, audio float64, createdAt time.Time) *models.TranscriptionJob {
+	job := &models.TranscriptionJob{ID: id, AudioPath: id + ".mp3", Status: status, CreatedAt: createdAt}
+	job.Parameters.Model = model
+	job.Parameters.Device = device
+	if audio > 0 {
+		job.AudioDuration = &audio
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	return job
+}
+
+// createExecution records a run of a job that started at startedAt and,
+// unless took is 0, completed after took
+func (suite *ETATestSuite) createExecution(job *models.TranscriptionJob, startedAt time.Time, took time.Duration) {
+	execution := &models.TranscriptionJobExecution{TranscriptionJobID: job.ID, StartedAt: startedAt, ActualParameters: job.Parameters, Status: models.StatusProcessing}
+	if took > 0 {
+		completedAt := startedAt.Add(took)
+		execution.CompletedAt = &completedAt
+		execution.Status = models.StatusCompleted
+		execution.CalculateProcessingDuration()
+	}
+	suite.Require().NoError(suite.helper.DB.Create(execution).Error)
+}
+
+// createHistory completes jobs at realtime factors of 0.5 for small on the
+// CPU and 0.1 for small on CUDA, plus one too old to count
+func (suite *ETATestSuite) createHistory() {
+	done := suite.now.Add(-24 * time.Hour)
+	suite.createExecution(suite.createJob("done-cpu", models.StatusCompleted, "small", "cpu", 100, done), done, 50*time.Second)
+	suite.createExecution(suite.createJob("done-cuda", models.StatusCompleted, "small", "cuda", 100, done), done, 10*time.Second)
+	old := suite.now.Add(-60 * 24 * time.Hour)
+	suite.createExecution(suite.createJob("done-old", models.StatusCompleted, "small", "cpu", 10, old), old, time.Hour)
+}
+
+func (suite *ETATestSuite) TestSpeeds() {
+	speeds, err := suite.service.Speeds()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), eta.Speed{RealtimeFactor: eta.DefaultRealtimeFactor, Basis: eta.BasisDefault}, speeds.For("small", "cpu"))
+
+	suite.createHistory()
+	speeds, err = suite.service.Speeds()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), eta.Speed{RealtimeFactor: 0.5, Basis: eta.BasisModelDevice, Samples: 1}, speeds.For("small", "cpu"))
+	assert.Equal(suite.T(), eta.Speed{RealtimeFactor: 0.1, Basis: eta.BasisModelDevice, Samples: 1}, speeds.For("small", "cuda"))
+	assert.Equal(suite.T(), eta.Speed{RealtimeFactor: 0.3, Basis: eta.BasisModel, Samples: 2}, speeds.For("small", "mps"))
+	assert.Equal(suite.T(), eta.Speed{RealtimeFactor: 0.3, Basis: eta.BasisAll, Samples: 2}, speeds.For("large-v3", "cpu"))
+}
+
+func (suite *ETATestSuite) TestEstimate() {
+	suite.createHistory()
+	created := suite.now.Add(-time.Hour)
+	running := suite.createJob("running", models.StatusProcessing, "small", "cuda", 200, created)
+	suite.createExecution(running, suite.now.Add(-5*time.Second), 0)
+	suite.createJob("first", models.StatusPending, "small", "cpu", 60, created.Add(time.Minute))
+	suite.createJob("second", models.StatusPending, "large-v3", "cpu", 100, created.Add(2*time.Minute))
+	suite.createJob("unknown", models.StatusPending, "small", "cpu", 0, created.Add(3*time.Minute))
+	scheduled := suite.createJob("scheduled", models.StatusPending, "small", "cuda", 100, created.Add(4*time.Minute))
+	runAfter := suite.now.Add(time.Hour)
+	suite.Require().NoError(suite.helper.DB.Model(scheduled).Update("run_after", runAfter).Error)
+	suite.createJob("last", models.StatusPending, "small", "cpu", 20, created.Add(5*time.Minute))
+	suite.createJob("track_last_0", models.StatusPending, "small", "cpu", 1000, created.Add(6*time.Minute))
+
+	ids := []string{"running", "first", "second", "unknown", "scheduled", "last", "done-cpu"}
+	estimates, err := suite.service.Estimate(ids, 1)
+	suite.Require().NoError(err)
+	assert.Len(suite.T(), estimates, 5)
+	assert.NotContains(suite.T(), estimates, "unknown")
+	assert.NotContains(suite.T(), estimates, "done-cpu")
+
+	at := func(seconds int) time.Time { return suite.now.Add(time.Duration(seconds) * time.Second) }
+	assert.True(suite.T(), at(15).Equal(estimates["running"].CompletesAt), estimates["running"].CompletesAt)
+	assert.Nil(suite.T(), estimates["running"].StartsAt)
+	assert.Equal(suite.T(), 15.0, estimates["running"].RemainingSeconds)
+
+	// One worker takes the queued jobs in turn; the scheduled one waits for
+	// its time without holding up the job after it
+	expected := map[string][3]int{"first": {15, 45, 1}, "second": {45, 75, 2}, "scheduled": {3600, 3610, 3}, "last": {75, 85, 3}}
+	for id, want := range expected {
+		estimate := estimates[id]
+		suite.Require().NotNil(estimate.StartsAt, id)
+		assert.True(suite.T(), at(want[0]).Equal(*estimate.StartsAt), "%s starts at %s", id, estimate.StartsAt)
+		assert.True(suite.T(), at(want[1]).Equal(estimate.CompletesAt), "%s completes at %s", id, estimate.CompletesAt)
+		assert.Equal(suite.T(), want[2], estimate.JobsAhead, id)
+	}
+	assert.Equal(suite.T(), eta.BasisAll, estimates["second"].Speed.Basis)
+
+	// A second worker takes the first job straight away
+	estimates, err = suite.service.Estimate([]string{"first", "second"}, 2)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), suite.now.Equal(*estimates["first"].StartsAt))
+	assert.True(suite.T(), at(30).Equal(estimates["first"].CompletesAt))
+	assert.True(suite.T(), at(15).Equal(*estimates["second"].StartsAt))
+}
+
+func (suite *ETATestSuite) TestStatusAndListResponses() {
+	suite.createHistory()
+	suite.createJob("waiting", models.StatusPending, "small", "cpu", 60, suite.now)
+
+	tq := queue.NewTaskQueue(1, nil)
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, tq, nil, nil, nil)
+	router := api.SetupRoutes(handler, suite.helper.AuthService)
+	get := func(path string) []byte {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+		w := httptest.NewRecorder()
+		router.ServeHTTP(w, req)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		return w.Body.Bytes()
+	}
+
+	var status api.JobWithETA
+	suite.Require().NoError(json.Unmarshal(get("/api/v1/transcription/waiting/status"), &status))
+	assert.Equal(suite.T(), "waiting", status.ID)
+	suite.Require().NotNil(status.ETA)
+	assert.Equal(suite.T(), 0.5, status.ETA.Speed.RealtimeFactor)
+
+	var list struct {
+		Jobs []api.JobWithETA `json:"jobs"`
+	}
+	suite.Require().NoError(json.Unmarshal(get("/api/v1/transcription/list?limit=100"), &list))
+	etas := map[string]bool{}
+	for _, job := range list.Jobs {
+		etas[job.ID] = job.ETA != nil
+	}
+	assert.Equal(suite.T(), map[string]bool{"waiting": true, "done-cpu": false, "done-cuda": false, "done-old": false}, etas)
+}
+
+func TestETATestSuite(t *testing.T) {
+	suite.Run(t, new(ETATestSuite))
+}
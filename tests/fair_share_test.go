@@ -0,0 +1,168 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// orderProcessor records the order jobs run in, holding the first until
+// release is closed so the rest queue up behind it
+type orderProcessor struct {
+	release chan struct{}
+	mu      sync.Mutex
+	order   []string
+}
+
+func (p *orderProcessor) ProcessJob(ctx context.Context, jobID string) error {
+	return p.ProcessJobWithProcess(ctx, jobID, nil)
+}
+
+func (p *orderProcessor) ProcessJobWithProcess(ctx context.Context, jobID string, registerProcess func(*exec.Cmd)) error {
+	p.mu.Lock()
+	p.order = append(p.order, jobID)
+	first := len(p.order) == 1
+	p.mu.Unlock()
+	if first {
+		<-p.release
+	}
+	return nil
+}
+
+func (p *orderProcessor) ran() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.order...)
+}
+
+type FairShareTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+	users  map[string]*uint
+}
+
+func (suite *FairShareTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "fair_share_test.db")
+	suite.users = map[string]*uint{}
+	for _, name := range []string{"alice", "bob", "carol"} {
+		user := &models.User{Username: name, Password: "secret"}
+		suite.Require().NoError(suite.helper.DB.Create(user).Error)
+		suite.users[name] = &user.ID
+	}
+}
+
+func (suite *FairShareTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *FairShareTestSuite) createJob(id, owner string) {
+	job := &models.TranscriptionJob{ID: id, AudioPath: id + ".mp3", Status: models.StatusPending}
+	if owner != "" {
+		job.UserID = suite.users[owner]
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+}
+
+// runInOrder queues one unowned job to hold the only worker, then the
+// given jobs, and returns the order the queue ran them in
+func (suite *FairShareTestSuite) runInOrder(fairShare bool, jobs []string) []string {
+	processor := &orderProcessor{release: make(chan struct{})}
+	tq := queue.NewTaskQueue(1, processor)
+	tq.SetFairShare(fairShare, map[string]int{"alice": 2})
+	tq.Start()
+	defer tq.Stop()
+
+	suite.createJob("blocker", "")
+	suite.Require().NoError(tq.EnqueueJob("blocker"))
+	assert.Eventually(suite.T(), func() bool { return len(processor.ran()) == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	for _, id := range jobs {
+		suite.Require().NoError(tq.EnqueueJob(id))
+	}
+	// Queued twice, run once
+	suite.Require().NoError(tq.EnqueueJob(jobs[0]))
+	suite.waitUntilTakenIn(tq, len(jobs))
+	close(processor.release)
+
+	assert.Eventually(suite.T(), func() bool { return len(processor.ran()) == len(jobs)+1 }, 2*time.Second, 5*time.Millisecond)
+	return processor.ran()[1:]
+}
+
+// waitUntilTakenIn waits until the dispatcher has moved every enqueued job
+// into the fair queue, dropping repeats, and count jobs wait there
+func (suite *FairShareTestSuite) waitUntilTakenIn(tq *queue.TaskQueue, count int) {
+	assert.Eventually(suite.T(), func() bool {
+		snapshot := tq.Snapshot()
+		waiting := 0
+		for _, depth := range snapshot.ByPriority {
+			waiting += depth.Jobs
+		}
+		return waiting == count && snapshot.Depth == count
+	}, 2*time.Second, 5*time.Millisecond)
+}
+
+func (suite *FairShareTestSuite) createBatches() []string {
+	owners := map[string]string{"a": "alice", "b": "bob", "c": "carol", "u": ""}
+	ids := []string{"a1", "a2", "a3", "a4", "a5", "a6", "b1", "b2", "b3", "c1", "u1"}
+	for _, id := range ids {
+		suite.createJob(id, owners[id[:1]])
+	}
+	return ids
+}
+
+func (suite *FairShareTestSuite) TestTurnsByWeight() {
+	ids := suite.createBatches()
+	// Carol's one job does not wait behind the batches, and alice, who
+	// weighs 2, gets two turns for each of bob's
+	assert.Equal(suite.T(), []string{"a1", "b1", "c1", "a2", "a3", "b2", "u1", "a4", "a5", "b3", "a6"}, suite.runInOrder(true, ids))
+}
+
+func (suite *FairShareTestSuite) TestFirstComeFirstServed() {
+	ids := suite.createBatches()
+	assert.Equal(suite.T(), ids, suite.runInOrder(false, ids))
+}
+
+// Test the jobs the dispatcher took in still count against the queue's capacity
+func (suite *FairShareTestSuite) TestCapacity() {
+	processor := &orderProcessor{release: make(chan struct{})}
+	tq := queue.NewTaskQueue(1, processor)
+	tq.SetFairShare(true, nil)
+	tq.Start()
+	defer tq.Stop()
+	defer close(processor.release)
+
+	suite.createJob("blocker", "")
+	suite.Require().NoError(tq.EnqueueJob("blocker"))
+	assert.Eventually(suite.T(), func() bool { return len(processor.ran()) == 1 }, 2*time.Second, 5*time.Millisecond)
+
+	capacity := tq.Snapshot().Capacity
+	for i := 0; i < capacity; i++ {
+		suite.Require().NoError(tq.EnqueueJob(fmt.Sprintf("waiting%d", i)))
+	}
+	suite.waitUntilTakenIn(tq, capacity)
+	assert.EqualError(suite.T(), tq.EnqueueJob("overflow"), "queue is full")
+	assert.Equal(suite.T(), capacity, tq.Snapshot().Depth)
+}
+
+func (suite *FairShareTestSuite) TestParseUserWeights() {
+	weights, err := queue.ParseUserWeights(" alice=3, bob = 2 ,")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), map[string]int{"alice": 3, "bob": 2}, weights)
+	for _, value := range []string{"alice", "alice=0", "=2", "alice=many"} {
+		_, err := queue.ParseUserWeights(value)
+		assert.Error(suite.T(), err, value)
+	}
+}
+
+func TestFairShareTestSuite(t *testing.T) {
+	suite.Run(t, new(FairShareTestSuite))
+}
@@ -0,0 +1,98 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"synthezia/internal/transcription/adapters"
+	"synthezia/internal/transcription/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type FakeBackendTestSuite struct {
+	suite.Suite
+	adapter *adapters.FakeAdapter
+	dir     string
+}
+
+func (suite *FakeBackendTestSuite) SetupTest() {
+	suite.adapter = adapters.NewFakeAdapter()
+	suite.dir = suite.T().TempDir()
+}
+
+func (suite *FakeBackendTestSuite) writeAudio(name string, content []byte) string {
+	path := filepath.Join(suite.dir, name)
+	require.NoError(suite.T(), os.WriteFile(path, content, 0644))
+	return path
+}
+
+func (suite *FakeBackendTestSuite) transcribe(path string, params map[string]interface{}) *interfaces.TranscriptResult {
+	result, err := suite.adapter.Transcribe(context.Background(), interfaces.AudioInput{FilePath: path}, params, interfaces.ProcessingContext{JobID: "fake-job", Metadata: map[string]string{}})
+	require.NoError(suite.T(), err)
+	return result
+}
+
+// Test transcripts are derived from the filename
+func (suite *FakeBackendTestSuite) TestTranscriptFromFilename() {
+	path := suite.writeAudio("weekly_team-sync.mp3", []byte("not really audio"))
+
+	first := suite.transcribe(path, map[string]interface{}{})
+	second := suite.transcribe(path, map[string]interface{}{})
+
+	assert.Equal(suite.T(), "weekly team sync", first.Text)
+	assert.Equal(suite.T(), first.Segments, second.Segments)
+	assert.Equal(suite.T(), "filename", first.Metadata["fake_source"])
+	assert.Len(suite.T(), first.WordSegments, 3)
+}
+
+// Test sidecar text takes precedence
+func (suite *FakeBackendTestSuite) TestTranscriptFromSidecar() {
+	path := suite.writeAudio("meeting.wav", []byte("RIFF"))
+	suite.writeAudio("meeting.txt", []byte("hello from the sidecar file"))
+
+	result := suite.transcribe(path, map[string]interface{}{})
+	assert.Equal(suite.T(), "hello from the sidecar file", result.Text)
+	assert.Equal(suite.T(), "sidecar", result.Metadata["fake_source"])
+}
+
+// Test embedded marker text and diarization labels
+func (suite *FakeBackendTestSuite) TestEmbeddedTranscriptWithDiarization() {
+	text := "one two three four five six seven eight nine ten eleven twelve thirteen"
+	path := suite.writeAudio("clip.mp3", []byte("ID3\x00\x00"+adapters.FakeTranscriptMarker+text+"\x00trailing"))
+
+	result := suite.transcribe(path, map[string]interface{}{"diarize": true})
+	assert.Equal(suite.T(), text, result.Text)
+	require.Len(suite.T(), result.Segments, 2)
+	assert.Equal(suite.T(), "SPEAKER_00", *result.Segments[0].Speaker)
+	assert.Equal(suite.T(), "SPEAKER_01", *result.Segments[1].Speaker)
+	assert.InDelta(suite.T(), 5.2, result.Segments[1].End, 0.001)
+}
+
+// Test segments are reported as progress while the transcript is built
+func (suite *FakeBackendTestSuite) TestReportsProgress() {
+	text := "one two three four five six seven eight nine ten eleven twelve thirteen"
+	path := suite.writeAudio("progress.txt.mp3", []byte("audio"))
+	suite.writeAudio("progress.txt.mp3.txt", []byte(text))
+
+	var reported []interfaces.TranscriptionProgress
+	procCtx := interfaces.ProcessingContext{JobID: "fake-job", Metadata: map[string]string{}, Progress: func(progress interfaces.TranscriptionProgress) {
+		reported = append(reported, progress)
+	}}
+	result, err := suite.adapter.Transcribe(context.Background(), interfaces.AudioInput{FilePath: path}, map[string]interface{}{}, procCtx)
+	require.NoError(suite.T(), err)
+
+	require.Len(suite.T(), reported, 2)
+	assert.Equal(suite.T(), 50.0, reported[0].Percent)
+	assert.Equal(suite.T(), 100.0, reported[1].Percent)
+	assert.Equal(suite.T(), result.Segments[0], *reported[0].Segment)
+	assert.Equal(suite.T(), result.Segments[1], *reported[1].Segment)
+}
+
+func TestFakeBackendTestSuite(t *testing.T) {
+	suite.Run(t, new(FakeBackendTestSuite))
+}
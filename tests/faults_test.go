@@ -0,0 +1,88 @@
+package tests
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"synthezia/internal/faults"
+	"synthezia/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FaultsTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+}
+
+func (suite *FaultsTestSuite) SetupSuite() {
+	suite.helper = NewTestHelper(suite.T(), "faults_test.db")
+}
+
+func (suite *FaultsTestSuite) TearDownSuite() {
+	faults.Reset()
+	suite.helper.Cleanup()
+}
+
+func (suite *FaultsTestSuite) SetupTest() {
+	faults.Reset()
+}
+
+// Test fault injection is inert by default
+func (suite *FaultsTestSuite) TestDisabledByDefault() {
+	assert.False(suite.T(), faults.Enabled())
+	assert.NoError(suite.T(), faults.Inject(faults.FFmpeg))
+	assert.NoError(suite.T(), faults.Inject(faults.DBLock))
+}
+
+// Test spec parsing
+func (suite *FaultsTestSuite) TestConfigure() {
+	assert.NoError(suite.T(), faults.Configure("ffmpeg:1, whisperx_oom:0"))
+	assert.True(suite.T(), faults.Enabled())
+	assert.Error(suite.T(), faults.Inject(faults.FFmpeg))
+	assert.NoError(suite.T(), faults.Inject(faults.WhisperXOOM))
+
+	assert.Error(suite.T(), faults.Configure("ffmpeg"))
+	assert.Error(suite.T(), faults.Configure("gpu_fire:0.5"))
+	assert.Error(suite.T(), faults.Configure("ffmpeg:1.5"))
+}
+
+// Test injected errors are identifiable
+func (suite *FaultsTestSuite) TestInjectedErrorType() {
+	faults.Set(faults.WhisperXOOM, 1)
+
+	err := faults.Inject(faults.WhisperXOOM)
+	var injected *faults.InjectedError
+	assert.True(suite.T(), errors.As(err, &injected))
+	assert.Equal(suite.T(), faults.WhisperXOOM, injected.Point)
+	assert.Contains(suite.T(), err.Error(), "CUDA out of memory")
+}
+
+// Test DB lock faults surface through GORM
+func (suite *FaultsTestSuite) TestDBLockInjection() {
+	faults.Set(faults.DBLock, 1)
+
+	var count int64
+	err := suite.helper.DB.Model(&models.TranscriptionJob{}).Count(&count).Error
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "database is locked")
+
+	faults.Reset()
+	assert.NoError(suite.T(), suite.helper.DB.Model(&models.TranscriptionJob{}).Count(&count).Error)
+}
+
+// Test slow disk delay
+func (suite *FaultsTestSuite) TestSlowDiskDelay() {
+	faults.Set(faults.SlowDisk, 1)
+	faults.SetSlowDiskDelay(50 * time.Millisecond)
+
+	start := time.Now()
+	faults.Delay()
+	assert.GreaterOrEqual(suite.T(), time.Since(start), 50*time.Millisecond)
+}
+
+func TestFaultsTestSuite(t *testing.T) {
+	suite.Run(t, new(FaultsTestSuite))
+}
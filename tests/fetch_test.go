@@ -0,0 +1,404 @@
+package tests
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/fetch"
+	"synthezia/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeYtDlp writes the file yt-dlp would, printing the title and progress
+// lines the way yt-dlp does with --newline
+const fakeYtDlp = `#!/bin/sh
+out=""
+while [ $# -gt 0 ]; do
+	if [ "$1" = "--output" ]; then out="$2"; shift; fi
+	shift
+done
+echo "title:Conference keynote"
+echo "[download]  25.0% of 4.00MiB at 1.00MiB/s ETA 00:03"
+echo "[download] 100.0% of 4.00MiB at 1.00MiB/s ETA 00:00"
+echo "fake audio" > "$(echo "$out" | sed 's/%(ext)s/mp3/')"
+`
+
+type FetchTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	server  *httptest.Server
+	service *fetch.Service
+	dir     string
+	// Requests to /media/slow wait for release, counting how many wait at once
+	release  chan struct{}
+	slow     int
+	slowPeak int
+
+	mu       sync.Mutex
+	enqueued []string
+}
+
+func (suite *FetchTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "fetch_test.db")
+	suite.dir = suite.T().TempDir()
+	suite.enqueued = nil
+	suite.release = make(chan struct{})
+	suite.slow, suite.slowPeak = 0, 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/media/keynote", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte(strings.Repeat("a", 1000)))
+	})
+	mux.HandleFunc("/media/slow", func(w http.ResponseWriter, r *http.Request) {
+		suite.mu.Lock()
+		suite.slow++
+		suite.slowPeak = max(suite.slowPeak, suite.slow)
+		suite.mu.Unlock()
+		<-suite.release
+		suite.mu.Lock()
+		suite.slow--
+		suite.mu.Unlock()
+		w.Header().Set("Content-Type", "audio/mpeg")
+		w.Write([]byte("a"))
+	})
+	mux.HandleFunc("/media/gone.mp3", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/media/moved", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, suite.server.URL+"/media/keynote", http.StatusFound)
+	})
+	suite.server = httptest.NewServer(mux)
+	suite.service = suite.newService(fetch.Options{})
+}
+
+func (suite *FetchTestSuite) TearDownTest() {
+	suite.server.Close()
+	suite.helper.Cleanup()
+}
+
+func (suite *FetchTestSuite) newService(opts fetch.Options) *fetch.Service {
+	opts.UploadDir = filepath.Join(suite.dir, "uploads")
+	// The test server listens on loopback, which downloads may not reach by default
+	if opts.AllowedHosts == nil {
+		opts.AllowedHosts = []string{"127.0.0.1"}
+	}
+	service := fetch.NewService(suite.helper.DB, opts)
+	service.SetEnqueue(func(jobID string) error {
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+		suite.enqueued = append(suite.enqueued, jobID)
+		return nil
+	})
+	return service
+}
+
+// createDownload creates a job waiting for the media at rawURL
+func (suite *FetchTestSuite) createDownload(id, source, rawURL string) {
+	job := &models.TranscriptionJob{ID: id, Status: models.StatusUploaded}
+	require.NoError(suite.T(), suite.helper.DB.Create(job).Error)
+	download := &models.RemoteDownload{JobID: id, Source: source, URL: rawURL, Host: "example.com", Status: models.DownloadPending}
+	require.NoError(suite.T(), suite.helper.DB.Create(download).Error)
+}
+
+func (suite *FetchTestSuite) load(id string) (models.TranscriptionJob, models.RemoteDownload) {
+	var job models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", id).First(&job).Error)
+	var download models.RemoteDownload
+	require.NoError(suite.T(), suite.helper.DB.Where("job_id = ?", id).First(&download).Error)
+	return job, download
+}
+
+// Test URLs are sorted into direct links and yt-dlp pages
+func (suite *FetchTestSuite) TestClassify() {
+	source, host, err := suite.service.Classify("https://bucket.s3.amazonaws.com/talk.mp3?X-Amz-Signature=abc")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.DownloadSourceHTTP, source)
+	assert.Equal(suite.T(), "bucket.s3.amazonaws.com", host)
+
+	for _, raw := range []string{"ftp://example.com/talk.mp3", "/talk.mp3", "not a url"} {
+		_, _, err := suite.service.Classify(raw)
+		assert.ErrorIs(suite.T(), err, fetch.ErrInvalidURL, raw)
+	}
+
+	_, _, err = suite.service.Classify("https://www.youtube.com/watch?v=abc")
+	assert.ErrorIs(suite.T(), err, fetch.ErrYouTubeDisabled)
+	source, host, err = suite.newService(fetch.Options{YouTube: true}).Classify("https://youtu.be/abc")
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.DownloadSourceYouTube, source)
+	assert.Equal(suite.T(), "youtu.be", host)
+}
+
+// Test URLs into the server's own network are refused unless allowed
+func (suite *FetchTestSuite) TestClassifyInternalAddresses() {
+	service := suite.newService(fetch.Options{AllowedHosts: []string{}})
+	for _, raw := range []string{
+		"http://169.254.169.254/latest/meta-data/",
+		"http://localhost:8080/talk.mp3",
+		"http://10.0.0.5/talk.mp3",
+		"http://[::1]/talk.mp3",
+		"http://0.0.0.0/talk.mp3",
+	} {
+		_, _, err := service.Classify(raw)
+		assert.ErrorIs(suite.T(), err, fetch.ErrInternalAddress, raw)
+	}
+
+	service = suite.newService(fetch.Options{AllowedHosts: []string{"10.0.0.0/8", "media.internal", " "}})
+	for _, raw := range []string{"http://10.0.0.5/talk.mp3", "http://media.internal/talk.mp3", "https://example.com/talk.mp3"} {
+		_, _, err := service.Classify(raw)
+		assert.NoError(suite.T(), err, raw)
+	}
+	_, _, err := service.Classify("http://192.168.1.1/talk.mp3")
+	assert.ErrorIs(suite.T(), err, fetch.ErrInternalAddress)
+}
+
+// Test every blocked range is refused, IPv4 addresses mapped into IPv6 included
+func (suite *FetchTestSuite) TestClassifyBlockedRanges() {
+	service := suite.newService(fetch.Options{AllowedHosts: []string{}})
+	for _, host := range []string{
+		"0.1.2.3",
+		"10.1.2.3",
+		"100.64.0.1",
+		"100.127.255.254",
+		"127.0.0.2",
+		"169.254.169.254",
+		"172.16.0.1",
+		"192.0.0.8",
+		"192.0.2.1",
+		"192.168.1.1",
+		"198.18.0.1",
+		"198.19.255.254",
+		"198.51.100.1",
+		"203.0.113.1",
+		"224.0.0.1",
+		"240.0.0.1",
+		"255.255.255.255",
+		"[::]",
+		"[::1]",
+		"[::a00:1]",
+		"[::ffff:10.0.0.1]",
+		"[::ffff:100.64.0.1]",
+		"[::ffff:169.254.169.254]",
+		"[64:ff9b::a9fe:a9fe]",
+		"[64:ff9b:1::1]",
+		"[100::1]",
+		"[2001:db8::1]",
+		"[2002:a00:1::1]",
+		"[fd00::1]",
+		"[fe80::1]",
+		"[ff02::1]",
+	} {
+		_, _, err := service.Classify("http://" + host + "/talk.mp3")
+		assert.ErrorIs(suite.T(), err, fetch.ErrInternalAddress, host)
+	}
+
+	for _, host := range []string{"93.184.216.34", "100.128.0.1", "198.20.0.1", "[::ffff:93.184.216.34]", "[2606:2800:220:1::1]"} {
+		_, _, err := service.Classify("http://" + host + "/talk.mp3")
+		assert.NoError(suite.T(), err, host)
+	}
+}
+
+// Test names resolving inward and redirects into the network are refused when dialing
+func (suite *FetchTestSuite) TestHTTPDownloadInternalAddress() {
+	port := suite.server.URL[strings.LastIndex(suite.server.URL, ":")+1:]
+
+	service := suite.newService(fetch.Options{AllowedHosts: []string{}})
+	suite.createDownload("job-inward", models.DownloadSourceHTTP, "http://localhost:"+port+"/media/keynote")
+	service.Start("job-inward")
+	service.Wait("job-inward")
+	job, download := suite.load("job-inward")
+	assert.Equal(suite.T(), models.StatusFailed, job.Status)
+	require.NotNil(suite.T(), download.Error)
+	assert.Contains(suite.T(), *download.Error, fetch.ErrInternalAddress.Error())
+
+	// Allowing localhost by name does not allow where it redirects to
+	service = suite.newService(fetch.Options{AllowedHosts: []string{"localhost"}})
+	suite.createDownload("job-moved", models.DownloadSourceHTTP, "http://localhost:"+port+"/media/moved")
+	service.Start("job-moved")
+	service.Wait("job-moved")
+	job, download = suite.load("job-moved")
+	assert.Equal(suite.T(), models.StatusFailed, job.Status)
+	require.NotNil(suite.T(), download.Error)
+	assert.Contains(suite.T(), *download.Error, fetch.ErrInternalAddress.Error())
+
+	entries, _ := os.ReadDir(filepath.Join(suite.dir, "uploads"))
+	assert.Empty(suite.T(), entries)
+	assert.Empty(suite.T(), suite.enqueued)
+}
+
+// Test direct links go through a configured proxy, with each target and
+// redirect still checked before it is requested
+func (suite *FetchTestSuite) TestHTTPDownloadThroughProxy() {
+	service := suite.newService(fetch.Options{AllowedHosts: []string{}, Proxy: suite.server.URL})
+
+	// The test server answers as the proxy for a public address
+	suite.createDownload("job-proxied", models.DownloadSourceHTTP, "http://93.184.216.34/media/keynote")
+	service.Start("job-proxied")
+	service.Wait("job-proxied")
+	job, download := suite.load("job-proxied")
+	assert.Equal(suite.T(), models.StatusPending, job.Status)
+	assert.Equal(suite.T(), models.DownloadCompleted, download.Status)
+
+	for id, rawURL := range map[string]string{
+		"job-private": "http://10.0.0.5/media/keynote",
+		"job-moved":   "http://93.184.216.34/media/moved",
+	} {
+		suite.createDownload(id, models.DownloadSourceHTTP, rawURL)
+		service.Start(id)
+		service.Wait(id)
+		job, download := suite.load(id)
+		assert.Equal(suite.T(), models.StatusFailed, job.Status, id)
+		require.NotNil(suite.T(), download.Error, id)
+		assert.Contains(suite.T(), *download.Error, fetch.ErrInternalAddress.Error(), id)
+	}
+	assert.Equal(suite.T(), []string{"job-proxied"}, suite.enqueued)
+}
+
+// Test a download that is not audio fails rather than becoming the job's audio
+func (suite *FetchTestSuite) TestHTTPDownloadNotAudio() {
+	ffprobe := filepath.Join(suite.dir, "ffprobe")
+	require.NoError(suite.T(), os.WriteFile(ffprobe, []byte("#!/bin/sh\necho 'Invalid data found when processing input' >&2\nexit 1\n"), 0755))
+	service := suite.newService(fetch.Options{FFprobePath: ffprobe})
+
+	suite.createDownload("job-page", models.DownloadSourceHTTP, suite.server.URL+"/media/keynote")
+	service.Start("job-page")
+	service.Wait("job-page")
+
+	job, download := suite.load("job-page")
+	assert.Equal(suite.T(), models.StatusFailed, job.Status)
+	assert.Empty(suite.T(), job.AudioPath)
+	require.NotNil(suite.T(), download.Error)
+	assert.Contains(suite.T(), *download.Error, "Invalid data found")
+	entries, _ := os.ReadDir(filepath.Join(suite.dir, "uploads"))
+	assert.Empty(suite.T(), entries)
+	assert.Empty(suite.T(), suite.enqueued)
+}
+
+// Test a direct link is downloaded, named after its type and queued
+func (suite *FetchTestSuite) TestHTTPDownload() {
+	suite.createDownload("job-http", models.DownloadSourceHTTP, suite.server.URL+"/media/keynote")
+	suite.service.Start("job-http")
+	suite.service.Wait("job-http")
+
+	job, download := suite.load("job-http")
+	assert.Equal(suite.T(), models.StatusPending, job.Status)
+	assert.Equal(suite.T(), ".mp3", filepath.Ext(job.AudioPath))
+	require.NotNil(suite.T(), job.Title)
+	assert.Equal(suite.T(), "keynote", *job.Title)
+	data, err := os.ReadFile(job.AudioPath)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), data, 1000)
+
+	assert.Equal(suite.T(), models.DownloadCompleted, download.Status)
+	assert.Equal(suite.T(), int64(1000), download.BytesDone)
+	assert.Equal(suite.T(), float64(100), download.Progress)
+	assert.NotNil(suite.T(), download.CompletedAt)
+	assert.Equal(suite.T(), []string{"job-http"}, suite.enqueued)
+}
+
+// Test a failed download fails its job without leaking the URL's credentials
+func (suite *FetchTestSuite) TestHTTPDownloadFails() {
+	suite.createDownload("job-gone", models.DownloadSourceHTTP, suite.server.URL+"/media/gone.mp3?X-Amz-Signature=secret")
+	suite.service.Start("job-gone")
+	suite.service.Wait("job-gone")
+
+	job, download := suite.load("job-gone")
+	assert.Equal(suite.T(), models.StatusFailed, job.Status)
+	require.NotNil(suite.T(), download.Error)
+	assert.Contains(suite.T(), *download.Error, "404")
+	assert.Equal(suite.T(), models.DownloadFailed, download.Status)
+	assert.Empty(suite.T(), suite.enqueued)
+
+	suite.createDownload("job-closed", models.DownloadSourceHTTP, "http://127.0.0.1:1/talk.mp3?X-Amz-Signature=secret")
+	suite.service.Start("job-closed")
+	suite.service.Wait("job-closed")
+	job, download = suite.load("job-closed")
+	assert.Equal(suite.T(), models.StatusFailed, job.Status)
+	require.NotNil(suite.T(), download.Error)
+	assert.NotContains(suite.T(), *download.Error, "secret")
+	require.NotNil(suite.T(), job.ErrorMessage)
+	assert.NotContains(suite.T(), *job.ErrorMessage, "secret")
+}
+
+// Test downloads beyond the concurrency limit wait for a slot
+func (suite *FetchTestSuite) TestConcurrencyLimit() {
+	service := suite.newService(fetch.Options{Concurrency: 1})
+	ids := []string{"job-slow1", "job-slow2", "job-slow3"}
+	for _, id := range ids {
+		suite.createDownload(id, models.DownloadSourceHTTP, suite.server.URL+"/media/slow")
+		service.Start(id)
+	}
+	assert.Eventually(suite.T(), func() bool {
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+		return suite.slow == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	close(suite.release)
+	for _, id := range ids {
+		service.Wait(id)
+	}
+
+	assert.Equal(suite.T(), 1, suite.slowPeak)
+	assert.ElementsMatch(suite.T(), ids, suite.enqueued)
+}
+
+// Test media over the limit is refused and nothing is left behind
+func (suite *FetchTestSuite) TestHTTPDownloadTooLarge() {
+	service := suite.newService(fetch.Options{MaxBytes: 100})
+	suite.createDownload("job-big", models.DownloadSourceHTTP, suite.server.URL+"/media/keynote")
+	service.Start("job-big")
+	service.Wait("job-big")
+
+	job, download := suite.load("job-big")
+	assert.Equal(suite.T(), models.StatusFailed, job.Status)
+	require.NotNil(suite.T(), download.Error)
+	assert.Equal(suite.T(), fetch.ErrTooLarge.Error(), *download.Error)
+	entries, _ := os.ReadDir(filepath.Join(suite.dir, "uploads"))
+	assert.Empty(suite.T(), entries)
+}
+
+// Test pages are fetched with yt-dlp, taking its title
+func (suite *FetchTestSuite) TestYtDlpDownload() {
+	script := filepath.Join(suite.dir, "yt-dlp")
+	require.NoError(suite.T(), os.WriteFile(script, []byte(fakeYtDlp), 0755))
+	service := suite.newService(fetch.Options{YouTube: true, YtDlp: []string{script}})
+
+	suite.createDownload("job-yt", models.DownloadSourceYouTube, "https://www.youtube.com/watch?v=abc")
+	service.Start("job-yt")
+	service.Wait("job-yt")
+
+	job, download := suite.load("job-yt")
+	assert.Equal(suite.T(), models.StatusPending, job.Status)
+	assert.Equal(suite.T(), "job-yt.mp3", filepath.Base(job.AudioPath))
+	require.NotNil(suite.T(), job.Title)
+	assert.Equal(suite.T(), "Conference keynote", *job.Title)
+	assert.Equal(suite.T(), models.DownloadCompleted, download.Status)
+	assert.Equal(suite.T(), []string{"job-yt"}, suite.enqueued)
+}
+
+// Test downloads interrupted by a restart start over
+func (suite *FetchTestSuite) TestResume() {
+	suite.createDownload("job-resume", models.DownloadSourceHTTP, suite.server.URL+"/media/keynote")
+	require.NoError(suite.T(), suite.helper.DB.Model(&models.RemoteDownload{}).Where("job_id = ?", "job-resume").Updates(map[string]interface{}{"status": models.DownloadDownloading, "bytes_done": 500}).Error)
+
+	suite.service.Resume()
+	suite.service.Wait("job-resume")
+
+	job, download := suite.load("job-resume")
+	assert.Equal(suite.T(), models.StatusPending, job.Status)
+	assert.Equal(suite.T(), int64(1000), download.BytesDone)
+}
+
+func TestFetchTestSuite(t *testing.T) {
+	suite.Run(t, new(FetchTestSuite))
+}
@@ -0,0 +1,215 @@
+package tests
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"synthezia/internal/ffmpegbin"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// ffmpegFilters is the start of ffmpeg -filters output with the filters
+// synthezia needs
+const ffmpegFilters = `Filters:
+  T.. = Timeline support
+  .S. = Slice threading
+  ..C = Command support
+  A = Audio input/output
+  | = Source or sink filter
+ ..C adelay            A->A       Delay one or more audio channels.
+ ... amix              N->A       Audio mixing.
+ ... loudnorm          A->A       EBU R128 loudness normalization
+ T.. volume            A->A       Change input volume.
+`
+
+// fakeFFmpegBinary answers -version and -filters like the given ffmpeg
+// release with the given filter list, using only shell builtins since the
+// tests empty the PATH
+func fakeFFmpegBinary(version, filters string) string {
+	return fmt.Sprintf(`#!/bin/sh
+case "$2" in
+-version) printf '%%s\n' 'ffmpeg version %s Copyright (c) 2000-2024 the FFmpeg developers' ;;
+-filters) printf '%%s' '%s' ;;
+esac
+`, version, filters)
+}
+
+const fakeFFprobeBinary = `#!/bin/sh
+printf '%s\n' 'ffprobe version 6.1 Copyright (c) 2007-2024 the FFmpeg developers'
+`
+
+type FFmpegBinTestSuite struct {
+	suite.Suite
+	dir string
+}
+
+func (suite *FFmpegBinTestSuite) SetupTest() {
+	suite.dir = suite.T().TempDir()
+	// Only the binaries a test installs are found
+	suite.T().Setenv("PATH", filepath.Join(suite.dir, "bin"))
+}
+
+// install writes fake binaries into a directory and returns it
+func (suite *FFmpegBinTestSuite) install(name, ffmpeg, ffprobe string) string {
+	dir := filepath.Join(suite.dir, name)
+	require.NoError(suite.T(), os.MkdirAll(dir, 0755))
+	if ffmpeg != "" {
+		require.NoError(suite.T(), os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(ffmpeg), 0755))
+	}
+	if ffprobe != "" {
+		require.NoError(suite.T(), os.WriteFile(filepath.Join(dir, "ffprobe"), []byte(ffprobe), 0755))
+	}
+	return dir
+}
+
+// Test a recent ffmpeg with the required filters is usable
+func (suite *FFmpegBinTestSuite) TestCheckHealthy() {
+	dir := suite.install("bin", fakeFFmpegBinary("6.1.1", ffmpegFilters), fakeFFprobeBinary)
+
+	status := ffmpegbin.Check(context.Background())
+	assert.True(suite.T(), status.Healthy, status.Error)
+	assert.Equal(suite.T(), "6.1.1", status.Version)
+	assert.Equal(suite.T(), filepath.Join(dir, "ffmpeg"), status.FFmpegPath)
+	assert.Equal(suite.T(), filepath.Join(dir, "ffprobe"), status.FFprobePath)
+	assert.Empty(suite.T(), status.MissingFilters)
+}
+
+// Test missing binaries, old releases and missing filters are reported
+func (suite *FFmpegBinTestSuite) TestCheckProblems() {
+	status := ffmpegbin.Check(context.Background())
+	assert.False(suite.T(), status.Healthy)
+	assert.Equal(suite.T(), "ffmpeg not found", status.Error)
+
+	dir := suite.install("bin", fakeFFmpegBinary("4.2.7", ffmpegFilters), "")
+	status = ffmpegbin.Check(context.Background())
+	assert.Equal(suite.T(), "ffprobe not found", status.Error)
+
+	require.NoError(suite.T(), os.WriteFile(filepath.Join(dir, "ffprobe"), []byte(fakeFFprobeBinary), 0755))
+	status = ffmpegbin.Check(context.Background())
+	assert.False(suite.T(), status.Healthy)
+	assert.Equal(suite.T(), "4.2.7", status.Version)
+	assert.Contains(suite.T(), status.Error, "older than 4.4")
+
+	noLoudnorm := strings.Replace(ffmpegFilters, " ... loudnorm          A->A       EBU R128 loudness normalization\n", "", 1)
+	require.NoError(suite.T(), os.WriteFile(filepath.Join(dir, "ffmpeg"), []byte(fakeFFmpegBinary("n7.0", noLoudnorm)), 0755))
+	status = ffmpegbin.Check(context.Background())
+	assert.False(suite.T(), status.Healthy)
+	assert.Equal(suite.T(), []string{"loudnorm"}, status.MissingFilters)
+}
+
+// Test versions and filter lists are read from ffmpeg's output
+func (suite *FFmpegBinTestSuite) TestParseOutput() {
+	assert.Equal(suite.T(), "4.4.2-0ubuntu0.22.04.1", ffmpegbin.ParseVersion("ffmpeg version 4.4.2-0ubuntu0.22.04.1 Copyright (c) 2000-2021 the FFmpeg developers\nbuilt with gcc 11"))
+	assert.Equal(suite.T(), "N-113000-g1234abcd", ffmpegbin.ParseVersion("ffmpeg version N-113000-g1234abcd Copyright"))
+	assert.Empty(suite.T(), ffmpegbin.ParseVersion("sox: SoX v14.4.2"))
+
+	filters := ffmpegbin.ParseFilters(ffmpegFilters)
+	assert.Equal(suite.T(), map[string]bool{"adelay": true, "amix": true, "loudnorm": true, "volume": true}, filters)
+}
+
+// Test checksums are read by binary name and malformed ones rejected
+func (suite *FFmpegBinTestSuite) TestParseChecksums() {
+	sum := strings.Repeat("ab", 32)
+	sums, err := ffmpegbin.ParseChecksums("ffmpeg=" + strings.ToUpper(sum) + ", ffprobe=" + sum)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), map[string]string{"ffmpeg": sum, "ffprobe": sum}, sums)
+
+	_, err = ffmpegbin.ParseChecksums("ffmpeg=abc")
+	assert.Error(suite.T(), err)
+	_, err = ffmpegbin.ParseChecksums(sum)
+	assert.Error(suite.T(), err)
+}
+
+// gzipped compresses data as the static builds are published
+func gzipped(t *testing.T, data string) []byte {
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	_, err := gz.Write([]byte(data))
+	require.NoError(t, err)
+	require.NoError(t, gz.Close())
+	return buf.Bytes()
+}
+
+// Test Startup downloads a build when none is usable, then reuses it
+func (suite *FFmpegBinTestSuite) TestStartupAutoInstall() {
+	builds := map[string][]byte{
+		"/ffmpeg.gz":  gzipped(suite.T(), fakeFFmpegBinary("6.0", ffmpegFilters)),
+		"/ffprobe.gz": gzipped(suite.T(), fakeFFprobeBinary),
+	}
+	downloads := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		build, ok := builds[r.URL.Path]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		downloads++
+		w.Write(build)
+	}))
+	defer server.Close()
+	ffmpegSum := sha256.Sum256(builds["/ffmpeg.gz"])
+
+	installDir := filepath.Join(suite.dir, "installed")
+	opts := ffmpegbin.Options{
+		AutoInstall: true,
+		InstallDir:  installDir,
+		DownloadURL: server.URL + "/{name}.gz",
+		SHA256:      map[string]string{"ffmpeg": hex.EncodeToString(ffmpegSum[:])},
+	}
+	status := ffmpegbin.Startup(context.Background(), opts)
+	assert.True(suite.T(), status.Healthy, status.Error)
+	assert.True(suite.T(), status.Installed)
+	assert.Equal(suite.T(), filepath.Join(installDir, "ffmpeg"), status.FFmpegPath)
+	assert.Equal(suite.T(), 2, downloads)
+
+	current, ok := ffmpegbin.Current()
+	require.True(suite.T(), ok)
+	assert.Equal(suite.T(), status, current)
+
+	// The next start finds the installed build
+	suite.T().Setenv("PATH", filepath.Join(suite.dir, "bin"))
+	status = ffmpegbin.Startup(context.Background(), opts)
+	assert.True(suite.T(), status.Healthy, status.Error)
+	assert.False(suite.T(), status.Installed)
+	assert.Equal(suite.T(), 2, downloads)
+}
+
+// Test a download whose checksum does not match is not installed
+func (suite *FFmpegBinTestSuite) TestInstallChecksumMismatch() {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(fakeFFprobeBinary))
+	}))
+	defer server.Close()
+
+	installDir := filepath.Join(suite.dir, "installed")
+	err := ffmpegbin.Install(context.Background(), installDir, server.URL+"/{name}", map[string]string{"ffmpeg": strings.Repeat("00", 32)})
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "checksum mismatch")
+	entries, err := os.ReadDir(installDir)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries)
+}
+
+// Test the download URL names the binary and platform
+func (suite *FFmpegBinTestSuite) TestDownloadURL() {
+	url := ffmpegbin.DownloadURL(ffmpegbin.DefaultDownloadURL, "ffprobe")
+	assert.True(suite.T(), strings.HasPrefix(url, "https://github.com/eugeneware/ffmpeg-static/releases/download/"+ffmpegbin.PinnedRelease+"/ffprobe-"))
+	assert.NotContains(suite.T(), url, "{")
+}
+
+func TestFFmpegBinTestSuite(t *testing.T) {
+	suite.Run(t, new(FFmpegBinTestSuite))
+}
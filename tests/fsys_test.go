@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"synthezia/internal/audio"
+	"synthezia/pkg/fsys"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type FSysTestSuite struct {
+	suite.Suite
+	fs *fsys.MemFS
+}
+
+func (suite *FSysTestSuite) SetupTest() {
+	suite.fs = fsys.NewMemFS()
+}
+
+// Test files round-trip through the in-memory filesystem
+func (suite *FSysTestSuite) TestReadWrite() {
+	assert.NoError(suite.T(), suite.fs.MkdirAll("uploads/job", 0755))
+	assert.NoError(suite.T(), fsys.WriteFile(suite.fs, "uploads/job/audio.mp3", []byte("hello")))
+
+	data, err := fsys.ReadFile(suite.fs, "uploads/./job/audio.mp3")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "hello", string(data))
+
+	info, err := suite.fs.Stat("uploads/job/audio.mp3")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(5), info.Size())
+	assert.False(suite.T(), info.IsDir())
+
+	// Files opened for reading cannot be written
+	f, err := suite.fs.Open("uploads/job/audio.mp3")
+	assert.NoError(suite.T(), err)
+	_, err = f.Write([]byte("x"))
+	assert.Error(suite.T(), err)
+	f.Close()
+}
+
+// Test missing paths report os.ErrNotExist like the real filesystem
+func (suite *FSysTestSuite) TestNotExist() {
+	_, err := suite.fs.Stat("missing.mp3")
+	assert.True(suite.T(), os.IsNotExist(err))
+
+	_, err = suite.fs.Create("no/such/dir/file.mp3")
+	assert.True(suite.T(), os.IsNotExist(err))
+
+	assert.True(suite.T(), os.IsNotExist(suite.fs.Remove("missing.mp3")))
+	assert.NoError(suite.T(), suite.fs.RemoveAll("missing"))
+}
+
+// Test directory operations and walking
+func (suite *FSysTestSuite) TestWalkRenameRemove() {
+	suite.fs.MkdirAll("root/b", 0755)
+	fsys.WriteFile(suite.fs, "root/a.wav", []byte("a"))
+	fsys.WriteFile(suite.fs, "root/b/c.wav", []byte("c"))
+
+	var visited []string
+	err := fsys.Walk(suite.fs, "root", func(path string, info os.FileInfo, err error) error {
+		visited = append(visited, path)
+		return err
+	})
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{"root", filepath.Join("root", "a.wav"), filepath.Join("root", "b"), filepath.Join("root", "b", "c.wav")}, visited)
+
+	assert.Error(suite.T(), suite.fs.Remove("root/b"), "non-empty directory")
+
+	assert.NoError(suite.T(), suite.fs.Rename("root/b", "root/moved"))
+	assert.True(suite.T(), fsys.Exists(suite.fs, "root/moved/c.wav"))
+	assert.False(suite.T(), fsys.Exists(suite.fs, "root/b/c.wav"))
+
+	assert.NoError(suite.T(), suite.fs.RemoveAll("root"))
+	assert.False(suite.T(), fsys.Exists(suite.fs, "root/a.wav"))
+}
+
+// Test the merger checks inputs through the injected filesystem
+func (suite *FSysTestSuite) TestMergerUsesFS() {
+	merger := audio.NewAudioMergerWithPath("ffmpeg-not-installed")
+	merger.SetFS(suite.fs)
+
+	err := merger.MergeTracksWithOffsets(context.Background(), []audio.TrackInfo{{FilePath: "tracks/missing.wav"}}, "out.mp3", nil)
+	assert.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "input file does not exist")
+}
+
+// failingReader returns some data and then an error, like a dropped upload
+type failingReader struct{ sent bool }
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.sent {
+		return 0, errors.New("connection reset")
+	}
+	r.sent = true
+	return copy(p, "partial"), nil
+}
+
+// Test files are built in the temp directory and only renamed into place complete
+func (suite *FSysTestSuite) TestWriteFileAtomic() {
+	suite.fs.MkdirAll("uploads", 0755)
+	suite.fs.MkdirAll("temp", 0755)
+
+	tempPath := fsys.TempPath("temp", "uploads/job.mp3")
+	assert.Equal(suite.T(), "temp", filepath.Dir(tempPath))
+	assert.True(suite.T(), strings.HasPrefix(filepath.Base(tempPath), ".job.mp3."))
+	assert.NotEqual(suite.T(), tempPath, fsys.TempPath("temp", "uploads/job.mp3"))
+	assert.Equal(suite.T(), "uploads", filepath.Dir(fsys.TempPath("", "uploads/job.mp3")))
+
+	n, err := fsys.WriteFileAtomic(suite.fs, "temp", "uploads/job.mp3", strings.NewReader("audio"))
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(5), n)
+	data, err := fsys.ReadFile(suite.fs, "uploads/job.mp3")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "audio", string(data))
+
+	// A failed copy leaves neither the destination nor the temp file behind
+	_, err = fsys.WriteFileAtomic(suite.fs, "temp", "uploads/broken.mp3", &failingReader{})
+	assert.Error(suite.T(), err)
+	assert.False(suite.T(), fsys.Exists(suite.fs, "uploads/broken.mp3"))
+	entries, err := suite.fs.ReadDir("temp")
+	assert.NoError(suite.T(), err)
+	assert.Empty(suite.T(), entries)
+}
+
+func TestFSysTestSuite(t *testing.T) {
+	suite.Run(t, new(FSysTestSuite))
+}
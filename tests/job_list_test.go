@@ -0,0 +1,180 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobListTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+	router *gin.Engine
+	start  time.Time
+}
+
+type jobListResponse struct {
+	Jobs       []models.TranscriptionJob `json:"jobs"`
+	Pagination struct {
+		Page       *int    `json:"page"`
+		Limit      int     `json:"limit"`
+		Total      int64   `json:"total"`
+		NextCursor *string `json:"next_cursor"`
+	} `json:"pagination"`
+}
+
+func (suite *JobListTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "job_list_test.db")
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, nil, nil, nil)
+	suite.router = api.SetupRoutes(handler, suite.helper.AuthService)
+	suite.start = time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+}
+
+func (suite *JobListTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// createJob adds a job created the given hours after the start
+func (suite *JobListTestSuite) createJob(title string, hours int, status models.JobStatus, duration *float64, edit func(*models.TranscriptionJob)) *models.TranscriptionJob {
+	job := &models.TranscriptionJob{Title: &title, AudioPath: title + ".mp3", Status: status, AudioDuration: duration, CreatedAt: suite.start.Add(time.Duration(hours) * time.Hour)}
+	if edit != nil {
+		edit(job)
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	return job
+}
+
+func (suite *JobListTestSuite) list(query url.Values) (*httptest.ResponseRecorder, jobListResponse) {
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/transcription/list?"+query.Encode(), nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	var response jobListResponse
+	if w.Code == http.StatusOK {
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w, response
+}
+
+func titles(jobs []models.TranscriptionJob) []string {
+	names := make([]string, len(jobs))
+	for i, job := range jobs {
+		names[i] = *job.Title
+	}
+	return names
+}
+
+// walk follows cursors through every page of a listing
+func (suite *JobListTestSuite) walk(query url.Values) []string {
+	all := []string{}
+	for pages := 0; pages < 20; pages++ {
+		w, response := suite.list(query)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		all = append(all, titles(response.Jobs)...)
+		if response.Pagination.NextCursor == nil {
+			return all
+		}
+		query.Set("cursor", *response.Pagination.NextCursor)
+	}
+	suite.FailNow("cursor never ended")
+	return nil
+}
+
+func durationOf(seconds float64) *float64 { return &seconds }
+
+func (suite *JobListTestSuite) TestCursorPagination() {
+	for i, title := range []string{"a", "b", "c", "d", "e"} {
+		suite.createJob(title, i, models.StatusCompleted, nil, nil)
+	}
+	// Jobs created in the same instant still page without gaps
+	suite.createJob("f", 4, models.StatusCompleted, nil, nil)
+
+	query := url.Values{"limit": {"2"}}
+	w, first := suite.list(query)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Equal(suite.T(), int64(6), first.Pagination.Total)
+	suite.Require().NotNil(first.Pagination.NextCursor)
+	suite.Require().NotNil(first.Pagination.Page)
+
+	all := suite.walk(url.Values{"limit": {"2"}})
+	suite.Require().Len(all, 6)
+	assert.ElementsMatch(suite.T(), []string{"e", "f"}, all[:2])
+	assert.Equal(suite.T(), []string{"d", "c", "b", "a"}, all[2:])
+	assert.Equal(suite.T(), all, suite.walk(url.Values{"limit": {"1"}}), "ties split across pages")
+
+	// A job added after the first page does not shift the next one
+	suite.createJob("new", 10, models.StatusPending, nil, nil)
+	query.Set("cursor", *first.Pagination.NextCursor)
+	_, second := suite.list(query)
+	assert.Equal(suite.T(), []string{"d", "c"}, titles(second.Jobs))
+	assert.Nil(suite.T(), second.Pagination.Page)
+	assert.Equal(suite.T(), int64(7), second.Pagination.Total)
+
+	assert.Equal(suite.T(), []string{"a", "b", "c", "d"}, suite.walk(url.Values{"limit": {"2"}, "order": {"asc"}})[:4])
+
+	w, _ = suite.list(url.Values{"cursor": {*first.Pagination.NextCursor}, "order": {"asc"}})
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code, "cursors belong to one sort")
+	w, _ = suite.list(url.Values{"cursor": {"nonsense"}})
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *JobListTestSuite) TestSorting() {
+	suite.createJob("short", 0, models.StatusCompleted, durationOf(30), nil)
+	suite.createJob("long", 1, models.StatusFailed, durationOf(3600), nil)
+	suite.createJob("unknown", 2, models.StatusPending, nil, nil)
+	suite.createJob("medium", 3, models.StatusCompleted, durationOf(600), nil)
+
+	assert.Equal(suite.T(), []string{"long", "medium", "short", "unknown"}, suite.walk(url.Values{"sort": {"duration"}, "limit": {"1"}}))
+	assert.Equal(suite.T(), []string{"short", "medium", "long", "unknown"}, suite.walk(url.Values{"sort": {"duration"}, "order": {"asc"}, "limit": {"3"}}))
+	assert.Equal(suite.T(), []string{"medium", "short", "long", "unknown"}, suite.walk(url.Values{"sort": {"status"}, "order": {"asc"}, "limit": {"1"}}))
+
+	w, _ := suite.list(url.Values{"sort": {"title"}})
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *JobListTestSuite) TestFilters() {
+	english := "en"
+	detected := `{"language":"fr","segments":[]}`
+	suite.createJob("english", 0, models.StatusCompleted, nil, func(job *models.TranscriptionJob) { job.Parameters.Language = &english })
+	suite.createJob("french", 24, models.StatusCompleted, nil, func(job *models.TranscriptionJob) { job.Transcript = &detected })
+	suite.createJob("tracks", 48, models.StatusFailed, nil, func(job *models.TranscriptionJob) { job.IsMultiTrack = true })
+	suite.createJob("waiting", 72, models.StatusPending, nil, nil)
+
+	cases := []struct {
+		query url.Values
+		want  []string
+	}{
+		{url.Values{"status": {"completed,failed"}}, []string{"tracks", "french", "english"}},
+		{url.Values{"is_multi_track": {"true"}}, []string{"tracks"}},
+		{url.Values{"is_multi_track": {"false"}, "status": {"pending"}}, []string{"waiting"}},
+		{url.Values{"language": {"EN"}}, []string{"english"}},
+		{url.Values{"language": {"fr"}}, []string{"french"}},
+		{url.Values{"created_from": {"2026-03-02"}, "created_to": {"2026-03-03"}}, []string{"tracks", "french"}},
+		{url.Values{"created_from": {"2026-03-03T09:00:00Z"}}, []string{"waiting", "tracks"}},
+	}
+	for _, tc := range cases {
+		w, response := suite.list(tc.query)
+		suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+		assert.Equal(suite.T(), tc.want, titles(response.Jobs), tc.query.Encode())
+		assert.Equal(suite.T(), int64(len(tc.want)), response.Pagination.Total, tc.query.Encode())
+	}
+
+	for _, query := range []url.Values{{"is_multi_track": {"maybe"}}, {"created_from": {"March"}}, {"created_to": {"2026-13-01"}}} {
+		w, _ := suite.list(query)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code, query.Encode())
+	}
+}
+
+func TestJobListTestSuite(t *testing.T) {
+	suite.Run(t, new(JobListTestSuite))
+}
@@ -0,0 +1,134 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+	"synthezia/internal/transcription"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobProgressTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+	clock  *clock.Fake
+	job    *models.TranscriptionJob
+}
+
+func (suite *JobProgressTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "job_progress_test.db")
+	suite.clock = clock.NewFake(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	suite.job = &models.TranscriptionJob{AudioPath: "talk.mp3", Status: models.StatusProcessing}
+	suite.Require().NoError(suite.helper.DB.Create(suite.job).Error)
+}
+
+func (suite *JobProgressTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// stored returns the progress and processed seconds stored on the job
+func (suite *JobProgressTestSuite) stored() (float64, float64) {
+	var job models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", suite.job.ID).First(&job).Error)
+	return job.Progress, job.ProcessedAudioSeconds
+}
+
+func segmentEnding(end float64) interfaces.TranscriptionProgress {
+	return interfaces.TranscriptionProgress{Segment: &interfaces.TranscriptSegment{Start: end - 1, End: end, Text: "words"}}
+}
+
+// Test segments give the audio transcribed, written at most once a second
+func (suite *JobProgressTestSuite) TestSegments() {
+	writer := transcription.NewJobProgressWriter(suite.helper.DB, suite.clock, suite.job.ID, 0, 200)
+	writer.Begin(0)
+
+	writer.Report(segmentEnding(50))
+	progress, processed := suite.stored()
+	assert.Equal(suite.T(), 0.0, progress)
+	assert.Equal(suite.T(), 0.0, processed)
+
+	suite.clock.Advance(time.Second)
+	writer.Report(segmentEnding(84))
+	progress, processed = suite.stored()
+	assert.Equal(suite.T(), 42.0, progress)
+	assert.Equal(suite.T(), 84.0, processed)
+
+	// Progress never goes backwards
+	suite.clock.Advance(time.Second)
+	writer.Report(segmentEnding(60))
+	progress, _ = suite.stored()
+	assert.Equal(suite.T(), 42.0, progress)
+
+	writer.Finish()
+	progress, processed = suite.stored()
+	assert.Equal(suite.T(), 100.0, progress)
+	assert.Equal(suite.T(), 200.0, processed)
+}
+
+// Test a resumed run of part of a recording counts from the part's start
+func (suite *JobProgressTestSuite) TestResumedRange() {
+	writer := transcription.NewJobProgressWriter(suite.helper.DB, suite.clock, suite.job.ID, 30, 130)
+	writer.Begin(50)
+	progress, processed := suite.stored()
+	assert.Equal(suite.T(), 20.0, progress)
+	assert.Equal(suite.T(), 20.0, processed)
+
+	// The adapter's percentage covers what this run transcribes, from 50 to 130
+	suite.clock.Advance(time.Second)
+	writer.Report(interfaces.TranscriptionProgress{Percent: 50})
+	progress, processed = suite.stored()
+	assert.Equal(suite.T(), 60.0, progress)
+	assert.Equal(suite.T(), 60.0, processed)
+
+	// Segments are timed within the whole recording
+	suite.clock.Advance(time.Second)
+	writer.Report(segmentEnding(110))
+	progress, _ = suite.stored()
+	assert.Equal(suite.T(), 80.0, progress)
+}
+
+// Test audio of unknown length counts seconds without a percentage
+func (suite *JobProgressTestSuite) TestUnknownLength() {
+	writer := transcription.NewJobProgressWriter(suite.helper.DB, suite.clock, suite.job.ID, 0, 0)
+	writer.Begin(0)
+	suite.clock.Advance(time.Second)
+	writer.Report(segmentEnding(12))
+	progress, processed := suite.stored()
+	assert.Equal(suite.T(), 0.0, progress)
+	assert.Equal(suite.T(), 12.0, processed)
+}
+
+// Test the status endpoint shows the stored progress
+func (suite *JobProgressTestSuite) TestStatus() {
+	writer := transcription.NewJobProgressWriter(suite.helper.DB, suite.clock, suite.job.ID, 0, 100)
+	writer.Begin(0)
+	suite.clock.Advance(time.Second)
+	writer.Report(segmentEnding(42))
+
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, queue.NewTaskQueue(1, nil), nil, nil, nil)
+	router := api.SetupRoutes(handler, suite.helper.AuthService)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/transcription/"+suite.job.ID+"/status", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+
+	var status map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &status))
+	assert.Equal(suite.T(), 42.0, status["progress"])
+	assert.Equal(suite.T(), 42.0, status["processed_audio_seconds"])
+}
+
+func TestJobProgressTestSuite(t *testing.T) {
+	suite.Run(t, new(JobProgressTestSuite))
+}
@@ -0,0 +1,221 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync"
+	"testing"
+
+	"synthezia/internal/api"
+	"synthezia/internal/jobversion"
+	"synthezia/internal/models"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobVersionTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+	router *gin.Engine
+	job    *models.TranscriptionJob
+}
+
+func (suite *JobVersionTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "job_version_test.db")
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, nil, nil, nil)
+	suite.router = api.SetupRoutes(handler, suite.helper.AuthService)
+
+	transcript := `{"text":"Hello there.","segments":[{"start":0,"end":1,"text":"Hello there.","speaker":"SPEAKER_00"}]}`
+	suite.job = &models.TranscriptionJob{AudioPath: "edited.mp3", Status: models.StatusCompleted, Transcript: &transcript, Diarization: true}
+	suite.Require().NoError(suite.helper.DB.Create(suite.job).Error)
+}
+
+func (suite *JobVersionTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// request sends body as JSON, based on version when it is not empty
+func (suite *JobVersionTestSuite) request(method, path string, body interface{}, version string) *httptest.ResponseRecorder {
+	var reader *bytes.Reader
+	if body != nil {
+		data, _ := json.Marshal(body)
+		reader = bytes.NewReader(data)
+	} else {
+		reader = bytes.NewReader(nil)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+	if version != "" {
+		req.Header.Set("If-Match", version)
+	}
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *JobVersionTestSuite) TestParseETag() {
+	for value, want := range map[string]int{`"3"`: 3, `W/"12"`: 12, "7": 7} {
+		version, err := jobversion.ParseETag(value)
+		suite.Require().NoError(err, value)
+		assert.Equal(suite.T(), want, *version, value)
+	}
+	for _, value := range []string{"", "*"} {
+		version, err := jobversion.ParseETag(value)
+		suite.Require().NoError(err)
+		assert.Nil(suite.T(), version)
+	}
+	for _, value := range []string{`"abc"`, `"0"`, "-1"} {
+		_, err := jobversion.ParseETag(value)
+		assert.Error(suite.T(), err, value)
+	}
+}
+
+func (suite *JobVersionTestSuite) TestConcurrentEdits() {
+	base := "/api/v1/transcription/" + suite.job.ID
+	w := suite.request(http.MethodGet, base, nil, "")
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Equal(suite.T(), `"1"`, w.Header().Get("ETag"))
+	var job models.TranscriptionJob
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &job))
+	assert.Equal(suite.T(), 1, job.Version)
+
+	// Two editors loaded version 1; the first to save wins
+	w = suite.request(http.MethodPut, base+"/title", map[string]string{"title": "First"}, `"1"`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), `"2"`, w.Header().Get("ETag"))
+	w = suite.request(http.MethodPut, base+"/title", map[string]string{"title": "Second"}, `"1"`)
+	suite.Require().Equal(http.StatusConflict, w.Code)
+	assert.Equal(suite.T(), `"2"`, w.Header().Get("ETag"))
+	var conflict map[string]interface{}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &conflict))
+	assert.Equal(suite.T(), float64(2), conflict["version"])
+
+	var stored models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", suite.job.ID).First(&stored).Error)
+	assert.Equal(suite.T(), "First", *stored.Title)
+
+	// Transcript edits are versioned with the rest of the job
+	edit := map[string]interface{}{"segments": []map[string]interface{}{{"id": 0, "text": "Hi there."}}}
+	w = suite.request(http.MethodPut, base+"/transcript/segments", edit, `"1"`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	w = suite.request(http.MethodPut, base+"/transcript/segments", edit, `"2"`)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var rev models.TranscriptRevision
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &rev))
+	assert.Equal(suite.T(), 3, rev.JobVersion)
+	assert.Equal(suite.T(), `"3"`, w.Header().Get("ETag"))
+	w = suite.request(http.MethodPost, base+"/transcript/revisions/1/revert", nil, `"2"`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+
+	w = suite.request(http.MethodPost, base+"/speakers", map[string]interface{}{"mappings": []map[string]string{{"original_speaker": "SPEAKER_00", "custom_name": "Ann"}}}, `"2"`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	var mappings int64
+	suite.helper.DB.Model(&models.SpeakerMapping{}).Where("transcription_job_id = ?", suite.job.ID).Count(&mappings)
+	assert.Zero(suite.T(), mappings, "a refused edit changes nothing")
+
+	// Edits without If-Match apply to the current version
+	w = suite.request(http.MethodPut, base+"/recorded-at", map[string]string{"recorded_at": "2026-01-02"}, "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), `"4"`, w.Header().Get("ETag"))
+
+	w = suite.request(http.MethodPut, base+"/title", map[string]string{"title": "Third"}, "soon")
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	w = suite.request(http.MethodPut, "/api/v1/transcription/missing/title", map[string]string{"title": "Gone"}, `"1"`)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code)
+}
+
+// Test the flow of the web editor, which sends back the ETag of its last
+// response on speaker and note edits
+func (suite *JobVersionTestSuite) TestEditorFlow() {
+	base := "/api/v1/transcription/" + suite.job.ID
+	note := models.Note{ID: "editor-note", TranscriptionID: suite.job.ID, Quote: "Hello", Content: "Original"}
+	suite.Require().NoError(suite.helper.DB.Create(&note).Error)
+
+	// Two editors open the job at version 1
+	w := suite.request(http.MethodGet, base, nil, "")
+	suite.Require().Equal(http.StatusOK, w.Code)
+	first := w.Header().Get("ETag")
+	second := first
+
+	rename := map[string]interface{}{"mappings": []map[string]string{{"original_speaker": "SPEAKER_00", "custom_name": "Ann"}}}
+	w = suite.request(http.MethodPost, base+"/speakers", rename, first)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	first = w.Header().Get("ETag")
+	assert.Equal(suite.T(), `"2"`, first)
+
+	w = suite.request(http.MethodPut, "/api/v1/notes/editor-note", map[string]string{"content": "Ann's edit"}, first)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), `"3"`, w.Header().Get("ETag"))
+
+	// The second editor's copy is stale, so their edits are refused
+	w = suite.request(http.MethodPut, "/api/v1/notes/editor-note", map[string]string{"content": "Bob's edit"}, second)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	w = suite.request(http.MethodDelete, "/api/v1/notes/editor-note", nil, second)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	var stored models.Note
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", note.ID).First(&stored).Error)
+	assert.Equal(suite.T(), "Ann's edit", stored.Content)
+
+	// Reloading picks up the current version
+	w = suite.request(http.MethodGet, base, nil, "")
+	second = w.Header().Get("ETag")
+	w = suite.request(http.MethodDelete, "/api/v1/notes/editor-note", nil, second)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), `"4"`, w.Header().Get("ETag"))
+}
+
+func (suite *JobVersionTestSuite) TestTags() {
+	base := "/api/v1/transcription/" + suite.job.ID
+	w := suite.request(http.MethodPost, base+"/tags", map[string][]string{"tags": []string{"a"}}, "")
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var tags api.JobTagsResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &tags))
+	assert.Equal(suite.T(), 2, tags.Version)
+
+	w = suite.request(http.MethodPut, base+"/tags", map[string][]string{"tags": []string{"b"}}, `"1"`)
+	assert.Equal(suite.T(), http.StatusConflict, w.Code)
+	w = suite.request(http.MethodDelete, base+"/tags/a", nil, `"2"`)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &tags))
+	assert.Empty(suite.T(), tags.Tags)
+	assert.Equal(suite.T(), 3, tags.Version)
+}
+
+// Test edits saved at once each get the version their own bump produced
+func (suite *JobVersionTestSuite) TestConcurrentBumps() {
+	const editors = 20
+	versions := make(chan int, editors)
+	var wg sync.WaitGroup
+	for i := 0; i < editors; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			version, err := jobversion.Bump(suite.helper.DB, suite.job.ID, nil)
+			assert.NoError(suite.T(), err)
+			versions <- version
+		}()
+	}
+	wg.Wait()
+	close(versions)
+
+	var got []int
+	for version := range versions {
+		got = append(got, version)
+	}
+	sort.Ints(got)
+	want := make([]int, editors)
+	for i := range want {
+		want[i] = i + 2
+	}
+	assert.Equal(suite.T(), want, got)
+}
+
+func TestJobVersionTestSuite(t *testing.T) {
+	suite.Run(t, new(JobVersionTestSuite))
+}
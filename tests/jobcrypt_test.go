@@ -0,0 +1,216 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"synthezia/internal/jobcrypt"
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobCryptTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	fs      *fsys.MemFS
+	clock   *clock.Fake
+	service *jobcrypt.Service
+	key     []byte
+}
+
+func (suite *JobCryptTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "jobcrypt_test.db")
+	suite.fs = fsys.NewMemFS()
+	require.NoError(suite.T(), suite.fs.MkdirAll("uploads", 0755))
+	suite.clock = clock.NewFake(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	suite.service = jobcrypt.NewService(suite.helper.DB)
+	suite.service.SetFS(suite.fs)
+	suite.service.SetClock(suite.clock)
+	suite.key = make([]byte, jobcrypt.KeySize)
+	_, err := rand.Read(suite.key)
+	require.NoError(suite.T(), err)
+}
+
+func (suite *JobCryptTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// seal encrypts data with key in memory
+func (suite *JobCryptTestSuite) seal(data, key []byte) []byte {
+	var buf bytes.Buffer
+	w, err := jobcrypt.NewWriter(&buf, key)
+	require.NoError(suite.T(), err)
+	_, err = w.Write(data)
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), w.Close())
+	return buf.Bytes()
+}
+
+// Test sealed data decrypts back for sizes around the chunk boundaries
+func (suite *JobCryptTestSuite) TestStreamRoundTrip() {
+	for _, size := range []int{0, 1, 64*1024 - 1, 64 * 1024, 64*1024 + 1, 200 * 1024} {
+		data := make([]byte, size)
+		_, err := rand.Read(data)
+		require.NoError(suite.T(), err)
+		sealed := suite.seal(data, suite.key)
+
+		r, err := jobcrypt.NewReader(bytes.NewReader(sealed), int64(len(sealed)), suite.key)
+		require.NoError(suite.T(), err, "size %d", size)
+		assert.Equal(suite.T(), int64(size), r.Size())
+		plain, err := io.ReadAll(r)
+		require.NoError(suite.T(), err)
+		assert.True(suite.T(), bytes.Equal(data, plain), "size %d", size)
+	}
+}
+
+// Test reads can start anywhere in the plaintext
+func (suite *JobCryptTestSuite) TestStreamSeek() {
+	data := make([]byte, 150*1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	sealed := suite.seal(data, suite.key)
+	r, err := jobcrypt.NewReader(bytes.NewReader(sealed), int64(len(sealed)), suite.key)
+	require.NoError(suite.T(), err)
+
+	offset := int64(64*1024 - 10)
+	_, err = r.Seek(offset, io.SeekStart)
+	require.NoError(suite.T(), err)
+	part := make([]byte, 20)
+	_, err = io.ReadFull(r, part)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), data[offset:offset+20], part)
+
+	pos, err := r.Seek(-5, io.SeekEnd)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(len(data)-5), pos)
+	tail, err := io.ReadAll(r)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), data[len(data)-5:], tail)
+}
+
+// Test a wrong key and truncated data are both rejected
+func (suite *JobCryptTestSuite) TestStreamRejectsTampering() {
+	data := make([]byte, 100*1024)
+	sealed := suite.seal(data, suite.key)
+
+	other := make([]byte, jobcrypt.KeySize)
+	_, err := jobcrypt.NewReader(bytes.NewReader(sealed), int64(len(sealed)), other)
+	assert.ErrorIs(suite.T(), err, jobcrypt.ErrWrongKey)
+
+	// Dropping the final chunk leaves a valid-looking but non-final last chunk
+	truncated := sealed[:len(sealed)-(len(sealed)-12)%(64*1024+16)]
+	r, err := jobcrypt.NewReader(bytes.NewReader(truncated), int64(len(truncated)), suite.key)
+	if err == nil {
+		_, err = io.ReadAll(r)
+	}
+	assert.Error(suite.T(), err)
+}
+
+// Test database values round-trip and are recognisable once sealed
+func (suite *JobCryptTestSuite) TestSealText() {
+	sealed, err := jobcrypt.SealText("hello world", suite.key)
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), jobcrypt.IsSealedText(sealed))
+	assert.NotContains(suite.T(), sealed, "hello")
+
+	plain, err := jobcrypt.OpenText(sealed, suite.key)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "hello world", plain)
+
+	_, err = jobcrypt.OpenText(sealed, make([]byte, jobcrypt.KeySize))
+	assert.ErrorIs(suite.T(), err, jobcrypt.ErrWrongKey)
+	assert.False(suite.T(), jobcrypt.IsSealedText("hello world"))
+}
+
+// Test keys are parsed from base64 and must be 32 bytes
+func (suite *JobCryptTestSuite) TestParseKey() {
+	key, err := jobcrypt.ParseKey("AAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAAA=")
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), key, jobcrypt.KeySize)
+
+	_, err = jobcrypt.ParseKey("c2hvcnQ=")
+	assert.Error(suite.T(), err)
+	_, err = jobcrypt.ParseKey("not base64!")
+	assert.Error(suite.T(), err)
+}
+
+// encryptedJob creates a completed job submitted with suite.key
+func (suite *JobCryptTestSuite) encryptedJob() *models.TranscriptionJob {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Encrypted")
+	job.AudioPath = "uploads/" + job.ID + ".mp3"
+	_, err := fsys.WriteFileAtomic(suite.fs, "", job.AudioPath, strings.NewReader("secret audio"))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), suite.helper.DB.Model(job).Updates(map[string]interface{}{
+		"audio_path":          job.AudioPath,
+		"status":              models.StatusCompleted,
+		"transcript":          `{"text":"secret words"}`,
+		"encrypted":           true,
+		"encryption_key_hash": jobcrypt.Fingerprint(suite.key),
+	}).Error)
+	return job
+}
+
+// Test sealing replaces the audio and transcript with encrypted copies
+func (suite *JobCryptTestSuite) TestSealJob() {
+	stop := jobstate.RecordEvents()
+	defer stop()
+	job := suite.encryptedJob()
+	suite.service.Hold(job.ID, suite.key)
+
+	require.NoError(suite.T(), suite.service.Seal(context.Background(), job.ID, suite.key))
+
+	var stored models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	require.NotNil(suite.T(), stored.EncryptedAt)
+	assert.True(suite.T(), stored.EncryptedAt.Equal(suite.clock.Now()))
+	assert.Equal(suite.T(), job.AudioPath+".enc", stored.AudioPath)
+	assert.False(suite.T(), fsys.Exists(suite.fs, job.AudioPath))
+	require.NotNil(suite.T(), stored.Transcript)
+	assert.True(suite.T(), jobcrypt.IsSealedText(*stored.Transcript))
+
+	transcript, err := jobcrypt.OpenText(*stored.Transcript, suite.key)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), `{"text":"secret words"}`, transcript)
+
+	reader, closer, err := jobcrypt.OpenFile(suite.fs, stored.AudioPath, suite.key)
+	require.NoError(suite.T(), err)
+	defer closer.Close()
+	audio, err := io.ReadAll(reader)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "secret audio", string(audio))
+
+	events, err := jobstate.Timeline(job.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), events, 1)
+	assert.Equal(suite.T(), jobcrypt.EventSealed, events[0].Event)
+
+	// Sealing again is a no-op
+	require.NoError(suite.T(), suite.service.Seal(context.Background(), job.ID, suite.key))
+}
+
+// Test a job is not sealed with a key other than the one it was submitted with
+func (suite *JobCryptTestSuite) TestSealWrongKey() {
+	job := suite.encryptedJob()
+	other := make([]byte, jobcrypt.KeySize)
+
+	err := suite.service.Seal(context.Background(), job.ID, other)
+	assert.ErrorIs(suite.T(), err, jobcrypt.ErrWrongKey)
+	assert.True(suite.T(), fsys.Exists(suite.fs, job.AudioPath))
+	assert.False(suite.T(), fsys.Exists(suite.fs, job.AudioPath+".enc"))
+}
+
+func TestJobCryptTestSuite(t *testing.T) {
+	suite.Run(t, new(JobCryptTestSuite))
+}
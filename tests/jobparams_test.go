@@ -0,0 +1,109 @@
+package tests
+
+import (
+	"testing"
+
+	"synthezia/internal/config"
+	"synthezia/internal/jobparams"
+	"synthezia/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type JobParamsTestSuite struct {
+	suite.Suite
+	options jobparams.Options
+}
+
+func (suite *JobParamsTestSuite) SetupTest() {
+	suite.options = jobparams.FromConfig(&config.Config{
+		TranscriptionDefaultModel:     "base",
+		TranscriptionDefaultBatchSize: 4,
+		TranscriptionModels:           "tiny, base ,small",
+		TranscriptionMaxBatchSize:     16,
+	})
+}
+
+// Test settings left unset keep the built-in defaults
+func (suite *JobParamsTestSuite) TestFromConfig() {
+	assert.Equal(suite.T(), "base", suite.options.Model)
+	assert.Equal(suite.T(), 4, suite.options.BatchSize)
+	assert.Equal(suite.T(), []string{"tiny", "base", "small"}, suite.options.Models)
+	assert.Equal(suite.T(), 16, suite.options.MaxBatchSize)
+	assert.Equal(suite.T(), jobparams.Default.ComputeType, suite.options.ComputeType)
+	assert.Equal(suite.T(), jobparams.Default.MaxBeamSize, suite.options.MaxBeamSize)
+
+	assert.NoError(suite.T(), suite.options.Check())
+	tooBig := jobparams.FromConfig(&config.Config{TranscriptionDefaultBatchSize: 64, TranscriptionMaxBatchSize: 32})
+	assert.Error(suite.T(), tooBig.Check(), "the default batch size is over the limit")
+	notAllowed := jobparams.FromConfig(&config.Config{TranscriptionModels: "large-v3"})
+	assert.Error(suite.T(), notAllowed.Check(), "the default model is not allowed")
+}
+
+// Test options a job leaves out take the server defaults
+func (suite *JobParamsTestSuite) TestFill() {
+	params := models.WhisperXParams{Model: "tiny", Temperature: 0.4}
+	suite.options.Fill(&params)
+	assert.Equal(suite.T(), "whisper", params.ModelFamily)
+	assert.Equal(suite.T(), "tiny", params.Model)
+	assert.Equal(suite.T(), 4, params.BatchSize)
+	assert.Equal(suite.T(), "float32", params.ComputeType)
+	assert.Equal(suite.T(), 5, params.BeamSize)
+	assert.Equal(suite.T(), "pyannote", params.VadMethod)
+	assert.Equal(suite.T(), 0.4, params.Temperature)
+	assert.NoError(suite.T(), suite.options.Validate(params))
+
+	defaults := suite.options.Defaults()
+	assert.Equal(suite.T(), "base", defaults.Model)
+	assert.Equal(suite.T(), "transcribe", defaults.Task)
+	assert.NoError(suite.T(), suite.options.Validate(defaults))
+}
+
+// Test only a server on the fake backend accepts the fake model family
+func (suite *JobParamsTestSuite) TestFakeModelFamily() {
+	params := suite.options.Defaults()
+	params.ModelFamily = jobparams.FakeModelFamily
+	err := suite.options.Validate(params)
+	require.Error(suite.T(), err)
+	assert.Contains(suite.T(), err.Error(), "model_family must be one of whisper, nvidia_parakeet, nvidia_canary")
+
+	fake := jobparams.FromConfig(&config.Config{TranscriptionBackend: "fake"})
+	params = fake.Defaults()
+	params.ModelFamily = jobparams.FakeModelFamily
+	assert.NoError(suite.T(), fake.Validate(params))
+}
+
+// Test every option past its limit is reported
+func (suite *JobParamsTestSuite) TestValidate() {
+	params := suite.options.Defaults()
+	params.Model = "large-v3"
+	params.BatchSize = 17
+	params.ComputeType = "float8"
+	params.Device = "tpu"
+	params.BeamSize = 11
+	params.Temperature = 1.5
+	params.VadMethod = "webrtc"
+	params.VadOnset = 1
+	params.ChunkSize = 60
+
+	err := suite.options.Validate(params)
+	require.Error(suite.T(), err)
+	for _, option := range []string{"model", "batch_size", "compute_type", "device", "beam_size", "temperature", "vad_method", "vad_onset", "chunk_size"} {
+		assert.Contains(suite.T(), err.Error(), option+" must")
+	}
+	assert.NotContains(suite.T(), err.Error(), "vad_offset")
+
+	// Other model families pick their own models
+	params = suite.options.Defaults()
+	params.ModelFamily = "nvidia_parakeet"
+	params.Model = "parakeet-tdt-0.6b-v2"
+	assert.NoError(suite.T(), suite.options.Validate(params))
+	params.ModelFamily = "nvidia_unknown"
+	assert.Error(suite.T(), suite.options.Validate(params))
+}
+
+func TestJobParamsTestSuite(t *testing.T) {
+	suite.Run(t, new(JobParamsTestSuite))
+}
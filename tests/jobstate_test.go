@@ -0,0 +1,185 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"gorm.io/gorm"
+)
+
+type JobStateTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	service *jobstate.Service
+}
+
+func (suite *JobStateTestSuite) SetupSuite() {
+	suite.helper = NewTestHelper(suite.T(), "jobstate_test.db")
+}
+
+func (suite *JobStateTestSuite) TearDownSuite() {
+	suite.helper.Cleanup()
+}
+
+func (suite *JobStateTestSuite) SetupTest() {
+	suite.service = jobstate.NewService(suite.helper.DB)
+}
+
+func (suite *JobStateTestSuite) reload(jobID string) models.TranscriptionJob {
+	var job models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", jobID).First(&job).Error)
+	return job
+}
+
+// Test the allowed transition table
+func (suite *JobStateTestSuite) TestCanTransition() {
+	assert.True(suite.T(), jobstate.CanTransition(models.StatusPending, models.StatusProcessing))
+	assert.True(suite.T(), jobstate.CanTransition(models.StatusProcessing, models.StatusCompleted))
+	assert.True(suite.T(), jobstate.CanTransition(models.StatusFailed, models.StatusPending))
+	assert.True(suite.T(), jobstate.CanTransition(models.StatusCompleted, models.StatusCompleted))
+	assert.False(suite.T(), jobstate.CanTransition(models.StatusCompleted, models.StatusProcessing))
+	assert.False(suite.T(), jobstate.CanTransition(models.StatusFailed, models.StatusCompleted))
+}
+
+// Test a valid transition updates the row and emits an event
+func (suite *JobStateTestSuite) TestTransitionEmitsEvent() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "State Event")
+
+	var events []jobstate.Event
+	unsubscribe := suite.service.Subscribe(func(e jobstate.Event) { events = append(events, e) })
+	defer unsubscribe()
+
+	_, err := suite.service.Transition(job.ID, models.StatusProcessing)
+	require.NoError(suite.T(), err)
+	_, err = suite.service.Transition(job.ID, models.StatusFailed, jobstate.WithError("boom"))
+	require.NoError(suite.T(), err)
+
+	stored := suite.reload(job.ID)
+	assert.Equal(suite.T(), models.StatusFailed, stored.Status)
+	require.NotNil(suite.T(), stored.ErrorMessage)
+	assert.Equal(suite.T(), "boom", *stored.ErrorMessage)
+
+	require.Len(suite.T(), events, 2)
+	assert.Equal(suite.T(), models.StatusPending, events[0].From)
+	assert.Equal(suite.T(), models.StatusProcessing, events[0].To)
+	assert.Equal(suite.T(), "boom", events[1].Error)
+	assert.False(suite.T(), events[1].At.IsZero())
+}
+
+// Test invalid transitions are rejected without touching the row
+func (suite *JobStateTestSuite) TestInvalidTransition() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "State Invalid")
+	_, err := suite.service.Transition(job.ID, models.StatusCompleted)
+	assert.True(suite.T(), errors.Is(err, jobstate.ErrInvalidTransition))
+	assert.Equal(suite.T(), models.StatusPending, suite.reload(job.ID).Status)
+
+	_, err = suite.service.Transition("missing-job", models.StatusProcessing)
+	assert.True(suite.T(), errors.Is(err, gorm.ErrRecordNotFound))
+}
+
+// Test staying in the same status applies fields but emits nothing
+func (suite *JobStateTestSuite) TestSelfTransition() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "State Self")
+
+	emitted := 0
+	unsubscribe := suite.service.Subscribe(func(jobstate.Event) { emitted++ })
+	defer unsubscribe()
+
+	_, err := suite.service.Transition(job.ID, models.StatusPending, jobstate.WithError("retrying"))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, emitted)
+	assert.Equal(suite.T(), "retrying", *suite.reload(job.ID).ErrorMessage)
+}
+
+// Test extra transaction writes roll back with a failed transition
+func (suite *JobStateTestSuite) TestWithTxRollsBack() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "State Rollback")
+
+	_, err := suite.service.Transition(job.ID, models.StatusProcessing, jobstate.WithTx(func(tx *gorm.DB) error {
+		return errors.New("extra write failed")
+	}))
+	assert.Error(suite.T(), err)
+	assert.Equal(suite.T(), models.StatusPending, suite.reload(job.ID).Status)
+}
+
+// Test only one of several concurrent claims changes the status
+func (suite *JobStateTestSuite) TestConcurrentClaims() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "State Concurrent")
+
+	var mu sync.Mutex
+	events := 0
+	unsubscribe := suite.service.Subscribe(func(jobstate.Event) {
+		mu.Lock()
+		events++
+		mu.Unlock()
+	})
+	defer unsubscribe()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			suite.service.Transition(job.ID, models.StatusProcessing)
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(suite.T(), 1, events)
+	assert.Equal(suite.T(), models.StatusProcessing, suite.reload(job.ID).Status)
+}
+
+// Test a panicking listener does not break the transition
+func (suite *JobStateTestSuite) TestListenerPanicRecovered() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "State Panic")
+	unsubscribe := suite.service.Subscribe(func(jobstate.Event) { panic("listener bug") })
+	defer unsubscribe()
+
+	_, err := suite.service.Transition(job.ID, models.StatusProcessing)
+	assert.NoError(suite.T(), err)
+}
+
+// Test a job's lifecycle can be read back from stored events
+func (suite *JobStateTestSuite) TestRecordedTimeline() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "State Timeline")
+	stop := suite.service.RecordEvents()
+	defer stop()
+
+	jobstate.Created(context.Background(), job.ID, models.StatusPending, "source", "test")
+	_, err := suite.service.Transition(job.ID, models.StatusProcessing)
+	require.NoError(suite.T(), err)
+	_, err = suite.service.Transition(job.ID, models.StatusCompleted)
+	require.NoError(suite.T(), err)
+
+	events, err := suite.service.Timeline(job.ID)
+	require.NoError(suite.T(), err)
+	var names []string
+	for _, event := range events {
+		names = append(names, event.Event)
+	}
+	assert.Equal(suite.T(), []string{"uploaded", "queued", "transcribing", "completed"}, names)
+	require.NotNil(suite.T(), events[0].Attrs)
+	assert.JSONEq(suite.T(), `{"source":"test"}`, *events[0].Attrs)
+	require.NotNil(suite.T(), events[2].Attrs)
+	assert.JSONEq(suite.T(), `{"from":"pending"}`, *events[2].Attrs)
+
+	// Nothing is stored once recording stops
+	stop()
+	_, err = suite.service.Transition(job.ID, models.StatusPending)
+	require.NoError(suite.T(), err)
+	events, err = suite.service.Timeline(job.ID)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), events, 4)
+}
+
+func TestJobStateTestSuite(t *testing.T) {
+	suite.Run(t, new(JobStateTestSuite))
+}
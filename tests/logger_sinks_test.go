@@ -0,0 +1,156 @@
+package tests
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"synthezia/pkg/logger"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type LoggerSinksTestSuite struct {
+	suite.Suite
+}
+
+func (suite *LoggerSinksTestSuite) SetupTest() {
+	logger.Init("debug")
+}
+
+func (suite *LoggerSinksTestSuite) TearDownTest() {
+	require.NoError(suite.T(), logger.ConfigureSinks(logger.SinkConfig{}))
+	logger.SetOutput(os.Stdout)
+	logger.Init("info")
+}
+
+// Test RFC 5424 messages over UDP with priority and structured data
+func (suite *LoggerSinksTestSuite) TestSyslogUDP() {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	require.NoError(suite.T(), err)
+	defer conn.Close()
+
+	require.NoError(suite.T(), logger.ConfigureSinks(logger.SinkConfig{
+		Outputs:        []string{"syslog"},
+		SyslogAddress:  "udp://" + conn.LocalAddr().String(),
+		SyslogFacility: "local0",
+	}))
+
+	logger.Error("Upload failed", "job_id", `a"b]`)
+
+	buf := make([]byte, 4096)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	require.NoError(suite.T(), err)
+	msg := string(buf[:n])
+
+	// local0 (16) * 8 + err (3)
+	assert.True(suite.T(), strings.HasPrefix(msg, "<131>1 "), msg)
+	assert.Contains(suite.T(), msg, " synthezia ")
+	assert.Contains(suite.T(), msg, `[attrs@32473 job_id="a\"b\]"]`)
+	assert.True(suite.T(), strings.HasSuffix(msg, " Upload failed"), msg)
+}
+
+// Test octet-counted framing over TCP and module level filtering
+func (suite *LoggerSinksTestSuite) TestSyslogTCP() {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(suite.T(), err)
+	defer listener.Close()
+
+	frames := make(chan string, 4)
+	go func() {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			length, err := reader.ReadString(' ')
+			if err != nil {
+				return
+			}
+			size, _ := strconv.Atoi(strings.TrimSpace(length))
+			frame := make([]byte, size)
+			if _, err := io.ReadFull(reader, frame); err != nil {
+				return
+			}
+			frames <- string(frame)
+		}
+	}()
+
+	require.NoError(suite.T(), logger.ConfigureSinks(logger.SinkConfig{
+		Outputs:       []string{"syslog"},
+		SyslogAddress: "tcp://" + listener.Addr().String(),
+	}))
+
+	logger.SetModuleLevel(logger.ModuleQueue, "warn")
+	defer logger.SetModuleLevel(logger.ModuleQueue, "")
+	queueLog := logger.Module(logger.ModuleQueue)
+	queueLog.Info("Filtered out")
+	queueLog.Warn("Worker stalled")
+
+	select {
+	case frame := <-frames:
+		// daemon (3) * 8 + warning (4)
+		assert.True(suite.T(), strings.HasPrefix(frame, "<28>1 "), frame)
+		assert.Contains(suite.T(), frame, `module="queue"`)
+		assert.True(suite.T(), strings.HasSuffix(frame, "Worker stalled"), frame)
+	case <-time.After(2 * time.Second):
+		suite.T().Fatal("no syslog frame received")
+	}
+}
+
+// Test journald native protocol fields, including multi-line values
+func (suite *LoggerSinksTestSuite) TestJournald() {
+	socket := filepath.Join(suite.T().TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	require.NoError(suite.T(), err)
+	defer conn.Close()
+
+	require.NoError(suite.T(), logger.ConfigureSinks(logger.SinkConfig{
+		Outputs:        []string{"journald"},
+		JournaldSocket: socket,
+		AppName:        "synthezia-test",
+	}))
+
+	logger.Warn("Slow request", "request.path", "/api", "trace", "line1\nline2")
+
+	buf := make([]byte, 8192)
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, err := conn.Read(buf)
+	require.NoError(suite.T(), err)
+	msg := string(buf[:n])
+
+	assert.Contains(suite.T(), msg, "MESSAGE=Slow request\n")
+	assert.Contains(suite.T(), msg, "PRIORITY=4\n")
+	assert.Contains(suite.T(), msg, "SYSLOG_IDENTIFIER=synthezia-test\n")
+	assert.Contains(suite.T(), msg, "REQUEST_PATH=/api\n")
+	assert.Contains(suite.T(), msg, "TRACE\n\x0b\x00\x00\x00\x00\x00\x00\x00line1\nline2\n")
+}
+
+// Test configuration errors leave the existing outputs in place
+func (suite *LoggerSinksTestSuite) TestInvalidConfig() {
+	assert.Error(suite.T(), logger.ConfigureSinks(logger.SinkConfig{Outputs: []string{"kafka"}}))
+	assert.Error(suite.T(), logger.ConfigureSinks(logger.SinkConfig{Outputs: []string{"syslog"}}))
+	assert.Error(suite.T(), logger.ConfigureSinks(logger.SinkConfig{
+		Outputs: []string{"syslog"}, SyslogAddress: "udp://127.0.0.1:514", SyslogFacility: "nope",
+	}))
+	assert.Error(suite.T(), logger.ConfigureSinks(logger.SinkConfig{
+		Outputs: []string{"journald"}, JournaldSocket: filepath.Join(suite.T().TempDir(), "missing.sock"),
+	}))
+
+	assert.Equal(suite.T(), []string{"stdout", "syslog"}, logger.ParseOutputs(" stdout, SYSLOG ,"))
+}
+
+func TestLoggerSinksTestSuite(t *testing.T) {
+	suite.Run(t, new(LoggerSinksTestSuite))
+}
@@ -2,11 +2,15 @@ package tests
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"synthezia/pkg/logger"
 
@@ -492,6 +496,250 @@ func (suite *LoggerTestSuite) TestLoggerLongMessages() {
 	logger.Debug(longMessage, "key", strings.Repeat("B", 5000))
 }
 
+// Test module overrides are read from LOG_LEVEL_<MODULE>
+func (suite *LoggerTestSuite) TestModuleLevelsFromEnv() {
+	suite.T().Setenv("LOG_LEVEL_DROPZONE", "debug")
+	suite.T().Setenv("LOG_LEVEL_HTTP", "warn")
+	suite.T().Setenv("LOG_LEVEL_QUEUE", "nonsense")
+	logger.Init("info")
+
+	assert.Equal(suite.T(), logger.LevelDebug, logger.ModuleLevel(logger.ModuleDropzone))
+	assert.Equal(suite.T(), logger.LevelWarn, logger.ModuleLevel("HTTP"))
+	assert.Equal(suite.T(), logger.LevelInfo, logger.ModuleLevel(logger.ModuleQueue))
+	assert.Equal(suite.T(), logger.LevelInfo, logger.ModuleLevel("unknown"))
+}
+
+// Test module loggers apply their own threshold
+func (suite *LoggerTestSuite) TestModuleLoggerThreshold() {
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	defer logger.SetOutput(os.Stdout)
+
+	logger.SetModuleLevel("dropzone", "debug")
+	logger.SetModuleLevel("http", "error")
+	defer logger.SetModuleLevel("dropzone", "")
+	defer logger.SetModuleLevel("http", "")
+
+	logger.Module("dropzone").Debug("dropzone detail")
+	logger.WithContext("module", "http").Warn("http warning")
+	logger.Debug("global detail")
+	logger.Module("queue").Info("queue info")
+
+	out := buf.String()
+	assert.Contains(suite.T(), out, "dropzone detail")
+	assert.Contains(suite.T(), out, "module=dropzone")
+	assert.NotContains(suite.T(), out, "http warning")
+	assert.NotContains(suite.T(), out, "global detail")
+	assert.Contains(suite.T(), out, "queue info")
+}
+
+// Test LOG_LEVEL_HTTP=warn only keeps failed requests
+func (suite *LoggerTestSuite) TestGinLoggerHTTPModuleLevel() {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	defer logger.SetOutput(os.Stdout)
+	logger.SetModuleLevel(logger.ModuleHTTP, "warn")
+	defer logger.SetModuleLevel(logger.ModuleHTTP, "")
+
+	router := gin.New()
+	router.Use(logger.GinLogger())
+	router.GET("/ok", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/missing", func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	for _, path := range []string{"/ok", "/missing"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	assert.NotContains(suite.T(), buf.String(), "/ok")
+	assert.Contains(suite.T(), buf.String(), "/missing")
+}
+
+// Test request sampling rules thin out matching paths but keep failures
+func (suite *LoggerTestSuite) TestGinLoggerSampling() {
+	gin.SetMode(gin.TestMode)
+	var buf bytes.Buffer
+	logger.SetOutput(&buf)
+	defer logger.SetOutput(os.Stdout)
+
+	rules, err := logger.ParseSampleRules("/health=3, /api/*/status=0")
+	assert.NoError(suite.T(), err)
+	logger.SetHTTPSampling(rules)
+	defer logger.SetHTTPSampling(logger.DefaultHTTPSampling)
+
+	router := gin.New()
+	router.Use(logger.GinLogger())
+	router.GET("/health", func(c *gin.Context) { c.Status(http.StatusOK) })
+	router.GET("/api/v1/transcription/:id/status", func(c *gin.Context) {
+		if c.Param("id") == "missing" {
+			c.Status(http.StatusNotFound)
+			return
+		}
+		c.Status(http.StatusOK)
+	})
+	router.GET("/api/v1/transcription/list", func(c *gin.Context) { c.Status(http.StatusOK) })
+
+	for i := 0; i < 7; i++ {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/health", nil))
+	}
+	for _, path := range []string{"/api/v1/transcription/abc/status", "/api/v1/transcription/missing/status", "/api/v1/transcription/list"} {
+		router.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", path, nil))
+	}
+
+	output := buf.String()
+	// Requests 1, 4 and 7 of the health checks
+	assert.Equal(suite.T(), 3, strings.Count(output, "GET /health "))
+	assert.NotContains(suite.T(), output, "/abc/status")
+	assert.Contains(suite.T(), output, "/missing/status")
+	// Custom rules replace the defaults
+	assert.Contains(suite.T(), output, "/api/v1/transcription/list")
+}
+
+// Test sampling rules are validated
+func (suite *LoggerTestSuite) TestParseSampleRules() {
+	rules, err := logger.ParseSampleRules("")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), logger.DefaultHTTPSampling, rules)
+
+	rules, err = logger.ParseSampleRules("/health=100,,*/track-progress=0")
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []logger.SampleRule{{Pattern: "/health", Rate: 100}, {Pattern: "*/track-progress", Rate: 0}}, rules)
+
+	for _, spec := range []string{"/health", "=5", "/health=often", "/health=-1"} {
+		_, err := logger.ParseSampleRules(spec)
+		assert.Error(suite.T(), err, spec)
+	}
+}
+
+// Test the in-memory buffer keeps the newest entries and filters them
+func (suite *LoggerTestSuite) TestRecentLogBuffer() {
+	logger.SetBufferSize(3)
+	defer logger.SetBufferSize(logger.DefaultBufferSize)
+
+	logger.Info("first")
+	logger.Warn("second", "job_id", "job-1")
+	logger.Module(logger.ModuleQueue).Error("third")
+	logger.Debug("filtered by global level")
+	logger.Info("fourth")
+
+	entries := logger.Recent().Entries(logger.EntryFilter{})
+	if assert.Len(suite.T(), entries, 3) {
+		assert.Equal(suite.T(), "second", entries[0].Message)
+		assert.Equal(suite.T(), "job-1", entries[0].Attrs["job_id"])
+		assert.Equal(suite.T(), "queue", entries[1].Module)
+		assert.Equal(suite.T(), "fourth", entries[2].Message)
+	}
+
+	warnings := logger.Recent().Entries(logger.EntryFilter{MinLevel: logger.LevelWarn})
+	assert.Len(suite.T(), warnings, 2)
+	assert.Len(suite.T(), logger.Recent().Entries(logger.EntryFilter{Module: "queue"}), 1)
+	assert.Len(suite.T(), logger.Recent().Entries(logger.EntryFilter{Limit: 1}), 1)
+	assert.Empty(suite.T(), logger.Recent().Entries(logger.EntryFilter{Since: time.Now().Add(time.Minute)}))
+}
+
+// Test requests over budget are logged at WARN with the job they touch
+func (suite *LoggerTestSuite) TestSlowRequestWarning() {
+	gin.SetMode(gin.TestMode)
+	logger.Init("info")
+	logger.SetBufferSize(10)
+	defer logger.SetBufferSize(logger.DefaultBufferSize)
+	defer logger.SetSlowThresholds(logger.CurrentSlowThresholds())
+	logger.SetSlowThresholds(logger.SlowThresholds{Request: time.Millisecond})
+
+	router := gin.New()
+	router.Use(logger.GinLogger())
+	router.GET("/api/v1/transcription/:id/status", func(c *gin.Context) {
+		time.Sleep(5 * time.Millisecond)
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+	router.GET("/fast", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"status": "ok"})
+	})
+
+	for _, path := range []string{"/api/v1/transcription/job-42/status", "/fast"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("GET", path, nil)
+		router.ServeHTTP(w, req)
+	}
+
+	warnings := logger.Recent().Entries(logger.EntryFilter{MinLevel: logger.LevelWarn})
+	if assert.Len(suite.T(), warnings, 1) {
+		assert.Equal(suite.T(), "Slow request", warnings[0].Message)
+		assert.Equal(suite.T(), "job-42", warnings[0].Attrs["job_id"])
+		assert.Equal(suite.T(), "/api/v1/transcription/:id/status", warnings[0].Attrs["route"])
+		assert.Equal(suite.T(), "1.00ms", warnings[0].Attrs["budget"])
+	}
+}
+
+// Test slow queries and ffmpeg stages pick up the job ID from the context
+func (suite *LoggerTestSuite) TestSlowQueryAndFFmpegWarnings() {
+	logger.Init("info")
+	logger.SetBufferSize(10)
+	defer logger.SetBufferSize(logger.DefaultBufferSize)
+	defer logger.SetSlowThresholds(logger.CurrentSlowThresholds())
+	logger.SetSlowThresholds(logger.SlowThresholds{Query: time.Millisecond, FFmpeg: time.Millisecond})
+
+	ctx := logger.WithJobID(context.Background(), "job-7")
+	assert.Equal(suite.T(), "job-7", logger.JobIDFromContext(ctx))
+
+	logger.SlowQuery(ctx, time.Microsecond, "SELECT 1", 1, nil)
+	logger.SlowQuery(ctx, 10*time.Millisecond, "SELECT * FROM transcription_jobs", 3, errors.New("boom"))
+	logger.FFmpegStage(ctx, "merge", time.Now().Add(-time.Second), "tracks", 2)
+	logger.FFmpegStage(ctx, "convert", time.Now())
+
+	warnings := logger.Recent().Entries(logger.EntryFilter{MinLevel: logger.LevelWarn})
+	if assert.Len(suite.T(), warnings, 2) {
+		assert.Equal(suite.T(), "Slow query", warnings[0].Message)
+		assert.Equal(suite.T(), "job-7", warnings[0].Attrs["job_id"])
+		assert.Equal(suite.T(), "3", warnings[0].Attrs["rows"])
+		assert.Equal(suite.T(), "boom", warnings[0].Attrs["error"])
+
+		assert.Equal(suite.T(), "Slow ffmpeg stage", warnings[1].Message)
+		assert.Equal(suite.T(), "job-7", warnings[1].Attrs["job_id"])
+		assert.Equal(suite.T(), "merge", warnings[1].Attrs["stage"])
+		assert.Equal(suite.T(), "2", warnings[1].Attrs["tracks"])
+	}
+}
+
+// Test job events go to their own JSON stream and to the hook
+func (suite *LoggerTestSuite) TestJobEventStream() {
+	var stream bytes.Buffer
+	var hooked []logger.JobEventRecord
+	logger.SetJobEventOutput(&stream)
+	logger.SetJobEventHook(func(ctx context.Context, event logger.JobEventRecord) {
+		hooked = append(hooked, event)
+	})
+	defer logger.SetJobEventOutput(nil)
+	defer logger.SetJobEventHook(nil)
+
+	logger.JobEvent(context.Background(), "job-1", logger.JobEventQueued, "from", "uploaded")
+	logger.JobEvent(nil, "job-1", logger.JobEventTranscribing, "attempt", 2)
+
+	lines := strings.Split(strings.TrimSpace(stream.String()), "\n")
+	if assert.Len(suite.T(), lines, 2) {
+		var line map[string]any
+		assert.NoError(suite.T(), json.Unmarshal([]byte(lines[0]), &line))
+		assert.Equal(suite.T(), "job_event", line["msg"])
+		assert.Equal(suite.T(), "job-1", line["job_id"])
+		assert.Equal(suite.T(), "queued", line["event"])
+		assert.Equal(suite.T(), "uploaded", line["from"])
+	}
+
+	if assert.Len(suite.T(), hooked, 2) {
+		assert.Equal(suite.T(), "job-1", hooked[1].JobID)
+		assert.Equal(suite.T(), "transcribing", hooked[1].Event)
+		assert.Equal(suite.T(), map[string]string{"attempt": "2"}, hooked[1].Attrs)
+		assert.False(suite.T(), hooked[1].Time.IsZero())
+	}
+
+	// Nothing is written once the stream is turned off
+	logger.SetJobEventOutput(nil)
+	stream.Reset()
+	logger.JobEvent(context.Background(), "job-1", logger.JobEventCompleted)
+	assert.Empty(suite.T(), stream.String())
+	assert.Len(suite.T(), hooked, 3)
+}
+
 func TestLoggerTestSuite(t *testing.T) {
 	suite.Run(t, new(LoggerTestSuite))
 }
@@ -0,0 +1,278 @@
+package tests
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"synthezia/internal/dropzone"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeIMAP serves LOGIN, SELECT, UID SEARCH UNSEEN, UID FETCH, UID STORE and
+// LOGOUT for one mailbox
+type fakeIMAP struct {
+	mu       sync.Mutex
+	listener net.Listener
+	messages map[uint32]string
+	seen     map[uint32]bool
+	password string
+}
+
+func newFakeIMAP(t *testing.T, password string) *fakeIMAP {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	f := &fakeIMAP{listener: listener, messages: make(map[uint32]string), seen: make(map[uint32]bool), password: password}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f
+}
+
+func (f *fakeIMAP) deliver(uid uint32, message string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.messages[uid] = strings.ReplaceAll(message, "\n", "\r\n")
+}
+
+func (f *fakeIMAP) isSeen(uid uint32) bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.seen[uid]
+}
+
+func (f *fakeIMAP) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	fmt.Fprint(conn, "* OK fake IMAP ready\r\n")
+	for {
+		line, err := r.ReadString('\n')
+		if err != nil {
+			return
+		}
+		fields := strings.Fields(line)
+		if len(fields) < 2 {
+			return
+		}
+		tag, command := fields[0], strings.ToUpper(fields[1])
+		if command == "UID" && len(fields) > 2 {
+			command += " " + strings.ToUpper(fields[2])
+		}
+
+		f.mu.Lock()
+		switch command {
+		case "LOGIN":
+			if fields[3] != `"`+f.password+`"` {
+				fmt.Fprintf(conn, "%s NO invalid credentials\r\n", tag)
+				f.mu.Unlock()
+				continue
+			}
+		case "UID SEARCH":
+			var uids []int
+			for uid := range f.messages {
+				if !f.seen[uid] {
+					uids = append(uids, int(uid))
+				}
+			}
+			sort.Ints(uids)
+			reply := "* SEARCH"
+			for _, uid := range uids {
+				reply += " " + strconv.Itoa(uid)
+			}
+			fmt.Fprint(conn, reply+"\r\n")
+		case "UID FETCH":
+			uid, _ := strconv.Atoi(fields[3])
+			if message, ok := f.messages[uint32(uid)]; ok {
+				fmt.Fprintf(conn, "* 1 FETCH (UID %d BODY[] {%d}\r\n%s)\r\n", uid, len(message), message)
+			}
+		case "UID STORE":
+			uid, _ := strconv.Atoi(fields[3])
+			f.seen[uint32(uid)] = true
+		case "LOGOUT":
+			fmt.Fprint(conn, "* BYE\r\n")
+		}
+		f.mu.Unlock()
+		fmt.Fprintf(conn, "%s OK done\r\n", tag)
+		if command == "LOGOUT" {
+			return
+		}
+	}
+}
+
+type MailboxTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	fs      *fsys.MemFS
+	imap    *fakeIMAP
+	service *dropzone.Service
+	sent    []string
+}
+
+func (suite *MailboxTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "mailbox_test.db")
+	suite.fs = fsys.NewMemFS()
+	suite.imap = newFakeIMAP(suite.T(), "mail-secret")
+	suite.sent = nil
+
+	cfg := suite.helper.Config
+	cfg.UploadDir = "uploads"
+	cfg.PublicURL = "https://synthezia.example.com/"
+	cfg.DropzoneIMAPAddr = suite.imap.listener.Addr().String()
+	cfg.DropzoneIMAPTLS = false
+	cfg.DropzoneIMAPUsername = "inbox@example.com"
+	cfg.DropzoneIMAPPassword = "mail-secret"
+	cfg.DropzoneIMAPMailbox = "INBOX"
+	cfg.DropzoneIMAPOptions = "auto_transcribe=false"
+	cfg.SMTPFrom = "Synthezia <noreply@example.com>"
+	suite.service = dropzone.NewService(cfg, nil)
+	suite.service.SetFS(suite.fs)
+
+	email := "alice@example.com"
+	require.NoError(suite.T(), suite.helper.DB.Model(&models.User{}).Where("username = ?", "testuser").Update("email", email).Error)
+}
+
+func (suite *MailboxTestSuite) TearDownTest() {
+	suite.imap.listener.Close()
+	suite.helper.Cleanup()
+}
+
+func (suite *MailboxTestSuite) watcher() *dropzone.MailboxWatcher {
+	watcher, err := dropzone.NewMailboxWatcher(suite.service, suite.helper.Config)
+	require.NoError(suite.T(), err)
+	watcher.SetSendMail(func(to string, msg []byte) error {
+		suite.sent = append(suite.sent, to+"\n"+string(msg))
+		return nil
+	})
+	return watcher
+}
+
+const mailWithAttachments = `From: Alice <Alice@Example.com>
+To: inbox@example.com
+Subject: Interview recordings
+Message-Id: <interview-1@example.com>
+MIME-Version: 1.0
+Content-Type: multipart/mixed; boundary="outer"
+
+--outer
+Content-Type: text/plain
+
+Two takes attached.
+--outer
+Content-Type: audio/mpeg; name="take1.mp3"
+Content-Disposition: attachment; filename="take1.mp3"
+Content-Transfer-Encoding: base64
+
+bWFpbGVkIHRha2Ugb25l
+--outer
+Content-Type: audio/wav
+Content-Disposition: attachment; filename="=?utf-8?q?take_2.wav?="
+Content-Transfer-Encoding: quoted-printable
+
+mailed take=20two
+--outer
+Content-Type: application/pdf
+Content-Disposition: attachment; filename="notes.pdf"
+
+not audio
+--outer--
+`
+
+// Test audio attached to mail from a known sender becomes that user's jobs
+func (suite *MailboxTestSuite) TestPollIngestsAttachments() {
+	suite.imap.deliver(1, mailWithAttachments)
+	suite.imap.deliver(2, "From: mallory@example.com\nSubject: Spam\nContent-Type: audio/mpeg; name=\"spam.mp3\"\n\nspam\n")
+	suite.imap.deliver(3, "From: alice@example.com\nSubject: Just text\n\nNo attachments here.\n")
+
+	count, err := suite.watcher().Poll()
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, count)
+
+	var jobs []models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("title IN ?", []string{"take1.mp3", "take 2.wav"}).Order("title").Find(&jobs).Error)
+	if assert.Len(suite.T(), jobs, 2) {
+		audio, err := fsys.ReadFile(suite.fs, jobs[0].AudioPath)
+		require.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "mailed take two", string(audio))
+		audio, err = fsys.ReadFile(suite.fs, jobs[1].AudioPath)
+		require.NoError(suite.T(), err)
+		assert.Equal(suite.T(), "mailed take one", string(audio))
+		assert.Equal(suite.T(), models.StatusUploaded, jobs[1].Status)
+	}
+
+	var records []models.IngestedMail
+	suite.helper.DB.Find(&records)
+	if assert.Len(suite.T(), records, 2) {
+		assert.Equal(suite.T(), "Alice@Example.com", records[0].Sender)
+		assert.Equal(suite.T(), "<interview-1@example.com>", records[0].MessageID)
+	}
+	var spam int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "spam.mp3").Count(&spam)
+	assert.Zero(suite.T(), spam)
+
+	// Every message was handled, so the next poll finds nothing
+	for uid := uint32(1); uid <= 3; uid++ {
+		assert.True(suite.T(), suite.imap.isSeen(uid), "message %d", uid)
+	}
+	count, err = suite.watcher().Poll()
+	require.NoError(suite.T(), err)
+	assert.Zero(suite.T(), count)
+}
+
+// Test the sender is sent a link once, after the job completes
+func (suite *MailboxTestSuite) TestReplyOnCompletion() {
+	suite.imap.deliver(1, mailWithAttachments)
+	watcher := suite.watcher()
+	_, err := watcher.Poll()
+	require.NoError(suite.T(), err)
+
+	watcher.SendPendingReplies()
+	assert.Empty(suite.T(), suite.sent, "nothing is sent before the jobs complete")
+
+	var job models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("title = ?", "take1.mp3").First(&job).Error)
+	require.NoError(suite.T(), suite.helper.DB.Model(&job).Update("status", models.StatusCompleted).Error)
+	watcher.SendPendingReplies()
+	watcher.SendPendingReplies()
+
+	if assert.Len(suite.T(), suite.sent, 1) {
+		reply := suite.sent[0]
+		assert.True(suite.T(), strings.HasPrefix(reply, "Alice@Example.com\n"))
+		assert.Contains(suite.T(), reply, "Subject: Re: Interview recordings\r\n")
+		assert.Contains(suite.T(), reply, "In-Reply-To: <interview-1@example.com>\r\n")
+		assert.Contains(suite.T(), reply, "https://synthezia.example.com/audio/"+job.ID)
+	}
+}
+
+// Test mail stays unread when the mailbox cannot be read, and options
+// cannot name a user
+func (suite *MailboxTestSuite) TestRefusals() {
+	suite.helper.Config.DropzoneIMAPPassword = "wrong"
+	suite.imap.deliver(1, mailWithAttachments)
+	_, err := suite.watcher().Poll()
+	assert.ErrorContains(suite.T(), err, "invalid credentials")
+	assert.False(suite.T(), suite.imap.isSeen(1))
+
+	suite.helper.Config.DropzoneIMAPOptions = "user=testuser"
+	_, err = dropzone.NewMailboxWatcher(suite.service, suite.helper.Config)
+	assert.ErrorContains(suite.T(), err, "DROPZONE_IMAP_OPTIONS")
+}
+
+func TestMailboxTestSuite(t *testing.T) {
+	suite.Run(t, new(MailboxTestSuite))
+}
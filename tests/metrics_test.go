@@ -0,0 +1,117 @@
+package tests
+
+import (
+	"bytes"
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"synthezia/pkg/metrics"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MetricsTestSuite struct {
+	suite.Suite
+	collector *metrics.Collector
+	now       time.Time
+}
+
+func (suite *MetricsTestSuite) SetupTest() {
+	suite.collector = metrics.NewCollector()
+	suite.now = time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+	suite.collector.SLO().SetClock(func() time.Time { return suite.now })
+}
+
+func (suite *MetricsTestSuite) statsFor(window time.Duration) metrics.WindowStats {
+	for _, ws := range suite.collector.SLO().Stats() {
+		if ws.Window == window {
+			return ws
+		}
+	}
+	suite.T().Fatalf("window %s not tracked", window)
+	return metrics.WindowStats{}
+}
+
+// Test success ratio ignores cancelled jobs
+func (suite *MetricsTestSuite) TestSuccessRatio() {
+	for i := 0; i < 3; i++ {
+		suite.collector.ObserveJobOutcome(metrics.OutcomeSucceeded)
+	}
+	suite.collector.ObserveJobOutcome(metrics.OutcomeFailed)
+	suite.collector.ObserveJobOutcome(metrics.OutcomeCancelled)
+
+	ws := suite.statsFor(5 * time.Minute)
+	assert.Equal(suite.T(), 3, ws.Succeeded)
+	assert.Equal(suite.T(), 1, ws.Failed)
+	assert.InDelta(suite.T(), 0.75, ws.SuccessRatio, 0.0001)
+}
+
+// Test samples fall out of short windows but stay in longer ones
+func (suite *MetricsTestSuite) TestRollingWindows() {
+	suite.collector.ObserveJobOutcome(metrics.OutcomeFailed)
+	suite.now = suite.now.Add(10 * time.Minute)
+	suite.collector.ObserveJobOutcome(metrics.OutcomeSucceeded)
+
+	short := suite.statsFor(5 * time.Minute)
+	assert.Equal(suite.T(), 1.0, short.SuccessRatio)
+
+	long := suite.statsFor(time.Hour)
+	assert.InDelta(suite.T(), 0.5, long.SuccessRatio, 0.0001)
+
+	suite.now = suite.now.Add(4 * 24 * time.Hour)
+	empty := suite.statsFor(72 * time.Hour)
+	assert.True(suite.T(), math.IsNaN(empty.SuccessRatio))
+}
+
+// Test p95 queue wait and processing ratio
+func (suite *MetricsTestSuite) TestPercentiles() {
+	for i := 1; i <= 20; i++ {
+		suite.collector.ObserveQueueWait(time.Duration(i) * time.Second)
+		suite.collector.ObserveProcessing(time.Duration(i)*time.Second, 10*time.Second)
+	}
+
+	ws := suite.statsFor(time.Hour)
+	assert.Equal(suite.T(), 19.0, ws.QueueWaitP95)
+	assert.InDelta(suite.T(), 1.9, ws.ProcessingRatioP95, 0.0001)
+}
+
+// Test processing without a known audio duration does not skew the ratio
+func (suite *MetricsTestSuite) TestProcessingWithoutAudioDuration() {
+	suite.collector.ObserveProcessing(30*time.Second, 0)
+
+	ws := suite.statsFor(time.Hour)
+	assert.True(suite.T(), math.IsNaN(ws.ProcessingRatioP95))
+}
+
+// Test Prometheus text exposition
+func (suite *MetricsTestSuite) TestWritePrometheus() {
+	suite.collector.ObserveJobOutcome(metrics.OutcomeSucceeded)
+	suite.collector.ObserveQueueWait(2 * time.Second)
+	suite.collector.RegisterGauge("synthezia_test_gauge", "Test gauge.", func() float64 { return 7 })
+
+	var buf bytes.Buffer
+	suite.collector.WritePrometheus(&buf)
+	out := buf.String()
+
+	assert.Contains(suite.T(), out, "# TYPE synthezia_jobs_total counter")
+	assert.Contains(suite.T(), out, `synthezia_jobs_total{outcome="succeeded"} 1`)
+	assert.Contains(suite.T(), out, `synthezia_job_queue_wait_seconds_bucket{le="5"} 1`)
+	assert.Contains(suite.T(), out, `synthezia_slo_job_success_ratio{window="5m"} 1`)
+	assert.Contains(suite.T(), out, `synthezia_slo_queue_wait_p95_seconds{window="3d"} 2`)
+	assert.Contains(suite.T(), out, `synthezia_slo_processing_ratio_p95{window="1h"} NaN`)
+	assert.Contains(suite.T(), out, "synthezia_test_gauge 7")
+
+	for _, line := range strings.Split(strings.TrimSpace(out), "\n") {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		assert.Len(suite.T(), strings.Fields(line), 2, "malformed sample line: %s", line)
+	}
+}
+
+func TestMetricsTestSuite(t *testing.T) {
+	suite.Run(t, new(MetricsTestSuite))
+}
@@ -459,6 +459,119 @@ func (suite *MiddlewareTestSuite) TestAuthMiddlewareEmptyBearerToken() {
 	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
 }
 
+// Test CSRF middleware rejects cookie-authenticated requests without a token
+func (suite *MiddlewareTestSuite) TestCSRFMiddlewareRejectsMissingToken() {
+	router := gin.New()
+	router.Use(middleware.CSRFMiddleware("synthezia_refresh_token"))
+	router.POST("/refresh", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "synthezia_refresh_token", Value: "session"})
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+}
+
+// Test CSRF middleware accepts a matching double-submit token
+func (suite *MiddlewareTestSuite) TestCSRFMiddlewareAcceptsMatchingToken() {
+	router := gin.New()
+	router.Use(middleware.CSRFMiddleware("synthezia_refresh_token"))
+	router.POST("/refresh", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "synthezia_refresh_token", Value: "session"})
+	req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: "token-123"})
+	req.Header.Set(middleware.CSRFHeaderName, "token-123")
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/refresh", nil)
+	req.AddCookie(&http.Cookie{Name: "synthezia_refresh_token", Value: "session"})
+	req.AddCookie(&http.Cookie{Name: middleware.CSRFCookieName, Value: "token-123"})
+	req.Header.Set(middleware.CSRFHeaderName, "token-456")
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusForbidden, w.Code)
+}
+
+// Test CSRF middleware exempts API key requests and safe methods
+func (suite *MiddlewareTestSuite) TestCSRFMiddlewareExemptions() {
+	router := gin.New()
+	router.Use(middleware.CSRFMiddleware("synthezia_refresh_token"))
+	router.GET("/data", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+	router.POST("/data", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("POST", "/data", nil)
+	req.AddCookie(&http.Cookie{Name: "synthezia_refresh_token", Value: "session"})
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("GET", "/data", nil)
+	req.AddCookie(&http.Cookie{Name: "synthezia_refresh_token", Value: "session"})
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+
+	w = httptest.NewRecorder()
+	req, _ = http.NewRequest("POST", "/data", nil)
+	router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusOK, w.Code)
+}
+
+// Test debug capture records sanitized bodies for matching routes only
+func (suite *MiddlewareTestSuite) TestDebugCaptureMiddleware() {
+	store := middleware.NewCaptureStore(2, 1024)
+	store.AddRule(middleware.CaptureRule{RoutePrefix: "/captured"}, "")
+
+	router := gin.New()
+	router.Use(middleware.DebugCaptureMiddleware(store))
+	router.POST("/captured", func(c *gin.Context) {
+		body, _ := io.ReadAll(c.Request.Body)
+		c.Data(http.StatusOK, "application/json", body)
+	})
+	router.POST("/ignored", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"message": "success"})
+	})
+
+	for _, path := range []string{"/captured", "/ignored"} {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", path, strings.NewReader(`{"username":"admin","password":"hunter2"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", "Bearer secret-token")
+		router.ServeHTTP(w, req)
+		assert.Equal(suite.T(), http.StatusOK, w.Code)
+	}
+
+	entries := store.Entries()
+	assert.Len(suite.T(), entries, 1)
+	assert.Equal(suite.T(), "/captured", entries[0].Path)
+	assert.Contains(suite.T(), entries[0].RequestBody, `"username":"admin"`)
+	assert.NotContains(suite.T(), entries[0].RequestBody, "hunter2")
+	assert.NotContains(suite.T(), entries[0].ResponseBody, "hunter2")
+	assert.Equal(suite.T(), []string{"[REDACTED]"}, entries[0].RequestHeaders["Authorization"])
+
+	// Store is bounded
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		req, _ := http.NewRequest("POST", "/captured", strings.NewReader(`{}`))
+		req.Header.Set("Content-Type", "application/json")
+		router.ServeHTTP(w, req)
+	}
+	assert.Len(suite.T(), store.Entries(), 2)
+}
+
 func TestMiddlewareTestSuite(t *testing.T) {
 	suite.Run(t, new(MiddlewareTestSuite))
 }
@@ -0,0 +1,162 @@
+package tests
+
+import (
+	"os"
+	"testing"
+
+	"synthezia/internal/config"
+	"synthezia/internal/database"
+	"synthezia/internal/models"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type MigrationsTestSuite struct {
+	suite.Suite
+	cfg *config.Config
+}
+
+func (suite *MigrationsTestSuite) SetupTest() {
+	suite.cfg = &config.Config{DatabasePath: "migrations_test.db"}
+	suite.Require().NoError(database.Open(suite.cfg))
+}
+
+func (suite *MigrationsTestSuite) TearDownTest() {
+	database.Close()
+	os.Remove(suite.cfg.DatabasePath)
+	os.Remove(suite.cfg.DatabasePath + "-wal")
+	os.Remove(suite.cfg.DatabasePath + "-shm")
+}
+
+func (suite *MigrationsTestSuite) TestUpAndDown() {
+	db := database.DB
+	latest := database.LatestVersion()
+
+	statuses, err := database.Status(db)
+	suite.Require().NoError(err)
+	suite.Require().Len(statuses, latest)
+	for _, status := range statuses {
+		assert.Nil(suite.T(), status.AppliedAt, status.Name)
+	}
+
+	count, err := database.Migrate(db)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), latest, count)
+	assert.True(suite.T(), db.Migrator().HasTable(&models.TranscriptionJob{}))
+	assert.True(suite.T(), db.Migrator().HasTable("transcript_search"))
+	version, err := database.CurrentVersion(db)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), latest, version)
+
+	count, err = database.Migrate(db)
+	suite.Require().NoError(err)
+	assert.Zero(suite.T(), count, "applied migrations do not run again")
+
+	count, err = database.Rollback(db, 1)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1, count)
+	pending, err := database.Pending(db)
+	suite.Require().NoError(err)
+	suite.Require().Len(pending, 1)
+	assert.Equal(suite.T(), latest, pending[0].Version)
+
+	count, err = database.MigrateTo(db, 0)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), latest-1, count)
+	assert.False(suite.T(), db.Migrator().HasTable(&models.TranscriptionJob{}), "reverting the baseline drops its tables")
+
+	count, err = database.MigrateTo(db, 1)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1, count)
+	assert.True(suite.T(), db.Migrator().HasTable(&models.TranscriptionJob{}))
+	assert.False(suite.T(), db.Migrator().HasTable("transcript_search"))
+
+	_, err = database.MigrateTo(db, latest+1)
+	assert.Error(suite.T(), err)
+}
+
+func (suite *MigrationsTestSuite) TestAdoptsAutoMigratedDatabase() {
+	db := database.DB
+	// A database created before versioned migrations, with data in it
+	suite.Require().NoError(db.AutoMigrate(&models.TranscriptionJob{}, &models.User{}))
+	suite.Require().NoError(db.Create(&models.TranscriptionJob{AudioPath: "old.mp3", Status: models.StatusCompleted}).Error)
+
+	_, err := database.Migrate(db)
+	suite.Require().NoError(err)
+	var jobs int64
+	db.Model(&models.TranscriptionJob{}).Count(&jobs)
+	assert.Equal(suite.T(), int64(1), jobs)
+}
+
+// Test an older AutoMigrate schema gains the baseline columns it lacks
+func (suite *MigrationsTestSuite) TestAdoptsOlderDatabase() {
+	db := database.DB
+	suite.Require().NoError(db.Exec("CREATE TABLE `transcription_jobs` (`id` varchar(36),`audio_path` text NOT NULL,`status` varchar(20) NOT NULL DEFAULT \"pending\",PRIMARY KEY (`id`))").Error)
+	suite.Require().NoError(db.Exec("INSERT INTO transcription_jobs (id, audio_path, status) VALUES ('old', 'old.mp3', 'completed')").Error)
+
+	_, err := database.Migrate(db)
+	suite.Require().NoError(err)
+	for _, column := range []string{"title", "audio_fingerprint", "model_family", "version"} {
+		assert.True(suite.T(), db.Migrator().HasColumn("transcription_jobs", column), column)
+	}
+	var job models.TranscriptionJob
+	suite.Require().NoError(db.Where("id = ?", "old").First(&job).Error)
+	assert.Equal(suite.T(), "whisper", job.Parameters.ModelFamily)
+}
+
+// Test the baseline is a fixed schema rather than the current models
+func (suite *MigrationsTestSuite) TestBaselineIsFrozen() {
+	db := database.DB
+	_, err := database.MigrateTo(db, 1)
+	suite.Require().NoError(err)
+	// Columns added to the model since come from their own migrations
+	assert.False(suite.T(), db.Migrator().HasColumn(&models.TranscriptionJob{}, "Version"))
+	assert.False(suite.T(), db.Migrator().HasColumn(&models.TranscriptionJob{}, "Progress"))
+
+	_, err = database.Migrate(db)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), db.Migrator().HasColumn(&models.TranscriptionJob{}, "Version"))
+	assert.True(suite.T(), db.Migrator().HasColumn(&models.TranscriptionJob{}, "Progress"))
+
+	// The later columns have the defaults their migrations spell out
+	suite.Require().NoError(db.Exec("INSERT INTO transcription_jobs (id, audio_path) VALUES ('raw', 'raw.mp3')").Error)
+	var job models.TranscriptionJob
+	suite.Require().NoError(db.Where("id = ?", "raw").First(&job).Error)
+	assert.Equal(suite.T(), 1, job.Version)
+	assert.Zero(suite.T(), job.Recoveries)
+	assert.Zero(suite.T(), job.Progress)
+	assert.Zero(suite.T(), job.ProcessedAudioSeconds)
+}
+
+func (suite *MigrationsTestSuite) TestNewerSchema() {
+	db := database.DB
+	_, err := database.Migrate(db)
+	suite.Require().NoError(err)
+	suite.Require().NoError(db.Create(&database.SchemaMigration{Version: 9999, Name: "from_the_future"}).Error)
+
+	statuses, err := database.Status(db)
+	suite.Require().NoError(err)
+	last := statuses[len(statuses)-1]
+	assert.Equal(suite.T(), 9999, last.Version)
+	assert.Equal(suite.T(), "from_the_future (unknown)", last.Name)
+
+	_, err = database.Rollback(db, 1)
+	assert.ErrorContains(suite.T(), err, "applied by a newer version")
+}
+
+func (suite *MigrationsTestSuite) TestManualMigrations() {
+	database.Close()
+	suite.cfg.DatabaseManualMigrations = true
+	err := database.Initialize(suite.cfg)
+	assert.ErrorContains(suite.T(), err, "migrations are pending")
+
+	_, err = database.Migrate(database.DB)
+	suite.Require().NoError(err)
+	database.Close()
+	suite.Require().NoError(database.Initialize(suite.cfg))
+}
+
+func TestMigrationsTestSuite(t *testing.T) {
+	suite.Run(t, new(MigrationsTestSuite))
+}
@@ -0,0 +1,146 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"synthezia/internal/llm"
+	"synthezia/internal/models"
+	"synthezia/internal/postprocess"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type PostProcessTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	server  *httptest.Server
+	service *postprocess.Service
+
+	mu      sync.Mutex
+	reply   string
+	prompts []string
+}
+
+func (suite *PostProcessTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "postprocess_test.db")
+	suite.prompts = nil
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/chat/completions" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		var req llm.ChatRequest
+		require.NoError(suite.T(), json.NewDecoder(r.Body).Decode(&req))
+		suite.mu.Lock()
+		suite.prompts = append(suite.prompts, req.Messages[0].Content)
+		reply := suite.reply
+		suite.mu.Unlock()
+
+		resp := map[string]interface{}{
+			"model":   req.Model,
+			"choices": []map[string]interface{}{{"message": map[string]string{"role": "assistant", "content": reply}}},
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(resp)
+	}))
+	suite.service = postprocess.NewService(suite.helper.DB, llm.NewOpenAICompatibleService(suite.server.URL+"/v1/", "key"), "insight-model")
+}
+
+func (suite *PostProcessTestSuite) TearDownTest() {
+	suite.server.Close()
+	suite.helper.Cleanup()
+}
+
+func (suite *PostProcessTestSuite) createTranscribed(id string) *models.TranscriptionJob {
+	transcript := `{"text":"","language":"en","segments":[
+		{"start":0,"end":20,"text":" Welcome, let's review the budget.","speaker":"SPEAKER_00"},
+		{"start":20,"end":50,"text":" Bob will send the figures by Friday.","speaker":"SPEAKER_01"},
+		{"start":50,"end":90,"text":" Next, hiring.","speaker":"SPEAKER_00"}]}`
+	job := &models.TranscriptionJob{ID: id, AudioPath: id + ".mp3", Status: models.StatusCompleted, Transcript: &transcript}
+	require.NoError(suite.T(), suite.helper.DB.Create(job).Error)
+	require.NoError(suite.T(), suite.helper.DB.Create(&models.SpeakerMapping{TranscriptionJobID: id, OriginalSpeaker: "SPEAKER_01", CustomName: "Bob"}).Error)
+	return job
+}
+
+// Test the summary, action items and chapters are drawn from a timed
+// transcript and stored, with the chapters fitted to the recording
+func (suite *PostProcessTestSuite) TestDrawInsights() {
+	suite.reply = "Sure!\n```json\n" + `{"summary":"The team reviewed the budget and hiring.",
+		"action_items":[{"text":"Send the budget figures","owner":"Bob","due":"Friday"},{"text":"  "}],
+		"chapters":[{"start":48,"title":"Hiring"},{"start":3,"title":"Budget","summary":"Figures are due."},{"start":400,"title":"Beyond the end"}]}` + "\n```"
+	job := suite.createTranscribed("job-insights")
+
+	started, err := suite.service.Start(job.ID)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.InsightsPending, started.Status)
+	suite.service.Wait(job.ID)
+
+	var stored models.TranscriptInsights
+	require.NoError(suite.T(), suite.helper.DB.Where("transcription_id = ?", job.ID).First(&stored).Error)
+	assert.Equal(suite.T(), models.InsightsCompleted, stored.Status, "error: %v", stored.Error)
+	assert.Equal(suite.T(), "insight-model", stored.Model)
+	insights, err := postprocess.Decode(&stored)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "The team reviewed the budget and hiring.", insights.Summary)
+	assert.Equal(suite.T(), []postprocess.ActionItem{{Text: "Send the budget figures", Owner: "Bob", Due: "Friday"}}, insights.ActionItems)
+	assert.Equal(suite.T(), []postprocess.Chapter{
+		{Start: 0, End: 48, Title: "Budget", Summary: "Figures are due."},
+		{Start: 48, End: 90, Title: "Hiring"},
+	}, insights.Chapters)
+
+	require.Len(suite.T(), suite.prompts, 1)
+	assert.Contains(suite.T(), suite.prompts[0], "[20.0] Bob: Bob will send the figures by Friday.")
+
+	// The summary is also the job's latest summary
+	var summary models.Summary
+	require.NoError(suite.T(), suite.helper.DB.Where("transcription_id = ?", job.ID).First(&summary).Error)
+	assert.Equal(suite.T(), insights.Summary, summary.Content)
+	var reloaded models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", job.ID).First(&reloaded).Error)
+	assert.Equal(suite.T(), insights.Summary, *reloaded.Summary)
+}
+
+// Test a reply that is not JSON fails the run
+func (suite *PostProcessTestSuite) TestInvalidReplyFails() {
+	suite.reply = "I could not find anything to summarize."
+	job := suite.createTranscribed("job-invalid")
+
+	_, err := suite.service.Start(job.ID)
+	require.NoError(suite.T(), err)
+	suite.service.Wait(job.ID)
+
+	var stored models.TranscriptInsights
+	require.NoError(suite.T(), suite.helper.DB.Where("transcription_id = ?", job.ID).First(&stored).Error)
+	assert.Equal(suite.T(), models.InsightsFailed, stored.Status)
+	require.NotNil(suite.T(), stored.Error)
+	assert.True(suite.T(), strings.Contains(*stored.Error, "not JSON"))
+}
+
+// Test jobs that cannot be processed are refused up front
+func (suite *PostProcessTestSuite) TestRefusesUnprocessable() {
+	disabled := postprocess.NewService(suite.helper.DB, nil, "")
+	job := suite.createTranscribed("job-disabled")
+	_, err := disabled.Start(job.ID)
+	assert.ErrorIs(suite.T(), err, postprocess.ErrDisabled)
+
+	encrypted := &models.TranscriptionJob{ID: "job-sealed", AudioPath: "sealed.mp3", Status: models.StatusCompleted, Transcript: job.Transcript, Encrypted: true}
+	require.NoError(suite.T(), suite.helper.DB.Create(encrypted).Error)
+	_, err = suite.service.Start(encrypted.ID)
+	assert.ErrorIs(suite.T(), err, postprocess.ErrEncrypted)
+
+	pending := &models.TranscriptionJob{ID: "job-waiting", AudioPath: "waiting.mp3", Status: models.StatusPending}
+	require.NoError(suite.T(), suite.helper.DB.Create(pending).Error)
+	_, err = suite.service.Start(pending.ID)
+	assert.ErrorIs(suite.T(), err, postprocess.ErrNotTranscribed)
+}
+
+func TestPostProcessTestSuite(t *testing.T) {
+	suite.Run(t, new(PostProcessTestSuite))
+}
@@ -5,9 +5,12 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
+	"synthezia/internal/audio"
 	"synthezia/internal/models"
 	"synthezia/internal/processing"
+	"synthezia/internal/transcription"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/suite"
@@ -194,6 +197,74 @@ func (suite *ProcessingTestSuite) TestGetMergeStatusWithError() {
 	assert.Equal(suite.T(), "Test error message", *errorMsg)
 }
 
+// Test every clip playing a file is kept, not just one
+func (suite *ProcessingTestSuite) TestTrackClipsFromProject() {
+	multiTrackFolder := filepath.Join(suite.testDir, "clips")
+	os.MkdirAll(multiTrackFolder, 0755)
+
+	aupContent := `<?xml version="1.0" standalone="no" ?>
+<project xmlns="http://audacity.sourceforge.net/xml/" audacityversion="2.4.2" rate="44100">
+  <wavetrack name="Host" channel="0" linked="0" mute="0" solo="0" rate="44100" gain="0.8" pan="0.0">
+    <waveclip offset="0.0">
+      <import filename="host.wav" offset="0.0" channel="0"/>
+    </waveclip>
+    <waveclip offset="42.0">
+      <import filename="host.wav" offset="0.0" channel="0"/>
+    </waveclip>
+  </wavetrack>
+</project>`
+	aupPath := filepath.Join(multiTrackFolder, "project.aup")
+	os.WriteFile(aupPath, []byte(aupContent), 0644)
+
+	job := &models.TranscriptionJob{
+		Title:            stringPtr("Clips Test"),
+		Status:           models.StatusPending,
+		IsMultiTrack:     true,
+		AupFilePath:      &aupPath,
+		MultiTrackFolder: &multiTrackFolder,
+		MergeStatus:      "pending",
+		MultiTrackFiles: []models.MultiTrackFile{
+			{FileName: "host", FilePath: filepath.Join(multiTrackFolder, "host.wav"), TrackIndex: 0},
+		},
+	}
+	assert.NoError(suite.T(), suite.helper.DB.Create(job).Error)
+
+	// The merge fails without audio, after the tracks are updated
+	_ = suite.processor.ProcessMultiTrackJob(context.Background(), job.ID)
+
+	var track models.MultiTrackFile
+	suite.Require().NoError(suite.helper.DB.Where("transcription_job_id = ?", job.ID).First(&track).Error)
+	assert.Equal(suite.T(), 0.0, track.Offset)
+	suite.Require().NotNil(track.Clips)
+	assert.JSONEq(suite.T(), `[{"track":1,"offset":0,"gain":0.8},{"track":1,"offset":42,"gain":0.8}]`, *track.Clips)
+}
+
+// Test the merge progress tracker keeps running merges and fans out updates
+func (suite *ProcessingTestSuite) TestMergeProgressTracker() {
+	tracker := processing.NewMergeProgressTracker()
+	updates, unsubscribe := tracker.Subscribe("job-1")
+
+	tracker.Report("job-1", audio.MergeProgress{Stage: "processing", Progress: 60, ProcessedSeconds: 5, TotalSeconds: 10})
+	tracker.Report("job-2", audio.MergeProgress{Stage: "processing", Progress: 30})
+
+	progress, ok := tracker.Get("job-1")
+	suite.Require().True(ok)
+	assert.Equal(suite.T(), 60.0, progress.Progress)
+	assert.Equal(suite.T(), 10.0, progress.TotalSeconds)
+	assert.Equal(suite.T(), 60.0, (<-updates).Progress)
+	assert.Empty(suite.T(), updates, "updates of other jobs are not delivered")
+
+	// Finished merges are forgotten, after subscribers hear of them
+	tracker.Report("job-1", audio.MergeProgress{Stage: "completed", Progress: 100})
+	assert.Equal(suite.T(), "completed", (<-updates).Stage)
+	_, ok = tracker.Get("job-1")
+	assert.False(suite.T(), ok)
+
+	unsubscribe()
+	tracker.Report("job-1", audio.MergeProgress{Stage: "processing", Progress: 40})
+	assert.Empty(suite.T(), updates)
+}
+
 // Test GetMergeStatus with non-existent job
 func (suite *ProcessingTestSuite) TestGetMergeStatusNotFound() {
 	status, errorMsg, err := suite.processor.GetMergeStatus("nonexistent-job-id")
@@ -382,6 +453,281 @@ func (suite *ProcessingTestSuite) TestUpdateTrackOffsetsPartialMatch() {
 	assert.Equal(suite.T(), 0.0, updatedFile2.Pan)
 }
 
+// Test that tracks are named after their speaker in the project, unless the user named them,
+// and keep the project's solos and volume envelopes
+func (suite *ProcessingTestSuite) TestTrackSpeakersFromProject() {
+	multiTrackFolder := filepath.Join(suite.testDir, "speakers")
+	os.MkdirAll(multiTrackFolder, 0755)
+
+	aupContent := `<?xml version="1.0" standalone="no" ?>
+<project xmlns="http://audacity.sourceforge.net/xml/" audacityversion="2.4.2" rate="44100">
+  <wavetrack name="Alice" channel="0" linked="0" mute="0" solo="0" rate="44100" gain="1.0" pan="0.0">
+    <waveclip offset="0.0">
+      <envelope numpoints="2">
+        <controlpoint t="0.0" val="0.0"/>
+        <controlpoint t="1.5" val="1.0"/>
+      </envelope>
+      <import filename="host.wav" offset="0.0" channel="0"/>
+    </waveclip>
+  </wavetrack>
+  <wavetrack name="Bob" channel="0" linked="0" mute="0" solo="1" rate="44100" gain="1.0" pan="0.0">
+    <waveclip offset="1.5">
+      <import filename="guest.wav" offset="0.0" channel="0"/>
+    </waveclip>
+  </wavetrack>
+</project>`
+	aupPath := filepath.Join(multiTrackFolder, "project.aup")
+	os.WriteFile(aupPath, []byte(aupContent), 0644)
+
+	job := &models.TranscriptionJob{
+		Title:            stringPtr("Speakers Test"),
+		Status:           models.StatusPending,
+		IsMultiTrack:     true,
+		AupFilePath:      &aupPath,
+		MultiTrackFolder: &multiTrackFolder,
+		MergeStatus:      "pending",
+		MultiTrackFiles: []models.MultiTrackFile{
+			{FileName: "host", FilePath: filepath.Join(multiTrackFolder, "host.wav"), TrackIndex: 0},
+			{FileName: "guest", FilePath: filepath.Join(multiTrackFolder, "guest.wav"), TrackIndex: 1, SpeakerName: stringPtr("Robert")},
+		},
+	}
+	assert.NoError(suite.T(), suite.helper.DB.Create(job).Error)
+
+	// The merge fails without audio, after the tracks are updated
+	_ = suite.processor.ProcessMultiTrackJob(context.Background(), job.ID)
+
+	var tracks []models.MultiTrackFile
+	suite.helper.DB.Where("transcription_job_id = ?", job.ID).Order("track_index").Find(&tracks)
+	suite.Require().Len(tracks, 2)
+	suite.Require().NotNil(tracks[0].SpeakerName)
+	assert.Equal(suite.T(), "Alice", *tracks[0].SpeakerName)
+	suite.Require().NotNil(tracks[1].SpeakerName)
+	assert.Equal(suite.T(), "Robert", *tracks[1].SpeakerName)
+	assert.Equal(suite.T(), 1.5, tracks[1].Offset)
+	assert.False(suite.T(), tracks[0].Solo)
+	assert.True(suite.T(), tracks[1].Solo)
+	suite.Require().NotNil(tracks[0].VolumeEnvelope)
+	assert.JSONEq(suite.T(), `[{"t":0,"value":0},{"t":1.5,"value":1}]`, *tracks[0].VolumeEnvelope)
+	assert.Nil(suite.T(), tracks[1].VolumeEnvelope)
+}
+
+// fakeStallingMixFFmpeg renders tracks at once but mixes until it is killed
+const fakeStallingMixFFmpeg = `#!/bin/sh
+for arg; do out="$arg"; done
+case "$*" in
+*libmp3lame*) exec sleep 30 ;;
+*) echo render > "$out" ;;
+esac
+`
+
+// createHostProject creates a one-track multi-track job in a folder of its
+// own, with a fake ffmpeg there whose mixes stall, and returns the job and ffmpeg
+func (suite *ProcessingTestSuite) createHostProject(name string) (*models.TranscriptionJob, string) {
+	dir, err := filepath.Abs(filepath.Join(suite.testDir, name))
+	suite.Require().NoError(err)
+	os.MkdirAll(dir, 0755)
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	suite.Require().NoError(os.WriteFile(ffmpeg, []byte(fakeStallingMixFFmpeg), 0755))
+	os.WriteFile(filepath.Join(dir, "host.wav"), []byte("host"), 0644)
+	aupPath := filepath.Join(dir, "project.aup")
+	os.WriteFile(aupPath, []byte(`<?xml version="1.0" standalone="no" ?>
+<project xmlns="http://audacity.sourceforge.net/xml/" audacityversion="2.4.2" rate="44100">
+  <wavetrack name="Host" channel="0" linked="0" mute="0" solo="0" rate="44100" gain="1.0" pan="0.0">
+    <waveclip offset="0.0">
+      <import filename="host.wav" offset="0.0" channel="0"/>
+    </waveclip>
+  </wavetrack>
+</project>`), 0644)
+
+	job := &models.TranscriptionJob{
+		Title:            stringPtr("Checkpoint Test"),
+		Status:           models.StatusPending,
+		IsMultiTrack:     true,
+		AupFilePath:      &aupPath,
+		MultiTrackFolder: &dir,
+		MergeStatus:      "pending",
+		MultiTrackFiles: []models.MultiTrackFile{
+			{FileName: "host", FilePath: filepath.Join(dir, "host.wav"), TrackIndex: 0},
+		},
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	return job, ffmpeg
+}
+
+// Test stopping the processor checkpoints a running merge for the next start
+func (suite *ProcessingTestSuite) TestStopCheckpointsMerge() {
+	job, ffmpeg := suite.createHostProject("checkpoint")
+	dir := *job.MultiTrackFolder
+
+	processor := processing.NewMultiTrackProcessor()
+	processor.SetFFmpegPath(ffmpeg)
+	result := make(chan error, 1)
+	go func() { result <- processor.ProcessMultiTrackJob(context.Background(), job.ID) }()
+
+	// Stop once the track is rendered and the mix is running
+	render := filepath.Join(dir, "renders", "track_01.flac")
+	suite.Require().Eventually(func() bool {
+		_, err := os.Stat(render)
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	processor.Stop()
+
+	err := <-result
+	assert.ErrorIs(suite.T(), err, processing.ErrProcessorStopped)
+	assert.FileExists(suite.T(), render, "finished renders are kept")
+	var stored models.TranscriptionJob
+	suite.helper.DB.First(&stored, "id = ?", job.ID)
+	assert.Equal(suite.T(), "pending", stored.MergeStatus)
+	assert.Nil(suite.T(), stored.MergeError)
+
+	// A stopped processor starts no merge
+	assert.ErrorIs(suite.T(), processor.ProcessMultiTrackJob(context.Background(), job.ID), processing.ErrProcessorStopped)
+}
+
+// Test merges over the limit wait in the merge_queued state
+func (suite *ProcessingTestSuite) TestMaxConcurrentMerges() {
+	running, ffmpeg := suite.createHostProject("limit_running")
+	queued, _ := suite.createHostProject("limit_queued")
+
+	processor := processing.NewMultiTrackProcessor()
+	processor.SetFFmpegPath(ffmpeg)
+	processor.SetMaxConcurrentMerges(1)
+	results := make(chan error, 2)
+	go func() { results <- processor.ProcessMultiTrackJob(context.Background(), running.ID) }()
+	mergeStatus := func(job *models.TranscriptionJob) string {
+		status, _, err := processor.GetMergeStatus(job.ID)
+		suite.Require().NoError(err)
+		return status
+	}
+	suite.Require().Eventually(func() bool { return mergeStatus(running) == "processing" }, 5*time.Second, 10*time.Millisecond)
+
+	go func() { results <- processor.ProcessMultiTrackJob(context.Background(), queued.ID) }()
+	suite.Require().Eventually(func() bool { return mergeStatus(queued) == "merge_queued" }, 5*time.Second, 10*time.Millisecond)
+	progress, ok := processing.MergeProgresses.Get(queued.ID)
+	suite.Require().True(ok)
+	assert.Equal(suite.T(), "queued", progress.Stage)
+	assert.Equal(suite.T(), "processing", mergeStatus(running))
+
+	// Stopping leaves both to be resumed, the queued one without having run
+	processor.Stop()
+	for i := 0; i < 2; i++ {
+		assert.ErrorIs(suite.T(), <-results, processing.ErrProcessorStopped)
+	}
+	assert.Equal(suite.T(), "pending", mergeStatus(running))
+	assert.Equal(suite.T(), "pending", mergeStatus(queued))
+	assert.NoDirExists(suite.T(), filepath.Join(*queued.MultiTrackFolder, "renders"))
+	_, ok = processing.MergeProgresses.Get(queued.ID)
+	assert.False(suite.T(), ok)
+}
+
+// Test cancelling a merge kills it and removes its renders, whether it runs
+// in this process or was left unfinished by another
+func (suite *ProcessingTestSuite) TestCancelMerge() {
+	job, ffmpeg := suite.createHostProject("cancel")
+	renderDir := filepath.Join(*job.MultiTrackFolder, "renders")
+
+	processor := processing.NewMultiTrackProcessor()
+	processor.SetFFmpegPath(ffmpeg)
+	defer processor.Stop()
+	result := make(chan error, 1)
+	go func() { result <- processor.ProcessMultiTrackJob(context.Background(), job.ID) }()
+	suite.Require().Eventually(func() bool {
+		_, err := os.Stat(filepath.Join(renderDir, "track_01.flac"))
+		return err == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	suite.Require().NoError(processor.CancelMerge(job.ID))
+	select {
+	case err := <-result:
+		assert.ErrorIs(suite.T(), err, processing.ErrMergeCancelled)
+	default:
+		suite.Fail("the merge should have returned before CancelMerge")
+	}
+	status, _, err := processor.GetMergeStatus(job.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "cancelled", status)
+	assert.NoDirExists(suite.T(), renderDir)
+	_, ok := processing.MergeProgresses.Get(job.ID)
+	assert.False(suite.T(), ok)
+
+	// A merge a previous run left processing is cancelled in place
+	stale, _ := suite.createHostProject("cancel_stale")
+	suite.helper.DB.Model(stale).Update("merge_status", "processing")
+	staleRenders := filepath.Join(*stale.MultiTrackFolder, "renders")
+	os.MkdirAll(staleRenders, 0755)
+	suite.Require().NoError(processor.CancelMerge(stale.ID))
+	status, _, err = processor.GetMergeStatus(stale.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "cancelled", status)
+	assert.NoDirExists(suite.T(), staleRenders)
+}
+
+// fakeSplitFFmpeg reports distinct channel levels when analyzing and writes
+// the two channel files when splitting
+const fakeSplitFFmpeg = `#!/bin/sh
+case "$*" in
+*astats*)
+	for level in -24 -26 -28 -29; do
+		echo "[Parsed_astats_1 @ 0x1] Channel: $((n=n+1))" >&2
+		echo "[Parsed_astats_1 @ 0x1] RMS level dB: $level" >&2
+	done ;;
+*channelsplit*)
+	for arg; do
+		case "$prev" in "[left]"|"[right]") echo "$prev" > "$arg" ;; esac
+		prev="$arg"
+	done ;;
+esac
+`
+
+// Test a stereo interview becomes one track per channel, labelled by side
+func (suite *ProcessingTestSuite) TestSplitJobChannels() {
+	dir := suite.T().TempDir()
+	ffmpeg := filepath.Join(dir, "ffmpeg")
+	suite.Require().NoError(os.WriteFile(ffmpeg, []byte(fakeSplitFFmpeg), 0755))
+	audioPath := filepath.Join(dir, "interview.wav")
+	suite.Require().NoError(os.WriteFile(audioPath, []byte("stereo"), 0644))
+
+	job := &models.TranscriptionJob{
+		Title:         stringPtr("Interview"),
+		Status:        models.StatusPending,
+		AudioPath:     audioPath,
+		AudioChannels: intPtr(2),
+		Diarization:   true,
+		Parameters:    models.WhisperXParams{SplitChannels: models.SplitChannelsAuto, Diarize: true},
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+
+	split, err := transcription.SplitJobChannels(context.Background(), ffmpeg, job)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), split)
+	assert.True(suite.T(), job.IsMultiTrack)
+	assert.True(suite.T(), job.Parameters.IsMultiTrackEnabled)
+	assert.False(suite.T(), job.Parameters.Diarize)
+	suite.Require().NotNil(job.MergedAudioPath)
+	assert.Equal(suite.T(), audioPath, *job.MergedAudioPath, "the recording is still played back")
+	suite.Require().Len(job.MultiTrackFiles, 2)
+	for i, speaker := range []string{"Left", "Right"} {
+		track := job.MultiTrackFiles[i]
+		suite.Require().NotNil(track.SpeakerName)
+		assert.Equal(suite.T(), speaker, *track.SpeakerName)
+		assert.FileExists(suite.T(), track.FilePath)
+	}
+
+	// Already split, mono and unrequested jobs are left alone
+	split, err = transcription.SplitJobChannels(context.Background(), ffmpeg, job)
+	suite.Require().NoError(err)
+	assert.False(suite.T(), split)
+	for _, other := range []*models.TranscriptionJob{
+		{AudioPath: audioPath, AudioChannels: intPtr(1), Parameters: models.WhisperXParams{SplitChannels: models.SplitChannelsAlways}},
+		{AudioPath: audioPath, AudioChannels: intPtr(2)},
+	} {
+		split, err = transcription.SplitJobChannels(context.Background(), ffmpeg, other)
+		suite.Require().NoError(err)
+		assert.False(suite.T(), split)
+	}
+}
+
 func TestProcessingTestSuite(t *testing.T) {
 	suite.Run(t, new(ProcessingTestSuite))
 }
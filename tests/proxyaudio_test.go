@@ -0,0 +1,119 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/internal/proxyaudio"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeFFmpeg copies its input (-y -i <input> ...) to the last argument
+const fakeFFmpeg = `#!/bin/sh
+for arg; do out="$arg"; done
+cp "$3" "$out"
+`
+
+type ProxyAudioTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	dir     string
+	service *proxyaudio.Service
+}
+
+func (suite *ProxyAudioTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "proxyaudio_test.db")
+	suite.dir = suite.T().TempDir()
+	suite.service = proxyaudio.NewService(suite.helper.DB)
+	suite.service.SetFFmpegPath(suite.script("ffmpeg", fakeFFmpeg))
+}
+
+func (suite *ProxyAudioTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// script writes an executable shell script and returns its path
+func (suite *ProxyAudioTestSuite) script(name, body string) string {
+	path := filepath.Join(suite.dir, name)
+	require.NoError(suite.T(), os.WriteFile(path, []byte(body), 0755))
+	return path
+}
+
+// jobWithAudio creates a job whose audio file exists on disk
+func (suite *ProxyAudioTestSuite) jobWithAudio(name string) *models.TranscriptionJob {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), name)
+	job.AudioPath = filepath.Join(suite.dir, job.ID+".wav")
+	require.NoError(suite.T(), os.WriteFile(job.AudioPath, []byte("audio "+name), 0644))
+	require.NoError(suite.T(), suite.helper.DB.Model(job).Update("audio_path", job.AudioPath).Error)
+	return job
+}
+
+// Test proxies are created for jobs without one and recorded in the timeline
+func (suite *ProxyAudioTestSuite) TestGenerateMissing() {
+	stop := jobstate.RecordEvents()
+	defer stop()
+	job := suite.jobWithAudio("needs proxy")
+	removed := suite.jobWithAudio("audio deleted")
+	require.NoError(suite.T(), suite.helper.DB.Model(removed).Update("source_audio_removed_at", time.Now()).Error)
+	unmerged := suite.jobWithAudio("multi-track")
+	require.NoError(suite.T(), suite.helper.DB.Model(unmerged).Update("is_multi_track", true).Error)
+
+	created, err := suite.service.GenerateMissing(context.Background())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 1, created)
+
+	var stored models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	require.NotNil(suite.T(), stored.ProxyAudioPath)
+	assert.Equal(suite.T(), filepath.Join(suite.dir, job.ID+".playback.opus"), *stored.ProxyAudioPath)
+	data, err := os.ReadFile(*stored.ProxyAudioPath)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "audio needs proxy", string(data))
+
+	events, err := jobstate.Timeline(job.ID)
+	require.NoError(suite.T(), err)
+	if assert.Len(suite.T(), events, 1) {
+		assert.Equal(suite.T(), proxyaudio.EventCreated, events[0].Event)
+	}
+
+	// Nothing is left to do on the next pass
+	created, err = suite.service.GenerateMissing(context.Background())
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 0, created)
+}
+
+// Test a job whose proxy fails to encode is not retried on every pass
+func (suite *ProxyAudioTestSuite) TestFailedEncodeNotRetried() {
+	marker := filepath.Join(suite.dir, "calls")
+	suite.service.SetFFmpegPath(suite.script("failing-ffmpeg", "#!/bin/sh\necho x >> "+marker+"\nexit 1\n"))
+	job := suite.jobWithAudio("broken")
+
+	for i := 0; i < 2; i++ {
+		created, err := suite.service.GenerateMissing(context.Background())
+		require.NoError(suite.T(), err)
+		assert.Equal(suite.T(), 0, created)
+	}
+
+	calls, err := os.ReadFile(marker)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "x\n", string(calls))
+
+	var stored models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	assert.Nil(suite.T(), stored.ProxyAudioPath)
+	entries, err := os.ReadDir(suite.dir)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), entries, 4) // both scripts, the audio and the call log
+}
+
+func TestProxyAudioTestSuite(t *testing.T) {
+	suite.Run(t, new(ProxyAudioTestSuite))
+}
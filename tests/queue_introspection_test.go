@@ -0,0 +1,150 @@
+package tests
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+	"synthezia/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type QueueIntrospectionTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+}
+
+func (suite *QueueIntrospectionTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "queue_introspection_test.db")
+}
+
+func (suite *QueueIntrospectionTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *QueueIntrospectionTestSuite) createJob(id string, userID *uint) {
+	job := &models.TranscriptionJob{ID: id, AudioPath: id + ".mp3", Status: models.StatusPending, UserID: userID}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+}
+
+func (suite *QueueIntrospectionTestSuite) TestSnapshot() {
+	alice := &models.User{Username: "alice", Password: "secret"}
+	bob := &models.User{Username: "bob", Password: "secret"}
+	suite.Require().NoError(suite.helper.DB.Create(alice).Error)
+	suite.Require().NoError(suite.helper.DB.Create(bob).Error)
+
+	processor := &scriptedProcessor{release: make(chan struct{})}
+	fakeClock := clock.NewFake(time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC))
+	tq := queue.NewTaskQueue(2, processor)
+	tq.SetClock(fakeClock)
+	tq.SetFairShare(true, map[string]int{"alice": 2})
+	tq.Start()
+	defer tq.Stop()
+	defer close(processor.release)
+
+	snapshot := tq.Snapshot()
+	assert.Zero(suite.T(), snapshot.Depth)
+	assert.Equal(suite.T(), []queue.WorkerState{{ID: 0, State: queue.WorkerIdle}, {ID: 1, State: queue.WorkerIdle}}, snapshot.Workers)
+
+	// One job completes and one fails
+	for _, id := range []string{"quick", "fail"} {
+		suite.createJob(id, nil)
+		suite.Require().NoError(tq.EnqueueJob(id))
+	}
+	assert.Eventually(suite.T(), func() bool {
+		throughput := tq.Snapshot().Throughput[0]
+		return throughput.Completed == 1 && throughput.Failed == 1
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// Both workers are held, so the rest wait
+	for _, id := range []string{"hold1", "hold2"} {
+		suite.createJob(id, nil)
+		suite.Require().NoError(tq.EnqueueJob(id))
+	}
+	assert.Eventually(suite.T(), func() bool {
+		workers := tq.Snapshot().Workers
+		return len(workers) == 2 && workers[0].State == queue.WorkerRunning && workers[1].State == queue.WorkerRunning
+	}, 2*time.Second, 10*time.Millisecond)
+	suite.createJob("a1", &alice.ID)
+	suite.createJob("a2", &alice.ID)
+	suite.createJob("b1", &bob.ID)
+	for _, id := range []string{"a1", "a2", "b1"} {
+		suite.Require().NoError(tq.EnqueueJob(id))
+		fakeClock.Advance(10 * time.Second)
+	}
+	assert.Eventually(suite.T(), func() bool { return len(tq.Snapshot().ByPriority) == 2 }, 2*time.Second, 10*time.Millisecond)
+
+	snapshot = tq.Snapshot()
+	assert.Equal(suite.T(), 3, snapshot.Depth)
+	assert.Equal(suite.T(), []queue.PriorityDepth{{Weight: 2, Jobs: 2, Users: 1}, {Weight: 1, Jobs: 1, Users: 1}}, snapshot.ByPriority)
+	assert.Equal(suite.T(), "a1", snapshot.OldestPendingJobID)
+	assert.Equal(suite.T(), 30.0, snapshot.OldestPendingAge)
+	held := []string{}
+	for _, worker := range snapshot.Workers {
+		held = append(held, worker.JobID)
+		assert.Equal(suite.T(), 30.0, worker.RuntimeSeconds)
+	}
+	assert.ElementsMatch(suite.T(), []string{"hold1", "hold2"}, held)
+
+	// Finished jobs leave the shorter windows first
+	fakeClock.Advance(10 * time.Minute)
+	snapshot = tq.Snapshot()
+	assert.Equal(suite.T(), []queue.Throughput{
+		{Window: "5m", JobsPerHour: 0},
+		{Window: "15m", Completed: 1, Failed: 1, JobsPerHour: 8},
+		{Window: "1h", Completed: 1, Failed: 1, JobsPerHour: 2},
+	}, snapshot.Throughput)
+
+	// The same through the API
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, tq, nil, nil, nil)
+	router := api.SetupRoutes(handler, suite.helper.AuthService)
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/queue", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var response queue.Snapshot
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	assert.Equal(suite.T(), 3, response.Depth)
+	assert.Len(suite.T(), response.Workers, 2)
+}
+
+// Test a job turned away by a full queue leaves no enqueue time behind
+func (suite *QueueIntrospectionTestSuite) TestFullQueueForgetsJob() {
+	start := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	fakeClock := clock.NewFake(start)
+	tq := queue.NewTaskQueue(1, &scriptedProcessor{release: make(chan struct{})})
+	tq.SetClock(fakeClock)
+
+	// Not started, so nothing drains the channel
+	for i := 0; ; i++ {
+		if err := tq.EnqueueJob(fmt.Sprintf("waiting%d", i)); err != nil {
+			assert.EqualError(suite.T(), err, "queue is full")
+			break
+		}
+	}
+
+	// Turned away earlier than anything waiting, so it would be the oldest
+	fakeClock.Set(start.Add(-time.Hour))
+	assert.EqualError(suite.T(), tq.EnqueueJob("overflow"), "queue is full")
+	// A job already waiting keeps its time when enqueued again
+	assert.EqualError(suite.T(), tq.EnqueueJob("waiting0"), "queue is full")
+
+	fakeClock.Set(start.Add(time.Minute))
+	snapshot := tq.Snapshot()
+	assert.True(suite.T(), strings.HasPrefix(snapshot.OldestPendingJobID, "waiting"), snapshot.OldestPendingJobID)
+	assert.Equal(suite.T(), 60.0, snapshot.OldestPendingAge)
+}
+
+func TestQueueIntrospectionTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueIntrospectionTestSuite))
+}
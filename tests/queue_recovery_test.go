@@ -0,0 +1,161 @@
+package tests
+
+import (
+	"context"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// recordingProcessor completes every job, remembering which it ran
+type recordingProcessor struct {
+	mu   sync.Mutex
+	jobs []string
+}
+
+func (p *recordingProcessor) ProcessJob(ctx context.Context, jobID string) error {
+	return p.ProcessJobWithProcess(ctx, jobID, nil)
+}
+
+func (p *recordingProcessor) ProcessJobWithProcess(ctx context.Context, jobID string, registerProcess func(*exec.Cmd)) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.jobs = append(p.jobs, jobID)
+	return nil
+}
+
+func (p *recordingProcessor) ran() []string {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return append([]string(nil), p.jobs...)
+}
+
+type QueueRecoveryTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+}
+
+func (suite *QueueRecoveryTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "queue_recovery_test.db")
+}
+
+func (suite *QueueRecoveryTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *QueueRecoveryTestSuite) createJob(id string, status models.JobStatus, edit func(*models.TranscriptionJob)) {
+	job := &models.TranscriptionJob{ID: id, AudioPath: id + ".mp3", Status: status}
+	if edit != nil {
+		edit(job)
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+}
+
+func (suite *QueueRecoveryTestSuite) reload(id string) models.TranscriptionJob {
+	var job models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", id).First(&job).Error)
+	return job
+}
+
+func (suite *QueueRecoveryTestSuite) TestParsePolicy() {
+	policy, err := queue.ParseRecoveryPolicy(" Requeue ")
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), queue.RecoveryRequeue, policy)
+	_, err = queue.ParseRecoveryPolicy("retry")
+	assert.Error(suite.T(), err)
+}
+
+func (suite *QueueRecoveryTestSuite) TestRequeue() {
+	later := time.Now().Add(time.Hour)
+	suite.createJob("interrupted", models.StatusProcessing, nil)
+	suite.createJob("crashing", models.StatusProcessing, func(job *models.TranscriptionJob) { job.Recoveries = 3 })
+	suite.createJob("waiting", models.StatusPending, nil)
+	suite.createJob("scheduled", models.StatusPending, func(job *models.TranscriptionJob) { job.RunAfter = &later })
+	suite.createJob("done", models.StatusCompleted, nil)
+	suite.createJob("track_interrupted_a.wav_01", models.StatusProcessing, nil)
+	suite.Require().NoError(suite.helper.DB.Create(&models.TranscriptionJobExecution{
+		TranscriptionJobID: "interrupted", StartedAt: time.Now(), Status: models.StatusProcessing,
+	}).Error)
+
+	processor := &recordingProcessor{}
+	tq := queue.NewTaskQueue(1, processor)
+	report, err := tq.RecoverJobs(queue.RecoveryRequeue, 3)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), queue.RecoveryReport{Requeued: 1, Failed: 1, Enqueued: 2}, report)
+
+	interrupted := suite.reload("interrupted")
+	assert.Equal(suite.T(), models.StatusPending, interrupted.Status)
+	assert.Equal(suite.T(), 1, interrupted.Recoveries)
+	crashing := suite.reload("crashing")
+	assert.Equal(suite.T(), models.StatusFailed, crashing.Status)
+	suite.Require().NotNil(crashing.ErrorMessage)
+	assert.Contains(suite.T(), *crashing.ErrorMessage, "4 server restarts")
+	assert.Equal(suite.T(), models.StatusPending, suite.reload("scheduled").Status)
+	assert.Equal(suite.T(), models.StatusCompleted, suite.reload("done").Status)
+
+	var execution models.TranscriptionJobExecution
+	suite.Require().NoError(suite.helper.DB.Where("transcription_job_id = ?", "interrupted").First(&execution).Error)
+	assert.Equal(suite.T(), models.StatusFailed, execution.Status)
+	assert.NotNil(suite.T(), execution.CompletedAt)
+
+	var tracks int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("id LIKE ?", "track%").Count(&tracks)
+	assert.Zero(suite.T(), tracks, "track jobs are transcribed again with their job")
+
+	// The workers pick the recovered jobs up without waiting for a scan
+	tq.Start()
+	defer tq.Stop()
+	assert.Eventually(suite.T(), func() bool {
+		return suite.reload("interrupted").Status == models.StatusCompleted && suite.reload("waiting").Status == models.StatusCompleted
+	}, 2*time.Second, 10*time.Millisecond)
+	assert.ElementsMatch(suite.T(), []string{"interrupted", "waiting"}, processor.ran())
+}
+
+// Test a job running when the queue stops is left for recovery, not failed
+func (suite *QueueRecoveryTestSuite) TestShutdownLeavesJobForRecovery() {
+	suite.createJob("hold-shutdown", models.StatusPending, nil)
+
+	tq := queue.NewTaskQueue(1, &scriptedProcessor{release: make(chan struct{})})
+	tq.Start()
+	suite.Require().NoError(tq.EnqueueJob("hold-shutdown"))
+	suite.Require().Eventually(func() bool {
+		return suite.reload("hold-shutdown").Status == models.StatusProcessing
+	}, 2*time.Second, 10*time.Millisecond)
+	tq.Stop()
+
+	job := suite.reload("hold-shutdown")
+	assert.Equal(suite.T(), models.StatusProcessing, job.Status)
+	assert.Nil(suite.T(), job.ErrorMessage)
+
+	report, err := queue.NewTaskQueue(1, &recordingProcessor{}).RecoverJobs(queue.RecoveryRequeue, 3)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 1, report.Requeued)
+	assert.Equal(suite.T(), models.StatusPending, suite.reload("hold-shutdown").Status)
+}
+
+func (suite *QueueRecoveryTestSuite) TestFailPolicy() {
+	suite.createJob("interrupted", models.StatusProcessing, nil)
+	suite.createJob("paused", models.StatusPaused, nil)
+
+	tq := queue.NewTaskQueue(1, &recordingProcessor{})
+	report, err := tq.RecoverJobs(queue.RecoveryFail, 0)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), queue.RecoveryReport{Failed: 1}, report)
+
+	interrupted := suite.reload("interrupted")
+	assert.Equal(suite.T(), models.StatusFailed, interrupted.Status)
+	suite.Require().NotNil(interrupted.ErrorMessage)
+	assert.Contains(suite.T(), *interrupted.ErrorMessage, "server restart")
+	assert.Equal(suite.T(), models.StatusPaused, suite.reload("paused").Status)
+}
+
+func TestQueueRecoveryTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueRecoveryTestSuite))
+}
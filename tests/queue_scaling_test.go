@@ -0,0 +1,214 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+	"synthezia/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// gatedProcessor holds every job until release is closed, counting how
+// many run at once
+type gatedProcessor struct {
+	release chan struct{}
+	mu      sync.Mutex
+	running int
+	peak    int
+}
+
+func newGatedProcessor() *gatedProcessor {
+	return &gatedProcessor{release: make(chan struct{})}
+}
+
+func (p *gatedProcessor) ProcessJob(ctx context.Context, jobID string) error {
+	return p.ProcessJobWithProcess(ctx, jobID, nil)
+}
+
+func (p *gatedProcessor) ProcessJobWithProcess(ctx context.Context, jobID string, registerProcess func(*exec.Cmd)) error {
+	p.mu.Lock()
+	p.running++
+	p.peak = max(p.peak, p.running)
+	p.mu.Unlock()
+	defer func() {
+		p.mu.Lock()
+		p.running--
+		p.mu.Unlock()
+	}()
+
+	select {
+	case <-p.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (p *gatedProcessor) counts() (running, peak int) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.running, p.peak
+}
+
+type QueueScalingTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+}
+
+func (suite *QueueScalingTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "queue_scaling_test.db")
+}
+
+func (suite *QueueScalingTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// enqueue creates pending jobs and hands them to the queue
+func (suite *QueueScalingTestSuite) enqueue(tq *queue.TaskQueue, count int) []string {
+	ids := make([]string, count)
+	for i := range ids {
+		job := &models.TranscriptionJob{AudioPath: "scaled.mp3", Status: models.StatusPending}
+		suite.Require().NoError(suite.helper.DB.Create(job).Error)
+		suite.Require().NoError(tq.EnqueueJob(job.ID))
+		ids[i] = job.ID
+	}
+	return ids
+}
+
+func (suite *QueueScalingTestSuite) putWorkers(router http.Handler, body interface{}) (*httptest.ResponseRecorder, api.QueueWorkersResponse) {
+	data, _ := json.Marshal(body)
+	req := httptest.NewRequest(http.MethodPut, "/api/v1/admin/queue/workers", bytes.NewReader(data))
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	var response api.QueueWorkersResponse
+	if w.Code == http.StatusOK {
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &response))
+	}
+	return w, response
+}
+
+func (suite *QueueScalingTestSuite) TestResizeThroughAPI() {
+	processor := newGatedProcessor()
+	tq := queue.NewTaskQueue(3, processor)
+	tq.Start()
+	defer tq.Stop()
+	defer close(processor.release)
+
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, tq, nil, nil, nil)
+	router := api.SetupRoutes(handler, suite.helper.AuthService)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/queue/workers", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var workers api.QueueWorkersResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &workers))
+	assert.Equal(suite.T(), api.QueueWorkersResponse{WorkerLimits: queue.WorkerLimits{Min: 3, Max: 3}, CurrentWorkers: 3}, workers)
+
+	w, workers = suite.putWorkers(router, map[string]int{"workers": 1})
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), 1, workers.CurrentWorkers)
+
+	// The stopped workers take no more jobs: the one worker left runs one
+	// job and the others keep waiting
+	suite.enqueue(tq, 3)
+	assert.Eventually(suite.T(), func() bool {
+		running, _ := processor.counts()
+		snapshot := tq.Snapshot()
+		return running == 1 && snapshot.Depth == 2 && len(snapshot.Workers) == 1
+	}, 2*time.Second, 10*time.Millisecond)
+	_, peak := processor.counts()
+	assert.Equal(suite.T(), 1, peak)
+
+	w, workers = suite.putWorkers(router, map[string]int{"workers": 2})
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Eventually(suite.T(), func() bool {
+		running, _ := processor.counts()
+		return running == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	w, workers = suite.putWorkers(router, map[string]interface{}{"max_workers": 4, "auto_scale": true})
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Equal(suite.T(), queue.WorkerLimits{Min: 2, Max: 4, AutoScale: true}, workers.WorkerLimits)
+	assert.Equal(suite.T(), 2, workers.CurrentWorkers)
+
+	for _, body := range []interface{}{map[string]int{"workers": 0}, map[string]int{"min_workers": 5}, map[string]int{"workers": queue.MaxWorkerLimit + 1}} {
+		w, _ = suite.putWorkers(router, body)
+		assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+	}
+}
+
+func (suite *QueueScalingTestSuite) TestAutoScale() {
+	processor := newGatedProcessor()
+	fakeClock := clock.NewFake(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC))
+	var loadMu sync.Mutex
+	load := 0.2
+	setLoad := func(value float64) {
+		loadMu.Lock()
+		defer loadMu.Unlock()
+		load = value
+	}
+
+	tq := queue.NewTaskQueue(1, processor)
+	tq.SetClock(fakeClock)
+	tq.SetSystemLoad(func() (float64, bool) {
+		loadMu.Lock()
+		defer loadMu.Unlock()
+		return load, true
+	})
+	suite.Require().NoError(tq.SetWorkerLimits(queue.WorkerLimits{Min: 1, Max: 3, AutoScale: true}))
+	tq.Start()
+	defer tq.Stop()
+
+	ids := suite.enqueue(tq, 4)
+	fakeClock.BlockUntil(2) // The scanner's and auto-scaler's tickers
+	workersBecome := func(count int) {
+		fakeClock.Advance(time.Minute)
+		assert.Eventually(suite.T(), func() bool { return tq.CurrentWorkers() == count }, 2*time.Second, 10*time.Millisecond, "%d workers", count)
+	}
+
+	// Jobs wait, so workers are added up to the limit
+	workersBecome(2)
+	workersBecome(3)
+	assert.Eventually(suite.T(), func() bool {
+		running, _ := processor.counts()
+		return running == 3
+	}, 2*time.Second, 10*time.Millisecond)
+
+	// An overloaded system sheds workers however long the queue
+	setLoad(1.5)
+	workersBecome(2)
+	workersBecome(1)
+	workersBecome(1)
+
+	// Idle workers go once the queue drains
+	setLoad(0.2)
+	workersBecome(2)
+	close(processor.release)
+	// Copies of the jobs the scanner enqueued leave the queue as well
+	assert.Eventually(suite.T(), func() bool {
+		var done int64
+		suite.helper.DB.Model(&models.TranscriptionJob{}).Where("id IN ? AND status = ?", ids, models.StatusCompleted).Count(&done)
+		return done == int64(len(ids)) && tq.Snapshot().Depth == 0
+	}, 2*time.Second, 10*time.Millisecond)
+	workersBecome(1)
+}
+
+func TestQueueScalingTestSuite(t *testing.T) {
+	suite.Run(t, new(QueueScalingTestSuite))
+}
@@ -8,8 +8,10 @@ import (
 	"testing"
 	"time"
 
+	"synthezia/internal/devices"
 	"synthezia/internal/models"
 	"synthezia/internal/queue"
+	"synthezia/pkg/clock"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
@@ -119,6 +121,65 @@ func (suite *QueueTestSuite) TestJobProcessing() {
 	assert.Equal(suite.T(), models.StatusCompleted, updatedJob.Status)
 }
 
+// Test the pending job scanner with a fake clock instead of waiting 10 seconds
+func (suite *QueueTestSuite) TestPendingJobScanWithFakeClock() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Test Pending Scan")
+
+	mockProcessor := &MockJobProcessor{}
+	mockProcessor.On("ProcessJobWithProcess", mock.Anything, mock.Anything).Return(nil)
+
+	fakeClock := clock.NewFake(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC))
+	tq := queue.NewTaskQueue(1, mockProcessor)
+	tq.SetClock(fakeClock)
+	tq.Start()
+	defer tq.Stop()
+
+	// Nothing is scanned until the scanner's ticker fires
+	fakeClock.BlockUntil(1)
+	mockProcessor.AssertNotCalled(suite.T(), "ProcessJobWithProcess", mock.Anything, job.ID)
+
+	fakeClock.Advance(10 * time.Second)
+
+	assert.Eventually(suite.T(), func() bool {
+		updatedJob, err := tq.GetJobStatus(job.ID)
+		return err == nil && updatedJob.Status == models.StatusCompleted
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+// Test a job scheduled for later is held back until the scanner finds it due
+func (suite *QueueTestSuite) TestScheduledJobHeldBack() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Test Scheduled Job")
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	runAfter := start.Add(15 * time.Second)
+	suite.Require().NoError(suite.helper.DB.Model(job).Update("run_after", runAfter).Error)
+
+	mockProcessor := &MockJobProcessor{}
+	mockProcessor.On("ProcessJobWithProcess", mock.Anything, mock.Anything).Return(nil)
+
+	fakeClock := clock.NewFake(start)
+	tq := queue.NewTaskQueue(1, mockProcessor)
+	tq.SetClock(fakeClock)
+	tq.Start()
+	defer tq.Stop()
+	fakeClock.BlockUntil(1)
+
+	// Enqueued early, the worker drops it
+	assert.NoError(suite.T(), tq.EnqueueJob(job.ID))
+	fakeClock.Advance(10 * time.Second)
+	time.Sleep(100 * time.Millisecond)
+	mockProcessor.AssertNotCalled(suite.T(), "ProcessJobWithProcess", mock.Anything, job.ID)
+	updatedJob, err := tq.GetJobStatus(job.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.StatusPending, updatedJob.Status)
+	assert.Equal(suite.T(), int64(1), tq.GetQueueStats()["scheduled_jobs"])
+
+	fakeClock.Advance(10 * time.Second)
+	assert.Eventually(suite.T(), func() bool {
+		updatedJob, err := tq.GetJobStatus(job.ID)
+		return err == nil && updatedJob.Status == models.StatusCompleted
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
 // Test job processing failure
 func (suite *QueueTestSuite) TestJobProcessingFailure() {
 	mockProcessor := &MockJobProcessor{}
@@ -192,6 +253,129 @@ func (suite *QueueTestSuite) TestJobCancellation() {
 	assert.Equal(suite.T(), models.StatusFailed, updatedJob.Status)
 }
 
+// Test cancelling a running job fails it once its worker returns
+func (suite *QueueTestSuite) TestCancelRunningJob() {
+	mockProcessor := &MockJobProcessor{processDelay: 5 * time.Second}
+	mockProcessor.On("ProcessJobWithProcess", mock.Anything, mock.Anything).Return(nil)
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Test Cancel Running")
+
+	tq := queue.NewTaskQueue(1, mockProcessor)
+	tq.Start()
+	defer tq.Stop()
+	assert.NoError(suite.T(), tq.EnqueueJob(job.ID))
+	assert.Eventually(suite.T(), func() bool { return tq.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+
+	assert.NoError(suite.T(), tq.CancelJob(job.ID))
+	assert.Eventually(suite.T(), func() bool { return !tq.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+
+	updatedJob, err := tq.GetJobStatus(job.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.StatusFailed, updatedJob.Status)
+	if assert.NotNil(suite.T(), updatedJob.ErrorMessage) {
+		assert.Equal(suite.T(), "Job was cancelled by user", *updatedJob.ErrorMessage)
+	}
+}
+
+// Test cancelling a queued job fails it before a worker takes it
+func (suite *QueueTestSuite) TestCancelQueuedJob() {
+	mockProcessor := &MockJobProcessor{}
+	tq := queue.NewTaskQueue(1, mockProcessor)
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Test Cancel Queued")
+	assert.NoError(suite.T(), tq.EnqueueJob(job.ID))
+
+	assert.NoError(suite.T(), tq.CancelJob(job.ID))
+	updatedJob, err := tq.GetJobStatus(job.ID)
+	assert.NoError(suite.T(), err)
+	assert.Equal(suite.T(), models.StatusFailed, updatedJob.Status)
+
+	// The stale channel entry is dropped by the worker
+	tq.Start()
+	defer tq.Stop()
+	time.Sleep(100 * time.Millisecond)
+	mockProcessor.AssertNotCalled(suite.T(), "ProcessJobWithProcess", mock.Anything, job.ID)
+
+	assert.Error(suite.T(), tq.CancelJob(job.ID), "a failed job cannot be cancelled")
+}
+
+// Test jobs run on the device the queue assigned and fail when no device can run them
+func (suite *QueueTestSuite) TestDeviceAssignment() {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Test Device Assignment")
+	gpuJob := suite.helper.CreateTestTranscriptionJob(suite.T(), "Test Device Assignment GPU")
+	suite.Require().NoError(suite.helper.DB.Model(gpuJob).Update("device", "cuda").Error)
+
+	assigned := make(chan string, 1)
+	mockProcessor := &MockJobProcessor{}
+	mockProcessor.On("ProcessJobWithProcess", mock.Anything, job.ID).Run(func(args mock.Arguments) {
+		device := ""
+		if lease, ok := devices.FromContext(args.Get(0).(context.Context)); ok {
+			device = lease.Device.ID
+		}
+		assigned <- device
+	}).Return(nil)
+
+	tq := queue.NewTaskQueue(1, mockProcessor)
+	tq.SetDevices(devices.NewManager(devices.Options{}))
+	tq.Start()
+	defer tq.Stop()
+	assert.NoError(suite.T(), tq.EnqueueJob(job.ID))
+	assert.NoError(suite.T(), tq.EnqueueJob(gpuJob.ID))
+
+	select {
+	case device := <-assigned:
+		assert.Equal(suite.T(), devices.KindCPU, device, "a job asking for auto runs on the CPU of a host without GPUs")
+	case <-time.After(2 * time.Second):
+		suite.FailNow("the job did not run")
+	}
+	var updatedJob *models.TranscriptionJob
+	assert.Eventually(suite.T(), func() bool {
+		var err error
+		updatedJob, err = tq.GetJobStatus(gpuJob.ID)
+		return err == nil && updatedJob.Status == models.StatusFailed
+	}, 2*time.Second, 5*time.Millisecond)
+	mockProcessor.AssertNotCalled(suite.T(), "ProcessJobWithProcess", mock.Anything, gpuJob.ID)
+	suite.Require().NotNil(updatedJob.ErrorMessage)
+	assert.Contains(suite.T(), *updatedJob.ErrorMessage, "no device can run the job")
+
+	assert.Eventually(suite.T(), func() bool {
+		stats := tq.GetQueueStats()
+		deviceList, ok := stats["devices"].([]devices.Device)
+		return ok && len(deviceList[0].Jobs) == 0
+	}, 2*time.Second, 5*time.Millisecond, "the device is released")
+}
+
+// Test pausing a running job tells the processor why it was stopped
+func (suite *QueueTestSuite) TestPauseJob() {
+	causes := make(chan error, 1)
+	mockProcessor := &MockJobProcessor{processDelay: 5 * time.Second}
+	mockProcessor.On("ProcessJobWithProcess", mock.Anything, mock.Anything).Return(nil).Run(func(args mock.Arguments) {
+		ctx := args.Get(0).(context.Context)
+		go func() {
+			<-ctx.Done()
+			causes <- context.Cause(ctx)
+		}()
+	})
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Test Pause")
+
+	tq := queue.NewTaskQueue(1, mockProcessor)
+	tq.Start()
+	defer tq.Stop()
+	assert.Error(suite.T(), tq.PauseJob(job.ID), "only running jobs can be paused")
+	assert.NoError(suite.T(), tq.EnqueueJob(job.ID))
+	assert.Eventually(suite.T(), func() bool { return tq.IsJobRunning(job.ID) }, time.Second, 10*time.Millisecond)
+
+	assert.NoError(suite.T(), tq.PauseJob(job.ID))
+	select {
+	case cause := <-causes:
+		assert.ErrorIs(suite.T(), cause, queue.ErrPaused)
+	case <-time.After(time.Second):
+		suite.T().Fatal("processor was not stopped")
+	}
+	assert.Eventually(suite.T(), func() bool {
+		updatedJob, err := tq.GetJobStatus(job.ID)
+		return err == nil && updatedJob.Status == models.StatusPaused
+	}, time.Second, 10*time.Millisecond)
+}
+
 // Test killing non-running job
 func (suite *QueueTestSuite) TestKillNonRunningJob() {
 	mockProcessor := &MockJobProcessor{}
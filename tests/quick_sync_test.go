@@ -0,0 +1,126 @@
+package tests
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type QuickSyncTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	quick   *transcription.QuickTranscriptionService
+	router  *gin.Engine
+	release chan struct{}
+	fail    bool
+}
+
+func (suite *QuickSyncTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "quick_sync_test.db")
+	suite.helper.Config.QuickSyncTimeoutSeconds = 5
+	suite.release = make(chan struct{})
+	suite.fail = false
+
+	processor := transcription.NewUnifiedJobProcessor()
+	suite.Require().NoError(processor.SetBackend(transcription.BackendFake))
+	processor.SetTempDirectory(suite.T().TempDir())
+
+	var err error
+	suite.quick, err = transcription.NewQuickTranscriptionService(suite.helper.Config, processor)
+	suite.Require().NoError(err)
+	suite.quick.SetProcessor(func(ctx context.Context, jobID string) error {
+		<-suite.release
+		if suite.fail {
+			return errors.New("audio could not be decoded")
+		}
+		return suite.helper.DB.Model(&models.TranscriptionJob{}).Where("id = ?", jobID).Updates(map[string]any{
+			"status":     models.StatusCompleted,
+			"transcript": `{"text":"Call me back"}`,
+		}).Error
+	})
+
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, processor, nil, suite.quick)
+	suite.router = api.SetupRoutes(handler, suite.helper.AuthService)
+}
+
+func (suite *QuickSyncTestSuite) TearDownTest() {
+	suite.quick.Close()
+	suite.helper.Cleanup()
+}
+
+func (suite *QuickSyncTestSuite) submit(fields map[string]string) (*httptest.ResponseRecorder, transcription.QuickTranscriptionJob) {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("audio", "voicemail.wav")
+	suite.Require().NoError(err)
+	part.Write([]byte("RIFF audio"))
+	for key, value := range fields {
+		writer.WriteField(key, value)
+	}
+	suite.Require().NoError(writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transcription/quick/sync", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+
+	var job transcription.QuickTranscriptionJob
+	if w.Code < 300 {
+		suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &job))
+	}
+	return w, job
+}
+
+func (suite *QuickSyncTestSuite) TestTranscriptInline() {
+	close(suite.release)
+
+	w, job := suite.submit(nil)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	assert.Equal(suite.T(), models.StatusCompleted, job.Status)
+	suite.Require().NotNil(job.Transcript)
+	assert.Contains(suite.T(), *job.Transcript, "Call me back")
+}
+
+func (suite *QuickSyncTestSuite) TestFailureInline() {
+	suite.fail = true
+	close(suite.release)
+
+	w, job := suite.submit(nil)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	assert.Equal(suite.T(), models.StatusFailed, job.Status)
+	suite.Require().NotNil(job.ErrorMessage)
+	assert.Contains(suite.T(), *job.ErrorMessage, "could not be decoded")
+}
+
+func (suite *QuickSyncTestSuite) TestFallsBackToPolling() {
+	w, job := suite.submit(map[string]string{"timeout": "0"})
+	suite.Require().Equal(http.StatusAccepted, w.Code)
+	assert.Equal(suite.T(), "/api/v1/transcription/quick/"+job.ID, w.Header().Get("Location"))
+	assert.Nil(suite.T(), job.Transcript)
+
+	close(suite.release)
+	finished, err := suite.quick.WaitQuickJob(context.Background(), job.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), models.StatusCompleted, finished.Status, "the job keeps running after the request returns")
+
+	w, _ = suite.submit(map[string]string{"timeout": "soon"})
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func TestQuickSyncTestSuite(t *testing.T) {
+	suite.Run(t, new(QuickSyncTestSuite))
+}
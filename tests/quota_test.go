@@ -0,0 +1,209 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/dropzone"
+	"synthezia/internal/models"
+	"synthezia/internal/quota"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// quotaQueue takes the jobs the dropzone queues and drops them
+type quotaQueue struct{}
+
+func (quotaQueue) EnqueueJob(string) error { return nil }
+
+type QuotaTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+}
+
+func (suite *QuotaTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "quota_test.db")
+	suite.helper.Config.StorageQuotaMB = 1
+}
+
+func (suite *QuotaTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// ownedJob creates a job of the test user, or of no user, with audio of the given size
+func (suite *QuotaTestSuite) ownedJob(fs fsys.FS, owned bool, audioPath string, size int) *models.TranscriptionJob {
+	if size > 0 {
+		suite.Require().NoError(fs.MkdirAll(filepath.Dir(audioPath), 0755))
+		suite.Require().NoError(fsys.WriteFile(fs, audioPath, bytes.Repeat([]byte("a"), size)))
+	}
+	job := &models.TranscriptionJob{AudioPath: audioPath, Status: models.StatusUploaded}
+	if owned {
+		job.UserID = &suite.helper.TestUser.ID
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	return job
+}
+
+func (suite *QuotaTestSuite) TestUsage() {
+	memFS := fsys.NewMemFS()
+	service := quota.NewService(suite.helper.DB)
+	service.SetFS(memFS)
+
+	job := suite.ownedJob(memFS, true, "uploads/call.mp3", 1000)
+	suite.Require().NoError(fsys.WriteFile(memFS, "uploads/call.proxy.mp3", make([]byte, 100)))
+	suite.Require().NoError(suite.helper.DB.Model(job).Updates(map[string]any{
+		"proxy_audio_path": "uploads/call.proxy.mp3",
+		"transcript":       "héllo",
+	}).Error)
+	suite.ownedJob(memFS, true, "uploads/call.mp3", 0) // A duplicate sharing the audio
+	suite.ownedJob(memFS, true, "uploads/deleted.mp3", 0)
+	suite.ownedJob(memFS, false, "uploads/api.mp3", 500)
+
+	usage, err := service.Usage(suite.helper.TestUser.ID)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), int64(3), usage.Jobs)
+	assert.Equal(suite.T(), int64(1000), usage.AudioBytes, "shared audio counts once, removed audio not at all")
+	assert.Equal(suite.T(), int64(106), usage.ArtifactBytes, "the proxy and the transcript's bytes")
+	assert.Equal(suite.T(), int64(1106), usage.TotalBytes)
+	assert.Zero(suite.T(), usage.QuotaBytes)
+	assert.Nil(suite.T(), usage.RemainingBytes)
+	assert.NoError(suite.T(), service.Check(&suite.helper.TestUser.ID, 1<<40), "no quota is set")
+
+	service.SetLimit(2000)
+	usage, err = service.Usage(suite.helper.TestUser.ID)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(usage.RemainingBytes)
+	assert.Equal(suite.T(), int64(894), *usage.RemainingBytes)
+
+	assert.NoError(suite.T(), service.Check(&suite.helper.TestUser.ID, 894))
+	err = service.Check(&suite.helper.TestUser.ID, 895)
+	var exceeded *quota.ExceededError
+	suite.Require().ErrorAs(err, &exceeded)
+	assert.Equal(suite.T(), int64(895), exceeded.Incoming)
+	assert.Equal(suite.T(), "storage quota exceeded: 1.1 KB of 2.0 KB used, and this upload needs 895 B more", err.Error())
+	assert.NoError(suite.T(), service.Check(nil, 1<<40), "jobs without a user are not limited")
+
+	report, err := service.Report()
+	suite.Require().NoError(err)
+	suite.Require().Len(report, 2)
+	assert.Equal(suite.T(), "testuser", report[0].Username)
+	assert.Equal(suite.T(), int64(1106), report[0].TotalBytes)
+	assert.Nil(suite.T(), report[1].UserID)
+	assert.Equal(suite.T(), int64(500), report[1].TotalBytes)
+}
+
+func (suite *QuotaTestSuite) upload(router *gin.Engine, bearer bool, size int) *httptest.ResponseRecorder {
+	var body bytes.Buffer
+	writer := multipart.NewWriter(&body)
+	part, err := writer.CreateFormFile("audio", "memo.wav")
+	suite.Require().NoError(err)
+	part.Write(bytes.Repeat([]byte("a"), size))
+	suite.Require().NoError(writer.Close())
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/transcription/upload", &body)
+	req.Header.Set("Content-Type", writer.FormDataContentType())
+	if bearer {
+		req.Header.Set("Authorization", "Bearer "+suite.helper.TestToken)
+	} else {
+		req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	}
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	return w
+}
+
+func (suite *QuotaTestSuite) TestUploadEnforced() {
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, nil, nil, nil)
+	router := api.SetupRoutes(handler, suite.helper.AuthService)
+	existing := suite.ownedJob(fsys.OS, true, filepath.Join(suite.helper.Config.UploadDir, "existing.mp3"), 1<<20-100)
+
+	w := suite.upload(router, true, 200)
+	suite.Require().Equal(http.StatusInsufficientStorage, w.Code, w.Body.String())
+	var rejected struct {
+		Error string      `json:"error"`
+		Usage quota.Usage `json:"usage"`
+	}
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &rejected))
+	assert.True(suite.T(), strings.HasPrefix(rejected.Error, "storage quota exceeded"), rejected.Error)
+	assert.Equal(suite.T(), int64(1<<20-100), rejected.Usage.TotalBytes)
+
+	w = suite.upload(router, false, 200)
+	assert.Equal(suite.T(), http.StatusOK, w.Code, "API key uploads belong to no user")
+
+	suite.Require().NoError(os.Remove(existing.AudioPath))
+	w = suite.upload(router, true, 200)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var job models.TranscriptionJob
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &job))
+	suite.Require().NotNil(job.UserID)
+	assert.Equal(suite.T(), suite.helper.TestUser.ID, *job.UserID)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/user/storage", nil)
+	req.Header.Set("Authorization", "Bearer "+suite.helper.TestToken)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var usage quota.Usage
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &usage))
+	assert.Equal(suite.T(), int64(2), usage.Jobs)
+	assert.Equal(suite.T(), int64(200), usage.AudioBytes)
+	assert.Equal(suite.T(), int64(1<<20), usage.QuotaBytes)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/admin/storage", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var report api.StorageReport
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	suite.Require().Len(report.Users, 1)
+	assert.Equal(suite.T(), int64(200), report.Users[0].AudioBytes)
+	assert.Equal(suite.T(), int64(1), report.Unowned.Jobs)
+}
+
+func (suite *QuotaTestSuite) TestDropzoneIngest() {
+	memFS := fsys.NewMemFS()
+	dropzonePath := filepath.Join("data", "dropzone")
+	suite.Require().NoError(memFS.MkdirAll(dropzonePath, 0755))
+	suite.Require().NoError(fsys.WriteFile(memFS, filepath.Join(dropzonePath, "large.mp3"), make([]byte, 300)))
+	suite.ownedJob(memFS, true, filepath.Join(suite.helper.Config.UploadDir, "existing.mp3"), 1<<20-200)
+
+	cfg := *suite.helper.Config
+	cfg.DropzonePaths = dropzonePath + ";user=testuser;auto_transcribe=false"
+	fakeClock := clock.NewFake(time.Now())
+	service := dropzone.NewService(&cfg, quotaQueue{})
+	service.SetFS(memFS)
+	service.SetClock(fakeClock)
+
+	started := make(chan error, 1)
+	go func() { started <- service.Start() }()
+	fakeClock.BlockUntil(1)
+	fakeClock.Advance(500 * time.Millisecond)
+	suite.Require().NoError(<-started)
+	defer service.Stop()
+
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "large.mp3").Count(&count)
+	assert.Zero(suite.T(), count)
+	assert.True(suite.T(), fsys.Exists(memFS, filepath.Join(dropzonePath, "large.mp3")), "the file waits for space to be freed")
+	statuses := service.Status()
+	suite.Require().NotEmpty(statuses)
+	assert.Contains(suite.T(), statuses[0].Error, "testuser: storage quota exceeded")
+}
+
+func TestQuotaTestSuite(t *testing.T) {
+	suite.Run(t, new(QuotaTestSuite))
+}
@@ -0,0 +1,167 @@
+package tests
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/llm"
+	"synthezia/internal/models"
+	"synthezia/internal/regenerate"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeSummarizer answers with a canned summary and fails prompts containing "FAIL"
+type fakeSummarizer struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+func (f *fakeSummarizer) GetModels(ctx context.Context) ([]string, error) {
+	return []string{"test-model"}, nil
+}
+
+func (f *fakeSummarizer) ChatCompletion(ctx context.Context, model string, messages []llm.ChatMessage, temperature float64) (*llm.ChatResponse, error) {
+	f.mu.Lock()
+	f.prompts = append(f.prompts, messages[0].Content)
+	f.mu.Unlock()
+	if strings.Contains(messages[0].Content, "FAIL") {
+		return nil, errors.New("model overloaded")
+	}
+	resp := &llm.ChatResponse{}
+	resp.Choices = make([]struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message.Content = "new summary by " + model
+	return resp, nil
+}
+
+func (f *fakeSummarizer) ChatCompletionStream(ctx context.Context, model string, messages []llm.ChatMessage, temperature float64) (<-chan string, <-chan error) {
+	return nil, nil
+}
+
+type RegenerateTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+	fake   *fakeSummarizer
+	runner *regenerate.Runner
+	tpl    models.SummaryTemplate
+}
+
+func (suite *RegenerateTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "regenerate_test.db")
+	suite.fake = &fakeSummarizer{}
+	suite.runner = regenerate.NewRunner(suite.helper.DB, func() (llm.Service, error) {
+		return suite.fake, nil
+	})
+
+	suite.tpl = models.SummaryTemplate{Name: "Minutes", Model: "tpl-model", Prompt: "Write minutes:", UpdatedAt: time.Now().Add(-time.Hour)}
+	require.NoError(suite.T(), suite.helper.DB.Create(&suite.tpl).Error)
+}
+
+func (suite *RegenerateTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *RegenerateTestSuite) createSummarized(id, transcript string, summarizedAt time.Time) {
+	job := models.TranscriptionJob{ID: id, AudioPath: id + ".mp3", Status: models.StatusCompleted, Transcript: &transcript}
+	require.NoError(suite.T(), suite.helper.DB.Create(&job).Error)
+	summary := models.Summary{TranscriptionID: id, TemplateID: &suite.tpl.ID, Model: "old", Content: "old summary", CreatedAt: summarizedAt}
+	require.NoError(suite.T(), suite.helper.DB.Create(&summary).Error)
+}
+
+// Test stale summaries are regenerated and failures are reported per item
+func (suite *RegenerateTestSuite) TestRegenerateStale() {
+	stale := time.Now().Add(-2 * time.Hour)
+	suite.createSummarized("job-ok", `{"segments":[{"text":"Hello","speaker":"SPEAKER_00"}]}`, stale)
+	suite.createSummarized("job-fail", `{"segments":[{"text":"FAIL"}]}`, stale)
+	suite.createSummarized("job-current", `{"segments":[{"text":"Fresh"}]}`, time.Now())
+	suite.helper.DB.Create(&models.SpeakerMapping{TranscriptionJobID: "job-ok", OriginalSpeaker: "SPEAKER_00", CustomName: "Alice"})
+
+	run, err := suite.runner.Start(suite.tpl.ID, regenerate.Selection{OnlyStale: true})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), 2, run.Total)
+	assert.Equal(suite.T(), "tpl-model", run.Model)
+	suite.runner.Wait(run.ID)
+
+	var done models.SummaryRegeneration
+	require.NoError(suite.T(), suite.helper.DB.Preload("Items").Where("id = ?", run.ID).First(&done).Error)
+	assert.Equal(suite.T(), models.RegenerationCompleted, done.Status)
+	assert.Equal(suite.T(), 1, done.Succeeded)
+	assert.Equal(suite.T(), 1, done.Failed)
+	assert.NotNil(suite.T(), done.CompletedAt)
+	for _, item := range done.Items {
+		if item.TranscriptionID == "job-fail" {
+			assert.Equal(suite.T(), models.RegenerationFailed, item.Status)
+			require.NotNil(suite.T(), item.Error)
+			assert.Contains(suite.T(), *item.Error, "model overloaded")
+		} else {
+			assert.Equal(suite.T(), models.RegenerationCompleted, item.Status)
+			assert.NotNil(suite.T(), item.SummaryID)
+		}
+	}
+
+	var job models.TranscriptionJob
+	suite.helper.DB.Where("id = ?", "job-ok").First(&job)
+	require.NotNil(suite.T(), job.Summary)
+	assert.Equal(suite.T(), "new summary by tpl-model", *job.Summary)
+	assert.Contains(suite.T(), suite.fake.prompts, "Write minutes:\n\nAlice: Hello\n")
+}
+
+// Test explicit selections, model overrides and selection errors
+func (suite *RegenerateTestSuite) TestSelection() {
+	suite.createSummarized("job-a", `{"segments":[{"text":"A"}]}`, time.Now())
+
+	_, err := suite.runner.Start(suite.tpl.ID, regenerate.Selection{OnlyStale: true})
+	assert.ErrorIs(suite.T(), err, regenerate.ErrNothingToRegenerate)
+
+	run, err := suite.runner.Start(suite.tpl.ID, regenerate.Selection{TranscriptionIDs: []string{"job-a"}, Model: "other"})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "other", run.Model)
+	suite.runner.Wait(run.ID)
+
+	var latest models.Summary
+	suite.helper.DB.Where("transcription_id = ?", "job-a").Order("created_at DESC").First(&latest)
+	assert.Equal(suite.T(), "new summary by other", latest.Content)
+
+	noModel := models.SummaryTemplate{Name: "Bare", Prompt: "Summarize"}
+	require.NoError(suite.T(), suite.helper.DB.Create(&noModel).Error)
+	_, err = suite.runner.Start(noModel.ID, regenerate.Selection{TranscriptionIDs: []string{"job-a"}})
+	assert.ErrorIs(suite.T(), err, regenerate.ErrNoModel)
+}
+
+// Test interrupted runs continue with their pending items
+func (suite *RegenerateTestSuite) TestResume() {
+	suite.createSummarized("job-r", `{"segments":[{"text":"Resume me"}]}`, time.Now())
+	run := models.SummaryRegeneration{
+		TemplateID: suite.tpl.ID,
+		Model:      "tpl-model",
+		Status:     models.RegenerationRunning,
+		Total:      1,
+		Items:      []models.SummaryRegenerationItem{{TranscriptionID: "job-r", Status: models.RegenerationPending}},
+	}
+	require.NoError(suite.T(), suite.helper.DB.Create(&run).Error)
+
+	suite.runner.Resume()
+	suite.runner.Wait(run.ID)
+
+	var done models.SummaryRegeneration
+	suite.helper.DB.Where("id = ?", run.ID).First(&done)
+	assert.Equal(suite.T(), models.RegenerationCompleted, done.Status)
+	assert.Equal(suite.T(), 1, done.Succeeded)
+}
+
+func TestRegenerateTestSuite(t *testing.T) {
+	suite.Run(t, new(RegenerateTestSuite))
+}
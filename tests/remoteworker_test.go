@@ -0,0 +1,228 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+	"synthezia/internal/remoteworker"
+	"synthezia/internal/transcription/interfaces"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RemoteWorkerTestSuite struct {
+	suite.Suite
+	helper     *TestHelper
+	dispatcher *remoteworker.Dispatcher
+	server     *httptest.Server
+	audioPath  string
+	stopAgent  context.CancelFunc
+	agentDone  chan struct{}
+}
+
+func (suite *RemoteWorkerTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "remoteworker_test.db")
+	suite.helper.Config.WorkerToken = "worker-secret"
+	suite.dispatcher = remoteworker.NewDispatcher(time.Second)
+
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, nil, nil, nil)
+	handler.SetRemoteWorkers(suite.dispatcher)
+	suite.server = httptest.NewServer(api.SetupRoutes(handler, suite.helper.AuthService))
+
+	suite.audioPath = filepath.Join(suite.T().TempDir(), "meeting.wav")
+	suite.Require().NoError(os.WriteFile(suite.audioPath, []byte("RIFF audio"), 0644))
+}
+
+func (suite *RemoteWorkerTestSuite) TearDownTest() {
+	if suite.stopAgent != nil {
+		suite.stopAgent()
+		<-suite.agentDone
+		suite.stopAgent = nil
+	}
+	suite.server.Close()
+	suite.helper.Cleanup()
+}
+
+// startAgent runs a worker agent transcribing with the given function
+func (suite *RemoteWorkerTestSuite) startAgent(transcribe remoteworker.TranscribeFunc) {
+	agent := remoteworker.NewAgent(remoteworker.AgentOptions{
+		ServerURL:  suite.server.URL,
+		Token:      "worker-secret",
+		Name:       "gpu-box",
+		TempDir:    suite.T().TempDir(),
+		Transcribe: transcribe,
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	suite.stopAgent = cancel
+	suite.agentDone = make(chan struct{})
+	go func() {
+		defer close(suite.agentDone)
+		agent.Run(ctx)
+	}()
+}
+
+func (suite *RemoteWorkerTestSuite) transcribe(ctx context.Context, progress func(interfaces.TranscriptionProgress)) (*interfaces.TranscriptResult, error) {
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+	return suite.dispatcher.Transcribe(ctx, suite.audioPath, models.WhisperXParams{Model: "small", Device: "cpu"}, interfaces.ProcessingContext{
+		JobID:    "job-1",
+		Progress: progress,
+	})
+}
+
+func (suite *RemoteWorkerTestSuite) TestRoundTrip() {
+	suite.startAgent(func(ctx context.Context, taskID, audioPath string, params models.WhisperXParams, progress func(interfaces.TranscriptionProgress)) (*interfaces.TranscriptResult, error) {
+		data, err := os.ReadFile(audioPath)
+		if err != nil {
+			return nil, err
+		}
+		assert.Equal(suite.T(), "RIFF audio", string(data))
+		assert.Equal(suite.T(), ".wav", filepath.Ext(audioPath))
+		assert.Equal(suite.T(), "small", params.Model)
+
+		segment := interfaces.TranscriptSegment{Start: 0, End: 1.5, Text: "Hello"}
+		progress(interfaces.TranscriptionProgress{Percent: 50, Segment: &segment})
+		progress(interfaces.TranscriptionProgress{Percent: 100})
+		return &interfaces.TranscriptResult{Text: "Hello", Language: "en", Segments: []interfaces.TranscriptSegment{segment}}, nil
+	})
+
+	var mu sync.Mutex
+	var updates []interfaces.TranscriptionProgress
+	result, err := suite.transcribe(context.Background(), func(p interfaces.TranscriptionProgress) {
+		mu.Lock()
+		defer mu.Unlock()
+		updates = append(updates, p)
+	})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), "Hello", result.Text)
+	suite.Require().Len(result.Segments, 1)
+
+	mu.Lock()
+	suite.Require().Len(updates, 2, "progress is sent before the result")
+	assert.Equal(suite.T(), "Hello", updates[0].Segment.Text)
+	assert.Equal(suite.T(), 100.0, updates[1].Percent)
+	mu.Unlock()
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/workers", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	suite.server.Config.Handler.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var resp api.WorkerListResponse
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &resp))
+	suite.Require().Len(resp.Workers, 1)
+	assert.Equal(suite.T(), "gpu-box", resp.Workers[0].Name)
+	assert.Empty(suite.T(), resp.Workers[0].Tasks)
+	assert.Zero(suite.T(), resp.WaitingTasks)
+}
+
+func (suite *RemoteWorkerTestSuite) TestFailure() {
+	suite.startAgent(func(ctx context.Context, taskID, audioPath string, params models.WhisperXParams, progress func(interfaces.TranscriptionProgress)) (*interfaces.TranscriptResult, error) {
+		return nil, errors.New("CUDA out of memory")
+	})
+
+	_, err := suite.transcribe(context.Background(), nil)
+	assert.ErrorContains(suite.T(), err, "remote worker gpu-box: CUDA out of memory")
+}
+
+func (suite *RemoteWorkerTestSuite) TestCancel() {
+	started := make(chan struct{})
+	stopped := make(chan struct{})
+	suite.startAgent(func(ctx context.Context, taskID, audioPath string, params models.WhisperXParams, progress func(interfaces.TranscriptionProgress)) (*interfaces.TranscriptResult, error) {
+		close(started)
+		<-ctx.Done()
+		close(stopped)
+		return nil, ctx.Err()
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		<-started
+		cancel()
+	}()
+	_, err := suite.transcribe(ctx, nil)
+	assert.ErrorIs(suite.T(), err, context.Canceled)
+
+	select {
+	case <-stopped:
+	case <-time.After(5 * time.Second):
+		suite.Fail("the worker was not told to stop the cancelled task")
+	}
+}
+
+func (suite *RemoteWorkerTestSuite) TestWorkerToken() {
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/workers/register", nil)
+	req.Header.Set("Authorization", "Bearer wrong")
+	w := httptest.NewRecorder()
+	suite.server.Config.Handler.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusUnauthorized, w.Code)
+
+	suite.helper.Config.WorkerToken = ""
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/workers/register", nil)
+	req.Header.Set("Authorization", "Bearer ")
+	w = httptest.NewRecorder()
+	suite.server.Config.Handler.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusNotFound, w.Code, "worker endpoints are off without a token")
+}
+
+func (suite *RemoteWorkerTestSuite) TestExpiredWorker() {
+	suite.dispatcher = remoteworker.NewDispatcher(10 * time.Second)
+	now := time.Now()
+	suite.dispatcher.SetClock(func() time.Time { return now })
+	first := suite.dispatcher.Register(remoteworker.RegisterRequest{Name: "first", Slots: 1}, "10.0.0.1")
+
+	type outcome struct {
+		result *interfaces.TranscriptResult
+		err    error
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		result, err := suite.transcribe(context.Background(), nil)
+		done <- outcome{result, err}
+	}()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	task, err := suite.dispatcher.Next(ctx, first.WorkerID)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(task)
+	assert.Equal(suite.T(), "job-1", task.JobID)
+	assert.Equal(suite.T(), "meeting.wav", task.AudioName)
+
+	now = now.Add(10 * time.Second)
+	second := suite.dispatcher.Register(remoteworker.RegisterRequest{Name: "second", Slots: 1}, "10.0.0.2")
+	now = now.Add(25 * time.Second)
+	suite.dispatcher.Expire()
+	suite.Require().Len(suite.dispatcher.Workers(), 1, "the first worker missed three heartbeats")
+
+	requeued, err := suite.dispatcher.Next(ctx, second.WorkerID)
+	suite.Require().NoError(err)
+	suite.Require().NotNil(requeued)
+	assert.Equal(suite.T(), task.ID, requeued.ID)
+
+	assert.ErrorIs(suite.T(), suite.dispatcher.Progress(first.WorkerID, task.ID, nil), remoteworker.ErrUnknownWorker)
+	heartbeat, err := suite.dispatcher.Heartbeat(second.WorkerID, remoteworker.HeartbeatRequest{Running: []string{task.ID, "stale"}})
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), []string{"stale"}, heartbeat.Cancel)
+
+	suite.Require().NoError(suite.dispatcher.Complete(second.WorkerID, task.ID, &interfaces.TranscriptResult{Text: "Done"}))
+	assert.ErrorIs(suite.T(), suite.dispatcher.Complete(second.WorkerID, task.ID, &interfaces.TranscriptResult{}), remoteworker.ErrUnknownTask)
+	got := <-done
+	suite.Require().NoError(got.err)
+	assert.Equal(suite.T(), "Done", got.result.Text)
+}
+
+func TestRemoteWorkerTestSuite(t *testing.T) {
+	suite.Run(t, new(RemoteWorkerTestSuite))
+}
@@ -0,0 +1,123 @@
+package tests
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/models"
+	"synthezia/internal/queue"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// heldTasks are tasks that hold until release is closed, counting how many
+// run at once
+type heldTasks struct {
+	release chan struct{}
+	mu      sync.Mutex
+	running int
+	peak    int
+	done    int
+}
+
+type heldTask struct {
+	id    string
+	tasks *heldTasks
+}
+
+func (t heldTask) TaskID() string { return t.id }
+
+func (t heldTask) Run(ctx context.Context) error {
+	t.tasks.mu.Lock()
+	t.tasks.running++
+	t.tasks.peak = max(t.tasks.peak, t.tasks.running)
+	t.tasks.mu.Unlock()
+	defer func() {
+		t.tasks.mu.Lock()
+		t.tasks.running--
+		t.tasks.done++
+		t.tasks.mu.Unlock()
+	}()
+
+	select {
+	case <-t.tasks.release:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *heldTasks) counts() (running, peak, done int) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.running, t.peak, t.done
+}
+
+// instantProcessor completes every job at once
+type instantProcessor struct{}
+
+func (instantProcessor) ProcessJob(ctx context.Context, jobID string) error {
+	return nil
+}
+
+func (instantProcessor) ProcessJobWithProcess(ctx context.Context, jobID string, registerProcess func(*exec.Cmd)) error {
+	return nil
+}
+
+type ResourceLimitsTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+}
+
+func (suite *ResourceLimitsTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "resource_limits_test.db")
+}
+
+func (suite *ResourceLimitsTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// Test a burst of tasks runs within its own limit and does not hold back
+// transcriptions on the single worker
+func (suite *ResourceLimitsTestSuite) TestTasksDoNotBlockTranscriptions() {
+	tasks := &heldTasks{release: make(chan struct{})}
+	tq := queue.NewTaskQueue(1, instantProcessor{})
+	tq.SetTaskConcurrency(2)
+	tq.Start()
+	defer tq.Stop()
+
+	for i := 0; i < 5; i++ {
+		suite.Require().NoError(tq.EnqueueTask(heldTask{id: fmt.Sprintf("conversion:%d", i), tasks: tasks}))
+	}
+	assert.Eventually(suite.T(), func() bool {
+		running, _, _ := tasks.counts()
+		return running == 2
+	}, 2*time.Second, 10*time.Millisecond)
+
+	job := &models.TranscriptionJob{AudioPath: "talk.mp3", Status: models.StatusPending}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	suite.Require().NoError(tq.EnqueueJob(job.ID))
+	assert.Eventually(suite.T(), func() bool {
+		var current models.TranscriptionJob
+		suite.helper.DB.Where("id = ?", job.ID).First(&current)
+		return current.Status == models.StatusCompleted
+	}, 2*time.Second, 10*time.Millisecond)
+
+	close(tasks.release)
+	assert.Eventually(suite.T(), func() bool {
+		_, _, done := tasks.counts()
+		return done == 5
+	}, 2*time.Second, 10*time.Millisecond)
+	_, peak, _ := tasks.counts()
+	assert.Equal(suite.T(), 2, peak)
+	assert.Equal(suite.T(), 2, tq.GetQueueStats()["task_workers"])
+}
+
+func TestResourceLimitsTestSuite(t *testing.T) {
+	suite.Run(t, new(ResourceLimitsTestSuite))
+}
@@ -0,0 +1,197 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+	"synthezia/internal/retention"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type RetentionTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	memFS   *fsys.MemFS
+	clock   *clock.Fake
+	service *retention.Service
+}
+
+func (suite *RetentionTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "retention_test.db")
+	suite.memFS = fsys.NewMemFS()
+	suite.clock = clock.NewFake(time.Date(2026, 6, 1, 12, 0, 0, 0, time.UTC))
+	suite.service = retention.NewService(suite.helper.DB)
+	suite.service.SetFS(suite.memFS)
+	suite.service.SetClock(suite.clock)
+	suite.service.SetArchiveDir("archive")
+}
+
+func (suite *RetentionTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// completedJob creates a job that finished transcribing the given days ago,
+// with audio of the given size
+func (suite *RetentionTestSuite) completedJob(title string, daysAgo int, tags string, owned bool) *models.TranscriptionJob {
+	audioPath := filepath.Join("uploads", title+".mp3")
+	suite.Require().NoError(suite.memFS.MkdirAll("uploads", 0755))
+	suite.Require().NoError(fsys.WriteFile(suite.memFS, audioPath, make([]byte, 1000)))
+	transcript := `{"text":"hello","segments":[]}`
+	job := &models.TranscriptionJob{Title: &title, AudioPath: audioPath, Status: models.StatusCompleted, Transcript: &transcript}
+	if tags != "" {
+		job.Tags = &tags
+	}
+	if owned {
+		job.UserID = &suite.helper.TestUser.ID
+	}
+	suite.Require().NoError(suite.helper.DB.Create(job).Error)
+	completedAt := suite.clock.Now().AddDate(0, 0, -daysAgo)
+	suite.Require().NoError(suite.helper.DB.Create(&models.TranscriptionJobExecution{
+		TranscriptionJobID: job.ID,
+		StartedAt:          completedAt.Add(-time.Minute),
+		CompletedAt:        &completedAt,
+		Status:             models.StatusCompleted,
+	}).Error)
+	return job
+}
+
+func (suite *RetentionTestSuite) reload(id string) models.TranscriptionJob {
+	var job models.TranscriptionJob
+	suite.Require().NoError(suite.helper.DB.Where("id = ?", id).First(&job).Error)
+	return job
+}
+
+func (suite *RetentionTestSuite) TestParsePolicies() {
+	policies, err := retention.ParsePolicies(" *;days=90 , tag=legal;days=365;audio=archive,user=testuser;days=30;audio=keep;transcripts=true")
+	suite.Require().NoError(err)
+	suite.Require().Len(policies, 3)
+	assert.Equal(suite.T(), retention.Policy{Days: 90, Audio: retention.AudioDelete}, policies[0])
+	assert.Equal(suite.T(), retention.Policy{Tag: "legal", Days: 365, Audio: retention.AudioArchive}, policies[1])
+	assert.Equal(suite.T(), "user=testuser;days=30;audio=keep;transcripts=true", policies[2].String())
+
+	policies, err = retention.ParsePolicies("")
+	suite.Require().NoError(err)
+	assert.Empty(suite.T(), policies)
+
+	for _, spec := range []string{
+		"*",
+		"*;days=0",
+		"*;days=90;audio=shred",
+		"*;days=90;audio=keep",
+		"*;days=90;size=1",
+		"group=sales;days=90",
+		"tag=a;days=1,TAG=A;days=2",
+	} {
+		_, err := retention.ParsePolicies(spec)
+		assert.Error(suite.T(), err, spec)
+	}
+}
+
+func (suite *RetentionTestSuite) TestSweep() {
+	policies, err := retention.ParsePolicies("*;days=90,tag=legal;days=365;audio=archive,user=testuser;days=30;audio=keep;transcripts=true")
+	suite.Require().NoError(err)
+	suite.service.SetPolicies(policies)
+
+	old := suite.completedJob("old", 100, "", false)
+	recent := suite.completedJob("recent", 60, "", false)
+	legal := suite.completedJob("legal", 400, `["Legal"]`, true)
+	held := suite.completedJob("held", 100, `["legal"]`, false)
+	owned := suite.completedJob("owned", 31, "", true)
+	suite.Require().NoError(suite.helper.DB.Create(&models.Translation{TranscriptionID: owned.ID, TargetLanguage: "fr", Provider: "llm", Status: "completed"}).Error)
+
+	report, err := suite.service.Sweep(context.Background(), true)
+	suite.Require().NoError(err)
+	assert.True(suite.T(), report.DryRun)
+	ids := []string{}
+	for _, action := range report.Jobs {
+		ids = append(ids, action.JobID)
+	}
+	assert.ElementsMatch(suite.T(), []string{old.ID, legal.ID, owned.ID}, ids, "a tag policy wins over the user's and the default")
+	assert.Equal(suite.T(), int64(2000), report.BytesFreed, "kept audio is not counted")
+	assert.True(suite.T(), fsys.Exists(suite.memFS, old.AudioPath), "a dry run changes nothing")
+
+	report, err = suite.service.Sweep(context.Background(), false)
+	suite.Require().NoError(err)
+	suite.Require().Len(report.Jobs, 3)
+	for _, action := range report.Jobs {
+		assert.Empty(suite.T(), action.Error)
+	}
+
+	stored := suite.reload(old.ID)
+	assert.False(suite.T(), fsys.Exists(suite.memFS, old.AudioPath))
+	assert.NotNil(suite.T(), stored.SourceAudioRemovedAt)
+	assert.NotNil(suite.T(), stored.RetentionAppliedAt)
+	assert.NotNil(suite.T(), stored.Transcript, "the default policy keeps transcripts")
+
+	stored = suite.reload(legal.ID)
+	assert.Equal(suite.T(), filepath.Join("archive", legal.ID, "legal.mp3"), stored.AudioPath)
+	assert.True(suite.T(), fsys.Exists(suite.memFS, stored.AudioPath))
+	assert.False(suite.T(), fsys.Exists(suite.memFS, legal.AudioPath))
+	assert.Nil(suite.T(), stored.SourceAudioRemovedAt, "archived audio can still be played")
+
+	stored = suite.reload(owned.ID)
+	assert.True(suite.T(), fsys.Exists(suite.memFS, owned.AudioPath))
+	assert.Nil(suite.T(), stored.Transcript)
+	var translations int64
+	suite.helper.DB.Model(&models.Translation{}).Where("transcription_id = ?", owned.ID).Count(&translations)
+	assert.Zero(suite.T(), translations)
+
+	stored = suite.reload(recent.ID)
+	assert.Nil(suite.T(), stored.RetentionAppliedAt)
+	stored = suite.reload(held.ID)
+	assert.Nil(suite.T(), stored.RetentionAppliedAt)
+
+	suite.clock.Advance(31 * 24 * time.Hour)
+	report, err = suite.service.Sweep(context.Background(), false)
+	suite.Require().NoError(err)
+	suite.Require().Len(report.Jobs, 1, "jobs are handled once")
+	assert.Equal(suite.T(), recent.ID, report.Jobs[0].JobID)
+}
+
+func (suite *RetentionTestSuite) TestEndpoints() {
+	policies, err := retention.ParsePolicies("*;days=90")
+	suite.Require().NoError(err)
+	suite.service.SetPolicies(policies)
+	job := suite.completedJob("old", 100, "", false)
+
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, nil, nil, nil)
+	handler.SetRetention(suite.service)
+	router := api.SetupRoutes(handler, suite.helper.AuthService)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/admin/retention", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	var report retention.Report
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	assert.True(suite.T(), report.DryRun)
+	suite.Require().Len(report.Jobs, 1)
+	assert.Equal(suite.T(), "*;days=90;audio=delete", report.Jobs[0].Policy)
+	assert.True(suite.T(), fsys.Exists(suite.memFS, job.AudioPath))
+
+	req = httptest.NewRequest(http.MethodPost, "/api/v1/admin/retention/run", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w = httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code, w.Body.String())
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &report))
+	assert.False(suite.T(), report.DryRun)
+	assert.Equal(suite.T(), int64(1000), report.BytesFreed)
+	assert.False(suite.T(), fsys.Exists(suite.memFS, job.AudioPath))
+}
+
+func TestRetentionTestSuite(t *testing.T) {
+	suite.Run(t, new(RetentionTestSuite))
+}
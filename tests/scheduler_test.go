@@ -0,0 +1,193 @@
+package tests
+
+import (
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/models"
+	"synthezia/internal/scheduler"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/cron"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SchedulerTestSuite struct {
+	suite.Suite
+	helper    *TestHelper
+	clock     *clock.Fake
+	scheduler *scheduler.Scheduler
+
+	mu       sync.Mutex
+	enqueued []string
+}
+
+func (suite *SchedulerTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "scheduler_test.db")
+	suite.clock = clock.NewFake(time.Date(2024, 3, 1, 18, 0, 0, 0, time.UTC))
+	suite.enqueued = nil
+	suite.scheduler = scheduler.New(suite.helper.DB)
+	suite.scheduler.SetClock(suite.clock)
+	suite.scheduler.SetEnqueue(func(jobID string) error {
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+		suite.enqueued = append(suite.enqueued, jobID)
+		return nil
+	})
+}
+
+func (suite *SchedulerTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *SchedulerTestSuite) createJob(id string, status models.JobStatus, runAfter *time.Time) {
+	job := &models.TranscriptionJob{ID: id, AudioPath: id + ".mp3", Status: status, RunAfter: runAfter}
+	require.NoError(suite.T(), suite.helper.DB.Create(job).Error)
+}
+
+// Test cron expressions match the minutes cron would run them
+func (suite *SchedulerTestSuite) TestCronNext() {
+	from := time.Date(2024, 3, 1, 18, 30, 0, 0, time.UTC) // a Friday
+	cases := []struct {
+		expr string
+		want time.Time
+	}{
+		{"* * * * *", time.Date(2024, 3, 1, 18, 31, 0, 0, time.UTC)},
+		{"*/15 * * * *", time.Date(2024, 3, 1, 18, 45, 0, 0, time.UTC)},
+		{"0 1 * * *", time.Date(2024, 3, 2, 1, 0, 0, 0, time.UTC)},
+		{"30 22 * * mon-fri", time.Date(2024, 3, 1, 22, 30, 0, 0, time.UTC)},
+		{"0 2 * * sat,sun", time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2024, 3, 3, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 feb *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		{"0 0 1 * 1", time.Date(2024, 3, 4, 0, 0, 0, 0, time.UTC)}, // day or weekday, as in cron
+		{"@daily", time.Date(2024, 3, 2, 0, 0, 0, 0, time.UTC)},
+		{"@monthly", time.Date(2024, 4, 1, 0, 0, 0, 0, time.UTC)},
+		{"5/20 18 * * *", time.Date(2024, 3, 1, 18, 45, 0, 0, time.UTC)},
+	}
+	for _, c := range cases {
+		schedule, err := cron.Parse(c.expr)
+		require.NoError(suite.T(), err, c.expr)
+		assert.Equal(suite.T(), c.want, schedule.Next(from), c.expr)
+	}
+
+	never, err := cron.Parse("0 0 31 2 *")
+	require.NoError(suite.T(), err)
+	assert.True(suite.T(), never.Next(from).IsZero())
+
+	for _, expr := range []string{"", "* * * *", "60 * * * *", "* 24 * * *", "* * 0 * *", "* * * 13 *", "*/0 * * * *", "5-1 * * * *", "* * * * funday"} {
+		_, err := cron.Parse(expr)
+		assert.Error(suite.T(), err, expr)
+	}
+}
+
+// Test the run_after and schedule options resolve to a start time
+func (suite *SchedulerTestSuite) TestResolveRunAfter() {
+	now := suite.clock.Now()
+
+	runAfter, err := scheduler.ResolveRunAfter("", "", now)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), runAfter)
+
+	runAfter, err = scheduler.ResolveRunAfter("2024-03-01T23:00:00+01:00", "", now)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), runAfter)
+	assert.Equal(suite.T(), time.Date(2024, 3, 1, 22, 0, 0, 0, time.UTC), *runAfter)
+
+	runAfter, err = scheduler.ResolveRunAfter("2024-03-01T12:00:00Z", "", now)
+	require.NoError(suite.T(), err)
+	assert.Nil(suite.T(), runAfter, "a time already past runs straight away")
+
+	runAfter, err = scheduler.ResolveRunAfter("", "0 1 * * *", now)
+	require.NoError(suite.T(), err)
+	require.NotNil(suite.T(), runAfter)
+	assert.Equal(suite.T(), time.Date(2024, 3, 2, 1, 0, 0, 0, time.UTC), *runAfter)
+
+	_, err = scheduler.ResolveRunAfter("tonight", "", now)
+	assert.Error(suite.T(), err)
+	_, err = scheduler.ResolveRunAfter("", "0 25 * * *", now)
+	assert.Error(suite.T(), err)
+	_, err = scheduler.ResolveRunAfter("2024-03-01T23:00:00Z", "0 1 * * *", now)
+	assert.ErrorIs(suite.T(), err, scheduler.ErrConflictingSchedule)
+}
+
+// Test scheduled jobs are enqueued once, when their time comes
+func (suite *SchedulerTestSuite) TestReleaseJobs() {
+	now := suite.clock.Now()
+	soon := now.Add(time.Hour)
+	later := now.Add(3 * time.Hour)
+	past := now.Add(-time.Minute)
+	suite.createJob("job-soon", models.StatusPending, &soon)
+	suite.createJob("job-later", models.StatusPending, &later)
+	suite.createJob("job-past", models.StatusPending, &past)
+	suite.createJob("job-now", models.StatusPending, nil)
+	suite.createJob("job-uploaded", models.StatusUploaded, &past)
+
+	suite.scheduler.RunDue()
+	assert.Equal(suite.T(), []string{"job-past"}, suite.enqueued, "overdue jobs are released at once")
+
+	jobs, err := suite.scheduler.ScheduledJobs()
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), jobs, 2)
+	assert.Equal(suite.T(), "job-soon", jobs[0].ID)
+	assert.Equal(suite.T(), "job-later", jobs[1].ID)
+
+	suite.clock.Advance(90 * time.Minute)
+	suite.scheduler.RunDue()
+	assert.Equal(suite.T(), []string{"job-past", "job-soon"}, suite.enqueued)
+
+	suite.clock.Advance(2 * time.Hour)
+	suite.scheduler.RunDue()
+	suite.scheduler.RunDue()
+	assert.Equal(suite.T(), []string{"job-past", "job-soon", "job-later"}, suite.enqueued)
+}
+
+// Test recurring tasks run on their schedule and report their last run
+func (suite *SchedulerTestSuite) TestRecurringTasks() {
+	var mu sync.Mutex
+	runs := 0
+	require.NoError(suite.T(), suite.scheduler.Add("sweep", "0 2 * * *", func() error {
+		mu.Lock()
+		defer mu.Unlock()
+		runs++
+		if runs == 2 {
+			return errors.New("root is offline")
+		}
+		return nil
+	}))
+	assert.Error(suite.T(), suite.scheduler.Add("sweep", "@hourly", func() error { return nil }), "names are unique")
+	assert.Error(suite.T(), suite.scheduler.Add("broken", "every night", func() error { return nil }))
+
+	tasks := suite.scheduler.Tasks()
+	require.Len(suite.T(), tasks, 1)
+	require.NotNil(suite.T(), tasks[0].NextRun)
+	assert.Equal(suite.T(), time.Date(2024, 3, 2, 2, 0, 0, 0, time.UTC), *tasks[0].NextRun)
+	assert.Nil(suite.T(), tasks[0].LastRun)
+
+	suite.scheduler.RunDue()
+	suite.scheduler.Wait()
+	assert.Equal(suite.T(), 0, runs)
+
+	suite.clock.Set(time.Date(2024, 3, 2, 2, 0, 30, 0, time.UTC))
+	suite.scheduler.RunDue()
+	suite.scheduler.Wait()
+	suite.scheduler.RunDue()
+	suite.scheduler.Wait()
+	assert.Equal(suite.T(), 1, runs, "a task runs once per match")
+
+	suite.clock.Set(time.Date(2024, 3, 3, 2, 1, 0, 0, time.UTC))
+	suite.scheduler.RunDue()
+	suite.scheduler.Wait()
+	assert.Equal(suite.T(), 2, runs)
+	tasks = suite.scheduler.Tasks()
+	require.NotNil(suite.T(), tasks[0].LastRun)
+	assert.Equal(suite.T(), "root is offline", tasks[0].LastError)
+	assert.Equal(suite.T(), time.Date(2024, 3, 4, 2, 0, 0, 0, time.UTC), *tasks[0].NextRun)
+}
+
+func TestSchedulerTestSuite(t *testing.T) {
+	suite.Run(t, new(SchedulerTestSuite))
+}
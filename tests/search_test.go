@@ -0,0 +1,152 @@
+package tests
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"synthezia/internal/api"
+	"synthezia/internal/models"
+	"synthezia/internal/search"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+type SearchTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	service *search.Service
+	router  *gin.Engine
+}
+
+func (suite *SearchTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "search_test.db")
+	suite.service = search.NewService(suite.helper.DB)
+	handler := api.NewHandler(suite.helper.Config, suite.helper.AuthService, nil, nil, nil, nil)
+	suite.router = api.SetupRoutes(handler, suite.helper.AuthService)
+}
+
+func (suite *SearchTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// completedJob creates a completed job with the given transcript
+func (suite *SearchTestSuite) completedJob(title, transcript string) *models.TranscriptionJob {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), title)
+	suite.Require().NoError(suite.helper.DB.Model(job).Updates(map[string]any{
+		"status":     models.StatusCompleted,
+		"transcript": transcript,
+	}).Error)
+	return job
+}
+
+const planningTranscript = `{"text":"...","segments":[
+	{"start":12.5,"end":15,"text":"Next we should look at the budget for the spring campaign.","speaker":"SPEAKER_01"},
+	{"start":0,"end":4,"text":"Good morning everyone.","speaker":"SPEAKER_00"},
+	{"start":30,"end":34,"text":"The budgets were approved last week.","speaker":"SPEAKER_00"}
+]}`
+
+func (suite *SearchTestSuite) TestSearch() {
+	planning := suite.completedJob("Planning", planningTranscript)
+	standup := suite.completedJob("Standup", `{"segments":[{"start":1,"end":2,"text":"No budget talk today, only the release."}]}`)
+	suite.completedJob("Retro", `{"segments":[{"start":1,"end":2,"text":"What went well this sprint?"}]}`)
+	suite.Require().NoError(suite.helper.DB.Create(&models.SpeakerMapping{TranscriptionJobID: planning.ID, OriginalSpeaker: "SPEAKER_01", CustomName: "Alice"}).Error)
+	indexed, err := suite.service.Backfill()
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 3, indexed)
+
+	results, err := suite.service.Search("where did we discuss the budget", 10, 0)
+	suite.Require().NoError(err)
+	suite.Require().Equal(2, results.Total)
+	assert.Equal(suite.T(), planning.ID, results.Jobs[0].JobID, "two segments mention the budget")
+	assert.Equal(suite.T(), "Planning", *results.Jobs[0].Title)
+	assert.Equal(suite.T(), 2, results.Jobs[0].TotalMatches)
+	assert.Equal(suite.T(), standup.ID, results.Jobs[1].JobID)
+
+	var spring *search.Match
+	for i, m := range results.Jobs[0].Matches {
+		if m.Start == 12.5 {
+			spring = &results.Jobs[0].Matches[i]
+		}
+	}
+	suite.Require().NotNil(spring)
+	assert.Equal(suite.T(), 1, spring.Segment, "segments are numbered in order of start time")
+	assert.Equal(suite.T(), 15.0, spring.End)
+	assert.Equal(suite.T(), "Alice", *spring.Speaker)
+	assert.Contains(suite.T(), spring.Snippet, "the <mark>budget</mark> for")
+
+	page, err := suite.service.Search("budget", 1, 1)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 2, page.Total)
+	suite.Require().Len(page.Jobs, 1)
+	assert.Equal(suite.T(), standup.ID, page.Jobs[0].JobID)
+
+	phrase, err := suite.service.Search(`"spring campaign" OR) release:`, 10, 0)
+	suite.Require().NoError(err, "punctuation is not query syntax")
+	assert.Equal(suite.T(), 2, phrase.Total)
+
+	prefix, err := suite.service.Search("spri*", 10, 0)
+	suite.Require().NoError(err)
+	assert.Equal(suite.T(), 2, prefix.Total, "spring and sprint")
+}
+
+func (suite *SearchTestSuite) TestIndexUpdates() {
+	job := suite.completedJob("Planning", planningTranscript)
+	suite.Require().NoError(suite.service.Index(job.ID))
+
+	suite.Require().NoError(suite.helper.DB.Model(job).Update("transcript", `{"segments":[{"start":0,"end":1,"text":"Only the roadmap now."}]}`).Error)
+	suite.Require().NoError(suite.service.Index(job.ID))
+	results, _ := suite.service.Search("budget", 10, 0)
+	assert.Zero(suite.T(), results.Total, "the old transcript is replaced")
+	results, _ = suite.service.Search("roadmap", 10, 0)
+	assert.Equal(suite.T(), 1, results.Total)
+
+	suite.Require().NoError(suite.helper.DB.Model(job).Update("encrypted", true).Error)
+	suite.Require().NoError(suite.service.Index(job.ID))
+	results, _ = suite.service.Search("roadmap", 10, 0)
+	assert.Zero(suite.T(), results.Total, "encrypted transcripts are not indexed")
+
+	other := suite.completedJob("Other", planningTranscript)
+	suite.Require().NoError(suite.service.Index(other.ID))
+	suite.Require().NoError(suite.helper.DB.Delete(other).Error)
+	results, _ = suite.service.Search("budget", 10, 0)
+	assert.Zero(suite.T(), results.Total, "deleted jobs are not returned")
+}
+
+func (suite *SearchTestSuite) TestEndpoint() {
+	job := suite.completedJob("Planning", planningTranscript)
+	suite.Require().NoError(search.Default.Index(job.ID))
+
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/search?q=budget", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w := httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	suite.Require().Equal(http.StatusOK, w.Code)
+	var results search.Results
+	suite.Require().NoError(json.Unmarshal(w.Body.Bytes(), &results))
+	assert.Equal(suite.T(), "budget", results.Query)
+	suite.Require().Len(results.Jobs, 1)
+	assert.Len(suite.T(), results.Jobs[0].Matches, 2)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/v1/search", nil)
+	req.Header.Set("X-API-Key", suite.helper.TestAPIKey)
+	w = httptest.NewRecorder()
+	suite.router.ServeHTTP(w, req)
+	assert.Equal(suite.T(), http.StatusBadRequest, w.Code)
+}
+
+func (suite *SearchTestSuite) TestMatchQuery() {
+	assert.Equal(suite.T(), `"budget" OR "q3"*`, search.MatchQuery("budget q3*"))
+	assert.Equal(suite.T(), `"spring campaign" OR "NEAR"`, search.MatchQuery(`"spring   campaign" NEAR`))
+	assert.Equal(suite.T(), `"discuss" OR "budget?"`, search.MatchQuery("Where did we discuss the budget?"))
+	assert.Equal(suite.T(), `"the" OR "who"`, search.MatchQuery("the who"), "a search of only common words keeps them")
+	assert.Equal(suite.T(), `"it's"`, search.MatchQuery(`it's "`))
+	assert.Empty(suite.T(), search.MatchQuery(` * "" `))
+}
+
+func TestSearchTestSuite(t *testing.T) {
+	suite.Run(t, new(SearchTestSuite))
+}
@@ -0,0 +1,237 @@
+package tests
+
+import (
+	"bytes"
+	"encoding/binary"
+	"io"
+	"net"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"synthezia/internal/dropzone"
+	"synthezia/internal/models"
+	"synthezia/pkg/fsys"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+	"golang.org/x/crypto/ssh"
+)
+
+// sftpClient speaks just enough SFTP v3 to upload files
+type sftpClient struct {
+	t      *testing.T
+	conn   *ssh.Client
+	stdin  io.WriteCloser
+	stdout io.Reader
+	nextID uint32
+}
+
+func (c *sftpClient) send(packetType byte, fields ...any) {
+	var payload bytes.Buffer
+	payload.WriteByte(packetType)
+	for _, field := range fields {
+		switch v := field.(type) {
+		case uint32:
+			binary.Write(&payload, binary.BigEndian, v)
+		case uint64:
+			binary.Write(&payload, binary.BigEndian, v)
+		case string:
+			binary.Write(&payload, binary.BigEndian, uint32(len(v)))
+			payload.WriteString(v)
+		}
+	}
+	packet := binary.BigEndian.AppendUint32(nil, uint32(payload.Len()))
+	_, err := c.stdin.Write(append(packet, payload.Bytes()...))
+	require.NoError(c.t, err)
+}
+
+func (c *sftpClient) receive() (byte, []byte) {
+	var length uint32
+	require.NoError(c.t, binary.Read(c.stdout, binary.BigEndian, &length))
+	packet := make([]byte, length)
+	_, err := io.ReadFull(c.stdout, packet)
+	require.NoError(c.t, err)
+	return packet[0], packet[1:]
+}
+
+// request sends a request and returns the reply type and payload after the ID
+func (c *sftpClient) request(packetType byte, fields ...any) (byte, []byte) {
+	c.nextID++
+	c.send(packetType, append([]any{c.nextID}, fields...)...)
+	replyType, reply := c.receive()
+	require.Equal(c.t, c.nextID, binary.BigEndian.Uint32(reply))
+	return replyType, reply[4:]
+}
+
+// status returns the code and message of a status reply
+func (c *sftpClient) status(replyType byte, reply []byte) (uint32, string) {
+	require.Equal(c.t, byte(101), replyType, "expected a status reply")
+	code := binary.BigEndian.Uint32(reply)
+	length := binary.BigEndian.Uint32(reply[4:])
+	return code, string(reply[8 : 8+length])
+}
+
+func (c *sftpClient) open(path string, flags uint32) string {
+	replyType, reply := c.request(3, path, flags, uint32(0))
+	require.Equal(c.t, byte(102), replyType, "expected a handle")
+	length := binary.BigEndian.Uint32(reply)
+	return string(reply[4 : 4+length])
+}
+
+// upload writes content to path in chunks and returns the status of the close
+func (c *sftpClient) upload(path, content string) (uint32, string) {
+	handle := c.open(path, 0x02|0x08|0x10)
+	for offset := 0; offset < len(content); offset += 4 {
+		end := min(offset+4, len(content))
+		code, message := c.status(c.request(6, handle, uint64(offset), content[offset:end]))
+		require.Equal(c.t, uint32(0), code, message)
+	}
+	return c.status(c.request(4, handle))
+}
+
+type SFTPTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	fs      *fsys.MemFS
+	service *dropzone.Service
+	server  *dropzone.SFTPServer
+	addr    string
+	hostKey ssh.PublicKey
+}
+
+func (suite *SFTPTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "sftp_test.db")
+	suite.helper.Config.UploadDir = "uploads"
+	suite.fs = fsys.NewMemFS()
+	suite.service = dropzone.NewService(suite.helper.Config, nil)
+	suite.service.SetFS(suite.fs)
+
+	keyPath := filepath.Join(suite.T().TempDir(), "host_key")
+	hostKey, err := dropzone.LoadHostKey(keyPath)
+	require.NoError(suite.T(), err)
+	suite.hostKey = hostKey.PublicKey()
+	reloaded, err := dropzone.LoadHostKey(keyPath)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), suite.hostKey.Marshal(), reloaded.PublicKey().Marshal())
+
+	suite.server, err = dropzone.NewSFTPServer(suite.service, hostKey, "auto_transcribe=false")
+	require.NoError(suite.T(), err)
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(suite.T(), err)
+	suite.addr = listener.Addr().String()
+	go suite.server.Serve(listener)
+}
+
+func (suite *SFTPTestSuite) TearDownTest() {
+	suite.server.Close()
+	suite.helper.Cleanup()
+}
+
+func (suite *SFTPTestSuite) dial(password string) (*ssh.Client, error) {
+	return ssh.Dial("tcp", suite.addr, &ssh.ClientConfig{
+		User:            "testuser",
+		Auth:            []ssh.AuthMethod{ssh.Password(password)},
+		HostKeyCallback: ssh.FixedHostKey(suite.hostKey),
+		Timeout:         5 * time.Second,
+	})
+}
+
+func (suite *SFTPTestSuite) connect() *sftpClient {
+	conn, err := suite.dial("testpassword123")
+	require.NoError(suite.T(), err)
+	session, err := conn.NewSession()
+	require.NoError(suite.T(), err)
+	stdin, err := session.StdinPipe()
+	require.NoError(suite.T(), err)
+	stdout, err := session.StdoutPipe()
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), session.RequestSubsystem("sftp"))
+
+	client := &sftpClient{t: suite.T(), conn: conn, stdin: stdin, stdout: stdout}
+	client.send(1, uint32(3))
+	replyType, reply := client.receive()
+	require.Equal(suite.T(), byte(2), replyType)
+	require.Equal(suite.T(), uint32(3), binary.BigEndian.Uint32(reply))
+	return client
+}
+
+// stagedUploads lists upload files left in the staging directory
+func (suite *SFTPTestSuite) stagedUploads() []string {
+	entries, _ := suite.fs.ReadDir("uploads")
+	var staged []string
+	for _, entry := range entries {
+		if strings.HasSuffix(entry.Name(), ".part") {
+			staged = append(staged, entry.Name())
+		}
+	}
+	return staged
+}
+
+// Test a closed upload becomes a job of the user who logged in
+func (suite *SFTPTestSuite) TestUploadCreatesJob() {
+	client := suite.connect()
+	defer client.conn.Close()
+
+	code, message := client.upload("/recordings/take1.wav", "field recording one")
+	require.Equal(suite.T(), uint32(0), code, message)
+
+	var job models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("title = ?", "take1.wav").First(&job).Error)
+	assert.Equal(suite.T(), models.StatusUploaded, job.Status)
+	audio, err := fsys.ReadFile(suite.fs, job.AudioPath)
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), "field recording one", string(audio))
+	assert.Empty(suite.T(), suite.stagedUploads())
+}
+
+// Test clients that upload to a temporary name and rename it afterwards
+func (suite *SFTPTestSuite) TestUploadRenamedIntoPlace() {
+	client := suite.connect()
+	defer client.conn.Close()
+
+	code, _ := client.upload("take2.wav.filepart", "field recording two")
+	require.Equal(suite.T(), uint32(0), code)
+	var count int64
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "take2.wav").Count(&count)
+	assert.Zero(suite.T(), count)
+
+	code, message := client.status(client.request(18, "take2.wav.filepart", "take2.wav"))
+	require.Equal(suite.T(), uint32(0), code, message)
+	suite.helper.DB.Model(&models.TranscriptionJob{}).Where("title = ?", "take2.wav").Count(&count)
+	assert.Equal(suite.T(), int64(1), count)
+}
+
+// Test wrong passwords, reads and invalid files are refused
+func (suite *SFTPTestSuite) TestRefusals() {
+	_, err := suite.dial("wrong password")
+	assert.Error(suite.T(), err)
+
+	client := suite.connect()
+	defer client.conn.Close()
+
+	code, _ := client.status(client.request(3, "/take1.wav", uint32(0x01), uint32(0)))
+	assert.Equal(suite.T(), uint32(3), code, "reads are denied")
+
+	suite.helper.Config.DropzoneQuarantine = true
+	defer func() { suite.helper.Config.DropzoneQuarantine = false }()
+	code, message := client.upload("/empty.wav", "")
+	assert.Equal(suite.T(), uint32(4), code)
+	assert.Contains(suite.T(), message, "file is empty")
+}
+
+// Test an upload the client abandons is discarded with its session
+func (suite *SFTPTestSuite) TestAbandonedUploadDiscarded() {
+	client := suite.connect()
+	client.open("/take3.wav", 0x02|0x08)
+	assert.Len(suite.T(), suite.stagedUploads(), 1)
+	client.conn.Close()
+
+	assert.Eventually(suite.T(), func() bool { return len(suite.stagedUploads()) == 0 }, 5*time.Second, 10*time.Millisecond)
+}
+
+func TestSFTPTestSuite(t *testing.T) {
+	suite.Run(t, new(SFTPTestSuite))
+}
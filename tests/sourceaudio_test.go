@@ -0,0 +1,112 @@
+package tests
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/internal/sourceaudio"
+	"synthezia/pkg/clock"
+	"synthezia/pkg/fsys"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type SourceAudioTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	fs      *fsys.MemFS
+	clock   *clock.Fake
+	service *sourceaudio.Service
+}
+
+func (suite *SourceAudioTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "sourceaudio_test.db")
+	suite.fs = fsys.NewMemFS()
+	require.NoError(suite.T(), suite.fs.MkdirAll("uploads", 0755))
+	suite.clock = clock.NewFake(time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	suite.service = sourceaudio.NewService(suite.helper.DB)
+	suite.service.SetFS(suite.fs)
+	suite.service.SetClock(suite.clock)
+}
+
+func (suite *SourceAudioTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// completedJob creates a completed job whose audio exists in the fake filesystem
+func (suite *SourceAudioTestSuite) completedJob(action *string) *models.TranscriptionJob {
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Source Audio")
+	job.AudioPath = "uploads/" + job.ID + ".mp3"
+	_, err := fsys.WriteFileAtomic(suite.fs, "", job.AudioPath, strings.NewReader("audio-bytes"))
+	require.NoError(suite.T(), err)
+	require.NoError(suite.T(), suite.helper.DB.Model(job).Updates(map[string]interface{}{
+		"audio_path":          job.AudioPath,
+		"status":              models.StatusCompleted,
+		"source_audio_action": action,
+	}).Error)
+	return job
+}
+
+// Test a job set to delete loses its audio and records the removal
+func (suite *SourceAudioTestSuite) TestDeleteAfterCompletion() {
+	stop := jobstate.RecordEvents()
+	defer stop()
+	action := models.SourceAudioDelete
+	job := suite.completedJob(&action)
+
+	require.NoError(suite.T(), suite.service.Apply(context.Background(), job.ID))
+	assert.False(suite.T(), fsys.Exists(suite.fs, job.AudioPath))
+
+	var stored models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", job.ID).First(&stored).Error)
+	require.NotNil(suite.T(), stored.SourceAudioRemovedAt)
+	assert.True(suite.T(), stored.SourceAudioRemovedAt.Equal(suite.clock.Now()))
+
+	events, err := jobstate.Timeline(job.ID)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), events, 1)
+	assert.Equal(suite.T(), sourceaudio.EventDeleted, events[0].Event)
+	require.NotNil(suite.T(), events[0].Attrs)
+	assert.JSONEq(suite.T(), `{"bytes_freed":"11"}`, *events[0].Attrs)
+
+	// A second pass is a no-op
+	require.NoError(suite.T(), suite.service.Apply(context.Background(), job.ID))
+	events, err = jobstate.Timeline(job.ID)
+	require.NoError(suite.T(), err)
+	assert.Len(suite.T(), events, 1)
+}
+
+// Test the server default applies only to jobs that do not choose
+func (suite *SourceAudioTestSuite) TestDefaultAction() {
+	require.Error(suite.T(), suite.service.SetDefaultAction("shred"))
+	require.NoError(suite.T(), suite.service.SetDefaultAction(models.SourceAudioDelete))
+
+	keep := models.SourceAudioKeep
+	kept := suite.completedJob(&keep)
+	defaulted := suite.completedJob(nil)
+	require.NoError(suite.T(), suite.service.Apply(context.Background(), kept.ID))
+	require.NoError(suite.T(), suite.service.Apply(context.Background(), defaulted.ID))
+
+	assert.True(suite.T(), fsys.Exists(suite.fs, kept.AudioPath))
+	assert.False(suite.T(), fsys.Exists(suite.fs, defaulted.AudioPath))
+}
+
+// Test audio is left alone until the job completes
+func (suite *SourceAudioTestSuite) TestIgnoresIncompleteJobs() {
+	action := models.SourceAudioDelete
+	job := suite.completedJob(&action)
+	require.NoError(suite.T(), suite.helper.DB.Model(job).Update("status", models.StatusProcessing).Error)
+
+	require.NoError(suite.T(), suite.service.Apply(context.Background(), job.ID))
+	assert.True(suite.T(), fsys.Exists(suite.fs, job.AudioPath))
+}
+
+func TestSourceAudioTestSuite(t *testing.T) {
+	suite.Run(t, new(SourceAudioTestSuite))
+}
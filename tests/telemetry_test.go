@@ -0,0 +1,165 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"synthezia/internal/telemetry"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// exportedSpan is the subset of the OTLP JSON span the tests inspect
+type exportedSpan struct {
+	TraceID      string `json:"traceId"`
+	SpanID       string `json:"spanId"`
+	ParentSpanID string `json:"parentSpanId"`
+	Name         string `json:"name"`
+	Kind         int    `json:"kind"`
+	Status       struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"status"`
+}
+
+type TelemetryTestSuite struct {
+	suite.Suite
+	collector *httptest.Server
+	mu        sync.Mutex
+	spans     []exportedSpan
+	paths     []string
+}
+
+func (suite *TelemetryTestSuite) SetupTest() {
+	suite.spans = nil
+	suite.paths = nil
+	suite.collector = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		var payload struct {
+			ResourceSpans []struct {
+				ScopeSpans []struct {
+					Spans []exportedSpan `json:"spans"`
+				} `json:"scopeSpans"`
+			} `json:"resourceSpans"`
+		}
+		json.Unmarshal(body, &payload)
+
+		suite.mu.Lock()
+		defer suite.mu.Unlock()
+		suite.paths = append(suite.paths, r.URL.Path)
+		for _, rs := range payload.ResourceSpans {
+			for _, ss := range rs.ScopeSpans {
+				suite.spans = append(suite.spans, ss.Spans...)
+			}
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	err := telemetry.Init(telemetry.Config{Endpoint: suite.collector.URL, ServiceName: "synthezia-test"})
+	assert.NoError(suite.T(), err)
+}
+
+func (suite *TelemetryTestSuite) TearDownTest() {
+	telemetry.Shutdown(context.Background())
+	suite.collector.Close()
+}
+
+// flush shuts tracing down so every finished span is exported
+func (suite *TelemetryTestSuite) flush() []exportedSpan {
+	assert.NoError(suite.T(), telemetry.Shutdown(context.Background()))
+	suite.mu.Lock()
+	defer suite.mu.Unlock()
+	return suite.spans
+}
+
+// Test tracing is a no-op without an endpoint
+func (suite *TelemetryTestSuite) TestDisabled() {
+	telemetry.Shutdown(context.Background())
+	assert.False(suite.T(), telemetry.Enabled())
+
+	ctx, span := telemetry.Start(context.Background(), "noop", telemetry.SpanKindInternal)
+	assert.Nil(suite.T(), span)
+	assert.Nil(suite.T(), telemetry.SpanFromContext(ctx))
+	span.SetAttributes(telemetry.String("k", "v"))
+	span.RecordError(errors.New("ignored"))
+	span.End()
+	assert.Empty(suite.T(), telemetry.LogAttrs(ctx))
+}
+
+// Test child spans share the trace and are exported over OTLP/HTTP
+func (suite *TelemetryTestSuite) TestParentChildExport() {
+	ctx, parent := telemetry.Start(context.Background(), "queue.process_job", telemetry.SpanKindConsumer,
+		telemetry.String("job.id", "job-1"))
+	_, child := telemetry.Start(ctx, "whisperx.transcribe", telemetry.SpanKindInternal)
+	child.RecordError(errors.New("CUDA out of memory"))
+	child.End()
+	parent.End()
+
+	logAttrs := telemetry.LogAttrs(ctx)
+	assert.Equal(suite.T(), []any{"trace_id", parent.TraceID(), "span_id", parent.SpanID()}, logAttrs)
+
+	spans := suite.flush()
+	assert.Equal(suite.T(), []string{"/v1/traces"}, suite.paths)
+	if assert.Len(suite.T(), spans, 2) {
+		assert.Equal(suite.T(), "whisperx.transcribe", spans[0].Name)
+		assert.Equal(suite.T(), parent.TraceID(), spans[0].TraceID)
+		assert.Equal(suite.T(), parent.SpanID(), spans[0].ParentSpanID)
+		assert.Equal(suite.T(), 2, spans[0].Status.Code)
+		assert.Equal(suite.T(), "CUDA out of memory", spans[0].Status.Message)
+
+		assert.Equal(suite.T(), "queue.process_job", spans[1].Name)
+		assert.Empty(suite.T(), spans[1].ParentSpanID)
+		assert.Equal(suite.T(), int(telemetry.SpanKindConsumer), spans[1].Kind)
+	}
+}
+
+// Test the HTTP middleware continues an incoming W3C trace
+func (suite *TelemetryTestSuite) TestGinMiddlewarePropagation() {
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.Use(telemetry.GinMiddleware())
+	router.GET("/api/v1/items/:id", func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	traceID := "4bf92f3577b34da6a3ce929d0e0e4736"
+	req := httptest.NewRequest(http.MethodGet, "/api/v1/items/42", nil)
+	req.Header.Set("traceparent", "00-"+traceID+"-00f067aa0ba902b7-01")
+	w := httptest.NewRecorder()
+	router.ServeHTTP(w, req)
+
+	assert.Equal(suite.T(), traceID, w.Header().Get(telemetry.TraceIDHeader))
+
+	spans := suite.flush()
+	if assert.Len(suite.T(), spans, 1) {
+		assert.Equal(suite.T(), "GET /api/v1/items/:id", spans[0].Name)
+		assert.Equal(suite.T(), traceID, spans[0].TraceID)
+		assert.Equal(suite.T(), "00f067aa0ba902b7", spans[0].ParentSpanID)
+		assert.Equal(suite.T(), int(telemetry.SpanKindServer), spans[0].Kind)
+	}
+}
+
+// Test malformed traceparent headers start a new trace
+func (suite *TelemetryTestSuite) TestInvalidTraceparentIgnored() {
+	ctx := telemetry.ContextWithTraceparent(context.Background(), "00-zz-00f067aa0ba902b7-01")
+	_, span := telemetry.Start(ctx, "root", telemetry.SpanKindInternal)
+	span.End()
+
+	spans := suite.flush()
+	if assert.Len(suite.T(), spans, 1) {
+		assert.Empty(suite.T(), spans[0].ParentSpanID)
+		assert.Len(suite.T(), spans[0].TraceID, 32)
+	}
+}
+
+func TestTelemetryTestSuite(t *testing.T) {
+	suite.Run(t, new(TelemetryTestSuite))
+}
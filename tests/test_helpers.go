@@ -1,7 +1,12 @@
 package tests
 
 import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
 	"os"
+	"os/exec"
 	"strings"
 	"testing"
 
@@ -235,3 +240,45 @@ func floatPtr(f float64) *float64 {
 func boolPtr(b bool) *bool {
 	return &b
 }
+
+// id3TextFrame encodes an ID3v2 text frame holding value
+func id3TextFrame(id, value string) []byte {
+	body := append([]byte{3}, []byte(value)...) // UTF-8
+	frame := []byte(id)
+	frame = binary.BigEndian.AppendUint32(frame, uint32(len(body)))
+	frame = append(frame, 0, 0)
+	return append(frame, body...)
+}
+
+// id3Tag encodes an ID3v2 tag of the given major version holding frames
+func id3Tag(version byte, frames ...[]byte) []byte {
+	data := bytes.Join(frames, nil)
+	size := len(data)
+	header := []byte{'I', 'D', '3', version, 0, 0,
+		byte(size >> 21 & 0x7f), byte(size >> 14 & 0x7f), byte(size >> 7 & 0x7f), byte(size & 0x7f)}
+	return append(header, data...)
+}
+
+// scriptedProcessor fails jobs named fail*, holds jobs named hold* until
+// release is closed and completes the rest
+type scriptedProcessor struct {
+	release chan struct{}
+}
+
+func (p *scriptedProcessor) ProcessJob(ctx context.Context, jobID string) error {
+	return p.ProcessJobWithProcess(ctx, jobID, nil)
+}
+
+func (p *scriptedProcessor) ProcessJobWithProcess(ctx context.Context, jobID string, registerProcess func(*exec.Cmd)) error {
+	switch {
+	case strings.HasPrefix(jobID, "fail"):
+		return errors.New("model crashed")
+	case strings.HasPrefix(jobID, "hold"):
+		select {
+		case <-p.release:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+	return nil
+}
@@ -0,0 +1,242 @@
+package tests
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/jobstate"
+	"synthezia/internal/llm"
+	"synthezia/internal/models"
+	"synthezia/internal/transcription/interfaces"
+	"synthezia/internal/translation"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeTranslator "translates" numbered lines by tagging them with the target
+// language, and drops the last line of prompts containing "DROP"
+type fakeTranslator struct {
+	mu      sync.Mutex
+	prompts []string
+}
+
+var fakeTargetLanguage = regexp.MustCompile(`code "([a-z]+)"`)
+
+func (f *fakeTranslator) GetModels(ctx context.Context) ([]string, error) {
+	return []string{"mt-model"}, nil
+}
+
+func (f *fakeTranslator) ChatCompletion(ctx context.Context, model string, messages []llm.ChatMessage, temperature float64) (*llm.ChatResponse, error) {
+	prompt := messages[0].Content
+	f.mu.Lock()
+	f.prompts = append(f.prompts, prompt)
+	f.mu.Unlock()
+	if strings.Contains(prompt, "FAIL") {
+		return nil, errors.New("model overloaded")
+	}
+
+	language := fakeTargetLanguage.FindStringSubmatch(prompt)[1]
+	_, lines, _ := strings.Cut(prompt, "\n\n")
+	var reply []string
+	for _, line := range strings.Split(strings.TrimSpace(lines), "\n") {
+		n, text, _ := strings.Cut(line, ": ")
+		reply = append(reply, fmt.Sprintf("%s: [%s] %s", n, language, text))
+	}
+	if strings.Contains(prompt, "DROP") {
+		reply = reply[:len(reply)-1]
+	}
+
+	resp := &llm.ChatResponse{}
+	resp.Choices = make([]struct {
+		Index   int `json:"index"`
+		Message struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"message"`
+		FinishReason string `json:"finish_reason"`
+	}, 1)
+	resp.Choices[0].Message.Content = "Here is the translation:\n" + strings.Join(reply, "\n")
+	return resp, nil
+}
+
+func (f *fakeTranslator) ChatCompletionStream(ctx context.Context, model string, messages []llm.ChatMessage, temperature float64) (<-chan string, <-chan error) {
+	return nil, nil
+}
+
+type TranslationTestSuite struct {
+	suite.Suite
+	helper      *TestHelper
+	fake        *fakeTranslator
+	transcribed []models.WhisperXParams
+	service     *translation.Service
+}
+
+func (suite *TranslationTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "translation_test.db")
+	suite.fake = &fakeTranslator{}
+	suite.transcribed = nil
+	suite.service = translation.NewService(suite.helper.DB, func() (llm.Service, error) {
+		return suite.fake, nil
+	}, func(ctx context.Context, audioPath string, params models.WhisperXParams) (*interfaces.TranscriptResult, error) {
+		suite.transcribed = append(suite.transcribed, params)
+		return &interfaces.TranscriptResult{
+			Text:     "Hello.",
+			Language: "fr",
+			Segments: []interfaces.TranscriptSegment{{Start: 0, End: 1, Text: "Hello."}},
+		}, nil
+	})
+	require.NoError(suite.T(), suite.helper.DB.Create(&models.SummarySetting{DefaultModel: "mt-model"}).Error)
+}
+
+func (suite *TranslationTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *TranslationTestSuite) createTranscribed(id string, segments ...string) *models.TranscriptionJob {
+	result := interfaces.TranscriptResult{Language: "fr", Text: strings.Join(segments, " ")}
+	speaker := "SPEAKER_00"
+	for i, text := range segments {
+		result.Segments = append(result.Segments, interfaces.TranscriptSegment{Start: float64(i), End: float64(i) + 0.9, Text: " " + text, Speaker: &speaker})
+		result.WordSegments = append(result.WordSegments, interfaces.TranscriptWord{Start: float64(i), End: float64(i) + 0.5, Word: text})
+	}
+	data, err := json.Marshal(result)
+	require.NoError(suite.T(), err)
+	transcript := string(data)
+	job := &models.TranscriptionJob{ID: id, AudioPath: id + ".mp3", Status: models.StatusCompleted, Transcript: &transcript}
+	require.NoError(suite.T(), suite.helper.DB.Create(job).Error)
+	return job
+}
+
+func (suite *TranslationTestSuite) translated(id string) (models.Translation, *interfaces.TranscriptResult) {
+	suite.service.Wait(id)
+	var t models.Translation
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", id).First(&t).Error)
+	if t.Transcript == nil {
+		return t, nil
+	}
+	var result interfaces.TranscriptResult
+	require.NoError(suite.T(), json.Unmarshal([]byte(*t.Transcript), &result))
+	return t, &result
+}
+
+// Test each language is translated segment by segment into its own
+// translation, keeping timing and speakers and leaving the original alone
+func (suite *TranslationTestSuite) TestTranslateWithLLM() {
+	job := suite.createTranscribed("job-llm", "Bonjour.", "Merci.")
+	original := *job.Transcript
+
+	translations, err := suite.service.Start(job.ID, translation.Request{Languages: []string{"en", "de"}})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), translations, 2)
+
+	t, result := suite.translated(translations[1].ID)
+	assert.Equal(suite.T(), models.TranslationCompleted, t.Status)
+	assert.Equal(suite.T(), models.TranslationProviderLLM, t.Provider)
+	assert.Equal(suite.T(), "mt-model", t.Model)
+	assert.NotNil(suite.T(), t.CompletedAt)
+	require.NotNil(suite.T(), result)
+	assert.Equal(suite.T(), "de", result.Language)
+	assert.Equal(suite.T(), "[de] Bonjour. [de] Merci.", result.Text)
+	require.Len(suite.T(), result.Segments, 2)
+	assert.Equal(suite.T(), "[de] Merci.", result.Segments[1].Text)
+	assert.Equal(suite.T(), 1.0, result.Segments[1].Start)
+	assert.Equal(suite.T(), "SPEAKER_00", *result.Segments[1].Speaker)
+	assert.Empty(suite.T(), result.WordSegments, "word timings do not carry over")
+
+	suite.service.Wait(translations[0].ID)
+	var reloaded models.TranscriptionJob
+	require.NoError(suite.T(), suite.helper.DB.Where("id = ?", job.ID).First(&reloaded).Error)
+	assert.Equal(suite.T(), original, *reloaded.Transcript)
+
+	// Translating again replaces the earlier translation
+	again, err := suite.service.Start(job.ID, translation.Request{Languages: []string{"de"}, Model: "other-model"})
+	require.NoError(suite.T(), err)
+	suite.service.Wait(again[0].ID)
+	var count int64
+	suite.helper.DB.Model(&models.Translation{}).Where("transcription_id = ?", job.ID).Count(&count)
+	assert.Equal(suite.T(), int64(2), count)
+	t, _ = suite.translated(again[0].ID)
+	assert.Equal(suite.T(), "other-model", t.Model)
+}
+
+// Test a reply missing lines fails the translation instead of misaligning it
+func (suite *TranslationTestSuite) TestIncompleteReplyFails() {
+	job := suite.createTranscribed("job-drop", "Un.", "DROP deux.")
+
+	translations, err := suite.service.Start(job.ID, translation.Request{Languages: []string{"en"}})
+	require.NoError(suite.T(), err)
+	t, result := suite.translated(translations[0].ID)
+	assert.Equal(suite.T(), models.TranslationFailed, t.Status)
+	require.NotNil(suite.T(), t.Error)
+	assert.Contains(suite.T(), *t.Error, "left out line 2")
+	assert.Nil(suite.T(), result)
+}
+
+// Test the whisper provider transcribes the audio again with the translate task
+func (suite *TranslationTestSuite) TestTranslateWithWhisper() {
+	job := suite.createTranscribed("job-whisper", "Bonjour.")
+
+	_, err := suite.service.Start(job.ID, translation.Request{Languages: []string{"de"}, Provider: models.TranslationProviderWhisper})
+	assert.Error(suite.T(), err, "whisper only translates into English")
+
+	translations, err := suite.service.Start(job.ID, translation.Request{Languages: []string{"en"}, Provider: models.TranslationProviderWhisper})
+	require.NoError(suite.T(), err)
+	t, result := suite.translated(translations[0].ID)
+	assert.Equal(suite.T(), models.TranslationCompleted, t.Status)
+	require.Len(suite.T(), suite.transcribed, 1)
+	assert.Equal(suite.T(), "translate", suite.transcribed[0].Task)
+	require.NotNil(suite.T(), result)
+	assert.Equal(suite.T(), "en", result.Language)
+	assert.Empty(suite.T(), suite.fake.prompts)
+}
+
+// Test jobs that finish with translate_to set are translated automatically
+func (suite *TranslationTestSuite) TestTranslateOnCompletion() {
+	stop := suite.service.Track()
+	defer stop()
+
+	transcript := `{"text":"Salut.","language":"fr","segments":[{"start":0,"end":1,"text":"Salut."}]}`
+	languages := "en, ES,en"
+	job := &models.TranscriptionJob{ID: "job-auto", AudioPath: "auto.mp3", Status: models.StatusProcessing, Transcript: &transcript}
+	job.Parameters.TranslateTo = &languages
+	require.NoError(suite.T(), suite.helper.DB.Create(job).Error)
+	_, err := jobstate.Transition(job.ID, models.StatusCompleted)
+	require.NoError(suite.T(), err)
+
+	var translations []models.Translation
+	require.Eventually(suite.T(), func() bool {
+		suite.helper.DB.Where("transcription_id = ?", job.ID).Order("target_language ASC").Find(&translations)
+		return len(translations) == 2
+	}, 5*time.Second, 20*time.Millisecond)
+	assert.Equal(suite.T(), "en", translations[0].TargetLanguage)
+	assert.Equal(suite.T(), "es", translations[1].TargetLanguage)
+	_, result := suite.translated(translations[1].ID)
+	require.NotNil(suite.T(), result)
+	assert.Equal(suite.T(), "[es] Salut.", result.Text)
+}
+
+// Test transcripts that cannot be translated are refused up front
+func (suite *TranslationTestSuite) TestRefusesUntranslatable() {
+	pending := &models.TranscriptionJob{ID: "job-pending", AudioPath: "pending.mp3", Status: models.StatusPending}
+	require.NoError(suite.T(), suite.helper.DB.Create(pending).Error)
+	_, err := suite.service.Start(pending.ID, translation.Request{Languages: []string{"en"}})
+	assert.ErrorIs(suite.T(), err, translation.ErrNotTranscribed)
+
+	job := suite.createTranscribed("job-nomodel", "Bonjour.")
+	require.NoError(suite.T(), suite.helper.DB.Where("1 = 1").Delete(&models.SummarySetting{}).Error)
+	_, err = suite.service.Start(job.ID, translation.Request{Languages: []string{"en"}})
+	assert.ErrorIs(suite.T(), err, translation.ErrNoModel)
+}
+
+func TestTranslationTestSuite(t *testing.T) {
+	suite.Run(t, new(TranslationTestSuite))
+}
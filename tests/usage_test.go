@@ -0,0 +1,135 @@
+package tests
+
+import (
+	"testing"
+	"time"
+
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/internal/usage"
+	"synthezia/pkg/clock"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+type UsageTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	clock   *clock.Fake
+	tracker *usage.Tracker
+	key     models.APIKey
+}
+
+func (suite *UsageTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "usage_test.db")
+	start := time.Date(2024, 5, 1, 0, 30, 0, 0, time.UTC)
+	suite.clock = clock.NewFake(start)
+	suite.tracker = usage.NewTracker(suite.helper.DB)
+	suite.tracker.SetClock(suite.clock)
+
+	suite.key = models.APIKey{Key: "usage-key", Name: "CI", IsActive: true, CreatedAt: start}
+	require.NoError(suite.T(), suite.helper.DB.Create(&suite.key).Error)
+}
+
+func (suite *UsageTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+// record makes n requests in the current fake hour, failed of them failing
+func (suite *UsageTestSuite) record(n, failed int) {
+	for i := 0; i < n; i++ {
+		require.NoError(suite.T(), suite.tracker.Record(suite.key.ID, i < failed))
+	}
+}
+
+// Test requests are bucketed per hour and summed
+func (suite *UsageTestSuite) TestHourlyBuckets() {
+	suite.record(3, 1)
+	suite.clock.Advance(time.Hour)
+	suite.record(2, 0)
+	require.NoError(suite.T(), suite.tracker.RecordAudio(suite.key.ID, 90))
+
+	buckets, err := suite.tracker.Hourly(suite.key.ID, suite.clock.Now().Add(-2*time.Hour))
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), buckets, 2)
+	assert.Equal(suite.T(), int64(3), buckets[0].Requests)
+	assert.Equal(suite.T(), int64(2), buckets[1].Requests)
+
+	totals, err := suite.tracker.Usage(suite.key.ID, suite.clock.Now().Add(-2*time.Hour))
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), int64(5), totals.Requests)
+	assert.Equal(suite.T(), int64(1), totals.Errors)
+	assert.InDelta(suite.T(), 0.2, totals.ErrorRate, 1e-9)
+	assert.Equal(suite.T(), 1.5, totals.AudioMinutes)
+}
+
+// Test a sharp rise over the baseline raises one alert
+func (suite *UsageTestSuite) TestRequestSpike() {
+	for hour := 0; hour < 48; hour++ {
+		suite.record(2, 0)
+		suite.clock.Advance(time.Hour)
+	}
+
+	alerts, err := suite.tracker.Check(suite.key)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), alerts)
+
+	suite.record(150, 0)
+	alerts, err = suite.tracker.Check(suite.key)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), alerts, 1)
+	assert.Equal(suite.T(), usage.AlertRequestSpike, alerts[0].Kind)
+	assert.Equal(suite.T(), 150.0, alerts[0].Observed)
+	assert.InDelta(suite.T(), 2.0, alerts[0].Baseline, 0.1)
+
+	// The same anomaly is not reported twice within the cooldown
+	alerts, err = suite.tracker.Check(suite.key)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), alerts)
+}
+
+// Test a jump in failed requests raises an error alert
+func (suite *UsageTestSuite) TestErrorSpike() {
+	for hour := 0; hour < 30; hour++ {
+		suite.record(10, 0)
+		suite.clock.Advance(time.Hour)
+	}
+	suite.record(30, 25)
+
+	alerts, err := suite.tracker.Check(suite.key)
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), alerts, 1)
+	assert.Equal(suite.T(), usage.AlertErrorSpike, alerts[0].Kind)
+}
+
+// Test keys without enough history are not compared
+func (suite *UsageTestSuite) TestNewKeyIsNotChecked() {
+	suite.record(500, 0)
+	alerts, err := suite.tracker.Check(suite.key)
+	require.NoError(suite.T(), err)
+	assert.Empty(suite.T(), alerts)
+}
+
+// Test completed jobs add their audio to the submitting key
+func (suite *UsageTestSuite) TestTrackJobs() {
+	stop := suite.tracker.TrackJobs()
+	defer stop()
+
+	transcript := `{"segments":[{"start":0,"end":30.5,"text":"a"},{"start":30.5,"end":120,"text":"b"}]}`
+	job := models.TranscriptionJob{ID: "usage-job", AudioPath: "a.mp3", Status: models.StatusProcessing, APIKeyID: &suite.key.ID, Transcript: &transcript}
+	require.NoError(suite.T(), suite.helper.DB.Create(&job).Error)
+
+	_, err := jobstate.Transition(job.ID, models.StatusCompleted)
+	require.NoError(suite.T(), err)
+
+	assert.Eventually(suite.T(), func() bool {
+		totals, _ := suite.tracker.Usage(suite.key.ID, suite.clock.Now().Add(-time.Hour))
+		return totals.AudioMinutes == 2
+	}, 2*time.Second, 10*time.Millisecond)
+}
+
+func TestUsageTestSuite(t *testing.T) {
+	suite.Run(t, new(UsageTestSuite))
+}
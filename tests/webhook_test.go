@@ -0,0 +1,205 @@
+package tests
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"synthezia/internal/jobstate"
+	"synthezia/internal/models"
+	"synthezia/internal/webhook"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/stretchr/testify/suite"
+)
+
+// receivedDelivery is a request the test receiver got
+type receivedDelivery struct {
+	header http.Header
+	body   []byte
+}
+
+type WebhookTestSuite struct {
+	suite.Suite
+	helper  *TestHelper
+	server  *httptest.Server
+	service *webhook.Service
+
+	mu       sync.Mutex
+	statuses []int // Answers to the next requests; 200 once used up
+	received []receivedDelivery
+}
+
+func (suite *WebhookTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "webhook_test.db")
+	suite.statuses = nil
+	suite.received = nil
+	suite.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		suite.mu.Lock()
+		suite.received = append(suite.received, receivedDelivery{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(suite.statuses) > 0 {
+			status, suite.statuses = suite.statuses[0], suite.statuses[1:]
+		}
+		suite.mu.Unlock()
+		w.WriteHeader(status)
+		if status != http.StatusOK {
+			w.Write([]byte("try again later"))
+		}
+	}))
+	suite.service = webhook.NewService(suite.helper.DB)
+	suite.service.SetBackoff([]time.Duration{time.Millisecond, time.Millisecond})
+}
+
+func (suite *WebhookTestSuite) TearDownTest() {
+	suite.server.Close()
+	suite.helper.Cleanup()
+}
+
+func (suite *WebhookTestSuite) createWebhook(events string, active bool) *models.Webhook {
+	hook := &models.Webhook{UserID: suite.helper.TestUser.ID, URL: suite.server.URL + "/hook", Secret: "s3cret", Events: events, IsActive: active}
+	require.NoError(suite.T(), suite.helper.DB.Create(hook).Error)
+	return hook
+}
+
+func (suite *WebhookTestSuite) createJob(id string, status models.JobStatus) *models.TranscriptionJob {
+	title := "Weekly sync"
+	job := &models.TranscriptionJob{ID: id, Title: &title, AudioPath: id + ".mp3", Status: status}
+	require.NoError(suite.T(), suite.helper.DB.Create(job).Error)
+	return job
+}
+
+func (suite *WebhookTestSuite) delivery(id uint) models.WebhookDelivery {
+	suite.service.Wait(id)
+	var delivery models.WebhookDelivery
+	require.NoError(suite.T(), suite.helper.DB.First(&delivery, id).Error)
+	return delivery
+}
+
+// Test a completed job is posted, signed, to the webhooks subscribed to it
+func (suite *WebhookTestSuite) TestDeliversSignedEvent() {
+	hook := suite.createWebhook("", true)
+	suite.createWebhook(webhook.EventJobFailed, true)
+	suite.createWebhook("", false)
+	job := suite.createJob("job-done", models.StatusCompleted)
+
+	at := time.Date(2026, 3, 4, 5, 6, 7, 0, time.UTC)
+	deliveries, err := suite.service.Notify(jobstate.Event{JobID: job.ID, From: models.StatusProcessing, To: models.StatusCompleted, At: at})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), deliveries, 1, "only the active webhook subscribed to completions is told")
+	assert.Equal(suite.T(), hook.ID, deliveries[0].WebhookID)
+
+	delivery := suite.delivery(deliveries[0].ID)
+	assert.Equal(suite.T(), models.WebhookDeliverySucceeded, delivery.Status)
+	assert.Equal(suite.T(), 1, delivery.Attempts)
+	require.NotNil(suite.T(), delivery.ResponseStatus)
+	assert.Equal(suite.T(), http.StatusOK, *delivery.ResponseStatus)
+	assert.NotNil(suite.T(), delivery.DeliveredAt)
+
+	require.Len(suite.T(), suite.received, 1)
+	received := suite.received[0]
+	assert.Equal(suite.T(), webhook.EventJobCompleted, received.header.Get(webhook.HeaderEvent))
+	timestamp := received.header.Get(webhook.HeaderTimestamp)
+	assert.Equal(suite.T(), webhook.Sign("s3cret", timestamp, received.body), received.header.Get(webhook.HeaderSignature))
+
+	var payload webhook.Payload
+	require.NoError(suite.T(), json.Unmarshal(received.body, &payload))
+	assert.Equal(suite.T(), webhook.Payload{
+		Event:      webhook.EventJobCompleted,
+		DeliveryID: delivery.ID,
+		JobID:      job.ID,
+		Title:      "Weekly sync",
+		Status:     models.StatusCompleted,
+		OccurredAt: at,
+	}, payload)
+}
+
+// Test failed attempts are retried until the receiver accepts the delivery
+func (suite *WebhookTestSuite) TestRetriesWithBackoff() {
+	suite.createWebhook("", true)
+	job := suite.createJob("job-retry", models.StatusFailed)
+	suite.statuses = []int{http.StatusServiceUnavailable, http.StatusInternalServerError}
+
+	deliveries, err := suite.service.Notify(jobstate.Event{JobID: job.ID, From: models.StatusProcessing, To: models.StatusFailed, Error: "out of memory", At: time.Now()})
+	require.NoError(suite.T(), err)
+	require.Len(suite.T(), deliveries, 1)
+
+	delivery := suite.delivery(deliveries[0].ID)
+	assert.Equal(suite.T(), models.WebhookDeliverySucceeded, delivery.Status)
+	assert.Equal(suite.T(), 3, delivery.Attempts)
+	assert.Nil(suite.T(), delivery.Error)
+	assert.Nil(suite.T(), delivery.NextAttemptAt)
+	require.Len(suite.T(), suite.received, 3)
+	assert.Equal(suite.T(), suite.received[0].body, suite.received[2].body, "retries send the same payload")
+	assert.Contains(suite.T(), string(suite.received[0].body), `"error":"out of memory"`)
+}
+
+// Test a delivery fails once its retries run out, keeping the last answer
+func (suite *WebhookTestSuite) TestGivesUpAfterRetries() {
+	suite.createWebhook("", true)
+	job := suite.createJob("job-refused", models.StatusCompleted)
+	suite.statuses = []int{http.StatusBadGateway, http.StatusBadGateway, http.StatusBadGateway}
+
+	deliveries, err := suite.service.Notify(jobstate.Event{JobID: job.ID, From: models.StatusProcessing, To: models.StatusCompleted, At: time.Now()})
+	require.NoError(suite.T(), err)
+
+	delivery := suite.delivery(deliveries[0].ID)
+	assert.Equal(suite.T(), models.WebhookDeliveryFailed, delivery.Status)
+	assert.Equal(suite.T(), 3, delivery.Attempts)
+	require.NotNil(suite.T(), delivery.ResponseStatus)
+	assert.Equal(suite.T(), http.StatusBadGateway, *delivery.ResponseStatus)
+	require.NotNil(suite.T(), delivery.Error)
+	assert.Equal(suite.T(), "receiver answered 502: try again later", *delivery.Error)
+}
+
+// Test pending deliveries are picked up again after a restart
+func (suite *WebhookTestSuite) TestResumesPendingDeliveries() {
+	hook := suite.createWebhook("", true)
+	pending := &models.WebhookDelivery{WebhookID: hook.ID, JobID: "job-resumed", Event: webhook.EventJobCompleted, Payload: `{"event":"job.completed"}`, Status: models.WebhookDeliveryPending, Attempts: 1}
+	require.NoError(suite.T(), suite.helper.DB.Create(pending).Error)
+
+	suite.service.Resume()
+	delivery := suite.delivery(pending.ID)
+	assert.Equal(suite.T(), models.WebhookDeliverySucceeded, delivery.Status)
+	assert.Equal(suite.T(), 2, delivery.Attempts)
+}
+
+// Test job status changes are delivered while tracking
+func (suite *WebhookTestSuite) TestTracksJobCompletion() {
+	suite.createWebhook(webhook.EventJobCompleted, true)
+	job := suite.createJob("job-tracked", models.StatusProcessing)
+
+	stop := suite.service.Track()
+	defer stop()
+	_, err := jobstate.Transition(job.ID, models.StatusCompleted)
+	require.NoError(suite.T(), err)
+
+	var delivery models.WebhookDelivery
+	require.Eventually(suite.T(), func() bool {
+		return suite.helper.DB.Where("job_id = ? AND status = ?", job.ID, models.WebhookDeliverySucceeded).First(&delivery).Error == nil
+	}, 5*time.Second, 10*time.Millisecond)
+	assert.Equal(suite.T(), webhook.EventJobCompleted, delivery.Event)
+}
+
+// Test event filters only accept known events
+func (suite *WebhookTestSuite) TestNormalizeEvents() {
+	events, err := webhook.NormalizeEvents([]string{"job.failed", " job.completed ", "job.failed", ""})
+	require.NoError(suite.T(), err)
+	assert.Equal(suite.T(), []string{webhook.EventJobCompleted, webhook.EventJobFailed}, events)
+
+	_, err = webhook.NormalizeEvents([]string{"job.started"})
+	assert.ErrorIs(suite.T(), err, webhook.ErrUnknownEvent)
+	assert.ErrorIs(suite.T(), webhook.ValidateURL("ftp://example.com/hook"), webhook.ErrInvalidURL)
+	assert.ErrorIs(suite.T(), webhook.ValidateURL("/hook"), webhook.ErrInvalidURL)
+	assert.NoError(suite.T(), webhook.ValidateURL("https://example.com/hook"))
+}
+
+func TestWebhookTestSuite(t *testing.T) {
+	suite.Run(t, new(WebhookTestSuite))
+}
@@ -0,0 +1,163 @@
+package tests
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"synthezia/internal/jobparams"
+	"synthezia/internal/models"
+	"synthezia/internal/whispermodels"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/suite"
+)
+
+// fakeModelPython lays a model out in the cache the way huggingface_hub
+// does, snapshot files linking to blobs, and records its arguments. It is
+// called as python -c <script> <repo> <cache dir> [<device> <compute type>].
+const fakeModelPython = `#!/bin/sh
+echo "$3 $5 $6" >> "$(dirname "$0")/calls"
+dir="$4/models--$(echo "$3" | sed 's|/|--|')"
+mkdir -p "$dir/blobs" "$dir/snapshots/main"
+printf 'weights' > "$dir/blobs/abc"
+ln -sf ../../blobs/abc "$dir/snapshots/main/model.bin"
+`
+
+const failingModelPython = `#!/bin/sh
+echo "Traceback (most recent call last):" >&2
+echo "OSError: repository not reachable" >&2
+exit 1
+`
+
+type WhisperModelsTestSuite struct {
+	suite.Suite
+	helper *TestHelper
+	dir    string
+}
+
+func (suite *WhisperModelsTestSuite) SetupTest() {
+	suite.helper = NewTestHelper(suite.T(), "whispermodels_test.db")
+	suite.dir = suite.T().TempDir()
+}
+
+func (suite *WhisperModelsTestSuite) TearDownTest() {
+	suite.helper.Cleanup()
+}
+
+func (suite *WhisperModelsTestSuite) newManager(script string, jobs jobparams.Options) *whispermodels.Manager {
+	python := filepath.Join(suite.dir, "python")
+	suite.Require().NoError(os.WriteFile(python, []byte(script), 0755))
+	return whispermodels.NewManager(suite.helper.DB, whispermodels.Options{
+		CacheDir: filepath.Join(suite.dir, "hub"),
+		Python:   []string{python},
+		Jobs:     jobs,
+	})
+}
+
+func (suite *WhisperModelsTestSuite) calls() []string {
+	data, _ := os.ReadFile(filepath.Join(suite.dir, "calls"))
+	return strings.Split(strings.TrimSpace(string(data)), "\n")
+}
+
+func (suite *WhisperModelsTestSuite) TestList() {
+	jobs := jobparams.Default
+	jobs.Models = []string{"small", "medium"}
+	manager := suite.newManager(fakeModelPython, jobs)
+
+	list := manager.List()
+	suite.Require().Len(list, len(whispermodels.Names))
+	assert.Equal(suite.T(), "tiny.en", list[0].Name)
+	for _, model := range list {
+		assert.False(suite.T(), model.Downloaded, model.Name)
+		assert.Equal(suite.T(), model.Name == "small", model.Default, model.Name)
+		assert.Equal(suite.T(), model.Name == "small" || model.Name == "medium", model.Allowed, model.Name)
+	}
+
+	_, err := manager.Get("enormous")
+	assert.ErrorIs(suite.T(), err, whispermodels.ErrUnknownModel)
+}
+
+func (suite *WhisperModelsTestSuite) TestDownload() {
+	manager := suite.newManager(fakeModelPython, jobparams.Default)
+
+	started, err := manager.Download("large-v3")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), started)
+	manager.Wait("large-v3")
+
+	model, err := manager.Get("large-v3")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), model.Downloaded)
+	assert.False(suite.T(), model.Downloading)
+	assert.Equal(suite.T(), int64(len("weights")), model.SizeBytes, "snapshot links are not counted twice")
+	assert.Equal(suite.T(), []string{"Systran/faster-whisper-large-v3"}, suite.calls(), "no device is given, so the model is not loaded")
+
+	alias, _ := manager.Get("large")
+	assert.True(suite.T(), alias.Downloaded, "large shares the weights of large-v3")
+	started, err = manager.Download("large")
+	suite.Require().NoError(err)
+	assert.False(suite.T(), started)
+
+	_, err = manager.Download("enormous")
+	assert.ErrorIs(suite.T(), err, whispermodels.ErrUnknownModel)
+}
+
+func (suite *WhisperModelsTestSuite) TestDownloadFailure() {
+	manager := suite.newManager(failingModelPython, jobparams.Default)
+
+	started, err := manager.Download("tiny")
+	suite.Require().NoError(err)
+	assert.True(suite.T(), started)
+	manager.Wait("tiny")
+
+	model, _ := manager.Get("tiny")
+	assert.False(suite.T(), model.Downloaded)
+	assert.Contains(suite.T(), model.Error, "OSError: repository not reachable")
+}
+
+func (suite *WhisperModelsTestSuite) TestDelete() {
+	manager := suite.newManager(fakeModelPython, jobparams.Default)
+
+	assert.ErrorIs(suite.T(), manager.Delete("base"), whispermodels.ErrNotDownloaded)
+	for _, name := range []string{"base", "small"} {
+		manager.Download(name)
+		manager.Wait(name)
+	}
+
+	assert.ErrorIs(suite.T(), manager.Delete("small"), whispermodels.ErrInUse, "the default model is kept")
+
+	job := suite.helper.CreateTestTranscriptionJob(suite.T(), "Uses base")
+	assert.ErrorIs(suite.T(), manager.Delete("base"), whispermodels.ErrInUse, "a queued job picked it")
+
+	suite.Require().NoError(suite.helper.DB.Model(job).Update("status", models.StatusCompleted).Error)
+	suite.Require().NoError(manager.Delete("base"))
+	model, _ := manager.Get("base")
+	assert.False(suite.T(), model.Downloaded)
+	assert.NoDirExists(suite.T(), filepath.Join(manager.CacheDir(), "models--Systran--faster-whisper-base"))
+}
+
+func (suite *WhisperModelsTestSuite) TestWarmUp() {
+	jobs := jobparams.Default
+	jobs.Model = "medium.en"
+	jobs.Device = "cuda"
+	jobs.ComputeType = "float16"
+	manager := suite.newManager(fakeModelPython, jobs)
+
+	suite.Require().NoError(manager.WarmUp(context.Background()))
+	model, _ := manager.Get("medium.en")
+	assert.True(suite.T(), model.Downloaded)
+	assert.Equal(suite.T(), []string{"Systran/faster-whisper-medium.en cuda float16"}, suite.calls())
+
+	failing := suite.newManager(failingModelPython, jobs)
+	assert.ErrorContains(suite.T(), failing.WarmUp(context.Background()), "repository not reachable")
+
+	jobs.Model = "Systran/custom-model"
+	assert.ErrorIs(suite.T(), suite.newManager(fakeModelPython, jobs).WarmUp(context.Background()), whispermodels.ErrUnknownModel)
+}
+
+func TestWhisperModelsTestSuite(t *testing.T) {
+	suite.Run(t, new(WhisperModelsTestSuite))
+}
@@ -856,15 +856,26 @@ useEffect(() => {
     };
 
     const updateNote = async (id: string, newContent: string) => {
-        await apiClient(`/api/v1/notes/${id}`, {
+        const res = await apiClient(`/api/v1/notes/${id}`, {
             method: 'PUT',
             body: JSON.stringify({ content: newContent }),
+            jobId: audioId,
         });
+        if (!res.ok) {
+            const data = await res.json().catch(() => null);
+            toast({ title: 'Failed to update note', description: data?.error });
+            return;
+        }
         setNotes(prev => prev.map(n => n.id === id ? { ...n, content: newContent } : n));
     };
 
     const deleteNote = async (id: string) => {
-        await apiClient(`/api/v1/notes/${id}`, { method: 'DELETE' });
+        const res = await apiClient(`/api/v1/notes/${id}`, { method: 'DELETE', jobId: audioId });
+        if (!res.ok) {
+            const data = await res.json().catch(() => null);
+            toast({ title: 'Failed to delete note', description: data?.error });
+            return;
+        }
         setNotes(prev => prev.filter(n => n.id !== id));
     };
 
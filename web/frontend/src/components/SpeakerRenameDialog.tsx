@@ -112,7 +112,9 @@ const SpeakerRenameDialog: React.FC<SpeakerRenameDialogProps> = ({
       });
 
       if (!response.ok) {
-        throw new Error(`Failed to save speaker mappings: ${response.statusText}`);
+        // A 409 means someone else changed the job since it was loaded
+        const data = await response.json().catch(() => null);
+        throw new Error(data?.error || `Failed to save speaker mappings: ${response.statusText}`);
       }
 
       const updatedMappings: SpeakerMapping[] = await response.json();
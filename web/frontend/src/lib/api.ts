@@ -31,12 +31,49 @@ export const isTokenExpired = (token: string): boolean => {
 	}
 };
 
+const CSRF_COOKIE = "synthezia_csrf_token";
+const SAFE_METHODS = ["GET", "HEAD", "OPTIONS"];
+
+export const getCSRFToken = (): string | null => {
+	const match = document.cookie
+		.split("; ")
+		.find((row) => row.startsWith(`${CSRF_COOKIE}=`));
+	return match ? decodeURIComponent(match.split("=")[1]) : null;
+};
+
+// Ensure a CSRF token cookie exists before a state-changing request
+const ensureCSRFToken = async (): Promise<string | null> => {
+	const existing = getCSRFToken();
+	if (existing) {
+		return existing;
+	}
+	try {
+		const res = await fetch("/api/v1/auth/csrf");
+		if (res.ok) {
+			const data = await res.json();
+			return data.csrf_token ?? getCSRFToken();
+		}
+	} catch {
+		// Ignore - the server will reject the request if a token is required
+	}
+	return null;
+};
+
+// Last version seen of each job, from the ETag of its responses. Edits send
+// it back in If-Match so the server refuses them with 409 Conflict when
+// someone else changed the job in the meantime, instead of overwriting.
+const jobVersions = new Map<string, string>();
+const JOB_URL = /^\/api\/v1\/transcription\/([^/?]+)/;
+
 interface ApiOptions extends RequestInit {
 	skipAuth?: boolean;
+	// The job a request reads or edits, for URLs that do not name it
+	jobId?: string;
 }
 
 export const apiClient = async (url: string, options: ApiOptions = {}) => {
-	const { skipAuth, ...fetchOptions } = options;
+	const { skipAuth, jobId: givenJobId, ...fetchOptions } = options;
+	const jobId = givenJobId ?? url.match(JOB_URL)?.[1];
 	
 	// Add default headers
 	const headers = new Headers(fetchOptions.headers);
@@ -49,6 +86,19 @@ export const apiClient = async (url: string, options: ApiOptions = {}) => {
 		}
 	}
 
+	// Echo the CSRF token on state-changing requests (double-submit cookie)
+	const method = (fetchOptions.method || "GET").toUpperCase();
+	if (!SAFE_METHODS.includes(method)) {
+		const csrfToken = await ensureCSRFToken();
+		if (csrfToken) {
+			headers.set("X-CSRF-Token", csrfToken);
+		}
+	}
+	const version = jobId ? jobVersions.get(jobId) : undefined;
+	if (version && !SAFE_METHODS.includes(method) && !headers.has("If-Match")) {
+		headers.set("If-Match", version);
+	}
+
 	// Ensure Content-Type is JSON if body is present and not FormData
 	if (fetchOptions.body && !(fetchOptions.body instanceof FormData) && !headers.has("Content-Type")) {
 		headers.set("Content-Type", "application/json");
@@ -65,7 +115,11 @@ export const apiClient = async (url: string, options: ApiOptions = {}) => {
 	if (response.status === 401 && !skipAuth && !url.includes("/auth/login") && !url.includes("/auth/refresh")) {
 		try {
 			// Try to refresh the token
-			const refreshResponse = await fetch("/api/v1/auth/refresh", { method: "POST" });
+			const csrfToken = await ensureCSRFToken();
+			const refreshResponse = await fetch("/api/v1/auth/refresh", {
+				method: "POST",
+				headers: csrfToken ? { "X-CSRF-Token": csrfToken } : undefined,
+			});
 			
 			if (refreshResponse.ok) {
 				const data = await refreshResponse.json();
@@ -91,5 +145,12 @@ export const apiClient = async (url: string, options: ApiOptions = {}) => {
 		}
 	}
 
+	// A refused edit keeps the version the page was loaded at; reloading
+	// the job picks up the current one
+	const etag = response.headers.get("ETag");
+	if (jobId && etag && response.ok) {
+		jobVersions.set(jobId, etag);
+	}
+
 	return response;
 };